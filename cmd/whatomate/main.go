@@ -2,24 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/shridarpatil/whatomate/internal/config"
 	"github.com/shridarpatil/whatomate/internal/database"
-	"github.com/shridarpatil/whatomate/internal/frontend"
 	"github.com/shridarpatil/whatomate/internal/handlers"
-	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/leader"
+	"github.com/shridarpatil/whatomate/internal/loadgen"
+	"github.com/shridarpatil/whatomate/internal/logredact"
+	"github.com/shridarpatil/whatomate/internal/plugins"
 	"github.com/shridarpatil/whatomate/internal/queue"
+	httpserver "github.com/shridarpatil/whatomate/internal/server"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/internal/worker"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
-	"github.com/valyala/fasthttp"
-	"github.com/zerodha/fastglue"
 	"github.com/zerodha/logf"
 )
 
@@ -39,6 +42,8 @@ func main() {
 		runServer(os.Args[2:])
 	case "worker":
 		runWorker(os.Args[2:])
+	case "loadgen":
+		runLoadgen(os.Args[2:])
 	case "version":
 		fmt.Printf("Whatomate %s (built %s)\n", Version, BuildTime)
 	case "help", "-h", "--help":
@@ -59,24 +64,41 @@ Usage:
 Commands:
   server    Start the API server (with optional embedded workers)
   worker    Start background workers only (no API server)
+  loadgen   Generate synthetic traffic against a running server
   version   Show version information
   help      Show this help message
 
 Server Options:
   -config string    Path to config file (default "config.toml")
   -migrate          Run database migrations on startup
+  -unpartition      Convert partitioned tables back to plain tables and exit
   -workers int      Number of embedded workers (0 to disable) (default 1)
 
 Worker Options:
   -config string    Path to config file (default "config.toml")
   -workers int      Number of workers to run (default 1)
 
+Loadgen Options:
+  -url string           Server to load (default "http://localhost:8080")
+  -email string         Login of the user sending messages (default "admin@admin.com")
+  -password string      Password of that user (default "admin")
+  -account string       WhatsApp account to simulate (default: the first one)
+  -mock-addr string     Listen address of the mock Meta backend (default "127.0.0.1:9090")
+  -meta-latency dur     Delay the mock Meta backend adds to each call (default 100ms)
+  -webhook-rate float   Inbound messages per second (default 10)
+  -send-rate float      API sends per second (default 5)
+  -contacts int         Number of synthetic contacts (default 100)
+  -duration dur         How long to generate traffic (default 1m)
+  -drain dur            How long to wait for outstanding messages (default 10s)
+  -json                 Print the report as JSON
+
 Examples:
   whatomate server                     # API + 1 embedded worker
   whatomate server -workers 0          # API only (no workers)
   whatomate server -workers 4          # API + 4 embedded workers
   whatomate server -migrate            # Run migrations and start server
   whatomate worker -workers 4          # 4 workers only (no API)
+  whatomate loadgen -webhook-rate 50   # 50 inbound messages/s for a minute
 
 Deployment Scenarios:
   All-in-one:    whatomate server
@@ -92,6 +114,7 @@ func runServer(args []string) {
 	serverFlags := flag.NewFlagSet("server", flag.ExitOnError)
 	configPath := serverFlags.String("config", "config.toml", "Path to config file")
 	migrate := serverFlags.Bool("migrate", false, "Run database migrations")
+	unpartition := serverFlags.Bool("unpartition", false, "Convert partitioned tables back to plain tables, then exit")
 	numWorkers := serverFlags.Int("workers", 1, "Number of workers to run (0 to disable embedded workers)")
 	_ = serverFlags.Parse(args)
 
@@ -112,14 +135,8 @@ func runServer(args []string) {
 		lo.Fatal("Failed to load config", "error", err)
 	}
 
-	// Set log level based on environment
-	if cfg.App.Environment == "production" {
-		lo = logf.New(logf.Opts{
-			Level:           logf.InfoLevel,
-			TimestampFormat: "2006-01-02 15:04:05",
-			DefaultFields:   []any{"app", "whatomate"},
-		})
-	}
+	// Set log level and redaction based on environment
+	lo = newLogger(cfg, lo, "whatomate")
 
 	// Connect to PostgreSQL
 	db, err := database.NewPostgres(&cfg.Database, cfg.App.Debug)
@@ -128,11 +145,37 @@ func runServer(args []string) {
 	}
 	lo.Info("Connected to PostgreSQL")
 
+	// Warn when the connection pool is exhausted
+	poolCtx, poolCancel := context.WithCancel(context.Background())
+	defer poolCancel()
+	if cfg.Database.PoolMonitorInterval > 0 {
+		go database.MonitorPool(poolCtx, db, lo, time.Duration(cfg.Database.PoolMonitorInterval)*time.Second)
+	}
+
+	// Roll back table partitioning if requested
+	if *unpartition {
+		if cfg.Database.Partitioning {
+			lo.Fatal("Set partitioning = false in the config before unpartitioning")
+		}
+		lo.Info("Converting partitioned tables back to plain tables, tables are locked while their rows are copied")
+		if err := database.UnpartitionTables(db); err != nil {
+			lo.Fatal("Unpartitioning failed", "error", err)
+		}
+		lo.Info("Tables unpartitioned")
+		return
+	}
+
 	// Run migrations if requested
 	if *migrate {
 		if err := database.RunMigrationWithProgress(db); err != nil {
 			lo.Fatal("Migration failed", "error", err)
 		}
+		if cfg.Database.Partitioning {
+			lo.Info("Partitioning tables by month, large tables are locked while they are converted")
+			if err := database.PartitionTables(db, lo, cfg.Database.PartitionPremakeMonths); err != nil {
+				lo.Fatal("Partitioning failed", "error", err)
+			}
+		}
 	}
 
 	// Connect to Redis
@@ -146,11 +189,9 @@ func runServer(args []string) {
 	jobQueue := queue.NewRedisQueue(rdb, lo)
 	lo.Info("Job queue initialized")
 
-	// Initialize Fastglue
-	g := fastglue.NewGlue()
-
 	// Initialize WhatsApp client
-	waClient := whatsapp.New(lo)
+	waClient := whatsapp.NewWithBaseURL(lo, cfg.WhatsApp.BaseURL)
+	waClient.SetRateLimit(whatsapp.NewRedisRateLimiter(rdb), cfg.WhatsApp.MessagesPerSecond)
 
 	// Initialize WebSocket hub
 	wsHub := websocket.NewHub(lo)
@@ -168,26 +209,27 @@ func runServer(args []string) {
 		Queue:    jobQueue,
 	}
 
+	// Load plugins from the plugins directory; organizations enable them individually
+	app.Plugins = plugins.NewManager(time.Duration(cfg.Plugins.Timeout)*time.Second, func(plugin, hook string, err error) {
+		lo.Error("Plugin hook failed", "plugin", plugin, "hook", hook, "error", err)
+	})
+	if loaded, err := app.Plugins.LoadDir(cfg.Plugins.Dir); err != nil {
+		lo.Error("Failed to load plugins", "dir", cfg.Plugins.Dir, "error", err)
+	} else if len(loaded) > 0 {
+		lo.Info("Plugins loaded", "plugins", loaded)
+	}
+
 	// Start campaign stats subscriber for real-time WebSocket updates from worker
 	if err := app.StartCampaignStatsSubscriber(); err != nil {
 		lo.Error("Failed to start campaign stats subscriber", "error", err)
 	}
-
-	// Setup middleware (CORS is handled by corsWrapper at fasthttp level)
-	g.Before(middleware.RequestLogger(lo))
-	g.Before(middleware.Recovery(lo))
-
-	// Setup routes
-	setupRoutes(g, app, lo, cfg.Server.BasePath)
-
-	// Create server with CORS wrapper
-	server := &fasthttp.Server{
-		Handler:      corsWrapper(g.Handler()),
-		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
-		Name:         "Whatomate",
+	if err := app.StartContactBulkUpdateSubscriber(); err != nil {
+		lo.Error("Failed to start contact bulk update subscriber", "error", err)
 	}
 
+	// Create the HTTP server with middleware and routes
+	server := httpserver.New(app, lo)
+
 	// Start server in goroutine
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	go func() {
@@ -197,11 +239,19 @@ func runServer(args []string) {
 		}
 	}()
 
-	// Start SLA processor (runs every minute)
-	slaProcessor := handlers.NewSLAProcessor(app, time.Minute)
-	slaCtx, slaCancel := context.WithCancel(context.Background())
-	go slaProcessor.Start(slaCtx)
-	lo.Info("SLA processor started")
+	// Periodic background tasks run on the elected leader only, so several instances
+	// behind a load balancer don't duplicate them; another instance takes over when the
+	// leader goes away
+	app.Leader = leader.New(rdb, leader.DefaultKey, time.Duration(cfg.Cluster.LeaderLeaseTTL)*time.Second, lo)
+	leaderCtx, leaderCancel := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		app.Leader.Run(leaderCtx, func(ctx context.Context) {
+			runSingletonTasks(ctx, app, cfg, lo)
+		})
+	}()
+	lo.Info("Leader election started", "id", app.Leader.ID())
 
 	// Start embedded workers
 	var workers []*worker.Worker
@@ -240,13 +290,14 @@ func runServer(args []string) {
 	// Stop campaign stats subscriber
 	lo.Info("Stopping campaign stats subscriber...")
 	app.StopCampaignStatsSubscriber()
+	app.StopContactBulkUpdateSubscriber()
 	lo.Info("Campaign stats subscriber stopped")
 
-	// Stop SLA processor
-	lo.Info("Stopping SLA processor...")
-	slaCancel()
-	slaProcessor.Stop()
-	lo.Info("SLA processor stopped")
+	// Stop singleton tasks and hand leadership to another instance
+	lo.Info("Stopping background processors...")
+	leaderCancel()
+	<-leaderDone
+	lo.Info("Background processors stopped")
 
 	// Stop workers first
 	if workerCancel != nil {
@@ -266,6 +317,82 @@ func runServer(args []string) {
 	lo.Info("Server stopped")
 }
 
+// runSingletonTasks runs the periodic background processors until ctx is done. It is
+// called each time this instance is elected leader.
+func runSingletonTasks(ctx context.Context, app *handlers.App, cfg *config.Config, lo logf.Logger) {
+	var wg sync.WaitGroup
+	run := func(name string, start func(context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start(ctx)
+		}()
+		lo.Info(name + " started")
+	}
+
+	run("SLA processor", handlers.NewSLAProcessor(app, time.Minute).Start)
+	run("Assignment offer processor", handlers.NewAssignmentOfferProcessor(app, 5*time.Second).Start)
+	run("Contact score processor", handlers.NewContactScoreProcessor(app, time.Hour).Start)
+	run("Sheet sync processor", handlers.NewSheetSyncProcessor(app, time.Minute).Start)
+	run("CRM sync processor", handlers.NewCRMSyncProcessor(app, time.Minute).Start)
+	run("Calendar availability processor", handlers.NewCalendarAvailabilityProcessor(app, time.Minute).Start)
+	run("Shift availability processor", handlers.NewShiftAvailabilityProcessor(app, time.Minute).Start)
+	run("Announcement processor", handlers.NewAnnouncementProcessor(app, time.Minute).Start)
+	run("Campaign scheduler processor", handlers.NewCampaignSchedulerProcessor(app, time.Minute).Start)
+	run("Sequence processor", handlers.NewSequenceProcessor(app, time.Minute).Start)
+	run("Outbox processor", handlers.NewOutboxProcessor(app, 5*time.Second).Start)
+	run("Contact avatar processor", handlers.NewContactAvatarProcessor(app, time.Hour).Start)
+	run("Template sync processor", handlers.NewTemplateSyncProcessor(app, time.Minute).Start)
+	run("Analytics export processor", handlers.NewAnalyticsExportProcessor(app, time.Minute).Start)
+	run("Message archive processor", handlers.NewMessageArchiveProcessor(app, time.Hour).Start)
+	run("Unanswered digest processor", handlers.NewUnansweredDigestProcessor(app, time.Hour).Start)
+	run("API usage rollup processor", handlers.NewAPIUsageRollupProcessor(app, 15*time.Minute).Start)
+	if cfg.Database.Partitioning {
+		// Create upcoming monthly partitions every 6 hours
+		run("Partition maintenance", func(ctx context.Context) {
+			database.MaintainPartitions(ctx, app.DB, lo, cfg.Database.PartitionPremakeMonths, 6*time.Hour)
+		})
+	}
+
+	wg.Wait()
+}
+
+// newLogger returns the logger for the configured environment: info level in
+// production, and with message content and identifiers redacted when enabled
+func newLogger(cfg *config.Config, lo logf.Logger, app string) logf.Logger {
+	opts := lo.Opts
+	if cfg.App.Environment == "production" {
+		opts = logf.Opts{
+			Level:           logf.InfoLevel,
+			TimestampFormat: "2006-01-02 15:04:05",
+			DefaultFields:   []any{"app", app},
+		}
+	}
+	if !logredact.Enabled(cfg.Privacy.LogRedaction, cfg.App.Environment) {
+		return logf.New(opts)
+	}
+
+	redactOpts := logredact.Options{
+		ContentFields:    cfg.Privacy.ContentFields,
+		IdentifierFields: cfg.Privacy.IdentifierFields,
+	}
+	if cfg.Privacy.AuditLogPath != "" {
+		audit, err := os.OpenFile(cfg.Privacy.AuditLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			lo.Error("Failed to open audit log, severe entries are only logged redacted", "error", err, "path", cfg.Privacy.AuditLogPath)
+		} else {
+			redactOpts.Audit = audit
+			if lvl, err := logf.LevelFromString(cfg.Privacy.AuditLevel); err == nil {
+				redactOpts.AuditLevel = lvl
+			}
+		}
+	}
+	opts.Writer = logredact.NewWriter(os.Stderr, redactOpts)
+	lo = logf.New(opts)
+	lo.Info("Log redaction enabled", "audit_log", cfg.Privacy.AuditLogPath != "")
+	return lo
+}
+
 // ============================================================================
 // WORKER COMMAND
 // ============================================================================
@@ -293,14 +420,8 @@ func runWorker(args []string) {
 		lo.Fatal("Failed to load config", "error", err)
 	}
 
-	// Set log level based on environment
-	if cfg.App.Environment == "production" {
-		lo = logf.New(logf.Opts{
-			Level:           logf.InfoLevel,
-			TimestampFormat: "2006-01-02 15:04:05",
-			DefaultFields:   []any{"app", "whatomate-worker"},
-		})
-	}
+	// Set log level and redaction based on environment
+	lo = newLogger(cfg, lo, "whatomate-worker")
 
 	// Connect to PostgreSQL
 	db, err := database.NewPostgres(&cfg.Database, cfg.App.Debug)
@@ -320,6 +441,11 @@ func runWorker(args []string) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Warn when the connection pool is exhausted
+	if cfg.Database.PoolMonitorInterval > 0 {
+		go database.MonitorPool(ctx, db, lo, time.Duration(cfg.Database.PoolMonitorInterval)*time.Second)
+	}
+
 	// Handle shutdown signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -368,313 +494,46 @@ func runWorker(args []string) {
 }
 
 // ============================================================================
-// ROUTES
+// LOADGEN COMMAND
 // ============================================================================
 
-func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePath string) {
-	// Health check
-	g.GET("/health", app.HealthCheck)
-	g.GET("/ready", app.ReadyCheck)
-
-	// Auth routes (public)
-	g.POST("/api/auth/login", app.Login)
-	g.POST("/api/auth/register", app.Register)
-	g.POST("/api/auth/refresh", app.RefreshToken)
-
-	// SSO routes (public)
-	g.GET("/api/auth/sso/providers", app.GetPublicSSOProviders)
-	g.GET("/api/auth/sso/{provider}/init", app.InitSSO)
-	g.GET("/api/auth/sso/{provider}/callback", app.CallbackSSO)
-
-	// Webhook routes (public - for Meta)
-	g.GET("/api/webhook", app.WebhookVerify)
-	g.POST("/api/webhook", app.WebhookHandler)
-
-	// WebSocket route (auth handled in handler via query param)
-	g.GET("/ws", app.WebSocketHandler)
-
-	// For protected routes, we'll use a path-based middleware approach
-	// Apply auth middleware globally but check path in the middleware
-	g.Before(func(r *fastglue.Request) *fastglue.Request {
-		// Skip auth for OPTIONS preflight requests (handled by CORS middleware)
-		if string(r.RequestCtx.Method()) == "OPTIONS" {
-			return r
-		}
-		path := string(r.RequestCtx.Path())
-		// Skip auth for public routes
-		if path == "/health" || path == "/ready" ||
-			path == "/api/auth/login" || path == "/api/auth/register" || path == "/api/auth/refresh" ||
-			path == "/api/webhook" || path == "/ws" {
-			return r
-		}
-		// Skip auth for SSO routes (they handle their own auth via state tokens)
-		if len(path) >= 13 && path[:13] == "/api/auth/sso" {
-			return r
-		}
-		// Skip auth for custom action redirects (uses one-time token)
-		if len(path) >= 28 && path[:28] == "/api/custom-actions/redirect" {
-			return r
-		}
-		// Apply auth for all other /api routes (supports both JWT and API key)
-		if len(path) > 4 && path[:4] == "/api" {
-			return middleware.AuthWithDB(app.Config.JWT.Secret, app.DB)(r)
-		}
-		return r
-	})
+func runLoadgen(args []string) {
+	loadFlags := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	cfg := loadgen.Config{}
+	loadFlags.StringVar(&cfg.URL, "url", "http://localhost:8080", "Base URL of the server to load")
+	loadFlags.StringVar(&cfg.Email, "email", "admin@admin.com", "Login of the user sending messages")
+	loadFlags.StringVar(&cfg.Password, "password", "admin", "Password of that user")
+	loadFlags.StringVar(&cfg.Account, "account", "", "WhatsApp account to simulate (default: the first one)")
+	loadFlags.StringVar(&cfg.MockAddr, "mock-addr", "127.0.0.1:9090", "Listen address of the mock Meta backend")
+	loadFlags.DurationVar(&cfg.MetaLatency, "meta-latency", 100*time.Millisecond, "Delay the mock Meta backend adds to each call")
+	loadFlags.Float64Var(&cfg.WebhookRate, "webhook-rate", 10, "Inbound messages per second")
+	loadFlags.Float64Var(&cfg.SendRate, "send-rate", 5, "API sends per second")
+	loadFlags.IntVar(&cfg.Contacts, "contacts", 100, "Number of synthetic contacts")
+	loadFlags.DurationVar(&cfg.Duration, "duration", time.Minute, "How long to generate traffic")
+	loadFlags.DurationVar(&cfg.Drain, "drain", 10*time.Second, "How long to wait for outstanding messages")
+	asJSON := loadFlags.Bool("json", false, "Print the report as JSON")
+	_ = loadFlags.Parse(args)
 
-	// Role-based access control middleware
-	g.Before(func(r *fastglue.Request) *fastglue.Request {
-		method := string(r.RequestCtx.Method())
-
-		// Skip OPTIONS preflight requests
-		if method == "OPTIONS" {
-			return r
-		}
-
-		// Route-level permission checks are now handled at the handler level
-		// using the granular permission system (HasPermission checks)
-		return r
+	lo := logf.New(logf.Opts{
+		EnableColor:     true,
+		Level:           logf.InfoLevel,
+		TimestampFormat: "2006-01-02 15:04:05",
+		DefaultFields:   []any{"app", "whatomate-loadgen"},
 	})
 
-	// Current User (all authenticated users)
-	g.GET("/api/me", app.GetCurrentUser)
-	g.PUT("/api/me/settings", app.UpdateCurrentUserSettings)
-	g.PUT("/api/me/password", app.ChangePassword)
-	g.PUT("/api/me/availability", app.UpdateAvailability)
-
-	// User Management (admin only - enforced by middleware)
-	g.GET("/api/users", app.ListUsers)
-	g.POST("/api/users", app.CreateUser)
-	g.GET("/api/users/{id}", app.GetUser)
-	g.PUT("/api/users/{id}", app.UpdateUser)
-	g.DELETE("/api/users/{id}", app.DeleteUser)
-
-	// Roles & Permissions (admin only - enforced by middleware)
-	g.GET("/api/roles", app.ListRoles)
-	g.POST("/api/roles", app.CreateRole)
-	g.GET("/api/roles/{id}", app.GetRole)
-	g.PUT("/api/roles/{id}", app.UpdateRole)
-	g.DELETE("/api/roles/{id}", app.DeleteRole)
-	g.GET("/api/permissions", app.ListPermissions)
-
-	// API Keys (admin only - enforced by middleware)
-	g.GET("/api/api-keys", app.ListAPIKeys)
-	g.POST("/api/api-keys", app.CreateAPIKey)
-	g.DELETE("/api/api-keys/{id}", app.DeleteAPIKey)
-
-	// Accounts
-	g.GET("/api/accounts", app.ListAccounts)
-	g.POST("/api/accounts", app.CreateAccount)
-	g.GET("/api/accounts/{id}", app.GetAccount)
-	g.PUT("/api/accounts/{id}", app.UpdateAccount)
-	g.DELETE("/api/accounts/{id}", app.DeleteAccount)
-	g.POST("/api/accounts/{id}/test", app.TestAccountConnection)
-
-	// Contacts
-	g.GET("/api/contacts", app.ListContacts)
-	g.POST("/api/contacts", app.CreateContact)
-	g.GET("/api/contacts/{id}", app.GetContact)
-	g.PUT("/api/contacts/{id}", app.UpdateContact)
-	g.DELETE("/api/contacts/{id}", app.DeleteContact)
-	g.PUT("/api/contacts/{id}/assign", app.AssignContact)
-	g.GET("/api/contacts/{id}/session-data", app.GetContactSessionData)
-
-	// Messages
-	g.GET("/api/contacts/{id}/messages", app.GetMessages)
-	g.POST("/api/contacts/{id}/messages", app.SendMessage)
-	g.POST("/api/contacts/{id}/messages/{message_id}/reaction", app.SendReaction)
-	g.POST("/api/messages", app.SendMessage) // Legacy route
-	g.POST("/api/messages/template", app.SendTemplateMessage)
-	g.POST("/api/messages/media", app.SendMediaMessage)
-	g.PUT("/api/messages/{id}/read", app.MarkMessageRead)
-
-	// Media (serves media files for messages, auth-protected)
-	g.GET("/api/media/{message_id}", app.ServeMedia)
-
-	// Templates
-	g.GET("/api/templates", app.ListTemplates)
-	g.POST("/api/templates", app.CreateTemplate)
-	g.GET("/api/templates/{id}", app.GetTemplate)
-	g.PUT("/api/templates/{id}", app.UpdateTemplate)
-	g.DELETE("/api/templates/{id}", app.DeleteTemplate)
-	g.POST("/api/templates/sync", app.SyncTemplates)
-	g.POST("/api/templates/{id}/publish", app.SubmitTemplate)
-	g.POST("/api/templates/upload-media", app.UploadTemplateMedia)
-
-	// WhatsApp Flows
-	g.GET("/api/flows", app.ListFlows)
-	g.POST("/api/flows", app.CreateFlow)
-	g.GET("/api/flows/{id}", app.GetFlow)
-	g.PUT("/api/flows/{id}", app.UpdateFlow)
-	g.DELETE("/api/flows/{id}", app.DeleteFlow)
-	g.POST("/api/flows/{id}/save-to-meta", app.SaveFlowToMeta)
-	g.POST("/api/flows/{id}/publish", app.PublishFlow)
-	g.POST("/api/flows/{id}/deprecate", app.DeprecateFlow)
-	g.POST("/api/flows/{id}/duplicate", app.DuplicateFlow)
-	g.POST("/api/flows/sync", app.SyncFlows)
-
-	// Bulk Campaigns
-	g.GET("/api/campaigns", app.ListCampaigns)
-	g.POST("/api/campaigns", app.CreateCampaign)
-	g.GET("/api/campaigns/{id}", app.GetCampaign)
-	g.PUT("/api/campaigns/{id}", app.UpdateCampaign)
-	g.DELETE("/api/campaigns/{id}", app.DeleteCampaign)
-	g.POST("/api/campaigns/{id}/start", app.StartCampaign)
-	g.POST("/api/campaigns/{id}/pause", app.PauseCampaign)
-	g.POST("/api/campaigns/{id}/cancel", app.CancelCampaign)
-	g.POST("/api/campaigns/{id}/retry-failed", app.RetryFailed)
-	g.GET("/api/campaigns/{id}/progress", app.GetCampaign)
-	g.POST("/api/campaigns/{id}/recipients/import", app.ImportRecipients)
-	g.GET("/api/campaigns/{id}/recipients", app.GetCampaignRecipients)
-	g.DELETE("/api/campaigns/{id}/recipients/{recipientId}", app.DeleteCampaignRecipient)
-	g.POST("/api/campaigns/{id}/media", app.UploadCampaignMedia)
-	g.GET("/api/campaigns/{id}/media", app.ServeCampaignMedia)
-
-	// Chatbot Settings
-	g.GET("/api/chatbot/settings", app.GetChatbotSettings)
-	g.PUT("/api/chatbot/settings", app.UpdateChatbotSettings)
-
-	// Keyword Rules
-	g.GET("/api/chatbot/keywords", app.ListKeywordRules)
-	g.POST("/api/chatbot/keywords", app.CreateKeywordRule)
-	g.GET("/api/chatbot/keywords/{id}", app.GetKeywordRule)
-	g.PUT("/api/chatbot/keywords/{id}", app.UpdateKeywordRule)
-	g.DELETE("/api/chatbot/keywords/{id}", app.DeleteKeywordRule)
-
-	// Chatbot Flows
-	g.GET("/api/chatbot/flows", app.ListChatbotFlows)
-	g.POST("/api/chatbot/flows", app.CreateChatbotFlow)
-	g.GET("/api/chatbot/flows/{id}", app.GetChatbotFlow)
-	g.PUT("/api/chatbot/flows/{id}", app.UpdateChatbotFlow)
-	g.DELETE("/api/chatbot/flows/{id}", app.DeleteChatbotFlow)
-
-	// AI Contexts
-	g.GET("/api/chatbot/ai-contexts", app.ListAIContexts)
-	g.POST("/api/chatbot/ai-contexts", app.CreateAIContext)
-	g.GET("/api/chatbot/ai-contexts/{id}", app.GetAIContext)
-	g.PUT("/api/chatbot/ai-contexts/{id}", app.UpdateAIContext)
-	g.DELETE("/api/chatbot/ai-contexts/{id}", app.DeleteAIContext)
-
-	// Agent Transfers
-	g.GET("/api/chatbot/transfers", app.ListAgentTransfers)
-	g.POST("/api/chatbot/transfers", app.CreateAgentTransfer)
-	g.POST("/api/chatbot/transfers/pick", app.PickNextTransfer)
-	g.PUT("/api/chatbot/transfers/{id}/resume", app.ResumeFromTransfer)
-	g.PUT("/api/chatbot/transfers/{id}/assign", app.AssignAgentTransfer)
-
-	// Teams (admin/manager - access control in handler)
-	g.GET("/api/teams", app.ListTeams)
-	g.POST("/api/teams", app.CreateTeam)
-	g.GET("/api/teams/{id}", app.GetTeam)
-	g.PUT("/api/teams/{id}", app.UpdateTeam)
-	g.DELETE("/api/teams/{id}", app.DeleteTeam)
-	g.GET("/api/teams/{id}/members", app.ListTeamMembers)
-	g.POST("/api/teams/{id}/members", app.AddTeamMember)
-	g.DELETE("/api/teams/{id}/members/{user_id}", app.RemoveTeamMember)
-
-	// Canned Responses
-	g.GET("/api/canned-responses", app.ListCannedResponses)
-	g.POST("/api/canned-responses", app.CreateCannedResponse)
-	g.GET("/api/canned-responses/{id}", app.GetCannedResponse)
-	g.PUT("/api/canned-responses/{id}", app.UpdateCannedResponse)
-	g.DELETE("/api/canned-responses/{id}", app.DeleteCannedResponse)
-	g.POST("/api/canned-responses/{id}/use", app.IncrementCannedResponseUsage)
-
-	// Sessions (admin/debug)
-	g.GET("/api/chatbot/sessions", app.ListChatbotSessions)
-	g.GET("/api/chatbot/sessions/{id}", app.GetChatbotSession)
-
-	// Analytics
-	g.GET("/api/analytics/dashboard", app.GetDashboardStats)
-	g.GET("/api/analytics/messages", app.GetMessageAnalytics)
-	g.GET("/api/analytics/chatbot", app.GetChatbotAnalytics)
-	g.GET("/api/analytics/agents", app.GetAgentAnalytics)
-	g.GET("/api/analytics/agents/{id}", app.GetAgentDetails)
-	g.GET("/api/analytics/agents/comparison", app.GetAgentComparison)
-
-	// Organization Settings
-	g.GET("/api/org/settings", app.GetOrganizationSettings)
-	g.PUT("/api/org/settings", app.UpdateOrganizationSettings)
-
-	// Organizations (super admin only)
-	g.GET("/api/organizations", app.ListOrganizations)
-	g.GET("/api/organizations/current", app.GetCurrentOrganization)
-
-	// SSO Settings (admin only - enforced by middleware)
-	g.GET("/api/settings/sso", app.GetSSOSettings)
-	g.PUT("/api/settings/sso/{provider}", app.UpdateSSOProvider)
-	g.DELETE("/api/settings/sso/{provider}", app.DeleteSSOProvider)
-
-	// Webhooks
-	g.GET("/api/webhooks", app.ListWebhooks)
-	g.POST("/api/webhooks", app.CreateWebhook)
-	g.GET("/api/webhooks/{id}", app.GetWebhook)
-	g.PUT("/api/webhooks/{id}", app.UpdateWebhook)
-	g.DELETE("/api/webhooks/{id}", app.DeleteWebhook)
-	g.POST("/api/webhooks/{id}/test", app.TestWebhook)
-
-	// Custom Actions
-	g.GET("/api/custom-actions", app.ListCustomActions)
-	g.POST("/api/custom-actions", app.CreateCustomAction)
-	g.GET("/api/custom-actions/{id}", app.GetCustomAction)
-	g.PUT("/api/custom-actions/{id}", app.UpdateCustomAction)
-	g.DELETE("/api/custom-actions/{id}", app.DeleteCustomAction)
-	g.POST("/api/custom-actions/{id}/execute", app.ExecuteCustomAction)
-	g.GET("/api/custom-actions/redirect/{token}", app.CustomActionRedirect)
-
-	// Catalogs
-	g.GET("/api/catalogs", app.ListCatalogs)
-	g.POST("/api/catalogs", app.CreateCatalog)
-	g.GET("/api/catalogs/{id}", app.GetCatalog)
-	g.DELETE("/api/catalogs/{id}", app.DeleteCatalog)
-	g.POST("/api/catalogs/sync", app.SyncCatalogs)
-
-	// Catalog Products
-	g.GET("/api/catalogs/{id}/products", app.ListCatalogProducts)
-	g.POST("/api/catalogs/{id}/products", app.CreateCatalogProduct)
-	g.GET("/api/products/{id}", app.GetCatalogProduct)
-	g.PUT("/api/products/{id}", app.UpdateCatalogProduct)
-	g.DELETE("/api/products/{id}", app.DeleteCatalogProduct)
-
-	// Serve embedded frontend (SPA)
-	if frontend.IsEmbedded() {
-		lo.Info("Serving embedded frontend", "base_path", basePath)
-		frontendHandler := frontend.Handler(basePath)
-		// Catch-all for frontend routes
-		g.GET("/{path:*}", func(r *fastglue.Request) error {
-			frontendHandler(r.RequestCtx)
-			return nil
-		})
-		g.GET("/", func(r *fastglue.Request) error {
-			frontendHandler(r.RequestCtx)
-			return nil
-		})
-	} else {
-		lo.Info("Frontend not embedded, API-only mode")
-	}
-}
-
-// corsWrapper wraps a handler with CORS support at the fasthttp level
-// This ensures CORS headers are set even for auto-handled OPTIONS requests
-func corsWrapper(next fasthttp.RequestHandler) fasthttp.RequestHandler {
-	return func(ctx *fasthttp.RequestCtx) {
-		origin := string(ctx.Request.Header.Peek("Origin"))
-		if origin == "" {
-			origin = "*"
-		}
-
-		ctx.Response.Header.Set("Access-Control-Allow-Origin", origin)
-		ctx.Response.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		ctx.Response.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Requested-With, X-Organization-ID")
-		ctx.Response.Header.Set("Access-Control-Allow-Credentials", "true")
-		ctx.Response.Header.Set("Access-Control-Max-Age", "86400")
-
-		// Handle preflight OPTIONS requests
-		if string(ctx.Method()) == "OPTIONS" {
-			ctx.SetStatusCode(fasthttp.StatusNoContent)
-			return
-		}
+	// Stop generating early on Ctrl+C, still reporting what was measured
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-		next(ctx)
+	report, err := loadgen.Run(ctx, cfg, lo)
+	if err != nil {
+		lo.Fatal("Load test failed", "error", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+		return
 	}
+	report.Print(os.Stdout)
 }
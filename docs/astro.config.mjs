@@ -28,9 +28,12 @@ export default defineConfig({
             { label: 'Chatbot Automation', slug: 'features/chatbot' },
             { label: 'Canned Responses', slug: 'features/canned-responses' },
             { label: 'Custom Actions', slug: 'features/custom-actions' },
+            { label: 'Plugins', slug: 'features/plugins' },
+            { label: 'Pipeline Scripts', slug: 'features/scripting' },
             { label: 'Templates', slug: 'features/templates' },
             { label: 'Campaigns', slug: 'features/campaigns' },
             { label: 'WhatsApp Flows', slug: 'features/whatsapp-flows' },
+            { label: 'CRM Integrations', slug: 'features/crm-integrations' },
           ],
         },
         {
@@ -40,17 +43,29 @@ export default defineConfig({
             { label: 'Authentication', slug: 'api-reference/authentication' },
             { label: 'API Keys', slug: 'api-reference/api-keys' },
             { label: 'Users', slug: 'api-reference/users' },
+            { label: 'Impersonation', slug: 'api-reference/impersonation' },
+            { label: 'Encryption Keys', slug: 'api-reference/encryption-keys' },
             { label: 'Roles', slug: 'api-reference/roles' },
             { label: 'Accounts', slug: 'api-reference/accounts' },
             { label: 'Contacts', slug: 'api-reference/contacts' },
+            { label: 'Segments', slug: 'api-reference/segments' },
             { label: 'Messages', slug: 'api-reference/messages' },
+            { label: 'Search', slug: 'api-reference/search' },
+            { label: 'Transactional API', slug: 'api-reference/transactional' },
+            { label: 'Verifications', slug: 'api-reference/verifications' },
             { label: 'Templates', slug: 'api-reference/templates' },
             { label: 'Flows', slug: 'api-reference/flows' },
             { label: 'Campaigns', slug: 'api-reference/campaigns' },
+            { label: 'Sequences', slug: 'api-reference/sequences' },
             { label: 'Chatbot', slug: 'api-reference/chatbot' },
             { label: 'Canned Responses', slug: 'api-reference/canned-responses' },
             { label: 'Custom Actions', slug: 'api-reference/custom-actions' },
+            { label: 'Announcements', slug: 'api-reference/announcements' },
+            { label: 'Feature Flags', slug: 'api-reference/feature-flags' },
+            { label: 'Instance Admin', slug: 'api-reference/instance-admin' },
             { label: 'Webhooks', slug: 'api-reference/webhooks' },
+            { label: 'Email Gateways', slug: 'api-reference/email-gateways' },
+            { label: 'Error Codes', slug: 'api-reference/errors' },
             { label: 'Analytics', slug: 'api-reference/analytics' },
           ],
         },
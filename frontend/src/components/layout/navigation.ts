@@ -15,7 +15,13 @@ import {
   BarChart3,
   ShieldCheck,
   Zap,
-  Shield
+  Shield,
+  Mail,
+  MessageCircleQuestion,
+  Filter,
+  Braces,
+  CalendarClock,
+  ListOrdered
 } from 'lucide-vue-next'
 import type { Component } from 'vue'
 
@@ -41,6 +47,12 @@ export const navigationItems: NavItem[] = [
     icon: MessageSquare,
     permission: 'chat'
   },
+  {
+    name: 'Approvals',
+    path: '/approvals',
+    icon: ShieldCheck,
+    permission: 'chat'
+  },
   {
     name: 'Chatbot',
     path: '/chatbot',
@@ -51,7 +63,9 @@ export const navigationItems: NavItem[] = [
       { name: 'Overview', path: '/chatbot', icon: Bot, permission: 'settings.chatbot' },
       { name: 'Keywords', path: '/chatbot/keywords', icon: Key, permission: 'chatbot.keywords' },
       { name: 'Flows', path: '/chatbot/flows', icon: Workflow, permission: 'flows.chatbot' },
-      { name: 'AI Contexts', path: '/chatbot/ai', icon: Sparkles, permission: 'chatbot.ai' }
+      { name: 'AI Contexts', path: '/chatbot/ai', icon: Sparkles, permission: 'chatbot.ai' },
+      { name: 'Variables', path: '/chatbot/variables', icon: Braces, permission: 'settings.chatbot' },
+      { name: 'Unanswered', path: '/chatbot/unanswered', icon: MessageCircleQuestion, permission: 'settings.chatbot' }
     ]
   },
   {
@@ -84,6 +98,18 @@ export const navigationItems: NavItem[] = [
     icon: Megaphone,
     permission: 'campaigns'
   },
+  {
+    name: 'Sequences',
+    path: '/sequences',
+    icon: ListOrdered,
+    permission: 'campaigns'
+  },
+  {
+    name: 'Segments',
+    path: '/segments',
+    icon: Filter,
+    permission: 'contacts'
+  },
   {
     name: 'Settings',
     path: '/settings',
@@ -96,10 +122,12 @@ export const navigationItems: NavItem[] = [
       { name: 'Accounts', path: '/settings/accounts', icon: Users, permission: 'accounts' },
       { name: 'Canned Responses', path: '/settings/canned-responses', icon: MessageSquareText, permission: 'canned_responses' },
       { name: 'Teams', path: '/settings/teams', icon: Users, permission: 'teams' },
+      { name: 'Shifts', path: '/settings/shifts', icon: CalendarClock, permission: 'teams' },
       { name: 'Users', path: '/settings/users', icon: Users, permission: 'users' },
       { name: 'Roles', path: '/settings/roles', icon: Shield, permission: 'roles' },
       { name: 'API Keys', path: '/settings/api-keys', icon: Key, permission: 'api_keys' },
       { name: 'Webhooks', path: '/settings/webhooks', icon: Webhook, permission: 'webhooks' },
+      { name: 'Email Gateways', path: '/settings/email-gateways', icon: Mail, permission: 'settings.general' },
       { name: 'Custom Actions', path: '/settings/custom-actions', icon: Zap, permission: 'custom_actions' },
       { name: 'SSO', path: '/settings/sso', icon: ShieldCheck, permission: 'settings.sso' }
     ]
@@ -0,0 +1,49 @@
+import { ref } from 'vue'
+import { useAuthStore } from '@/stores/auth'
+
+// Contact avatars are served by an authenticated endpoint, so <img> can't load them
+// directly. They're fetched once with the token and shared as blob URLs.
+const blobUrls = ref<Record<string, string>>({})
+const pending = new Set<string>()
+
+async function loadAvatar(url: string, token: string) {
+  pending.add(url)
+  try {
+    const basePath = ((window as any).__BASE_PATH__ ?? '').replace(/\/$/, '')
+    const response = await fetch(`${basePath}${url}`, {
+      headers: {
+        'Authorization': `Bearer ${token}`
+      }
+    })
+    if (!response.ok) {
+      throw new Error(`Failed to load avatar: ${response.status}`)
+    }
+    const blob = await response.blob()
+    blobUrls.value[url] = URL.createObjectURL(blob)
+  } catch (error) {
+    // Leave it unset so the initials fallback shows
+    console.error('Failed to load avatar:', error, 'url:', url)
+  }
+}
+
+export function useAvatarUrls() {
+  const authStore = useAuthStore()
+
+  // avatarSrc returns a displayable URL for a contact's avatar_url, starting the fetch
+  // on first use. It's undefined until the image has loaded.
+  function avatarSrc(url?: string): string | undefined {
+    if (!url) return undefined
+    if (!url.startsWith('/api/')) return url
+
+    const cached = blobUrls.value[url]
+    if (cached) return cached
+    if (!pending.has(url) && authStore.token) {
+      loadAvatar(url, authStore.token)
+    }
+    return undefined
+  }
+
+  return {
+    avatarSrc
+  }
+}
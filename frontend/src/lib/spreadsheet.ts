@@ -0,0 +1,154 @@
+// Reads the header row of an uploaded CSV or XLSX file, so columns can be mapped
+// before the file is sent to the server for import.
+
+export async function readSpreadsheetHeaders(file: File): Promise<string[]> {
+  if (file.name.toLowerCase().endsWith('.xlsx')) {
+    return readXlsxHeaders(file)
+  }
+  const text = await file.slice(0, 64 * 1024).text()
+  const firstLine = text.replace(/^\uFEFF/, '').split(/\r?\n/)[0] || ''
+  return parseCSVLine(firstLine).map(h => h.trim()).filter(h => h !== '')
+}
+
+export function parseCSVLine(line: string): string[] {
+  const result: string[] = []
+  let current = ''
+  let inQuotes = false
+
+  for (let i = 0; i < line.length; i++) {
+    const char = line[i]
+    if (char === '"') {
+      if (inQuotes && line[i + 1] === '"') {
+        current += '"'
+        i++
+      } else {
+        inQuotes = !inQuotes
+      }
+    } else if (char === ',' && !inQuotes) {
+      result.push(current)
+      current = ''
+    } else {
+      current += char
+    }
+  }
+  result.push(current)
+  return result
+}
+
+// An .xlsx file is a zip archive; the entries are read from its central directory and
+// inflated with the browser's DecompressionStream
+async function readXlsxHeaders(file: File): Promise<string[]> {
+  const buffer = await file.arrayBuffer()
+  const entries = readZipEntries(buffer)
+
+  const sharedXml = entries.has('xl/sharedStrings.xml') ? await readZipEntry(buffer, entries.get('xl/sharedStrings.xml')!) : ''
+  const shared = sharedXml
+    ? Array.from(parseXml(sharedXml).getElementsByTagName('si')).map(si =>
+        Array.from(si.getElementsByTagName('t'))
+          .filter(t => t.parentElement?.localName !== 'rPh')
+          .map(t => t.textContent || '')
+          .join('')
+      )
+    : []
+
+  const sheetEntry = entries.get(await firstSheetPath(buffer, entries))
+  if (!sheetEntry) throw new Error('Workbook has no sheets')
+  const sheet = parseXml(await readZipEntry(buffer, sheetEntry))
+  const row = sheet.getElementsByTagName('row')[0]
+  if (!row) return []
+
+  const headers: string[] = []
+  for (const cell of Array.from(row.getElementsByTagName('c'))) {
+    const ref = cell.getAttribute('r') || ''
+    const letters = ref.replace(/[0-9]/g, '')
+    let col = headers.length
+    if (letters) {
+      col = letters.split('').reduce((n, ch) => n * 26 + ch.charCodeAt(0) - 64, 0) - 1
+    }
+    let value = ''
+    const type = cell.getAttribute('t')
+    if (type === 's') {
+      value = shared[Number(cell.getElementsByTagName('v')[0]?.textContent)] || ''
+    } else if (type === 'inlineStr') {
+      value = Array.from(cell.getElementsByTagName('t')).map(t => t.textContent || '').join('')
+    } else {
+      value = cell.getElementsByTagName('v')[0]?.textContent || ''
+    }
+    while (headers.length <= col) headers.push('')
+    headers[col] = value.trim()
+  }
+  return headers.filter(h => h !== '')
+}
+
+interface ZipEntry {
+  offset: number
+  compressedSize: number
+  method: number
+}
+
+function readZipEntries(buffer: ArrayBuffer): Map<string, ZipEntry> {
+  const view = new DataView(buffer)
+  // The end of central directory record is in the last 64KB + 22 bytes
+  let eocd = -1
+  for (let i = buffer.byteLength - 22; i >= Math.max(0, buffer.byteLength - 65557); i--) {
+    if (view.getUint32(i, true) === 0x06054b50) {
+      eocd = i
+      break
+    }
+  }
+  if (eocd < 0) throw new Error('Not a valid .xlsx file')
+
+  const entries = new Map<string, ZipEntry>()
+  const count = view.getUint16(eocd + 10, true)
+  let pos = view.getUint32(eocd + 16, true)
+  const decoder = new TextDecoder()
+  for (let i = 0; i < count; i++) {
+    if (view.getUint32(pos, true) !== 0x02014b50) break
+    const method = view.getUint16(pos + 10, true)
+    const compressedSize = view.getUint32(pos + 20, true)
+    const nameLength = view.getUint16(pos + 28, true)
+    const extraLength = view.getUint16(pos + 30, true)
+    const commentLength = view.getUint16(pos + 32, true)
+    const offset = view.getUint32(pos + 42, true)
+    const name = decoder.decode(new Uint8Array(buffer, pos + 46, nameLength))
+    entries.set(name, { offset, compressedSize, method })
+    pos += 46 + nameLength + extraLength + commentLength
+  }
+  return entries
+}
+
+async function readZipEntry(buffer: ArrayBuffer, entry: ZipEntry): Promise<string> {
+  const view = new DataView(buffer)
+  const nameLength = view.getUint16(entry.offset + 26, true)
+  const extraLength = view.getUint16(entry.offset + 28, true)
+  const start = entry.offset + 30 + nameLength + extraLength
+  const data = new Uint8Array(buffer, start, entry.compressedSize)
+
+  if (entry.method === 0) {
+    return new TextDecoder().decode(data)
+  }
+  const stream = new Blob([data]).stream().pipeThrough(new DecompressionStream('deflate-raw'))
+  return new Response(stream).text()
+}
+
+async function firstSheetPath(buffer: ArrayBuffer, entries: Map<string, ZipEntry>): Promise<string> {
+  const fallback = 'xl/worksheets/sheet1.xml'
+  const workbookEntry = entries.get('xl/workbook.xml')
+  const relsEntry = entries.get('xl/_rels/workbook.xml.rels')
+  if (!workbookEntry || !relsEntry) return fallback
+
+  const workbook = parseXml(await readZipEntry(buffer, workbookEntry))
+  const sheet = workbook.getElementsByTagName('sheet')[0]
+  const relId = sheet?.getAttribute('r:id')
+  if (!relId) return fallback
+
+  const rels = parseXml(await readZipEntry(buffer, relsEntry))
+  const rel = Array.from(rels.getElementsByTagName('Relationship')).find(r => r.getAttribute('Id') === relId)
+  const target = rel?.getAttribute('Target')
+  if (!target) return fallback
+  return target.startsWith('/') ? target.slice(1) : `xl/${target}`
+}
+
+function parseXml(xml: string): Document {
+  return new DOMParser().parseFromString(xml, 'application/xml')
+}
@@ -28,12 +28,30 @@ const router = createRouter({
       component: () => import('@/views/auth/RegisterView.vue'),
       meta: { requiresAuth: false }
     },
+    {
+      path: '/forgot-password',
+      name: 'forgot-password',
+      component: () => import('@/views/auth/ForgotPasswordView.vue'),
+      meta: { requiresAuth: false }
+    },
+    {
+      path: '/reset-password',
+      name: 'reset-password',
+      component: () => import('@/views/auth/ResetPasswordView.vue'),
+      meta: { requiresAuth: false }
+    },
     {
       path: '/auth/sso/callback',
       name: 'sso-callback',
       component: () => import('@/views/auth/SSOCallbackView.vue'),
       meta: { requiresAuth: false }
     },
+    {
+      path: '/public/dashboards/:token',
+      name: 'public-dashboard',
+      component: () => import('@/views/public/PublicDashboardView.vue'),
+      meta: { requiresAuth: false }
+    },
     {
       path: '/',
       component: () => import('@/components/layout/AppLayout.vue'),
@@ -58,6 +76,12 @@ const router = createRouter({
           props: true,
           meta: { permission: 'chat' }
         },
+        {
+          path: 'approvals',
+          name: 'message-approvals',
+          component: () => import('@/views/chat/MessageApprovalsView.vue'),
+          meta: { permission: 'chat' }
+        },
         {
           path: 'profile',
           name: 'profile',
@@ -82,6 +106,18 @@ const router = createRouter({
           component: () => import('@/views/settings/CampaignsView.vue'),
           meta: { permission: 'campaigns' }
         },
+        {
+          path: 'sequences',
+          name: 'sequences',
+          component: () => import('@/views/settings/SequencesView.vue'),
+          meta: { permission: 'campaigns' }
+        },
+        {
+          path: 'segments',
+          name: 'segments',
+          component: () => import('@/views/settings/SegmentsView.vue'),
+          meta: { permission: 'contacts' }
+        },
         {
           path: 'chatbot',
           name: 'chatbot',
@@ -122,6 +158,18 @@ const router = createRouter({
           component: () => import('@/views/chatbot/AIContextsView.vue'),
           meta: { permission: 'chatbot.ai' }
         },
+        {
+          path: 'chatbot/variables',
+          name: 'chatbot-variables',
+          component: () => import('@/views/chatbot/VariablesView.vue'),
+          meta: { permission: 'settings.chatbot' }
+        },
+        {
+          path: 'chatbot/unanswered',
+          name: 'chatbot-unanswered',
+          component: () => import('@/views/chatbot/UnansweredQuestionsView.vue'),
+          meta: { permission: 'settings.chatbot' }
+        },
         {
           path: 'chatbot/transfers',
           name: 'chatbot-transfers',
@@ -176,6 +224,12 @@ const router = createRouter({
           component: () => import('@/views/settings/TeamsView.vue'),
           meta: { permission: 'teams' }
         },
+        {
+          path: 'settings/shifts',
+          name: 'shifts',
+          component: () => import('@/views/settings/ShiftsView.vue'),
+          meta: { permission: 'teams' }
+        },
         {
           path: 'settings/api-keys',
           name: 'api-keys',
@@ -188,6 +242,12 @@ const router = createRouter({
           component: () => import('@/views/settings/WebhooksView.vue'),
           meta: { permission: 'webhooks' }
         },
+        {
+          path: 'settings/email-gateways',
+          name: 'email-gateways',
+          component: () => import('@/views/settings/EmailGatewaysView.vue'),
+          meta: { permission: 'settings.general' }
+        },
         {
           path: 'settings/sso',
           name: 'sso-settings',
@@ -219,23 +279,28 @@ const navigationOrder = [
     { path: '/chatbot', permission: 'settings.chatbot' },
     { path: '/chatbot/keywords', permission: 'chatbot.keywords' },
     { path: '/chatbot/flows', permission: 'flows.chatbot' },
-    { path: '/chatbot/ai', permission: 'chatbot.ai' }
+    { path: '/chatbot/ai', permission: 'chatbot.ai' },
+    { path: '/chatbot/unanswered', permission: 'settings.chatbot' }
   ]},
   { path: '/chatbot/transfers', permission: 'transfers' },
   { path: '/analytics/agents', permission: 'analytics.agents' },
   { path: '/templates', permission: 'templates' },
   { path: '/flows', permission: 'flows.whatsapp' },
   { path: '/campaigns', permission: 'campaigns' },
+  { path: '/sequences', permission: 'campaigns' },
+  { path: '/segments', permission: 'contacts' },
   { path: '/settings', permission: 'settings.general', childPaths: [
     { path: '/settings', permission: 'settings.general' },
     { path: '/settings/chatbot', permission: 'settings.chatbot' },
     { path: '/settings/accounts', permission: 'accounts' },
     { path: '/settings/canned-responses', permission: 'canned_responses' },
     { path: '/settings/teams', permission: 'teams' },
+    { path: '/settings/shifts', permission: 'teams' },
     { path: '/settings/users', permission: 'users' },
     { path: '/settings/roles', permission: 'roles' },
     { path: '/settings/api-keys', permission: 'api_keys' },
     { path: '/settings/webhooks', permission: 'webhooks' },
+    { path: '/settings/email-gateways', permission: 'settings.general' },
     { path: '/settings/custom-actions', permission: 'custom_actions' },
     { path: '/settings/sso', permission: 'settings.sso' }
   ]}
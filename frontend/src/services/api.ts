@@ -31,6 +31,26 @@ api.interceptors.request.use(
   }
 )
 
+// Key holding the super admin's own session while they impersonate a user
+export const IMPERSONATOR_SESSION_KEY = 'impersonator_session'
+
+// restoreImpersonatorSession switches back to the super admin's own session after
+// impersonating, returning false if there was none
+export function restoreImpersonatorSession(): boolean {
+  const stored = localStorage.getItem(IMPERSONATOR_SESSION_KEY)
+  if (!stored) return false
+  localStorage.removeItem(IMPERSONATOR_SESSION_KEY)
+  try {
+    const session = JSON.parse(stored)
+    localStorage.setItem('auth_token', session.token)
+    localStorage.setItem('refresh_token', session.refresh_token)
+    localStorage.setItem('user', JSON.stringify(session.user))
+    return true
+  } catch {
+    return false
+  }
+}
+
 // Response interceptor for error handling
 api.interceptors.response.use(
   (response) => response,
@@ -40,6 +60,14 @@ api.interceptors.response.use(
     // Skip token refresh logic for auth endpoints
     const isAuthEndpoint = originalRequest?.url?.startsWith('/auth/')
 
+    // Impersonation tokens can't be refreshed; once the session ends, go back to the
+    // super admin's own session
+    const isEndImpersonation = originalRequest?.url === '/me/impersonation'
+    if (error.response?.status === 401 && !isAuthEndpoint && !isEndImpersonation && restoreImpersonatorSession()) {
+      window.location.href = '/'
+      return Promise.reject(error)
+    }
+
     // Handle 401 errors - try to refresh token (but not for auth endpoints)
     if (error.response?.status === 401 && !originalRequest._retry && !isAuthEndpoint) {
       originalRequest._retry = true
@@ -86,31 +114,194 @@ export const authService = {
   refreshToken: (refreshToken: string) =>
     api.post('/auth/refresh', { refresh_token: refreshToken }),
 
-  me: () => api.get('/auth/me')
+  forgotPassword: (email: string) =>
+    api.post('/auth/forgot-password', { email }),
+
+  resetPassword: (token: string, password: string) =>
+    api.post('/auth/reset-password', { token, password }),
+
+  me: () => api.get('/auth/me'),
+
+  // Organizations the user can switch to, and switching issues tokens for the new one
+  myOrganizations: () => api.get('/me/organizations'),
+  switchOrganization: (organizationId: string) =>
+    api.post('/auth/switch-org', { organization_id: organizationId })
+}
+
+export interface UserOrganization {
+  organization_id: string
+  organization_name: string
+  role_id?: string
+  role_name?: string
+  is_current: boolean
+}
+
+export interface OrganizationMember {
+  user_id: string
+  email: string
+  full_name: string
+  role_id?: string
+  role_name?: string
+}
+
+export interface OrganizationInvite {
+  id: string
+  email: string
+  organization_id: string
+  organization_name?: string
+  role_id?: string
+  role_name?: string
+  invited_by?: string
+  expires_at: string
+}
+
+export const organizationMembersService = {
+  list: () => api.get('/organization-members'),
+  update: (userId: string, data: { role_id: string }) => api.put(`/organization-members/${userId}`, data),
+  remove: (userId: string) => api.delete(`/organization-members/${userId}`),
+  listInvites: () => api.get('/organization-invites'),
+  invite: (data: { email: string; role_id?: string }) => api.post('/organization-invites', data),
+  revokeInvite: (id: string) => api.delete(`/organization-invites/${id}`)
+}
+
+// Invitations sent to the current user by other organizations
+export const myOrganizationInvitesService = {
+  list: () => api.get('/me/organization-invites'),
+  accept: (id: string) => api.post(`/me/organization-invites/${id}/accept`),
+  decline: (id: string) => api.delete(`/me/organization-invites/${id}`)
+}
+
+export interface UserImportResult {
+  dry_run: boolean
+  created: number
+  existing: number
+  teams_added: number
+  users: {
+    row: number
+    user_id?: string
+    email: string
+    full_name: string
+    role?: string
+    teams: string[]
+    status: 'created' | 'existing'
+  }[]
+  rejected: { row: number; email: string; reason: string }[]
+}
+
+export interface SignaturePreview {
+  whatsapp_account: string
+  signature: string
+  position: 'append' | 'prepend'
+  source: 'user' | 'team' | 'account' | ''
+  message: string
 }
 
 export const usersService = {
   list: () => api.get('/users'),
   get: (id: string) => api.get(`/users/${id}`),
-  create: (data: { email: string; password: string; full_name: string; role_id?: string }) =>
+  create: (data: { email: string; password: string; full_name: string; role_id?: string; skills?: string[] }) =>
     api.post('/users', data),
-  update: (id: string, data: { email?: string; password?: string; full_name?: string; role_id?: string; is_active?: boolean }) =>
+  update: (id: string, data: { email?: string; password?: string; full_name?: string; role_id?: string; is_active?: boolean; requires_approval?: boolean; skills?: string[] }) =>
     api.put(`/users/${id}`, data),
   delete: (id: string) => api.delete(`/users/${id}`),
+  // CSV with email, name, role, team and team_role columns. A dry run only checks the rows.
+  import: (file: File, dryRun = false) => {
+    const formData = new FormData()
+    if (dryRun) formData.append('dry_run', 'true')
+    formData.append('file', file)
+    return api.post<{ data: UserImportResult }>('/users/import', formData, {
+      headers: { 'Content-Type': 'multipart/form-data' }
+    })
+  },
   me: () => api.get('/me'),
   updateSettings: (data: { email_notifications: boolean; new_message_alerts: boolean; campaign_updates: boolean }) =>
     api.put('/me/settings', data),
   changePassword: (data: { current_password: string; new_password: string }) =>
     api.put('/me/password', data),
   updateAvailability: (isAvailable: boolean) =>
-    api.put('/me/availability', { is_available: isAvailable })
+    api.put('/me/availability', { is_available: isAvailable }),
+  // An empty signature uses the team or account signature; "-" turns signatures off
+  updateSignature: (signature: string) => api.put('/me/signature', { signature }),
+  previewSignature: (data: { whatsapp_account?: string; content?: string; signature?: string }) =>
+    api.post<{ data: SignaturePreview }>('/me/signature/preview', data),
+  // An empty away message uses the team's
+  updateAwayMessage: (awayMessage: string) => api.put('/me/away-message', { away_message: awayMessage }),
+  getIntegrations: () => api.get('/me/integrations'),
+  connectCalendar: (provider: string) => api.post(`/me/integrations/calendar/${provider}/connect`),
+  disconnectCalendar: () => api.delete('/me/integrations/calendar')
+}
+
+export interface ImpersonationSession {
+  id: string
+  organization_id: string
+  user_id: string
+  user_name?: string
+  user_email?: string
+  impersonator_id: string
+  impersonator_name?: string
+  impersonator_email?: string
+  reason: string
+  status: 'pending' | 'approved' | 'denied' | 'active' | 'ended'
+  responded_at?: string
+  started_at?: string
+  expires_at?: string
+  ended_at?: string
+  created_at: string
+}
+
+export interface ImpersonationToken {
+  access_token: string
+  expires_in: number
+  session: ImpersonationSession
+}
+
+export const impersonationService = {
+  list: () => api.get<{ sessions: ImpersonationSession[] }>('/impersonations'),
+  // Returns a token right away, or a pending session when the user must consent first
+  create: (data: { user_id: string; reason: string }) =>
+    api.post<ImpersonationToken | ImpersonationSession>('/impersonations', data),
+  start: (id: string) => api.post<ImpersonationToken>(`/impersonations/${id}/start`),
+  end: () => api.delete('/me/impersonation'),
+  listRequests: () => api.get<{ requests: ImpersonationSession[] }>('/me/impersonation-requests'),
+  approve: (id: string) => api.post<ImpersonationSession>(`/me/impersonation-requests/${id}/approve`),
+  deny: (id: string) => api.post<ImpersonationSession>(`/me/impersonation-requests/${id}/deny`)
+}
+
+export interface APIUsage {
+  days: number
+  from: string
+  to: string
+  total_requests: number
+  total_rejected: number
+  api_keys: {
+    api_key_id: string
+    name: string
+    key_prefix: string
+    daily_quota: number
+    deleted: boolean
+    requests: number
+    rejected: number
+    requests_today: number
+    top_endpoint: string
+  }[]
+  users: {
+    user_id: string
+    full_name: string
+    email: string
+    requests: number
+    session_requests: number
+  }[]
+  endpoints: { endpoint: string; requests: number; rejected: number }[]
+  daily: { day: string; requests: number; rejected: number }[]
 }
 
 export const apiKeysService = {
   list: () => api.get('/api-keys'),
-  create: (data: { name: string; expires_at?: string }) =>
+  create: (data: { name: string; expires_at?: string; scope?: 'full' | 'transactional'; daily_quota?: number }) =>
     api.post('/api-keys', data),
-  delete: (id: string) => api.delete(`/api-keys/${id}`)
+  update: (id: string, data: { name?: string; daily_quota?: number }) => api.put(`/api-keys/${id}`, data),
+  delete: (id: string) => api.delete(`/api-keys/${id}`),
+  usage: (days?: number) => api.get('/org/api-usage', { params: { days } })
 }
 
 export const accountsService = {
@@ -118,7 +309,27 @@ export const accountsService = {
   get: (id: string) => api.get(`/accounts/${id}`),
   create: (data: any) => api.post('/accounts', data),
   update: (id: string, data: any) => api.put(`/accounts/${id}`, data),
-  delete: (id: string) => api.delete(`/accounts/${id}`)
+  delete: (id: string) => api.delete(`/accounts/${id}`),
+  getHealth: (id: string, hours?: number) => api.get(`/accounts/${id}/health`, { params: { hours } }),
+  getProfile: (id: string) => api.get(`/accounts/${id}/profile`),
+  updateProfile: (id: string, data: any) => api.put(`/accounts/${id}/profile`, data),
+  getCommerceSettings: (id: string) => api.get(`/accounts/${id}/commerce-settings`),
+  updateCommerceSettings: (id: string, data: { is_cart_enabled?: boolean; is_catalog_visible?: boolean }) =>
+    api.put(`/accounts/${id}/commerce-settings`, data),
+  getRegistration: (id: string) => api.get(`/accounts/${id}/registration`),
+  register: (id: string, pin: string) => api.post(`/accounts/${id}/register`, { pin }),
+  deregister: (id: string) => api.post(`/accounts/${id}/deregister`),
+  setTwoStepPin: (id: string, pin: string) => api.put(`/accounts/${id}/two-step-pin`, { pin }),
+  getSubscription: (id: string) => api.get(`/accounts/${id}/subscription`),
+  subscribeWebhooks: (id: string, data?: { override_callback?: boolean }) => api.post(`/accounts/${id}/subscription`, data),
+  unsubscribeWebhooks: (id: string) => api.delete(`/accounts/${id}/subscription`),
+  uploadProfilePhoto: (id: string, file: File) => {
+    const formData = new FormData()
+    formData.append('file', file)
+    return api.post(`/accounts/${id}/profile/photo`, formData, {
+      headers: { 'Content-Type': 'multipart/form-data' }
+    })
+  }
 }
 
 export const contactsService = {
@@ -131,34 +342,114 @@ export const contactsService = {
   assign: (id: string, userId: string | null) =>
     api.put(`/contacts/${id}/assign`, { user_id: userId }),
   getSessionData: (id: string) => api.get(`/contacts/${id}/session-data`),
-  import: (file: File) => {
+  getMemory: (id: string) => api.get(`/contacts/${id}/memory`),
+  updateMemory: (id: string, facts: string) => api.put(`/contacts/${id}/memory`, { facts }),
+  eraseMemory: (id: string) => api.delete(`/contacts/${id}/memory`),
+  enrich: (id: string) => api.post(`/contacts/${id}/enrich`),
+  listConsents: (id: string) => api.get(`/contacts/${id}/consents`),
+  recordConsent: (id: string, data: { source: string; method: string; campaign_id?: string; consented_at?: string; evidence?: string }) =>
+    api.post(`/contacts/${id}/consents`, data),
+  revokeConsent: (id: string, consentId: string, reason?: string) =>
+    api.post(`/contacts/${id}/consents/${consentId}/revoke`, { reason }),
+  setPriority: (id: string, priority: 'urgent' | 'high' | 'normal') => api.put(`/contacts/${id}/priority`, { priority }),
+  optOut: (id: string, reason?: string) => api.post(`/contacts/${id}/opt-out`, { reason }),
+  optIn: (id: string, reason?: string) => api.delete(`/contacts/${id}/opt-out`, { data: { reason } }),
+  listOptOuts: (params?: { contact_id?: string; action?: string; source?: string; from?: string; to?: string; page?: number; limit?: number }) =>
+    api.get('/opt-outs', { params }),
+  // Files are imported in the background; progress arrives over the WebSocket and
+  // can be polled with getImport
+  import: (file: File, mapping: Record<string, string>, tags?: string[]) => {
     const formData = new FormData()
+    formData.append('mapping', JSON.stringify(mapping))
+    if (tags?.length) formData.append('tags', JSON.stringify(tags))
     formData.append('file', file)
     return api.post('/contacts/import', formData, {
       headers: { 'Content-Type': 'multipart/form-data' }
     })
-  }
+  },
+  getImport: (id: string) => api.get(`/contacts/imports/${id}`),
+  // Bulk updates are applied by a worker; progress arrives over the WebSocket and can
+  // be polled with getBulkUpdate
+  bulkUpdate: (data: ContactBulkUpdateRequest) => api.post('/contacts/bulk', data),
+  getBulkUpdate: (id: string) => api.get(`/contacts/bulk/${id}`),
+  export: (params?: { format?: 'csv' | 'xlsx'; search?: string; lifecycle_stage?: string; tag?: string }) =>
+    api.get('/contacts/export', { params, responseType: 'blob' })
+}
+
+export interface ContactImport {
+  id: string
+  filename: string
+  format: 'csv' | 'xlsx'
+  status: 'processing' | 'completed' | 'failed'
+  error?: string
+  processed_rows: number
+  created_count: number
+  updated_count: number
+  duplicate_count: number
+  rejected_count: number
+  rejected: Array<{ row: number; phone_number: string; reason: string }>
+  tags: string[]
+  created_at: string
+  completed_at?: string
+}
+
+export interface ContactBulkUpdateRequest {
+  contact_ids?: string[]
+  filter?: { tags?: string[]; exclude_tags?: string[]; whatsapp_account?: string }
+  add_tags?: string[]
+  remove_tags?: string[]
+  custom_fields?: Record<string, string>
+  assign?: boolean
+  assign_user_id?: string | null
+  whatsapp_account?: string
+}
+
+export interface ContactBulkUpdate {
+  id: string
+  status: 'queued' | 'processing' | 'completed' | 'failed'
+  error?: string
+  total_count: number
+  processed_count: number
+  updated_count: number
 }
 
 export const messagesService = {
-  list: (contactId: string, params?: { page?: number; limit?: number; before_id?: string }) =>
+  list: (contactId: string, params?: { page?: number; limit?: number; before_id?: string; whatsapp_account?: string; all_accounts?: boolean }) =>
     api.get(`/contacts/${contactId}/messages`, { params }),
+  listArchived: (contactId: string, params?: { limit?: number; before_id?: string; whatsapp_account?: string; all_accounts?: boolean }) =>
+    api.get(`/contacts/${contactId}/messages/archived`, { params }),
   send: (contactId: string, data: { type: string; content: any; reply_to_message_id?: string }) =>
     api.post(`/contacts/${contactId}/messages`, data),
   sendTemplate: (contactId: string, data: { template_name: string; components?: any[] }) =>
     api.post(`/contacts/${contactId}/messages/template`, data),
   sendReaction: (contactId: string, messageId: string, emoji: string) =>
-    api.post(`/contacts/${contactId}/messages/${messageId}/reaction`, { emoji })
+    api.post(`/contacts/${contactId}/messages/${messageId}/reaction`, { emoji }),
+  retry: (messageId: string) => api.post(`/messages/${messageId}/retry`),
+  cancel: (messageId: string) => api.post(`/messages/${messageId}/cancel`),
+  queue: (contactId: string) => api.get(`/contacts/${contactId}/outbox`),
+  repairMedia: () => api.post('/media/repair')
+}
+
+export const messageApprovalsService = {
+  list: (params?: { status?: string }) => api.get('/message-approvals', { params }),
+  stats: (params?: { from?: string; to?: string }) => api.get('/message-approvals/stats', { params }),
+  approve: (id: string, data?: { note?: string; content?: string }) => api.post(`/message-approvals/${id}/approve`, data || {}),
+  reject: (id: string, note?: string) => api.post(`/message-approvals/${id}/reject`, { note })
 }
 
 export const templatesService = {
-  list: (params?: { status?: string; category?: string }) =>
+  list: (params?: { status?: string; category?: string; archived?: boolean }) =>
     api.get('/templates', { params }),
   get: (id: string) => api.get(`/templates/${id}`),
+  preview: (id: string, values?: Record<string, string>) =>
+    api.get(`/templates/${id}/preview`, { params: values }),
   create: (data: any) => api.post('/templates', data),
   update: (id: string, data: any) => api.put(`/templates/${id}`, data),
   delete: (id: string) => api.delete(`/templates/${id}`),
   sync: () => api.post('/templates/sync'),
+  cleanupSuggestions: (days?: number) => api.get('/templates/cleanup-suggestions', { params: { days } }),
+  archive: (templateIds: string[]) => api.post('/templates/archive', { template_ids: templateIds }),
+  unarchive: (templateIds: string[]) => api.post('/templates/unarchive', { template_ids: templateIds }),
   uploadMedia: (accountName: string, file: File) => {
     const formData = new FormData()
     formData.append('file', file)
@@ -184,6 +475,241 @@ export const flowsService = {
   sync: (whatsappAccount: string) => api.post('/flows/sync', { whatsapp_account: whatsappAccount })
 }
 
+export interface SheetSourceRequest {
+  spreadsheet: string
+  range?: string
+  column_mapping?: Record<string, string>
+  sync_interval_mins?: number
+}
+
+export const googleSheetsService = {
+  getSettings: () => api.get('/integrations/google-sheets'),
+  updateSettings: (data: { client_id: string; client_secret?: string }) =>
+    api.put('/integrations/google-sheets', data),
+  connect: () => api.post('/integrations/google-sheets/connect'),
+  disconnect: () => api.delete('/integrations/google-sheets'),
+  preview: (data: SheetSourceRequest) => api.post('/integrations/google-sheets/preview', data)
+}
+
+export type CRMProvider = 'hubspot' | 'salesforce'
+
+export interface CRMConnection {
+  provider: CRMProvider
+  client_id: string
+  has_secret: boolean
+  connected: boolean
+  instance_url?: string
+  connected_at?: string
+  push_contacts: boolean
+  push_summaries: boolean
+  field_mappings: Record<string, string>
+  sync_interval_mins: number
+  linked_contacts: number
+  last_synced_at?: string
+  sync_error?: string
+}
+
+export interface CRMConnectionRequest {
+  client_id: string
+  client_secret?: string
+  push_contacts?: boolean
+  push_summaries?: boolean
+  field_mappings?: Record<string, string>
+  sync_interval_mins?: number
+}
+
+export interface CRMSyncResult {
+  contacts_pushed: number
+  summaries_pushed: number
+  contacts_pulled: number
+  failed: number
+  synced_at: string
+}
+
+export const crmService = {
+  list: () => api.get('/integrations/crm'),
+  update: (provider: CRMProvider, data: CRMConnectionRequest) =>
+    api.put(`/integrations/crm/${provider}`, data),
+  connect: (provider: CRMProvider) => api.post(`/integrations/crm/${provider}/connect`),
+  sync: (provider: CRMProvider, full = false) => api.post(`/integrations/crm/${provider}/sync`, { full }),
+  disconnect: (provider: CRMProvider) => api.delete(`/integrations/crm/${provider}`)
+}
+
+export interface SMTPSettings {
+  configured?: boolean
+  host: string
+  port: number
+  username: string
+  password?: string
+  has_password?: boolean
+  from_email: string
+  from_name: string
+  tls_mode: 'none' | 'starttls' | 'tls'
+  insecure_skip_verify: boolean
+  is_enabled: boolean
+}
+
+export const smtpService = {
+  getSettings: () => api.get<SMTPSettings>('/settings/smtp'),
+  updateSettings: (data: SMTPSettings) => api.put<SMTPSettings>('/settings/smtp', data),
+  test: (to?: string) => api.post('/settings/smtp/test', { to })
+}
+
+// Organization's own encryption key (HashiCorp Vault / OpenBao Transit)
+export interface EncryptionKey {
+  configured: boolean
+  provider?: 'vault_transit'
+  address?: string
+  mount?: string
+  key_name?: string
+  status?: 'active' | 'unavailable'
+  last_error?: string
+  last_checked_at?: string
+  rotated_at?: string
+  created_at?: string
+}
+
+export const encryptionKeyService = {
+  get: () => api.get<EncryptionKey>('/org/encryption-key'),
+  // An empty token keeps the stored one
+  update: (data: { address: string; mount?: string; key_name: string; token?: string }) =>
+    api.put<EncryptionKey>('/org/encryption-key', { provider: 'vault_transit', ...data }),
+  rotate: () => api.post<EncryptionKey>('/org/encryption-key/rotate')
+}
+
+export interface RecipientTimelineEvent {
+  status: string
+  timestamp: string
+  attempt?: number
+  message_id?: string
+  error?: string
+}
+
+export interface RecipientSendAttempt {
+  attempt: number
+  message_id: string
+  whatsapp_message_id?: string
+  status: string
+  error_code?: number
+  error_message?: string
+  error_info?: ErrorCodeInfo
+  created_at: string
+  status_history: Array<{ status: string; timestamp: string; error?: string }>
+}
+
+export interface RecipientTimeline {
+  recipient: { id: string; phone_number: string; recipient_name: string; status: string; error_message?: string; deferred_until?: string }
+  status: string
+  retries: number
+  attempts: RecipientSendAttempt[]
+  timeline: RecipientTimelineEvent[]
+}
+
+export type SegmentFieldOperator = 'equals' | 'not_equals' | 'contains' | 'exists' | 'not_exists'
+
+export interface SegmentFilters {
+  tags?: string[]
+  exclude_tags?: string[]
+  custom_fields?: { field: string; operator: SegmentFieldOperator; value?: string }[]
+  last_message_within_days?: number
+  no_message_for_days?: number
+  whatsapp_account?: string
+  opt_in?: '' | 'opted_in' | 'not_opted_in'
+}
+
+export interface Segment {
+  id: string
+  name: string
+  description: string
+  filters: SegmentFilters
+  contact_count: number
+  created_at: string
+  updated_at: string
+}
+
+export interface SegmentContact {
+  id: string
+  phone_number: string
+  name: string
+  tags: string[]
+  whatsapp_account: string
+  last_message_at?: string
+}
+
+export const segmentsService = {
+  list: () => api.get<{ segments: Segment[] }>('/segments'),
+  get: (id: string) => api.get<Segment>(`/segments/${id}`),
+  create: (data: { name: string; description?: string; filters: SegmentFilters }) => api.post<Segment>('/segments', data),
+  update: (id: string, data: { name: string; description?: string; filters: SegmentFilters }) => api.put<Segment>(`/segments/${id}`, data),
+  delete: (id: string) => api.delete(`/segments/${id}`),
+  contacts: (id: string, params?: { page?: number; limit?: number }) =>
+    api.get<{ contacts: SegmentContact[]; total: number }>(`/segments/${id}/contacts`, { params }),
+  preview: (filters: SegmentFilters) => api.post<{ contacts: SegmentContact[]; total: number }>('/segments/preview', { filters })
+}
+
+export type SequenceStatus = 'draft' | 'active' | 'paused'
+export type SequenceEnrollmentStatus = 'active' | 'completed' | 'exited' | 'failed'
+
+export interface SequenceStep {
+  id?: string
+  position?: number
+  template_id: string
+  template_name?: string
+  delay_minutes: number
+  template_param_mapping: Record<string, string>
+}
+
+export interface Sequence {
+  id: string
+  name: string
+  whatsapp_account: string
+  status: SequenceStatus
+  exit_on_reply: boolean
+  exit_on_button_click: boolean
+  steps: SequenceStep[]
+  enrollments: Partial<Record<SequenceEnrollmentStatus, number>>
+  created_at: string
+  updated_at: string
+}
+
+export interface SequenceEnrollment {
+  id: string
+  contact_id: string
+  phone_number: string
+  contact_name: string
+  status: SequenceEnrollmentStatus
+  next_step: number
+  next_step_at?: string
+  last_sent_at?: string
+  exit_reason?: 'replied' | 'button_clicked' | 'opted_out' | 'unenrolled'
+  error?: string
+  enrolled_at: string
+  finished_at?: string
+}
+
+export interface SequenceInput {
+  name: string
+  whatsapp_account: string
+  exit_on_reply: boolean
+  exit_on_button_click: boolean
+  steps: SequenceStep[]
+}
+
+export const sequencesService = {
+  list: () => api.get<{ sequences: Sequence[] }>('/sequences'),
+  get: (id: string) => api.get<Sequence>(`/sequences/${id}`),
+  create: (data: SequenceInput) => api.post<Sequence>('/sequences', data),
+  update: (id: string, data: SequenceInput) => api.put<Sequence>(`/sequences/${id}`, data),
+  delete: (id: string) => api.delete(`/sequences/${id}`),
+  activate: (id: string) => api.post<Sequence>(`/sequences/${id}/activate`),
+  pause: (id: string) => api.post<Sequence>(`/sequences/${id}/pause`),
+  enrollments: (id: string, params?: { status?: string; page?: number; limit?: number }) =>
+    api.get<{ enrollments: SequenceEnrollment[]; total: number }>(`/sequences/${id}/enrollments`, { params }),
+  enroll: (id: string, data: { contact_ids?: string[]; segment_id?: string }) =>
+    api.post<{ enrolled: number; skipped: number }>(`/sequences/${id}/enrollments`, data),
+  unenroll: (id: string, enrollmentId: string) => api.delete(`/sequences/${id}/enrollments/${enrollmentId}`)
+}
+
 export const campaignsService = {
   list: (params?: { status?: string; from?: string; to?: string }) => api.get('/campaigns', { params }),
   get: (id: string) => api.get(`/campaigns/${id}`),
@@ -195,12 +721,35 @@ export const campaignsService = {
   cancel: (id: string) => api.post(`/campaigns/${id}/cancel`),
   retryFailed: (id: string) => api.post(`/campaigns/${id}/retry-failed`),
   stats: (id: string) => api.get(`/campaigns/${id}/stats`),
+  estimate: (id: string) => api.get(`/campaigns/${id}/estimate`),
+  updateBudget: (id: string, maxBudget: number | null) => api.put(`/campaigns/${id}/budget`, { max_budget: maxBudget }),
   // Recipients
   getRecipients: (id: string) => api.get(`/campaigns/${id}/recipients`),
   addRecipients: (id: string, recipients: Array<{ phone_number: string; recipient_name?: string; template_params?: Record<string, any> }>) =>
     api.post(`/campaigns/${id}/recipients/import`, { recipients }),
+  // Large CSV files are imported in the background; poll getRecipientImport for progress
+  importRecipientsCSV: (id: string, file: File, mapping: Record<string, string>) => {
+    const formData = new FormData()
+    formData.append('mapping', JSON.stringify(mapping))
+    formData.append('file', file)
+    return axios.post(`${api.defaults.baseURL}/campaigns/${id}/recipients/import/csv`, formData, {
+      headers: {
+        'Authorization': `Bearer ${localStorage.getItem('auth_token')}`
+      }
+    })
+  },
+  getRecipientImport: (id: string, importId: string) => api.get(`/campaigns/${id}/recipients/imports/${importId}`),
+  // Template params map parameter names to name, phone_number or a contact custom field
+  addSegment: (id: string, segmentId: string, templateParams: Record<string, string>) =>
+    api.post(`/campaigns/${id}/recipients/segment`, { segment_id: segmentId, template_params: templateParams }),
+  getRecipientTimeline: (campaignId: string, recipientId: string) =>
+    api.get<RecipientTimeline>(`/campaigns/${campaignId}/recipients/${recipientId}`),
   deleteRecipient: (campaignId: string, recipientId: string) =>
     api.delete(`/campaigns/${campaignId}/recipients/${recipientId}`),
+  // Google Sheets source
+  setSheet: (id: string, data: SheetSourceRequest) => api.put(`/campaigns/${id}/sheet`, data),
+  syncSheet: (id: string) => api.post(`/campaigns/${id}/sheet/sync`),
+  unlinkSheet: (id: string) => api.delete(`/campaigns/${id}/sheet`),
   // Media
   uploadMedia: (campaignId: string, file: File) => {
     const formData = new FormData()
@@ -215,6 +764,42 @@ export const campaignsService = {
     api.get(`/campaigns/${campaignId}/media`, { responseType: 'arraybuffer' })
 }
 
+export interface SharedLocation {
+  latitude: number
+  longitude: number
+  name?: string
+  address?: string
+}
+
+export interface FlowSimulationInput {
+  text: string
+  button_id?: string
+  flow_response?: Record<string, any>
+  location?: SharedLocation
+}
+
+export interface FlowSimulationRequest {
+  inputs: FlowSimulationInput[]
+  version?: number
+  call_apis?: boolean
+}
+
+export interface FlowSimulationEntry {
+  at: string
+  kind: string
+  step_name: string
+  message?: string
+  detail?: Record<string, any>
+}
+
+export interface FlowSimulationResult {
+  transcript: FlowSimulationEntry[]
+  session_data: Record<string, any>
+  current_step?: string
+  completed: boolean
+  inputs_used: number
+}
+
 export const chatbotService = {
   // Settings
   getSettings: () => api.get('/chatbot/settings'),
@@ -233,6 +818,12 @@ export const chatbotService = {
   createFlow: (data: any) => api.post('/chatbot/flows', data),
   updateFlow: (id: string, data: any) => api.put(`/chatbot/flows/${id}`, data),
   deleteFlow: (id: string) => api.delete(`/chatbot/flows/${id}`),
+  listFlowVersions: (id: string) => api.get(`/chatbot/flows/${id}/versions`),
+  getFlowVersion: (id: string, version: number) => api.get(`/chatbot/flows/${id}/versions/${version}`),
+  publishFlow: (id: string) => api.post(`/chatbot/flows/${id}/publish`),
+  rollbackFlow: (id: string, version: number) => api.post(`/chatbot/flows/${id}/versions/${version}/rollback`),
+  discardFlowDraft: (id: string) => api.delete(`/chatbot/flows/${id}/draft`),
+  simulateFlow: (id: string, data: FlowSimulationRequest) => api.post(`/chatbot/flows/${id}/simulate`, data),
 
   // AI Contexts
   listAIContexts: () => api.get('/chatbot/ai-contexts'),
@@ -241,6 +832,27 @@ export const chatbotService = {
   updateAIContext: (id: string, data: any) => api.put(`/chatbot/ai-contexts/${id}`, data),
   deleteAIContext: (id: string) => api.delete(`/chatbot/ai-contexts/${id}`),
 
+  // Variables
+  listVariables: () => api.get('/chatbot/variables'),
+  createVariable: (data: { name: string; value: string; description?: string }) =>
+    api.post('/chatbot/variables', data),
+  updateVariable: (id: string, data: { name: string; value: string; description?: string }) =>
+    api.put(`/chatbot/variables/${id}`, data),
+  deleteVariable: (id: string) => api.delete(`/chatbot/variables/${id}`),
+
+  // Unanswered Questions
+  listUnansweredQuestions: (params?: { days?: number; whatsapp_account?: string }) =>
+    api.get('/chatbot/unanswered-questions', { params }),
+  resolveUnansweredQuestion: (data: {
+    whatsapp_account: string
+    normalized: string
+    action: 'keyword_rule' | 'ai_context' | 'dismissed'
+    name?: string
+    keywords?: string[]
+    response?: string
+    content?: string
+  }) => api.post('/chatbot/unanswered-questions/resolve', data),
+
   // Sessions
   listSessions: (params?: { status?: string; contact_id?: string }) =>
     api.get('/chatbot/sessions', { params }),
@@ -263,9 +875,49 @@ export const chatbotService = {
     source?: string
   }) => api.post('/chatbot/transfers', data),
   pickNextTransfer: () => api.post('/chatbot/transfers/pick'),
-  resumeTransfer: (id: string) => api.put(`/chatbot/transfers/${id}/resume`),
+  resumeTransfer: (id: string, data?: ResumeTransferData) =>
+    api.put(`/chatbot/transfers/${id}/resume`, data),
   assignTransfer: (id: string, agentId: string | null, teamId?: string | null) =>
-    api.put(`/chatbot/transfers/${id}/assign`, { agent_id: agentId, team_id: teamId })
+    api.put(`/chatbot/transfers/${id}/assign`, { agent_id: agentId, team_id: teamId }),
+  acceptTransfer: (id: string) => api.post(`/chatbot/transfers/${id}/accept`),
+  declineTransfer: (id: string) => api.post(`/chatbot/transfers/${id}/decline`)
+}
+
+export interface ResumeTransferData {
+  // Hand the contact back into a flow
+  flow_id?: string
+  step_name?: string
+  session_data?: Record<string, any>
+  // Wrap up the resolved conversation
+  wrap_up_code_id?: string
+  wrap_up_notes?: string
+  send_closing_message?: boolean
+  closing_message?: string
+}
+
+export interface WrapUpCode {
+  id: string
+  code: string
+  name: string
+  description: string
+  is_active: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface WrapUpAnalytics {
+  total_resolved: number
+  uncoded: number
+  codes: { code_id: string; code: string; name: string; count: number; percent: number }[]
+}
+
+export const wrapUpCodesService = {
+  list: (params?: { active_only?: string; whatsapp_account?: string }) => api.get('/wrap-up-codes', { params }),
+  create: (data: { code: string; name: string; description?: string; is_active?: boolean }) =>
+    api.post('/wrap-up-codes', data),
+  update: (id: string, data: { code: string; name: string; description?: string; is_active?: boolean }) =>
+    api.put(`/wrap-up-codes/${id}`, data),
+  delete: (id: string) => api.delete(`/wrap-up-codes/${id}`)
 }
 
 export interface CannedResponse {
@@ -280,6 +932,12 @@ export interface CannedResponse {
   updated_at: string
 }
 
+export interface CannedResponseSearchResult extends CannedResponse {
+  // How often the current user inserted this response
+  my_usage_count: number
+  score: number
+}
+
 export const cannedResponsesService = {
   list: (params?: { category?: string; search?: string; active_only?: string }) =>
     api.get('/canned-responses', { params }),
@@ -289,7 +947,96 @@ export const cannedResponsesService = {
   update: (id: string, data: { name?: string; shortcut?: string; content?: string; category?: string; is_active?: boolean }) =>
     api.put(`/canned-responses/${id}`, data),
   delete: (id: string) => api.delete(`/canned-responses/${id}`),
-  use: (id: string) => api.post(`/canned-responses/${id}/use`)
+  use: (id: string) => api.post(`/canned-responses/${id}/use`),
+  search: (params: { q: string; limit?: number }) =>
+    api.get('/canned-responses/search', { params })
+}
+
+export type SearchType = 'contacts' | 'messages' | 'templates' | 'campaigns' | 'canned_responses' | 'flows'
+
+export interface SearchResult {
+  id: string
+  type: SearchType
+  title: string
+  subtitle?: string
+  parent_id?: string
+  kind?: 'chatbot' | 'whatsapp'
+  updated_at: string
+}
+
+export const searchService = {
+  search: (params: { q: string; types?: string; limit?: number }) =>
+    api.get('/search', { params })
+}
+
+export type AnalyticsExportReport = 'messages' | 'agents' | 'campaigns' | 'wrap_up'
+
+export interface AnalyticsExport {
+  id: string
+  report: AnalyticsExportReport
+  format: 'csv' | 'xlsx'
+  from: string
+  to: string
+  status: 'pending' | 'processing' | 'completed' | 'failed' | 'expired'
+  error?: string
+  row_count: number
+  file_size: number
+  download_url?: string
+  expires_at?: string
+  created_at: string
+  completed_at?: string
+}
+
+export type PublicDashboardWidget = 'overview' | 'campaigns' | 'transfer_queue'
+
+export interface PublicDashboard {
+  id: string
+  name: string
+  widgets: PublicDashboardWidget[]
+  refresh_seconds: number
+  is_active: boolean
+  url: string
+  last_viewed_at?: string
+  created_at: string
+}
+
+export interface PublicDashboardView {
+  name: string
+  organization: string
+  refresh_seconds: number
+  generated_at: string
+  widgets: PublicDashboardWidget[]
+  overview?: {
+    period_start: string
+    total_messages: number
+    messages_change: number
+    total_contacts: number
+    contacts_change: number
+    chatbot_sessions: number
+    chatbot_change: number
+    campaigns_sent: number
+    campaigns_change: number
+  }
+  campaigns?: {
+    name: string
+    status: string
+    total_recipients: number
+    sent_count: number
+    delivered_count: number
+    read_count: number
+    failed_count: number
+    started_at?: string
+    completed_at?: string
+  }[]
+  transfer_queue?: {
+    waiting: number
+    waiting_urgent: number
+    waiting_high: number
+    longest_wait_seconds: number
+    in_progress: number
+    sla_breached: number
+    agents_available: number
+  }
 }
 
 export const analyticsService = {
@@ -299,8 +1046,43 @@ export const analyticsService = {
     api.get('/analytics/messages', { params }),
   campaigns: (params?: { from?: string; to?: string }) =>
     api.get('/analytics/campaigns', { params }),
+  compareCampaigns: (ids: string[], weeks?: number) =>
+    api.get('/analytics/campaigns/compare', { params: { ids: ids.join(','), weeks } }),
   chatbot: (params?: { from?: string; to?: string }) =>
-    api.get('/analytics/chatbot', { params })
+    api.get('/analytics/chatbot', { params }),
+  deliveryLatency: (params?: { from?: string; to?: string; whatsapp_account?: string }) =>
+    api.get('/analytics/delivery-latency', { params }),
+  verifications: (params?: { from?: string; to?: string }) =>
+    api.get('/analytics/verifications', { params }),
+  wrapUpCodes: (params?: { from?: string; to?: string }) =>
+    api.get('/analytics/wrap-up-codes', { params }),
+  createExport: (data: { report: AnalyticsExportReport; format?: 'csv' | 'xlsx'; from: string; to: string; notify_email?: boolean }) =>
+    api.post('/analytics/export', data),
+  listExports: () => api.get('/analytics/exports'),
+  getExport: (id: string) => api.get(`/analytics/exports/${id}`),
+  listPublicDashboards: () => api.get('/analytics/public-dashboards'),
+  createPublicDashboard: (data: { name: string; widgets: PublicDashboardWidget[]; refresh_seconds?: number }) =>
+    api.post('/analytics/public-dashboards', data),
+  updatePublicDashboard: (id: string, data: { name: string; widgets: PublicDashboardWidget[]; refresh_seconds?: number; is_active?: boolean }) =>
+    api.put(`/analytics/public-dashboards/${id}`, data),
+  rotatePublicDashboardToken: (id: string) => api.post(`/analytics/public-dashboards/${id}/rotate-token`),
+  deletePublicDashboard: (id: string) => api.delete(`/analytics/public-dashboards/${id}`),
+  // Public: the token is the only credential
+  viewPublicDashboard: (token: string) => api.get(`/public/dashboards/${token}`)
+}
+
+export interface ErrorCodeInfo {
+  code: number
+  title: string
+  category: string
+  description: string
+  remediation: string
+  retryable: boolean
+}
+
+export const errorCatalogService = {
+  list: (params?: { code?: number; category?: string }) =>
+    api.get<{ errors: ErrorCodeInfo[] }>('/errors/catalog', { params })
 }
 
 export const agentAnalyticsService = {
@@ -318,8 +1100,33 @@ export const organizationService = {
     mask_phone_numbers?: boolean
     timezone?: string
     date_format?: string
+    allowed_countries?: string[]
+    blocked_countries?: string[]
+    content_policy?: {
+      banned_words: string[]
+      banned_patterns: string[]
+      approval_patterns: string[]
+      disclaimers: { keywords: string[]; text: string }[]
+    }
+    ip_access?: {
+      api_key_cidrs: string[]
+      admin_cidrs: string[]
+    }
+    inbound_media_policy?: {
+      max_size_mb: number
+      allowed_types: string[]
+      notice: string
+    }
+    archive_after_days?: number
+    require_marketing_consent?: boolean
+    opt_out_keywords?: string[]
+    require_impersonation_consent?: boolean
     name?: string
-  }) => api.put('/org/settings', data)
+  }) => api.put('/org/settings', data),
+  exportConsents: (params?: { from?: string; to?: string; active_only?: boolean }) =>
+    api.get('/consents/export', { params, responseType: 'blob' }),
+  auditLogs: (params?: { action?: string; impersonator_id?: string; page?: number; limit?: number }) =>
+    api.get('/org/audit-logs', { params })
 }
 
 // Organizations (super admin only)
@@ -327,12 +1134,25 @@ export interface Organization {
   id: string
   name: string
   slug?: string
+  data_region: string
+  data_region_label: string
   created_at: string
 }
 
+export interface DataRegion {
+  name: string
+  label: string
+  default: boolean
+  organizations: number
+}
+
 export const organizationsService = {
   list: () => api.get<{ organizations: Organization[] }>('/organizations'),
-  getCurrent: () => api.get<Organization>('/organizations/current')
+  getCurrent: () => api.get<Organization>('/organizations/current'),
+  // Super admin only
+  listDataRegions: () => api.get<{ regions: DataRegion[] }>('/admin/data-regions'),
+  setDataRegion: (orgId: string, region: string) =>
+    api.put(`/admin/organizations/${orgId}/data-region`, { region })
 }
 
 export interface Webhook {
@@ -359,6 +1179,9 @@ export interface Team {
   description: string
   assignment_strategy: 'round_robin' | 'load_balanced' | 'manual'
   is_active: boolean
+  requires_approval: boolean
+  signature: string
+  away_message: string
   member_count: number
   created_at: string
   updated_at: string
@@ -385,12 +1208,18 @@ export const teamsService = {
     name: string
     description?: string
     assignment_strategy?: 'round_robin' | 'load_balanced' | 'manual'
+    requires_approval?: boolean
+    signature?: string
+    away_message?: string
   }) => api.post<{ team: Team }>('/teams', data),
   update: (id: string, data: {
     name?: string
     description?: string
     assignment_strategy?: 'round_robin' | 'load_balanced' | 'manual'
     is_active?: boolean
+    requires_approval?: boolean
+    signature?: string
+    away_message?: string
   }) => api.put<{ team: Team }>(`/teams/${id}`, data),
   delete: (id: string) => api.delete(`/teams/${id}`),
   // Members
@@ -398,7 +1227,42 @@ export const teamsService = {
   addMember: (teamId: string, data: { user_id: string; role?: 'manager' | 'agent' }) =>
     api.post<{ member: TeamMember }>(`/teams/${teamId}/members`, data),
   removeMember: (teamId: string, userId: string) =>
-    api.delete(`/teams/${teamId}/members/${userId}`)
+    api.delete(`/teams/${teamId}/members/${userId}`),
+  bulkMembers: (data: { action: 'add' | 'remove'; user_ids: string[]; team_ids: string[]; role?: 'manager' | 'agent' }) =>
+    api.post<{ data: { added: number; removed: number; skipped: number } }>('/teams/members/bulk', data)
+}
+
+// Agent shifts: weekly windows in the organization's timezone. A shift whose end time
+// is before its start time runs past midnight.
+export interface ShiftInput {
+  day_of_week: number // 0 = Sunday
+  start_time: string // HH:MM
+  end_time: string // HH:MM
+}
+
+export interface AgentShift extends ShiftInput {
+  id: string
+  user_id?: string
+  team_id?: string
+}
+
+export interface ShiftCoverage {
+  min_agents: number
+  business_hours: boolean
+  timezone: string
+  agents: { id: string; full_name: string }[]
+  slots: { day_of_week: number; start_time: string; agents: number; agent_ids?: string[]; required: boolean }[]
+  gaps: { day_of_week: number; start_time: string; end_time: string; agents: number }[]
+}
+
+export const shiftsService = {
+  list: (params: { user_id?: string; team_id?: string }) => api.get('/shifts', { params }),
+  mine: () => api.get('/me/shifts'),
+  updateForUser: (userId: string, shifts: ShiftInput[]) =>
+    api.put(`/users/${userId}/shifts`, { shifts }),
+  updateForTeam: (teamId: string, shifts: ShiftInput[]) =>
+    api.put(`/teams/${teamId}/shifts`, { shifts }),
+  coverage: (params: { team_id?: string; min_agents?: number }) => api.get('/shifts/coverage', { params })
 }
 
 export const webhooksService = {
@@ -423,6 +1287,248 @@ export const webhooksService = {
   test: (id: string) => api.post(`/webhooks/${id}/test`)
 }
 
+export interface NotificationChannel {
+  id: string
+  name: string
+  provider: 'slack' | 'teams'
+  webhook_url_hint: string
+  events: string[]
+  is_active: boolean
+  created_at: string
+  updated_at: string
+}
+
+export const notificationChannelsService = {
+  list: () => api.get<{ channels: NotificationChannel[]; available_events: WebhookEvent[] }>('/notification-channels'),
+  create: (data: {
+    name: string
+    provider: string
+    webhook_url: string
+    events: string[]
+  }) => api.post<NotificationChannel>('/notification-channels', data),
+  update: (id: string, data: {
+    name?: string
+    provider?: string
+    webhook_url?: string
+    events?: string[]
+    is_active: boolean
+  }) => api.put<NotificationChannel>(`/notification-channels/${id}`, data),
+  delete: (id: string) => api.delete(`/notification-channels/${id}`),
+  test: (id: string) => api.post(`/notification-channels/${id}/test`)
+}
+
+export interface EmailGatewayParamRule {
+  source: 'subject' | 'body' | 'from'
+  pattern: string
+}
+
+export interface EmailGateway {
+  id: string
+  name: string
+  inbound_url: string
+  allowed_senders: string[]
+  subject_pattern: string
+  template_id: string
+  template_name?: string
+  whatsapp_account: string
+  param_rules: Record<string, EmailGatewayParamRule>
+  recipients: string[]
+  recipient_pattern: string
+  is_active: boolean
+  last_received_at?: string
+  last_result: string
+  created_at: string
+}
+
+export interface EmailGatewayInput {
+  name: string
+  allowed_senders: string[]
+  subject_pattern: string
+  template_id: string
+  whatsapp_account: string
+  param_rules: Record<string, EmailGatewayParamRule>
+  recipients: string[]
+  recipient_pattern: string
+  is_active: boolean
+}
+
+export const emailGatewaysService = {
+  list: () => api.get<{ gateways: EmailGateway[] }>('/email-gateways'),
+  create: (data: EmailGatewayInput) => api.post<EmailGateway>('/email-gateways', data),
+  update: (id: string, data: EmailGatewayInput) => api.put<EmailGateway>(`/email-gateways/${id}`, data),
+  delete: (id: string) => api.delete(`/email-gateways/${id}`)
+}
+
+export interface EnrichmentProvider {
+  id: string
+  name: string
+  provider: 'clearbit' | 'custom'
+  url: string
+  has_api_key: boolean
+  priority: number
+  is_active: boolean
+  created_at: string
+  updated_at: string
+}
+
+export const enrichmentProvidersService = {
+  list: () => api.get<{ providers: EnrichmentProvider[] }>('/enrichment-providers'),
+  create: (data: {
+    name: string
+    provider: string
+    url?: string
+    api_key?: string
+    priority?: number
+  }) => api.post<EnrichmentProvider>('/enrichment-providers', data),
+  update: (id: string, data: {
+    name?: string
+    provider?: string
+    url: string
+    api_key?: string
+    priority: number
+    is_active: boolean
+  }) => api.put<EnrichmentProvider>(`/enrichment-providers/${id}`, data),
+  delete: (id: string) => api.delete(`/enrichment-providers/${id}`)
+}
+
+export interface Announcement {
+  id: string
+  title: string
+  body: string
+  type: 'banner' | 'changelog'
+  category: 'info' | 'maintenance' | 'feature'
+  starts_at?: string
+  ends_at?: string
+  published_at?: string
+  global: boolean
+  is_read: boolean
+  created_at: string
+}
+
+export const announcementsService = {
+  list: (params?: { unread?: boolean }) =>
+    api.get<{ announcements: Announcement[]; unread_count: number }>('/announcements', { params }),
+  markRead: (id: string) => api.post(`/announcements/${id}/read`),
+  markAllRead: () => api.post('/announcements/read-all'),
+  listManaged: () => api.get<{ announcements: Announcement[]; can_manage_global: boolean }>('/announcements/manage'),
+  create: (data: {
+    title: string
+    body?: string
+    type?: string
+    category?: string
+    starts_at?: string | null
+    ends_at?: string | null
+    global?: boolean
+  }) => api.post<Announcement>('/announcements', data),
+  update: (id: string, data: {
+    title: string
+    body?: string
+    type?: string
+    category?: string
+    starts_at?: string | null
+    ends_at?: string | null
+  }) => api.put<Announcement>(`/announcements/${id}`, data),
+  delete: (id: string) => api.delete(`/announcements/${id}`)
+}
+
+export interface Plugin {
+  name: string
+  version: string
+  description: string
+  hooks: string[]
+  enabled: boolean
+  config: Record<string, any>
+  updated_at?: string
+}
+
+export const pluginsService = {
+  list: () => api.get<{ plugins: Plugin[] }>('/plugins'),
+  update: (name: string, data: { enabled: boolean; config?: Record<string, any> }) =>
+    api.put(`/plugins/${name}`, data)
+}
+
+export type ScriptHook = 'contact_created' | 'pre_send' | 'routing'
+
+export interface PipelineScript {
+  id: string
+  name: string
+  description: string
+  hook: ScriptHook
+  source: string
+  is_active: boolean
+  priority: number
+  timeout_ms: number
+  max_steps: number
+  created_at: string
+  updated_at: string
+}
+
+export interface ScriptExecution {
+  id: string
+  script_id: string
+  hook: ScriptHook
+  status: 'success' | 'rejected' | 'error' | 'timeout'
+  duration_us: number
+  steps: number
+  logs: string[]
+  error: string
+  created_at: string
+}
+
+export interface ScriptTestResult {
+  status: ScriptExecution['status']
+  error?: string
+  reason?: string
+  return: any
+  output: Record<string, any>
+  logs: string[]
+  steps: number
+  duration_us: number
+}
+
+type ScriptInput = Pick<PipelineScript, 'name' | 'hook' | 'source'> &
+  Partial<Pick<PipelineScript, 'description' | 'is_active' | 'priority' | 'timeout_ms' | 'max_steps'>>
+
+export const scriptsService = {
+  list: (hook?: ScriptHook) => api.get<{ scripts: PipelineScript[] }>('/scripts', { params: { hook } }),
+  get: (id: string) => api.get<PipelineScript>(`/scripts/${id}`),
+  create: (data: ScriptInput) => api.post<PipelineScript>('/scripts', data),
+  update: (id: string, data: ScriptInput) => api.put<PipelineScript>(`/scripts/${id}`, data),
+  delete: (id: string) => api.delete(`/scripts/${id}`),
+  test: (data: { hook: ScriptHook; source: string; input?: Record<string, any>; timeout_ms?: number; max_steps?: number }) =>
+    api.post<ScriptTestResult>('/scripts/test', data),
+  executions: (id: string, params?: { status?: string; limit?: number }) =>
+    api.get<{ executions: ScriptExecution[] }>(`/scripts/${id}/executions`, { params })
+}
+
+export interface FeatureFlagOverride {
+  organization_id: string
+  organization_name: string
+  enabled: boolean
+}
+
+export interface FeatureFlag {
+  key: string
+  description: string
+  built_in: boolean
+  configured: boolean
+  default: boolean
+  enabled: boolean
+  rollout_percent: number
+  overrides: FeatureFlagOverride[]
+}
+
+export const featureFlagsService = {
+  get: () => api.get<{ flags: Record<string, boolean> }>('/feature-flags'),
+  // Super admin only
+  list: () => api.get<{ flags: FeatureFlag[] }>('/admin/feature-flags'),
+  update: (key: string, data: { description?: string; enabled: boolean; rollout_percent?: number }) =>
+    api.put(`/admin/feature-flags/${key}`, data),
+  delete: (key: string) => api.delete(`/admin/feature-flags/${key}`),
+  setOverride: (key: string, orgId: string, enabled: boolean | null) =>
+    api.put(`/admin/feature-flags/${key}/organizations/${orgId}`, { enabled })
+}
+
 export interface CustomAction {
   id: string
   name: string
@@ -493,6 +1599,7 @@ export interface Role {
   description: string
   is_system: boolean
   is_default: boolean
+  restrict_data_access: boolean
   permissions: string[] // ["resource:action", ...]
   user_count: number
   created_at: string
@@ -502,9 +1609,9 @@ export interface Role {
 export const rolesService = {
   list: () => api.get<{ roles: Role[] }>('/roles'),
   get: (id: string) => api.get<Role>(`/roles/${id}`),
-  create: (data: { name: string; description?: string; is_default?: boolean; permissions: string[] }) =>
+  create: (data: { name: string; description?: string; is_default?: boolean; restrict_data_access?: boolean; permissions: string[] }) =>
     api.post<Role>('/roles', data),
-  update: (id: string, data: { name?: string; description?: string; is_default?: boolean; permissions?: string[] }) =>
+  update: (id: string, data: { name?: string; description?: string; is_default?: boolean; restrict_data_access?: boolean; permissions?: string[] }) =>
     api.put<Role>(`/roles/${id}`, data),
   delete: (id: string) => api.delete(`/roles/${id}`)
 }
@@ -3,6 +3,7 @@ import { useTransfersStore } from '@/stores/transfers'
 import { useAuthStore } from '@/stores/auth'
 import { toast } from 'vue-sonner'
 import router from '@/router'
+import { chatbotService } from '@/services/api'
 
 // Notification sound
 let notificationSound: HTMLAudioElement | null = null
@@ -53,6 +54,11 @@ const WS_TYPE_AGENT_TRANSFER = 'agent_transfer'
 const WS_TYPE_AGENT_TRANSFER_RESUME = 'agent_transfer_resume'
 const WS_TYPE_AGENT_TRANSFER_ASSIGN = 'agent_transfer_assign'
 const WS_TYPE_TRANSFER_ESCALATION = 'transfer_escalation'
+const WS_TYPE_ASSIGNMENT_OFFER = 'assignment_offer'
+const WS_TYPE_ASSIGNMENT_OFFER_DONE = 'assignment_offer_done'
+
+// Conversation priority types
+const WS_TYPE_CONVERSATION_PRIORITY = 'conversation_priority'
 
 // Campaign types
 const WS_TYPE_CAMPAIGN_STATS_UPDATE = 'campaign_stats_update'
@@ -60,6 +66,27 @@ const WS_TYPE_CAMPAIGN_STATS_UPDATE = 'campaign_stats_update'
 // Permission types
 const WS_TYPE_PERMISSIONS_UPDATED = 'permissions_updated'
 
+// Message approval types
+const WS_TYPE_MESSAGE_APPROVAL = 'message_approval'
+const WS_TYPE_MESSAGE_APPROVAL_REVIEWED = 'message_approval_reviewed'
+
+// Announcement types
+const WS_TYPE_ANNOUNCEMENT = 'announcement'
+const WS_TYPE_ANNOUNCEMENT_DELETED = 'announcement_deleted'
+
+// Analytics export types
+const WS_TYPE_ANALYTICS_EXPORT = 'analytics_export'
+
+// Impersonation consent types
+const WS_TYPE_IMPERSONATION_REQUEST = 'impersonation_request'
+const WS_TYPE_IMPERSONATION_RESPONSE = 'impersonation_response'
+
+// Contact import types
+const WS_TYPE_CONTACT_IMPORT = 'contact_import'
+
+// Contact bulk update types
+const WS_TYPE_CONTACT_BULK_UPDATE = 'contact_bulk_update'
+
 interface WSMessage {
   type: string
   payload: any
@@ -74,6 +101,12 @@ class WebSocketService {
   private isConnected = false
   private hasConnectedBefore = false
   private campaignStatsCallbacks: ((payload: any) => void)[] = []
+  private messageApprovalCallbacks: ((type: string, payload: any) => void)[] = []
+  private announcementCallbacks: ((type: string, payload: any) => void)[] = []
+  private impersonationCallbacks: ((type: string, payload: any) => void)[] = []
+  private contactImportCallbacks: ((payload: any) => void)[] = []
+  private contactBulkUpdateCallbacks: ((payload: any) => void)[] = []
+  private pendingOffers = new Set<string>()
 
   connect(token: string) {
     if (this.ws?.readyState === WebSocket.OPEN) {
@@ -153,6 +186,17 @@ class WebSocketService {
         case WS_TYPE_TRANSFER_ESCALATION:
           this.handleTransferEscalation(message.payload)
           break
+        case WS_TYPE_ASSIGNMENT_OFFER:
+          this.handleAssignmentOffer(message.payload)
+          break
+        case WS_TYPE_ASSIGNMENT_OFFER_DONE:
+          this.pendingOffers.delete(message.payload.transfer_id)
+          toast.dismiss(message.payload.offer_id)
+          break
+        case WS_TYPE_CONVERSATION_PRIORITY:
+          store.updateContactPriority(message.payload.contact_id, message.payload.priority, message.payload.priority_source)
+          useTransfersStore().setContactPriority(message.payload.contact_id, message.payload.priority)
+          break
         case WS_TYPE_REACTION_UPDATE:
           this.handleReactionUpdate(store, message.payload)
           break
@@ -165,6 +209,27 @@ class WebSocketService {
         case WS_TYPE_PERMISSIONS_UPDATED:
           this.handlePermissionsUpdated()
           break
+        case WS_TYPE_MESSAGE_APPROVAL:
+        case WS_TYPE_MESSAGE_APPROVAL_REVIEWED:
+          this.handleMessageApproval(message.type, message.payload)
+          break
+        case WS_TYPE_ANNOUNCEMENT:
+        case WS_TYPE_ANNOUNCEMENT_DELETED:
+          this.announcementCallbacks.forEach(callback => callback(message.type, message.payload))
+          break
+        case WS_TYPE_ANALYTICS_EXPORT:
+          this.handleAnalyticsExport(message.payload)
+          break
+        case WS_TYPE_IMPERSONATION_REQUEST:
+        case WS_TYPE_IMPERSONATION_RESPONSE:
+          this.impersonationCallbacks.forEach(callback => callback(message.type, message.payload))
+          break
+        case WS_TYPE_CONTACT_IMPORT:
+          this.contactImportCallbacks.forEach(callback => callback(message.payload))
+          break
+        case WS_TYPE_CONTACT_BULK_UPDATE:
+          this.contactBulkUpdateCallbacks.forEach(callback => callback(message.payload))
+          break
         default:
           // Unknown message type, ignore
           break
@@ -190,7 +255,10 @@ class WebSocketService {
         media_url: payload.media_url,
         media_mime_type: payload.media_mime_type,
         media_filename: payload.media_filename,
+        media_id: payload.media_id,
+        media_rejected: payload.media_rejected,
         interactive_data: payload.interactive_data,
+        flow_response: payload.flow_response,
         status: payload.status,
         wamid: payload.wamid,
         error_message: payload.error_message,
@@ -265,6 +333,7 @@ class WebSocketService {
       agent_id: payload.agent_id,
       team_id: payload.team_id,
       notes: payload.notes,
+      priority: payload.priority,
       transferred_at: payload.transferred_at,
       // Default SLA values - will be updated on next fetch
       sla_breached: false,
@@ -320,9 +389,9 @@ class WebSocketService {
     // Always refresh to ensure UI is in sync (queue counts, etc.)
     transfersStore.fetchTransfers()
 
-    // Notify if assigned to current user
+    // Notify if assigned to current user, unless they're being asked to accept it
     const currentUserId = authStore.user?.id
-    if (payload.agent_id === currentUserId) {
+    if (payload.agent_id === currentUserId && !this.pendingOffers.has(payload.id)) {
       toast.info('Transfer Assigned', {
         description: 'A transfer has been assigned to you',
         duration: 5000,
@@ -334,6 +403,43 @@ class WebSocketService {
     }
   }
 
+  // Auto-assigned transfers wait for the agent to accept within the offer window
+  private handleAssignmentOffer(payload: any) {
+    const transfersStore = useTransfersStore()
+    this.pendingOffers.add(payload.transfer_id)
+    playNotificationSound()
+
+    const contactName = payload.contact_name || payload.phone_number
+    toast.info('New conversation for you', {
+      id: payload.offer_id,
+      description: `${contactName} is waiting. Accept within ${payload.timeout_secs}s or it goes to someone else.`,
+      duration: payload.timeout_secs * 1000,
+      action: {
+        label: 'Accept',
+        onClick: async () => {
+          try {
+            await chatbotService.acceptTransfer(payload.transfer_id)
+            router.push(`/chat/${payload.contact_id}`)
+          } catch (error: any) {
+            toast.error(error.response?.data?.message || 'Failed to accept transfer')
+          }
+          transfersStore.fetchTransfers()
+        }
+      },
+      cancel: {
+        label: 'Decline',
+        onClick: async () => {
+          try {
+            await chatbotService.declineTransfer(payload.transfer_id)
+          } catch (error: any) {
+            toast.error(error.response?.data?.message || 'Failed to decline transfer')
+          }
+          transfersStore.fetchTransfers()
+        }
+      }
+    })
+  }
+
   private handleTransferEscalation(payload: any) {
     const authStore = useAuthStore()
     const currentUserId = authStore.user?.id
@@ -370,6 +476,45 @@ class WebSocketService {
     this.campaignStatsCallbacks.forEach(callback => callback(payload))
   }
 
+  private handleMessageApproval(type: string, payload: any) {
+    const authStore = useAuthStore()
+    const isSender = payload.requested_by_id === authStore.user?.id
+
+    if (type === WS_TYPE_MESSAGE_APPROVAL_REVIEWED && isSender) {
+      const contact = payload.contact_name || payload.contact_phone
+      if (payload.status === 'approved') {
+        toast.success('Message approved', { description: `Your message to ${contact} was sent` })
+      } else {
+        toast.error('Message rejected', { description: payload.review_note || `Your message to ${contact} was not sent` })
+      }
+    } else if (type === WS_TYPE_MESSAGE_APPROVAL && !isSender && authStore.hasPermission('chat.assign', 'write')) {
+      toast.info('Message awaiting approval', {
+        description: `${payload.requested_by_name || 'An agent'} wrote to ${payload.contact_name || payload.contact_phone}`,
+        action: {
+          label: 'Review',
+          onClick: () => router.push('/approvals')
+        }
+      })
+    }
+
+    this.messageApprovalCallbacks.forEach(callback => callback(type, payload))
+  }
+
+  private handleAnalyticsExport(payload: any) {
+    if (payload.status === 'completed' && payload.download_url) {
+      toast.success('Export ready', {
+        description: `Your ${payload.report} export (${payload.row_count} rows) is ready to download`,
+        duration: 15000,
+        action: {
+          label: 'Download',
+          onClick: () => window.open(payload.download_url, '_blank')
+        }
+      })
+    } else if (payload.status === 'failed') {
+      toast.error('Export failed', { description: payload.error || `Your ${payload.report} export could not be generated` })
+    }
+  }
+
   private async handlePermissionsUpdated() {
     const authStore = useAuthStore()
 
@@ -400,6 +545,61 @@ class WebSocketService {
     }
   }
 
+  onMessageApproval(callback: (type: string, payload: any) => void) {
+    this.messageApprovalCallbacks.push(callback)
+    // Return unsubscribe function
+    return () => {
+      const index = this.messageApprovalCallbacks.indexOf(callback)
+      if (index > -1) {
+        this.messageApprovalCallbacks.splice(index, 1)
+      }
+    }
+  }
+
+  onAnnouncement(callback: (type: string, payload: any) => void) {
+    this.announcementCallbacks.push(callback)
+    // Return unsubscribe function
+    return () => {
+      const index = this.announcementCallbacks.indexOf(callback)
+      if (index > -1) {
+        this.announcementCallbacks.splice(index, 1)
+      }
+    }
+  }
+
+  onImpersonation(callback: (type: string, payload: any) => void) {
+    this.impersonationCallbacks.push(callback)
+    // Return unsubscribe function
+    return () => {
+      const index = this.impersonationCallbacks.indexOf(callback)
+      if (index > -1) {
+        this.impersonationCallbacks.splice(index, 1)
+      }
+    }
+  }
+
+  onContactImport(callback: (payload: any) => void) {
+    this.contactImportCallbacks.push(callback)
+    // Return unsubscribe function
+    return () => {
+      const index = this.contactImportCallbacks.indexOf(callback)
+      if (index > -1) {
+        this.contactImportCallbacks.splice(index, 1)
+      }
+    }
+  }
+
+  onContactBulkUpdate(callback: (payload: any) => void) {
+    this.contactBulkUpdateCallbacks.push(callback)
+    // Return unsubscribe function
+    return () => {
+      const index = this.contactBulkUpdateCallbacks.indexOf(callback)
+      if (index > -1) {
+        this.contactBulkUpdateCallbacks.splice(index, 1)
+      }
+    }
+  }
+
   private handleReconnect(token: string) {
     if (this.reconnectAttempts >= this.maxReconnectAttempts) {
       return
@@ -1,6 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import { api } from '@/services/api'
+import { api, IMPERSONATOR_SESSION_KEY, restoreImpersonatorSession, type ImpersonationSession } from '@/services/api'
 
 export interface UserSettings {
   email_notifications?: boolean
@@ -34,6 +34,8 @@ export interface User {
   settings?: UserSettings
   is_available?: boolean
   is_super_admin?: boolean
+  // Set while a super admin is impersonating this user
+  impersonation?: ImpersonationSession
 }
 
 export interface AuthState {
@@ -53,6 +55,7 @@ export const useAuthStore = defineStore('auth', () => {
   const organizationId = computed(() => user.value?.organization_id || '')
   const userSettings = computed(() => user.value?.settings || {})
   const isAvailable = computed(() => user.value?.is_available ?? true)
+  const isImpersonating = computed(() => !!user.value?.impersonation)
 
   function setAuth(authData: { user: User; access_token: string; refresh_token: string }) {
     user.value = authData.user
@@ -157,6 +160,35 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
+  // Switch to an impersonation token, keeping the super admin's own session to return to
+  async function startImpersonation(accessToken: string): Promise<void> {
+    localStorage.setItem(IMPERSONATOR_SESSION_KEY, JSON.stringify({
+      token: token.value,
+      refresh_token: refreshToken.value,
+      user: user.value
+    }))
+    token.value = accessToken
+    refreshToken.value = null
+    localStorage.setItem('auth_token', accessToken)
+    localStorage.removeItem('refresh_token')
+    await refreshUserData()
+  }
+
+  async function stopImpersonation(): Promise<void> {
+    try {
+      await api.delete('/me/impersonation')
+    } catch {
+      // The session may already have expired
+    }
+    if (!restoreImpersonatorSession()) {
+      clearAuth()
+      return
+    }
+    token.value = localStorage.getItem('auth_token')
+    refreshToken.value = localStorage.getItem('refresh_token')
+    user.value = JSON.parse(localStorage.getItem('user') || 'null')
+  }
+
   function setAvailability(available: boolean, breakStart?: string | null) {
     if (user.value) {
       user.value = { ...user.value, is_available: available }
@@ -219,6 +251,7 @@ export const useAuthStore = defineStore('auth', () => {
     organizationId,
     userSettings,
     isAvailable,
+    isImpersonating,
     setAuth,
     clearAuth,
     restoreSession,
@@ -228,6 +261,8 @@ export const useAuthStore = defineStore('auth', () => {
     register,
     logout,
     refreshAccessToken,
+    startImpersonation,
+    stopImpersonation,
     setAvailability,
     hasPermission,
     hasAnyPermission
@@ -14,6 +14,12 @@ export interface Contact {
   last_message_at?: string
   unread_count: number
   assigned_user_id?: string
+  whatsapp_account?: string
+  whatsapp_accounts?: string[]
+  opted_out?: boolean
+  opted_out_at?: string
+  priority?: 'urgent' | 'high' | 'normal'
+  priority_source?: 'manual' | 'keyword' | 'sentiment'
   created_at: string
   updated_at: string
 }
@@ -38,8 +44,11 @@ export interface Message {
   message_type: string
   content: any
   media_url?: string
+  media_id?: string
   media_mime_type?: string
   media_filename?: string
+  // Why the inbound media policy rejected the file
+  media_rejected?: string
   interactive_data?: {
     type?: string
     body?: string
@@ -54,17 +63,43 @@ export interface Message {
       title?: string
     }>
   }
+  flow_response?: Record<string, any>
+  // Location pin of location messages
+  location?: { latitude: number; longitude: number; name?: string; address?: string }
+  // Contact cards of contacts messages
+  contacts?: Array<{ name: string; phones?: string[] }>
   status: string
   wamid?: string
   error_message?: string
+  error_code?: number
+  error_category?: string
   is_reply?: boolean
   reply_to_message_id?: string
   reply_to_message?: ReplyPreview
   reactions?: Reaction[]
+  whatsapp_account?: string
+  status_history?: MessageStatusEntry[]
+  // Place in the send queue while WhatsApp hasn't accepted the message yet
+  queue?: MessageQueueState
   created_at: string
   updated_at: string
 }
 
+export interface MessageQueueState {
+  state: 'queued' | 'retrying' | 'sending'
+  position?: number
+  attempts: number
+  next_attempt_at?: string
+  last_error?: string
+  cancellable: boolean
+}
+
+export interface MessageStatusEntry {
+  status: 'accepted' | 'sent' | 'delivered' | 'read' | 'failed'
+  timestamp: string
+  error?: string
+}
+
 export const useContactsStore = defineStore('contacts', () => {
   const contacts = ref<Contact[]>([])
   const currentContact = ref<Contact | null>(null)
@@ -73,8 +108,12 @@ export const useContactsStore = defineStore('contacts', () => {
   const isLoadingMessages = ref(false)
   const isLoadingOlderMessages = ref(false)
   const hasMoreMessages = ref(false)
+  // Older history moved to the archive, loaded on demand once hasMoreMessages is false
+  const hasArchivedMessages = ref(false)
   const searchQuery = ref('')
   const replyingTo = ref<Message | null>(null)
+  // WhatsApp account whose thread is shown; empty shows the unified history across all numbers
+  const threadAccount = ref('')
 
   // Contacts pagination
   const contactsPage = ref(1)
@@ -165,11 +204,16 @@ export const useContactsStore = defineStore('contacts', () => {
   async function fetchMessages(contactId: string, params?: { page?: number; limit?: number }) {
     isLoadingMessages.value = true
     try {
-      const response = await messagesService.list(contactId, params)
+      const response = await messagesService.list(contactId, {
+        ...params,
+        whatsapp_account: threadAccount.value || undefined,
+        all_accounts: threadAccount.value ? undefined : true
+      })
       // API returns { status: "success", data: { messages: [...], has_more: boolean } }
       const data = response.data.data || response.data
       messages.value = data.messages || []
       hasMoreMessages.value = data.has_more === true
+      hasArchivedMessages.value = data.has_archived === true
     } catch (error) {
       console.error('Failed to fetch messages:', error)
     } finally {
@@ -186,7 +230,11 @@ export const useContactsStore = defineStore('contacts', () => {
     try {
       // Get the oldest message ID for cursor-based pagination
       const oldestMessageId = messages.value[0].id
-      const response = await messagesService.list(contactId, { before_id: oldestMessageId })
+      const response = await messagesService.list(contactId, {
+        before_id: oldestMessageId,
+        whatsapp_account: threadAccount.value || undefined,
+        all_accounts: threadAccount.value ? undefined : true
+      })
       const data = response.data.data || response.data
       const olderMessages = data.messages || []
 
@@ -195,6 +243,7 @@ export const useContactsStore = defineStore('contacts', () => {
         messages.value = [...olderMessages, ...messages.value]
       }
       hasMoreMessages.value = data.has_more === true
+      hasArchivedMessages.value = data.has_archived === true
     } catch (error) {
       console.error('Failed to fetch older messages:', error)
     } finally {
@@ -202,11 +251,41 @@ export const useContactsStore = defineStore('contacts', () => {
     }
   }
 
+  async function fetchArchivedMessages(contactId: string) {
+    if (isLoadingOlderMessages.value || !hasArchivedMessages.value) {
+      return
+    }
+
+    isLoadingOlderMessages.value = true
+    try {
+      const response = await messagesService.listArchived(contactId, {
+        before_id: messages.value[0]?.id,
+        whatsapp_account: threadAccount.value || undefined,
+        all_accounts: threadAccount.value ? undefined : true
+      })
+      const data = response.data.data || response.data
+      const archivedMessages = data.messages || []
+
+      if (archivedMessages.length > 0) {
+        messages.value = [...archivedMessages, ...messages.value]
+      }
+      hasArchivedMessages.value = data.has_more === true
+    } catch (error) {
+      console.error('Failed to fetch archived messages:', error)
+    } finally {
+      isLoadingOlderMessages.value = false
+    }
+  }
+
   async function sendMessage(contactId: string, type: string, content: any, replyToMessageId?: string) {
     try {
       const response = await messagesService.send(contactId, { type, content, reply_to_message_id: replyToMessageId })
       // API returns { status: "success", data: { ... } }
       const newMessage = response.data.data || response.data
+      // Held by the content policy until a manager approves it
+      if (newMessage.pending_approval) {
+        return newMessage
+      }
       // Use addMessage which has duplicate checking (WebSocket may also broadcast this)
       addMessage(newMessage)
 
@@ -244,7 +323,10 @@ export const useContactsStore = defineStore('contacts', () => {
   function addMessage(message: Message) {
     // Check if message already exists
     const exists = messages.value.some(m => m.id === message.id)
-    if (!exists) {
+    // Skip messages for other numbers while a single account thread is shown
+    const otherThread = threadAccount.value !== '' && !!message.whatsapp_account &&
+      message.whatsapp_account !== threadAccount.value
+    if (!exists && !otherThread) {
       messages.value.push(message)
 
       // Update contact
@@ -262,20 +344,42 @@ export const useContactsStore = defineStore('contacts', () => {
     const message = messages.value.find(m => m.id === messageId)
     if (message) {
       message.status = status
+      if (status !== 'pending') {
+        message.queue = undefined
+      }
     }
   }
 
   function setCurrentContact(contact: Contact | null) {
     currentContact.value = contact
     replyingTo.value = null // Clear reply state when switching contacts
+    threadAccount.value = ''
     if (contact) {
       contact.unread_count = 0
     }
   }
 
+  function updateContactPriority(contactId: string, priority: Contact['priority'], source: Contact['priority_source']) {
+    const contact = contacts.value.find(c => c.id === contactId)
+    if (contact) {
+      contact.priority = priority
+      contact.priority_source = source
+    }
+    if (currentContact.value?.id === contactId) {
+      currentContact.value.priority = priority
+      currentContact.value.priority_source = source
+    }
+  }
+
+  async function setThreadAccount(contactId: string, account: string) {
+    threadAccount.value = account
+    await fetchMessages(contactId)
+  }
+
   function clearMessages() {
     messages.value = []
     hasMoreMessages.value = false
+    hasArchivedMessages.value = false
   }
 
   function updateMessageReactions(messageId: string, reactions: Reaction[]) {
@@ -293,8 +397,10 @@ export const useContactsStore = defineStore('contacts', () => {
     isLoadingMessages,
     isLoadingOlderMessages,
     hasMoreMessages,
+    hasArchivedMessages,
     searchQuery,
     replyingTo,
+    threadAccount,
     filteredContacts,
     sortedContacts,
     // Contacts pagination
@@ -307,11 +413,14 @@ export const useContactsStore = defineStore('contacts', () => {
     fetchContact,
     fetchMessages,
     fetchOlderMessages,
+    fetchArchivedMessages,
+    setThreadAccount,
     sendMessage,
     sendTemplate,
     addMessage,
     updateMessageStatus,
     setCurrentContact,
+    updateContactPriority,
     clearMessages,
     setReplyingTo,
     clearReplyingTo,
@@ -0,0 +1,29 @@
+import { defineStore } from 'pinia'
+import { ref } from 'vue'
+import { featureFlagsService } from '@/services/api'
+
+export const useFeatureFlagsStore = defineStore('featureFlags', () => {
+  const flags = ref<Record<string, boolean>>({})
+  const loaded = ref(false)
+
+  async function fetchFlags(): Promise<void> {
+    try {
+      const response = await featureFlagsService.get()
+      flags.value = response.data.data.flags || {}
+      loaded.value = true
+    } catch (err) {
+      console.error('Failed to fetch feature flags:', err)
+    }
+  }
+
+  function isEnabled(key: string): boolean {
+    return flags.value[key] === true
+  }
+
+  return {
+    flags,
+    loaded,
+    fetchFlags,
+    isEnabled
+  }
+})
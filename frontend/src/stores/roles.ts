@@ -6,6 +6,7 @@ export interface CreateRoleData {
   name: string
   description?: string
   is_default?: boolean
+  restrict_data_access?: boolean
   permissions: string[]
 }
 
@@ -13,6 +14,7 @@ export interface UpdateRoleData {
   name?: string
   description?: string
   is_default?: boolean
+  restrict_data_access?: boolean
   permissions?: string[]
 }
 
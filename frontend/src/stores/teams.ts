@@ -6,6 +6,9 @@ export interface CreateTeamData {
   name: string
   description?: string
   assignment_strategy?: 'round_robin' | 'load_balanced' | 'manual'
+  requires_approval?: boolean
+  signature?: string
+  away_message?: string
 }
 
 export interface UpdateTeamData {
@@ -13,6 +16,9 @@ export interface UpdateTeamData {
   description?: string
   assignment_strategy?: 'round_robin' | 'load_balanced' | 'manual'
   is_active?: boolean
+  requires_approval?: boolean
+  signature?: string
+  away_message?: string
 }
 
 export const useTeamsStore = defineStore('teams', () => {
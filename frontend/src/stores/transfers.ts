@@ -17,10 +17,15 @@ export interface AgentTransfer {
   transferred_by?: string
   transferred_by_name?: string
   notes?: string
+  handoff_context?: Record<string, any>
+  priority?: ConversationPriority
   transferred_at: string
   resumed_at?: string
   resumed_by?: string
   resumed_by_name?: string
+  wrap_up_code_id?: string
+  wrap_up_code_name?: string
+  wrap_up_notes?: string
   // SLA fields
   sla_response_deadline?: string
   sla_resolution_deadline?: string
@@ -32,6 +37,17 @@ export interface AgentTransfer {
   expires_at?: string
 }
 
+export type ConversationPriority = 'urgent' | 'high' | 'normal'
+
+// Queue order: urgent first, then high, then normal; oldest first within a priority
+const priorityRank: Record<ConversationPriority, number> = { urgent: 0, high: 1, normal: 2 }
+
+export function compareTransferPriority(a: AgentTransfer, b: AgentTransfer): number {
+  const byPriority = priorityRank[a.priority || 'normal'] - priorityRank[b.priority || 'normal']
+  if (byPriority !== 0) return byPriority
+  return new Date(a.transferred_at).getTime() - new Date(b.transferred_at).getTime()
+}
+
 // Helper to determine SLA status
 export type SLAStatus = 'ok' | 'warning' | 'breached' | 'expired'
 
@@ -168,7 +184,7 @@ export const useTransfersStore = defineStore('transfers', () => {
         status: 'resumed',
         limit: params?.limit ?? historyLimit.value,
         offset: params?.offset ?? 0,
-        include: 'contact,agent,team,resumed_by' // Skip transferred_by for history
+        include: 'contact,agent,team,resumed_by,wrap_up' // Skip transferred_by for history
       })
       const data = response.data.data || response.data
 
@@ -286,6 +302,14 @@ export const useTransfersStore = defineStore('transfers', () => {
     }
   }
 
+  // Apply a conversation priority change to the contact's active transfers
+  function setContactPriority(contactId: string, priority: ConversationPriority) {
+    lastSyncedAt.value = Date.now()
+    transfers.value = transfers.value.map(t =>
+      t.contact_id === contactId && t.status === 'active' ? { ...t, priority } : t
+    )
+  }
+
   // Check if WebSocket sync is stale (no updates in given ms)
   function isSyncStale(thresholdMs: number = 60000): boolean {
     if (lastSyncedAt.value === 0) return true // Never synced
@@ -318,6 +342,7 @@ export const useTransfersStore = defineStore('transfers', () => {
     addTransfer,
     updateTransfer,
     removeTransfer,
+    setContactPriority,
     getActiveTransferForContact,
     isSyncStale
   }
@@ -17,6 +17,8 @@ export interface User {
   role?: UserRole
   is_active: boolean
   is_super_admin?: boolean
+  requires_approval?: boolean
+  skills?: string[]
   organization_id: string
   created_at: string
   updated_at: string
@@ -28,6 +30,8 @@ export interface CreateUserData {
   full_name: string
   role_id?: string
   is_super_admin?: boolean
+  requires_approval?: boolean
+  skills?: string[]
 }
 
 export interface UpdateUserData {
@@ -37,6 +41,8 @@ export interface UpdateUserData {
   role_id?: string
   is_active?: boolean
   is_super_admin?: boolean
+  requires_approval?: boolean
+  skills?: string[]
 }
 
 export const useUsersStore = defineStore('users', () => {
@@ -19,6 +19,7 @@ export interface ApiConfig {
 export interface TransferConfig {
   team_id: string
   notes: string
+  skills?: string[]
 }
 
 export interface FlowStep {
@@ -0,0 +1,271 @@
+// Package byok encrypts an organization's data with its own key. Each organization has a
+// random data key that encrypts its secrets and media with AES-256-GCM; the data key is
+// only stored wrapped by the organization's key in an external KMS. Without the KMS
+// unwrapping it, nothing encrypted with the data key can be read.
+package byok
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrKeyUnavailable is returned when the KMS refuses the organization's key, e.g. because
+// it was revoked, disabled or deleted
+var ErrKeyUnavailable = errors.New("organization encryption key is unavailable")
+
+// ErrCorrupt is returned for encrypted data that fails authentication or is truncated
+var ErrCorrupt = errors.New("encrypted data is corrupt")
+
+// DataKeySize is the size of a data key, for AES-256
+const DataKeySize = 32
+
+// secretPrefix marks encrypted secrets, so values stored before encryption was set up can
+// still be told apart and read as they are
+const secretPrefix = "byok:v1:"
+
+// fileMagic starts every encrypted file
+var fileMagic = []byte("WMBYOK01")
+
+const (
+	// HeaderSize is how much of a file IsEncryptedFile needs to see
+	HeaderSize = 8
+	// chunkSize is how much plaintext each sealed chunk of a file holds
+	chunkSize = 64 * 1024
+	// noncePrefixSize random bytes start each chunk's nonce; the chunk counter fills the rest
+	noncePrefixSize = 8
+)
+
+// NewDataKey returns a random data key
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsEncrypted reports whether a stored secret was encrypted with EncryptString
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+// EncryptString encrypts a secret for storage. Empty values are kept empty.
+func EncryptString(key []byte, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString decrypts a secret stored by EncryptString
+func DecryptString(key []byte, value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", ErrCorrupt
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	return string(plaintext), nil
+}
+
+// IsEncryptedFile reports whether a file starting with header, at least HeaderSize
+// bytes of it, was written by NewWriter
+func IsEncryptedFile(header []byte) bool {
+	return bytes.HasPrefix(header, fileMagic)
+}
+
+// chunkNonce returns the nonce of chunk n
+func chunkNonce(prefix []byte, n uint32, size int) []byte {
+	nonce := make([]byte, size)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[size-4:], n)
+	return nonce
+}
+
+// chunkAAD marks the last chunk, so a file cut short at a chunk boundary is detected
+func chunkAAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Writer encrypts a file as a sequence of sealed chunks. Close must be called to write
+// the last chunk; it doesn't close the underlying writer.
+type Writer struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewWriter returns a writer that encrypts what is written to it into w
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(fileMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, gcm: gcm, prefix: prefix, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// Write buffers p, sealing full chunks once more data follows them
+func (e *Writer) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("byok: write after close")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if len(e.buf) == chunkSize {
+			if err := e.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		take := min(chunkSize-len(e.buf), len(p))
+		e.buf = append(e.buf, p[:take]...)
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// Close seals the last chunk
+func (e *Writer) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.flush(true)
+}
+
+func (e *Writer) flush(last bool) error {
+	nonce := chunkNonce(e.prefix, e.counter, e.gcm.NonceSize())
+	sealed := e.gcm.Seal(nil, nonce, e.buf, chunkAAD(last))
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := e.w.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Reader decrypts a file written by Writer
+type Reader struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	done    bool
+}
+
+// NewReader returns a reader that decrypts r, which must start with the file header
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, HeaderSize+noncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil || !IsEncryptedFile(header) {
+		return nil, ErrCorrupt
+	}
+	return &Reader{r: r, gcm: gcm, prefix: header[HeaderSize:]}, nil
+}
+
+// Read returns decrypted data, failing with ErrCorrupt if the file was altered or cut short
+func (d *Reader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *Reader) next() error {
+	var length [4]byte
+	if _, err := io.ReadFull(d.r, length[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrCorrupt
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > chunkSize+uint32(d.gcm.Overhead()) {
+		return ErrCorrupt
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrCorrupt
+		}
+		return err
+	}
+
+	nonce := chunkNonce(d.prefix, d.counter, d.gcm.NonceSize())
+	plaintext, err := d.gcm.Open(nil, nonce, sealed, chunkAAD(false))
+	if err != nil {
+		plaintext, err = d.gcm.Open(nil, nonce, sealed, chunkAAD(true))
+		if err != nil {
+			return fmt.Errorf("%w: chunk %d", ErrCorrupt, d.counter)
+		}
+		d.done = true
+	}
+	d.counter++
+	d.buf = plaintext
+	return nil
+}
@@ -0,0 +1,95 @@
+package byok
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptString(t *testing.T) {
+	key, err := NewDataKey()
+	require.NoError(t, err)
+
+	sealed, err := EncryptString(key, "EAAG-access-token")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(sealed))
+	assert.NotContains(t, sealed, "EAAG")
+
+	plain, err := DecryptString(key, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "EAAG-access-token", plain)
+
+	empty, err := EncryptString(key, "")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+	assert.False(t, IsEncrypted("EAAG-access-token"))
+
+	other, err := NewDataKey()
+	require.NoError(t, err)
+	_, err = DecryptString(other, sealed)
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestWriterReader(t *testing.T) {
+	key, err := NewDataKey()
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 10, chunkSize, chunkSize + 1, 3*chunkSize + 17} {
+		plaintext := bytes.Repeat([]byte("x"), size)
+
+		var file bytes.Buffer
+		w, err := NewWriter(&file, key)
+		require.NoError(t, err)
+		// Write in uneven pieces
+		for rest := plaintext; len(rest) > 0; {
+			n := min(len(rest), 1000)
+			_, err := w.Write(rest[:n])
+			require.NoError(t, err)
+			rest = rest[n:]
+		}
+		require.NoError(t, w.Close())
+		assert.True(t, IsEncryptedFile(file.Bytes()))
+
+		r, err := NewReader(bytes.NewReader(file.Bytes()), key)
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plaintext, got, "size %d", size)
+	}
+}
+
+func TestReader_DetectsTampering(t *testing.T) {
+	key, err := NewDataKey()
+	require.NoError(t, err)
+
+	var file bytes.Buffer
+	w, err := NewWriter(&file, key)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(strings.Repeat("report ", 2*chunkSize/7)))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	data := file.Bytes()
+
+	read := func(data []byte) error {
+		r, err := NewReader(bytes.NewReader(data), key)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(r)
+		return err
+	}
+
+	// Cut after the first chunk, at a chunk boundary
+	firstChunk := HeaderSize + noncePrefixSize + 4 + chunkSize + 16
+	assert.ErrorIs(t, read(data[:firstChunk]), ErrCorrupt)
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-1] ^= 1
+	assert.ErrorIs(t, read(flipped), ErrCorrupt)
+
+	assert.ErrorIs(t, read([]byte("plain file")), ErrCorrupt)
+}
@@ -0,0 +1,87 @@
+package byok
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// KeyCacheTTL is how long an unwrapped data key is kept in memory. It is never cached
+	// anywhere else, so a key revoked in the KMS stops working within this time.
+	KeyCacheTTL = 5 * time.Minute
+	// noKeyCacheTTL is how long an organization is remembered to have no key of its own
+	noKeyCacheTTL = time.Minute
+	// unavailableCacheTTL is how long a refused key is remembered before asking the KMS again
+	unavailableCacheTTL = 30 * time.Second
+)
+
+type keyringEntry struct {
+	key     []byte
+	err     error
+	expires time.Time
+}
+
+// Keyring loads and caches the data keys of organizations with their own key. The zero
+// value is ready to use.
+type Keyring struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]keyringEntry
+}
+
+// DataKey returns an organization's data key, or nil if it doesn't bring its own key.
+// Errors wrap ErrKeyUnavailable when the KMS refuses the key.
+func (k *Keyring) DataKey(ctx context.Context, db *gorm.DB, orgID uuid.UUID) ([]byte, error) {
+	if orgID == uuid.Nil {
+		return nil, nil
+	}
+	now := time.Now()
+	k.mu.Lock()
+	entry, ok := k.entries[orgID]
+	k.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.key, entry.err
+	}
+
+	var record models.OrganizationEncryptionKey
+	if err := db.Where("organization_id = ?", orgID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			k.store(orgID, keyringEntry{expires: now.Add(noKeyCacheTTL)})
+			return nil, nil
+		}
+		return nil, err
+	}
+	provider, err := NewProvider(&record)
+	if err != nil {
+		return nil, err
+	}
+	key, err := provider.Unwrap(ctx, record.WrappedKey)
+	switch {
+	case err == nil:
+		k.store(orgID, keyringEntry{key: key, expires: now.Add(KeyCacheTTL)})
+	case errors.Is(err, ErrKeyUnavailable):
+		k.store(orgID, keyringEntry{err: err, expires: now.Add(unavailableCacheTTL)})
+	}
+	return key, err
+}
+
+// Forget drops an organization's cached data key, e.g. after its key settings change
+func (k *Keyring) Forget(orgID uuid.UUID) {
+	k.mu.Lock()
+	delete(k.entries, orgID)
+	k.mu.Unlock()
+}
+
+func (k *Keyring) store(orgID uuid.UUID, entry keyringEntry) {
+	k.mu.Lock()
+	if k.entries == nil {
+		k.entries = make(map[uuid.UUID]keyringEntry)
+	}
+	k.entries[orgID] = entry
+	k.mu.Unlock()
+}
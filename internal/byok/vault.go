@@ -0,0 +1,154 @@
+package byok
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// Provider wraps and unwraps data keys with an organization's key in a KMS
+type Provider interface {
+	// Wrap encrypts a data key with the newest version of the KMS key
+	Wrap(ctx context.Context, dataKey []byte) (string, error)
+	// Unwrap decrypts a wrapped data key
+	Unwrap(ctx context.Context, wrapped string) ([]byte, error)
+	// Rewrap re-encrypts a wrapped data key with the newest version of the KMS key,
+	// without the data key leaving the KMS
+	Rewrap(ctx context.Context, wrapped string) (string, error)
+}
+
+// NewProvider returns the provider for an organization's key
+func NewProvider(key *models.OrganizationEncryptionKey) (Provider, error) {
+	switch key.Provider {
+	case models.EncryptionKeyVaultTransit:
+		return &VaultTransit{
+			Address: key.Address,
+			Mount:   key.Mount,
+			KeyName: key.KeyName,
+			Token:   key.Token,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown encryption key provider: %s", key.Provider)
+	}
+}
+
+// DefaultVaultMount is where Vault mounts the Transit secrets engine unless told otherwise
+const DefaultVaultMount = "transit"
+
+// vaultTimeout bounds each call to Vault
+const vaultTimeout = 10 * time.Second
+
+// VaultTransit uses a key in the Transit secrets engine of HashiCorp Vault or OpenBao
+type VaultTransit struct {
+	Address string // e.g. https://vault.example.com:8200
+	Mount   string // DefaultVaultMount when empty
+	KeyName string
+	Token   string
+
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// Wrap encrypts a data key with the newest version of the Transit key
+func (v *VaultTransit) Wrap(ctx context.Context, dataKey []byte) (string, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := v.call(ctx, "encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}, &resp)
+	return resp.Ciphertext, err
+}
+
+// Unwrap decrypts a data key wrapped by Wrap or Rewrap
+func (v *VaultTransit) Unwrap(ctx context.Context, wrapped string) ([]byte, error) {
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := v.call(ctx, "decrypt", map[string]string{"ciphertext": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil || len(key) != DataKeySize {
+		return nil, fmt.Errorf("vault returned an invalid data key")
+	}
+	return key, nil
+}
+
+// Rewrap re-encrypts a wrapped data key with the newest version of the Transit key
+func (v *VaultTransit) Rewrap(ctx context.Context, wrapped string) (string, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := v.call(ctx, "rewrap", map[string]string{"ciphertext": wrapped}, &resp)
+	return resp.Ciphertext, err
+}
+
+// call posts to a Transit endpoint of the key. Vault refusing the request, e.g. because
+// the token or key was revoked, is reported as ErrKeyUnavailable; server and network
+// errors are returned as they are, since they may pass.
+func (v *VaultTransit) call(ctx context.Context, op string, body any, out any) error {
+	mount := strings.Trim(v.Mount, "/")
+	if mount == "" {
+		mount = DefaultVaultMount
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimRight(v.Address, "/"), mount, op, url.PathEscape(v.KeyName))
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := v.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: vaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault %s failed: %w", op, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("vault %s failed: %w", op, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(respBody, &vaultErr)
+		msg := strings.Join(vaultErr.Errors, "; ")
+		if msg == "" {
+			msg = resp.Status
+		}
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return fmt.Errorf("%w: vault %s: %s", ErrKeyUnavailable, op, msg)
+		}
+		return fmt.Errorf("vault %s failed: %s", op, msg)
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &envelope); err != nil {
+		return fmt.Errorf("vault %s returned an invalid response: %w", op, err)
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("vault %s returned an invalid response: %w", op, err)
+	}
+	return nil
+}
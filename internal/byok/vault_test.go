@@ -0,0 +1,87 @@
+package byok
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransit mimics the Transit engine for one key; its "encryption" only tags the
+// plaintext with the key version
+type fakeTransit struct {
+	version int
+	revoked bool
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.revoked || r.Header.Get("X-Vault-Token") != "s.token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	var body map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	unwrap := func(ciphertext string) string {
+		_, plaintext, _ := strings.Cut(strings.TrimPrefix(ciphertext, "vault:"), ":")
+		return plaintext
+	}
+	wrap := func(plaintext string) string {
+		return "vault:v" + strconv.Itoa(f.version) + ":" + plaintext
+	}
+
+	var data map[string]string
+	switch r.URL.Path {
+	case "/v1/transit/encrypt/orgkey":
+		data = map[string]string{"ciphertext": wrap(body["plaintext"])}
+	case "/v1/transit/decrypt/orgkey":
+		data = map[string]string{"plaintext": unwrap(body["ciphertext"])}
+	case "/v1/transit/rewrap/orgkey":
+		data = map[string]string{"ciphertext": wrap(unwrap(body["ciphertext"]))}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func TestVaultTransit(t *testing.T) {
+	transit := &fakeTransit{version: 1}
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	vault := &VaultTransit{Address: server.URL + "/", KeyName: "orgkey", Token: "s.token"}
+	ctx := context.Background()
+	dataKey, err := NewDataKey()
+	require.NoError(t, err)
+
+	wrapped, err := vault.Wrap(ctx, dataKey)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(wrapped, "vault:v1:"))
+
+	transit.version = 2
+	rewrapped, err := vault.Rewrap(ctx, wrapped)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rewrapped, "vault:v2:"))
+
+	unwrapped, err := vault.Unwrap(ctx, rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	// A revoked key is reported as unavailable, not as a passing failure
+	transit.revoked = true
+	_, err = vault.Unwrap(ctx, rewrapped)
+	assert.ErrorIs(t, err, ErrKeyUnavailable)
+	assert.Contains(t, err.Error(), "permission denied")
+
+	server.Close()
+	_, err = vault.Unwrap(ctx, rewrapped)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrKeyUnavailable)
+}
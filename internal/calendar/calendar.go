@@ -0,0 +1,201 @@
+// Package calendar reads busy periods from Google and Microsoft work calendars.
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Supported calendar providers; they match the SSO provider names
+const (
+	ProviderGoogle    = "google"
+	ProviderMicrosoft = "microsoft"
+)
+
+// Scopes are the OAuth scopes needed per provider to read free/busy and the account email
+var Scopes = map[string][]string{
+	ProviderGoogle: {
+		"https://www.googleapis.com/auth/calendar.freebusy",
+		"https://www.googleapis.com/auth/userinfo.email",
+	},
+	ProviderMicrosoft: {"offline_access", "User.Read", "Calendars.Read"},
+}
+
+// Endpoints (overridable in tests)
+var (
+	GoogleBaseURL    = "https://www.googleapis.com/calendar/v3"
+	MicrosoftBaseURL = "https://graph.microsoft.com/v1.0"
+)
+
+// microsoftTimeLayout is the layout Graph uses for dateTimeTimeZone values
+const microsoftTimeLayout = "2006-01-02T15:04:05.9999999"
+
+// Interval is a busy period on a calendar
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// IsSupported reports whether provider has a calendar integration
+func IsSupported(provider string) bool {
+	_, ok := Scopes[provider]
+	return ok
+}
+
+// FetchBusy returns the busy periods between from and to on the user's primary calendar.
+// client must attach OAuth credentials for provider.
+func FetchBusy(ctx context.Context, client *http.Client, provider string, from, to time.Time) ([]Interval, error) {
+	switch provider {
+	case ProviderGoogle:
+		return fetchGoogleBusy(ctx, client, from, to)
+	case ProviderMicrosoft:
+		return fetchMicrosoftBusy(ctx, client, from, to)
+	default:
+		return nil, fmt.Errorf("unsupported calendar provider: %s", provider)
+	}
+}
+
+// BusyAt reports whether now falls in a busy period and, if so, when the busy stretch
+// ends, following back-to-back or overlapping events
+func BusyAt(intervals []Interval, now time.Time) (bool, time.Time) {
+	var until time.Time
+	for {
+		extended := false
+		for _, iv := range intervals {
+			at := now
+			if !until.IsZero() {
+				at = until
+			}
+			if !iv.Start.After(at) && iv.End.After(at) {
+				until = iv.End
+				extended = true
+			}
+		}
+		if !extended {
+			return !until.IsZero(), until
+		}
+	}
+}
+
+func fetchGoogleBusy(ctx context.Context, client *http.Client, from, to time.Time) ([]Interval, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"timeMin": from.UTC().Format(time.RFC3339),
+		"timeMax": to.UTC().Format(time.RFC3339),
+		"items":   []map[string]string{{"id": "primary"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, GoogleBaseURL+"/freeBusy", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Calendars map[string]struct {
+			Busy []struct {
+				Start time.Time `json:"start"`
+				End   time.Time `json:"end"`
+			} `json:"busy"`
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"calendars"`
+	}
+	if err := doJSON(client, req, &result); err != nil {
+		return nil, err
+	}
+
+	primary := result.Calendars["primary"]
+	if len(primary.Errors) > 0 {
+		return nil, fmt.Errorf("google calendar: %s", primary.Errors[0].Reason)
+	}
+	intervals := make([]Interval, 0, len(primary.Busy))
+	for _, b := range primary.Busy {
+		intervals = append(intervals, Interval{Start: b.Start, End: b.End})
+	}
+	return intervals, nil
+}
+
+func fetchMicrosoftBusy(ctx context.Context, client *http.Client, from, to time.Time) ([]Interval, error) {
+	query := url.Values{}
+	query.Set("startDateTime", from.UTC().Format(time.RFC3339))
+	query.Set("endDateTime", to.UTC().Format(time.RFC3339))
+	query.Set("$select", "showAs,start,end")
+	query.Set("$top", "100")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, MicrosoftBaseURL+"/me/calendarView?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+
+	var result struct {
+		Value []struct {
+			ShowAs string `json:"showAs"`
+			Start  struct {
+				DateTime string `json:"dateTime"`
+			} `json:"start"`
+			End struct {
+				DateTime string `json:"dateTime"`
+			} `json:"end"`
+		} `json:"value"`
+	}
+	if err := doJSON(client, req, &result); err != nil {
+		return nil, err
+	}
+
+	var intervals []Interval
+	for _, ev := range result.Value {
+		// Free, tentative and working-elsewhere events don't block routing
+		if ev.ShowAs != "busy" && ev.ShowAs != "oof" {
+			continue
+		}
+		start, err := time.Parse(microsoftTimeLayout, ev.Start.DateTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event start %q: %w", ev.Start.DateTime, err)
+		}
+		end, err := time.Parse(microsoftTimeLayout, ev.End.DateTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event end %q: %w", ev.End.DateTime, err)
+		}
+		intervals = append(intervals, Interval{Start: start, End: end})
+	}
+	return intervals, nil
+}
+
+// doJSON sends req and decodes a successful JSON response into out
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calendar request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("calendar request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("calendar: %s", apiErr.Error.Message)
+		}
+		return fmt.Errorf("calendar request failed with status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse calendar response: %w", err)
+	}
+	return nil
+}
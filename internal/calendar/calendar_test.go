@@ -0,0 +1,95 @@
+package calendar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusyAt(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2026, 3, 2, hour, min, 0, 0, time.UTC) }
+	intervals := []Interval{
+		{Start: at(9, 0), End: at(10, 0)},
+		{Start: at(10, 0), End: at(10, 30)}, // back-to-back
+		{Start: at(10, 15), End: at(11, 0)}, // overlapping
+		{Start: at(14, 0), End: at(15, 0)},
+	}
+
+	busy, until := BusyAt(intervals, at(9, 30))
+	assert.True(t, busy)
+	assert.Equal(t, at(11, 0), until)
+
+	busy, _ = BusyAt(intervals, at(11, 0))
+	assert.False(t, busy)
+
+	busy, until = BusyAt(intervals, at(14, 0))
+	assert.True(t, busy)
+	assert.Equal(t, at(15, 0), until)
+
+	busy, _ = BusyAt(nil, at(9, 30))
+	assert.False(t, busy)
+}
+
+func TestFetchBusy_Google(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/freeBusy", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), `"id":"primary"`)
+		_, _ = w.Write([]byte(`{"calendars":{"primary":{"busy":[{"start":"2026-03-02T09:00:00Z","end":"2026-03-02T10:00:00Z"}]}}}`))
+	}))
+	defer server.Close()
+
+	orig := GoogleBaseURL
+	GoogleBaseURL = server.URL
+	defer func() { GoogleBaseURL = orig }()
+
+	from := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	intervals, err := FetchBusy(context.Background(), server.Client(), ProviderGoogle, from, from.Add(4*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, intervals, 1)
+	assert.Equal(t, time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), intervals[0].Start.UTC())
+}
+
+func TestFetchBusy_Microsoft(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/me/calendarView", r.URL.Path)
+		assert.Equal(t, `outlook.timezone="UTC"`, r.Header.Get("Prefer"))
+		_, _ = w.Write([]byte(`{"value":[
+			{"showAs":"busy","start":{"dateTime":"2026-03-02T09:00:00.0000000"},"end":{"dateTime":"2026-03-02T10:00:00.0000000"}},
+			{"showAs":"free","start":{"dateTime":"2026-03-02T11:00:00.0000000"},"end":{"dateTime":"2026-03-02T12:00:00.0000000"}},
+			{"showAs":"oof","start":{"dateTime":"2026-03-02T13:00:00.0000000"},"end":{"dateTime":"2026-03-02T14:00:00.0000000"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	orig := MicrosoftBaseURL
+	MicrosoftBaseURL = server.URL
+	defer func() { MicrosoftBaseURL = orig }()
+
+	from := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	intervals, err := FetchBusy(context.Background(), server.Client(), ProviderMicrosoft, from, from.Add(8*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+	assert.Equal(t, time.Date(2026, 3, 2, 13, 0, 0, 0, time.UTC), intervals[1].Start)
+}
+
+func TestFetchBusy_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"message":"Invalid Credentials"}}`))
+	}))
+	defer server.Close()
+
+	orig := GoogleBaseURL
+	GoogleBaseURL = server.URL
+	defer func() { GoogleBaseURL = orig }()
+
+	_, err := FetchBusy(context.Background(), server.Client(), ProviderGoogle, time.Now(), time.Now().Add(time.Hour))
+	assert.EqualError(t, err, "calendar: Invalid Credentials")
+}
@@ -0,0 +1,208 @@
+// Package chatnotify posts notifications to Slack and Microsoft Teams incoming webhooks.
+package chatnotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Supported providers
+const (
+	ProviderSlack = "slack"
+	ProviderTeams = "teams"
+)
+
+// Severity colors the notification
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityDanger  Severity = "danger"
+)
+
+// Field is a labelled value shown in the notification
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Message is a provider-neutral notification
+type Message struct {
+	Title    string
+	Text     string
+	Fields   []Field
+	URL      string // Optional link back to the app
+	Severity Severity
+}
+
+// IsSupported reports whether provider is a known chat provider
+func IsSupported(provider string) bool {
+	return provider == ProviderSlack || provider == ProviderTeams
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute HTTPS URL
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid webhook URL")
+	}
+	if u.Scheme != "https" {
+		return errors.New("webhook URL must use https")
+	}
+	return nil
+}
+
+// Payload renders msg as the JSON body expected by provider's incoming webhooks
+func Payload(provider string, msg Message) ([]byte, error) {
+	switch provider {
+	case ProviderSlack:
+		return json.Marshal(slackPayload(msg))
+	case ProviderTeams:
+		return json.Marshal(teamsPayload(msg))
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", provider)
+	}
+}
+
+// Send posts msg to an incoming webhook
+func Send(ctx context.Context, client *http.Client, provider, webhookURL string, msg Message) error {
+	body, err := Payload(provider, msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s webhook returned status %d: %s", provider, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+var slackColors = map[Severity]string{
+	SeverityInfo:    "#2563eb",
+	SeverityWarning: "#d97706",
+	SeverityDanger:  "#dc2626",
+}
+
+// slackEscaper escapes the characters Slack treats as control sequences in mrkdwn
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackPayload(msg Message) map[string]interface{} {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": msg.Title},
+		},
+	}
+	if msg.Text != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": slackEscaper.Replace(msg.Text)},
+		})
+	}
+	if len(msg.Fields) > 0 {
+		fields := make([]map[string]string, 0, len(msg.Fields))
+		for _, f := range msg.Fields {
+			fields = append(fields, map[string]string{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s*\n%s", slackEscaper.Replace(f.Name), slackEscaper.Replace(f.Value)),
+			})
+		}
+		// Slack allows at most 10 fields per section
+		for len(fields) > 0 {
+			n := min(len(fields), 10)
+			blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields[:n]})
+			fields = fields[n:]
+		}
+	}
+	if msg.URL != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []map[string]interface{}{{
+				"type": "button",
+				"text": map[string]string{"type": "plain_text", "text": "Open"},
+				"url":  msg.URL,
+			}},
+		})
+	}
+
+	color := slackColors[msg.Severity]
+	if color == "" {
+		color = slackColors[SeverityInfo]
+	}
+	return map[string]interface{}{
+		"text":        msg.Title, // Fallback for notifications and clients without blocks
+		"attachments": []map[string]interface{}{{"color": color, "blocks": blocks}},
+	}
+}
+
+var teamsColors = map[Severity]string{
+	SeverityInfo:    "Accent",
+	SeverityWarning: "Warning",
+	SeverityDanger:  "Attention",
+}
+
+// teamsPayload builds an Adaptive Card message, accepted by both Teams connector and
+// Workflows webhooks
+func teamsPayload(msg Message) map[string]interface{} {
+	color := teamsColors[msg.Severity]
+	if color == "" {
+		color = teamsColors[SeverityInfo]
+	}
+
+	body := []map[string]interface{}{{
+		"type":   "TextBlock",
+		"text":   msg.Title,
+		"weight": "Bolder",
+		"size":   "Medium",
+		"color":  color,
+		"wrap":   true,
+	}}
+	if msg.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": msg.Text, "wrap": true})
+	}
+	if len(msg.Fields) > 0 {
+		facts := make([]map[string]string, 0, len(msg.Fields))
+		for _, f := range msg.Fields {
+			facts = append(facts, map[string]string{"title": f.Name, "value": f.Value})
+		}
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if msg.URL != "" {
+		card["actions"] = []map[string]string{{"type": "Action.OpenUrl", "title": "Open", "url": msg.URL}}
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
@@ -0,0 +1,105 @@
+package chatnotify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMessage = Message{
+	Title:    "SLA breached",
+	Text:     "Transfer for <Ada> & co waited too long",
+	Fields:   []Field{{Name: "Contact", Value: "Ada"}, {Name: "Team", Value: "Support"}},
+	URL:      "https://app.example.com/chatbot/transfers",
+	Severity: SeverityDanger,
+}
+
+func TestPayload_Slack(t *testing.T) {
+	body, err := Payload(ProviderSlack, testMessage)
+	require.NoError(t, err)
+
+	var payload struct {
+		Text        string `json:"text"`
+		Attachments []struct {
+			Color  string                   `json:"color"`
+			Blocks []map[string]interface{} `json:"blocks"`
+		} `json:"attachments"`
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+
+	assert.Equal(t, "SLA breached", payload.Text)
+	require.Len(t, payload.Attachments, 1)
+	assert.Equal(t, "#dc2626", payload.Attachments[0].Color)
+
+	blocks := payload.Attachments[0].Blocks
+	require.Len(t, blocks, 4) // header, text, fields, actions
+	assert.Equal(t, "Transfer for &lt;Ada&gt; &amp; co waited too long", blocks[1]["text"].(map[string]interface{})["text"])
+	assert.Equal(t, "actions", blocks[3]["type"])
+}
+
+func TestPayload_Teams(t *testing.T) {
+	body, err := Payload(ProviderTeams, testMessage)
+	require.NoError(t, err)
+
+	var payload struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type    string                   `json:"type"`
+				Body    []map[string]interface{} `json:"body"`
+				Actions []map[string]string      `json:"actions"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+
+	assert.Equal(t, "message", payload.Type)
+	require.Len(t, payload.Attachments, 1)
+	card := payload.Attachments[0].Content
+	assert.Equal(t, "AdaptiveCard", card.Type)
+	assert.Equal(t, "Attention", card.Body[0]["color"])
+	assert.Equal(t, "FactSet", card.Body[2]["type"])
+	assert.Equal(t, testMessage.URL, card.Actions[0]["url"])
+}
+
+func TestPayload_UnsupportedProvider(t *testing.T) {
+	_, err := Payload("discord", testMessage)
+	assert.Error(t, err)
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	assert.NoError(t, ValidateWebhookURL("https://hooks.slack.com/services/T000/B000/XXX"))
+	assert.Error(t, ValidateWebhookURL("http://hooks.slack.com/services/T000/B000/XXX"))
+	assert.Error(t, ValidateWebhookURL("not a url"))
+}
+
+func TestSend(t *testing.T) {
+	var received []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	require.NoError(t, Send(context.Background(), server.Client(), ProviderSlack, server.URL, testMessage))
+	assert.Contains(t, string(received), `"text":"SLA breached"`)
+}
+
+func TestSend_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("no_service"))
+	}))
+	defer server.Close()
+
+	err := Send(context.Background(), server.Client(), ProviderSlack, server.URL, testMessage)
+	assert.EqualError(t, err, "slack webhook returned status 404: no_service")
+}
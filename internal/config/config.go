@@ -19,6 +19,11 @@ type Config struct {
 	WhatsApp WhatsAppConfig `koanf:"whatsapp"`
 	AI       AIConfig       `koanf:"ai"`
 	Storage  StorageConfig  `koanf:"storage"`
+	Security SecurityConfig `koanf:"security"`
+	Plugins  PluginsConfig  `koanf:"plugins"`
+	Queue    QueueConfig    `koanf:"queue"`
+	Cluster  ClusterConfig  `koanf:"cluster"`
+	Privacy  PrivacyConfig  `koanf:"privacy"`
 }
 
 type AppConfig struct {
@@ -33,6 +38,11 @@ type ServerConfig struct {
 	ReadTimeout  int    `koanf:"read_timeout"`
 	WriteTimeout int    `koanf:"write_timeout"`
 	BasePath     string `koanf:"base_path"` // Base path for frontend (e.g., "/whatomate" for proxy pass)
+	PublicURL    string `koanf:"public_url"` // Externally reachable URL used in generated links (e.g., short links)
+	MaxBodySize  int    `koanf:"max_body_size"` // Max request body in MB; media uploads use the storage limits instead
+	// Reverse proxies (IPs or CIDR ranges) allowed to set X-Forwarded-For. Without them the
+	// client IP is the connection's address.
+	TrustedProxies []string `koanf:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
@@ -44,7 +54,21 @@ type DatabaseConfig struct {
 	SSLMode         string `koanf:"ssl_mode"`
 	MaxOpenConns    int    `koanf:"max_open_conns"`
 	MaxIdleConns    int    `koanf:"max_idle_conns"`
-	ConnMaxLifetime int    `koanf:"conn_max_lifetime"`
+	ConnMaxLifetime int    `koanf:"conn_max_lifetime"`  // Seconds
+	ConnMaxIdleTime int    `koanf:"conn_max_idle_time"` // Seconds an idle connection is kept; 0 keeps it until its lifetime ends
+
+	// PgBouncer in transaction pooling mode can't keep prepared statements between
+	// transactions: enable prefer_simple_protocol and leave prepare_stmt off behind it
+	PreferSimpleProtocol bool `koanf:"prefer_simple_protocol"` // Send queries without server-side prepared statements
+	PrepareStmt          bool `koanf:"prepare_stmt"`           // Reuse prepared statements per connection
+
+	// Seconds between pool checks that warn when queries had to wait for a connection; -1 disables
+	PoolMonitorInterval int `koanf:"pool_monitor_interval"`
+
+	// Partition messages and chatbot_session_messages by month. Existing tables are
+	// converted by the next -migrate run.
+	Partitioning           bool `koanf:"partitioning"`
+	PartitionPremakeMonths int  `koanf:"partition_premake_months"` // Future monthly partitions to keep created
 }
 
 type RedisConfig struct {
@@ -64,6 +88,9 @@ type WhatsAppConfig struct {
 	WebhookVerifyToken string `koanf:"webhook_verify_token"`
 	APIVersion         string `koanf:"api_version"`
 	BaseURL            string `koanf:"base_url"` // Meta Graph API base URL
+	// Outbound messages per second per phone number, shared by the server and workers.
+	// Accounts can set their own; -1 sends without limiting.
+	MessagesPerSecond int `koanf:"messages_per_second"`
 }
 
 type AIConfig struct {
@@ -79,6 +106,63 @@ type StorageConfig struct {
 	S3Region  string `koanf:"s3_region"`
 	S3Key     string `koanf:"s3_key"`
 	S3Secret  string `koanf:"s3_secret"`
+
+	// Max upload size in MB per media type (defaults follow WhatsApp's limits)
+	MaxImageSize    int `koanf:"max_image_size"`
+	MaxVideoSize    int `koanf:"max_video_size"`
+	MaxAudioSize    int `koanf:"max_audio_size"`
+	MaxDocumentSize int `koanf:"max_document_size"`
+
+	// Data residency: RegionLabel names where local_path lives, and each entry of Regions
+	// is another storage location organizations can be pinned to (e.g., [storage.regions.eu])
+	RegionLabel string                   `koanf:"region_label"`
+	Regions     map[string]StorageRegion `koanf:"regions"`
+}
+
+// StorageRegion is a storage location for organizations with data residency requirements
+type StorageRegion struct {
+	Label     string `koanf:"label"` // Shown in the API and UI, e.g. "EU (Frankfurt)"
+	LocalPath string `koanf:"local_path"`
+}
+
+type SecurityConfig struct {
+	DisableHeaders        bool   `koanf:"disable_headers"`         // Skip the hardening headers (e.g., when a proxy sets them)
+	ContentSecurityPolicy string `koanf:"content_security_policy"` // Overrides the built-in CSP for the frontend
+	HSTSMaxAge            int    `koanf:"hsts_max_age"`            // Seconds; sent only over HTTPS, -1 disables
+	FrameOptions          string `koanf:"frame_options"`           // DENY or SAMEORIGIN
+	Contact               string `koanf:"contact"`                 // security.txt contact (e.g., "mailto:security@example.com")
+	Policy                string `koanf:"policy"`                  // security.txt link to the vulnerability disclosure policy
+}
+
+type PluginsConfig struct {
+	Dir     string `koanf:"dir"`     // Directory scanned for Go plugin (.so) files at startup
+	Timeout int    `koanf:"timeout"` // Seconds a single plugin hook may run
+}
+
+type QueueConfig struct {
+	// Jobs a worker takes from each lane per cycle while both have work: the high lane
+	// carries API template sends, the low lane campaign messages
+	HighPriorityWeight int `koanf:"high_priority_weight"`
+	LowPriorityWeight  int `koanf:"low_priority_weight"`
+}
+
+type ClusterConfig struct {
+	// Seconds the elected leader's lease lasts without renewal. Only the leader runs
+	// periodic background tasks; if it dies another instance takes over within this time.
+	LeaderLeaseTTL int `koanf:"leader_lease_ttl"`
+}
+
+type PrivacyConfig struct {
+	// Redact message content and mask phone numbers and emails in logs: "auto" does so
+	// in production only, "on" always, "off" never
+	LogRedaction     string   `koanf:"log_redaction"`
+	ContentFields    []string `koanf:"content_fields"`    // Extra log fields to redact
+	IdentifierFields []string `koanf:"identifier_fields"` // Extra log fields to mask
+
+	// Unredacted entries at or above audit_level are appended to this file, kept for
+	// debugging severe issues. Empty disables the audit log.
+	AuditLogPath string `koanf:"audit_log_path"`
+	AuditLevel   string `koanf:"audit_level"` // error (default), warn, info or debug
 }
 
 // Load loads configuration from file and environment variables
@@ -145,6 +229,21 @@ func setDefaults(cfg *Config) {
 	if cfg.Database.ConnMaxLifetime == 0 {
 		cfg.Database.ConnMaxLifetime = 300
 	}
+	if cfg.Database.PoolMonitorInterval == 0 {
+		cfg.Database.PoolMonitorInterval = 60
+	}
+	if cfg.Database.PartitionPremakeMonths == 0 {
+		cfg.Database.PartitionPremakeMonths = 3
+	}
+	if cfg.Queue.HighPriorityWeight == 0 {
+		cfg.Queue.HighPriorityWeight = 4
+	}
+	if cfg.Queue.LowPriorityWeight == 0 {
+		cfg.Queue.LowPriorityWeight = 1
+	}
+	if cfg.Cluster.LeaderLeaseTTL == 0 {
+		cfg.Cluster.LeaderLeaseTTL = 15
+	}
 	if cfg.Redis.Port == 0 {
 		cfg.Redis.Port = 6379
 	}
@@ -160,10 +259,49 @@ func setDefaults(cfg *Config) {
 	if cfg.WhatsApp.BaseURL == "" {
 		cfg.WhatsApp.BaseURL = "https://graph.facebook.com"
 	}
+	if cfg.WhatsApp.MessagesPerSecond == 0 {
+		cfg.WhatsApp.MessagesPerSecond = 80
+	}
 	if cfg.Storage.Type == "" {
 		cfg.Storage.Type = "local"
 	}
 	if cfg.Storage.LocalPath == "" {
 		cfg.Storage.LocalPath = "./uploads"
 	}
+	if cfg.Server.MaxBodySize == 0 {
+		cfg.Server.MaxBodySize = 4
+	}
+	if cfg.Storage.RegionLabel == "" {
+		cfg.Storage.RegionLabel = "Default"
+	}
+	if cfg.Storage.MaxImageSize == 0 {
+		cfg.Storage.MaxImageSize = 5
+	}
+	if cfg.Storage.MaxVideoSize == 0 {
+		cfg.Storage.MaxVideoSize = 16
+	}
+	if cfg.Storage.MaxAudioSize == 0 {
+		cfg.Storage.MaxAudioSize = 16
+	}
+	if cfg.Storage.MaxDocumentSize == 0 {
+		cfg.Storage.MaxDocumentSize = 100
+	}
+	if cfg.Privacy.LogRedaction == "" {
+		cfg.Privacy.LogRedaction = "auto"
+	}
+	if cfg.Privacy.AuditLevel == "" {
+		cfg.Privacy.AuditLevel = "error"
+	}
+	if cfg.Security.HSTSMaxAge == 0 {
+		cfg.Security.HSTSMaxAge = 31536000
+	}
+	if cfg.Security.FrameOptions == "" {
+		cfg.Security.FrameOptions = "DENY"
+	}
+	if cfg.Plugins.Dir == "" {
+		cfg.Plugins.Dir = "./plugins"
+	}
+	if cfg.Plugins.Timeout == 0 {
+		cfg.Plugins.Timeout = 5
+	}
 }
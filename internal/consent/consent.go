@@ -0,0 +1,65 @@
+// Package consent checks that contacts opted in before they are sent marketing messages.
+// Organizations that require consent can only send marketing templates to numbers with
+// an active opt-in record.
+package consent
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrMissing is returned when a marketing message is sent to a number without an active opt-in
+var ErrMissing = errors.New("no marketing consent on record for this contact")
+
+// SettingKey is the organization setting that turns on consent checks
+const SettingKey = "require_marketing_consent"
+
+// RequiredFromSettings reports whether organization settings require consent for marketing sends
+func RequiredFromSettings(settings map[string]interface{}) bool {
+	v, _ := settings[SettingKey].(bool)
+	return v
+}
+
+// AppliesTo reports whether templates of the category need consent
+func AppliesTo(category string) bool {
+	return strings.EqualFold(category, string(models.TemplateCategoryMarketing))
+}
+
+// Check returns ErrMissing unless the number has an active opt-in with the organization
+func Check(db *gorm.DB, orgID uuid.UUID, phoneNumber string) error {
+	var count int64
+	if err := db.Model(&models.ContactConsent{}).
+		Where("organization_id = ? AND phone_number = ? AND revoked_at IS NULL", orgID, phoneNumber).
+		Limit(1).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrMissing
+	}
+	return nil
+}
+
+// IsValidSource reports whether s is a known consent source
+func IsValidSource(s models.ConsentSource) bool {
+	switch s {
+	case models.ConsentSourceWebForm, models.ConsentSourceWhatsApp, models.ConsentSourceCampaign,
+		models.ConsentSourceImport, models.ConsentSourceOffline, models.ConsentSourceAPI:
+		return true
+	}
+	return false
+}
+
+// IsValidMethod reports whether m is a known consent method
+func IsValidMethod(m models.ConsentMethod) bool {
+	switch m {
+	case models.ConsentMethodCheckbox, models.ConsentMethodDoubleOptIn, models.ConsentMethodKeyword,
+		models.ConsentMethodSignature, models.ConsentMethodVerbal:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package consent
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequiredFromSettings(t *testing.T) {
+	assert.False(t, RequiredFromSettings(nil))
+	assert.False(t, RequiredFromSettings(map[string]interface{}{}))
+	assert.False(t, RequiredFromSettings(map[string]interface{}{SettingKey: "yes"}))
+	assert.True(t, RequiredFromSettings(map[string]interface{}{SettingKey: true}))
+}
+
+func TestAppliesTo(t *testing.T) {
+	assert.True(t, AppliesTo("MARKETING"))
+	assert.True(t, AppliesTo("marketing"))
+	assert.False(t, AppliesTo("UTILITY"))
+	assert.False(t, AppliesTo("AUTHENTICATION"))
+	assert.False(t, AppliesTo(""))
+}
+
+func TestIsValidSourceAndMethod(t *testing.T) {
+	assert.True(t, IsValidSource(models.ConsentSourceWebForm))
+	assert.False(t, IsValidSource("rumour"))
+	assert.True(t, IsValidMethod(models.ConsentMethodDoubleOptIn))
+	assert.False(t, IsValidMethod(""))
+}
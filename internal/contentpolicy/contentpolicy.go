@@ -0,0 +1,169 @@
+// Package contentpolicy enforces an organization's rules for outbound agent messages:
+// banned words and patterns, mandatory disclaimers and patterns that need manager approval.
+package contentpolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrBlocked is returned when a message contains banned content
+var ErrBlocked = errors.New("message violates the content policy")
+
+// Disclaimer is text that must be appended to messages mentioning any of its keywords
+type Disclaimer struct {
+	Keywords []string `json:"keywords"`
+	Text     string   `json:"text"`
+}
+
+// Policy is an organization's outbound content policy. Words and keywords match whole
+// words case-insensitively; patterns are case-insensitive regular expressions.
+type Policy struct {
+	BannedWords      []string     `json:"banned_words"`
+	BannedPatterns   []string     `json:"banned_patterns"`
+	Disclaimers      []Disclaimer `json:"disclaimers"`
+	ApprovalPatterns []string     `json:"approval_patterns"`
+}
+
+// Result is the outcome of applying a policy to a message
+type Result struct {
+	// Text is the message with any required disclaimers appended
+	Text string
+	// RequiresApproval is set when the message matched an approval pattern
+	RequiresApproval bool
+	// ApprovalReason names the pattern that triggered approval
+	ApprovalReason string
+}
+
+// FromSettings reads the content policy from organization settings
+func FromSettings(settings map[string]interface{}) Policy {
+	var policy Policy
+	raw, ok := settings["content_policy"]
+	if !ok || raw == nil {
+		return policy
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return policy
+	}
+	_ = json.Unmarshal(data, &policy)
+	return policy
+}
+
+// IsEmpty reports whether no rules are configured
+func (p Policy) IsEmpty() bool {
+	return len(p.BannedWords) == 0 && len(p.BannedPatterns) == 0 &&
+		len(p.Disclaimers) == 0 && len(p.ApprovalPatterns) == 0
+}
+
+// Normalize trims whitespace, drops empty entries and checks that every pattern compiles
+func (p Policy) Normalize() (Policy, error) {
+	normalized := Policy{
+		BannedWords:      cleanList(p.BannedWords),
+		BannedPatterns:   cleanList(p.BannedPatterns),
+		ApprovalPatterns: cleanList(p.ApprovalPatterns),
+	}
+
+	for _, patterns := range [][]string{normalized.BannedPatterns, normalized.ApprovalPatterns} {
+		for _, pattern := range patterns {
+			if _, err := compilePattern(pattern); err != nil {
+				return Policy{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	for i, d := range p.Disclaimers {
+		keywords := cleanList(d.Keywords)
+		text := strings.TrimSpace(d.Text)
+		if len(keywords) == 0 && text == "" {
+			continue
+		}
+		if len(keywords) == 0 {
+			return Policy{}, fmt.Errorf("disclaimer %d has no keywords", i+1)
+		}
+		if text == "" {
+			return Policy{}, fmt.Errorf("disclaimer %d has no text", i+1)
+		}
+		normalized.Disclaimers = append(normalized.Disclaimers, Disclaimer{Keywords: keywords, Text: text})
+	}
+
+	return normalized, nil
+}
+
+// Apply checks text against the policy. Banned content returns an error wrapping ErrBlocked;
+// otherwise the result carries the text with disclaimers added and whether it needs approval.
+func (p Policy) Apply(text string) (Result, error) {
+	result := Result{Text: text}
+	if p.IsEmpty() || strings.TrimSpace(text) == "" {
+		return result, nil
+	}
+
+	for _, word := range p.BannedWords {
+		if containsWord(text, word) {
+			return result, fmt.Errorf("%w: contains banned word %q", ErrBlocked, word)
+		}
+	}
+	for _, pattern := range p.BannedPatterns {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(text) {
+			return result, fmt.Errorf("%w: matches banned pattern %q", ErrBlocked, pattern)
+		}
+	}
+
+	for _, pattern := range p.ApprovalPatterns {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(text) {
+			result.RequiresApproval = true
+			result.ApprovalReason = fmt.Sprintf("matches approval pattern %q", pattern)
+			break
+		}
+	}
+
+	for _, d := range p.Disclaimers {
+		if strings.Contains(result.Text, d.Text) {
+			continue
+		}
+		for _, keyword := range d.Keywords {
+			if containsWord(text, keyword) {
+				result.Text += "\n\n" + d.Text
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// containsWord reports whether text contains word as a whole word, ignoring case
+func containsWord(text, word string) bool {
+	re, err := regexp.Compile(`(?i)(^|\W)` + regexp.QuoteMeta(word) + `($|\W)`)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(text)
+}
+
+// compilePattern compiles a case-insensitive policy pattern
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// cleanList trims entries and removes empty ones
+func cleanList(list []string) []string {
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
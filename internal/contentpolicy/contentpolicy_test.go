@@ -0,0 +1,90 @@
+package contentpolicy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Apply_BannedContent(t *testing.T) {
+	policy := Policy{
+		BannedWords:    []string{"damn"},
+		BannedPatterns: []string{`\b\d{16}\b`},
+	}
+
+	_, err := policy.Apply("Well, DAMN that's late")
+	assert.True(t, errors.Is(err, ErrBlocked), "expected ErrBlocked, got %v", err)
+
+	_, err = policy.Apply("Your card 4111111111111111 is on file")
+	assert.True(t, errors.Is(err, ErrBlocked), "expected ErrBlocked, got %v", err)
+
+	// Whole-word match only
+	result, err := policy.Apply("Our dam project is on schedule")
+	require.NoError(t, err)
+	assert.Equal(t, "Our dam project is on schedule", result.Text)
+}
+
+func TestPolicy_Apply_Disclaimers(t *testing.T) {
+	policy := Policy{
+		Disclaimers: []Disclaimer{
+			{Keywords: []string{"returns", "investment"}, Text: "Past performance is not indicative of future results."},
+		},
+	}
+
+	result, err := policy.Apply("This investment has done well")
+	require.NoError(t, err)
+	assert.Equal(t, "This investment has done well\n\nPast performance is not indicative of future results.", result.Text)
+
+	// Not appended twice
+	again, err := policy.Apply(result.Text)
+	require.NoError(t, err)
+	assert.Equal(t, result.Text, again.Text)
+
+	plain, err := policy.Apply("Hello there")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there", plain.Text)
+}
+
+func TestPolicy_Apply_Approval(t *testing.T) {
+	policy := Policy{ApprovalPatterns: []string{`refund`, `discount of \d+%`}}
+
+	result, err := policy.Apply("I can offer a DISCOUNT of 20% today")
+	require.NoError(t, err)
+	assert.True(t, result.RequiresApproval)
+	assert.Contains(t, result.ApprovalReason, "discount")
+
+	result, err = policy.Apply("Thanks for waiting")
+	require.NoError(t, err)
+	assert.False(t, result.RequiresApproval)
+}
+
+func TestPolicy_Normalize(t *testing.T) {
+	policy, err := Policy{
+		BannedWords: []string{" spam ", ""},
+		Disclaimers: []Disclaimer{{Keywords: []string{""}, Text: " "}},
+	}.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spam"}, policy.BannedWords)
+	assert.Empty(t, policy.Disclaimers)
+
+	_, err = Policy{BannedPatterns: []string{"(unclosed"}}.Normalize()
+	assert.Error(t, err)
+
+	_, err = Policy{Disclaimers: []Disclaimer{{Keywords: []string{"loan"}}}}.Normalize()
+	assert.Error(t, err)
+}
+
+func TestFromSettings(t *testing.T) {
+	policy := FromSettings(map[string]interface{}{
+		"content_policy": map[string]interface{}{
+			"banned_words":      []interface{}{"spam"},
+			"approval_patterns": []interface{}{"refund"},
+		},
+	})
+	assert.Equal(t, []string{"spam"}, policy.BannedWords)
+	assert.Equal(t, []string{"refund"}, policy.ApprovalPatterns)
+
+	assert.True(t, FromSettings(nil).IsEmpty())
+}
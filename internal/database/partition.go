@@ -0,0 +1,327 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zerodha/logf"
+	"gorm.io/gorm"
+)
+
+// PartitionedTable is a table partitioned by month on a timestamp column
+type PartitionedTable struct {
+	Name   string
+	Column string
+}
+
+// PartitionedTables lists the tables that are partitioned when partitioning is enabled
+var PartitionedTables = []PartitionedTable{
+	{Name: "messages", Column: "created_at"},
+	{Name: "chatbot_session_messages", Column: "created_at"},
+}
+
+// partitionUpperBound extracts the upper bound from a range partition's bound expression,
+// e.g. FOR VALUES FROM ('2024-01-01 00:00:00+00') TO ('2024-02-01 00:00:00+00')
+var partitionUpperBound = regexp.MustCompile(`TO \('([^']+)'\)`)
+
+// ForeignKey is a foreign key constraint of another table referencing a partitioned table
+type ForeignKey struct {
+	TableName  string
+	Name       string
+	Definition string
+}
+
+// AddStatement returns the statement that recreates the foreign key
+func (fk ForeignKey) AddStatement() string {
+	return "ALTER TABLE " + fk.TableName + " ADD CONSTRAINT " + quoteIdent(fk.Name) + " " + fk.Definition
+}
+
+// PartitionTables converts every table in PartitionedTables that isn't partitioned yet
+// and creates partitions for the current month and the next premakeMonths months.
+// Foreign keys dropped by the conversion are logged with the statement that restores them.
+func PartitionTables(db *gorm.DB, log logf.Logger, premakeMonths int) error {
+	now := time.Now()
+	for _, t := range PartitionedTables {
+		dropped, err := PartitionTable(db, t, now)
+		if err != nil {
+			return fmt.Errorf("failed to partition %s: %w", t.Name, err)
+		}
+		for _, fk := range dropped {
+			log.Warn("Dropped foreign key referencing partitioned table", "table", t.Name, "constraint", fk.Name, "restore", fk.AddStatement())
+		}
+		if err := EnsurePartitions(db, t, now, premakeMonths); err != nil {
+			return fmt.Errorf("failed to create partitions for %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// IsPartitioned reports whether table is a partitioned table
+func IsPartitioned(db *gorm.DB, table string) (bool, error) {
+	var count int64
+	err := db.Raw("SELECT count(*) FROM pg_partitioned_table WHERE partrelid = to_regclass(?)", table).Scan(&count).Error
+	return count > 0, err
+}
+
+// PartitionTable turns an existing table into a table partitioned by month. The rows are
+// not copied: the old table is renamed to <name>_legacy and attached as the partition
+// holding everything before next month. The table is locked while its primary key index
+// is rebuilt to include the partition column. Foreign keys referencing the table are
+// dropped, since PostgreSQL requires them to include the partition column, and returned
+// so they can be restored after UnpartitionTable.
+func PartitionTable(db *gorm.DB, t PartitionedTable, now time.Time) ([]ForeignKey, error) {
+	partitioned, err := IsPartitioned(db, t.Name)
+	if err != nil {
+		return nil, err
+	}
+	if partitioned {
+		return nil, nil
+	}
+
+	legacy := t.Name + "_legacy"
+	bound := monthStart(now).AddDate(0, 1, 0)
+
+	var dropped []ForeignKey
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE " + quoteIdent(t.Name) + " IN ACCESS EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var err error
+		if dropped, err = DropReferencingForeignKeys(tx, t.Name); err != nil {
+			return err
+		}
+
+		// Index names are unique per schema, so the old table's indexes are renamed out of
+		// the way and recreated on the partitioned table, where PostgreSQL attaches them
+		var indexes []struct {
+			Name      string
+			Def       string
+			IsPrimary bool
+		}
+		if err := tx.Raw(`SELECT c.relname AS name, pg_get_indexdef(i.indexrelid) AS def, i.indisprimary AS is_primary
+			FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid WHERE i.indrelid = to_regclass(?)`, t.Name).Scan(&indexes).Error; err != nil {
+			return err
+		}
+
+		statements := []string{"ALTER TABLE " + quoteIdent(t.Name) + " RENAME TO " + quoteIdent(legacy)}
+		for _, idx := range indexes {
+			statements = append(statements, "ALTER INDEX "+quoteIdent(idx.Name)+" RENAME TO "+quoteIdent(legacyIndexName(idx.Name)))
+		}
+		statements = append(statements,
+			fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS) PARTITION BY RANGE (%s)",
+				quoteIdent(t.Name), quoteIdent(legacy), quoteIdent(t.Column)),
+			fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id, %s)", quoteIdent(t.Name), quoteIdent(t.Column)),
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", quoteIdent(legacy), quoteIdent(t.Column)),
+			fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM (MINVALUE) TO ('%s')",
+				quoteIdent(t.Name), quoteIdent(legacy), bound.Format(time.RFC3339)),
+		)
+		for _, idx := range indexes {
+			if !idx.IsPrimary {
+				statements = append(statements, idx.Def)
+			}
+		}
+
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dropped, nil
+}
+
+// UnpartitionTables converts every partitioned table in PartitionedTables back to a
+// plain table
+func UnpartitionTables(db *gorm.DB) error {
+	for _, t := range PartitionedTables {
+		if err := UnpartitionTable(db, t); err != nil {
+			return fmt.Errorf("failed to unpartition %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// UnpartitionTable rolls back PartitionTable: the rows of every partition are copied into
+// a plain table with the primary key on id alone, which replaces the partitioned table.
+// The table is locked for the whole copy. Foreign keys dropped when the table was
+// partitioned are not restored; run the statements PartitionTables logged for them.
+func UnpartitionTable(db *gorm.DB, t PartitionedTable) error {
+	partitioned, err := IsPartitioned(db, t.Name)
+	if err != nil {
+		return err
+	}
+	if !partitioned {
+		return nil
+	}
+
+	plain := t.Name + "_unpartitioned"
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE " + quoteIdent(t.Name) + " IN ACCESS EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var indexes []struct {
+			Def       string
+			IsPrimary bool
+		}
+		if err := tx.Raw(`SELECT pg_get_indexdef(i.indexrelid) AS def, i.indisprimary AS is_primary
+			FROM pg_index i WHERE i.indrelid = to_regclass(?)`, t.Name).Scan(&indexes).Error; err != nil {
+			return err
+		}
+
+		statements := []string{
+			fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", quoteIdent(plain), quoteIdent(t.Name)),
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", quoteIdent(plain), quoteIdent(t.Name)),
+			"DROP TABLE " + quoteIdent(t.Name),
+			"ALTER TABLE " + quoteIdent(plain) + " RENAME TO " + quoteIdent(t.Name),
+			"ALTER TABLE " + quoteIdent(t.Name) + " ADD PRIMARY KEY (id)",
+		}
+		// Indexes of a partitioned table are defined ON ONLY the parent
+		for _, idx := range indexes {
+			if !idx.IsPrimary {
+				statements = append(statements, strings.Replace(idx.Def, " ON ONLY ", " ON ", 1))
+			}
+		}
+
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DropReferencingForeignKeys drops the foreign keys of other tables that reference table
+// and returns them
+func DropReferencingForeignKeys(db *gorm.DB, table string) ([]ForeignKey, error) {
+	var foreignKeys []ForeignKey
+	if err := db.Raw(`SELECT conrelid::regclass::text AS table_name, conname AS name, pg_get_constraintdef(oid) AS definition
+		FROM pg_constraint WHERE contype = 'f' AND confrelid = to_regclass(?)`, table).Scan(&foreignKeys).Error; err != nil {
+		return nil, err
+	}
+	for _, fk := range foreignKeys {
+		if err := db.Exec("ALTER TABLE " + fk.TableName + " DROP CONSTRAINT " + quoteIdent(fk.Name)).Error; err != nil {
+			return nil, err
+		}
+	}
+	return foreignKeys, nil
+}
+
+// EnsurePartitions creates the monthly partitions of t up to premakeMonths months after
+// the current one. Partitions that already exist are left alone.
+func EnsurePartitions(db *gorm.DB, t PartitionedTable, now time.Time, premakeMonths int) error {
+	var bounds []string
+	if err := db.Raw(`SELECT pg_get_expr(c.relpartbound, c.oid) FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = to_regclass(?)`, t.Name).Scan(&bounds).Error; err != nil {
+		return err
+	}
+
+	// Continue after the latest existing partition
+	from := monthStart(now)
+	var latest time.Time
+	for _, b := range bounds {
+		upper, ok := parsePartitionUpperBound(b)
+		if ok && upper.After(latest) {
+			latest = upper
+		}
+	}
+	if !latest.IsZero() {
+		from = monthStart(latest)
+	}
+
+	until := monthStart(now).AddDate(0, premakeMonths+1, 0)
+	for m := from; m.Before(until); m = m.AddDate(0, 1, 0) {
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			quoteIdent(partitionName(t.Name, m)), quoteIdent(t.Name),
+			m.Format(time.RFC3339), m.AddDate(0, 1, 0).Format(time.RFC3339))
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaintainPartitions keeps the upcoming monthly partitions of every partitioned table
+// created, checking every interval until ctx is cancelled
+func MaintainPartitions(ctx context.Context, db *gorm.DB, log logf.Logger, premakeMonths int, interval time.Duration) {
+	maintain := func() {
+		for _, t := range PartitionedTables {
+			partitioned, err := IsPartitioned(db, t.Name)
+			if err != nil {
+				log.Error("Failed to check table partitioning", "error", err, "table", t.Name)
+				continue
+			}
+			if !partitioned {
+				log.Warn("Table is not partitioned yet, run the server with -migrate", "table", t.Name)
+				continue
+			}
+			if err := EnsurePartitions(db, t, time.Now(), premakeMonths); err != nil {
+				log.Error("Failed to create partitions", "error", err, "table", t.Name)
+			}
+		}
+	}
+
+	maintain()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			maintain()
+		}
+	}
+}
+
+// partitionName returns the name of the partition holding month m, e.g. messages_p2024_01
+func partitionName(table string, m time.Time) string {
+	return fmt.Sprintf("%s_p%04d_%02d", table, m.Year(), int(m.Month()))
+}
+
+// legacyIndexName renames an index of a table converted by PartitionTable, keeping
+// within PostgreSQL's 63 byte identifier limit
+func legacyIndexName(name string) string {
+	const suffix = "_legacy"
+	if len(name) > 63-len(suffix) {
+		name = name[:63-len(suffix)]
+	}
+	return name + suffix
+}
+
+// parsePartitionUpperBound returns the upper bound of a range partition bound expression.
+// It returns false for MAXVALUE and default partitions.
+func parsePartitionUpperBound(expr string) (time.Time, bool) {
+	m := partitionUpperBound.FindStringSubmatch(expr)
+	if m == nil {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05-07", "2006-01-02 15:04:05-07:00", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, m[1]); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// monthStart returns midnight UTC on the first day of t's month
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
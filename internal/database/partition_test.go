@@ -0,0 +1,147 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartitionUpperBound(t *testing.T) {
+	upper, ok := parsePartitionUpperBound("FOR VALUES FROM ('2024-01-01 00:00:00+00') TO ('2024-02-01 00:00:00+00')")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), upper)
+
+	upper, ok = parsePartitionUpperBound("FOR VALUES FROM (MINVALUE) TO ('2024-02-01 05:30:00+05:30')")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), upper)
+
+	_, ok = parsePartitionUpperBound("DEFAULT")
+	assert.False(t, ok)
+	_, ok = parsePartitionUpperBound("FOR VALUES FROM ('2024-01-01 00:00:00+00') TO (MAXVALUE)")
+	assert.False(t, ok)
+}
+
+func TestPartitionNames(t *testing.T) {
+	assert.Equal(t, "messages_p2024_03", partitionName("messages", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)))
+	// Months are UTC months
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		monthStart(time.Date(2024, 3, 31, 23, 0, 0, 0, time.FixedZone("", -5*3600))))
+
+	long := strings.Repeat("x", 63)
+	assert.Len(t, legacyIndexName(long), 63)
+	assert.Equal(t, "idx_legacy", legacyIndexName("idx"))
+}
+
+func TestPartitionTable(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	table := PartitionedTable{Name: "partition_test_" + strings.ReplaceAll(uuid.New().String()[:8], "-", ""), Column: "created_at"}
+	ref := table.Name + "_refs"
+	t.Cleanup(func() {
+		db.Exec("DROP TABLE IF EXISTS " + quoteIdent(ref))
+		db.Exec("DROP TABLE IF EXISTS " + quoteIdent(table.Name) + " CASCADE")
+	})
+
+	require.NoError(t, db.Exec("CREATE TABLE "+quoteIdent(table.Name)+
+		" (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), body text, created_at timestamptz)").Error)
+	require.NoError(t, db.Exec("CREATE INDEX "+quoteIdent("idx_"+table.Name+"_body")+" ON "+quoteIdent(table.Name)+" (body)").Error)
+	require.NoError(t, db.Exec("CREATE TABLE "+quoteIdent(ref)+" (id uuid REFERENCES "+quoteIdent(table.Name)+"(id))").Error)
+	require.NoError(t, db.Exec("INSERT INTO "+quoteIdent(table.Name)+" (body, created_at) VALUES ('old', now() - interval '1 year')").Error)
+
+	now := time.Now()
+	dropped, err := PartitionTable(db, table, now)
+	require.NoError(t, err)
+	require.Len(t, dropped, 1)
+	assert.Equal(t, ref, dropped[0].TableName)
+	assert.Contains(t, dropped[0].Definition, "REFERENCES "+table.Name+"(id)")
+	dropped, err = PartitionTable(db, table, now) // already partitioned
+	require.NoError(t, err)
+	assert.Empty(t, dropped)
+	require.NoError(t, EnsurePartitions(db, table, now, 2))
+
+	partitioned, err := IsPartitioned(db, table.Name)
+	require.NoError(t, err)
+	assert.True(t, partitioned)
+
+	var count int64
+	require.NoError(t, db.Table(table.Name).Where("body = ?", "old").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// Rows land in the current month's legacy partition or the premade ones
+	for _, at := range []time.Time{now, monthStart(now).AddDate(0, 1, 0), monthStart(now).AddDate(0, 2, 15)} {
+		require.NoError(t, db.Exec("INSERT INTO "+quoteIdent(table.Name)+" (body, created_at) VALUES ('new', ?)", at).Error)
+	}
+	assert.Error(t, db.Exec("INSERT INTO "+quoteIdent(table.Name)+" (body, created_at) VALUES ('new', ?)",
+		monthStart(now).AddDate(0, 3, 0)).Error)
+
+	var indexes int64
+	require.NoError(t, db.Raw("SELECT count(*) FROM pg_indexes WHERE tablename = ? AND indexname = ?",
+		table.Name, "idx_"+table.Name+"_body").Scan(&indexes).Error)
+	assert.Equal(t, int64(1), indexes)
+
+	// Running again creates nothing new
+	require.NoError(t, EnsurePartitions(db, table, now, 2))
+	var partitions int64
+	require.NoError(t, db.Raw("SELECT count(*) FROM pg_inherits WHERE inhparent = to_regclass(?)", table.Name).Scan(&partitions).Error)
+	assert.Equal(t, int64(3), partitions)
+}
+
+func TestPartitionTable_PopulatedRollback(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	table := PartitionedTable{Name: "partition_test_" + strings.ReplaceAll(uuid.New().String()[:8], "-", ""), Column: "created_at"}
+	ref := table.Name + "_refs"
+	t.Cleanup(func() {
+		db.Exec("DROP TABLE IF EXISTS " + quoteIdent(ref))
+		db.Exec("DROP TABLE IF EXISTS " + quoteIdent(table.Name) + " CASCADE")
+	})
+
+	require.NoError(t, db.Exec("CREATE TABLE "+quoteIdent(table.Name)+
+		" (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), body text, created_at timestamptz)").Error)
+	require.NoError(t, db.Exec("CREATE INDEX "+quoteIdent("idx_"+table.Name+"_body")+" ON "+quoteIdent(table.Name)+" (body)").Error)
+	require.NoError(t, db.Exec("CREATE TABLE "+quoteIdent(ref)+" (id uuid REFERENCES "+quoteIdent(table.Name)+"(id))").Error)
+
+	// Two years of rows, one per day, each referenced from the other table
+	require.NoError(t, db.Exec("INSERT INTO "+quoteIdent(table.Name)+" (body, created_at) "+
+		"SELECT 'row ' || n, now() - n * interval '1 day' FROM generate_series(1, 730) AS n").Error)
+	require.NoError(t, db.Exec("INSERT INTO "+quoteIdent(ref)+" SELECT id FROM "+quoteIdent(table.Name)).Error)
+
+	now := time.Now()
+	dropped, err := PartitionTable(db, table, now)
+	require.NoError(t, err)
+	require.Len(t, dropped, 1)
+	require.NoError(t, EnsurePartitions(db, table, now, 1))
+
+	countRows := func() int64 {
+		var count int64
+		require.NoError(t, db.Table(table.Name).Count(&count).Error)
+		return count
+	}
+	assert.Equal(t, int64(730), countRows())
+	require.NoError(t, db.Exec("INSERT INTO "+quoteIdent(table.Name)+" (body, created_at) VALUES ('next month', ?)",
+		monthStart(now).AddDate(0, 1, 1)).Error)
+
+	// Rolling back keeps every row, including those in the new partitions
+	require.NoError(t, UnpartitionTable(db, table))
+	require.NoError(t, UnpartitionTable(db, table)) // already plain
+	partitioned, err := IsPartitioned(db, table.Name)
+	require.NoError(t, err)
+	assert.False(t, partitioned)
+	assert.Equal(t, int64(731), countRows())
+
+	var primaryKey string
+	require.NoError(t, db.Raw(`SELECT pg_get_constraintdef(oid) FROM pg_constraint
+		WHERE contype = 'p' AND conrelid = to_regclass(?)`, table.Name).Scan(&primaryKey).Error)
+	assert.Equal(t, "PRIMARY KEY (id)", primaryKey)
+	var indexes int64
+	require.NoError(t, db.Raw("SELECT count(*) FROM pg_indexes WHERE tablename = ? AND indexname = ?",
+		table.Name, "idx_"+table.Name+"_body").Scan(&indexes).Error)
+	assert.Equal(t, int64(1), indexes)
+
+	// The dropped foreign key can be restored from the reported statement
+	require.NoError(t, db.Exec(dropped[0].AddStatement()).Error)
+	assert.Error(t, db.Exec("INSERT INTO "+quoteIdent(ref)+" VALUES (gen_random_uuid())").Error)
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zerodha/logf"
+	"gorm.io/gorm"
+)
+
+// PoolStats is a snapshot of the database connection pool
+type PoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`       // Total queries that waited for a free connection
+	WaitDurationMs     int64 `json:"wait_duration_ms"` // Total time spent waiting
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+// GetPoolStats returns the current connection pool statistics
+func GetPoolStats(db *gorm.DB) (PoolStats, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return PoolStats{}, fmt.Errorf("failed to get database instance: %w", err)
+	}
+
+	s := sqlDB.Stats()
+	return PoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMs:     s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}, nil
+}
+
+// MonitorPool logs a warning whenever queries had to wait for a free connection since
+// the previous check, which means max_open_conns is too low for the load. It returns
+// when ctx is cancelled.
+func MonitorPool(ctx context.Context, db *gorm.DB, log logf.Logger, interval time.Duration) {
+	prev, err := GetPoolStats(db)
+	if err != nil {
+		log.Error("Failed to read connection pool stats", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur, err := GetPoolStats(db)
+			if err != nil {
+				log.Error("Failed to read connection pool stats", "error", err)
+				continue
+			}
+			if waits := cur.WaitCount - prev.WaitCount; waits > 0 {
+				log.Warn("Database connection pool exhausted",
+					"waits", waits,
+					"wait_ms", cur.WaitDurationMs-prev.WaitDurationMs,
+					"in_use", cur.InUse,
+					"max_open", cur.MaxOpenConnections)
+			}
+			prev = cur
+		}
+	}
+}
@@ -27,8 +27,12 @@ func NewPostgres(cfg *config.DatabaseConfig, debug bool) (*gorm.DB, error) {
 		logLevel = logger.Info
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  dsn,
+		PreferSimpleProtocol: cfg.PreferSimpleProtocol,
+	}), &gorm.Config{
+		Logger:      logger.Default.LogMode(logLevel),
+		PrepareStmt: cfg.PrepareStmt,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -43,6 +47,7 @@ func NewPostgres(cfg *config.DatabaseConfig, debug bool) (*gorm.DB, error) {
 	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
 	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
 	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
 
 	return db, nil
 }
@@ -61,21 +66,60 @@ func GetMigrationModels() []MigrationModel {
 		{"Permission", &models.Permission{}},
 		{"CustomRole", &models.CustomRole{}},
 		{"User", &models.User{}},
+		{"UserOrganization", &models.UserOrganization{}},
+		{"OrganizationInvite", &models.OrganizationInvite{}},
 		{"Team", &models.Team{}},
 		{"TeamMember", &models.TeamMember{}},
+		{"AgentShift", &models.AgentShift{}},
 		{"APIKey", &models.APIKey{}},
+		{"APIUsageDaily", &models.APIUsageDaily{}},
 		{"SSOProvider", &models.SSOProvider{}},
+		{"GoogleSheetsConnection", &models.GoogleSheetsConnection{}},
+		{"CalendarConnection", &models.CalendarConnection{}},
+		{"CRMConnection", &models.CRMConnection{}},
+		{"SMTPSettings", &models.SMTPSettings{}},
 		{"Webhook", &models.Webhook{}},
+		{"NotificationChannel", &models.NotificationChannel{}},
+		{"EnrichmentProvider", &models.EnrichmentProvider{}},
+		{"Announcement", &models.Announcement{}},
+		{"AnnouncementRead", &models.AnnouncementRead{}},
+		{"FeatureFlag", &models.FeatureFlag{}},
+		{"FeatureFlagOverride", &models.FeatureFlagOverride{}},
+		{"OrganizationPlugin", &models.OrganizationPlugin{}},
+		{"OrganizationEncryptionKey", &models.OrganizationEncryptionKey{}},
+		{"PipelineScript", &models.PipelineScript{}},
+		{"ScriptExecution", &models.ScriptExecution{}},
 		{"CustomAction", &models.CustomAction{}},
 		{"WhatsAppAccount", &models.WhatsAppAccount{}},
 		{"Contact", &models.Contact{}},
+		{"ContactConsent", &models.ContactConsent{}},
+		{"ContactOptOut", &models.ContactOptOut{}},
+		{"ContactImport", &models.ContactImport{}},
+		{"ContactBulkUpdate", &models.ContactBulkUpdate{}},
+		{"CRMContactLink", &models.CRMContactLink{}},
+		{"Segment", &models.Segment{}},
 		{"Message", &models.Message{}},
+		{"ArchivedMessage", &models.ArchivedMessage{}},
+		{"MessageStatusEvent", &models.MessageStatusEvent{}},
+		{"OutboxMessage", &models.OutboxMessage{}},
+		{"TransactionalSend", &models.TransactionalSend{}},
+		{"Verification", &models.Verification{}},
+		{"MessageApproval", &models.MessageApproval{}},
+		{"AnalyticsExport", &models.AnalyticsExport{}},
+		{"PublicDashboard", &models.PublicDashboard{}},
 		{"Template", &models.Template{}},
 		{"WhatsAppFlow", &models.WhatsAppFlow{}},
+		{"EmailGateway", &models.EmailGateway{}},
 
 		// Bulk & Notifications
 		{"BulkMessageCampaign", &models.BulkMessageCampaign{}},
 		{"BulkMessageRecipient", &models.BulkMessageRecipient{}},
+		{"RecipientImport", &models.RecipientImport{}},
+		{"TemplateSendBatch", &models.TemplateSendBatch{}},
+		{"TemplateSendItem", &models.TemplateSendItem{}},
+		{"Sequence", &models.Sequence{}},
+		{"SequenceStep", &models.SequenceStep{}},
+		{"SequenceEnrollment", &models.SequenceEnrollment{}},
 		{"NotificationRule", &models.NotificationRule{}},
 
 		// Chatbot models
@@ -83,20 +127,36 @@ func GetMigrationModels() []MigrationModel {
 		{"KeywordRule", &models.KeywordRule{}},
 		{"ChatbotFlow", &models.ChatbotFlow{}},
 		{"ChatbotFlowStep", &models.ChatbotFlowStep{}},
+		{"ChatbotFlowVersion", &models.ChatbotFlowVersion{}},
 		{"ChatbotSession", &models.ChatbotSession{}},
 		{"ChatbotSessionMessage", &models.ChatbotSessionMessage{}},
+		{"ChatbotSessionTrace", &models.ChatbotSessionTrace{}},
 		{"AIContext", &models.AIContext{}},
+		{"ContactMemory", &models.ContactMemory{}},
+		{"ChatbotVariable", &models.ChatbotVariable{}},
+		{"WrapUpCode", &models.WrapUpCode{}},
 		{"AgentTransfer", &models.AgentTransfer{}},
+		{"TransferAssignmentOffer", &models.TransferAssignmentOffer{}},
+		{"ChatRating", &models.ChatRating{}},
+		{"UnansweredQuestion", &models.UnansweredQuestion{}},
 
 		// User tracking
 		{"UserAvailabilityLog", &models.UserAvailabilityLog{}},
+		{"AuditLog", &models.AuditLog{}},
+		{"ImpersonationSession", &models.ImpersonationSession{}},
 
 		// Canned responses
 		{"CannedResponse", &models.CannedResponse{}},
+		{"CannedResponseUsage", &models.CannedResponseUsage{}},
 
 		// Catalogs
 		{"Catalog", &models.Catalog{}},
 		{"CatalogProduct", &models.CatalogProduct{}},
+
+		// Engagement tracking
+		{"ButtonClick", &models.ButtonClick{}},
+		{"ShortLink", &models.ShortLink{}},
+		{"LinkClick", &models.LinkClick{}},
 	}
 }
 
@@ -146,6 +206,17 @@ func RunMigrationWithProgress(db *gorm.DB) error {
 		currentStep++
 	}
 
+	// Messages are archived and may be partitioned, so nothing references them with a
+	// foreign key; drop the ones created before the models stopped declaring them
+	droppedKeys, err := DropReferencingForeignKeys(silentDB, "messages")
+	if err != nil {
+		fmt.Printf("\n  \033[31m✗ Failed to drop message foreign keys\033[0m\n\n")
+		return err
+	}
+	for _, fk := range droppedKeys {
+		fmt.Printf("\n  \033[33m! Dropped foreign key %s on %s, restore it with: %s\033[0m", fk.Name, fk.TableName, fk.AddStatement())
+	}
+
 	// Create indexes
 	for _, idx := range indexes {
 		printProgress(currentStep, totalSteps)
@@ -200,6 +271,9 @@ func getIndexes() []string {
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_org_phone ON contacts(organization_id, phone_number)`,
 		`CREATE INDEX IF NOT EXISTS idx_contacts_assigned_read ON contacts(assigned_user_id, is_read)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_phone_status ON chatbot_sessions(organization_id, phone_number, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_usage_daily_org_day ON api_usage_daily(organization_id, day)`,
+		`CREATE INDEX IF NOT EXISTS idx_agent_shifts_org_day ON agent_shifts(organization_id, day_of_week)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_chatbot_flow_versions_flow_version ON chatbot_flow_versions(flow_id, version) WHERE deleted_at IS NULL`,
 		`CREATE INDEX IF NOT EXISTS idx_keyword_rules_priority ON keyword_rules(organization_id, is_enabled, priority DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_agent_transfers_active ON agent_transfers(organization_id, phone_number, status)`,
 		`CREATE INDEX IF NOT EXISTS idx_agent_transfers_org_contact ON agent_transfers(organization_id, contact_id, status)`,
@@ -210,12 +284,27 @@ func getIndexes() []string {
 		`CREATE INDEX IF NOT EXISTS idx_keyword_rules_account ON keyword_rules(whats_app_account, is_enabled, priority DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_chatbot_flows_account ON chatbot_flows(whats_app_account, is_enabled)`,
 		`CREATE INDEX IF NOT EXISTS idx_ai_contexts_account ON ai_contexts(whats_app_account, is_enabled, priority DESC)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_chatbot_variables_org_name ON chatbot_variables(organization_id, name) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_wrap_up_codes_org_code ON wrap_up_codes(organization_id, code) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_organizations_user_org ON user_organizations(user_id, organization_id) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invites_org_email ON organization_invites(organization_id, email) WHERE deleted_at IS NULL`,
 		`CREATE INDEX IF NOT EXISTS idx_bulk_campaigns_account ON bulk_message_campaigns(whats_app_account, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_bulk_recipients_campaign_phone ON bulk_message_recipients(campaign_id, phone_number)`,
+		`CREATE INDEX IF NOT EXISTS idx_sequence_enrollments_due ON sequence_enrollments(next_step_at) WHERE status = 'active'`,
+		`CREATE INDEX IF NOT EXISTS idx_sequence_enrollments_contact_active ON sequence_enrollments(contact_id) WHERE status = 'active'`,
 		`CREATE INDEX IF NOT EXISTS idx_notification_rules_account ON notification_rules(whats_app_account, is_enabled)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_account ON messages(whats_app_account, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_archived_messages_contact_created ON archived_messages(contact_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_contacts_account ON contacts(whats_app_account)`,
+		`CREATE INDEX IF NOT EXISTS idx_contact_consents_org_phone ON contact_consents(organization_id, phone_number) WHERE revoked_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_contact_consents_org_consented ON contact_consents(organization_id, consented_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_contact_opt_outs_org_created ON contact_opt_outs(organization_id, created_at DESC)`,
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_canned_responses_org_name ON canned_responses(organization_id, name)`,
 		`CREATE INDEX IF NOT EXISTS idx_canned_responses_active ON canned_responses(organization_id, is_active, usage_count DESC)`,
+		// Trigram indexes back the typo-tolerant canned response search
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_canned_responses_shortcut_trgm ON canned_responses USING gin (shortcut gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_canned_responses_content_trgm ON canned_responses USING gin (content gin_trgm_ops)`,
 		`CREATE INDEX IF NOT EXISTS idx_webhooks_org_active ON webhooks(organization_id, is_active)`,
 		`CREATE INDEX IF NOT EXISTS idx_availability_logs_user_time ON user_availability_logs(user_id, started_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_availability_logs_org_time ON user_availability_logs(organization_id, started_at DESC)`,
@@ -224,6 +313,17 @@ func getIndexes() []string {
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_roles_org_name ON custom_roles(organization_id, name)`,
 		`CREATE INDEX IF NOT EXISTS idx_custom_roles_org_system ON custom_roles(organization_id, is_system)`,
 		`CREATE INDEX IF NOT EXISTS idx_custom_roles_org_default ON custom_roles(organization_id, is_default) WHERE is_default = true`,
+		// Button click indexes
+		`CREATE INDEX IF NOT EXISTS idx_button_clicks_org_template ON button_clicks(organization_id, template_name, clicked_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_button_clicks_campaign ON button_clicks(campaign_id, button_text) WHERE campaign_id IS NOT NULL`,
+		// Short link indexes
+		`CREATE INDEX IF NOT EXISTS idx_link_clicks_link_time ON link_clicks(short_link_id, clicked_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_short_links_campaign_recipient ON short_links(campaign_id, recipient_id) WHERE campaign_id IS NOT NULL`,
+		// Chat rating indexes
+		`CREATE INDEX IF NOT EXISTS idx_chat_ratings_contact_status ON chat_ratings(contact_id, status, rated_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_ratings_agent_rated ON chat_ratings(organization_id, agent_id, rated_at) WHERE rating > 0`,
+		// Pending transfer offers are swept for expiry
+		`CREATE INDEX IF NOT EXISTS idx_transfer_offers_pending ON transfer_assignment_offers(expires_at) WHERE status = 'pending'`,
 	}
 }
 
@@ -268,12 +368,21 @@ func CreateIndexes(db *gorm.DB) error {
 
 		// Bulk messaging indexes
 		`CREATE INDEX IF NOT EXISTS idx_bulk_campaigns_account ON bulk_message_campaigns(whats_app_account, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_bulk_recipients_campaign_phone ON bulk_message_recipients(campaign_id, phone_number)`,
 		`CREATE INDEX IF NOT EXISTS idx_notification_rules_account ON notification_rules(whats_app_account, is_enabled)`,
 
 		// Messages and contacts by account
 		`CREATE INDEX IF NOT EXISTS idx_messages_account ON messages(whats_app_account, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_archived_messages_contact_created ON archived_messages(contact_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_contacts_account ON contacts(whats_app_account)`,
 
+		// Contact consent indexes
+		`CREATE INDEX IF NOT EXISTS idx_contact_consents_org_phone ON contact_consents(organization_id, phone_number) WHERE revoked_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_contact_consents_org_consented ON contact_consents(organization_id, consented_at)`,
+
+		// Contact opt-out history
+		`CREATE INDEX IF NOT EXISTS idx_contact_opt_outs_org_created ON contact_opt_outs(organization_id, created_at DESC)`,
+
 		// Canned responses indexes
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_canned_responses_org_name ON canned_responses(organization_id, name)`,
 		`CREATE INDEX IF NOT EXISTS idx_canned_responses_active ON canned_responses(organization_id, is_active, usage_count DESC)`,
@@ -292,6 +401,20 @@ func CreateIndexes(db *gorm.DB) error {
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_roles_org_name ON custom_roles(organization_id, name)`,
 		`CREATE INDEX IF NOT EXISTS idx_custom_roles_org_system ON custom_roles(organization_id, is_system)`,
 		`CREATE INDEX IF NOT EXISTS idx_custom_roles_org_default ON custom_roles(organization_id, is_default) WHERE is_default = true`,
+
+		// Button click indexes
+		`CREATE INDEX IF NOT EXISTS idx_button_clicks_org_template ON button_clicks(organization_id, template_name, clicked_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_button_clicks_campaign ON button_clicks(campaign_id, button_text) WHERE campaign_id IS NOT NULL`,
+
+		// Short link indexes
+		`CREATE INDEX IF NOT EXISTS idx_link_clicks_link_time ON link_clicks(short_link_id, clicked_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_short_links_campaign_recipient ON short_links(campaign_id, recipient_id) WHERE campaign_id IS NOT NULL`,
+
+		// Chat rating indexes
+		`CREATE INDEX IF NOT EXISTS idx_chat_ratings_contact_status ON chat_ratings(contact_id, status, rated_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_ratings_agent_rated ON chat_ratings(organization_id, agent_id, rated_at) WHERE rating > 0`,
+		// Pending transfer offers are swept for expiry
+		`CREATE INDEX IF NOT EXISTS idx_transfer_offers_pending ON transfer_assignment_offers(expires_at) WHERE status = 'pending'`,
 	}
 
 	for _, idx := range indexes {
@@ -392,7 +515,7 @@ func SeedPermissionsAndRoles(db *gorm.DB) error {
 }
 
 // SeedSystemRolesForAllOrgs creates system roles for all existing organizations
-// This is idempotent - it skips organizations that already have system roles
+// This is idempotent - it only adds the system roles an organization is missing
 func SeedSystemRolesForAllOrgs(db *gorm.DB) error {
 	var orgs []models.Organization
 	if err := db.Find(&orgs).Error; err != nil {
@@ -559,16 +682,17 @@ func MigrateExistingUserRoles(db *gorm.DB) error {
 	return nil
 }
 
-// SeedSystemRolesForOrg creates system roles for an organization
+// SeedSystemRolesForOrg creates the system roles an organization doesn't have yet, so
+// roles added in later releases reach existing organizations
 func SeedSystemRolesForOrg(db *gorm.DB, orgID uuid.UUID) error {
-	// Check if system roles exist for this org
-	var roleCount int64
-	if err := db.Model(&models.CustomRole{}).Where("organization_id = ? AND is_system = ?", orgID, true).Count(&roleCount).Error; err != nil {
-		return fmt.Errorf("failed to count roles: %w", err)
+	// A custom role with the same name keeps its place; the system role is skipped
+	var existing []string
+	if err := db.Model(&models.CustomRole{}).Where("organization_id = ?", orgID).Pluck("name", &existing).Error; err != nil {
+		return fmt.Errorf("failed to list roles: %w", err)
 	}
-
-	if roleCount > 0 {
-		return nil // Already seeded
+	seeded := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		seeded[name] = true
 	}
 
 	// Get all permissions from database
@@ -588,23 +712,29 @@ func SeedSystemRolesForOrg(db *gorm.DB, orgID uuid.UUID) error {
 
 	// Create system roles
 	systemRoles := []struct {
-		Name        string
-		Description string
-		IsDefault   bool
+		Name               string
+		Description        string
+		IsDefault          bool
+		RestrictDataAccess bool
 	}{
-		{"admin", "Full system access", false},
-		{"manager", "Manage chatbot, campaigns, and team operations", false},
-		{"agent", "Handle customer conversations", true},
+		{"admin", "Full system access", false, false},
+		{"manager", "Manage chatbot, campaigns, and team operations", false, false},
+		{"agent", "Handle customer conversations", true, false},
+		{"analyst", "View analytics and exports without contact details or message content", false, true},
 	}
 
 	for _, sr := range systemRoles {
+		if seeded[sr.Name] {
+			continue
+		}
 		role := models.CustomRole{
-			BaseModel:      models.BaseModel{ID: uuid.New()},
-			OrganizationID: orgID,
-			Name:           sr.Name,
-			Description:    sr.Description,
-			IsSystem:       true,
-			IsDefault:      sr.IsDefault,
+			BaseModel:          models.BaseModel{ID: uuid.New()},
+			OrganizationID:     orgID,
+			Name:               sr.Name,
+			Description:        sr.Description,
+			IsSystem:           true,
+			IsDefault:          sr.IsDefault && len(existing) == 0,
+			RestrictDataAccess: sr.RestrictDataAccess,
 		}
 
 		// Add permissions
@@ -0,0 +1,180 @@
+// Package emailgateway turns structured inbound emails, such as alerts from legacy
+// systems, into WhatsApp template parameters and recipients.
+package emailgateway
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Email is an inbound email as delivered by the mail provider's webhook
+type Email struct {
+	From    string
+	Subject string
+	Text    string // Plain text body
+}
+
+// Parts of the email a parameter can be read from
+const (
+	SourceSubject = "subject"
+	SourceBody    = "body"
+	SourceFrom    = "from"
+)
+
+// ParamRule fills one template parameter from the email. Without a pattern the whole
+// source is used; with one, its first capture group (or the whole match if it has none).
+type ParamRule struct {
+	Source  string `json:"source"`
+	Pattern string `json:"pattern"`
+}
+
+// SenderAddress returns the bare, lowercased address of a From header
+func SenderAddress(from string) string {
+	if addr, err := mail.ParseAddress(from); err == nil {
+		return strings.ToLower(addr.Address)
+	}
+	return strings.ToLower(strings.TrimSpace(from))
+}
+
+// SenderAllowed reports whether from matches an allowlist entry. Entries are full
+// addresses or "@domain" for every address at a domain. An empty allowlist allows no one.
+func SenderAllowed(allowlist []string, from string) bool {
+	addr := SenderAddress(from)
+	if addr == "" {
+		return false
+	}
+	for _, entry := range allowlist {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.HasPrefix(entry, "@") {
+			if strings.HasSuffix(addr, entry) {
+				return true
+			}
+		} else if addr == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSubject reports whether the subject matches pattern. An empty pattern matches
+// every subject; an invalid one matches none.
+func MatchSubject(pattern, subject string) bool {
+	if pattern == "" {
+		return true
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(subject)
+}
+
+// ExtractParams applies the rules to the email. It returns the values found and the
+// names of parameters whose pattern didn't match.
+func ExtractParams(rules map[string]ParamRule, email Email) (map[string]string, []string) {
+	params := make(map[string]string, len(rules))
+	var missing []string
+	for name, rule := range rules {
+		value, ok := extract(rule.Pattern, source(rule.Source, email))
+		if !ok || value == "" {
+			missing = append(missing, name)
+			continue
+		}
+		params[name] = value
+	}
+	sort.Strings(missing)
+	return params, missing
+}
+
+// ExtractRecipients returns every match of pattern in the email body (the first capture
+// group if the pattern has one), for mapping alerts to the contacts they mention
+func ExtractRecipients(pattern string, email Email) []string {
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	var found []string
+	for _, m := range re.FindAllStringSubmatch(email.Text, -1) {
+		if len(m) > 1 {
+			found = append(found, m[1])
+		} else {
+			found = append(found, m[0])
+		}
+	}
+	return found
+}
+
+// Validate checks a gateway's allowlist, patterns and parameter rules and returns a
+// user-facing error message, or "" if they are valid
+func Validate(allowlist []string, subjectPattern, recipientPattern string, rules map[string]ParamRule) string {
+	if len(allowlist) == 0 {
+		return "at least one allowed sender is required"
+	}
+	for _, entry := range allowlist {
+		entry = strings.TrimSpace(entry)
+		if strings.HasPrefix(entry, "@") {
+			if len(entry) < 2 || strings.Contains(entry[1:], "@") {
+				return fmt.Sprintf("invalid sender domain: %s", entry)
+			}
+			continue
+		}
+		if _, err := mail.ParseAddress(entry); err != nil {
+			return fmt.Sprintf("invalid sender address: %s", entry)
+		}
+	}
+	if _, err := regexp.Compile(subjectPattern); err != nil {
+		return "invalid subject pattern: " + err.Error()
+	}
+	if _, err := regexp.Compile(recipientPattern); err != nil {
+		return "invalid recipient pattern: " + err.Error()
+	}
+	for name, rule := range rules {
+		switch rule.Source {
+		case SourceSubject, SourceBody, SourceFrom:
+		default:
+			return fmt.Sprintf("parameter %s: source must be subject, body or from", name)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Sprintf("parameter %s: invalid pattern: %s", name, err.Error())
+		}
+	}
+	return ""
+}
+
+func source(name string, email Email) string {
+	switch name {
+	case SourceSubject:
+		return email.Subject
+	case SourceFrom:
+		return email.From
+	default:
+		return email.Text
+	}
+}
+
+func extract(pattern, text string) (string, bool) {
+	if pattern == "" {
+		return strings.TrimSpace(text), true
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", false
+	}
+	m := re.FindStringSubmatch(text)
+	if m == nil {
+		return "", false
+	}
+	if len(m) > 1 {
+		return strings.TrimSpace(m[1]), true
+	}
+	return strings.TrimSpace(m[0]), true
+}
@@ -0,0 +1,56 @@
+package emailgateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderAllowed(t *testing.T) {
+	allow := []string{"ops@legacy.example.com", "@alerts.example.com"}
+	assert.True(t, SenderAllowed(allow, "Ops <OPS@legacy.example.com>"))
+	assert.True(t, SenderAllowed(allow, "nagios@alerts.example.com"))
+	assert.False(t, SenderAllowed(allow, "someone@example.com"))
+	assert.False(t, SenderAllowed(allow, "nagios@evil-alerts.example.com.attacker.io"))
+	assert.False(t, SenderAllowed(nil, "ops@legacy.example.com"))
+}
+
+func TestMatchSubject(t *testing.T) {
+	assert.True(t, MatchSubject("", "anything"))
+	assert.True(t, MatchSubject(`^\[ALERT\]`, "[ALERT] Disk full"))
+	assert.False(t, MatchSubject(`^\[ALERT\]`, "Re: [ALERT] Disk full"))
+	assert.False(t, MatchSubject("(", "anything"))
+}
+
+func TestExtractParams(t *testing.T) {
+	email := Email{
+		From:    "nagios@alerts.example.com",
+		Subject: "[ALERT] Disk full on db-01",
+		Text:    "Host: db-01\nUsage: 97%\n",
+	}
+	params, missing := ExtractParams(map[string]ParamRule{
+		"host":    {Source: SourceSubject, Pattern: `on (\S+)`},
+		"usage":   {Source: SourceBody, Pattern: `Usage: (\d+%)`},
+		"sender":  {Source: SourceFrom},
+		"service": {Source: SourceBody, Pattern: `Service: (\S+)`},
+	}, email)
+
+	assert.Equal(t, "db-01", params["host"])
+	assert.Equal(t, "97%", params["usage"])
+	assert.Equal(t, "nagios@alerts.example.com", params["sender"])
+	assert.Equal(t, []string{"service"}, missing)
+}
+
+func TestExtractRecipients(t *testing.T) {
+	email := Email{Text: "Notify: +919876543210\nNotify: +14155550100\n"}
+	assert.Equal(t, []string{"+919876543210", "+14155550100"}, ExtractRecipients(`Notify: (\+\d+)`, email))
+	assert.Nil(t, ExtractRecipients("", email))
+}
+
+func TestValidate(t *testing.T) {
+	assert.Equal(t, "", Validate([]string{"@alerts.example.com"}, "", "", nil))
+	assert.NotEmpty(t, Validate(nil, "", "", nil))
+	assert.NotEmpty(t, Validate([]string{"not an address"}, "", "", nil))
+	assert.NotEmpty(t, Validate([]string{"@alerts.example.com"}, "(", "", nil))
+	assert.NotEmpty(t, Validate([]string{"@alerts.example.com"}, "", "", map[string]ParamRule{"1": {Source: "header"}}))
+}
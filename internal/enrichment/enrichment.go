@@ -0,0 +1,187 @@
+// Package enrichment looks up details about a contact's phone number from external
+// providers: Clearbit-style person APIs or a custom HTTP endpoint.
+package enrichment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Supported providers
+const (
+	ProviderClearbit = "clearbit"
+	ProviderCustom   = "custom"
+)
+
+// DefaultClearbitURL is used for clearbit providers without a URL
+const DefaultClearbitURL = "https://person.clearbit.com/v2/people/find"
+
+// ErrNotFound is returned when a provider has no details for the number
+var ErrNotFound = errors.New("no match found")
+
+// Provider is a configured lookup provider
+type Provider struct {
+	Type   string
+	URL    string // Optional for clearbit
+	APIKey string
+}
+
+// Request identifies the contact to look up
+type Request struct {
+	PhoneNumber string // Digits only, as stored on contacts
+	Name        string // WhatsApp profile name, if known
+}
+
+// Result holds the details found for a contact
+type Result struct {
+	Name      string                 `json:"name,omitempty"`
+	Company   string                 `json:"company,omitempty"`
+	AvatarURL string                 `json:"avatar_url,omitempty"` // HTTPS URL of a profile photo
+	Fields    map[string]interface{} `json:"fields,omitempty"`     // Saved as contact custom fields
+}
+
+// Empty reports whether the result carries no details
+func (r *Result) Empty() bool {
+	return r.Name == "" && r.Company == "" && r.AvatarURL == "" && len(r.Fields) == 0
+}
+
+// IsSupported reports whether provider is a known provider type
+func IsSupported(provider string) bool {
+	return provider == ProviderClearbit || provider == ProviderCustom
+}
+
+// ValidateURL checks that a provider URL is an absolute HTTPS URL, since requests carry
+// the API key and customer phone numbers
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid provider URL")
+	}
+	if u.Scheme != "https" {
+		return errors.New("provider URL must use https")
+	}
+	return nil
+}
+
+// Lookup asks a provider for details about a phone number. It returns ErrNotFound
+// when the provider has no match.
+func Lookup(ctx context.Context, client *http.Client, p Provider, req Request) (*Result, error) {
+	switch p.Type {
+	case ProviderClearbit:
+		return lookupClearbit(ctx, client, p, req)
+	case ProviderCustom:
+		return lookupCustom(ctx, client, p, req)
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", p.Type)
+	}
+}
+
+// clearbitPerson is the subset of a Clearbit person record that maps onto contacts
+type clearbitPerson struct {
+	Name struct {
+		FullName string `json:"fullName"`
+	} `json:"name"`
+	Employment struct {
+		Name  string `json:"name"`
+		Title string `json:"title"`
+	} `json:"employment"`
+	Location string `json:"location"`
+	Avatar   string `json:"avatar"`
+}
+
+func lookupClearbit(ctx context.Context, client *http.Client, p Provider, req Request) (*Result, error) {
+	endpoint := p.URL
+	if endpoint == "" {
+		endpoint = DefaultClearbitURL
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("phone", "+"+strings.TrimPrefix(req.PhoneNumber, "+"))
+	u.RawQuery = q.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var person clearbitPerson
+	if err := do(client, httpReq, p.APIKey, &person); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Name:      person.Name.FullName,
+		Company:   person.Employment.Name,
+		AvatarURL: person.Avatar,
+		Fields:    map[string]interface{}{},
+	}
+	if person.Employment.Title != "" {
+		result.Fields["job_title"] = person.Employment.Title
+	}
+	if person.Location != "" {
+		result.Fields["location"] = person.Location
+	}
+	return result, nil
+}
+
+func lookupCustom(ctx context.Context, client *http.Client, p Provider, req Request) (*Result, error) {
+	body, err := json.Marshal(map[string]string{
+		"phone_number": req.PhoneNumber,
+		"name":         req.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	var result Result
+	if err := do(client, httpReq, p.APIKey, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do sends an authenticated request and decodes a JSON response into out
+func do(client *http.Client, req *http.Request, apiKey string, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusNoContent:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusAccepted:
+		// Clearbit answers 202 while it looks a record up asynchronously
+		return ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("provider returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("invalid provider response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup_Clearbit(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "+919876543210", r.URL.Query().Get("phone"))
+		assert.Equal(t, "Bearer sk_test", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{
+			"name": {"fullName": "Ada Lovelace"},
+			"employment": {"name": "Analytical Engines", "title": "Engineer"},
+			"location": "London, UK",
+			"avatar": "https://example.com/ada.png"
+		}`))
+	}))
+	defer server.Close()
+
+	result, err := Lookup(context.Background(), server.Client(),
+		Provider{Type: ProviderClearbit, URL: server.URL, APIKey: "sk_test"},
+		Request{PhoneNumber: "919876543210"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Ada Lovelace", result.Name)
+	assert.Equal(t, "Analytical Engines", result.Company)
+	assert.Equal(t, "https://example.com/ada.png", result.AvatarURL)
+	assert.Equal(t, map[string]interface{}{"job_title": "Engineer", "location": "London, UK"}, result.Fields)
+}
+
+func TestLookup_Custom(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "919876543210", body["phone_number"])
+		assert.Equal(t, "Ada", body["name"])
+
+		_, _ = w.Write([]byte(`{"company": "Analytical Engines", "fields": {"plan": "pro", "seats": 5}}`))
+	}))
+	defer server.Close()
+
+	result, err := Lookup(context.Background(), server.Client(),
+		Provider{Type: ProviderCustom, URL: server.URL},
+		Request{PhoneNumber: "919876543210", Name: "Ada"})
+	require.NoError(t, err)
+
+	assert.Empty(t, result.Name)
+	assert.Equal(t, "Analytical Engines", result.Company)
+	assert.Equal(t, "pro", result.Fields["plan"])
+	assert.Equal(t, float64(5), result.Fields["seats"])
+}
+
+func TestLookup_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		notFound bool
+	}{
+		{"not found", http.StatusNotFound, true},
+		{"queued", http.StatusAccepted, true},
+		{"unauthorized", http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			_, err := Lookup(context.Background(), server.Client(),
+				Provider{Type: ProviderCustom, URL: server.URL}, Request{PhoneNumber: "15551234567"})
+			require.Error(t, err)
+			assert.Equal(t, tt.notFound, errors.Is(err, ErrNotFound))
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	assert.NoError(t, ValidateURL("https://crm.example.com/lookup"))
+	assert.Error(t, ValidateURL("http://crm.example.com/lookup"))
+	assert.Error(t, ValidateURL("not a url"))
+}
@@ -0,0 +1,103 @@
+// Package featureflags decides which experimental modules are enabled for an organization,
+// and which contacts fall within a percentage rollout of chatbot automation.
+package featureflags
+
+import (
+	"hash/fnv"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// Built-in flags
+const (
+	AISuggestions = "ai_suggestions" // AI reply suggestions in the chat composer
+	NewRouting    = "new_routing"    // Next-generation agent routing for transfers
+	Commerce      = "commerce"       // Catalogs and products
+)
+
+// Definition describes a flag known to the application
+type Definition struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"` // Used when the flag has no stored configuration
+}
+
+// Known lists the built-in flags. Commerce shipped before flags existed, so it stays on
+// unless switched off.
+var Known = []Definition{
+	{Key: AISuggestions, Description: "AI reply suggestions for agents", Default: false},
+	{Key: NewRouting, Description: "New agent routing for transfers", Default: false},
+	{Key: Commerce, Description: "Catalogs and products", Default: true},
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,62}$`)
+
+// ValidKey reports whether key can be used as a flag key (lowercase snake_case)
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Lookup returns the built-in definition for key
+func Lookup(key string) (Definition, bool) {
+	for _, d := range Known {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Rule is a flag's stored configuration
+type Rule struct {
+	Enabled        bool // Master switch
+	RolloutPercent int  // Share of organizations (0-100) that get the flag while enabled
+}
+
+// Evaluate decides whether the flag is on for orgID. An organization override wins;
+// otherwise the rule applies, and without a rule the built-in default is used.
+func Evaluate(key string, rule *Rule, override *bool, orgID uuid.UUID) bool {
+	if override != nil {
+		return *override
+	}
+	if rule == nil {
+		d, _ := Lookup(key)
+		return d.Default
+	}
+	return rule.Enabled && InRollout(key, orgID, rule.RolloutPercent)
+}
+
+// InRollout reports whether orgID falls within the first percent of organizations for key.
+// Hashing the key with the org ID keeps an organization's bucket stable as the percentage
+// grows, while different flags roll out to different organizations first.
+func InRollout(key string, orgID uuid.UUID, percent int) bool {
+	if percent >= 100 {
+		return true
+	}
+	if percent <= 0 {
+		return false
+	}
+	return bucket([]byte(key), orgID[:]) < percent
+}
+
+// ContactInRollout reports whether a contact falls within the first percent of contacts
+// for the automation identified by seed (a chatbot settings or flow ID). Contacts keep
+// their bucket as the percentage grows, and each automation picks a different first share.
+func ContactInRollout(seed uuid.UUID, phoneNumber string, percent int) bool {
+	if percent >= 100 {
+		return true
+	}
+	if percent <= 0 {
+		return false
+	}
+	return bucket(seed[:], []byte(phoneNumber)) < percent
+}
+
+// bucket hashes the parts into one of 100 buckets
+func bucket(parts ...[]byte) int {
+	h := fnv.New32a()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,88 @@
+package featureflags
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestEvaluate_DefaultsWithoutRule(t *testing.T) {
+	org := uuid.New()
+	assert.False(t, Evaluate(AISuggestions, nil, nil, org))
+	assert.True(t, Evaluate(Commerce, nil, nil, org))
+	assert.False(t, Evaluate("unknown_flag", nil, nil, org))
+}
+
+func TestEvaluate_OverrideWins(t *testing.T) {
+	org := uuid.New()
+	assert.True(t, Evaluate(NewRouting, &Rule{Enabled: false}, boolPtr(true), org))
+	assert.False(t, Evaluate(Commerce, &Rule{Enabled: true, RolloutPercent: 100}, boolPtr(false), org))
+}
+
+func TestEvaluate_Rule(t *testing.T) {
+	org := uuid.New()
+	assert.True(t, Evaluate(NewRouting, &Rule{Enabled: true, RolloutPercent: 100}, nil, org))
+	assert.False(t, Evaluate(NewRouting, &Rule{Enabled: false, RolloutPercent: 100}, nil, org))
+	assert.False(t, Evaluate(NewRouting, &Rule{Enabled: true, RolloutPercent: 0}, nil, org))
+}
+
+func TestInRollout_StableAndMonotonic(t *testing.T) {
+	orgs := make([]uuid.UUID, 1000)
+	for i := range orgs {
+		orgs[i] = uuid.New()
+	}
+
+	count := func(percent int) int {
+		n := 0
+		for _, org := range orgs {
+			if InRollout(NewRouting, org, percent) {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Roughly the requested share is enabled
+	assert.InDelta(t, 250, count(25), 60)
+
+	// Organizations in the rollout stay in it as the percentage grows
+	for _, org := range orgs {
+		if InRollout(NewRouting, org, 10) {
+			assert.True(t, InRollout(NewRouting, org, 50))
+		}
+	}
+}
+
+func TestContactInRollout(t *testing.T) {
+	seed := uuid.New()
+	phones := make([]string, 1000)
+	for i := range phones {
+		phones[i] = fmt.Sprintf("9198%08d", i)
+	}
+
+	n := 0
+	for _, phone := range phones {
+		if ContactInRollout(seed, phone, 5) {
+			n++
+			// Contacts in the rollout stay in it as the percentage grows
+			assert.True(t, ContactInRollout(seed, phone, 20))
+		}
+	}
+	assert.InDelta(t, 50, n, 30)
+
+	assert.True(t, ContactInRollout(seed, phones[0], 100))
+	assert.False(t, ContactInRollout(seed, phones[0], 0))
+}
+
+func TestValidKey(t *testing.T) {
+	assert.True(t, ValidKey("ai_suggestions"))
+	assert.True(t, ValidKey("beta2"))
+	assert.False(t, ValidKey("AI"))
+	assert.False(t, ValidKey("with-dash"))
+	assert.False(t, ValidKey("1starts_with_digit"))
+	assert.False(t, ValidKey(""))
+}
@@ -0,0 +1,155 @@
+package frontend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// cacheImmutable is used for Vite's content-hashed build output under assets/
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheRevalidate makes browsers check the ETag before reusing index.html and unhashed files
+	cacheRevalidate = "no-cache"
+
+	// minCompressSize skips compressing files too small to benefit
+	minCompressSize = 1024
+)
+
+// compressibleTypes are the extensions worth serving gzip or brotli encoded
+var compressibleTypes = map[string]bool{
+	".js":   true,
+	".mjs":  true,
+	".css":  true,
+	".html": true,
+	".json": true,
+	".svg":  true,
+	".ttf":  true,
+	".eot":  true,
+	".ico":  true,
+	".map":  true,
+}
+
+// asset is an embedded file prepared for serving, with its compressed variants
+type asset struct {
+	content      []byte
+	brotli       []byte
+	gzip         []byte
+	etag         string
+	contentType  string
+	cacheControl string
+}
+
+// newAsset prepares content for serving. Pre-compressed variants in variants (name.br,
+// name.gz) are used when present, otherwise compressible files are compressed once here.
+// variants may be nil for generated content.
+func newAsset(name string, content []byte, variants fs.FS) *asset {
+	ext := strings.ToLower(path.Ext(name))
+	a := &asset{
+		content:      content,
+		etag:         etagFor(content),
+		contentType:  mimeTypes[ext],
+		cacheControl: cacheRevalidate,
+	}
+	if a.contentType == "" {
+		a.contentType = "application/octet-stream"
+	}
+	if strings.HasPrefix(name, "assets/") {
+		a.cacheControl = cacheImmutable
+	}
+
+	if !compressibleTypes[ext] || len(content) < minCompressSize {
+		return a
+	}
+	if variants != nil {
+		a.brotli, _ = fs.ReadFile(variants, name+".br")
+		a.gzip, _ = fs.ReadFile(variants, name+".gz")
+	}
+	if a.brotli == nil {
+		a.brotli = fasthttp.AppendBrotliBytesLevel(nil, content, fasthttp.CompressBrotliDefaultCompression)
+	}
+	if a.gzip == nil {
+		a.gzip = fasthttp.AppendGzipBytesLevel(nil, content, fasthttp.CompressBestCompression)
+	}
+	return a
+}
+
+// loadAssets reads every embedded file except pre-compressed variants, which are
+// attached to the file they belong to
+func loadAssets(distSubFS fs.FS) (map[string]*asset, error) {
+	assets := make(map[string]*asset)
+	err := fs.WalkDir(distSubFS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(name, ".br") || strings.HasSuffix(name, ".gz") || name == ".gitkeep" {
+			return nil
+		}
+		content, err := fs.ReadFile(distSubFS, name)
+		if err != nil {
+			return err
+		}
+		assets[name] = newAsset(name, content, distSubFS)
+		return nil
+	})
+	return assets, err
+}
+
+// serve writes the asset, choosing the best encoding the client accepts. ETag
+// revalidation and byte ranges are handled by http.ServeContent.
+func (a *asset) serve(w http.ResponseWriter, r *http.Request, name string) {
+	h := w.Header()
+	h.Set("Content-Type", a.contentType)
+	h.Set("Cache-Control", a.cacheControl)
+
+	content, encoding := a.content, ""
+	if a.brotli != nil || a.gzip != nil {
+		h.Set("Vary", "Accept-Encoding")
+		// Ranges refer to the identity encoding, so only compress whole-file responses
+		if r.Header.Get("Range") == "" {
+			acceptEncoding := r.Header.Get("Accept-Encoding")
+			switch {
+			case a.brotli != nil && acceptsEncoding(acceptEncoding, "br"):
+				content, encoding = a.brotli, "br"
+			case a.gzip != nil && acceptsEncoding(acceptEncoding, "gzip"):
+				content, encoding = a.gzip, "gzip"
+			}
+		}
+	}
+
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+		// Each encoding is a different representation and needs its own validator
+		h.Set("ETag", strings.TrimSuffix(a.etag, `"`)+"-"+encoding+`"`)
+	} else {
+		h.Set("ETag", a.etag)
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+// etagFor returns a strong ETag derived from the content
+func etagFor(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows encoding
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		params = strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return params != "q=0" && params != "q=0.0" && params != "q=0.00" && params != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package frontend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		want     bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip, deflate", "br", false},
+		{"br;q=0, gzip", "br", false},
+		{"br; q=0.5", "br", true},
+		{"", "gzip", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.header, tt.encoding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestAssetServe(t *testing.T) {
+	js := []byte(strings.Repeat("console.log('whatomate');\n", 100))
+	assets, err := loadAssets(fstest.MapFS{
+		"assets/index-abc123.js":    {Data: js},
+		"assets/index-abc123.js.gz": {Data: []byte("precompressed")},
+		"notification.mp3":          {Data: []byte("0123456789")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := assets["assets/index-abc123.js.gz"]; ok {
+		t.Fatal("pre-compressed variant should not be served as its own asset")
+	}
+
+	t.Run("hashed asset is immutable and compressed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/assets/index-abc123.js", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		assets["assets/index-abc123.js"].serve(rec, req, "assets/index-abc123.js")
+
+		if got := rec.Header().Get("Cache-Control"); got != cacheImmutable {
+			t.Errorf("Cache-Control = %q", got)
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("Content-Encoding = %q", got)
+		}
+		if got := rec.Body.String(); got != "precompressed" {
+			t.Errorf("body = %q, want shipped gzip variant", got)
+		}
+	})
+
+	t.Run("matching etag returns not modified", func(t *testing.T) {
+		a := assets["assets/index-abc123.js"]
+		req := httptest.NewRequest(http.MethodGet, "/assets/index-abc123.js", nil)
+		req.Header.Set("If-None-Match", a.etag)
+		rec := httptest.NewRecorder()
+		a.serve(rec, req, "assets/index-abc123.js")
+
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("status = %d, want 304", rec.Code)
+		}
+	})
+
+	t.Run("range request on media", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/notification.mp3", nil)
+		req.Header.Set("Range", "bytes=2-5")
+		rec := httptest.NewRecorder()
+		assets["notification.mp3"].serve(rec, req, "notification.mp3")
+
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("status = %d, want 206", rec.Code)
+		}
+		if got := rec.Body.String(); got != "2345" {
+			t.Errorf("body = %q", got)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != cacheRevalidate {
+			t.Errorf("Cache-Control = %q", got)
+		}
+	})
+}
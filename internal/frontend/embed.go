@@ -1,11 +1,13 @@
 package frontend
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/base64"
 	"fmt"
 	"io/fs"
 	"net/http"
-	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/valyala/fasthttp"
@@ -29,6 +31,9 @@ var mimeTypes = map[string]string{
 	".woff2": "font/woff2",
 	".ttf":   "font/ttf",
 	".eot":   "application/vnd.ms-fontobject",
+	".mp3":   "audio/mpeg",
+	".webp":  "image/webp",
+	".map":   "application/json",
 }
 
 //go:embed all:dist
@@ -37,6 +42,9 @@ var distFS embed.FS
 // cachedIndexHTML stores the modified index.html with injected base path
 var cachedIndexHTML []byte
 
+// inlineScriptPattern matches inline scripts (those without attributes such as src)
+var inlineScriptPattern = regexp.MustCompile(`(?s)<script>(.*?)</script>`)
+
 // Handler returns a fasthttp handler that serves the embedded frontend files
 // basePath should be empty string for root deployment or "/subpath" for subdirectory
 // If frontend is not embedded, returns a handler that shows a helpful message
@@ -51,88 +59,87 @@ func Handler(basePath string) fasthttp.RequestHandler {
 	}
 
 	// Read and modify index.html to inject base path
-	indexContent, err := fs.ReadFile(distSubFS, "index.html")
+	cachedIndexHTML, err = buildIndexHTML(distSubFS, basePath)
 	if err != nil {
 		return notEmbeddedHandler("Frontend not embedded: index.html not found. Run 'make build-prod' to embed frontend.")
 	}
 
-	// Inject base tag right after <head> so it's processed before any relative URLs
-	// Base tag ensures relative URLs (./assets/...) resolve from basePath, not current page path
-	baseHref := basePath + "/"
-	if basePath == "" {
-		baseHref = "/"
+	// Prepare every file once: compressed variants, ETags and cache headers
+	assets, err := loadAssets(distSubFS)
+	if err != nil {
+		return notEmbeddedHandler("Frontend not embedded: " + err.Error())
 	}
-	baseTag := fmt.Sprintf(`<head><base href="%s">`, baseHref)
-	modifiedHTML := strings.Replace(string(indexContent), "<head>", baseTag, 1)
+	index := newAsset("index.html", cachedIndexHTML, nil)
+	index.contentType = "text/html; charset=utf-8"
+	assets["index.html"] = index
 
-	// Inject base path script before </head>
-	basePathScript := fmt.Sprintf(`<script>window.__BASE_PATH__ = "%s";</script></head>`, basePath)
-	cachedIndexHTML = []byte(strings.Replace(modifiedHTML, "</head>", basePathScript, 1))
-
-	// Create file server
-	fileServer := http.FileServer(http.FS(distSubFS))
-
-	// Wrap with SPA fallback and proper MIME types
+	// Serve files with SPA fallback
 	spaHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
 		// Try to serve the file
 		if path != "/" && !strings.HasPrefix(path, "/api") {
-			// Check if file exists
 			filePath := strings.TrimPrefix(path, "/")
-			file, err := distSubFS.Open(filePath)
-			if err == nil {
-				defer func() { _ = file.Close() }()
-
-				// Get file info for size
-				stat, err := file.Stat()
-				if err != nil {
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-					return
-				}
-
-				// Skip directories
-				if stat.IsDir() {
-					fileServer.ServeHTTP(w, r)
-					return
-				}
-
-				// Set correct Content-Type based on file extension
-				ext := strings.ToLower(filepath.Ext(filePath))
-				if mimeType, ok := mimeTypes[ext]; ok {
-					w.Header().Set("Content-Type", mimeType)
-				} else {
-					w.Header().Set("Content-Type", "application/octet-stream")
-				}
-
-				// Read and serve file content
-				content, err := fs.ReadFile(distSubFS, filePath)
-				if err != nil {
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-					return
-				}
-
-				w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
-				_, _ = w.Write(content)
+			if a, ok := assets[filePath]; ok {
+				a.serve(w, r, filePath)
 				return
 			}
 		}
 
 		// For root or non-existent files (SPA routes), serve modified index.html
 		if path == "/" || (!strings.HasPrefix(path, "/api") && !strings.Contains(path, ".")) {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			_, _ = w.Write(cachedIndexHTML)
+			index.serve(w, r, "index.html")
 			return
 		}
 
-		// Serve the actual file
-		fileServer.ServeHTTP(w, r)
+		http.NotFound(w, r)
 	})
 
 	// Convert to fasthttp handler
 	return fasthttpadaptor.NewFastHTTPHandler(spaHandler)
 }
 
+// buildIndexHTML returns index.html with the base tag and base path script injected
+func buildIndexHTML(distSubFS fs.FS, basePath string) ([]byte, error) {
+	indexContent, err := fs.ReadFile(distSubFS, "index.html")
+	if err != nil {
+		return nil, err
+	}
+
+	// Inject base tag right after <head> so it's processed before any relative URLs
+	// Base tag ensures relative URLs (./assets/...) resolve from basePath, not current page path
+	baseHref := basePath + "/"
+	if basePath == "" {
+		baseHref = "/"
+	}
+	baseTag := fmt.Sprintf(`<head><base href="%s">`, baseHref)
+	modifiedHTML := strings.Replace(string(indexContent), "<head>", baseTag, 1)
+
+	// Inject base path script before </head>
+	basePathScript := fmt.Sprintf(`<script>window.__BASE_PATH__ = "%s";</script></head>`, basePath)
+	return []byte(strings.Replace(modifiedHTML, "</head>", basePathScript, 1)), nil
+}
+
+// InlineScriptHashes returns CSP source expressions ('sha256-...') for the inline scripts
+// in the served index.html, so a Content-Security-Policy can allow them without 'unsafe-inline'
+func InlineScriptHashes(basePath string) []string {
+	distSubFS, err := fs.Sub(distFS, "dist")
+	if err != nil {
+		return nil
+	}
+	html, err := buildIndexHTML(distSubFS, strings.TrimSuffix(basePath, "/"))
+	if err != nil {
+		return nil
+	}
+
+	var hashes []string
+	for _, match := range inlineScriptPattern.FindAllSubmatch(html, -1) {
+		sum := sha256.Sum256(match[1])
+		hashes = append(hashes, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	}
+	return hashes
+}
+
 // IsEmbedded returns true if the frontend dist folder is embedded
 func IsEmbedded() bool {
 	entries, err := distFS.ReadDir("dist")
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/chatnotify"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// accountMetric is a counter kept per WhatsApp account and hour
+type accountMetric string
+
+const (
+	accountMetricProcessed     accountMetric = "processed"      // Incoming messages and status updates handled
+	accountMetricFailed        accountMetric = "failed"         // Events that couldn't be fully processed
+	accountMetricChatbotErrors accountMetric = "chatbot_errors" // Chatbot replies, AI calls and flow API calls that failed
+)
+
+const (
+	// accountHealthPrefix prefixes the hourly counters of an account
+	accountHealthPrefix = "account:health:"
+
+	// accountHealthRetention is how long hourly counters are kept
+	accountHealthRetention = 8 * 24 * time.Hour
+
+	// maxAccountHealthHours bounds the window of the health endpoint
+	maxAccountHealthHours = 7 * 24
+)
+
+// AccountHealthStats holds an account's counters over a period
+type AccountHealthStats struct {
+	EventsProcessed int64   `json:"events_processed"`
+	EventsFailed    int64   `json:"events_failed"`
+	ChatbotErrors   int64   `json:"chatbot_errors"`
+	FailureRate     float64 `json:"failure_rate"` // Percent of processed events that failed or hit a chatbot error
+}
+
+// AccountHealthHour is one hour of an account's counters
+type AccountHealthHour struct {
+	Hour time.Time `json:"hour"`
+	AccountHealthStats
+}
+
+// AccountHealthResponse is an account's webhook processing health
+type AccountHealthResponse struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Hours     int       `json:"hours"`
+	// Status is "healthy", "unhealthy" when the current hour is over the alert
+	// threshold, or "unknown" when metrics aren't available
+	Status                 string              `json:"status"`
+	HealthAlertFailureRate int                 `json:"health_alert_failure_rate"`
+	HealthAlertMinEvents   int                 `json:"health_alert_min_events"`
+	Total                  AccountHealthStats  `json:"total"`
+	CurrentHour            AccountHealthStats  `json:"current_hour"`
+	Hourly                 []AccountHealthHour `json:"hourly"` // Oldest first
+}
+
+// GetAccountHealth returns the webhook processing metrics of an account. hours is the
+// window, 24 by default and at most a week.
+func (a *App) GetAccountHealth(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	hours, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("hours")))
+	if hours < 1 || hours > maxAccountHealthHours {
+		hours = 24
+	}
+
+	resp := AccountHealthResponse{
+		AccountID:              account.ID,
+		Hours:                  hours,
+		Status:                 "unknown",
+		HealthAlertFailureRate: account.HealthAlertFailureRate,
+		HealthAlertMinEvents:   account.HealthAlertMinEvents,
+		Hourly:                 []AccountHealthHour{},
+	}
+	if a.Redis == nil {
+		return r.SendEnvelope(resp)
+	}
+
+	hourly, err := a.getAccountHealthHours(r.RequestCtx, account.ID, hours, time.Now())
+	if err != nil {
+		a.Log.Error("Failed to read account health", "error", err, "account_id", account.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read account health", nil, "")
+	}
+	resp.Hourly = hourly
+	for _, h := range hourly {
+		resp.Total.EventsProcessed += h.EventsProcessed
+		resp.Total.EventsFailed += h.EventsFailed
+		resp.Total.ChatbotErrors += h.ChatbotErrors
+	}
+	resp.Total.FailureRate = failureRate(resp.Total)
+	resp.CurrentHour = hourly[len(hourly)-1].AccountHealthStats
+
+	resp.Status = "healthy"
+	if accountOverThreshold(account, resp.CurrentHour) {
+		resp.Status = "unhealthy"
+	}
+	return r.SendEnvelope(resp)
+}
+
+// getAccountHealthHours reads the hourly counters of the last hours, ending with the
+// hour that contains now
+func (a *App) getAccountHealthHours(ctx context.Context, accountID uuid.UUID, hours int, now time.Time) ([]AccountHealthHour, error) {
+	start := now.Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+
+	pipe := a.Redis.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, hours)
+	for i := 0; i < hours; i++ {
+		cmds[i] = pipe.HGetAll(ctx, accountHealthKey(accountID, start.Add(time.Duration(i)*time.Hour)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	result := make([]AccountHealthHour, hours)
+	for i, cmd := range cmds {
+		result[i] = AccountHealthHour{
+			Hour:               start.Add(time.Duration(i) * time.Hour).UTC(),
+			AccountHealthStats: parseAccountHealth(cmd.Val()),
+		}
+	}
+	return result, nil
+}
+
+// recordAccountMetric counts an event for the account in the current hour, and alerts
+// subscribed channels the first time a failure takes the hour over the threshold
+func (a *App) recordAccountMetric(account *models.WhatsAppAccount, metric accountMetric) {
+	// Simulated flows run with an unsaved account
+	if a.Redis == nil || account == nil || account.ID == uuid.Nil {
+		return
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	key := accountHealthKey(account.ID, now)
+	pipe := a.Redis.Pipeline()
+	pipe.HIncrBy(ctx, key, string(metric), 1)
+	pipe.Expire(ctx, key, accountHealthRetention)
+	counters := pipe.HGetAll(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		a.Log.Warn("Failed to record account metric", "error", err, "account_id", account.ID, "metric", metric)
+		return
+	}
+
+	if metric == accountMetricProcessed {
+		return
+	}
+	stats := parseAccountHealth(counters.Val())
+	if !accountOverThreshold(account, stats) {
+		return
+	}
+	if !a.notifyOnce(fmt.Sprintf("account_unhealthy:%s:%d", account.ID, now.Unix()/3600)) {
+		return
+	}
+	a.notifyAccountUnhealthy(account, stats)
+}
+
+// trackChatbotError counts a failed chatbot send for the account and returns err
+func (a *App) trackChatbotError(account *models.WhatsAppAccount, err error) error {
+	if err != nil {
+		a.recordAccountMetric(account, accountMetricChatbotErrors)
+	}
+	return err
+}
+
+// notifyAccountUnhealthy posts an account whose failure rate crossed its threshold
+func (a *App) notifyAccountUnhealthy(account *models.WhatsAppAccount, stats AccountHealthStats) {
+	acc := *account
+	a.NotifyChannels(acc.OrganizationID, models.NotificationEventAccountUnhealthy, func() (chatnotify.Message, bool) {
+		return chatnotify.Message{
+			Title: fmt.Sprintf("WhatsApp account %q is failing", acc.Name),
+			Text:  "Check the account's access token, webhook subscription and chatbot flows.",
+			Fields: []chatnotify.Field{
+				{Name: "Failure rate", Value: fmt.Sprintf("%.0f%%", stats.FailureRate)},
+				{Name: "Events this hour", Value: fmt.Sprint(stats.EventsProcessed)},
+				{Name: "Failed", Value: fmt.Sprint(stats.EventsFailed)},
+				{Name: "Chatbot errors", Value: fmt.Sprint(stats.ChatbotErrors)},
+			},
+			URL:      a.appLink("/settings/accounts"),
+			Severity: chatnotify.SeverityDanger,
+		}, true
+	})
+}
+
+// accountOverThreshold reports whether an hour's counters breach the account's alert threshold
+func accountOverThreshold(account *models.WhatsAppAccount, stats AccountHealthStats) bool {
+	if account.HealthAlertFailureRate <= 0 {
+		return false
+	}
+	if stats.EventsProcessed < int64(account.HealthAlertMinEvents) || stats.EventsProcessed == 0 {
+		return false
+	}
+	return stats.FailureRate >= float64(account.HealthAlertFailureRate)
+}
+
+func accountHealthKey(accountID uuid.UUID, t time.Time) string {
+	return fmt.Sprintf("%s%s:%d", accountHealthPrefix, accountID, t.Unix()/3600)
+}
+
+func parseAccountHealth(values map[string]string) AccountHealthStats {
+	var s AccountHealthStats
+	s.EventsProcessed, _ = strconv.ParseInt(values[string(accountMetricProcessed)], 10, 64)
+	s.EventsFailed, _ = strconv.ParseInt(values[string(accountMetricFailed)], 10, 64)
+	s.ChatbotErrors, _ = strconv.ParseInt(values[string(accountMetricChatbotErrors)], 10, 64)
+	s.FailureRate = failureRate(s)
+	return s
+}
+
+func failureRate(s AccountHealthStats) float64 {
+	if s.EventsProcessed == 0 {
+		return 0
+	}
+	rate := float64(s.EventsFailed+s.ChatbotErrors) * 100 / float64(s.EventsProcessed)
+	if rate > 100 {
+		rate = 100
+	}
+	return rate
+}
@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_AccountHealth(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Update("phone_id", "phone-"+uuid.NewString()[:8]).Error)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	update := func(body map[string]any) (int, handlers.AccountResponse) {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", account.ID.String())
+		require.NoError(t, app.UpdateAccount(req))
+		var result struct {
+			Data handlers.AccountResponse `json:"data"`
+		}
+		status := testutil.GetResponseStatusCode(req)
+		if status == fasthttp.StatusOK {
+			testutil.ParseJSONResponse(t, req, &result)
+		}
+		return status, result.Data
+	}
+
+	status, _ := update(map[string]any{"health_alert_failure_rate": 101})
+	assert.Equal(t, fasthttp.StatusBadRequest, status)
+	status, _ = update(map[string]any{"health_alert_min_events": 0})
+	assert.Equal(t, fasthttp.StatusBadRequest, status)
+
+	status, resp := update(map[string]any{"health_alert_failure_rate": 50, "health_alert_min_events": 2})
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, 50, resp.HealthAlertFailureRate)
+	assert.Equal(t, 2, resp.HealthAlertMinEvents)
+
+	getHealth := func() handlers.AccountHealthResponse {
+		req := testutil.NewGETRequest(t)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", account.ID.String())
+		testutil.SetQueryParam(req, "hours", "6")
+		require.NoError(t, app.GetAccountHealth(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var result struct {
+			Data handlers.AccountHealthResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &result)
+		return result.Data
+	}
+
+	if app.Redis == nil {
+		assert.Equal(t, "unknown", getHealth().Status)
+		return
+	}
+
+	health := getHealth()
+	assert.Equal(t, "healthy", health.Status)
+	assert.Len(t, health.Hourly, 6)
+
+	// Meta reporting failed deliveries counts against the account
+	for i := 0; i < 2; i++ {
+		body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+			"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+			"statuses":[{"id":%q,"status":"failed","timestamp":%q,"recipient_id":"15551234567"}]}}]}]}`,
+			account.PhoneID, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()))
+		req := testutil.NewJSONRequest(t, nil)
+		req.RequestCtx.Request.SetBody([]byte(body))
+		require.NoError(t, app.WebhookHandler(req))
+	}
+
+	require.Eventually(t, func() bool {
+		return getHealth().Total.EventsFailed == 2
+	}, 2*time.Second, 20*time.Millisecond)
+
+	health = getHealth()
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Equal(t, int64(2), health.CurrentHour.EventsProcessed)
+	assert.Equal(t, float64(100), health.CurrentHour.FailureRate)
+
+	// Turning alerts off leaves the counters but clears the status
+	status, resp = update(map[string]any{"health_alert_failure_rate": 0})
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, 0, resp.HealthAlertFailureRate)
+	assert.Equal(t, "healthy", getHealth().Status)
+
+	var stored models.WhatsAppAccount
+	require.NoError(t, app.DB.First(&stored, account.ID).Error)
+	assert.Equal(t, 0, stored.HealthAlertFailureRate)
+}
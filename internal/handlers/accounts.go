@@ -1,19 +1,32 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 )
 
+const (
+	// maxAccountMessagesPerSecond is the highest Cloud API throughput Meta grants a number
+	maxAccountMessagesPerSecond = 1000
+	messagesPerSecondError      = "messages_per_second must be between 0 and 1000"
+	healthAlertFailureRateError = "health_alert_failure_rate must be between 0 and 100"
+	healthAlertMinEventsError   = "health_alert_min_events must be at least 1"
+)
+
 // AccountRequest represents the request body for creating/updating an account
 type AccountRequest struct {
 	Name               string `json:"name" validate:"required"`
@@ -26,26 +39,54 @@ type AccountRequest struct {
 	IsDefaultIncoming  bool   `json:"is_default_incoming"`
 	IsDefaultOutgoing  bool   `json:"is_default_outgoing"`
 	AutoReadReceipt    bool   `json:"auto_read_receipt"`
+	MessagesPerSecond  int    `json:"messages_per_second"` // 0 uses the server's default send rate
+	// Health alert thresholds; omitted values keep the current (or default) setting
+	HealthAlertFailureRate *int `json:"health_alert_failure_rate"` // 0 turns alerts off
+	HealthAlertMinEvents   *int `json:"health_alert_min_events"`
+	// Minutes between scheduled template syncs; 0 turns them off, omitted keeps the
+	// current (or default) interval
+	TemplateSyncInterval *int `json:"template_sync_interval"`
+	// Agent signatures; omitted values keep the current setting
+	SenderName        *string                   `json:"sender_name"`
+	Signature         *string                   `json:"signature"` // Empty turns signatures off
+	SignaturePosition *models.SignaturePosition `json:"signature_position"`
 }
 
 // AccountResponse represents the response for an account (without sensitive data)
 type AccountResponse struct {
-	ID                 uuid.UUID `json:"id"`
-	Name               string    `json:"name"`
-	AppID              string    `json:"app_id"`
-	PhoneID            string    `json:"phone_id"`
-	BusinessID         string    `json:"business_id"`
-	WebhookVerifyToken string    `json:"webhook_verify_token"`
-	APIVersion         string    `json:"api_version"`
-	IsDefaultIncoming  bool      `json:"is_default_incoming"`
-	IsDefaultOutgoing  bool      `json:"is_default_outgoing"`
-	AutoReadReceipt    bool      `json:"auto_read_receipt"`
-	Status             string    `json:"status"`
-	HasAccessToken     bool      `json:"has_access_token"`
-	PhoneNumber        string    `json:"phone_number,omitempty"`
-	DisplayName        string    `json:"display_name,omitempty"`
-	CreatedAt          string    `json:"created_at"`
-	UpdatedAt          string    `json:"updated_at"`
+	ID                     uuid.UUID `json:"id"`
+	Name                   string    `json:"name"`
+	AppID                  string    `json:"app_id"`
+	PhoneID                string    `json:"phone_id"`
+	BusinessID             string    `json:"business_id"`
+	WebhookVerifyToken     string    `json:"webhook_verify_token"`
+	APIVersion             string    `json:"api_version"`
+	IsDefaultIncoming      bool      `json:"is_default_incoming"`
+	IsDefaultOutgoing      bool      `json:"is_default_outgoing"`
+	AutoReadReceipt        bool      `json:"auto_read_receipt"`
+	MessagesPerSecond      int       `json:"messages_per_second"`
+	HealthAlertFailureRate int       `json:"health_alert_failure_rate"`
+	HealthAlertMinEvents   int       `json:"health_alert_min_events"`
+	SenderName             string    `json:"sender_name"`
+	Signature              string    `json:"signature"`
+	SignaturePosition      string    `json:"signature_position"`
+	Status                 string    `json:"status"`
+	HasAccessToken         bool      `json:"has_access_token"`
+	PhoneNumber            string    `json:"phone_number,omitempty"`
+	DisplayName            string    `json:"display_name,omitempty"`
+	CreatedAt              string    `json:"created_at"`
+	UpdatedAt              string    `json:"updated_at"`
+
+	TemplateSync AccountTemplateSync `json:"template_sync"`
+}
+
+// AccountTemplateSync is the template sync status of an account
+type AccountTemplateSync struct {
+	Interval     int        `json:"interval"`                 // Minutes between scheduled syncs; 0 when they are off
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"` // Start of the last completed sync
+	InProgress   bool       `json:"in_progress"`              // A sync stopped early and continues at RetryAt
+	RetryAt      *time.Time `json:"retry_at,omitempty"`
+	Error        string     `json:"error,omitempty"` // Why the last sync failed
 }
 
 // ListAccounts returns all WhatsApp accounts for the organization
@@ -88,6 +129,15 @@ func (a *App) CreateAccount(r *fastglue.Request) error {
 	if req.Name == "" || req.PhoneID == "" || req.BusinessID == "" || req.AccessToken == "" {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name, phone_id, business_id, and access_token are required", nil, "")
 	}
+	if req.MessagesPerSecond < 0 || req.MessagesPerSecond > maxAccountMessagesPerSecond {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, messagesPerSecondError, nil, "")
+	}
+	if msg := validateHealthAlertSettings(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if msg := validateAccountSignature(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
 
 	// Generate webhook verify token if not provided
 	webhookVerifyToken := req.WebhookVerifyToken
@@ -101,20 +151,29 @@ func (a *App) CreateAccount(r *fastglue.Request) error {
 		apiVersion = "v21.0"
 	}
 
+	accessToken, err := a.encryptOrgSecret(orgID, req.AccessToken)
+	if err != nil {
+		a.Log.Error("Failed to encrypt access token", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization encryption key is unavailable", nil, "")
+	}
+
 	account := models.WhatsAppAccount{
 		OrganizationID:     orgID,
 		Name:               req.Name,
 		AppID:              req.AppID,
 		PhoneID:            req.PhoneID,
 		BusinessID:         req.BusinessID,
-		AccessToken:        req.AccessToken, // TODO: encrypt before storing
+		AccessToken:        accessToken,
 		WebhookVerifyToken: webhookVerifyToken,
 		APIVersion:         apiVersion,
 		IsDefaultIncoming:  req.IsDefaultIncoming,
 		IsDefaultOutgoing:  req.IsDefaultOutgoing,
 		AutoReadReceipt:    req.AutoReadReceipt,
+		MessagesPerSecond:  req.MessagesPerSecond,
 		Status:             "active",
 	}
+	applyHealthAlertSettings(&account, &req)
+	applyAccountSignature(&account, &req)
 
 	// If this is set as default, unset other defaults
 	if req.IsDefaultIncoming {
@@ -132,6 +191,25 @@ func (a *App) CreateAccount(r *fastglue.Request) error {
 		a.Log.Error("Failed to create account", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
 	}
+	// Create skips zero values in favour of the column default, so turning alerts off
+	// has to be written separately
+	if req.HealthAlertFailureRate != nil && *req.HealthAlertFailureRate == 0 {
+		a.DB.Model(&account).Update("health_alert_failure_rate", 0)
+		account.HealthAlertFailureRate = 0
+	}
+	if req.TemplateSyncInterval != nil && *req.TemplateSyncInterval == 0 {
+		a.DB.Model(&account).Update("template_sync_interval", 0)
+		account.TemplateSyncInterval = 0
+	}
+
+	// Subscribe the WABA to this app's webhooks so incoming messages start flowing
+	if account.BusinessID != "" && account.AccessToken != "" {
+		a.wg.Add(1)
+		go func(acc models.WhatsAppAccount) {
+			defer a.wg.Done()
+			a.autoSubscribeAccountWebhooks(acc)
+		}(account)
+	}
 
 	return r.SendEnvelope(accountToResponse(account))
 }
@@ -197,7 +275,12 @@ func (a *App) UpdateAccount(r *fastglue.Request) error {
 		account.BusinessID = req.BusinessID
 	}
 	if req.AccessToken != "" {
-		account.AccessToken = req.AccessToken // TODO: encrypt
+		accessToken, err := a.encryptOrgSecret(orgID, req.AccessToken)
+		if err != nil {
+			a.Log.Error("Failed to encrypt access token", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization encryption key is unavailable", nil, "")
+		}
+		account.AccessToken = accessToken
 	}
 	if req.WebhookVerifyToken != "" {
 		account.WebhookVerifyToken = req.WebhookVerifyToken
@@ -206,6 +289,18 @@ func (a *App) UpdateAccount(r *fastglue.Request) error {
 		account.APIVersion = req.APIVersion
 	}
 	account.AutoReadReceipt = req.AutoReadReceipt
+	if req.MessagesPerSecond < 0 || req.MessagesPerSecond > maxAccountMessagesPerSecond {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, messagesPerSecondError, nil, "")
+	}
+	account.MessagesPerSecond = req.MessagesPerSecond
+	if msg := validateHealthAlertSettings(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if msg := validateAccountSignature(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	applyHealthAlertSettings(&account, &req)
+	applyAccountSignature(&account, &req)
 
 	// Handle default flags
 	if req.IsDefaultIncoming && !account.IsDefaultIncoming {
@@ -284,8 +379,12 @@ func (a *App) TestAccountConnection(r *fastglue.Request) error {
 	url := fmt.Sprintf("%s/%s/%s?fields=display_phone_number,verified_name,quality_rating,messaging_limit_tier",
 		a.Config.WhatsApp.BaseURL, account.APIVersion, account.PhoneID)
 
+	accessToken, err := a.accountAccessToken(&account)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization encryption key is unavailable", nil, "")
+	}
 	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -312,12 +411,22 @@ func (a *App) TestAccountConnection(r *fastglue.Request) error {
 	var result map[string]interface{}
 	_ = json.Unmarshal(body, &result)
 
+	// Report whether webhooks are wired up; nil means the status couldn't be determined
+	var webhookSubscribed interface{}
+	waAccount := a.toWhatsAppAccount(&account)
+	if apps, err := a.WhatsApp.ListSubscribedApps(context.Background(), waAccount); err != nil {
+		a.Log.Warn("Failed to check webhook subscription", "error", err, "account", account.Name)
+	} else {
+		webhookSubscribed = whatsapp.IsAppSubscribed(waAccount, apps)
+	}
+
 	return r.SendEnvelope(map[string]interface{}{
 		"success":              true,
 		"display_phone_number": result["display_phone_number"],
 		"verified_name":        result["verified_name"],
 		"quality_rating":       result["quality_rating"],
 		"messaging_limit_tier": result["messaging_limit_tier"],
+		"webhook_subscribed":   webhookSubscribed,
 	})
 }
 
@@ -325,20 +434,90 @@ func (a *App) TestAccountConnection(r *fastglue.Request) error {
 
 func accountToResponse(acc models.WhatsAppAccount) AccountResponse {
 	return AccountResponse{
-		ID:                 acc.ID,
-		Name:               acc.Name,
-		AppID:              acc.AppID,
-		PhoneID:            acc.PhoneID,
-		BusinessID:         acc.BusinessID,
-		WebhookVerifyToken: acc.WebhookVerifyToken,
-		APIVersion:         acc.APIVersion,
-		IsDefaultIncoming:  acc.IsDefaultIncoming,
-		IsDefaultOutgoing:  acc.IsDefaultOutgoing,
-		AutoReadReceipt:    acc.AutoReadReceipt,
-		Status:             acc.Status,
-		HasAccessToken:     acc.AccessToken != "",
-		CreatedAt:          acc.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		UpdatedAt:          acc.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:                     acc.ID,
+		Name:                   acc.Name,
+		AppID:                  acc.AppID,
+		PhoneID:                acc.PhoneID,
+		BusinessID:             acc.BusinessID,
+		WebhookVerifyToken:     acc.WebhookVerifyToken,
+		APIVersion:             acc.APIVersion,
+		IsDefaultIncoming:      acc.IsDefaultIncoming,
+		IsDefaultOutgoing:      acc.IsDefaultOutgoing,
+		AutoReadReceipt:        acc.AutoReadReceipt,
+		MessagesPerSecond:      acc.MessagesPerSecond,
+		HealthAlertFailureRate: acc.HealthAlertFailureRate,
+		HealthAlertMinEvents:   acc.HealthAlertMinEvents,
+		SenderName:             acc.SenderName,
+		Signature:              acc.Signature,
+		SignaturePosition:      string(acc.SignaturePosition),
+		Status:                 acc.Status,
+		HasAccessToken:         acc.AccessToken != "",
+		CreatedAt:              acc.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:              acc.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		TemplateSync: AccountTemplateSync{
+			Interval:     acc.TemplateSyncInterval,
+			LastSyncedAt: acc.TemplatesSyncedAt,
+			InProgress:   acc.TemplateSyncCursor != "",
+			RetryAt:      acc.TemplateSyncRetryAt,
+			Error:        acc.TemplateSyncError,
+		},
+	}
+}
+
+// validateHealthAlertSettings checks the alert thresholds of an account request.
+// Returns an error message, or "" when valid.
+func validateHealthAlertSettings(req *AccountRequest) string {
+	if req.HealthAlertFailureRate != nil && (*req.HealthAlertFailureRate < 0 || *req.HealthAlertFailureRate > 100) {
+		return healthAlertFailureRateError
+	}
+	if req.HealthAlertMinEvents != nil && *req.HealthAlertMinEvents < 1 {
+		return healthAlertMinEventsError
+	}
+	if req.TemplateSyncInterval != nil && (*req.TemplateSyncInterval < 0 || *req.TemplateSyncInterval > maxTemplateSyncInterval) {
+		return templateSyncIntervalError
+	}
+	return ""
+}
+
+func applyHealthAlertSettings(account *models.WhatsAppAccount, req *AccountRequest) {
+	if req.HealthAlertFailureRate != nil {
+		account.HealthAlertFailureRate = *req.HealthAlertFailureRate
+	}
+	if req.HealthAlertMinEvents != nil {
+		account.HealthAlertMinEvents = *req.HealthAlertMinEvents
+	}
+	if req.TemplateSyncInterval != nil {
+		account.TemplateSyncInterval = *req.TemplateSyncInterval
+	}
+}
+
+// validateAccountSignature checks the signature settings of an account request.
+// Returns an error message, or "" when valid.
+func validateAccountSignature(req *AccountRequest) string {
+	if req.SenderName != nil && utf8.RuneCountInString(strings.TrimSpace(*req.SenderName)) > maxSenderNameLength {
+		return "sender_name must be 100 characters or fewer"
+	}
+	if req.Signature != nil {
+		if msg := validateSignature(strings.TrimSpace(*req.Signature)); msg != "" {
+			return msg
+		}
+	}
+	if req.SignaturePosition != nil && *req.SignaturePosition != models.SignaturePositionAppend &&
+		*req.SignaturePosition != models.SignaturePositionPrepend {
+		return "signature_position must be append or prepend"
+	}
+	return ""
+}
+
+func applyAccountSignature(account *models.WhatsAppAccount, req *AccountRequest) {
+	if req.SenderName != nil {
+		account.SenderName = strings.TrimSpace(*req.SenderName)
+	}
+	if req.Signature != nil {
+		account.Signature = strings.TrimSpace(*req.Signature)
+	}
+	if req.SignaturePosition != nil {
+		account.SignaturePosition = *req.SignaturePosition
 	}
 }
 
@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/database"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// orgUsageMessageWindow is how far back messages are counted in organization usage
+const orgUsageMessageWindow = 30 * 24 * time.Hour
+
+// AdminOrganizationResponse is an organization with its usage, as listed in the
+// instance console
+type AdminOrganizationResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	Name            string     `json:"name"`
+	Slug            string     `json:"slug"`
+	CreatedAt       time.Time  `json:"created_at"`
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason string     `json:"suspended_reason,omitempty"`
+	DataRegion      string     `json:"data_region"`
+	DataRegionLabel string     `json:"data_region_label"`
+	Usage           OrgUsage   `json:"usage"`
+}
+
+// OrgUsage counts what an organization has set up and how much it messages
+type OrgUsage struct {
+	Users            int64 `json:"users"`
+	ActiveUsers      int64 `json:"active_users"`
+	WhatsAppAccounts int64 `json:"whatsapp_accounts"`
+	Contacts         int64 `json:"contacts"`
+	Campaigns        int64 `json:"campaigns"`
+	Messages30d      int64 `json:"messages_30d"`
+}
+
+// SuspendOrganizationRequest is the body of a suspension
+type SuspendOrganizationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// MaintenanceNoticeRequest is the body of a maintenance notice
+type MaintenanceNoticeRequest struct {
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// requireSuperAdmin sends a 403 and returns false unless the caller is a super admin
+func (a *App) requireSuperAdmin(r *fastglue.Request) bool {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+		return false
+	}
+	if !a.IsSuperAdmin(userID) {
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only super admins can access this", nil, "")
+		return false
+	}
+	return true
+}
+
+// ListAdminOrganizations lists every organization with its usage (super admin only).
+// search matches the name or slug; status is active or suspended.
+func (a *App) ListAdminOrganizations(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+
+	page, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	query := a.DB.Model(&models.Organization{})
+	if search := strings.TrimSpace(string(r.RequestCtx.QueryArgs().Peek("search"))); search != "" {
+		pattern := "%" + search + "%"
+		query = query.Where("name ILIKE ? OR slug ILIKE ?", pattern, pattern)
+	}
+	switch string(r.RequestCtx.QueryArgs().Peek("status")) {
+	case "active":
+		query = query.Where("suspended_at IS NULL")
+	case "suspended":
+		query = query.Where("suspended_at IS NOT NULL")
+	case "":
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "status must be active or suspended", nil, "")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var orgs []models.Organization
+	if err := query.Order("name ASC").Offset((page - 1) * limit).Limit(limit).Find(&orgs).Error; err != nil {
+		a.Log.Error("Failed to list organizations", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list organizations", nil, "")
+	}
+
+	ids := make([]uuid.UUID, len(orgs))
+	for i, org := range orgs {
+		ids[i] = org.ID
+	}
+	usage, err := a.getOrgUsage(ids)
+	if err != nil {
+		a.Log.Error("Failed to load organization usage", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list organizations", nil, "")
+	}
+
+	response := make([]AdminOrganizationResponse, len(orgs))
+	for i, org := range orgs {
+		response[i] = AdminOrganizationResponse{
+			ID:              org.ID,
+			Name:            org.Name,
+			Slug:            org.Slug,
+			CreatedAt:       org.CreatedAt,
+			SuspendedAt:     org.SuspendedAt,
+			SuspendedReason: org.SuspendedReason,
+			DataRegion:      org.DataRegion,
+			DataRegionLabel: a.dataRegionLabel(org.DataRegion),
+			Usage:           usage[org.ID],
+		}
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"organizations": response,
+		"total":         total,
+		"page":          page,
+		"limit":         limit,
+	})
+}
+
+// getOrgUsage counts the usage of each organization in orgIDs
+func (a *App) getOrgUsage(orgIDs []uuid.UUID) (map[uuid.UUID]OrgUsage, error) {
+	usage := make(map[uuid.UUID]OrgUsage, len(orgIDs))
+	if len(orgIDs) == 0 {
+		return usage, nil
+	}
+
+	type orgCount struct {
+		OrganizationID uuid.UUID
+		Count          int64
+	}
+	since := time.Now().Add(-orgUsageMessageWindow)
+	counts := []struct {
+		model any
+		where string
+		args  []any
+		set   func(*OrgUsage, int64)
+	}{
+		{&models.User{}, "", nil, func(u *OrgUsage, n int64) { u.Users = n }},
+		{&models.User{}, "is_active = ?", []any{true}, func(u *OrgUsage, n int64) { u.ActiveUsers = n }},
+		{&models.WhatsAppAccount{}, "", nil, func(u *OrgUsage, n int64) { u.WhatsAppAccounts = n }},
+		{&models.Contact{}, "", nil, func(u *OrgUsage, n int64) { u.Contacts = n }},
+		{&models.BulkMessageCampaign{}, "", nil, func(u *OrgUsage, n int64) { u.Campaigns = n }},
+		{&models.Message{}, "created_at >= ?", []any{since}, func(u *OrgUsage, n int64) { u.Messages30d = n }},
+	}
+	for _, c := range counts {
+		query := a.DB.Model(c.model).
+			Select("organization_id, COUNT(*) AS count").
+			Where("organization_id IN ?", orgIDs)
+		if c.where != "" {
+			query = query.Where(c.where, c.args...)
+		}
+		var rows []orgCount
+		if err := query.Group("organization_id").Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			u := usage[row.OrganizationID]
+			c.set(&u, row.Count)
+			usage[row.OrganizationID] = u
+		}
+	}
+	return usage, nil
+}
+
+// SuspendOrganization suspends an organization (super admin only). Its users are signed
+// out of the API, and its running campaigns are paused.
+func (a *App) SuspendOrganization(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+	adminID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	org, err := a.loadAdminOrganization(r)
+	if err != nil || org == nil {
+		return err
+	}
+
+	var req SuspendOrganizationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "reason is required", nil, "")
+	}
+	if len(req.Reason) > 500 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "reason must be at most 500 characters", nil, "")
+	}
+	if org.SuspendedAt != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization is already suspended", nil, "")
+	}
+
+	now := time.Now()
+	var paused int64
+	if err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(org).Updates(map[string]any{
+			"suspended_at":     now,
+			"suspended_reason": req.Reason,
+		}).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.BulkMessageCampaign{}).
+			Where("organization_id = ? AND status IN ?", org.ID,
+				[]models.CampaignStatus{models.CampaignStatusQueued, models.CampaignStatusProcessing}).
+			Update("status", models.CampaignStatusPaused)
+		paused = result.RowsAffected
+		return result.Error
+	}); err != nil {
+		a.Log.Error("Failed to suspend organization", "error", err, "organization_id", org.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to suspend organization", nil, "")
+	}
+	a.InvalidateOrgSuspensionCache(org.ID)
+
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: org.ID,
+		UserID:         &adminID,
+		Action:         models.AuditActionOrganizationSuspended,
+		IPAddress:      middleware.ClientIP(r),
+		Path:           string(r.RequestCtx.Path()),
+		Details:        fmt.Sprintf("Suspended: %s (%d campaigns paused)", req.Reason, paused),
+	})
+	a.Log.Info("Organization suspended", "organization_id", org.ID, "by", adminID, "campaigns_paused", paused)
+
+	return r.SendEnvelope(map[string]any{
+		"message":          "Organization suspended",
+		"suspended_at":     now,
+		"campaigns_paused": paused,
+	})
+}
+
+// ReactivateOrganization lifts an organization's suspension (super admin only). Paused
+// campaigns stay paused until the organization resumes them.
+func (a *App) ReactivateOrganization(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+	adminID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	org, err := a.loadAdminOrganization(r)
+	if err != nil || org == nil {
+		return err
+	}
+	if org.SuspendedAt == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization is not suspended", nil, "")
+	}
+
+	if err := a.DB.Model(org).Updates(map[string]any{
+		"suspended_at":     nil,
+		"suspended_reason": "",
+	}).Error; err != nil {
+		a.Log.Error("Failed to reactivate organization", "error", err, "organization_id", org.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reactivate organization", nil, "")
+	}
+	a.InvalidateOrgSuspensionCache(org.ID)
+
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: org.ID,
+		UserID:         &adminID,
+		Action:         models.AuditActionOrganizationReactivated,
+		IPAddress:      middleware.ClientIP(r),
+		Path:           string(r.RequestCtx.Path()),
+		Details:        "Suspension lifted",
+	})
+	a.Log.Info("Organization reactivated", "organization_id", org.ID, "by", adminID)
+
+	return r.SendEnvelope(map[string]string{"message": "Organization reactivated"})
+}
+
+// loadAdminOrganization loads the organization from the path. On failure it sends the
+// error response and returns a nil organization.
+func (a *App) loadAdminOrganization(r *fastglue.Request) (*models.Organization, error) {
+	orgID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid organization ID", nil, "")
+	}
+	var org models.Organization
+	if err := a.DB.Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+	return &org, nil
+}
+
+// GetInstanceHealth reports the health of the database, Redis, the job queue and its
+// workers (super admin only)
+func (a *App) GetInstanceHealth(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.RequestCtx, 5*time.Second)
+	defer cancel()
+
+	healthy := true
+	check := func(err error) map[string]any {
+		if err != nil {
+			healthy = false
+			return map[string]any{"status": "error", "error": err.Error()}
+		}
+		return map[string]any{"status": "ok"}
+	}
+
+	dbHealth := check(a.pingDatabase(ctx))
+	if stats, err := database.GetPoolStats(a.DB); err == nil {
+		dbHealth["pool"] = stats
+	}
+
+	if a.Redis == nil {
+		return r.SendEnvelope(map[string]any{
+			"status":   "degraded",
+			"database": dbHealth,
+			"redis":    map[string]any{"status": "error", "error": "redis is not configured"},
+		})
+	}
+	redisHealth := check(a.Redis.Ping(ctx).Err())
+
+	queueHealth := map[string]any{"status": "ok"}
+	lanes, err := queue.GetLaneStats(ctx, a.Redis, 5*time.Minute)
+	if err == nil {
+		var workers []queue.WorkerStats
+		workers, err = queue.GetWorkerStats(ctx, a.Redis)
+		alive := 0
+		for _, w := range workers {
+			if w.Alive {
+				alive++
+			}
+		}
+		queueHealth["lanes"] = lanes
+		queueHealth["workers"] = workers
+		queueHealth["alive_workers"] = alive
+		// Jobs waiting with nobody to take them
+		if err == nil && alive == 0 {
+			for _, lane := range lanes {
+				if lane.Waiting > 0 {
+					healthy = false
+					queueHealth["status"] = "degraded"
+					queueHealth["error"] = "jobs are waiting but no worker is consuming the queue"
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		healthy = false
+		queueHealth = map[string]any{"status": "error", "error": err.Error()}
+	}
+
+	status := "ok"
+	if !healthy {
+		status = "degraded"
+	}
+	websocketClients := 0
+	if a.WSHub != nil {
+		websocketClients = a.WSHub.GetClientCount()
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"status":            status,
+		"database":          dbHealth,
+		"redis":             redisHealth,
+		"queue":             queueHealth,
+		"websocket_clients": websocketClients,
+		"checked_at":        time.Now().UTC(),
+	})
+}
+
+// pingDatabase checks that the database answers
+func (a *App) pingDatabase(ctx context.Context) error {
+	sqlDB, err := a.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CreateMaintenanceNotice broadcasts a maintenance banner to every organization (super
+// admin only). It is a global announcement and is managed like one afterwards.
+func (a *App) CreateMaintenanceNotice(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req MaintenanceNoticeRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	announcement := models.Announcement{CreatedByID: &userID}
+	if msg := applyAnnouncementRequest(&announcement, AnnouncementRequest{
+		Title:    req.Title,
+		Body:     req.Body,
+		Type:     string(models.AnnouncementTypeBanner),
+		Category: string(models.AnnouncementCategoryMaintenance),
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+		Global:   true,
+	}); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Create(&announcement).Error; err != nil {
+		a.Log.Error("Failed to create maintenance notice", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create maintenance notice", nil, "")
+	}
+
+	a.publishAnnouncement(&announcement)
+
+	return r.SendEnvelope(announcementToResponse(announcement, false))
+}
+
+// IsOrganizationSuspended reports whether an organization is suspended. It is cached,
+// as every authenticated request checks it.
+func (a *App) IsOrganizationSuspended(orgID uuid.UUID) bool {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgSuspendedCachePrefix, orgID.String())
+
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			return cached == "1"
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id, suspended_at").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return false
+	}
+	suspended := org.SuspendedAt != nil
+
+	if a.Redis != nil {
+		value := "0"
+		if suspended {
+			value = "1"
+		}
+		a.Redis.Set(ctx, cacheKey, value, orgSuspendedCacheTTL)
+	}
+	return suspended
+}
+
+// InvalidateOrgSuspensionCache invalidates the cached suspension state of an organization
+func (a *App) InvalidateOrgSuspensionCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgSuspendedCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
+
+// isSuspendedUser reports whether user belongs to a suspended organization and isn't a
+// super admin, so may not sign in
+func (a *App) isSuspendedUser(user *models.User) bool {
+	return !user.IsSuperAdmin && a.IsOrganizationSuspended(user.OrganizationID)
+}
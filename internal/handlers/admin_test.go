@@ -0,0 +1,198 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestApp_ListAdminOrganizations(t *testing.T) {
+	app := testApp(t)
+	home := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, home.ID)
+
+	org := createTestOrganization(t, app)
+	createTestUser(t, app, org.ID, uniqueEmail("usage-active"), "password", nil, true)
+	createTestUser(t, app, org.ID, uniqueEmail("usage-inactive"), "password", nil, false)
+	createTestContact(t, app, org.ID)
+
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	req.RequestCtx.QueryArgs().Set("search", org.Slug)
+	require.NoError(t, app.ListAdminOrganizations(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(testutil.GetResponseBody(req)))
+
+	var resp struct {
+		Data struct {
+			Organizations []handlers.AdminOrganizationResponse `json:"organizations"`
+			Total         int64                                `json:"total"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	require.Len(t, resp.Data.Organizations, 1)
+	assert.Equal(t, int64(1), resp.Data.Total)
+	usage := resp.Data.Organizations[0].Usage
+	assert.Equal(t, int64(2), usage.Users)
+	assert.Equal(t, int64(1), usage.ActiveUsers)
+	assert.Equal(t, int64(1), usage.Contacts)
+	assert.Zero(t, usage.Messages30d)
+
+	// Organization admins can't see other organizations
+	user := createTestUser(t, app, org.ID, uniqueEmail("usage-denied"), "password", nil, true)
+	req = testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	require.NoError(t, app.ListAdminOrganizations(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
+
+func TestApp_SuspendOrganization(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	home := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, home.ID)
+
+	org := createTestOrganization(t, app)
+	email := uniqueEmail("suspended-user")
+	user := createTestUser(t, app, org.ID, email, "password123", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "suspend-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	running := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusProcessing)
+
+	suspend := func(body map[string]any) *fasthttp.RequestCtx {
+		req := testutil.NewJSONRequest(t, body)
+		req.RequestCtx.SetUserValue("user_id", admin.ID)
+		testutil.SetPathParam(req, "id", org.ID.String())
+		require.NoError(t, app.SuspendOrganization(req))
+		return req.RequestCtx
+	}
+	login := func() int {
+		req := testutil.NewJSONRequest(t, map[string]string{"email": email, "password": "password123"})
+		require.NoError(t, app.Login(req))
+		return testutil.GetResponseStatusCode(req)
+	}
+
+	assert.Equal(t, fasthttp.StatusBadRequest, suspend(map[string]any{}).Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusOK, login())
+
+	ctx := suspend(map[string]any{"reason": "Unpaid invoices"})
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode(), string(ctx.Response.Body()))
+	assert.Equal(t, fasthttp.StatusConflict, suspend(map[string]any{"reason": "Again"}).Response.StatusCode())
+
+	assert.True(t, app.IsOrganizationSuspended(org.ID))
+	assert.False(t, app.IsOrganizationSuspended(home.ID))
+	assert.Equal(t, fasthttp.StatusForbidden, login())
+	assertCampaignStatus(t, app, running.ID.String(), models.CampaignStatusPaused)
+
+	var audit models.AuditLog
+	require.NoError(t, app.DB.Where("organization_id = ? AND action = ?", org.ID, models.AuditActionOrganizationSuspended).First(&audit).Error)
+	assert.Equal(t, admin.ID, *audit.UserID)
+
+	req := testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	testutil.SetPathParam(req, "id", org.ID.String())
+	require.NoError(t, app.ReactivateOrganization(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.False(t, app.IsOrganizationSuspended(org.ID))
+	assert.Equal(t, fasthttp.StatusOK, login())
+	// Paused campaigns are left for the organization to resume
+	assertCampaignStatus(t, app, running.ID.String(), models.CampaignStatusPaused)
+}
+
+func TestApp_SetOrganizationDataRegion(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	app.Config.Storage.RegionLabel = "US"
+	app.Config.Storage.Regions = map[string]config.StorageRegion{
+		"eu": {Label: "EU (Frankfurt)", LocalPath: t.TempDir()},
+	}
+	home := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, home.ID)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("region-user"), "password123", nil, true)
+
+	setRegion := func(userID any, region string) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, map[string]string{"region": region})
+		req.RequestCtx.SetUserValue("user_id", userID)
+		testutil.SetPathParam(req, "id", org.ID.String())
+		require.NoError(t, app.SetOrganizationDataRegion(req))
+		return req
+	}
+
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(setRegion(user.ID, "eu")))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(setRegion(admin.ID, "apac")))
+
+	req := setRegion(admin.ID, "eu")
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		DataRegion      string `json:"data_region"`
+		DataRegionLabel string `json:"data_region_label"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	assert.Equal(t, "eu", resp.DataRegion)
+	assert.Equal(t, "EU (Frankfurt)", resp.DataRegionLabel)
+
+	var audit models.AuditLog
+	require.NoError(t, app.DB.Where("organization_id = ? AND action = ?", org.ID, models.AuditActionDataRegionChanged).First(&audit).Error)
+	assert.Equal(t, "Data region changed from US to EU (Frankfurt)", audit.Details)
+
+	// The organization's users see their region
+	req = testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.GetCurrentOrganization(req))
+	var current handlers.OrganizationResponse
+	testutil.ParseEnvelopeResponse(t, req, &current)
+	assert.Equal(t, "eu", current.DataRegion)
+	assert.Equal(t, "EU (Frankfurt)", current.DataRegionLabel)
+
+	req = testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	require.NoError(t, app.ListDataRegions(req))
+	var regions struct {
+		Regions []handlers.DataRegionResponse `json:"regions"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &regions)
+	require.Len(t, regions.Regions, 2)
+	assert.True(t, regions.Regions[0].Default)
+	assert.Equal(t, "US", regions.Regions[0].Label)
+	assert.Equal(t, "eu", regions.Regions[1].Name)
+	assert.GreaterOrEqual(t, regions.Regions[1].Organizations, int64(1))
+
+	// An empty region moves the organization back to the default storage
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(setRegion(admin.ID, "")))
+	var stored models.Organization
+	require.NoError(t, app.DB.Where("id = ?", org.ID).First(&stored).Error)
+	assert.Empty(t, stored.DataRegion)
+}
+
+func TestApp_CreateMaintenanceNotice(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, org.ID)
+
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"title": "Scheduled maintenance",
+		"body":  "The service will be unavailable for 10 minutes.",
+	})
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	require.NoError(t, app.CreateMaintenanceNotice(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(testutil.GetResponseBody(req)))
+
+	var resp struct {
+		Data handlers.AnnouncementResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.True(t, resp.Data.Global)
+	assert.Equal(t, string(models.AnnouncementTypeBanner), resp.Data.Type)
+	assert.Equal(t, string(models.AnnouncementCategoryMaintenance), resp.Data.Category)
+
+	user := createTestUser(t, app, org.ID, uniqueEmail("notice-denied"), "password", nil, true)
+	req = testutil.NewJSONRequest(t, map[string]any{"title": "Not allowed"})
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	require.NoError(t, app.CreateMaintenanceNotice(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
@@ -19,6 +19,12 @@ type AgentAnalyticsSummary struct {
 	TransfersBySource     map[string]int64 `json:"transfers_by_source"`
 	TotalBreakTimeMins    float64          `json:"total_break_time_mins"`
 	BreakCount            int64            `json:"break_count"`
+	AvgCSAT               float64          `json:"avg_csat"`
+	CSATResponses         int64            `json:"csat_responses"`
+	AssignmentOffers      int64            `json:"assignment_offers"`
+	AssignmentsDeclined   int64            `json:"assignments_declined"`
+	AssignmentsExpired    int64            `json:"assignments_expired"`
+	AssignmentAcceptRate  float64          `json:"assignment_accept_rate"`
 }
 
 // AgentPerformanceStats represents performance metrics for an agent
@@ -34,6 +40,10 @@ type AgentPerformanceStats struct {
 	BreakCount           int64    `json:"break_count"`
 	IsAvailable          bool     `json:"is_available"`
 	CurrentBreakStart    *string  `json:"current_break_start,omitempty"`
+	AvgCSAT              float64  `json:"avg_csat"`
+	CSATResponses        int64    `json:"csat_responses"`
+	AssignmentOffers     int64    `json:"assignment_offers"`
+	AssignmentAcceptRate float64  `json:"assignment_accept_rate"`
 }
 
 // TrendPoint represents a data point for time-series charts
@@ -70,22 +80,23 @@ func (a *App) GetAgentAnalytics(r *fastglue.Request) error {
 		groupBy = "day"
 	}
 
-	now := time.Now()
+	loc := a.getOrgLocation(orgID)
+	now := time.Now().In(loc)
 	var periodStart, periodEnd time.Time
 
 	if fromStr != "" && toStr != "" {
-		periodStart, err = time.Parse("2006-01-02", fromStr)
+		periodStart, err = time.ParseInLocation("2006-01-02", fromStr, loc)
 		if err != nil {
 			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
 		}
-		periodEnd, err = time.Parse("2006-01-02", toStr)
+		periodEnd, err = time.ParseInLocation("2006-01-02", toStr, loc)
 		if err != nil {
 			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
 		}
 		periodEnd = periodEnd.Add(24*time.Hour - time.Nanosecond)
 	} else {
 		// Default to current month
-		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 		periodEnd = now
 	}
 
@@ -98,7 +109,7 @@ func (a *App) GetAgentAnalytics(r *fastglue.Request) error {
 
 	// Check if filtering by specific agent (requires analytics permission)
 	var filterAgentID *uuid.UUID
-	if a.HasPermission(userID, models.ResourceAnalytics, models.ActionRead) && agentIDStr != "" {
+	if a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) && agentIDStr != "" {
 		parsedID, err := uuid.Parse(agentIDStr)
 		if err == nil {
 			filterAgentID = &parsedID
@@ -112,7 +123,7 @@ func (a *App) GetAgentAnalytics(r *fastglue.Request) error {
 		response.TrendData = a.calculateTrendData(orgID, periodStart, periodEnd, groupBy, filterAgentID)
 		// Calculate summary for this specific agent
 		a.calculateAgentSummaryStats(orgID, *filterAgentID, periodStart, periodEnd, &response.Summary)
-	} else if !a.HasPermission(userID, models.ResourceAnalytics, models.ActionRead) {
+	} else if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
 		// Users without analytics permission only see their own stats
 		myStats := a.calculateAgentStats(orgID, userID, periodStart, periodEnd)
 		response.MyStats = &myStats
@@ -139,7 +150,7 @@ func (a *App) GetAgentDetails(r *fastglue.Request) error {
 	}
 
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceAnalytics, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access denied", nil, "")
 	}
 
@@ -157,15 +168,16 @@ func (a *App) GetAgentDetails(r *fastglue.Request) error {
 		groupBy = "day"
 	}
 
-	now := time.Now()
+	loc := a.getOrgLocation(orgID)
+	now := time.Now().In(loc)
 	var periodStart, periodEnd time.Time
 
 	if fromStr != "" && toStr != "" {
-		periodStart, _ = time.Parse("2006-01-02", fromStr)
-		periodEnd, _ = time.Parse("2006-01-02", toStr)
+		periodStart, _ = time.ParseInLocation("2006-01-02", fromStr, loc)
+		periodEnd, _ = time.ParseInLocation("2006-01-02", toStr, loc)
 		periodEnd = periodEnd.Add(24*time.Hour - time.Nanosecond)
 	} else {
-		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 		periodEnd = now
 	}
 
@@ -192,7 +204,7 @@ func (a *App) GetAgentComparison(r *fastglue.Request) error {
 	}
 
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceAnalytics, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access denied", nil, "")
 	}
 
@@ -200,15 +212,16 @@ func (a *App) GetAgentComparison(r *fastglue.Request) error {
 	fromStr := string(r.RequestCtx.QueryArgs().Peek("from"))
 	toStr := string(r.RequestCtx.QueryArgs().Peek("to"))
 
-	now := time.Now()
+	loc := a.getOrgLocation(orgID)
+	now := time.Now().In(loc)
 	var periodStart, periodEnd time.Time
 
 	if fromStr != "" && toStr != "" {
-		periodStart, _ = time.Parse("2006-01-02", fromStr)
-		periodEnd, _ = time.Parse("2006-01-02", toStr)
+		periodStart, _ = time.ParseInLocation("2006-01-02", fromStr, loc)
+		periodEnd, _ = time.ParseInLocation("2006-01-02", toStr, loc)
 		periodEnd = periodEnd.Add(24*time.Hour - time.Nanosecond)
 	} else {
-		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 		periodEnd = now
 	}
 
@@ -269,6 +282,13 @@ func (a *App) calculateSummaryStats(orgID uuid.UUID, start, end time.Time, summa
 	for _, sc := range sourceCounts {
 		summary.TransfersBySource[sc.Source] = sc.Count
 	}
+
+	// Customer satisfaction
+	summary.AvgCSAT, summary.CSATResponses = a.calculateCSAT(orgID, nil, start, end)
+
+	// Answers to auto-assigned transfers
+	summary.AssignmentOffers, summary.AssignmentsDeclined, summary.AssignmentsExpired, summary.AssignmentAcceptRate =
+		a.calculateAssignmentAcceptance(orgID, nil, start, end)
 }
 
 func (a *App) calculateAgentSummaryStats(orgID, agentID uuid.UUID, start, end time.Time, summary *AgentAnalyticsSummary) {
@@ -313,6 +333,13 @@ func (a *App) calculateAgentSummaryStats(orgID, agentID uuid.UUID, start, end ti
 
 	// Calculate break time
 	summary.TotalBreakTimeMins, summary.BreakCount = a.calculateBreakTime(agentID, start, end)
+
+	// Customer satisfaction for this agent
+	summary.AvgCSAT, summary.CSATResponses = a.calculateCSAT(orgID, &agentID, start, end)
+
+	// Answers to transfers auto-assigned to this agent
+	summary.AssignmentOffers, summary.AssignmentsDeclined, summary.AssignmentsExpired, summary.AssignmentAcceptRate =
+		a.calculateAssignmentAcceptance(orgID, &agentID, start, end)
 }
 
 func (a *App) calculateAgentStats(orgID, agentID uuid.UUID, start, end time.Time) AgentPerformanceStats {
@@ -357,6 +384,10 @@ func (a *App) calculateAgentStats(orgID, agentID uuid.UUID, start, end time.Time
 		Scan(&resolutionTimeResult)
 	stats.AvgResolutionMins = resolutionTimeResult.Avg
 
+	// Customer satisfaction
+	stats.AvgCSAT, stats.CSATResponses = a.calculateCSAT(orgID, &agentID, start, end)
+	stats.AssignmentOffers, _, _, stats.AssignmentAcceptRate = a.calculateAssignmentAcceptance(orgID, &agentID, start, end)
+
 	// Calculate break time from availability logs
 	stats.TotalBreakTimeMins, stats.BreakCount = a.calculateBreakTime(agentID, start, end)
 
@@ -395,6 +426,22 @@ func (a *App) calculateAllAgentStats(orgID uuid.UUID, start, end time.Time) []Ag
 	return stats
 }
 
+// calculateCSAT returns the average rating and number of answered surveys, optionally for a single agent
+func (a *App) calculateCSAT(orgID uuid.UUID, agentID *uuid.UUID, start, end time.Time) (avg float64, count int64) {
+	var result struct {
+		Avg   float64
+		Count int64
+	}
+	query := a.DB.Model(&models.ChatRating{}).
+		Select("COALESCE(AVG(rating), 0) as avg, COUNT(*) as count").
+		Where("organization_id = ? AND rating > 0 AND rated_at >= ? AND rated_at <= ?", orgID, start, end)
+	if agentID != nil {
+		query = query.Where("agent_id = ?", *agentID)
+	}
+	query.Scan(&result)
+	return result.Avg, result.Count
+}
+
 // calculateBreakTime calculates total break time and count for an agent within a time period
 func (a *App) calculateBreakTime(agentID uuid.UUID, start, end time.Time) (totalMins float64, count int64) {
 	// Get all "away" periods that overlap with the time range
@@ -453,8 +500,10 @@ func (a *App) calculateTrendData(orgID uuid.UUID, start, end time.Time, groupBy
 		Count int64
 	}
 
+	// Bucket by the organization's local calendar days
+	tz := a.getOrgLocation(orgID).String()
 	query := a.DB.Model(&models.AgentTransfer{}).
-		Select("DATE_TRUNC('" + dateTrunc + "', transferred_at) as date, COUNT(*) as count").
+		Select("DATE_TRUNC('"+dateTrunc+"', transferred_at AT TIME ZONE ?) as date, COUNT(*) as count", tz).
 		Where("organization_id = ? AND status = ? AND transferred_at >= ? AND transferred_at <= ?",
 			orgID, models.TransferStatusResumed, start, end)
 
@@ -463,7 +512,7 @@ func (a *App) calculateTrendData(orgID uuid.UUID, start, end time.Time, groupBy
 	}
 
 	var results []TrendResult
-	query.Group("DATE_TRUNC('" + dateTrunc + "', transferred_at)").
+	query.Group("date").
 		Order("date ASC").
 		Scan(&results)
 
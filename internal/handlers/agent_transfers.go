@@ -11,6 +11,7 @@ import (
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -28,9 +29,13 @@ type agentTransferRow struct {
 	TeamID                *uuid.UUID `gorm:"column:team_id"`
 	TransferredByUserID   *uuid.UUID `gorm:"column:transferred_by_user_id"`
 	Notes                 string     `gorm:"column:notes"`
+	HandoffContext        models.JSONB `gorm:"column:handoff_context"`
+	Priority              models.ConversationPriority `gorm:"column:priority"`
 	TransferredAt         time.Time  `gorm:"column:transferred_at"`
 	ResumedAt             *time.Time `gorm:"column:resumed_at"`
 	ResumedBy             *uuid.UUID `gorm:"column:resumed_by"`
+	WrapUpCodeID          *uuid.UUID `gorm:"column:wrap_up_code_id"`
+	WrapUpNotes           string     `gorm:"column:wrap_up_notes"`
 	SLAResponseDeadline   *time.Time `gorm:"column:sla_response_deadline"`
 	SLAResolutionDeadline *time.Time `gorm:"column:sla_resolution_deadline"`
 	SLABreached           bool       `gorm:"column:sla_breached"`
@@ -46,6 +51,7 @@ type agentTransferRow struct {
 	TeamName          *string `gorm:"column:team_name"`
 	TransferredByName *string `gorm:"column:transferred_by_name"`
 	ResumedByName     *string `gorm:"column:resumed_by_name"`
+	WrapUpCodeName    *string `gorm:"column:wrap_up_code_name"`
 }
 
 // CreateAgentTransferRequest represents the request to create an agent transfer
@@ -80,10 +86,15 @@ type AgentTransferResponse struct {
 	TransferredBy     *string              `json:"transferred_by,omitempty"`
 	TransferredByName *string              `json:"transferred_by_name,omitempty"`
 	Notes             string               `json:"notes"`
+	HandoffContext    map[string]any       `json:"handoff_context,omitempty"`
+	Priority          models.ConversationPriority `json:"priority"`
 	TransferredAt     string               `json:"transferred_at"`
 	ResumedAt         *string              `json:"resumed_at,omitempty"`
 	ResumedBy         *string              `json:"resumed_by,omitempty"`
 	ResumedByName     *string              `json:"resumed_by_name,omitempty"`
+	WrapUpCodeID      *string              `json:"wrap_up_code_id,omitempty"`
+	WrapUpCodeName    *string              `json:"wrap_up_code_name,omitempty"`
+	WrapUpNotes       string               `json:"wrap_up_notes,omitempty"`
 
 	// SLA fields
 	SLAResponseDeadline   *string `json:"sla_response_deadline,omitempty"`
@@ -107,7 +118,7 @@ func (a *App) ListAgentTransfers(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 
 	// Check permissions - users with write permission have full access (like admin)
-	hasFullAccess := a.HasPermission(userID, models.ResourceTransfers, models.ActionWrite)
+	hasFullAccess := a.HasPermission(userID, orgID, models.ResourceTransfers, models.ActionWrite)
 
 	// Query params
 	status := string(r.RequestCtx.QueryArgs().Peek("status"))
@@ -155,12 +166,16 @@ func (a *App) ListAgentTransfers(r *fastglue.Request) error {
 	if includeAll || includeSet["resumed_by"] {
 		selectCols = append(selectCols, "resumed_by.full_name AS resumed_by_name")
 	}
+	if includeAll || includeSet["wrap_up"] {
+		selectCols = append(selectCols, "wrap_up_codes.name AS wrap_up_code_name")
+	}
 
 	// Build query with conditional JOINs for better performance
 	query := a.DB.Table("agent_transfers").
 		Select(strings.Join(selectCols, ", ")).
 		Where("agent_transfers.organization_id = ?", orgID).
-		Order("agent_transfers.transferred_at ASC") // FIFO
+		Order(transferPriorityOrder).
+		Order("agent_transfers.transferred_at ASC") // Priority first, then FIFO
 
 	// Only add JOINs for requested relations (lazy loading)
 	if includeAll || includeSet["contact"] {
@@ -178,6 +193,9 @@ func (a *App) ListAgentTransfers(r *fastglue.Request) error {
 	if includeAll || includeSet["resumed_by"] {
 		query = query.Joins("LEFT JOIN users AS resumed_by ON resumed_by.id = agent_transfers.resumed_by")
 	}
+	if includeAll || includeSet["wrap_up"] {
+		query = query.Joins("LEFT JOIN wrap_up_codes ON wrap_up_codes.id = agent_transfers.wrap_up_code_id")
+	}
 
 	// Filter by status if provided
 	if status != "" {
@@ -288,6 +306,7 @@ func (a *App) ListAgentTransfers(r *fastglue.Request) error {
 
 	// Check if phone masking is enabled
 	shouldMask := a.ShouldMaskPhoneNumbers(orgID)
+	restricted := a.HasRestrictedDataAccess(userID, orgID)
 
 	// Build response from flat joined rows
 	response := make([]AgentTransferResponse, len(transfers))
@@ -305,8 +324,12 @@ func (a *App) ListAgentTransfers(r *fastglue.Request) error {
 			Status:          t.Status,
 			Source:          t.Source,
 			Notes:           t.Notes,
+			Priority:        t.Priority,
 			TransferredAt:   t.TransferredAt.Format(time.RFC3339),
 		}
+		if len(t.HandoffContext) > 0 && !restricted {
+			resp.HandoffContext = t.HandoffContext
+		}
 
 		if t.ContactName != nil {
 			contactName := *t.ContactName
@@ -345,6 +368,13 @@ func (a *App) ListAgentTransfers(r *fastglue.Request) error {
 			resp.ResumedByName = t.ResumedByName
 		}
 
+		if t.WrapUpCodeID != nil {
+			wrapUpCodeID := t.WrapUpCodeID.String()
+			resp.WrapUpCodeID = &wrapUpCodeID
+			resp.WrapUpCodeName = t.WrapUpCodeName
+		}
+		resp.WrapUpNotes = t.WrapUpNotes
+
 		// SLA fields
 		resp.SLABreached = t.SLABreached
 		resp.EscalationLevel = t.EscalationLevel
@@ -443,8 +473,9 @@ func (a *App) CreateAgentTransfer(r *fastglue.Request) error {
 		teamID = &parsedTeamID
 	}
 
-	// Determine agent assignment
+	// Determine agent assignment; agents picked automatically may need to accept
 	var agentID *uuid.UUID
+	autoAssigned := false
 
 	// First, try explicit agent from request
 	if req.AgentID != nil && *req.AgentID != "" {
@@ -464,15 +495,22 @@ func (a *App) CreateAgentTransfer(r *fastglue.Request) error {
 	} else if teamID != nil {
 		// Apply team's assignment strategy
 		agentID = a.assignToTeam(*teamID, orgID)
+		autoAssigned = true
 	} else if settings != nil && settings.AgentAssignment.AssignToSameAgent && contact.AssignedUserID != nil {
 		// Auto-assign to contact's existing assigned agent (if setting enabled and agent is available)
 		var assignedAgent models.User
 		if a.DB.Where("id = ?", contact.AssignedUserID).First(&assignedAgent).Error == nil && assignedAgent.IsAvailable {
 			agentID = contact.AssignedUserID
+			autoAssigned = true
 		}
 		// If agent is not available, falls through to queue (agentID remains nil)
 	}
-	// Otherwise, agentID remains nil (goes to queue)
+	// Otherwise apply the organization's assignment strategy, which may also leave the
+	// transfer in the queue
+	if agentID == nil && teamID == nil {
+		teamID, agentID = a.autoAssignTransfer(orgID, &contact, settings)
+		autoAssigned = true
+	}
 
 	// Determine source
 	source := req.Source
@@ -493,6 +531,7 @@ func (a *App) CreateAgentTransfer(r *fastglue.Request) error {
 		TeamID:              teamID,
 		TransferredByUserID: &userID,
 		Notes:               req.Notes,
+		Priority:            contactPriority(&contact),
 		TransferredAt:       time.Now(),
 	}
 
@@ -526,6 +565,10 @@ func (a *App) CreateAgentTransfer(r *fastglue.Request) error {
 
 	// Broadcast WebSocket notification
 	a.broadcastTransferCreated(&transfer, &contact)
+	a.notifyTransferQueued(&transfer, &contact)
+	if autoAssigned {
+		a.offerAssignment(&transfer, &contact, settings)
+	}
 
 	// Dispatch webhook for transfer created
 	var agentIDStr *string
@@ -567,6 +610,7 @@ func (a *App) CreateAgentTransfer(r *fastglue.Request) error {
 		Status:          transfer.Status,
 		Source:          transfer.Source,
 		Notes:           transfer.Notes,
+		Priority:        transfer.Priority,
 		TransferredAt:   transfer.TransferredAt.Format(time.RFC3339),
 	}
 
@@ -620,6 +664,18 @@ func (a *App) CreateAgentTransfer(r *fastglue.Request) error {
 	})
 }
 
+// ResumeTransferRequest wraps up a resolved conversation, or hands the contact back into
+// a chatbot flow at a given step
+type ResumeTransferRequest struct {
+	FlowID             *uuid.UUID     `json:"flow_id"`
+	StepName           string         `json:"step_name"`    // Empty starts at the first step
+	SessionData        map[string]any `json:"session_data"` // Prefilled flow variables
+	WrapUpCodeID       *uuid.UUID     `json:"wrap_up_code_id"`
+	WrapUpNotes        string         `json:"wrap_up_notes"`
+	SendClosingMessage *bool          `json:"send_closing_message"` // Defaults to sending the configured closing message
+	ClosingMessage     string         `json:"closing_message"`      // Overrides the configured closing message
+}
+
 // ResumeFromTransfer resumes chatbot processing for a transferred contact
 func (a *App) ResumeFromTransfer(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
@@ -644,6 +700,45 @@ func (a *App) ResumeFromTransfer(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Transfer is not active", nil, "")
 	}
 
+	// Optional handback into a flow
+	var req ResumeTransferRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+	var handbackFlow *models.ChatbotFlow
+	var handbackStep *models.ChatbotFlowStep
+	if req.FlowID != nil {
+		handbackFlow, err = a.getChatbotFlowByIDCached(orgID, *req.FlowID)
+		if err != nil || !handbackFlow.IsEnabled {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Flow not found or disabled", nil, "")
+		}
+		if len(handbackFlow.Steps) == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Flow has no steps", nil, "")
+		}
+		handbackStep = &handbackFlow.Steps[0]
+		if req.StepName != "" {
+			if handbackStep = findFlowStep(handbackFlow, req.StepName); handbackStep == nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Flow step not found", nil, "")
+			}
+		}
+	}
+
+	// Get chatbot settings to check AssignToSameAgent and wrap-up (use cache)
+	settings, _ := a.getChatbotSettingsCached(orgID, transfer.WhatsAppAccount)
+
+	// Wrap-up disposition; a handback continues the conversation, so it needs none
+	if req.WrapUpCodeID != nil {
+		if _, err := a.activeWrapUpCode(orgID, *req.WrapUpCodeID); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Wrap-up code not found or inactive", nil, "")
+		}
+		transfer.WrapUpCodeID = req.WrapUpCodeID
+	} else if handbackFlow == nil && settings != nil && settings.WrapUp.RequireCode {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "A wrap-up code is required to resolve this conversation", nil, "")
+	}
+	transfer.WrapUpNotes = strings.TrimSpace(req.WrapUpNotes)
+
 	// Update transfer
 	now := time.Now()
 	transfer.Status = models.TransferStatusResumed
@@ -656,9 +751,7 @@ func (a *App) ResumeFromTransfer(r *fastglue.Request) error {
 
 	// Clear chatbot tracking so client inactivity SLA doesn't trigger after transfer is closed
 	a.ClearContactChatbotTracking(transfer.ContactID)
-
-	// Get chatbot settings to check AssignToSameAgent (use cache)
-	settings, _ := a.getChatbotSettingsCached(orgID, transfer.WhatsAppAccount)
+	a.resetConversationPriority(transfer.ContactID)
 
 	// If AssignToSameAgent is disabled, unassign the contact
 	if settings != nil && !settings.AgentAssignment.AssignToSameAgent {
@@ -684,11 +777,90 @@ func (a *App) ResumeFromTransfer(r *fastglue.Request) error {
 		WhatsAppAccount: transfer.WhatsAppAccount,
 	})
 
+	if handbackFlow != nil {
+		// The conversation continues in the flow, so there's nothing to rate yet
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.handBackToFlow(transfer, &contact, handbackFlow, handbackStep, req.SessionData)
+		}()
+
+		return r.SendEnvelope(map[string]any{
+			"message":   "Transfer resumed, contact handed back to the chatbot flow",
+			"flow_id":   handbackFlow.ID,
+			"step_name": handbackStep.StepName,
+		})
+	}
+
+	closingMessage := strings.TrimSpace(req.ClosingMessage)
+	if closingMessage == "" && settings != nil {
+		closingMessage = settings.WrapUp.ClosingMessage
+	}
+	if req.SendClosingMessage != nil && !*req.SendClosingMessage {
+		closingMessage = ""
+	}
+
+	// Say goodbye, then ask the contact to rate the conversation (no-op unless enabled for the account)
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if closingMessage != "" {
+			a.sendClosingMessage(transfer, closingMessage)
+		}
+		a.sendCSATSurvey(transfer)
+	}()
+
 	return r.SendEnvelope(map[string]any{
 		"message": "Transfer resumed, chatbot is now active for this contact",
 	})
 }
 
+// handBackToFlow puts a resumed contact into a chatbot flow at the given step,
+// with prefilled session variables, and sends that step's message
+func (a *App) handBackToFlow(transfer models.AgentTransfer, contact *models.Contact, flow *models.ChatbotFlow, step *models.ChatbotFlowStep, sessionData map[string]any) {
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("organization_id = ? AND name = ?", transfer.OrganizationID, transfer.WhatsAppAccount).First(&account).Error; err != nil {
+		a.Log.Error("Failed to load WhatsApp account for flow handback", "error", err, "transfer_id", transfer.ID)
+		return
+	}
+
+	timeoutMins := 30
+	if settings, err := a.getChatbotSettingsCached(transfer.OrganizationID, transfer.WhatsAppAccount); err == nil && settings.SessionTimeoutMins > 0 {
+		timeoutMins = settings.SessionTimeoutMins
+	}
+	session, _ := a.getOrCreateSession(transfer.OrganizationID, contact.ID, account.Name, contact.PhoneNumber, timeoutMins)
+
+	data := models.JSONB{}
+	for k, v := range sessionData {
+		data[k] = v
+	}
+	data["_flow_id"] = flow.ID.String()
+	data["_flow_name"] = flow.Name
+
+	session.CurrentFlowID = &flow.ID
+	session.CurrentStep = step.StepName
+	session.StepRetries = 0
+	session.SessionData = data
+	if err := a.DB.Model(session).Updates(map[string]any{
+		"current_flow_id": flow.ID,
+		"current_step":    step.StepName,
+		"step_retries":    0,
+		"session_data":    data,
+	}).Error; err != nil {
+		a.Log.Error("Failed to update session for flow handback", "error", err, "session_id", session.ID)
+		return
+	}
+
+	a.Log.Info("Contact handed back to flow",
+		"transfer_id", transfer.ID,
+		"contact_id", contact.ID,
+		"flow_id", flow.ID,
+		"step", step.StepName,
+	)
+
+	a.sendStepWithSkipCheck(&account, session, contact, step, flow, nil)
+}
+
 // AssignAgentTransfer assigns a transfer to a specific agent
 func (a *App) AssignAgentTransfer(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
@@ -699,7 +871,7 @@ func (a *App) AssignAgentTransfer(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 
 	// Check permissions - users with write permission can assign transfers to others
-	hasWriteAccess := a.HasPermission(userID, models.ResourceTransfers, models.ActionWrite)
+	hasWriteAccess := a.HasPermission(userID, orgID, models.ResourceTransfers, models.ActionWrite)
 
 	transferIDStr := r.RequestCtx.UserValue("id").(string)
 	transferID, err := uuid.Parse(transferIDStr)
@@ -842,8 +1014,8 @@ func (a *App) PickNextTransfer(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 
 	// Check permissions - users with write permission have full access
-	hasFullAccess := a.HasPermission(userID, models.ResourceTransfers, models.ActionWrite)
-	hasPickupPermission := a.HasPermission(userID, models.ResourceTransfers, models.ActionPickup)
+	hasFullAccess := a.HasPermission(userID, orgID, models.ResourceTransfers, models.ActionWrite)
+	hasPickupPermission := a.HasPermission(userID, orgID, models.ResourceTransfers, models.ActionPickup)
 
 	// Check if agent queue pickup is allowed (use cache)
 	settings, _ := a.getChatbotSettingsCached(orgID, "")
@@ -882,6 +1054,7 @@ func (a *App) PickNextTransfer(r *fastglue.Request) error {
 	// Build query for picking transfer with row-level locking
 	query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
 		Where("organization_id = ? AND status = ? AND agent_id IS NULL", orgID, models.TransferStatusActive).
+		Order(transferPriorityOrder).
 		Order("transferred_at ASC")
 
 	if teamIDStr != "" {
@@ -984,8 +1157,12 @@ func (a *App) PickNextTransfer(r *fastglue.Request) error {
 		Status:          transfer.Status,
 		Source:          transfer.Source,
 		Notes:           transfer.Notes,
+		Priority:        transfer.Priority,
 		TransferredAt:   transfer.TransferredAt.Format(time.RFC3339),
 	}
+	if len(transfer.HandoffContext) > 0 && !a.HasRestrictedDataAccess(userID, orgID) {
+		resp.HandoffContext = transfer.HandoffContext
+	}
 
 	if transfer.Contact != nil {
 		contactName := transfer.Contact.ProfileName
@@ -1069,6 +1246,7 @@ func (a *App) broadcastTransferCreated(transfer *models.AgentTransfer, contact *
 		"status":           transfer.Status,
 		"source":           transfer.Source,
 		"notes":            transfer.Notes,
+		"priority":         transfer.Priority,
 		"transferred_at":   transfer.TransferredAt.Format(time.RFC3339),
 	}
 
@@ -1141,6 +1319,12 @@ func (a *App) broadcastTransferAssigned(transfer *models.AgentTransfer) {
 
 // createTransferToQueue creates an unassigned agent transfer that goes to the queue
 func (a *App) createTransferToQueue(account *models.WhatsAppAccount, contact *models.Contact, source models.TransferSource) {
+	a.createQueueTransfer(account, contact, source, transferHandoff{})
+}
+
+// createQueueTransfer creates a transfer to the general queue carrying the handoff's
+// notes and context. Its skills pick the agent when the organization auto-assigns.
+func (a *App) createQueueTransfer(account *models.WhatsAppAccount, contact *models.Contact, source models.TransferSource, handoff transferHandoff) {
 	// Check for existing active transfer
 	var existingCount int64
 	a.DB.Model(&models.AgentTransfer{}).
@@ -1152,10 +1336,26 @@ func (a *App) createTransferToQueue(account *models.WhatsAppAccount, contact *mo
 		return
 	}
 
-	// Get chatbot settings for SLA (use cache)
+	// Let routing scripts send the transfer to a team or straight to an agent
+	teamID, agentID := a.routeTransferWithScripts(account, contact, source)
+	if teamID != nil {
+		if handoff.Notes == "" {
+			handoff.Notes = "Routed by script"
+		}
+		a.createTeamTransfer(account, contact, *teamID, source, handoff)
+		return
+	}
+
+	// Get chatbot settings for SLA and assignment (use cache)
 	settings, _ := a.getChatbotSettingsCached(account.OrganizationID, account.Name)
+	if agentID == nil && len(handoff.Skills) > 0 && settings != nil && autoAssignsAgents(settings.AgentAssignment.Strategy) {
+		agentID = a.assignBySkills(account.OrganizationID, nil, handoff.Skills, settings.AgentAssignment.MaxActiveChats)
+	}
+	if agentID == nil {
+		teamID, agentID = a.autoAssignTransfer(account.OrganizationID, contact, settings)
+	}
 
-	// Create unassigned transfer (goes to queue)
+	// Create transfer; unassigned ones go to the queue
 	transfer := models.AgentTransfer{
 		BaseModel:       models.BaseModel{ID: uuid.New()},
 		OrganizationID:  account.OrganizationID,
@@ -1164,7 +1364,11 @@ func (a *App) createTransferToQueue(account *models.WhatsAppAccount, contact *mo
 		PhoneNumber:     contact.PhoneNumber,
 		Status:          models.TransferStatusActive,
 		Source:          source,
-		AgentID:         nil, // Unassigned - goes to queue
+		AgentID:         agentID,
+		TeamID:          teamID,
+		Notes:           handoff.Notes,
+		HandoffContext:  handoff.Context,
+		Priority:        contactPriority(contact),
 		TransferredAt:   time.Now(),
 	}
 
@@ -1172,16 +1376,24 @@ func (a *App) createTransferToQueue(account *models.WhatsAppAccount, contact *mo
 	if settings != nil {
 		a.SetSLADeadlines(&transfer, settings)
 	}
+	if agentID != nil {
+		a.UpdateSLAOnPickup(&transfer)
+	}
 
 	if err := a.DB.Create(&transfer).Error; err != nil {
 		a.Log.Error("Failed to create transfer to queue", "error", err, "contact_id", contact.ID, "source", string(source))
 		return
 	}
+	if agentID != nil {
+		a.DB.Model(contact).Update("assigned_user_id", agentID)
+	}
 
 	a.Log.Info("Transfer created to agent queue", "transfer_id", transfer.ID, "contact_id", contact.ID, "source", source)
 
 	// Broadcast to WebSocket
 	a.broadcastTransferCreated(&transfer, contact)
+	a.notifyTransferQueued(&transfer, contact)
+	a.offerAssignment(&transfer, contact, settings)
 }
 
 // createTransferFromKeyword creates an agent transfer triggered by a keyword rule
@@ -1202,7 +1414,7 @@ func (a *App) createTransferFromKeyword(account *models.WhatsAppAccount, contact
 
 	// Check business hours - if outside hours, send out of hours message instead of transfer
 	if settings != nil && settings.BusinessHours.Enabled && len(settings.BusinessHours.Hours) > 0 {
-		if !a.isWithinBusinessHours(settings.BusinessHours.Hours) {
+		if !a.isWithinBusinessHours(account.OrganizationID, settings.BusinessHours.Hours) {
 			a.Log.Info("Outside business hours, sending out of hours message instead of transfer", "contact_id", contact.ID)
 			if settings.BusinessHours.OutOfHoursMessage != "" {
 				_ = a.sendAndSaveTextMessage(account, contact, settings.BusinessHours.OutOfHoursMessage)
@@ -1222,6 +1434,20 @@ func (a *App) createTransferFromKeyword(account *models.WhatsAppAccount, contact
 		// If agent is not available, falls through to queue (agentID remains nil)
 	}
 
+	// Otherwise let routing scripts pick a team or an agent, then the assignment strategy
+	var teamID *uuid.UUID
+	if agentID == nil {
+		routedTeamID, routedAgentID := a.routeTransferWithScripts(account, contact, models.TransferSourceKeyword)
+		if routedTeamID != nil {
+			a.createTransferToTeam(account, contact, *routedTeamID, "Routed by script", models.TransferSourceKeyword)
+			return
+		}
+		agentID = routedAgentID
+	}
+	if agentID == nil {
+		teamID, agentID = a.autoAssignTransfer(account.OrganizationID, contact, settings)
+	}
+
 	// Create transfer
 	transfer := models.AgentTransfer{
 		BaseModel:       models.BaseModel{ID: uuid.New()},
@@ -1232,6 +1458,8 @@ func (a *App) createTransferFromKeyword(account *models.WhatsAppAccount, contact
 		Status:          models.TransferStatusActive,
 		Source:          models.TransferSourceKeyword,
 		AgentID:         agentID,
+		TeamID:          teamID,
+		Priority:        contactPriority(contact),
 		TransferredAt:   time.Now(),
 	}
 
@@ -1275,11 +1503,13 @@ func (a *App) createTransferFromKeyword(account *models.WhatsAppAccount, contact
 
 	// Broadcast to WebSocket
 	a.broadcastTransferCreated(&transfer, contact)
+	a.notifyTransferQueued(&transfer, contact)
+	a.offerAssignment(&transfer, contact, settings)
 }
 
-// assignToTeam applies the team's assignment strategy to select an agent
-// Returns nil if manual strategy or no available agents
-func (a *App) assignToTeam(teamID uuid.UUID, orgID uuid.UUID) *uuid.UUID {
+// assignToTeam applies the team's assignment strategy to select an agent, skipping any
+// excluded agents. Returns nil if manual strategy or no available agents
+func (a *App) assignToTeam(teamID uuid.UUID, orgID uuid.UUID, exclude ...uuid.UUID) *uuid.UUID {
 	// Get team and its assignment strategy
 	var team models.Team
 	if err := a.DB.Where("id = ? AND organization_id = ? AND is_active = ?", teamID, orgID, true).First(&team).Error; err != nil {
@@ -1289,26 +1519,37 @@ func (a *App) assignToTeam(teamID uuid.UUID, orgID uuid.UUID) *uuid.UUID {
 
 	switch team.AssignmentStrategy {
 	case models.AssignmentStrategyRoundRobin:
-		return a.assignToTeamRoundRobin(teamID, orgID)
+		return a.assignToTeamRoundRobin(teamID, orgID, exclude)
 	case models.AssignmentStrategyLoadBalanced:
-		return a.assignToTeamLoadBalanced(teamID, orgID)
+		return a.assignToTeamLoadBalanced(teamID, orgID, exclude)
 	case models.AssignmentStrategyManual:
 		// Manual means no auto-assignment
 		return nil
 	default:
 		// Default to round-robin
-		return a.assignToTeamRoundRobin(teamID, orgID)
+		return a.assignToTeamRoundRobin(teamID, orgID, exclude)
 	}
 }
 
-// assignToTeamRoundRobin selects the next agent using round-robin
-func (a *App) assignToTeamRoundRobin(teamID uuid.UUID, orgID uuid.UUID) *uuid.UUID {
-	// Get team members who are available agents, ordered by last assigned time
-	var members []models.TeamMember
-	err := a.DB.
+// availableTeamAgents scopes team members to available, active agents not in exclude who
+// aren't off shift
+func (a *App) availableTeamAgents(teamID uuid.UUID, exclude []uuid.UUID) *gorm.DB {
+	query := a.DB.
 		Joins("JOIN users ON users.id = team_members.user_id").
 		Where("team_members.team_id = ? AND team_members.role = ? AND users.is_available = ? AND users.is_active = ?",
 			teamID, models.TeamRoleAgent, true, true).
+		Where("users.on_shift IS NOT FALSE")
+	if len(exclude) > 0 {
+		query = query.Where("team_members.user_id NOT IN ?", exclude)
+	}
+	return query
+}
+
+// assignToTeamRoundRobin selects the next agent using round-robin
+func (a *App) assignToTeamRoundRobin(teamID uuid.UUID, orgID uuid.UUID, exclude []uuid.UUID) *uuid.UUID {
+	// Get team members who are available agents, ordered by last assigned time
+	var members []models.TeamMember
+	err := a.availableTeamAgents(teamID, exclude).
 		Order("team_members.last_assigned_at ASC NULLS FIRST").
 		Find(&members).Error
 
@@ -1329,14 +1570,10 @@ func (a *App) assignToTeamRoundRobin(teamID uuid.UUID, orgID uuid.UUID) *uuid.UU
 }
 
 // assignToTeamLoadBalanced selects the agent with fewest active transfers
-func (a *App) assignToTeamLoadBalanced(teamID uuid.UUID, orgID uuid.UUID) *uuid.UUID {
+func (a *App) assignToTeamLoadBalanced(teamID uuid.UUID, orgID uuid.UUID, exclude []uuid.UUID) *uuid.UUID {
 	// Get team members who are available agents
 	var members []models.TeamMember
-	err := a.DB.
-		Joins("JOIN users ON users.id = team_members.user_id").
-		Where("team_members.team_id = ? AND team_members.role = ? AND users.is_available = ? AND users.is_active = ?",
-			teamID, models.TeamRoleAgent, true, true).
-		Find(&members).Error
+	err := a.availableTeamAgents(teamID, exclude).Find(&members).Error
 
 	if err != nil || len(members) == 0 {
 		a.Log.Debug("No available agents in team for load-balanced", "team_id", teamID)
@@ -1389,6 +1626,12 @@ func (a *App) assignToTeamLoadBalanced(teamID uuid.UUID, orgID uuid.UUID) *uuid.
 
 // createTransferToTeam creates an agent transfer to a specific team with appropriate assignment
 func (a *App) createTransferToTeam(account *models.WhatsAppAccount, contact *models.Contact, teamID uuid.UUID, notes string, source models.TransferSource) {
+	a.createTeamTransfer(account, contact, teamID, source, transferHandoff{Notes: notes})
+}
+
+// createTeamTransfer creates a transfer to the team carrying the handoff's notes and
+// context. Its skills pick the team agent before the team's own strategy does.
+func (a *App) createTeamTransfer(account *models.WhatsAppAccount, contact *models.Contact, teamID uuid.UUID, source models.TransferSource, handoff transferHandoff) {
 	// Check for existing active transfer
 	var existingCount int64
 	a.DB.Model(&models.AgentTransfer{}).
@@ -1403,8 +1646,14 @@ func (a *App) createTransferToTeam(account *models.WhatsAppAccount, contact *mod
 	// Get chatbot settings for SLA (use cache)
 	settings, _ := a.getChatbotSettingsCached(account.OrganizationID, account.Name)
 
-	// Apply team's assignment strategy
-	agentID := a.assignToTeam(teamID, account.OrganizationID)
+	// Prefer a team agent with the handoff's skills, then apply the team's assignment strategy
+	var agentID *uuid.UUID
+	if len(handoff.Skills) > 0 {
+		agentID = a.assignToTeamBySkills(teamID, account.OrganizationID, handoff.Skills)
+	}
+	if agentID == nil {
+		agentID = a.assignToTeam(teamID, account.OrganizationID)
+	}
 
 	// Create transfer
 	transfer := models.AgentTransfer{
@@ -1417,7 +1666,9 @@ func (a *App) createTransferToTeam(account *models.WhatsAppAccount, contact *mod
 		Source:          source,
 		AgentID:         agentID,
 		TeamID:          &teamID,
-		Notes:           notes,
+		Notes:           handoff.Notes,
+		HandoffContext:  handoff.Context,
+		Priority:        contactPriority(contact),
 		TransferredAt:   time.Now(),
 	}
 
@@ -1463,6 +1714,8 @@ func (a *App) createTransferToTeam(account *models.WhatsAppAccount, contact *mod
 
 	// Broadcast to WebSocket
 	a.broadcastTransferCreated(&transfer, contact)
+	a.notifyTransferQueued(&transfer, contact)
+	a.offerAssignment(&transfer, contact, settings)
 }
 
 
@@ -628,6 +628,44 @@ func TestApp_ResumeFromTransfer_Success(t *testing.T) {
 	assert.Equal(t, user.ID, *updatedTransfer.ResumedBy)
 }
 
+func TestApp_ResumeFromTransfer_HandBackUnknownStep(t *testing.T) {
+	app := agentTransfersTestApp(t)
+	org := createTransferTestOrg(t, app)
+	user := createTransferTestUser(t, app, org.ID, nil)
+	account := createTransferTestAccount(t, app, org.ID)
+
+	contact := createTestContact(t, app, org.ID)
+	transfer := createTestTransfer(t, app, org.ID, contact.ID, account.Name, models.TransferStatusActive, nil)
+
+	flow := models.ChatbotFlow{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		Name:            "Checkout",
+		IsEnabled:       true,
+		Steps: []models.ChatbotFlowStep{
+			{BaseModel: models.BaseModel{ID: uuid.New()}, StepName: "payment", StepOrder: 1, Message: "How would you like to pay?"},
+		},
+	}
+	require.NoError(t, app.DB.Create(&flow).Error)
+
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"flow_id":   flow.ID.String(),
+		"step_name": "shipping",
+	})
+	setTransferAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", transfer.ID.String())
+
+	err := app.ResumeFromTransfer(req)
+	require.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	// Transfer must stay active when the handback target is invalid
+	var updatedTransfer models.AgentTransfer
+	require.NoError(t, app.DB.First(&updatedTransfer, transfer.ID).Error)
+	assert.Equal(t, models.TransferStatusActive, updatedTransfer.Status)
+}
+
 func TestApp_ResumeFromTransfer_NotFound(t *testing.T) {
 	app := agentTransfersTestApp(t)
 	org := createTransferTestOrg(t, app)
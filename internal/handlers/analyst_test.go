@@ -0,0 +1,71 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_RestrictedDataAccess(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	perms := models.SystemRolePermissions()["analyst"]
+	role := createTransferTestRole(t, app.DB, org.ID, "analyst", perms)
+	require.NoError(t, app.DB.Model(role).Update("restrict_data_access", true).Error)
+	analyst := createTestUser(t, app, org.ID, uniqueEmail("analyst"), "password", &role.ID, true)
+
+	contact := &models.Contact{
+		BaseModel:          models.BaseModel{ID: uuid.New()},
+		OrganizationID:     org.ID,
+		PhoneNumber:        "14155550190",
+		ProfileName:        "Grace Hopper",
+		LastMessagePreview: "My card ends in 4242",
+		Metadata:           models.JSONB{"email": "grace@example.com"},
+	}
+	require.NoError(t, app.DB.Create(contact).Error)
+
+	// Contacts are listed with masked details and no message preview or custom fields
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, analyst.ID)
+	require.NoError(t, app.ListContacts(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var list struct {
+		Contacts []handlers.ContactResponse `json:"contacts"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &list)
+	require.Len(t, list.Contacts, 1)
+	assert.Equal(t, "*******0190", list.Contacts[0].PhoneNumber)
+	assert.Equal(t, "G***", list.Contacts[0].ProfileName)
+	assert.Empty(t, list.Contacts[0].LastMessagePreview)
+	assert.Empty(t, list.Contacts[0].CustomFields)
+
+	// Message history and per-contact details are off limits
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, analyst.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.GetMessages(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, analyst.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.ListContactConsents(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	// Exports are masked and leave out custom fields
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, analyst.ID)
+	require.NoError(t, app.ExportContacts(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	body := string(req.RequestCtx.Response.Body())
+	assert.Contains(t, body, "*******0190")
+	assert.NotContains(t, body, "14155550190")
+	assert.NotContains(t, body, "Grace Hopper")
+	assert.NotContains(t, body, "grace@example.com")
+}
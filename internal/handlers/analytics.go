@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
@@ -37,7 +39,8 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
 	}
 
-	now := time.Now()
+	loc := a.getOrgLocation(orgID)
+	now := time.Now().In(loc)
 
 	// Parse date range from query params
 	fromStr := string(r.RequestCtx.QueryArgs().Peek("from"))
@@ -45,12 +48,12 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 
 	var periodStart, periodEnd time.Time
 	if fromStr != "" && toStr != "" {
-		// Parse custom date range
-		periodStart, err = time.Parse("2006-01-02", fromStr)
+		// Parse custom date range in the organization's timezone
+		periodStart, err = time.ParseInLocation("2006-01-02", fromStr, loc)
 		if err != nil {
 			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
 		}
-		periodEnd, err = time.Parse("2006-01-02", toStr)
+		periodEnd, err = time.ParseInLocation("2006-01-02", toStr, loc)
 		if err != nil {
 			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
 		}
@@ -58,10 +61,58 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 		periodEnd = periodEnd.Add(24*time.Hour - time.Nanosecond)
 	} else {
 		// Default to current month
-		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 		periodEnd = now
 	}
 
+	stats := a.computeDashboardStats(orgID, periodStart, periodEnd)
+
+	// Get recent messages
+	var messages []models.Message
+	a.DB.Where("organization_id = ?", orgID).
+		Preload("Contact").
+		Order("created_at DESC").
+		Limit(5).
+		Find(&messages)
+
+	// Restricted roles only see the type of each message
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	mask := a.dataMaskFor(orgID, userID)
+	recentMessages := make([]RecentMessageResponse, len(messages))
+	for i, msg := range messages {
+		contactName := "Unknown"
+		if msg.Contact != nil {
+			if msg.Contact.ProfileName != "" {
+				contactName = mask.Name(msg.Contact.ProfileName)
+			} else {
+				contactName = mask.Phone(msg.Contact.PhoneNumber)
+			}
+		}
+
+		content := mask.Content(msg.Content)
+		if content == "" && (msg.MessageType != models.MessageTypeText || mask.restricted) {
+			content = "[" + string(msg.MessageType) + "]"
+		}
+
+		recentMessages[i] = RecentMessageResponse{
+			ID:          msg.ID.String(),
+			ContactName: contactName,
+			Content:     content,
+			Direction:   msg.Direction,
+			CreatedAt:   msg.CreatedAt.Format(time.RFC3339),
+			Status:      msg.Status,
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"stats":           stats,
+		"recent_messages": recentMessages,
+	})
+}
+
+// computeDashboardStats counts the organization's activity in a period, with the change
+// against the period of the same length just before it
+func (a *App) computeDashboardStats(orgID uuid.UUID, periodStart, periodEnd time.Time) DashboardStats {
 	// Calculate the previous period for comparison (same duration, before the current period)
 	periodDuration := periodEnd.Sub(periodStart)
 	previousPeriodStart := periodStart.Add(-periodDuration - time.Nanosecond)
@@ -115,7 +166,7 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 
 	campaignsChange := calculatePercentageChange(previousPeriodCampaigns, currentPeriodCampaigns)
 
-	stats := DashboardStats{
+	return DashboardStats{
 		TotalMessages:   currentPeriodMessages,
 		MessagesChange:  messagesChange,
 		TotalContacts:   currentPeriodContacts,
@@ -125,45 +176,6 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 		CampaignsSent:   currentPeriodCampaigns,
 		CampaignsChange: campaignsChange,
 	}
-
-	// Get recent messages
-	var messages []models.Message
-	a.DB.Where("organization_id = ?", orgID).
-		Preload("Contact").
-		Order("created_at DESC").
-		Limit(5).
-		Find(&messages)
-
-	recentMessages := make([]RecentMessageResponse, len(messages))
-	for i, msg := range messages {
-		contactName := "Unknown"
-		if msg.Contact != nil {
-			if msg.Contact.ProfileName != "" {
-				contactName = msg.Contact.ProfileName
-			} else {
-				contactName = msg.Contact.PhoneNumber
-			}
-		}
-
-		content := msg.Content
-		if content == "" && msg.MessageType != models.MessageTypeText {
-			content = "[" + string(msg.MessageType) + "]"
-		}
-
-		recentMessages[i] = RecentMessageResponse{
-			ID:          msg.ID.String(),
-			ContactName: contactName,
-			Content:     content,
-			Direction:   msg.Direction,
-			CreatedAt:   msg.CreatedAt.Format(time.RFC3339),
-			Status:      msg.Status,
-		}
-	}
-
-	return r.SendEnvelope(map[string]interface{}{
-		"stats":           stats,
-		"recent_messages": recentMessages,
-	})
 }
 
 // calculatePercentageChange calculates the percentage change between two values
@@ -176,3 +188,34 @@ func calculatePercentageChange(previous, current int64) float64 {
 	}
 	return float64(current-previous) / float64(previous) * 100.0
 }
+
+// calculateRate returns part as a percentage of total
+func calculateRate(part, total int64) float64 {
+	if total == 0 {
+		return 0.0
+	}
+	return float64(part) / float64(total) * 100.0
+}
+
+// parseAnalyticsPeriod reads the from/to query params (YYYY-MM-DD) as dates in loc, defaulting to the current month
+func parseAnalyticsPeriod(r *fastglue.Request, loc *time.Location) (time.Time, time.Time, error) {
+	fromStr := string(r.RequestCtx.QueryArgs().Peek("from"))
+	toStr := string(r.RequestCtx.QueryArgs().Peek("to"))
+
+	if fromStr == "" || toStr == "" {
+		now := time.Now().In(loc)
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc), now, nil
+	}
+
+	periodStart, err := time.ParseInLocation("2006-01-02", fromStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid 'from' date format. Use YYYY-MM-DD")
+	}
+	periodEnd, err := time.ParseInLocation("2006-01-02", toStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid 'to' date format. Use YYYY-MM-DD")
+	}
+
+	// End of day for the to date
+	return periodStart, periodEnd.Add(24*time.Hour - time.Nanosecond), nil
+}
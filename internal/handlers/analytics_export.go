@@ -0,0 +1,736 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/byok"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/mailer"
+	"github.com/shridarpatil/whatomate/pkg/xlsx"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// analyticsExportTTL is how long a finished export can be downloaded
+	analyticsExportTTL = 24 * time.Hour
+	// maxAnalyticsExportDays caps the date range of a single export
+	maxAnalyticsExportDays = 366
+	// analyticsExportPickupDelay is how long a job waits before the processor runs it,
+	// covering jobs whose request-time run was lost to a restart
+	analyticsExportPickupDelay = time.Minute
+	// analyticsExportTimeout marks jobs still processing after this long as failed
+	analyticsExportTimeout = 30 * time.Minute
+	// analyticsExportListLimit caps how many of the user's exports are listed
+	analyticsExportListLimit = 20
+	// analyticsExportDir is the media storage subdirectory holding export files
+	analyticsExportDir = "exports"
+	// analyticsExportDownloadPath is the public, token-authenticated download route
+	analyticsExportDownloadPath = "/api/analytics/exports/download/"
+)
+
+// Analytics export reports
+const (
+	AnalyticsExportMessages  = "messages"
+	AnalyticsExportAgents    = "agents"
+	AnalyticsExportCampaigns = "campaigns"
+	AnalyticsExportWrapUp    = "wrap_up"
+)
+
+var (
+	analyticsExportReports = []string{AnalyticsExportMessages, AnalyticsExportAgents, AnalyticsExportCampaigns, AnalyticsExportWrapUp}
+	analyticsExportFormats = []string{"csv", "xlsx"}
+)
+
+// AnalyticsExportRequest requests a report file for a date range
+type AnalyticsExportRequest struct {
+	Report      string `json:"report"`
+	Format      string `json:"format"` // csv (default) or xlsx
+	From        string `json:"from"`   // YYYY-MM-DD
+	To          string `json:"to"`
+	NotifyEmail bool   `json:"notify_email"` // Also email the download link when ready
+}
+
+// AnalyticsExportResponse describes an export job
+type AnalyticsExportResponse struct {
+	ID          uuid.UUID                    `json:"id"`
+	Report      string                       `json:"report"`
+	Format      string                       `json:"format"`
+	From        string                       `json:"from"`
+	To          string                       `json:"to"`
+	Status      models.AnalyticsExportStatus `json:"status"`
+	Error       string                       `json:"error,omitempty"`
+	RowCount    int                          `json:"row_count"`
+	FileSize    int64                        `json:"file_size"`
+	DownloadURL string                       `json:"download_url,omitempty"` // Only while the file can be downloaded
+	ExpiresAt   *time.Time                   `json:"expires_at,omitempty"`
+	CreatedAt   time.Time                    `json:"created_at"`
+	CompletedAt *time.Time                   `json:"completed_at,omitempty"`
+}
+
+// CreateAnalyticsExport queues a report export. The requester is notified over the
+// WebSocket (and by email when asked) once the file is ready.
+func (a *App) CreateAnalyticsExport(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req AnalyticsExportRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	if msg := a.validateAnalyticsExport(orgID, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	token := generateVerifyToken()
+	export := models.AnalyticsExport{
+		OrganizationID: orgID,
+		RequestedByID:  userID,
+		Report:         req.Report,
+		Format:         req.Format,
+		FromDate:       req.From,
+		ToDate:         req.To,
+		NotifyEmail:    req.NotifyEmail,
+		Status:         models.AnalyticsExportStatusPending,
+		DownloadToken:  token,
+		DownloadURL:    a.frontendURL(r, analyticsExportDownloadPath+token),
+	}
+	if err := a.DB.Create(&export).Error; err != nil {
+		a.Log.Error("Failed to create analytics export", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create export", nil, "")
+	}
+
+	exportID := export.ID
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.runAnalyticsExport(exportID)
+	}()
+
+	return r.SendEnvelope(buildAnalyticsExportResponse(&export))
+}
+
+// ListAnalyticsExports returns the current user's recent exports
+func (a *App) ListAnalyticsExports(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var exports []models.AnalyticsExport
+	if err := a.DB.Where("organization_id = ? AND requested_by_id = ?", orgID, userID).
+		Order("created_at DESC").Limit(analyticsExportListLimit).Find(&exports).Error; err != nil {
+		a.Log.Error("Failed to list analytics exports", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list exports", nil, "")
+	}
+
+	result := make([]AnalyticsExportResponse, len(exports))
+	for i := range exports {
+		result[i] = buildAnalyticsExportResponse(&exports[i])
+	}
+	return r.SendEnvelope(map[string]interface{}{"exports": result})
+}
+
+// GetAnalyticsExport returns one of the current user's exports
+func (a *App) GetAnalyticsExport(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid export ID", nil, "")
+	}
+
+	var export models.AnalyticsExport
+	if err := a.DB.Where("id = ? AND organization_id = ? AND requested_by_id = ?", id, orgID, userID).
+		First(&export).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Export not found", nil, "")
+	}
+
+	return r.SendEnvelope(buildAnalyticsExportResponse(&export))
+}
+
+// DownloadAnalyticsExport serves a finished export file. The token in the URL is the
+// only credential, so links work from email without a session.
+func (a *App) DownloadAnalyticsExport(r *fastglue.Request) error {
+	token, _ := r.RequestCtx.UserValue("token").(string)
+	if token == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Export not found", nil, "")
+	}
+
+	var export models.AnalyticsExport
+	if err := a.DB.Where("download_token = ?", token).First(&export).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Export not found", nil, "")
+	}
+	if export.Status == models.AnalyticsExportStatusExpired ||
+		(export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt)) {
+		return r.SendErrorEnvelope(fasthttp.StatusGone, "Download link has expired", nil, "")
+	}
+	if export.Status != models.AnalyticsExportStatusCompleted || export.FilePath == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Export is not ready", nil, "")
+	}
+
+	f, err := a.openMediaFile(export.OrganizationID, export.FilePath)
+	if err != nil {
+		if errors.Is(err, byok.ErrKeyUnavailable) {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization encryption key is unavailable", nil, "")
+		}
+		a.Log.Error("Failed to open analytics export", "error", err, "export_id", export.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Export file not found", nil, "")
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if export.Format == "xlsx" {
+		contentType = xlsx.ContentType
+	}
+	r.RequestCtx.Response.Header.Set("Content-Type", contentType)
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, analyticsExportFilename(&export)))
+	r.RequestCtx.Response.Header.Set("Cache-Control", "private, no-store")
+	// fasthttp closes the file once the body is sent
+	r.RequestCtx.SetBodyStream(f, int(export.FileSize))
+	return nil
+}
+
+// validateAnalyticsExport checks the report, format and date range, returning an error
+// message or "" when valid
+func (a *App) validateAnalyticsExport(orgID uuid.UUID, req *AnalyticsExportRequest) string {
+	if !slices.Contains(analyticsExportReports, req.Report) {
+		return "report must be one of: " + strings.Join(analyticsExportReports, ", ")
+	}
+	if !slices.Contains(analyticsExportFormats, req.Format) {
+		return "format must be csv or xlsx"
+	}
+
+	loc := a.getOrgLocation(orgID)
+	from, err := time.ParseInLocation("2006-01-02", req.From, loc)
+	if err != nil {
+		return "Invalid 'from' date format. Use YYYY-MM-DD"
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.To, loc)
+	if err != nil {
+		return "Invalid 'to' date format. Use YYYY-MM-DD"
+	}
+	if to.Before(from) {
+		return "'to' must not be before 'from'"
+	}
+	if to.Sub(from) >= maxAnalyticsExportDays*24*time.Hour {
+		return fmt.Sprintf("Date range cannot exceed %d days", maxAnalyticsExportDays)
+	}
+	return ""
+}
+
+// runAnalyticsExport claims a pending export, writes its file and notifies the requester.
+// Claiming is atomic, so the request-time run and the processor never both run a job.
+func (a *App) runAnalyticsExport(exportID uuid.UUID) {
+	now := time.Now()
+	result := a.DB.Model(&models.AnalyticsExport{}).
+		Where("id = ? AND status = ?", exportID, models.AnalyticsExportStatusPending).
+		Updates(map[string]interface{}{"status": models.AnalyticsExportStatusProcessing, "started_at": now})
+	if result.Error != nil {
+		a.Log.Error("Failed to claim analytics export", "error", result.Error, "export_id", exportID)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	var export models.AnalyticsExport
+	if err := a.DB.Where("id = ?", exportID).First(&export).Error; err != nil {
+		a.Log.Error("Failed to load analytics export", "error", err, "export_id", exportID)
+		return
+	}
+
+	path, rows, size, err := a.writeAnalyticsExport(&export)
+	if err != nil {
+		a.Log.Error("Analytics export failed", "error", err, "export_id", export.ID, "report", export.Report)
+		export.Status = models.AnalyticsExportStatusFailed
+		export.Error = "Failed to generate the export"
+		if err := a.DB.Model(&export).Updates(map[string]interface{}{
+			"status": export.Status,
+			"error":  export.Error,
+		}).Error; err != nil {
+			a.Log.Error("Failed to update analytics export", "error", err, "export_id", export.ID)
+		}
+		a.notifyAnalyticsExport(&export)
+		return
+	}
+
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(analyticsExportTTL)
+	export.Status = models.AnalyticsExportStatusCompleted
+	export.FilePath = path
+	export.RowCount = rows
+	export.FileSize = size
+	export.CompletedAt = &completedAt
+	export.ExpiresAt = &expiresAt
+	if err := a.DB.Model(&export).Updates(map[string]interface{}{
+		"status":       export.Status,
+		"file_path":    path,
+		"row_count":    rows,
+		"file_size":    size,
+		"completed_at": completedAt,
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		a.Log.Error("Failed to update analytics export", "error", err, "export_id", export.ID)
+		_ = os.Remove(a.mediaFullPath(path))
+		return
+	}
+
+	a.notifyAnalyticsExport(&export)
+}
+
+// writeAnalyticsExport writes the report file and returns its storage path, the number
+// of data rows and the file size
+func (a *App) writeAnalyticsExport(export *models.AnalyticsExport) (string, int, int64, error) {
+	loc := a.getOrgLocation(export.OrganizationID)
+	start, err := time.ParseInLocation("2006-01-02", export.FromDate, loc)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	end, err := time.ParseInLocation("2006-01-02", export.ToDate, loc)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	end = end.Add(24*time.Hour - time.Nanosecond)
+
+	dir, err := a.ensureMediaDir(export.OrganizationID, analyticsExportDir)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	path := filepath.Join(dir, export.ID.String()+"."+export.Format)
+	fullPath := a.mediaFullPath(path)
+
+	f, err := a.createMediaFile(export.OrganizationID, path, os.O_TRUNC)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	fail := func(err error) (string, int, int64, error) {
+		_ = f.Close()
+		_ = os.Remove(fullPath)
+		return "", 0, 0, err
+	}
+	// The size is counted before encryption, as that is what gets downloaded
+	counter := &countingWriter{w: f}
+
+	var w analyticsExportWriter
+	if export.Format == "xlsx" {
+		xw, err := xlsx.NewWriter(counter, export.Report)
+		if err != nil {
+			return fail(err)
+		}
+		w = xw
+	} else {
+		w = &csvExportWriter{w: csv.NewWriter(counter)}
+	}
+
+	var rows int
+	switch export.Report {
+	case AnalyticsExportMessages:
+		rows, err = a.exportMessagesReport(export.OrganizationID, start, end, loc, a.dataMaskFor(export.OrganizationID, export.RequestedByID), w)
+	case AnalyticsExportAgents:
+		rows, err = a.exportAgentsReport(export.OrganizationID, start, end, w)
+	case AnalyticsExportCampaigns:
+		rows, err = a.exportCampaignsReport(export.OrganizationID, start, end, loc, w)
+	case AnalyticsExportWrapUp:
+		rows, err = a.exportWrapUpReport(export.OrganizationID, start, end, loc, a.dataMaskFor(export.OrganizationID, export.RequestedByID), w)
+	default:
+		err = fmt.Errorf("unknown report: %s", export.Report)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if err := w.Close(); err != nil {
+		return fail(err)
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(fullPath)
+		return "", 0, 0, err
+	}
+	return path, rows, counter.n, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// exportMessagesReport writes one row per message in the range, oldest first. Contacts
+// are masked for the user who requested the export, and restricted roles get no content.
+func (a *App) exportMessagesReport(orgID uuid.UUID, start, end time.Time, loc *time.Location, mask dataMask, w analyticsExportWriter) (int, error) {
+	if err := w.Write([]interface{}{
+		"Date", "Direction", "Type", "Status", "Contact", "Phone Number", "Account",
+		"Template", "Sent By", "Content", "Error",
+	}); err != nil {
+		return 0, err
+	}
+
+	rows, err := a.DB.Table("messages").
+		Select("messages.created_at, messages.direction, messages.message_type, messages.status, "+
+			"messages.whats_app_account, messages.template_name, messages.content, messages.error_message, "+
+			"contacts.profile_name, contacts.phone_number, users.full_name AS sent_by").
+		Joins("LEFT JOIN contacts ON contacts.id = messages.contact_id").
+		Joins("LEFT JOIN users ON users.id = messages.sent_by_user_id").
+		Where("messages.organization_id = ? AND messages.deleted_at IS NULL", orgID).
+		Where("messages.created_at >= ? AND messages.created_at <= ?", start, end).
+		Order("messages.created_at").
+		Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	count := 0
+	for rows.Next() {
+		var row struct {
+			CreatedAt       time.Time
+			Direction       string
+			MessageType     string
+			Status          string
+			WhatsAppAccount string `gorm:"column:whats_app_account"`
+			TemplateName    string
+			Content         string
+			ErrorMessage    string
+			ProfileName     string
+			PhoneNumber     string
+			SentBy          string
+		}
+		if err := a.DB.ScanRows(rows, &row); err != nil {
+			return count, err
+		}
+		if err := w.Write([]interface{}{
+			row.CreatedAt.In(loc), row.Direction, row.MessageType, row.Status,
+			mask.Name(row.ProfileName), mask.Phone(row.PhoneNumber),
+			row.WhatsAppAccount, row.TemplateName, row.SentBy, mask.Content(row.Content), row.ErrorMessage,
+		}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// exportAgentsReport writes the agent performance table shown in agent analytics
+func (a *App) exportAgentsReport(orgID uuid.UUID, start, end time.Time, w analyticsExportWriter) (int, error) {
+	if err := w.Write([]interface{}{
+		"Agent", "Transfers Handled", "Active Transfers", "Messages Sent",
+		"Avg First Response (mins)", "Avg Resolution (mins)", "Break Time (mins)", "Breaks",
+		"Avg CSAT", "CSAT Responses",
+	}); err != nil {
+		return 0, err
+	}
+
+	stats := a.calculateAllAgentStats(orgID, start, end)
+	for _, s := range stats {
+		if err := w.Write([]interface{}{
+			s.AgentName, s.TransfersHandled, s.ActiveTransfers, s.MessagesSent,
+			s.AvgFirstResponseMins, s.AvgResolutionMins, s.TotalBreakTimeMins, s.BreakCount,
+			s.AvgCSAT, s.CSATResponses,
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(stats), nil
+}
+
+// exportCampaignsReport writes the delivery results of campaigns created in the range
+func (a *App) exportCampaignsReport(orgID uuid.UUID, start, end time.Time, loc *time.Location, w analyticsExportWriter) (int, error) {
+	if err := w.Write([]interface{}{
+		"Campaign", "Status", "Account", "Recipients", "Sent", "Delivered", "Read", "Failed",
+		"Estimated Cost", "Actual Cost", "Created", "Started", "Completed",
+	}); err != nil {
+		return 0, err
+	}
+
+	var campaigns []models.BulkMessageCampaign
+	if err := a.DB.Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, start, end).
+		Order("created_at").Find(&campaigns).Error; err != nil {
+		return 0, err
+	}
+
+	localTime := func(t *time.Time) interface{} {
+		if t == nil {
+			return nil
+		}
+		return t.In(loc)
+	}
+	for _, c := range campaigns {
+		if err := w.Write([]interface{}{
+			c.Name, string(c.Status), c.WhatsAppAccount, c.TotalRecipients, c.SentCount,
+			c.DeliveredCount, c.ReadCount, c.FailedCount, c.EstimatedCost, c.ActualCost,
+			c.CreatedAt.In(loc), localTime(c.StartedAt), localTime(c.CompletedAt),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(campaigns), nil
+}
+
+// exportWrapUpReport writes one row per conversation resolved in the range with its
+// wrap-up code and handling times, oldest first
+func (a *App) exportWrapUpReport(orgID uuid.UUID, start, end time.Time, loc *time.Location, mask dataMask, w analyticsExportWriter) (int, error) {
+	if err := w.Write([]interface{}{
+		"Resolved At", "Wrap-up Code", "Wrap-up Name", "Notes", "Agent", "Team", "Source",
+		"Contact", "Phone Number", "Account", "Transferred At", "Handle Time (mins)",
+	}); err != nil {
+		return 0, err
+	}
+
+	rows, err := a.DB.Table("agent_transfers").
+		Select("agent_transfers.resumed_at, agent_transfers.transferred_at, agent_transfers.source, "+
+			"agent_transfers.whats_app_account, agent_transfers.wrap_up_notes, "+
+			"wrap_up_codes.code AS wrap_up_code, wrap_up_codes.name AS wrap_up_name, "+
+			"contacts.profile_name, contacts.phone_number, users.full_name AS agent_name, teams.name AS team_name").
+		Joins("LEFT JOIN wrap_up_codes ON wrap_up_codes.id = agent_transfers.wrap_up_code_id").
+		Joins("LEFT JOIN contacts ON contacts.id = agent_transfers.contact_id").
+		Joins("LEFT JOIN users ON users.id = agent_transfers.agent_id").
+		Joins("LEFT JOIN teams ON teams.id = agent_transfers.team_id").
+		Where("agent_transfers.organization_id = ? AND agent_transfers.status = ? AND agent_transfers.deleted_at IS NULL",
+			orgID, models.TransferStatusResumed).
+		Where("agent_transfers.resumed_at >= ? AND agent_transfers.resumed_at <= ?", start, end).
+		Order("agent_transfers.resumed_at").
+		Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	count := 0
+	for rows.Next() {
+		var row struct {
+			ResumedAt       time.Time
+			TransferredAt   time.Time
+			Source          string
+			WhatsAppAccount string `gorm:"column:whats_app_account"`
+			WrapUpNotes     string
+			WrapUpCode      string
+			WrapUpName      string
+			ProfileName     string
+			PhoneNumber     string
+			AgentName       string
+			TeamName        string
+		}
+		if err := a.DB.ScanRows(rows, &row); err != nil {
+			return count, err
+		}
+		if err := w.Write([]interface{}{
+			row.ResumedAt.In(loc), row.WrapUpCode, row.WrapUpName, mask.Content(row.WrapUpNotes),
+			row.AgentName, row.TeamName, row.Source, mask.Name(row.ProfileName), mask.Phone(row.PhoneNumber),
+			row.WhatsAppAccount, row.TransferredAt.In(loc), math.Round(row.ResumedAt.Sub(row.TransferredAt).Minutes()*10) / 10,
+		}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// notifyAnalyticsExport tells the requester that an export finished or failed
+func (a *App) notifyAnalyticsExport(export *models.AnalyticsExport) {
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToUser(export.OrganizationID, export.RequestedByID, websocket.WSMessage{
+			Type:    websocket.TypeAnalyticsExport,
+			Payload: buildAnalyticsExportResponse(export),
+		})
+	}
+
+	if !export.NotifyEmail || export.Status != models.AnalyticsExportStatusCompleted {
+		return
+	}
+	var user models.User
+	if err := a.DB.Select("email", "full_name").Where("id = ?", export.RequestedByID).First(&user).Error; err != nil {
+		a.Log.Error("Failed to load export requester", "error", err, "export_id", export.ID)
+		return
+	}
+	a.sendOrgEmailAsync(export.OrganizationID, []string{user.Email}, mailer.TemplateExportReady, map[string]interface{}{
+		"Name":        user.FullName,
+		"Report":      export.Report,
+		"Range":       export.FromDate + " to " + export.ToDate,
+		"Rows":        export.RowCount,
+		"DownloadURL": export.DownloadURL,
+		"ExpiresIn":   "24 hours",
+	})
+}
+
+// processAnalyticsExports runs jobs left pending (e.g. across a restart), fails jobs
+// stuck processing and removes files whose download window has passed
+func (a *App) processAnalyticsExports() {
+	now := time.Now()
+
+	var pendingIDs []uuid.UUID
+	if err := a.DB.Model(&models.AnalyticsExport{}).
+		Where("status = ? AND created_at < ?", models.AnalyticsExportStatusPending, now.Add(-analyticsExportPickupDelay)).
+		Order("created_at").Pluck("id", &pendingIDs).Error; err != nil {
+		a.Log.Error("Failed to load pending analytics exports", "error", err)
+	}
+	for _, id := range pendingIDs {
+		a.runAnalyticsExport(id)
+	}
+
+	if err := a.DB.Model(&models.AnalyticsExport{}).
+		Where("status = ? AND started_at < ?", models.AnalyticsExportStatusProcessing, now.Add(-analyticsExportTimeout)).
+		Updates(map[string]interface{}{
+			"status": models.AnalyticsExportStatusFailed,
+			"error":  "Export was interrupted",
+		}).Error; err != nil {
+		a.Log.Error("Failed to fail stuck analytics exports", "error", err)
+	}
+
+	var expired []models.AnalyticsExport
+	if err := a.DB.Where("status = ? AND expires_at < ?", models.AnalyticsExportStatusCompleted, now).
+		Find(&expired).Error; err != nil {
+		a.Log.Error("Failed to load expired analytics exports", "error", err)
+		return
+	}
+	for _, export := range expired {
+		if export.FilePath != "" {
+			err := os.Remove(a.mediaFullPath(export.FilePath))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				a.Log.Error("Failed to remove expired analytics export", "error", err, "export_id", export.ID)
+				continue
+			}
+		}
+		if err := a.DB.Model(&export).Updates(map[string]interface{}{
+			"status":    models.AnalyticsExportStatusExpired,
+			"file_path": "",
+		}).Error; err != nil {
+			a.Log.Error("Failed to expire analytics export", "error", err, "export_id", export.ID)
+		}
+	}
+}
+
+// AnalyticsExportProcessor runs leftover export jobs and cleans up expired files
+type AnalyticsExportProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAnalyticsExportProcessor creates a new analytics export processor
+func NewAnalyticsExportProcessor(app *App, interval time.Duration) *AnalyticsExportProcessor {
+	return &AnalyticsExportProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the processing loop
+func (p *AnalyticsExportProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Analytics export processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Analytics export processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Analytics export processor stopped")
+			return
+		case <-ticker.C:
+			p.app.processAnalyticsExports()
+		}
+	}
+}
+
+// Stop stops the analytics export processor
+func (p *AnalyticsExportProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// analyticsExportWriter writes report rows in the export's file format
+type analyticsExportWriter interface {
+	Write(row []interface{}) error
+	Close() error
+}
+
+// csvExportWriter writes rows as CSV, formatting values like the XLSX writer
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvExportWriter) Write(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		s := xlsx.Format(v)
+		if _, ok := v.(string); ok {
+			s = escapeCSVFormula(s)
+		}
+		record[i] = s
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvExportWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeCSVFormula prefixes text that spreadsheets would run as a formula
+func escapeCSVFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func analyticsExportFilename(export *models.AnalyticsExport) string {
+	return fmt.Sprintf("whatomate-%s-%s-to-%s.%s", export.Report, export.FromDate, export.ToDate, export.Format)
+}
+
+func buildAnalyticsExportResponse(export *models.AnalyticsExport) AnalyticsExportResponse {
+	resp := AnalyticsExportResponse{
+		ID:          export.ID,
+		Report:      export.Report,
+		Format:      export.Format,
+		From:        export.FromDate,
+		To:          export.ToDate,
+		Status:      export.Status,
+		Error:       export.Error,
+		RowCount:    export.RowCount,
+		FileSize:    export.FileSize,
+		ExpiresAt:   export.ExpiresAt,
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+	}
+	if export.Status == models.AnalyticsExportStatusCompleted &&
+		export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt) {
+		resp.DownloadURL = export.DownloadURL
+	}
+	return resp
+}
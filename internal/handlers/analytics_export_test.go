@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_AnalyticsExport(t *testing.T) {
+	app := testApp(t)
+	app.Config.Storage.LocalPath = t.TempDir()
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("export"), "password", &role.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "export-account")
+
+	contact := &models.Contact{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		PhoneNumber:     "15550002222",
+		ProfileName:     "Export Contact",
+	}
+	require.NoError(t, app.DB.Create(contact).Error)
+	require.NoError(t, app.DB.Create(&models.Message{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		Direction:       models.DirectionIncoming,
+		MessageType:     models.MessageTypeText,
+		Content:         "=1+1, a formula",
+	}).Error)
+
+	now := time.Now().UTC()
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"report": "messages",
+		"from":   now.AddDate(0, 0, -1).Format("2006-01-02"),
+		"to":     now.AddDate(0, 0, 1).Format("2006-01-02"),
+	})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreateAnalyticsExport(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var created struct {
+		Data handlers.AnalyticsExportResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, "csv", created.Data.Format)
+	assert.Empty(t, created.Data.DownloadURL)
+
+	app.WaitForBackgroundTasks()
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", created.Data.ID.String())
+	require.NoError(t, app.GetAnalyticsExport(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var fetched struct {
+		Data handlers.AnalyticsExportResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &fetched)
+	require.Equal(t, models.AnalyticsExportStatusCompleted, fetched.Data.Status)
+	assert.Equal(t, 1, fetched.Data.RowCount)
+	assert.NotEmpty(t, fetched.Data.DownloadURL)
+
+	var export models.AnalyticsExport
+	require.NoError(t, app.DB.First(&export, created.Data.ID).Error)
+
+	req = testutil.NewGETRequest(t)
+	testutil.SetPathParam(req, "token", export.DownloadToken)
+	require.NoError(t, app.DownloadAnalyticsExport(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	body := string(req.RequestCtx.Response.Body())
+	assert.Contains(t, body, "Export Contact")
+	assert.Contains(t, body, "'=1+1, a formula")
+
+	// Other users can't see the export
+	other := createTestUser(t, app, org.ID, uniqueEmail("export-other"), "password", &role.ID, true)
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, other.ID)
+	testutil.SetPathParam(req, "id", created.Data.ID.String())
+	require.NoError(t, app.GetAnalyticsExport(req))
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+
+	// Expired links are refused
+	require.NoError(t, app.DB.Model(&export).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	req = testutil.NewGETRequest(t)
+	testutil.SetPathParam(req, "token", export.DownloadToken)
+	require.NoError(t, app.DownloadAnalyticsExport(req))
+	assert.Equal(t, fasthttp.StatusGone, testutil.GetResponseStatusCode(req))
+}
+
+func TestApp_AnalyticsExport_Validation(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("export-invalid"), "password", &role.ID, true)
+
+	tests := map[string]map[string]interface{}{
+		"unknown report": {"report": "invoices", "from": "2024-01-01", "to": "2024-01-31"},
+		"unknown format": {"report": "messages", "format": "pdf", "from": "2024-01-01", "to": "2024-01-31"},
+		"bad date":       {"report": "messages", "from": "01/01/2024", "to": "2024-01-31"},
+		"reversed range": {"report": "messages", "from": "2024-02-01", "to": "2024-01-31"},
+		"range too long": {"report": "messages", "from": "2022-01-01", "to": "2024-01-31"},
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := testutil.NewJSONRequest(t, body)
+			setAuthContext(req, org.ID, user.ID)
+			require.NoError(t, app.CreateAnalyticsExport(req))
+			assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+		})
+	}
+
+	// Requires analytics:read
+	chatRole := createTransferTestRole(t, app.DB, org.ID, "export-chat", []string{"chat:read"})
+	agent := createTestUser(t, app, org.ID, uniqueEmail("export-agent"), "password", &chatRole.ID, true)
+	req := testutil.NewJSONRequest(t, map[string]interface{}{"report": "messages", "from": "2024-01-01", "to": "2024-01-31"})
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.CreateAnalyticsExport(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
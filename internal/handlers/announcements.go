@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+// announcementFeedLimit caps how many entries the feed returns
+const announcementFeedLimit = 50
+
+// AnnouncementRequest represents the request body for creating/updating an announcement
+type AnnouncementRequest struct {
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	Type     string     `json:"type"`
+	Category string     `json:"category"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	Global   bool       `json:"global"` // Super admins only: show to every organization
+}
+
+// AnnouncementResponse represents an announcement as seen by the current user
+type AnnouncementResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Type        string     `json:"type"`
+	Category    string     `json:"category"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Global      bool       `json:"global"`
+	IsRead      bool       `json:"is_read"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// announcementRow is an announcement joined with the current user's read receipt
+type announcementRow struct {
+	models.Announcement
+	ReadAt *time.Time
+}
+
+// ListAnnouncements returns the current user's announcement feed: live banners and
+// changelog entries, newest first, with read state
+func (a *App) ListAnnouncements(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	unreadOnly := string(r.RequestCtx.QueryArgs().Peek("unread")) == "true"
+
+	now := time.Now()
+	query := a.DB.Model(&models.Announcement{}).
+		Select("announcements.*, announcement_reads.read_at").
+		Joins("LEFT JOIN announcement_reads ON announcement_reads.announcement_id = announcements.id AND announcement_reads.user_id = ?", userID).
+		Where("announcements.organization_id = ? OR announcements.organization_id IS NULL", orgID).
+		Where("announcements.starts_at IS NULL OR announcements.starts_at <= ?", now).
+		// Banners disappear when they end; changelog entries stay in the feed
+		Where("announcements.type = ? OR announcements.ends_at IS NULL OR announcements.ends_at > ?", models.AnnouncementTypeChangelog, now)
+	if unreadOnly {
+		query = query.Where("announcement_reads.read_at IS NULL")
+	}
+
+	var rows []announcementRow
+	if err := query.Order("COALESCE(announcements.starts_at, announcements.created_at) DESC").
+		Limit(announcementFeedLimit).Scan(&rows).Error; err != nil {
+		a.Log.Error("Failed to list announcements", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list announcements", nil, "")
+	}
+
+	result := make([]AnnouncementResponse, len(rows))
+	unread := 0
+	for i, row := range rows {
+		result[i] = announcementToResponse(row.Announcement, row.ReadAt != nil)
+		if row.ReadAt == nil {
+			unread++
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"announcements": result,
+		"unread_count":  unread,
+	})
+}
+
+// MarkAnnouncementRead records that the current user has read or dismissed an announcement
+func (a *App) MarkAnnouncementRead(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	announcementID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid announcement ID", nil, "")
+	}
+
+	var count int64
+	a.DB.Model(&models.Announcement{}).
+		Where("id = ? AND (organization_id = ? OR organization_id IS NULL)", announcementID, orgID).
+		Count(&count)
+	if count == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Announcement not found", nil, "")
+	}
+
+	if err := a.markAnnouncementsRead(userID, []uuid.UUID{announcementID}); err != nil {
+		a.Log.Error("Failed to mark announcement read", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to mark announcement read", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Announcement marked as read"})
+}
+
+// MarkAllAnnouncementsRead marks every visible announcement as read for the current user
+func (a *App) MarkAllAnnouncementsRead(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var ids []uuid.UUID
+	if err := a.DB.Model(&models.Announcement{}).
+		Where("organization_id = ? OR organization_id IS NULL", orgID).
+		Where("starts_at IS NULL OR starts_at <= ?", time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		a.Log.Error("Failed to list announcements", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to mark announcements read", nil, "")
+	}
+
+	if err := a.markAnnouncementsRead(userID, ids); err != nil {
+		a.Log.Error("Failed to mark announcements read", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to mark announcements read", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{"message": "Announcements marked as read", "count": len(ids)})
+}
+
+// ListManagedAnnouncements returns every announcement an admin can see, including
+// scheduled and expired ones
+func (a *App) ListManagedAnnouncements(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var announcements []models.Announcement
+	if err := a.DB.Where("organization_id = ? OR organization_id IS NULL", orgID).
+		Order("created_at DESC").Find(&announcements).Error; err != nil {
+		a.Log.Error("Failed to list announcements", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list announcements", nil, "")
+	}
+
+	result := make([]AnnouncementResponse, len(announcements))
+	for i, ann := range announcements {
+		result[i] = announcementToResponse(ann, false)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"announcements":     result,
+		"can_manage_global": a.IsSuperAdmin(userID),
+	})
+}
+
+// CreateAnnouncement creates an announcement and pushes it to connected users once it starts
+func (a *App) CreateAnnouncement(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req AnnouncementRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Global && !a.IsSuperAdmin(userID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only super admins can create global announcements", nil, "")
+	}
+
+	announcement := models.Announcement{CreatedByID: &userID}
+	if !req.Global {
+		announcement.OrganizationID = &orgID
+	}
+	if msg := applyAnnouncementRequest(&announcement, req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Create(&announcement).Error; err != nil {
+		a.Log.Error("Failed to create announcement", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create announcement", nil, "")
+	}
+
+	a.publishAnnouncement(&announcement)
+
+	return r.SendEnvelope(announcementToResponse(announcement, false))
+}
+
+// UpdateAnnouncement updates an announcement; already published ones are re-pushed
+func (a *App) UpdateAnnouncement(r *fastglue.Request) error {
+	announcement, err := a.loadManagedAnnouncement(r)
+	if err != nil || announcement == nil {
+		return err
+	}
+
+	var req AnnouncementRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := applyAnnouncementRequest(announcement, req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	// Moving the start into the future un-publishes it until then
+	if announcement.StartsAt != nil && announcement.StartsAt.After(time.Now()) {
+		announcement.PublishedAt = nil
+	}
+
+	if err := a.DB.Save(announcement).Error; err != nil {
+		a.Log.Error("Failed to update announcement", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update announcement", nil, "")
+	}
+
+	if announcement.PublishedAt != nil {
+		a.broadcastAnnouncement(websocket.TypeAnnouncement, *announcement)
+	} else {
+		a.publishAnnouncement(announcement)
+	}
+
+	return r.SendEnvelope(announcementToResponse(*announcement, false))
+}
+
+// DeleteAnnouncement removes an announcement and clears it from connected clients
+func (a *App) DeleteAnnouncement(r *fastglue.Request) error {
+	announcement, err := a.loadManagedAnnouncement(r)
+	if err != nil || announcement == nil {
+		return err
+	}
+
+	if err := a.DB.Delete(announcement).Error; err != nil {
+		a.Log.Error("Failed to delete announcement", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete announcement", nil, "")
+	}
+	a.DB.Where("announcement_id = ?", announcement.ID).Delete(&models.AnnouncementRead{})
+
+	if announcement.PublishedAt != nil {
+		a.broadcastAnnouncement(websocket.TypeAnnouncementDeleted, *announcement)
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Announcement deleted successfully"})
+}
+
+// loadManagedAnnouncement checks write permission and loads the announcement from the path.
+// Global announcements can only be managed by super admins. On failure it sends the error
+// response and returns a nil announcement.
+func (a *App) loadManagedAnnouncement(r *fastglue.Request) (*models.Announcement, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	announcementID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid announcement ID", nil, "")
+	}
+
+	var announcement models.Announcement
+	if err := a.DB.Where("id = ? AND (organization_id = ? OR organization_id IS NULL)", announcementID, orgID).
+		First(&announcement).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Announcement not found", nil, "")
+	}
+	if announcement.OrganizationID == nil && !a.IsSuperAdmin(userID) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only super admins can manage global announcements", nil, "")
+	}
+	return &announcement, nil
+}
+
+// applyAnnouncementRequest validates req and copies it onto announcement. It returns a
+// user-facing error message, or "" if valid.
+func applyAnnouncementRequest(announcement *models.Announcement, req AnnouncementRequest) string {
+	if req.Title == "" {
+		return "title is required"
+	}
+	if req.Type == "" {
+		req.Type = string(models.AnnouncementTypeChangelog)
+	}
+	switch models.AnnouncementType(req.Type) {
+	case models.AnnouncementTypeBanner, models.AnnouncementTypeChangelog:
+	default:
+		return "type must be banner or changelog"
+	}
+	if req.Category == "" {
+		req.Category = string(models.AnnouncementCategoryInfo)
+	}
+	switch models.AnnouncementCategory(req.Category) {
+	case models.AnnouncementCategoryInfo, models.AnnouncementCategoryMaintenance, models.AnnouncementCategoryFeature:
+	default:
+		return "category must be info, maintenance or feature"
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return "ends_at must be after starts_at"
+	}
+
+	announcement.Title = req.Title
+	announcement.Body = req.Body
+	announcement.Type = models.AnnouncementType(req.Type)
+	announcement.Category = models.AnnouncementCategory(req.Category)
+	announcement.StartsAt = req.StartsAt
+	announcement.EndsAt = req.EndsAt
+	return ""
+}
+
+func (a *App) markAnnouncementsRead(userID uuid.UUID, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	now := time.Now()
+	reads := make([]models.AnnouncementRead, len(ids))
+	for i, id := range ids {
+		reads[i] = models.AnnouncementRead{AnnouncementID: id, UserID: userID, ReadAt: now}
+	}
+	return a.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&reads).Error
+}
+
+// publishAnnouncement pushes an announcement to connected users if it has started and
+// hasn't been pushed yet
+func (a *App) publishAnnouncement(announcement *models.Announcement) {
+	now := time.Now()
+	if announcement.StartsAt != nil && announcement.StartsAt.After(now) {
+		return
+	}
+	result := a.DB.Model(&models.Announcement{}).
+		Where("id = ? AND published_at IS NULL", announcement.ID).
+		Update("published_at", now)
+	if result.Error != nil {
+		a.Log.Error("Failed to publish announcement", "error", result.Error, "announcement_id", announcement.ID)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	announcement.PublishedAt = &now
+	a.broadcastAnnouncement(websocket.TypeAnnouncement, *announcement)
+}
+
+// publishDueAnnouncements pushes scheduled announcements whose start time has passed.
+// Claiming them with a single UPDATE means only one instance pushes each.
+func (a *App) publishDueAnnouncements() {
+	now := time.Now()
+	var due []models.Announcement
+	if err := a.DB.Model(&due).Clauses(clause.Returning{}).
+		Where("published_at IS NULL AND (starts_at IS NULL OR starts_at <= ?)", now).
+		Update("published_at", now).Error; err != nil {
+		a.Log.Error("Failed to publish scheduled announcements", "error", err)
+		return
+	}
+	for _, ann := range due {
+		a.broadcastAnnouncement(websocket.TypeAnnouncement, ann)
+	}
+}
+
+// broadcastAnnouncement sends an announcement event to its organization, or to every
+// organization for global announcements
+func (a *App) broadcastAnnouncement(msgType string, announcement models.Announcement) {
+	if a.WSHub == nil {
+		return
+	}
+
+	var orgIDs []uuid.UUID
+	if announcement.OrganizationID != nil {
+		orgIDs = []uuid.UUID{*announcement.OrganizationID}
+	} else if err := a.DB.Model(&models.Organization{}).Pluck("id", &orgIDs).Error; err != nil {
+		a.Log.Error("Failed to list organizations for announcement", "error", err)
+		return
+	}
+
+	msg := websocket.WSMessage{Type: msgType, Payload: announcementToResponse(announcement, false)}
+	for _, id := range orgIDs {
+		a.WSHub.BroadcastToOrg(id, msg)
+	}
+}
+
+func announcementToResponse(ann models.Announcement, isRead bool) AnnouncementResponse {
+	return AnnouncementResponse{
+		ID:          ann.ID,
+		Title:       ann.Title,
+		Body:        ann.Body,
+		Type:        string(ann.Type),
+		Category:    string(ann.Category),
+		StartsAt:    ann.StartsAt,
+		EndsAt:      ann.EndsAt,
+		PublishedAt: ann.PublishedAt,
+		Global:      ann.OrganizationID == nil,
+		IsRead:      isRead,
+		CreatedAt:   ann.CreatedAt,
+	}
+}
+
+// AnnouncementProcessor publishes scheduled announcements when they start
+type AnnouncementProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAnnouncementProcessor creates a new announcement processor
+func NewAnnouncementProcessor(app *App, interval time.Duration) *AnnouncementProcessor {
+	return &AnnouncementProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the publishing loop
+func (p *AnnouncementProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Announcement processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Announcement processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Announcement processor stopped")
+			return
+		case <-ticker.C:
+			p.app.publishDueAnnouncements()
+		}
+	}
+}
+
+// Stop stops the announcement processor
+func (p *AnnouncementProcessor) Stop() {
+	close(p.stopCh)
+}
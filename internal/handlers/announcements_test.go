@@ -0,0 +1,186 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// createAnnouncementAdmin creates a user whose role can manage organization settings
+func createAnnouncementAdmin(t *testing.T, app *handlers.App, orgID uuid.UUID) *models.User {
+	t.Helper()
+
+	var perm models.Permission
+	err := app.DB.Where("resource = ? AND action = ?", models.ResourceSettingsGeneral, models.ActionWrite).First(&perm).Error
+	if err != nil {
+		perm = models.Permission{
+			BaseModel:   models.BaseModel{ID: uuid.New()},
+			Resource:    models.ResourceSettingsGeneral,
+			Action:      models.ActionWrite,
+			Description: "Edit general settings",
+		}
+		require.NoError(t, app.DB.Create(&perm).Error)
+	}
+
+	role := createTestRole(t, app, orgID, "Announcer "+uuid.New().String()[:8], false, false, []models.Permission{perm})
+	return createTestUser(t, app, orgID, uniqueEmail("announcer"), "password123", &role.ID, true)
+}
+
+func createAnnouncement(t *testing.T, app *handlers.App, user *models.User, body map[string]any) handlers.AnnouncementResponse {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, body)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	req.RequestCtx.SetUserValue("organization_id", user.OrganizationID)
+	require.NoError(t, app.CreateAnnouncement(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(testutil.GetResponseBody(req)))
+
+	var resp struct {
+		Data handlers.AnnouncementResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+	return resp.Data
+}
+
+type announcementFeed struct {
+	Announcements []handlers.AnnouncementResponse `json:"announcements"`
+	UnreadCount   int                             `json:"unread_count"`
+}
+
+func listAnnouncements(t *testing.T, app *handlers.App, user *models.User) announcementFeed {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	req.RequestCtx.SetUserValue("organization_id", user.OrganizationID)
+	require.NoError(t, app.ListAnnouncements(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data announcementFeed `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+	return resp.Data
+}
+
+func TestApp_Announcements_FeedAndReadTracking(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("announce-agent"), "password123", nil, true)
+
+	published := createAnnouncement(t, app, admin, map[string]any{
+		"title":    "Maintenance tonight",
+		"type":     "banner",
+		"category": "maintenance",
+	})
+	assert.NotNil(t, published.PublishedAt)
+
+	scheduled := createAnnouncement(t, app, admin, map[string]any{
+		"title":     "Coming soon",
+		"starts_at": time.Now().Add(time.Hour),
+	})
+	assert.Nil(t, scheduled.PublishedAt)
+
+	feed := listAnnouncements(t, app, agent)
+	require.Len(t, feed.Announcements, 1)
+	assert.Equal(t, published.ID, feed.Announcements[0].ID)
+	assert.False(t, feed.Announcements[0].IsRead)
+	assert.Equal(t, 1, feed.UnreadCount)
+
+	req := testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.SetUserValue("user_id", agent.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	req.RequestCtx.SetUserValue("id", published.ID.String())
+	require.NoError(t, app.MarkAnnouncementRead(req))
+	assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	feed = listAnnouncements(t, app, agent)
+	require.Len(t, feed.Announcements, 1)
+	assert.True(t, feed.Announcements[0].IsRead)
+	assert.Equal(t, 0, feed.UnreadCount)
+
+	// Read state is per user
+	feed = listAnnouncements(t, app, admin)
+	assert.Equal(t, 1, feed.UnreadCount)
+}
+
+func TestApp_Announcements_OtherOrganizationHidden(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	otherOrg := createTestOrganization(t, app)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+	outsider := createTestUser(t, app, otherOrg.ID, uniqueEmail("announce-outsider"), "password123", nil, true)
+
+	ann := createAnnouncement(t, app, admin, map[string]any{"title": "Org only"})
+
+	feed := listAnnouncements(t, app, outsider)
+	assert.Empty(t, feed.Announcements)
+
+	req := testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.SetUserValue("user_id", outsider.ID)
+	req.RequestCtx.SetUserValue("organization_id", otherOrg.ID)
+	req.RequestCtx.SetUserValue("id", ann.ID.String())
+	require.NoError(t, app.MarkAnnouncementRead(req))
+	assertErrorResponse(t, req, fasthttp.StatusNotFound, "Announcement not found")
+}
+
+func TestApp_CreateAnnouncement_RequiresPermission(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("announce-noperm"), "password123", nil, true)
+
+	req := testutil.NewJSONRequest(t, map[string]any{"title": "Hello"})
+	req.RequestCtx.SetUserValue("user_id", agent.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.CreateAnnouncement(req))
+	assertErrorResponse(t, req, fasthttp.StatusForbidden, "Insufficient permissions")
+}
+
+func TestApp_CreateAnnouncement_GlobalRequiresSuperAdmin(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+
+	req := testutil.NewJSONRequest(t, map[string]any{"title": "Everyone", "global": true})
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.CreateAnnouncement(req))
+	assertErrorResponse(t, req, fasthttp.StatusForbidden, "Only super admins")
+}
+
+func TestApp_CreateAnnouncement_Validation(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		body    map[string]any
+		message string
+	}{
+		{"missing title", map[string]any{"body": "x"}, "title is required"},
+		{"bad type", map[string]any{"title": "x", "type": "popup"}, "type must be banner or changelog"},
+		{"bad category", map[string]any{"title": "x", "category": "urgent"}, "category must be"},
+		{"ends before start", map[string]any{"title": "x", "starts_at": now, "ends_at": now.Add(-time.Hour)}, "ends_at must be after starts_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testutil.NewJSONRequest(t, tt.body)
+			req.RequestCtx.SetUserValue("user_id", admin.ID)
+			req.RequestCtx.SetUserValue("organization_id", org.ID)
+			require.NoError(t, app.CreateAnnouncement(req))
+			assertErrorResponse(t, req, fasthttp.StatusBadRequest, tt.message)
+		})
+	}
+}
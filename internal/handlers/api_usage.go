@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiUsagePrefix prefixes the daily request counters of an organization
+	apiUsagePrefix = "api:usage:"
+
+	// apiQuotaPrefix prefixes the daily request count of an API key with a quota
+	apiQuotaPrefix = "api:quota:"
+
+	// apiUsageRetention keeps daily counters long enough to be rolled up after the day ends
+	apiUsageRetention = 3 * 24 * time.Hour
+
+	apiUsageDayFormat   = "2006-01-02"
+	apiUsageDefaultDays = 7
+	apiUsageMaxDays     = 90
+	apiUsageTopN        = 50
+)
+
+// APIUsageByKey is the usage of one API key
+type APIUsageByKey struct {
+	APIKeyID      uuid.UUID `json:"api_key_id"`
+	Name          string    `json:"name"`
+	KeyPrefix     string    `json:"key_prefix"`
+	DailyQuota    int       `json:"daily_quota"`
+	Deleted       bool      `json:"deleted"`
+	Requests      int64     `json:"requests"`
+	Rejected      int64     `json:"rejected"`
+	RequestsToday int64     `json:"requests_today"`
+	TopEndpoint   string    `json:"top_endpoint"`
+}
+
+// APIUsageByUser is the usage of one user, with their login sessions and their API keys
+type APIUsageByUser struct {
+	UserID          uuid.UUID `json:"user_id"`
+	FullName        string    `json:"full_name"`
+	Email           string    `json:"email"`
+	Requests        int64     `json:"requests"`
+	SessionRequests int64     `json:"session_requests"` // Made from the app rather than with an API key
+}
+
+// APIUsageByEndpoint is the usage of one endpoint
+type APIUsageByEndpoint struct {
+	Endpoint string `json:"endpoint"`
+	Requests int64  `json:"requests"`
+	Rejected int64  `json:"rejected"`
+}
+
+// APIUsageDay is one day of an organization's API usage
+type APIUsageDay struct {
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+	Rejected int64  `json:"rejected"`
+}
+
+// APIUsageResponse is an organization's API usage over a number of days
+type APIUsageResponse struct {
+	Days          int                  `json:"days"`
+	From          string               `json:"from"`
+	To            string               `json:"to"`
+	TotalRequests int64                `json:"total_requests"`
+	TotalRejected int64                `json:"total_rejected"`
+	APIKeys       []APIUsageByKey      `json:"api_keys"`  // Busiest first
+	Users         []APIUsageByUser     `json:"users"`     // Busiest first
+	Endpoints     []APIUsageByEndpoint `json:"endpoints"` // Busiest first, at most 50
+	Daily         []APIUsageDay        `json:"daily"`     // Oldest first
+}
+
+// GetAPIUsage returns the organization's API requests per API key, user, endpoint and
+// day. days is the window in UTC days, 7 by default and at most 90.
+func (a *App) GetAPIUsage(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAPIKeys, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	days := apiUsageDefaultDays
+	if v := string(r.RequestCtx.QueryArgs().Peek("days")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > apiUsageMaxDays {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", apiUsageMaxDays), nil, "")
+		}
+		days = n
+	}
+
+	today := apiUsageDay(time.Now())
+	from := today.AddDate(0, 0, -(days - 1))
+
+	// Earlier days come from the rollups, today from the live counters when available
+	query := a.DB.Where("organization_id = ? AND day >= ?", orgID, from)
+	var live []models.APIUsageDaily
+	if a.Redis != nil {
+		if live, err = a.liveAPIUsage(r.RequestCtx, orgID, today); err != nil {
+			a.Log.Error("Failed to read live API usage", "error", err, "organization_id", orgID)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load API usage", nil, "")
+		}
+		query = query.Where("day < ?", today)
+	}
+	var rows []models.APIUsageDaily
+	if err := query.Find(&rows).Error; err != nil {
+		a.Log.Error("Failed to load API usage", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load API usage", nil, "")
+	}
+	rows = append(rows, live...)
+
+	resp := summarizeAPIUsage(rows, from, today)
+	resp.Days = days
+	a.labelAPIUsage(orgID, &resp)
+	return r.SendEnvelope(resp)
+}
+
+// TrackAPIUsage counts an authenticated API request for the day and reports whether it
+// is within its API key's daily quota. Requests are let through when Redis is unavailable.
+func (a *App) TrackAPIUsage(usage middleware.APIUsage) bool {
+	if a.Redis == nil {
+		return true
+	}
+
+	ctx := context.Background()
+	day := apiUsageDay(time.Now()).Format(apiUsageDayFormat)
+	counterKey := apiUsageKey(day, usage.OrganizationID)
+
+	allowed := true
+	if usage.APIKeyID != nil && usage.DailyQuota > 0 {
+		quotaKey := apiQuotaPrefix + usage.APIKeyID.String() + ":" + day
+		pipe := a.Redis.Pipeline()
+		count := pipe.Incr(ctx, quotaKey)
+		pipe.Expire(ctx, quotaKey, 2*24*time.Hour)
+		if _, err := pipe.Exec(ctx); err != nil {
+			a.Log.Warn("Failed to check API quota", "error", err, "api_key_id", *usage.APIKeyID)
+		} else if count.Val() > int64(usage.DailyQuota) {
+			allowed = false
+			counterKey += ":rejected"
+		}
+	}
+
+	orgsKey := apiUsageOrgsKey(day)
+	pipe := a.Redis.Pipeline()
+	pipe.HIncrBy(ctx, counterKey, apiUsageField(usage.UserID, usage.APIKeyID, apiUsageEndpoint(usage.Method, usage.Path)), 1)
+	pipe.Expire(ctx, counterKey, apiUsageRetention)
+	pipe.SAdd(ctx, orgsKey, usage.OrganizationID.String())
+	pipe.Expire(ctx, orgsKey, apiUsageRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		a.Log.Warn("Failed to record API usage", "error", err, "organization_id", usage.OrganizationID)
+	}
+	return allowed
+}
+
+// liveAPIUsage reads an organization's counters for a day from Redis
+func (a *App) liveAPIUsage(ctx context.Context, orgID uuid.UUID, day time.Time) ([]models.APIUsageDaily, error) {
+	key := apiUsageKey(day.Format(apiUsageDayFormat), orgID)
+	pipe := a.Redis.Pipeline()
+	requests := pipe.HGetAll(ctx, key)
+	rejected := pipe.HGetAll(ctx, key+":rejected")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return apiUsageRows(orgID, day, requests.Val(), rejected.Val()), nil
+}
+
+// rollupAPIUsage copies the day's Redis counters of every organization that used the
+// API to api_usage_daily, replacing what an earlier rollup stored for the day
+func (a *App) rollupAPIUsage(ctx context.Context, day time.Time) error {
+	day = apiUsageDay(day)
+	orgIDs, err := a.Redis.SMembers(ctx, apiUsageOrgsKey(day.Format(apiUsageDayFormat))).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range orgIDs {
+		orgID, err := uuid.Parse(id)
+		if err != nil {
+			continue
+		}
+		rows, err := a.liveAPIUsage(ctx, orgID, day)
+		if err != nil {
+			return err
+		}
+		err = a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("organization_id = ? AND day = ?", orgID, day).Delete(&models.APIUsageDaily{}).Error; err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			return tx.CreateInBatches(&rows, 500).Error
+		})
+		if err != nil {
+			return fmt.Errorf("organization %s: %w", orgID, err)
+		}
+	}
+	return nil
+}
+
+// labelAPIUsage fills in the names of the API keys and users in a usage summary
+func (a *App) labelAPIUsage(orgID uuid.UUID, resp *APIUsageResponse) {
+	if len(resp.APIKeys) > 0 {
+		ids := make([]uuid.UUID, len(resp.APIKeys))
+		for i, k := range resp.APIKeys {
+			ids[i] = k.APIKeyID
+		}
+		var keys []models.APIKey
+		a.DB.Unscoped().Where("organization_id = ? AND id IN ?", orgID, ids).Find(&keys)
+		byID := make(map[uuid.UUID]models.APIKey, len(keys))
+		for _, k := range keys {
+			byID[k.ID] = k
+		}
+		for i := range resp.APIKeys {
+			if k, ok := byID[resp.APIKeys[i].APIKeyID]; ok {
+				resp.APIKeys[i].Name = k.Name
+				resp.APIKeys[i].KeyPrefix = k.KeyPrefix
+				resp.APIKeys[i].DailyQuota = k.DailyQuota
+				resp.APIKeys[i].Deleted = k.DeletedAt.Valid
+			} else {
+				resp.APIKeys[i].Deleted = true
+			}
+		}
+	}
+
+	if len(resp.Users) > 0 {
+		ids := make([]uuid.UUID, len(resp.Users))
+		for i, u := range resp.Users {
+			ids[i] = u.UserID
+		}
+		var users []models.User
+		a.DB.Unscoped().Where("id IN ?", ids).Find(&users)
+		byID := make(map[uuid.UUID]models.User, len(users))
+		for _, u := range users {
+			byID[u.ID] = u
+		}
+		for i := range resp.Users {
+			if u, ok := byID[resp.Users[i].UserID]; ok {
+				resp.Users[i].FullName = u.FullName
+				resp.Users[i].Email = u.Email
+			}
+		}
+	}
+}
+
+// summarizeAPIUsage totals usage rows per API key, user, endpoint and day
+func summarizeAPIUsage(rows []models.APIUsageDaily, from, today time.Time) APIUsageResponse {
+	resp := APIUsageResponse{
+		From:      from.Format(apiUsageDayFormat),
+		To:        today.Format(apiUsageDayFormat),
+		APIKeys:   []APIUsageByKey{},
+		Users:     []APIUsageByUser{},
+		Endpoints: []APIUsageByEndpoint{},
+	}
+
+	keys := map[uuid.UUID]*APIUsageByKey{}
+	keyEndpoints := map[uuid.UUID]map[string]int64{}
+	users := map[uuid.UUID]*APIUsageByUser{}
+	endpoints := map[string]*APIUsageByEndpoint{}
+	daily := map[string]*APIUsageDay{}
+	for d := from; !d.After(today); d = d.AddDate(0, 0, 1) {
+		day := d.Format(apiUsageDayFormat)
+		daily[day] = &APIUsageDay{Day: day}
+		resp.Daily = append(resp.Daily, APIUsageDay{Day: day})
+	}
+
+	for _, row := range rows {
+		resp.TotalRequests += row.Requests
+		resp.TotalRejected += row.Rejected
+
+		if d, ok := daily[apiUsageDay(row.Day).Format(apiUsageDayFormat)]; ok {
+			d.Requests += row.Requests
+			d.Rejected += row.Rejected
+		}
+
+		e := endpoints[row.Endpoint]
+		if e == nil {
+			e = &APIUsageByEndpoint{Endpoint: row.Endpoint}
+			endpoints[row.Endpoint] = e
+		}
+		e.Requests += row.Requests
+		e.Rejected += row.Rejected
+
+		u := users[row.UserID]
+		if u == nil {
+			u = &APIUsageByUser{UserID: row.UserID}
+			users[row.UserID] = u
+		}
+		u.Requests += row.Requests
+
+		if row.APIKeyID == nil {
+			u.SessionRequests += row.Requests
+			continue
+		}
+		k := keys[*row.APIKeyID]
+		if k == nil {
+			k = &APIUsageByKey{APIKeyID: *row.APIKeyID}
+			keys[*row.APIKeyID] = k
+			keyEndpoints[*row.APIKeyID] = map[string]int64{}
+		}
+		k.Requests += row.Requests
+		k.Rejected += row.Rejected
+		if apiUsageDay(row.Day).Equal(today) {
+			k.RequestsToday += row.Requests
+		}
+		keyEndpoints[*row.APIKeyID][row.Endpoint] += row.Requests
+	}
+
+	for i := range resp.Daily {
+		resp.Daily[i] = *daily[resp.Daily[i].Day]
+	}
+	for id, k := range keys {
+		var top int64
+		for endpoint, n := range keyEndpoints[id] {
+			if n > top || (n == top && endpoint < k.TopEndpoint) {
+				top, k.TopEndpoint = n, endpoint
+			}
+		}
+		resp.APIKeys = append(resp.APIKeys, *k)
+	}
+	for _, u := range users {
+		resp.Users = append(resp.Users, *u)
+	}
+	for _, e := range endpoints {
+		resp.Endpoints = append(resp.Endpoints, *e)
+	}
+
+	sort.Slice(resp.APIKeys, func(i, j int) bool {
+		if resp.APIKeys[i].Requests != resp.APIKeys[j].Requests {
+			return resp.APIKeys[i].Requests > resp.APIKeys[j].Requests
+		}
+		return resp.APIKeys[i].APIKeyID.String() < resp.APIKeys[j].APIKeyID.String()
+	})
+	sort.Slice(resp.Users, func(i, j int) bool {
+		if resp.Users[i].Requests != resp.Users[j].Requests {
+			return resp.Users[i].Requests > resp.Users[j].Requests
+		}
+		return resp.Users[i].UserID.String() < resp.Users[j].UserID.String()
+	})
+	sort.Slice(resp.Endpoints, func(i, j int) bool {
+		if resp.Endpoints[i].Requests != resp.Endpoints[j].Requests {
+			return resp.Endpoints[i].Requests > resp.Endpoints[j].Requests
+		}
+		return resp.Endpoints[i].Endpoint < resp.Endpoints[j].Endpoint
+	})
+	if len(resp.Endpoints) > apiUsageTopN {
+		resp.Endpoints = resp.Endpoints[:apiUsageTopN]
+	}
+	return resp
+}
+
+// apiUsageRows turns a day's Redis counters into usage rows
+func apiUsageRows(orgID uuid.UUID, day time.Time, requests, rejected map[string]string) []models.APIUsageDaily {
+	byField := map[string]*models.APIUsageDaily{}
+	add := func(counters map[string]string, isRejected bool) {
+		for field, v := range counters {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			row := byField[field]
+			if row == nil {
+				userID, apiKeyID, endpoint, ok := parseAPIUsageField(field)
+				if !ok {
+					continue
+				}
+				row = &models.APIUsageDaily{
+					OrganizationID: orgID,
+					Day:            day,
+					APIKeyID:       apiKeyID,
+					UserID:         userID,
+					Endpoint:       endpoint,
+				}
+				byField[field] = row
+			}
+			if isRejected {
+				row.Rejected += n
+			} else {
+				row.Requests += n
+			}
+		}
+	}
+	add(requests, false)
+	add(rejected, true)
+
+	rows := make([]models.APIUsageDaily, 0, len(byField))
+	for _, row := range byField {
+		rows = append(rows, *row)
+	}
+	return rows
+}
+
+// apiUsageEndpoint names an endpoint by its method and path, with IDs replaced so that
+// requests for different records count together
+func apiUsageEndpoint(method, path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if isAPIUsageID(s) {
+			segments[i] = "{id}"
+		}
+	}
+	endpoint := method + " " + strings.Join(segments, "/")
+	if len(endpoint) > 255 {
+		endpoint = endpoint[:255]
+	}
+	return endpoint
+}
+
+// isAPIUsageID reports whether a path segment looks like a record ID or token
+func isAPIUsageID(s string) bool {
+	if s == "" {
+		return false
+	}
+	if _, err := uuid.Parse(s); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return true
+	}
+	return len(s) >= 24 && !strings.ContainsAny(s, "-_.")
+}
+
+// apiUsageField identifies who called an endpoint: "<user id>|<api key id or ->|<endpoint>"
+func apiUsageField(userID uuid.UUID, apiKeyID *uuid.UUID, endpoint string) string {
+	key := "-"
+	if apiKeyID != nil {
+		key = apiKeyID.String()
+	}
+	return userID.String() + "|" + key + "|" + endpoint
+}
+
+func parseAPIUsageField(field string) (userID uuid.UUID, apiKeyID *uuid.UUID, endpoint string, ok bool) {
+	parts := strings.SplitN(field, "|", 3)
+	if len(parts) != 3 {
+		return uuid.Nil, nil, "", false
+	}
+	userID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, nil, "", false
+	}
+	if parts[1] != "-" {
+		id, err := uuid.Parse(parts[1])
+		if err != nil {
+			return uuid.Nil, nil, "", false
+		}
+		apiKeyID = &id
+	}
+	return userID, apiKeyID, parts[2], true
+}
+
+// apiUsageDay is the UTC day that t falls on
+func apiUsageDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func apiUsageKey(day string, orgID uuid.UUID) string {
+	return apiUsagePrefix + day + ":" + orgID.String()
+}
+
+// apiUsageOrgsKey holds the organizations that made API requests on a day
+func apiUsageOrgsKey(day string) string {
+	return apiUsagePrefix + day + ":orgs"
+}
+
+// APIUsageRollupProcessor copies the API usage counters from Redis to Postgres
+type APIUsageRollupProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAPIUsageRollupProcessor creates a new API usage rollup processor
+func NewAPIUsageRollupProcessor(app *App, interval time.Duration) *APIUsageRollupProcessor {
+	return &APIUsageRollupProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the rollup loop. Each run rolls up today and yesterday, so the last
+// requests of a day are stored once it is over.
+func (p *APIUsageRollupProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("API usage rollup processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("API usage rollup processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("API usage rollup processor stopped")
+			return
+		case <-ticker.C:
+			if p.app.Redis == nil {
+				continue
+			}
+			now := time.Now()
+			for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+				if err := p.app.rollupAPIUsage(ctx, day); err != nil {
+					p.app.Log.Error("Failed to roll up API usage", "error", err, "day", apiUsageDay(day).Format(apiUsageDayFormat))
+				}
+			}
+		}
+	}
+}
+
+// Stop stops the API usage rollup processor
+func (p *APIUsageRollupProcessor) Stop() {
+	close(p.stopCh)
+}
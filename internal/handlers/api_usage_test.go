@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIUsageEndpoint(t *testing.T) {
+	id := uuid.NewString()
+
+	assert.Equal(t, "GET /api/contacts/{id}/messages", apiUsageEndpoint("GET", "/api/contacts/"+id+"/messages"))
+	assert.Equal(t, "GET /api/campaigns/{id}", apiUsageEndpoint("GET", "/api/campaigns/42"))
+	assert.Equal(t, "GET /api/public/dashboards/{id}", apiUsageEndpoint("GET", "/api/public/dashboards/9f8e7d6c5b4a39281706f5e4d3c2b1a0"))
+	assert.Equal(t, "PUT /api/me/notification-preferences", apiUsageEndpoint("PUT", "/api/me/notification-preferences"))
+}
+
+func TestAPIUsageField(t *testing.T) {
+	userID, keyID := uuid.New(), uuid.New()
+
+	gotUser, gotKey, endpoint, ok := parseAPIUsageField(apiUsageField(userID, &keyID, "POST /api/v1/send"))
+	require.True(t, ok)
+	assert.Equal(t, userID, gotUser)
+	require.NotNil(t, gotKey)
+	assert.Equal(t, keyID, *gotKey)
+	assert.Equal(t, "POST /api/v1/send", endpoint)
+
+	_, gotKey, _, ok = parseAPIUsageField(apiUsageField(userID, nil, "GET /api/contacts"))
+	require.True(t, ok)
+	assert.Nil(t, gotKey)
+
+	_, _, _, ok = parseAPIUsageField("not-a-field")
+	assert.False(t, ok)
+}
+
+func TestSummarizeAPIUsage(t *testing.T) {
+	orgID, userID, keyID := uuid.New(), uuid.New(), uuid.New()
+	today := apiUsageDay(time.Now())
+	yesterday := today.AddDate(0, 0, -1)
+
+	rows := apiUsageRows(orgID, today,
+		map[string]string{
+			apiUsageField(userID, &keyID, "POST /api/v1/send"): "30",
+			apiUsageField(userID, &keyID, "GET /api/contacts"): "5",
+			apiUsageField(userID, nil, "GET /api/contacts"):    "7",
+		},
+		map[string]string{
+			apiUsageField(userID, &keyID, "POST /api/v1/send"): "3",
+		})
+	rows = append(rows, models.APIUsageDaily{OrganizationID: orgID, Day: yesterday, APIKeyID: &keyID, UserID: userID, Endpoint: "GET /api/contacts", Requests: 10})
+
+	resp := summarizeAPIUsage(rows, yesterday, today)
+
+	assert.Equal(t, int64(52), resp.TotalRequests)
+	assert.Equal(t, int64(3), resp.TotalRejected)
+	require.Len(t, resp.Daily, 2)
+	assert.Equal(t, APIUsageDay{Day: yesterday.Format(apiUsageDayFormat), Requests: 10}, resp.Daily[0])
+	assert.Equal(t, int64(42), resp.Daily[1].Requests)
+
+	require.Len(t, resp.APIKeys, 1)
+	key := resp.APIKeys[0]
+	assert.Equal(t, int64(45), key.Requests)
+	assert.Equal(t, int64(35), key.RequestsToday)
+	assert.Equal(t, int64(3), key.Rejected)
+	assert.Equal(t, "POST /api/v1/send", key.TopEndpoint)
+
+	require.Len(t, resp.Users, 1)
+	assert.Equal(t, int64(52), resp.Users[0].Requests)
+	assert.Equal(t, int64(7), resp.Users[0].SessionRequests)
+
+	require.Len(t, resp.Endpoints, 2)
+	assert.Equal(t, "POST /api/v1/send", resp.Endpoints[0].Endpoint)
+	assert.Equal(t, APIUsageByEndpoint{Endpoint: "GET /api/contacts", Requests: 22}, resp.Endpoints[1])
+}
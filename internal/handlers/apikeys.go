@@ -14,29 +14,41 @@ import (
 
 // APIKeyRequest represents the request body for creating an API key
 type APIKeyRequest struct {
-	Name      string  `json:"name"`
-	ExpiresAt *string `json:"expires_at,omitempty"`
+	Name       string             `json:"name"`
+	ExpiresAt  *string            `json:"expires_at,omitempty"`
+	Scope      models.APIKeyScope `json:"scope,omitempty"`       // full (default) or transactional
+	DailyQuota *int               `json:"daily_quota,omitempty"` // Requests per UTC day; 0 or omitted is unlimited
+}
+
+// APIKeyUpdateRequest represents the request body for updating an API key
+type APIKeyUpdateRequest struct {
+	Name       *string `json:"name,omitempty"`
+	DailyQuota *int    `json:"daily_quota,omitempty"` // 0 removes the quota
 }
 
 // APIKeyResponse represents an API key in list responses
 type APIKeyResponse struct {
-	ID         uuid.UUID  `json:"id"`
-	Name       string     `json:"name"`
-	KeyPrefix  string     `json:"key_prefix"`
-	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
-	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
-	IsActive   bool       `json:"is_active"`
-	CreatedAt  string     `json:"created_at"`
+	ID         uuid.UUID          `json:"id"`
+	Name       string             `json:"name"`
+	KeyPrefix  string             `json:"key_prefix"`
+	LastUsedAt *time.Time         `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty"`
+	IsActive   bool               `json:"is_active"`
+	Scope      models.APIKeyScope `json:"scope"`
+	DailyQuota int                `json:"daily_quota"`
+	CreatedAt  string             `json:"created_at"`
 }
 
 // APIKeyCreateResponse includes the full key (only shown once)
 type APIKeyCreateResponse struct {
-	ID        uuid.UUID  `json:"id"`
-	Name      string     `json:"name"`
-	Key       string     `json:"key"` // Full key, only returned on create
-	KeyPrefix string     `json:"key_prefix"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt string     `json:"created_at"`
+	ID         uuid.UUID          `json:"id"`
+	Name       string             `json:"name"`
+	Key        string             `json:"key"` // Full key, only returned on create
+	KeyPrefix  string             `json:"key_prefix"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty"`
+	Scope      models.APIKeyScope `json:"scope"`
+	DailyQuota int                `json:"daily_quota"`
+	CreatedAt  string             `json:"created_at"`
 }
 
 // generateAPIKey generates a random API key with whm_ prefix
@@ -56,7 +68,7 @@ func (a *App) ListAPIKeys(r *fastglue.Request) error {
 	}
 
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceAPIKeys, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceAPIKeys, models.ActionRead) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -76,6 +88,8 @@ func (a *App) ListAPIKeys(r *fastglue.Request) error {
 			LastUsedAt: key.LastUsedAt,
 			ExpiresAt:  key.ExpiresAt,
 			IsActive:   key.IsActive,
+			Scope:      key.Scope,
+			DailyQuota: key.DailyQuota,
 			CreatedAt:  key.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		}
 	}
@@ -91,7 +105,7 @@ func (a *App) CreateAPIKey(r *fastglue.Request) error {
 	}
 
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceAPIKeys, models.ActionWrite) {
+	if !a.HasPermission(userID, orgID, models.ResourceAPIKeys, models.ActionWrite) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -105,6 +119,22 @@ func (a *App) CreateAPIKey(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
 	}
 
+	switch req.Scope {
+	case "":
+		req.Scope = models.APIKeyScopeFull
+	case models.APIKeyScopeFull, models.APIKeyScopeTransactional:
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid scope. Use full or transactional", nil, "")
+	}
+
+	dailyQuota := 0
+	if req.DailyQuota != nil {
+		if *req.DailyQuota < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "daily_quota can't be negative", nil, "")
+		}
+		dailyQuota = *req.DailyQuota
+	}
+
 	// Parse expiration date if provided
 	var expiresAt *time.Time
 	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
@@ -140,6 +170,8 @@ func (a *App) CreateAPIKey(r *fastglue.Request) error {
 		KeyHash:        string(hashedKey),
 		ExpiresAt:      expiresAt,
 		IsActive:       true,
+		Scope:          req.Scope,
+		DailyQuota:     dailyQuota,
 	}
 
 	if err := a.DB.Create(&apiKey).Error; err != nil {
@@ -149,12 +181,77 @@ func (a *App) CreateAPIKey(r *fastglue.Request) error {
 
 	// Return full key only on creation
 	return r.SendEnvelope(APIKeyCreateResponse{
-		ID:        apiKey.ID,
-		Name:      apiKey.Name,
-		Key:       fullKey, // This is the only time the full key is returned
-		KeyPrefix: apiKey.KeyPrefix,
-		ExpiresAt: apiKey.ExpiresAt,
-		CreatedAt: apiKey.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Key:        fullKey, // This is the only time the full key is returned
+		KeyPrefix:  apiKey.KeyPrefix,
+		ExpiresAt:  apiKey.ExpiresAt,
+		Scope:      apiKey.Scope,
+		DailyQuota: apiKey.DailyQuota,
+		CreatedAt:  apiKey.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// UpdateAPIKey renames an API key or changes its daily quota
+func (a *App) UpdateAPIKey(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAPIKeys, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	idStr := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid API key ID", nil, "")
+	}
+
+	var req APIKeyUpdateRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	var apiKey models.APIKey
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&apiKey).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "API key not found", nil, "")
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
+		}
+		updates["name"] = *req.Name
+		apiKey.Name = *req.Name
+	}
+	if req.DailyQuota != nil {
+		if *req.DailyQuota < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "daily_quota can't be negative", nil, "")
+		}
+		updates["daily_quota"] = *req.DailyQuota
+		apiKey.DailyQuota = *req.DailyQuota
+	}
+	if len(updates) > 0 {
+		if err := a.DB.Model(&apiKey).Updates(updates).Error; err != nil {
+			a.Log.Error("Failed to update API key", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update API key", nil, "")
+		}
+	}
+
+	return r.SendEnvelope(APIKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		KeyPrefix:  apiKey.KeyPrefix,
+		LastUsedAt: apiKey.LastUsedAt,
+		ExpiresAt:  apiKey.ExpiresAt,
+		IsActive:   apiKey.IsActive,
+		Scope:      apiKey.Scope,
+		DailyQuota: apiKey.DailyQuota,
+		CreatedAt:  apiKey.CreatedAt.Format("2006-01-02T15:04:05Z"),
 	})
 }
 
@@ -166,7 +263,7 @@ func (a *App) DeleteAPIKey(r *fastglue.Request) error {
 	}
 
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceAPIKeys, models.ActionDelete) {
+	if !a.HasPermission(userID, orgID, models.ResourceAPIKeys, models.ActionDelete) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
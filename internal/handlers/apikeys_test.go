@@ -0,0 +1,90 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_APIKeyQuotaAndUsage(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	// Create a key with a quota
+	req := testutil.NewJSONRequest(t, map[string]any{"name": "Shop sync", "daily_quota": 2})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.CreateAPIKey(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var created struct {
+		Data handlers.APIKeyCreateResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, 2, created.Data.DailyQuota)
+
+	update := func(body map[string]any) int {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", created.Data.ID.String())
+		require.NoError(t, app.UpdateAPIKey(req))
+		return testutil.GetResponseStatusCode(req)
+	}
+	assert.Equal(t, fasthttp.StatusBadRequest, update(map[string]any{"daily_quota": -1}))
+	require.Equal(t, fasthttp.StatusOK, update(map[string]any{"daily_quota": 3}))
+
+	var stored models.APIKey
+	require.NoError(t, app.DB.First(&stored, created.Data.ID).Error)
+	assert.Equal(t, 3, stored.DailyQuota)
+
+	getUsage := func() handlers.APIUsageResponse {
+		req := testutil.NewGETRequest(t)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		testutil.SetQueryParam(req, "days", "3")
+		require.NoError(t, app.GetAPIUsage(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp struct {
+			Data handlers.APIUsageResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data
+	}
+
+	keyID := created.Data.ID
+	usage := middleware.APIUsage{
+		OrganizationID: org.ID,
+		UserID:         admin.ID,
+		APIKeyID:       &keyID,
+		DailyQuota:     stored.DailyQuota,
+		Method:         "GET",
+		Path:           "/api/contacts/" + uuid.NewString(),
+	}
+
+	if app.Redis == nil {
+		assert.True(t, app.TrackAPIUsage(usage), "quotas aren't enforced without Redis")
+		assert.Len(t, getUsage().Daily, 3)
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, app.TrackAPIUsage(usage))
+	}
+	assert.False(t, app.TrackAPIUsage(usage), "the fourth request is over the quota")
+	assert.True(t, app.TrackAPIUsage(middleware.APIUsage{OrganizationID: org.ID, UserID: admin.ID, Method: "GET", Path: "/api/contacts"}))
+
+	resp := getUsage()
+	assert.Equal(t, int64(4), resp.TotalRequests)
+	assert.Equal(t, int64(1), resp.TotalRejected)
+	require.Len(t, resp.APIKeys, 1)
+	assert.Equal(t, "Shop sync", resp.APIKeys[0].Name)
+	assert.Equal(t, int64(3), resp.APIKeys[0].RequestsToday)
+	assert.Equal(t, "GET /api/contacts/{id}", resp.APIKeys[0].TopEndpoint)
+	require.Len(t, resp.Users, 1)
+	assert.Equal(t, int64(1), resp.Users[0].SessionRequests)
+}
@@ -3,14 +3,23 @@ package handlers
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/byok"
 	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/shridarpatil/whatomate/internal/database"
+	"github.com/shridarpatil/whatomate/internal/leader"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/plugins"
 	"github.com/shridarpatil/whatomate/internal/queue"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 	"github.com/zerodha/logf"
 	"gorm.io/gorm"
@@ -26,6 +35,18 @@ type App struct {
 	WSHub             *websocket.Hub
 	Queue             queue.Queue
 	CampaignSubCancel context.CancelFunc
+	// ContactBulkSubCancel stops relaying bulk contact update progress
+	ContactBulkSubCancel context.CancelFunc
+	// Leader tells whether this instance runs singleton background work; nil means it
+	// runs alone and always does
+	Leader *leader.Elector
+	// Plugins runs the lifecycle hooks of loaded plugins; nil disables them
+	Plugins *plugins.Manager
+	// keys caches the data keys of organizations that bring their own encryption key
+	keys byok.Keyring
+	// simulations holds the flow simulations in progress, keyed by the IDs of their
+	// in-memory session and contact
+	simulations sync.Map
 	// wg tracks background goroutines for graceful shutdown
 	wg sync.WaitGroup
 }
@@ -101,6 +122,83 @@ func (a *App) ReadyCheck(r *fastglue.Request) error {
 	})
 }
 
+// GetDatabasePoolStats returns database connection pool statistics (super admin only)
+func (a *App) GetDatabasePoolStats(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if !a.IsSuperAdmin(userID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	stats, err := database.GetPoolStats(a.DB)
+	if err != nil {
+		a.Log.Error("Failed to get database pool stats", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to get database pool stats", nil, "")
+	}
+
+	return r.SendEnvelope(stats)
+}
+
+// GetQueueStats returns the backlog and throughput of each job queue lane (super admin
+// only). window is in minutes, 5 by default and at most 60.
+func (a *App) GetQueueStats(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if !a.IsSuperAdmin(userID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	window, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("window")))
+	if window < 1 || window > 60 {
+		window = 5
+	}
+
+	lanes, err := queue.GetLaneStats(r.RequestCtx, a.Redis, time.Duration(window)*time.Minute)
+	if err != nil {
+		a.Log.Error("Failed to get queue stats", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to get queue stats", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"window_minutes": window,
+		"lanes":          lanes,
+	})
+}
+
+// SecurityTxt serves /.well-known/security.txt (RFC 9116) when a security contact is configured
+func (a *App) SecurityTxt(r *fastglue.Request) error {
+	cfg := a.Config.Security
+	if cfg.Contact == "" {
+		r.RequestCtx.SetStatusCode(fasthttp.StatusNotFound)
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Contact: " + cfg.Contact + "\n")
+	// Always one year ahead so the file never goes stale between deployments
+	b.WriteString("Expires: " + time.Now().UTC().AddDate(1, 0, 0).Truncate(24*time.Hour).Format(time.RFC3339) + "\n")
+	if cfg.Policy != "" {
+		b.WriteString("Policy: " + cfg.Policy + "\n")
+	}
+	if a.Config.Server.PublicURL != "" {
+		b.WriteString("Canonical: " + strings.TrimSuffix(a.Config.Server.PublicURL, "/") + "/.well-known/security.txt\n")
+	}
+	b.WriteString("Preferred-Languages: en\n")
+
+	r.RequestCtx.SetContentType("text/plain; charset=utf-8")
+	r.RequestCtx.SetBodyString(b.String())
+	return nil
+}
+
+// isLeader reports whether this instance runs singleton background work
+func (a *App) isLeader() bool {
+	return a.Leader == nil || a.Leader.IsLeader()
+}
+
 // StartCampaignStatsSubscriber starts listening for campaign stats updates from Redis pub/sub
 // and broadcasts them via WebSocket
 func (a *App) StartCampaignStatsSubscriber() error {
@@ -133,6 +231,12 @@ func (a *App) StartCampaignStatsSubscriber() error {
 				"failed_count":    update.FailedCount,
 			},
 		})
+
+		// Every instance relays updates to its own WebSocket clients, but only the leader
+		// notifies channels
+		if update.Status == models.CampaignStatusCompleted && a.isLeader() {
+			a.notifyCampaignCompleted(update)
+		}
 	})
 
 	if err != nil {
@@ -0,0 +1,36 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/database"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_GetDatabasePoolStats(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, org.ID)
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.GetDatabasePoolStats(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data database.PoolStats `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.GreaterOrEqual(t, resp.Data.OpenConnections, 1)
+
+	// Regular users can't read pool stats
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("pool"), "password", &role.ID, true)
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.GetDatabasePoolStats(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// assignmentCandidate is an agent who can take a transfer, with their current load
+type assignmentCandidate struct {
+	UserID         uuid.UUID          `gorm:"column:user_id"`
+	Skills         models.StringArray `gorm:"column:skills"`
+	ActiveChats    int                `gorm:"column:active_chats"`
+	LastAssignedAt *time.Time         `gorm:"column:last_assigned_at"`
+}
+
+// autoAssignTransfer applies the organization's assignment strategy to a transfer that
+// has no team and no agent yet. by_team returns the configured team along with the agent
+// its own strategy picked; the other strategies return an agent only. Both are nil when
+// the transfer should wait in the queue.
+func (a *App) autoAssignTransfer(orgID uuid.UUID, contact *models.Contact, settings *models.ChatbotSettings, exclude ...uuid.UUID) (teamID, agentID *uuid.UUID) {
+	if settings == nil {
+		return nil, nil
+	}
+	config := settings.AgentAssignment
+
+	switch config.Strategy {
+	case models.AssignmentStrategyByTeam:
+		if config.TeamID == nil {
+			return nil, nil
+		}
+		var count int64
+		a.DB.Model(&models.Team{}).Where("id = ? AND organization_id = ? AND is_active = ?", *config.TeamID, orgID, true).Count(&count)
+		if count == 0 {
+			a.Log.Warn("Assignment team not found or inactive, leaving transfer in queue", "team_id", *config.TeamID)
+			return nil, nil
+		}
+		return config.TeamID, a.assignToTeam(*config.TeamID, orgID, exclude...)
+	case models.AssignmentStrategyRoundRobin, models.AssignmentStrategyLeastActiveChats, models.AssignmentStrategyBySkill:
+	default:
+		return nil, nil
+	}
+
+	candidates, err := a.assignmentCandidates(orgID, config.MaxActiveChats, exclude)
+	if err != nil {
+		a.Log.Error("Failed to load agents for assignment", "error", err)
+		return nil, nil
+	}
+
+	var tags []string
+	if contact != nil {
+		for _, t := range contact.Tags {
+			if tag, ok := t.(string); ok {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	picked := pickAssignmentCandidate(config.Strategy, candidates, tags)
+	if picked == nil {
+		a.Log.Debug("No available agent for assignment", "strategy", config.Strategy)
+		return nil, nil
+	}
+
+	a.DB.Model(&models.User{}).Where("id = ?", picked.UserID).Update("last_assigned_at", time.Now())
+	a.Log.Debug("Auto-assigned transfer", "strategy", config.Strategy, "user_id", picked.UserID, "active_chats", picked.ActiveChats)
+	return nil, &picked.UserID
+}
+
+// assignmentCandidates returns the organization's active, available agents who may pick
+// up transfers, with their number of active transfers. Agents at maxActiveChats or more
+// are left out when it is set.
+func (a *App) assignmentCandidates(orgID uuid.UUID, maxActiveChats int, exclude []uuid.UUID) ([]assignmentCandidate, error) {
+	activeChats := a.DB.Model(&models.AgentTransfer{}).
+		Select("COUNT(*)").
+		Where("agent_transfers.agent_id = users.id AND agent_transfers.status = ?", models.TransferStatusActive)
+
+	query := a.DB.Table("users").
+		Select("users.id AS user_id, users.skills, users.last_assigned_at, (?) AS active_chats", activeChats).
+		Joins("JOIN role_permissions ON role_permissions.custom_role_id = users.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("users.organization_id = ? AND users.is_active = ? AND users.is_available = ? AND users.on_shift IS NOT FALSE AND users.deleted_at IS NULL",
+			orgID, true, true).
+		Where("permissions.resource = ? AND permissions.action = ?", models.ResourceTransfers, models.ActionPickup)
+	if len(exclude) > 0 {
+		query = query.Where("users.id NOT IN ?", exclude)
+	}
+
+	var candidates []assignmentCandidate
+	if err := query.Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+	if maxActiveChats <= 0 {
+		return candidates, nil
+	}
+	available := candidates[:0]
+	for _, c := range candidates {
+		if c.ActiveChats < maxActiveChats {
+			available = append(available, c)
+		}
+	}
+	return available, nil
+}
+
+// pickAssignmentCandidate chooses an agent for the strategy. round_robin takes the agent
+// assigned least recently; least_active_chats the one with the fewest active transfers.
+// by_skill prefers agents with the most skills matching the contact's tags, by load, and
+// falls back to least_active_chats when nobody has a matching skill.
+func pickAssignmentCandidate(strategy models.AssignmentStrategy, candidates []assignmentCandidate, tags []string) *assignmentCandidate {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	leastRecent := func(x, y assignmentCandidate) bool {
+		if x.LastAssignedAt == nil || y.LastAssignedAt == nil {
+			return x.LastAssignedAt == nil && y.LastAssignedAt != nil
+		}
+		return x.LastAssignedAt.Before(*y.LastAssignedAt)
+	}
+	leastLoaded := func(x, y assignmentCandidate) bool {
+		if x.ActiveChats != y.ActiveChats {
+			return x.ActiveChats < y.ActiveChats
+		}
+		return leastRecent(x, y)
+	}
+
+	ranked := append([]assignmentCandidate(nil), candidates...)
+	switch strategy {
+	case models.AssignmentStrategyRoundRobin:
+		sort.SliceStable(ranked, func(i, j int) bool { return leastRecent(ranked[i], ranked[j]) })
+	case models.AssignmentStrategyBySkill:
+		matches := make(map[uuid.UUID]int, len(ranked))
+		for _, c := range ranked {
+			matches[c.UserID] = matchingSkills(c.Skills, tags)
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			if mi, mj := matches[ranked[i].UserID], matches[ranked[j].UserID]; mi != mj {
+				return mi > mj
+			}
+			return leastLoaded(ranked[i], ranked[j])
+		})
+	default:
+		sort.SliceStable(ranked, func(i, j int) bool { return leastLoaded(ranked[i], ranked[j]) })
+	}
+	return &ranked[0]
+}
+
+// matchingSkills counts the agent's skills that match one of the tags, ignoring case
+func matchingSkills(skills []string, tags []string) int {
+	count := 0
+	for _, skill := range skills {
+		for _, tag := range tags {
+			if strings.EqualFold(strings.TrimSpace(skill), strings.TrimSpace(tag)) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// autoAssignsAgents reports whether the organization strategy picks an agent itself,
+// rather than leaving transfers in the queue or handing them to a team
+func autoAssignsAgents(strategy models.AssignmentStrategy) bool {
+	switch strategy {
+	case models.AssignmentStrategyRoundRobin, models.AssignmentStrategyLeastActiveChats, models.AssignmentStrategyBySkill:
+		return true
+	}
+	return false
+}
+
+// assignToTeamBySkills picks the team agent with the most of the skills. Nil when the
+// team assigns manually or none of its available agents has any of them.
+func (a *App) assignToTeamBySkills(teamID, orgID uuid.UUID, skills []string) *uuid.UUID {
+	var team models.Team
+	if err := a.DB.Where("id = ? AND organization_id = ? AND is_active = ?", teamID, orgID, true).First(&team).Error; err != nil {
+		return nil
+	}
+	if team.AssignmentStrategy == models.AssignmentStrategyManual {
+		return nil
+	}
+	return a.assignBySkills(orgID, &teamID, skills, 0)
+}
+
+// assignBySkills picks the available agent with the most of the skills, by load, among
+// the team's agents when teamID is set. Nil when nobody has any of them.
+func (a *App) assignBySkills(orgID uuid.UUID, teamID *uuid.UUID, skills []string, maxActiveChats int) *uuid.UUID {
+	candidates, err := a.assignmentCandidates(orgID, maxActiveChats, nil)
+	if err != nil {
+		a.Log.Error("Failed to load agents for skill assignment", "error", err)
+		return nil
+	}
+	if teamID != nil {
+		var memberIDs []uuid.UUID
+		a.availableTeamAgents(*teamID, nil).Model(&models.TeamMember{}).Pluck("team_members.user_id", &memberIDs)
+		candidates = slices.DeleteFunc(candidates, func(c assignmentCandidate) bool {
+			return !slices.Contains(memberIDs, c.UserID)
+		})
+	}
+	candidates = slices.DeleteFunc(candidates, func(c assignmentCandidate) bool {
+		return matchingSkills(c.Skills, skills) == 0
+	})
+
+	picked := pickAssignmentCandidate(models.AssignmentStrategyBySkill, candidates, skills)
+	if picked == nil {
+		a.Log.Debug("No available agent with the handoff skills", "skills", skills, "team_id", teamID)
+		return nil
+	}
+
+	now := time.Now()
+	a.DB.Model(&models.User{}).Where("id = ?", picked.UserID).Update("last_assigned_at", now)
+	if teamID != nil {
+		a.DB.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", *teamID, picked.UserID).Update("last_assigned_at", now)
+	}
+	a.Log.Debug("Assigned transfer by skill", "user_id", picked.UserID, "skills", skills, "team_id", teamID)
+	return &picked.UserID
+}
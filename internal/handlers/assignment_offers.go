@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// AcceptTransferAssignment confirms an auto-assigned transfer offered to the current agent
+func (a *App) AcceptTransferAssignment(r *fastglue.Request) error {
+	offer, err := a.pendingOfferFromRequest(r)
+	if err != nil || offer == nil {
+		return err
+	}
+
+	if !a.closeAssignmentOffer(offer, models.AssignmentOfferAccepted) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "This assignment is no longer waiting for you", nil, "")
+	}
+	a.broadcastAssignmentOfferDone(offer)
+
+	a.Log.Info("Transfer assignment accepted", "transfer_id", offer.TransferID, "agent_id", offer.AgentID)
+	return r.SendEnvelope(map[string]any{
+		"message": "Transfer accepted",
+	})
+}
+
+// DeclineTransferAssignment turns down an auto-assigned transfer; it moves on to the next
+// agent in the team or back to the queue
+func (a *App) DeclineTransferAssignment(r *fastglue.Request) error {
+	offer, err := a.pendingOfferFromRequest(r)
+	if err != nil || offer == nil {
+		return err
+	}
+
+	if !a.closeAssignmentOffer(offer, models.AssignmentOfferDeclined) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "This assignment is no longer waiting for you", nil, "")
+	}
+	a.broadcastAssignmentOfferDone(offer)
+	a.passTransferOn(offer)
+
+	a.Log.Info("Transfer assignment declined", "transfer_id", offer.TransferID, "agent_id", offer.AgentID)
+	return r.SendEnvelope(map[string]any{
+		"message": "Transfer declined",
+	})
+}
+
+// pendingOfferFromRequest loads the current user's open offer for the transfer in the path.
+// When there is none it sends the error response and returns a nil offer.
+func (a *App) pendingOfferFromRequest(r *fastglue.Request) (*models.TransferAssignmentOffer, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	transferID, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid transfer ID", nil, "")
+	}
+
+	var offer models.TransferAssignmentOffer
+	if err := a.DB.Where("organization_id = ? AND transfer_id = ? AND agent_id = ? AND status = ?",
+		orgID, transferID, userID, models.AssignmentOfferPending).
+		Order("created_at DESC").First(&offer).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "No assignment is waiting for you on this transfer", nil, "")
+	}
+	if time.Now().After(offer.ExpiresAt) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusConflict, "This assignment has expired", nil, "")
+	}
+	return &offer, nil
+}
+
+// offerAssignment asks the auto-assigned agent to accept the transfer within the
+// configured window. Without a window the assignment stands as is.
+func (a *App) offerAssignment(transfer *models.AgentTransfer, contact *models.Contact, settings *models.ChatbotSettings) {
+	if transfer.AgentID == nil || settings == nil || settings.AgentAssignment.AcceptTimeoutSecs <= 0 {
+		return
+	}
+
+	offer := models.TransferAssignmentOffer{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: transfer.OrganizationID,
+		TransferID:     transfer.ID,
+		AgentID:        *transfer.AgentID,
+		Status:         models.AssignmentOfferPending,
+		ExpiresAt:      time.Now().Add(time.Duration(settings.AgentAssignment.AcceptTimeoutSecs) * time.Second),
+	}
+	if err := a.DB.Create(&offer).Error; err != nil {
+		a.Log.Error("Failed to create assignment offer", "error", err, "transfer_id", transfer.ID)
+		return
+	}
+
+	if a.WSHub == nil {
+		return
+	}
+	phoneNumber := transfer.PhoneNumber
+	contactName := contact.ProfileName
+	if a.ShouldMaskPhoneNumbers(transfer.OrganizationID) {
+		phoneNumber = MaskPhoneNumber(phoneNumber)
+		contactName = MaskIfPhoneNumber(contactName)
+	}
+	payload := map[string]any{
+		"offer_id":     offer.ID.String(),
+		"transfer_id":  transfer.ID.String(),
+		"contact_id":   transfer.ContactID.String(),
+		"contact_name": contactName,
+		"phone_number": phoneNumber,
+		"notes":        transfer.Notes,
+		"expires_at":   offer.ExpiresAt.Format(time.RFC3339),
+		"timeout_secs": settings.AgentAssignment.AcceptTimeoutSecs,
+	}
+	if transfer.TeamID != nil {
+		payload["team_id"] = transfer.TeamID.String()
+	}
+	a.WSHub.BroadcastToUser(transfer.OrganizationID, offer.AgentID, websocket.WSMessage{
+		Type:    websocket.TypeAssignmentOffer,
+		Payload: payload,
+	})
+}
+
+// closeAssignmentOffer moves a pending offer to its final status. It returns false if
+// the offer was already answered or expired, so only one caller acts on it.
+func (a *App) closeAssignmentOffer(offer *models.TransferAssignmentOffer, status models.AssignmentOfferStatus) bool {
+	now := time.Now()
+	result := a.DB.Model(&models.TransferAssignmentOffer{}).
+		Where("id = ? AND status = ?", offer.ID, models.AssignmentOfferPending).
+		Updates(map[string]any{
+			"status":       status,
+			"responded_at": now,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to close assignment offer", "error", result.Error, "offer_id", offer.ID)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+	offer.Status = status
+	offer.RespondedAt = &now
+	return true
+}
+
+// passTransferOn hands a declined or expired transfer to the next available agent in its
+// team who hasn't been offered it yet, or back to the queue
+func (a *App) passTransferOn(offer *models.TransferAssignmentOffer) {
+	var transfer models.AgentTransfer
+	if err := a.DB.Where("id = ? AND status = ?", offer.TransferID, models.TransferStatusActive).
+		First(&transfer).Error; err != nil {
+		return
+	}
+	// Someone already moved the transfer elsewhere
+	if transfer.AgentID == nil || *transfer.AgentID != offer.AgentID {
+		return
+	}
+
+	var offered []uuid.UUID
+	a.DB.Model(&models.TransferAssignmentOffer{}).
+		Where("transfer_id = ?", transfer.ID).
+		Pluck("agent_id", &offered)
+
+	var contact models.Contact
+	if err := a.DB.Where("id = ?", transfer.ContactID).First(&contact).Error; err != nil {
+		a.Log.Error("Failed to load contact for reassigned transfer", "error", err, "transfer_id", transfer.ID)
+		return
+	}
+	settings, _ := a.getChatbotSettingsCached(transfer.OrganizationID, transfer.WhatsAppAccount)
+
+	var next *uuid.UUID
+	if transfer.TeamID != nil {
+		next = a.assignToTeam(*transfer.TeamID, transfer.OrganizationID, offered...)
+	} else {
+		var teamID *uuid.UUID
+		teamID, next = a.autoAssignTransfer(transfer.OrganizationID, &contact, settings, offered...)
+		transfer.TeamID = teamID
+	}
+
+	transfer.AgentID = next
+	if err := a.DB.Save(&transfer).Error; err != nil {
+		a.Log.Error("Failed to reassign transfer", "error", err, "transfer_id", transfer.ID)
+		return
+	}
+	if next != nil {
+		a.DB.Model(&contact).Update("assigned_user_id", *next)
+	} else {
+		a.DB.Model(&contact).Update("assigned_user_id", nil)
+	}
+
+	if next == nil {
+		a.Log.Info("Transfer returned to queue after assignment offer", "transfer_id", transfer.ID, "offer_status", offer.Status)
+		a.broadcastTransferAssigned(&transfer)
+		a.notifyTransferQueued(&transfer, &contact)
+		return
+	}
+
+	// Offer before announcing the assignment so the agent's client shows the prompt
+	// rather than a plain assignment notice
+	a.Log.Info("Transfer passed to next agent", "transfer_id", transfer.ID, "agent_id", *next, "offer_status", offer.Status)
+	a.offerAssignment(&transfer, &contact, settings)
+	a.broadcastTransferAssigned(&transfer)
+}
+
+// broadcastAssignmentOfferDone tells the agent's open sessions to dismiss the prompt
+func (a *App) broadcastAssignmentOfferDone(offer *models.TransferAssignmentOffer) {
+	if a.WSHub == nil {
+		return
+	}
+	a.WSHub.BroadcastToUser(offer.OrganizationID, offer.AgentID, websocket.WSMessage{
+		Type: websocket.TypeAssignmentOfferDone,
+		Payload: map[string]any{
+			"offer_id":    offer.ID.String(),
+			"transfer_id": offer.TransferID.String(),
+			"status":      offer.Status,
+		},
+	})
+}
+
+// expireAssignmentOffers passes on transfers whose accept window ran out
+func (a *App) expireAssignmentOffers() {
+	var offers []models.TransferAssignmentOffer
+	if err := a.DB.Where("status = ? AND expires_at < ?", models.AssignmentOfferPending, time.Now()).
+		Order("expires_at").Limit(500).Find(&offers).Error; err != nil {
+		a.Log.Error("Failed to load expired assignment offers", "error", err)
+		return
+	}
+
+	for i := range offers {
+		offer := &offers[i]
+		if !a.closeAssignmentOffer(offer, models.AssignmentOfferExpired) {
+			continue
+		}
+		a.broadcastAssignmentOfferDone(offer)
+		a.passTransferOn(offer)
+	}
+}
+
+// calculateAssignmentAcceptance counts answered assignment offers and the share accepted
+func (a *App) calculateAssignmentAcceptance(orgID uuid.UUID, agentID *uuid.UUID, start, end time.Time) (offers, declined, expired int64, acceptRate float64) {
+	var result struct {
+		Accepted int64
+		Declined int64
+		Expired  int64
+	}
+	query := a.DB.Model(&models.TransferAssignmentOffer{}).
+		Select("COUNT(*) FILTER (WHERE status = ?) as accepted, COUNT(*) FILTER (WHERE status = ?) as declined, COUNT(*) FILTER (WHERE status = ?) as expired",
+			models.AssignmentOfferAccepted, models.AssignmentOfferDeclined, models.AssignmentOfferExpired).
+		Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, start, end)
+	if agentID != nil {
+		query = query.Where("agent_id = ?", *agentID)
+	}
+	query.Scan(&result)
+
+	offers = result.Accepted + result.Declined + result.Expired
+	if offers > 0 {
+		acceptRate = float64(result.Accepted) / float64(offers) * 100
+	}
+	return offers, result.Declined, result.Expired, acceptRate
+}
+
+// AssignmentOfferProcessor expires assignment offers agents didn't answer in time
+type AssignmentOfferProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAssignmentOfferProcessor creates a new assignment offer processor
+func NewAssignmentOfferProcessor(app *App, interval time.Duration) *AssignmentOfferProcessor {
+	return &AssignmentOfferProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the expiry loop
+func (p *AssignmentOfferProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Assignment offer processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Assignment offer processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Assignment offer processor stopped")
+			return
+		case <-ticker.C:
+			p.app.expireAssignmentOffers()
+		}
+	}
+}
+
+// Stop stops the assignment offer processor
+func (p *AssignmentOfferProcessor) Stop() {
+	close(p.stopCh)
+}
@@ -0,0 +1,82 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_TransferAssignmentOffers(t *testing.T) {
+	app := agentTransfersTestApp(t)
+	org := createTransferTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	account := createTransferTestAccount(t, app, org.ID)
+	contact := createTestContact(t, app, org.ID)
+	first := createTestAgent(t, app, org.ID)
+	second := createTestAgent(t, app, org.ID)
+	team := createTestTeam(t, app, org.ID, first.ID, second.ID)
+
+	settings := &models.ChatbotSettings{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: org.ID,
+	}
+	settings.AgentAssignment.AcceptTimeoutSecs = 30
+	require.NoError(t, app.DB.Create(settings).Error)
+
+	teamID := team.ID.String()
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"contact_id":       contact.ID.String(),
+		"whatsapp_account": account.Name,
+		"team_id":          teamID,
+	})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.CreateAgentTransfer(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var transfer models.AgentTransfer
+	require.NoError(t, app.DB.Where("contact_id = ?", contact.ID).First(&transfer).Error)
+	require.NotNil(t, transfer.AgentID)
+	offeredTo := *transfer.AgentID
+	other := first.ID
+	if offeredTo == first.ID {
+		other = second.ID
+	}
+
+	respond := func(userID uuid.UUID, action string) int {
+		req := testutil.NewJSONRequest(t, map[string]any{})
+		setTransferAuthContext(req, org.ID, userID)
+		testutil.SetPathParam(req, "id", transfer.ID.String())
+		if action == "accept" {
+			require.NoError(t, app.AcceptTransferAssignment(req))
+		} else {
+			require.NoError(t, app.DeclineTransferAssignment(req))
+		}
+		return testutil.GetResponseStatusCode(req)
+	}
+
+	// Only the offered agent can answer
+	assert.Equal(t, fasthttp.StatusNotFound, respond(other, "accept"))
+
+	// Declining passes the transfer to the other team agent
+	require.Equal(t, fasthttp.StatusOK, respond(offeredTo, "decline"))
+	require.NoError(t, app.DB.First(&transfer, transfer.ID).Error)
+	require.NotNil(t, transfer.AgentID)
+	assert.Equal(t, other, *transfer.AgentID)
+
+	// Once everyone declined it goes back to the queue
+	require.Equal(t, fasthttp.StatusOK, respond(other, "decline"))
+	require.NoError(t, app.DB.First(&transfer, transfer.ID).Error)
+	assert.Nil(t, transfer.AgentID)
+
+	var offers []models.TransferAssignmentOffer
+	require.NoError(t, app.DB.Where("transfer_id = ?", transfer.ID).Find(&offers).Error)
+	require.Len(t, offers, 2)
+	for _, o := range offers {
+		assert.Equal(t, models.AssignmentOfferDeclined, o.Status)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickAssignmentCandidate(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	busy := assignmentCandidate{UserID: uuid.New(), ActiveChats: 4, LastAssignedAt: &earlier}
+	idle := assignmentCandidate{UserID: uuid.New(), ActiveChats: 1, LastAssignedAt: &now}
+	billing := assignmentCandidate{UserID: uuid.New(), ActiveChats: 3, LastAssignedAt: &now, Skills: models.StringArray{"Billing", "spanish"}}
+	fresh := assignmentCandidate{UserID: uuid.New(), ActiveChats: 1}
+	candidates := []assignmentCandidate{busy, idle, billing}
+
+	tests := []struct {
+		name       string
+		strategy   models.AssignmentStrategy
+		candidates []assignmentCandidate
+		tags       []string
+		want       uuid.UUID
+	}{
+		{"round robin takes least recently assigned", models.AssignmentStrategyRoundRobin, candidates, nil, busy.UserID},
+		{"round robin prefers never assigned", models.AssignmentStrategyRoundRobin, append(candidates, fresh), nil, fresh.UserID},
+		{"least active chats", models.AssignmentStrategyLeastActiveChats, candidates, nil, idle.UserID},
+		{"least active chats tie goes to least recent", models.AssignmentStrategyLeastActiveChats, append(candidates, fresh), nil, fresh.UserID},
+		{"skill match beats load", models.AssignmentStrategyBySkill, candidates, []string{"billing"}, billing.UserID},
+		{"no skill match falls back to load", models.AssignmentStrategyBySkill, candidates, []string{"vip"}, idle.UserID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked := pickAssignmentCandidate(tt.strategy, tt.candidates, tt.tags)
+			require.NotNil(t, picked)
+			assert.Equal(t, tt.want, picked.UserID)
+		})
+	}
+
+	assert.Nil(t, pickAssignmentCandidate(models.AssignmentStrategyRoundRobin, nil, nil))
+}
+
+func TestMatchingSkills(t *testing.T) {
+	assert.Equal(t, 2, matchingSkills([]string{"Billing", " Spanish ", "vip"}, []string{"billing", "spanish"}))
+	assert.Equal(t, 0, matchingSkills(nil, []string{"billing"}))
+}
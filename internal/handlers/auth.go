@@ -83,6 +83,15 @@ func (a *App) Login(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid credentials", nil, "")
 	}
 
+	if a.isSuspendedUser(&user) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Your organization has been suspended", nil, "")
+	}
+
+	// Enforce the organization's admin IP allowlist
+	if !a.checkAdminLoginIP(&user, middleware.ClientIP(r)) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Login from this IP address is not allowed", nil, "")
+	}
+
 	// Generate tokens
 	accessToken, err := a.generateAccessToken(&user)
 	if err != nil {
@@ -233,6 +242,10 @@ func (a *App) RefreshToken(r *fastglue.Request) error {
 	if !ok {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid token claims", nil, "")
 	}
+	// Impersonation tokens expire with their session and can't be exchanged for a full one
+	if claims.ImpersonatorID != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid refresh token", nil, "")
+	}
 
 	// Get user
 	var user models.User
@@ -243,6 +256,14 @@ func (a *App) RefreshToken(r *fastglue.Request) error {
 	if !user.IsActive {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Account is disabled", nil, "")
 	}
+	// The new tokens stay in the organization the session was issued for
+	user, ok = a.userInOrganization(user, claims.OrganizationID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "You no longer have access to this organization", nil, "")
+	}
+	if a.isSuspendedUser(&user) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Your organization has been suspended", nil, "")
+	}
 
 	// Generate new tokens
 	accessToken, _ := a.generateAccessToken(&user)
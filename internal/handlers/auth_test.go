@@ -1,6 +1,9 @@
 package handlers_test
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"testing"
 	"time"
@@ -494,3 +497,96 @@ func TestApp_GeneratedTokensAreValid(t *testing.T) {
 	require.True(t, ok)
 	assert.Equal(t, user.ID, refreshClaims.UserID)
 }
+
+// seedPasswordResetToken stores a reset token for user the way ForgotPassword does.
+func seedPasswordResetToken(t *testing.T, app *handlers.App, user *models.User) string {
+	t.Helper()
+
+	token := uuid.New().String()
+	sum := sha256.Sum256([]byte(token))
+	key := "password_reset:" + hex.EncodeToString(sum[:])
+	require.NoError(t, app.Redis.Set(context.Background(), key, user.ID.String(), time.Hour).Err())
+	return token
+}
+
+func TestApp_ForgotPassword_UnknownEmail(t *testing.T) {
+	app := testApp(t)
+
+	req := testutil.NewJSONRequest(t, map[string]string{
+		"email": uniqueEmail("forgot-unknown"),
+	})
+
+	err := app.ForgotPassword(req)
+	require.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Contains(t, string(testutil.GetResponseBody(req)), "If an account exists")
+}
+
+func TestApp_ForgotPassword_ThrottlesRepeatedRequests(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	email := uniqueEmail("forgot-throttle")
+	user := createTestUser(t, app, org.ID, email, "validpassword123", nil, true)
+
+	for i := 0; i < 2; i++ {
+		req := testutil.NewJSONRequest(t, map[string]string{"email": email})
+		require.NoError(t, app.ForgotPassword(req))
+		assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	}
+
+	ttl, err := app.Redis.TTL(context.Background(), "password_reset:cooldown:"+user.ID.String()).Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0))
+}
+
+func TestApp_ResetPassword_Success(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	email := uniqueEmail("reset-success")
+	user := createTestUser(t, app, org.ID, email, "oldpassword123", nil, true)
+	token := seedPasswordResetToken(t, app, user)
+
+	req := testutil.NewJSONRequest(t, map[string]string{
+		"token":    token,
+		"password": "newpassword123",
+	})
+	require.NoError(t, app.ResetPassword(req))
+	assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var updated models.User
+	require.NoError(t, app.DB.Where("id = ?", user.ID).First(&updated).Error)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(updated.PasswordHash), []byte("newpassword123")))
+
+	// Tokens are single use
+	req = testutil.NewJSONRequest(t, map[string]string{
+		"token":    token,
+		"password": "anotherpassword123",
+	})
+	require.NoError(t, app.ResetPassword(req))
+	assertErrorResponse(t, req, fasthttp.StatusBadRequest, "Reset link is invalid or has expired")
+}
+
+func TestApp_ResetPassword_ShortPassword(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("reset-short"), "oldpassword123", nil, true)
+	token := seedPasswordResetToken(t, app, user)
+
+	req := testutil.NewJSONRequest(t, map[string]string{
+		"token":    token,
+		"password": "short",
+	})
+	require.NoError(t, app.ResetPassword(req))
+	assertErrorResponse(t, req, fasthttp.StatusBadRequest, "at least 8 characters")
+}
+
+func TestApp_ResetPassword_InvalidToken(t *testing.T) {
+	app := testApp(t)
+
+	req := testutil.NewJSONRequest(t, map[string]string{
+		"token":    "not-a-real-token",
+		"password": "newpassword123",
+	})
+	require.NoError(t, app.ResetPassword(req))
+	assertErrorResponse(t, req, fasthttp.StatusBadRequest, "Reset link is invalid or has expired")
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// maxAwayMessageLength caps away messages on teams and users
+	maxAwayMessageLength = 1000
+	// awayReplyKeyPrefix marks a contact that has had an away reply this session
+	awayReplyKeyPrefix = "away_reply:"
+
+	awayMessageLengthError = "Away message must be 1000 characters or fewer"
+)
+
+// validateAwayMessage checks an away message's length
+func validateAwayMessage(message string) string {
+	if utf8.RuneCountInString(message) > maxAwayMessageLength {
+		return awayMessageLengthError
+	}
+	return ""
+}
+
+// resolveAwayMessage works out the away message for an agent's contacts: the agent's own
+// message, else the first of their active teams (by name) that has one. It returns "" when
+// neither is set. The message takes the same placeholders as signatures.
+func (a *App) resolveAwayMessage(account *models.WhatsAppAccount, agent *models.User) string {
+	vars := signatureVars{Name: agent.FullName, Business: account.SenderName}
+	if vars.Name == "" {
+		vars.Name = account.SenderName
+	}
+	template := agent.AwayMessage
+
+	var teams []models.Team
+	a.DB.Select("teams.name, teams.away_message").
+		Joins("JOIN team_members ON team_members.team_id = teams.id AND team_members.deleted_at IS NULL").
+		Where("team_members.user_id = ? AND teams.organization_id = ? AND teams.is_active = ?", agent.ID, account.OrganizationID, true).
+		Order("teams.name ASC").
+		Find(&teams)
+	for _, team := range teams {
+		if vars.Team == "" {
+			vars.Team = team.Name
+		}
+		if template == "" && team.AwayMessage != "" {
+			vars.Team = team.Name
+			template = team.AwayMessage
+			break
+		}
+	}
+
+	if template == "" {
+		return ""
+	}
+	return strings.TrimSpace(vars.replace(template))
+}
+
+// sendAwayReply answers a contact whose assigned agent is away with the agent's away
+// message. The contact gets one reply per session: the marker lasts the chatbot session
+// timeout and is extended by each message, so only a quiet spell resets it.
+func (a *App) sendAwayReply(account *models.WhatsAppAccount, contact *models.Contact) {
+	if contact.AssignedUserID == nil {
+		return
+	}
+
+	var agent models.User
+	if err := a.DB.Select("id, full_name, away_message, is_available").
+		Where("id = ?", contact.AssignedUserID).
+		First(&agent).Error; err != nil || agent.IsAvailable {
+		return
+	}
+	message := a.resolveAwayMessage(account, &agent)
+	if message == "" {
+		return
+	}
+
+	timeoutMins := 30
+	if settings, err := a.getChatbotSettingsCached(account.OrganizationID, account.Name); err == nil && settings.SessionTimeoutMins > 0 {
+		timeoutMins = settings.SessionTimeoutMins
+	}
+	ctx := context.Background()
+	key := awayReplyKeyPrefix + contact.ID.String()
+	ttl := time.Duration(timeoutMins) * time.Minute
+	first, err := a.Redis.SetNX(ctx, key, agent.ID.String(), ttl).Result()
+	if err != nil {
+		a.Log.Error("Failed to record away reply", "error", err, "contact_id", contact.ID)
+		return
+	}
+	if !first {
+		a.Redis.Expire(ctx, key, ttl)
+		return
+	}
+
+	if err := a.sendAndSaveTextMessage(account, contact, message); err != nil {
+		a.Log.Error("Failed to send away reply", "error", err, "contact", contact.PhoneNumber, "agent_id", agent.ID)
+		a.Redis.Del(ctx, key)
+		return
+	}
+	a.Log.Info("Sent away reply", "contact_id", contact.ID, "agent_id", agent.ID)
+}
+
+// MyAwayMessageRequest sets the current user's own away message
+type MyAwayMessageRequest struct {
+	AwayMessage string `json:"away_message"` // Empty uses the team's away message
+}
+
+// UpdateMyAwayMessage sets the reply sent to the current user's contacts while they are away
+func (a *App) UpdateMyAwayMessage(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req MyAwayMessageRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.AwayMessage = strings.TrimSpace(req.AwayMessage)
+	if msg := validateAwayMessage(req.AwayMessage); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Model(&models.User{}).Where("id = ?", userID).Update("away_message", req.AwayMessage).Error; err != nil {
+		a.Log.Error("Failed to update away message", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update away message", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":      "Away message updated successfully",
+		"away_message": req.AwayMessage,
+	})
+}
@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_AwayReply(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Updates(map[string]interface{}{
+		"phone_id":    "phone-" + uuid.NewString()[:8],
+		"sender_name": "Acme",
+	}).Error)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("away-agent"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	require.NoError(t, app.DB.Model(agent).Updates(map[string]interface{}{"full_name": "Priya Shah", "is_available": false}).Error)
+
+	team := &models.Team{OrganizationID: org.ID, Name: "Support", IsActive: true, AwayMessage: "The {team} team at {business} is away"}
+	require.NoError(t, app.DB.Create(team).Error)
+	require.NoError(t, app.DB.Create(&models.TeamMember{TeamID: team.ID, UserID: agent.ID, Role: models.TeamRoleAgent}).Error)
+
+	contact := &models.Contact{OrganizationID: org.ID, PhoneNumber: "14155550190", ProfileName: "Sam", AssignedUserID: &agent.ID}
+	require.NoError(t, app.DB.Create(contact).Error)
+
+	// receive delivers a message from the contact and waits until it's stored
+	received := 0
+	receive := func(text string) {
+		body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+			"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+			"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+			"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"text","text":{"body":%q}}]}}]}]}`,
+			account.PhoneID, contact.PhoneNumber, contact.PhoneNumber, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()), text)
+		req := testutil.NewJSONRequest(t, nil)
+		req.RequestCtx.Request.SetBody([]byte(body))
+		require.NoError(t, app.WebhookHandler(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		received++
+		require.Eventually(t, func() bool {
+			var count int64
+			app.DB.Model(&models.Message{}).Where("contact_id = ? AND direction = ?", contact.ID, models.DirectionIncoming).Count(&count)
+			return count == int64(received)
+		}, 2*time.Second, 20*time.Millisecond)
+	}
+	replies := func() []string {
+		var messages []models.Message
+		app.DB.Where("contact_id = ? AND direction = ?", contact.ID, models.DirectionOutgoing).Order("created_at ASC").Find(&messages)
+		contents := make([]string, len(messages))
+		for i, m := range messages {
+			contents[i] = m.Content
+		}
+		return contents
+	}
+
+	// The team's away message answers the first message of the session only
+	receive("Is my order ready?")
+	require.Eventually(t, func() bool { return len(replies()) == 1 }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "The Support team at Acme is away", replies()[0])
+	receive("Hello?")
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, replies(), 1)
+
+	// The agent's own message replaces the team's in the next session
+	req := testutil.NewJSONRequest(t, map[string]interface{}{"away_message": "{first_name} is out until Monday"})
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.UpdateMyAwayMessage(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	require.NoError(t, app.Redis.Del(context.Background(), "away_reply:"+contact.ID.String()).Err())
+	receive("Anyone there?")
+	require.Eventually(t, func() bool { return len(replies()) == 2 }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "Priya is out until Monday", replies()[1])
+
+	// Available agents answer for themselves
+	require.NoError(t, app.DB.Model(agent).Update("is_available", true).Error)
+	require.NoError(t, app.Redis.Del(context.Background(), "away_reply:"+contact.ID.String()).Err())
+	receive("Thanks")
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, replies(), 2)
+}
+
+func TestApp_UpdateMyAwayMessage_TooLong(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("away-long"), "password", nil, true)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{"away_message": strings.Repeat("a", 1001)})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.UpdateMyAwayMessage(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// Limits enforced by the Cloud API on business profile fields
+	maxProfileAboutLength       = 139
+	maxProfileAddressLength     = 256
+	maxProfileDescriptionLength = 512
+	maxProfileEmailLength       = 128
+	maxProfileWebsites          = 2
+	maxProfilePhotoSize         = 5 << 20 // 5MB
+)
+
+// BusinessProfileRequest represents a business profile update. Omitted fields are left unchanged.
+type BusinessProfileRequest struct {
+	About       *string   `json:"about"`
+	Address     *string   `json:"address"`
+	Description *string   `json:"description"`
+	Email       *string   `json:"email"`
+	Websites    *[]string `json:"websites"`
+	Vertical    *string   `json:"vertical"`
+}
+
+// GetBusinessProfile returns the WhatsApp business profile of an account's phone number
+func (a *App) GetBusinessProfile(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	profile, err := a.WhatsApp.GetBusinessProfile(context.Background(), a.toWhatsAppAccount(account))
+	if err != nil {
+		a.Log.Error("Failed to fetch business profile", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch business profile: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"profile":   profile,
+		"verticals": whatsapp.BusinessVerticals,
+	})
+}
+
+// UpdateBusinessProfile updates the WhatsApp business profile of an account's phone number
+func (a *App) UpdateBusinessProfile(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var req BusinessProfileRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if msg := validateBusinessProfileRequest(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	update := &whatsapp.BusinessProfileUpdate{
+		About:       req.About,
+		Address:     req.Address,
+		Description: req.Description,
+		Email:       req.Email,
+		Vertical:    req.Vertical,
+	}
+	if req.Websites != nil {
+		update.Websites = *req.Websites
+	}
+
+	ctx := context.Background()
+	waAccount := a.toWhatsAppAccount(account)
+	if err := a.WhatsApp.UpdateBusinessProfile(ctx, waAccount, update); err != nil {
+		a.Log.Error("Failed to update business profile", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to update business profile: "+err.Error(), nil, "")
+	}
+
+	profile, err := a.WhatsApp.GetBusinessProfile(ctx, waAccount)
+	if err != nil {
+		a.Log.Warn("Failed to re-fetch business profile after update", "error", err, "account", account.Name)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Business profile updated successfully",
+		"profile": profile,
+	})
+}
+
+// UploadBusinessProfilePhoto replaces the profile photo of an account's phone number
+func (a *App) UploadBusinessProfilePhoto(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	if account.AppID == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account does not have app_id configured. Please update the account settings.", nil, "")
+	}
+
+	fileHeader, err := r.RequestCtx.FormFile("file")
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No file provided", nil, "")
+	}
+	if fileHeader.Size > maxProfilePhotoSize {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Profile photo must be 5MB or smaller", nil, "")
+	}
+
+	mimeType := fileHeader.Header.Get("Content-Type")
+	if mimeType != "image/jpeg" && mimeType != "image/png" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Profile photo must be a JPEG or PNG image", nil, "")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to open uploaded file", nil, "")
+	}
+	defer func() { _ = file.Close() }()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read file data", nil, "")
+	}
+
+	ctx := context.Background()
+	waAccount := a.toWhatsAppAccount(account)
+	handle, err := a.WhatsApp.ResumableUpload(ctx, waAccount, data, mimeType, fileHeader.Filename)
+	if err != nil {
+		a.Log.Error("Failed to upload profile photo", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to upload profile photo: "+err.Error(), nil, "")
+	}
+
+	if err := a.WhatsApp.UpdateBusinessProfile(ctx, waAccount, &whatsapp.BusinessProfileUpdate{ProfilePictureHandle: handle}); err != nil {
+		a.Log.Error("Failed to set profile photo", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to set profile photo: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Profile photo updated successfully",
+	})
+}
+
+// getAccountByIDParam loads the organization's WhatsApp account referenced by the {id} route param
+func (a *App) getAccountByIDParam(r *fastglue.Request, orgID uuid.UUID) (*models.WhatsAppAccount, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// validateBusinessProfileRequest checks the update against Cloud API limits and normalizes the vertical.
+// Returns an error message, or "" if the request is valid.
+func validateBusinessProfileRequest(req *BusinessProfileRequest) string {
+	if req.About != nil && utf8.RuneCountInString(*req.About) > maxProfileAboutLength {
+		return "About must be 139 characters or fewer"
+	}
+	if req.Address != nil && utf8.RuneCountInString(*req.Address) > maxProfileAddressLength {
+		return "Address must be 256 characters or fewer"
+	}
+	if req.Description != nil && utf8.RuneCountInString(*req.Description) > maxProfileDescriptionLength {
+		return "Description must be 512 characters or fewer"
+	}
+	if req.Email != nil && *req.Email != "" {
+		if len(*req.Email) > maxProfileEmailLength {
+			return "Email must be 128 characters or fewer"
+		}
+		if _, err := mail.ParseAddress(*req.Email); err != nil {
+			return "Invalid email address"
+		}
+	}
+	if req.Websites != nil {
+		if len(*req.Websites) > maxProfileWebsites {
+			return "At most 2 websites are allowed"
+		}
+		for _, site := range *req.Websites {
+			parsed, err := url.Parse(site)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return "Websites must be valid http(s) URLs"
+			}
+		}
+	}
+	if req.Vertical != nil {
+		vertical := strings.ToUpper(*req.Vertical)
+		if !slices.Contains(whatsapp.BusinessVerticals, vertical) {
+			return "Invalid vertical"
+		}
+		req.Vertical = &vertical
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// fakeProfileGraph keeps one business profile and applies the updates posted to it
+type fakeProfileGraph struct {
+	mu      sync.Mutex
+	profile map[string]any
+	updates []map[string]any
+}
+
+func (g *fakeProfileGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		var update map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&update)
+		g.updates = append(g.updates, update)
+		for k, v := range update {
+			if k != "messaging_product" {
+				g.profile[k] = v
+			}
+		}
+		_, _ = w.Write([]byte(`{"success":true}`))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{g.profile}})
+}
+
+func TestApp_BusinessProfile(t *testing.T) {
+	app := testApp(t)
+	graph := &fakeProfileGraph{profile: map[string]any{"about": "Open 9-5", "vertical": "RETAIL"}}
+	server := httptest.NewServer(graph)
+	t.Cleanup(server.Close)
+	app.WhatsApp = whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("business-profile"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "profile-account")
+
+	t.Run("get", func(t *testing.T) {
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", account.ID.String())
+
+		require.NoError(t, app.GetBusinessProfile(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp struct {
+			Profile   whatsapp.BusinessProfile `json:"profile"`
+			Verticals []string                 `json:"verticals"`
+		}
+		testutil.ParseEnvelopeResponse(t, req, &resp)
+		assert.Equal(t, "Open 9-5", resp.Profile.About)
+		assert.Contains(t, resp.Verticals, "RETAIL")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		req := testutil.NewJSONRequest(t, map[string]any{
+			"email":    "hello@example.com",
+			"websites": []string{"https://example.com"},
+			"vertical": "travel",
+		})
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", account.ID.String())
+
+		require.NoError(t, app.UpdateBusinessProfile(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp struct {
+			Profile whatsapp.BusinessProfile `json:"profile"`
+		}
+		testutil.ParseEnvelopeResponse(t, req, &resp)
+		assert.Equal(t, "hello@example.com", resp.Profile.Email)
+		assert.Equal(t, "TRAVEL", resp.Profile.Vertical)
+		assert.Equal(t, "Open 9-5", resp.Profile.About, "omitted fields are left unchanged")
+
+		graph.mu.Lock()
+		defer graph.mu.Unlock()
+		require.Len(t, graph.updates, 1)
+		assert.Equal(t, "whatsapp", graph.updates[0]["messaging_product"])
+		assert.NotContains(t, graph.updates[0], "about")
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		for _, body := range []map[string]any{
+			{"email": "not-an-email"},
+			{"websites": []string{"ftp://example.com"}},
+			{"websites": []string{"https://a.com", "https://b.com", "https://c.com"}},
+			{"vertical": "SPACE"},
+		} {
+			req := testutil.NewJSONRequest(t, body)
+			setAuthContext(req, org.ID, user.ID)
+			testutil.SetPathParam(req, "id", account.ID.String())
+
+			require.NoError(t, app.UpdateBusinessProfile(req))
+			assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req), "body %v", body)
+		}
+	})
+
+	t.Run("other organization's account", func(t *testing.T) {
+		otherOrg := createTestOrganization(t, app)
+		otherAccount := createTestWhatsAppAccount(t, app, otherOrg.ID, "other-profile-account")
+
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", otherAccount.ID.String())
+
+		require.NoError(t, app.GetBusinessProfile(req))
+		assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+	})
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/shortlink"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// ButtonClickResponse represents a single recorded button click
+type ButtonClickResponse struct {
+	ID             uuid.UUID         `json:"id"`
+	ContactID      uuid.UUID         `json:"contact_id"`
+	MessageID      *uuid.UUID        `json:"message_id,omitempty"`
+	ReplyMessageID *uuid.UUID        `json:"reply_message_id,omitempty"`
+	CampaignID     *uuid.UUID        `json:"campaign_id,omitempty"`
+	TemplateName   string            `json:"template_name,omitempty"`
+	ButtonType     models.ButtonType `json:"button_type"`
+	ButtonID       string            `json:"button_id"`
+	ButtonText     string            `json:"button_text"`
+	ClickedAt      string            `json:"clicked_at"`
+}
+
+// ButtonClickCount represents the number of clicks on a single button
+type ButtonClickCount struct {
+	ButtonText string            `json:"button_text"`
+	ButtonType models.ButtonType `json:"button_type"`
+	Clicks     int64             `json:"clicks"`
+}
+
+// ButtonCTRStats represents button click-through stats for a template or campaign
+type ButtonCTRStats struct {
+	TemplateName string             `json:"template_name,omitempty"`
+	CampaignID   string             `json:"campaign_id,omitempty"`
+	CampaignName string             `json:"campaign_name,omitempty"`
+	MessagesSent int64              `json:"messages_sent"`
+	TotalClicks  int64              `json:"total_clicks"`
+	UniqueClicks int64              `json:"unique_clicks"` // Distinct messages with at least one click
+	CTR          float64            `json:"ctr"`           // Percentage of sent messages clicked
+	Buttons      []ButtonClickCount `json:"buttons"`
+}
+
+// recordButtonClick stores a button tap and correlates it back to the message that carried the button
+func (a *App) recordButtonClick(account *models.WhatsAppAccount, contact *models.Contact, whatsappMsgID, contextWAMID string, buttonType models.ButtonType, buttonID, buttonText string) {
+	click := models.ButtonClick{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  account.OrganizationID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		ButtonType:      buttonType,
+		ButtonID:        buttonID,
+		ButtonText:      buttonText,
+		ClickedAt:       time.Now(),
+	}
+
+	// Link the incoming reply that carried the click
+	if whatsappMsgID != "" {
+		var reply models.Message
+		if err := a.DB.Select("id").Where("whats_app_message_id = ?", whatsappMsgID).First(&reply).Error; err == nil {
+			click.ReplyMessageID = &reply.ID
+		}
+	}
+
+	// Meta includes the originating message in the reply context
+	if contextWAMID != "" {
+		var source models.Message
+		if err := a.DB.Where("whats_app_message_id = ? AND organization_id = ?", contextWAMID, account.OrganizationID).First(&source).Error; err == nil {
+			setClickSource(&click, &source)
+		} else {
+			a.Log.Debug("Originating message for button click not found", "context_wamid", contextWAMID)
+		}
+	}
+
+	if err := a.DB.Create(&click).Error; err != nil {
+		a.Log.Error("Failed to record button click", "error", err, "contact_id", contact.ID)
+	}
+}
+
+// setClickSource attributes a click to the message that carried the button, and to
+// that message's template and campaign
+func setClickSource(click *models.ButtonClick, source *models.Message) {
+	click.MessageID = &source.ID
+	click.TemplateName = source.TemplateName
+	if campaignIDStr, ok := source.Metadata["campaign_id"].(string); ok && campaignIDStr != "" {
+		if campaignID, err := uuid.Parse(campaignIDStr); err == nil {
+			click.CampaignID = &campaignID
+		}
+	}
+}
+
+// trackCTAURLButton points a CTA URL button at a tracked short link for the message, so
+// opening it is recorded as a tap. WhatsApp doesn't report URL button taps, so this is
+// the only way to see them. Without a public URL the button keeps its own link.
+func (a *App) trackCTAURLButton(req *OutgoingMessageRequest, msg *models.Message) {
+	if req.Type != models.MessageTypeInteractive || req.InteractiveType != "cta_url" || req.URL == "" ||
+		a.Config == nil || a.Config.Server.PublicURL == "" {
+		return
+	}
+	link, err := shortlink.Create(a.DB, shortlink.Owner{
+		OrganizationID: msg.OrganizationID,
+		ContactID:      &msg.ContactID,
+		CreatedBy:      msg.SentByUserID,
+		MessageID:      &msg.ID,
+		ButtonText:     req.ButtonText,
+	}, req.URL)
+	if err != nil {
+		a.Log.Error("Failed to create tracked link for URL button, sending the original URL", "error", err, "message_id", msg.ID)
+		return
+	}
+	req.URL = shortlink.URL(a.Config.Server.PublicURL, link.Code)
+}
+
+// recordURLButtonClick records the opening of a CTA URL button's tracked link as a tap
+func (a *App) recordURLButtonClick(link *models.ShortLink, clickedAt time.Time) {
+	var source models.Message
+	if err := a.DB.Where("id = ? AND organization_id = ?", link.MessageID, link.OrganizationID).First(&source).Error; err != nil {
+		a.Log.Debug("Message for URL button click not found", "message_id", link.MessageID)
+		return
+	}
+
+	click := models.ButtonClick{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  link.OrganizationID,
+		WhatsAppAccount: source.WhatsAppAccount,
+		ContactID:       source.ContactID,
+		ButtonType:      models.ButtonTypeURL,
+		ButtonID:        link.Code,
+		ButtonText:      link.ButtonText,
+		ClickedAt:       clickedAt,
+	}
+	setClickSource(&click, &source)
+	if err := a.DB.Create(&click).Error; err != nil {
+		a.Log.Error("Failed to record URL button click", "error", err, "message_id", source.ID)
+	}
+}
+
+// GetMessageButtonClicks returns the button clicks recorded against a message
+func (a *App) GetMessageButtonClicks(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	messageID, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid message ID", nil, "")
+	}
+
+	var message models.Message
+	if err := a.DB.Where("id = ? AND organization_id = ?", messageID, orgID).First(&message).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Message not found", nil, "")
+	}
+
+	var clicks []models.ButtonClick
+	if err := a.DB.Where("message_id = ? AND organization_id = ?", messageID, orgID).
+		Order("clicked_at ASC").Find(&clicks).Error; err != nil {
+		a.Log.Error("Failed to list button clicks", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list button clicks", nil, "")
+	}
+
+	response := make([]ButtonClickResponse, len(clicks))
+	for i, c := range clicks {
+		response[i] = buttonClickToResponse(c)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message_id": message.ID,
+		"clicks":     response,
+		"total":      len(response),
+	})
+}
+
+// GetButtonAnalytics returns button click-through rates grouped by template or campaign
+func (a *App) GetButtonAnalytics(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	periodStart, periodEnd, err := parseAnalyticsPeriod(r, a.getOrgLocation(orgID))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	groupBy := string(r.RequestCtx.QueryArgs().Peek("group_by"))
+	var stats []ButtonCTRStats
+	switch groupBy {
+	case "campaign":
+		stats = a.calculateCampaignButtonCTR(orgID, periodStart, periodEnd)
+	case "", "template":
+		groupBy = "template"
+		stats = a.calculateTemplateButtonCTR(orgID, periodStart, periodEnd)
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid group_by. Use 'template' or 'campaign'", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"group_by": groupBy,
+		"stats":    stats,
+	})
+}
+
+type buttonClickAggregate struct {
+	GroupKey     string
+	TotalClicks  int64
+	UniqueClicks int64
+}
+
+type buttonClickBreakdown struct {
+	GroupKey   string
+	ButtonText string
+	ButtonType models.ButtonType
+	Clicks     int64
+}
+
+// aggregateButtonClicks returns click totals and per-button breakdowns keyed by the given column
+func (a *App) aggregateButtonClicks(orgID uuid.UUID, start, end time.Time, column string) ([]buttonClickAggregate, map[string][]ButtonClickCount) {
+	var totals []buttonClickAggregate
+	a.DB.Model(&models.ButtonClick{}).
+		Select(column+"::text as group_key, COUNT(*) as total_clicks, COUNT(DISTINCT message_id) as unique_clicks").
+		Where("organization_id = ? AND clicked_at >= ? AND clicked_at <= ? AND "+column+" IS NOT NULL AND "+column+"::text != ''", orgID, start, end).
+		Group(column).
+		Order("total_clicks DESC").
+		Scan(&totals)
+
+	var breakdown []buttonClickBreakdown
+	a.DB.Model(&models.ButtonClick{}).
+		Select(column+"::text as group_key, button_text, button_type, COUNT(*) as clicks").
+		Where("organization_id = ? AND clicked_at >= ? AND clicked_at <= ? AND "+column+" IS NOT NULL AND "+column+"::text != ''", orgID, start, end).
+		Group(column + ", button_text, button_type").
+		Order("clicks DESC").
+		Scan(&breakdown)
+
+	buttons := make(map[string][]ButtonClickCount)
+	for _, b := range breakdown {
+		buttons[b.GroupKey] = append(buttons[b.GroupKey], ButtonClickCount{
+			ButtonText: b.ButtonText,
+			ButtonType: b.ButtonType,
+			Clicks:     b.Clicks,
+		})
+	}
+
+	return totals, buttons
+}
+
+func (a *App) calculateTemplateButtonCTR(orgID uuid.UUID, start, end time.Time) []ButtonCTRStats {
+	totals, buttons := a.aggregateButtonClicks(orgID, start, end, "template_name")
+
+	stats := make([]ButtonCTRStats, 0, len(totals))
+	for _, t := range totals {
+		var sent int64
+		a.DB.Model(&models.Message{}).
+			Where("organization_id = ? AND direction = ? AND template_name = ? AND status != ? AND created_at >= ? AND created_at <= ?",
+				orgID, models.DirectionOutgoing, t.GroupKey, models.MessageStatusFailed, start, end).
+			Count(&sent)
+
+		stats = append(stats, ButtonCTRStats{
+			TemplateName: t.GroupKey,
+			MessagesSent: sent,
+			TotalClicks:  t.TotalClicks,
+			UniqueClicks: t.UniqueClicks,
+			CTR:          calculateRate(t.UniqueClicks, sent),
+			Buttons:      buttons[t.GroupKey],
+		})
+	}
+
+	return stats
+}
+
+func (a *App) calculateCampaignButtonCTR(orgID uuid.UUID, start, end time.Time) []ButtonCTRStats {
+	totals, buttons := a.aggregateButtonClicks(orgID, start, end, "campaign_id")
+
+	stats := make([]ButtonCTRStats, 0, len(totals))
+	for _, t := range totals {
+		var campaign models.BulkMessageCampaign
+		if err := a.DB.Preload("Template").Where("id = ? AND organization_id = ?", t.GroupKey, orgID).First(&campaign).Error; err != nil {
+			continue
+		}
+
+		stat := ButtonCTRStats{
+			CampaignID:   t.GroupKey,
+			CampaignName: campaign.Name,
+			MessagesSent: int64(campaign.SentCount),
+			TotalClicks:  t.TotalClicks,
+			UniqueClicks: t.UniqueClicks,
+			CTR:          calculateRate(t.UniqueClicks, int64(campaign.SentCount)),
+			Buttons:      buttons[t.GroupKey],
+		}
+		if campaign.Template != nil {
+			stat.TemplateName = campaign.Template.Name
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats
+}
+
+func buttonClickToResponse(c models.ButtonClick) ButtonClickResponse {
+	return ButtonClickResponse{
+		ID:             c.ID,
+		ContactID:      c.ContactID,
+		MessageID:      c.MessageID,
+		ReplyMessageID: c.ReplyMessageID,
+		CampaignID:     c.CampaignID,
+		TemplateName:   c.TemplateName,
+		ButtonType:     c.ButtonType,
+		ButtonID:       c.ButtonID,
+		ButtonText:     c.ButtonText,
+		ClickedAt:      c.ClickedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
@@ -0,0 +1,202 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// messageClicks calls GetMessageButtonClicks for a message
+func messageClicks(t *testing.T, app *handlers.App, orgID, userID, messageID uuid.UUID) []handlers.ButtonClickResponse {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "id", messageID.String())
+	require.NoError(t, app.GetMessageButtonClicks(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Clicks []handlers.ButtonClickResponse `json:"clicks"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	return resp.Clicks
+}
+
+func TestApp_ButtonClick_ReplyTap(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Update("phone_id", "phone-"+uuid.NewString()[:8]).Error)
+	user := createTestUser(t, app, org.ID, uniqueEmail("button-reply"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	contact := &models.Contact{OrganizationID: org.ID, PhoneNumber: "14155550210", ProfileName: "Sam"}
+	require.NoError(t, app.DB.Create(contact).Error)
+
+	// The interactive message the contact answers
+	campaignID := uuid.New()
+	source := &models.Message{
+		OrganizationID:    org.ID,
+		WhatsAppAccount:   account.Name,
+		ContactID:         contact.ID,
+		WhatsAppMessageID: "wamid." + uuid.NewString(),
+		Direction:         models.DirectionOutgoing,
+		MessageType:       models.MessageTypeTemplate,
+		TemplateName:      "order_update",
+		Status:            models.MessageStatusSent,
+		Metadata:          models.JSONB{"campaign_id": campaignID.String()},
+	}
+	require.NoError(t, app.DB.Create(source).Error)
+
+	body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+		"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+		"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+		"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"interactive","context":{"from":"15550001111","id":%q},
+			"interactive":{"type":"button_reply","button_reply":{"id":"track","title":"Track order"}}}]}}]}]}`,
+		account.PhoneID, contact.PhoneNumber, contact.PhoneNumber, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()), source.WhatsAppMessageID)
+	req := testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.Request.SetBody([]byte(body))
+	require.NoError(t, app.WebhookHandler(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var clicks []handlers.ButtonClickResponse
+	require.Eventually(t, func() bool {
+		clicks = messageClicks(t, app, org.ID, user.ID, source.ID)
+		return len(clicks) == 1
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, models.ButtonTypeReply, clicks[0].ButtonType)
+	assert.Equal(t, "track", clicks[0].ButtonID)
+	assert.Equal(t, "Track order", clicks[0].ButtonText)
+	assert.Equal(t, "order_update", clicks[0].TemplateName)
+	require.NotNil(t, clicks[0].CampaignID)
+	assert.Equal(t, campaignID, *clicks[0].CampaignID)
+	assert.NotNil(t, clicks[0].ReplyMessageID)
+}
+
+func TestApp_ButtonClick_URLButton(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	app.Config.Server.PublicURL = "https://wa.example.com"
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	user := createTestUser(t, app, org.ID, uniqueEmail("button-url"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	msg, err := app.SendOutgoingMessage(context.Background(), handlers.OutgoingMessageRequest{
+		Account:         account,
+		Contact:         contact,
+		Type:            models.MessageTypeInteractive,
+		InteractiveType: "cta_url",
+		BodyText:        "Your order has shipped",
+		ButtonText:      "Track",
+		URL:             "https://shop.example.com/orders/42",
+	}, handlers.ChatbotSendOptions())
+	require.NoError(t, err)
+
+	// WhatsApp gets the tracked link; the conversation keeps the real one
+	var link models.ShortLink
+	require.NoError(t, app.DB.Where("message_id = ?", msg.ID).First(&link).Error)
+	assert.Equal(t, "https://shop.example.com/orders/42", link.TargetURL)
+	assert.Equal(t, "Track", link.ButtonText)
+	require.Len(t, mockServer.sentMessages, 1)
+	assert.Contains(t, fmt.Sprint(mockServer.sentMessages[0]), "https://wa.example.com/l/"+link.Code)
+	assert.Equal(t, "https://shop.example.com/orders/42", msg.InteractiveData["url"])
+
+	// Opening the link redirects and counts as a tap on the button
+	req := testutil.NewGETRequest(t)
+	testutil.SetPathParam(req, "code", link.Code)
+	require.NoError(t, app.ShortLinkRedirect(req))
+	assert.Equal(t, fasthttp.StatusFound, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, link.TargetURL, string(req.RequestCtx.Response.Header.Peek("Location")))
+
+	clicks := messageClicks(t, app, org.ID, user.ID, msg.ID)
+	require.Len(t, clicks, 1)
+	assert.Equal(t, models.ButtonTypeURL, clicks[0].ButtonType)
+	assert.Equal(t, "Track", clicks[0].ButtonText)
+	assert.Equal(t, contact.ID, clicks[0].ContactID)
+}
+
+func TestApp_GetButtonAnalytics(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("button-stats"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	contact := createTestContact(t, app, org.ID)
+	template := "promo_" + uuid.NewString()[:8]
+
+	// Three sends of the template, two of them tapped
+	var messages []*models.Message
+	for i := 0; i < 3; i++ {
+		m := &models.Message{
+			OrganizationID:  org.ID,
+			WhatsAppAccount: "stats-account",
+			ContactID:       contact.ID,
+			Direction:       models.DirectionOutgoing,
+			MessageType:     models.MessageTypeTemplate,
+			TemplateName:    template,
+			Status:          models.MessageStatusDelivered,
+		}
+		require.NoError(t, app.DB.Create(m).Error)
+		messages = append(messages, m)
+	}
+	click := func(m *models.Message, buttonType models.ButtonType, text string) {
+		require.NoError(t, app.DB.Create(&models.ButtonClick{
+			OrganizationID:  org.ID,
+			WhatsAppAccount: "stats-account",
+			ContactID:       contact.ID,
+			MessageID:       &m.ID,
+			TemplateName:    template,
+			ButtonType:      buttonType,
+			ButtonText:      text,
+			ClickedAt:       time.Now().Add(-time.Minute),
+		}).Error)
+	}
+	click(messages[0], models.ButtonTypeQuickReply, "Yes")
+	click(messages[0], models.ButtonTypeURL, "Shop now")
+	click(messages[1], models.ButtonTypeQuickReply, "Yes")
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, admin.ID)
+	testutil.SetQueryParam(req, "group_by", "template")
+	require.NoError(t, app.GetButtonAnalytics(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Stats []handlers.ButtonCTRStats `json:"stats"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	require.Len(t, resp.Stats, 1)
+	stat := resp.Stats[0]
+	assert.Equal(t, template, stat.TemplateName)
+	assert.Equal(t, int64(3), stat.MessagesSent)
+	assert.Equal(t, int64(3), stat.TotalClicks)
+	assert.Equal(t, int64(2), stat.UniqueClicks)
+	require.Len(t, stat.Buttons, 2)
+	assert.Equal(t, handlers.ButtonClickCount{ButtonText: "Yes", ButtonType: models.ButtonTypeQuickReply, Clicks: 2}, stat.Buttons[0])
+	assert.Equal(t, handlers.ButtonClickCount{ButtonText: "Shop now", ButtonType: models.ButtonTypeURL, Clicks: 1}, stat.Buttons[1])
+
+	// Invalid grouping, and users without analytics access
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, admin.ID)
+	testutil.SetQueryParam(req, "group_by", "contact")
+	require.NoError(t, app.GetButtonAnalytics(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	agent := createTestUser(t, app, org.ID, uniqueEmail("button-stats-agent"), "password", &createTransferAgentRole(t, app.DB, org.ID).ID, true)
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.GetButtonAnalytics(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
@@ -21,19 +21,45 @@ const (
 	webhooksCacheTTL        = 6 * time.Hour
 	slaSettingsCacheTTL     = 6 * time.Hour
 	aiContextsCacheTTL      = 6 * time.Hour
+	orgVariablesCacheTTL    = 6 * time.Hour
 	userPermissionsCacheTTL = 6 * time.Hour
 	rolePermissionsCacheTTL = 6 * time.Hour
-
-	// Cache key prefixes
-	settingsCachePrefix        = "chatbot:settings:"
-	flowsCachePrefix           = "chatbot:flows:"
-	keywordRulesCachePrefix    = "chatbot:keywords:"
-	whatsappAccountCachePrefix = "whatsapp:account:"
-	webhooksCachePrefix        = "webhooks:"
-	slaSettingsCacheKey        = "chatbot:sla_enabled_settings"
-	aiContextsCachePrefix      = "chatbot:ai_contexts:"
-	userPermissionsCachePrefix = "permissions:user:"
-	rolePermissionsCachePrefix = "permissions:role:"
+	orgTimezoneCacheTTL     = 6 * time.Hour
+	orgCountriesCacheTTL    = 6 * time.Hour
+	orgPolicyCacheTTL       = 6 * time.Hour
+	orgMediaPolicyCacheTTL  = 6 * time.Hour
+	orgIPAccessCacheTTL     = 6 * time.Hour
+	orgFeatureFlagsCacheTTL = 6 * time.Hour
+	orgPluginsCacheTTL      = 6 * time.Hour
+	orgScriptsCacheTTL      = 6 * time.Hour
+	orgConsentCacheTTL      = 6 * time.Hour
+	orgSuspendedCacheTTL    = 6 * time.Hour
+	orgDataRegionCacheTTL   = 6 * time.Hour
+
+	// Cache key prefixes. Chatbot settings and flows are versioned so entries cached
+	// before rollout_percent existed aren't read back as a 0% rollout.
+	settingsCachePrefix         = "chatbot:settings:v2:"
+	flowsCachePrefix            = "chatbot:flows:v2:"
+	keywordRulesCachePrefix     = "chatbot:keywords:"
+	whatsappAccountCachePrefix  = "whatsapp:account:"
+	webhooksCachePrefix         = "webhooks:"
+	slaSettingsCacheKey         = "chatbot:sla_enabled_settings"
+	aiContextsCachePrefix       = "chatbot:ai_contexts:"
+	orgVariablesCachePrefix     = "chatbot:variables:"
+	userPermissionsCachePrefix  = "permissions:user:"
+	userOrganizationCachePrefix = "permissions:user_org:"
+	rolePermissionsCachePrefix  = "permissions:role:"
+	orgTimezoneCachePrefix      = "org:timezone:"
+	orgCountriesCachePrefix     = "org:countries:"
+	orgPolicyCachePrefix        = "org:content_policy:"
+	orgMediaPolicyCachePrefix   = "org:media_policy:"
+	orgIPAccessCachePrefix      = "org:ip_access:"
+	orgFeatureFlagsCachePrefix  = "org:feature_flags:"
+	orgPluginsCachePrefix       = "org:plugins:"
+	orgScriptsCachePrefix       = "org:scripts:"
+	orgConsentCachePrefix       = "org:require_consent:"
+	orgSuspendedCachePrefix     = "org:suspended:"
+	orgDataRegionCachePrefix    = "org:data_region:"
 )
 
 // chatbotSettingsCache is used for caching since AI.APIKey has json:"-" tag
@@ -373,17 +399,69 @@ func (a *App) InvalidateAIContextsCache(orgID uuid.UUID) {
 
 // UserPermissions represents cached user permissions
 type UserPermissions struct {
-	RoleID       uuid.UUID `json:"role_id"`
-	RoleName     string    `json:"role_name"`
-	IsSystem     bool      `json:"is_system"`
-	IsSuperAdmin bool      `json:"is_super_admin"`
-	Permissions  []string  `json:"permissions"` // Format: "resource:action"
+	RoleID             uuid.UUID `json:"role_id"`
+	RoleName           string    `json:"role_name"`
+	IsSystem           bool      `json:"is_system"`
+	IsSuperAdmin       bool      `json:"is_super_admin"`
+	RestrictDataAccess bool      `json:"restrict_data_access"`
+	Permissions        []string  `json:"permissions"` // Format: "resource:action"
+}
+
+// userOrganizationRoles is the role a user has in each organization they can work in:
+// their own organization and the ones they are a member of
+type userOrganizationRoles struct {
+	IsSuperAdmin bool                     `json:"is_super_admin"`
+	Roles        map[uuid.UUID]*uuid.UUID `json:"roles"` // Organization ID to role ID
 }
 
-// getUserPermissionsCached retrieves user permissions from cache or database
-func (a *App) getUserPermissionsCached(userID uuid.UUID) (*UserPermissions, error) {
+// getUserOrganizationRolesCached retrieves the user's roles per organization from cache
+// or database
+func (a *App) getUserOrganizationRolesCached(userID uuid.UUID) (*userOrganizationRoles, error) {
 	ctx := context.Background()
-	cacheKey := fmt.Sprintf("%s%s", userPermissionsCachePrefix, userID.String())
+	cacheKey := fmt.Sprintf("%s%s", userOrganizationCachePrefix, userID.String())
+
+	if a.Redis != nil {
+		cached, err := a.Redis.Get(ctx, cacheKey).Result()
+		if err == nil && cached != "" {
+			var roles userOrganizationRoles
+			if err := json.Unmarshal([]byte(cached), &roles); err == nil && roles.Roles != nil {
+				return &roles, nil
+			}
+		}
+	}
+
+	var user models.User
+	if err := a.DB.Select("id, organization_id, role_id, is_super_admin").Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	var memberships []models.UserOrganization
+	if err := a.DB.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+
+	roles := userOrganizationRoles{
+		IsSuperAdmin: user.IsSuperAdmin,
+		Roles:        make(map[uuid.UUID]*uuid.UUID, len(memberships)+1),
+	}
+	for _, m := range memberships {
+		roles.Roles[m.OrganizationID] = m.RoleID
+	}
+	roles.Roles[user.OrganizationID] = user.RoleID
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(roles); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, userPermissionsCacheTTL)
+		}
+	}
+
+	return &roles, nil
+}
+
+// getUserPermissionsCached retrieves the user's permissions in an organization from cache
+// or database. They are those of the user's role there.
+func (a *App) getUserPermissionsCached(userID, orgID uuid.UUID) (*UserPermissions, error) {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s:%s", userPermissionsCachePrefix, userID.String(), orgID.String())
 
 	// Try cache first (if Redis is available)
 	if a.Redis != nil {
@@ -397,28 +475,29 @@ func (a *App) getUserPermissionsCached(userID uuid.UUID) (*UserPermissions, erro
 	}
 
 	// Cache miss - fetch from database
-	var user models.User
-	if err := a.DB.Preload("Role.Permissions").Where("id = ?", userID).First(&user).Error; err != nil {
+	roles, err := a.getUserOrganizationRolesCached(userID)
+	if err != nil {
 		return nil, err
 	}
 
-	if user.Role == nil {
+	perms := UserPermissions{IsSuperAdmin: roles.IsSuperAdmin, Permissions: []string{}}
+	if roleID := roles.Roles[orgID]; roleID != nil {
+		var role models.CustomRole
+		if err := a.DB.Preload("Permissions").Where("id = ?", roleID).First(&role).Error; err != nil {
+			return nil, err
+		}
+		perms.RoleID = role.ID
+		perms.RoleName = role.Name
+		perms.IsSystem = role.IsSystem
+		perms.RestrictDataAccess = role.RestrictDataAccess
+		for _, p := range role.Permissions {
+			perms.Permissions = append(perms.Permissions, p.Resource+":"+p.Action)
+		}
+	} else if !roles.IsSuperAdmin {
+		// No role in this organization; super admins act in every organization without one
 		return nil, gorm.ErrRecordNotFound
 	}
 
-	// Build permissions list
-	perms := UserPermissions{
-		RoleID:       user.Role.ID,
-		RoleName:     user.Role.Name,
-		IsSystem:     user.Role.IsSystem,
-		IsSuperAdmin: user.IsSuperAdmin,
-		Permissions:  make([]string, 0, len(user.Role.Permissions)),
-	}
-
-	for _, p := range user.Role.Permissions {
-		perms.Permissions = append(perms.Permissions, p.Resource+":"+p.Action)
-	}
-
 	// Cache the result (if Redis is available)
 	if a.Redis != nil {
 		if data, err := json.Marshal(perms); err == nil {
@@ -429,12 +508,12 @@ func (a *App) getUserPermissionsCached(userID uuid.UUID) (*UserPermissions, erro
 	return &perms, nil
 }
 
-// HasPermission checks if a user has a specific permission
+// HasPermission checks if a user has a specific permission in an organization
 // Super admins have all permissions automatically
-func (a *App) HasPermission(userID uuid.UUID, resource, action string) bool {
-	perms, err := a.getUserPermissionsCached(userID)
+func (a *App) HasPermission(userID, orgID uuid.UUID, resource, action string) bool {
+	perms, err := a.getUserPermissionsCached(userID, orgID)
 	if err != nil {
-		a.Log.Error("Failed to get user permissions", "error", err, "user_id", userID)
+		a.Log.Error("Failed to get user permissions", "error", err, "user_id", userID, "organization_id", orgID)
 		return false
 	}
 
@@ -453,12 +532,25 @@ func (a *App) HasPermission(userID uuid.UUID, resource, action string) bool {
 	return false
 }
 
-// HasAnyPermission checks if a user has any of the specified permissions
+// IsOrganizationMember reports whether the user can work in orgID: it is their own
+// organization or one they are a member of. Tokens and API keys carry the
+// organization they were issued for, so this is checked on every request. Super admins
+// act in every organization.
+func (a *App) IsOrganizationMember(userID, orgID uuid.UUID) bool {
+	roles, err := a.getUserOrganizationRolesCached(userID)
+	if err != nil {
+		return false
+	}
+	_, ok := roles.Roles[orgID]
+	return ok || roles.IsSuperAdmin
+}
+
+// HasAnyPermission checks if a user has any of the specified permissions in an organization
 // Super admins have all permissions automatically
-func (a *App) HasAnyPermission(userID uuid.UUID, permissions ...string) bool {
-	perms, err := a.getUserPermissionsCached(userID)
+func (a *App) HasAnyPermission(userID, orgID uuid.UUID, permissions ...string) bool {
+	perms, err := a.getUserPermissionsCached(userID, orgID)
 	if err != nil {
-		a.Log.Error("Failed to get user permissions", "error", err, "user_id", userID)
+		a.Log.Error("Failed to get user permissions", "error", err, "user_id", userID, "organization_id", orgID)
 		return false
 	}
 
@@ -483,11 +575,22 @@ func (a *App) HasAnyPermission(userID uuid.UUID, permissions ...string) bool {
 
 // IsSuperAdmin checks if a user is a super admin
 func (a *App) IsSuperAdmin(userID uuid.UUID) bool {
-	perms, err := a.getUserPermissionsCached(userID)
+	roles, err := a.getUserOrganizationRolesCached(userID)
 	if err != nil {
 		return false
 	}
-	return perms.IsSuperAdmin
+	return roles.IsSuperAdmin
+}
+
+// HasRestrictedDataAccess checks if a user's role in an organization hides contact details
+// and message content. Super admins are never restricted; users whose permissions can't
+// be loaded are.
+func (a *App) HasRestrictedDataAccess(userID, orgID uuid.UUID) bool {
+	perms, err := a.getUserPermissionsCached(userID, orgID)
+	if err != nil {
+		return true
+	}
+	return perms.RestrictDataAccess && !perms.IsSuperAdmin
 }
 
 // ScopedQuery returns a gorm query scoped to the organization
@@ -536,16 +639,25 @@ func (a *App) GetRolePermissionsCached(roleID uuid.UUID) ([]string, error) {
 	return perms, nil
 }
 
-// InvalidateUserPermissionsCache invalidates the permissions cache for a user
+// InvalidateUserPermissionsCache invalidates the permissions cache for a user in every
+// organization
 func (a *App) InvalidateUserPermissionsCache(userID uuid.UUID) {
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("%s%s", userPermissionsCachePrefix, userID.String())
-	a.Redis.Del(ctx, cacheKey)
+	a.deleteUserPermissionsCache(userID)
 
 	// Notify user via WebSocket to refresh their permissions
 	a.notifyUserPermissionsChanged(userID)
 }
 
+// deleteUserPermissionsCache drops the user's cached roles and permissions
+func (a *App) deleteUserPermissionsCache(userID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	a.Redis.Del(ctx, fmt.Sprintf("%s%s", userOrganizationCachePrefix, userID.String()))
+	a.deleteKeysByPattern(ctx, fmt.Sprintf("%s%s:*", userPermissionsCachePrefix, userID.String()))
+}
+
 // InvalidateRolePermissionsCache invalidates the permissions cache for a role and all users with that role
 func (a *App) InvalidateRolePermissionsCache(roleID uuid.UUID) {
 	ctx := context.Background()
@@ -554,19 +666,24 @@ func (a *App) InvalidateRolePermissionsCache(roleID uuid.UUID) {
 	roleCacheKey := fmt.Sprintf("%s%s", rolePermissionsCachePrefix, roleID.String())
 	a.Redis.Del(ctx, roleCacheKey)
 
-	// Find all users with this role and invalidate their cache
-	var users []models.User
-	if err := a.DB.Select("id, organization_id").Where("role_id = ?", roleID).Find(&users).Error; err != nil {
+	// Find all users with this role, in their own organization or one they are a member
+	// of, and invalidate their cache
+	var userIDs []uuid.UUID
+	if err := a.DB.Model(&models.User{}).Where("role_id = ?", roleID).Pluck("id", &userIDs).Error; err != nil {
 		a.Log.Error("Failed to find users for role permission cache invalidation", "error", err, "role_id", roleID)
 		return
 	}
+	var memberIDs []uuid.UUID
+	if err := a.DB.Model(&models.UserOrganization{}).Where("role_id = ?", roleID).Pluck("user_id", &memberIDs).Error; err != nil {
+		a.Log.Error("Failed to find members for role permission cache invalidation", "error", err, "role_id", roleID)
+		return
+	}
 
-	for _, user := range users {
-		userCacheKey := fmt.Sprintf("%s%s", userPermissionsCachePrefix, user.ID.String())
-		a.Redis.Del(ctx, userCacheKey)
+	for _, userID := range append(userIDs, memberIDs...) {
+		a.deleteUserPermissionsCache(userID)
 
 		// Notify user via WebSocket to refresh their permissions
-		a.notifyUserPermissionsChanged(user.ID)
+		a.notifyUserPermissionsChanged(userID)
 	}
 }
 
@@ -590,15 +707,17 @@ func (a *App) notifyUserPermissionsChanged(userID uuid.UUID) {
 		return
 	}
 
-	// Get user's organization ID for the broadcast
-	var user models.User
-	if err := a.DB.Select("organization_id").Where("id = ?", userID).First(&user).Error; err != nil {
+	// The user may be connected in any of their organizations
+	roles, err := a.getUserOrganizationRolesCached(userID)
+	if err != nil {
 		a.Log.Error("Failed to find user for permissions notification", "error", err, "user_id", userID)
 		return
 	}
 
-	a.WSHub.BroadcastToUser(user.OrganizationID, userID, websocket.WSMessage{
-		Type:    websocket.TypePermissionsUpdated,
-		Payload: map[string]string{"message": "Your permissions have been updated"},
-	})
+	for orgID := range roles.Roles {
+		a.WSHub.BroadcastToUser(orgID, userID, websocket.WSMessage{
+			Type:    websocket.TypePermissionsUpdated,
+			Payload: map[string]string{"message": "Your permissions have been updated"},
+		})
+	}
 }
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/calendar"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	// calendarStateTTL is how long a calendar OAuth state token stays valid
+	calendarStateTTL = 10 * time.Minute
+	// calendarCallbackPath is the OAuth redirect path to register with the SSO client
+	calendarCallbackPath = "/api/me/integrations/calendar/callback"
+	// calendarLookahead is how far ahead busy periods are read to find when a busy stretch ends
+	calendarLookahead = 12 * time.Hour
+	// calendarCheckTimeout bounds a single calendar check
+	calendarCheckTimeout = 20 * time.Second
+)
+
+// CalendarState is stored in Redis during the calendar OAuth flow
+type CalendarState struct {
+	OrgID    uuid.UUID `json:"org_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Provider string    `json:"provider"`
+}
+
+// CalendarIntegrationResponse describes a user's calendar connection (tokens omitted)
+type CalendarIntegrationResponse struct {
+	Provider      string     `json:"provider"`
+	AccountEmail  string     `json:"account_email"`
+	ConnectedAt   time.Time  `json:"connected_at"`
+	MarkedAway    bool       `json:"marked_away"`
+	BusyUntil     *time.Time `json:"busy_until,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	SyncError     string     `json:"sync_error,omitempty"`
+}
+
+// MyIntegrationsResponse lists the current user's integrations
+type MyIntegrationsResponse struct {
+	Calendar            *CalendarIntegrationResponse `json:"calendar"`
+	CalendarProviders   []string                     `json:"calendar_providers"` // Providers the organization has OAuth clients for
+	CalendarRedirectURL string                       `json:"calendar_redirect_url"`
+}
+
+// GetMyIntegrations returns the current user's personal integrations
+func (a *App) GetMyIntegrations(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	response := MyIntegrationsResponse{
+		CalendarProviders:   []string{},
+		CalendarRedirectURL: a.oauthCallbackURL(r, calendarCallbackPath),
+	}
+
+	var providers []models.SSOProvider
+	a.DB.Where("organization_id = ? AND provider IN ?", orgID, []string{calendar.ProviderGoogle, calendar.ProviderMicrosoft}).
+		Order("provider").Find(&providers)
+	for _, p := range providers {
+		response.CalendarProviders = append(response.CalendarProviders, p.Provider)
+	}
+
+	var conn models.CalendarConnection
+	if err := a.DB.Where("user_id = ?", userID).First(&conn).Error; err == nil {
+		response.Calendar = &CalendarIntegrationResponse{
+			Provider:      conn.Provider,
+			AccountEmail:  conn.AccountEmail,
+			ConnectedAt:   conn.CreatedAt,
+			MarkedAway:    conn.MarkedAway,
+			BusyUntil:     conn.BusyUntil,
+			LastCheckedAt: conn.LastCheckedAt,
+			SyncError:     conn.SyncError,
+		}
+	}
+
+	return r.SendEnvelope(response)
+}
+
+// ConnectCalendar starts the calendar OAuth flow and returns the provider consent URL
+func (a *App) ConnectCalendar(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	provider, _ := r.RequestCtx.UserValue("provider").(string)
+	if !calendar.IsSupported(provider) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid calendar provider", nil, "")
+	}
+
+	ssoConfig, err := a.calendarSSOConfig(orgID, provider)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Your organization has not configured this provider", nil, "")
+	}
+
+	nonce := generateRandomString(32)
+	stateJSON, _ := json.Marshal(CalendarState{OrgID: orgID, UserID: userID, Provider: provider})
+	if err := a.Redis.Set(r.RequestCtx, "calendar:state:"+nonce, stateJSON, calendarStateTTL).Err(); err != nil {
+		a.Log.Error("Failed to store calendar state", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start calendar authorization", nil, "")
+	}
+
+	// Force the consent screen so the provider always returns a refresh token
+	oauthConfig := calendarOAuthConfig(ssoConfig, a.oauthCallbackURL(r, calendarCallbackPath))
+	authURL := oauthConfig.AuthCodeURL(nonce, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+
+	return r.SendEnvelope(map[string]string{"auth_url": authURL})
+}
+
+// CalendarCallback completes the calendar OAuth flow and stores the tokens (public, state-checked)
+func (a *App) CalendarCallback(r *fastglue.Request) error {
+	code := string(r.RequestCtx.QueryArgs().Peek("code"))
+	nonce := string(r.RequestCtx.QueryArgs().Peek("state"))
+
+	if errorParam := string(r.RequestCtx.QueryArgs().Peek("error")); errorParam != "" {
+		a.redirectToCalendarSettings(r, "error", "Calendar authorization failed: "+errorParam)
+		return nil
+	}
+	if code == "" || nonce == "" {
+		a.redirectToCalendarSettings(r, "error", "Invalid callback parameters")
+		return nil
+	}
+
+	stateKey := "calendar:state:" + nonce
+	stateJSON, err := a.Redis.Get(r.RequestCtx, stateKey).Bytes()
+	if err != nil {
+		a.redirectToCalendarSettings(r, "error", "Invalid or expired state")
+		return nil
+	}
+	// Delete state immediately to prevent replay
+	a.Redis.Del(r.RequestCtx, stateKey)
+
+	var state CalendarState
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		a.redirectToCalendarSettings(r, "error", "Invalid state")
+		return nil
+	}
+
+	ssoConfig, err := a.calendarSSOConfig(state.OrgID, state.Provider)
+	if err != nil {
+		a.redirectToCalendarSettings(r, "error", "Calendar provider is no longer configured")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	oauthConfig := calendarOAuthConfig(ssoConfig, a.oauthCallbackURL(r, calendarCallbackPath))
+	token, err := oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		a.Log.Error("Failed to exchange calendar OAuth code", "error", err, "user_id", state.UserID)
+		a.redirectToCalendarSettings(r, "error", "Failed to authorize calendar access")
+		return nil
+	}
+
+	var email string
+	if info, err := a.fetchUserInfo(state.Provider, ssoConfig, token); err == nil {
+		email = info.Email
+	} else {
+		a.Log.Warn("Failed to fetch calendar account email", "error", err)
+	}
+
+	// Connecting a calendar replaces any previous one
+	var conn models.CalendarConnection
+	if err := a.DB.Where("user_id = ?", state.UserID).First(&conn).Error; err != nil {
+		conn = models.CalendarConnection{OrganizationID: state.OrgID, UserID: state.UserID}
+	} else if conn.Provider != state.Provider {
+		conn.RefreshToken = ""
+	}
+	conn.Provider = state.Provider
+	conn.AccountEmail = email
+	conn.SyncError = ""
+	applyCalendarToken(&conn, token)
+	if err := a.DB.Save(&conn).Error; err != nil {
+		a.Log.Error("Failed to save calendar connection", "error", err)
+		a.redirectToCalendarSettings(r, "error", "Failed to save calendar authorization")
+		return nil
+	}
+
+	a.Log.Info("Calendar connected", "user_id", state.UserID, "provider", state.Provider)
+	a.redirectToCalendarSettings(r, "connected", "")
+	return nil
+}
+
+// DisconnectCalendar removes the current user's calendar connection, making them
+// available again if the calendar had marked them away
+func (a *App) DisconnectCalendar(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var conn models.CalendarConnection
+	if err := a.DB.Where("user_id = ?", userID).First(&conn).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No calendar connected", nil, "")
+	}
+	if err := a.DB.Delete(&conn).Error; err != nil {
+		a.Log.Error("Failed to disconnect calendar", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to disconnect calendar", nil, "")
+	}
+
+	if conn.MarkedAway {
+		var user models.User
+		if err := a.DB.Where("id = ?", userID).First(&user).Error; err == nil && !user.IsAvailable {
+			if _, err := a.setUserAvailability(&user, orgID, true); err != nil {
+				a.Log.Error("Failed to restore availability", "error", err, "user_id", userID)
+			}
+		}
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Calendar disconnected"})
+}
+
+// overrideCalendarAvailability stops the calendar from changing the user's availability
+// until the current busy stretch ends. Called when the user sets their status manually.
+func (a *App) overrideCalendarAvailability(userID uuid.UUID) {
+	if err := a.DB.Model(&models.CalendarConnection{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"marked_away": false, "ignore_until": gorm.Expr("busy_until")}).Error; err != nil {
+		a.Log.Error("Failed to record calendar override", "error", err, "user_id", userID)
+	}
+}
+
+// checkCalendar reads the connection's calendar and marks the user away during busy
+// events, restoring them once the calendar frees up
+func (a *App) checkCalendar(ctx context.Context, conn *models.CalendarConnection) {
+	now := time.Now()
+	conn.LastCheckedAt = &now
+
+	intervals, err := a.fetchCalendarBusy(ctx, conn, now)
+	if err != nil {
+		a.Log.Warn("Calendar check failed", "error", err, "user_id", conn.UserID)
+		conn.SyncError = err.Error()
+		a.saveCalendarState(conn)
+		return
+	}
+	conn.SyncError = ""
+
+	var user models.User
+	if err := a.DB.Where("id = ? AND is_active = ?", conn.UserID, true).First(&user).Error; err != nil {
+		a.saveCalendarState(conn)
+		return
+	}
+
+	busy, until := calendar.BusyAt(intervals, now)
+	if busy {
+		conn.BusyUntil = &until
+		overridden := conn.IgnoreUntil != nil && now.Before(*conn.IgnoreUntil)
+		if !overridden && !conn.MarkedAway && user.IsAvailable {
+			if _, err := a.setUserAvailability(&user, conn.OrganizationID, false); err != nil {
+				a.Log.Error("Failed to mark user away for calendar event", "error", err, "user_id", user.ID)
+			} else {
+				conn.MarkedAway = true
+				a.Log.Info("User marked away for calendar event", "user_id", user.ID, "until", until)
+			}
+		}
+	} else {
+		conn.BusyUntil = nil
+		conn.IgnoreUntil = nil
+		if conn.MarkedAway {
+			// Only undo our own change; a user who is available again, or whose shift
+			// ended meanwhile, needs nothing
+			if !user.IsAvailable && (user.OnShift == nil || *user.OnShift) {
+				if _, err := a.setUserAvailability(&user, conn.OrganizationID, true); err != nil {
+					a.Log.Error("Failed to restore availability after calendar event", "error", err, "user_id", user.ID)
+					a.saveCalendarState(conn)
+					return
+				}
+			}
+			conn.MarkedAway = false
+		}
+	}
+
+	a.saveCalendarState(conn)
+}
+
+// fetchCalendarBusy reads busy periods from now through the lookahead window with the
+// connection's credentials, persisting refreshed tokens
+func (a *App) fetchCalendarBusy(ctx context.Context, conn *models.CalendarConnection, now time.Time) ([]calendar.Interval, error) {
+	ssoConfig, err := a.calendarSSOConfig(conn.OrganizationID, conn.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("%s is no longer configured for your organization", conn.Provider)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  conn.AccessToken,
+		RefreshToken: conn.RefreshToken,
+		TokenType:    "Bearer",
+	}
+	if conn.TokenExpiry != nil {
+		token.Expiry = *conn.TokenExpiry
+	}
+
+	current, err := calendarOAuthConfig(ssoConfig, "").TokenSource(ctx, token).Token()
+	if err != nil {
+		return nil, fmt.Errorf("calendar authorization expired, reconnect your calendar: %w", err)
+	}
+	if current.AccessToken != conn.AccessToken {
+		applyCalendarToken(conn, current)
+		if err := a.DB.Model(conn).Select("access_token", "refresh_token", "token_expiry").Updates(conn).Error; err != nil {
+			a.Log.Error("Failed to save refreshed calendar token", "error", err)
+		}
+	}
+
+	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(current))
+	return calendar.FetchBusy(ctx, client, conn.Provider, now, now.Add(calendarLookahead))
+}
+
+// saveCalendarState persists the connection's sync fields
+func (a *App) saveCalendarState(conn *models.CalendarConnection) {
+	if err := a.DB.Model(conn).
+		Select("marked_away", "busy_until", "ignore_until", "last_checked_at", "sync_error").
+		Updates(conn).Error; err != nil {
+		a.Log.Error("Failed to save calendar state", "error", err, "user_id", conn.UserID)
+	}
+}
+
+// calendarSSOConfig returns the organization's OAuth client for a calendar provider
+func (a *App) calendarSSOConfig(orgID uuid.UUID, provider string) (*models.SSOProvider, error) {
+	var ssoConfig models.SSOProvider
+	if err := a.DB.Where("organization_id = ? AND provider = ?", orgID, provider).First(&ssoConfig).Error; err != nil {
+		return nil, err
+	}
+	return &ssoConfig, nil
+}
+
+// calendarOAuthConfig builds the OAuth config for calendar access using an SSO client
+func calendarOAuthConfig(ssoConfig *models.SSOProvider, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     ssoConfig.ClientID,
+		ClientSecret: ssoConfig.ClientSecret,
+		Endpoint:     oauthProviders[ssoConfig.Provider].Endpoint,
+		Scopes:       calendar.Scopes[ssoConfig.Provider],
+		RedirectURL:  redirectURL,
+	}
+}
+
+// redirectToCalendarSettings sends the browser back to the profile page
+func (a *App) redirectToCalendarSettings(r *fastglue.Request, status, message string) {
+	basePath := a.Config.Server.BasePath
+	if basePath != "" && basePath[0] != '/' {
+		basePath = "/" + basePath
+	}
+	redirectURL := fmt.Sprintf("%s/profile?calendar=%s", basePath, status)
+	if message != "" {
+		redirectURL += "&message=" + url.QueryEscape(message)
+	}
+	r.RequestCtx.Redirect(redirectURL, fasthttp.StatusTemporaryRedirect)
+}
+
+// applyCalendarToken copies token fields onto the connection, keeping the stored refresh
+// token when the provider doesn't return a new one
+func applyCalendarToken(conn *models.CalendarConnection, token *oauth2.Token) {
+	conn.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		conn.RefreshToken = token.RefreshToken
+	}
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry
+		conn.TokenExpiry = &expiry
+	}
+}
+
+// CalendarAvailabilityProcessor periodically syncs agent availability from connected calendars
+type CalendarAvailabilityProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewCalendarAvailabilityProcessor creates a new calendar availability processor
+func NewCalendarAvailabilityProcessor(app *App, interval time.Duration) *CalendarAvailabilityProcessor {
+	return &CalendarAvailabilityProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the sync loop
+func (p *CalendarAvailabilityProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Calendar availability processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Calendar availability processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Calendar availability processor stopped")
+			return
+		case <-ticker.C:
+			p.checkConnections(ctx)
+		}
+	}
+}
+
+// Stop stops the calendar availability processor
+func (p *CalendarAvailabilityProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// checkConnections checks every connected calendar once
+func (p *CalendarAvailabilityProcessor) checkConnections(ctx context.Context) {
+	var conns []models.CalendarConnection
+	if err := p.app.DB.Where("refresh_token <> ''").Find(&conns).Error; err != nil {
+		p.app.Log.Error("Failed to load calendar connections", "error", err)
+		return
+	}
+
+	for i := range conns {
+		if ctx.Err() != nil {
+			return
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, calendarCheckTimeout)
+		p.app.checkCalendar(checkCtx, &conns[i])
+		cancel()
+	}
+}
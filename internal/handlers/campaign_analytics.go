@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/optout"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// maxComparedCampaigns caps how many campaigns can be compared at once
+	maxComparedCampaigns = 10
+	// campaignReplyWindow is how long after a send an incoming message counts as a reply
+	campaignReplyWindow = 7 * 24 * time.Hour
+	// defaultCohortWeeks is how many weeks of responder retention are reported
+	defaultCohortWeeks = 8
+	maxCohortWeeks     = 26
+)
+
+// replyTimeBuckets are the upper bounds of the reply-time distribution buckets
+var replyTimeBuckets = []struct {
+	Label string
+	Max   time.Duration
+}{
+	{"<5m", 5 * time.Minute},
+	{"5-30m", 30 * time.Minute},
+	{"30m-1h", time.Hour},
+	{"1-4h", 4 * time.Hour},
+	{"4-24h", 24 * time.Hour},
+	{"1-7d", campaignReplyWindow},
+}
+
+// ReplyTimeBucket is one bar of a reply-time distribution
+type ReplyTimeBucket struct {
+	Label string `json:"label"`
+	Count int64  `json:"count"`
+}
+
+// CohortWeek is the share of a campaign's responders that messaged again in a given week
+type CohortWeek struct {
+	Week      int     `json:"week"` // Weeks after the campaign was sent; week 0 is the reply week
+	Active    int64   `json:"active"`
+	Retention float64 `json:"retention"` // Percentage of responders
+}
+
+// CampaignComparison is the side-by-side report for a single campaign
+type CampaignComparison struct {
+	ID              uuid.UUID         `json:"id"`
+	Name            string            `json:"name"`
+	Status          string            `json:"status"`
+	TemplateName    string            `json:"template_name"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	SentCount       int               `json:"sent_count"`
+	DeliveredCount  int               `json:"delivered_count"`
+	ReadCount       int               `json:"read_count"`
+	FailedCount     int               `json:"failed_count"`
+	ReplyCount      int64             `json:"reply_count"`
+	OptOutCount     int64             `json:"opt_out_count"`
+	DeliveryRate    float64           `json:"delivery_rate"`
+	ReadRate        float64           `json:"read_rate"`
+	ReplyRate       float64           `json:"reply_rate"`
+	OptOutRate      float64           `json:"opt_out_rate"`
+	MedianReplyMins float64           `json:"median_reply_mins"`
+	ReplyTimes      []ReplyTimeBucket `json:"reply_times"`
+	Cohort          []CohortWeek      `json:"cohort"`
+}
+
+// campaignSendRow is one contact reached by a campaign with their first reply, if any
+type campaignSendRow struct {
+	CampaignID   string
+	ContactID    uuid.UUID
+	SentAt       time.Time
+	FirstReplyAt *time.Time
+	OptedOut     bool
+}
+
+// GetCampaignComparison returns delivery, read, reply and opt-out rates, reply-time
+// distributions and weekly responder retention for the campaigns in ?ids=
+func (a *App) GetCampaignComparison(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access denied", nil, "")
+	}
+
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, s := range strings.Split(string(r.RequestCtx.QueryArgs().Peek("ids")), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID: "+s, nil, "")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "ids is required", nil, "")
+	}
+	if len(ids) > maxComparedCampaigns {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Too many campaigns (max "+strconv.Itoa(maxComparedCampaigns)+")", nil, "")
+	}
+
+	weeks := defaultCohortWeeks
+	if v := string(r.RequestCtx.QueryArgs().Peek("weeks")); v != "" {
+		weeks, err = strconv.Atoi(v)
+		if err != nil || weeks < 1 || weeks > maxCohortWeeks {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "weeks must be between 1 and "+strconv.Itoa(maxCohortWeeks), nil, "")
+		}
+	}
+
+	var campaigns []models.BulkMessageCampaign
+	if err := a.DB.Where("organization_id = ? AND id IN ?", orgID, ids).
+		Preload("Template").Find(&campaigns).Error; err != nil {
+		a.Log.Error("Failed to load campaigns", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load campaigns", nil, "")
+	}
+	if len(campaigns) != len(ids) {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+
+	sends, err := a.loadCampaignSends(orgID, ids)
+	if err != nil {
+		a.Log.Error("Failed to load campaign replies", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load campaign replies", nil, "")
+	}
+	activity, err := a.loadResponderActivity(orgID, ids, weeks)
+	if err != nil {
+		a.Log.Error("Failed to load responder activity", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load responder activity", nil, "")
+	}
+
+	// Keep the order the campaigns were requested in
+	byID := make(map[uuid.UUID]models.BulkMessageCampaign, len(campaigns))
+	for _, c := range campaigns {
+		byID[c.ID] = c
+	}
+
+	result := make([]CampaignComparison, 0, len(ids))
+	for _, id := range ids {
+		c := byID[id]
+		comparison := CampaignComparison{
+			ID:             c.ID,
+			Name:           c.Name,
+			Status:         string(c.Status),
+			StartedAt:      c.StartedAt,
+			SentCount:      c.SentCount,
+			DeliveredCount: c.DeliveredCount,
+			ReadCount:      c.ReadCount,
+			FailedCount:    c.FailedCount,
+			DeliveryRate:   calculateRate(int64(c.DeliveredCount), int64(c.SentCount)),
+			ReadRate:       calculateRate(int64(c.ReadCount), int64(c.SentCount)),
+		}
+		if c.Template != nil {
+			comparison.TemplateName = c.Template.Name
+		}
+
+		var replyTimes []time.Duration
+		for _, s := range sends[c.ID.String()] {
+			if s.FirstReplyAt != nil {
+				comparison.ReplyCount++
+				replyTimes = append(replyTimes, s.FirstReplyAt.Sub(s.SentAt))
+			}
+			if s.OptedOut {
+				comparison.OptOutCount++
+			}
+		}
+		reached := int64(len(sends[c.ID.String()]))
+		comparison.ReplyRate = calculateRate(comparison.ReplyCount, reached)
+		comparison.OptOutRate = calculateRate(comparison.OptOutCount, reached)
+		comparison.ReplyTimes = bucketReplyTimes(replyTimes)
+		comparison.MedianReplyMins = medianMinutes(replyTimes)
+		comparison.Cohort = buildCohort(comparison.ReplyCount, activity[c.ID.String()], weeks)
+
+		result = append(result, comparison)
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"campaigns":         result,
+		"reply_window_days": int(campaignReplyWindow.Hours() / 24),
+		"weeks":             weeks,
+	})
+}
+
+// loadCampaignSends returns, per campaign ID, every contact the campaign reached with their
+// first reply inside the reply window and whether that window contains an opt-out keyword
+func (a *App) loadCampaignSends(orgID uuid.UUID, campaignIDs []uuid.UUID) (map[string][]campaignSendRow, error) {
+	keywords := a.orgOptOutKeywords(orgID)
+	if len(keywords) == 0 {
+		// Keyword opt-outs are off, but replies like STOP still say something about a campaign
+		keywords = optout.DefaultKeywords
+	}
+
+	var rows []campaignSendRow
+	err := a.DB.Raw(`
+		WITH sends AS (
+			SELECT metadata->>'campaign_id' AS campaign_id, contact_id, MIN(created_at) AS sent_at
+			FROM messages
+			WHERE organization_id = ? AND direction = ? AND status <> ? AND deleted_at IS NULL
+				AND metadata->>'campaign_id' IN ?
+			GROUP BY 1, 2
+		)
+		SELECT s.campaign_id, s.contact_id, s.sent_at,
+			(SELECT MIN(i.created_at) FROM messages i
+				WHERE i.contact_id = s.contact_id AND i.direction = ? AND i.deleted_at IS NULL
+					AND i.created_at > s.sent_at AND i.created_at <= s.sent_at + make_interval(secs => ?)) AS first_reply_at,
+			EXISTS (SELECT 1 FROM messages i
+				WHERE i.contact_id = s.contact_id AND i.direction = ? AND i.deleted_at IS NULL
+					AND i.created_at > s.sent_at AND i.created_at <= s.sent_at + make_interval(secs => ?)
+					AND UPPER(TRIM(i.content)) IN ?) AS opted_out
+		FROM sends s`,
+		orgID, models.DirectionOutgoing, models.MessageStatusFailed, uuidStrings(campaignIDs),
+		models.DirectionIncoming, campaignReplyWindow.Seconds(),
+		models.DirectionIncoming, campaignReplyWindow.Seconds(), keywords,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]campaignSendRow)
+	for _, row := range rows {
+		result[row.CampaignID] = append(result[row.CampaignID], row)
+	}
+	return result, nil
+}
+
+// loadResponderActivity returns, per campaign ID and week after the send, how many of the
+// campaign's responders sent at least one message that week
+func (a *App) loadResponderActivity(orgID uuid.UUID, campaignIDs []uuid.UUID, weeks int) (map[string]map[int]int64, error) {
+	var rows []struct {
+		CampaignID string
+		Week       int
+		Active     int64
+	}
+	err := a.DB.Raw(`
+		WITH sends AS (
+			SELECT metadata->>'campaign_id' AS campaign_id, contact_id, MIN(created_at) AS sent_at
+			FROM messages
+			WHERE organization_id = ? AND direction = ? AND status <> ? AND deleted_at IS NULL
+				AND metadata->>'campaign_id' IN ?
+			GROUP BY 1, 2
+		),
+		responders AS (
+			SELECT s.* FROM sends s
+			WHERE EXISTS (SELECT 1 FROM messages i
+				WHERE i.contact_id = s.contact_id AND i.direction = ? AND i.deleted_at IS NULL
+					AND i.created_at > s.sent_at AND i.created_at <= s.sent_at + make_interval(secs => ?))
+		)
+		SELECT r.campaign_id,
+			FLOOR(EXTRACT(EPOCH FROM (i.created_at - r.sent_at)) / 604800)::int AS week,
+			COUNT(DISTINCT r.contact_id) AS active
+		FROM responders r
+		JOIN messages i ON i.contact_id = r.contact_id AND i.direction = ? AND i.deleted_at IS NULL
+			AND i.created_at > r.sent_at AND i.created_at < r.sent_at + make_interval(weeks => ?)
+		GROUP BY 1, 2`,
+		orgID, models.DirectionOutgoing, models.MessageStatusFailed, uuidStrings(campaignIDs),
+		models.DirectionIncoming, campaignReplyWindow.Seconds(),
+		models.DirectionIncoming, weeks,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[int]int64)
+	for _, row := range rows {
+		if result[row.CampaignID] == nil {
+			result[row.CampaignID] = make(map[int]int64)
+		}
+		result[row.CampaignID][row.Week] = row.Active
+	}
+	return result, nil
+}
+
+// bucketReplyTimes groups reply delays into the fixed replyTimeBuckets
+func bucketReplyTimes(times []time.Duration) []ReplyTimeBucket {
+	buckets := make([]ReplyTimeBucket, len(replyTimeBuckets))
+	for i, b := range replyTimeBuckets {
+		buckets[i].Label = b.Label
+	}
+	for _, t := range times {
+		for i, b := range replyTimeBuckets {
+			if t < b.Max || i == len(replyTimeBuckets)-1 {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+	return buckets
+}
+
+// medianMinutes returns the median of times in minutes, or 0 if empty
+func medianMinutes(times []time.Duration) float64 {
+	if len(times) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	median := sorted[mid]
+	if len(sorted)%2 == 0 {
+		median = (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return median.Minutes()
+}
+
+// buildCohort turns weekly active-responder counts into a retention curve
+func buildCohort(responders int64, active map[int]int64, weeks int) []CohortWeek {
+	cohort := make([]CohortWeek, weeks)
+	for w := range cohort {
+		cohort[w] = CohortWeek{
+			Week:      w,
+			Active:    active[w],
+			Retention: calculateRate(active[w], responders),
+		}
+	}
+	return cohort
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = id.String()
+	}
+	return result
+}
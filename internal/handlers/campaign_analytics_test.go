@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketReplyTimes(t *testing.T) {
+	buckets := bucketReplyTimes([]time.Duration{
+		time.Minute,
+		4 * time.Minute,
+		10 * time.Minute,
+		2 * time.Hour,
+		3 * 24 * time.Hour,
+	})
+
+	counts := map[string]int64{}
+	for _, b := range buckets {
+		counts[b.Label] = b.Count
+	}
+	assert.Len(t, buckets, len(replyTimeBuckets))
+	assert.Equal(t, int64(2), counts["<5m"])
+	assert.Equal(t, int64(1), counts["5-30m"])
+	assert.Equal(t, int64(0), counts["30m-1h"])
+	assert.Equal(t, int64(1), counts["1-4h"])
+	assert.Equal(t, int64(1), counts["1-7d"])
+}
+
+func TestMedianMinutes(t *testing.T) {
+	assert.Equal(t, 0.0, medianMinutes(nil))
+	assert.Equal(t, 10.0, medianMinutes([]time.Duration{30 * time.Minute, time.Minute, 10 * time.Minute}))
+	assert.Equal(t, 15.0, medianMinutes([]time.Duration{10 * time.Minute, 20 * time.Minute}))
+}
+
+func TestBuildCohort(t *testing.T) {
+	cohort := buildCohort(4, map[int]int64{0: 4, 2: 1}, 3)
+
+	assert.Equal(t, []CohortWeek{
+		{Week: 0, Active: 4, Retention: 100},
+		{Week: 1, Active: 0, Retention: 0},
+		{Week: 2, Active: 1, Retention: 25},
+	}, cohort)
+}
+
+func TestBuildCohort_NoResponders(t *testing.T) {
+	cohort := buildCohort(0, nil, 2)
+
+	assert.Equal(t, []CohortWeek{{Week: 0}, {Week: 1}}, cohort)
+}
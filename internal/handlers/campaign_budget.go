@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/pricing"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// CampaignBudgetRequest represents a campaign budget update. A null budget removes the cap.
+type CampaignBudgetRequest struct {
+	MaxBudget *float64 `json:"max_budget"`
+}
+
+// CampaignEstimateResponse represents the projected cost of a campaign's pending sends
+type CampaignEstimateResponse struct {
+	pricing.Estimate
+	ActualCost      float64  `json:"actual_cost"`
+	MaxBudget       *float64 `json:"max_budget,omitempty"`
+	RemainingBudget *float64 `json:"remaining_budget,omitempty"`
+	WithinBudget    bool     `json:"within_budget"`
+}
+
+// GetCampaignEstimate estimates the cost of sending to a campaign's pending recipients
+func (a *App) GetCampaignEstimate(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	campaignID, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaignID, orgID).Preload("Template").First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+
+	var phoneNumbers []string
+	if err := a.DB.Model(&models.BulkMessageRecipient{}).
+		Where("campaign_id = ? AND status = ?", campaignID, models.MessageStatusPending).
+		Pluck("phone_number", &phoneNumbers).Error; err != nil {
+		a.Log.Error("Failed to load recipients", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load recipients", nil, "")
+	}
+
+	return r.SendEnvelope(buildCampaignEstimate(&campaign, phoneNumbers))
+}
+
+// UpdateCampaignBudget sets or removes a campaign's spend cap. Allowed in any state so a
+// campaign paused for exceeding its budget can be resumed after raising it.
+func (a *App) UpdateCampaignBudget(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	campaignID, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var req CampaignBudgetRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.MaxBudget != nil && *req.MaxBudget < 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Budget cannot be negative", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaignID, orgID).First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+
+	if err := a.DB.Model(&campaign).Update("max_budget", req.MaxBudget).Error; err != nil {
+		a.Log.Error("Failed to update campaign budget", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update campaign budget", nil, "")
+	}
+
+	a.Log.Info("Campaign budget updated", "campaign_id", campaignID, "max_budget", req.MaxBudget)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":    "Campaign budget updated",
+		"max_budget": req.MaxBudget,
+		"currency":   pricing.Currency,
+	})
+}
+
+// buildCampaignEstimate prices the given recipients and compares the result against the campaign budget
+func buildCampaignEstimate(campaign *models.BulkMessageCampaign, phoneNumbers []string) CampaignEstimateResponse {
+	category := ""
+	if campaign.Template != nil {
+		category = campaign.Template.Category
+	}
+
+	resp := CampaignEstimateResponse{
+		Estimate:     pricing.EstimateCost(phoneNumbers, category),
+		ActualCost:   campaign.ActualCost,
+		MaxBudget:    campaign.MaxBudget,
+		WithinBudget: true,
+	}
+	if campaign.MaxBudget != nil {
+		remaining := *campaign.MaxBudget - campaign.ActualCost
+		resp.RemainingBudget = &remaining
+		resp.WithinBudget = resp.Total <= remaining
+	}
+	return resp
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/sheets"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/xlsx"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// maxRecipientImportSize caps an uploaded recipients CSV file
+	maxRecipientImportSize = 200 << 20
+	// recipientImportDir is the media storage subdirectory holding files being imported
+	recipientImportDir = "imports"
+	// recipientImportRejectedSample is how many rejected rows an import keeps for review
+	recipientImportRejectedSample = 100
+	// recipientImportStallTimeout marks imports without progress for this long as
+	// interrupted, e.g. by a restart
+	recipientImportStallTimeout = 5 * time.Minute
+)
+
+// errCampaignNotDraft stops an import when the campaign leaves draft while it runs
+var errCampaignNotDraft = errors.New("campaign is no longer a draft")
+
+// ImportRecipientsCSV imports campaign recipients from an uploaded CSV file in the
+// background. The multipart form holds the file and a mapping field, a JSON object of
+// column header -> phone_number, recipient_name or a template parameter name. The file
+// is streamed to disk and inserted in batches; progress is pushed to the uploader over
+// the WebSocket and can be polled with GetRecipientImport. Numbers already on the
+// campaign are skipped, so an interrupted import can be uploaded again.
+func (a *App) ImportRecipientsCSV(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+	if campaign.Status != models.CampaignStatusDraft {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only add recipients to draft campaigns", nil, "")
+	}
+
+	// Concurrent imports into one campaign would race on skipping duplicates
+	var running int64
+	a.DB.Model(&models.RecipientImport{}).
+		Where("campaign_id = ? AND status = ? AND updated_at > ?", id, models.RecipientImportStatusProcessing, time.Now().Add(-recipientImportStallTimeout)).
+		Count(&running)
+	if running > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "An import is already running for this campaign", nil, "")
+	}
+
+	upload, err := a.readImportUpload(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errMediaTooLarge):
+			return r.SendErrorEnvelope(fasthttp.StatusRequestEntityTooLarge, "CSV file is too large", nil, "")
+		case errors.Is(err, errInvalidUpload):
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid multipart form", nil, "")
+		}
+		a.Log.Error("Failed to save recipients file", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save file", nil, "")
+	}
+	if upload.LocalPath == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "file is required", nil, "")
+	}
+	// From here on the file belongs to the import job
+	queued := false
+	defer func() {
+		if !queued {
+			a.removeUpload(upload)
+		}
+	}()
+
+	var mapping sheets.Mapping
+	if err := json.Unmarshal([]byte(upload.Fields["mapping"]), &mapping); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid column mapping", nil, "")
+	}
+	headers, err := a.readRecipientImportHeaders(orgID, upload.LocalPath)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid CSV file", nil, "")
+	}
+	if err := mapping.Validate(headers); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	columnMapping := make(models.JSONB, len(mapping))
+	for column, target := range mapping {
+		columnMapping[column] = target
+	}
+	job := models.RecipientImport{
+		OrganizationID: orgID,
+		CampaignID:     id,
+		RequestedByID:  userID,
+		Filename:       upload.Filename,
+		FilePath:       upload.LocalPath,
+		ColumnMapping:  columnMapping,
+		Status:         models.RecipientImportStatusProcessing,
+		Rejected:       models.JSONBArray{},
+	}
+	if err := a.DB.Create(&job).Error; err != nil {
+		a.Log.Error("Failed to create recipient import", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start import", nil, "")
+	}
+	queued = true
+
+	jobID := job.ID
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.runRecipientImport(jobID)
+	}()
+
+	return r.SendEnvelope(job)
+}
+
+// GetRecipientImport returns the progress of a campaign recipient import
+func (a *App) GetRecipientImport(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	campaignID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+	importID, err := uuid.Parse(r.RequestCtx.UserValue("importId").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid import ID", nil, "")
+	}
+
+	var job models.RecipientImport
+	if err := a.DB.Where("id = ? AND campaign_id = ? AND organization_id = ?", importID, campaignID, orgID).First(&job).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Import not found", nil, "")
+	}
+
+	if job.Status == models.RecipientImportStatusProcessing && job.UpdatedAt.Before(time.Now().Add(-recipientImportStallTimeout)) {
+		job.Status = models.RecipientImportStatusFailed
+		job.Error = "Import was interrupted, upload the file again to import the remaining rows"
+		a.finishRecipientImport(&job)
+	}
+
+	return r.SendEnvelope(job)
+}
+
+// readImportUpload streams a multipart CSV or XLSX upload to storage without buffering
+// the file in memory. Files are stored as CSV unless the upload is named .xlsx.
+func (a *App) readImportUpload(r *fastglue.Request) (*mediaUpload, error) {
+	if r.RequestCtx.Request.Header.ContentLength() > maxRecipientImportSize+uploadFormOverhead {
+		return nil, errMediaTooLarge
+	}
+
+	boundary := string(r.RequestCtx.Request.Header.MultipartFormBoundary())
+	if boundary == "" {
+		return nil, errInvalidUpload
+	}
+
+	// The body is only streamed when the server has StreamRequestBody enabled
+	body := r.RequestCtx.RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(r.RequestCtx.PostBody())
+	}
+
+	orgID, _ := a.getOrgIDFromContext(r)
+
+	upload := &mediaUpload{Fields: make(map[string]string)}
+	reader := multipart.NewReader(io.LimitReader(body, maxRecipientImportSize+uploadFormOverhead), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			a.removeUpload(upload)
+			return nil, errInvalidUpload
+		}
+
+		if part.FormName() != "file" || part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxUploadFieldSize))
+			if err != nil {
+				a.removeUpload(upload)
+				return nil, errInvalidUpload
+			}
+			upload.Fields[part.FormName()] = string(value)
+			continue
+		}
+		if upload.LocalPath != "" {
+			a.removeUpload(upload)
+			return nil, errInvalidUpload
+		}
+
+		dir, err := a.ensureMediaDir(orgID, recipientImportDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create import directory: %w", err)
+		}
+		ext, mimeType := ".csv", "text/csv"
+		if strings.EqualFold(filepath.Ext(part.FileName()), ".xlsx") {
+			ext, mimeType = ".xlsx", xlsx.ContentType
+		}
+		relPath := filepath.Join(dir, uuid.New().String()+ext)
+		size, err := a.saveStream(orgID, part, relPath, maxRecipientImportSize)
+		if err != nil {
+			a.removeUpload(upload)
+			return nil, err
+		}
+		upload.LocalPath = relPath
+		upload.Filename = filepath.Base(part.FileName())
+		upload.MimeType = mimeType
+		upload.Size = size
+	}
+	return upload, nil
+}
+
+// newImportCSVReader returns a CSV reader over an import file and its header row
+func newImportCSVReader(f io.Reader) (*csv.Reader, []string, error) {
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, h := range headers {
+		headers[i] = strings.TrimSpace(h)
+	}
+	// Spreadsheet exports often start with a byte order mark
+	headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	return reader, headers, nil
+}
+
+// readRecipientImportHeaders returns the header row of a stored import file
+func (a *App) readRecipientImportHeaders(orgID uuid.UUID, path string) ([]string, error) {
+	f, err := a.openMediaFile(orgID, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	_, headers, err := newImportCSVReader(f)
+	return headers, err
+}
+
+// runRecipientImport imports the rows of a stored CSV file, then records the outcome and
+// removes the file
+func (a *App) runRecipientImport(importID uuid.UUID) {
+	var job models.RecipientImport
+	if err := a.DB.Where("id = ?", importID).First(&job).Error; err != nil {
+		a.Log.Error("Failed to load recipient import", "error", err, "import_id", importID)
+		return
+	}
+
+	err := a.processRecipientImport(&job)
+	var parseErr *csv.ParseError
+	switch {
+	case err == nil:
+		job.Status = models.RecipientImportStatusCompleted
+	case errors.Is(err, errCampaignNotDraft):
+		job.Status = models.RecipientImportStatusFailed
+		job.Error = "Import stopped because the campaign is no longer a draft"
+	case errors.As(err, &parseErr):
+		job.Status = models.RecipientImportStatusFailed
+		job.Error = fmt.Sprintf("Invalid CSV on line %d: %v", parseErr.Line, parseErr.Err)
+	default:
+		a.Log.Error("Recipient import failed", "error", err, "import_id", job.ID, "campaign_id", job.CampaignID)
+		job.Status = models.RecipientImportStatusFailed
+		job.Error = "Failed to import recipients"
+	}
+	a.finishRecipientImport(&job)
+
+	a.Log.Info("Recipient import finished", "import_id", job.ID, "campaign_id", job.CampaignID,
+		"status", job.Status, "rows", job.ProcessedRows, "added", job.AddedCount, "rejected", job.RejectedCount)
+}
+
+// processRecipientImport reads the import file row by row and adds the recipients in
+// batches, so memory use doesn't grow with the file
+func (a *App) processRecipientImport(job *models.RecipientImport) error {
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ?", job.CampaignID).First(&campaign).Error; err != nil {
+		return err
+	}
+
+	f, err := a.openMediaFile(job.OrganizationID, job.FilePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	reader, headers, err := newImportCSVReader(f)
+	if err != nil {
+		return err
+	}
+	mapping := sheets.MappingFromJSON(job.ColumnMapping)
+
+	batch := make([]RecipientRequest, 0, recipientInsertBatchSize)
+	flush := func() error {
+		// Stop if the campaign was started or deleted since the last batch
+		var status models.CampaignStatus
+		if err := a.DB.Model(&models.BulkMessageCampaign{}).Where("id = ?", campaign.ID).
+			Select("status").Scan(&status).Error; err != nil {
+			return err
+		}
+		if status != models.CampaignStatusDraft {
+			return errCampaignNotDraft
+		}
+
+		added, rejected, err := a.addCampaignRecipients(&campaign, batch, true)
+		if err != nil {
+			return err
+		}
+		job.ProcessedRows += len(batch)
+		job.AddedCount += added
+		job.RejectedCount += len(rejected)
+		for _, rec := range rejected {
+			if len(job.Rejected) >= recipientImportRejectedSample {
+				break
+			}
+			job.Rejected = append(job.Rejected, rec)
+		}
+		batch = batch[:0]
+
+		if err := a.DB.Model(job).Updates(map[string]interface{}{
+			"processed_rows": job.ProcessedRows,
+			"added_count":    job.AddedCount,
+			"rejected_count": job.RejectedCount,
+			"rejected":       job.Rejected,
+		}).Error; err != nil {
+			return err
+		}
+		a.notifyRecipientImport(job)
+		return nil
+	}
+
+	for row := 2; ; row++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		rec := mapping.MapRow(headers, values, row)
+		batch = append(batch, RecipientRequest{
+			PhoneNumber:    strings.TrimSpace(rec.PhoneNumber),
+			RecipientName:  strings.TrimSpace(rec.RecipientName),
+			TemplateParams: rec.TemplateParams,
+		})
+		if len(batch) == recipientInsertBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// finishRecipientImport records the final state of an import, removes its file and
+// notifies the uploader
+func (a *App) finishRecipientImport(job *models.RecipientImport) {
+	if job.FilePath != "" {
+		if err := os.Remove(a.mediaFullPath(job.FilePath)); err != nil && !os.IsNotExist(err) {
+			a.Log.Warn("Failed to remove recipient import file", "error", err, "path", job.FilePath)
+		}
+	}
+
+	now := time.Now()
+	job.CompletedAt = &now
+	job.FilePath = ""
+	if err := a.DB.Model(job).Updates(map[string]interface{}{
+		"status":         job.Status,
+		"error":          job.Error,
+		"processed_rows": job.ProcessedRows,
+		"added_count":    job.AddedCount,
+		"rejected_count": job.RejectedCount,
+		"rejected":       job.Rejected,
+		"file_path":      "",
+		"completed_at":   now,
+	}).Error; err != nil {
+		a.Log.Error("Failed to update recipient import", "error", err, "import_id", job.ID)
+	}
+	a.notifyRecipientImport(job)
+}
+
+// notifyRecipientImport pushes an import's progress to the uploader
+func (a *App) notifyRecipientImport(job *models.RecipientImport) {
+	if a.WSHub == nil {
+		return
+	}
+	a.WSHub.BroadcastToUser(job.OrganizationID, job.RequestedByID, websocket.WSMessage{
+		Type:    websocket.TypeRecipientImport,
+		Payload: job,
+	})
+}
@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// newRecipientCSVRequest builds a multipart recipient import request
+func newRecipientCSVRequest(t *testing.T, mapping, csv string) *fastglue.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	require.NoError(t, w.WriteField("mapping", mapping))
+	part, err := w.CreateFormFile("file", "recipients.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(csv))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req := testutil.NewRequest(t)
+	req.RequestCtx.Request.Header.SetMethod("POST")
+	req.RequestCtx.Request.Header.SetContentType(w.FormDataContentType())
+	req.RequestCtx.Request.SetBody(body.Bytes())
+	return req
+}
+
+func TestApp_ImportRecipientsCSV(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	app.Config.Storage.LocalPath = t.TempDir()
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("import-csv"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "import-csv-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	campaign := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusDraft)
+	createTestRecipient(t, app, campaign.ID, "+14155550000", models.MessageStatusPending)
+	app.DB.Model(campaign).Update("total_recipients", 1)
+
+	// More rows than one insert batch, with an existing number, a duplicate and an invalid row
+	var csv strings.Builder
+	csv.WriteString("\ufeffPhone,Name,First Name\n")
+	for i := 0; i < 2500; i++ {
+		fmt.Fprintf(&csv, "+1415555%04d,Contact %d,\"Name, %d\"\n", i, i, i)
+	}
+	csv.WriteString("+14155550001,Duplicate,Dup\n")
+	csv.WriteString("not-a-number,Invalid,Bad\n")
+
+	req := newRecipientCSVRequest(t, `{"Phone":"phone_number","Name":"recipient_name","First Name":"1"}`, csv.String())
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", campaign.ID.String())
+	require.NoError(t, app.ImportRecipientsCSV(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var created struct {
+		Data models.RecipientImport `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, models.RecipientImportStatusProcessing, created.Data.Status)
+
+	var job models.RecipientImport
+	require.Eventually(t, func() bool {
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", campaign.ID.String())
+		testutil.SetPathParam(req, "importId", created.Data.ID.String())
+		require.NoError(t, app.GetRecipientImport(req))
+		var resp struct {
+			Data models.RecipientImport `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		job = resp.Data
+		return job.Status != models.RecipientImportStatusProcessing
+	}, 30*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, models.RecipientImportStatusCompleted, job.Status)
+	assert.Equal(t, 2502, job.ProcessedRows)
+	assert.Equal(t, 2499, job.AddedCount)
+	assert.Equal(t, 1, job.RejectedCount)
+	assert.Len(t, job.Rejected, 1)
+
+	var total int64
+	app.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ?", campaign.ID).Count(&total)
+	assert.Equal(t, int64(2500), total)
+	var updated models.BulkMessageCampaign
+	require.NoError(t, app.DB.First(&updated, "id = ?", campaign.ID).Error)
+	assert.Equal(t, 2500, updated.TotalRecipients)
+
+	var recipient models.BulkMessageRecipient
+	require.NoError(t, app.DB.Where("campaign_id = ? AND phone_number = ?", campaign.ID, "+14155550042").First(&recipient).Error)
+	assert.Equal(t, "Contact 42", recipient.RecipientName)
+	assert.Equal(t, "Name, 42", recipient.TemplateParams["1"])
+}
+
+func TestApp_ImportRecipientsCSV_InvalidMapping(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	app.Config.Storage.LocalPath = t.TempDir()
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("import-csv-mapping"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "import-csv-mapping-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	campaign := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusDraft)
+
+	for _, mapping := range []string{`not json`, `{"Name":"recipient_name"}`, `{"Mobile":"phone_number"}`} {
+		req := newRecipientCSVRequest(t, mapping, "Phone,Name\n+14155550000,Jane\n")
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", campaign.ID.String())
+		require.NoError(t, app.ImportRecipientsCSV(req))
+		assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req), mapping)
+	}
+
+	var count int64
+	app.DB.Model(&models.RecipientImport{}).Where("campaign_id = ?", campaign.ID).Count(&count)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/templatevars"
+)
+
+// unresolvedParamsSample is how many recipients with missing placeholder values are
+// listed when a campaign can't start
+const unresolvedParamsSample = 20
+
+// errUnresolvedTemplateParams is returned when some recipients would get a message with
+// empty template placeholders
+var errUnresolvedTemplateParams = errors.New("template placeholders without a value")
+
+// UnresolvedRecipient is a campaign recipient some template placeholders have no value for
+type UnresolvedRecipient struct {
+	PhoneNumber string   `json:"phone_number"`
+	Params      []string `json:"params"`
+}
+
+// unresolvedParamsError lists the recipients whose placeholders can't all be filled
+type unresolvedParamsError struct {
+	count   int
+	params  []string
+	samples []UnresolvedRecipient
+}
+
+func (e *unresolvedParamsError) Error() string {
+	placeholders := make([]string, len(e.params))
+	for i, p := range e.params {
+		placeholders[i] = "{{" + p + "}}"
+	}
+	return fmt.Sprintf("%d recipient(s) have no value for %s; add the values to the recipients or map the placeholders to contact fields",
+		e.count, strings.Join(placeholders, ", "))
+}
+
+func (e *unresolvedParamsError) Unwrap() error {
+	return errUnresolvedTemplateParams
+}
+
+// Details returns the error's data for API responses
+func (e *unresolvedParamsError) Details() map[string]interface{} {
+	return map[string]interface{}{
+		"unresolved_count": e.count,
+		"params":           e.params,
+		"recipients":       e.samples,
+	}
+}
+
+// campaignParamMapping normalizes a campaign's placeholder mapping and checks it against
+// the template's placeholders
+func campaignParamMapping(template *models.Template, mapping map[string]string) (templatevars.Mapping, error) {
+	normalized, err := templatevars.Mapping(mapping).Normalize()
+	if err != nil {
+		return nil, err
+	}
+	if err := normalized.CheckParams(ExtractParamNamesFromContent(template.BodyContent)); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// checkCampaignParams returns an *unresolvedParamsError when a template placeholder has
+// no value for some recipients, from their own parameters or the campaign's mapping. The
+// campaign's Template must be loaded.
+func (a *App) checkCampaignParams(campaign *models.BulkMessageCampaign, recipients []models.BulkMessageRecipient) error {
+	if campaign.Template == nil {
+		return nil
+	}
+	names := ExtractParamNamesFromContent(campaign.Template.BodyContent)
+	if len(names) == 0 {
+		return nil
+	}
+	mapping := templatevars.FromJSONB(campaign.TemplateParamMapping)
+
+	var contacts map[string]*models.Contact
+	if len(mapping) > 0 {
+		phoneNumbers := make([]string, len(recipients))
+		for i, recipient := range recipients {
+			phoneNumbers[i] = recipient.PhoneNumber
+		}
+		var err error
+		if contacts, err = a.contactsByPhone(campaign.OrganizationID, phoneNumbers); err != nil {
+			return err
+		}
+	}
+
+	unresolved := &unresolvedParamsError{}
+	missingParams := make(map[string]bool)
+	for _, recipient := range recipients {
+		contact := contacts[strings.TrimPrefix(recipient.PhoneNumber, "+")]
+		_, missing := templatevars.Apply(names, recipient.TemplateParams, mapping, contact)
+		if len(missing) == 0 {
+			continue
+		}
+		unresolved.count++
+		for _, p := range missing {
+			missingParams[p] = true
+		}
+		if len(unresolved.samples) < unresolvedParamsSample {
+			unresolved.samples = append(unresolved.samples, UnresolvedRecipient{PhoneNumber: recipient.PhoneNumber, Params: missing})
+		}
+	}
+	if unresolved.count == 0 {
+		return nil
+	}
+	for p := range missingParams {
+		unresolved.params = append(unresolved.params, p)
+	}
+	sort.Strings(unresolved.params)
+	return unresolved
+}
+
+// contactsByPhone loads the organization's contacts with the given phone numbers, keyed
+// by number without the leading "+". Contacts are stored with or without it.
+func (a *App) contactsByPhone(orgID uuid.UUID, phoneNumbers []string) (map[string]*models.Contact, error) {
+	contacts := make(map[string]*models.Contact, len(phoneNumbers))
+	for start := 0; start < len(phoneNumbers); start += recipientInsertBatchSize {
+		end := min(start+recipientInsertBatchSize, len(phoneNumbers))
+		numbers := make([]string, 0, 2*(end-start))
+		for _, p := range phoneNumbers[start:end] {
+			p = strings.TrimPrefix(p, "+")
+			numbers = append(numbers, p, "+"+p)
+		}
+
+		var batch []models.Contact
+		if err := a.DB.Select("id, phone_number, profile_name, metadata").
+			Where("organization_id = ? AND phone_number IN ?", orgID, numbers).
+			Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			contacts[strings.TrimPrefix(batch[i].PhoneNumber, "+")] = &batch[i]
+		}
+	}
+	return contacts, nil
+}
@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestApp_CreateCampaign_ParamMapping(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("param-mapping"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "param-mapping-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+
+	create := func(mapping map[string]string) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, map[string]interface{}{
+			"name":                   "Mapped Campaign",
+			"whatsapp_account":       account.Name,
+			"template_id":            template.ID.String(),
+			"template_param_mapping": mapping,
+		})
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.CreateCampaign(req))
+		return req
+	}
+
+	req := create(map[string]string{"1": " {{contact.custom_fields.first_name}} "})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		Data handlers.CampaignResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+	assert.Equal(t, "{{contact.custom_fields.first_name}}", resp.Data.TemplateParamMapping["1"])
+
+	// Unknown contact fields and placeholders the template doesn't have are refused
+	for _, mapping := range []map[string]string{
+		{"1": "{{contact.email}}"},
+		{"order_id": "{{contact.name}}"},
+	} {
+		req := create(mapping)
+		assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req), mapping)
+	}
+}
+
+func TestApp_StartCampaign_UnresolvedParams(t *testing.T) {
+	app, mockQueue := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("unresolved-params"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "unresolved-params-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	campaign := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusDraft)
+	require.NoError(t, app.DB.Model(campaign).Update("template_param_mapping", models.JSONB{
+		"1": "{{contact.custom_fields.first_name}}",
+	}).Error)
+
+	// Neither recipient carries a value; only the first has a contact with the field
+	for _, phone := range []string{"+1234567890", "+1987654321"} {
+		require.NoError(t, app.DB.Create(&models.BulkMessageRecipient{
+			BaseModel:   models.BaseModel{ID: uuid.New()},
+			CampaignID:  campaign.ID,
+			PhoneNumber: phone,
+			Status:      models.MessageStatusPending,
+		}).Error)
+	}
+	require.NoError(t, app.DB.Create(&models.Contact{
+		OrganizationID: org.ID,
+		PhoneNumber:    "1234567890",
+		Metadata:       models.JSONB{"first_name": "Ada"},
+	}).Error)
+
+	start := func() *fastglue.Request {
+		req := testutil.NewJSONRequest(t, nil)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", campaign.ID.String())
+		require.NoError(t, app.StartCampaign(req))
+		return req
+	}
+
+	req := start()
+	require.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		Data struct {
+			UnresolvedCount int                            `json:"unresolved_count"`
+			Params          []string                       `json:"params"`
+			Recipients      []handlers.UnresolvedRecipient `json:"recipients"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+	assert.Equal(t, 1, resp.Data.UnresolvedCount)
+	assert.Equal(t, []string{"1"}, resp.Data.Params)
+	require.Len(t, resp.Data.Recipients, 1)
+	assert.Equal(t, "+1987654321", resp.Data.Recipients[0].PhoneNumber)
+	assert.Empty(t, mockQueue.EnqueuedJobs)
+
+	// Once the second contact has the field too, the campaign starts
+	require.NoError(t, app.DB.Create(&models.Contact{
+		OrganizationID: org.ID,
+		PhoneNumber:    "+1987654321",
+		Metadata:       models.JSONB{"first_name": "Grace"},
+	}).Error)
+	req = start()
+	assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Len(t, mockQueue.EnqueuedJobs, 2)
+}
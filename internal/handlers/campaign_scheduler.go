@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"gorm.io/gorm/clause"
+)
+
+// startDueCampaigns starts scheduled campaigns whose start time has passed. Claiming them
+// with a single UPDATE means only one instance starts each. Campaigns of suspended
+// organizations wait until they are reactivated.
+func (a *App) startDueCampaigns(ctx context.Context) {
+	suspended := a.DB.Model(&models.Organization{}).Select("id").Where("suspended_at IS NOT NULL")
+
+	var due []models.BulkMessageCampaign
+	if err := a.DB.Model(&due).Clauses(clause.Returning{}).
+		Where("status = ? AND scheduled_at <= ?", models.CampaignStatusScheduled, time.Now()).
+		Where("organization_id NOT IN (?)", suspended).
+		Update("status", models.CampaignStatusQueued).Error; err != nil {
+		a.Log.Error("Failed to claim scheduled campaigns", "error", err)
+		return
+	}
+
+	for i := range due {
+		campaign := &due[i]
+		if err := a.DB.Preload("Template").Where("id = ?", campaign.ID).First(campaign).Error; err != nil {
+			a.Log.Error("Failed to load scheduled campaign", "error", err, "campaign_id", campaign.ID)
+			continue
+		}
+
+		recipients, estimate, err := a.prepareCampaignStart(campaign)
+		if err != nil {
+			// Back to draft so it can be fixed and started again
+			a.Log.Warn("Scheduled campaign could not start", "campaign_id", campaign.ID, "error", err)
+			a.DB.Model(campaign).Update("status", models.CampaignStatusDraft)
+			continue
+		}
+		_ = a.queueCampaign(ctx, campaign, recipients, estimate.Total)
+	}
+}
+
+// requeueDeferredRecipients queues recipients of running campaigns that were held back
+// until their send window opened
+func (a *App) requeueDeferredRecipients(ctx context.Context) {
+	running := a.DB.Model(&models.BulkMessageCampaign{}).
+		Select("id").
+		Where("status = ?", models.CampaignStatusProcessing)
+
+	var due []models.BulkMessageRecipient
+	if err := a.DB.Model(&due).Clauses(clause.Returning{}).
+		Where("status = ? AND deferred_until <= ?", models.MessageStatusPending, time.Now()).
+		Where("campaign_id IN (?)", running).
+		Update("deferred_until", nil).Error; err != nil {
+		a.Log.Error("Failed to claim deferred recipients", "error", err)
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	campaignIDs := make([]uuid.UUID, 0, len(due))
+	seen := make(map[uuid.UUID]bool)
+	for _, recipient := range due {
+		if !seen[recipient.CampaignID] {
+			seen[recipient.CampaignID] = true
+			campaignIDs = append(campaignIDs, recipient.CampaignID)
+		}
+	}
+	var campaigns []models.BulkMessageCampaign
+	if err := a.DB.Select("id, organization_id").Where("id IN ?", campaignIDs).Find(&campaigns).Error; err != nil {
+		a.Log.Error("Failed to load campaigns for deferred recipients", "error", err)
+		return
+	}
+	orgIDs := make(map[uuid.UUID]uuid.UUID, len(campaigns))
+	for _, c := range campaigns {
+		orgIDs[c.ID] = c.OrganizationID
+	}
+
+	jobs := make([]*queue.RecipientJob, 0, len(due))
+	for _, recipient := range due {
+		jobs = append(jobs, &queue.RecipientJob{
+			CampaignID:     recipient.CampaignID,
+			RecipientID:    recipient.ID,
+			OrganizationID: orgIDs[recipient.CampaignID],
+			PhoneNumber:    recipient.PhoneNumber,
+			RecipientName:  recipient.RecipientName,
+			TemplateParams: recipient.TemplateParams,
+		})
+	}
+
+	if err := a.Queue.EnqueueRecipients(ctx, jobs); err != nil {
+		a.Log.Error("Failed to enqueue deferred recipients", "error", err, "count", len(jobs))
+		return
+	}
+	a.Log.Info("Deferred recipients enqueued", "count", len(jobs))
+}
+
+// CampaignSchedulerProcessor starts scheduled campaigns when they're due and re-queues
+// recipients whose send window has opened
+type CampaignSchedulerProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewCampaignSchedulerProcessor creates a new campaign scheduler processor
+func NewCampaignSchedulerProcessor(app *App, interval time.Duration) *CampaignSchedulerProcessor {
+	return &CampaignSchedulerProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the scheduling loop
+func (p *CampaignSchedulerProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Campaign scheduler processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Campaign scheduler processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Campaign scheduler processor stopped")
+			return
+		case <-ticker.C:
+			p.app.startDueCampaigns(ctx)
+			p.app.requeueDeferredRecipients(ctx)
+		}
+	}
+}
+
+// Stop stops the campaign scheduler processor
+func (p *CampaignSchedulerProcessor) Stop() {
+	close(p.stopCh)
+}
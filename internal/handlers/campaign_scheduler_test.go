@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_CampaignScheduler(t *testing.T) {
+	app, mockQueue := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("campaign-scheduler"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "scheduler-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+
+	// Starting a draft with a future start time schedules it without sending
+	scheduled := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusDraft)
+	require.NoError(t, app.DB.Model(scheduled).Update("scheduled_at", time.Now().Add(time.Hour)).Error)
+	scheduledRecipient := createTestRecipient(t, app, scheduled.ID, "+1234567890", models.MessageStatusPending)
+
+	req := testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", scheduled.ID.String())
+	require.NoError(t, app.StartCampaign(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assertCampaignStatus(t, app, scheduled.ID.String(), models.CampaignStatusScheduled)
+	assert.Empty(t, mockQueue.EnqueuedJobs)
+
+	// Once due it is started by the scheduler
+	require.NoError(t, app.DB.Model(scheduled).Update("scheduled_at", time.Now().Add(-time.Minute)).Error)
+
+	// A due campaign with nobody to send to goes back to draft
+	empty := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusScheduled)
+	require.NoError(t, app.DB.Model(empty).Update("scheduled_at", time.Now().Add(-time.Minute)).Error)
+
+	// Recipients held back by the send window are re-queued once it opens
+	running := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusProcessing)
+	deferred := createTestRecipient(t, app, running.ID, "+1987654321", models.MessageStatusPending)
+	require.NoError(t, app.DB.Model(deferred).Update("deferred_until", time.Now().Add(-time.Second)).Error)
+	notYet := createTestRecipient(t, app, running.ID, "+1555000111", models.MessageStatusPending)
+	require.NoError(t, app.DB.Model(notYet).Update("deferred_until", time.Now().Add(time.Hour)).Error)
+
+	processor := handlers.NewCampaignSchedulerProcessor(app, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		processor.Start(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		var pending int64
+		app.DB.Model(&models.BulkMessageRecipient{}).Where("id = ? AND deferred_until IS NOT NULL", deferred.ID).Count(&pending)
+		var started int64
+		app.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND status = ?", scheduled.ID, models.CampaignStatusProcessing).Count(&started)
+		return pending == 0 && started == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	cancel()
+	<-done
+
+	assertCampaignStatus(t, app, empty.ID.String(), models.CampaignStatusDraft)
+
+	queued := make(map[string]bool)
+	for _, job := range mockQueue.EnqueuedJobs {
+		queued[job.RecipientID.String()] = true
+		assert.Equal(t, org.ID, job.OrganizationID)
+	}
+	assert.True(t, queued[scheduledRecipient.ID.String()])
+	assert.True(t, queued[deferred.ID.String()])
+	assert.False(t, queued[notYet.ID.String()])
+}
+
+func TestApp_CreateCampaign_InvalidSendWindow(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("send-window"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "send-window-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"name":              "Windowed",
+		"whatsapp_account":  account.Name,
+		"template_id":       template.ID.String(),
+		"send_window_start": "09:00",
+	})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreateCampaign(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewJSONRequest(t, map[string]interface{}{
+		"name":              "Windowed",
+		"whatsapp_account":  account.Name,
+		"template_id":       template.ID.String(),
+		"send_window_start": "09:00",
+		"send_window_end":   "18:00",
+	})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreateCampaign(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data handlers.CampaignResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.Equal(t, "09:00", resp.Data.SendWindowStart)
+	assert.Equal(t, "18:00", resp.Data.SendWindowEnd)
+}
+
+func assertCampaignStatus(t *testing.T, app *handlers.App, id string, want models.CampaignStatus) {
+	t.Helper()
+	var campaign models.BulkMessageCampaign
+	require.NoError(t, app.DB.Where("id = ?", id).First(&campaign).Error)
+	assert.Equal(t, want, campaign.Status)
+}
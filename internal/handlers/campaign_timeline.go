@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// campaignRecipientQueued is the timeline step for a recipient waiting to be sent
+const campaignRecipientQueued models.MessageStatus = "queued"
+
+// CampaignRecipientAttempt is one send of a campaign message to a recipient. Retrying
+// failed recipients sends a new message, so a recipient can have several.
+type CampaignRecipientAttempt struct {
+	Attempt           int                  `json:"attempt"`
+	MessageID         uuid.UUID            `json:"message_id"`
+	WhatsAppMessageID string               `json:"whatsapp_message_id,omitempty"`
+	Status            models.MessageStatus `json:"status"`
+	ErrorCode         int                  `json:"error_code,omitempty"`
+	ErrorMessage      string               `json:"error_message,omitempty"`
+	ErrorInfo         *whatsapp.ErrorInfo  `json:"error_info,omitempty"`
+	CreatedAt         time.Time            `json:"created_at"`
+	StatusHistory     []MessageStatusEntry `json:"status_history"`
+}
+
+// CampaignRecipientEvent is one step of a recipient's journey through a campaign
+type CampaignRecipientEvent struct {
+	Status    models.MessageStatus `json:"status"`
+	Timestamp time.Time            `json:"timestamp"`
+	Attempt   int                  `json:"attempt,omitempty"`
+	MessageID *uuid.UUID           `json:"message_id,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// CampaignRecipientTimelineResponse is the full delivery history of one campaign recipient
+type CampaignRecipientTimelineResponse struct {
+	Recipient models.BulkMessageRecipient `json:"recipient"`
+	Status    models.MessageStatus        `json:"status"`
+	Retries   int                         `json:"retries"`
+	Attempts  []CampaignRecipientAttempt  `json:"attempts"`
+	Timeline  []CampaignRecipientEvent    `json:"timeline"`
+}
+
+// GetCampaignRecipientTimeline returns everything known about sending a campaign to one
+// recipient: when it was queued, each send attempt with its delivery statuses, and the
+// error and remediation hints for failures
+func (a *App) GetCampaignRecipientTimeline(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	campaignID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+	recipientID, err := uuid.Parse(r.RequestCtx.UserValue("recipientId").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid recipient ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaignID, orgID).First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+
+	var recipient models.BulkMessageRecipient
+	if err := a.DB.Where("id = ? AND campaign_id = ?", recipientID, campaignID).First(&recipient).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Recipient not found", nil, "")
+	}
+
+	messages, err := a.campaignRecipientMessages(&campaign, &recipient)
+	if err != nil {
+		a.Log.Error("Failed to load recipient messages", "error", err, "recipient_id", recipient.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load recipient timeline", nil, "")
+	}
+
+	resp := a.buildCampaignRecipientTimeline(&campaign, &recipient, messages)
+	a.dataMaskFor(orgID, userID).Recipient(&resp.Recipient)
+	return r.SendEnvelope(resp)
+}
+
+// campaignRecipientMessages returns the messages sent to a campaign recipient, oldest
+// first. Messages are tagged with their recipient; older ones only carry the campaign,
+// so those are matched on the recipient's phone number instead.
+func (a *App) campaignRecipientMessages(campaign *models.BulkMessageCampaign, recipient *models.BulkMessageRecipient) ([]models.Message, error) {
+	phone := strings.TrimPrefix(recipient.PhoneNumber, "+")
+	contactIDs := a.DB.Model(&models.Contact{}).
+		Select("id").
+		Where("organization_id = ? AND phone_number IN ?", campaign.OrganizationID, []string{phone, "+" + phone})
+
+	var messages []models.Message
+	err := a.DB.Where("organization_id = ? AND metadata->>'campaign_id' = ?", campaign.OrganizationID, campaign.ID.String()).
+		Where("metadata->>'recipient_id' = ? OR (metadata->>'recipient_id' IS NULL AND contact_id IN (?))", recipient.ID.String(), contactIDs).
+		Order("created_at ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+// buildCampaignRecipientTimeline merges the recipient's queueing, send attempts and
+// their delivery statuses into one timeline
+func (a *App) buildCampaignRecipientTimeline(campaign *models.BulkMessageCampaign, recipient *models.BulkMessageRecipient, messages []models.Message) CampaignRecipientTimelineResponse {
+	resp := CampaignRecipientTimelineResponse{
+		Recipient: *recipient,
+		Status:    recipient.Status,
+		Attempts:  make([]CampaignRecipientAttempt, 0, len(messages)),
+		Timeline:  []CampaignRecipientEvent{},
+	}
+
+	// Recipients added to a running campaign are queued straight away
+	if campaign.StartedAt != nil {
+		queuedAt := *campaign.StartedAt
+		if recipient.CreatedAt.After(queuedAt) {
+			queuedAt = recipient.CreatedAt
+		}
+		resp.Timeline = append(resp.Timeline, CampaignRecipientEvent{
+			Status:    campaignRecipientQueued,
+			Timestamp: queuedAt,
+		})
+	}
+
+	messageIDs := make([]uuid.UUID, len(messages))
+	for i, m := range messages {
+		messageIDs[i] = m.ID
+	}
+	history := a.loadStatusHistory(messageIDs)
+
+	for i, m := range messages {
+		attempt := CampaignRecipientAttempt{
+			Attempt:           i + 1,
+			MessageID:         m.ID,
+			WhatsAppMessageID: m.WhatsAppMessageID,
+			Status:            m.Status,
+			ErrorCode:         m.ErrorCode,
+			ErrorMessage:      m.ErrorMessage,
+			CreatedAt:         m.CreatedAt,
+			StatusHistory:     history[m.ID],
+		}
+		if m.ErrorCode != 0 {
+			info := whatsapp.LookupError(m.ErrorCode)
+			attempt.ErrorInfo = &info
+		}
+		if attempt.StatusHistory == nil {
+			attempt.StatusHistory = []MessageStatusEntry{}
+		}
+		resp.Attempts = append(resp.Attempts, attempt)
+
+		for _, entry := range attempt.StatusHistory {
+			resp.Timeline = append(resp.Timeline, CampaignRecipientEvent{
+				Status:    entry.Status,
+				Timestamp: entry.Timestamp,
+				Attempt:   attempt.Attempt,
+				MessageID: &messages[i].ID,
+				Error:     entry.Error,
+			})
+		}
+	}
+
+	if len(messages) > 0 {
+		// The message has the delivery status from webhooks; the recipient only
+		// records whether it was sent
+		resp.Status = messages[len(messages)-1].Status
+		resp.Retries = len(messages) - 1
+	} else if recipient.Status == models.MessageStatusFailed {
+		// Rejected before sending, e.g. by country restrictions or missing consent
+		resp.Timeline = append(resp.Timeline, CampaignRecipientEvent{
+			Status:    models.MessageStatusFailed,
+			Timestamp: recipient.UpdatedAt,
+			Error:     recipient.ErrorMessage,
+		})
+	}
+
+	sort.SliceStable(resp.Timeline, func(i, j int) bool {
+		return resp.Timeline[i].Timestamp.Before(resp.Timeline[j].Timestamp)
+	})
+	return resp
+}
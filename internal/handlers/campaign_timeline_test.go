@@ -0,0 +1,106 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_GetCampaignRecipientTimeline(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("recipient-timeline"), "password", &role.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "timeline-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	campaign := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusCompleted)
+	recipient := createTestRecipient(t, app, campaign.ID, "+15550001111", models.MessageStatusSent)
+	other := createTestRecipient(t, app, campaign.ID, "+15550002222", models.MessageStatusSent)
+
+	started := time.Now().Add(-time.Hour)
+	require.NoError(t, app.DB.Model(campaign).Update("started_at", started).Error)
+
+	contact := &models.Contact{OrganizationID: org.ID, PhoneNumber: "15550001111"}
+	require.NoError(t, app.DB.Create(contact).Error)
+
+	// The first attempt failed, the retry was delivered; the other recipient's message
+	// must not show up
+	send := func(recipientID uuid.UUID, status models.MessageStatus, errorCode int, sentAt time.Time, history ...models.MessageStatus) *models.Message {
+		msg := &models.Message{
+			OrganizationID:    org.ID,
+			WhatsAppAccount:   account.Name,
+			ContactID:         contact.ID,
+			WhatsAppMessageID: "wamid." + uuid.NewString(),
+			Direction:         models.DirectionOutgoing,
+			MessageType:       models.MessageTypeTemplate,
+			Status:            status,
+			ErrorCode:         errorCode,
+			Metadata: models.JSONB{
+				"campaign_id":  campaign.ID.String(),
+				"recipient_id": recipientID.String(),
+			},
+		}
+		msg.CreatedAt = sentAt
+		require.NoError(t, app.DB.Create(msg).Error)
+		for i, s := range history {
+			require.NoError(t, app.DB.Create(&models.MessageStatusEvent{
+				OrganizationID: org.ID,
+				MessageID:      msg.ID,
+				Status:         s,
+				OccurredAt:     sentAt.Add(time.Duration(i) * time.Minute),
+			}).Error)
+		}
+		return msg
+	}
+	failed := send(recipient.ID, models.MessageStatusFailed, 131026, started.Add(time.Minute), models.MessageStatusAccepted, models.MessageStatusFailed)
+	retried := send(recipient.ID, models.MessageStatusDelivered, 0, started.Add(10*time.Minute), models.MessageStatusAccepted, models.MessageStatusSent, models.MessageStatusDelivered)
+	send(other.ID, models.MessageStatusRead, 0, started.Add(2*time.Minute), models.MessageStatusAccepted)
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", campaign.ID.String())
+	testutil.SetPathParam(req, "recipientId", recipient.ID.String())
+
+	require.NoError(t, app.GetCampaignRecipientTimeline(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data handlers.CampaignRecipientTimelineResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+
+	assert.Equal(t, models.MessageStatusDelivered, resp.Data.Status)
+	assert.Equal(t, 1, resp.Data.Retries)
+	require.Len(t, resp.Data.Attempts, 2)
+	assert.Equal(t, failed.ID, resp.Data.Attempts[0].MessageID)
+	require.NotNil(t, resp.Data.Attempts[0].ErrorInfo)
+	assert.Equal(t, 131026, resp.Data.Attempts[0].ErrorInfo.Code)
+	assert.Equal(t, retried.ID, resp.Data.Attempts[1].MessageID)
+	assert.Equal(t, retried.WhatsAppMessageID, resp.Data.Attempts[1].WhatsAppMessageID)
+
+	var steps []models.MessageStatus
+	for _, e := range resp.Data.Timeline {
+		steps = append(steps, e.Status)
+	}
+	assert.Equal(t, []models.MessageStatus{
+		"queued",
+		models.MessageStatusAccepted, models.MessageStatusFailed,
+		models.MessageStatusAccepted, models.MessageStatusSent, models.MessageStatusDelivered,
+	}, steps)
+
+	// A recipient of another campaign is not found
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", campaign.ID.String())
+	testutil.SetPathParam(req, "recipientId", uuid.NewString())
+	require.NoError(t, app.GetCampaignRecipientTimeline(req))
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+}
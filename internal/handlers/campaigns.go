@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,8 +11,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/byok"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/optout"
+	"github.com/shridarpatil/whatomate/internal/phone"
 	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/internal/sendwindow"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
@@ -24,6 +30,14 @@ type CampaignRequest struct {
 	TemplateID      string     `json:"template_id" validate:"required"`
 	HeaderMediaID   string     `json:"header_media_id"`
 	ScheduledAt     *time.Time `json:"scheduled_at"`
+	TrackLinks      bool       `json:"track_links"`
+	MaxBudget       *float64   `json:"max_budget"`
+	SendWindowStart string     `json:"send_window_start"` // HH:MM in the recipient's timezone
+	SendWindowEnd   string     `json:"send_window_end"`
+
+	// Template placeholder -> text filled from each recipient's contact, such as
+	// "{{contact.name}}"
+	TemplateParamMapping map[string]string `json:"template_param_mapping"`
 }
 
 // CampaignResponse represents campaign in API responses
@@ -37,16 +51,50 @@ type CampaignResponse struct {
 	HeaderMediaFilename   string                `json:"header_media_filename,omitempty"`
 	HeaderMediaMimeType   string                `json:"header_media_mime_type,omitempty"`
 	Status                models.CampaignStatus `json:"status"`
+	TrackLinks            bool                  `json:"track_links"`
+	Sheet                 *CampaignSheetSource  `json:"sheet,omitempty"`
 	TotalRecipients int                  `json:"total_recipients"`
 	SentCount       int                  `json:"sent_count"`
 	DeliveredCount  int                  `json:"delivered_count"`
 	ReadCount       int                  `json:"read_count"`
 	FailedCount     int                  `json:"failed_count"`
+	MaxBudget       *float64             `json:"max_budget,omitempty"`
+	EstimatedCost   float64              `json:"estimated_cost"`
+	ActualCost      float64              `json:"actual_cost"`
 	ScheduledAt     *time.Time           `json:"scheduled_at,omitempty"`
+	SendWindowStart string               `json:"send_window_start,omitempty"`
+	SendWindowEnd   string               `json:"send_window_end,omitempty"`
 	StartedAt       *time.Time           `json:"started_at,omitempty"`
 	CompletedAt     *time.Time           `json:"completed_at,omitempty"`
 	CreatedAt       time.Time            `json:"created_at"`
 	UpdatedAt       time.Time            `json:"updated_at"`
+
+	TemplateParamMapping models.JSONB `json:"template_param_mapping,omitempty"`
+}
+
+// CampaignSheetSource describes the spreadsheet a campaign imports recipients from
+type CampaignSheetSource struct {
+	SpreadsheetID    string       `json:"spreadsheet_id"`
+	Range            string       `json:"range"`
+	ColumnMapping    models.JSONB `json:"column_mapping"`
+	SyncIntervalMins int          `json:"sync_interval_mins"`
+	LastSyncedAt     *time.Time   `json:"last_synced_at,omitempty"`
+	SyncError        string       `json:"sync_error,omitempty"`
+}
+
+// campaignSheetSource returns the campaign's linked spreadsheet, or nil if none
+func campaignSheetSource(c *models.BulkMessageCampaign) *CampaignSheetSource {
+	if c.SheetSpreadsheetID == "" {
+		return nil
+	}
+	return &CampaignSheetSource{
+		SpreadsheetID:    c.SheetSpreadsheetID,
+		Range:            c.SheetRange,
+		ColumnMapping:    c.SheetColumnMapping,
+		SyncIntervalMins: c.SheetSyncIntervalMins,
+		LastSyncedAt:     c.SheetLastSyncedAt,
+		SyncError:        c.SheetSyncError,
+	}
 }
 
 // RecipientRequest represents recipient import request
@@ -56,6 +104,16 @@ type RecipientRequest struct {
 	TemplateParams map[string]interface{} `json:"template_params"`
 }
 
+// recipientInsertBatchSize is how many recipients are inserted per statement, keeping
+// large imports well under PostgreSQL's bind parameter limit
+const recipientInsertBatchSize = 1000
+
+// RejectedRecipient is a recipient that was not imported and why
+type RejectedRecipient struct {
+	PhoneNumber string `json:"phone_number"`
+	Reason      string `json:"reason"`
+}
+
 // ListCampaigns implements campaign listing
 func (a *App) ListCampaigns(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
@@ -80,13 +138,14 @@ func (a *App) ListCampaigns(r *fastglue.Request) error {
 	if whatsappAccount != "" {
 		query = query.Where("whats_app_account = ?", whatsappAccount)
 	}
+	loc := a.getOrgLocation(orgID)
 	if fromDate != "" {
-		if parsedFrom, err := time.Parse("2006-01-02", fromDate); err == nil {
+		if parsedFrom, err := time.ParseInLocation("2006-01-02", fromDate, loc); err == nil {
 			query = query.Where("created_at >= ?", parsedFrom)
 		}
 	}
 	if toDate != "" {
-		if parsedTo, err := time.Parse("2006-01-02", toDate); err == nil {
+		if parsedTo, err := time.ParseInLocation("2006-01-02", toDate, loc); err == nil {
 			// End of day
 			endOfDay := parsedTo.Add(24*time.Hour - time.Nanosecond)
 			query = query.Where("created_at <= ?", endOfDay)
@@ -110,16 +169,25 @@ func (a *App) ListCampaigns(r *fastglue.Request) error {
 			HeaderMediaFilename: c.HeaderMediaFilename,
 			HeaderMediaMimeType: c.HeaderMediaMimeType,
 			Status:              c.Status,
+			TrackLinks:          c.TrackLinks,
+			Sheet:               campaignSheetSource(&c),
 			TotalRecipients:     c.TotalRecipients,
 			SentCount:           c.SentCount,
 			DeliveredCount:      c.DeliveredCount,
 			ReadCount:           c.ReadCount,
 			FailedCount:         c.FailedCount,
+			MaxBudget:           c.MaxBudget,
+			EstimatedCost:       c.EstimatedCost,
+			ActualCost:          c.ActualCost,
 			ScheduledAt:         c.ScheduledAt,
+			SendWindowStart:     c.SendWindowStart,
+			SendWindowEnd:       c.SendWindowEnd,
 			StartedAt:           c.StartedAt,
 			CompletedAt:         c.CompletedAt,
 			CreatedAt:           c.CreatedAt,
 			UpdatedAt:           c.UpdatedAt,
+
+			TemplateParamMapping: c.TemplateParamMapping,
 		}
 		if c.Template != nil {
 			response[i].TemplateName = c.Template.Name
@@ -148,6 +216,12 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 	if err := r.Decode(&req, "json"); err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
+	if req.MaxBudget != nil && *req.MaxBudget < 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Budget cannot be negative", nil, "")
+	}
+	if _, err := sendwindow.Parse(req.SendWindowStart, req.SendWindowEnd); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
 
 	// Validate template exists
 	templateID, err := uuid.Parse(req.TemplateID)
@@ -159,6 +233,13 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 	if err := a.DB.Where("id = ? AND organization_id = ?", templateID, orgID).First(&template).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template not found", nil, "")
 	}
+	if template.ArchivedAt != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template is archived", nil, "")
+	}
+	mapping, err := campaignParamMapping(&template, req.TemplateParamMapping)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
 
 	// Validate WhatsApp account exists
 	var account models.WhatsAppAccount
@@ -173,8 +254,14 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 		TemplateID:      templateID,
 		HeaderMediaID:  req.HeaderMediaID,
 		Status:          models.CampaignStatusDraft,
+		TrackLinks:      req.TrackLinks,
+		MaxBudget:       req.MaxBudget,
 		ScheduledAt:     req.ScheduledAt,
+		SendWindowStart: req.SendWindowStart,
+		SendWindowEnd:   req.SendWindowEnd,
 		CreatedBy:       userID,
+
+		TemplateParamMapping: mapping.JSONB(),
 	}
 
 	if err := a.DB.Create(&campaign).Error; err != nil {
@@ -194,13 +281,22 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 		HeaderMediaFilename: campaign.HeaderMediaFilename,
 		HeaderMediaMimeType: campaign.HeaderMediaMimeType,
 		Status:              campaign.Status,
+		TrackLinks:          campaign.TrackLinks,
+		Sheet:               campaignSheetSource(&campaign),
 		TotalRecipients:     campaign.TotalRecipients,
 		SentCount:           campaign.SentCount,
 		DeliveredCount:      campaign.DeliveredCount,
 		FailedCount:         campaign.FailedCount,
+		MaxBudget:           campaign.MaxBudget,
+		EstimatedCost:       campaign.EstimatedCost,
+		ActualCost:          campaign.ActualCost,
 		ScheduledAt:         campaign.ScheduledAt,
+		SendWindowStart:     campaign.SendWindowStart,
+		SendWindowEnd:       campaign.SendWindowEnd,
 		CreatedAt:           campaign.CreatedAt,
 		UpdatedAt:           campaign.UpdatedAt,
+
+		TemplateParamMapping: campaign.TemplateParamMapping,
 	})
 }
 
@@ -233,15 +329,24 @@ func (a *App) GetCampaign(r *fastglue.Request) error {
 		HeaderMediaFilename: campaign.HeaderMediaFilename,
 		HeaderMediaMimeType: campaign.HeaderMediaMimeType,
 		Status:              campaign.Status,
+		TrackLinks:          campaign.TrackLinks,
+		Sheet:               campaignSheetSource(&campaign),
 		TotalRecipients:     campaign.TotalRecipients,
 		SentCount:           campaign.SentCount,
 		DeliveredCount:      campaign.DeliveredCount,
 		FailedCount:         campaign.FailedCount,
+		MaxBudget:           campaign.MaxBudget,
+		EstimatedCost:       campaign.EstimatedCost,
+		ActualCost:          campaign.ActualCost,
 		ScheduledAt:         campaign.ScheduledAt,
+		SendWindowStart:     campaign.SendWindowStart,
+		SendWindowEnd:       campaign.SendWindowEnd,
 		StartedAt:           campaign.StartedAt,
 		CompletedAt:         campaign.CompletedAt,
 		CreatedAt:           campaign.CreatedAt,
 		UpdatedAt:           campaign.UpdatedAt,
+
+		TemplateParamMapping: campaign.TemplateParamMapping,
 	}
 	if campaign.Template != nil {
 		response.TemplateName = campaign.Template.Name
@@ -268,30 +373,57 @@ func (a *App) UpdateCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
 	}
 
-	// Only allow updates to draft campaigns
-	if campaign.Status != models.CampaignStatusDraft {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only update draft campaigns", nil, "")
+	// Only allow updates to campaigns that haven't started
+	if campaign.Status != models.CampaignStatusDraft && campaign.Status != models.CampaignStatusScheduled {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only update draft or scheduled campaigns", nil, "")
 	}
 
 	var req CampaignRequest
 	if err := r.Decode(&req, "json"); err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
+	if req.MaxBudget != nil && *req.MaxBudget < 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Budget cannot be negative", nil, "")
+	}
+	if _, err := sendwindow.Parse(req.SendWindowStart, req.SendWindowEnd); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
 
 	// Update fields
 	updates := map[string]interface{}{
-		"name":         req.Name,
-		"scheduled_at": req.ScheduledAt,
+		"name":              req.Name,
+		"scheduled_at":      req.ScheduledAt,
+		"track_links":       req.TrackLinks,
+		"max_budget":        req.MaxBudget,
+		"send_window_start": req.SendWindowStart,
+		"send_window_end":   req.SendWindowEnd,
 	}
 
+	// Removing the schedule turns a scheduled campaign back into a draft
+	if campaign.Status == models.CampaignStatusScheduled && req.ScheduledAt == nil {
+		updates["status"] = models.CampaignStatusDraft
+	}
+
+	templateID := campaign.TemplateID
 	if req.TemplateID != "" {
-		templateID, err := uuid.Parse(req.TemplateID)
+		templateID, err = uuid.Parse(req.TemplateID)
 		if err != nil {
 			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template ID", nil, "")
 		}
 		updates["template_id"] = templateID
 	}
 
+	// The placeholder mapping is checked against the campaign's template, new or current
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", templateID, orgID).First(&template).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template not found", nil, "")
+	}
+	mapping, err := campaignParamMapping(&template, req.TemplateParamMapping)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	updates["template_param_mapping"] = mapping.JSONB()
+
 	if req.WhatsAppAccount != "" {
 		updates["whats_app_account"] = req.WhatsAppAccount
 	}
@@ -313,13 +445,22 @@ func (a *App) UpdateCampaign(r *fastglue.Request) error {
 		HeaderMediaFilename: campaign.HeaderMediaFilename,
 		HeaderMediaMimeType: campaign.HeaderMediaMimeType,
 		Status:              campaign.Status,
+		TrackLinks:          campaign.TrackLinks,
+		Sheet:               campaignSheetSource(&campaign),
 		TotalRecipients:     campaign.TotalRecipients,
 		SentCount:           campaign.SentCount,
 		DeliveredCount:      campaign.DeliveredCount,
 		FailedCount:         campaign.FailedCount,
+		MaxBudget:           campaign.MaxBudget,
+		EstimatedCost:       campaign.EstimatedCost,
+		ActualCost:          campaign.ActualCost,
 		ScheduledAt:         campaign.ScheduledAt,
+		SendWindowStart:     campaign.SendWindowStart,
+		SendWindowEnd:       campaign.SendWindowEnd,
 		CreatedAt:           campaign.CreatedAt,
 		UpdatedAt:           campaign.UpdatedAt,
+
+		TemplateParamMapping: campaign.TemplateParamMapping,
 	}
 	if campaign.Template != nil {
 		response.TemplateName = campaign.Template.Name
@@ -384,7 +525,7 @@ func (a *App) StartCampaign(r *fastglue.Request) error {
 	}
 
 	var campaign models.BulkMessageCampaign
-	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&campaign).Error; err != nil {
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Preload("Template").First(&campaign).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
 	}
 
@@ -393,57 +534,129 @@ func (a *App) StartCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign cannot be started in current state", nil, "")
 	}
 
-	// Get all pending recipients
-	var recipients []models.BulkMessageRecipient
-	if err := a.DB.Where("campaign_id = ? AND status = ?", id, models.MessageStatusPending).Find(&recipients).Error; err != nil {
+	// Drafts with a future start time are scheduled instead; starting a scheduled campaign sends it now
+	schedule := campaign.Status == models.CampaignStatusDraft && campaign.ScheduledAt != nil && campaign.ScheduledAt.After(time.Now())
+
+	recipients, estimate, err := a.prepareCampaignStart(&campaign)
+	var unresolved *unresolvedParamsError
+	switch {
+	case errors.Is(err, errNoPendingRecipients):
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign has no pending recipients", nil, "")
+	case errors.As(err, &unresolved):
+		// Refuse to start while some recipients would get a message with empty placeholders
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, unresolved.Error(), unresolved.Details(), "")
+	case errors.Is(err, errCampaignOverBudget):
+		// Refuse to start when the pending sends are expected to exceed the remaining budget
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+			fmt.Sprintf("Estimated cost %.2f %s exceeds the remaining campaign budget of %.2f %s",
+				estimate.Total, estimate.Currency, *estimate.RemainingBudget, estimate.Currency),
+			estimate, "")
+	case err != nil:
 		a.Log.Error("Failed to load recipients", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load recipients", nil, "")
 	}
 
+	if schedule {
+		if err := a.DB.Model(&campaign).Update("status", models.CampaignStatusScheduled).Error; err != nil {
+			a.Log.Error("Failed to schedule campaign", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to schedule campaign", nil, "")
+		}
+
+		a.Log.Info("Campaign scheduled", "campaign_id", id, "scheduled_at", campaign.ScheduledAt)
+
+		return r.SendEnvelope(map[string]interface{}{
+			"message":      "Campaign scheduled",
+			"status":       models.CampaignStatusScheduled,
+			"scheduled_at": campaign.ScheduledAt,
+		})
+	}
+
+	if err := a.queueCampaign(r.RequestCtx, &campaign, recipients, estimate.Total); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue recipients", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Campaign started",
+		"status":  models.CampaignStatusProcessing,
+	})
+}
+
+var (
+	// errNoPendingRecipients is returned when a campaign has nobody left to send to
+	errNoPendingRecipients = errors.New("campaign has no pending recipients")
+	// errCampaignOverBudget is returned when the pending sends would exceed the campaign budget
+	errCampaignOverBudget = errors.New("estimated cost exceeds the remaining campaign budget")
+)
+
+// prepareCampaignStart loads a campaign's pending recipients, checks every template
+// placeholder has a value for them and estimates the cost of sending to them. The
+// campaign's Template must be loaded.
+func (a *App) prepareCampaignStart(campaign *models.BulkMessageCampaign) ([]models.BulkMessageRecipient, CampaignEstimateResponse, error) {
+	var recipients []models.BulkMessageRecipient
+	if err := a.DB.Where("campaign_id = ? AND status = ?", campaign.ID, models.MessageStatusPending).Find(&recipients).Error; err != nil {
+		return nil, CampaignEstimateResponse{}, err
+	}
 	if len(recipients) == 0 {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign has no pending recipients", nil, "")
+		return nil, CampaignEstimateResponse{}, errNoPendingRecipients
+	}
+	if err := a.checkCampaignParams(campaign, recipients); err != nil {
+		return recipients, CampaignEstimateResponse{}, err
+	}
+
+	phoneNumbers := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		phoneNumbers[i] = recipient.PhoneNumber
 	}
+	estimate := buildCampaignEstimate(campaign, phoneNumbers)
+	if !estimate.WithinBudget {
+		return recipients, estimate, errCampaignOverBudget
+	}
+	return recipients, estimate, nil
+}
 
-	// Update status to processing
+// queueCampaign marks a campaign as processing and enqueues its pending recipients
+func (a *App) queueCampaign(ctx context.Context, campaign *models.BulkMessageCampaign, recipients []models.BulkMessageRecipient, estimatedCost float64) error {
 	now := time.Now()
 	updates := map[string]interface{}{
-		"status":     models.CampaignStatusProcessing,
-		"started_at": now,
+		"status":         models.CampaignStatusProcessing,
+		"started_at":     now,
+		"estimated_cost": campaign.ActualCost + estimatedCost,
 	}
 
-	if err := a.DB.Model(&campaign).Updates(updates).Error; err != nil {
+	if err := a.DB.Model(campaign).Updates(updates).Error; err != nil {
 		a.Log.Error("Failed to start campaign", "error", err)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start campaign", nil, "")
+		return err
 	}
 
-	a.Log.Info("Campaign started", "campaign_id", id, "recipients", len(recipients))
+	// Every pending recipient is queued below, so none are waiting on their send window any more
+	a.DB.Model(&models.BulkMessageRecipient{}).
+		Where("campaign_id = ? AND status = ? AND deferred_until IS NOT NULL", campaign.ID, models.MessageStatusPending).
+		Update("deferred_until", nil)
+
+	a.Log.Info("Campaign started", "campaign_id", campaign.ID, "recipients", len(recipients))
 
 	// Enqueue all recipients as individual jobs for parallel processing
 	jobs := make([]*queue.RecipientJob, len(recipients))
 	for i, recipient := range recipients {
 		jobs[i] = &queue.RecipientJob{
-			CampaignID:     id,
+			CampaignID:     campaign.ID,
 			RecipientID:    recipient.ID,
-			OrganizationID: orgID,
+			OrganizationID: campaign.OrganizationID,
 			PhoneNumber:    recipient.PhoneNumber,
 			RecipientName:  recipient.RecipientName,
 			TemplateParams: recipient.TemplateParams,
 		}
 	}
 
-	if err := a.Queue.EnqueueRecipients(r.RequestCtx, jobs); err != nil {
+	if err := a.Queue.EnqueueRecipients(ctx, jobs); err != nil {
 		a.Log.Error("Failed to enqueue recipients", "error", err)
 		// Revert status on failure
-		a.DB.Model(&campaign).Update("status", models.CampaignStatusDraft)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue recipients", nil, "")
+		a.DB.Model(campaign).Update("status", models.CampaignStatusDraft)
+		return err
 	}
 
-	a.Log.Info("Recipients enqueued for processing", "campaign_id", id, "count", len(jobs))
-
-	return r.SendEnvelope(map[string]interface{}{
-		"message": "Campaign started",
-		"status":  models.CampaignStatusProcessing,
-	})
+	a.Log.Info("Recipients enqueued for processing", "campaign_id", campaign.ID, "count", len(jobs))
+	return nil
 }
 
 // PauseCampaign implements pausing a campaign
@@ -638,35 +851,138 @@ func (a *App) ImportRecipients(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
-	// Create recipients
-	recipients := make([]models.BulkMessageRecipient, len(req.Recipients))
-	for i, rec := range req.Recipients {
-		recipients[i] = models.BulkMessageRecipient{
-			CampaignID:     id,
-			PhoneNumber:    rec.PhoneNumber,
+	added, rejected, err := a.addCampaignRecipients(&campaign, req.Recipients, false)
+	if err != nil {
+		a.Log.Error("Failed to add recipients", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to add recipients", nil, "")
+	}
+	if added == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No valid recipients", map[string]interface{}{
+			"rejected": rejected,
+		}, "")
+	}
+
+	a.Log.Info("Recipients added to campaign", "campaign_id", id, "count", added, "rejected", len(rejected))
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":          "Recipients added successfully",
+		"added_count":      added,
+		"rejected_count":   len(rejected),
+		"rejected":         rejected,
+		"total_recipients": campaign.TotalRecipients,
+	})
+}
+
+// addCampaignRecipients normalizes and stores recipients, dropping invalid, restricted
+// or opted-out numbers, and refreshes the campaign's recipient count. With skipExisting,
+// numbers already on the campaign are ignored so a source can be re-imported safely.
+func (a *App) addCampaignRecipients(campaign *models.BulkMessageCampaign, reqs []RecipientRequest, skipExisting bool) (int, []RejectedRecipient, error) {
+	// Normalize phone numbers and drop invalid or restricted destinations
+	restrictions := a.getOrgCountryRestrictions(campaign.OrganizationID)
+	recipients := make([]models.BulkMessageRecipient, 0, len(reqs))
+	rejected := []RejectedRecipient{}
+	seen := make(map[string]bool)
+	for _, rec := range reqs {
+		phoneNumber, err := phone.Normalize(rec.PhoneNumber)
+		if err == nil {
+			err = restrictions.Check(phoneNumber)
+		}
+		if err != nil {
+			rejected = append(rejected, RejectedRecipient{PhoneNumber: rec.PhoneNumber, Reason: err.Error()})
+			continue
+		}
+		if skipExisting {
+			if seen[phoneNumber] {
+				continue
+			}
+			seen[phoneNumber] = true
+		}
+
+		recipients = append(recipients, models.BulkMessageRecipient{
+			CampaignID:     campaign.ID,
+			PhoneNumber:    phoneNumber,
 			RecipientName:  rec.RecipientName,
 			TemplateParams: models.JSONB(rec.TemplateParams),
 			Status:         models.MessageStatusPending,
+		})
+	}
+
+	// Contacts who opted out don't receive campaigns
+	numbers := make([]string, len(recipients))
+	for i, rec := range recipients {
+		numbers[i] = rec.PhoneNumber
+	}
+	optedOut, err := a.optedOutNumbers(campaign.OrganizationID, numbers)
+	if err != nil {
+		return 0, rejected, err
+	}
+	if len(optedOut) > 0 {
+		kept := recipients[:0]
+		for _, rec := range recipients {
+			if optedOut[rec.PhoneNumber] {
+				rejected = append(rejected, RejectedRecipient{PhoneNumber: rec.PhoneNumber, Reason: optout.ErrOptedOut.Error()})
+				continue
+			}
+			kept = append(kept, rec)
 		}
+		recipients = kept
 	}
 
-	if err := a.DB.Create(&recipients).Error; err != nil {
-		a.Log.Error("Failed to add recipients", "error", err)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to add recipients", nil, "")
+	if skipExisting {
+		if recipients, err = a.withoutExistingRecipients(campaign.ID, recipients); err != nil {
+			return 0, rejected, err
+		}
+	}
+	if len(recipients) == 0 {
+		return 0, rejected, nil
+	}
+	if err := a.DB.CreateInBatches(&recipients, recipientInsertBatchSize).Error; err != nil {
+		return 0, rejected, err
 	}
 
-	// Update total recipients count
-	var totalCount int64
-	a.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ?", id).Count(&totalCount)
-	a.DB.Model(&campaign).Update("total_recipients", totalCount)
+	// Update total recipients count without recounting large campaigns
+	if err := a.DB.Model(&models.BulkMessageCampaign{}).Where("id = ?", campaign.ID).
+		UpdateColumn("total_recipients", gorm.Expr("total_recipients + ?", len(recipients))).Error; err != nil {
+		return len(recipients), rejected, err
+	}
+	var totalCount int
+	a.DB.Model(&models.BulkMessageCampaign{}).Where("id = ?", campaign.ID).Select("total_recipients").Scan(&totalCount)
+	campaign.TotalRecipients = totalCount
 
-	a.Log.Info("Recipients added to campaign", "campaign_id", id, "count", len(req.Recipients))
+	return len(recipients), rejected, nil
+}
 
-	return r.SendEnvelope(map[string]interface{}{
-		"message":          "Recipients added successfully",
-		"added_count":      len(req.Recipients),
-		"total_recipients": totalCount,
-	})
+// withoutExistingRecipients drops recipients whose number is already on the campaign,
+// looking the numbers up in batches rather than loading the whole campaign
+func (a *App) withoutExistingRecipients(campaignID uuid.UUID, recipients []models.BulkMessageRecipient) ([]models.BulkMessageRecipient, error) {
+	existing := make(map[string]bool)
+	for start := 0; start < len(recipients); start += recipientInsertBatchSize {
+		end := min(start+recipientInsertBatchSize, len(recipients))
+		numbers := make([]string, 0, end-start)
+		for _, rec := range recipients[start:end] {
+			numbers = append(numbers, rec.PhoneNumber)
+		}
+		var found []string
+		if err := a.DB.Model(&models.BulkMessageRecipient{}).
+			Where("campaign_id = ? AND phone_number IN ?", campaignID, numbers).
+			Pluck("phone_number", &found).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range found {
+			existing[p] = true
+		}
+	}
+	if len(existing) == 0 {
+		return recipients, nil
+	}
+
+	kept := recipients[:0]
+	for _, rec := range recipients {
+		if !existing[rec.PhoneNumber] {
+			kept = append(kept, rec)
+		}
+	}
+	return kept, nil
 }
 
 // GetCampaignRecipients implements listing campaign recipients
@@ -694,6 +1010,12 @@ func (a *App) GetCampaignRecipients(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list recipients", nil, "")
 	}
 
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	mask := a.dataMaskFor(orgID, userID)
+	for i := range recipients {
+		mask.Recipient(&recipients[i])
+	}
+
 	return r.SendEnvelope(map[string]interface{}{
 		"recipients": recipients,
 		"total":      len(recipients),
@@ -827,7 +1149,7 @@ func (a *App) UploadCampaignMedia(r *fastglue.Request) error {
 	}
 
 	// Save file locally for preview
-	localPath, err := a.saveCampaignMedia(campaignID, data, mimeType)
+	localPath, err := a.saveCampaignMedia(orgID, campaignID, data, mimeType)
 	if err != nil {
 		a.Log.Error("Failed to save media locally", "error", err)
 		// Don't fail the request, just log the error - preview won't work
@@ -857,7 +1179,7 @@ func (a *App) UploadCampaignMedia(r *fastglue.Request) error {
 }
 
 // saveCampaignMedia saves uploaded media locally for preview
-func (a *App) saveCampaignMedia(campaignID string, data []byte, mimeType string) (string, error) {
+func (a *App) saveCampaignMedia(orgID uuid.UUID, campaignID string, data []byte, mimeType string) (string, error) {
 	// Determine file extension
 	ext := getExtensionFromMimeType(mimeType)
 	if ext == "" {
@@ -865,22 +1187,19 @@ func (a *App) saveCampaignMedia(campaignID string, data []byte, mimeType string)
 	}
 
 	// Create campaigns media directory
-	subdir := "campaigns"
-	if err := a.ensureMediaDir(subdir); err != nil {
+	subdir, err := a.ensureMediaDir(orgID, "campaigns")
+	if err != nil {
 		return "", fmt.Errorf("failed to create media directory: %w", err)
 	}
 
 	// Generate filename using campaign ID
-	filename := campaignID + ext
-	filePath := filepath.Join(a.getMediaStoragePath(), subdir, filename)
+	relativePath := filepath.Join(subdir, campaignID+ext)
 
 	// Save file
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := a.writeMediaFile(orgID, relativePath, data); err != nil {
 		return "", fmt.Errorf("failed to save media file: %w", err)
 	}
 
-	// Return relative path for storage
-	relativePath := filepath.Join(subdir, filename)
 	a.Log.Info("Campaign media saved locally", "path", relativePath, "size", len(data))
 
 	return relativePath, nil
@@ -919,7 +1238,7 @@ func (a *App) ServeCampaignMedia(r *fastglue.Request) error {
 	}
 
 	// Build full path
-	fullPath := filepath.Join(a.getMediaStoragePath(), filePath)
+	fullPath := a.mediaFullPath(filePath)
 
 	// Check if file exists
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
@@ -927,8 +1246,11 @@ func (a *App) ServeCampaignMedia(r *fastglue.Request) error {
 	}
 
 	// Read file
-	data, err := os.ReadFile(fullPath)
+	data, err := a.readMediaFile(orgID, filePath)
 	if err != nil {
+		if errors.Is(err, byok.ErrKeyUnavailable) {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization encryption key is unavailable", nil, "")
+		}
 		a.Log.Error("Failed to read media file", "path", fullPath, "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read file", nil, "")
 	}
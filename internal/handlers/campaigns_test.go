@@ -20,8 +20,10 @@ import (
 
 // MockQueue implements queue.Queue for testing
 type MockQueue struct {
-	EnqueuedJobs []*queue.RecipientJob
-	EnqueueErr   error
+	EnqueuedJobs          []*queue.RecipientJob
+	TemplateSendJobs      []*queue.TemplateSendJob
+	ContactBulkUpdateJobs []*queue.ContactBulkUpdateJob
+	EnqueueErr            error
 }
 
 func (m *MockQueue) EnqueueRecipient(ctx context.Context, job *queue.RecipientJob) error {
@@ -40,6 +42,22 @@ func (m *MockQueue) EnqueueRecipients(ctx context.Context, jobs []*queue.Recipie
 	return nil
 }
 
+func (m *MockQueue) EnqueueTemplateSends(ctx context.Context, jobs []*queue.TemplateSendJob) error {
+	if m.EnqueueErr != nil {
+		return m.EnqueueErr
+	}
+	m.TemplateSendJobs = append(m.TemplateSendJobs, jobs...)
+	return nil
+}
+
+func (m *MockQueue) EnqueueContactBulkUpdate(ctx context.Context, job *queue.ContactBulkUpdateJob) error {
+	if m.EnqueueErr != nil {
+		return m.EnqueueErr
+	}
+	m.ContactBulkUpdateJobs = append(m.ContactBulkUpdateJobs, job)
+	return nil
+}
+
 func (m *MockQueue) Close() error {
 	return nil
 }
@@ -133,6 +151,8 @@ func createTestRecipient(t *testing.T, app *handlers.App, campaignID uuid.UUID,
 		PhoneNumber:   phone,
 		RecipientName: "Test Recipient",
 		Status:        status,
+		// Fills the "Hello {{1}}" placeholder of createTestTemplate
+		TemplateParams: models.JSONB{"1": "Test Recipient"},
 	}
 	require.NoError(t, app.DB.Create(recipient).Error)
 	return recipient
@@ -735,7 +755,7 @@ func TestApp_ImportRecipients_Success(t *testing.T) {
 	req := testutil.NewJSONRequest(t, map[string]interface{}{
 		"recipients": []map[string]interface{}{
 			{"phone_number": "+1234567890", "recipient_name": "John Doe"},
-			{"phone_number": "+0987654321", "recipient_name": "Jane Doe"},
+			{"phone_number": "+447700900123", "recipient_name": "Jane Doe"},
 		},
 	})
 	setAuthContext(req, org.ID, user.ID)
@@ -788,6 +808,46 @@ func TestApp_ImportRecipients_WithTemplateParams(t *testing.T) {
 	assert.NotNil(t, recipient.TemplateParams)
 }
 
+func TestApp_ImportRecipients_RejectsInvalidAndRestricted(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	require.NoError(t, app.DB.Model(org).Update("settings", models.JSONB{"blocked_countries": []string{"44"}}).Error)
+	app.InvalidateOrgCountriesCache(org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("import-rejects"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "import-rejects-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	campaign := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusDraft)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"recipients": []map[string]interface{}{
+			{"phone_number": "+91 98765 43210"},
+			{"phone_number": "0987654321"},
+			{"phone_number": "+447700900123"},
+		},
+	})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", campaign.ID.String())
+
+	err := app.ImportRecipients(req)
+	require.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data struct {
+			AddedCount    int `json:"added_count"`
+			RejectedCount int `json:"rejected_count"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+	assert.Equal(t, 1, resp.Data.AddedCount)
+	assert.Equal(t, 2, resp.Data.RejectedCount)
+
+	// The accepted number is stored normalized
+	var recipient models.BulkMessageRecipient
+	require.NoError(t, app.DB.Where("campaign_id = ?", campaign.ID).First(&recipient).Error)
+	assert.Equal(t, "919876543210", recipient.PhoneNumber)
+}
+
 func TestApp_ImportRecipients_NotDraft(t *testing.T) {
 	app, _ := campaignTestApp(t)
 	org := createTestOrganization(t, app)
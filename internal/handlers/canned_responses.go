@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CannedResponseRequest represents the request body for creating/updating a canned response
@@ -231,13 +237,37 @@ func (a *App) IncrementCannedResponseUsage(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid ID", nil, "")
 	}
 
-	if err := a.DB.Model(&models.CannedResponse{}).
+	result := a.DB.Model(&models.CannedResponse{}).
 		Where("id = ? AND organization_id = ?", id, orgID).
-		UpdateColumn("usage_count", gorm.Expr("usage_count + 1")).Error; err != nil {
+		UpdateColumn("usage_count", gorm.Expr("usage_count + 1"))
+	if result.Error != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError,
 			"Failed to update usage", nil, "")
 	}
 
+	// Per-user counts rank the user's own searches
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if result.RowsAffected > 0 && userID != uuid.Nil {
+		now := time.Now()
+		usage := models.CannedResponseUsage{
+			OrganizationID:   orgID,
+			UserID:           userID,
+			CannedResponseID: id,
+			UseCount:         1,
+			LastUsedAt:       now,
+		}
+		if err := a.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "canned_response_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"use_count":    gorm.Expr("canned_response_usages.use_count + 1"),
+				"last_used_at": now,
+				"updated_at":   now,
+			}),
+		}).Create(&usage).Error; err != nil {
+			a.Log.Error("Failed to record canned response usage", "error", err, "user_id", userID)
+		}
+	}
+
 	return r.SendEnvelope(map[string]string{"message": "Usage incremented"})
 }
 
@@ -254,3 +284,103 @@ func cannedResponseToResponse(cr models.CannedResponse) CannedResponseResponse {
 		UpdatedAt:  cr.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
 }
+
+// Canned response search limits
+const (
+	defaultCannedSearchLimit = 10
+	maxCannedSearchLimit     = 50
+	// cannedSearchShortcutSimilarity is how close a shortcut must be to the query
+	// (pg_trgm similarity) to match despite typos
+	cannedSearchShortcutSimilarity = 0.3
+	// cannedSearchContentSimilarity is how close some stretch of the content must be
+	// to the query (pg_trgm word similarity)
+	cannedSearchContentSimilarity = 0.45
+)
+
+// CannedResponseSearchResult is a canned response matched by SearchCannedResponses
+type CannedResponseSearchResult struct {
+	CannedResponseResponse
+	// MyUsageCount is how often the searching user inserted this response
+	MyUsageCount int     `json:"my_usage_count"`
+	Score        float64 `json:"score"`
+}
+
+// SearchCannedResponses finds active canned responses whose shortcut or content matches
+// q, tolerating typos. Exact shortcut matches come first, then the responses the user
+// inserts most often, then the closest matches. Without q it returns the user's most
+// used responses.
+func (a *App) SearchCannedResponses(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	// The composer triggers the picker with "/shortcut"
+	q := strings.TrimPrefix(strings.TrimSpace(string(r.RequestCtx.QueryArgs().Peek("q"))), "/")
+
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if limit < 1 {
+		limit = defaultCannedSearchLimit
+	}
+	if limit > maxCannedSearchLimit {
+		limit = maxCannedSearchLimit
+	}
+
+	var rows []struct {
+		models.CannedResponse
+		MyUsageCount int
+		Score        float64
+	}
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("canned_responses").
+			Joins("LEFT JOIN canned_response_usages u ON u.canned_response_id = canned_responses.id AND u.user_id = ? AND u.deleted_at IS NULL", userID).
+			Where("canned_responses.organization_id = ? AND canned_responses.is_active = ? AND canned_responses.deleted_at IS NULL", orgID, true)
+
+		if q == "" {
+			return query.Select("canned_responses.*, COALESCE(u.use_count, 0) AS my_usage_count, 0 AS score").
+				Order("my_usage_count DESC, canned_responses.usage_count DESC, canned_responses.name").
+				Limit(limit).Scan(&rows).Error
+		}
+
+		// The % and <% operators use the trigram indexes; their thresholds are settings,
+		// scoped to this transaction
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL pg_trgm.similarity_threshold = %g", cannedSearchShortcutSimilarity)).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL pg_trgm.word_similarity_threshold = %g", cannedSearchContentSimilarity)).Error; err != nil {
+			return err
+		}
+
+		pattern := likePattern(q)
+		return query.
+			Select("canned_responses.*, COALESCE(u.use_count, 0) AS my_usage_count, "+
+				"lower(canned_responses.shortcut) = lower(@q) AS exact_shortcut, "+
+				"GREATEST(similarity(canned_responses.shortcut, @q), word_similarity(@q, canned_responses.content)) AS score",
+				map[string]interface{}{"q": q}).
+			Where("canned_responses.shortcut ILIKE @pattern OR canned_responses.content ILIKE @pattern OR "+
+				"canned_responses.shortcut % @q OR @q <% canned_responses.content",
+				map[string]interface{}{"q": q, "pattern": pattern}).
+			Order("exact_shortcut DESC, my_usage_count DESC, score DESC, canned_responses.usage_count DESC, canned_responses.name").
+			Limit(limit).Scan(&rows).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to search canned responses", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError,
+			"Failed to search canned responses", nil, "")
+	}
+
+	results := make([]CannedResponseSearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = CannedResponseSearchResult{
+			CannedResponseResponse: cannedResponseToResponse(row.CannedResponse),
+			MyUsageCount:           row.MyUsageCount,
+			Score:                  row.Score,
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"query":            q,
+		"canned_responses": results,
+	})
+}
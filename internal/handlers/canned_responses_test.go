@@ -0,0 +1,80 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_SearchCannedResponses(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	agent := createTestAgent(t, app, org.ID)
+
+	create := func(name, shortcut, content string, active bool) models.CannedResponse {
+		cr := models.CannedResponse{
+			BaseModel:      models.BaseModel{ID: uuid.New()},
+			OrganizationID: org.ID,
+			Name:           name,
+			Shortcut:       shortcut,
+			Content:        content,
+			IsActive:       true,
+			CreatedByID:    agent.ID,
+		}
+		require.NoError(t, app.DB.Create(&cr).Error)
+		if !active {
+			require.NoError(t, app.DB.Model(&cr).Update("is_active", false).Error)
+		}
+		return cr
+	}
+	refund := create("Refund done", "refund", "Your refund has been processed.", true)
+	policy := create("Refund policy", "refund-policy", "Our refund policy allows returns within 30 days.", true)
+	create("Shipping", "shipping", "Your order ships within 2 days.", true)
+	create("Old refund", "refund-old", "Refunds take 10 days.", false)
+
+	for range 2 {
+		req := testutil.NewJSONRequest(t, nil)
+		setTransferAuthContext(req, org.ID, agent.ID)
+		testutil.SetPathParam(req, "id", policy.ID.String())
+		require.NoError(t, app.IncrementCannedResponseUsage(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	}
+
+	search := func(q string) []handlers.CannedResponseSearchResult {
+		req := testutil.NewGETRequest(t)
+		setTransferAuthContext(req, org.ID, agent.ID)
+		testutil.SetQueryParam(req, "q", q)
+		require.NoError(t, app.SearchCannedResponses(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp struct {
+			Data struct {
+				CannedResponses []handlers.CannedResponseSearchResult `json:"canned_responses"`
+			} `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data.CannedResponses
+	}
+
+	// A typo still matches, and the agent's most used response ranks first
+	results := search("refudn")
+	require.Len(t, results, 2)
+	assert.Equal(t, policy.ID, results[0].ID)
+	assert.Equal(t, 2, results[0].MyUsageCount)
+	assert.Equal(t, refund.ID, results[1].ID)
+
+	// An exact shortcut beats usage
+	results = search("/refund")
+	require.NotEmpty(t, results)
+	assert.Equal(t, refund.ID, results[0].ID)
+
+	// Without a query the agent's most used responses come first
+	results = search("")
+	require.Len(t, results, 3)
+	assert.Equal(t, policy.ID, results[0].ID)
+}
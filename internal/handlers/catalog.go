@@ -61,6 +61,20 @@ type SyncCatalogsRequest struct {
 	WhatsAppAccount string `json:"whatsapp_account"`
 }
 
+// CommerceSettingsRequest represents a commerce settings update. Omitted fields are left unchanged.
+type CommerceSettingsRequest struct {
+	IsCartEnabled    *bool `json:"is_cart_enabled"`
+	IsCatalogVisible *bool `json:"is_catalog_visible"`
+}
+
+// CommerceSettingsResponse represents a phone number's commerce settings and linked catalogs
+type CommerceSettingsResponse struct {
+	WhatsAppAccount  string                 `json:"whatsapp_account"`
+	IsCartEnabled    bool                   `json:"is_cart_enabled"`
+	IsCatalogVisible bool                   `json:"is_catalog_visible"`
+	LinkedCatalogs   []whatsapp.CatalogInfo `json:"linked_catalogs"`
+}
+
 // ListCatalogs returns all catalogs for the organization
 func (a *App) ListCatalogs(r *fastglue.Request) error {
 	orgID, err := getOrganizationID(r)
@@ -282,6 +296,115 @@ func (a *App) SyncCatalogs(r *fastglue.Request) error {
 	})
 }
 
+// GetCommerceSettings returns the cart and catalog visibility settings of a WhatsApp account
+func (a *App) GetCommerceSettings(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	ctx := context.Background()
+	waAccount := a.toWhatsAppAccount(account)
+
+	settings, err := a.WhatsApp.GetCommerceSettings(ctx, waAccount)
+	if err != nil {
+		a.Log.Error("Failed to fetch commerce settings", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch commerce settings: "+err.Error(), nil, "")
+	}
+
+	linked, err := a.WhatsApp.ListLinkedCatalogs(ctx, waAccount)
+	if err != nil {
+		a.Log.Warn("Failed to fetch linked catalogs", "error", err, "account", account.Name)
+		linked = []whatsapp.CatalogInfo{}
+	}
+
+	return r.SendEnvelope(CommerceSettingsResponse{
+		WhatsAppAccount:  account.Name,
+		IsCartEnabled:    settings.IsCartEnabled,
+		IsCatalogVisible: settings.IsCatalogVisible,
+		LinkedCatalogs:   linked,
+	})
+}
+
+// UpdateCommerceSettings enables or disables the cart and catalog visibility of a WhatsApp account.
+// Enabling either requires a catalog to be linked to the WhatsApp Business Account.
+func (a *App) UpdateCommerceSettings(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var req CommerceSettingsRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	ctx := context.Background()
+	waAccount := a.toWhatsAppAccount(account)
+
+	// Start from the current settings so omitted fields are preserved
+	settings, err := a.WhatsApp.GetCommerceSettings(ctx, waAccount)
+	if err != nil {
+		a.Log.Error("Failed to fetch commerce settings", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch commerce settings: "+err.Error(), nil, "")
+	}
+	if req.IsCartEnabled != nil {
+		settings.IsCartEnabled = *req.IsCartEnabled
+	}
+	if req.IsCatalogVisible != nil {
+		settings.IsCatalogVisible = *req.IsCatalogVisible
+	}
+
+	// Product messages fail without a linked catalog, so refuse to enable commerce without one
+	var linked []whatsapp.CatalogInfo
+	if settings.IsCartEnabled || settings.IsCatalogVisible {
+		linked, err = a.WhatsApp.ListLinkedCatalogs(ctx, waAccount)
+		if err != nil {
+			a.Log.Error("Failed to fetch linked catalogs", "error", err, "account", account.Name)
+			return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to verify catalog linkage: "+err.Error(), nil, "")
+		}
+		if len(linked) == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No catalog is linked to this WhatsApp Business Account. Link a catalog in Commerce Manager before enabling the cart or catalog.", nil, "")
+		}
+	}
+
+	if err := a.WhatsApp.UpdateCommerceSettings(ctx, waAccount, settings); err != nil {
+		a.Log.Error("Failed to update commerce settings", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to update commerce settings: "+err.Error(), nil, "")
+	}
+
+	if linked == nil {
+		linked = []whatsapp.CatalogInfo{}
+	}
+
+	return r.SendEnvelope(CommerceSettingsResponse{
+		WhatsAppAccount:  account.Name,
+		IsCartEnabled:    settings.IsCartEnabled,
+		IsCatalogVisible: settings.IsCatalogVisible,
+		LinkedCatalogs:   linked,
+	})
+}
+
 // ListCatalogProducts returns all products in a catalog
 func (a *App) ListCatalogProducts(r *fastglue.Request) error {
 	orgID, err := getOrganizationID(r)
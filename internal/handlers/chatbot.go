@@ -19,6 +19,7 @@ type ChatbotSettingsResponse struct {
 	FallbackMessage       string                   `json:"fallback_message"`
 	FallbackButtons       []map[string]interface{} `json:"fallback_buttons"`
 	SessionTimeoutMinutes int                      `json:"session_timeout_minutes"`
+	RolloutPercent        int                      `json:"rollout_percent"`
 	BusinessHoursEnabled       bool                     `json:"business_hours_enabled"`
 	BusinessHours              []map[string]interface{} `json:"business_hours"`
 	OutOfHoursMessage          string                   `json:"out_of_hours_message"`
@@ -26,11 +27,17 @@ type ChatbotSettingsResponse struct {
 	AllowAgentQueuePickup        bool                     `json:"allow_agent_queue_pickup"`
 	AssignToSameAgent            bool                     `json:"assign_to_same_agent"`
 	AgentCurrentConversationOnly bool                     `json:"agent_current_conversation_only"`
+	AssignmentAcceptTimeoutSecs  int                       `json:"assignment_accept_timeout_secs"`
+	AssignmentStrategy           models.AssignmentStrategy `json:"assignment_strategy"`
+	AssignmentTeamID             *uuid.UUID                `json:"assignment_team_id"`
+	AssignmentMaxActiveChats     int                       `json:"assignment_max_active_chats"`
 	AIEnabled                    bool                     `json:"ai_enabled"`
 	AIProvider            models.AIProvider        `json:"ai_provider"`
 	AIModel               string                   `json:"ai_model"`
 	AIMaxTokens           int                      `json:"ai_max_tokens"`
 	AISystemPrompt        string                   `json:"ai_system_prompt"`
+	AIMemoryEnabled       bool                     `json:"ai_memory_enabled"`
+	AISentimentPriority   bool                     `json:"ai_sentiment_priority"`
 	// SLA Settings
 	SLAEnabled             bool     `json:"sla_enabled"`
 	SLAResponseMinutes     int      `json:"sla_response_minutes"`
@@ -46,6 +53,14 @@ type ChatbotSettingsResponse struct {
 	ClientReminderMessage  string `json:"client_reminder_message"`
 	ClientAutoCloseMinutes int    `json:"client_auto_close_minutes"`
 	ClientAutoCloseMessage string `json:"client_auto_close_message"`
+	// CSAT Survey Settings
+	CSATEnabled         bool   `json:"csat_enabled"`
+	CSATQuestion        string `json:"csat_question"`
+	CSATCommentPrompt   string `json:"csat_comment_prompt"`
+	CSATThankYouMessage string `json:"csat_thank_you_message"`
+	// Wrap-up Settings
+	WrapUpRequireCode    bool   `json:"wrap_up_require_code"`
+	WrapUpClosingMessage string `json:"wrap_up_closing_message"`
 }
 
 // ChatbotStatsResponse represents chatbot statistics
@@ -70,6 +85,7 @@ type KeywordRuleResponse struct {
 	ResponseContent json.RawMessage    `json:"response_content"`
 	Priority        int                `json:"priority"`
 	Enabled         bool               `json:"enabled"`
+	SetPriority     models.ConversationPriority `json:"set_priority,omitempty"`
 	CreatedAt       string             `json:"created_at"`
 }
 
@@ -80,7 +96,10 @@ type ChatbotFlowResponse struct {
 	Description     string   `json:"description"`
 	TriggerKeywords []string `json:"trigger_keywords"`
 	Enabled         bool     `json:"enabled"`
+	RolloutPercent  int      `json:"rollout_percent"`
 	StepsCount      int      `json:"steps_count"`
+	Version         int      `json:"version"`
+	DraftVersion    *int     `json:"draft_version,omitempty"`
 	CreatedAt       string   `json:"created_at"`
 }
 
@@ -112,6 +131,7 @@ func (a *App) GetChatbotSettings(r *fastglue.Request) error {
 			IsEnabled:          false,
 			DefaultResponse:    "Hello! How can I help you today?",
 			SessionTimeoutMins: 30,
+			RolloutPercent:     100,
 			AI:                 models.AIConfig{Enabled: false},
 		}
 	}
@@ -155,6 +175,7 @@ func (a *App) GetChatbotSettings(r *fastglue.Request) error {
 		FallbackMessage:       settings.FallbackMessage,
 		FallbackButtons:       fallbackButtons,
 		SessionTimeoutMinutes: settings.SessionTimeoutMins,
+		RolloutPercent:        settings.RolloutPercent,
 		// Business Hours
 		BusinessHoursEnabled:       settings.BusinessHours.Enabled,
 		BusinessHours:              businessHours,
@@ -164,12 +185,18 @@ func (a *App) GetChatbotSettings(r *fastglue.Request) error {
 		AllowAgentQueuePickup:        settings.AgentAssignment.AllowQueuePickup,
 		AssignToSameAgent:            settings.AgentAssignment.AssignToSameAgent,
 		AgentCurrentConversationOnly: settings.AgentAssignment.CurrentConversationOnly,
+		AssignmentAcceptTimeoutSecs:  settings.AgentAssignment.AcceptTimeoutSecs,
+		AssignmentStrategy:           settings.AgentAssignment.Strategy,
+		AssignmentTeamID:             settings.AgentAssignment.TeamID,
+		AssignmentMaxActiveChats:     settings.AgentAssignment.MaxActiveChats,
 		// AI
-		AIEnabled:      settings.AI.Enabled,
-		AIProvider:     settings.AI.Provider,
-		AIModel:        settings.AI.Model,
-		AIMaxTokens:    settings.AI.MaxTokens,
-		AISystemPrompt: settings.AI.SystemPrompt,
+		AIEnabled:           settings.AI.Enabled,
+		AIProvider:          settings.AI.Provider,
+		AIModel:             settings.AI.Model,
+		AIMaxTokens:         settings.AI.MaxTokens,
+		AISystemPrompt:      settings.AI.SystemPrompt,
+		AIMemoryEnabled:     settings.AI.MemoryEnabled,
+		AISentimentPriority: settings.AI.SentimentPriority,
 		// SLA Settings
 		SLAEnabled:             settings.SLA.Enabled,
 		SLAResponseMinutes:     settings.SLA.ResponseMinutes,
@@ -185,6 +212,14 @@ func (a *App) GetChatbotSettings(r *fastglue.Request) error {
 		ClientReminderMessage:  settings.ClientInactivity.ReminderMessage,
 		ClientAutoCloseMinutes: settings.ClientInactivity.AutoCloseMinutes,
 		ClientAutoCloseMessage: settings.ClientInactivity.AutoCloseMessage,
+		// CSAT Survey Settings
+		CSATEnabled:         settings.CSAT.Enabled,
+		CSATQuestion:        settings.CSAT.Question,
+		CSATCommentPrompt:   settings.CSAT.CommentPrompt,
+		CSATThankYouMessage: settings.CSAT.ThankYouMessage,
+		// Wrap-up Settings
+		WrapUpRequireCode:    settings.WrapUp.RequireCode,
+		WrapUpClosingMessage: settings.WrapUp.ClosingMessage,
 	}
 
 	return r.SendEnvelope(map[string]interface{}{
@@ -207,6 +242,7 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		FallbackMessage            *string                    `json:"fallback_message"`
 		FallbackButtons            *[]map[string]interface{}  `json:"fallback_buttons"`
 		SessionTimeoutMinutes      *int                       `json:"session_timeout_minutes"`
+		RolloutPercent             *int                       `json:"rollout_percent"`
 		BusinessHoursEnabled       *bool                      `json:"business_hours_enabled"`
 		BusinessHours              *[]map[string]interface{}  `json:"business_hours"`
 		OutOfHoursMessage          *string                    `json:"out_of_hours_message"`
@@ -214,12 +250,18 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		AllowAgentQueuePickup        *bool                      `json:"allow_agent_queue_pickup"`
 		AssignToSameAgent            *bool                      `json:"assign_to_same_agent"`
 		AgentCurrentConversationOnly *bool                      `json:"agent_current_conversation_only"`
+		AssignmentAcceptTimeoutSecs  *int                       `json:"assignment_accept_timeout_secs"`
+		AssignmentStrategy           *models.AssignmentStrategy `json:"assignment_strategy"`
+		AssignmentTeamID             *string                    `json:"assignment_team_id"`
+		AssignmentMaxActiveChats     *int                       `json:"assignment_max_active_chats"`
 		AIEnabled                    *bool                      `json:"ai_enabled"`
 		AIProvider                 *models.AIProvider         `json:"ai_provider"`
 		AIAPIKey                   *string                    `json:"ai_api_key"`
 		AIModel                    *string                    `json:"ai_model"`
 		AIMaxTokens                *int                       `json:"ai_max_tokens"`
 		AISystemPrompt             *string                    `json:"ai_system_prompt"`
+		AIMemoryEnabled            *bool                      `json:"ai_memory_enabled"`
+		AISentimentPriority        *bool                      `json:"ai_sentiment_priority"`
 		// SLA Settings
 		SLAEnabled             *bool     `json:"sla_enabled"`
 		SLAResponseMinutes     *int      `json:"sla_response_minutes"`
@@ -235,6 +277,14 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		ClientReminderMessage  *string `json:"client_reminder_message"`
 		ClientAutoCloseMinutes *int    `json:"client_auto_close_minutes"`
 		ClientAutoCloseMessage *string `json:"client_auto_close_message"`
+		// CSAT Survey Settings
+		CSATEnabled         *bool   `json:"csat_enabled"`
+		CSATQuestion        *string `json:"csat_question"`
+		CSATCommentPrompt   *string `json:"csat_comment_prompt"`
+		CSATThankYouMessage *string `json:"csat_thank_you_message"`
+		// Wrap-up Settings
+		WrapUpRequireCode    *bool   `json:"wrap_up_require_code"`
+		WrapUpClosingMessage *string `json:"wrap_up_closing_message"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
@@ -249,6 +299,7 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		settings = models.ChatbotSettings{
 			BaseModel:      models.BaseModel{ID: uuid.New()},
 			OrganizationID: orgID,
+			RolloutPercent: 100,
 		}
 	}
 
@@ -279,6 +330,12 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 	if req.SessionTimeoutMinutes != nil {
 		settings.SessionTimeoutMins = *req.SessionTimeoutMinutes
 	}
+	if req.RolloutPercent != nil {
+		if *req.RolloutPercent < 0 || *req.RolloutPercent > 100 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "rollout_percent must be between 0 and 100", nil, "")
+		}
+		settings.RolloutPercent = *req.RolloutPercent
+	}
 	// Business Hours
 	if req.BusinessHoursEnabled != nil {
 		settings.BusinessHours.Enabled = *req.BusinessHoursEnabled
@@ -307,6 +364,46 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 	if req.AgentCurrentConversationOnly != nil {
 		settings.AgentAssignment.CurrentConversationOnly = *req.AgentCurrentConversationOnly
 	}
+	if req.AssignmentAcceptTimeoutSecs != nil {
+		if *req.AssignmentAcceptTimeoutSecs < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "assignment_accept_timeout_secs cannot be negative", nil, "")
+		}
+		settings.AgentAssignment.AcceptTimeoutSecs = *req.AssignmentAcceptTimeoutSecs
+	}
+	if req.AssignmentStrategy != nil {
+		switch *req.AssignmentStrategy {
+		case models.AssignmentStrategyQueue, models.AssignmentStrategyRoundRobin, models.AssignmentStrategyLeastActiveChats,
+			models.AssignmentStrategyByTeam, models.AssignmentStrategyBySkill:
+		default:
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "assignment_strategy must be one of queue, round_robin, least_active_chats, by_team or by_skill", nil, "")
+		}
+		settings.AgentAssignment.Strategy = *req.AssignmentStrategy
+	}
+	if req.AssignmentTeamID != nil {
+		if *req.AssignmentTeamID == "" {
+			settings.AgentAssignment.TeamID = nil
+		} else {
+			teamID, err := uuid.Parse(*req.AssignmentTeamID)
+			if err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid assignment_team_id", nil, "")
+			}
+			var count int64
+			a.DB.Model(&models.Team{}).Where("id = ? AND organization_id = ?", teamID, orgID).Count(&count)
+			if count == 0 {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Assignment team not found", nil, "")
+			}
+			settings.AgentAssignment.TeamID = &teamID
+		}
+	}
+	if settings.AgentAssignment.Strategy == models.AssignmentStrategyByTeam && settings.AgentAssignment.TeamID == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "assignment_team_id is required for the by_team strategy", nil, "")
+	}
+	if req.AssignmentMaxActiveChats != nil {
+		if *req.AssignmentMaxActiveChats < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "assignment_max_active_chats cannot be negative", nil, "")
+		}
+		settings.AgentAssignment.MaxActiveChats = *req.AssignmentMaxActiveChats
+	}
 
 	// AI Settings
 	if req.AIEnabled != nil {
@@ -316,7 +413,12 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		settings.AI.Provider = *req.AIProvider
 	}
 	if req.AIAPIKey != nil && *req.AIAPIKey != "" {
-		settings.AI.APIKey = *req.AIAPIKey
+		apiKey, err := a.encryptOrgSecret(orgID, *req.AIAPIKey)
+		if err != nil {
+			a.Log.Error("Failed to encrypt AI API key", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization encryption key is unavailable", nil, "")
+		}
+		settings.AI.APIKey = apiKey
 	}
 	if req.AIModel != nil {
 		settings.AI.Model = *req.AIModel
@@ -327,6 +429,12 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 	if req.AISystemPrompt != nil {
 		settings.AI.SystemPrompt = *req.AISystemPrompt
 	}
+	if req.AIMemoryEnabled != nil {
+		settings.AI.MemoryEnabled = *req.AIMemoryEnabled
+	}
+	if req.AISentimentPriority != nil {
+		settings.AI.SentimentPriority = *req.AISentimentPriority
+	}
 
 	// SLA Settings
 	if req.SLAEnabled != nil {
@@ -371,6 +479,28 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		settings.ClientInactivity.AutoCloseMessage = *req.ClientAutoCloseMessage
 	}
 
+	// CSAT Survey Settings
+	if req.CSATEnabled != nil {
+		settings.CSAT.Enabled = *req.CSATEnabled
+	}
+	if req.CSATQuestion != nil {
+		settings.CSAT.Question = *req.CSATQuestion
+	}
+	if req.CSATCommentPrompt != nil {
+		settings.CSAT.CommentPrompt = *req.CSATCommentPrompt
+	}
+	if req.CSATThankYouMessage != nil {
+		settings.CSAT.ThankYouMessage = *req.CSATThankYouMessage
+	}
+
+	// Wrap-up Settings
+	if req.WrapUpRequireCode != nil {
+		settings.WrapUp.RequireCode = *req.WrapUpRequireCode
+	}
+	if req.WrapUpClosingMessage != nil {
+		settings.WrapUp.ClosingMessage = *req.WrapUpClosingMessage
+	}
+
 	if err := a.DB.Save(&settings).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save settings", nil, "")
 	}
@@ -410,6 +540,7 @@ func (a *App) ListKeywordRules(r *fastglue.Request) error {
 			ResponseContent: responseContent,
 			Priority:        rule.Priority,
 			Enabled:         rule.IsEnabled,
+			SetPriority:     rule.SetPriority,
 			CreatedAt:       rule.CreatedAt.Format(time.RFC3339),
 		}
 	}
@@ -434,6 +565,7 @@ func (a *App) CreateKeywordRule(r *fastglue.Request) error {
 		ResponseContent map[string]interface{} `json:"response_content"`
 		Priority        int                    `json:"priority"`
 		Enabled         bool                   `json:"enabled"`
+		SetPriority     models.ConversationPriority `json:"set_priority"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
@@ -443,6 +575,9 @@ func (a *App) CreateKeywordRule(r *fastglue.Request) error {
 	if len(req.Keywords) == 0 {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "At least one keyword is required", nil, "")
 	}
+	if !isValidKeywordRulePriority(req.SetPriority) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "set_priority must be urgent or high", nil, "")
+	}
 
 	// Set defaults
 	if req.MatchType == "" {
@@ -465,6 +600,7 @@ func (a *App) CreateKeywordRule(r *fastglue.Request) error {
 		ResponseContent: models.JSONB(req.ResponseContent),
 		Priority:        req.Priority,
 		IsEnabled:       req.Enabled,
+		SetPriority:     req.SetPriority,
 	}
 
 	if err := a.DB.Create(&rule).Error; err != nil {
@@ -508,6 +644,7 @@ func (a *App) GetKeywordRule(r *fastglue.Request) error {
 		ResponseContent: responseContent,
 		Priority:        rule.Priority,
 		Enabled:         rule.IsEnabled,
+		SetPriority:     rule.SetPriority,
 		CreatedAt:       rule.CreatedAt.Format(time.RFC3339),
 	}
 
@@ -540,6 +677,7 @@ func (a *App) UpdateKeywordRule(r *fastglue.Request) error {
 		ResponseContent map[string]interface{}  `json:"response_content"`
 		Priority        *int                    `json:"priority"`
 		Enabled         *bool                   `json:"enabled"`
+		SetPriority     *models.ConversationPriority `json:"set_priority"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
@@ -568,6 +706,12 @@ func (a *App) UpdateKeywordRule(r *fastglue.Request) error {
 	if req.Enabled != nil {
 		rule.IsEnabled = *req.Enabled
 	}
+	if req.SetPriority != nil {
+		if !isValidKeywordRulePriority(*req.SetPriority) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "set_priority must be urgent or high", nil, "")
+		}
+		rule.SetPriority = *req.SetPriority
+	}
 
 	if err := a.DB.Save(&rule).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update keyword rule", nil, "")
@@ -613,15 +757,15 @@ func (a *App) DeleteKeywordRule(r *fastglue.Request) error {
 // ListChatbotFlows lists all chatbot flows
 func (a *App) ListChatbotFlows(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceFlowsChatbot, models.ActionRead) {
-		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
-	}
-
 	orgID, err := a.getOrgIDFromContext(r)
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
 	}
 
+	if !a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
+	}
+
 	var flows []models.ChatbotFlow
 	if err := a.DB.Where("organization_id = ?", orgID).
 		Preload("Steps").
@@ -630,6 +774,12 @@ func (a *App) ListChatbotFlows(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to fetch flows", nil, "")
 	}
 
+	flowIDs := make([]uuid.UUID, len(flows))
+	for i, flow := range flows {
+		flowIDs[i] = flow.ID
+	}
+	published, drafts := a.flowVersionNumbers(flowIDs)
+
 	response := make([]ChatbotFlowResponse, len(flows))
 	for i, flow := range flows {
 		response[i] = ChatbotFlowResponse{
@@ -638,9 +788,14 @@ func (a *App) ListChatbotFlows(r *fastglue.Request) error {
 			Description:     flow.Description,
 			TriggerKeywords: flow.TriggerKeywords,
 			Enabled:         flow.IsEnabled,
+			RolloutPercent:  flow.RolloutPercent,
 			StepsCount:      len(flow.Steps),
+			Version:         published[flow.ID],
 			CreatedAt:       flow.CreatedAt.Format(time.RFC3339),
 		}
+		if draft, ok := drafts[flow.ID]; ok {
+			response[i].DraftVersion = &draft
+		}
 	}
 
 	return r.SendEnvelope(map[string]interface{}{
@@ -667,105 +822,101 @@ type FlowStepRequest struct {
 	SkipCondition   string                   `json:"skip_condition"`
 	RetryOnInvalid  bool                     `json:"retry_on_invalid"`
 	MaxRetries      int                      `json:"max_retries"`
+
+	// Inactivity nudge
+	InactivityTimeoutMins int    `json:"inactivity_timeout_minutes"`
+	InactivityMessage     string `json:"inactivity_message"`
+	InactivityNextStep    string `json:"inactivity_next_step"`
 }
 
-// CreateChatbotFlow creates a new chatbot flow
-func (a *App) CreateChatbotFlow(r *fastglue.Request) error {
-	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceFlowsChatbot, models.ActionWrite) {
-		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
+// validateFlowAbandonAction checks an abandonment action and its config
+func validateFlowAbandonAction(action models.FlowAbandonAction, config map[string]interface{}) string {
+	switch action {
+	case "", models.FlowAbandonActionNone, models.FlowAbandonActionTransfer:
+		return ""
+	case models.FlowAbandonActionWebhook:
+		if url, _ := config["url"].(string); url == "" {
+			return "Webhook URL is required for the webhook abandon action"
+		}
+		return ""
+	default:
+		return "Invalid abandon action"
 	}
+}
 
+// CreateChatbotFlow creates a new chatbot flow, live as its first version
+func (a *App) CreateChatbotFlow(r *fastglue.Request) error {
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 	orgID, err := a.getOrgIDFromContext(r)
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
 	}
 
+	if !a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
+	}
+
 	var req struct {
-		Name              string                 `json:"name"`
-		Description       string                 `json:"description"`
-		TriggerKeywords   []string               `json:"trigger_keywords"`
-		InitialMessage    string                 `json:"initial_message"`
-		CompletionMessage string                 `json:"completion_message"`
-		OnCompleteAction  string                 `json:"on_complete_action"`
-		CompletionConfig  map[string]interface{} `json:"completion_config"`
-		PanelConfig       map[string]interface{} `json:"panel_config"`
-		Enabled           bool                   `json:"enabled"`
-		Steps             []FlowStepRequest      `json:"steps"`
+		ChatbotFlowDefinition
+		Enabled        bool `json:"enabled"`
+		RolloutPercent *int `json:"rollout_percent"` // Defaults to 100
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
-	if req.Name == "" {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
+	if msg := req.validate(); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	rollout := 100
+	if req.RolloutPercent != nil {
+		rollout = *req.RolloutPercent
+	}
+	if rollout < 0 || rollout > 100 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "rollout_percent must be between 0 and 100", nil, "")
 	}
 
-	// Use transaction for flow + steps
+	// Use transaction for flow + steps + first version
 	tx := a.DB.Begin()
 
-	flowID := uuid.New()
 	flow := models.ChatbotFlow{
-		BaseModel:         models.BaseModel{ID: flowID},
-		OrganizationID:    orgID,
-		Name:              req.Name,
-		Description:       req.Description,
-		TriggerKeywords:   req.TriggerKeywords,
-		InitialMessage:    req.InitialMessage,
-		CompletionMessage: req.CompletionMessage,
-		OnCompleteAction:  req.OnCompleteAction,
-		CompletionConfig:  models.JSONB(req.CompletionConfig),
-		PanelConfig:       models.JSONB(req.PanelConfig),
-		IsEnabled:         req.Enabled,
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		IsEnabled:      req.Enabled,
+		RolloutPercent: rollout,
 	}
+	req.applyTo(&flow)
 
 	if err := tx.Create(&flow).Error; err != nil {
 		tx.Rollback()
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create flow", nil, "")
 	}
 
-	// Create steps
-	for i, stepReq := range req.Steps {
-		// Convert buttons to JSONBArray
-		var buttons models.JSONBArray
-		for _, btn := range stepReq.Buttons {
-			buttons = append(buttons, btn)
-		}
-
-		step := models.ChatbotFlowStep{
-			BaseModel:       models.BaseModel{ID: uuid.New()},
-			FlowID:          flowID,
-			StepName:        stepReq.StepName,
-			StepOrder:       i + 1,
-			Message:         stepReq.Message,
-			MessageType:     stepReq.MessageType,
-			InputType:       stepReq.InputType,
-			InputConfig:     models.JSONB(stepReq.InputConfig),
-			ApiConfig:       models.JSONB(stepReq.ApiConfig),
-			Buttons:         buttons,
-			TransferConfig:  models.JSONB(stepReq.TransferConfig),
-			ValidationRegex: stepReq.ValidationRegex,
-			ValidationError: stepReq.ValidationError,
-			StoreAs:         stepReq.StoreAs,
-			NextStep:        stepReq.NextStep,
-			ConditionalNext: models.JSONB(stepReq.ConditionalNext),
-			SkipCondition:   stepReq.SkipCondition,
-			RetryOnInvalid:  stepReq.RetryOnInvalid,
-			MaxRetries:      stepReq.MaxRetries,
-		}
-		if step.MessageType == "" {
-			step.MessageType = models.FlowStepTypeText
-		}
-		if step.MaxRetries == 0 {
-			step.MaxRetries = 3
-		}
-		if err := tx.Create(&step).Error; err != nil {
+	steps := buildFlowSteps(flow.ID, req.Steps)
+	if len(steps) > 0 {
+		if err := tx.Create(&steps).Error; err != nil {
 			tx.Rollback()
 			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create flow step", nil, "")
 		}
 	}
 
+	now := time.Now()
+	version := models.ChatbotFlowVersion{
+		OrganizationID: orgID,
+		FlowID:         flow.ID,
+		Version:        1,
+		Status:         models.FlowVersionStatusPublished,
+		Definition:     req.toJSONB(),
+		CreatedByID:    &userID,
+		PublishedByID:  &userID,
+		PublishedAt:    &now,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		tx.Rollback()
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create flow", nil, "")
+	}
+
 	tx.Commit()
 
 	// Invalidate cache
@@ -780,15 +931,15 @@ func (a *App) CreateChatbotFlow(r *fastglue.Request) error {
 // GetChatbotFlow gets a single chatbot flow with steps
 func (a *App) GetChatbotFlow(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceFlowsChatbot, models.ActionRead) {
-		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
-	}
-
 	orgID, err := a.getOrgIDFromContext(r)
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
 	}
 
+	if !a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
+	}
+
 	idStr := r.RequestCtx.UserValue("id").(string)
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -804,21 +955,30 @@ func (a *App) GetChatbotFlow(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
 	}
 
-	return r.SendEnvelope(flow)
+	response := ChatbotFlowDetailResponse{ChatbotFlow: flow}
+	published, drafts := a.flowVersionNumbers([]uuid.UUID{flow.ID})
+	response.PublishedVersion = published[flow.ID]
+	if draft, ok := drafts[flow.ID]; ok {
+		response.DraftVersion = &draft
+	}
+
+	return r.SendEnvelope(response)
 }
 
-// UpdateChatbotFlow updates a chatbot flow
+// UpdateChatbotFlow saves changes to a chatbot flow's content as a draft version, leaving
+// the live flow alone until the draft is published. Enabling the flow and its rollout
+// take effect immediately.
 func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceFlowsChatbot, models.ActionWrite) {
-		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
-	}
-
 	orgID, err := a.getOrgIDFromContext(r)
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
 	}
 
+	if !a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
+	}
+
 	idStr := r.RequestCtx.UserValue("id").(string)
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -826,7 +986,11 @@ func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 	}
 
 	var flow models.ChatbotFlow
-	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&flow).Error; err != nil {
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("step_order ASC")
+		}).
+		First(&flow).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
 	}
 
@@ -840,96 +1004,114 @@ func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 		CompletionConfig  map[string]interface{} `json:"completion_config"`
 		PanelConfig       map[string]interface{} `json:"panel_config"`
 		Enabled           *bool                  `json:"enabled"`
+		RolloutPercent    *int                   `json:"rollout_percent"`
 		Steps             []FlowStepRequest      `json:"steps"`
+
+		AbandonAfterMins *int                      `json:"abandon_after_minutes"`
+		AbandonAction    *models.FlowAbandonAction `json:"abandon_action"`
+		AbandonConfig    map[string]interface{}    `json:"abandon_config"`
+		AbandonMessage   *string                   `json:"abandon_message"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
+	if req.RolloutPercent != nil && (*req.RolloutPercent < 0 || *req.RolloutPercent > 100) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "rollout_percent must be between 0 and 100", nil, "")
+	}
 
 	tx := a.DB.Begin()
 
+	// Operational switches apply to the live flow
+	live := map[string]interface{}{}
+	if req.Enabled != nil {
+		live["is_enabled"] = *req.Enabled
+	}
+	if req.RolloutPercent != nil {
+		live["rollout_percent"] = *req.RolloutPercent
+	}
+	if len(live) > 0 {
+		if err := tx.Model(&flow).Updates(live).Error; err != nil {
+			tx.Rollback()
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update flow", nil, "")
+		}
+	}
+
+	editsContent := req.Name != nil || req.Description != nil || len(req.TriggerKeywords) > 0 ||
+		req.InitialMessage != nil || req.CompletionMessage != nil || req.OnCompleteAction != nil ||
+		req.CompletionConfig != nil || req.PanelConfig != nil || len(req.Steps) > 0 ||
+		req.AbandonAfterMins != nil || req.AbandonAction != nil || req.AbandonConfig != nil || req.AbandonMessage != nil
+	if !editsContent {
+		tx.Commit()
+		a.InvalidateChatbotFlowsCache(orgID)
+		return r.SendEnvelope(map[string]interface{}{
+			"message": "Flow updated successfully",
+		})
+	}
+
+	// Content edits build on the pending draft, or on the live flow when there is none
+	if err := ensureFlowVersions(tx, &flow); err != nil {
+		tx.Rollback()
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update flow", nil, "")
+	}
+	def := flowDefinitionOf(&flow)
+	var draft models.ChatbotFlowVersion
+	if err := tx.Where("flow_id = ? AND status = ?", flow.ID, models.FlowVersionStatusDraft).First(&draft).Error; err == nil {
+		if def, err = flowDefinitionFromJSONB(draft.Definition); err != nil {
+			tx.Rollback()
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read flow draft", nil, "")
+		}
+	}
+
 	if req.Name != nil {
-		flow.Name = *req.Name
+		def.Name = *req.Name
 	}
 	if req.Description != nil {
-		flow.Description = *req.Description
+		def.Description = *req.Description
 	}
 	if len(req.TriggerKeywords) > 0 {
-		flow.TriggerKeywords = req.TriggerKeywords
+		def.TriggerKeywords = req.TriggerKeywords
 	}
 	if req.InitialMessage != nil {
-		flow.InitialMessage = *req.InitialMessage
+		def.InitialMessage = *req.InitialMessage
 	}
 	if req.CompletionMessage != nil {
-		flow.CompletionMessage = *req.CompletionMessage
+		def.CompletionMessage = *req.CompletionMessage
 	}
 	if req.OnCompleteAction != nil {
-		flow.OnCompleteAction = *req.OnCompleteAction
+		def.OnCompleteAction = *req.OnCompleteAction
 	}
 	if req.CompletionConfig != nil {
-		flow.CompletionConfig = models.JSONB(req.CompletionConfig)
+		def.CompletionConfig = req.CompletionConfig
 	}
 	if req.PanelConfig != nil {
-		flow.PanelConfig = models.JSONB(req.PanelConfig)
+		def.PanelConfig = req.PanelConfig
 	}
-	if req.Enabled != nil {
-		flow.IsEnabled = *req.Enabled
+	if len(req.Steps) > 0 {
+		def.Steps = req.Steps
 	}
-
-	if err := tx.Save(&flow).Error; err != nil {
+	if req.AbandonAfterMins != nil {
+		def.AbandonAfterMins = *req.AbandonAfterMins
+	}
+	if req.AbandonAction != nil {
+		def.AbandonAction = *req.AbandonAction
+	}
+	if req.AbandonConfig != nil {
+		def.AbandonConfig = req.AbandonConfig
+	}
+	if req.AbandonMessage != nil {
+		def.AbandonMessage = *req.AbandonMessage
+	}
+	if msg := def.validate(); msg != "" {
 		tx.Rollback()
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update flow", nil, "")
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
 	}
 
-	// Update steps if provided
-	if len(req.Steps) > 0 {
-		// Delete existing steps
-		if err := tx.Where("flow_id = ?", id).Delete(&models.ChatbotFlowStep{}).Error; err != nil {
-			tx.Rollback()
-			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update flow steps", nil, "")
-		}
-
-		// Create new steps
-		for i, stepReq := range req.Steps {
-			// Convert buttons to JSONBArray
-			var buttons models.JSONBArray
-			for _, btn := range stepReq.Buttons {
-				buttons = append(buttons, btn)
-			}
-
-			step := models.ChatbotFlowStep{
-				BaseModel:       models.BaseModel{ID: uuid.New()},
-				FlowID:          id,
-				StepName:        stepReq.StepName,
-				StepOrder:       i + 1,
-				Message:         stepReq.Message,
-				MessageType:     stepReq.MessageType,
-				InputType:       stepReq.InputType,
-				InputConfig:     models.JSONB(stepReq.InputConfig),
-				ApiConfig:       models.JSONB(stepReq.ApiConfig),
-				Buttons:         buttons,
-				TransferConfig:  models.JSONB(stepReq.TransferConfig),
-				ValidationRegex: stepReq.ValidationRegex,
-				ValidationError: stepReq.ValidationError,
-				StoreAs:         stepReq.StoreAs,
-				NextStep:        stepReq.NextStep,
-				ConditionalNext: models.JSONB(stepReq.ConditionalNext),
-				SkipCondition:   stepReq.SkipCondition,
-				RetryOnInvalid:  stepReq.RetryOnInvalid,
-				MaxRetries:      stepReq.MaxRetries,
-			}
-			if step.MessageType == "" {
-				step.MessageType = models.FlowStepTypeText
-			}
-			if step.MaxRetries == 0 {
-				step.MaxRetries = 3
-			}
-			if err := tx.Create(&step).Error; err != nil {
-				tx.Rollback()
-				return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create flow step", nil, "")
-			}
-		}
+	saved, err := saveFlowDraft(tx, &flow, &def, userID)
+	if err != nil {
+		tx.Rollback()
+		a.Log.Error("Failed to save flow draft", "error", err, "flow_id", flow.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save flow draft", nil, "")
 	}
 
 	tx.Commit()
@@ -938,22 +1120,23 @@ func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 	a.InvalidateChatbotFlowsCache(orgID)
 
 	return r.SendEnvelope(map[string]interface{}{
-		"message": "Flow updated successfully",
+		"message":       "Draft saved",
+		"draft_version": saved.Version,
 	})
 }
 
 // DeleteChatbotFlow deletes a chatbot flow
 func (a *App) DeleteChatbotFlow(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceFlowsChatbot, models.ActionDelete) {
-		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
-	}
-
 	orgID, err := a.getOrgIDFromContext(r)
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
 	}
 
+	if !a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, models.ActionDelete) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
+	}
+
 	idStr := r.RequestCtx.UserValue("id").(string)
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -968,6 +1151,10 @@ func (a *App) DeleteChatbotFlow(r *fastglue.Request) error {
 		tx.Rollback()
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete flow steps", nil, "")
 	}
+	if err := tx.Where("flow_id = ?", id).Delete(&models.ChatbotFlowVersion{}).Error; err != nil {
+		tx.Rollback()
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete flow versions", nil, "")
+	}
 
 	// Delete flow
 	result := tx.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.ChatbotFlow{})
@@ -1213,7 +1400,9 @@ func (a *App) ListChatbotSessions(r *fastglue.Request) error {
 	})
 }
 
-// GetChatbotSession gets a single chatbot session with messages
+// GetChatbotSession gets a single chatbot session with messages and a replay of how
+// the chatbot handled it: steps sent, skip conditions and routing evaluated, API fetches
+// and AI prompts
 func (a *App) GetChatbotSession(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
 	if err != nil {
@@ -1229,12 +1418,22 @@ func (a *App) GetChatbotSession(r *fastglue.Request) error {
 	var session models.ChatbotSession
 	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
 		Preload("Contact").
-		Preload("Messages").
+		Preload("Messages", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
 		First(&session).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Session not found", nil, "")
 	}
 
-	return r.SendEnvelope(session)
+	var traces []models.ChatbotSessionTrace
+	if err := a.DB.Where("session_id = ?", session.ID).Order("created_at ASC").Find(&traces).Error; err != nil {
+		a.Log.Error("Failed to load session traces", "error", err, "session_id", session.ID)
+	}
+
+	return r.SendEnvelope(ChatbotSessionDetailResponse{
+		ChatbotSession: session,
+		Replay:         buildSessionReplay(session.Messages, traces),
+	})
 }
 
 // getChatbotStats returns chatbot statistics for an organization
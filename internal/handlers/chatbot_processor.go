@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/featureflags"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/plugins"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 )
@@ -43,6 +45,10 @@ type IncomingTextMessage struct {
 			Name         string `json:"name"`
 		} `json:"nfm_reply,omitempty"`
 	} `json:"interactive,omitempty"`
+	Button *struct {
+		Payload string `json:"payload"` // Template quick-reply button payload
+		Text    string `json:"text"`
+	} `json:"button,omitempty"`
 	Image *struct {
 		ID       string `json:"id"`
 		MimeType string `json:"mime_type"`
@@ -114,10 +120,11 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 		a.Log.Error("WhatsApp account not found", "phone_id", phoneNumberID, "error", err)
 		return
 	}
+	a.recordAccountMetric(account, accountMetricProcessed)
 
 	// Handle reaction messages specially - they update existing messages, not create new ones
 	if msg.Type == "reaction" && msg.Reaction != nil {
-		a.handleIncomingReaction(account, msg.From, msg.Reaction.MessageID, msg.Reaction.Emoji, profileName)
+		a.handleIncomingReaction(account, msg.From, msg.Reaction.MessageID, msg.Reaction.Emoji)
 		return
 	}
 
@@ -126,22 +133,34 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 
 	// Dispatch webhook if new contact was created
 	if isNewContact {
+		a.enrichContactWithScripts(contact)
+		a.enrichContactWithProviders(contact)
 		a.DispatchWebhook(account.OrganizationID, models.WebhookEventContactCreated, ContactEventData{
 			ContactID:       contact.ID.String(),
 			ContactPhone:    contact.PhoneNumber,
 			ContactName:     contact.ProfileName,
 			WhatsAppAccount: account.Name,
 		})
+		a.notifyPluginsContactCreated(plugins.ContactEvent{
+			OrganizationID:  account.OrganizationID,
+			ContactID:       contact.ID,
+			PhoneNumber:     contact.PhoneNumber,
+			ProfileName:     contact.ProfileName,
+			WhatsAppAccount: account.Name,
+		})
 	}
 
 	// Get message content - handle text, button replies, list replies, and media
 	messageText := ""
 	messageType := msg.Type
 	buttonID := "" // Track button/list ID for conditional routing
+	var clickType models.ButtonType
 	var mediaInfo *MediaInfo
 
 	// Track flow response data for WhatsApp Flow forms
 	var flowResponseData map[string]interface{}
+	// Location pin shared by the contact, exposed to flows as session variables
+	var location *SharedLocation
 
 	if msg.Type == "text" && msg.Text != nil {
 		messageText = msg.Text.Body
@@ -151,12 +170,14 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 			messageText = msg.Interactive.ButtonReply.Title
 			buttonID = msg.Interactive.ButtonReply.ID
 			messageType = "button_reply"
+			clickType = models.ButtonTypeReply
 		}
 		// Handle list reply
 		if msg.Interactive.ListReply != nil {
 			messageText = msg.Interactive.ListReply.Title
 			buttonID = msg.Interactive.ListReply.ID
 			messageType = "button_reply"
+			clickType = models.ButtonTypeList
 		}
 		// Handle WhatsApp Flow reply (nfm_reply)
 		if msg.Interactive.NFMReply != nil {
@@ -173,116 +194,124 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 				}
 			}
 		}
+	} else if msg.Type == "button" && msg.Button != nil {
+		// Handle template quick-reply button
+		messageText = msg.Button.Text
+		buttonID = msg.Button.Payload
+		messageType = "button_reply"
+		clickType = models.ButtonTypeQuickReply
 	} else if msg.Type == "image" && msg.Image != nil {
 		// Handle image message
 		messageText = msg.Image.Caption
 		mediaInfo = &MediaInfo{
 			MediaMimeType: msg.Image.MimeType,
-		}
-		// Download and save media locally
-		waAccount := a.toWhatsAppAccount(account)
-		if localPath, err := a.DownloadAndSaveMedia(context.Background(), msg.Image.ID, msg.Image.MimeType, waAccount); err != nil {
-			a.Log.Error("Failed to download image", "error", err, "media_id", msg.Image.ID)
-		} else {
-			mediaInfo.MediaURL = localPath
+			MediaID:       msg.Image.ID,
 		}
 	} else if msg.Type == "document" && msg.Document != nil {
 		// Handle document message
 		messageText = msg.Document.Caption
 		mediaInfo = &MediaInfo{
 			MediaMimeType: msg.Document.MimeType,
+			MediaID:       msg.Document.ID,
 			MediaFilename: msg.Document.Filename,
 		}
-		// Download and save media locally
-		waAccount := a.toWhatsAppAccount(account)
-		if localPath, err := a.DownloadAndSaveMedia(context.Background(), msg.Document.ID, msg.Document.MimeType, waAccount); err != nil {
-			a.Log.Error("Failed to download document", "error", err, "media_id", msg.Document.ID)
-		} else {
-			mediaInfo.MediaURL = localPath
-		}
 	} else if msg.Type == "video" && msg.Video != nil {
 		// Handle video message
 		messageText = msg.Video.Caption
 		mediaInfo = &MediaInfo{
 			MediaMimeType: msg.Video.MimeType,
-		}
-		// Download and save media locally
-		waAccount := a.toWhatsAppAccount(account)
-		if localPath, err := a.DownloadAndSaveMedia(context.Background(), msg.Video.ID, msg.Video.MimeType, waAccount); err != nil {
-			a.Log.Error("Failed to download video", "error", err, "media_id", msg.Video.ID)
-		} else {
-			mediaInfo.MediaURL = localPath
+			MediaID:       msg.Video.ID,
 		}
 	} else if msg.Type == "audio" && msg.Audio != nil {
 		// Handle audio message
 		mediaInfo = &MediaInfo{
 			MediaMimeType: msg.Audio.MimeType,
-		}
-		// Download and save media locally
-		waAccount := a.toWhatsAppAccount(account)
-		if localPath, err := a.DownloadAndSaveMedia(context.Background(), msg.Audio.ID, msg.Audio.MimeType, waAccount); err != nil {
-			a.Log.Error("Failed to download audio", "error", err, "media_id", msg.Audio.ID)
-		} else {
-			mediaInfo.MediaURL = localPath
+			MediaID:       msg.Audio.ID,
 		}
 	} else if msg.Type == "sticker" && msg.Sticker != nil {
 		// Handle sticker message (treat like image)
 		mediaInfo = &MediaInfo{
 			MediaMimeType: msg.Sticker.MimeType,
-		}
-		// Download and save media locally
-		waAccount := a.toWhatsAppAccount(account)
-		if localPath, err := a.DownloadAndSaveMedia(context.Background(), msg.Sticker.ID, msg.Sticker.MimeType, waAccount); err != nil {
-			a.Log.Error("Failed to download sticker", "error", err, "media_id", msg.Sticker.ID)
-		} else {
-			mediaInfo.MediaURL = localPath
+			MediaID:       msg.Sticker.ID,
 		}
 	} else if msg.Type == "location" && msg.Location != nil {
 		// Handle location message - store as JSON in content
-		locationData := map[string]any{
-			"latitude":  msg.Location.Latitude,
-			"longitude": msg.Location.Longitude,
-		}
-		if msg.Location.Name != "" {
-			locationData["name"] = msg.Location.Name
-		}
-		if msg.Location.Address != "" {
-			locationData["address"] = msg.Location.Address
+		location = &SharedLocation{
+			Latitude:  msg.Location.Latitude,
+			Longitude: msg.Location.Longitude,
+			Name:      msg.Location.Name,
+			Address:   msg.Location.Address,
 		}
-		if jsonBytes, err := json.Marshal(locationData); err == nil {
+		if jsonBytes, err := json.Marshal(location); err == nil {
 			messageText = string(jsonBytes)
 		}
 	} else if msg.Type == "contacts" && len(msg.Contacts) > 0 {
 		// Handle contacts message - store as JSON in content
-		contactsData := make([]map[string]any, 0, len(msg.Contacts))
+		contactsData := make([]SharedContact, 0, len(msg.Contacts))
 		for _, c := range msg.Contacts {
-			contact := map[string]any{
-				"name": c.Name.FormattedName,
+			shared := SharedContact{Name: c.Name.FormattedName}
+			if shared.Name == "" {
+				shared.Name = strings.TrimSpace(c.Name.FirstName + " " + c.Name.LastName)
 			}
-			if len(c.Phones) > 0 {
-				phones := make([]string, 0, len(c.Phones))
-				for _, p := range c.Phones {
-					phones = append(phones, p.Phone)
-				}
-				contact["phones"] = phones
+			for _, p := range c.Phones {
+				shared.Phones = append(shared.Phones, p.Phone)
 			}
-			contactsData = append(contactsData, contact)
+			contactsData = append(contactsData, shared)
 		}
 		if jsonBytes, err := json.Marshal(contactsData); err == nil {
 			messageText = string(jsonBytes)
 		}
 	}
 
+	// Download media locally unless the organization's media policy rejects it
+	if mediaInfo != nil {
+		a.fetchIncomingMedia(account, mediaInfo)
+	}
+
+	// Media that couldn't be fetched usually points at an expired access token
+	if mediaInfo != nil && mediaInfo.MediaURL == "" && mediaInfo.RejectedReason == "" {
+		a.recordAccountMetric(account, accountMetricFailed)
+	}
+
 	// Save incoming message to messages table (always, even if chatbot is disabled)
 	var replyToWAMID string
 	if msg.Context != nil && msg.Context.ID != "" {
 		replyToWAMID = msg.Context.ID
 	}
-	a.saveIncomingMessage(account, contact, msg.ID, messageType, messageText, mediaInfo, replyToWAMID)
+	a.saveIncomingMessage(account, contact, msg.ID, messageType, messageText, mediaInfo, replyToWAMID, flowResponseData)
+
+	if mediaInfo != nil && mediaInfo.RejectedReason != "" {
+		a.handleRejectedMedia(account, contact, mediaInfo)
+	}
+
+	// The chatbot reads a location as its coordinates rather than the stored JSON
+	if location != nil {
+		messageText = location.Coordinates()
+	}
+
+	// Track button taps against the message that carried the button
+	if clickType != "" {
+		a.recordButtonClick(account, contact, msg.ID, replyToWAMID, clickType, buttonID, messageText)
+	}
+
+	// Drip sequences set to stop on a reply or button tap end here
+	a.exitSequencesOnInbound(contact, clickType != "")
+
+	// Replies such as STOP opt the contact out of campaigns; the chatbot still answers
+	// so a keyword rule can confirm the opt-out
+	a.handleOptOutKeyword(contact, messageText)
+
+	// Keyword rules and AI sentiment can raise the conversation's place in the agent queue
+	a.updateConversationPriority(account, contact, messageText)
 
 	// Clear chatbot tracking since client has replied
 	a.ClearContactChatbotTracking(contact.ID)
 
+	// Answers to a post-conversation rating survey are not routed to the chatbot
+	if a.handleCSATResponse(account, contact, buttonID, messageText) {
+		return
+	}
+
 	// Check for active agent transfer - skip chatbot processing if transferred
 	if a.hasActiveAgentTransfer(account.OrganizationID, contact.ID) {
 		a.Log.Info("Contact has active agent transfer, skipping chatbot processing",
@@ -291,6 +320,9 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 		return
 	}
 
+	// A contact writing to an assigned agent who is away hears back once per session
+	a.sendAwayReply(account, contact)
+
 	// Check if chatbot is enabled for this account (use cache)
 	settings, err := a.getChatbotSettingsCached(account.OrganizationID, account.Name)
 	if err != nil {
@@ -303,11 +335,16 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 		a.createTransferToQueue(account, contact, models.TransferSourceChatbotDisabled)
 		return
 	}
+	if !featureflags.ContactInRollout(settings.ID, contact.PhoneNumber, settings.RolloutPercent) {
+		a.Log.Debug("Contact outside chatbot rollout, creating transfer for agent queue", "account", account.Name, "rollout_percent", settings.RolloutPercent)
+		a.createTransferToQueue(account, contact, models.TransferSourceChatbotDisabled)
+		return
+	}
 	a.Log.Info("Chatbot settings loaded", "settings_id", settings.ID, "is_enabled", settings.IsEnabled, "ai_enabled", settings.AI.Enabled, "ai_provider", settings.AI.Provider, "default_response", settings.DefaultResponse)
 
 	// Check business hours if enabled
 	if settings.BusinessHours.Enabled && len(settings.BusinessHours.Hours) > 0 {
-		if !a.isWithinBusinessHours(settings.BusinessHours.Hours) {
+		if !a.isWithinBusinessHours(account.OrganizationID, settings.BusinessHours.Hours) {
 			// If automated responses are not allowed outside hours, send out-of-hours message and stop
 			if !settings.BusinessHours.AllowAutomatedOutside {
 				a.Log.Info("Outside business hours, sending out of hours message")
@@ -334,6 +371,15 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 	// Get or create active session for this contact
 	session, isNewSession := a.getOrCreateSession(account.OrganizationID, contact.ID, account.Name, msg.From, settings.SessionTimeoutMins)
 
+	// A new session means the previous one is over: fold it into the contact's AI memory
+	if isNewSession && settings.AI.Enabled && settings.AI.MemoryEnabled {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.updateContactMemory(settings, contact.ID, session.ID)
+		}()
+	}
+
 	// Log incoming message to session
 	a.logSessionMessage(session.ID, models.DirectionIncoming, messageText, "keyword_check")
 
@@ -343,7 +389,7 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 		a.Log.Info("Transfer keyword matched", "response", keywordResponse.Body)
 		// Check business hours - if outside hours, send out of hours message instead
 		if settings.BusinessHours.Enabled && len(settings.BusinessHours.Hours) > 0 {
-			if !a.isWithinBusinessHours(settings.BusinessHours.Hours) {
+			if !a.isWithinBusinessHours(account.OrganizationID, settings.BusinessHours.Hours) {
 				a.Log.Info("Outside business hours, sending out of hours message instead of transfer")
 				if settings.BusinessHours.OutOfHoursMessage != "" {
 					if err := a.sendAndSaveTextMessage(account, contact, settings.BusinessHours.OutOfHoursMessage); err != nil {
@@ -365,12 +411,12 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 
 	// Check if user is in an active flow
 	if session.CurrentFlowID != nil {
-		a.processFlowResponse(account, session, contact, messageText, buttonID, flowResponseData)
+		a.processFlowResponse(account, session, contact, messageText, buttonID, flowResponseData, location)
 		return
 	}
 
 	// Try to match flow trigger keywords first (before greeting to avoid duplicate messages)
-	if flow := a.matchFlowTrigger(account.OrganizationID, account.Name, messageText); flow != nil {
+	if flow := a.matchFlowTrigger(account.OrganizationID, account.Name, messageText, contact.PhoneNumber); flow != nil {
 		a.startFlow(account, session, contact, flow)
 		return
 	}
@@ -423,11 +469,14 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 	}
 
 	// If no keyword matched, try AI response if enabled
+	unansweredReason := models.UnansweredReasonNoMatch
 	if settings.AI.Enabled && settings.AI.Provider != "" && settings.AI.APIKey != "" {
 		a.Log.Info("Attempting AI response", "provider", settings.AI.Provider, "model", settings.AI.Model)
+		unansweredReason = models.UnansweredReasonAIFailed
 		aiResponse, err := a.generateAIResponse(settings, session, messageText)
 		if err != nil {
 			a.Log.Error("AI response failed", "error", err, "provider", settings.AI.Provider, "model", settings.AI.Model)
+			a.recordAccountMetric(account, accountMetricChatbotErrors)
 			// Fall through to default response
 		} else if aiResponse != "" {
 			a.Log.Info("AI response generated successfully", "response_length", len(aiResponse))
@@ -443,6 +492,11 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 		a.Log.Info("AI not configured", "ai_enabled", settings.AI.Enabled, "has_provider", settings.AI.Provider != "", "has_api_key", settings.AI.APIKey != "")
 	}
 
+	// Button taps are answers to our own prompts, not questions
+	if buttonID == "" {
+		a.recordUnansweredQuestion(account, contact, session, messageText, unansweredReason)
+	}
+
 	// If no AI response or AI not enabled, send fallback message (for existing sessions)
 	// Greeting is already sent for new sessions above
 	if settings.FallbackMessage != "" && !isNewSession {
@@ -490,83 +544,97 @@ func (a *App) matchKeywordRules(orgID uuid.UUID, accountName, messageText string
 		return nil, false
 	}
 
-	messageLower := strings.ToLower(messageText)
+	for i := range rules {
+		rule := &rules[i]
+		if !keywordRuleMatches(rule, messageText) {
+			continue
+		}
 
-	for _, rule := range rules {
-		for _, keyword := range rule.Keywords {
-			keywordLower := strings.ToLower(keyword)
-			matched := false
+		response := &KeywordResponse{
+			ResponseType: rule.ResponseType,
+		}
 
-			switch rule.MatchType {
-			case models.MatchTypeExact:
-				if rule.CaseSensitive {
-					matched = messageText == keyword
-				} else {
-					matched = messageLower == keywordLower
-				}
-			case models.MatchTypeContains:
-				if rule.CaseSensitive {
-					matched = strings.Contains(messageText, keyword)
-				} else {
-					matched = strings.Contains(messageLower, keywordLower)
-				}
-			case models.MatchTypeStartsWith:
-				if rule.CaseSensitive {
-					matched = strings.HasPrefix(messageText, keyword)
-				} else {
-					matched = strings.HasPrefix(messageLower, keywordLower)
-				}
-			case models.MatchTypeRegex:
-				re, err := regexp.Compile(keyword)
-				if err == nil {
-					matched = re.MatchString(messageText)
-				}
-			default:
-				// Default to contains
-				matched = strings.Contains(messageLower, keywordLower)
+		// For transfer type, use body as the transfer message
+		if rule.ResponseType == models.ResponseTypeTransfer {
+			if body, ok := rule.ResponseContent["body"].(string); ok {
+				response.Body = a.resolveOrgVariables(orgID, body)
 			}
+			return response, true
+		}
 
-			if matched {
-				response := &KeywordResponse{
-					ResponseType: rule.ResponseType,
-				}
+		// Get response body
+		if body, ok := rule.ResponseContent["body"].(string); ok {
+			response.Body = a.resolveOrgVariables(orgID, body)
+		}
 
-				// For transfer type, use body as the transfer message
-				if rule.ResponseType == models.ResponseTypeTransfer {
-					if body, ok := rule.ResponseContent["body"].(string); ok {
-						response.Body = body
-					}
-					return response, true
+		// Get buttons if present
+		if buttons, ok := rule.ResponseContent["buttons"].([]interface{}); ok && len(buttons) > 0 {
+			response.Buttons = make([]map[string]interface{}, 0, len(buttons))
+			for _, btn := range buttons {
+				if btnMap, ok := btn.(map[string]interface{}); ok {
+					response.Buttons = append(response.Buttons, btnMap)
 				}
+			}
+		}
 
-				// Get response body
-				if body, ok := rule.ResponseContent["body"].(string); ok {
-					response.Body = body
-				}
+		if response.Body != "" {
+			return response, true
+		}
+	}
 
-				// Get buttons if present
-				if buttons, ok := rule.ResponseContent["buttons"].([]interface{}); ok && len(buttons) > 0 {
-					response.Buttons = make([]map[string]interface{}, 0, len(buttons))
-					for _, btn := range buttons {
-						if btnMap, ok := btn.(map[string]interface{}); ok {
-							response.Buttons = append(response.Buttons, btnMap)
-						}
-					}
-				}
+	return nil, false
+}
 
-				if response.Body != "" {
-					return response, true
-				}
+// keywordRuleMatches reports whether any of the rule's keywords matches the message
+func keywordRuleMatches(rule *models.KeywordRule, messageText string) bool {
+	messageLower := strings.ToLower(messageText)
+	for _, keyword := range rule.Keywords {
+		keywordLower := strings.ToLower(keyword)
+		matched := false
+
+		switch rule.MatchType {
+		case models.MatchTypeExact:
+			if rule.CaseSensitive {
+				matched = messageText == keyword
+			} else {
+				matched = messageLower == keywordLower
 			}
+		case models.MatchTypeContains:
+			if rule.CaseSensitive {
+				matched = strings.Contains(messageText, keyword)
+			} else {
+				matched = strings.Contains(messageLower, keywordLower)
+			}
+		case models.MatchTypeStartsWith:
+			if rule.CaseSensitive {
+				matched = strings.HasPrefix(messageText, keyword)
+			} else {
+				matched = strings.HasPrefix(messageLower, keywordLower)
+			}
+		case models.MatchTypeRegex:
+			re, err := regexp.Compile(keyword)
+			if err == nil {
+				matched = re.MatchString(messageText)
+			}
+		default:
+			// Default to contains
+			matched = strings.Contains(messageLower, keywordLower)
 		}
-	}
 
-	return nil, false
+		if matched {
+			return true
+		}
+	}
+	return false
 }
 
 // sendAndSaveTextMessage sends a text message and saves it to the database
 // Uses the unified SendOutgoingMessage for consistent behavior
 func (a *App) sendAndSaveTextMessage(account *models.WhatsAppAccount, contact *models.Contact, message string) error {
+	if sim := a.simulation(contact.ID); sim != nil {
+		sim.send(message, models.JSONB{"type": models.MessageTypeText})
+		return nil
+	}
 	ctx := context.Background()
 	_, err := a.SendOutgoingMessage(ctx, OutgoingMessageRequest{
 		Account: account,
@@ -574,7 +642,7 @@ func (a *App) sendAndSaveTextMessage(account *models.WhatsAppAccount, contact *m
 		Type:    models.MessageTypeText,
 		Content: message,
 	}, ChatbotSendOptions())
-	return err
+	return a.trackChatbotError(account, err)
 }
 
 // sendAndSaveInteractiveButtons sends an interactive button message and saves it to the database
@@ -611,6 +679,11 @@ func (a *App) sendAndSaveInteractiveButtons(account *models.WhatsAppAccount, con
 		interactiveType = "list"
 	}
 
+	if sim := a.simulation(contact.ID); sim != nil {
+		sim.send(bodyText, models.JSONB{"type": models.MessageTypeInteractive, "interactive_type": interactiveType, "buttons": waButtons})
+		return nil
+	}
+
 	ctx := context.Background()
 	_, err := a.SendOutgoingMessage(ctx, OutgoingMessageRequest{
 		Account:         account,
@@ -620,12 +693,16 @@ func (a *App) sendAndSaveInteractiveButtons(account *models.WhatsAppAccount, con
 		BodyText:        bodyText,
 		Buttons:         waButtons,
 	}, ChatbotSendOptions())
-	return err
+	return a.trackChatbotError(account, err)
 }
 
 // sendAndSaveCTAURLButton sends a CTA URL button message and saves it to the database
 // Uses the unified SendOutgoingMessage for consistent behavior
 func (a *App) sendAndSaveCTAURLButton(account *models.WhatsAppAccount, contact *models.Contact, bodyText, buttonText, url string) error {
+	if sim := a.simulation(contact.ID); sim != nil {
+		sim.send(bodyText, models.JSONB{"type": models.MessageTypeInteractive, "interactive_type": "cta_url", "button_text": buttonText, "url": url})
+		return nil
+	}
 	ctx := context.Background()
 	_, err := a.SendOutgoingMessage(ctx, OutgoingMessageRequest{
 		Account:         account,
@@ -636,12 +713,16 @@ func (a *App) sendAndSaveCTAURLButton(account *models.WhatsAppAccount, contact *
 		ButtonText:      buttonText,
 		URL:             url,
 	}, ChatbotSendOptions())
-	return err
+	return a.trackChatbotError(account, err)
 }
 
 // sendAndSaveFlowMessage sends a WhatsApp Flow message and saves it to the database
 // Uses the unified SendOutgoingMessage for consistent behavior
 func (a *App) sendAndSaveFlowMessage(account *models.WhatsAppAccount, contact *models.Contact, flowID, headerText, bodyText, ctaText, flowToken, firstScreen string) error {
+	if sim := a.simulation(contact.ID); sim != nil {
+		sim.send(bodyText, models.JSONB{"type": models.MessageTypeFlow, "flow_id": flowID, "header": headerText, "cta": ctaText, "first_screen": firstScreen})
+		return nil
+	}
 	ctx := context.Background()
 	_, err := a.SendOutgoingMessage(ctx, OutgoingMessageRequest{
 		Account:         account,
@@ -654,14 +735,18 @@ func (a *App) sendAndSaveFlowMessage(account *models.WhatsAppAccount, contact *m
 		FlowToken:       flowToken,
 		FlowFirstScreen: firstScreen,
 	}, ChatbotSendOptions())
-	return err
+	return a.trackChatbotError(account, err)
 }
 
 // getOrCreateContact finds or creates a contact for the phone number
 // Returns the contact and a boolean indicating if the contact was newly created
 func (a *App) getOrCreateContact(orgID uuid.UUID, phoneNumber, profileName string) (*models.Contact, bool) {
 	var contact models.Contact
-	result := a.DB.Where("organization_id = ? AND phone_number = ?", orgID, phoneNumber).First(&contact)
+	// Contacts are shared by every WhatsApp account in the org, so a customer who messages
+	// several of the org's numbers keeps one contact with per-account message threads.
+	// Legacy contacts may have been stored with a leading +.
+	result := a.DB.Where("organization_id = ? AND phone_number IN ?", orgID, []string{phoneNumber, "+" + phoneNumber}).
+		Order("created_at ASC").First(&contact)
 	if result.Error == nil {
 		// Update profile name if changed
 		if profileName != "" && contact.ProfileName != profileName {
@@ -723,6 +808,12 @@ func (a *App) getOrCreateSession(orgID, contactID uuid.UUID, accountName, phoneN
 
 // logSessionMessage logs a message to the chatbot session
 func (a *App) logSessionMessage(sessionID uuid.UUID, direction models.Direction, message, stepName string) {
+	if sim := a.simulation(sessionID); sim != nil {
+		if direction == models.DirectionOutgoing {
+			sim.nameSent(stepName)
+		}
+		return
+	}
 	msg := models.ChatbotSessionMessage{
 		BaseModel: models.BaseModel{ID: uuid.New()},
 		SessionID: sessionID,
@@ -735,8 +826,8 @@ func (a *App) logSessionMessage(sessionID uuid.UUID, direction models.Direction,
 	}
 }
 
-// matchFlowTrigger checks if the message triggers any flow
-func (a *App) matchFlowTrigger(orgID uuid.UUID, accountName, messageText string) *models.ChatbotFlow {
+// matchFlowTrigger checks if the message triggers any flow rolled out to the contact
+func (a *App) matchFlowTrigger(orgID uuid.UUID, accountName, messageText, phoneNumber string) *models.ChatbotFlow {
 	// Use cached flows (includes steps)
 	flows, err := a.getChatbotFlowsCached(orgID)
 	if err != nil {
@@ -747,6 +838,9 @@ func (a *App) matchFlowTrigger(orgID uuid.UUID, accountName, messageText string)
 	messageLower := strings.ToLower(messageText)
 
 	for _, flow := range flows {
+		if !featureflags.ContactInRollout(flow.ID, phoneNumber, flow.RolloutPercent) {
+			continue
+		}
 		for _, keyword := range flow.TriggerKeywords {
 			if strings.Contains(messageLower, strings.ToLower(keyword)) {
 				return &flow
@@ -773,7 +867,7 @@ func (a *App) startFlow(account *models.WhatsAppAccount, session *models.Chatbot
 		"_flow_id":   flow.ID.String(),
 		"_flow_name": flow.Name,
 	}
-	a.DB.Save(session)
+	a.sessionDB(session).Save(session)
 
 	// Send initial message if configured
 	if flow.InitialMessage != "" {
@@ -788,7 +882,7 @@ func (a *App) startFlow(account *models.WhatsAppAccount, session *models.Chatbot
 		firstStep := &flow.Steps[0]
 		a.Log.Info("Sending first step", "step_name", firstStep.StepName, "message_type", firstStep.MessageType, "message", firstStep.Message)
 		session.CurrentStep = firstStep.StepName
-		a.DB.Model(session).Update("current_step", firstStep.StepName)
+		a.sessionDB(session).Model(session).Update("current_step", firstStep.StepName)
 
 		a.sendStepWithSkipCheck(account, session, contact, firstStep, flow, nil)
 	} else {
@@ -798,9 +892,9 @@ func (a *App) startFlow(account *models.WhatsAppAccount, session *models.Chatbot
 }
 
 // processFlowResponse handles user response within a flow
-func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *models.ChatbotSession, contact *models.Contact, userInput string, buttonID string, flowResponseData map[string]interface{}) {
+func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *models.ChatbotSession, contact *models.Contact, userInput string, buttonID string, flowResponseData map[string]interface{}, location *SharedLocation) {
 	// Load the current flow from cache
-	flow, err := a.getChatbotFlowByIDCached(account.OrganizationID, *session.CurrentFlowID)
+	flow, err := a.sessionFlow(account.OrganizationID, session)
 	if err != nil {
 		a.Log.Error("Failed to load flow", "error", err)
 		a.exitFlow(session)
@@ -837,6 +931,10 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 		return
 	}
 
+	// The user responded: restart the inactivity timers for the step being answered.
+	// Moving on to another step reschedules them for that step.
+	a.scheduleFlowInactivity(session, flow, currentStep)
+
 	// Validate input if required (skip validation for button/list responses)
 	if currentStep.ValidationRegex != "" && buttonID == "" {
 		re, err := regexp.Compile(currentStep.ValidationRegex)
@@ -844,7 +942,7 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 			// Invalid input
 			session.StepRetries++
 			if currentStep.RetryOnInvalid && session.StepRetries < currentStep.MaxRetries {
-				a.DB.Model(session).Update("step_retries", session.StepRetries)
+				a.sessionDB(session).Model(session).Update("step_retries", session.StepRetries)
 				errorMsg := currentStep.ValidationError
 				if errorMsg == "" {
 					errorMsg = "Invalid input. Please try again."
@@ -860,6 +958,24 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 		}
 	}
 
+	// Location steps wait for the contact to share a location pin
+	if currentStep.InputType == models.InputTypeLocation && location == nil {
+		session.StepRetries++
+		if currentStep.RetryOnInvalid && session.StepRetries < currentStep.MaxRetries {
+			a.sessionDB(session).Model(session).Update("step_retries", session.StepRetries)
+			errorMsg := currentStep.ValidationError
+			if errorMsg == "" {
+				errorMsg = "Please share a location."
+			}
+			if err := a.sendAndSaveTextMessage(account, contact, errorMsg); err != nil {
+				a.Log.Error("Failed to send validation error", "error", err, "contact", contact.PhoneNumber)
+			}
+			a.logSessionMessage(session.ID, models.DirectionOutgoing, errorMsg, currentStep.StepName+"_retry")
+			return
+		}
+		a.Log.Warn("Max retries exceeded", "step", currentStep.StepName)
+	}
+
 	// Auto-validate button responses when step expects button/select input
 	// Only validate if InputType is button/select, or if buttons are configured and user clicked a button
 	shouldValidateButtons := len(currentStep.Buttons) > 0 &&
@@ -900,7 +1016,7 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 			// Invalid button selection
 			session.StepRetries++
 			a.Log.Debug("Invalid button selection", "buttonID", buttonID, "userInput", userInput, "step", currentStep.StepName, "retries", session.StepRetries)
-			a.DB.Model(session).Update("step_retries", session.StepRetries)
+			a.sessionDB(session).Model(session).Update("step_retries", session.StepRetries)
 
 			maxRetries := currentStep.MaxRetries
 			if maxRetries == 0 {
@@ -931,13 +1047,15 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 			sessionData = models.JSONB{}
 		}
 		// Store both the ID and the title for button responses
-		if buttonID != "" {
+		if location != nil {
+			storeLocation(sessionData, currentStep.StoreAs, location)
+		} else if buttonID != "" {
 			sessionData[currentStep.StoreAs] = buttonID
 			sessionData[currentStep.StoreAs+"_title"] = userInput
 		} else {
 			sessionData[currentStep.StoreAs] = userInput
 		}
-		a.DB.Model(session).Update("session_data", sessionData)
+		a.sessionDB(session).Model(session).Update("session_data", sessionData)
 		session.SessionData = sessionData
 	}
 
@@ -954,7 +1072,7 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 		}
 		// Also store the raw flow response for reference
 		sessionData["_flow_response"] = flowResponseData
-		a.DB.Model(session).Update("session_data", sessionData)
+		a.sessionDB(session).Model(session).Update("session_data", sessionData)
 		session.SessionData = sessionData
 		a.Log.Info("Stored WhatsApp Flow response in session", "fields", len(flowResponseData))
 	}
@@ -967,25 +1085,46 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 
 	// Check conditional next - use buttonID first (for button/list responses), then userInput
 	if len(currentStep.ConditionalNext) > 0 {
+		matched := ""
 		// Try buttonID first (for interactive responses)
 		if buttonID != "" {
 			if next, ok := currentStep.ConditionalNext[buttonID].(string); ok {
-				nextStepName = next
+				nextStepName, matched = next, buttonID
 			} else if next, ok := currentStep.ConditionalNext[userInput].(string); ok {
-				nextStepName = next
+				nextStepName, matched = next, userInput
 			} else if defaultNext, ok := currentStep.ConditionalNext["default"].(string); ok {
-				nextStepName = defaultNext
+				nextStepName, matched = defaultNext, "default"
 			}
 		} else {
 			// Text input - try matching the text
 			if next, ok := currentStep.ConditionalNext[userInput].(string); ok {
-				nextStepName = next
+				nextStepName, matched = next, userInput
 			} else if defaultNext, ok := currentStep.ConditionalNext["default"].(string); ok {
-				nextStepName = defaultNext
+				nextStepName, matched = defaultNext, "default"
 			}
 		}
+		a.traceSession(session.ID, models.SessionTraceKindCondition, currentStep.StepName, models.JSONB{
+			"button_id": buttonID,
+			"input":     userInput,
+			"routes":    currentStep.ConditionalNext,
+			"matched":   matched, // Empty when no route matched and the step's next step was used
+			"next_step": nextStepName,
+		})
 	}
 
+	a.notifyPluginsFlowStep(plugins.FlowStepEvent{
+		OrganizationID: account.OrganizationID,
+		FlowID:         flow.ID,
+		FlowName:       flow.Name,
+		SessionID:      session.ID,
+		ContactID:      contact.ID,
+		ContactPhone:   contact.PhoneNumber,
+		StepName:       currentStep.StepName,
+		Input:          userInput,
+		NextStep:       nextStepName,
+		SessionData:    session.SessionData,
+	})
+
 	// Move to next step or complete flow
 	if nextStepName == "" {
 		a.completeFlow(account, session, contact, flow)
@@ -1008,7 +1147,7 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 	}
 
 	// Update session and send next step message (with skip check)
-	a.DB.Model(session).Updates(map[string]interface{}{
+	a.sessionDB(session).Model(session).Updates(map[string]interface{}{
 		"current_step": nextStep.StepName,
 		"step_retries": 0,
 	})
@@ -1023,7 +1162,7 @@ func (a *App) completeFlow(account *models.WhatsAppAccount, session *models.Chat
 
 	// Send completion message
 	if flow.CompletionMessage != "" {
-		message := a.replaceVariables(flow.CompletionMessage, session.SessionData)
+		message := a.resolveOrgVariables(account.OrganizationID, a.replaceVariables(flow.CompletionMessage, session.SessionData))
 		if err := a.sendAndSaveTextMessage(account, contact, message); err != nil {
 			a.Log.Error("Failed to send flow completion message", "error", err, "contact", contact.PhoneNumber)
 		}
@@ -1032,12 +1171,16 @@ func (a *App) completeFlow(account *models.WhatsAppAccount, session *models.Chat
 
 	// Execute on-complete action
 	if flow.OnCompleteAction == "webhook" && len(flow.CompletionConfig) > 0 {
-		go a.sendFlowCompletionWebhook(flow, session, contact)
+		if sim := a.simulation(session.ID); sim != nil {
+			sim.record(simulationKindWebhook, "", "", models.JSONB{"url": flow.CompletionConfig["url"], "event": "completed"})
+		} else {
+			go a.sendFlowWebhook(flow, session, contact, flow.CompletionConfig, "completed")
+		}
 	}
 
 	// Update session (keep current_flow_id for panel config reference)
 	now := time.Now()
-	a.DB.Model(session).Updates(map[string]interface{}{
+	a.sessionDB(session).Model(session).Updates(map[string]interface{}{
 		"current_step": "",
 		"status":       models.SessionStatusCompleted,
 		"completed_at": now,
@@ -1047,10 +1190,9 @@ func (a *App) completeFlow(account *models.WhatsAppAccount, session *models.Chat
 	a.ClearContactChatbotTracking(contact.ID)
 }
 
-// sendFlowCompletionWebhook sends session data to configured webhook URL
-func (a *App) sendFlowCompletionWebhook(flow *models.ChatbotFlow, session *models.ChatbotSession, contact *models.Contact) {
-	config := flow.CompletionConfig
-
+// sendFlowWebhook sends session data to a configured webhook URL when a flow is
+// completed or abandoned
+func (a *App) sendFlowWebhook(flow *models.ChatbotFlow, session *models.ChatbotSession, contact *models.Contact, config models.JSONB, event string) {
 	// Get webhook URL (required)
 	webhookURL, ok := config["url"].(string)
 	if !ok || webhookURL == "" {
@@ -1076,7 +1218,8 @@ func (a *App) sendFlowCompletionWebhook(flow *models.ChatbotFlow, session *model
 		"contact_id":   contact.ID.String(),
 		"contact_name": contact.ProfileName,
 		"session_data": session.SessionData,
-		"completed_at": time.Now().UTC().Format(time.RFC3339),
+		"event":        event,
+		event + "_at":  time.Now().UTC().Format(time.RFC3339),
 	}
 
 	// Allow custom body template if provided
@@ -1145,7 +1288,7 @@ func (a *App) sendFlowCompletionWebhook(flow *models.ChatbotFlow, session *model
 // exitFlow ends a flow session (transfer, cancel, or error)
 func (a *App) exitFlow(session *models.ChatbotSession) {
 	now := time.Now()
-	a.DB.Model(session).Updates(map[string]interface{}{
+	a.sessionDB(session).Model(session).Updates(map[string]interface{}{
 		"current_step": "",
 		"step_retries": 0,
 		"status":       models.SessionStatusCompleted,
@@ -1158,7 +1301,7 @@ func (a *App) exitFlow(session *models.ChatbotSession) {
 
 // closeSession ends the chatbot session and clears contact tracking
 func (a *App) closeSession(session *models.ChatbotSession) {
-	a.DB.Model(session).Updates(map[string]interface{}{
+	a.sessionDB(session).Model(session).Updates(map[string]interface{}{
 		"status":       models.SessionStatusCompleted,
 		"completed_at": time.Now(),
 	})
@@ -1201,7 +1344,11 @@ func (a *App) sendStepWithSkipCheck(account *models.WhatsAppAccount, session *mo
 		sessionData = models.JSONB{}
 	}
 
-	if a.shouldSkipStep(step, sessionData) {
+	skip := a.shouldSkipStep(step, sessionData)
+	if step.SkipCondition != "" {
+		a.traceSession(session.ID, models.SessionTraceKindSkip, step.StepName, expressionTrace(step.SkipCondition, sessionData, skip))
+	}
+	if skip {
 		a.Log.Info("Skipping step", "step", step.StepName, "condition", step.SkipCondition)
 		skippedSteps[step.StepName] = true
 
@@ -1240,7 +1387,7 @@ func (a *App) sendStepWithSkipCheck(account *models.WhatsAppAccount, session *mo
 
 		// Update session to next step
 		session.CurrentStep = nextStep.StepName
-		a.DB.Model(session).Update("current_step", nextStep.StepName)
+		a.sessionDB(session).Model(session).Update("current_step", nextStep.StepName)
 
 		// Recursively check next step (it may also need to be skipped)
 		a.sendStepWithSkipCheck(account, session, contact, nextStep, flow, skippedSteps)
@@ -1250,6 +1397,11 @@ func (a *App) sendStepWithSkipCheck(account *models.WhatsAppAccount, session *mo
 	// Not skipping - send the step message normally
 	a.sendStepMessage(account, session, contact, step)
 
+	// Steps that wait for a reply get inactivity nudges and the flow abandonment timer
+	if step.InputType != models.InputTypeNone && step.MessageType != models.FlowStepTypeTransfer {
+		a.scheduleFlowInactivity(session, flow, step)
+	}
+
 	// If input type is "none", automatically advance to next step without waiting for user input
 	if step.InputType == models.InputTypeNone {
 
@@ -1288,7 +1440,7 @@ func (a *App) sendStepWithSkipCheck(account *models.WhatsAppAccount, session *mo
 
 		// Update session to next step
 		session.CurrentStep = nextStep.StepName
-		a.DB.Model(session).Update("current_step", nextStep.StepName)
+		a.sessionDB(session).Model(session).Update("current_step", nextStep.StepName)
 
 		// Recursively process next step (it may also need to skip or have no input)
 		a.sendStepWithSkipCheck(account, session, contact, nextStep, flow, skippedSteps)
@@ -1301,18 +1453,40 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 
 	a.Log.Debug("sendStepMessage called", "step", step.StepName, "message_type", step.MessageType, "input_config", step.InputConfig)
 
+	// Messages can use the session's collected variables and the organization's {{org.*}} variables
+	data := a.flowTemplateData(account.OrganizationID, session.SessionData)
+
+	a.traceSession(session.ID, models.SessionTraceKindStep, step.StepName, models.JSONB{
+		"message_type": step.MessageType,
+		"session_data": redactJSON(map[string]interface{}(session.SessionData)),
+	})
+
 	switch step.MessageType {
 	case models.FlowStepTypeAPIFetch:
 		// Fetch response from external API (may include message + buttons)
 		// Pass the step message as template - it will be processed with API response data
-		apiResp, err := a.fetchApiResponse(step.ApiConfig, session.SessionData, step.Message)
+		trace := &apiFetchTrace{}
+		var apiResp *ApiResponse
+		var err error
+		if sim := a.simulation(session.ID); sim != nil && !sim.callAPIs {
+			err = errSimulationSkipsAPIs
+		} else {
+			apiResp, err = a.fetchApiResponse(step.ApiConfig, data, step.Message, trace)
+		}
+		if err != nil {
+			trace.fail(err)
+		} else {
+			trace.Mapped = apiResp.MappedData
+		}
+		a.traceSession(session.ID, models.SessionTraceKindAPIFetch, step.StepName, trace.detail())
 		if err != nil {
 			a.Log.Error("Failed to fetch API response", "error", err, "step", step.StepName)
+			a.recordAccountMetric(account, accountMetricChatbotErrors)
 			// Use fallback message if configured, otherwise use the step message
 			if fallback, ok := step.ApiConfig["fallback_message"].(string); ok && fallback != "" {
-				message = processTemplate(fallback, session.SessionData)
+				message = processTemplate(fallback, data)
 			} else if step.Message != "" {
-				message = processTemplate(step.Message, session.SessionData)
+				message = processTemplate(step.Message, data)
 			} else {
 				message = "Sorry, there was an error processing your request."
 			}
@@ -1327,7 +1501,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 				for k, v := range apiResp.MappedData {
 					session.SessionData[k] = v
 				}
-				a.DB.Model(session).Update("session_data", session.SessionData)
+				a.sessionDB(session).Model(session).Update("session_data", session.SessionData)
 			}
 
 			// Check if API returned buttons
@@ -1345,7 +1519,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 
 	case models.FlowStepTypeButtons:
 		// Send interactive buttons message
-		message = processTemplate(step.Message, session.SessionData)
+		message = processTemplate(step.Message, data)
 		if len(step.Buttons) > 0 {
 			// Separate reply buttons from URL buttons
 			// WhatsApp doesn't allow mixing them in the same message
@@ -1403,7 +1577,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 
 	case models.FlowStepTypeTransfer:
 		// Transfer to team/agent queue
-		message = processTemplate(step.Message, session.SessionData)
+		message = processTemplate(step.Message, data)
 		if message != "" {
 			if err := a.sendAndSaveTextMessage(account, contact, message); err != nil {
 				a.Log.Error("Failed to send transfer message", "error", err, "contact", contact.PhoneNumber)
@@ -1411,27 +1585,8 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 			a.logSessionMessage(session.ID, models.DirectionOutgoing, message, step.StepName)
 		}
 
-		// Get transfer configuration
-		var teamID *uuid.UUID
-		var notes string
-		if step.TransferConfig != nil {
-			if teamIDStr, ok := step.TransferConfig["team_id"].(string); ok && teamIDStr != "" && teamIDStr != "_general" {
-				if parsedID, err := uuid.Parse(teamIDStr); err == nil {
-					teamID = &parsedID
-				}
-			}
-			if n, ok := step.TransferConfig["notes"].(string); ok {
-				notes = processTemplate(n, session.SessionData)
-			}
-		}
-
-		// Create the transfer
-		if teamID != nil {
-			a.createTransferToTeam(account, contact, *teamID, notes, models.TransferSourceFlow)
-		} else {
-			// General queue transfer
-			a.createTransferToQueue(account, contact, models.TransferSourceFlow)
-		}
+		// Transfer to the step's team, or the general queue, with what the flow collected
+		a.handOffFlow(account, contact, step.TransferConfig, session.SessionData)
 
 		// End the flow session (transfer takes over)
 		a.exitFlow(session)
@@ -1440,7 +1595,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 	case models.FlowStepTypeWhatsAppFlow:
 		// Send a WhatsApp Flow (interactive form)
 		a.Log.Debug("Processing WhatsApp Flow step", "step", step.StepName, "input_config", step.InputConfig)
-		message = processTemplate(step.Message, session.SessionData)
+		message = processTemplate(step.Message, data)
 
 		// Extract flow configuration from input_config
 		var flowID, headerText, ctaText string
@@ -1450,7 +1605,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 				a.Log.Debug("Found WhatsApp Flow ID", "flow_id", flowID)
 			}
 			if header, ok := step.InputConfig["flow_header"].(string); ok {
-				headerText = processTemplate(header, session.SessionData)
+				headerText = processTemplate(header, data)
 			}
 			if cta, ok := step.InputConfig["flow_cta"].(string); ok {
 				ctaText = cta
@@ -1505,7 +1660,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 	default:
 		// Default: use the step message with template processing
 		a.Log.Debug("Unhandled message type, falling back to text", "message_type", step.MessageType, "step", step.StepName)
-		message = processTemplate(step.Message, session.SessionData)
+		message = processTemplate(step.Message, data)
 		if err := a.sendAndSaveTextMessage(account, contact, message); err != nil {
 			a.Log.Error("Failed to send step message", "error", err, "contact", contact.PhoneNumber)
 		}
@@ -1522,8 +1677,9 @@ type ApiResponse struct {
 }
 
 // fetchApiResponse fetches a response from an external API, supporting message + buttons
-// and response_mapping for storing API data in session variables
-func (a *App) fetchApiResponse(apiConfig models.JSONB, sessionData models.JSONB, messageTemplate string) (*ApiResponse, error) {
+// and response_mapping for storing API data in session variables. trace, when not nil,
+// receives a redacted snapshot of the request and response.
+func (a *App) fetchApiResponse(apiConfig models.JSONB, sessionData models.JSONB, messageTemplate string, trace *apiFetchTrace) (*ApiResponse, error) {
 	if apiConfig == nil {
 		return nil, fmt.Errorf("API config is empty")
 	}
@@ -1545,8 +1701,9 @@ func (a *App) fetchApiResponse(apiConfig models.JSONB, sessionData models.JSONB,
 
 	// Prepare request body if configured
 	var bodyReader io.Reader
+	bodyWithVars := ""
 	if bodyTemplate, ok := apiConfig["body"].(string); ok && bodyTemplate != "" {
-		bodyWithVars := processTemplate(bodyTemplate, sessionData)
+		bodyWithVars = processTemplate(bodyTemplate, sessionData)
 		bodyReader = strings.NewReader(bodyWithVars)
 	}
 
@@ -1570,8 +1727,11 @@ func (a *App) fetchApiResponse(apiConfig models.JSONB, sessionData models.JSONB,
 		}
 	}
 
+	trace.setRequest(req, bodyWithVars)
+
 	// Make the request
 	client := &http.Client{Timeout: 30 * time.Second}
+	started := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("API request failed: %w", err)
@@ -1584,6 +1744,7 @@ func (a *App) fetchApiResponse(apiConfig models.JSONB, sessionData models.JSONB,
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	trace.setResponse(resp.StatusCode, respBody, time.Since(started))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
@@ -1660,9 +1821,42 @@ func (a *App) fetchApiResponse(apiConfig models.JSONB, sessionData models.JSONB,
 
 // generateAIResponse generates a response using the configured AI provider
 func (a *App) generateAIResponse(settings *models.ChatbotSettings, session *models.ChatbotSession, userMessage string) (string, error) {
+	// Resolve {{org.*}} variables in the system prompt without changing the caller's settings
+	if prompt := a.resolveOrgVariables(settings.OrganizationID, settings.AI.SystemPrompt); prompt != settings.AI.SystemPrompt {
+		resolved := *settings
+		resolved.AI.SystemPrompt = prompt
+		settings = &resolved
+	}
+
 	// Build context from AIContext entries
 	contextData := a.buildAIContext(settings.OrganizationID, session, userMessage)
 
+	// Add long-term memory about the contact from earlier sessions
+	if settings.AI.MemoryEnabled && session != nil {
+		if memory := a.buildContactMemoryContext(settings.OrganizationID, session.ContactID); memory != "" {
+			if contextData != "" {
+				contextData = contextData + "\n\n" + memory
+			} else {
+				contextData = memory
+			}
+		}
+	}
+
+	// Record the prompt for the session replay; the API key is never part of it
+	if session != nil {
+		prompt := models.JSONB{
+			"provider":      settings.AI.Provider,
+			"model":         settings.AI.Model,
+			"system_prompt": settings.AI.SystemPrompt,
+			"context":       contextData,
+			"user_message":  userMessage,
+		}
+		if settings.AI.IncludeHistory {
+			prompt["history_limit"] = settings.AI.HistoryLimit
+		}
+		a.traceSession(session.ID, models.SessionTraceKindAIPrompt, "", prompt)
+	}
+
 	switch settings.AI.Provider {
 	case models.AIProviderOpenAI:
 		return a.generateOpenAIResponse(settings, session, userMessage, contextData)
@@ -1696,11 +1890,11 @@ func (a *App) buildAIContext(orgID uuid.UUID, session *models.ChatbotSession, us
 
 		switch ctx.ContextType {
 		case models.ContextTypeStatic:
-			content = ctx.StaticContent
+			content = a.resolveOrgVariables(orgID, ctx.StaticContent)
 
 		case models.ContextTypeAPI:
 			// Start with static content/prompt if provided
-			content = ctx.StaticContent
+			content = a.resolveOrgVariables(orgID, ctx.StaticContent)
 
 			// Fetch data from external API and append
 			apiContent, err := a.fetchAPIContext(ctx.ApiConfig, session, userMessage)
@@ -1883,8 +2077,12 @@ func (a *App) generateOpenAIResponse(settings *models.ChatbotSettings, session *
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
+	apiKey, err := a.decryptOrgSecret(settings.OrganizationID, settings.AI.APIKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt API key: %w", err)
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+settings.AI.APIKey)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 
 	client := &http.Client{Timeout: 60 * time.Second}
 	resp, err := client.Do(req)
@@ -1986,8 +2184,12 @@ func (a *App) generateAnthropicResponse(settings *models.ChatbotSettings, sessio
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
+	apiKey, err := a.decryptOrgSecret(settings.OrganizationID, settings.AI.APIKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt API key: %w", err)
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", settings.AI.APIKey)
+	req.Header.Set("x-api-key", apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
 	client := &http.Client{Timeout: 60 * time.Second}
@@ -2030,8 +2232,12 @@ func (a *App) generateAnthropicResponse(settings *models.ChatbotSettings, sessio
 
 // generateGoogleResponse generates a response using Google Gemini API
 func (a *App) generateGoogleResponse(settings *models.ChatbotSettings, session *models.ChatbotSession, userMessage string, contextData string) (string, error) {
+	apiKey, err := a.decryptOrgSecret(settings.OrganizationID, settings.AI.APIKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt API key: %w", err)
+	}
 	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
-		settings.AI.Model, settings.AI.APIKey)
+		settings.AI.Model, apiKey)
 
 	// Build contents array
 	contents := []map[string]interface{}{}
@@ -2166,7 +2372,7 @@ type Reaction struct {
 }
 
 // handleIncomingReaction handles incoming reaction messages from WhatsApp
-func (a *App) handleIncomingReaction(account *models.WhatsAppAccount, fromPhone, messageWAMID, emoji, profileName string) {
+func (a *App) handleIncomingReaction(account *models.WhatsAppAccount, fromPhone, messageWAMID, emoji string) {
 	a.Log.Info("Handling incoming reaction",
 		"from", fromPhone,
 		"message_wamid", messageWAMID,
@@ -2178,7 +2384,7 @@ func (a *App) handleIncomingReaction(account *models.WhatsAppAccount, fromPhone,
 	// has different WAMIDs from sender vs recipient perspective.
 	// We match on the suffix after "FQIA" + 4 chars (type indicator like "ERgS" or "EhgU")
 	var message models.Message
-	if err := a.DB.Where("whats_app_message_id = ?", messageWAMID).First(&message).Error; err != nil {
+	if err := a.findMessageByWhatsAppID(messageWAMID, &message); err != nil {
 		// Try matching on WAMID suffix (the unique message ID part)
 		if idx := strings.Index(messageWAMID, "FQIA"); idx != -1 {
 			// Extract suffix after "FQIA" + 4 char type indicator (e.g., "ERgS", "EhgU")
@@ -2199,72 +2405,78 @@ func (a *App) handleIncomingReaction(account *models.WhatsAppAccount, fromPhone,
 		}
 	}
 
-	// Get or create contact
-	contact, _ := a.getOrCreateContact(account.OrganizationID, fromPhone, profileName)
+	// Replace this contact's reaction (each contact can only have one reaction);
+	// an empty emoji removes it
+	reactions := upsertReaction(parseReactions(message.Metadata), Reaction{
+		Emoji:     emoji,
+		FromPhone: fromPhone,
+	})
 
-	// Parse existing reactions from Metadata
-	var metadata map[string]interface{}
-	if message.Metadata != nil {
-		metadata = message.Metadata
-	} else {
-		metadata = make(map[string]interface{})
+	metadata := message.Metadata
+	if metadata == nil {
+		metadata = models.JSONB{}
+	}
+	metadata["reactions"] = reactions
+
+	// Save to database
+	if err := a.DB.Model(&message).Update("metadata", metadata).Error; err != nil {
+		a.Log.Error("Failed to update message reactions", "error", err)
+		return
 	}
 
-	// Get or initialize reactions array
+	a.Log.Info("Updated message reaction", "message_id", message.ID, "reactions_count", len(reactions))
+
+	a.broadcastReactionUpdate(account.OrganizationID, &message, reactions)
+}
+
+// parseReactions reads the reactions stored in a message's metadata.
+func parseReactions(metadata map[string]interface{}) []Reaction {
+	reactionsArray, ok := metadata["reactions"].([]interface{})
+	if !ok {
+		return nil
+	}
 	var reactions []Reaction
-	if reactionsRaw, ok := metadata["reactions"]; ok {
-		if reactionsArray, ok := reactionsRaw.([]interface{}); ok {
-			for _, r := range reactionsArray {
-				if rMap, ok := r.(map[string]interface{}); ok {
-					emoji, _ := rMap["emoji"].(string)
-					reactions = append(reactions, Reaction{
-						Emoji:     emoji,
-						FromPhone: getStringFromMap(rMap, "from_phone"),
-						FromUser:  getStringFromMap(rMap, "from_user"),
-					})
-				}
-			}
+	for _, r := range reactionsArray {
+		if rMap, ok := r.(map[string]interface{}); ok {
+			reactions = append(reactions, Reaction{
+				Emoji:     getStringFromMap(rMap, "emoji"),
+				FromPhone: getStringFromMap(rMap, "from_phone"),
+				FromUser:  getStringFromMap(rMap, "from_user"),
+			})
 		}
 	}
+	return reactions
+}
 
-	// Remove existing reaction from this contact (each contact can only have one reaction)
-	var newReactions []Reaction
+// upsertReaction replaces any existing reaction from the same sender (contact
+// phone or agent user) with the given one. An empty emoji only removes.
+func upsertReaction(reactions []Reaction, reaction Reaction) []Reaction {
+	var result []Reaction
 	for _, r := range reactions {
-		if r.FromPhone != fromPhone {
-			newReactions = append(newReactions, r)
+		if r.FromPhone == reaction.FromPhone && r.FromUser == reaction.FromUser {
+			continue
 		}
+		result = append(result, r)
 	}
-
-	// Add new reaction if emoji is not empty (empty = remove reaction)
-	if emoji != "" {
-		newReactions = append(newReactions, Reaction{
-			Emoji:     emoji,
-			FromPhone: fromPhone,
-		})
+	if reaction.Emoji != "" {
+		result = append(result, reaction)
 	}
+	return result
+}
 
-	// Update metadata
-	metadata["reactions"] = newReactions
-
-	// Save to database
-	if err := a.DB.Model(&message).Update("metadata", metadata).Error; err != nil {
-		a.Log.Error("Failed to update message reactions", "error", err)
+// broadcastReactionUpdate pushes a message's current reactions to the org's agents.
+func (a *App) broadcastReactionUpdate(orgID uuid.UUID, message *models.Message, reactions []Reaction) {
+	if a.WSHub == nil {
 		return
 	}
-
-	a.Log.Info("Updated message reaction", "message_id", message.ID, "reactions_count", len(newReactions))
-
-	// Broadcast via WebSocket
-	if a.WSHub != nil {
-		a.WSHub.BroadcastToOrg(account.OrganizationID, websocket.WSMessage{
-			Type: "reaction_update",
-			Payload: map[string]any{
-				"message_id": message.ID.String(),
-				"contact_id": contact.ID.String(),
-				"reactions":  newReactions,
-			},
-		})
-	}
+	a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
+		Type: websocket.TypeReactionUpdate,
+		Payload: map[string]any{
+			"message_id": message.ID.String(),
+			"contact_id": message.ContactID.String(),
+			"reactions":  reactions,
+		},
+	})
 }
 
 // Helper function to safely get string from map
@@ -2282,10 +2494,13 @@ type MediaInfo struct {
 	MediaURL      string
 	MediaMimeType string
 	MediaFilename string
+	MediaID       string
+	// RejectedReason is set when the inbound media policy rejected the file
+	RejectedReason string
 }
 
 // saveIncomingMessage saves an incoming message to the messages table
-func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *models.Contact, whatsappMsgID, msgType, content string, mediaInfo *MediaInfo, replyToWAMID string) {
+func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *models.Contact, whatsappMsgID, msgType, content string, mediaInfo *MediaInfo, replyToWAMID string, flowResponse map[string]interface{}) {
 	now := time.Now()
 
 	message := models.Message{
@@ -2303,7 +2518,7 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 	// Handle reply context - look up the original message by WhatsApp message ID
 	if replyToWAMID != "" {
 		var replyToMsg models.Message
-		if err := a.DB.Where("whats_app_message_id = ?", replyToWAMID).First(&replyToMsg).Error; err == nil {
+		if err := a.findMessageByWhatsAppID(replyToWAMID, &replyToMsg); err == nil {
 			message.IsReply = true
 			message.ReplyToMessageID = &replyToMsg.ID
 		} else {
@@ -2316,13 +2531,26 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 		message.MediaURL = mediaInfo.MediaURL
 		message.MediaMimeType = mediaInfo.MediaMimeType
 		message.MediaFilename = mediaInfo.MediaFilename
+		message.MediaID = mediaInfo.MediaID
+		if mediaInfo.RejectedReason != "" {
+			message.Metadata = models.JSONB{mediaRejectedMetadataKey: mediaInfo.RejectedReason}
+		}
+	}
+
+	if len(flowResponse) > 0 {
+		message.FlowResponse = models.JSONB(flowResponse)
 	}
 
 	if err := a.DB.Create(&message).Error; err != nil {
 		a.Log.Error("Failed to save incoming message", "error", err)
+		a.recordAccountMetric(account, accountMetricFailed)
 		return
 	}
 
+	if len(flowResponse) > 0 {
+		a.saveFlowResponseToContact(contact, flowResponse)
+	}
+
 	// Update contact's last message info
 	preview := content
 	if len(preview) > 100 {
@@ -2358,12 +2586,26 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 			"media_url":        message.MediaURL,
 			"media_mime_type":  message.MediaMimeType,
 			"media_filename":   message.MediaFilename,
+			"media_id":         message.MediaID,
 			"status":           message.Status,
 			"wamid":            message.WhatsAppMessageID,
+			"whatsapp_account": message.WhatsAppAccount,
 			"created_at":       message.CreatedAt,
 			"updated_at":       message.UpdatedAt,
 			"is_reply":         message.IsReply,
 		}
+		if message.FlowResponse != nil {
+			wsPayload["flow_response"] = message.FlowResponse
+		}
+		if mediaInfo != nil && mediaInfo.RejectedReason != "" {
+			wsPayload["media_rejected"] = mediaInfo.RejectedReason
+		}
+		switch message.MessageType {
+		case models.MessageTypeLocation:
+			wsPayload["location"] = parseSharedLocation(message.Content)
+		case models.MessageTypeContact:
+			wsPayload["contacts"] = parseSharedContacts(message.Content)
+		}
 		// Include reply context if this is a reply
 		if message.IsReply && message.ReplyToMessageID != nil {
 			wsPayload["reply_to_message_id"] = message.ReplyToMessageID.String()
@@ -2395,11 +2637,55 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 		WhatsAppAccount: account.Name,
 		Direction:       models.DirectionIncoming,
 	})
+
+	a.notifyPluginsMessageReceived(plugins.MessageEvent{
+		OrganizationID:  account.OrganizationID,
+		MessageID:       message.ID,
+		ContactID:       contact.ID,
+		ContactPhone:    contact.PhoneNumber,
+		ContactName:     contact.ProfileName,
+		WhatsAppAccount: account.Name,
+		Type:            msgType,
+		Content:         content,
+	})
 }
 
-// isWithinBusinessHours checks if current time is within configured business hours
-func (a *App) isWithinBusinessHours(businessHours models.JSONBArray) bool {
-	now := time.Now()
+// saveFlowResponseToContact copies the fields of a submitted WhatsApp Flow onto the
+// contact's custom fields, so form answers become part of the lead record. The latest
+// submission wins; the flow_token is tracking data and is not copied.
+func (a *App) saveFlowResponseToContact(contact *models.Contact, flowResponse map[string]interface{}) {
+	metadata := models.JSONB{}
+	for k, v := range contact.Metadata {
+		metadata[k] = v
+	}
+	changed := false
+	for k, v := range flowResponse {
+		if k == "flow_token" {
+			continue
+		}
+		if existing, ok := metadata[k]; ok && jsonEqual(existing, v) {
+			continue
+		}
+		metadata[k] = v
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	if err := a.DB.Model(contact).Update("metadata", metadata).Error; err != nil {
+		a.Log.Error("Failed to save flow response to contact", "error", err, "contact_id", contact.ID)
+		return
+	}
+	contact.Metadata = metadata
+}
+
+// isWithinBusinessHours checks if the current time in the organization's timezone is within configured business hours
+func (a *App) isWithinBusinessHours(orgID uuid.UUID, businessHours models.JSONBArray) bool {
+	return isWithinBusinessHoursAt(businessHours, time.Now().In(a.getOrgLocation(orgID)))
+}
+
+// isWithinBusinessHoursAt checks if the given wall-clock time falls within the configured business hours
+func isWithinBusinessHoursAt(businessHours models.JSONBArray, now time.Time) bool {
 	currentDay := int(now.Weekday()) // 0 = Sunday, 1 = Monday, etc.
 	currentTime := now.Format("15:04")
 
@@ -2549,26 +2835,38 @@ func evaluateSingleCondition(expr string, data map[string]interface{}) bool {
 		return false
 	}
 
+	varName, op, expectedValue, ok := parseSingleCondition(expr)
+	if !ok {
+		return false
+	}
+	return compareValues(conditionValue(data, varName), op, expectedValue)
+}
+
+// parseSingleCondition splits a condition like age > 18 into the variable name, the
+// operator and the expected value
+func parseSingleCondition(expr string) (varName, op, expected string, ok bool) {
 	operators := []string{"!=", "==", ">=", "<=", ">", "<"}
 
 	for _, op := range operators {
 		if strings.Contains(expr, op) {
 			parts := strings.SplitN(expr, op, 2)
 			if len(parts) == 2 {
-				varName := strings.TrimSpace(parts[0])
-				expectedValue := strings.TrimSpace(parts[1])
-				expectedValue = strings.Trim(expectedValue, "'\"")
-
-				actualValue := ""
-				if val, exists := data[varName]; exists && val != nil {
-					actualValue = fmt.Sprintf("%v", val)
-				}
-
-				return compareValues(actualValue, op, expectedValue)
+				varName = strings.TrimSpace(parts[0])
+				expected = strings.TrimSpace(parts[1])
+				expected = strings.Trim(expected, "'\"")
+				return varName, op, expected, true
 			}
 		}
 	}
-	return false
+	return "", "", "", false
+}
+
+// conditionValue returns a session variable as compared by conditions, empty when unset
+func conditionValue(data map[string]interface{}, varName string) string {
+	if val, exists := data[varName]; exists && val != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	return ""
 }
 
 // compareValues compares two values using the specified operator
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWithinBusinessHoursAt_UsesGivenLocation(t *testing.T) {
+	hours := models.JSONBArray{
+		map[string]interface{}{"day": float64(time.Monday), "enabled": true, "start_time": "09:00", "end_time": "17:00"},
+	}
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	// Monday 05:00 UTC is 10:30 in Kolkata
+	utc := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+	assert.False(t, isWithinBusinessHoursAt(hours, utc))
+	assert.True(t, isWithinBusinessHoursAt(hours, utc.In(kolkata)))
+}
+
+func TestIsWithinBusinessHoursAt_DisabledAndMissingDays(t *testing.T) {
+	hours := models.JSONBArray{
+		map[string]interface{}{"day": float64(time.Monday), "enabled": false, "start_time": "09:00", "end_time": "17:00"},
+	}
+
+	monday := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	assert.False(t, isWithinBusinessHoursAt(hours, monday))
+	assert.False(t, isWithinBusinessHoursAt(hours, tuesday))
+}
+
+func TestNormalizeQuestion(t *testing.T) {
+	assert.Equal(t, "do you deliver on sundays", normalizeQuestion("  Do you deliver on Sundays?? "))
+	assert.Equal(t, "what s the price of item 42", normalizeQuestion("What's the price of item #42!"))
+	assert.Equal(t, "कीमत क्या है", normalizeQuestion("कीमत क्या है?"))
+	assert.Equal(t, "", normalizeQuestion("👍 !!"))
+}
+
+func TestParseReactions(t *testing.T) {
+	metadata := map[string]interface{}{
+		"reactions": []interface{}{
+			map[string]interface{}{"emoji": "👍", "from_phone": "15551234567"},
+			map[string]interface{}{"emoji": "❤️", "from_user": "agent-1"},
+			"garbage",
+		},
+	}
+
+	assert.Equal(t, []Reaction{
+		{Emoji: "👍", FromPhone: "15551234567"},
+		{Emoji: "❤️", FromUser: "agent-1"},
+	}, parseReactions(metadata))
+	assert.Nil(t, parseReactions(nil))
+	assert.Nil(t, parseReactions(map[string]interface{}{"reactions": "nope"}))
+}
+
+func TestUpsertReaction(t *testing.T) {
+	reactions := []Reaction{
+		{Emoji: "👍", FromPhone: "15551234567"},
+		{Emoji: "❤️", FromUser: "agent-1"},
+	}
+
+	// Contact changes their reaction; the agent's reaction is untouched
+	updated := upsertReaction(reactions, Reaction{Emoji: "😂", FromPhone: "15551234567"})
+	assert.Equal(t, []Reaction{
+		{Emoji: "❤️", FromUser: "agent-1"},
+		{Emoji: "😂", FromPhone: "15551234567"},
+	}, updated)
+
+	// Empty emoji removes the sender's reaction
+	removed := upsertReaction(updated, Reaction{FromUser: "agent-1"})
+	assert.Equal(t, []Reaction{{Emoji: "😂", FromPhone: "15551234567"}}, removed)
+}
+
+func TestKeywordRuleMatches(t *testing.T) {
+	rule := func(matchType models.MatchType, caseSensitive bool, keywords ...string) *models.KeywordRule {
+		return &models.KeywordRule{Keywords: keywords, MatchType: matchType, CaseSensitive: caseSensitive}
+	}
+
+	assert.True(t, keywordRuleMatches(rule(models.MatchTypeContains, false, "refund"), "I want a REFUND now"))
+	assert.False(t, keywordRuleMatches(rule(models.MatchTypeContains, true, "refund"), "I want a REFUND now"))
+	assert.True(t, keywordRuleMatches(rule(models.MatchTypeExact, false, "hi", "hello"), "Hello"))
+	assert.False(t, keywordRuleMatches(rule(models.MatchTypeExact, false, "hello"), "hello there"))
+	assert.True(t, keywordRuleMatches(rule(models.MatchTypeStartsWith, false, "order"), "Order #42 is late"))
+	assert.True(t, keywordRuleMatches(rule(models.MatchTypeRegex, false, `^\d{6}$`), "123456"))
+	assert.False(t, keywordRuleMatches(rule(models.MatchTypeRegex, false, `(`), "("))
+}
+
+func TestPriorityRank(t *testing.T) {
+	assert.Less(t, priorityRank(models.ConversationPriorityUrgent), priorityRank(models.ConversationPriorityHigh))
+	assert.Less(t, priorityRank(models.ConversationPriorityHigh), priorityRank(models.ConversationPriorityNormal))
+	assert.Equal(t, priorityRank(models.ConversationPriorityNormal), priorityRank(""))
+
+	assert.True(t, isValidKeywordRulePriority(""))
+	assert.True(t, isValidKeywordRulePriority(models.ConversationPriorityUrgent))
+	assert.False(t, isValidKeywordRulePriority(models.ConversationPriorityNormal))
+	assert.False(t, isValidConversationPriority("critical"))
+}
+
+func TestRenderOrgVariables(t *testing.T) {
+	vars := map[string]string{"store_url": "https://shop.example.com", "hours": "9-5"}
+
+	assert.Equal(t, "Visit https://shop.example.com (9-5)", renderOrgVariables("Visit {{org.store_url}} ({{ org.hours }})", vars))
+	assert.Equal(t, "Code: ", renderOrgVariables("Code: {{org.promo}}", vars))
+	assert.Equal(t, "Hi {{name}}", renderOrgVariables("Hi {{name}}", vars))
+}
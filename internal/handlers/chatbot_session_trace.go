@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+const (
+	// maxTraceBodyBytes bounds the request and response bodies kept in API fetch traces
+	maxTraceBodyBytes = 4096
+
+	// redactedValue replaces secrets in session traces
+	redactedValue = "[REDACTED]"
+)
+
+// sensitiveKeyParts mark header, query parameter and JSON keys whose values are
+// never stored in session traces
+var sensitiveKeyParts = []string{
+	"authorization", "token", "secret", "password", "passwd", "api_key", "apikey", "api-key",
+	"cookie", "signature", "credential", "private_key",
+}
+
+// SessionReplayEntry is one event of a chatbot session. Messages and traces are merged
+// in the order they happened.
+type SessionReplayEntry struct {
+	At       time.Time    `json:"at"`
+	Kind     string       `json:"kind"` // incoming, outgoing, step, skip, condition, api_fetch or ai_prompt
+	StepName string       `json:"step_name"`
+	Message  string       `json:"message,omitempty"`
+	Detail   models.JSONB `json:"detail,omitempty"`
+}
+
+// ChatbotSessionDetailResponse is a chatbot session with its replay
+type ChatbotSessionDetailResponse struct {
+	models.ChatbotSession
+	Replay []SessionReplayEntry `json:"replay"`
+}
+
+// traceSession records how the chatbot handled a step of a session
+func (a *App) traceSession(sessionID uuid.UUID, kind models.SessionTraceKind, stepName string, detail models.JSONB) {
+	if sim := a.simulation(sessionID); sim != nil {
+		sim.record(string(kind), stepName, "", detail)
+		return
+	}
+	trace := models.ChatbotSessionTrace{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		SessionID: sessionID,
+		Kind:      kind,
+		StepName:  stepName,
+		Detail:    detail,
+	}
+	if err := a.DB.Create(&trace).Error; err != nil {
+		a.Log.Error("Failed to record session trace", "error", err, "session_id", sessionID, "kind", kind)
+	}
+}
+
+// buildSessionReplay merges a session's messages and traces into a timeline
+func buildSessionReplay(messages []models.ChatbotSessionMessage, traces []models.ChatbotSessionTrace) []SessionReplayEntry {
+	replay := make([]SessionReplayEntry, 0, len(messages)+len(traces))
+	for _, msg := range messages {
+		replay = append(replay, SessionReplayEntry{
+			At:       msg.CreatedAt,
+			Kind:     string(msg.Direction),
+			StepName: msg.StepName,
+			Message:  msg.Message,
+		})
+	}
+	for _, trace := range traces {
+		replay = append(replay, SessionReplayEntry{
+			At:       trace.CreatedAt,
+			Kind:     string(trace.Kind),
+			StepName: trace.StepName,
+			Detail:   trace.Detail,
+		})
+	}
+	sort.SliceStable(replay, func(i, j int) bool {
+		return replay[i].At.Before(replay[j].At)
+	})
+	return replay
+}
+
+// expressionTrace describes how a skip condition was evaluated: the session variables
+// it read and the result of each comparison
+func expressionTrace(expr string, data map[string]interface{}, result bool) models.JSONB {
+	inputs := map[string]interface{}{}
+	conditions := []map[string]interface{}{}
+
+	flat := strings.NewReplacer("(", " ", ")", " ").Replace(expr)
+	for _, orPart := range splitByLogicOperator(flat, " OR ") {
+		for _, cond := range splitByLogicOperator(orPart, " AND ") {
+			cond = strings.TrimSpace(cond)
+			varName, _, _, ok := parseSingleCondition(cond)
+			if !ok {
+				continue
+			}
+			inputs[varName] = redactTraceValue(varName, data[varName])
+			conditions = append(conditions, map[string]interface{}{
+				"condition": cond,
+				"result":    evaluateSingleCondition(cond, data),
+			})
+		}
+	}
+
+	return models.JSONB{
+		"expression": expr,
+		"inputs":     inputs,
+		"conditions": conditions,
+		"result":     result,
+	}
+}
+
+// apiFetchTrace captures the request and response of an API fetch step, with secrets
+// redacted and bodies truncated
+type apiFetchTrace struct {
+	Method     string
+	URL        string
+	Headers    map[string]string
+	Body       string
+	StatusCode int
+	Response   string
+	Mapped     map[string]interface{}
+	Duration   time.Duration
+	Error      string
+}
+
+// setRequest snapshots the outgoing request
+func (t *apiFetchTrace) setRequest(req *http.Request, body string) {
+	if t == nil {
+		return
+	}
+	t.Method = req.Method
+	t.URL = redactURL(req.URL)
+	t.Headers = make(map[string]string, len(req.Header))
+	for key := range req.Header {
+		if isSensitiveKey(key) {
+			t.Headers[key] = redactedValue
+		} else {
+			t.Headers[key] = req.Header.Get(key)
+		}
+	}
+	t.Body = redactBody(body)
+}
+
+// setResponse snapshots the API's response
+func (t *apiFetchTrace) setResponse(statusCode int, body []byte, duration time.Duration) {
+	if t == nil {
+		return
+	}
+	t.StatusCode = statusCode
+	t.Response = redactBody(string(body))
+	t.Duration = duration
+}
+
+// fail records why the fetch failed. Error statuses aren't repeated with the body, which
+// is already in the redacted response.
+func (t *apiFetchTrace) fail(err error) {
+	if t.StatusCode != 0 && (t.StatusCode < 200 || t.StatusCode >= 300) {
+		t.Error = fmt.Sprintf("API returned status %d", t.StatusCode)
+		return
+	}
+	t.Error = err.Error()
+}
+
+func (t *apiFetchTrace) detail() models.JSONB {
+	detail := models.JSONB{
+		"request": map[string]interface{}{
+			"method":  t.Method,
+			"url":     t.URL,
+			"headers": t.Headers,
+			"body":    t.Body,
+		},
+		"response": map[string]interface{}{
+			"status_code": t.StatusCode,
+			"body":        t.Response,
+		},
+		"duration_ms": t.Duration.Milliseconds(),
+	}
+	if len(t.Mapped) > 0 {
+		detail["mapped"] = redactJSON(t.Mapped)
+	}
+	if t.Error != "" {
+		detail["error"] = t.Error
+	}
+	return detail
+}
+
+// isSensitiveKey reports whether a header, query parameter or field holds a secret
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactTraceValue hides a value stored under a sensitive key
+func redactTraceValue(key string, value interface{}) interface{} {
+	if value != nil && isSensitiveKey(key) {
+		return redactedValue
+	}
+	return redactJSON(value)
+}
+
+// redactJSON returns a copy of decoded JSON with the values of sensitive keys replaced
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			out[key] = redactTraceValue(key, val)
+		}
+		return out
+	case models.JSONB:
+		return redactJSON(map[string]interface{}(v))
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = redactJSON(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// redactBody redacts a JSON body and truncates it. Bodies that aren't JSON are only truncated.
+func redactBody(body string) string {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(body), &parsed); err == nil {
+		if redacted, err := json.Marshal(redactJSON(parsed)); err == nil {
+			body = string(redacted)
+		}
+	}
+	if len(body) > maxTraceBodyBytes {
+		body = body[:maxTraceBodyBytes] + "…"
+	}
+	return body
+}
+
+// redactURL hides credentials and sensitive query parameters in a URL
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User(redactedValue)
+	}
+	query := redacted.Query()
+	changed := false
+	for key := range query {
+		if isSensitiveKey(key) {
+			query.Set(key, redactedValue)
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
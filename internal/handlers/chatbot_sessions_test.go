@@ -0,0 +1,69 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_GetChatbotSession_Replay(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	contact := createTestContact(t, app, org.ID)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	session := models.ChatbotSession{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  org.ID,
+		ContactID:       contact.ID,
+		WhatsAppAccount: "test-account",
+		PhoneNumber:     contact.PhoneNumber,
+		Status:          models.SessionStatusActive,
+		CurrentStep:     "ask_plan",
+	}
+	require.NoError(t, app.DB.Create(&session).Error)
+	require.NoError(t, app.DB.Create(&models.ChatbotSessionMessage{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		SessionID: session.ID,
+		Direction: models.DirectionIncoming,
+		Message:   "premium",
+		StepName:  "ask_plan",
+	}).Error)
+	require.NoError(t, app.DB.Create(&models.ChatbotSessionTrace{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		SessionID: session.ID,
+		Kind:      models.SessionTraceKindCondition,
+		StepName:  "ask_plan",
+		Detail:    models.JSONB{"input": "premium", "matched": "default", "next_step": "basic_info"},
+	}).Error)
+
+	req := testutil.NewGETRequest(t)
+	setTransferAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", session.ID.String())
+	require.NoError(t, app.GetChatbotSession(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data handlers.ChatbotSessionDetailResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.Equal(t, session.ID, resp.Data.ID)
+	require.Len(t, resp.Data.Replay, 2)
+	assert.Equal(t, "incoming", resp.Data.Replay[0].Kind)
+	assert.Equal(t, "condition", resp.Data.Replay[1].Kind)
+	assert.Equal(t, "basic_info", resp.Data.Replay[1].Detail["next_step"])
+
+	// Sessions of other organizations aren't visible
+	other := createTestOrg(t, app)
+	req = testutil.NewGETRequest(t)
+	setTransferAuthContext(req, other.ID, admin.ID)
+	testutil.SetPathParam(req, "id", session.ID.String())
+	require.NoError(t, app.GetChatbotSession(req))
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+}
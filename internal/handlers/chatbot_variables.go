@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+var (
+	// variableNamePattern matches names the template engine can resolve as {{org.name}}
+	variableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// {{org.name}}, with optional spaces inside the braces
+	orgVariablePattern = regexp.MustCompile(`\{\{\s*org\.([a-zA-Z_][a-zA-Z0-9_]*)\s*\}\}`)
+)
+
+// maxVariableNameLen matches the size of the name column
+const maxVariableNameLen = 100
+
+// ChatbotVariableRequest represents the request body for creating/updating a chatbot variable
+type ChatbotVariableRequest struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+// ListChatbotVariables returns the organization's chatbot variables
+func (a *App) ListChatbotVariables(r *fastglue.Request) error {
+	orgID, err := a.chatbotSettingsAccess(r, models.ActionRead)
+	if err != nil {
+		return nil
+	}
+
+	var variables []models.ChatbotVariable
+	if err := a.DB.Where("organization_id = ?", orgID).Order("name ASC").Find(&variables).Error; err != nil {
+		a.Log.Error("Failed to list chatbot variables", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list variables", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"variables": variables,
+	})
+}
+
+// CreateChatbotVariable creates a chatbot variable
+func (a *App) CreateChatbotVariable(r *fastglue.Request) error {
+	orgID, err := a.chatbotSettingsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+
+	var req ChatbotVariableRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := validateChatbotVariableRequest(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if a.chatbotVariableNameTaken(orgID, req.Name, uuid.Nil) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A variable with this name already exists", nil, "")
+	}
+
+	variable := models.ChatbotVariable{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Value:          req.Value,
+		Description:    req.Description,
+	}
+	if err := a.DB.Create(&variable).Error; err != nil {
+		a.Log.Error("Failed to create chatbot variable", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create variable", nil, "")
+	}
+
+	a.InvalidateChatbotVariablesCache(orgID)
+	return r.SendEnvelope(variable)
+}
+
+// UpdateChatbotVariable updates a chatbot variable
+func (a *App) UpdateChatbotVariable(r *fastglue.Request) error {
+	orgID, err := a.chatbotSettingsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	variable, err := a.findChatbotVariable(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	var req ChatbotVariableRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := validateChatbotVariableRequest(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if a.chatbotVariableNameTaken(orgID, req.Name, variable.ID) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A variable with this name already exists", nil, "")
+	}
+
+	if err := a.DB.Model(variable).Updates(map[string]interface{}{
+		"name":        req.Name,
+		"value":       req.Value,
+		"description": req.Description,
+	}).Error; err != nil {
+		a.Log.Error("Failed to update chatbot variable", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update variable", nil, "")
+	}
+	a.DB.First(variable, variable.ID)
+
+	a.InvalidateChatbotVariablesCache(orgID)
+	return r.SendEnvelope(variable)
+}
+
+// DeleteChatbotVariable deletes a chatbot variable
+func (a *App) DeleteChatbotVariable(r *fastglue.Request) error {
+	orgID, err := a.chatbotSettingsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	variable, err := a.findChatbotVariable(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	if err := a.DB.Delete(variable).Error; err != nil {
+		a.Log.Error("Failed to delete chatbot variable", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete variable", nil, "")
+	}
+
+	a.InvalidateChatbotVariablesCache(orgID)
+	return r.SendEnvelope(map[string]string{"message": "Variable deleted"})
+}
+
+// chatbotSettingsAccess resolves the organization and checks the chatbot settings
+// permission, sending a 4xx on failure
+func (a *App) chatbotSettingsAccess(r *fastglue.Request, action string) (uuid.UUID, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+		return uuid.Nil, err
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsChatbot, action) {
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		return uuid.Nil, errors.New("forbidden")
+	}
+	return orgID, nil
+}
+
+// findChatbotVariable loads the variable named by the id path parameter, sending a 4xx if missing
+func (a *App) findChatbotVariable(r *fastglue.Request, orgID uuid.UUID) (*models.ChatbotVariable, error) {
+	id, err := uuid.Parse(fmt.Sprint(r.RequestCtx.UserValue("id")))
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid variable ID", nil, "")
+		return nil, err
+	}
+	var variable models.ChatbotVariable
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&variable).Error; err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusNotFound, "Variable not found", nil, "")
+		return nil, err
+	}
+	return &variable, nil
+}
+
+func (a *App) chatbotVariableNameTaken(orgID uuid.UUID, name string, exceptID uuid.UUID) bool {
+	var count int64
+	a.DB.Model(&models.ChatbotVariable{}).
+		Where("organization_id = ? AND name = ? AND id <> ?", orgID, name, exceptID).
+		Count(&count)
+	return count > 0
+}
+
+// validateChatbotVariableRequest checks a variable request.
+// Returns an error message, or "" when valid.
+func validateChatbotVariableRequest(req *ChatbotVariableRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if len(req.Name) > maxVariableNameLen || !variableNamePattern.MatchString(req.Name) {
+		return "Name must start with a letter or underscore and contain only letters, digits and underscores"
+	}
+	return ""
+}
+
+// getChatbotVariables returns the organization's variables as name => value
+func (a *App) getChatbotVariables(orgID uuid.UUID) map[string]string {
+	ctx := context.Background()
+	cacheKey := orgVariablesCachePrefix + orgID.String()
+
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			var vars map[string]string
+			if err := json.Unmarshal([]byte(cached), &vars); err == nil {
+				return vars
+			}
+		}
+	}
+
+	var variables []models.ChatbotVariable
+	if err := a.DB.Where("organization_id = ?", orgID).Find(&variables).Error; err != nil {
+		a.Log.Error("Failed to load chatbot variables", "error", err, "organization_id", orgID)
+		return nil
+	}
+	vars := make(map[string]string, len(variables))
+	for _, v := range variables {
+		vars[v.Name] = v.Value
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(vars); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgVariablesCacheTTL)
+		}
+	}
+	return vars
+}
+
+// InvalidateChatbotVariablesCache clears the organization's cached variables
+func (a *App) InvalidateChatbotVariablesCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	a.Redis.Del(context.Background(), orgVariablesCachePrefix+orgID.String())
+}
+
+// flowTemplateData returns the data flow messages are rendered with: the session's
+// collected variables plus the organization's variables under "org". The session data
+// itself is left untouched so "org" is never saved with it.
+func (a *App) flowTemplateData(orgID uuid.UUID, sessionData models.JSONB) map[string]interface{} {
+	data := make(map[string]interface{}, len(sessionData)+1)
+	for key, value := range sessionData {
+		data[key] = value
+	}
+	org := map[string]interface{}{}
+	for name, value := range a.getChatbotVariables(orgID) {
+		org[name] = value
+	}
+	data["org"] = org
+	return data
+}
+
+// resolveOrgVariables replaces {{org.name}} in text with the organization's variables,
+// leaving any other placeholders as they are
+func (a *App) resolveOrgVariables(orgID uuid.UUID, text string) string {
+	if !strings.Contains(text, "org.") {
+		return text
+	}
+	return renderOrgVariables(text, a.getChatbotVariables(orgID))
+}
+
+// renderOrgVariables replaces {{org.name}} with its value, or an empty string for
+// unknown names as the template engine does
+func renderOrgVariables(text string, vars map[string]string) string {
+	return orgVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := orgVariablePattern.FindStringSubmatch(match)[1]
+		return vars[name]
+	})
+}
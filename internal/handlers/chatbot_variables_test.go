@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_ChatbotVariables(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Update("phone_id", "phone-"+uuid.NewString()[:8]).Error)
+	require.NoError(t, app.DB.Create(&models.ChatbotSettings{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		IsEnabled:       true,
+	}).Error)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	agent := createTestAgent(t, app, org.ID)
+
+	create := func(userID uuid.UUID, body map[string]any) (int, models.ChatbotVariable) {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, userID)
+		require.NoError(t, app.CreateChatbotVariable(req))
+		var result struct {
+			Data models.ChatbotVariable `json:"data"`
+		}
+		status := testutil.GetResponseStatusCode(req)
+		if status == fasthttp.StatusOK {
+			testutil.ParseJSONResponse(t, req, &result)
+		}
+		return status, result.Data
+	}
+
+	status, _ := create(agent.ID, map[string]any{"name": "store_url", "value": "https://shop.example.com"})
+	assert.Equal(t, fasthttp.StatusForbidden, status)
+	status, _ = create(admin.ID, map[string]any{"name": "store url", "value": "x"})
+	assert.Equal(t, fasthttp.StatusBadRequest, status)
+
+	status, storeURL := create(admin.ID, map[string]any{"name": "store_url", "value": "https://shop.example.com"})
+	require.Equal(t, fasthttp.StatusOK, status)
+	status, _ = create(admin.ID, map[string]any{"name": "store_url", "value": "https://other.example.com"})
+	assert.Equal(t, fasthttp.StatusConflict, status)
+
+	require.NoError(t, app.DB.Create(&models.KeywordRule{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		Name:            "Shop",
+		IsEnabled:       true,
+		Keywords:        models.StringArray{"shop"},
+		MatchType:       models.MatchTypeContains,
+		ResponseType:    models.ResponseTypeText,
+		ResponseContent: models.JSONB{"body": "Order at {{org.store_url}}{{org.unknown}}"},
+	}).Error)
+
+	const phone = "14155550300"
+	lastReply := func(text string) string {
+		var before int64
+		app.DB.Model(&models.Message{}).Where("organization_id = ? AND direction = ?", org.ID, models.DirectionOutgoing).Count(&before)
+
+		body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+			"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+			"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+			"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"text","text":{"body":%q}}]}}]}]}`,
+			account.PhoneID, phone, phone, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()), text)
+		req := testutil.NewJSONRequest(t, nil)
+		req.RequestCtx.Request.SetBody([]byte(body))
+		require.NoError(t, app.WebhookHandler(req))
+
+		var reply models.Message
+		require.Eventually(t, func() bool {
+			var count int64
+			app.DB.Model(&models.Message{}).Where("organization_id = ? AND direction = ?", org.ID, models.DirectionOutgoing).Count(&count)
+			return count > before
+		}, 2*time.Second, 20*time.Millisecond)
+		require.NoError(t, app.DB.Where("organization_id = ? AND direction = ?", org.ID, models.DirectionOutgoing).
+			Order("created_at DESC").First(&reply).Error)
+		return reply.Content
+	}
+
+	assert.Equal(t, "Order at https://shop.example.com", lastReply("shop"))
+
+	// Updating the value is picked up without touching the rule
+	req := testutil.NewJSONRequest(t, map[string]any{"name": "store_url", "value": "https://new.example.com"})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", storeURL.ID.String())
+	require.NoError(t, app.UpdateChatbotVariable(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	assert.Equal(t, "Order at https://new.example.com", lastReply("shop again"))
+
+	req = testutil.NewJSONRequest(t, nil)
+	setTransferAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", storeURL.ID.String())
+	require.NoError(t, app.DeleteChatbotVariable(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewGETRequest(t)
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.ListChatbotVariables(req))
+	var list struct {
+		Data struct {
+			Variables []models.ChatbotVariable `json:"variables"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &list)
+	assert.Empty(t, list.Data.Variables)
+
+	// The name is free again once deleted
+	status, _ = create(admin.ID, map[string]any{"name": "store_url", "value": "https://shop.example.com"})
+	assert.Equal(t, fasthttp.StatusOK, status)
+}
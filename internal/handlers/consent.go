@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/consent"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxConsentEvidenceLength caps the proof stored with an opt-in
+	maxConsentEvidenceLength = 2000
+	// consentExportBatchSize is how many records are read at a time when exporting
+	consentExportBatchSize = 1000
+)
+
+// consentExportHeader is the header row of a consent export
+var consentExportHeader = []string{
+	"consent_id", "contact_id", "phone_number", "source", "method", "campaign_id",
+	"consented_at", "evidence", "recorded_by", "revoked_at", "revoked_by", "revoke_reason",
+}
+
+// ContactConsentRequest records a contact's opt-in
+type ContactConsentRequest struct {
+	Source      models.ConsentSource `json:"source"`
+	Method      models.ConsentMethod `json:"method"`
+	CampaignID  string               `json:"campaign_id"`
+	ConsentedAt string               `json:"consented_at"` // RFC 3339; defaults to now
+	Evidence    string               `json:"evidence"`
+}
+
+// RevokeConsentRequest withdraws a contact's opt-in
+type RevokeConsentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListContactConsents returns a contact's opt-in records, newest first, including revoked ones
+func (a *App) ListContactConsents(r *fastglue.Request) error {
+	contact, err := a.contactFromPath(r, models.ActionRead)
+	if err != nil || contact == nil {
+		return err
+	}
+
+	var consents []models.ContactConsent
+	if err := a.DB.Where("organization_id = ? AND contact_id = ?", contact.OrganizationID, contact.ID).
+		Order("consented_at DESC").Find(&consents).Error; err != nil {
+		a.Log.Error("Failed to list contact consents", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list consents", nil, "")
+	}
+
+	active := false
+	for i := range consents {
+		if consents[i].IsActive() {
+			active = true
+			break
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"consents":   consents,
+		"has_active": active,
+	})
+}
+
+// CreateContactConsent records proof that a contact opted in to marketing messages
+func (a *App) CreateContactConsent(r *fastglue.Request) error {
+	contact, err := a.contactFromPath(r, models.ActionWrite)
+	if err != nil || contact == nil {
+		return err
+	}
+
+	var req ContactConsentRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if !consent.IsValidSource(req.Source) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "source must be one of: web_form, whatsapp, campaign, import, offline, api", nil, "")
+	}
+	if !consent.IsValidMethod(req.Method) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "method must be one of: checkbox, double_opt_in, keyword, signature, verbal", nil, "")
+	}
+	req.Evidence = strings.TrimSpace(req.Evidence)
+	if len(req.Evidence) > maxConsentEvidenceLength {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Evidence cannot exceed %d characters", maxConsentEvidenceLength), nil, "")
+	}
+
+	consentedAt := time.Now()
+	if req.ConsentedAt != "" {
+		if consentedAt, err = time.Parse(time.RFC3339, req.ConsentedAt); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid consented_at. Use RFC 3339, e.g. 2024-01-31T14:00:00Z", nil, "")
+		}
+		if consentedAt.After(time.Now().Add(time.Minute)) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "consented_at cannot be in the future", nil, "")
+		}
+	}
+
+	var campaignID *uuid.UUID
+	if req.CampaignID != "" {
+		id, err := uuid.Parse(req.CampaignID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign_id", nil, "")
+		}
+		var count int64
+		a.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND organization_id = ?", id, contact.OrganizationID).Count(&count)
+		if count == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+		}
+		campaignID = &id
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	record := models.ContactConsent{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: contact.OrganizationID,
+		ContactID:      contact.ID,
+		PhoneNumber:    contact.PhoneNumber,
+		Source:         req.Source,
+		Method:         req.Method,
+		CampaignID:     campaignID,
+		ConsentedAt:    consentedAt.UTC(),
+		Evidence:       req.Evidence,
+	}
+	if userID != uuid.Nil {
+		record.RecordedBy = &userID
+	}
+	if err := a.DB.Create(&record).Error; err != nil {
+		a.Log.Error("Failed to record consent", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to record consent", nil, "")
+	}
+
+	a.Log.Info("Contact consent recorded", "contact_id", contact.ID, "source", record.Source, "method", record.Method)
+	return r.SendEnvelope(record)
+}
+
+// RevokeContactConsent marks a contact's opt-in as withdrawn. The record is kept for audits.
+func (a *App) RevokeContactConsent(r *fastglue.Request) error {
+	contact, err := a.contactFromPath(r, models.ActionWrite)
+	if err != nil || contact == nil {
+		return err
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("consent_id").(string)
+	consentID, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid consent ID", nil, "")
+	}
+
+	var req RevokeConsentRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > 255 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Reason cannot exceed 255 characters", nil, "")
+	}
+
+	var record models.ContactConsent
+	if err := a.DB.Where("id = ? AND organization_id = ? AND contact_id = ?", consentID, contact.OrganizationID, contact.ID).
+		First(&record).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Consent not found", nil, "")
+	}
+	if !record.IsActive() {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Consent is already revoked", nil, "")
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"revoked_at":    now,
+		"revoke_reason": req.Reason,
+	}
+	if userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID); ok && userID != uuid.Nil {
+		updates["revoked_by"] = userID
+	}
+	if err := a.DB.Model(&record).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to revoke consent", "error", err, "consent_id", record.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to revoke consent", nil, "")
+	}
+
+	a.Log.Info("Contact consent revoked", "contact_id", contact.ID, "consent_id", record.ID)
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Consent revoked",
+	})
+}
+
+// ExportConsents downloads the organization's opt-in records as CSV for audits. from and
+// to (YYYY-MM-DD, in the organization's timezone) filter on when consent was given.
+func (a *App) ExportConsents(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	query := a.DB.Model(&models.ContactConsent{}).Where("organization_id = ?", orgID)
+	loc := a.getOrgLocation(orgID)
+	if from := string(r.RequestCtx.QueryArgs().Peek("from")); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
+		}
+		query = query.Where("consented_at >= ?", t)
+	}
+	if to := string(r.RequestCtx.QueryArgs().Peek("to")); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
+		}
+		query = query.Where("consented_at < ?", t.AddDate(0, 0, 1))
+	}
+	if string(r.RequestCtx.QueryArgs().Peek("active_only")) == "true" {
+		query = query.Where("revoked_at IS NULL")
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(consentExportHeader)
+	var batch []models.ContactConsent
+	if err := query.Order("consented_at, id").FindInBatches(&batch, consentExportBatchSize, func(_ *gorm.DB, _ int) error {
+		for i := range batch {
+			_ = w.Write(consentExportRow(&batch[i]))
+		}
+		return nil
+	}).Error; err != nil {
+		a.Log.Error("Failed to export consents", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export consents", nil, "")
+	}
+	w.Flush()
+
+	r.RequestCtx.Response.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="consents-%s.csv"`, time.Now().In(loc).Format("2006-01-02")))
+	r.RequestCtx.Response.Header.Set("Cache-Control", "private, no-store")
+	r.RequestCtx.SetBody(buf.Bytes())
+	return nil
+}
+
+// consentExportRow formats a consent record as a CSV row matching consentExportHeader
+func consentExportRow(c *models.ContactConsent) []string {
+	optionalID := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	revokedAt := ""
+	if c.RevokedAt != nil {
+		revokedAt = c.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		c.ID.String(), c.ContactID.String(), c.PhoneNumber, string(c.Source), string(c.Method),
+		optionalID(c.CampaignID), c.ConsentedAt.UTC().Format(time.RFC3339), c.Evidence,
+		optionalID(c.RecordedBy), revokedAt, optionalID(c.RevokedBy), c.RevokeReason,
+	}
+}
+
+// checkMarketingConsent returns consent.ErrMissing when the organization requires consent
+// for marketing messages and the number has no active opt-in
+func (a *App) checkMarketingConsent(orgID uuid.UUID, phoneNumber string, template *models.Template) error {
+	if template == nil || !consent.AppliesTo(template.Category) || !a.orgRequiresConsent(orgID) {
+		return nil
+	}
+	err := consent.Check(a.DB, orgID, phoneNumber)
+	if err != nil && !errors.Is(err, consent.ErrMissing) {
+		// Don't block sends on a lookup failure
+		a.Log.Error("Failed to check marketing consent", "error", err, "org_id", orgID)
+		return nil
+	}
+	return err
+}
+
+// orgRequiresConsent reports whether the organization only sends marketing templates to
+// contacts with an active opt-in
+func (a *App) orgRequiresConsent(orgID uuid.UUID) bool {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgConsentCachePrefix, orgID.String())
+
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			return cached == "1"
+		}
+	}
+
+	required := false
+	var org models.Organization
+	if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err == nil && org.Settings != nil {
+		required = consent.RequiredFromSettings(org.Settings)
+	}
+
+	if a.Redis != nil {
+		value := "0"
+		if required {
+			value = "1"
+		}
+		a.Redis.Set(ctx, cacheKey, value, orgConsentCacheTTL)
+	}
+	return required
+}
+
+// InvalidateOrgConsentCache invalidates the cached consent requirement for an organization
+func (a *App) InvalidateOrgConsentCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgConsentCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/consent"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_ContactConsent(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("consent"), "password", &role.ID, true)
+	contact := createTestContact(t, app, org.ID)
+
+	// Record an opt-in
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"source":       "web_form",
+		"method":       "checkbox",
+		"consented_at": "2024-03-01T10:00:00Z",
+		"evidence":     "https://example.com/signup",
+	})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.CreateContactConsent(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var created struct {
+		Data models.ContactConsent `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, contact.PhoneNumber, created.Data.PhoneNumber)
+	require.NoError(t, consent.Check(app.DB, org.ID, contact.PhoneNumber))
+
+	// Unknown sources are rejected
+	req = testutil.NewJSONRequest(t, map[string]interface{}{"source": "rumour", "method": "checkbox"})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.CreateContactConsent(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	// The export lists the record
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetQueryParam(req, "from", "2024-03-01")
+	require.NoError(t, app.ExportConsents(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	rows, err := csv.NewReader(strings.NewReader(string(testutil.GetResponseBody(req)))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, contact.PhoneNumber, rows[1][2])
+	assert.Equal(t, "2024-03-01T10:00:00Z", rows[1][6])
+
+	// Revoking keeps the record but withdraws the opt-in
+	req = testutil.NewJSONRequest(t, map[string]interface{}{"reason": "Replied STOP"})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	testutil.SetPathParam(req, "consent_id", created.Data.ID.String())
+	require.NoError(t, app.RevokeContactConsent(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.ErrorIs(t, consent.Check(app.DB, org.ID, contact.PhoneNumber), consent.ErrMissing)
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.ListContactConsents(req))
+	var list struct {
+		Data struct {
+			Consents  []models.ContactConsent `json:"consents"`
+			HasActive bool                    `json:"has_active"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &list)
+	require.Len(t, list.Data.Consents, 1)
+	assert.False(t, list.Data.HasActive)
+	assert.Equal(t, "Replied STOP", list.Data.Consents[0].RevokeReason)
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetQueryParam(req, "active_only", "true")
+	require.NoError(t, app.ExportConsents(req))
+	rows, err = csv.NewReader(strings.NewReader(string(testutil.GetResponseBody(req)))).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+}
+
+func TestApp_SendOutgoingMessage_RequiresMarketingConsent(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	marketing := createTestTemplate(t, app, org.ID, account.Name)
+	utility := createTestTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(utility).Update("category", "UTILITY").Error)
+
+	org.Settings = models.JSONB{consent.SettingKey: true}
+	require.NoError(t, app.DB.Save(org).Error)
+
+	send := func(template *models.Template) error {
+		_, err := app.SendOutgoingMessage(testutil.TestContext(t), handlers.OutgoingMessageRequest{
+			Account:    account,
+			Contact:    contact,
+			Type:       models.MessageTypeTemplate,
+			Template:   template,
+			BodyParams: map[string]string{"1": "Ann"},
+		}, handlers.ChatbotSendOptions())
+		return err
+	}
+
+	// Marketing needs an opt-in, utility doesn't
+	assert.ErrorIs(t, send(marketing), consent.ErrMissing)
+	require.NoError(t, send(utility))
+
+	require.NoError(t, app.DB.Create(&models.ContactConsent{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: org.ID,
+		ContactID:      contact.ID,
+		PhoneNumber:    contact.PhoneNumber,
+		Source:         models.ConsentSourceWhatsApp,
+		Method:         models.ConsentMethodKeyword,
+		ConsentedAt:    contact.CreatedAt,
+	}).Error)
+	require.NoError(t, send(marketing))
+	assert.Len(t, mockServer.sentMessages, 2)
+}
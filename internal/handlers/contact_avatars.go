@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/enrichment"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// avatarSubdir is the media storage directory for contact photos
+	avatarSubdir = "avatars"
+	// avatarMaxBytes caps the size of a downloaded profile photo
+	avatarMaxBytes = 2 << 20
+	// avatarRefreshInterval is how often a contact's photo is looked up again
+	avatarRefreshInterval = 7 * 24 * time.Hour
+	// avatarActiveWindow limits refreshes to contacts that messaged recently
+	avatarActiveWindow = 30 * 24 * time.Hour
+	// avatarRefreshBatchSize caps how many contacts a single refresh run looks up
+	avatarRefreshBatchSize = 200
+)
+
+// avatarContentTypes maps the photo formats accepted from providers to file extensions
+var avatarContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// avatarColors are the backgrounds of generated initials avatars
+var avatarColors = []string{"#2563eb", "#7c3aed", "#db2777", "#dc2626", "#ea580c", "#16a34a", "#0d9488", "#0891b2"}
+
+// contactAvatarURL returns the API path of a contact's cached photo, or "" if it has none.
+// The version parameter changes whenever the photo is refreshed.
+func contactAvatarURL(c *models.Contact) string {
+	if c.AvatarPath == "" {
+		return ""
+	}
+	var version int64
+	if c.AvatarCheckedAt != nil {
+		version = c.AvatarCheckedAt.Unix()
+	}
+	return fmt.Sprintf("/api/contacts/%s/avatar?v=%d", c.ID, version)
+}
+
+// GetContactAvatar serves a contact's cached profile photo, or an initials avatar if
+// none is available
+func (a *App) GetContactAvatar(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	// Users without contacts:read permission can only access their assigned contacts
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	r.RequestCtx.Response.Header.Set("Cache-Control", "private, max-age=86400")
+
+	// Restricted roles only get the initials of the masked name
+	mask := a.dataMaskFor(orgID, userID)
+	if contact.AvatarPath != "" && !strings.Contains(contact.AvatarPath, "..") && !mask.restricted {
+		data, err := a.readMediaFile(orgID, contact.AvatarPath)
+		if err == nil {
+			r.RequestCtx.Response.Header.Set("Content-Type", http.DetectContentType(data))
+			r.RequestCtx.SetBody(data)
+			return nil
+		}
+		a.Log.Warn("Cached contact avatar missing", "contact_id", contact.ID, "path", contact.AvatarPath, "error", err)
+	}
+
+	// Names that are phone numbers have no letters and render as "#", so nothing is leaked
+	r.RequestCtx.Response.Header.Set("Content-Type", "image/svg+xml")
+	r.RequestCtx.SetBody(initialsAvatarSVG(mask.Name(contact.ProfileName), contact.ID.String()))
+	return nil
+}
+
+// avatarInitials returns up to two initials for a name, or "#" for names without letters
+// such as phone numbers
+func avatarInitials(name string) string {
+	var initials []rune
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) {
+				initials = append(initials, unicode.ToUpper(r))
+			}
+			break
+		}
+		if len(initials) == 2 {
+			break
+		}
+	}
+	if len(initials) == 0 {
+		return "#"
+	}
+	return string(initials)
+}
+
+// initialsAvatarSVG renders a round avatar with the name's initials. The background
+// color is derived from seed so it stays the same for a contact.
+func initialsAvatarSVG(name, seed string) []byte {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(seed))
+	color := avatarColors[h.Sum32()%uint32(len(avatarColors))]
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="128" height="128" viewBox="0 0 128 128">`+
+		`<circle cx="64" cy="64" r="64" fill="%s"/>`+
+		`<text x="64" y="64" dy=".35em" text-anchor="middle" fill="#ffffff" font-family="Helvetica, Arial, sans-serif" font-size="52" font-weight="600">%s</text>`+
+		`</svg>`, color, html.EscapeString(avatarInitials(name))))
+}
+
+// downloadAvatar fetches a profile photo, returning its content and file extension.
+// Only HTTPS URLs and common image formats up to avatarMaxBytes are accepted.
+func downloadAvatar(ctx context.Context, client *http.Client, photoURL string) ([]byte, string, error) {
+	if err := enrichment.ValidateURL(photoURL); err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, photoURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("photo download returned %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, avatarMaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > avatarMaxBytes {
+		return nil, "", errors.New("photo is too large")
+	}
+	// Trust the content, not the header, so only real images are stored and served
+	ext, ok := avatarContentTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, "", errors.New("photo is not a supported image")
+	}
+	return data, ext, nil
+}
+
+// storeContactAvatar downloads a contact's photo into media storage and records it
+func (a *App) storeContactAvatar(ctx context.Context, client *http.Client, contact *models.Contact, photoURL string) error {
+	data, ext, err := downloadAvatar(ctx, client, photoURL)
+	if err != nil {
+		return err
+	}
+
+	dir, err := a.ensureMediaDir(contact.OrganizationID, avatarSubdir)
+	if err != nil {
+		return fmt.Errorf("failed to create avatar directory: %w", err)
+	}
+	relativePath := filepath.Join(dir, contact.ID.String()+ext)
+	if err := a.writeMediaFile(contact.OrganizationID, relativePath, data); err != nil {
+		return fmt.Errorf("failed to save avatar: %w", err)
+	}
+	// A photo in another format leaves the previous file behind
+	if contact.AvatarPath != "" && contact.AvatarPath != relativePath {
+		_ = os.Remove(a.mediaFullPath(contact.AvatarPath))
+	}
+
+	now := time.Now()
+	if err := a.DB.Model(contact).Updates(map[string]interface{}{
+		"avatar_path":       relativePath,
+		"avatar_checked_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	contact.AvatarPath = relativePath
+	contact.AvatarCheckedAt = &now
+	return nil
+}
+
+// refreshContactAvatar looks the contact's photo up with the organization's enrichment
+// providers, using cached lookups, and stores the first one found. The check time is
+// recorded either way so contacts without a photo aren't looked up on every run.
+func (a *App) refreshContactAvatar(ctx context.Context, client *http.Client, contact *models.Contact, providers []models.EnrichmentProvider) {
+	for _, p := range providers {
+		res, err := a.lookupEnrichment(ctx, client, p, contact, false)
+		if err != nil || res.AvatarURL == "" {
+			continue
+		}
+		if err := a.storeContactAvatar(ctx, client, contact, res.AvatarURL); err != nil {
+			a.Log.Warn("Failed to store contact avatar", "error", err, "contact_id", contact.ID, "provider_id", p.ID)
+			continue
+		}
+		return
+	}
+	a.DB.Model(contact).Update("avatar_checked_at", time.Now())
+}
+
+// refreshContactAvatars refreshes the photos of recently active contacts in organizations
+// with enrichment providers, starting with those never checked
+func (a *App) refreshContactAvatars(ctx context.Context) {
+	var orgIDs []uuid.UUID
+	if err := a.DB.Model(&models.EnrichmentProvider{}).
+		Where("is_active = ?", true).
+		Distinct().Pluck("organization_id", &orgIDs).Error; err != nil {
+		a.Log.Error("Failed to list organizations for avatar refresh", "error", err)
+		return
+	}
+
+	now := time.Now()
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, orgID := range orgIDs {
+		providers, err := a.activeEnrichmentProviders(orgID)
+		if err != nil || len(providers) == 0 {
+			continue
+		}
+
+		var contacts []models.Contact
+		if err := a.DB.Where("organization_id = ? AND last_message_at >= ?", orgID, now.Add(-avatarActiveWindow)).
+			Where("avatar_checked_at IS NULL OR avatar_checked_at < ?", now.Add(-avatarRefreshInterval)).
+			Order("avatar_checked_at ASC NULLS FIRST").
+			Limit(avatarRefreshBatchSize).
+			Find(&contacts).Error; err != nil {
+			a.Log.Error("Failed to list contacts for avatar refresh", "error", err, "org_id", orgID)
+			continue
+		}
+
+		for i := range contacts {
+			if ctx.Err() != nil {
+				return
+			}
+			a.refreshContactAvatar(ctx, client, &contacts[i], providers)
+		}
+	}
+}
+
+// ContactAvatarProcessor periodically refreshes cached contact photos
+type ContactAvatarProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewContactAvatarProcessor creates a new contact avatar processor
+func NewContactAvatarProcessor(app *App, interval time.Duration) *ContactAvatarProcessor {
+	return &ContactAvatarProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the refresh loop
+func (p *ContactAvatarProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Contact avatar processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Contact avatar processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Contact avatar processor stopped")
+			return
+		case <-ticker.C:
+			p.app.refreshContactAvatars(ctx)
+		}
+	}
+}
+
+// Stop stops the contact avatar processor
+func (p *ContactAvatarProcessor) Stop() {
+	close(p.stopCh)
+}
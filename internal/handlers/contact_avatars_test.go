@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestAvatarInitials(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Ada Lovelace", "AL"},
+		{"ada", "A"},
+		{"Ada King Lovelace", "AK"},
+		{"  émile  zola ", "ÉZ"},
+		{"919876543210", "#"},
+		{"", "#"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, avatarInitials(tt.name))
+		})
+	}
+}
+
+func TestInitialsAvatarSVG(t *testing.T) {
+	svg := string(initialsAvatarSVG("Ada Lovelace", "seed"))
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, ">AL</text>")
+
+	// The color only depends on the seed
+	assert.Equal(t, initialsAvatarSVG("Ada", "seed")[:200], initialsAvatarSVG("Bob", "seed")[:200])
+}
+
+func TestContactAvatarURL(t *testing.T) {
+	contact := &models.Contact{BaseModel: models.BaseModel{ID: uuid.New()}}
+	assert.Empty(t, contactAvatarURL(contact))
+
+	checked := time.Unix(1700000000, 0)
+	contact.AvatarPath = "avatars/" + contact.ID.String() + ".png"
+	contact.AvatarCheckedAt = &checked
+	assert.Equal(t, "/api/contacts/"+contact.ID.String()+"/avatar?v=1700000000", contactAvatarURL(contact))
+}
+
+func TestDownloadAvatar(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo":
+			_, _ = w.Write(pngHeader)
+		case "/html":
+			// A misleading header doesn't make it an image
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte("<html><body>not a photo</body></html>"))
+		case "/large":
+			_, _ = w.Write(append(pngHeader, bytes.Repeat([]byte{0}, avatarMaxBytes)...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := server.Client()
+	ctx := context.Background()
+
+	data, ext, err := downloadAvatar(ctx, client, server.URL+"/photo")
+	require.NoError(t, err)
+	assert.Equal(t, ".png", ext)
+	assert.Equal(t, pngHeader, data)
+
+	for _, path := range []string{"/html", "/large", "/missing"} {
+		_, _, err := downloadAvatar(ctx, client, server.URL+path)
+		assert.Error(t, err, path)
+	}
+
+	_, _, err = downloadAvatar(ctx, client, strings.Replace(server.URL, "https://", "http://", 1)+"/photo")
+	assert.Error(t, err, "plain http is rejected")
+}
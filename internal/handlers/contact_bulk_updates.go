@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxContactBulkUpdate caps the contacts one bulk update can change
+	maxContactBulkUpdate = 100000
+	// maxContactBulkIDs caps the contacts a bulk update can list by ID, which stays well
+	// under the query parameter limit of Postgres
+	maxContactBulkIDs = 10000
+	// maxContactBulkTags caps the tags a bulk update adds or removes
+	maxContactBulkTags = 20
+	// maxContactBulkFields caps the custom fields a bulk update sets
+	maxContactBulkFields = 20
+)
+
+// ContactBulkUpdateRequest selects contacts, either by ID or with segment filters, and
+// the changes to make to them. Changes left unset are not made.
+type ContactBulkUpdateRequest struct {
+	ContactIDs []uuid.UUID            `json:"contact_ids"`
+	Filter     *models.SegmentFilters `json:"filter"`
+
+	AddTags         []string          `json:"add_tags"`
+	RemoveTags      []string          `json:"remove_tags"`
+	CustomFields    map[string]string `json:"custom_fields"`
+	Assign          bool              `json:"assign"`         // Set to change the assignment
+	AssignUserID    *uuid.UUID        `json:"assign_user_id"` // nil with assign unassigns
+	WhatsAppAccount string            `json:"whatsapp_account"`
+}
+
+// BulkUpdateContacts queues a change to many contacts. The matching contacts are chosen
+// now and changed by a worker job; progress is pushed to the requester over the
+// WebSocket and can be polled with GetContactBulkUpdate.
+func (a *App) BulkUpdateContacts(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "You do not have permission to update contacts", nil, "")
+	}
+
+	var req ContactBulkUpdateRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := normalizeContactBulkUpdate(&req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if req.Assign && req.AssignUserID != nil {
+		var count int64
+		a.DB.Model(&models.User{}).Where("id = ? AND organization_id = ?", req.AssignUserID, orgID).Count(&count)
+		if count == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "User not found", nil, "")
+		}
+	}
+	if req.WhatsAppAccount != "" {
+		var count int64
+		a.DB.Model(&models.WhatsAppAccount{}).Where("name = ? AND organization_id = ?", req.WhatsAppAccount, orgID).Count(&count)
+		if count == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+		}
+	}
+
+	var query *gorm.DB
+	if req.Filter != nil {
+		query = a.segmentContacts(orgID, *req.Filter)
+	} else {
+		query = a.DB.Model(&models.Contact{}).Where("contacts.organization_id = ? AND contacts.id IN ?", orgID, req.ContactIDs)
+	}
+	// One more than the cap tells a selection that is too large from one that fits
+	var ids []uuid.UUID
+	if err := query.Order("contacts.id").Limit(maxContactBulkUpdate+1).Pluck("contacts.id", &ids).Error; err != nil {
+		a.Log.Error("Failed to select contacts for bulk update", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to select contacts", nil, "")
+	}
+	if len(ids) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No contacts match", nil, "")
+	}
+	if len(ids) > maxContactBulkUpdate {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("At most %d contacts can be updated at once", maxContactBulkUpdate), nil, "")
+	}
+
+	update := models.ContactBulkUpdate{
+		OrganizationID:  orgID,
+		RequestedByID:   userID,
+		AddTags:         toJSONBArray(req.AddTags),
+		RemoveTags:      toJSONBArray(req.RemoveTags),
+		CustomFields:    make(models.JSONB, len(req.CustomFields)),
+		Assign:          req.Assign,
+		AssignUserID:    req.AssignUserID,
+		WhatsAppAccount: req.WhatsAppAccount,
+		ContactIDs:      make(models.JSONBArray, len(ids)),
+		Status:          models.ContactBulkUpdateStatusQueued,
+		TotalCount:      len(ids),
+	}
+	for k, v := range req.CustomFields {
+		update.CustomFields[k] = v
+	}
+	for i, id := range ids {
+		update.ContactIDs[i] = id.String()
+	}
+	if err := a.DB.Create(&update).Error; err != nil {
+		a.Log.Error("Failed to create contact bulk update", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start bulk update", nil, "")
+	}
+
+	if err := a.Queue.EnqueueContactBulkUpdate(r.RequestCtx, &queue.ContactBulkUpdateJob{
+		UpdateID:       update.ID,
+		OrganizationID: orgID,
+	}); err != nil {
+		a.Log.Error("Failed to enqueue contact bulk update", "error", err, "update_id", update.ID)
+		a.DB.Model(&update).Updates(map[string]interface{}{
+			"status": models.ContactBulkUpdateStatusFailed,
+			"error":  "Failed to queue the update",
+		})
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start bulk update", nil, "")
+	}
+
+	return r.SendEnvelope(update)
+}
+
+// GetContactBulkUpdate returns the progress of a bulk contact update
+func (a *App) GetContactBulkUpdate(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid bulk update ID", nil, "")
+	}
+
+	var update models.ContactBulkUpdate
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&update).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Bulk update not found", nil, "")
+	}
+	return r.SendEnvelope(update)
+}
+
+// normalizeContactBulkUpdate trims the request in place and checks it selects contacts
+// one way and makes at least one change
+func normalizeContactBulkUpdate(req *ContactBulkUpdateRequest) error {
+	switch {
+	case len(req.ContactIDs) > 0 && req.Filter != nil:
+		return errors.New("use either contact_ids or filter, not both")
+	case len(req.ContactIDs) == 0 && req.Filter == nil:
+		return errors.New("contact_ids or filter is required")
+	case len(req.ContactIDs) > maxContactBulkIDs:
+		return fmt.Errorf("at most %d contact_ids can be given, use a filter for more", maxContactBulkIDs)
+	}
+	if req.Filter != nil {
+		if err := normalizeSegmentFilters(req.Filter); err != nil {
+			return err
+		}
+	}
+
+	req.AddTags = cleanContactTags(req.AddTags)
+	req.RemoveTags = cleanContactTags(req.RemoveTags)
+	if len(req.AddTags) > maxContactBulkTags || len(req.RemoveTags) > maxContactBulkTags {
+		return fmt.Errorf("at most %d tags can be added or removed", maxContactBulkTags)
+	}
+	for _, tag := range req.AddTags {
+		if slices.Contains(req.RemoveTags, tag) {
+			return fmt.Errorf("tag %q can't be both added and removed", tag)
+		}
+	}
+
+	if len(req.CustomFields) > maxContactBulkFields {
+		return fmt.Errorf("at most %d custom fields can be set", maxContactBulkFields)
+	}
+	fields := make(map[string]string, len(req.CustomFields))
+	for name, value := range req.CustomFields {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return errors.New("custom field names cannot be empty")
+		}
+		if len(name) > maxContactFieldNameLength {
+			return fmt.Errorf("custom field name %q is too long", name)
+		}
+		fields[name] = value
+	}
+	req.CustomFields = fields
+
+	req.WhatsAppAccount = strings.TrimSpace(req.WhatsAppAccount)
+	if !req.Assign {
+		req.AssignUserID = nil
+	}
+
+	if len(req.AddTags) == 0 && len(req.RemoveTags) == 0 && len(req.CustomFields) == 0 && !req.Assign && req.WhatsAppAccount == "" {
+		return errors.New("no changes requested")
+	}
+	return nil
+}
+
+// toJSONBArray copies strings into a JSONBArray
+func toJSONBArray(values []string) models.JSONBArray {
+	arr := make(models.JSONBArray, len(values))
+	for i, v := range values {
+		arr[i] = v
+	}
+	return arr
+}
+
+// StartContactBulkUpdateSubscriber relays bulk contact update progress published by the
+// workers to the requesting user's WebSocket clients
+func (a *App) StartContactBulkUpdateSubscriber() error {
+	if a.WSHub == nil {
+		a.Log.Warn("WebSocket hub not initialized, skipping contact bulk update subscriber")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.ContactBulkSubCancel = cancel
+
+	subscriber := queue.NewSubscriber(a.Redis, a.Log)
+	err := subscriber.SubscribeContactBulkUpdates(ctx, func(progress *queue.ContactBulkUpdateProgress) {
+		a.WSHub.BroadcastToUser(progress.OrganizationID, progress.RequestedByID, websocket.WSMessage{
+			Type:    websocket.TypeContactBulkUpdate,
+			Payload: progress,
+		})
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	a.Log.Info("Contact bulk update subscriber started")
+	return nil
+}
+
+// StopContactBulkUpdateSubscriber stops the contact bulk update subscriber
+func (a *App) StopContactBulkUpdateSubscriber() {
+	if a.ContactBulkSubCancel != nil {
+		a.ContactBulkSubCancel()
+	}
+}
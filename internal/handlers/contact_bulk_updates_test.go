@@ -0,0 +1,115 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_BulkUpdateContacts(t *testing.T) {
+	app, mockQueue := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("bulk"), "password", &role.ID, true)
+	agent := createTestAgent(t, app, org.ID)
+	createTestWhatsAppAccount(t, app, org.ID, "bulk-account")
+	pune, mumbai, churned := createSegmentTestContacts(t, app, org.ID)
+
+	t.Run("filter selects matching contacts", func(t *testing.T) {
+		req := testutil.NewJSONRequest(t, map[string]any{
+			"filter":         map[string]any{"exclude_tags": []string{"churned"}},
+			"add_tags":       []string{" gold ", "gold"},
+			"remove_tags":    []string{"vip"},
+			"custom_fields":  map[string]string{"tier": "gold"},
+			"assign":         true,
+			"assign_user_id": agent.ID,
+		})
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.BulkUpdateContacts(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		var update models.ContactBulkUpdate
+		testutil.ParseEnvelopeResponse(t, req, &update)
+		assert.Equal(t, models.ContactBulkUpdateStatusQueued, update.Status)
+		assert.Equal(t, 2, update.TotalCount)
+		assert.Equal(t, models.JSONBArray{"gold"}, update.AddTags)
+
+		var stored models.ContactBulkUpdate
+		require.NoError(t, app.DB.Where("id = ?", update.ID).First(&stored).Error)
+		assert.ElementsMatch(t, models.JSONBArray{pune.ID.String(), mumbai.ID.String()}, stored.ContactIDs)
+
+		require.NotEmpty(t, mockQueue.ContactBulkUpdateJobs)
+		job := mockQueue.ContactBulkUpdateJobs[len(mockQueue.ContactBulkUpdateJobs)-1]
+		assert.Equal(t, update.ID, job.UpdateID)
+		assert.Equal(t, org.ID, job.OrganizationID)
+	})
+
+	t.Run("contact IDs outside the organization are ignored", func(t *testing.T) {
+		other := createTestOrganization(t, app)
+		outsider := createTestContact(t, app, other.ID)
+
+		req := testutil.NewJSONRequest(t, map[string]any{
+			"contact_ids":      []uuid.UUID{churned.ID, outsider.ID},
+			"whatsapp_account": "bulk-account",
+		})
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.BulkUpdateContacts(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		var update models.ContactBulkUpdate
+		testutil.ParseEnvelopeResponse(t, req, &update)
+		assert.Equal(t, 1, update.TotalCount)
+
+		// The progress can be polled
+		get := testutil.NewGETRequest(t)
+		setAuthContext(get, org.ID, user.ID)
+		testutil.SetPathParam(get, "id", update.ID.String())
+		require.NoError(t, app.GetContactBulkUpdate(get))
+		assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(get))
+	})
+
+	tests := []struct {
+		name string
+		body map[string]any
+	}{
+		{"no selection", map[string]any{"add_tags": []string{"gold"}}},
+		{"both selections", map[string]any{"contact_ids": []uuid.UUID{pune.ID}, "filter": map[string]any{}, "add_tags": []string{"gold"}}},
+		{"no changes", map[string]any{"contact_ids": []uuid.UUID{pune.ID}}},
+		{"tag added and removed", map[string]any{"contact_ids": []uuid.UUID{pune.ID}, "add_tags": []string{"gold"}, "remove_tags": []string{"gold"}}},
+		{"empty field name", map[string]any{"contact_ids": []uuid.UUID{pune.ID}, "custom_fields": map[string]string{" ": "x"}}},
+		{"unknown assignee", map[string]any{"contact_ids": []uuid.UUID{pune.ID}, "assign": true, "assign_user_id": uuid.New()}},
+		{"unknown account", map[string]any{"contact_ids": []uuid.UUID{pune.ID}, "whatsapp_account": "missing"}},
+		{"nothing matches", map[string]any{"filter": map[string]any{"tags": []string{"nobody"}}, "add_tags": []string{"gold"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queued := len(mockQueue.ContactBulkUpdateJobs)
+			req := testutil.NewJSONRequest(t, tt.body)
+			setAuthContext(req, org.ID, user.ID)
+			require.NoError(t, app.BulkUpdateContacts(req))
+			assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+			assert.Len(t, mockQueue.ContactBulkUpdateJobs, queued)
+		})
+	}
+}
+
+func TestApp_BulkUpdateContacts_RequiresPermission(t *testing.T) {
+	app, mockQueue := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	agent := createTestAgent(t, app, org.ID)
+	contact := createTestContact(t, app, org.ID)
+
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"contact_ids": []uuid.UUID{contact.ID},
+		"add_tags":    []string{"gold"},
+	})
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.BulkUpdateContacts(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+	assert.Empty(t, mockQueue.ContactBulkUpdateJobs)
+}
@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// editContact calls CreateContact or UpdateContact as userID
+func editContact(t *testing.T, handler func(*fastglue.Request) error, orgID, userID uuid.UUID, contactID string, body any) *fastglue.Request {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, body)
+	setAuthContext(req, orgID, userID)
+	if contactID != "" {
+		testutil.SetPathParam(req, "id", contactID)
+	}
+	require.NoError(t, handler(req))
+	return req
+}
+
+func TestApp_CreateContact(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("contact-admin"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	// Numbers are stored as E.164 digits, however they were written
+	req := editContact(t, app.CreateContact, org.ID, admin.ID, "", map[string]any{
+		"phone_number": "+91 98765-43210",
+		"name":         " Asha ",
+		"tags":         []string{"vip", " vip ", ""},
+		"metadata":     map[string]any{"city": "Pune"},
+	})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var created handlers.ContactResponse
+	testutil.ParseEnvelopeResponse(t, req, &created)
+	assert.Equal(t, "919876543210", created.PhoneNumber)
+	assert.Equal(t, "Asha", created.Name)
+	assert.Equal(t, []string{"vip"}, created.Tags)
+
+	var contact models.Contact
+	require.NoError(t, app.DB.Where("id = ?", created.ID).First(&contact).Error)
+	assert.Equal(t, "919876543210", contact.PhoneNumber)
+	assert.Equal(t, "Pune", contact.Metadata["city"])
+
+	// The same number written differently is a duplicate
+	req = editContact(t, app.CreateContact, org.ID, admin.ID, "", map[string]any{"phone_number": "919876543210"})
+	assert.Equal(t, fasthttp.StatusConflict, testutil.GetResponseStatusCode(req))
+
+	req = editContact(t, app.CreateContact, org.ID, admin.ID, "", map[string]any{"name": "No number"})
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "phone_number is required")
+	req = editContact(t, app.CreateContact, org.ID, admin.ID, "", map[string]any{"phone_number": "12ab"})
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	// Restricted destination countries are rejected
+	require.NoError(t, app.DB.Model(org).Update("settings", models.JSONB{"blocked_countries": []string{"44"}}).Error)
+	req = editContact(t, app.CreateContact, org.ID, admin.ID, "", map[string]any{"phone_number": "+44 7700 900123"})
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	// Creating contacts needs contacts:write
+	readerRole := createTransferTestRole(t, app.DB, org.ID, "contact-reader", []string{"contacts:read"})
+	reader := createTestUser(t, app, org.ID, uniqueEmail("contact-reader"), "password", &readerRole.ID, true)
+	req = editContact(t, app.CreateContact, org.ID, reader.ID, "", map[string]any{"phone_number": "+1 415 555 0100"})
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
+
+func TestApp_UpdateContact(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("contact-admin"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	contact := createTestContact(t, app, org.ID)
+	other := createTestContact(t, app, org.ID)
+
+	req := editContact(t, app.UpdateContact, org.ID, admin.ID, contact.ID.String(), map[string]any{
+		"phone_number": "+1 (415) 555-0199",
+		"name":         "Ravi",
+	})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var updated models.Contact
+	require.NoError(t, app.DB.Where("id = ?", contact.ID).First(&updated).Error)
+	assert.Equal(t, "14155550199", updated.PhoneNumber)
+	assert.Equal(t, "Ravi", updated.ProfileName)
+
+	// Fields left out are unchanged
+	req = editContact(t, app.UpdateContact, org.ID, admin.ID, contact.ID.String(), map[string]any{"tags": []string{"lead"}})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	require.NoError(t, app.DB.Where("id = ?", contact.ID).First(&updated).Error)
+	assert.Equal(t, "14155550199", updated.PhoneNumber)
+	assert.Equal(t, "Ravi", updated.ProfileName)
+
+	// Another contact's number can't be taken, and invalid numbers are rejected
+	req = editContact(t, app.UpdateContact, org.ID, admin.ID, other.ID.String(), map[string]any{"phone_number": "+14155550199"})
+	assert.Equal(t, fasthttp.StatusConflict, testutil.GetResponseStatusCode(req))
+	req = editContact(t, app.UpdateContact, org.ID, admin.ID, other.ID.String(), map[string]any{"phone_number": "not a number"})
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	// Contacts of other organizations can't be updated
+	stranger := createTestContact(t, app, createTestOrg(t, app).ID)
+	req = editContact(t, app.UpdateContact, org.ID, admin.ID, stranger.ID.String(), map[string]any{"name": "Taken"})
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/xlsx"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// contactExportBatchSize is how many contacts are loaded at a time while exporting
+	contactExportBatchSize = 1000
+	// maxContactExportFields caps the custom field columns in an export
+	maxContactExportFields = 50
+)
+
+// contactExportHeader is the fixed part of an export's header row. Its first three
+// columns match the import targets, so an export can be imported again.
+var contactExportHeader = []string{
+	"Phone Number", "Name", "Tags", "Lifecycle Stage", "Engagement Score",
+	"WhatsApp Account", "Last Message At", "Created At",
+}
+
+// ExportContacts downloads the organization's contacts as CSV (default) or XLSX, with a
+// column per custom field. It accepts the search and lifecycle_stage filters of
+// ListContacts, and tag to export only contacts with that tag. Users without
+// contacts:read only export the contacts assigned to them. Roles with restricted data
+// access get masked names and numbers, no custom fields, and can't filter by search.
+func (a *App) ExportContacts(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionExport) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	format := string(r.RequestCtx.QueryArgs().Peek("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "format must be csv or xlsx", nil, "")
+	}
+
+	mask := a.dataMaskFor(orgID, userID)
+	query := a.DB.Model(&models.Contact{}).Where("organization_id = ?", orgID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if search := string(r.RequestCtx.QueryArgs().Peek("search")); search != "" && !mask.restricted {
+		searchPattern := "%" + search + "%"
+		query = query.Where("phone_number LIKE ? OR profile_name LIKE ?", searchPattern, searchPattern)
+	}
+	if stage := string(r.RequestCtx.QueryArgs().Peek("lifecycle_stage")); stage != "" {
+		query = query.Where("lifecycle_stage = ?", stage)
+	}
+	if tag := string(r.RequestCtx.QueryArgs().Peek("tag")); tag != "" {
+		tagJSON, _ := json.Marshal([]string{tag})
+		query = query.Where("tags @> ?", string(tagJSON))
+	}
+
+	var fields []string
+	if !mask.restricted {
+		if err := query.Session(&gorm.Session{}).
+			Select("DISTINCT jsonb_object_keys(metadata)").Scan(&fields).Error; err != nil {
+			a.Log.Error("Failed to list contact fields", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export contacts", nil, "")
+		}
+	}
+	slices.Sort(fields)
+	if len(fields) > maxContactExportFields {
+		fields = fields[:maxContactExportFields]
+	}
+
+	var buf bytes.Buffer
+	var write func([]interface{}) error
+	var finish func() error
+	if format == "xlsx" {
+		xw, err := xlsx.NewWriter(&buf, "Contacts")
+		if err != nil {
+			a.Log.Error("Failed to start contacts workbook", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export contacts", nil, "")
+		}
+		write, finish = xw.Write, xw.Close
+	} else {
+		cw := csv.NewWriter(&buf)
+		write = func(values []interface{}) error {
+			row := make([]string, len(values))
+			for i, v := range values {
+				row[i] = xlsx.Format(v)
+			}
+			return cw.Write(row)
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	header := make([]interface{}, 0, len(contactExportHeader)+len(fields))
+	for _, h := range contactExportHeader {
+		header = append(header, h)
+	}
+	for _, f := range fields {
+		header = append(header, f)
+	}
+	if err := write(header); err != nil {
+		a.Log.Error("Failed to write contacts export", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export contacts", nil, "")
+	}
+
+	var batch []models.Contact
+	// FindInBatches pages by primary key, so no other ordering is applied
+	if err := query.FindInBatches(&batch, contactExportBatchSize, func(_ *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := write(contactExportRow(&batch[i], fields, mask)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error; err != nil {
+		a.Log.Error("Failed to export contacts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export contacts", nil, "")
+	}
+	if err := finish(); err != nil {
+		a.Log.Error("Failed to finish contacts export", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export contacts", nil, "")
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == "xlsx" {
+		contentType = xlsx.ContentType
+	}
+	date := time.Now().In(a.getOrgLocation(orgID)).Format("2006-01-02")
+	r.RequestCtx.Response.Header.Set("Content-Type", contentType)
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="contacts-%s.%s"`, date, format))
+	r.RequestCtx.Response.Header.Set("Cache-Control", "private, no-store")
+	r.RequestCtx.SetBody(buf.Bytes())
+	return nil
+}
+
+// contactExportRow formats a contact as a row matching contactExportHeader followed by
+// the custom fields
+func contactExportRow(c *models.Contact, fields []string, mask dataMask) []interface{} {
+	tags := make([]string, 0, len(c.Tags))
+	for _, t := range c.Tags {
+		if s, ok := t.(string); ok {
+			tags = append(tags, s)
+		}
+	}
+
+	row := []interface{}{
+		mask.Phone(c.PhoneNumber), mask.Name(c.ProfileName), strings.Join(tags, ", "), string(c.LifecycleStage), c.EngagementScore,
+		c.WhatsAppAccount, c.LastMessageAt, c.CreatedAt,
+	}
+	for _, f := range fields {
+		row = append(row, contactFieldText(c.Metadata[f]))
+	}
+	return row
+}
+
+// contactFieldText renders a custom field value as cell text. Values set by imports are
+// strings; anything else is written as JSON.
+func contactFieldText(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/shridarpatil/whatomate/internal/sheets"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/xlsx"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// contactImportBatchSize is how many rows are matched and saved together
+	contactImportBatchSize = 500
+	// contactImportRejectedSample is how many rejected rows an import keeps for review
+	contactImportRejectedSample = 100
+	// contactImportStallTimeout marks imports without progress for this long as
+	// interrupted, e.g. by a restart
+	contactImportStallTimeout = 5 * time.Minute
+	// maxContactImportTags caps the tags added to every imported contact
+	maxContactImportTags = 20
+	// maxContactFieldNameLength caps custom field names created by an import
+	maxContactFieldNameLength = 64
+)
+
+// Contact import mapping targets. Any other target is the name of a custom field.
+const (
+	ContactFieldPhoneNumber = sheets.TargetPhoneNumber
+	ContactFieldName        = "name"
+	ContactFieldTags        = "tags" // Comma or semicolon separated
+)
+
+// RejectedContactRow is an import row that couldn't be saved
+type RejectedContactRow struct {
+	Row         int    `json:"row"`
+	PhoneNumber string `json:"phone_number"`
+	Reason      string `json:"reason"`
+}
+
+// contactImportRow is a mapped import row
+type contactImportRow struct {
+	Row          int
+	PhoneNumber  string
+	Name         string
+	Tags         []string
+	CustomFields map[string]string
+}
+
+// contactRowReader reads import rows, as both csv.Reader and xlsx.Reader do
+type contactRowReader interface {
+	Read() ([]string, error)
+}
+
+// ImportContacts imports contacts from an uploaded CSV or XLSX file in the background.
+// The multipart form holds the file, a mapping field (a JSON object of column header ->
+// phone_number, name, tags or a custom field name) and an optional tags field (a JSON
+// array of tags added to every imported contact). Rows are matched to existing contacts
+// by normalized phone number; matches are updated rather than duplicated. Progress is
+// pushed to the uploader over the WebSocket and can be polled with GetContactImport.
+func (a *App) ImportContacts(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionImport) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	upload, err := a.readImportUpload(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errMediaTooLarge):
+			return r.SendErrorEnvelope(fasthttp.StatusRequestEntityTooLarge, "File is too large", nil, "")
+		case errors.Is(err, errInvalidUpload):
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid multipart form", nil, "")
+		}
+		a.Log.Error("Failed to save contacts file", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save file", nil, "")
+	}
+	if upload.LocalPath == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "file is required", nil, "")
+	}
+	// From here on the file belongs to the import job
+	queued := false
+	defer func() {
+		if !queued {
+			a.removeUpload(upload)
+		}
+	}()
+
+	format := "csv"
+	if upload.MimeType == xlsx.ContentType {
+		format = "xlsx"
+	}
+
+	var mapping sheets.Mapping
+	if err := json.Unmarshal([]byte(upload.Fields["mapping"]), &mapping); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid column mapping", nil, "")
+	}
+	headers, err := a.readContactImportHeaders(orgID, upload.LocalPath, format)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Invalid %s file", strings.ToUpper(format)), nil, "")
+	}
+	if err := validateContactMapping(mapping, headers); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var tags []string
+	if raw := strings.TrimSpace(upload.Fields["tags"]); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &tags); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "tags must be a JSON array of strings", nil, "")
+		}
+	}
+	tags = cleanContactTags(tags)
+	if len(tags) > maxContactImportTags {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("At most %d tags can be added", maxContactImportTags), nil, "")
+	}
+
+	columnMapping := make(models.JSONB, len(mapping))
+	for column, target := range mapping {
+		columnMapping[column] = target
+	}
+	jobTags := make(models.JSONBArray, len(tags))
+	for i, tag := range tags {
+		jobTags[i] = tag
+	}
+	job := models.ContactImport{
+		OrganizationID: orgID,
+		RequestedByID:  userID,
+		Filename:       upload.Filename,
+		Format:         format,
+		FilePath:       upload.LocalPath,
+		ColumnMapping:  columnMapping,
+		Tags:           jobTags,
+		Status:         models.ContactImportStatusProcessing,
+		Rejected:       models.JSONBArray{},
+	}
+	if err := a.DB.Create(&job).Error; err != nil {
+		a.Log.Error("Failed to create contact import", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start import", nil, "")
+	}
+	queued = true
+
+	jobID := job.ID
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.runContactImport(jobID)
+	}()
+
+	return r.SendEnvelope(job)
+}
+
+// GetContactImport returns the progress of a contact import
+func (a *App) GetContactImport(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionImport) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid import ID", nil, "")
+	}
+
+	var job models.ContactImport
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&job).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Import not found", nil, "")
+	}
+
+	if job.Status == models.ContactImportStatusProcessing && job.UpdatedAt.Before(time.Now().Add(-contactImportStallTimeout)) {
+		job.Status = models.ContactImportStatusFailed
+		job.Error = "Import was interrupted, upload the file again to import the remaining rows"
+		a.finishContactImport(&job)
+	}
+
+	return r.SendEnvelope(job)
+}
+
+// validateContactMapping checks that every mapped column exists, a column is mapped to
+// phone_number and custom field names are usable
+func validateContactMapping(mapping sheets.Mapping, headers []string) error {
+	if err := mapping.Validate(headers); err != nil {
+		return err
+	}
+	targets := make(map[string]string, len(mapping))
+	for column, target := range mapping {
+		if target == "" {
+			continue
+		}
+		if len(target) > maxContactFieldNameLength {
+			return fmt.Errorf("custom field name %q is too long", target)
+		}
+		if other, ok := targets[target]; ok {
+			return fmt.Errorf("columns %q and %q are both mapped to %s", other, column, target)
+		}
+		targets[target] = column
+	}
+	return nil
+}
+
+// cleanContactTags trims tags and drops empty and repeated ones
+func cleanContactTags(tags []string) []string {
+	cleaned := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(cleaned, tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return cleaned
+}
+
+// mapContactRow turns one data row into a contact import row. row is the 1-based row
+// number.
+func mapContactRow(mapping sheets.Mapping, headers, values []string, row int) contactImportRow {
+	rec := contactImportRow{Row: row, CustomFields: make(map[string]string)}
+	for col, header := range headers {
+		target, ok := mapping[header]
+		if !ok || target == "" || col >= len(values) {
+			continue
+		}
+		value := strings.TrimSpace(values[col])
+		switch target {
+		case ContactFieldPhoneNumber:
+			rec.PhoneNumber = value
+		case ContactFieldName:
+			rec.Name = value
+		case ContactFieldTags:
+			rec.Tags = cleanContactTags(strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }))
+		default:
+			if value != "" {
+				rec.CustomFields[target] = value
+			}
+		}
+	}
+	return rec
+}
+
+// openContactImport opens a stored import file and returns a row reader over it, its
+// header row and a function closing the file
+func (a *App) openContactImport(orgID uuid.UUID, path, format string) (contactRowReader, []string, func(), error) {
+	if format != "xlsx" {
+		f, err := a.openMediaFile(orgID, path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		reader, headers, err := newImportCSVReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, nil, err
+		}
+		return reader, headers, func() { _ = f.Close() }, nil
+	}
+
+	f, size, err := a.openMediaFileAt(orgID, path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	reader, err := xlsx.NewReader(f, size)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, nil, err
+	}
+	closeFn := func() {
+		_ = reader.Close()
+		_ = f.Close()
+	}
+	headers, err := reader.Read()
+	if err != nil {
+		closeFn()
+		if err == io.EOF {
+			return nil, nil, nil, xlsx.ErrInvalidWorkbook
+		}
+		return nil, nil, nil, err
+	}
+	for i, h := range headers {
+		headers[i] = strings.TrimSpace(h)
+	}
+	return reader, headers, closeFn, nil
+}
+
+// readContactImportHeaders returns the header row of a stored import file
+func (a *App) readContactImportHeaders(orgID uuid.UUID, path, format string) ([]string, error) {
+	_, headers, closeFn, err := a.openContactImport(orgID, path, format)
+	if err != nil {
+		return nil, err
+	}
+	closeFn()
+	return headers, nil
+}
+
+// runContactImport imports the rows of a stored file, then records the outcome and
+// removes the file
+func (a *App) runContactImport(importID uuid.UUID) {
+	var job models.ContactImport
+	if err := a.DB.Where("id = ?", importID).First(&job).Error; err != nil {
+		a.Log.Error("Failed to load contact import", "error", err, "import_id", importID)
+		return
+	}
+
+	err := a.processContactImport(&job)
+	var parseErr *csv.ParseError
+	switch {
+	case err == nil:
+		job.Status = models.ContactImportStatusCompleted
+	case errors.As(err, &parseErr):
+		job.Status = models.ContactImportStatusFailed
+		job.Error = fmt.Sprintf("Invalid CSV on line %d: %v", parseErr.Line, parseErr.Err)
+	case errors.Is(err, xlsx.ErrInvalidWorkbook):
+		job.Status = models.ContactImportStatusFailed
+		job.Error = "Invalid XLSX file"
+	default:
+		a.Log.Error("Contact import failed", "error", err, "import_id", job.ID)
+		job.Status = models.ContactImportStatusFailed
+		job.Error = "Failed to import contacts"
+	}
+	a.finishContactImport(&job)
+
+	a.Log.Info("Contact import finished", "import_id", job.ID, "status", job.Status, "rows", job.ProcessedRows,
+		"created", job.CreatedCount, "updated", job.UpdatedCount, "duplicates", job.DuplicateCount, "rejected", job.RejectedCount)
+}
+
+// processContactImport reads the import file row by row and saves the contacts in
+// batches, so memory use doesn't grow with the file
+func (a *App) processContactImport(job *models.ContactImport) error {
+	reader, headers, closeFn, err := a.openContactImport(job.OrganizationID, job.FilePath, job.Format)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	mapping := sheets.MappingFromJSON(job.ColumnMapping)
+	jobTags := make([]string, 0, len(job.Tags))
+	for _, tag := range job.Tags {
+		if s, ok := tag.(string); ok {
+			jobTags = append(jobTags, s)
+		}
+	}
+	// Numbers seen earlier in the file, so a repeated row doesn't overwrite the first
+	seen := make(map[string]bool)
+
+	batch := make([]contactImportRow, 0, contactImportBatchSize)
+	flush := func() error {
+		if err := a.saveContactImportBatch(job, batch, jobTags, seen); err != nil {
+			return err
+		}
+		job.ProcessedRows += len(batch)
+		batch = batch[:0]
+
+		if err := a.DB.Model(job).Updates(map[string]interface{}{
+			"processed_rows":  job.ProcessedRows,
+			"created_count":   job.CreatedCount,
+			"updated_count":   job.UpdatedCount,
+			"duplicate_count": job.DuplicateCount,
+			"rejected_count":  job.RejectedCount,
+			"rejected":        job.Rejected,
+		}).Error; err != nil {
+			return err
+		}
+		a.notifyContactImport(job)
+		return nil
+	}
+
+	for row := 2; ; row++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, mapContactRow(mapping, headers, values, row))
+		if len(batch) == contactImportBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// saveContactImportBatch normalizes a batch of rows, updates the contacts that already
+// exist and creates the rest
+func (a *App) saveContactImportBatch(job *models.ContactImport, rows []contactImportRow, jobTags []string, seen map[string]bool) error {
+	reject := func(row contactImportRow, reason string) {
+		job.RejectedCount++
+		if len(job.Rejected) < contactImportRejectedSample {
+			job.Rejected = append(job.Rejected, RejectedContactRow{Row: row.Row, PhoneNumber: row.PhoneNumber, Reason: reason})
+		}
+	}
+
+	valid := make([]contactImportRow, 0, len(rows))
+	lookup := make([]string, 0, 2*len(rows))
+	for _, row := range rows {
+		if row.PhoneNumber == "" {
+			reject(row, "phone number is missing")
+			continue
+		}
+		number, err := phone.Normalize(row.PhoneNumber)
+		if err != nil {
+			reject(row, err.Error())
+			continue
+		}
+		if seen[number] {
+			job.DuplicateCount++
+			continue
+		}
+		seen[number] = true
+		row.PhoneNumber = number
+		valid = append(valid, row)
+		// Legacy contacts may have been stored with a leading +
+		lookup = append(lookup, number, "+"+number)
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []models.Contact
+		if err := tx.Where("organization_id = ? AND phone_number IN ?", job.OrganizationID, lookup).
+			Order("created_at ASC").Find(&existing).Error; err != nil {
+			return err
+		}
+		byNumber := make(map[string]*models.Contact, len(existing))
+		for i := range existing {
+			number := strings.TrimPrefix(existing[i].PhoneNumber, "+")
+			if _, ok := byNumber[number]; !ok {
+				byNumber[number] = &existing[i]
+			}
+		}
+
+		created := make([]models.Contact, 0, len(valid))
+		for _, row := range valid {
+			tags := append(slices.Clone(row.Tags), jobTags...)
+			contact, ok := byNumber[row.PhoneNumber]
+			if !ok {
+				created = append(created, models.Contact{
+					BaseModel:      models.BaseModel{ID: uuid.New()},
+					OrganizationID: job.OrganizationID,
+					PhoneNumber:    row.PhoneNumber,
+					ProfileName:    row.Name,
+					Tags:           mergeContactTags(nil, tags),
+					Metadata:       mergeContactFields(nil, row.CustomFields),
+				})
+				continue
+			}
+
+			updates := map[string]interface{}{}
+			if row.Name != "" && row.Name != contact.ProfileName {
+				updates["profile_name"] = row.Name
+			}
+			if merged := mergeContactTags(contact.Tags, tags); len(merged) != len(contact.Tags) {
+				updates["tags"] = merged
+			}
+			if len(row.CustomFields) > 0 {
+				updates["metadata"] = mergeContactFields(contact.Metadata, row.CustomFields)
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(contact).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			job.UpdatedCount++
+		}
+
+		if len(created) > 0 {
+			// A contact created by an inbound message since the lookup is left as it is
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&created)
+			if result.Error != nil {
+				return result.Error
+			}
+			job.CreatedCount += int(result.RowsAffected)
+			job.DuplicateCount += len(created) - int(result.RowsAffected)
+		}
+		return nil
+	})
+}
+
+// mergeContactTags returns existing with any new tags appended
+func mergeContactTags(existing models.JSONBArray, tags []string) models.JSONBArray {
+	merged := make(models.JSONBArray, 0, len(existing)+len(tags))
+	merged = append(merged, existing...)
+	for _, tag := range tags {
+		if !slices.Contains(merged, interface{}(tag)) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// mergeContactFields returns metadata with the imported custom fields set
+func mergeContactFields(metadata models.JSONB, fields map[string]string) models.JSONB {
+	merged := make(models.JSONB, len(metadata)+len(fields))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return merged
+}
+
+// finishContactImport records the final state of an import, removes its file and
+// notifies the uploader
+func (a *App) finishContactImport(job *models.ContactImport) {
+	if job.FilePath != "" {
+		if err := os.Remove(a.mediaFullPath(job.FilePath)); err != nil && !os.IsNotExist(err) {
+			a.Log.Warn("Failed to remove contact import file", "error", err, "path", job.FilePath)
+		}
+	}
+
+	now := time.Now()
+	job.CompletedAt = &now
+	job.FilePath = ""
+	if err := a.DB.Model(job).Updates(map[string]interface{}{
+		"status":          job.Status,
+		"error":           job.Error,
+		"processed_rows":  job.ProcessedRows,
+		"created_count":   job.CreatedCount,
+		"updated_count":   job.UpdatedCount,
+		"duplicate_count": job.DuplicateCount,
+		"rejected_count":  job.RejectedCount,
+		"rejected":        job.Rejected,
+		"file_path":       "",
+		"completed_at":    now,
+	}).Error; err != nil {
+		a.Log.Error("Failed to update contact import", "error", err, "import_id", job.ID)
+	}
+	a.notifyContactImport(job)
+}
+
+// notifyContactImport pushes an import's progress to the uploader
+func (a *App) notifyContactImport(job *models.ContactImport) {
+	if a.WSHub == nil {
+		return
+	}
+	a.WSHub.BroadcastToUser(job.OrganizationID, job.RequestedByID, websocket.WSMessage{
+		Type:    websocket.TypeContactImport,
+		Payload: job,
+	})
+}
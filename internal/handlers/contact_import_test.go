@@ -0,0 +1,244 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/xlsx"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// newContactImportRequest builds a multipart contact import request
+func newContactImportRequest(t *testing.T, filename, mapping, tags string, file []byte) *fastglue.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	require.NoError(t, w.WriteField("mapping", mapping))
+	if tags != "" {
+		require.NoError(t, w.WriteField("tags", tags))
+	}
+	part, err := w.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(file)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req := testutil.NewRequest(t)
+	req.RequestCtx.Request.Header.SetMethod("POST")
+	req.RequestCtx.Request.Header.SetContentType(w.FormDataContentType())
+	req.RequestCtx.Request.SetBody(body.Bytes())
+	return req
+}
+
+// runContactImport starts an import and waits for it to finish
+func runContactImport(t *testing.T, app *handlers.App, orgID, userID uuid.UUID, req *fastglue.Request) models.ContactImport {
+	t.Helper()
+
+	setAuthContext(req, orgID, userID)
+	require.NoError(t, app.ImportContacts(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(req.RequestCtx.Response.Body()))
+	var created struct {
+		Data models.ContactImport `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, models.ContactImportStatusProcessing, created.Data.Status)
+
+	var job models.ContactImport
+	require.Eventually(t, func() bool {
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, orgID, userID)
+		testutil.SetPathParam(req, "id", created.Data.ID.String())
+		require.NoError(t, app.GetContactImport(req))
+		var resp struct {
+			Data models.ContactImport `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		job = resp.Data
+		return job.Status != models.ContactImportStatusProcessing
+	}, 30*time.Second, 50*time.Millisecond)
+	return job
+}
+
+func TestApp_ImportContacts_CSV(t *testing.T) {
+	app := testApp(t)
+	app.Config.Storage.LocalPath = t.TempDir()
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("contact-import"), "password", &role.ID, true)
+
+	// An existing contact stored with a legacy + prefix is updated, not duplicated
+	existing := &models.Contact{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: org.ID,
+		PhoneNumber:    "+14155550001",
+		ProfileName:    "Old Name",
+		Tags:           models.JSONBArray{"customer"},
+		Metadata:       models.JSONB{"city": "Paris", "timezone": "Europe/Paris"},
+	}
+	require.NoError(t, app.DB.Create(existing).Error)
+
+	// More rows than one batch, with a duplicate and an invalid row
+	var file strings.Builder
+	file.WriteString("\ufeffPhone,Name,Labels,City,Ignored\n")
+	for i := 0; i < 1200; i++ {
+		fmt.Fprintf(&file, "+1 415 555 %04d,Contact %d,\"lead, web\",Berlin,x\n", i, i)
+	}
+	file.WriteString("+14155550002,Duplicate,,,\n")
+	file.WriteString("not-a-number,Invalid,,,\n")
+
+	req := newContactImportRequest(t, "contacts.csv",
+		`{"Phone":"phone_number","Name":"name","Labels":"tags","City":"city"}`, `["spring-expo"]`, []byte(file.String()))
+	job := runContactImport(t, app, org.ID, user.ID, req)
+
+	assert.Equal(t, models.ContactImportStatusCompleted, job.Status, job.Error)
+	assert.Equal(t, "csv", job.Format)
+	assert.Equal(t, 1202, job.ProcessedRows)
+	assert.Equal(t, 1199, job.CreatedCount)
+	assert.Equal(t, 1, job.UpdatedCount)
+	assert.Equal(t, 1, job.DuplicateCount)
+	assert.Equal(t, 1, job.RejectedCount)
+	require.Len(t, job.Rejected, 1)
+
+	var count int64
+	app.DB.Model(&models.Contact{}).Where("organization_id = ?", org.ID).Count(&count)
+	assert.Equal(t, int64(1200), count)
+
+	var created models.Contact
+	require.NoError(t, app.DB.Where("organization_id = ? AND phone_number = ?", org.ID, "14155550042").First(&created).Error)
+	assert.Equal(t, "Contact 42", created.ProfileName)
+	assert.Equal(t, models.JSONBArray{"lead", "web", "spring-expo"}, created.Tags)
+	assert.Equal(t, "Berlin", created.Metadata["city"])
+
+	var updated models.Contact
+	require.NoError(t, app.DB.First(&updated, "id = ?", existing.ID).Error)
+	assert.Equal(t, "Contact 1", updated.ProfileName)
+	assert.Equal(t, models.JSONBArray{"customer", "lead", "web", "spring-expo"}, updated.Tags)
+	assert.Equal(t, "Berlin", updated.Metadata["city"])
+	assert.Equal(t, "Europe/Paris", updated.Metadata["timezone"])
+}
+
+func TestApp_ImportContacts_XLSX(t *testing.T) {
+	app := testApp(t)
+	app.Config.Storage.LocalPath = t.TempDir()
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("contact-import-xlsx"), "password", &role.ID, true)
+
+	var file bytes.Buffer
+	w, err := xlsx.NewWriter(&file, "Contacts")
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]interface{}{"Mobile", "Full Name"}))
+	require.NoError(t, w.Write([]interface{}{int64(447700900123), "Ada Lovelace"}))
+	require.NoError(t, w.Close())
+
+	req := newContactImportRequest(t, "contacts.XLSX", `{"Mobile":"phone_number","Full Name":"name"}`, "", file.Bytes())
+	job := runContactImport(t, app, org.ID, user.ID, req)
+	assert.Equal(t, models.ContactImportStatusCompleted, job.Status, job.Error)
+	assert.Equal(t, "xlsx", job.Format)
+	assert.Equal(t, 1, job.CreatedCount)
+
+	var contact models.Contact
+	require.NoError(t, app.DB.Where("organization_id = ? AND phone_number = ?", org.ID, "447700900123").First(&contact).Error)
+	assert.Equal(t, "Ada Lovelace", contact.ProfileName)
+}
+
+func TestApp_ImportContacts_Invalid(t *testing.T) {
+	app := testApp(t)
+	app.Config.Storage.LocalPath = t.TempDir()
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("contact-import-invalid"), "password", &role.ID, true)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("contact-import-agent"), "password", nil, true)
+
+	req := newContactImportRequest(t, "contacts.csv", `{"Phone":"phone_number"}`, "", []byte("Phone\n+14155550000\n"))
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.ImportContacts(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	for _, tc := range []struct{ filename, mapping, tags, file string }{
+		{"contacts.csv", `not json`, "", "Phone\n+14155550000\n"},
+		{"contacts.csv", `{"Name":"name"}`, "", "Phone,Name\n+14155550000,Jane\n"},
+		{"contacts.csv", `{"Phone":"phone_number","Name":"phone_number"}`, "", "Phone,Name\n+14155550000,Jane\n"},
+		{"contacts.csv", `{"Phone":"phone_number"}`, `vip`, "Phone\n+14155550000\n"},
+		{"contacts.xlsx", `{"Phone":"phone_number"}`, "", "Phone\n+14155550000\n"},
+	} {
+		req := newContactImportRequest(t, tc.filename, tc.mapping, tc.tags, []byte(tc.file))
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.ImportContacts(req))
+		assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req), tc.mapping)
+	}
+
+	var count int64
+	app.DB.Model(&models.ContactImport{}).Where("organization_id = ?", org.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestApp_ExportContacts(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("contact-export"), "password", &role.ID, true)
+
+	for i, tags := range []models.JSONBArray{{"vip"}, {}} {
+		require.NoError(t, app.DB.Create(&models.Contact{
+			BaseModel:      models.BaseModel{ID: uuid.New()},
+			OrganizationID: org.ID,
+			PhoneNumber:    fmt.Sprintf("1415555010%d", i),
+			ProfileName:    fmt.Sprintf("Export %d", i),
+			Tags:           tags,
+			Metadata:       models.JSONB{"city": "Lisbon"},
+		}).Error)
+	}
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	req.RequestCtx.QueryArgs().Set("tag", "vip")
+	require.NoError(t, app.ExportContacts(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(req.RequestCtx.Response.Body()))
+	assert.Contains(t, string(req.RequestCtx.Response.Header.Peek("Content-Disposition")), ".csv")
+
+	rows, err := csv.NewReader(bytes.NewReader(req.RequestCtx.Response.Body())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"Phone Number", "Name", "Tags"}, rows[0][:3])
+	assert.Equal(t, "city", rows[0][len(rows[0])-1])
+	assert.Equal(t, []string{"14155550100", "Export 0", "vip"}, rows[1][:3])
+	assert.Equal(t, "Lisbon", rows[1][len(rows[1])-1])
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	req.RequestCtx.QueryArgs().Set("format", "xlsx")
+	require.NoError(t, app.ExportContacts(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, xlsx.ContentType, string(req.RequestCtx.Response.Header.ContentType()))
+
+	body := req.RequestCtx.Response.Body()
+	reader, err := xlsx.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+	var count int
+	for {
+		if _, err := reader.Read(); err != nil {
+			break
+		}
+		count++
+	}
+	assert.Equal(t, 3, count)
+
+	agent := createTestUser(t, app, org.ID, uniqueEmail("contact-export-agent"), "password", nil, true)
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.ExportContacts(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// maxContactMemoryLength caps stored memory so it can't crowd out the system prompt
+	maxContactMemoryLength = 4000
+	// memoryTranscriptLimit is how many session messages are summarized into memory
+	memoryTranscriptLimit = 50
+)
+
+// contactMemoryPrompt instructs the AI provider to fold a finished session into the contact's memory
+const contactMemoryPrompt = `You maintain long-term memory about a customer for a customer support assistant.
+You are given the existing memory and the transcript of the latest conversation.
+Return the updated memory as a short bullet list of durable facts and preferences
+(name, language, products owned, preferences, open issues). Drop anything transient
+or no longer true. Never include payment details, passwords or one-time codes.
+Return only the bullet list, or an empty response if there is nothing worth remembering.`
+
+// ContactMemoryRequest represents a manual edit of a contact's AI memory
+type ContactMemoryRequest struct {
+	Facts string `json:"facts"`
+}
+
+// ContactMemoryResponse represents a contact's AI memory
+type ContactMemoryResponse struct {
+	ContactID    uuid.UUID  `json:"contact_id"`
+	Facts        string     `json:"facts"`
+	SummarizedAt *time.Time `json:"summarized_at,omitempty"`
+	EditedBy     *uuid.UUID `json:"edited_by,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+// GetContactMemory returns the AI memory stored for a contact
+func (a *App) GetContactMemory(r *fastglue.Request) error {
+	contact, err := a.contactFromPath(r, models.ActionRead)
+	if err != nil || contact == nil {
+		return err
+	}
+
+	resp := ContactMemoryResponse{ContactID: contact.ID}
+	var memory models.ContactMemory
+	if err := a.DB.Where("organization_id = ? AND contact_id = ?", contact.OrganizationID, contact.ID).First(&memory).Error; err == nil {
+		resp.Facts = memory.Facts
+		resp.SummarizedAt = memory.SummarizedAt
+		resp.EditedBy = memory.EditedBy
+		resp.UpdatedAt = &memory.UpdatedAt
+	}
+
+	return r.SendEnvelope(resp)
+}
+
+// UpdateContactMemory replaces a contact's AI memory with agent-edited facts
+func (a *App) UpdateContactMemory(r *fastglue.Request) error {
+	contact, err := a.contactFromPath(r, models.ActionWrite)
+	if err != nil || contact == nil {
+		return err
+	}
+
+	var req ContactMemoryRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Facts = strings.TrimSpace(req.Facts)
+	if len(req.Facts) > maxContactMemoryLength {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Memory cannot exceed %d characters", maxContactMemoryLength), nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	memory := models.ContactMemory{
+		OrganizationID: contact.OrganizationID,
+		ContactID:      contact.ID,
+	}
+	if err := a.DB.Where("organization_id = ? AND contact_id = ?", contact.OrganizationID, contact.ID).
+		Attrs(models.ContactMemory{BaseModel: models.BaseModel{ID: uuid.New()}}).
+		FirstOrCreate(&memory).Error; err != nil {
+		a.Log.Error("Failed to load contact memory", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update memory", nil, "")
+	}
+
+	if err := a.DB.Model(&memory).Updates(map[string]interface{}{
+		"facts":     req.Facts,
+		"edited_by": userID,
+	}).Error; err != nil {
+		a.Log.Error("Failed to update contact memory", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update memory", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Memory updated",
+		"facts":   req.Facts,
+	})
+}
+
+// DeleteContactMemory erases everything the AI remembers about a contact
+func (a *App) DeleteContactMemory(r *fastglue.Request) error {
+	contact, err := a.contactFromPath(r, models.ActionWrite)
+	if err != nil || contact == nil {
+		return err
+	}
+
+	// Hard delete: erased memory must not linger, and the contact can get a new one
+	if err := a.DB.Unscoped().Where("organization_id = ? AND contact_id = ?", contact.OrganizationID, contact.ID).
+		Delete(&models.ContactMemory{}).Error; err != nil {
+		a.Log.Error("Failed to erase contact memory", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to erase memory", nil, "")
+	}
+
+	a.Log.Info("Contact memory erased", "contact_id", contact.ID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Memory erased",
+	})
+}
+
+// contactFromPath checks permissions and loads the contact from the path. On failure it
+// sends the error response and returns a nil contact.
+func (a *App) contactFromPath(r *fastglue.Request, action string) (*models.Contact, error) {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, action) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+	if a.HasRestrictedDataAccess(userID, orgID) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, restrictedDataAccessMessage, nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	contactID, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var contact models.Contact
+	if err := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID).First(&contact).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+	return &contact, nil
+}
+
+// buildContactMemoryContext returns the memory section of the AI system prompt for a contact
+func (a *App) buildContactMemoryContext(orgID, contactID uuid.UUID) string {
+	var memory models.ContactMemory
+	if err := a.DB.Where("organization_id = ? AND contact_id = ?", orgID, contactID).First(&memory).Error; err != nil {
+		return ""
+	}
+	if strings.TrimSpace(memory.Facts) == "" {
+		return ""
+	}
+	return "## What you remember about this customer\n\n" + memory.Facts
+}
+
+// updateContactMemory summarizes the contact's most recent finished session into their
+// long-term memory. Each session is folded in at most once.
+func (a *App) updateContactMemory(settings *models.ChatbotSettings, contactID, currentSessionID uuid.UUID) {
+	var previous models.ChatbotSession
+	if err := a.DB.Where("organization_id = ? AND contact_id = ? AND id != ?", settings.OrganizationID, contactID, currentSessionID).
+		Order("last_activity_at DESC").First(&previous).Error; err != nil {
+		return
+	}
+
+	var memory models.ContactMemory
+	hasMemory := a.DB.Where("organization_id = ? AND contact_id = ?", settings.OrganizationID, contactID).First(&memory).Error == nil
+	if hasMemory && memory.LastSessionID != nil && *memory.LastSessionID == previous.ID {
+		return
+	}
+
+	history := a.getSessionHistory(previous.ID, memoryTranscriptLimit)
+	if len(history) == 0 {
+		return
+	}
+
+	var transcript strings.Builder
+	transcript.WriteString("Existing memory:\n")
+	if memory.Facts != "" {
+		transcript.WriteString(memory.Facts)
+	} else {
+		transcript.WriteString("(none)")
+	}
+	transcript.WriteString("\n\nLatest conversation:\n")
+	for _, msg := range history {
+		role := "Customer"
+		if msg.Direction == models.DirectionOutgoing {
+			role = "Assistant"
+		}
+		transcript.WriteString(role + ": " + msg.Message + "\n")
+	}
+
+	facts, err := a.completeAI(settings, contactMemoryPrompt, transcript.String())
+	if err != nil {
+		a.Log.Error("Failed to summarize contact memory", "error", err, "contact_id", contactID)
+		return
+	}
+	facts = strings.TrimSpace(facts)
+	if len(facts) > maxContactMemoryLength {
+		facts = facts[:maxContactMemoryLength]
+	}
+
+	now := time.Now()
+	if !hasMemory {
+		memory = models.ContactMemory{
+			BaseModel:      models.BaseModel{ID: uuid.New()},
+			OrganizationID: settings.OrganizationID,
+			ContactID:      contactID,
+		}
+		if err := a.DB.Create(&memory).Error; err != nil {
+			a.Log.Error("Failed to create contact memory", "error", err, "contact_id", contactID)
+			return
+		}
+	}
+	if err := a.DB.Model(&memory).Updates(map[string]interface{}{
+		"facts":           facts,
+		"last_session_id": previous.ID,
+		"summarized_at":   now,
+	}).Error; err != nil {
+		a.Log.Error("Failed to save contact memory", "error", err, "contact_id", contactID)
+		return
+	}
+
+	a.Log.Info("Contact memory updated", "contact_id", contactID, "session_id", previous.ID)
+}
+
+// completeAI runs a single prompt against the configured AI provider without
+// session history or AI contexts
+func (a *App) completeAI(settings *models.ChatbotSettings, systemPrompt, input string) (string, error) {
+	s := *settings
+	s.AI.SystemPrompt = systemPrompt
+	s.AI.IncludeHistory = false
+
+	switch s.AI.Provider {
+	case models.AIProviderOpenAI:
+		return a.generateOpenAIResponse(&s, nil, input, "")
+	case models.AIProviderAnthropic:
+		return a.generateAnthropicResponse(&s, nil, input, "")
+	case models.AIProviderGoogle:
+		return a.generateGoogleResponse(&s, nil, input, "")
+	default:
+		return "", fmt.Errorf("unsupported AI provider: %s", s.AI.Provider)
+	}
+}
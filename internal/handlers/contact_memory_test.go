@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// contactMemoryRequest calls a contact memory handler for contactID
+func contactMemoryRequest(t *testing.T, handler func(*fastglue.Request) error, orgID, userID uuid.UUID, contactID string, body any) *fastglue.Request {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, body)
+	setAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "id", contactID)
+	require.NoError(t, handler(req))
+	return req
+}
+
+func getContactMemory(t *testing.T, app *handlers.App, orgID, userID, contactID uuid.UUID) handlers.ContactMemoryResponse {
+	t.Helper()
+
+	req := contactMemoryRequest(t, app.GetContactMemory, orgID, userID, contactID.String(), nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp handlers.ContactMemoryResponse
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	return resp
+}
+
+func TestApp_ContactMemory(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("memory-admin"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	contact := createTestContact(t, app, org.ID)
+
+	// Contacts start with no memory
+	memory := getContactMemory(t, app, org.ID, admin.ID, contact.ID)
+	assert.Equal(t, contact.ID, memory.ContactID)
+	assert.Empty(t, memory.Facts)
+	assert.Nil(t, memory.UpdatedAt)
+
+	req := contactMemoryRequest(t, app.UpdateContactMemory, org.ID, admin.ID, contact.ID.String(),
+		handlers.ContactMemoryRequest{Facts: "  - Prefers Hindi\n- Owns the X200 blender  "})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	memory = getContactMemory(t, app, org.ID, admin.ID, contact.ID)
+	assert.Equal(t, "- Prefers Hindi\n- Owns the X200 blender", memory.Facts)
+	require.NotNil(t, memory.EditedBy)
+	assert.Equal(t, admin.ID, *memory.EditedBy)
+	assert.NotNil(t, memory.UpdatedAt)
+
+	// Edits replace the stored memory rather than adding another record
+	req = contactMemoryRequest(t, app.UpdateContactMemory, org.ID, admin.ID, contact.ID.String(),
+		handlers.ContactMemoryRequest{Facts: "- Prefers English"})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var count int64
+	app.DB.Model(&models.ContactMemory{}).Where("contact_id = ?", contact.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, "- Prefers English", getContactMemory(t, app, org.ID, admin.ID, contact.ID).Facts)
+
+	req = contactMemoryRequest(t, app.DeleteContactMemory, org.ID, admin.ID, contact.ID.String(), nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Empty(t, getContactMemory(t, app, org.ID, admin.ID, contact.ID).Facts)
+	app.DB.Unscoped().Model(&models.ContactMemory{}).Where("contact_id = ?", contact.ID).Count(&count)
+	assert.Zero(t, count)
+
+	// Memory can be written again after it was erased
+	req = contactMemoryRequest(t, app.UpdateContactMemory, org.ID, admin.ID, contact.ID.String(),
+		handlers.ContactMemoryRequest{Facts: "- Moved to Pune"})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, "- Moved to Pune", getContactMemory(t, app, org.ID, admin.ID, contact.ID).Facts)
+}
+
+func TestApp_ContactMemory_Validation(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("memory-invalid"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	contact := createTestContact(t, app, org.ID)
+
+	req := contactMemoryRequest(t, app.UpdateContactMemory, org.ID, admin.ID, contact.ID.String(),
+		handlers.ContactMemoryRequest{Facts: strings.Repeat("a", 4001)})
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Memory cannot exceed 4000 characters")
+
+	req = contactMemoryRequest(t, app.GetContactMemory, org.ID, admin.ID, "not-a-uuid", nil)
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Invalid contact ID")
+
+	req = contactMemoryRequest(t, app.GetContactMemory, org.ID, admin.ID, uuid.NewString(), nil)
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusNotFound, "Contact not found")
+
+	// Contacts of other organizations can't be read or edited
+	other := createTestOrg(t, app)
+	stranger := createTestContact(t, app, other.ID)
+	require.NoError(t, app.DB.Create(&models.ContactMemory{OrganizationID: other.ID, ContactID: stranger.ID, Facts: "- VIP"}).Error)
+	for _, handler := range []func(*fastglue.Request) error{app.GetContactMemory, app.UpdateContactMemory, app.DeleteContactMemory} {
+		req = contactMemoryRequest(t, handler, org.ID, admin.ID, stranger.ID.String(), handlers.ContactMemoryRequest{Facts: "- Overwritten"})
+		assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+	}
+	var memory models.ContactMemory
+	require.NoError(t, app.DB.Where("contact_id = ?", stranger.ID).First(&memory).Error)
+	assert.Equal(t, "- VIP", memory.Facts)
+}
+
+func TestApp_ContactMemory_Permissions(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	contact := createTestContact(t, app, org.ID)
+	require.NoError(t, app.DB.Create(&models.ContactMemory{OrganizationID: org.ID, ContactID: contact.ID, Facts: "- Prefers Hindi"}).Error)
+
+	// Reading memory needs contacts:read and editing or erasing it contacts:write
+	readerRole := createTransferTestRole(t, app.DB, org.ID, "memory-reader", []string{"contacts:read"})
+	reader := createTestUser(t, app, org.ID, uniqueEmail("memory-reader"), "password", &readerRole.ID, true)
+	assert.Equal(t, "- Prefers Hindi", getContactMemory(t, app, org.ID, reader.ID, contact.ID).Facts)
+
+	req := contactMemoryRequest(t, app.UpdateContactMemory, org.ID, reader.ID, contact.ID.String(), handlers.ContactMemoryRequest{Facts: "- Changed"})
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+	req = contactMemoryRequest(t, app.DeleteContactMemory, org.ID, reader.ID, contact.ID.String(), nil)
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	noneRole := createTransferTestRole(t, app.DB, org.ID, "memory-none", []string{"chat:read"})
+	none := createTestUser(t, app, org.ID, uniqueEmail("memory-none"), "password", &noneRole.ID, true)
+	req = contactMemoryRequest(t, app.GetContactMemory, org.ID, none.ID, contact.ID.String(), nil)
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	// Roles with restricted data access never see it
+	analystRole := createTransferTestRole(t, app.DB, org.ID, "memory-analyst", []string{"contacts:read"})
+	require.NoError(t, app.DB.Model(analystRole).Update("restrict_data_access", true).Error)
+	analyst := createTestUser(t, app, org.ID, uniqueEmail("memory-analyst"), "password", &analystRole.ID, true)
+	req = contactMemoryRequest(t, app.GetContactMemory, org.ID, analyst.ID, contact.ID.String(), nil)
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	var memory models.ContactMemory
+	require.NoError(t, app.DB.Where("contact_id = ?", contact.ID).First(&memory).Error)
+	assert.Equal(t, "- Prefers Hindi", memory.Facts)
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// engagementWindow is how far back activity counts towards the engagement score
+	engagementWindow = 90 * 24 * time.Hour
+	// dormantAfter is how long a contact can go without replying before being marked dormant
+	dormantAfter = 90 * 24 * time.Hour
+	// scoreBatchSize is the number of contacts scored per query batch
+	scoreBatchSize = 500
+)
+
+// contactActivity holds the raw engagement signals for a contact
+type contactActivity struct {
+	InboundCount      int64
+	LastInboundAt     *time.Time
+	CampaignResponses int64
+}
+
+// ContactScoreProcessor periodically recomputes contact engagement scores and lifecycle stages
+type ContactScoreProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewContactScoreProcessor creates a new contact score processor
+func NewContactScoreProcessor(app *App, interval time.Duration) *ContactScoreProcessor {
+	return &ContactScoreProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the scoring loop
+func (p *ContactScoreProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Contact score processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Contact score processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Contact score processor stopped")
+			return
+		case <-ticker.C:
+			p.scoreAllContacts(ctx)
+		}
+	}
+}
+
+// Stop stops the contact score processor
+func (p *ContactScoreProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// scoreAllContacts walks all contacts in batches and updates their score and stage.
+// Only contacts whose score or stage changed are written, one UPDATE per batch.
+func (p *ContactScoreProcessor) scoreAllContacts(ctx context.Context) {
+	now := time.Now()
+	lastID := uuid.Nil
+	updated := 0
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		var contacts []models.Contact
+		if err := p.app.DB.Select("id, organization_id, engagement_score, lifecycle_stage, peak_lifecycle_stage, lifecycle_stage_set_at, created_at").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(scoreBatchSize).
+			Find(&contacts).Error; err != nil {
+			p.app.Log.Error("Failed to load contacts for scoring", "error", err)
+			return
+		}
+		if len(contacts) == 0 {
+			break
+		}
+
+		activity := p.loadActivity(contacts, now.Add(-engagementWindow))
+		var changed []models.Contact
+		for _, c := range contacts {
+			act := activity[c.ID]
+			score := calculateEngagementScore(act, now)
+			stage := deriveLifecycleStage(&c, act.LastInboundAt, now)
+			peak := c.PeakLifecycleStage
+			if stage == models.LifecycleStageCustomer {
+				peak = models.LifecycleStageCustomer
+			}
+			if score == c.EngagementScore && stage == c.LifecycleStage && peak == c.PeakLifecycleStage {
+				continue
+			}
+			c.EngagementScore, c.LifecycleStage, c.PeakLifecycleStage = score, stage, peak
+			changed = append(changed, c)
+		}
+
+		if len(changed) > 0 {
+			if err := p.saveScores(changed, now); err != nil {
+				p.app.Log.Error("Failed to update contact scores", "error", err, "contacts", len(changed))
+			} else {
+				updated += len(changed)
+			}
+		}
+
+		lastID = contacts[len(contacts)-1].ID
+	}
+
+	p.app.Log.Info("Contact engagement scores updated", "contacts", updated, "duration", time.Since(now))
+}
+
+// saveScores writes the score and stage of a batch of contacts in a single UPDATE
+func (p *ContactScoreProcessor) saveScores(contacts []models.Contact, now time.Time) error {
+	rows := make([]string, len(contacts))
+	args := make([]interface{}, 0, len(contacts)*4+1)
+	args = append(args, now)
+	for i, c := range contacts {
+		rows[i] = "(?::uuid, ?::int, ?, ?)"
+		args = append(args, c.ID, c.EngagementScore, c.LifecycleStage, c.PeakLifecycleStage)
+	}
+
+	return p.app.DB.Exec(`UPDATE contacts SET
+			engagement_score = v.score,
+			lifecycle_stage = v.stage,
+			peak_lifecycle_stage = v.peak,
+			score_updated_at = ?
+		FROM (VALUES `+strings.Join(rows, ", ")+`) AS v(id, score, stage, peak)
+		WHERE contacts.id = v.id`, args...).Error
+}
+
+// loadActivity aggregates engagement signals for a batch of contacts
+func (p *ContactScoreProcessor) loadActivity(contacts []models.Contact, since time.Time) map[uuid.UUID]contactActivity {
+	ids := make([]uuid.UUID, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ID
+	}
+	activity := make(map[uuid.UUID]contactActivity, len(contacts))
+
+	// Inbound replies in the window plus the most recent reply overall
+	var inbound []struct {
+		ContactID     uuid.UUID
+		InboundCount  int64
+		LastInboundAt *time.Time
+	}
+	p.app.DB.Model(&models.Message{}).
+		Select("contact_id, COUNT(*) FILTER (WHERE created_at >= ?) as inbound_count, MAX(created_at) as last_inbound_at", since).
+		Where("contact_id IN ? AND direction = ?", ids, models.DirectionIncoming).
+		Group("contact_id").
+		Scan(&inbound)
+	for _, row := range inbound {
+		act := activity[row.ContactID]
+		act.InboundCount = row.InboundCount
+		act.LastInboundAt = row.LastInboundAt
+		activity[row.ContactID] = act
+	}
+
+	// Campaign responses: button taps on campaign messages and tracked link clicks
+	var responses []struct {
+		ContactID uuid.UUID
+		Total     int64
+	}
+	p.app.DB.Model(&models.ButtonClick{}).
+		Select("contact_id, COUNT(*) as total").
+		Where("contact_id IN ? AND campaign_id IS NOT NULL AND clicked_at >= ?", ids, since).
+		Group("contact_id").
+		Scan(&responses)
+	for _, row := range responses {
+		act := activity[row.ContactID]
+		act.CampaignResponses += row.Total
+		activity[row.ContactID] = act
+	}
+
+	responses = nil
+	p.app.DB.Table("link_clicks").
+		Select("short_links.contact_id as contact_id, COUNT(*) as total").
+		Joins("JOIN short_links ON short_links.id = link_clicks.short_link_id").
+		Where("short_links.contact_id IN ? AND link_clicks.clicked_at >= ?", ids, since).
+		Group("short_links.contact_id").
+		Scan(&responses)
+	for _, row := range responses {
+		act := activity[row.ContactID]
+		act.CampaignResponses += row.Total
+		activity[row.ContactID] = act
+	}
+
+	return activity
+}
+
+// calculateEngagementScore combines recency (40), reply frequency (30) and campaign responses (30) into 0-100
+func calculateEngagementScore(act contactActivity, now time.Time) int {
+	score := 0
+
+	if act.LastInboundAt != nil {
+		switch since := now.Sub(*act.LastInboundAt); {
+		case since <= 7*24*time.Hour:
+			score += 40
+		case since <= 30*24*time.Hour:
+			score += 25
+		case since <= engagementWindow:
+			score += 10
+		}
+	}
+
+	score += int(min(act.InboundCount*2, 30))
+	score += int(min(act.CampaignResponses*10, 30))
+
+	return min(score, 100)
+}
+
+// deriveLifecycleStage moves inactive contacts to dormant and revives dormant contacts that reply
+// again at their peak stage, so a returning customer stays a customer. A stage set by hand is kept
+// until the contact next writes in.
+func deriveLifecycleStage(c *models.Contact, lastInboundAt *time.Time, now time.Time) models.LifecycleStage {
+	if c.LifecycleStageSetAt != nil && (lastInboundAt == nil || !lastInboundAt.After(*c.LifecycleStageSetAt)) {
+		return c.LifecycleStage
+	}
+
+	lastActivity := c.CreatedAt
+	if lastInboundAt != nil && lastInboundAt.After(lastActivity) {
+		lastActivity = *lastInboundAt
+	}
+
+	if now.Sub(lastActivity) > dormantAfter {
+		return models.LifecycleStageDormant
+	}
+	if c.LifecycleStage == models.LifecycleStageCustomer || c.PeakLifecycleStage == models.LifecycleStageCustomer {
+		return models.LifecycleStageCustomer
+	}
+	return models.LifecycleStageLead
+}
+
+// ContactLifecycleRequest represents a manual lifecycle stage change
+type ContactLifecycleRequest struct {
+	LifecycleStage models.LifecycleStage `json:"lifecycle_stage"`
+}
+
+// UpdateContactLifecycle sets a contact's lifecycle stage (e.g. marking a lead as customer)
+func (a *App) UpdateContactLifecycle(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	contactID, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var req ContactLifecycleRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	switch req.LifecycleStage {
+	case models.LifecycleStageLead, models.LifecycleStageCustomer, models.LifecycleStageDormant:
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid lifecycle_stage. Use lead, customer or dormant", nil, "")
+	}
+
+	// Lead and customer also set the stage a dormant contact returns to
+	updates := map[string]interface{}{
+		"lifecycle_stage":        req.LifecycleStage,
+		"lifecycle_stage_set_at": time.Now(),
+	}
+	if req.LifecycleStage != models.LifecycleStageDormant {
+		updates["peak_lifecycle_stage"] = req.LifecycleStage
+	}
+	result := a.DB.Model(&models.Contact{}).
+		Where("id = ? AND organization_id = ?", contactID, orgID).
+		Updates(updates)
+	if result.Error != nil {
+		a.Log.Error("Failed to update lifecycle stage", "error", result.Error, "contact_id", contactID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update lifecycle stage", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"id":              contactID,
+		"lifecycle_stage": req.LifecycleStage,
+	})
+}
+
+// GetEngagementAnalytics returns the distribution of contacts by lifecycle stage and engagement score
+func (a *App) GetEngagementAnalytics(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var stages []struct {
+		LifecycleStage string `json:"lifecycle_stage"`
+		Count          int64  `json:"count"`
+	}
+	a.DB.Model(&models.Contact{}).
+		Select("lifecycle_stage, COUNT(*) as count").
+		Where("organization_id = ?", orgID).
+		Group("lifecycle_stage").
+		Order("count DESC").
+		Scan(&stages)
+
+	// Scores bucketed in bands of 20 (0-19, 20-39, ... 80-100)
+	var buckets []struct {
+		Bucket int
+		Count  int64
+	}
+	a.DB.Model(&models.Contact{}).
+		Select("LEAST(engagement_score / 20, 4) as bucket, COUNT(*) as count").
+		Where("organization_id = ?", orgID).
+		Group("bucket").
+		Order("bucket ASC").
+		Scan(&buckets)
+
+	type scoreBand struct {
+		Range string `json:"range"`
+		Count int64  `json:"count"`
+	}
+	bands := make([]scoreBand, 5)
+	for i := range bands {
+		upper := i*20 + 19
+		if i == 4 {
+			upper = 100
+		}
+		bands[i].Range = fmt.Sprintf("%d-%d", i*20, upper)
+	}
+	for _, b := range buckets {
+		if b.Bucket >= 0 && b.Bucket < len(bands) {
+			bands[b.Bucket].Count = b.Count
+		}
+	}
+
+	var avg struct {
+		Average float64
+	}
+	a.DB.Model(&models.Contact{}).
+		Select("COALESCE(AVG(engagement_score), 0) as average").
+		Where("organization_id = ?", orgID).
+		Scan(&avg)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"lifecycle_stages": stages,
+		"score_bands":      bands,
+		"average_score":    avg.Average,
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateEngagementScore_NoActivity(t *testing.T) {
+	assert.Equal(t, 0, calculateEngagementScore(contactActivity{}, time.Now()))
+}
+
+func TestCalculateEngagementScore_RecentActiveContact(t *testing.T) {
+	now := time.Now()
+	lastInbound := now.Add(-2 * 24 * time.Hour)
+	act := contactActivity{InboundCount: 5, LastInboundAt: &lastInbound, CampaignResponses: 1}
+	// 40 (recency) + 10 (frequency) + 10 (campaign responses)
+	assert.Equal(t, 60, calculateEngagementScore(act, now))
+}
+
+func TestCalculateEngagementScore_Capped(t *testing.T) {
+	now := time.Now()
+	lastInbound := now.Add(-time.Hour)
+	act := contactActivity{InboundCount: 500, LastInboundAt: &lastInbound, CampaignResponses: 50}
+	assert.Equal(t, 100, calculateEngagementScore(act, now))
+}
+
+func TestDeriveLifecycleStage(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-24 * time.Hour)
+	old := now.Add(-200 * 24 * time.Hour)
+	contact := func(stage, peak models.LifecycleStage, setAt *time.Time) *models.Contact {
+		return &models.Contact{LifecycleStage: stage, PeakLifecycleStage: peak, LifecycleStageSetAt: setAt, BaseModel: models.BaseModel{CreatedAt: old}}
+	}
+
+	// New contacts without replies start as leads
+	newContact := contact("", "", nil)
+	newContact.CreatedAt = recent
+	assert.Equal(t, models.LifecycleStageLead, deriveLifecycleStage(newContact, nil, now))
+
+	// Customers keep their stage while active
+	assert.Equal(t, models.LifecycleStageCustomer, deriveLifecycleStage(contact(models.LifecycleStageCustomer, "", nil), &recent, now))
+
+	// Inactive contacts become dormant
+	assert.Equal(t, models.LifecycleStageDormant, deriveLifecycleStage(contact(models.LifecycleStageCustomer, models.LifecycleStageCustomer, nil), &old, now))
+
+	// Dormant contacts that reply again return to their peak stage
+	assert.Equal(t, models.LifecycleStageLead, deriveLifecycleStage(contact(models.LifecycleStageDormant, "", nil), &recent, now))
+	assert.Equal(t, models.LifecycleStageCustomer, deriveLifecycleStage(contact(models.LifecycleStageDormant, models.LifecycleStageCustomer, nil), &recent, now))
+}
+
+func TestDeriveLifecycleStage_ManualStage(t *testing.T) {
+	now := time.Now()
+	setAt := now.Add(-48 * time.Hour)
+	before := now.Add(-72 * time.Hour)
+	after := now.Add(-time.Hour)
+	old := now.Add(-200 * 24 * time.Hour)
+
+	// A contact marked dormant by hand stays dormant despite earlier replies
+	c := &models.Contact{LifecycleStage: models.LifecycleStageDormant, PeakLifecycleStage: models.LifecycleStageCustomer, LifecycleStageSetAt: &setAt, BaseModel: models.BaseModel{CreatedAt: old}}
+	assert.Equal(t, models.LifecycleStageDormant, deriveLifecycleStage(c, &before, now))
+	assert.Equal(t, models.LifecycleStageDormant, deriveLifecycleStage(c, nil, now))
+
+	// ...until they write in again
+	assert.Equal(t, models.LifecycleStageCustomer, deriveLifecycleStage(c, &after, now))
+
+	// A lead set by hand on an inactive contact is not made dormant
+	c = &models.Contact{LifecycleStage: models.LifecycleStageLead, LifecycleStageSetAt: &setAt, BaseModel: models.BaseModel{CreatedAt: old}}
+	assert.Equal(t, models.LifecycleStageLead, deriveLifecycleStage(c, &old, now))
+}
@@ -0,0 +1,283 @@
+package handlers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// receiveText delivers a text message from phone to account and waits until it's stored
+func receiveText(t *testing.T, app *handlers.App, account *models.WhatsAppAccount, phone, text string) {
+	t.Helper()
+
+	var before int64
+	app.DB.Model(&models.Message{}).Where("whats_app_account = ? AND organization_id = ?", account.Name, account.OrganizationID).Count(&before)
+
+	body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+		"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+		"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+		"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"text","text":{"body":%q}}]}}]}]}`,
+		account.PhoneID, phone, phone, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()), text)
+	req := testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.Request.SetBody([]byte(body))
+	require.NoError(t, app.WebhookHandler(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	require.Eventually(t, func() bool {
+		var count int64
+		app.DB.Model(&models.Message{}).Where("whats_app_account = ? AND organization_id = ?", account.Name, account.OrganizationID).Count(&count)
+		return count > before
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+// createThreadTestAccount creates a WhatsApp account with its own phone number ID
+func createThreadTestAccount(t *testing.T, app *handlers.App, orgID uuid.UUID) *models.WhatsAppAccount {
+	t.Helper()
+
+	account := createTestAccount(t, app, orgID)
+	account.PhoneID = "phone-" + uuid.NewString()[:8]
+	require.NoError(t, app.DB.Model(account).Update("phone_id", account.PhoneID).Error)
+	return account
+}
+
+// contactMessages calls GetMessages for a contact with optional query parameters
+func contactMessages(t *testing.T, app *handlers.App, orgID, userID, contactID uuid.UUID, query map[string]string) (int, []handlers.MessageResponse) {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "id", contactID.String())
+	for k, v := range query {
+		testutil.SetQueryParam(req, k, v)
+	}
+	require.NoError(t, app.GetMessages(req))
+	status := testutil.GetResponseStatusCode(req)
+	if status != fasthttp.StatusOK {
+		return status, nil
+	}
+
+	var resp struct {
+		Messages []handlers.MessageResponse `json:"messages"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	return status, resp.Messages
+}
+
+func messageContents(messages []handlers.MessageResponse) []string {
+	contents := make([]string, len(messages))
+	for i, m := range messages {
+		if content, ok := m.Content.(map[string]any); ok {
+			contents[i], _ = content["body"].(string)
+		}
+	}
+	return contents
+}
+
+func TestApp_ContactSharedAcrossAccounts(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	sales := createThreadTestAccount(t, app, org.ID)
+	support := createThreadTestAccount(t, app, org.ID)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("threads-admin"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	phone := "1415555" + fmt.Sprint(time.Now().UnixNano()%10000)
+
+	receiveText(t, app, sales, phone, "Do you ship abroad?")
+	receiveText(t, app, support, phone, "My order is late")
+
+	// Both numbers share one contact
+	var contacts []models.Contact
+	require.NoError(t, app.DB.Where("organization_id = ? AND phone_number = ?", org.ID, phone).Find(&contacts).Error)
+	require.Len(t, contacts, 1)
+	contact := contacts[0]
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.GetContact(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var detail handlers.ContactResponse
+	testutil.ParseEnvelopeResponse(t, req, &detail)
+	assert.ElementsMatch(t, []string{sales.Name, support.Name}, detail.WhatsAppAccounts)
+
+	// The unified history has both threads, each message labelled with its number
+	status, messages := contactMessages(t, app, org.ID, admin.ID, contact.ID, nil)
+	require.Equal(t, fasthttp.StatusOK, status)
+	require.Len(t, messages, 2)
+	assert.Equal(t, []string{"Do you ship abroad?", "My order is late"}, messageContents(messages))
+	assert.Equal(t, sales.Name, messages[0].WhatsAppAccount)
+	assert.Equal(t, support.Name, messages[1].WhatsAppAccount)
+
+	// A single number's thread
+	status, messages = contactMessages(t, app, org.ID, admin.ID, contact.ID, map[string]string{"whatsapp_account": support.Name})
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, []string{"My order is late"}, messageContents(messages))
+}
+
+func TestApp_ContactSharedAcrossAccounts_LegacyPlusPrefix(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createThreadTestAccount(t, app, org.ID)
+	phone := "1415556" + fmt.Sprint(time.Now().UnixNano()%10000)
+
+	legacy := &models.Contact{OrganizationID: org.ID, PhoneNumber: "+" + phone, ProfileName: "Sam"}
+	require.NoError(t, app.DB.Create(legacy).Error)
+
+	// Messages to the number are filed under the existing contact
+	receiveText(t, app, account, phone, "Hi again")
+	var count int64
+	app.DB.Model(&models.Contact{}).Where("organization_id = ? AND phone_number IN ?", org.ID, []string{phone, "+" + phone}).Count(&count)
+	assert.Equal(t, int64(1), count)
+	app.DB.Model(&models.Message{}).Where("contact_id = ?", legacy.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// Contacts in other organizations are separate
+	other := createTestOrg(t, app)
+	otherAccount := createThreadTestAccount(t, app, other.ID)
+	receiveText(t, app, otherAccount, phone, "Hello other org")
+	app.DB.Model(&models.Contact{}).Where("organization_id = ? AND phone_number = ?", other.ID, phone).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestApp_GetMessages_AccessChecks(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createThreadTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	role := createTransferTestRole(t, app.DB, org.ID, "thread-viewer", []string{"chat:read"})
+	viewer := createTestUser(t, app, org.ID, uniqueEmail("threads-viewer"), "password", &role.ID, true)
+
+	// Without contacts:read only assigned contacts' threads are visible
+	status, _ := contactMessages(t, app, org.ID, viewer.ID, contact.ID, map[string]string{"whatsapp_account": account.Name})
+	assert.Equal(t, fasthttp.StatusNotFound, status)
+
+	require.NoError(t, app.DB.Model(contact).Update("assigned_user_id", viewer.ID).Error)
+	status, _ = contactMessages(t, app, org.ID, viewer.ID, contact.ID, map[string]string{"whatsapp_account": account.Name})
+	assert.Equal(t, fasthttp.StatusOK, status)
+
+	// Other organizations' contacts and malformed IDs
+	other := createTestOrg(t, app)
+	status, _ = contactMessages(t, app, other.ID, viewer.ID, contact.ID, nil)
+	assert.Equal(t, fasthttp.StatusNotFound, status)
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, viewer.ID)
+	testutil.SetPathParam(req, "id", "not-a-uuid")
+	require.NoError(t, app.GetMessages(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+}
+
+// createThreadMessage stores an incoming text message from contact on account
+func createThreadMessage(t *testing.T, app *handlers.App, account *models.WhatsAppAccount, contact *models.Contact, text string, createdAt time.Time) {
+	t.Helper()
+
+	msg := &models.Message{
+		BaseModel:       models.BaseModel{CreatedAt: createdAt},
+		OrganizationID:  account.OrganizationID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		Direction:       models.DirectionIncoming,
+		MessageType:     models.MessageTypeText,
+		Content:         text,
+		Status:          models.MessageStatusReceived,
+	}
+	require.NoError(t, app.DB.Create(msg).Error)
+}
+
+func TestApp_GetMessages_AllAccounts(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	sales := createThreadTestAccount(t, app, org.ID)
+	support := createThreadTestAccount(t, app, org.ID)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("threads-merge"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	phone := "1415557" + fmt.Sprint(time.Now().UnixNano()%10000)
+
+	// The same customer stored twice, once by a legacy import with a leading +
+	contact := &models.Contact{OrganizationID: org.ID, PhoneNumber: phone, ProfileName: "Sam", WhatsAppAccount: sales.Name}
+	legacy := &models.Contact{OrganizationID: org.ID, PhoneNumber: "+" + phone, ProfileName: "Sam", WhatsAppAccount: support.Name}
+	require.NoError(t, app.DB.Create(contact).Error)
+	require.NoError(t, app.DB.Create(legacy).Error)
+
+	// The same number in another organization is a different customer
+	other := createTestOrg(t, app)
+	otherAccount := createThreadTestAccount(t, app, other.ID)
+	stranger := &models.Contact{OrganizationID: other.ID, PhoneNumber: phone, ProfileName: "Sam"}
+	require.NoError(t, app.DB.Create(stranger).Error)
+
+	now := time.Now()
+	createThreadMessage(t, app, sales, contact, "Do you ship abroad?", now.Add(-3*time.Minute))
+	createThreadMessage(t, app, support, legacy, "My order is late", now.Add(-2*time.Minute))
+	createThreadMessage(t, app, otherAccount, stranger, "Not for this org", now.Add(-time.Minute))
+
+	// By default only the contact's own record is returned
+	status, messages := contactMessages(t, app, org.ID, admin.ID, contact.ID, nil)
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, []string{"Do you ship abroad?"}, messageContents(messages))
+
+	// all_accounts merges the linked records, each message labelled with its number
+	status, messages = contactMessages(t, app, org.ID, admin.ID, contact.ID, map[string]string{"all_accounts": "true"})
+	require.Equal(t, fasthttp.StatusOK, status)
+	require.Len(t, messages, 2)
+	assert.Equal(t, []string{"Do you ship abroad?", "My order is late"}, messageContents(messages))
+	assert.Equal(t, sales.Name, messages[0].WhatsAppAccount)
+	assert.Equal(t, support.Name, messages[1].WhatsAppAccount)
+
+	// It combines with the per-number filter, from either record
+	status, messages = contactMessages(t, app, org.ID, admin.ID, legacy.ID, map[string]string{"all_accounts": "true", "whatsapp_account": sales.Name})
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, []string{"Do you ship abroad?"}, messageContents(messages))
+
+	// The contact lists every number across its linked records
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.GetContact(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var detail handlers.ContactResponse
+	testutil.ParseEnvelopeResponse(t, req, &detail)
+	assert.ElementsMatch(t, []string{sales.Name, support.Name}, detail.WhatsAppAccounts)
+}
+
+func TestApp_GetMessages_AllAccounts_AccessChecks(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createThreadTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	role := createTransferTestRole(t, app.DB, org.ID, "merge-viewer", []string{"chat:read"})
+	viewer := createTestUser(t, app, org.ID, uniqueEmail("threads-merge-viewer"), "password", &role.ID, true)
+	query := map[string]string{"all_accounts": "true"}
+
+	// Merging doesn't bypass the assignment check or organization scoping
+	status, _ := contactMessages(t, app, org.ID, viewer.ID, contact.ID, query)
+	assert.Equal(t, fasthttp.StatusNotFound, status)
+
+	other := createTestOrg(t, app)
+	status, _ = contactMessages(t, app, other.ID, viewer.ID, contact.ID, query)
+	assert.Equal(t, fasthttp.StatusNotFound, status)
+
+	require.NoError(t, app.DB.Model(contact).Update("assigned_user_id", viewer.ID).Error)
+	status, _ = contactMessages(t, app, org.ID, viewer.ID, contact.ID, query)
+	assert.Equal(t, fasthttp.StatusOK, status)
+}
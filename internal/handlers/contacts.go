@@ -4,18 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
-	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/shridarpatil/whatomate/internal/plugins"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
@@ -35,8 +35,18 @@ type ContactResponse struct {
 	LastMessagePreview string     `json:"last_message_preview"`
 	UnreadCount        int        `json:"unread_count"`
 	AssignedUserID     *uuid.UUID `json:"assigned_user_id,omitempty"`
-	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
+	EngagementScore    int        `json:"engagement_score"`
+	LifecycleStage     string     `json:"lifecycle_stage"`
+	WhatsAppAccount    string     `json:"whatsapp_account"`
+	OptedOut           bool       `json:"opted_out"`
+	OptedOutAt         *time.Time `json:"opted_out_at,omitempty"`
+	// Priority of the current conversation, set by agents, keyword rules or AI sentiment
+	Priority       models.ConversationPriority `json:"priority"`
+	PrioritySource models.PrioritySource       `json:"priority_source,omitempty"`
+	// WhatsAppAccounts lists every org number the contact has a thread with (contact detail only)
+	WhatsAppAccounts []string  `json:"whatsapp_accounts,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // MessageResponse represents a message for the frontend
@@ -49,14 +59,24 @@ type MessageResponse struct {
 	MediaURL         string               `json:"media_url,omitempty"`
 	MediaMimeType    string               `json:"media_mime_type,omitempty"`
 	MediaFilename    string               `json:"media_filename,omitempty"`
+	MediaID          string               `json:"media_id,omitempty"`
 	InteractiveData  models.JSONB         `json:"interactive_data,omitempty"`
+	FlowResponse     models.JSONB         `json:"flow_response,omitempty"`
+	Location         *SharedLocation      `json:"location,omitempty"`       // Location messages
+	Contacts         []SharedContact      `json:"contacts,omitempty"`       // Contacts messages
+	MediaRejected    string               `json:"media_rejected,omitempty"` // Why the inbound media policy rejected the file
 	Status           models.MessageStatus `json:"status"`
 	WAMID            string               `json:"wamid"`
 	Error            string               `json:"error_message"`
+	ErrorCode        int                  `json:"error_code,omitempty"`
+	ErrorCategory    string               `json:"error_category,omitempty"`
 	IsReply          bool                 `json:"is_reply"`
 	ReplyToMessageID *string              `json:"reply_to_message_id,omitempty"`
 	ReplyToMessage   *ReplyPreview        `json:"reply_to_message,omitempty"`
 	Reactions        []ReactionInfo       `json:"reactions,omitempty"`
+	WhatsAppAccount  string               `json:"whatsapp_account"`
+	StatusHistory    []MessageStatusEntry `json:"status_history,omitempty"`
+	Queue            *MessageQueueState   `json:"queue,omitempty"` // Pending messages still in the send queue
 	CreatedAt        time.Time            `json:"created_at"`
 	UpdatedAt        time.Time            `json:"updated_at"`
 }
@@ -95,19 +115,34 @@ func (a *App) ListContacts(r *fastglue.Request) error {
 	}
 	offset := (page - 1) * limit
 
+	// Mask phone numbers, and contact details for restricted roles
+	mask := a.dataMaskFor(orgID, userID)
+
 	var contacts []models.Contact
 	query := a.ScopeToOrg(a.DB, userID, orgID)
 
 	// Users without contacts:read permission can only see contacts assigned to them
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
 		query = query.Where("assigned_user_id = ?", userID)
 	}
 
-	if search != "" {
+	// Restricted roles can't look contacts up by name or number
+	if search != "" && !mask.restricted {
 		searchPattern := "%" + search + "%"
 		query = query.Where("phone_number LIKE ? OR profile_name LIKE ?", searchPattern, searchPattern)
 	}
 
+	// Engagement filters
+	if stage := string(r.RequestCtx.QueryArgs().Peek("lifecycle_stage")); stage != "" {
+		query = query.Where("lifecycle_stage = ?", stage)
+	}
+	if minScore, err := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("min_score"))); err == nil {
+		query = query.Where("engagement_score >= ?", minScore)
+	}
+	if maxScore, err := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("max_score"))); err == nil {
+		query = query.Where("engagement_score <= ?", maxScore)
+	}
+
 	// Order by last message time (most recent first)
 	query = query.Order("last_message_at DESC NULLS LAST, created_at DESC")
 
@@ -119,9 +154,6 @@ func (a *App) ListContacts(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list contacts", nil, "")
 	}
 
-	// Check if phone masking is enabled
-	shouldMask := a.ShouldMaskPhoneNumbers(orgID)
-
 	// Convert to response format
 	response := make([]ContactResponse, len(contacts))
 	for i, c := range contacts {
@@ -140,25 +172,28 @@ func (a *App) ListContacts(r *fastglue.Request) error {
 			}
 		}
 
-		phoneNumber := c.PhoneNumber
-		profileName := c.ProfileName
-		if shouldMask {
-			phoneNumber = MaskPhoneNumber(phoneNumber)
-			profileName = MaskIfPhoneNumber(profileName)
-		}
+		profileName := mask.Name(c.ProfileName)
 
 		response[i] = ContactResponse{
 			ID:                 c.ID,
-			PhoneNumber:        phoneNumber,
+			PhoneNumber:        mask.Phone(c.PhoneNumber),
 			Name:               profileName,
 			ProfileName:        profileName,
+			AvatarURL:          contactAvatarURL(&c),
 			Status:             "active",
 			Tags:               tags,
-			CustomFields:       c.Metadata,
+			CustomFields:       mask.CustomFields(c.Metadata),
 			LastMessageAt:      c.LastMessageAt,
-			LastMessagePreview: c.LastMessagePreview,
+			LastMessagePreview: mask.Content(c.LastMessagePreview),
 			UnreadCount:        int(unreadCount),
 			AssignedUserID:     c.AssignedUserID,
+			EngagementScore:    c.EngagementScore,
+			LifecycleStage:     string(c.LifecycleStage),
+			WhatsAppAccount:    c.WhatsAppAccount,
+			OptedOut:           c.OptedOut,
+			OptedOutAt:         c.OptedOutAt,
+			Priority:           c.Priority,
+			PrioritySource:     c.PrioritySource,
 			CreatedAt:          c.CreatedAt,
 			UpdatedAt:          c.UpdatedAt,
 		}
@@ -188,7 +223,7 @@ func (a *App) GetContact(r *fastglue.Request) error {
 	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
 
 	// Users without contacts:read permission can only access their assigned contacts
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
 		query = query.Where("assigned_user_id = ?", userID)
 	}
 
@@ -196,6 +231,155 @@ func (a *App) GetContact(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
 	}
 
+	response := a.buildContactResponse(orgID, userID, &contact)
+
+	// Accounts the customer has talked to, for the inbox's per-number thread toggle
+	a.DB.Model(&models.Message{}).
+		Where("contact_id IN ? AND whats_app_account != ''", a.linkedContactIDs(orgID, &contact)).
+		Distinct().Order("whats_app_account").
+		Pluck("whats_app_account", &response.WhatsAppAccounts)
+
+	return r.SendEnvelope(response)
+}
+
+// ContactRequest represents the request body for creating or updating a contact. Fields
+// left out of an update are unchanged.
+type ContactRequest struct {
+	PhoneNumber     *string        `json:"phone_number"`
+	Name            *string        `json:"name"`
+	WhatsAppAccount *string        `json:"whatsapp_account"`
+	Tags            []string       `json:"tags"`
+	Metadata        map[string]any `json:"metadata"`
+}
+
+// CreateContact creates a contact. The phone number is normalized the same way as for
+// imports, and must be allowed by the organization's destination country rules.
+func (a *App) CreateContact(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req ContactRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.PhoneNumber == nil || strings.TrimSpace(*req.PhoneNumber) == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "phone_number is required", nil, "")
+	}
+
+	contact := models.Contact{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+	}
+	if err := a.applyContactRequest(r, orgID, &contact, &req); err != nil {
+		return nil
+	}
+
+	if err := a.DB.Create(&contact).Error; err != nil {
+		a.Log.Error("Failed to create contact", "error", err, "phone", contact.PhoneNumber)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create contact", nil, "")
+	}
+	a.Log.Info("Contact created from API", "contact_id", contact.ID, "phone", contact.PhoneNumber)
+	a.enrichContactWithScripts(&contact)
+	a.enrichContactWithProviders(&contact)
+	a.notifyPluginsContactCreated(plugins.ContactEvent{
+		OrganizationID: orgID,
+		ContactID:      contact.ID,
+		PhoneNumber:    contact.PhoneNumber,
+	})
+
+	return r.SendEnvelope(a.buildContactResponse(orgID, userID, &contact))
+}
+
+// UpdateContact updates a contact's number, name, account, tags or metadata. A new phone
+// number is normalized and checked like on create.
+func (a *App) UpdateContact(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	contactID, err := uuid.Parse(fmt.Sprint(r.RequestCtx.UserValue("id")))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+	var contact models.Contact
+	if err := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID).First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var req ContactRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := a.applyContactRequest(r, orgID, &contact, &req); err != nil {
+		return nil
+	}
+
+	if err := a.DB.Save(&contact).Error; err != nil {
+		a.Log.Error("Failed to update contact", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update contact", nil, "")
+	}
+
+	return r.SendEnvelope(a.buildContactResponse(orgID, userID, &contact))
+}
+
+// applyContactRequest copies the fields set in req onto contact, sending a 4xx and
+// returning an error if they are invalid
+func (a *App) applyContactRequest(r *fastglue.Request, orgID uuid.UUID, contact *models.Contact, req *ContactRequest) error {
+	if req.PhoneNumber != nil {
+		number, err := phone.Normalize(*req.PhoneNumber)
+		if err != nil {
+			_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+			return err
+		}
+		if err := a.getOrgCountryRestrictions(orgID).Check(number); err != nil {
+			_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+			return err
+		}
+		// Legacy contacts may have been stored with a leading +
+		var count int64
+		a.DB.Model(&models.Contact{}).
+			Where("organization_id = ? AND phone_number IN ? AND id != ?", orgID, []string{number, "+" + number}, contact.ID).
+			Count(&count)
+		if count > 0 {
+			_ = r.SendErrorEnvelope(fasthttp.StatusConflict, "A contact with this phone number already exists", nil, "")
+			return errors.New("duplicate phone number")
+		}
+		contact.PhoneNumber = number
+	}
+	if req.WhatsAppAccount != nil && *req.WhatsAppAccount != "" {
+		if _, err := a.resolveWhatsAppAccount(orgID, *req.WhatsAppAccount); err != nil {
+			_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+			return err
+		}
+	}
+	if req.WhatsAppAccount != nil {
+		contact.WhatsAppAccount = *req.WhatsAppAccount
+	}
+	if req.Name != nil {
+		contact.ProfileName = strings.TrimSpace(*req.Name)
+	}
+	if req.Tags != nil {
+		contact.Tags = mergeContactTags(nil, cleanContactTags(req.Tags))
+	}
+	if req.Metadata != nil {
+		contact.Metadata = models.JSONB(req.Metadata)
+	}
+	return nil
+}
+
+// buildContactResponse converts a contact to its API response, masked for the user
+func (a *App) buildContactResponse(orgID, userID uuid.UUID, contact *models.Contact) ContactResponse {
 	// Count unread messages
 	var unreadCount int64
 	a.DB.Model(&models.Message{}).
@@ -211,31 +395,32 @@ func (a *App) GetContact(r *fastglue.Request) error {
 		}
 	}
 
-	phoneNumber := contact.PhoneNumber
-	profileName := contact.ProfileName
-	shouldMask := a.ShouldMaskPhoneNumbers(orgID)
-	if shouldMask {
-		phoneNumber = MaskPhoneNumber(phoneNumber)
-		profileName = MaskIfPhoneNumber(profileName)
-	}
+	mask := a.dataMaskFor(orgID, userID)
+	profileName := mask.Name(contact.ProfileName)
 
-	response := ContactResponse{
+	return ContactResponse{
 		ID:                 contact.ID,
-		PhoneNumber:        phoneNumber,
+		PhoneNumber:        mask.Phone(contact.PhoneNumber),
 		Name:               profileName,
 		ProfileName:        profileName,
+		AvatarURL:          contactAvatarURL(contact),
 		Status:             "active",
 		Tags:               tags,
-		CustomFields:       contact.Metadata,
+		CustomFields:       mask.CustomFields(contact.Metadata),
 		LastMessageAt:      contact.LastMessageAt,
-		LastMessagePreview: contact.LastMessagePreview,
+		LastMessagePreview: mask.Content(contact.LastMessagePreview),
 		UnreadCount:        int(unreadCount),
 		AssignedUserID:     contact.AssignedUserID,
+		EngagementScore:    contact.EngagementScore,
+		LifecycleStage:     string(contact.LifecycleStage),
+		WhatsAppAccount:    contact.WhatsAppAccount,
+		OptedOut:           contact.OptedOut,
+		OptedOutAt:         contact.OptedOutAt,
+		Priority:           contact.Priority,
+		PrioritySource:     contact.PrioritySource,
 		CreatedAt:          contact.CreatedAt,
 		UpdatedAt:          contact.UpdatedAt,
 	}
-
-	return r.SendEnvelope(response)
 }
 
 // GetMessages returns messages for a contact
@@ -250,8 +435,11 @@ func (a *App) GetMessages(r *fastglue.Request) error {
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
 	}
+	if a.HasRestrictedDataAccess(userID, orgID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, restrictedDataAccessMessage, nil, "")
+	}
 
-	hasContactsReadPermission := a.HasPermission(userID, models.ResourceContacts, models.ActionRead)
+	hasContactsReadPermission := a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead)
 
 	// Verify contact belongs to org (and to user if no contacts:read permission)
 	var contact models.Contact
@@ -271,14 +459,24 @@ func (a *App) GetMessages(r *fastglue.Request) error {
 		limit = 50
 	}
 
-	// Build base query
+	// Build base query. Messages from every org number are returned as one unified
+	// history unless whatsapp_account narrows it to a single number's thread.
+	// all_accounts=true also merges in linked contact records for the same phone number.
 	msgQuery := a.DB.Where("contact_id = ?", contactID)
+	if string(r.RequestCtx.QueryArgs().Peek("all_accounts")) == "true" {
+		msgQuery = a.DB.Where("contact_id IN ?", a.linkedContactIDs(orgID, &contact))
+	}
+	if account := string(r.RequestCtx.QueryArgs().Peek("whatsapp_account")); account != "" {
+		msgQuery = msgQuery.Where("whats_app_account = ?", account)
+	}
 
 	// Check if user without contacts:read should only see current conversation
+	currentConversationOnly := false
 	if !hasContactsReadPermission {
 		settings, err := a.getChatbotSettingsCached(orgID, "")
 		if err == nil {
 			if settings.AgentAssignment.CurrentConversationOnly {
+				currentConversationOnly = true
 				// Find the most recent session for this contact
 				var session models.ChatbotSession
 				if err := a.DB.Where("contact_id = ? AND organization_id = ?", contactID, orgID).
@@ -316,10 +514,12 @@ func (a *App) GetMessages(r *fastglue.Request) error {
 		}
 
 		response := a.buildMessagesResponse(messages)
+		hasMore := len(messages) == limit
 		return r.SendEnvelope(map[string]any{
-			"messages": response,
-			"total":    total,
-			"has_more": len(messages) == limit,
+			"messages":     response,
+			"total":        total,
+			"has_more":     hasMore,
+			"has_archived": !hasMore && !currentConversationOnly && a.hasArchivedMessages(r, orgID, &contact),
 		})
 	}
 
@@ -348,16 +548,47 @@ func (a *App) GetMessages(r *fastglue.Request) error {
 
 	response := a.buildMessagesResponse(messages)
 	return r.SendEnvelope(map[string]any{
-		"messages": response,
-		"total":    total,
-		"page":     page,
-		"limit":    limit,
-		"has_more": offset > 0,
+		"messages":     response,
+		"total":        total,
+		"page":         page,
+		"limit":        limit,
+		"has_more":     offset > 0,
+		"has_archived": offset == 0 && !currentConversationOnly && a.hasArchivedMessages(r, orgID, &contact),
 	})
 }
 
+// linkedContactIDs returns the IDs of all contact records in the org for the same phone
+// number as contact, including legacy records stored with a leading +
+func (a *App) linkedContactIDs(orgID uuid.UUID, contact *models.Contact) []uuid.UUID {
+	phone := strings.TrimPrefix(contact.PhoneNumber, "+")
+
+	var ids []uuid.UUID
+	if err := a.DB.Model(&models.Contact{}).
+		Where("organization_id = ? AND phone_number IN ?", orgID, []string{phone, "+" + phone}).
+		Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return []uuid.UUID{contact.ID}
+	}
+	return ids
+}
+
 // buildMessagesResponse converts messages to response format
 func (a *App) buildMessagesResponse(messages []models.Message) []MessageResponse {
+	outgoingIDs := make([]uuid.UUID, 0, len(messages))
+	var pendingIDs []uuid.UUID
+	for _, m := range messages {
+		if m.Direction == models.DirectionOutgoing {
+			outgoingIDs = append(outgoingIDs, m.ID)
+			if m.Status == models.MessageStatusPending {
+				pendingIDs = append(pendingIDs, m.ID)
+			}
+		}
+	}
+	statusHistory := a.loadStatusHistory(outgoingIDs)
+	var queueStates map[uuid.UUID]*MessageQueueState
+	if len(pendingIDs) > 0 {
+		queueStates = a.loadQueueStates(messages[0].OrganizationID, pendingIDs)
+	}
+
 	response := make([]MessageResponse, len(messages))
 	for i, m := range messages {
 		var content any
@@ -376,11 +607,18 @@ func (a *App) buildMessagesResponse(messages []models.Message) []MessageResponse
 			MediaURL:        m.MediaURL,
 			MediaMimeType:   m.MediaMimeType,
 			MediaFilename:   m.MediaFilename,
+			MediaID:         m.MediaID,
 			InteractiveData: m.InteractiveData,
+			FlowResponse:    m.FlowResponse,
 			Status:          m.Status,
 			WAMID:           m.WhatsAppMessageID,
 			Error:           m.ErrorMessage,
 			IsReply:         m.IsReply,
+			WhatsAppAccount: m.WhatsAppAccount,
+			StatusHistory:   statusHistory[m.ID],
+			Queue:           queueStates[m.ID],
+			ErrorCode:       m.ErrorCode,
+			ErrorCategory:   errorCategory(m.ErrorCode),
 			CreatedAt:       m.CreatedAt,
 			UpdatedAt:       m.UpdatedAt,
 		}
@@ -398,23 +636,11 @@ func (a *App) buildMessagesResponse(messages []models.Message) []MessageResponse
 			}
 		}
 
-		if m.Metadata != nil {
-			if reactionsRaw, ok := m.Metadata["reactions"]; ok {
-				if reactionsArray, ok := reactionsRaw.([]interface{}); ok {
-					for _, r := range reactionsArray {
-						if rMap, ok := r.(map[string]interface{}); ok {
-							emoji, _ := rMap["emoji"].(string)
-							fromPhone, _ := rMap["from_phone"].(string)
-							fromUser, _ := rMap["from_user"].(string)
-							msgResp.Reactions = append(msgResp.Reactions, ReactionInfo{
-								Emoji:     emoji,
-								FromPhone: fromPhone,
-								FromUser:  fromUser,
-							})
-						}
-					}
-				}
-			}
+		msgResp.setSharedContent(m)
+		msgResp.MediaRejected, _ = m.Metadata[mediaRejectedMetadataKey].(string)
+
+		for _, r := range parseReactions(m.Metadata) {
+			msgResp.Reactions = append(msgResp.Reactions, ReactionInfo(r))
 		}
 
 		response[i] = msgResp
@@ -445,9 +671,14 @@ func (a *App) markMessagesAsRead(orgID uuid.UUID, contactID uuid.UUID, contact *
 					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 					defer cancel()
 
+					accessToken, err := a.accountAccessToken(&account)
+					if err != nil {
+						a.Log.Error("Failed to decrypt access token", "error", err, "account", account.Name)
+						return
+					}
 					waAccount := &whatsapp.Account{
 						PhoneID:     account.PhoneID,
-						AccessToken: account.AccessToken,
+						AccessToken: accessToken,
 						APIVersion:  a.Config.WhatsApp.APIVersion,
 					}
 					for _, msg := range unreadMessages {
@@ -516,7 +747,7 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 	// Get contact (users without full read permission can only message their assigned contacts)
 	var contact models.Contact
 	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
 		query = query.Where("assigned_user_id = ?", userID)
 	}
 	if err := query.First(&contact).Error; err != nil {
@@ -530,15 +761,9 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 	}
 
 	// Handle reply context
-	var replyToMessage *models.Message
-	if req.ReplyToMessageID != "" {
-		replyToID, err := uuid.Parse(req.ReplyToMessageID)
-		if err == nil {
-			var replyTo models.Message
-			if err := a.DB.Where("id = ? AND contact_id = ?", replyToID, contactID).First(&replyTo).Error; err == nil {
-				replyToMessage = &replyTo
-			}
-		}
+	replyToMessage, err := a.findReplyToMessage(contact.ID, req.ReplyToMessageID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
 	}
 
 	// Build request and send using unified sender
@@ -552,21 +777,53 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 
 	// Handle interactive messages
 	if req.Type == models.MessageTypeInteractive && req.Interactive != nil {
-		msgReq.InteractiveType = req.Interactive.Type
-		msgReq.BodyText = req.Interactive.Body
-		msgReq.ButtonText = req.Interactive.ButtonText
-		msgReq.URL = req.Interactive.URL
-
-		// Convert buttons
-		if len(req.Interactive.Buttons) > 0 {
-			msgReq.Buttons = make([]whatsapp.Button, len(req.Interactive.Buttons))
-			for i, btn := range req.Interactive.Buttons {
-				msgReq.Buttons[i] = whatsapp.Button{
-					ID:    btn.ID,
-					Title: btn.Title,
-				}
+		applyInteractiveContent(&msgReq, req.Interactive)
+	}
+
+	// Enforce the organization's content policy on the text the contact will see
+	policyText := &msgReq.Content
+	if req.Type == models.MessageTypeInteractive {
+		policyText = &msgReq.BodyText
+	}
+	policyResult, err := a.getOrgContentPolicy(orgID).Apply(*policyText)
+	if err != nil {
+		a.Log.Warn("Outgoing message blocked by content policy", "contact_id", contact.ID, "user_id", userID, "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, err.Error(), nil, "")
+	}
+	*policyText = policyResult.Text
+
+	// Risky messages, and all messages from agents in approval mode, wait for a supervisor
+	approvalReason := policyResult.ApprovalReason
+	if approvalReason == "" && a.userRequiresApproval(userID) {
+		approvalReason = approvalReasonAgent
+	}
+	if approvalReason != "" && !a.canReviewMessages(userID, orgID) {
+		approval := models.MessageApproval{
+			OrganizationID:  orgID,
+			ContactID:       contact.ID,
+			WhatsAppAccount: account.Name,
+			RequestedByID:   userID,
+			MessageType:     req.Type,
+			Content:         *policyText,
+			Reason:          approvalReason,
+		}
+		if replyToMessage != nil {
+			approval.ReplyToMessageID = &replyToMessage.ID
+		}
+		if req.Type == models.MessageTypeInteractive && req.Interactive != nil {
+			interactive := *req.Interactive
+			interactive.Body = *policyText
+			if approval.InteractiveData, err = interactiveToJSONB(&interactive); err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid interactive message", nil, "")
 			}
 		}
+		if err := a.createMessageApproval(&approval, &contact); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to submit message for approval", nil, "")
+		}
+		return r.SendEnvelope(map[string]interface{}{
+			"pending_approval": true,
+			"approval":         buildMessageApprovalResponse(&approval),
+		})
 	}
 
 	opts := DefaultSendOptions()
@@ -575,6 +832,9 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 	ctx := context.Background()
 	message, err := a.SendOutgoingMessage(ctx, msgReq, opts)
 	if err != nil {
+		if isSendBlocked(err) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+		}
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to send message", nil, "")
 	}
 
@@ -586,6 +846,7 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 		MessageType:     message.MessageType,
 		Content:         map[string]string{"body": message.Content},
 		InteractiveData: message.InteractiveData,
+		FlowResponse:    message.FlowResponse,
 		Status:          message.Status,
 		IsReply:         message.IsReply,
 		CreatedAt:       message.CreatedAt,
@@ -607,6 +868,42 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 	return r.SendEnvelope(response)
 }
 
+// findReplyToMessage looks up the message of a contact that a new message replies to.
+// An empty ID means the message isn't a reply.
+func (a *App) findReplyToMessage(contactID uuid.UUID, messageID string) (*models.Message, error) {
+	if messageID == "" {
+		return nil, nil
+	}
+	replyToID, err := uuid.Parse(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reply_to_message_id")
+	}
+	var replyTo models.Message
+	if err := a.DB.Where("id = ? AND contact_id = ?", replyToID, contactID).First(&replyTo).Error; err != nil {
+		return nil, fmt.Errorf("reply-to message not found")
+	}
+	return &replyTo, nil
+}
+
+// applyInteractiveContent copies interactive message fields from the API request into msgReq
+func applyInteractiveContent(msgReq *OutgoingMessageRequest, interactive *InteractiveContent) {
+	msgReq.InteractiveType = interactive.Type
+	msgReq.BodyText = interactive.Body
+	msgReq.ButtonText = interactive.ButtonText
+	msgReq.URL = interactive.URL
+
+	// Convert buttons
+	if len(interactive.Buttons) > 0 {
+		msgReq.Buttons = make([]whatsapp.Button, len(interactive.Buttons))
+		for i, btn := range interactive.Buttons {
+			msgReq.Buttons[i] = whatsapp.Button{
+				ID:    btn.ID,
+				Title: btn.Title,
+			}
+		}
+	}
+}
+
 // resolveWhatsAppAccount gets the WhatsApp account for sending messages
 func (a *App) resolveWhatsAppAccount(orgID uuid.UUID, accountName string) (*models.WhatsAppAccount, error) {
 	var account models.WhatsAppAccount
@@ -640,70 +937,64 @@ func (a *App) SendMediaMessage(r *fastglue.Request) error {
 	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 
-	// Parse multipart form
-	form, err := r.RequestCtx.MultipartForm()
+	// Stream the multipart upload to storage rather than buffering the file
+	upload, err := a.readMediaUpload(r)
 	if err != nil {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid multipart form", nil, "")
+		switch {
+		case errors.Is(err, errMediaTooLarge):
+			return r.SendErrorEnvelope(fasthttp.StatusRequestEntityTooLarge, "File exceeds the maximum size for this media type", nil, "")
+		case errors.Is(err, errInvalidUpload):
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid multipart form", nil, "")
+		}
+		a.Log.Error("Failed to save media locally", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save media", nil, "")
+	}
+	if upload.LocalPath == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "file is required", nil, "")
 	}
+	// The stored file becomes the message's media once the contact and account check out
+	saved := false
+	defer func() {
+		if !saved {
+			a.removeUpload(upload)
+		}
+	}()
 
 	// Get contact ID from form
-	contactIDValues := form.Value["contact_id"]
-	if len(contactIDValues) == 0 {
+	if upload.Fields["contact_id"] == "" {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_id is required", nil, "")
 	}
-	contactID, err := uuid.Parse(contactIDValues[0])
+	contactID, err := uuid.Parse(upload.Fields["contact_id"])
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
 	}
 
 	// Get media type (image, document, video, audio)
 	mediaType := "image"
-	if typeValues := form.Value["type"]; len(typeValues) > 0 {
-		mediaType = typeValues[0]
+	if t := upload.Fields["type"]; t != "" {
+		mediaType = t
 	}
 
 	// Get caption (optional)
-	caption := ""
-	if captionValues := form.Value["caption"]; len(captionValues) > 0 {
-		caption = captionValues[0]
-	}
-
-	// Get uploaded file
-	files := form.File["file"]
-	if len(files) == 0 {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "file is required", nil, "")
-	}
-	fileHeader := files[0]
-
-	// Open the file
-	file, err := fileHeader.Open()
-	if err != nil {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Failed to read file", nil, "")
-	}
-	defer func() { _ = file.Close() }()
-
-	// Read file data
-	fileData, err := io.ReadAll(file)
-	if err != nil {
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read file data", nil, "")
-	}
-
-	// Get MIME type
-	mimeType := fileHeader.Header.Get("Content-Type")
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
-	}
+	caption := upload.Fields["caption"]
+	mimeType := upload.MimeType
+	localPath := upload.LocalPath
 
 	// Get contact (users without full read permission can only message their assigned contacts)
 	var contact models.Contact
 	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
 		query = query.Where("assigned_user_id = ?", userID)
 	}
 	if err := query.First(&contact).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
 	}
 
+	replyToMessage, err := a.findReplyToMessage(contact.ID, upload.Fields["reply_to_message_id"])
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
 	// Get WhatsApp account
 	var account models.WhatsAppAccount
 	if contact.WhatsAppAccount != "" {
@@ -719,23 +1010,57 @@ func (a *App) SendMediaMessage(r *fastglue.Request) error {
 		}
 	}
 
-	// Save file locally first
-	localPath, err := a.saveMediaLocally(fileData, mimeType, fileHeader.Filename)
+	// Enforce the organization's content policy on the caption
+	policyResult, err := a.getOrgContentPolicy(orgID).Apply(caption)
 	if err != nil {
-		a.Log.Error("Failed to save media locally", "error", err)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save media", nil, "")
+		a.Log.Warn("Outgoing media caption blocked by content policy", "contact_id", contact.ID, "user_id", userID, "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, err.Error(), nil, "")
+	}
+	caption = policyResult.Text
+
+	saved = true
+
+	// Risky captions, and all media from agents in approval mode, wait for a supervisor;
+	// the saved file is sent on approval
+	approvalReason := policyResult.ApprovalReason
+	if approvalReason == "" && a.userRequiresApproval(userID) {
+		approvalReason = approvalReasonAgent
+	}
+	if approvalReason != "" && !a.canReviewMessages(userID, orgID) {
+		approval := models.MessageApproval{
+			OrganizationID:  orgID,
+			ContactID:       contact.ID,
+			WhatsAppAccount: account.Name,
+			RequestedByID:   userID,
+			MessageType:     models.MessageType(mediaType),
+			Content:         caption,
+			MediaURL:        localPath,
+			MediaMimeType:   mimeType,
+			MediaFilename:   upload.Filename,
+			Reason:          approvalReason,
+		}
+		if replyToMessage != nil {
+			approval.ReplyToMessageID = &replyToMessage.ID
+		}
+		if err := a.createMessageApproval(&approval, &contact); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to submit message for approval", nil, "")
+		}
+		return r.SendEnvelope(map[string]interface{}{
+			"pending_approval": true,
+			"approval":         buildMessageApprovalResponse(&approval),
+		})
 	}
 
 	// Build and send via unified message sender
 	msgReq := OutgoingMessageRequest{
-		Account:       &account,
-		Contact:       &contact,
-		Type:          models.MessageType(mediaType),
-		MediaData:     fileData,
-		MediaURL:      localPath,
-		MediaMimeType: mimeType,
-		MediaFilename: fileHeader.Filename,
-		Caption:       caption,
+		Account:        &account,
+		Contact:        &contact,
+		Type:           models.MessageType(mediaType),
+		MediaURL:       localPath,
+		MediaMimeType:  mimeType,
+		MediaFilename:  upload.Filename,
+		Caption:        caption,
+		ReplyToMessage: replyToMessage,
 	}
 
 	opts := DefaultSendOptions()
@@ -744,6 +1069,9 @@ func (a *App) SendMediaMessage(r *fastglue.Request) error {
 	ctx := context.Background()
 	message, err := a.SendOutgoingMessage(ctx, msgReq, opts)
 	if err != nil {
+		if isSendBlocked(err) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+		}
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to send message", nil, "")
 	}
 
@@ -757,55 +1085,36 @@ func (a *App) SendMediaMessage(r *fastglue.Request) error {
 		MediaMimeType: message.MediaMimeType,
 		MediaFilename: message.MediaFilename,
 		Status:        message.Status,
+		IsReply:       message.IsReply,
 		CreatedAt:     message.CreatedAt,
 		UpdatedAt:     message.UpdatedAt,
 	}
+	if replyToMessage != nil {
+		replyToID := replyToMessage.ID.String()
+		response.ReplyToMessageID = &replyToID
+		response.ReplyToMessage = &ReplyPreview{
+			ID:          replyToMessage.ID.String(),
+			Content:     map[string]string{"body": replyToMessage.Content},
+			MessageType: replyToMessage.MessageType,
+			Direction:   replyToMessage.Direction,
+		}
+	}
 
 	return r.SendEnvelope(response)
 }
 
 // saveMediaLocally saves media data to local storage and returns the relative path
-func (a *App) saveMediaLocally(data []byte, mimeType, filename string) (string, error) {
-	// Determine subdirectory based on MIME type
-	var subdir string
-	switch {
-	case strings.HasPrefix(mimeType, "image/"):
-		subdir = "images"
-	case strings.HasPrefix(mimeType, "video/"):
-		subdir = "videos"
-	case strings.HasPrefix(mimeType, "audio/"):
-		subdir = "audio"
-	default:
-		subdir = "documents"
-	}
-
-	// Ensure directory exists
-	if err := a.ensureMediaDir(subdir); err != nil {
-		return "", fmt.Errorf("failed to create media directory: %w", err)
-	}
-
-	// Get extension from MIME type or filename
-	ext := getExtensionFromMimeType(mimeType)
-	if ext == "" {
-		// Try to get from filename
-		if dotIdx := strings.LastIndex(filename, "."); dotIdx >= 0 {
-			ext = filename[dotIdx:]
-		} else {
-			ext = ".bin"
-		}
+func (a *App) saveMediaLocally(orgID uuid.UUID, data []byte, mimeType, filename string) (string, error) {
+	relativePath, err := a.newMediaFilePath(orgID, mimeType, filename)
+	if err != nil {
+		return "", err
 	}
 
-	// Generate unique filename
-	newFilename := uuid.New().String() + ext
-	filePath := filepath.Join(a.getMediaStoragePath(), subdir, newFilename)
-
 	// Save file
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := a.writeMediaFile(orgID, relativePath, data); err != nil {
 		return "", fmt.Errorf("failed to save media file: %w", err)
 	}
 
-	// Return relative path
-	relativePath := filepath.Join(subdir, newFilename)
 	a.Log.Info("Media saved locally", "path", relativePath, "size", len(data))
 
 	return relativePath, nil
@@ -842,7 +1151,7 @@ func (a *App) SendReaction(r *fastglue.Request) error {
 	// Get contact (users without full read permission can only react to messages in their assigned contacts)
 	var contact models.Contact
 	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
 		query = query.Where("assigned_user_id = ?", userID)
 	}
 	if err := query.First(&contact).Error; err != nil {
@@ -869,56 +1178,16 @@ func (a *App) SendReaction(r *fastglue.Request) error {
 		}
 	}
 
-	// Parse existing reactions from Metadata
-	var metadata map[string]interface{}
-	if message.Metadata != nil {
-		metadata = message.Metadata
-	} else {
-		metadata = make(map[string]interface{})
-	}
-
-	// Get or initialize reactions array
-	type Reaction struct {
-		Emoji     string `json:"emoji"`
-		FromPhone string `json:"from_phone,omitempty"`
-		FromUser  string `json:"from_user,omitempty"`
-	}
-	var reactions []Reaction
-	if reactionsRaw, ok := metadata["reactions"]; ok {
-		if reactionsArray, ok := reactionsRaw.([]interface{}); ok {
-			for _, r := range reactionsArray {
-				if rMap, ok := r.(map[string]interface{}); ok {
-					emoji, _ := rMap["emoji"].(string)
-					fromPhone, _ := rMap["from_phone"].(string)
-					fromUser, _ := rMap["from_user"].(string)
-					reactions = append(reactions, Reaction{
-						Emoji:     emoji,
-						FromPhone: fromPhone,
-						FromUser:  fromUser,
-					})
-				}
-			}
-		}
-	}
-
-	// Remove existing reaction from this user (each user can only have one reaction)
-	userIDStr := userID.String()
-	var newReactions []Reaction
-	for _, r := range reactions {
-		if r.FromUser != userIDStr {
-			newReactions = append(newReactions, r)
-		}
-	}
+	// Replace this user's reaction (each user can only have one reaction)
+	newReactions := upsertReaction(parseReactions(message.Metadata), Reaction{
+		Emoji:    req.Emoji,
+		FromUser: userID.String(),
+	})
 
-	// Add new reaction if emoji is not empty
-	if req.Emoji != "" {
-		newReactions = append(newReactions, Reaction{
-			Emoji:    req.Emoji,
-			FromUser: userIDStr,
-		})
+	metadata := message.Metadata
+	if metadata == nil {
+		metadata = models.JSONB{}
 	}
-
-	// Update metadata
 	metadata["reactions"] = newReactions
 	if err := a.DB.Model(&message).Update("metadata", metadata).Error; err != nil {
 		a.Log.Error("Failed to update message reactions", "error", err)
@@ -928,17 +1197,7 @@ func (a *App) SendReaction(r *fastglue.Request) error {
 	// Send reaction to WhatsApp API
 	go a.sendWhatsAppReaction(&account, &contact, &message, req.Emoji)
 
-	// Broadcast via WebSocket
-	if a.WSHub != nil {
-		a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
-			Type: "reaction_update",
-			Payload: map[string]any{
-				"message_id": message.ID.String(),
-				"contact_id": contact.ID.String(),
-				"reactions":  newReactions,
-			},
-		})
-	}
+	a.broadcastReactionUpdate(orgID, &message, newReactions)
 
 	return r.SendEnvelope(map[string]any{
 		"message_id": message.ID.String(),
@@ -978,8 +1237,13 @@ func (a *App) sendWhatsAppReaction(account *models.WhatsAppAccount, contact *mod
 		return
 	}
 
+	accessToken, err := a.accountAccessToken(account)
+	if err != nil {
+		a.Log.Error("Failed to decrypt access token", "error", err, "account", account.Name)
+		return
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
@@ -1014,7 +1278,7 @@ func (a *App) AssignContact(r *fastglue.Request) error {
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 
 	// Only users with write permission can assign contacts
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionWrite) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionWrite) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "You do not have permission to assign contacts", nil, "")
 	}
 
@@ -1075,11 +1339,14 @@ func (a *App) GetContactSessionData(r *fastglue.Request) error {
 	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
 	}
+	if a.HasRestrictedDataAccess(userID, orgID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, restrictedDataAccessMessage, nil, "")
+	}
 
 	// Verify contact belongs to org (users without full read permission can only access assigned contacts)
 	var contact models.Contact
 	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
 		query = query.Where("assigned_user_id = ?", userID)
 	}
 	if err := query.First(&contact).Error; err != nil {
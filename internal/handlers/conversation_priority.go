@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// transferPriorityOrder sorts agent transfers urgent first, then high, then normal
+const transferPriorityOrder = "CASE agent_transfers.priority WHEN 'urgent' THEN 0 WHEN 'high' THEN 1 ELSE 2 END"
+
+// sentimentPriorityPrompt asks the AI provider to rate how urgently a customer needs help
+const sentimentPriorityPrompt = `You triage customer support conversations.
+Read the customer's message and rate how urgently a human agent should answer it.
+Reply "urgent" if the customer is angry, threatening to leave, reporting an outage, fraud or a safety issue.
+Reply "high" if the customer is frustrated or the issue blocks them.
+Reply "normal" otherwise.
+Reply with exactly one word: urgent, high or normal.`
+
+// ConversationPriorityRequest sets a conversation's priority by hand
+type ConversationPriorityRequest struct {
+	Priority models.ConversationPriority `json:"priority"`
+}
+
+// priorityRank orders priorities from most to least urgent
+func priorityRank(p models.ConversationPriority) int {
+	switch p {
+	case models.ConversationPriorityUrgent:
+		return 0
+	case models.ConversationPriorityHigh:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// contactPriority returns the priority of the contact's conversation, normal if unset
+func contactPriority(contact *models.Contact) models.ConversationPriority {
+	if contact.Priority == "" {
+		return models.ConversationPriorityNormal
+	}
+	return contact.Priority
+}
+
+// isValidConversationPriority reports whether p is one of the known priorities
+func isValidConversationPriority(p models.ConversationPriority) bool {
+	switch p {
+	case models.ConversationPriorityUrgent, models.ConversationPriorityHigh, models.ConversationPriorityNormal:
+		return true
+	}
+	return false
+}
+
+// isValidKeywordRulePriority reports whether a keyword rule can set p. Rules only raise
+// priority, so normal isn't allowed; empty leaves the priority alone.
+func isValidKeywordRulePriority(p models.ConversationPriority) bool {
+	return p == "" || p == models.ConversationPriorityUrgent || p == models.ConversationPriorityHigh
+}
+
+// SetConversationPriority sets the priority of a contact's conversation. Active agent
+// transfers for the contact move in the queue accordingly.
+func (a *App) SetConversationPriority(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceChat, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var req ConversationPriorityRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if !isValidConversationPriority(req.Priority) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "priority must be urgent, high or normal", nil, "")
+	}
+
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	// Users without contacts:read permission can only prioritize their assigned contacts
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	if err := a.setConversationPriority(&contact, req.Priority, models.PrioritySourceManual); err != nil {
+		a.Log.Error("Failed to set conversation priority", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to set priority", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"priority":            contact.Priority,
+		"priority_source":     contact.PrioritySource,
+		"priority_updated_at": contact.PriorityUpdatedAt,
+	})
+}
+
+// setConversationPriority updates the priority on the contact and its active agent
+// transfers, and tells connected clients
+func (a *App) setConversationPriority(contact *models.Contact, priority models.ConversationPriority, source models.PrioritySource) error {
+	now := time.Now()
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Contact{}).Where("id = ?", contact.ID).Updates(map[string]any{
+			"priority":            priority,
+			"priority_source":     source,
+			"priority_updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AgentTransfer{}).
+			Where("organization_id = ? AND contact_id = ? AND status = ?", contact.OrganizationID, contact.ID, models.TransferStatusActive).
+			Update("priority", priority).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	contact.Priority = priority
+	contact.PrioritySource = source
+	contact.PriorityUpdatedAt = &now
+
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(contact.OrganizationID, websocket.WSMessage{
+			Type: websocket.TypeConversationPriority,
+			Payload: map[string]any{
+				"contact_id":      contact.ID.String(),
+				"priority":        priority,
+				"priority_source": source,
+			},
+		})
+	}
+	a.Log.Info("Conversation priority changed", "contact_id", contact.ID, "priority", priority, "source", source)
+	return nil
+}
+
+// raiseConversationPriority sets the priority only if it's more urgent than the current
+// one, so automatic sources never lower what an agent or an earlier message chose
+func (a *App) raiseConversationPriority(contact *models.Contact, priority models.ConversationPriority, source models.PrioritySource) {
+	if priorityRank(priority) >= priorityRank(contact.Priority) {
+		return
+	}
+	if err := a.setConversationPriority(contact, priority, source); err != nil {
+		a.Log.Error("Failed to raise conversation priority", "error", err, "contact_id", contact.ID, "source", source)
+	}
+}
+
+// resetConversationPriority puts the contact's conversation back to normal priority once
+// its agent transfer ends
+func (a *App) resetConversationPriority(contactID uuid.UUID) {
+	if err := a.DB.Model(&models.Contact{}).
+		Where("id = ? AND priority != ?", contactID, models.ConversationPriorityNormal).
+		Updates(map[string]any{
+			"priority":            models.ConversationPriorityNormal,
+			"priority_source":     "",
+			"priority_updated_at": time.Now(),
+		}).Error; err != nil {
+		a.Log.Error("Failed to reset conversation priority", "error", err, "contact_id", contactID)
+	}
+}
+
+// updateConversationPriority raises the conversation's priority from an incoming message,
+// using keyword rules that set one and, when enabled, the AI provider's read of the
+// customer's sentiment
+func (a *App) updateConversationPriority(account *models.WhatsAppAccount, contact *models.Contact, messageText string) {
+	if contact == nil || strings.TrimSpace(messageText) == "" {
+		return
+	}
+
+	if priority, ok := a.matchPriorityKeywordRules(account.OrganizationID, account.Name, messageText); ok {
+		a.raiseConversationPriority(contact, priority, models.PrioritySourceKeyword)
+	}
+	if contact.Priority == models.ConversationPriorityUrgent {
+		return
+	}
+
+	settings, err := a.getChatbotSettingsCached(account.OrganizationID, account.Name)
+	if err != nil || !settings.AI.Enabled || !settings.AI.SentimentPriority {
+		return
+	}
+	c := *contact
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.detectSentimentPriority(settings, &c, messageText)
+	}()
+}
+
+// matchPriorityKeywordRules returns the most urgent priority set by a keyword rule that
+// matches the message
+func (a *App) matchPriorityKeywordRules(orgID uuid.UUID, accountName, messageText string) (models.ConversationPriority, bool) {
+	rules, err := a.getKeywordRulesCached(orgID, accountName)
+	if err != nil {
+		a.Log.Error("Failed to fetch keyword rules", "error", err)
+		return "", false
+	}
+
+	var priority models.ConversationPriority
+	for i := range rules {
+		rule := &rules[i]
+		if !isValidConversationPriority(rule.SetPriority) || !keywordRuleMatches(rule, messageText) {
+			continue
+		}
+		if priority == "" || priorityRank(rule.SetPriority) < priorityRank(priority) {
+			priority = rule.SetPriority
+		}
+	}
+	return priority, priority != ""
+}
+
+// detectSentimentPriority asks the AI provider how urgent the message is and raises the
+// conversation's priority to match
+func (a *App) detectSentimentPriority(settings *models.ChatbotSettings, contact *models.Contact, messageText string) {
+	reply, err := a.completeAI(settings, sentimentPriorityPrompt, messageText)
+	if err != nil {
+		a.Log.Error("Failed to classify message sentiment", "error", err, "contact_id", contact.ID)
+		return
+	}
+
+	priority := models.ConversationPriority(strings.ToLower(strings.Trim(strings.TrimSpace(reply), ".\"'")))
+	if !isValidConversationPriority(priority) {
+		a.Log.Warn("Unexpected sentiment classification", "reply", reply, "contact_id", contact.ID)
+		return
+	}
+	a.raiseConversationPriority(contact, priority, models.PrioritySourceSentiment)
+}
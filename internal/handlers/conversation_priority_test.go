@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// setPriority calls SetConversationPriority for the contact and returns the status code
+func setPriority(t *testing.T, app *handlers.App, orgID, userID, contactID uuid.UUID, priority string) int {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, map[string]string{"priority": priority})
+	setTransferAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "id", contactID.String())
+	require.NoError(t, app.SetConversationPriority(req))
+	return testutil.GetResponseStatusCode(req)
+}
+
+func TestApp_PickNextTransfer_PriorityBeforeFIFO(t *testing.T) {
+	app := agentTransfersTestApp(t)
+	org := createTransferTestOrg(t, app)
+	account := createTransferTestAccount(t, app, org.ID)
+	agent := createTestAgent(t, app, org.ID)
+
+	create := func(priority models.ConversationPriority, age time.Duration) *models.AgentTransfer {
+		contact := createTestContact(t, app, org.ID)
+		transfer := &models.AgentTransfer{
+			OrganizationID:  org.ID,
+			ContactID:       contact.ID,
+			WhatsAppAccount: account.Name,
+			PhoneNumber:     contact.PhoneNumber,
+			Status:          models.TransferStatusActive,
+			Source:          models.TransferSourceManual,
+			Priority:        priority,
+			TransferredAt:   time.Now().Add(-age),
+		}
+		require.NoError(t, app.DB.Create(transfer).Error)
+		return transfer
+	}
+	normal := create(models.ConversationPriorityNormal, 3*time.Hour)
+	highNewer := create(models.ConversationPriorityHigh, time.Hour)
+	highOlder := create(models.ConversationPriorityHigh, 2*time.Hour)
+	urgent := create(models.ConversationPriorityUrgent, time.Minute)
+
+	for _, want := range []*models.AgentTransfer{urgent, highOlder, highNewer, normal} {
+		req := testutil.NewJSONRequest(t, nil)
+		setTransferAuthContext(req, org.ID, agent.ID)
+		require.NoError(t, app.PickNextTransfer(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		var resp struct {
+			Transfer *handlers.AgentTransferResponse `json:"transfer"`
+		}
+		testutil.ParseEnvelopeResponse(t, req, &resp)
+		require.NotNil(t, resp.Transfer)
+		assert.Equal(t, want.ID.String(), resp.Transfer.ID)
+		assert.Equal(t, want.Priority, resp.Transfer.Priority)
+	}
+}
+
+func TestApp_SetConversationPriority(t *testing.T) {
+	app := agentTransfersTestApp(t)
+	org := createTransferTestOrg(t, app)
+	account := createTransferTestAccount(t, app, org.ID)
+	agent := createTestAgent(t, app, org.ID)
+	contact := createTestContact(t, app, org.ID)
+	active := createTestTransfer(t, app, org.ID, contact.ID, account.Name, models.TransferStatusActive, nil)
+	closed := createTestTransfer(t, app, org.ID, contact.ID, account.Name, models.TransferStatusResumed, nil)
+
+	require.Equal(t, fasthttp.StatusOK, setPriority(t, app, org.ID, agent.ID, contact.ID, "urgent"))
+
+	var updated models.Contact
+	require.NoError(t, app.DB.First(&updated, contact.ID).Error)
+	assert.Equal(t, models.ConversationPriorityUrgent, updated.Priority)
+	assert.Equal(t, models.PrioritySourceManual, updated.PrioritySource)
+	assert.NotNil(t, updated.PriorityUpdatedAt)
+
+	var transfer models.AgentTransfer
+	require.NoError(t, app.DB.First(&transfer, active.ID).Error)
+	assert.Equal(t, models.ConversationPriorityUrgent, transfer.Priority)
+	require.NoError(t, app.DB.First(&transfer, closed.ID).Error)
+	assert.Equal(t, models.ConversationPriorityNormal, transfer.Priority)
+
+	assert.Equal(t, fasthttp.StatusBadRequest, setPriority(t, app, org.ID, agent.ID, contact.ID, "critical"))
+
+	// Without contacts:read only assigned contacts can be prioritized
+	role := createTransferTestRole(t, app.DB, org.ID, "chat-only", []string{"chat:read", "chat:write"})
+	user := createTransferTestUser(t, app, org.ID, &role.ID)
+	assert.Equal(t, fasthttp.StatusNotFound, setPriority(t, app, org.ID, user.ID, contact.ID, "high"))
+	require.NoError(t, app.DB.Model(contact).Update("assigned_user_id", user.ID).Error)
+	assert.Equal(t, fasthttp.StatusOK, setPriority(t, app, org.ID, user.ID, contact.ID, "high"))
+}
+
+func TestApp_PriorityKeywordRule(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Update("phone_id", "phone-"+uuid.NewString()[:8]).Error)
+	require.NoError(t, app.DB.Create(&models.KeywordRule{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		Name:            "Refunds",
+		IsEnabled:       true,
+		Keywords:        models.StringArray{"refund", "chargeback"},
+		MatchType:       models.MatchTypeContains,
+		ResponseType:    models.ResponseTypeText,
+		ResponseContent: models.JSONB{},
+		SetPriority:     models.ConversationPriorityUrgent,
+	}).Error)
+
+	receive := func(from, text string) {
+		body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+			"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+			"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+			"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"text","text":{"body":%q}}]}}]}]}`,
+			account.PhoneID, from, from, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()), text)
+		req := testutil.NewJSONRequest(t, nil)
+		req.RequestCtx.Request.SetBody([]byte(body))
+		require.NoError(t, app.WebhookHandler(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	}
+
+	priorityOf := func(phone string) models.ConversationPriority {
+		var contact models.Contact
+		if err := app.DB.Where("organization_id = ? AND phone_number = ?", org.ID, phone).First(&contact).Error; err != nil {
+			return ""
+		}
+		return contact.Priority
+	}
+
+	receive("14155550190", "Where is my REFUND?")
+	receive("14155550191", "Thanks for the quick delivery")
+
+	require.Eventually(t, func() bool {
+		return priorityOf("14155550190") == models.ConversationPriorityUrgent
+	}, 2*time.Second, 20*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return priorityOf("14155550191") == models.ConversationPriorityNormal
+	}, 2*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,782 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/integrations"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// crmStateTTL is how long a CRM OAuth state token stays valid
+	crmStateTTL = 10 * time.Minute
+	// crmCallbackPath is the OAuth redirect path registered in the CRM app, shared by all providers
+	crmCallbackPath = "/api/integrations/crm/callback"
+	// crmSyncTimeout bounds a single sync run
+	crmSyncTimeout = 2 * time.Minute
+	// crmSyncBatchSize caps the contacts pushed, summaries logged and contacts pulled per run
+	crmSyncBatchSize = 100
+	// maxCRMFieldMappings caps the CRM properties pulled into custom fields
+	maxCRMFieldMappings = 50
+	// crmDefaultTokenLifetime is assumed for access tokens issued without an expiry, as
+	// Salesforce's are, so they get refreshed before the session times out
+	crmDefaultTokenLifetime = time.Hour
+	// crmSummaryTitle heads conversation summaries logged in the CRM
+	crmSummaryTitle = "WhatsApp conversation summary"
+)
+
+// crmProviders lists the CRM providers in display order
+var crmProviders = []string{integrations.ProviderHubSpot, integrations.ProviderSalesforce}
+
+// errCRMNotConnected is returned when the organization hasn't connected the CRM
+var errCRMNotConnected = errors.New("crm is not connected")
+
+// CRMState is stored in Redis during the CRM OAuth flow
+type CRMState struct {
+	OrgID    uuid.UUID `json:"org_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Provider string    `json:"provider"`
+}
+
+// CRMConnectionRequest sets a CRM's OAuth client and sync options. Unset options are
+// left unchanged.
+type CRMConnectionRequest struct {
+	ClientID         string            `json:"client_id"`
+	ClientSecret     string            `json:"client_secret"`
+	PushContacts     *bool             `json:"push_contacts"`
+	PushSummaries    *bool             `json:"push_summaries"`
+	FieldMappings    map[string]string `json:"field_mappings"` // CRM property -> contact custom field
+	SyncIntervalMins *int              `json:"sync_interval_mins"`
+}
+
+// CRMConnectionResponse describes a CRM connection (secrets masked)
+type CRMConnectionResponse struct {
+	Provider         string            `json:"provider"`
+	ClientID         string            `json:"client_id"`
+	HasSecret        bool              `json:"has_secret"`
+	Connected        bool              `json:"connected"`
+	InstanceURL      string            `json:"instance_url,omitempty"`
+	ConnectedAt      *time.Time        `json:"connected_at,omitempty"`
+	PushContacts     bool              `json:"push_contacts"`
+	PushSummaries    bool              `json:"push_summaries"`
+	FieldMappings    map[string]string `json:"field_mappings"`
+	SyncIntervalMins int               `json:"sync_interval_mins"`
+	LinkedContacts   int64             `json:"linked_contacts"`
+	LastSyncedAt     *time.Time        `json:"last_synced_at,omitempty"`
+	SyncError        string            `json:"sync_error,omitempty"`
+}
+
+// CRMSyncResult reports the outcome of a CRM sync run
+type CRMSyncResult struct {
+	ContactsPushed  int       `json:"contacts_pushed"`
+	SummariesPushed int       `json:"summaries_pushed"`
+	ContactsPulled  int       `json:"contacts_pulled"`
+	Failed          int       `json:"failed"`
+	SyncedAt        time.Time `json:"synced_at"`
+}
+
+// ListCRMConnections returns the organization's HubSpot and Salesforce connections
+func (a *App) ListCRMConnections(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var conns []models.CRMConnection
+	a.DB.Where("organization_id = ?", orgID).Find(&conns)
+	byProvider := make(map[string]*models.CRMConnection, len(conns))
+	for i := range conns {
+		byProvider[conns[i].Provider] = &conns[i]
+	}
+
+	response := make([]CRMConnectionResponse, 0, len(crmProviders))
+	for _, provider := range crmProviders {
+		conn, ok := byProvider[provider]
+		if !ok {
+			conn = &models.CRMConnection{Provider: provider, PushContacts: true, PushSummaries: true, SyncIntervalMins: 60}
+		}
+		response = append(response, a.crmConnectionResponse(conn))
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"connections":  response,
+		"redirect_url": a.oauthCallbackURL(r, crmCallbackPath),
+	})
+}
+
+// UpdateCRMConnection saves a CRM's OAuth client credentials and sync options
+func (a *App) UpdateCRMConnection(r *fastglue.Request) error {
+	orgID, provider, ok := a.crmRequestContext(r, models.ActionWrite)
+	if !ok {
+		return nil
+	}
+
+	var req CRMConnectionRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.ClientID = strings.TrimSpace(req.ClientID)
+	if req.ClientID == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "client_id is required", nil, "")
+	}
+	if req.SyncIntervalMins != nil && *req.SyncIntervalMins < 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "sync_interval_mins cannot be negative", nil, "")
+	}
+	mappings, err := validateCRMFieldMappings(req.FieldMappings)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var conn models.CRMConnection
+	if err := a.DB.Where("organization_id = ? AND provider = ?", orgID, provider).First(&conn).Error; err != nil {
+		conn = models.CRMConnection{
+			OrganizationID:   orgID,
+			Provider:         provider,
+			PushContacts:     true,
+			PushSummaries:    true,
+			FieldMappings:    models.JSONB{},
+			SyncIntervalMins: 60,
+		}
+	}
+
+	// A different OAuth client can't use tokens issued to the old one
+	if conn.ClientID != "" && conn.ClientID != req.ClientID {
+		clearCRMTokens(&conn)
+	}
+	conn.ClientID = req.ClientID
+	if req.ClientSecret != "" {
+		conn.ClientSecret = req.ClientSecret
+	}
+	if conn.ClientSecret == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "client_secret is required", nil, "")
+	}
+	if req.PushContacts != nil {
+		conn.PushContacts = *req.PushContacts
+	}
+	if req.PushSummaries != nil {
+		conn.PushSummaries = *req.PushSummaries
+	}
+	if req.FieldMappings != nil {
+		conn.FieldMappings = mappings
+	}
+	if req.SyncIntervalMins != nil {
+		conn.SyncIntervalMins = *req.SyncIntervalMins
+	}
+
+	// Create replaces false and zero options with the column defaults, so new
+	// connections get them written again
+	isNew := conn.ID == uuid.Nil
+	pushContacts, pushSummaries, interval := conn.PushContacts, conn.PushSummaries, conn.SyncIntervalMins
+	if err := a.DB.Save(&conn).Error; err != nil {
+		a.Log.Error("Failed to save CRM connection", "error", err, "provider", provider)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save CRM settings", nil, "")
+	}
+	if isNew {
+		conn.PushContacts, conn.PushSummaries, conn.SyncIntervalMins = pushContacts, pushSummaries, interval
+		if err := a.DB.Model(&conn).Select("push_contacts", "push_summaries", "sync_interval_mins").Updates(&conn).Error; err != nil {
+			a.Log.Error("Failed to save CRM sync options", "error", err, "provider", provider)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save CRM settings", nil, "")
+		}
+	}
+
+	return r.SendEnvelope(a.crmConnectionResponse(&conn))
+}
+
+// ConnectCRM starts the OAuth flow and returns the CRM's consent URL
+func (a *App) ConnectCRM(r *fastglue.Request) error {
+	orgID, provider, ok := a.crmRequestContext(r, models.ActionWrite)
+	if !ok {
+		return nil
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var conn models.CRMConnection
+	if err := a.DB.Where("organization_id = ? AND provider = ?", orgID, provider).First(&conn).Error; err != nil || conn.ClientSecret == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Save an OAuth client ID and secret first", nil, "")
+	}
+
+	nonce := generateRandomString(32)
+	stateJSON, _ := json.Marshal(CRMState{OrgID: orgID, UserID: userID, Provider: provider})
+	if err := a.Redis.Set(r.RequestCtx, "crm:state:"+nonce, stateJSON, crmStateTTL).Err(); err != nil {
+		a.Log.Error("Failed to store CRM state", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start authorization", nil, "")
+	}
+
+	oauthConfig := crmOAuthConfig(&conn, a.oauthCallbackURL(r, crmCallbackPath))
+	authURL := oauthConfig.AuthCodeURL(nonce, oauth2.AccessTypeOffline)
+
+	return r.SendEnvelope(map[string]string{"auth_url": authURL})
+}
+
+// CRMCallback completes the OAuth flow and stores the tokens (public, state-checked)
+func (a *App) CRMCallback(r *fastglue.Request) error {
+	code := string(r.RequestCtx.QueryArgs().Peek("code"))
+	nonce := string(r.RequestCtx.QueryArgs().Peek("state"))
+
+	if errorParam := string(r.RequestCtx.QueryArgs().Peek("error")); errorParam != "" {
+		a.redirectToCRMSettings(r, "", "error", "CRM authorization failed: "+errorParam)
+		return nil
+	}
+	if code == "" || nonce == "" {
+		a.redirectToCRMSettings(r, "", "error", "Invalid callback parameters")
+		return nil
+	}
+
+	stateKey := "crm:state:" + nonce
+	stateJSON, err := a.Redis.Get(r.RequestCtx, stateKey).Bytes()
+	if err != nil {
+		a.redirectToCRMSettings(r, "", "error", "Invalid or expired state")
+		return nil
+	}
+	// Delete state immediately to prevent replay
+	a.Redis.Del(r.RequestCtx, stateKey)
+
+	var state CRMState
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		a.redirectToCRMSettings(r, "", "error", "Invalid state")
+		return nil
+	}
+
+	var conn models.CRMConnection
+	if err := a.DB.Where("organization_id = ? AND provider = ?", state.OrgID, state.Provider).First(&conn).Error; err != nil {
+		a.redirectToCRMSettings(r, state.Provider, "error", "The CRM is not configured")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	token, err := crmOAuthConfig(&conn, a.oauthCallbackURL(r, crmCallbackPath)).Exchange(ctx, code)
+	if err != nil {
+		a.Log.Error("Failed to exchange CRM OAuth code", "error", err, "provider", state.Provider, "organization_id", state.OrgID)
+		a.redirectToCRMSettings(r, state.Provider, "error", "Failed to authorize with the CRM")
+		return nil
+	}
+
+	now := time.Now()
+	conn.ConnectedByID = &state.UserID
+	conn.ConnectedAt = &now
+	conn.SyncError = ""
+	applyCRMToken(&conn, token)
+	if err := a.DB.Save(&conn).Error; err != nil {
+		a.Log.Error("Failed to save CRM tokens", "error", err)
+		a.redirectToCRMSettings(r, state.Provider, "error", "Failed to save the CRM authorization")
+		return nil
+	}
+
+	a.Log.Info("CRM connected", "provider", state.Provider, "organization_id", state.OrgID)
+	a.redirectToCRMSettings(r, state.Provider, "connected", "")
+	return nil
+}
+
+// DisconnectCRM removes the stored tokens and contact links, keeping the OAuth client
+// and sync options
+func (a *App) DisconnectCRM(r *fastglue.Request) error {
+	orgID, provider, ok := a.crmRequestContext(r, models.ActionWrite)
+	if !ok {
+		return nil
+	}
+
+	var conn models.CRMConnection
+	if err := a.DB.Where("organization_id = ? AND provider = ?", orgID, provider).First(&conn).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "CRM is not connected", nil, "")
+	}
+	clearCRMTokens(&conn)
+	if err := a.DB.Save(&conn).Error; err != nil {
+		a.Log.Error("Failed to disconnect CRM", "error", err, "provider", provider)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to disconnect the CRM", nil, "")
+	}
+	// Another CRM account has different record IDs
+	a.DB.Unscoped().Where("connection_id = ?", conn.ID).Delete(&models.CRMContactLink{})
+
+	return r.SendEnvelope(map[string]string{"message": "CRM disconnected"})
+}
+
+// SyncCRMConnection runs a sync with the CRM now. With "full", contacts are pushed again
+// from the start.
+func (a *App) SyncCRMConnection(r *fastglue.Request) error {
+	orgID, provider, ok := a.crmRequestContext(r, models.ActionWrite)
+	if !ok {
+		return nil
+	}
+	var body struct {
+		Full bool `json:"full"`
+	}
+	_ = r.Decode(&body, "json")
+
+	var conn models.CRMConnection
+	if err := a.DB.Where("organization_id = ? AND provider = ?", orgID, provider).First(&conn).Error; err != nil || conn.RefreshToken == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Connect the CRM first", nil, "")
+	}
+	if body.Full {
+		conn.ContactsSyncedThrough = nil
+	}
+
+	result, err := a.syncCRMConnection(&conn)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, err.Error(), nil, "")
+	}
+	return r.SendEnvelope(result)
+}
+
+// crmRequestContext checks settings permissions and the provider path parameter. On
+// failure it sends the error response and returns false.
+func (a *App) crmRequestContext(r *fastglue.Request, action string) (uuid.UUID, string, bool) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+		return uuid.Nil, "", false
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, action) {
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		return uuid.Nil, "", false
+	}
+	provider, _ := r.RequestCtx.UserValue("provider").(string)
+	if !integrations.IsSupported(provider) {
+		_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unsupported CRM provider", nil, "")
+		return uuid.Nil, "", false
+	}
+	return orgID, provider, true
+}
+
+// crmConnectionResponse describes a connection for the API
+func (a *App) crmConnectionResponse(conn *models.CRMConnection) CRMConnectionResponse {
+	resp := CRMConnectionResponse{
+		Provider:         conn.Provider,
+		ClientID:         conn.ClientID,
+		HasSecret:        conn.ClientSecret != "",
+		Connected:        conn.RefreshToken != "",
+		InstanceURL:      conn.InstanceURL,
+		ConnectedAt:      conn.ConnectedAt,
+		PushContacts:     conn.PushContacts,
+		PushSummaries:    conn.PushSummaries,
+		FieldMappings:    crmFieldMappings(conn),
+		SyncIntervalMins: conn.SyncIntervalMins,
+		LastSyncedAt:     conn.LastSyncedAt,
+		SyncError:        conn.SyncError,
+	}
+	if conn.ID != uuid.Nil {
+		a.DB.Model(&models.CRMContactLink{}).Where("connection_id = ?", conn.ID).Count(&resp.LinkedContacts)
+	}
+	return resp
+}
+
+// validateCRMFieldMappings checks CRM property names and custom field names, dropping
+// mappings with an empty custom field
+func validateCRMFieldMappings(mappings map[string]string) (models.JSONB, error) {
+	if len(mappings) > maxCRMFieldMappings {
+		return nil, fmt.Errorf("at most %d fields can be mapped", maxCRMFieldMappings)
+	}
+	result := make(models.JSONB, len(mappings))
+	for property, field := range mappings {
+		property, field = strings.TrimSpace(property), strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !integrations.ValidPropertyName(property) {
+			return nil, fmt.Errorf("invalid CRM property name %q", property)
+		}
+		if len(field) > maxContactFieldNameLength {
+			return nil, fmt.Errorf("custom field name %q is too long", field)
+		}
+		result[property] = field
+	}
+	return result, nil
+}
+
+// crmFieldMappings returns a connection's CRM property -> custom field mappings
+func crmFieldMappings(conn *models.CRMConnection) map[string]string {
+	mappings := make(map[string]string, len(conn.FieldMappings))
+	for property, field := range conn.FieldMappings {
+		if name, ok := field.(string); ok && name != "" {
+			mappings[property] = name
+		}
+	}
+	return mappings
+}
+
+// crmOAuthConfig builds the OAuth config for an organization's CRM app
+func crmOAuthConfig(conn *models.CRMConnection, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     conn.ClientID,
+		ClientSecret: conn.ClientSecret,
+		Endpoint:     integrations.Endpoint(conn.Provider),
+		Scopes:       integrations.Scopes[conn.Provider],
+		RedirectURL:  redirectURL,
+	}
+}
+
+// redirectToCRMSettings sends the browser back to the integration settings page
+func (a *App) redirectToCRMSettings(r *fastglue.Request, provider, status, message string) {
+	basePath := a.Config.Server.BasePath
+	if basePath != "" && basePath[0] != '/' {
+		basePath = "/" + basePath
+	}
+	redirectURL := fmt.Sprintf("%s/settings?tab=integrations&crm=%s", basePath, status)
+	if provider != "" {
+		redirectURL += "&provider=" + url.QueryEscape(provider)
+	}
+	if message != "" {
+		redirectURL += "&message=" + url.QueryEscape(message)
+	}
+	r.RequestCtx.Redirect(redirectURL, fasthttp.StatusTemporaryRedirect)
+}
+
+// applyCRMToken copies token fields onto the connection. Refresh responses may omit the
+// refresh token and Salesforce's instance URL, so empty values keep the stored ones.
+func applyCRMToken(conn *models.CRMConnection, token *oauth2.Token) {
+	conn.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		conn.RefreshToken = token.RefreshToken
+	}
+	expiry := token.Expiry
+	if expiry.IsZero() {
+		expiry = time.Now().Add(crmDefaultTokenLifetime)
+	}
+	conn.TokenExpiry = &expiry
+	if instanceURL, _ := token.Extra("instance_url").(string); instanceURL != "" {
+		conn.InstanceURL = instanceURL
+	}
+}
+
+// clearCRMTokens forgets the connected CRM account and where the sync got to
+func clearCRMTokens(conn *models.CRMConnection) {
+	conn.AccessToken = ""
+	conn.RefreshToken = ""
+	conn.TokenExpiry = nil
+	conn.InstanceURL = ""
+	conn.ConnectedByID = nil
+	conn.ConnectedAt = nil
+	conn.ContactsSyncedThrough = nil
+	conn.LastSyncedAt = nil
+	conn.SyncError = ""
+}
+
+// crmConnector returns a connector for the connection's CRM, persisting refreshed tokens
+func (a *App) crmConnector(ctx context.Context, conn *models.CRMConnection) (integrations.Connector, error) {
+	if conn.RefreshToken == "" {
+		return nil, errCRMNotConnected
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  conn.AccessToken,
+		RefreshToken: conn.RefreshToken,
+		TokenType:    "Bearer",
+	}
+	if conn.TokenExpiry != nil {
+		token.Expiry = *conn.TokenExpiry
+	}
+
+	tokenSource := crmOAuthConfig(conn, "").TokenSource(ctx, token)
+	current, err := tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%s authorization expired, reconnect it: %w", conn.Provider, err)
+	}
+	if current.AccessToken != conn.AccessToken {
+		applyCRMToken(conn, current)
+		if err := a.DB.Model(conn).Select("access_token", "refresh_token", "token_expiry", "instance_url").Updates(conn).Error; err != nil {
+			a.Log.Error("Failed to save refreshed CRM token", "error", err, "provider", conn.Provider)
+		}
+	}
+
+	return integrations.NewConnector(conn.Provider, oauth2.NewClient(ctx, oauth2.StaticTokenSource(current)), conn.InstanceURL)
+}
+
+// syncCRMConnection pushes changed contacts and new conversation summaries to the CRM and
+// pulls mapped CRM properties into contact custom fields, then records the outcome.
+// Failures on single contacts are counted and the first is kept as the sync error.
+func (a *App) syncCRMConnection(conn *models.CRMConnection) (*CRMSyncResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), crmSyncTimeout)
+	defer cancel()
+
+	now := time.Now()
+	result := &CRMSyncResult{SyncedAt: now}
+	var firstErr error
+	fail := func(err error) {
+		result.Failed++
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	connector, err := a.crmConnector(ctx, conn)
+	if err == nil {
+		if conn.PushContacts {
+			a.pushCRMContacts(ctx, conn, connector, result, fail)
+		}
+		if conn.PushSummaries {
+			a.pushCRMSummaries(ctx, conn, connector, result, fail)
+		}
+		if len(conn.FieldMappings) > 0 {
+			a.pullCRMProperties(ctx, conn, connector, result, fail)
+		}
+		err = firstErr
+	}
+
+	conn.LastSyncedAt = &now
+	conn.SyncError = ""
+	if err != nil {
+		conn.SyncError = err.Error()
+	}
+	if saveErr := a.DB.Model(conn).Select("contacts_synced_through", "last_synced_at", "sync_error").Updates(conn).Error; saveErr != nil {
+		a.Log.Error("Failed to save CRM sync state", "error", saveErr, "connection_id", conn.ID)
+	}
+
+	if result.Failed == 0 && err != nil {
+		a.Log.Warn("CRM sync failed", "error", err, "provider", conn.Provider, "organization_id", conn.OrganizationID)
+		return nil, err
+	}
+	a.Log.Info("CRM synced", "provider", conn.Provider, "organization_id", conn.OrganizationID,
+		"pushed", result.ContactsPushed, "summaries", result.SummariesPushed, "pulled", result.ContactsPulled, "failed", result.Failed)
+	return result, nil
+}
+
+// pushCRMContacts creates or updates CRM contacts for contacts changed since the last
+// push, oldest first, and links them to their CRM records
+func (a *App) pushCRMContacts(ctx context.Context, conn *models.CRMConnection, connector integrations.Connector, result *CRMSyncResult, fail func(error)) {
+	query := a.DB.Select("id, phone_number, profile_name, updated_at").
+		Where("organization_id = ?", conn.OrganizationID).
+		Order("updated_at ASC, id ASC").
+		Limit(crmSyncBatchSize)
+	if conn.ContactsSyncedThrough != nil {
+		query = query.Where("updated_at > ?", *conn.ContactsSyncedThrough)
+	}
+	var contacts []models.Contact
+	if err := query.Find(&contacts).Error; err != nil {
+		fail(fmt.Errorf("failed to load contacts: %w", err))
+		return
+	}
+
+	for _, contact := range contacts {
+		if ctx.Err() != nil {
+			return
+		}
+		firstName, lastName := integrations.SplitName(contact.ProfileName)
+		recordID, err := connector.UpsertContact(ctx, integrations.Contact{
+			Phone:     "+" + strings.TrimPrefix(contact.PhoneNumber, "+"),
+			FirstName: firstName,
+			LastName:  lastName,
+		})
+		// A failed contact isn't retried until it changes again; the error is reported
+		// on the connection
+		updatedAt := contact.UpdatedAt
+		conn.ContactsSyncedThrough = &updatedAt
+		if err != nil {
+			fail(fmt.Errorf("contact %s: %w", contact.PhoneNumber, err))
+			continue
+		}
+		if err := a.linkCRMContact(conn, contact.ID, recordID); err != nil {
+			fail(err)
+			continue
+		}
+		result.ContactsPushed++
+	}
+}
+
+// linkCRMContact records the CRM record a contact was synced to
+func (a *App) linkCRMContact(conn *models.CRMConnection, contactID uuid.UUID, recordID string) error {
+	var link models.CRMContactLink
+	if err := a.DB.Where("connection_id = ? AND contact_id = ?", conn.ID, contactID).First(&link).Error; err == nil {
+		if link.RecordID == recordID {
+			return nil
+		}
+		// A different record has none of the old one's notes
+		return a.DB.Model(&link).Updates(map[string]interface{}{"record_id": recordID, "summary_pushed_at": nil}).Error
+	}
+	return a.DB.Create(&models.CRMContactLink{
+		OrganizationID: conn.OrganizationID,
+		ConnectionID:   conn.ID,
+		ContactID:      contactID,
+		RecordID:       recordID,
+	}).Error
+}
+
+// crmSummaryRow is a linked contact whose conversation summary changed since it was logged
+type crmSummaryRow struct {
+	LinkID       uuid.UUID
+	RecordID     string
+	Facts        string
+	SummarizedAt time.Time
+}
+
+// pushCRMSummaries logs the conversation summaries of linked contacts that changed since
+// they were last logged
+func (a *App) pushCRMSummaries(ctx context.Context, conn *models.CRMConnection, connector integrations.Connector, result *CRMSyncResult, fail func(error)) {
+	var rows []crmSummaryRow
+	if err := a.DB.Table("crm_contact_links AS l").
+		Select("l.id AS link_id, l.record_id, m.facts, m.summarized_at").
+		Joins("JOIN contact_memories m ON m.contact_id = l.contact_id AND m.deleted_at IS NULL").
+		Where("l.connection_id = ? AND l.deleted_at IS NULL", conn.ID).
+		Where("m.summarized_at IS NOT NULL AND m.facts <> ''").
+		Where("l.summary_pushed_at IS NULL OR m.summarized_at > l.summary_pushed_at").
+		Order("m.summarized_at ASC").
+		Limit(crmSyncBatchSize).
+		Scan(&rows).Error; err != nil {
+		fail(fmt.Errorf("failed to load conversation summaries: %w", err))
+		return
+	}
+
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			return
+		}
+		err := connector.AddNote(ctx, row.RecordID, integrations.Note{
+			Title: crmSummaryTitle,
+			Body:  row.Facts,
+			Time:  row.SummarizedAt,
+		})
+		if err != nil {
+			a.handleCRMRecordError(row.LinkID, err, fail)
+			continue
+		}
+		a.DB.Model(&models.CRMContactLink{}).Where("id = ?", row.LinkID).Update("summary_pushed_at", row.SummarizedAt)
+		result.SummariesPushed++
+	}
+}
+
+// pullCRMProperties reads the mapped CRM properties of the linked contacts that were read
+// longest ago into their custom fields
+func (a *App) pullCRMProperties(ctx context.Context, conn *models.CRMConnection, connector integrations.Connector, result *CRMSyncResult, fail func(error)) {
+	mappings := crmFieldMappings(conn)
+	properties := make([]string, 0, len(mappings))
+	for property := range mappings {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	var links []models.CRMContactLink
+	if err := a.DB.Where("connection_id = ?", conn.ID).
+		Order("pulled_at ASC NULLS FIRST, id ASC").
+		Limit(crmSyncBatchSize).
+		Find(&links).Error; err != nil {
+		fail(fmt.Errorf("failed to load linked contacts: %w", err))
+		return
+	}
+
+	for _, link := range links {
+		if ctx.Err() != nil {
+			return
+		}
+		values, err := connector.FetchProperties(ctx, link.RecordID, properties)
+		if err != nil {
+			a.handleCRMRecordError(link.ID, err, fail)
+			continue
+		}
+
+		var contact models.Contact
+		if err := a.DB.Select("id, metadata").Where("id = ? AND organization_id = ?", link.ContactID, conn.OrganizationID).First(&contact).Error; err != nil {
+			a.DB.Unscoped().Delete(&link)
+			continue
+		}
+		if contact.Metadata == nil {
+			contact.Metadata = models.JSONB{}
+		}
+		changed := false
+		for _, property := range properties {
+			value, ok := values[property]
+			if !ok {
+				continue
+			}
+			field := mappings[property]
+			if current, _ := contact.Metadata[field].(string); current != value {
+				contact.Metadata[field] = value
+				changed = true
+			}
+		}
+		// UpdateColumn leaves updated_at alone, so pulled fields aren't pushed back
+		if changed {
+			if err := a.DB.Model(&contact).UpdateColumn("metadata", contact.Metadata).Error; err != nil {
+				fail(fmt.Errorf("failed to save custom fields: %w", err))
+				continue
+			}
+		}
+		a.DB.Model(&link).UpdateColumn("pulled_at", time.Now())
+		result.ContactsPulled++
+	}
+}
+
+// handleCRMRecordError counts a failed record call. A record deleted in the CRM is
+// unlinked so the contact is recreated when it next changes.
+func (a *App) handleCRMRecordError(linkID uuid.UUID, err error, fail func(error)) {
+	if errors.Is(err, integrations.ErrRecordNotFound) {
+		a.DB.Unscoped().Where("id = ?", linkID).Delete(&models.CRMContactLink{})
+		return
+	}
+	fail(err)
+}
+
+// CRMSyncProcessor periodically syncs CRM connections whose sync interval has elapsed
+type CRMSyncProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewCRMSyncProcessor creates a new CRM sync processor
+func NewCRMSyncProcessor(app *App, interval time.Duration) *CRMSyncProcessor {
+	return &CRMSyncProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the sync loop
+func (p *CRMSyncProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("CRM sync processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("CRM sync processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("CRM sync processor stopped")
+			return
+		case <-ticker.C:
+			p.syncDueConnections(ctx)
+		}
+	}
+}
+
+// Stop stops the CRM sync processor
+func (p *CRMSyncProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// syncDueConnections syncs every connected CRM whose sync interval has elapsed
+func (p *CRMSyncProcessor) syncDueConnections(ctx context.Context) {
+	var conns []models.CRMConnection
+	if err := p.app.DB.Where("refresh_token <> '' AND sync_interval_mins > 0").
+		Where("last_synced_at IS NULL OR last_synced_at + make_interval(mins => sync_interval_mins) <= ?", time.Now()).
+		Find(&conns).Error; err != nil {
+		p.app.Log.Error("Failed to load CRM connections for sync", "error", err)
+		return
+	}
+
+	for i := range conns {
+		if ctx.Err() != nil {
+			return
+		}
+		_, _ = p.app.syncCRMConnection(&conns[i])
+	}
+}
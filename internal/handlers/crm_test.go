@@ -0,0 +1,156 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/integrations"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// fakeHubSpot keeps contacts by phone number and records the notes logged on them
+type fakeHubSpot struct {
+	mu       sync.Mutex
+	contacts map[string]string // phone -> record ID
+	notes    []string
+}
+
+func (h *fakeHubSpot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	switch r.Method + " " + r.URL.Path {
+	case "POST /crm/v3/objects/contacts/search":
+		phone := body["filterGroups"].([]any)[0].(map[string]any)["filters"].([]any)[0].(map[string]any)["value"].(string)
+		results := []map[string]string{}
+		if id, ok := h.contacts[phone]; ok {
+			results = append(results, map[string]string{"id": id})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": results})
+	case "POST /crm/v3/objects/contacts":
+		phone := body["properties"].(map[string]any)["phone"].(string)
+		h.contacts[phone] = "hs-" + phone
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": h.contacts[phone]})
+	case "POST /crm/v3/objects/notes":
+		h.notes = append(h.notes, body["properties"].(map[string]any)["hs_note_body"].(string))
+		_, _ = w.Write([]byte(`{"id":"note"}`))
+	default:
+		_, _ = w.Write([]byte(`{"id":"x","properties":{"lifecyclestage":"customer","hs_lead_status":"OPEN"}}`))
+	}
+}
+
+func TestApp_CRMSync(t *testing.T) {
+	app := testApp(t)
+	hubspot := &fakeHubSpot{contacts: map[string]string{}}
+	server := httptest.NewServer(hubspot)
+	t.Cleanup(server.Close)
+	orig := integrations.HubSpotBaseURL
+	integrations.HubSpotBaseURL = server.URL
+	t.Cleanup(func() { integrations.HubSpotBaseURL = orig })
+
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("crm-sync"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	// Save the OAuth client and sync options
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"client_id":          "client",
+		"client_secret":      "secret",
+		"push_summaries":     false,
+		"sync_interval_mins": 0,
+		"field_mappings":     map[string]string{"lifecyclestage": "crm_stage", "hs_lead_status": ""},
+	})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "provider", integrations.ProviderHubSpot)
+	require.NoError(t, app.UpdateCRMConnection(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var conn models.CRMConnection
+	require.NoError(t, app.DB.Where("organization_id = ? AND provider = ?", org.ID, integrations.ProviderHubSpot).First(&conn).Error)
+	assert.True(t, conn.PushContacts)
+	assert.False(t, conn.PushSummaries, "false options survive the column defaults")
+	assert.Equal(t, 0, conn.SyncIntervalMins)
+	assert.Equal(t, models.JSONB{"lifecyclestage": "crm_stage"}, conn.FieldMappings)
+
+	// Pretend the OAuth flow finished
+	expiry := time.Now().Add(time.Hour)
+	require.NoError(t, app.DB.Model(&conn).Updates(map[string]any{
+		"access_token": "token", "refresh_token": "refresh", "token_expiry": expiry, "push_summaries": true,
+	}).Error)
+
+	contact := &models.Contact{OrganizationID: org.ID, PhoneNumber: "14155550100", ProfileName: "Ada Lovelace", Metadata: models.JSONB{}}
+	require.NoError(t, app.DB.Create(contact).Error)
+	now := time.Now()
+	require.NoError(t, app.DB.Create(&models.ContactMemory{
+		OrganizationID: org.ID, ContactID: contact.ID, Facts: "Wants a refund", SummarizedAt: &now,
+	}).Error)
+
+	runSync := func() handlers.CRMSyncResult {
+		req := testutil.NewJSONRequest(t, map[string]any{})
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "provider", integrations.ProviderHubSpot)
+		require.NoError(t, app.SyncCRMConnection(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var result handlers.CRMSyncResult
+		testutil.ParseEnvelopeResponse(t, req, &result)
+		return result
+	}
+
+	// The contact is created, its summary logged and the mapped property pulled back
+	result := runSync()
+	assert.Equal(t, 1, result.ContactsPushed)
+	assert.Equal(t, 1, result.SummariesPushed)
+	assert.Equal(t, 1, result.ContactsPulled)
+	assert.Equal(t, 0, result.Failed)
+	assert.Equal(t, "hs-+14155550100", hubspot.contacts["+14155550100"])
+	require.Len(t, hubspot.notes, 1)
+	assert.Contains(t, hubspot.notes[0], "Wants a refund")
+
+	var stored models.Contact
+	require.NoError(t, app.DB.Where("id = ?", contact.ID).First(&stored).Error)
+	assert.Equal(t, "customer", stored.Metadata["crm_stage"])
+	assert.NotContains(t, stored.Metadata, "hs_lead_status")
+
+	// Pulled fields don't count as changes, and summaries are logged once
+	result = runSync()
+	assert.Equal(t, 0, result.ContactsPushed)
+	assert.Equal(t, 0, result.SummariesPushed)
+	assert.Len(t, hubspot.notes, 1)
+
+	var link models.CRMContactLink
+	require.NoError(t, app.DB.Where("contact_id = ?", contact.ID).First(&link).Error)
+	assert.Equal(t, "hs-+14155550100", link.RecordID)
+}
+
+func TestApp_UpdateCRMConnection_Invalid(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("crm-invalid"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	for _, tc := range []struct {
+		provider string
+		body     map[string]any
+	}{
+		{"pipedrive", map[string]any{"client_id": "c", "client_secret": "s"}},
+		{integrations.ProviderSalesforce, map[string]any{"client_id": "c"}},
+		{integrations.ProviderSalesforce, map[string]any{"client_id": "c", "client_secret": "s", "field_mappings": map[string]string{"Name, Email": "x"}}},
+		{integrations.ProviderSalesforce, map[string]any{"client_id": "c", "client_secret": "s", "sync_interval_mins": -1}},
+	} {
+		req := testutil.NewJSONRequest(t, tc.body)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "provider", tc.provider)
+		require.NoError(t, app.UpdateCRMConnection(req))
+		assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req), "%s %v", tc.provider, tc.body)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+)
+
+const (
+	// csatButtonPrefix prefixes the button IDs of rating survey options (csat:<rating_id>:<score>)
+	csatButtonPrefix = "csat:"
+	// csatCommentWindow is how long a follow-up comment is accepted after rating
+	csatCommentWindow = 30 * time.Minute
+
+	defaultCSATQuestion        = "How would you rate your conversation with our team?"
+	defaultCSATThankYouMessage = "Thank you for your feedback!"
+)
+
+// csatOptions are the rating choices shown to the contact, best first
+var csatOptions = []struct {
+	Score int
+	Title string
+}{
+	{5, "⭐⭐⭐⭐⭐ Excellent"},
+	{4, "⭐⭐⭐⭐ Good"},
+	{3, "⭐⭐⭐ Okay"},
+	{2, "⭐⭐ Poor"},
+	{1, "⭐ Very poor"},
+}
+
+// sendCSATSurvey asks the contact to rate a closed transfer if surveys are enabled for the account
+func (a *App) sendCSATSurvey(transfer models.AgentTransfer) {
+	settings, err := a.getChatbotSettingsCached(transfer.OrganizationID, transfer.WhatsAppAccount)
+	if err != nil || !settings.CSAT.Enabled {
+		return
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", transfer.WhatsAppAccount, transfer.OrganizationID).First(&account).Error; err != nil {
+		a.Log.Error("Failed to load account for CSAT survey", "error", err, "account", transfer.WhatsAppAccount)
+		return
+	}
+
+	var contact models.Contact
+	if err := a.DB.Where("id = ?", transfer.ContactID).First(&contact).Error; err != nil {
+		a.Log.Error("Failed to load contact for CSAT survey", "error", err, "contact_id", transfer.ContactID)
+		return
+	}
+
+	rating := models.ChatRating{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  transfer.OrganizationID,
+		WhatsAppAccount: transfer.WhatsAppAccount,
+		ContactID:       transfer.ContactID,
+		TransferID:      &transfer.ID,
+		AgentID:         transfer.AgentID,
+		Status:          models.CSATStatusPending,
+	}
+	if err := a.DB.Create(&rating).Error; err != nil {
+		a.Log.Error("Failed to create chat rating", "error", err, "transfer_id", transfer.ID)
+		return
+	}
+
+	question := settings.CSAT.Question
+	if question == "" {
+		question = defaultCSATQuestion
+	}
+
+	buttons := make([]whatsapp.Button, len(csatOptions))
+	for i, opt := range csatOptions {
+		buttons[i] = whatsapp.Button{
+			ID:    fmt.Sprintf("%s%s:%d", csatButtonPrefix, rating.ID, opt.Score),
+			Title: opt.Title,
+		}
+	}
+
+	// Five options don't fit in reply buttons, so the survey is sent as a list
+	if _, err := a.SendOutgoingMessage(context.Background(), OutgoingMessageRequest{
+		Account:         &account,
+		Contact:         &contact,
+		Type:            models.MessageTypeInteractive,
+		InteractiveType: "list",
+		BodyText:        question,
+		Buttons:         buttons,
+	}, SLASendOptions()); err != nil {
+		a.Log.Error("Failed to send CSAT survey", "error", err, "contact_id", contact.ID)
+		a.DB.Delete(&rating)
+		return
+	}
+
+	a.Log.Info("CSAT survey sent", "transfer_id", transfer.ID, "contact_id", contact.ID)
+}
+
+// handleCSATResponse records a survey answer or follow-up comment.
+// Returns true if the message was consumed by the survey and should not reach the chatbot.
+func (a *App) handleCSATResponse(account *models.WhatsAppAccount, contact *models.Contact, buttonID, messageText string) bool {
+	if ratingID, score, ok := parseCSATButtonID(buttonID); ok {
+		var rating models.ChatRating
+		if err := a.DB.Where("id = ? AND contact_id = ?", ratingID, contact.ID).First(&rating).Error; err != nil {
+			a.Log.Warn("CSAT rating not found", "rating_id", ratingID, "contact_id", contact.ID)
+			return true
+		}
+		if rating.Status != models.CSATStatusPending {
+			// Already answered - ignore repeated taps
+			return true
+		}
+
+		settings, _ := a.getChatbotSettingsCached(account.OrganizationID, account.Name)
+
+		now := time.Now()
+		status := models.CSATStatusCompleted
+		if settings != nil && settings.CSAT.CommentPrompt != "" {
+			status = models.CSATStatusAwaitingComment
+		}
+		if err := a.DB.Model(&rating).Updates(map[string]interface{}{
+			"rating":   score,
+			"status":   status,
+			"rated_at": now,
+		}).Error; err != nil {
+			a.Log.Error("Failed to save chat rating", "error", err, "rating_id", rating.ID)
+			return true
+		}
+
+		if status == models.CSATStatusAwaitingComment {
+			a.sendCSATMessage(account, contact, settings.CSAT.CommentPrompt)
+		} else {
+			a.sendCSATThankYou(account, contact, settings)
+		}
+		return true
+	}
+
+	if buttonID != "" || strings.TrimSpace(messageText) == "" {
+		return false
+	}
+
+	// A free-text reply right after rating is taken as the follow-up comment
+	var rating models.ChatRating
+	if err := a.DB.Where("contact_id = ? AND status = ? AND rated_at > ?",
+		contact.ID, models.CSATStatusAwaitingComment, time.Now().Add(-csatCommentWindow)).
+		Order("rated_at DESC").First(&rating).Error; err != nil {
+		return false
+	}
+
+	if err := a.DB.Model(&rating).Updates(map[string]interface{}{
+		"comment": strings.TrimSpace(messageText),
+		"status":  models.CSATStatusCompleted,
+	}).Error; err != nil {
+		a.Log.Error("Failed to save chat rating comment", "error", err, "rating_id", rating.ID)
+		return false
+	}
+
+	settings, _ := a.getChatbotSettingsCached(account.OrganizationID, account.Name)
+	a.sendCSATThankYou(account, contact, settings)
+	return true
+}
+
+func (a *App) sendCSATThankYou(account *models.WhatsAppAccount, contact *models.Contact, settings *models.ChatbotSettings) {
+	message := defaultCSATThankYouMessage
+	if settings != nil && settings.CSAT.ThankYouMessage != "" {
+		message = settings.CSAT.ThankYouMessage
+	}
+	a.sendCSATMessage(account, contact, message)
+}
+
+func (a *App) sendCSATMessage(account *models.WhatsAppAccount, contact *models.Contact, message string) {
+	if _, err := a.SendOutgoingMessage(context.Background(), OutgoingMessageRequest{
+		Account: account,
+		Contact: contact,
+		Type:    models.MessageTypeText,
+		Content: message,
+	}, SLASendOptions()); err != nil {
+		a.Log.Error("Failed to send CSAT message", "error", err, "contact_id", contact.ID)
+	}
+}
+
+// parseCSATButtonID extracts the rating ID and score from a survey button ID
+func parseCSATButtonID(buttonID string) (uuid.UUID, int, bool) {
+	if !strings.HasPrefix(buttonID, csatButtonPrefix) {
+		return uuid.Nil, 0, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(buttonID, csatButtonPrefix), ":")
+	if len(parts) != 2 {
+		return uuid.Nil, 0, false
+	}
+
+	ratingID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, 0, false
+	}
+	score, err := strconv.Atoi(parts[1])
+	if err != nil || score < 1 || score > 5 {
+		return uuid.Nil, 0, false
+	}
+
+	return ratingID, score, true
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSATButtonID_Valid(t *testing.T) {
+	ratingID := uuid.New()
+
+	id, score, ok := parseCSATButtonID(fmt.Sprintf("csat:%s:4", ratingID))
+	assert.True(t, ok)
+	assert.Equal(t, ratingID, id)
+	assert.Equal(t, 4, score)
+}
+
+func TestParseCSATButtonID_Invalid(t *testing.T) {
+	ratingID := uuid.New().String()
+
+	tests := []string{
+		"",
+		"btn_1",
+		"csat:" + ratingID,
+		"csat:not-a-uuid:3",
+		"csat:" + ratingID + ":0",
+		"csat:" + ratingID + ":6",
+		"csat:" + ratingID + ":abc",
+	}
+
+	for _, buttonID := range tests {
+		_, _, ok := parseCSATButtonID(buttonID)
+		assert.False(t, ok, buttonID)
+	}
+}
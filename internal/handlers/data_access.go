@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// restrictedDataAccessMessage is returned by endpoints that only serve contact details or
+// message content, which roles with restricted data access can't see
+const restrictedDataAccessMessage = "Your role can't view contact details or message content"
+
+// dataMask hides contact details in responses. Phone numbers are masked when the
+// organization masks them or the user's role restricts data access; restricted roles
+// also get masked names and no message content.
+type dataMask struct {
+	phones     bool
+	restricted bool
+}
+
+// dataMaskFor returns the mask to apply to what the user sees in the organization
+func (a *App) dataMaskFor(orgID, userID uuid.UUID) dataMask {
+	return dataMask{
+		phones:     a.ShouldMaskPhoneNumbers(orgID),
+		restricted: a.HasRestrictedDataAccess(userID, orgID),
+	}
+}
+
+// Phone masks a phone number
+func (m dataMask) Phone(phone string) string {
+	if m.phones || m.restricted {
+		return MaskPhoneNumber(phone)
+	}
+	return phone
+}
+
+// Name masks a contact name. Names that are phone numbers are masked like one.
+func (m dataMask) Name(name string) string {
+	if m.restricted {
+		if LooksLikePhoneNumber(name) {
+			return MaskPhoneNumber(name)
+		}
+		return MaskName(name)
+	}
+	if m.phones {
+		return MaskIfPhoneNumber(name)
+	}
+	return name
+}
+
+// Content hides message content from restricted roles
+func (m dataMask) Content(content string) string {
+	if m.restricted {
+		return ""
+	}
+	return content
+}
+
+// CustomFields hides a contact's custom field values from restricted roles
+func (m dataMask) CustomFields(fields models.JSONB) models.JSONB {
+	if m.restricted {
+		return models.JSONB{}
+	}
+	return fields
+}
+
+// Recipient masks a campaign recipient in place. Restricted roles don't see the
+// template parameters, which usually carry the recipient's details.
+func (m dataMask) Recipient(rec *models.BulkMessageRecipient) {
+	rec.PhoneNumber = m.Phone(rec.PhoneNumber)
+	rec.RecipientName = m.Name(rec.RecipientName)
+	if m.restricted {
+		rec.TemplateParams = models.JSONB{}
+	}
+}
+
+// MaskName keeps the first letter of a name and hides the rest
+func MaskName(name string) string {
+	if name == "" {
+		return ""
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return string(r) + "***"
+}
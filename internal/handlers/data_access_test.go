@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataMask(t *testing.T) {
+	var none dataMask
+	assert.Equal(t, "919876543210", none.Phone("919876543210"))
+	assert.Equal(t, "Ada Lovelace", none.Name("Ada Lovelace"))
+	assert.Equal(t, "Hello", none.Content("Hello"))
+
+	phones := dataMask{phones: true}
+	assert.Equal(t, "********3210", phones.Phone("919876543210"))
+	assert.Equal(t, "Ada Lovelace", phones.Name("Ada Lovelace"))
+	assert.Equal(t, "********3210", phones.Name("919876543210"))
+	assert.Equal(t, "Hello", phones.Content("Hello"))
+
+	restricted := dataMask{restricted: true}
+	assert.Equal(t, "********3210", restricted.Phone("919876543210"))
+	assert.Equal(t, "A***", restricted.Name("Ada Lovelace"))
+	assert.Equal(t, "********3210", restricted.Name("919876543210"))
+	assert.Empty(t, restricted.Content("Hello"))
+	assert.Empty(t, restricted.CustomFields(models.JSONB{"email": "ada@example.com"}))
+
+	rec := models.BulkMessageRecipient{
+		PhoneNumber:    "919876543210",
+		RecipientName:  "Ada",
+		TemplateParams: models.JSONB{"1": "Ada"},
+	}
+	restricted.Recipient(&rec)
+	assert.Equal(t, "********3210", rec.PhoneNumber)
+	assert.Equal(t, "A***", rec.RecipientName)
+	assert.Empty(t, rec.TemplateParams)
+}
+
+func TestMaskName(t *testing.T) {
+	assert.Equal(t, "", MaskName(""))
+	assert.Equal(t, "É***", MaskName("Émile Zola"))
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// dataRegionsDir prefixes the stored paths of files written to a storage region. Paths
+// name their region, so files stay readable after an organization moves to another one.
+const dataRegionsDir = "regions"
+
+// DataRegionResponse is a storage region organizations can be pinned to
+type DataRegionResponse struct {
+	Name          string `json:"name"` // Empty for the default storage
+	Label         string `json:"label"`
+	Default       bool   `json:"default"`
+	Organizations int64  `json:"organizations"`
+}
+
+// SetDataRegionRequest is the body of a data region change; an empty region selects the
+// default storage
+type SetDataRegionRequest struct {
+	Region string `json:"region"`
+}
+
+// storageRegionPath returns the local path of a configured storage region
+func (a *App) storageRegionPath(region string) (string, bool) {
+	cfg, ok := a.Config.Storage.Regions[region]
+	if !ok || cfg.LocalPath == "" {
+		return "", false
+	}
+	return cfg.LocalPath, true
+}
+
+// dataRegionLabel returns the display label of a region, "" being the default storage
+func (a *App) dataRegionLabel(region string) string {
+	if region == "" {
+		return a.Config.Storage.RegionLabel
+	}
+	if cfg, ok := a.Config.Storage.Regions[region]; ok && cfg.Label != "" {
+		return cfg.Label
+	}
+	return region
+}
+
+// getOrgDataRegion returns the storage region of an organization, "" for the default storage
+func (a *App) getOrgDataRegion(orgID uuid.UUID) string {
+	if orgID == uuid.Nil {
+		return ""
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgDataRegionCachePrefix, orgID.String())
+
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			return cached
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id, data_region").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return ""
+	}
+
+	if a.Redis != nil {
+		a.Redis.Set(ctx, cacheKey, org.DataRegion, orgDataRegionCacheTTL)
+	}
+	return org.DataRegion
+}
+
+// InvalidateOrgDataRegionCache invalidates the cached storage region of an organization
+func (a *App) InvalidateOrgDataRegionCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgDataRegionCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
+
+// ensureMediaDir creates subdir in the organization's storage region and returns its
+// path relative to the media root. Nothing is written if the organization's region is no
+// longer configured, rather than falling back to the default storage.
+func (a *App) ensureMediaDir(orgID uuid.UUID, subdir string) (string, error) {
+	dir := subdir
+	if region := a.getOrgDataRegion(orgID); region != "" {
+		if _, ok := a.storageRegionPath(region); !ok {
+			return "", fmt.Errorf("storage region %q is not configured", region)
+		}
+		dir = filepath.Join(dataRegionsDir, region, subdir)
+	}
+	if err := os.MkdirAll(a.mediaFullPath(dir), 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// mediaFullPath resolves a stored relative path to its location on disk, in the storage
+// region it names or else under the default storage path
+func (a *App) mediaFullPath(relPath string) string {
+	if rest, ok := strings.CutPrefix(filepath.ToSlash(relPath), dataRegionsDir+"/"); ok {
+		region, path, _ := strings.Cut(rest, "/")
+		if root, ok := a.storageRegionPath(region); ok {
+			return filepath.Join(root, path)
+		}
+	}
+	return filepath.Join(a.getMediaStoragePath(), relPath)
+}
+
+// ListDataRegions lists the storage regions organizations can be pinned to, with how many
+// organizations use each (super admin only)
+func (a *App) ListDataRegions(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+
+	var counts []struct {
+		DataRegion string
+		Count      int64
+	}
+	if err := a.DB.Model(&models.Organization{}).
+		Select("data_region, COUNT(*) AS count").
+		Group("data_region").
+		Scan(&counts).Error; err != nil {
+		a.Log.Error("Failed to count organizations by data region", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list data regions", nil, "")
+	}
+	orgs := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		orgs[c.DataRegion] = c.Count
+	}
+
+	names := make([]string, 0, len(a.Config.Storage.Regions))
+	for name := range a.Config.Storage.Regions {
+		if _, ok := a.storageRegionPath(name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	regions := []DataRegionResponse{{
+		Label:         a.dataRegionLabel(""),
+		Default:       true,
+		Organizations: orgs[""],
+	}}
+	for _, name := range names {
+		regions = append(regions, DataRegionResponse{
+			Name:          name,
+			Label:         a.dataRegionLabel(name),
+			Organizations: orgs[name],
+		})
+	}
+
+	return r.SendEnvelope(map[string]any{"regions": regions})
+}
+
+// SetOrganizationDataRegion pins an organization's files to a storage region (super admin
+// only). Only new files go to the region; existing files stay where they were written.
+func (a *App) SetOrganizationDataRegion(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+	adminID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	org, err := a.loadAdminOrganization(r)
+	if err != nil || org == nil {
+		return err
+	}
+
+	var req SetDataRegionRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Region = strings.TrimSpace(req.Region)
+	if req.Region != "" {
+		if _, ok := a.storageRegionPath(req.Region); !ok {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unknown data region", nil, "")
+		}
+	}
+	if req.Region == org.DataRegion {
+		return r.SendEnvelope(map[string]string{
+			"data_region":       org.DataRegion,
+			"data_region_label": a.dataRegionLabel(org.DataRegion),
+		})
+	}
+
+	if err := a.DB.Model(org).Update("data_region", req.Region).Error; err != nil {
+		a.Log.Error("Failed to change data region", "error", err, "organization_id", org.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to change data region", nil, "")
+	}
+	a.InvalidateOrgDataRegionCache(org.ID)
+
+	from, to := a.dataRegionLabel(org.DataRegion), a.dataRegionLabel(req.Region)
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: org.ID,
+		UserID:         &adminID,
+		Action:         models.AuditActionDataRegionChanged,
+		IPAddress:      middleware.ClientIP(r),
+		Path:           string(r.RequestCtx.Path()),
+		Details:        fmt.Sprintf("Data region changed from %s to %s", from, to),
+	})
+	a.Log.Info("Organization data region changed", "organization_id", org.ID, "from", org.DataRegion, "to", req.Region, "by", adminID)
+
+	return r.SendEnvelope(map[string]string{
+		"data_region":       req.Region,
+		"data_region_label": to,
+	})
+}
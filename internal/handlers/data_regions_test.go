@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaFullPath(t *testing.T) {
+	root, eu := t.TempDir(), t.TempDir()
+	app := &App{Config: &config.Config{Storage: config.StorageConfig{
+		LocalPath:   root,
+		RegionLabel: "US",
+		Regions: map[string]config.StorageRegion{
+			"eu":      {Label: "EU (Frankfurt)", LocalPath: eu},
+			"pending": {Label: "Not set up"},
+		},
+	}}}
+
+	assert.Equal(t, filepath.Join(root, "images", "a.jpg"), app.mediaFullPath("images/a.jpg"))
+	assert.Equal(t, filepath.Join(eu, "images", "a.jpg"), app.mediaFullPath("regions/eu/images/a.jpg"))
+	// Regions that aren't configured resolve under the default storage path
+	assert.Equal(t, filepath.Join(root, "regions", "apac", "a.jpg"), app.mediaFullPath("regions/apac/a.jpg"))
+	assert.Equal(t, filepath.Join(root, "regions", "pending", "a.jpg"), app.mediaFullPath("regions/pending/a.jpg"))
+
+	assert.Equal(t, "US", app.dataRegionLabel(""))
+	assert.Equal(t, "EU (Frankfurt)", app.dataRegionLabel("eu"))
+	assert.Equal(t, "apac", app.dataRegionLabel("apac"))
+
+	// Without an organization, files go to the default storage
+	dir, err := app.ensureMediaDir(uuid.Nil, "documents")
+	require.NoError(t, err)
+	assert.Equal(t, "documents", dir)
+	assert.DirExists(t, filepath.Join(root, "documents"))
+}
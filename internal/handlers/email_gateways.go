@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/emailgateway"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// emailGatewayInboundPath is where mail providers post inbound emails, followed by the gateway token
+const emailGatewayInboundPath = "/api/email-gateways/inbound/"
+
+// EmailGatewayRequest represents the request body for creating/updating an email gateway
+type EmailGatewayRequest struct {
+	Name             string                            `json:"name"`
+	AllowedSenders   []string                          `json:"allowed_senders"`
+	SubjectPattern   string                            `json:"subject_pattern"`
+	TemplateID       string                            `json:"template_id"`
+	WhatsAppAccount  string                            `json:"whatsapp_account"`
+	ParamRules       map[string]emailgateway.ParamRule `json:"param_rules"`
+	Recipients       []string                          `json:"recipients"`
+	RecipientPattern string                            `json:"recipient_pattern"`
+	IsActive         bool                              `json:"is_active"`
+}
+
+// EmailGatewayResponse represents the API response for an email gateway
+type EmailGatewayResponse struct {
+	ID               uuid.UUID                         `json:"id"`
+	Name             string                            `json:"name"`
+	InboundURL       string                            `json:"inbound_url"`
+	AllowedSenders   []string                          `json:"allowed_senders"`
+	SubjectPattern   string                            `json:"subject_pattern"`
+	TemplateID       uuid.UUID                         `json:"template_id"`
+	TemplateName     string                            `json:"template_name,omitempty"`
+	WhatsAppAccount  string                            `json:"whatsapp_account"`
+	ParamRules       map[string]emailgateway.ParamRule `json:"param_rules"`
+	Recipients       []string                          `json:"recipients"`
+	RecipientPattern string                            `json:"recipient_pattern"`
+	IsActive         bool                              `json:"is_active"`
+	LastReceivedAt   *time.Time                        `json:"last_received_at,omitempty"`
+	LastResult       string                            `json:"last_result"`
+	CreatedAt        string                            `json:"created_at"`
+}
+
+// InboundEmailRequest is a JSON inbound email. Providers that post forms (Mailgun,
+// SendGrid) are read from the same field names plus their own.
+type InboundEmailRequest struct {
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+// ListEmailGateways returns the organization's email gateways
+func (a *App) ListEmailGateways(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var gateways []models.EmailGateway
+	if err := a.DB.Where("organization_id = ?", orgID).Preload("Template").
+		Order("created_at DESC").Find(&gateways).Error; err != nil {
+		a.Log.Error("Failed to list email gateways", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list email gateways", nil, "")
+	}
+
+	result := make([]EmailGatewayResponse, len(gateways))
+	for i := range gateways {
+		result[i] = a.emailGatewayToResponse(&gateways[i])
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"gateways": result,
+	})
+}
+
+// CreateEmailGateway adds an email gateway with a new inbound URL
+func (a *App) CreateEmailGateway(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req EmailGatewayRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name is required", nil, "")
+	}
+
+	gateway := models.EmailGateway{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Token:          generateVerifyToken(),
+		IsActive:       true,
+	}
+	if msg := a.applyEmailGatewayRequest(&gateway, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Create(&gateway).Error; err != nil {
+		a.Log.Error("Failed to create email gateway", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create email gateway", nil, "")
+	}
+
+	return r.SendEnvelope(a.emailGatewayToResponse(&gateway))
+}
+
+// UpdateEmailGateway updates an email gateway; its inbound URL stays the same
+func (a *App) UpdateEmailGateway(r *fastglue.Request) error {
+	gateway, err := a.loadEmailGateway(r)
+	if err != nil || gateway == nil {
+		return err
+	}
+
+	var req EmailGatewayRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.Name != "" {
+		gateway.Name = req.Name
+	}
+	gateway.IsActive = req.IsActive
+	if msg := a.applyEmailGatewayRequest(gateway, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Save(gateway).Error; err != nil {
+		a.Log.Error("Failed to update email gateway", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update email gateway", nil, "")
+	}
+
+	return r.SendEnvelope(a.emailGatewayToResponse(gateway))
+}
+
+// DeleteEmailGateway removes an email gateway; its inbound URL stops working
+func (a *App) DeleteEmailGateway(r *fastglue.Request) error {
+	gateway, err := a.loadEmailGateway(r)
+	if err != nil || gateway == nil {
+		return err
+	}
+
+	if err := a.DB.Delete(gateway).Error; err != nil {
+		a.Log.Error("Failed to delete email gateway", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete email gateway", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Email gateway deleted successfully"})
+}
+
+// ReceiveGatewayEmail accepts an inbound email from a mail provider's webhook and sends
+// the gateway's template to the mapped contacts. The token in the path authenticates
+// the request; the sender allowlist guards against mail that reached the address by accident.
+func (a *App) ReceiveGatewayEmail(r *fastglue.Request) error {
+	token, _ := r.RequestCtx.UserValue("token").(string)
+	var gateway models.EmailGateway
+	if token == "" || a.DB.Where("token = ? AND is_active = ?", token, true).First(&gateway).Error != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Email gateway not found", nil, "")
+	}
+
+	email, err := parseInboundEmail(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if !emailgateway.SenderAllowed(gateway.AllowedSenders, email.From) {
+		sender := emailgateway.SenderAddress(email.From)
+		a.Log.Warn("Email gateway rejected sender", "gateway_id", gateway.ID, "sender", sender)
+		a.recordEmailGatewayResult(&gateway, "Rejected email from "+sender)
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Sender is not allowed", nil, "")
+	}
+	if !emailgateway.MatchSubject(gateway.SubjectPattern, email.Subject) {
+		a.recordEmailGatewayResult(&gateway, "Ignored email: subject didn't match")
+		return r.SendEnvelope(map[string]interface{}{
+			"status": "ignored",
+		})
+	}
+
+	sent, failed, msg := a.deliverGatewayEmail(&gateway, email)
+	if msg != "" {
+		a.recordEmailGatewayResult(&gateway, msg)
+		return r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, msg, nil, "")
+	}
+
+	a.recordEmailGatewayResult(&gateway, fmt.Sprintf("Sent to %d contact(s), %d failed", sent, failed))
+	return r.SendEnvelope(map[string]interface{}{
+		"status": "sent",
+		"sent":   sent,
+		"failed": failed,
+	})
+}
+
+// deliverGatewayEmail sends the gateway's template, filled from the email, to each
+// recipient. It returns a message when nothing could be sent.
+func (a *App) deliverGatewayEmail(gateway *models.EmailGateway, email emailgateway.Email) (sent, failed int, msg string) {
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", gateway.TemplateID, gateway.OrganizationID).
+		First(&template).Error; err != nil {
+		return 0, 0, "Template not found"
+	}
+	if template.ArchivedAt != nil || template.Status != string(models.TemplateStatusApproved) {
+		return 0, 0, fmt.Sprintf("Template %s is not approved", template.Name)
+	}
+
+	params, missing := emailgateway.ExtractParams(emailGatewayParamRules(gateway.ParamRules), email)
+	if len(missing) > 0 {
+		return 0, 0, "Couldn't find template parameters in the email: " + strings.Join(missing, ", ")
+	}
+	paramNames := ExtractParamNamesFromContent(template.BodyContent)
+	bodyParams := ResolveParams(paramNames, params)
+	for i, name := range paramNames {
+		if i >= len(bodyParams) || bodyParams[i] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return 0, 0, "Template parameters have no rule: " + strings.Join(missing, ", ")
+	}
+
+	accountName := gateway.WhatsAppAccount
+	if accountName == "" {
+		accountName = template.WhatsAppAccount
+	}
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", accountName, gateway.OrganizationID).First(&account).Error; err != nil {
+		return 0, 0, "WhatsApp account not found"
+	}
+
+	recipients := emailGatewayRecipients(gateway, email)
+	if len(recipients) == 0 {
+		return 0, 0, "No recipients found for the email"
+	}
+
+	// Send synchronously so the result reflects what WhatsApp accepted
+	opts := APISendOptions()
+	opts.Async = false
+	for _, to := range recipients {
+		contact, _ := a.getOrCreateContact(gateway.OrganizationID, to, "")
+		_, err := a.SendOutgoingMessage(context.Background(), OutgoingMessageRequest{
+			Account:    &account,
+			Contact:    contact,
+			Type:       models.MessageTypeTemplate,
+			Template:   &template,
+			BodyParams: params,
+		}, opts)
+		if err != nil {
+			a.Log.Error("Email gateway send failed", "error", err, "gateway_id", gateway.ID, "contact_id", contact.ID)
+			failed++
+			continue
+		}
+		sent++
+	}
+	return sent, failed, ""
+}
+
+// emailGatewayRecipients returns the gateway's fixed recipients plus numbers found in
+// the email, normalized and without duplicates
+func emailGatewayRecipients(gateway *models.EmailGateway, email emailgateway.Email) []string {
+	candidates := append([]string{}, gateway.Recipients...)
+	candidates = append(candidates, emailgateway.ExtractRecipients(gateway.RecipientPattern, email)...)
+
+	seen := make(map[string]bool, len(candidates))
+	var recipients []string
+	for _, c := range candidates {
+		number, err := phone.Normalize(c)
+		if err != nil || seen[number] {
+			continue
+		}
+		seen[number] = true
+		recipients = append(recipients, number)
+	}
+	return recipients
+}
+
+// parseInboundEmail reads an email posted as JSON or as a provider's form
+func parseInboundEmail(r *fastglue.Request) (emailgateway.Email, error) {
+	var email emailgateway.Email
+	if strings.HasPrefix(string(r.RequestCtx.Request.Header.ContentType()), "application/json") {
+		var req InboundEmailRequest
+		if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+			return email, fmt.Errorf("invalid request body")
+		}
+		email = emailgateway.Email{From: req.From, Subject: req.Subject, Text: req.Text}
+	} else {
+		formValue := func(keys ...string) string {
+			for _, k := range keys {
+				if v := r.RequestCtx.FormValue(k); len(v) > 0 {
+					return string(v)
+				}
+			}
+			return ""
+		}
+		email = emailgateway.Email{
+			From:    formValue("from", "sender"),
+			Subject: formValue("subject"),
+			Text:    formValue("text", "body-plain", "stripped-text"),
+		}
+	}
+	if email.From == "" {
+		return email, fmt.Errorf("from is required")
+	}
+	return email, nil
+}
+
+// applyEmailGatewayRequest validates the request and copies it onto the gateway,
+// returning a user-facing error message or ""
+func (a *App) applyEmailGatewayRequest(gateway *models.EmailGateway, req *EmailGatewayRequest) string {
+	if msg := emailgateway.Validate(req.AllowedSenders, req.SubjectPattern, req.RecipientPattern, req.ParamRules); msg != "" {
+		return msg
+	}
+	if len(req.Recipients) == 0 && req.RecipientPattern == "" {
+		return "recipients or recipient_pattern is required"
+	}
+	for _, number := range req.Recipients {
+		if _, err := phone.Normalize(number); err != nil {
+			return fmt.Sprintf("invalid recipient %s: %s", number, err.Error())
+		}
+	}
+
+	templateID, err := uuid.Parse(req.TemplateID)
+	if err != nil {
+		return "Invalid template_id"
+	}
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", templateID, gateway.OrganizationID).First(&template).Error; err != nil {
+		return "Template not found"
+	}
+	if req.WhatsAppAccount != "" {
+		var count int64
+		a.DB.Model(&models.WhatsAppAccount{}).
+			Where("name = ? AND organization_id = ?", req.WhatsAppAccount, gateway.OrganizationID).Count(&count)
+		if count == 0 {
+			return "WhatsApp account not found"
+		}
+	}
+
+	rules := make(models.JSONB, len(req.ParamRules))
+	for name, rule := range req.ParamRules {
+		rules[name] = map[string]interface{}{"source": rule.Source, "pattern": rule.Pattern}
+	}
+
+	gateway.AllowedSenders = req.AllowedSenders
+	gateway.SubjectPattern = req.SubjectPattern
+	gateway.TemplateID = templateID
+	gateway.Template = &template
+	gateway.WhatsAppAccount = req.WhatsAppAccount
+	gateway.ParamRules = rules
+	gateway.Recipients = req.Recipients
+	gateway.RecipientPattern = req.RecipientPattern
+	return ""
+}
+
+// loadEmailGateway checks write permission and loads the gateway from the path.
+// On failure it sends the error response and returns a nil gateway.
+func (a *App) loadEmailGateway(r *fastglue.Request) (*models.EmailGateway, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	gatewayID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid gateway ID", nil, "")
+	}
+
+	var gateway models.EmailGateway
+	if err := a.DB.Where("id = ? AND organization_id = ?", gatewayID, orgID).First(&gateway).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Email gateway not found", nil, "")
+	}
+	return &gateway, nil
+}
+
+// recordEmailGatewayResult stores the outcome of the latest inbound email for the settings page
+func (a *App) recordEmailGatewayResult(gateway *models.EmailGateway, result string) {
+	if err := a.DB.Model(gateway).UpdateColumns(map[string]interface{}{
+		"last_received_at": time.Now(),
+		"last_result":      result,
+	}).Error; err != nil {
+		a.Log.Error("Failed to record email gateway result", "error", err, "gateway_id", gateway.ID)
+	}
+}
+
+func (a *App) emailGatewayToResponse(gateway *models.EmailGateway) EmailGatewayResponse {
+	inboundURL := a.appLink(emailGatewayInboundPath + gateway.Token)
+	if inboundURL == "" {
+		inboundURL = emailGatewayInboundPath + gateway.Token
+	}
+	resp := EmailGatewayResponse{
+		ID:               gateway.ID,
+		Name:             gateway.Name,
+		InboundURL:       inboundURL,
+		AllowedSenders:   gateway.AllowedSenders,
+		SubjectPattern:   gateway.SubjectPattern,
+		TemplateID:       gateway.TemplateID,
+		WhatsAppAccount:  gateway.WhatsAppAccount,
+		ParamRules:       emailGatewayParamRules(gateway.ParamRules),
+		Recipients:       gateway.Recipients,
+		RecipientPattern: gateway.RecipientPattern,
+		IsActive:         gateway.IsActive,
+		LastReceivedAt:   gateway.LastReceivedAt,
+		LastResult:       gateway.LastResult,
+		CreatedAt:        gateway.CreatedAt.Format(time.RFC3339),
+	}
+	if gateway.Template != nil {
+		resp.TemplateName = gateway.Template.Name
+	}
+	return resp
+}
+
+// emailGatewayParamRules reads the stored parameter rules
+func emailGatewayParamRules(stored models.JSONB) map[string]emailgateway.ParamRule {
+	rules := make(map[string]emailgateway.ParamRule, len(stored))
+	for name, v := range stored {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		source, _ := m["source"].(string)
+		pattern, _ := m["pattern"].(string)
+		rules[name] = emailgateway.ParamRule{Source: source, Pattern: pattern}
+	}
+	return rules
+}
@@ -0,0 +1,112 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestApp_EmailGateway(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("gateway"), "password", &role.ID, true)
+
+	gatewayBody := map[string]interface{}{
+		"name":            "Nagios alerts",
+		"allowed_senders": []string{"@alerts.example.com"},
+		"subject_pattern": `^\[ALERT\]`,
+		"template_id":     template.ID.String(),
+		"param_rules": map[string]interface{}{
+			"1": map[string]string{"source": "subject", "pattern": `on (\S+)`},
+		},
+		"recipients":        []string{"+1 555 123 4567"},
+		"recipient_pattern": `Notify: (\+\d+)`,
+	}
+
+	// Invalid patterns are rejected
+	gatewayBody["subject_pattern"] = "("
+	req := testutil.NewJSONRequest(t, gatewayBody)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreateEmailGateway(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	gatewayBody["subject_pattern"] = `^\[ALERT\]`
+	req = testutil.NewJSONRequest(t, gatewayBody)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreateEmailGateway(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var created struct {
+		Data handlers.EmailGatewayResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, template.Name, created.Data.TemplateName)
+	token := created.Data.InboundURL[strings.LastIndex(created.Data.InboundURL, "/")+1:]
+	require.NotEmpty(t, token)
+
+	receive := func(email map[string]interface{}) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, email)
+		testutil.SetPathParam(req, "token", token)
+		require.NoError(t, app.ReceiveGatewayEmail(req))
+		return req
+	}
+
+	// Unknown senders are refused
+	req = receive(map[string]interface{}{"from": "someone@example.com", "subject": "[ALERT] Disk full on db-01"})
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	// Emails whose subject doesn't match are ignored
+	req = receive(map[string]interface{}{"from": "nagios@alerts.example.com", "subject": "Weekly report"})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Contains(t, string(testutil.GetResponseBody(req)), `"ignored"`)
+	assert.Empty(t, mockServer.sentMessages)
+
+	// A matching alert goes to the fixed recipient and the number named in the body
+	req = receive(map[string]interface{}{
+		"from":    "Nagios <nagios@alerts.example.com>",
+		"subject": "[ALERT] Disk full on db-01",
+		"text":    "Usage is at 97%.\nNotify: +919876543210\n",
+	})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var result struct {
+		Data struct {
+			Sent   int `json:"sent"`
+			Failed int `json:"failed"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &result)
+	assert.Equal(t, 2, result.Data.Sent)
+	assert.Len(t, mockServer.sentMessages, 2)
+
+	var messages []models.Message
+	require.NoError(t, app.DB.Where("organization_id = ?", org.ID).Find(&messages).Error)
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[0].Content, "db-01")
+
+	// Form posts from mail providers are read too
+	req = testutil.NewRequest(t)
+	req.RequestCtx.Request.Header.SetMethod("POST")
+	req.RequestCtx.Request.Header.SetContentType("application/x-www-form-urlencoded")
+	req.RequestCtx.Request.SetBodyString("sender=nagios%40alerts.example.com&subject=%5BALERT%5D+Load+high+on+web-02&body-plain=")
+	testutil.SetPathParam(req, "token", token)
+	require.NoError(t, app.ReceiveGatewayEmail(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Len(t, mockServer.sentMessages, 3)
+
+	var gateway models.EmailGateway
+	require.NoError(t, app.DB.First(&gateway, created.Data.ID).Error)
+	assert.NotNil(t, gateway.LastReceivedAt)
+	assert.Equal(t, "Sent to 1 contact(s), 0 failed", gateway.LastResult)
+}
@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/byok"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// EncryptionKeyRequest sets the organization's own key. An empty token keeps the stored one.
+type EncryptionKeyRequest struct {
+	Provider models.EncryptionKeyProvider `json:"provider"`
+	Address  string                       `json:"address"`
+	Mount    string                       `json:"mount"`
+	KeyName  string                       `json:"key_name"`
+	Token    string                       `json:"token"`
+}
+
+// EncryptionKeyResponse describes the organization's own key (token omitted)
+type EncryptionKeyResponse struct {
+	Configured    bool                         `json:"configured"`
+	Provider      models.EncryptionKeyProvider `json:"provider,omitempty"`
+	Address       string                       `json:"address,omitempty"`
+	Mount         string                       `json:"mount,omitempty"`
+	KeyName       string                       `json:"key_name,omitempty"`
+	Status        models.EncryptionKeyStatus   `json:"status,omitempty"`
+	LastError     string                       `json:"last_error,omitempty"`
+	LastCheckedAt *time.Time                   `json:"last_checked_at,omitempty"`
+	RotatedAt     *time.Time                   `json:"rotated_at,omitempty"`
+	CreatedAt     *time.Time                   `json:"created_at,omitempty"`
+}
+
+// orgDataKey returns the organization's data key, or nil if it doesn't bring its own key
+func (a *App) orgDataKey(orgID uuid.UUID) ([]byte, error) {
+	return a.keys.DataKey(context.Background(), a.DB, orgID)
+}
+
+// encryptOrgSecret encrypts a secret with the organization's own key, if it has one
+func (a *App) encryptOrgSecret(orgID uuid.UUID, value string) (string, error) {
+	key, err := a.orgDataKey(orgID)
+	if err != nil || key == nil {
+		return value, err
+	}
+	return byok.EncryptString(key, value)
+}
+
+// decryptOrgSecret decrypts a secret stored by encryptOrgSecret. Secrets stored before
+// the organization set up its key are returned as they are.
+func (a *App) decryptOrgSecret(orgID uuid.UUID, value string) (string, error) {
+	if !byok.IsEncrypted(value) {
+		return value, nil
+	}
+	key, err := a.orgDataKey(orgID)
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", byok.ErrKeyUnavailable
+	}
+	return byok.DecryptString(key, value)
+}
+
+// accountAccessToken returns the decrypted access token of a WhatsApp account
+func (a *App) accountAccessToken(account *models.WhatsAppAccount) (string, error) {
+	return a.decryptOrgSecret(account.OrganizationID, account.AccessToken)
+}
+
+// mediaFile is a file in media storage that may be encrypted on the way to disk
+type mediaFile struct {
+	io.Writer
+	file      *os.File
+	encrypter *byok.Writer
+}
+
+// Close seals the last encrypted chunk, if any, and closes the file
+func (f *mediaFile) Close() error {
+	if f.encrypter != nil {
+		if err := f.encrypter.Close(); err != nil {
+			_ = f.file.Close()
+			return err
+		}
+	}
+	return f.file.Close()
+}
+
+// createMediaFile opens relPath in media storage for writing with os.O_WRONLY|os.O_CREATE
+// and flag. Files of organizations with their own key are encrypted.
+func (a *App) createMediaFile(orgID uuid.UUID, relPath string, flag int) (io.WriteCloser, error) {
+	key, err := a.orgDataKey(orgID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(a.mediaFullPath(relPath), os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return &mediaFile{Writer: file, file: file}, nil
+	}
+	encrypter, err := byok.NewWriter(file, key)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &mediaFile{Writer: encrypter, file: file, encrypter: encrypter}, nil
+}
+
+// writeMediaFile writes data to relPath in media storage, replacing any file there
+func (a *App) writeMediaFile(orgID uuid.UUID, relPath string, data []byte) error {
+	f, err := a.createMediaFile(orgID, relPath, os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// mediaFileReader reads a media file, decrypting it if needed
+type mediaFileReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *mediaFileReader) Close() error {
+	return r.file.Close()
+}
+
+// openMediaFile opens relPath in media storage for reading. Encrypted files are decrypted
+// with the organization's key; files stored before it set up its key are read as they are.
+func (a *App) openMediaFile(orgID uuid.UUID, relPath string) (io.ReadCloser, error) {
+	file, err := os.Open(a.mediaFullPath(relPath))
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(file)
+	header, _ := buffered.Peek(byok.HeaderSize)
+	if !byok.IsEncryptedFile(header) {
+		return &mediaFileReader{Reader: buffered, file: file}, nil
+	}
+
+	key, err := a.orgDataKey(orgID)
+	if err == nil && key == nil {
+		err = byok.ErrKeyUnavailable
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	decrypter, err := byok.NewReader(buffered, key)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &mediaFileReader{Reader: decrypter, file: file}, nil
+}
+
+// readerAtCloser is a media file open for random access
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// nopReaderAtCloser lets a decrypted file held in memory stand in for an open file
+type nopReaderAtCloser struct {
+	*bytes.Reader
+}
+
+func (nopReaderAtCloser) Close() error { return nil }
+
+// openMediaFileAt opens relPath in media storage for random access, as zip based formats
+// need, and returns its size. Encrypted files are decrypted into memory, so it is only for
+// files of bounded size such as imports.
+func (a *App) openMediaFileAt(orgID uuid.UUID, relPath string) (readerAtCloser, int64, error) {
+	file, err := os.Open(a.mediaFullPath(relPath))
+	if err != nil {
+		return nil, 0, err
+	}
+	header := make([]byte, byok.HeaderSize)
+	n, _ := file.ReadAt(header, 0)
+	if !byok.IsEncryptedFile(header[:n]) {
+		info, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			return nil, 0, err
+		}
+		return file, info.Size(), nil
+	}
+	_ = file.Close()
+
+	data, err := a.readMediaFile(orgID, relPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	return nopReaderAtCloser{bytes.NewReader(data)}, int64(len(data)), nil
+}
+
+// readMediaFile reads a whole media file, decrypting it if needed
+func (a *App) readMediaFile(orgID uuid.UUID, relPath string) ([]byte, error) {
+	f, err := a.openMediaFile(orgID, relPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return io.ReadAll(f)
+}
+
+// GetEncryptionKey returns the organization's own key and checks it can still be used
+func (a *App) GetEncryptionKey(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var record models.OrganizationEncryptionKey
+	if err := a.DB.Where("organization_id = ?", orgID).First(&record).Error; err != nil {
+		return r.SendEnvelope(EncryptionKeyResponse{})
+	}
+	a.checkEncryptionKey(r.RequestCtx, &record)
+	return r.SendEnvelope(encryptionKeyToResponse(&record))
+}
+
+// checkEncryptionKey unwraps the data key to see whether the KMS still accepts the key,
+// and records the result. Passing failures, like the KMS being unreachable, are recorded
+// without marking the key unavailable.
+func (a *App) checkEncryptionKey(ctx context.Context, record *models.OrganizationEncryptionKey) {
+	now := time.Now()
+	record.LastCheckedAt = &now
+	record.LastError = ""
+	provider, err := byok.NewProvider(record)
+	if err == nil {
+		_, err = provider.Unwrap(ctx, record.WrappedKey)
+	}
+	switch {
+	case err == nil:
+		record.Status = models.EncryptionKeyActive
+	case errors.Is(err, byok.ErrKeyUnavailable):
+		record.Status = models.EncryptionKeyUnavailable
+		record.LastError = err.Error()
+	default:
+		record.LastError = err.Error()
+	}
+	if err := a.DB.Model(record).Updates(map[string]any{
+		"status":          record.Status,
+		"last_error":      record.LastError,
+		"last_checked_at": now,
+	}).Error; err != nil {
+		a.Log.Error("Failed to record encryption key check", "error", err, "organization_id", record.OrganizationID)
+	}
+}
+
+// UpdateEncryptionKey sets the organization's own key. The first time, a data key is
+// created and the organization's stored secrets are encrypted with it; after that the
+// data key is moved to the new key, which the old key must still be able to unwrap.
+func (a *App) UpdateEncryptionKey(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req EncryptionKeyRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Provider == "" {
+		req.Provider = models.EncryptionKeyVaultTransit
+	}
+	if req.Provider != models.EncryptionKeyVaultTransit {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "provider must be vault_transit", nil, "")
+	}
+	req.Address = strings.TrimSpace(req.Address)
+	if u, err := url.Parse(req.Address); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "address must be an http(s) URL", nil, "")
+	}
+	req.KeyName = strings.TrimSpace(req.KeyName)
+	if req.KeyName == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "key_name is required", nil, "")
+	}
+	req.Mount = strings.Trim(strings.TrimSpace(req.Mount), "/")
+	if req.Mount == "" {
+		req.Mount = byok.DefaultVaultMount
+	}
+
+	var existing *models.OrganizationEncryptionKey
+	var stored models.OrganizationEncryptionKey
+	if err := a.DB.Where("organization_id = ?", orgID).First(&stored).Error; err == nil {
+		existing = &stored
+	}
+	if req.Token == "" && existing == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "token is required", nil, "")
+	}
+
+	record := models.OrganizationEncryptionKey{OrganizationID: orgID}
+	if existing != nil {
+		record = *existing
+	}
+	record.Provider = req.Provider
+	record.Address = req.Address
+	record.Mount = req.Mount
+	record.KeyName = req.KeyName
+	if req.Token != "" {
+		record.Token = req.Token
+	}
+
+	// The data key never changes, so everything already encrypted stays readable
+	var dataKey []byte
+	if existing != nil {
+		oldProvider, err := byok.NewProvider(existing)
+		if err == nil {
+			dataKey, err = oldProvider.Unwrap(r.RequestCtx, existing.WrappedKey)
+		}
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "The current key can't be used: "+err.Error(), nil, "")
+		}
+	} else if dataKey, err = byok.NewDataKey(); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create data key", nil, "")
+	}
+
+	provider, err := byok.NewProvider(&record)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	wrapped, err := provider.Wrap(r.RequestCtx, dataKey)
+	if err == nil {
+		// Make sure the key can also decrypt before relying on it
+		_, err = provider.Unwrap(r.RequestCtx, wrapped)
+	}
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "The key can't be used: "+err.Error(), nil, "")
+	}
+	now := time.Now()
+	record.WrappedKey = wrapped
+	record.Status = models.EncryptionKeyActive
+	record.LastError = ""
+	record.LastCheckedAt = &now
+
+	if err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+		if existing != nil {
+			return nil
+		}
+		return encryptStoredSecrets(tx, orgID, dataKey)
+	}); err != nil {
+		a.Log.Error("Failed to save encryption key", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save encryption key", nil, "")
+	}
+	a.keys.Forget(orgID)
+	if existing == nil {
+		a.invalidateOrgSecretCaches(orgID)
+	}
+
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: orgID,
+		UserID:         &userID,
+		Action:         models.AuditActionEncryptionKeyConfigured,
+		IPAddress:      middleware.ClientIP(r),
+		Path:           string(r.RequestCtx.Path()),
+		Details:        fmt.Sprintf("Key %s at %s", record.KeyName, record.Address),
+	})
+
+	return r.SendEnvelope(encryptionKeyToResponse(&record))
+}
+
+// RotateEncryptionKey rewraps the data key with the newest version of the organization's
+// key, e.g. after the key was rotated in the KMS, so older versions can be retired
+func (a *App) RotateEncryptionKey(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var record models.OrganizationEncryptionKey
+	if err := a.DB.Where("organization_id = ?", orgID).First(&record).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No encryption key configured", nil, "")
+	}
+	provider, err := byok.NewProvider(&record)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, err.Error(), nil, "")
+	}
+	wrapped, err := provider.Rewrap(r.RequestCtx, record.WrappedKey)
+	if err != nil {
+		if errors.Is(err, byok.ErrKeyUnavailable) {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, err.Error(), nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, err.Error(), nil, "")
+	}
+
+	now := time.Now()
+	record.WrappedKey = wrapped
+	record.RotatedAt = &now
+	record.Status = models.EncryptionKeyActive
+	record.LastError = ""
+	record.LastCheckedAt = &now
+	if err := a.DB.Model(&record).Updates(map[string]any{
+		"wrapped_key":     wrapped,
+		"rotated_at":      now,
+		"status":          record.Status,
+		"last_error":      "",
+		"last_checked_at": now,
+	}).Error; err != nil {
+		a.Log.Error("Failed to save rotated encryption key", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to rotate encryption key", nil, "")
+	}
+
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: orgID,
+		UserID:         &userID,
+		Action:         models.AuditActionEncryptionKeyRotated,
+		IPAddress:      middleware.ClientIP(r),
+		Path:           string(r.RequestCtx.Path()),
+		Details:        "Data key rewrapped with key " + record.KeyName,
+	})
+
+	return r.SendEnvelope(encryptionKeyToResponse(&record))
+}
+
+// encryptStoredSecrets encrypts the organization's stored secrets that are still in plain text
+func encryptStoredSecrets(tx *gorm.DB, orgID uuid.UUID, key []byte) error {
+	var accounts []models.WhatsAppAccount
+	if err := tx.Select("id, access_token").Where("organization_id = ?", orgID).Find(&accounts).Error; err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if account.AccessToken == "" || byok.IsEncrypted(account.AccessToken) {
+			continue
+		}
+		sealed, err := byok.EncryptString(key, account.AccessToken)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.WhatsAppAccount{}).Where("id = ?", account.ID).Update("access_token", sealed).Error; err != nil {
+			return err
+		}
+	}
+
+	var settings []models.ChatbotSettings
+	if err := tx.Select("id, ai_api_key").Where("organization_id = ?", orgID).Find(&settings).Error; err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if s.AI.APIKey == "" || byok.IsEncrypted(s.AI.APIKey) {
+			continue
+		}
+		sealed, err := byok.EncryptString(key, s.AI.APIKey)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ChatbotSettings{}).Where("id = ?", s.ID).Update("ai_api_key", sealed).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidateOrgSecretCaches drops cached copies of the organization's secrets, which were
+// cached before they were encrypted
+func (a *App) invalidateOrgSecretCaches(orgID uuid.UUID) {
+	var phoneIDs []string
+	a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ?", orgID).Pluck("phone_id", &phoneIDs)
+	for _, phoneID := range phoneIDs {
+		a.InvalidateWhatsAppAccountCache(phoneID)
+	}
+	a.InvalidateChatbotSettingsCache(orgID)
+}
+
+func encryptionKeyToResponse(record *models.OrganizationEncryptionKey) EncryptionKeyResponse {
+	createdAt := record.CreatedAt
+	return EncryptionKeyResponse{
+		Configured:    true,
+		Provider:      record.Provider,
+		Address:       record.Address,
+		Mount:         record.Mount,
+		KeyName:       record.KeyName,
+		Status:        record.Status,
+		LastError:     record.LastError,
+		LastCheckedAt: record.LastCheckedAt,
+		RotatedAt:     record.RotatedAt,
+		CreatedAt:     &createdAt,
+	}
+}
@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/byok"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// newFakeTransit serves the Transit endpoints of one key. Its "encryption" only tags the
+// plaintext with the key version, and every request is refused once revoked is set.
+func newFakeTransit(t *testing.T, version *atomic.Int32, revoked *atomic.Bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		unwrap := func(ciphertext string) string {
+			_, plaintext, _ := strings.Cut(strings.TrimPrefix(ciphertext, "vault:"), ":")
+			return plaintext
+		}
+		wrap := func(plaintext string) string {
+			return "vault:v" + strconv.Itoa(int(version.Load())) + ":" + plaintext
+		}
+
+		var data map[string]string
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/orgkey":
+			data = map[string]string{"ciphertext": wrap(body["plaintext"])}
+		case "/v1/transit/decrypt/orgkey":
+			data = map[string]string{"plaintext": unwrap(body["ciphertext"])}
+		case "/v1/transit/rewrap/orgkey":
+			data = map[string]string{"ciphertext": wrap(unwrap(body["ciphertext"]))}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestApp_EncryptionKey(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("byok"), "password", &role.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "byok-account")
+
+	var version atomic.Int32
+	var revoked atomic.Bool
+	version.Store(1)
+	vault := newFakeTransit(t, &version, &revoked)
+
+	getKey := func() handlers.EncryptionKeyResponse {
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.GetEncryptionKey(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp handlers.EncryptionKeyResponse
+		testutil.ParseEnvelopeResponse(t, req, &resp)
+		return resp
+	}
+	assert.False(t, getKey().Configured)
+
+	// A token is required the first time
+	req := testutil.NewJSONRequest(t, map[string]string{"address": vault.URL, "key_name": "orgkey"})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.UpdateEncryptionKey(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewJSONRequest(t, map[string]string{"address": vault.URL, "key_name": "orgkey", "token": "s.token"})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.UpdateEncryptionKey(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var configured handlers.EncryptionKeyResponse
+	testutil.ParseEnvelopeResponse(t, req, &configured)
+	assert.True(t, configured.Configured)
+	assert.Equal(t, models.EncryptionKeyActive, configured.Status)
+	assert.Equal(t, "transit", configured.Mount)
+
+	// Secrets stored before the key was set up are encrypted with it
+	var stored models.WhatsAppAccount
+	require.NoError(t, app.DB.Where("id = ?", account.ID).First(&stored).Error)
+	assert.True(t, byok.IsEncrypted(stored.AccessToken))
+	assert.NotContains(t, stored.AccessToken, "test-token")
+
+	var record models.OrganizationEncryptionKey
+	require.NoError(t, app.DB.Where("organization_id = ?", org.ID).First(&record).Error)
+	assert.True(t, strings.HasPrefix(record.WrappedKey, "vault:v1:"))
+
+	var audit models.AuditLog
+	require.NoError(t, app.DB.Where("organization_id = ? AND action = ?", org.ID, models.AuditActionEncryptionKeyConfigured).First(&audit).Error)
+
+	// Rotating rewraps the data key with the newest key version
+	version.Store(2)
+	req = testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.RotateEncryptionKey(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	require.NoError(t, app.DB.Where("organization_id = ?", org.ID).First(&record).Error)
+	assert.True(t, strings.HasPrefix(record.WrappedKey, "vault:v2:"))
+	assert.NotNil(t, record.RotatedAt)
+
+	// Once the key is revoked it is reported unavailable, and can't be rotated
+	revoked.Store(true)
+	resp := getKey()
+	assert.Equal(t, models.EncryptionKeyUnavailable, resp.Status)
+	assert.NotEmpty(t, resp.LastError)
+
+	req = testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.RotateEncryptionKey(req))
+	assert.Equal(t, fasthttp.StatusConflict, testutil.GetResponseStatusCode(req))
+}
+
+func TestApp_EncryptionKey_RequiresPermission(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	agent := createTestAgent(t, app, org.ID)
+
+	req := testutil.NewJSONRequest(t, map[string]string{"address": "https://vault.example.com", "key_name": "orgkey", "token": "s.token"})
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.UpdateEncryptionKey(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/enrichment"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// enrichmentCacheTTL is how long a provider's answer for a number is reused
+	enrichmentCacheTTL    = 7 * 24 * time.Hour
+	enrichmentCachePrefix = "enrichment:"
+)
+
+// EnrichmentProviderRequest represents the request body for creating/updating a provider
+type EnrichmentProviderRequest struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	URL      string `json:"url"`
+	APIKey   string `json:"api_key"`
+	Priority int    `json:"priority"`
+	IsActive bool   `json:"is_active"`
+}
+
+// EnrichmentProviderResponse represents the API response for a provider. The API key
+// is never returned.
+type EnrichmentProviderResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	URL       string    `json:"url"`
+	HasAPIKey bool      `json:"has_api_key"`
+	Priority  int       `json:"priority"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt string    `json:"created_at"`
+	UpdatedAt string    `json:"updated_at"`
+}
+
+// EnrichmentProviderResult reports what one provider returned for a manual re-enrich
+type EnrichmentProviderResult struct {
+	Provider string `json:"provider"`
+	Status   string `json:"status"` // matched, no_match, error
+	Error    string `json:"error,omitempty"`
+}
+
+// ListEnrichmentProviders returns the organization's contact enrichment providers
+func (a *App) ListEnrichmentProviders(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var providers []models.EnrichmentProvider
+	if err := a.DB.Where("organization_id = ?", orgID).Order("priority, created_at").Find(&providers).Error; err != nil {
+		a.Log.Error("Failed to list enrichment providers", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list enrichment providers", nil, "")
+	}
+
+	result := make([]EnrichmentProviderResponse, len(providers))
+	for i, p := range providers {
+		result[i] = enrichmentProviderToResponse(p)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"providers": result,
+	})
+}
+
+// CreateEnrichmentProvider adds a contact enrichment provider
+func (a *App) CreateEnrichmentProvider(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req EnrichmentProviderRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name is required", nil, "")
+	}
+	if msg := validateEnrichmentProvider(req.Provider, req.URL, req.APIKey); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	provider := models.EnrichmentProvider{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Provider:       req.Provider,
+		URL:            req.URL,
+		APIKey:         req.APIKey,
+		Priority:       req.Priority,
+		IsActive:       true,
+	}
+	if err := a.DB.Create(&provider).Error; err != nil {
+		a.Log.Error("Failed to create enrichment provider", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create enrichment provider", nil, "")
+	}
+
+	return r.SendEnvelope(enrichmentProviderToResponse(provider))
+}
+
+// UpdateEnrichmentProvider updates a provider; an empty api_key keeps the stored one
+func (a *App) UpdateEnrichmentProvider(r *fastglue.Request) error {
+	provider, err := a.loadEnrichmentProvider(r)
+	if err != nil || provider == nil {
+		return err
+	}
+
+	var req EnrichmentProviderRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.Name != "" {
+		provider.Name = req.Name
+	}
+	if req.Provider != "" {
+		provider.Provider = req.Provider
+	}
+	provider.URL = req.URL
+	if req.APIKey != "" {
+		provider.APIKey = req.APIKey
+	}
+	provider.Priority = req.Priority
+	provider.IsActive = req.IsActive
+
+	if msg := validateEnrichmentProvider(provider.Provider, provider.URL, provider.APIKey); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Save(provider).Error; err != nil {
+		a.Log.Error("Failed to update enrichment provider", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update enrichment provider", nil, "")
+	}
+
+	return r.SendEnvelope(enrichmentProviderToResponse(*provider))
+}
+
+// DeleteEnrichmentProvider removes a provider
+func (a *App) DeleteEnrichmentProvider(r *fastglue.Request) error {
+	provider, err := a.loadEnrichmentProvider(r)
+	if err != nil || provider == nil {
+		return err
+	}
+
+	if err := a.DB.Delete(provider).Error; err != nil {
+		a.Log.Error("Failed to delete enrichment provider", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete enrichment provider", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Enrichment provider deleted successfully"})
+}
+
+// EnrichContact looks a contact up again with every active provider, bypassing the
+// cache and overwriting previously enriched values
+func (a *App) EnrichContact(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var contact models.Contact
+	if err := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID).First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	providers, err := a.activeEnrichmentProviders(orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load enrichment providers", nil, "")
+	}
+	if len(providers) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No enrichment providers are configured", nil, "")
+	}
+
+	ctx, cancel := context.WithTimeout(r.RequestCtx, 30*time.Second)
+	defer cancel()
+
+	results, err := a.enrichContact(ctx, &contact, providers, true)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save contact enrichment", nil, "")
+	}
+	failed := 0
+	for _, res := range results {
+		if res.Status == "error" {
+			failed++
+		}
+	}
+	if failed == len(results) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Enrichment failed: "+results[0].Error, nil, "")
+	}
+
+	profileName := contact.ProfileName
+	if a.ShouldMaskPhoneNumbers(orgID) {
+		profileName = MaskIfPhoneNumber(profileName)
+	}
+	return r.SendEnvelope(map[string]interface{}{
+		"contact_id":    contact.ID,
+		"profile_name":  profileName,
+		"custom_fields": contact.Metadata,
+		"providers":     results,
+	})
+}
+
+// enrichContactWithProviders enriches a new contact in the background so provider
+// latency never delays message processing
+func (a *App) enrichContactWithProviders(contact *models.Contact) {
+	orgID, contactID := contact.OrganizationID, contact.ID
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		providers, err := a.activeEnrichmentProviders(orgID)
+		if err != nil || len(providers) == 0 {
+			return
+		}
+
+		// Reload so changes made since creation, e.g. by scripts, are kept
+		var c models.Contact
+		if err := a.DB.Where("id = ?", contactID).First(&c).Error; err != nil {
+			a.Log.Error("Failed to load contact for enrichment", "error", err, "contact_id", contactID)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if _, err := a.enrichContact(ctx, &c, providers, false); err != nil {
+			a.Log.Error("Failed to save contact enrichment", "error", err, "contact_id", contactID)
+		}
+	}()
+}
+
+// enrichContact queries providers in priority order and saves what they found; earlier
+// providers win when several return the same field. Without refresh, cached lookups are
+// reused and only empty values are filled. With refresh, providers are queried again and
+// their values overwrite the contact's.
+func (a *App) enrichContact(ctx context.Context, contact *models.Contact, providers []models.EnrichmentProvider, refresh bool) ([]EnrichmentProviderResult, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	merged := &enrichment.Result{Fields: map[string]interface{}{}}
+	results := make([]EnrichmentProviderResult, 0, len(providers))
+
+	for _, p := range providers {
+		res, err := a.lookupEnrichment(ctx, client, p, contact, refresh)
+		if err != nil {
+			a.Log.Warn("Contact enrichment lookup failed", "error", err, "provider_id", p.ID, "contact_id", contact.ID)
+			results = append(results, EnrichmentProviderResult{Provider: p.Name, Status: "error", Error: err.Error()})
+			continue
+		}
+		if res.Empty() {
+			results = append(results, EnrichmentProviderResult{Provider: p.Name, Status: "no_match"})
+			continue
+		}
+		results = append(results, EnrichmentProviderResult{Provider: p.Name, Status: "matched"})
+
+		if merged.Name == "" {
+			merged.Name = res.Name
+		}
+		if merged.Company == "" {
+			merged.Company = res.Company
+		}
+		if merged.AvatarURL == "" {
+			merged.AvatarURL = res.AvatarURL
+		}
+		for k, v := range res.Fields {
+			if _, ok := merged.Fields[k]; !ok {
+				merged.Fields[k] = v
+			}
+		}
+	}
+
+	if merged.AvatarURL != "" && (refresh || contact.AvatarPath == "") {
+		if err := a.storeContactAvatar(ctx, client, contact, merged.AvatarURL); err != nil {
+			a.Log.Warn("Failed to store contact avatar", "error", err, "contact_id", contact.ID)
+		}
+	}
+
+	updates := applyEnrichment(contact, merged, refresh)
+	if len(updates) == 0 {
+		return results, nil
+	}
+	if err := a.DB.Model(contact).Updates(updates).Error; err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// lookupEnrichment asks one provider about the contact. Misses are cached too, so unknown
+// numbers don't cost a paid lookup every time.
+func (a *App) lookupEnrichment(ctx context.Context, client *http.Client, p models.EnrichmentProvider, contact *models.Contact, refresh bool) (*enrichment.Result, error) {
+	cacheKey := fmt.Sprintf("%s%s:%s", enrichmentCachePrefix, p.ID.String(), contact.PhoneNumber)
+	if !refresh && a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			var result enrichment.Result
+			if err := json.Unmarshal([]byte(cached), &result); err == nil {
+				return &result, nil
+			}
+		}
+	}
+
+	result, err := enrichment.Lookup(ctx, client,
+		enrichment.Provider{Type: p.Provider, URL: p.URL, APIKey: p.APIKey},
+		enrichment.Request{PhoneNumber: contact.PhoneNumber, Name: contact.ProfileName})
+	if errors.Is(err, enrichment.ErrNotFound) {
+		result, err = &enrichment.Result{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(result); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, enrichmentCacheTTL)
+		}
+	}
+	return result, nil
+}
+
+// applyEnrichment copies a result onto the contact and returns the column updates. The
+// name only replaces a missing profile name unless overwrite is set; company and fields
+// are saved as custom fields.
+func applyEnrichment(contact *models.Contact, result *enrichment.Result, overwrite bool) map[string]interface{} {
+	updates := map[string]interface{}{}
+
+	if result.Name != "" && result.Name != contact.ProfileName &&
+		(overwrite || contact.ProfileName == "" || contact.ProfileName == contact.PhoneNumber) {
+		contact.ProfileName = result.Name
+		updates["profile_name"] = result.Name
+	}
+
+	fields := map[string]interface{}{}
+	for k, v := range result.Fields {
+		fields[k] = v
+	}
+	if result.Company != "" {
+		fields["company"] = result.Company
+	}
+
+	metadata := models.JSONB{}
+	for k, v := range contact.Metadata {
+		metadata[k] = v
+	}
+	changed := false
+	for k, v := range fields {
+		if existing, ok := metadata[k]; ok && (!overwrite || jsonEqual(existing, v)) {
+			continue
+		}
+		metadata[k] = v
+		changed = true
+	}
+	if changed {
+		contact.Metadata = metadata
+		updates["metadata"] = metadata
+	}
+	return updates
+}
+
+func (a *App) activeEnrichmentProviders(orgID uuid.UUID) ([]models.EnrichmentProvider, error) {
+	var providers []models.EnrichmentProvider
+	if err := a.DB.Where("organization_id = ? AND is_active = ?", orgID, true).
+		Order("priority, created_at").Find(&providers).Error; err != nil {
+		a.Log.Error("Failed to load enrichment providers", "error", err, "org_id", orgID)
+		return nil, err
+	}
+	return providers, nil
+}
+
+// loadEnrichmentProvider checks write permission and loads the provider from the path.
+// On failure it sends the error response and returns a nil provider.
+func (a *App) loadEnrichmentProvider(r *fastglue.Request) (*models.EnrichmentProvider, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	providerID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid provider ID", nil, "")
+	}
+
+	var provider models.EnrichmentProvider
+	if err := a.DB.Where("id = ? AND organization_id = ?", providerID, orgID).First(&provider).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Enrichment provider not found", nil, "")
+	}
+	return &provider, nil
+}
+
+// validateEnrichmentProvider returns a user-facing error message, or "" if valid
+func validateEnrichmentProvider(provider, rawURL, apiKey string) string {
+	if !enrichment.IsSupported(provider) {
+		return "provider must be clearbit or custom"
+	}
+	if provider == enrichment.ProviderCustom && rawURL == "" {
+		return "url is required for custom providers"
+	}
+	if provider == enrichment.ProviderClearbit && apiKey == "" {
+		return "api_key is required for clearbit providers"
+	}
+	if rawURL != "" {
+		if err := enrichment.ValidateURL(rawURL); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+func enrichmentProviderToResponse(p models.EnrichmentProvider) EnrichmentProviderResponse {
+	return EnrichmentProviderResponse{
+		ID:        p.ID,
+		Name:      p.Name,
+		Provider:  p.Provider,
+		URL:       p.URL,
+		HasAPIKey: p.APIKey != "",
+		Priority:  p.Priority,
+		IsActive:  p.IsActive,
+		CreatedAt: p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: p.UpdatedAt.Format(time.RFC3339),
+	}
+}
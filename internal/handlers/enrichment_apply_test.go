@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/enrichment"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyEnrichment_FillsOnlyMissingValues(t *testing.T) {
+	contact := &models.Contact{
+		PhoneNumber: "919876543210",
+		ProfileName: "Ada",
+		Metadata:    models.JSONB{"plan": "free"},
+	}
+	result := &enrichment.Result{
+		Name:    "Ada Lovelace",
+		Company: "Analytical Engines",
+		Fields:  map[string]interface{}{"plan": "pro", "location": "London"},
+	}
+
+	updates := applyEnrichment(contact, result, false)
+
+	assert.NotContains(t, updates, "profile_name")
+	assert.Equal(t, "Ada", contact.ProfileName)
+	assert.Equal(t, models.JSONB{"plan": "free", "company": "Analytical Engines", "location": "London"}, contact.Metadata)
+}
+
+func TestApplyEnrichment_ReplacesPhoneAsName(t *testing.T) {
+	contact := &models.Contact{PhoneNumber: "919876543210", ProfileName: "919876543210"}
+
+	updates := applyEnrichment(contact, &enrichment.Result{Name: "Ada Lovelace"}, false)
+
+	assert.Equal(t, "Ada Lovelace", updates["profile_name"])
+	assert.NotContains(t, updates, "metadata")
+}
+
+func TestApplyEnrichment_Overwrite(t *testing.T) {
+	contact := &models.Contact{
+		PhoneNumber: "919876543210",
+		ProfileName: "Ada",
+		Metadata:    models.JSONB{"plan": "free", "notes": "VIP"},
+	}
+	result := &enrichment.Result{Name: "Ada Lovelace", Fields: map[string]interface{}{"plan": "pro"}}
+
+	updates := applyEnrichment(contact, result, true)
+
+	assert.Equal(t, "Ada Lovelace", updates["profile_name"])
+	assert.Equal(t, models.JSONB{"plan": "pro", "notes": "VIP"}, contact.Metadata)
+
+	// Nothing changes when the values are already current
+	assert.Empty(t, applyEnrichment(contact, result, true))
+}
@@ -0,0 +1,137 @@
+package handlers_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestApp_EnrichmentProviders(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("enrichment"), "password", &role.ID, true)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+	}{
+		{"unknown provider", map[string]interface{}{"name": "CRM", "provider": "hubspot", "url": "https://crm.example.com"}},
+		{"custom without url", map[string]interface{}{"name": "CRM", "provider": "custom"}},
+		{"clearbit without key", map[string]interface{}{"name": "Clearbit", "provider": "clearbit"}},
+		{"plain http", map[string]interface{}{"name": "CRM", "provider": "custom", "url": "http://crm.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testutil.NewJSONRequest(t, tt.body)
+			setAuthContext(req, org.ID, user.ID)
+			require.NoError(t, app.CreateEnrichmentProvider(req))
+			assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+		})
+	}
+
+	// Re-enriching needs a provider
+	contact := createMsgTestContact(t, app, org.ID, "")
+	req := testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.EnrichContact(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewJSONRequest(t, map[string]interface{}{
+		"name":     "CRM",
+		"provider": "custom",
+		"url":      "https://crm.example.com/lookup",
+		"api_key":  "secret-key",
+	})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreateEnrichmentProvider(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var created struct {
+		Data handlers.EnrichmentProviderResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.True(t, created.Data.HasAPIKey)
+	assert.True(t, created.Data.IsActive)
+	assert.NotContains(t, string(req.RequestCtx.Response.Body()), "secret-key")
+
+	// An update without api_key keeps the stored key
+	req = testutil.NewJSONRequest(t, map[string]interface{}{
+		"provider":  "custom",
+		"url":       "https://crm.example.com/v2/lookup",
+		"priority":  1,
+		"is_active": true,
+	})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", created.Data.ID.String())
+	require.NoError(t, app.UpdateEnrichmentProvider(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var provider models.EnrichmentProvider
+	require.NoError(t, app.DB.First(&provider, created.Data.ID).Error)
+	assert.Equal(t, "secret-key", provider.APIKey)
+	assert.Equal(t, "https://crm.example.com/v2/lookup", provider.URL)
+	assert.Equal(t, 1, provider.Priority)
+}
+
+func TestApp_GetContactAvatar(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	app.Config.Storage.LocalPath = t.TempDir()
+	org := createTestOrg(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("avatar"), "password", &role.ID, true)
+	contact := createMsgTestContact(t, app, org.ID, "")
+
+	getAvatar := func() *fastglue.Request {
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", contact.ID.String())
+		require.NoError(t, app.GetContactAvatar(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		return req
+	}
+
+	// Without a cached photo an initials avatar is generated
+	req := getAvatar()
+	assert.Equal(t, "image/svg+xml", string(req.RequestCtx.Response.Header.ContentType()))
+
+	photo := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	require.NoError(t, os.MkdirAll(filepath.Join(app.Config.Storage.LocalPath, "avatars"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(app.Config.Storage.LocalPath, "avatars", contact.ID.String()+".png"), photo, 0644))
+	require.NoError(t, app.DB.Model(contact).Updates(map[string]interface{}{
+		"avatar_path":       filepath.Join("avatars", contact.ID.String()+".png"),
+		"avatar_checked_at": time.Now(),
+	}).Error)
+
+	req = getAvatar()
+	assert.Equal(t, "image/png", string(req.RequestCtx.Response.Header.ContentType()))
+	assert.Equal(t, photo, req.RequestCtx.Response.Body())
+
+	// The contact points at the cached photo
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.GetContact(req))
+	var resp struct {
+		Data handlers.ContactResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.True(t, strings.HasPrefix(resp.Data.AvatarURL, "/api/contacts/"+contact.ID.String()+"/avatar?v="))
+}
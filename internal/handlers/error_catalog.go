@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// errorCategory returns the catalog category of a Meta error code, or "" when the
+// message has no code
+func errorCategory(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return string(whatsapp.LookupError(code).Category)
+}
+
+// GetErrorCatalog returns the known Meta error codes with their categories and
+// remediation hints. Filter with ?code= or ?category=.
+func (a *App) GetErrorCatalog(r *fastglue.Request) error {
+	if codeStr := string(r.RequestCtx.QueryArgs().Peek("code")); codeStr != "" {
+		code, err := strconv.Atoi(codeStr)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid error code", nil, "")
+		}
+		return r.SendEnvelope(map[string]any{
+			"errors": []whatsapp.ErrorInfo{whatsapp.LookupError(code)},
+		})
+	}
+
+	catalog := whatsapp.ErrorCatalog()
+	if category := string(r.RequestCtx.QueryArgs().Peek("category")); category != "" {
+		filtered := make([]whatsapp.ErrorInfo, 0)
+		for _, info := range catalog {
+			if string(info.Category) == category {
+				filtered = append(filtered, info)
+			}
+		}
+		catalog = filtered
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"errors": catalog,
+	})
+}
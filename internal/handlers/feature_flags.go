@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/featureflags"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+// FeatureFlagRequest represents the request body for configuring a feature flag
+type FeatureFlagRequest struct {
+	Description    string `json:"description"`
+	Enabled        bool   `json:"enabled"`
+	RolloutPercent *int   `json:"rollout_percent"` // Defaults to 100
+}
+
+// FeatureFlagOverrideRequest forces a flag for one organization; null clears the override
+type FeatureFlagOverrideRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// FeatureFlagOverrideResponse is an organization-level override
+type FeatureFlagOverrideResponse struct {
+	OrganizationID   uuid.UUID `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	Enabled          bool      `json:"enabled"`
+}
+
+// FeatureFlagResponse represents a feature flag with its configuration and overrides
+type FeatureFlagResponse struct {
+	Key            string                        `json:"key"`
+	Description    string                        `json:"description"`
+	BuiltIn        bool                          `json:"built_in"`
+	Configured     bool                          `json:"configured"` // False when running on the built-in default
+	Default        bool                          `json:"default"`
+	Enabled        bool                          `json:"enabled"`
+	RolloutPercent int                           `json:"rollout_percent"`
+	Overrides      []FeatureFlagOverrideResponse `json:"overrides"`
+}
+
+// GetFeatureFlags returns which feature flags are enabled for the current organization
+func (a *App) GetFeatureFlags(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	flags, err := a.getOrgFeatureFlags(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load feature flags", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load feature flags", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"flags": flags,
+	})
+}
+
+// IsFeatureEnabled reports whether a feature flag is on for the organization
+func (a *App) IsFeatureEnabled(orgID uuid.UUID, key string) bool {
+	flags, err := a.getOrgFeatureFlags(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load feature flags", "error", err, "organization_id", orgID)
+		d, _ := featureflags.Lookup(key)
+		return d.Default
+	}
+	return flags[key]
+}
+
+// ListAdminFeatureFlags returns every feature flag with its rollout and overrides (super admin only)
+func (a *App) ListAdminFeatureFlags(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+
+	var stored []models.FeatureFlag
+	if err := a.DB.Find(&stored).Error; err != nil {
+		a.Log.Error("Failed to list feature flags", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list feature flags", nil, "")
+	}
+
+	var overrides []struct {
+		models.FeatureFlagOverride
+		OrganizationName string
+	}
+	if err := a.DB.Model(&models.FeatureFlagOverride{}).
+		Select("feature_flag_overrides.*, organizations.name AS organization_name").
+		Joins("JOIN organizations ON organizations.id = feature_flag_overrides.organization_id").
+		Order("organizations.name ASC").
+		Scan(&overrides).Error; err != nil {
+		a.Log.Error("Failed to list feature flag overrides", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list feature flags", nil, "")
+	}
+
+	flags := make(map[string]*FeatureFlagResponse)
+	for _, d := range featureflags.Known {
+		flags[d.Key] = &FeatureFlagResponse{
+			Key:            d.Key,
+			Description:    d.Description,
+			BuiltIn:        true,
+			Default:        d.Default,
+			Enabled:        d.Default,
+			RolloutPercent: 100,
+		}
+	}
+	for _, f := range stored {
+		resp, ok := flags[f.Key]
+		if !ok {
+			resp = &FeatureFlagResponse{Key: f.Key}
+			flags[f.Key] = resp
+		}
+		if f.Description != "" {
+			resp.Description = f.Description
+		}
+		resp.Configured = true
+		resp.Enabled = f.Enabled
+		resp.RolloutPercent = f.RolloutPercent
+	}
+	for _, o := range overrides {
+		if resp, ok := flags[o.FlagKey]; ok {
+			resp.Overrides = append(resp.Overrides, FeatureFlagOverrideResponse{
+				OrganizationID:   o.OrganizationID,
+				OrganizationName: o.OrganizationName,
+				Enabled:          o.Enabled,
+			})
+		}
+	}
+
+	result := make([]FeatureFlagResponse, 0, len(flags))
+	for _, f := range flags {
+		if f.Overrides == nil {
+			f.Overrides = []FeatureFlagOverrideResponse{}
+		}
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+
+	return r.SendEnvelope(map[string]interface{}{
+		"flags": result,
+	})
+}
+
+// UpdateFeatureFlag creates or updates a feature flag's rollout (super admin only)
+func (a *App) UpdateFeatureFlag(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+
+	key, _ := r.RequestCtx.UserValue("key").(string)
+	if !featureflags.ValidKey(key) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flag key (use lowercase letters, digits and underscores)", nil, "")
+	}
+
+	var req FeatureFlagRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	rollout := 100
+	if req.RolloutPercent != nil {
+		rollout = *req.RolloutPercent
+	}
+	if rollout < 0 || rollout > 100 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "rollout_percent must be between 0 and 100", nil, "")
+	}
+
+	flag := models.FeatureFlag{
+		Key:            key,
+		Description:    req.Description,
+		Enabled:        req.Enabled,
+		RolloutPercent: rollout,
+	}
+	if err := a.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "enabled", "rollout_percent", "updated_at"}),
+	}).Create(&flag).Error; err != nil {
+		a.Log.Error("Failed to save feature flag", "error", err, "key", key)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save feature flag", nil, "")
+	}
+
+	a.InvalidateFeatureFlagsCache(nil)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Feature flag saved",
+		"key":     key,
+	})
+}
+
+// DeleteFeatureFlag removes a flag's configuration and overrides. Built-in flags fall back
+// to their default (super admin only).
+func (a *App) DeleteFeatureFlag(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+
+	key, _ := r.RequestCtx.UserValue("key").(string)
+	result := a.DB.Unscoped().Where("key = ?", key).Delete(&models.FeatureFlag{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete feature flag", "error", result.Error, "key", key)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete feature flag", nil, "")
+	}
+	_, builtIn := featureflags.Lookup(key)
+	if result.RowsAffected == 0 && !builtIn {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Feature flag not found", nil, "")
+	}
+	a.DB.Unscoped().Where("flag_key = ?", key).Delete(&models.FeatureFlagOverride{})
+
+	a.InvalidateFeatureFlagsCache(nil)
+
+	return r.SendEnvelope(map[string]string{"message": "Feature flag deleted"})
+}
+
+// SetFeatureFlagOverride forces a flag on or off for one organization, or clears the
+// override when enabled is null (super admin only)
+func (a *App) SetFeatureFlagOverride(r *fastglue.Request) error {
+	if !a.requireSuperAdmin(r) {
+		return nil
+	}
+
+	key, _ := r.RequestCtx.UserValue("key").(string)
+	if !featureflags.ValidKey(key) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flag key", nil, "")
+	}
+	orgIDStr, _ := r.RequestCtx.UserValue("org_id").(string)
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid organization ID", nil, "")
+	}
+
+	var req FeatureFlagOverrideRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+
+	if req.Enabled == nil {
+		err = a.DB.Unscoped().Where("organization_id = ? AND flag_key = ?", orgID, key).
+			Delete(&models.FeatureFlagOverride{}).Error
+	} else {
+		override := models.FeatureFlagOverride{OrganizationID: orgID, FlagKey: key, Enabled: *req.Enabled}
+		err = a.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "organization_id"}, {Name: "flag_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+		}).Create(&override).Error
+	}
+	if err != nil {
+		a.Log.Error("Failed to save feature flag override", "error", err, "key", key, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save override", nil, "")
+	}
+
+	a.InvalidateFeatureFlagsCache(&orgID)
+
+	return r.SendEnvelope(map[string]string{"message": "Override saved"})
+}
+
+// getOrgFeatureFlags evaluates every known and configured flag for an organization
+func (a *App) getOrgFeatureFlags(orgID uuid.UUID) (map[string]bool, error) {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgFeatureFlagsCachePrefix, orgID.String())
+
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			var flags map[string]bool
+			if err := json.Unmarshal([]byte(cached), &flags); err == nil {
+				return flags, nil
+			}
+		}
+	}
+
+	var stored []models.FeatureFlag
+	if err := a.DB.Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	var overrides []models.FeatureFlagOverride
+	if err := a.DB.Where("organization_id = ?", orgID).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]*featureflags.Rule, len(stored))
+	for _, f := range stored {
+		rules[f.Key] = &featureflags.Rule{Enabled: f.Enabled, RolloutPercent: f.RolloutPercent}
+	}
+	forced := make(map[string]*bool, len(overrides))
+	for _, o := range overrides {
+		enabled := o.Enabled
+		forced[o.FlagKey] = &enabled
+	}
+
+	flags := make(map[string]bool)
+	for _, d := range featureflags.Known {
+		flags[d.Key] = featureflags.Evaluate(d.Key, rules[d.Key], forced[d.Key], orgID)
+	}
+	for key, rule := range rules {
+		flags[key] = featureflags.Evaluate(key, rule, forced[key], orgID)
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(flags); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgFeatureFlagsCacheTTL)
+		}
+	}
+	return flags, nil
+}
+
+// InvalidateFeatureFlagsCache clears cached flags for one organization, or all when orgID is nil
+func (a *App) InvalidateFeatureFlagsCache(orgID *uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	if orgID != nil {
+		a.Redis.Del(ctx, orgFeatureFlagsCachePrefix+orgID.String())
+		return
+	}
+	a.deleteKeysByPattern(ctx, orgFeatureFlagsCachePrefix+"*")
+}
@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/featureflags"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func createSuperAdmin(t *testing.T, app *handlers.App, orgID uuid.UUID) *models.User {
+	t.Helper()
+
+	user := createTestUser(t, app, orgID, uniqueEmail("superadmin"), "password123", nil, true)
+	require.NoError(t, app.DB.Model(user).Update("is_super_admin", true).Error)
+	user.IsSuperAdmin = true
+	return user
+}
+
+func uniqueFlagKey() string {
+	return "test_" + uuid.New().String()[:8]
+}
+
+func putFeatureFlag(t *testing.T, app *handlers.App, user *models.User, key string, body map[string]any) *fasthttp.RequestCtx {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, body)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	testutil.SetPathParam(req, "key", key)
+	require.NoError(t, app.UpdateFeatureFlag(req))
+	return req.RequestCtx
+}
+
+func setFeatureFlagOverride(t *testing.T, app *handlers.App, user *models.User, key string, orgID uuid.UUID, enabled *bool) {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, map[string]any{"enabled": enabled})
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	testutil.SetPathParam(req, "key", key)
+	testutil.SetPathParam(req, "org_id", orgID.String())
+	require.NoError(t, app.SetFeatureFlagOverride(req))
+	require.Equal(t, fasthttp.StatusOK, req.RequestCtx.Response.StatusCode(), string(req.RequestCtx.Response.Body()))
+}
+
+func TestApp_FeatureFlags_RolloutAndOverrides(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	other := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, org.ID)
+	key := uniqueFlagKey()
+
+	// Unknown flags are off until configured
+	assert.False(t, app.IsFeatureEnabled(org.ID, key))
+
+	ctx := putFeatureFlag(t, app, admin, key, map[string]any{"enabled": true})
+	require.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.True(t, app.IsFeatureEnabled(org.ID, key))
+	assert.True(t, app.IsFeatureEnabled(other.ID, key))
+
+	// A zero rollout switches it off everywhere except forced organizations
+	putFeatureFlag(t, app, admin, key, map[string]any{"enabled": true, "rollout_percent": 0})
+	enabled := true
+	setFeatureFlagOverride(t, app, admin, key, org.ID, &enabled)
+	assert.True(t, app.IsFeatureEnabled(org.ID, key))
+	assert.False(t, app.IsFeatureEnabled(other.ID, key))
+
+	// Clearing the override falls back to the rollout
+	setFeatureFlagOverride(t, app, admin, key, org.ID, nil)
+	assert.False(t, app.IsFeatureEnabled(org.ID, key))
+}
+
+func TestApp_FeatureFlags_BuiltInDefaults(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("flags"), "password123", nil, true)
+
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.GetFeatureFlags(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data struct {
+			Flags map[string]bool `json:"flags"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.True(t, resp.Data.Flags[featureflags.Commerce])
+	assert.False(t, resp.Data.Flags[featureflags.AISuggestions])
+	assert.False(t, resp.Data.Flags[featureflags.NewRouting])
+}
+
+func TestApp_FeatureFlags_Validation(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, org.ID)
+
+	ctx := putFeatureFlag(t, app, admin, "Bad-Key", map[string]any{"enabled": true})
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+
+	ctx = putFeatureFlag(t, app, admin, uniqueFlagKey(), map[string]any{"enabled": true, "rollout_percent": 150})
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+}
+
+func TestApp_FeatureFlags_RequiresSuperAdmin(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("not-super"), "password123", nil, true)
+
+	ctx := putFeatureFlag(t, app, user, uniqueFlagKey(), map[string]any{"enabled": true})
+	assert.Equal(t, fasthttp.StatusForbidden, ctx.Response.StatusCode())
+
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	require.NoError(t, app.ListAdminFeatureFlags(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
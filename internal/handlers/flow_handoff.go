@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// transferHandoff is what a transfer carries over from where it was created: notes for
+// the agent, skills that pick who takes it, and the variables a flow collected
+type transferHandoff struct {
+	Notes   string
+	Skills  []string
+	Context models.JSONB
+}
+
+// flowTransferTarget reads a flow's transfer configuration. The team is nil for the
+// general queue.
+func flowTransferTarget(config models.JSONB, sessionData models.JSONB) (*uuid.UUID, transferHandoff) {
+	handoff := transferHandoff{Context: handoffContext(sessionData)}
+	if config == nil {
+		return nil, handoff
+	}
+
+	var teamID *uuid.UUID
+	if teamIDStr, ok := config["team_id"].(string); ok && teamIDStr != "" && teamIDStr != "_general" {
+		if parsedID, err := uuid.Parse(teamIDStr); err == nil {
+			teamID = &parsedID
+		}
+	}
+	if n, ok := config["notes"].(string); ok {
+		handoff.Notes = processTemplate(n, sessionData)
+	}
+	if skills, ok := config["skills"].([]interface{}); ok {
+		for _, s := range skills {
+			if skill, ok := s.(string); ok && strings.TrimSpace(skill) != "" {
+				handoff.Skills = append(handoff.Skills, strings.TrimSpace(skill))
+			}
+		}
+	}
+	return teamID, handoff
+}
+
+// handoffContext returns the variables a flow session collected, leaving out the
+// internal ones whose names start with an underscore
+func handoffContext(sessionData models.JSONB) models.JSONB {
+	context := models.JSONB{}
+	for key, value := range sessionData {
+		if !strings.HasPrefix(key, "_") {
+			context[key] = value
+		}
+	}
+	if len(context) == 0 {
+		return nil
+	}
+	return context
+}
+
+// handOffFlow transfers the conversation out of a flow to the team in config (a transfer
+// step's transfer_config or the flow's abandon_config), or to the general queue when no
+// team is set or the team is gone or inactive
+func (a *App) handOffFlow(account *models.WhatsAppAccount, contact *models.Contact, config models.JSONB, sessionData models.JSONB) {
+	teamID, handoff := flowTransferTarget(config, sessionData)
+	if sim := a.simulation(contact.ID); sim != nil {
+		sim.record(simulationKindTransfer, "", "", models.JSONB{
+			"team_id":         teamID, // Nil for the general queue
+			"notes":           handoff.Notes,
+			"skills":          handoff.Skills,
+			"handoff_context": handoff.Context,
+		})
+		return
+	}
+	if teamID != nil {
+		var count int64
+		a.DB.Model(&models.Team{}).
+			Where("id = ? AND organization_id = ? AND is_active = ?", *teamID, account.OrganizationID, true).
+			Count(&count)
+		if count > 0 {
+			a.createTeamTransfer(account, contact, *teamID, models.TransferSourceFlow, handoff)
+			return
+		}
+		a.Log.Warn("Flow transfer team not found or inactive, using the general queue", "team_id", *teamID, "contact_id", contact.ID)
+	}
+	a.createQueueTransfer(account, contact, models.TransferSourceFlow, handoff)
+}
@@ -0,0 +1,87 @@
+package handlers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_FlowTransferStep_TeamHandoff(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Update("phone_id", "phone-"+uuid.NewString()[:8]).Error)
+	require.NoError(t, app.DB.Create(&models.ChatbotSettings{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		IsEnabled:       true,
+	}).Error)
+
+	generalist := createTestAgent(t, app, org.ID)
+	billing := createTestAgent(t, app, org.ID)
+	require.NoError(t, app.DB.Model(billing).Update("skills", models.StringArray{"Billing"}).Error)
+	team := createTestTeam(t, app, org.ID, generalist.ID, billing.ID)
+
+	require.NoError(t, app.DB.Create(&models.ChatbotFlow{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		Name:            "Billing",
+		IsEnabled:       true,
+		TriggerKeywords: models.StringArray{"invoice"},
+		Steps: []models.ChatbotFlowStep{
+			{StepName: "order", StepOrder: 1, Message: "What's your order number?", InputType: models.InputTypeText, StoreAs: "order_id", NextStep: "handoff"},
+			{StepName: "handoff", StepOrder: 2, Message: "Connecting you to billing", MessageType: models.FlowStepTypeTransfer, TransferConfig: models.JSONB{
+				"team_id": team.ID.String(),
+				"notes":   "Order {{order_id}}",
+				"skills":  []interface{}{"billing"},
+			}},
+		},
+	}).Error)
+
+	const phone = "14155550200"
+	receive := func(text string) {
+		body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+			"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+			"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+			"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"text","text":{"body":%q}}]}}]}]}`,
+			account.PhoneID, phone, phone, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()), text)
+		req := testutil.NewJSONRequest(t, nil)
+		req.RequestCtx.Request.SetBody([]byte(body))
+		require.NoError(t, app.WebhookHandler(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	}
+
+	receive("invoice")
+	require.Eventually(t, func() bool {
+		var count int64
+		app.DB.Model(&models.ChatbotSession{}).
+			Where("organization_id = ? AND phone_number = ? AND status = ?", org.ID, phone, models.SessionStatusActive).
+			Count(&count)
+		return count == 1
+	}, 2*time.Second, 20*time.Millisecond)
+	receive("A-1001")
+
+	var transfer models.AgentTransfer
+	require.Eventually(t, func() bool {
+		return app.DB.Where("organization_id = ? AND phone_number = ?", org.ID, phone).First(&transfer).Error == nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, models.TransferSourceFlow, transfer.Source)
+	require.NotNil(t, transfer.TeamID)
+	assert.Equal(t, team.ID, *transfer.TeamID)
+	require.NotNil(t, transfer.AgentID)
+	assert.Equal(t, billing.ID, *transfer.AgentID)
+	assert.Equal(t, "Order A-1001", transfer.Notes)
+	assert.Equal(t, "A-1001", transfer.HandoffContext["order_id"])
+	assert.NotContains(t, transfer.HandoffContext, "_flow_id")
+}
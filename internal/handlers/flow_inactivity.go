@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// defaultInactivityMessage is sent when a step has an inactivity timeout but no message or branch
+const defaultInactivityMessage = "Are you still there?"
+
+// flowInactivityBatchSize caps how many due sessions are handled per tick
+const flowInactivityBatchSize = 100
+
+// scheduleFlowInactivity (re)starts the inactivity timers for a session waiting on step:
+// the step's nudge and the flow's abandonment action. Due timers are picked up by the SLA processor.
+func (a *App) scheduleFlowInactivity(session *models.ChatbotSession, flow *models.ChatbotFlow, step *models.ChatbotFlowStep) {
+	if a.simulation(session.ID) != nil {
+		return
+	}
+	now := time.Now()
+
+	var nudgeAt, abandonAt *time.Time
+	if step.InactivityTimeoutMins > 0 {
+		t := now.Add(time.Duration(step.InactivityTimeoutMins) * time.Minute)
+		nudgeAt = &t
+	}
+	if flow.AbandonAfterMins > 0 {
+		t := now.Add(time.Duration(flow.AbandonAfterMins) * time.Minute)
+		abandonAt = &t
+	}
+
+	session.StepNudgeAt = nudgeAt
+	session.StepNudged = false
+	session.AbandonAt = abandonAt
+	a.DB.Model(session).Updates(map[string]interface{}{
+		"step_nudge_at": nudgeAt,
+		"step_nudged":   false,
+		"abandon_at":    abandonAt,
+	})
+}
+
+// processFlowInactivity sends due step nudges and runs due abandonment actions for active flow sessions
+func (p *SLAProcessor) processFlowInactivity(now time.Time) {
+	var nudges []models.ChatbotSession
+	if err := p.app.DB.Where("status = ? AND current_flow_id IS NOT NULL AND current_step != '' AND step_nudged = ? AND step_nudge_at <= ?",
+		models.SessionStatusActive, false, now).
+		Limit(flowInactivityBatchSize).Find(&nudges).Error; err != nil {
+		p.app.Log.Error("Failed to find sessions due for inactivity nudge", "error", err)
+	}
+	for i := range nudges {
+		p.nudgeInactiveSession(&nudges[i])
+	}
+
+	var abandoned []models.ChatbotSession
+	if err := p.app.DB.Where("status = ? AND current_flow_id IS NOT NULL AND abandon_at <= ?",
+		models.SessionStatusActive, now).
+		Limit(flowInactivityBatchSize).Find(&abandoned).Error; err != nil {
+		p.app.Log.Error("Failed to find abandoned flow sessions", "error", err)
+	}
+	for i := range abandoned {
+		p.abandonFlowSession(&abandoned[i])
+	}
+}
+
+// loadFlowSessionContext loads the flow, account and contact a session needs to send messages
+func (p *SLAProcessor) loadFlowSessionContext(session *models.ChatbotSession) (*models.ChatbotFlow, *models.WhatsAppAccount, *models.Contact, bool) {
+	flow, err := p.app.getChatbotFlowByIDCached(session.OrganizationID, *session.CurrentFlowID)
+	if err != nil {
+		p.app.Log.Error("Failed to load flow for inactive session", "error", err, "session_id", session.ID)
+		return nil, nil, nil, false
+	}
+
+	var account models.WhatsAppAccount
+	if err := p.app.DB.Where("organization_id = ? AND name = ?", session.OrganizationID, session.WhatsAppAccount).First(&account).Error; err != nil {
+		p.app.Log.Error("Failed to load WhatsApp account for inactive session", "error", err, "session_id", session.ID)
+		return nil, nil, nil, false
+	}
+
+	var contact models.Contact
+	if err := p.app.DB.Where("id = ?", session.ContactID).First(&contact).Error; err != nil {
+		p.app.Log.Error("Failed to load contact for inactive session", "error", err, "session_id", session.ID)
+		return nil, nil, nil, false
+	}
+
+	return flow, &account, &contact, true
+}
+
+// nudgeInactiveSession sends the current step's reminder or branches to its inactivity step
+func (p *SLAProcessor) nudgeInactiveSession(session *models.ChatbotSession) {
+	// Claim the nudge so it fires once even with several app instances
+	result := p.app.DB.Model(&models.ChatbotSession{}).
+		Where("id = ? AND step_nudged = ?", session.ID, false).
+		Update("step_nudged", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	if p.app.hasActiveAgentTransfer(session.OrganizationID, session.ContactID) {
+		return
+	}
+
+	flow, account, contact, ok := p.loadFlowSessionContext(session)
+	if !ok {
+		return
+	}
+
+	step := findFlowStep(flow, session.CurrentStep)
+	if step == nil {
+		return
+	}
+
+	if step.InactivityNextStep != "" {
+		if next := findFlowStep(flow, step.InactivityNextStep); next != nil {
+			session.CurrentStep = next.StepName
+			session.StepRetries = 0
+			p.app.DB.Model(session).Updates(map[string]interface{}{
+				"current_step": next.StepName,
+				"step_retries": 0,
+			})
+			p.app.Log.Info("Flow step inactive, branching", "session_id", session.ID, "from_step", step.StepName, "to_step", next.StepName)
+			p.app.sendStepWithSkipCheck(account, session, contact, next, flow, nil)
+			return
+		}
+		p.app.Log.Warn("Inactivity step not found, sending reminder instead", "session_id", session.ID, "step", step.InactivityNextStep)
+	}
+
+	message := step.InactivityMessage
+	if message == "" {
+		message = defaultInactivityMessage
+	}
+	message = processTemplate(message, p.app.flowTemplateData(session.OrganizationID, session.SessionData))
+	if err := p.app.sendAndSaveTextMessage(account, contact, message); err != nil {
+		p.app.Log.Error("Failed to send inactivity nudge", "error", err, "contact", contact.PhoneNumber)
+		return
+	}
+	p.app.logSessionMessage(session.ID, models.DirectionOutgoing, message, step.StepName+"_nudge")
+
+	p.app.Log.Info("Flow inactivity nudge sent", "session_id", session.ID, "step", step.StepName)
+}
+
+// abandonFlowSession ends a flow the user stopped responding to and runs the flow's abandonment action
+func (p *SLAProcessor) abandonFlowSession(session *models.ChatbotSession) {
+	// Claim the session so the action runs once
+	now := time.Now()
+	result := p.app.DB.Model(&models.ChatbotSession{}).
+		Where("id = ? AND status = ? AND abandon_at IS NOT NULL", session.ID, models.SessionStatusActive).
+		Updates(map[string]interface{}{
+			"abandon_at":    nil,
+			"step_nudge_at": nil,
+			"current_step":  "",
+			"status":        models.SessionStatusTimeout,
+			"completed_at":  now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	p.app.ClearContactChatbotTracking(session.ContactID)
+
+	flow, account, contact, ok := p.loadFlowSessionContext(session)
+	if !ok {
+		return
+	}
+
+	p.app.Log.Info("Flow abandoned", "session_id", session.ID, "flow_id", flow.ID, "action", flow.AbandonAction)
+
+	if flow.AbandonMessage != "" {
+		message := processTemplate(flow.AbandonMessage, p.app.flowTemplateData(session.OrganizationID, session.SessionData))
+		if err := p.app.sendAndSaveTextMessage(account, contact, message); err != nil {
+			p.app.Log.Error("Failed to send flow abandon message", "error", err, "contact", contact.PhoneNumber)
+		}
+		p.app.logSessionMessage(session.ID, models.DirectionOutgoing, message, "flow_abandon")
+	}
+
+	switch flow.AbandonAction {
+	case models.FlowAbandonActionTransfer:
+		p.app.handOffFlow(account, contact, flow.AbandonConfig, session.SessionData)
+
+	case models.FlowAbandonActionWebhook:
+		if len(flow.AbandonConfig) > 0 {
+			p.app.sendFlowWebhook(flow, session, contact, flow.AbandonConfig, "abandoned")
+		}
+	}
+}
+
+// findFlowStep returns the step with the given name, or nil
+func findFlowStep(flow *models.ChatbotFlow, name string) *models.ChatbotFlowStep {
+	for i := range flow.Steps {
+		if flow.Steps[i].StepName == name {
+			return &flow.Steps[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// flowInactivityTest is a contact in the middle of a chatbot flow
+type flowInactivityTest struct {
+	t       *testing.T
+	app     *handlers.App
+	org     *models.Organization
+	account *models.WhatsAppAccount
+	phone   string
+}
+
+// newFlowInactivityTest creates flow and starts it for a contact by sending its trigger keyword
+func newFlowInactivityTest(t *testing.T, flow *models.ChatbotFlow) *flowInactivityTest {
+	t.Helper()
+
+	mockServer := newMockWhatsAppServer()
+	t.Cleanup(mockServer.close)
+
+	app := messageTestApp(t, mockServer)
+	if app.Redis == nil {
+		t.Skip("flows are read from the flows cache, which needs Redis")
+	}
+	org := createTestOrg(t, app)
+	account := createThreadTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Create(&models.ChatbotSettings{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		IsEnabled:       true,
+	}).Error)
+
+	flow.OrganizationID = org.ID
+	flow.WhatsAppAccount = account.Name
+	flow.IsEnabled = true
+	flow.TriggerKeywords = models.StringArray{"invoice"}
+	require.NoError(t, app.DB.Create(flow).Error)
+
+	ft := &flowInactivityTest{t: t, app: app, org: org, account: account, phone: "1415558" + fmt.Sprint(time.Now().UnixNano()%10000)}
+	receiveText(t, app, account, ft.phone, "invoice")
+	require.Eventually(t, func() bool {
+		return ft.session().CurrentStep != ""
+	}, 2*time.Second, 20*time.Millisecond)
+	return ft
+}
+
+// session returns the contact's latest chatbot session
+func (ft *flowInactivityTest) session() models.ChatbotSession {
+	var session models.ChatbotSession
+	ft.app.DB.Where("organization_id = ? AND phone_number = ?", ft.org.ID, ft.phone).Order("created_at DESC").First(&session)
+	return session
+}
+
+// replies returns the contents of the messages sent to the contact
+func (ft *flowInactivityTest) replies() []string {
+	var messages []models.Message
+	ft.app.DB.Joins("JOIN contacts ON contacts.id = messages.contact_id").
+		Where("messages.organization_id = ? AND contacts.phone_number = ? AND messages.direction = ?", ft.org.ID, ft.phone, models.DirectionOutgoing).
+		Order("messages.created_at ASC").Find(&messages)
+	contents := make([]string, len(messages))
+	for i, m := range messages {
+		contents[i] = m.Content
+	}
+	return contents
+}
+
+// makeDue moves the session's timer column into the past and runs the SLA processor
+func (ft *flowInactivityTest) makeDue(column string) {
+	session := ft.session()
+	require.NoError(ft.t, ft.app.DB.Model(&session).Update(column, time.Now().Add(-time.Minute)).Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ft.t.Cleanup(cancel)
+	go handlers.NewSLAProcessor(ft.app, 20*time.Millisecond).Start(ctx)
+}
+
+func TestApp_FlowInactivity_Nudge(t *testing.T) {
+	ft := newFlowInactivityTest(t, &models.ChatbotFlow{
+		Name:             "Billing",
+		AbandonAfterMins: 60,
+		Steps: []models.ChatbotFlowStep{
+			{StepName: "order", StepOrder: 1, Message: "What's your order number?", InputType: models.InputTypeText, StoreAs: "order_id",
+				InactivityTimeoutMins: 5, InactivityMessage: "Still there? Send your order number to continue"},
+		},
+	})
+
+	// Waiting for a reply starts the step's nudge timer and the flow's abandonment timer
+	session := ft.session()
+	require.NotNil(t, session.StepNudgeAt)
+	require.NotNil(t, session.AbandonAt)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), *session.StepNudgeAt, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *session.AbandonAt, time.Minute)
+	assert.False(t, session.StepNudged)
+
+	ft.makeDue("step_nudge_at")
+	require.Eventually(t, func() bool { return len(ft.replies()) == 2 }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "Still there? Send your order number to continue", ft.replies()[1])
+	assert.True(t, ft.session().StepNudged)
+
+	// The nudge is sent once per step
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, ft.replies(), 2)
+	assert.Equal(t, models.SessionStatusActive, ft.session().Status)
+}
+
+func TestApp_FlowInactivity_BranchesToStep(t *testing.T) {
+	ft := newFlowInactivityTest(t, &models.ChatbotFlow{
+		Name: "Billing",
+		Steps: []models.ChatbotFlowStep{
+			{StepName: "order", StepOrder: 1, Message: "What's your order number?", InputType: models.InputTypeText,
+				InactivityTimeoutMins: 5, InactivityNextStep: "email"},
+			{StepName: "email", StepOrder: 2, Message: "No problem, what's your email instead?", InputType: models.InputTypeText},
+		},
+	})
+	assert.Nil(t, ft.session().AbandonAt)
+
+	ft.makeDue("step_nudge_at")
+	require.Eventually(t, func() bool { return ft.session().CurrentStep == "email" }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{"What's your order number?", "No problem, what's your email instead?"}, ft.replies())
+
+	// The new step has no inactivity timeout of its own
+	session := ft.session()
+	assert.Nil(t, session.StepNudgeAt)
+	assert.False(t, session.StepNudged)
+}
+
+func TestApp_FlowInactivity_AbandonTransfersToTeam(t *testing.T) {
+	ft := newFlowInactivityTest(t, &models.ChatbotFlow{
+		Name:             "Billing",
+		AbandonAfterMins: 30,
+		AbandonAction:    models.FlowAbandonActionTransfer,
+		AbandonMessage:   "An agent will follow up with you",
+		Steps: []models.ChatbotFlowStep{
+			{StepName: "order", StepOrder: 1, Message: "What's your order number?", InputType: models.InputTypeText},
+		},
+	})
+	agent := createTestAgent(t, ft.app, ft.org.ID)
+	team := createTestTeam(t, ft.app, ft.org.ID, agent.ID)
+	var flow models.ChatbotFlow
+	require.NoError(t, ft.app.DB.Where("organization_id = ?", ft.org.ID).First(&flow).Error)
+	require.NoError(t, ft.app.DB.Model(&flow).Update("abandon_config", models.JSONB{"team_id": team.ID.String(), "notes": "Left the billing flow"}).Error)
+	ft.app.InvalidateChatbotFlowsCache(ft.org.ID)
+
+	ft.makeDue("abandon_at")
+	var transfer models.AgentTransfer
+	require.Eventually(t, func() bool {
+		return ft.app.DB.Where("organization_id = ? AND phone_number = ?", ft.org.ID, ft.phone).First(&transfer).Error == nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, models.TransferSourceFlow, transfer.Source)
+	require.NotNil(t, transfer.TeamID)
+	assert.Equal(t, team.ID, *transfer.TeamID)
+	assert.Equal(t, "Left the billing flow", transfer.Notes)
+	assert.Contains(t, ft.replies(), "An agent will follow up with you")
+
+	session := ft.session()
+	assert.Equal(t, models.SessionStatusTimeout, session.Status)
+	assert.Nil(t, session.AbandonAt)
+	assert.NotNil(t, session.CompletedAt)
+}
+
+func TestApp_FlowInactivity_AbandonWebhook(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		select {
+		case received <- body:
+		default:
+		}
+	}))
+	defer hook.Close()
+
+	ft := newFlowInactivityTest(t, &models.ChatbotFlow{
+		Name:             "Billing",
+		AbandonAfterMins: 30,
+		AbandonAction:    models.FlowAbandonActionWebhook,
+		AbandonConfig:    models.JSONB{"url": hook.URL},
+		Steps: []models.ChatbotFlowStep{
+			{StepName: "order", StepOrder: 1, Message: "What's your order number?", InputType: models.InputTypeText},
+		},
+	})
+
+	ft.makeDue("abandon_at")
+	select {
+	case body := <-received:
+		assert.Equal(t, "abandoned", body["event"])
+		assert.Contains(t, body, "abandoned_at")
+		assert.Equal(t, ft.phone, body["phone_number"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("abandonment webhook not called")
+	}
+	assert.Equal(t, models.SessionStatusTimeout, ft.session().Status)
+}
+
+func TestApp_CreateChatbotFlow_AbandonValidation(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	agent := createTestAgent(t, app, org.ID)
+
+	create := func(userID uuid.UUID, body map[string]any) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, userID)
+		require.NoError(t, app.CreateChatbotFlow(req))
+		return req
+	}
+	flow := func(extra map[string]any) map[string]any {
+		body := map[string]any{"name": "Billing", "steps": []map[string]any{{"step_name": "order", "message": "Order number?"}}}
+		for k, v := range extra {
+			body[k] = v
+		}
+		return body
+	}
+
+	req := create(admin.ID, flow(map[string]any{"abandon_after_minutes": -1}))
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Abandon timeout cannot be negative")
+
+	req = create(admin.ID, flow(map[string]any{"abandon_after_minutes": 30, "abandon_action": "webhook"}))
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Webhook URL is required for the webhook abandon action")
+
+	req = create(admin.ID, flow(map[string]any{"abandon_after_minutes": 30, "abandon_action": "delete_contact"}))
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Invalid abandon action")
+
+	// Editing flows needs flows.chatbot:write
+	req = create(agent.ID, flow(nil))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	var count int64
+	app.DB.Model(&models.ChatbotFlow{}).Where("organization_id = ?", org.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestApp_CreateChatbotFlow_InactivitySettings(t *testing.T) {
+	app := testApp(t)
+	if app.Redis == nil {
+		t.Skip("saving a flow invalidates the flows cache, which needs Redis")
+	}
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"name":                  "Billing",
+		"abandon_after_minutes": 30,
+		"abandon_action":        "webhook",
+		"abandon_config":        map[string]any{"url": "https://example.com/abandoned"},
+		"abandon_message":       "Come back any time",
+		"steps": []map[string]any{{
+			"step_name":                  "order",
+			"message":                    "Order number?",
+			"input_type":                 "text",
+			"inactivity_timeout_minutes": 5,
+			"inactivity_message":         "Still there?",
+			"inactivity_next_step":       "email",
+		}},
+	})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.CreateChatbotFlow(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var flow models.ChatbotFlow
+	require.NoError(t, app.DB.Where("organization_id = ?", org.ID).Preload("Steps").First(&flow).Error)
+	assert.Equal(t, 30, flow.AbandonAfterMins)
+	assert.Equal(t, models.FlowAbandonActionWebhook, flow.AbandonAction)
+	assert.Equal(t, "https://example.com/abandoned", flow.AbandonConfig["url"])
+	assert.Equal(t, "Come back any time", flow.AbandonMessage)
+	require.Len(t, flow.Steps, 1)
+	assert.Equal(t, 5, flow.Steps[0].InactivityTimeoutMins)
+	assert.Equal(t, "Still there?", flow.Steps[0].InactivityMessage)
+	assert.Equal(t, "email", flow.Steps[0].InactivityNextStep)
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// maxSimulationInputs bounds the inputs one flow simulation replays
+const maxSimulationInputs = 50
+
+// Transcript kinds a simulation records besides the session trace kinds
+const (
+	simulationKindIncoming = "incoming"
+	simulationKindOutgoing = "outgoing"
+	simulationKindTransfer = "transfer" // The flow handed the conversation to an agent queue
+	simulationKindWebhook  = "webhook"  // The flow's completion webhook would have been called
+)
+
+// errSimulationSkipsAPIs fails the API fetch steps of simulations that don't call APIs
+var errSimulationSkipsAPIs = errors.New("API calls are skipped in this simulation")
+
+// SimulationInput is a message from the simulated contact
+type SimulationInput struct {
+	Text         string                 `json:"text"`
+	ButtonID     string                 `json:"button_id"`     // Reply button or list item picked
+	FlowResponse map[string]interface{} `json:"flow_response"` // Fields submitted from a WhatsApp Flow form
+	Location     *SharedLocation        `json:"location"`      // Location pin shared instead of text
+}
+
+// FlowSimulationRequest is the conversation to run a flow against
+type FlowSimulationRequest struct {
+	Inputs   []SimulationInput `json:"inputs"`
+	Version  int               `json:"version"`   // Version to run, such as the draft; 0 runs the live flow
+	CallAPIs bool              `json:"call_apis"` // Let API fetch steps call their APIs
+}
+
+// FlowSimulationResponse is how a flow handled a simulated conversation
+type FlowSimulationResponse struct {
+	Transcript  []SessionReplayEntry `json:"transcript"`
+	SessionData models.JSONB         `json:"session_data"`
+	CurrentStep string               `json:"current_step,omitempty"` // Step still waiting for input
+	Completed   bool                 `json:"completed"`
+	InputsUsed  int                  `json:"inputs_used"`
+}
+
+// flowSimulation stands in for WhatsApp and the database while a flow runs against an
+// in-memory session: what the flow sends and decides is recorded instead of done
+type flowSimulation struct {
+	flow       *models.ChatbotFlow
+	callAPIs   bool
+	transcript []SessionReplayEntry
+}
+
+// record adds an entry to the simulation's transcript
+func (s *flowSimulation) record(kind, stepName, message string, detail models.JSONB) {
+	s.transcript = append(s.transcript, SessionReplayEntry{
+		At:       time.Now(),
+		Kind:     kind,
+		StepName: stepName,
+		Message:  message,
+		Detail:   detail,
+	})
+}
+
+// send records an outgoing message. It gets its step name once the message is logged.
+func (s *flowSimulation) send(message string, detail models.JSONB) {
+	s.record(simulationKindOutgoing, "", message, detail)
+}
+
+// nameSent names the outgoing messages sent since the contact's last input
+func (s *flowSimulation) nameSent(stepName string) {
+	for i := len(s.transcript) - 1; i >= 0; i-- {
+		entry := &s.transcript[i]
+		if entry.Kind == simulationKindIncoming {
+			return
+		}
+		if entry.Kind == simulationKindOutgoing && entry.StepName == "" {
+			entry.StepName = stepName
+		}
+	}
+}
+
+// simulation returns the simulation a session or contact belongs to, or nil for real ones
+func (a *App) simulation(id uuid.UUID) *flowSimulation {
+	if sim, ok := a.simulations.Load(id); ok {
+		return sim.(*flowSimulation)
+	}
+	return nil
+}
+
+// sessionFlow returns the flow a session is in
+func (a *App) sessionFlow(orgID uuid.UUID, session *models.ChatbotSession) (*models.ChatbotFlow, error) {
+	if sim := a.simulation(session.ID); sim != nil {
+		return sim.flow, nil
+	}
+	return a.getChatbotFlowByIDCached(orgID, *session.CurrentFlowID)
+}
+
+// sessionDB returns the database to save a session with. Simulated sessions get a dry
+// run, which updates the in-memory session without writing it.
+func (a *App) sessionDB(session *models.ChatbotSession) *gorm.DB {
+	if a.simulation(session.ID) != nil {
+		return a.DB.Session(&gorm.Session{DryRun: true})
+	}
+	return a.DB
+}
+
+// SimulateChatbotFlow runs a flow against an in-memory session with a sequence of
+// inputs and returns what it sent and decided. Nothing is sent to WhatsApp or saved.
+func (a *App) SimulateChatbotFlow(r *fastglue.Request) error {
+	flow, err := a.flowFromPath(r, models.ActionWrite)
+	if err != nil || flow == nil {
+		return err
+	}
+
+	var req FlowSimulationRequest
+	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.Inputs) > maxSimulationInputs {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("At most %d inputs can be simulated", maxSimulationInputs), nil, "")
+	}
+
+	if req.Version > 0 {
+		var version models.ChatbotFlowVersion
+		if err := a.DB.Where("flow_id = ? AND version = ?", flow.ID, req.Version).First(&version).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Version not found", nil, "")
+		}
+		def, err := flowDefinitionFromJSONB(version.Definition)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read flow version", nil, "")
+		}
+		def.applyTo(flow)
+		flow.Steps = buildFlowSteps(flow.ID, def.Steps)
+	}
+
+	return r.SendEnvelope(a.simulateFlow(flow, req.Inputs, req.CallAPIs))
+}
+
+// simulateFlow starts the flow for a made-up contact and answers each step with the
+// next input until the inputs run out or the flow ends
+func (a *App) simulateFlow(flow *models.ChatbotFlow, inputs []SimulationInput, callAPIs bool) FlowSimulationResponse {
+	now := time.Now()
+	account := &models.WhatsAppAccount{OrganizationID: flow.OrganizationID, Name: "simulator"}
+	contact := &models.Contact{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: flow.OrganizationID,
+		PhoneNumber:    "simulator",
+		ProfileName:    "Simulator",
+	}
+	session := &models.ChatbotSession{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  flow.OrganizationID,
+		ContactID:       contact.ID,
+		WhatsAppAccount: account.Name,
+		PhoneNumber:     contact.PhoneNumber,
+		Status:          models.SessionStatusActive,
+		SessionData:     models.JSONB{},
+		StartedAt:       now,
+		LastActivityAt:  now,
+	}
+
+	sim := &flowSimulation{flow: flow, callAPIs: callAPIs}
+	a.simulations.Store(session.ID, sim)
+	a.simulations.Store(contact.ID, sim)
+	defer func() {
+		a.simulations.Delete(session.ID)
+		a.simulations.Delete(contact.ID)
+	}()
+
+	a.startFlow(account, session, contact, flow)
+
+	used := 0
+	for _, input := range inputs {
+		if session.Status != models.SessionStatusActive || session.CurrentStep == "" {
+			break
+		}
+		text := input.Text
+		var detail models.JSONB
+		if input.ButtonID != "" || len(input.FlowResponse) > 0 {
+			detail = models.JSONB{"button_id": input.ButtonID, "flow_response": input.FlowResponse}
+		}
+		if input.Location != nil {
+			text = input.Location.Coordinates()
+			detail = models.JSONB{"location": input.Location}
+		}
+		sim.record(simulationKindIncoming, session.CurrentStep, text, detail)
+		a.processFlowResponse(account, session, contact, text, input.ButtonID, input.FlowResponse, input.Location)
+		used++
+	}
+
+	response := FlowSimulationResponse{
+		Transcript:  sim.transcript,
+		SessionData: session.SessionData,
+		Completed:   session.Status != models.SessionStatusActive || session.CurrentStep == "",
+		InputsUsed:  used,
+	}
+	if !response.Completed {
+		response.CurrentStep = session.CurrentStep
+	}
+	return response
+}
@@ -0,0 +1,197 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_SimulateChatbotFlow(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	flow := models.ChatbotFlow{
+		BaseModel:         models.BaseModel{ID: uuid.New()},
+		OrganizationID:    org.ID,
+		Name:              "Plans",
+		InitialMessage:    "Welcome!",
+		CompletionMessage: "Thanks {{name}}",
+		OnCompleteAction:  "webhook",
+		CompletionConfig:  models.JSONB{"url": "https://example.com/hook"},
+		RolloutPercent:    100,
+	}
+	require.NoError(t, app.DB.Create(&flow).Error)
+	steps := []models.ChatbotFlowStep{
+		{
+			StepName:        "ask_name",
+			StepOrder:       1,
+			Message:         "What is your name?",
+			MessageType:     models.FlowStepTypeText,
+			InputType:       models.InputTypeText,
+			StoreAs:         "name",
+			ValidationRegex: "^[A-Za-z]+$",
+			ValidationError: "Letters only please",
+			RetryOnInvalid:  true,
+			MaxRetries:      3,
+		},
+		{
+			StepName:    "ask_plan",
+			StepOrder:   2,
+			Message:     "Pick a plan, {{name}}",
+			MessageType: models.FlowStepTypeButtons,
+			InputType:   models.InputTypeButton,
+			StoreAs:     "plan",
+			Buttons: models.JSONBArray{
+				map[string]interface{}{"id": "basic", "title": "Basic"},
+				map[string]interface{}{"id": "premium", "title": "Premium"},
+			},
+			ConditionalNext: models.JSONB{"premium": "premium_info", "default": ""},
+			MaxRetries:      3,
+		},
+		{
+			StepName:    "premium_info",
+			StepOrder:   3,
+			Message:     "Premium it is",
+			MessageType: models.FlowStepTypeText,
+			InputType:   models.InputTypeNone,
+		},
+	}
+	for i := range steps {
+		steps[i].ID = uuid.New()
+		steps[i].FlowID = flow.ID
+		require.NoError(t, app.DB.Create(&steps[i]).Error)
+	}
+
+	simulate := func(body map[string]any) handlers.FlowSimulationResponse {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", flow.ID.String())
+		require.NoError(t, app.SimulateChatbotFlow(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(testutil.GetResponseBody(req)))
+		var resp struct {
+			Data handlers.FlowSimulationResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data
+	}
+
+	result := simulate(map[string]any{"inputs": []map[string]any{
+		{"text": "R2D2"},
+		{"text": "Ada"},
+		{"text": "Premium", "button_id": "premium"},
+		{"text": "left over"},
+	}})
+	assert.True(t, result.Completed)
+	assert.Equal(t, 3, result.InputsUsed)
+	assert.Equal(t, "Ada", result.SessionData["name"])
+	assert.Equal(t, "premium", result.SessionData["plan"])
+
+	var outgoing []string
+	kinds := map[string]int{}
+	for _, entry := range result.Transcript {
+		kinds[entry.Kind]++
+		if entry.Kind == "outgoing" {
+			outgoing = append(outgoing, entry.Message)
+		}
+		if entry.Kind == "condition" {
+			assert.Equal(t, "premium_info", entry.Detail["next_step"])
+		}
+	}
+	assert.Equal(t, []string{
+		"Welcome!",
+		"What is your name?",
+		"Letters only please",
+		"Pick a plan, Ada",
+		"Premium it is",
+		"Thanks Ada",
+	}, outgoing)
+	assert.Equal(t, 1, kinds["condition"])
+	assert.Equal(t, 1, kinds["webhook"])
+
+	// Waiting on a step when the inputs run out
+	result = simulate(map[string]any{"inputs": []map[string]any{{"text": "Ada"}}})
+	assert.False(t, result.Completed)
+	assert.Equal(t, "ask_plan", result.CurrentStep)
+
+	// Nothing was sent or saved
+	var count int64
+	app.DB.Model(&models.ChatbotSession{}).Where("organization_id = ?", org.ID).Count(&count)
+	assert.Zero(t, count)
+	app.DB.Model(&models.Message{}).Where("organization_id = ?", org.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestApp_SimulateChatbotFlow_Location(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	flow := models.ChatbotFlow{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: org.ID,
+		Name:           "Store finder",
+		RolloutPercent: 100,
+	}
+	require.NoError(t, app.DB.Create(&flow).Error)
+	steps := []models.ChatbotFlowStep{
+		{
+			StepName:       "ask_location",
+			StepOrder:      1,
+			Message:        "Where are you?",
+			MessageType:    models.FlowStepTypeText,
+			InputType:      models.InputTypeLocation,
+			StoreAs:        "where",
+			RetryOnInvalid: true,
+			MaxRetries:     3,
+		},
+		{
+			StepName:    "confirm",
+			StepOrder:   2,
+			Message:     "Looking near {{where_name}} ({{where_latitude}}, {{where_longitude}})",
+			MessageType: models.FlowStepTypeText,
+			InputType:   models.InputTypeNone,
+		},
+	}
+	for i := range steps {
+		steps[i].ID = uuid.New()
+		steps[i].FlowID = flow.ID
+		require.NoError(t, app.DB.Create(&steps[i]).Error)
+	}
+
+	req := testutil.NewJSONRequest(t, map[string]any{"inputs": []map[string]any{
+		{"text": "Bangalore"},
+		{"location": map[string]any{"latitude": 12.9716, "longitude": 77.5946, "name": "MG Road"}},
+	}})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", flow.ID.String())
+	require.NoError(t, app.SimulateChatbotFlow(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		Data handlers.FlowSimulationResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+
+	assert.True(t, resp.Data.Completed)
+	assert.Equal(t, "12.9716,77.5946", resp.Data.SessionData["where"])
+	assert.Equal(t, "12.9716", resp.Data.SessionData["where_latitude"])
+	assert.Equal(t, "MG Road", resp.Data.SessionData["where_name"])
+
+	var outgoing []string
+	for _, entry := range resp.Data.Transcript {
+		if entry.Kind == "outgoing" {
+			outgoing = append(outgoing, entry.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		"Where are you?",
+		"Please share a location.",
+		"Looking near MG Road (12.9716, 77.5946)",
+	}, outgoing)
+}
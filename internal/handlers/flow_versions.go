@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// errNoFlowDraft is returned when publishing a flow that has no draft
+var errNoFlowDraft = errors.New("flow has no draft")
+
+// ChatbotFlowDefinition is the editable content of a chatbot flow. Every version stores
+// one, and publishing a version copies it onto the live flow. Whether the flow is
+// enabled and its rollout are operational switches that apply immediately instead.
+type ChatbotFlowDefinition struct {
+	Name              string                 `json:"name"`
+	Description       string                 `json:"description"`
+	TriggerKeywords   []string               `json:"trigger_keywords"`
+	InitialMessage    string                 `json:"initial_message"`
+	CompletionMessage string                 `json:"completion_message"`
+	OnCompleteAction  string                 `json:"on_complete_action"`
+	CompletionConfig  map[string]interface{} `json:"completion_config"`
+	PanelConfig       map[string]interface{} `json:"panel_config"`
+	Steps             []FlowStepRequest      `json:"steps"`
+
+	AbandonAfterMins int                      `json:"abandon_after_minutes"`
+	AbandonAction    models.FlowAbandonAction `json:"abandon_action"`
+	AbandonConfig    map[string]interface{}   `json:"abandon_config"`
+	AbandonMessage   string                   `json:"abandon_message"`
+}
+
+// ChatbotFlowVersionResponse is a version in the flow's history
+type ChatbotFlowVersionResponse struct {
+	ID          uuid.UUID                `json:"id"`
+	Version     int                      `json:"version"`
+	Status      models.FlowVersionStatus `json:"status"`
+	Name        string                   `json:"name"`
+	StepsCount  int                      `json:"steps_count"`
+	CreatedBy   string                   `json:"created_by,omitempty"`
+	PublishedBy string                   `json:"published_by,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+	PublishedAt *time.Time               `json:"published_at,omitempty"`
+	Definition  *ChatbotFlowDefinition   `json:"definition,omitempty"`
+}
+
+// ChatbotFlowDetailResponse is the live flow with the versions it's at
+type ChatbotFlowDetailResponse struct {
+	models.ChatbotFlow
+	PublishedVersion int  `json:"published_version,omitempty"`
+	DraftVersion     *int `json:"draft_version,omitempty"`
+}
+
+// validate checks the definition before it's saved
+func (d *ChatbotFlowDefinition) validate() string {
+	if d.Name == "" {
+		return "Name is required"
+	}
+	if d.AbandonAfterMins < 0 {
+		return "Abandon timeout cannot be negative"
+	}
+	return validateFlowAbandonAction(d.AbandonAction, d.AbandonConfig)
+}
+
+// toJSONB converts the definition for storage on a version
+func (d *ChatbotFlowDefinition) toJSONB() models.JSONB {
+	data, _ := json.Marshal(d)
+	var out models.JSONB
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// flowDefinitionFromJSONB reads a definition stored on a version
+func flowDefinitionFromJSONB(j models.JSONB) (ChatbotFlowDefinition, error) {
+	var def ChatbotFlowDefinition
+	data, err := json.Marshal(j)
+	if err != nil {
+		return def, err
+	}
+	err = json.Unmarshal(data, &def)
+	return def, err
+}
+
+// flowDefinitionOf returns the live content of a flow whose steps are loaded in order
+func flowDefinitionOf(flow *models.ChatbotFlow) ChatbotFlowDefinition {
+	def := ChatbotFlowDefinition{
+		Name:              flow.Name,
+		Description:       flow.Description,
+		TriggerKeywords:   flow.TriggerKeywords,
+		InitialMessage:    flow.InitialMessage,
+		CompletionMessage: flow.CompletionMessage,
+		OnCompleteAction:  flow.OnCompleteAction,
+		CompletionConfig:  flow.CompletionConfig,
+		PanelConfig:       flow.PanelConfig,
+		AbandonAfterMins:  flow.AbandonAfterMins,
+		AbandonAction:     flow.AbandonAction,
+		AbandonConfig:     flow.AbandonConfig,
+		AbandonMessage:    flow.AbandonMessage,
+		Steps:             make([]FlowStepRequest, len(flow.Steps)),
+	}
+	for i, step := range flow.Steps {
+		buttons := make([]map[string]interface{}, 0, len(step.Buttons))
+		for _, btn := range step.Buttons {
+			if m, ok := btn.(map[string]interface{}); ok {
+				buttons = append(buttons, m)
+			}
+		}
+		def.Steps[i] = FlowStepRequest{
+			StepName:        step.StepName,
+			StepOrder:       step.StepOrder,
+			Message:         step.Message,
+			MessageType:     step.MessageType,
+			InputType:       step.InputType,
+			InputConfig:     step.InputConfig,
+			ApiConfig:       step.ApiConfig,
+			Buttons:         buttons,
+			TransferConfig:  step.TransferConfig,
+			ValidationRegex: step.ValidationRegex,
+			ValidationError: step.ValidationError,
+			StoreAs:         step.StoreAs,
+			NextStep:        step.NextStep,
+			ConditionalNext: step.ConditionalNext,
+			SkipCondition:   step.SkipCondition,
+			RetryOnInvalid:  step.RetryOnInvalid,
+			MaxRetries:      step.MaxRetries,
+
+			InactivityTimeoutMins: step.InactivityTimeoutMins,
+			InactivityMessage:     step.InactivityMessage,
+			InactivityNextStep:    step.InactivityNextStep,
+		}
+	}
+	return def
+}
+
+// applyTo copies the definition's settings onto the flow
+func (d *ChatbotFlowDefinition) applyTo(flow *models.ChatbotFlow) {
+	flow.Name = d.Name
+	flow.Description = d.Description
+	flow.TriggerKeywords = d.TriggerKeywords
+	flow.InitialMessage = d.InitialMessage
+	flow.CompletionMessage = d.CompletionMessage
+	flow.OnCompleteAction = d.OnCompleteAction
+	flow.CompletionConfig = models.JSONB(d.CompletionConfig)
+	flow.PanelConfig = models.JSONB(d.PanelConfig)
+	flow.AbandonAfterMins = d.AbandonAfterMins
+	flow.AbandonAction = d.AbandonAction
+	flow.AbandonConfig = models.JSONB(d.AbandonConfig)
+	flow.AbandonMessage = d.AbandonMessage
+}
+
+// buildFlowSteps converts step requests into the flow's steps, numbered in order
+func buildFlowSteps(flowID uuid.UUID, reqs []FlowStepRequest) []models.ChatbotFlowStep {
+	steps := make([]models.ChatbotFlowStep, len(reqs))
+	for i, stepReq := range reqs {
+		// Convert buttons to JSONBArray
+		var buttons models.JSONBArray
+		for _, btn := range stepReq.Buttons {
+			buttons = append(buttons, btn)
+		}
+
+		step := models.ChatbotFlowStep{
+			BaseModel:       models.BaseModel{ID: uuid.New()},
+			FlowID:          flowID,
+			StepName:        stepReq.StepName,
+			StepOrder:       i + 1,
+			Message:         stepReq.Message,
+			MessageType:     stepReq.MessageType,
+			InputType:       stepReq.InputType,
+			InputConfig:     models.JSONB(stepReq.InputConfig),
+			ApiConfig:       models.JSONB(stepReq.ApiConfig),
+			Buttons:         buttons,
+			TransferConfig:  models.JSONB(stepReq.TransferConfig),
+			ValidationRegex: stepReq.ValidationRegex,
+			ValidationError: stepReq.ValidationError,
+			StoreAs:         stepReq.StoreAs,
+			NextStep:        stepReq.NextStep,
+			ConditionalNext: models.JSONB(stepReq.ConditionalNext),
+			SkipCondition:   stepReq.SkipCondition,
+			RetryOnInvalid:  stepReq.RetryOnInvalid,
+			MaxRetries:      stepReq.MaxRetries,
+
+			InactivityTimeoutMins: stepReq.InactivityTimeoutMins,
+			InactivityMessage:     stepReq.InactivityMessage,
+			InactivityNextStep:    stepReq.InactivityNextStep,
+		}
+		if step.MessageType == "" {
+			step.MessageType = models.FlowStepTypeText
+		}
+		if step.MaxRetries == 0 {
+			step.MaxRetries = 3
+		}
+		steps[i] = step
+	}
+	return steps
+}
+
+// makeFlowLive replaces the live flow's settings and steps with the definition
+func makeFlowLive(tx *gorm.DB, flow *models.ChatbotFlow, def *ChatbotFlowDefinition) error {
+	def.applyTo(flow)
+	if err := tx.Omit("Steps").Save(flow).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("flow_id = ?", flow.ID).Delete(&models.ChatbotFlowStep{}).Error; err != nil {
+		return err
+	}
+	steps := buildFlowSteps(flow.ID, def.Steps)
+	if len(steps) > 0 {
+		if err := tx.Create(&steps).Error; err != nil {
+			return err
+		}
+	}
+	flow.Steps = steps
+	return nil
+}
+
+// ensureFlowVersions records the live flow as its first published version when it has
+// no history yet, as for flows created before versioning
+func ensureFlowVersions(tx *gorm.DB, flow *models.ChatbotFlow) error {
+	var count int64
+	if err := tx.Model(&models.ChatbotFlowVersion{}).Where("flow_id = ?", flow.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	def := flowDefinitionOf(flow)
+	publishedAt := flow.UpdatedAt
+	return tx.Create(&models.ChatbotFlowVersion{
+		OrganizationID: flow.OrganizationID,
+		FlowID:         flow.ID,
+		Version:        1,
+		Status:         models.FlowVersionStatusPublished,
+		Definition:     def.toJSONB(),
+		PublishedAt:    &publishedAt,
+	}).Error
+}
+
+// saveFlowDraft stores the definition on the flow's draft, starting a new draft version
+// when there is none
+func saveFlowDraft(tx *gorm.DB, flow *models.ChatbotFlow, def *ChatbotFlowDefinition, userID uuid.UUID) (*models.ChatbotFlowVersion, error) {
+	var draft models.ChatbotFlowVersion
+	err := tx.Where("flow_id = ? AND status = ?", flow.ID, models.FlowVersionStatusDraft).First(&draft).Error
+	if err == nil {
+		draft.Definition = def.toJSONB()
+		return &draft, tx.Model(&draft).Update("definition", draft.Definition).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var latest int
+	if err := tx.Model(&models.ChatbotFlowVersion{}).Where("flow_id = ?", flow.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+	draft = models.ChatbotFlowVersion{
+		OrganizationID: flow.OrganizationID,
+		FlowID:         flow.ID,
+		Version:        latest + 1,
+		Status:         models.FlowVersionStatusDraft,
+		Definition:     def.toJSONB(),
+		CreatedByID:    &userID,
+	}
+	return &draft, tx.Create(&draft).Error
+}
+
+// publishFlowVersion makes the version live and supersedes the previously published one.
+// Publishing the draft releases it; publishing an earlier version rolls back to it.
+func publishFlowVersion(tx *gorm.DB, flow *models.ChatbotFlow, version *models.ChatbotFlowVersion, userID uuid.UUID) error {
+	def, err := flowDefinitionFromJSONB(version.Definition)
+	if err != nil {
+		return err
+	}
+	if err := makeFlowLive(tx, flow, &def); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.ChatbotFlowVersion{}).
+		Where("flow_id = ? AND status = ?", flow.ID, models.FlowVersionStatusPublished).
+		Update("status", models.FlowVersionStatusSuperseded).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	version.Status = models.FlowVersionStatusPublished
+	version.PublishedAt = &now
+	version.PublishedByID = &userID
+	return tx.Model(version).Select("status", "published_at", "published_by_id").Updates(version).Error
+}
+
+// flowFromPath checks permissions and loads the flow from the path with its steps in
+// order. On failure it sends the error response and returns a nil flow.
+func (a *App) flowFromPath(r *fastglue.Request, action string) (*models.ChatbotFlow, error) {
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	if !a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, action) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Permission denied", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flow ID", nil, "")
+	}
+
+	var flow models.ChatbotFlow
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("step_order ASC")
+		}).
+		First(&flow).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
+	}
+	return &flow, nil
+}
+
+// ListChatbotFlowVersions returns a flow's version history, newest first
+func (a *App) ListChatbotFlowVersions(r *fastglue.Request) error {
+	flow, err := a.flowFromPath(r, models.ActionRead)
+	if err != nil || flow == nil {
+		return err
+	}
+
+	if err := ensureFlowVersions(a.DB, flow); err != nil {
+		a.Log.Error("Failed to record flow version", "error", err, "flow_id", flow.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load versions", nil, "")
+	}
+
+	var versions []models.ChatbotFlowVersion
+	if err := a.DB.Where("flow_id = ?", flow.ID).
+		Preload("CreatedBy").Preload("PublishedBy").
+		Order("version DESC").Find(&versions).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load versions", nil, "")
+	}
+
+	response := make([]ChatbotFlowVersionResponse, len(versions))
+	for i := range versions {
+		response[i] = buildFlowVersionResponse(&versions[i], false)
+	}
+	return r.SendEnvelope(map[string]interface{}{"versions": response})
+}
+
+// GetChatbotFlowVersion returns one version with its definition
+func (a *App) GetChatbotFlowVersion(r *fastglue.Request) error {
+	flow, err := a.flowFromPath(r, models.ActionRead)
+	if err != nil || flow == nil {
+		return err
+	}
+
+	version, err := a.findFlowVersion(r, flow)
+	if err != nil || version == nil {
+		return err
+	}
+	return r.SendEnvelope(map[string]interface{}{"version": buildFlowVersionResponse(version, true)})
+}
+
+// PublishChatbotFlow makes the flow's draft live
+func (a *App) PublishChatbotFlow(r *fastglue.Request) error {
+	flow, err := a.flowFromPath(r, models.ActionWrite)
+	if err != nil || flow == nil {
+		return err
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var published models.ChatbotFlowVersion
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("flow_id = ? AND status = ?", flow.ID, models.FlowVersionStatusDraft).First(&published).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errNoFlowDraft
+			}
+			return err
+		}
+		return publishFlowVersion(tx, flow, &published, userID)
+	})
+	if errors.Is(err, errNoFlowDraft) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Flow has no draft to publish", nil, "")
+	}
+	if err != nil {
+		a.Log.Error("Failed to publish flow", "error", err, "flow_id", flow.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to publish flow", nil, "")
+	}
+
+	a.InvalidateChatbotFlowsCache(flow.OrganizationID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Flow published",
+		"version": published.Version,
+	})
+}
+
+// RollbackChatbotFlow makes an earlier version live again. A pending draft is kept.
+func (a *App) RollbackChatbotFlow(r *fastglue.Request) error {
+	flow, err := a.flowFromPath(r, models.ActionWrite)
+	if err != nil || flow == nil {
+		return err
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	version, err := a.findFlowVersion(r, flow)
+	if err != nil || version == nil {
+		return err
+	}
+	switch version.Status {
+	case models.FlowVersionStatusDraft:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Publish the draft instead of rolling back to it", nil, "")
+	case models.FlowVersionStatusPublished:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "This version is already live", nil, "")
+	}
+
+	if err := a.DB.Transaction(func(tx *gorm.DB) error {
+		return publishFlowVersion(tx, flow, version, userID)
+	}); err != nil {
+		a.Log.Error("Failed to roll back flow", "error", err, "flow_id", flow.ID, "version", version.Version)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to roll back flow", nil, "")
+	}
+
+	a.InvalidateChatbotFlowsCache(flow.OrganizationID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Flow rolled back to version " + strconv.Itoa(version.Version),
+		"version": version.Version,
+	})
+}
+
+// DiscardChatbotFlowDraft deletes the flow's draft, leaving the live version as is
+func (a *App) DiscardChatbotFlowDraft(r *fastglue.Request) error {
+	flow, err := a.flowFromPath(r, models.ActionWrite)
+	if err != nil || flow == nil {
+		return err
+	}
+
+	result := a.DB.Where("flow_id = ? AND status = ?", flow.ID, models.FlowVersionStatusDraft).Delete(&models.ChatbotFlowVersion{})
+	if result.Error != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to discard draft", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow has no draft", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Draft discarded"})
+}
+
+// findFlowVersion loads the version named by the path's version number. On failure it
+// sends the error response and returns a nil version.
+func (a *App) findFlowVersion(r *fastglue.Request, flow *models.ChatbotFlow) (*models.ChatbotFlowVersion, error) {
+	number, err := strconv.Atoi(r.RequestCtx.UserValue("version").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid version", nil, "")
+	}
+	if err := ensureFlowVersions(a.DB, flow); err != nil {
+		a.Log.Error("Failed to record flow version", "error", err, "flow_id", flow.ID)
+		return nil, r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load version", nil, "")
+	}
+
+	var version models.ChatbotFlowVersion
+	if err := a.DB.Where("flow_id = ? AND version = ?", flow.ID, number).
+		Preload("CreatedBy").Preload("PublishedBy").
+		First(&version).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Version not found", nil, "")
+	}
+	return &version, nil
+}
+
+// flowVersionNumbers returns the published and draft version numbers of the given flows
+func (a *App) flowVersionNumbers(flowIDs []uuid.UUID) (published map[uuid.UUID]int, drafts map[uuid.UUID]int) {
+	published, drafts = make(map[uuid.UUID]int), make(map[uuid.UUID]int)
+	if len(flowIDs) == 0 {
+		return published, drafts
+	}
+	var versions []models.ChatbotFlowVersion
+	if err := a.DB.Select("flow_id", "version", "status").
+		Where("flow_id IN ? AND status IN ?", flowIDs,
+			[]models.FlowVersionStatus{models.FlowVersionStatusPublished, models.FlowVersionStatusDraft}).
+		Find(&versions).Error; err != nil {
+		a.Log.Error("Failed to load flow versions", "error", err)
+		return published, drafts
+	}
+	for _, v := range versions {
+		if v.Status == models.FlowVersionStatusDraft {
+			drafts[v.FlowID] = v.Version
+		} else {
+			published[v.FlowID] = v.Version
+		}
+	}
+	return published, drafts
+}
+
+// buildFlowVersionResponse converts a version for the API, with its definition if asked
+func buildFlowVersionResponse(v *models.ChatbotFlowVersion, withDefinition bool) ChatbotFlowVersionResponse {
+	def, _ := flowDefinitionFromJSONB(v.Definition)
+	resp := ChatbotFlowVersionResponse{
+		ID:          v.ID,
+		Version:     v.Version,
+		Status:      v.Status,
+		Name:        def.Name,
+		StepsCount:  len(def.Steps),
+		CreatedAt:   v.CreatedAt,
+		UpdatedAt:   v.UpdatedAt,
+		PublishedAt: v.PublishedAt,
+	}
+	if v.CreatedBy != nil {
+		resp.CreatedBy = v.CreatedBy.FullName
+	}
+	if v.PublishedBy != nil {
+		resp.PublishedBy = v.PublishedBy.FullName
+	}
+	if withDefinition {
+		resp.Definition = &def
+	}
+	return resp
+}
@@ -0,0 +1,132 @@
+package handlers_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestApp_ChatbotFlowVersions(t *testing.T) {
+	app := testApp(t)
+	if app.Redis == nil {
+		t.Skip("saving a flow invalidates the flows cache, which needs Redis")
+	}
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	call := func(handler func(*fastglue.Request) error, flowID uuid.UUID, version int, body any) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", flowID.String())
+		if version > 0 {
+			testutil.SetPathParam(req, "version", strconv.Itoa(version))
+		}
+		require.NoError(t, handler(req))
+		return req
+	}
+	liveFlow := func(id uuid.UUID) handlers.ChatbotFlowDetailResponse {
+		req := call(app.GetChatbotFlow, id, 0, nil)
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp struct {
+			Data handlers.ChatbotFlowDetailResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data
+	}
+
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"name":             "Onboarding",
+		"trigger_keywords": []string{"start"},
+		"enabled":          true,
+		"steps":            []map[string]any{{"step_name": "greet", "message": "Hi"}},
+	})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.CreateChatbotFlow(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var created struct {
+		Data struct {
+			ID uuid.UUID `json:"id"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	flowID := created.Data.ID
+
+	flow := liveFlow(flowID)
+	assert.Equal(t, 1, flow.PublishedVersion)
+	assert.Nil(t, flow.DraftVersion)
+
+	// Editing saves a draft and leaves the live flow alone
+	edit := map[string]any{
+		"name":  "Onboarding v2",
+		"steps": []map[string]any{{"step_name": "greet", "message": "Hello"}, {"step_name": "ask_name", "message": "Name?"}},
+	}
+	req = call(app.UpdateChatbotFlow, flowID, 0, edit)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	flow = liveFlow(flowID)
+	assert.Equal(t, "Onboarding", flow.Name)
+	assert.Len(t, flow.Steps, 1)
+	require.NotNil(t, flow.DraftVersion)
+	assert.Equal(t, 2, *flow.DraftVersion)
+
+	// Saving again updates the same draft
+	edit["name"] = "Onboarding v2.1"
+	call(app.UpdateChatbotFlow, flowID, 0, edit)
+	assert.Equal(t, 2, *liveFlow(flowID).DraftVersion)
+
+	// Toggling the flow applies immediately without touching the draft
+	call(app.UpdateChatbotFlow, flowID, 0, map[string]any{"enabled": false})
+	flow = liveFlow(flowID)
+	assert.False(t, flow.IsEnabled)
+	assert.Equal(t, "Onboarding", flow.Name)
+
+	req = call(app.RollbackChatbotFlow, flowID, 2, nil)
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Publish the draft instead of rolling back to it")
+
+	req = call(app.PublishChatbotFlow, flowID, 0, nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	flow = liveFlow(flowID)
+	assert.Equal(t, "Onboarding v2.1", flow.Name)
+	assert.Len(t, flow.Steps, 2)
+	assert.Equal(t, 2, flow.PublishedVersion)
+	assert.Nil(t, flow.DraftVersion)
+
+	req = call(app.PublishChatbotFlow, flowID, 0, nil)
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Flow has no draft to publish")
+	req = call(app.RollbackChatbotFlow, flowID, 2, nil)
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "This version is already live")
+
+	req = call(app.RollbackChatbotFlow, flowID, 1, nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	flow = liveFlow(flowID)
+	assert.Equal(t, "Onboarding", flow.Name)
+	require.Len(t, flow.Steps, 1)
+	assert.Equal(t, "Hi", flow.Steps[0].Message)
+	assert.Equal(t, 1, flow.PublishedVersion)
+
+	req = call(app.ListChatbotFlowVersions, flowID, 0, nil)
+	var list struct {
+		Data struct {
+			Versions []handlers.ChatbotFlowVersionResponse `json:"versions"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &list)
+	require.Len(t, list.Data.Versions, 2)
+	assert.Equal(t, models.FlowVersionStatusSuperseded, list.Data.Versions[0].Status)
+	assert.Equal(t, models.FlowVersionStatusPublished, list.Data.Versions[1].Status)
+
+	// A discarded draft never reaches the live flow
+	call(app.UpdateChatbotFlow, flowID, 0, map[string]any{"name": "Scrapped"})
+	req = call(app.DiscardChatbotFlowDraft, flowID, 0, nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	flow = liveFlow(flowID)
+	assert.Equal(t, "Onboarding", flow.Name)
+	assert.Nil(t, flow.DraftVersion)
+}
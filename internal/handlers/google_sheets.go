@@ -0,0 +1,651 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/sheets"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// googleSheetsStateTTL is how long a Google Sheets OAuth state token stays valid
+	googleSheetsStateTTL = 10 * time.Minute
+	// googleSheetsCallbackPath is the OAuth redirect path registered in the Google Cloud console
+	googleSheetsCallbackPath = "/api/integrations/google-sheets/callback"
+	// sheetPreviewRows is how many data rows the preview returns for column mapping
+	sheetPreviewRows = 5
+	// sheetSyncTimeout bounds a single sheet import
+	sheetSyncTimeout = time.Minute
+)
+
+// errGoogleSheetsNotConnected is returned when the organization hasn't connected Google Sheets
+var errGoogleSheetsNotConnected = errors.New("google sheets is not connected")
+
+// GoogleSheetsState is stored in Redis during the Google Sheets OAuth flow
+type GoogleSheetsState struct {
+	OrgID  uuid.UUID `json:"org_id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// GoogleSheetsSettingsRequest sets the organization's Google OAuth client
+type GoogleSheetsSettingsRequest struct {
+	ClientID     string `json:"client_id" validate:"required"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// GoogleSheetsSettingsResponse describes the connection (secrets masked)
+type GoogleSheetsSettingsResponse struct {
+	ClientID     string     `json:"client_id"`
+	HasSecret    bool       `json:"has_secret"`
+	Connected    bool       `json:"connected"`
+	AccountEmail string     `json:"account_email,omitempty"`
+	ConnectedAt  *time.Time `json:"connected_at,omitempty"`
+	RedirectURL  string     `json:"redirect_url"`
+}
+
+// SheetSourceRequest links a campaign to a spreadsheet
+type SheetSourceRequest struct {
+	Spreadsheet      string            `json:"spreadsheet" validate:"required"` // URL or ID
+	Range            string            `json:"range"`
+	ColumnMapping    map[string]string `json:"column_mapping"`
+	SyncIntervalMins int               `json:"sync_interval_mins"`
+}
+
+// SheetSyncResult reports the outcome of a sheet import
+type SheetSyncResult struct {
+	AddedCount      int                 `json:"added_count"`
+	RejectedCount   int                 `json:"rejected_count"`
+	Rejected        []RejectedRecipient `json:"rejected"`
+	TotalRecipients int                 `json:"total_recipients"`
+	SyncedAt        time.Time           `json:"synced_at"`
+}
+
+// GetGoogleSheetsSettings returns the organization's Google Sheets connection
+func (a *App) GetGoogleSheetsSettings(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	response := GoogleSheetsSettingsResponse{RedirectURL: a.oauthCallbackURL(r, googleSheetsCallbackPath)}
+	var conn models.GoogleSheetsConnection
+	if err := a.DB.Where("organization_id = ?", orgID).First(&conn).Error; err == nil {
+		response.ClientID = conn.ClientID
+		response.HasSecret = conn.ClientSecret != ""
+		response.Connected = conn.RefreshToken != ""
+		response.AccountEmail = conn.AccountEmail
+		response.ConnectedAt = conn.ConnectedAt
+	}
+
+	return r.SendEnvelope(response)
+}
+
+// UpdateGoogleSheetsSettings saves the organization's Google OAuth client credentials
+func (a *App) UpdateGoogleSheetsSettings(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req GoogleSheetsSettingsRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.ClientID == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "client_id is required", nil, "")
+	}
+
+	var conn models.GoogleSheetsConnection
+	if err := a.DB.Where("organization_id = ?", orgID).First(&conn).Error; err != nil {
+		conn = models.GoogleSheetsConnection{OrganizationID: orgID}
+	}
+
+	// A different OAuth client can't use tokens issued to the old one
+	if conn.ClientID != "" && conn.ClientID != req.ClientID {
+		clearGoogleSheetsTokens(&conn)
+	}
+	conn.ClientID = req.ClientID
+	if req.ClientSecret != "" {
+		conn.ClientSecret = req.ClientSecret
+	}
+	if conn.ClientSecret == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "client_secret is required", nil, "")
+	}
+
+	if err := a.DB.Save(&conn).Error; err != nil {
+		a.Log.Error("Failed to save Google Sheets settings", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save Google Sheets settings", nil, "")
+	}
+
+	return r.SendEnvelope(GoogleSheetsSettingsResponse{
+		ClientID:     conn.ClientID,
+		HasSecret:    true,
+		Connected:    conn.RefreshToken != "",
+		AccountEmail: conn.AccountEmail,
+		ConnectedAt:  conn.ConnectedAt,
+		RedirectURL:  a.oauthCallbackURL(r, googleSheetsCallbackPath),
+	})
+}
+
+// ConnectGoogleSheets starts the OAuth flow and returns the Google consent URL
+func (a *App) ConnectGoogleSheets(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var conn models.GoogleSheetsConnection
+	if err := a.DB.Where("organization_id = ?", orgID).First(&conn).Error; err != nil || conn.ClientSecret == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Save a Google OAuth client ID and secret first", nil, "")
+	}
+
+	nonce := generateRandomString(32)
+	stateJSON, _ := json.Marshal(GoogleSheetsState{OrgID: orgID, UserID: userID})
+	if err := a.Redis.Set(r.RequestCtx, "google_sheets:state:"+nonce, stateJSON, googleSheetsStateTTL).Err(); err != nil {
+		a.Log.Error("Failed to store Google Sheets state", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start Google authorization", nil, "")
+	}
+
+	// Force the consent screen so Google always returns a refresh token
+	oauthConfig := a.googleSheetsOAuthConfig(&conn, a.oauthCallbackURL(r, googleSheetsCallbackPath))
+	authURL := oauthConfig.AuthCodeURL(nonce, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+
+	return r.SendEnvelope(map[string]string{"auth_url": authURL})
+}
+
+// GoogleSheetsCallback completes the OAuth flow and stores the tokens (public, state-checked)
+func (a *App) GoogleSheetsCallback(r *fastglue.Request) error {
+	code := string(r.RequestCtx.QueryArgs().Peek("code"))
+	nonce := string(r.RequestCtx.QueryArgs().Peek("state"))
+
+	if errorParam := string(r.RequestCtx.QueryArgs().Peek("error")); errorParam != "" {
+		a.redirectToGoogleSheetsSettings(r, "error", "Google authorization failed: "+errorParam)
+		return nil
+	}
+	if code == "" || nonce == "" {
+		a.redirectToGoogleSheetsSettings(r, "error", "Invalid callback parameters")
+		return nil
+	}
+
+	stateKey := "google_sheets:state:" + nonce
+	stateJSON, err := a.Redis.Get(r.RequestCtx, stateKey).Bytes()
+	if err != nil {
+		a.redirectToGoogleSheetsSettings(r, "error", "Invalid or expired state")
+		return nil
+	}
+	// Delete state immediately to prevent replay
+	a.Redis.Del(r.RequestCtx, stateKey)
+
+	var state GoogleSheetsState
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		a.redirectToGoogleSheetsSettings(r, "error", "Invalid state")
+		return nil
+	}
+
+	var conn models.GoogleSheetsConnection
+	if err := a.DB.Where("organization_id = ?", state.OrgID).First(&conn).Error; err != nil {
+		a.redirectToGoogleSheetsSettings(r, "error", "Google Sheets is not configured")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	oauthConfig := a.googleSheetsOAuthConfig(&conn, a.oauthCallbackURL(r, googleSheetsCallbackPath))
+	token, err := oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		a.Log.Error("Failed to exchange Google Sheets OAuth code", "error", err, "organization_id", state.OrgID)
+		a.redirectToGoogleSheetsSettings(r, "error", "Failed to authorize with Google")
+		return nil
+	}
+
+	email, err := fetchGoogleAccountEmail(ctx, oauthConfig.Client(ctx, token))
+	if err != nil {
+		a.Log.Warn("Failed to fetch Google account email", "error", err)
+	}
+
+	now := time.Now()
+	conn.AccountEmail = email
+	conn.ConnectedByID = &state.UserID
+	conn.ConnectedAt = &now
+	applyGoogleSheetsToken(&conn, token)
+	if err := a.DB.Save(&conn).Error; err != nil {
+		a.Log.Error("Failed to save Google Sheets tokens", "error", err)
+		a.redirectToGoogleSheetsSettings(r, "error", "Failed to save Google authorization")
+		return nil
+	}
+
+	a.Log.Info("Google Sheets connected", "organization_id", state.OrgID, "account", email)
+	a.redirectToGoogleSheetsSettings(r, "connected", "")
+	return nil
+}
+
+// DisconnectGoogleSheets removes the stored tokens, keeping the OAuth client settings
+func (a *App) DisconnectGoogleSheets(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var conn models.GoogleSheetsConnection
+	if err := a.DB.Where("organization_id = ?", orgID).First(&conn).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Google Sheets is not connected", nil, "")
+	}
+	clearGoogleSheetsTokens(&conn)
+	if err := a.DB.Save(&conn).Error; err != nil {
+		a.Log.Error("Failed to disconnect Google Sheets", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to disconnect Google Sheets", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Google Sheets disconnected"})
+}
+
+// PreviewSheet returns the header row and first rows of a spreadsheet for column mapping
+func (a *App) PreviewSheet(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req SheetSourceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	spreadsheetID, err := sheets.ParseSpreadsheetID(req.Spreadsheet)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sheetSyncTimeout)
+	defer cancel()
+
+	rows, err := a.fetchSheetRows(ctx, orgID, spreadsheetID, req.Range)
+	if err != nil {
+		if errors.Is(err, errGoogleSheetsNotConnected) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Connect Google Sheets in Settings first", nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, err.Error(), nil, "")
+	}
+
+	headers := []string{}
+	if len(rows) > 0 {
+		headers = rows[0]
+		rows = rows[1:]
+	}
+	total := len(rows)
+	if len(rows) > sheetPreviewRows {
+		rows = rows[:sheetPreviewRows]
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"spreadsheet_id": spreadsheetID,
+		"headers":        headers,
+		"rows":           rows,
+		"total_rows":     total,
+	})
+}
+
+// SetCampaignSheet links a draft campaign to a spreadsheet and imports its rows
+func (a *App) SetCampaignSheet(r *fastglue.Request) error {
+	campaign, err := a.loadDraftCampaign(r)
+	if err != nil || campaign == nil {
+		return err
+	}
+
+	var req SheetSourceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	spreadsheetID, err := sheets.ParseSpreadsheetID(req.Spreadsheet)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	if req.SyncIntervalMins < 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "sync_interval_mins cannot be negative", nil, "")
+	}
+
+	mapping := make(models.JSONB, len(req.ColumnMapping))
+	for column, target := range req.ColumnMapping {
+		if target != "" {
+			mapping[column] = target
+		}
+	}
+
+	campaign.SheetSpreadsheetID = spreadsheetID
+	campaign.SheetRange = req.Range
+	campaign.SheetColumnMapping = mapping
+	campaign.SheetSyncIntervalMins = req.SyncIntervalMins
+
+	result, err := a.syncCampaignSheet(campaign)
+	if err != nil {
+		if errors.Is(err, errGoogleSheetsNotConnected) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Connect Google Sheets in Settings first", nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(result)
+}
+
+// SyncCampaignSheet re-imports new rows from a campaign's linked spreadsheet
+func (a *App) SyncCampaignSheet(r *fastglue.Request) error {
+	campaign, err := a.loadDraftCampaign(r)
+	if err != nil || campaign == nil {
+		return err
+	}
+	if campaign.SheetSpreadsheetID == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign is not linked to a spreadsheet", nil, "")
+	}
+
+	result, err := a.syncCampaignSheet(campaign)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(result)
+}
+
+// UnlinkCampaignSheet stops syncing a campaign from its spreadsheet; imported recipients stay
+func (a *App) UnlinkCampaignSheet(r *fastglue.Request) error {
+	campaign, err := a.loadDraftCampaign(r)
+	if err != nil || campaign == nil {
+		return err
+	}
+
+	if err := a.DB.Model(campaign).Updates(map[string]interface{}{
+		"sheet_spreadsheet_id":     "",
+		"sheet_range":              "",
+		"sheet_column_mapping":     models.JSONB{},
+		"sheet_sync_interval_mins": 0,
+		"sheet_sync_error":         "",
+	}).Error; err != nil {
+		a.Log.Error("Failed to unlink campaign sheet", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to unlink spreadsheet", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Spreadsheet unlinked"})
+}
+
+// loadDraftCampaign checks permissions and loads the draft campaign from the path. On
+// failure it sends the error response and returns a nil campaign.
+func (a *App) loadDraftCampaign(r *fastglue.Request) (*models.BulkMessageCampaign, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionWrite) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&campaign).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+	if campaign.Status != models.CampaignStatusDraft {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only add recipients to draft campaigns", nil, "")
+	}
+	return &campaign, nil
+}
+
+// syncCampaignSheet imports rows not yet on the campaign and records the sync outcome
+func (a *App) syncCampaignSheet(campaign *models.BulkMessageCampaign) (*SheetSyncResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sheetSyncTimeout)
+	defer cancel()
+
+	now := time.Now()
+	result, err := a.importSheetRecipients(ctx, campaign)
+
+	campaign.SheetLastSyncedAt = &now
+	campaign.SheetSyncError = ""
+	if err != nil {
+		campaign.SheetSyncError = err.Error()
+	}
+	if saveErr := a.DB.Model(campaign).Select(
+		"sheet_spreadsheet_id", "sheet_range", "sheet_column_mapping",
+		"sheet_sync_interval_mins", "sheet_last_synced_at", "sheet_sync_error",
+	).Updates(campaign).Error; saveErr != nil {
+		a.Log.Error("Failed to save campaign sheet sync", "error", saveErr, "campaign_id", campaign.ID)
+	}
+
+	if err != nil {
+		a.Log.Warn("Campaign sheet sync failed", "error", err, "campaign_id", campaign.ID)
+		return nil, err
+	}
+	result.SyncedAt = now
+	a.Log.Info("Campaign sheet synced", "campaign_id", campaign.ID, "added", result.AddedCount, "rejected", result.RejectedCount)
+	return result, nil
+}
+
+// importSheetRecipients reads the campaign's spreadsheet and adds new recipients
+func (a *App) importSheetRecipients(ctx context.Context, campaign *models.BulkMessageCampaign) (*SheetSyncResult, error) {
+	rows, err := a.fetchSheetRows(ctx, campaign.OrganizationID, campaign.SheetSpreadsheetID, campaign.SheetRange)
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := sheets.MapRows(rows, sheets.MappingFromJSON(campaign.SheetColumnMapping))
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]RecipientRequest, len(mapped))
+	for i, m := range mapped {
+		reqs[i] = RecipientRequest{
+			PhoneNumber:    m.PhoneNumber,
+			RecipientName:  m.RecipientName,
+			TemplateParams: m.TemplateParams,
+		}
+	}
+
+	added, rejected, err := a.addCampaignRecipients(campaign, reqs, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add recipients: %w", err)
+	}
+	return &SheetSyncResult{
+		AddedCount:      added,
+		RejectedCount:   len(rejected),
+		Rejected:        rejected,
+		TotalRecipients: campaign.TotalRecipients,
+	}, nil
+}
+
+// fetchSheetRows reads a spreadsheet range with the organization's Google credentials,
+// persisting refreshed tokens
+func (a *App) fetchSheetRows(ctx context.Context, orgID uuid.UUID, spreadsheetID, cellRange string) ([][]string, error) {
+	var conn models.GoogleSheetsConnection
+	if err := a.DB.Where("organization_id = ?", orgID).First(&conn).Error; err != nil || conn.RefreshToken == "" {
+		return nil, errGoogleSheetsNotConnected
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  conn.AccessToken,
+		RefreshToken: conn.RefreshToken,
+		TokenType:    "Bearer",
+	}
+	if conn.TokenExpiry != nil {
+		token.Expiry = *conn.TokenExpiry
+	}
+
+	tokenSource := a.googleSheetsOAuthConfig(&conn, "").TokenSource(ctx, token)
+	current, err := tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("google authorization expired, reconnect Google Sheets: %w", err)
+	}
+	if current.AccessToken != conn.AccessToken {
+		applyGoogleSheetsToken(&conn, current)
+		if err := a.DB.Model(&conn).Select("access_token", "refresh_token", "token_expiry").Updates(&conn).Error; err != nil {
+			a.Log.Error("Failed to save refreshed Google token", "error", err)
+		}
+	}
+
+	return sheets.FetchValues(ctx, oauth2.NewClient(ctx, oauth2.StaticTokenSource(current)), spreadsheetID, cellRange)
+}
+
+// googleSheetsOAuthConfig builds the OAuth config for an organization's Google client
+func (a *App) googleSheetsOAuthConfig(conn *models.GoogleSheetsConnection, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     conn.ClientID,
+		ClientSecret: conn.ClientSecret,
+		Endpoint:     google.Endpoint,
+		Scopes:       sheets.Scopes,
+		RedirectURL:  redirectURL,
+	}
+}
+
+// redirectToGoogleSheetsSettings sends the browser back to the integration settings page
+func (a *App) redirectToGoogleSheetsSettings(r *fastglue.Request, status, message string) {
+	basePath := a.Config.Server.BasePath
+	if basePath != "" && basePath[0] != '/' {
+		basePath = "/" + basePath
+	}
+	redirectURL := fmt.Sprintf("%s/settings?tab=integrations&google_sheets=%s", basePath, status)
+	if message != "" {
+		redirectURL += "&message=" + url.QueryEscape(message)
+	}
+	r.RequestCtx.Redirect(redirectURL, fasthttp.StatusTemporaryRedirect)
+}
+
+// applyGoogleSheetsToken copies token fields onto the connection. Google only returns a
+// refresh token on consent, so an empty one keeps the stored value.
+func applyGoogleSheetsToken(conn *models.GoogleSheetsConnection, token *oauth2.Token) {
+	conn.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		conn.RefreshToken = token.RefreshToken
+	}
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry
+		conn.TokenExpiry = &expiry
+	}
+}
+
+// clearGoogleSheetsTokens forgets the connected Google account
+func clearGoogleSheetsTokens(conn *models.GoogleSheetsConnection) {
+	conn.AccessToken = ""
+	conn.RefreshToken = ""
+	conn.TokenExpiry = nil
+	conn.AccountEmail = ""
+	conn.ConnectedByID = nil
+	conn.ConnectedAt = nil
+}
+
+// fetchGoogleAccountEmail returns the email of the Google account behind client
+func fetchGoogleAccountEmail(ctx context.Context, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, oauthProviders["google"].UserInfoURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("user info request failed: %s", string(body))
+	}
+	var info struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", err
+	}
+	return info.Email, nil
+}
+
+// SheetSyncProcessor periodically re-imports draft campaigns linked to a spreadsheet
+type SheetSyncProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewSheetSyncProcessor creates a new sheet sync processor
+func NewSheetSyncProcessor(app *App, interval time.Duration) *SheetSyncProcessor {
+	return &SheetSyncProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the sync loop
+func (p *SheetSyncProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Sheet sync processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Sheet sync processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Sheet sync processor stopped")
+			return
+		case <-ticker.C:
+			p.syncDueCampaigns(ctx)
+		}
+	}
+}
+
+// Stop stops the sheet sync processor
+func (p *SheetSyncProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// syncDueCampaigns syncs every draft campaign whose sync interval has elapsed
+func (p *SheetSyncProcessor) syncDueCampaigns(ctx context.Context) {
+	var campaigns []models.BulkMessageCampaign
+	if err := p.app.DB.Where("status = ? AND sheet_spreadsheet_id <> '' AND sheet_sync_interval_mins > 0", models.CampaignStatusDraft).
+		Where("sheet_last_synced_at IS NULL OR sheet_last_synced_at + make_interval(mins => sheet_sync_interval_mins) <= ?", time.Now()).
+		Find(&campaigns).Error; err != nil {
+		p.app.Log.Error("Failed to load campaigns for sheet sync", "error", err)
+		return
+	}
+
+	for i := range campaigns {
+		if ctx.Err() != nil {
+			return
+		}
+		_, _ = p.app.syncCampaignSheet(&campaigns[i])
+	}
+}
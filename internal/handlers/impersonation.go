@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// impersonationTTL is how long an impersonation token is valid. It can't be refreshed.
+	impersonationTTL = 15 * time.Minute
+	// impersonationConsentTTL is how long a request waits for consent, and how long
+	// consent is valid before the session is started
+	impersonationConsentTTL = time.Hour
+	// impersonationConsentSettingKey is the organization setting that requires users to
+	// approve impersonation
+	impersonationConsentSettingKey = "require_impersonation_consent"
+)
+
+// ImpersonationRequest represents a request to impersonate a user
+type ImpersonationRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	Reason string    `json:"reason"`
+}
+
+// ImpersonationResponse represents an impersonation session in API responses
+type ImpersonationResponse struct {
+	ID                uuid.UUID                  `json:"id"`
+	OrganizationID    uuid.UUID                  `json:"organization_id"`
+	UserID            uuid.UUID                  `json:"user_id"`
+	UserName          string                     `json:"user_name,omitempty"`
+	UserEmail         string                     `json:"user_email,omitempty"`
+	ImpersonatorID    uuid.UUID                  `json:"impersonator_id"`
+	ImpersonatorName  string                     `json:"impersonator_name,omitempty"`
+	ImpersonatorEmail string                     `json:"impersonator_email,omitempty"`
+	Reason            string                     `json:"reason"`
+	Status            models.ImpersonationStatus `json:"status"`
+	RespondedAt       *time.Time                 `json:"responded_at,omitempty"`
+	StartedAt         *time.Time                 `json:"started_at,omitempty"`
+	ExpiresAt         *time.Time                 `json:"expires_at,omitempty"`
+	EndedAt           *time.Time                 `json:"ended_at,omitempty"`
+	CreatedAt         time.Time                  `json:"created_at"`
+}
+
+// ImpersonationTokenResponse is returned when an impersonation session starts
+type ImpersonationTokenResponse struct {
+	AccessToken string                `json:"access_token"`
+	ExpiresIn   int                   `json:"expires_in"`
+	Session     ImpersonationResponse `json:"session"`
+}
+
+// CreateImpersonation lets a super admin impersonate a user. The session starts right away
+// unless the user's organization requires consent, in which case the user is asked first.
+func (a *App) CreateImpersonation(r *fastglue.Request) error {
+	adminID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if !a.IsSuperAdmin(adminID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only super admins can impersonate users", nil, "")
+	}
+
+	var req ImpersonationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "A reason is required", nil, "")
+	}
+	if req.UserID == adminID {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "You can't impersonate yourself", nil, "")
+	}
+
+	var user models.User
+	if err := a.DB.Where("id = ?", req.UserID).First(&user).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "User not found", nil, "")
+	}
+	if !user.IsActive {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "User is disabled", nil, "")
+	}
+	if user.IsSuperAdmin {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Super admins can't be impersonated", nil, "")
+	}
+
+	session := models.ImpersonationSession{
+		OrganizationID: user.OrganizationID,
+		ImpersonatorID: adminID,
+		UserID:         user.ID,
+		Reason:         req.Reason,
+		Status:         models.ImpersonationStatusPending,
+	}
+	consentRequired := a.impersonationConsentRequired(user.OrganizationID)
+	if !consentRequired {
+		session.Status = models.ImpersonationStatusApproved
+	}
+	if err := a.DB.Create(&session).Error; err != nil {
+		a.Log.Error("Failed to create impersonation session", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create impersonation session", nil, "")
+	}
+	session.User = &user
+
+	if consentRequired {
+		a.auditImpersonation(&session, models.AuditActionImpersonationRequested, r, "Consent requested: "+session.Reason)
+		if a.WSHub != nil {
+			a.WSHub.BroadcastToUser(user.OrganizationID, user.ID, websocket.WSMessage{
+				Type:    websocket.TypeImpersonationRequest,
+				Payload: a.impersonationToResponse(&session),
+			})
+		}
+		return r.SendEnvelope(a.impersonationToResponse(&session))
+	}
+
+	return a.startImpersonation(r, &session, &user)
+}
+
+// StartImpersonation starts a session the user has consented to
+func (a *App) StartImpersonation(r *fastglue.Request) error {
+	adminID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if !a.IsSuperAdmin(adminID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only super admins can impersonate users", nil, "")
+	}
+	sessionID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid session ID", nil, "")
+	}
+
+	var session models.ImpersonationSession
+	if err := a.DB.Preload("User").
+		Where("id = ? AND impersonator_id = ?", sessionID, adminID).
+		First(&session).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Impersonation session not found", nil, "")
+	}
+	if session.Status != models.ImpersonationStatusApproved {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, fmt.Sprintf("Session is %s", session.Status), nil, "")
+	}
+	if session.RespondedAt != nil && time.Since(*session.RespondedAt) > impersonationConsentTTL {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Consent has expired, request it again", nil, "")
+	}
+	if session.User == nil || !session.User.IsActive {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "User is disabled", nil, "")
+	}
+
+	return a.startImpersonation(r, &session, session.User)
+}
+
+// startImpersonation activates an approved session and returns its token
+func (a *App) startImpersonation(r *fastglue.Request, session *models.ImpersonationSession, user *models.User) error {
+	now := time.Now()
+	expiresAt := now.Add(impersonationTTL)
+	result := a.DB.Model(session).
+		Where("status = ?", models.ImpersonationStatusApproved).
+		Updates(map[string]interface{}{
+			"status":     models.ImpersonationStatusActive,
+			"started_at": now,
+			"expires_at": expiresAt,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to start impersonation session", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start impersonation", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Session was already started", nil, "")
+	}
+	session.Status = models.ImpersonationStatusActive
+	session.StartedAt = &now
+	session.ExpiresAt = &expiresAt
+
+	token, err := a.generateImpersonationToken(session, user)
+	if err != nil {
+		a.Log.Error("Failed to sign impersonation token", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start impersonation", nil, "")
+	}
+
+	a.auditImpersonation(session, models.AuditActionImpersonationStarted, r, session.Reason)
+	a.Log.Info("Impersonation started", "session_id", session.ID, "impersonator_id", session.ImpersonatorID, "user_id", session.UserID)
+
+	return r.SendEnvelope(ImpersonationTokenResponse{
+		AccessToken: token,
+		ExpiresIn:   int(impersonationTTL.Seconds()),
+		Session:     a.impersonationToResponse(session),
+	})
+}
+
+// generateImpersonationToken issues an access token for the user that is marked with the
+// impersonator and expires with the session. It never carries super admin rights.
+func (a *App) generateImpersonationToken(session *models.ImpersonationSession, user *models.User) (string, error) {
+	claims := middleware.JWTClaims{
+		UserID:          user.ID,
+		OrganizationID:  user.OrganizationID,
+		Email:           user.Email,
+		RoleID:          user.RoleID,
+		ImpersonatorID:  &session.ImpersonatorID,
+		ImpersonationID: &session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(*session.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(*session.StartedAt),
+			Issuer:    "whatomate",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(a.Config.JWT.Secret))
+}
+
+// ListImpersonations returns the super admin's recent impersonation sessions
+func (a *App) ListImpersonations(r *fastglue.Request) error {
+	adminID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if !a.IsSuperAdmin(adminID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only super admins can impersonate users", nil, "")
+	}
+
+	var sessions []models.ImpersonationSession
+	if err := a.DB.Preload("User").
+		Where("impersonator_id = ?", adminID).
+		Order("created_at DESC").Limit(50).
+		Find(&sessions).Error; err != nil {
+		a.Log.Error("Failed to list impersonation sessions", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list impersonation sessions", nil, "")
+	}
+
+	response := make([]ImpersonationResponse, len(sessions))
+	for i := range sessions {
+		response[i] = a.impersonationToResponse(&sessions[i])
+	}
+	return r.SendEnvelope(map[string]any{
+		"sessions": response,
+	})
+}
+
+// EndImpersonation ends the impersonation session the request was made with
+func (a *App) EndImpersonation(r *fastglue.Request) error {
+	sessionID, ok := r.RequestCtx.UserValue(middleware.ContextKeyImpersonation).(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Not impersonating", nil, "")
+	}
+
+	var session models.ImpersonationSession
+	if err := a.DB.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Impersonation session not found", nil, "")
+	}
+
+	now := time.Now()
+	if err := a.DB.Model(&session).
+		Where("status = ?", models.ImpersonationStatusActive).
+		Updates(map[string]interface{}{
+			"status":   models.ImpersonationStatusEnded,
+			"ended_at": now,
+		}).Error; err != nil {
+		a.Log.Error("Failed to end impersonation session", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to end impersonation", nil, "")
+	}
+
+	a.auditImpersonation(&session, models.AuditActionImpersonationEnded, r, "")
+	return r.SendEnvelope(map[string]string{"message": "Impersonation ended"})
+}
+
+// ListImpersonationRequests returns impersonation requests waiting for the current user's consent
+func (a *App) ListImpersonationRequests(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var sessions []models.ImpersonationSession
+	if err := a.DB.Preload("Impersonator").
+		Where("user_id = ? AND status = ? AND created_at > ?", userID, models.ImpersonationStatusPending, time.Now().Add(-impersonationConsentTTL)).
+		Order("created_at DESC").
+		Find(&sessions).Error; err != nil {
+		a.Log.Error("Failed to list impersonation requests", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list impersonation requests", nil, "")
+	}
+
+	response := make([]ImpersonationResponse, len(sessions))
+	for i := range sessions {
+		response[i] = a.impersonationToResponse(&sessions[i])
+	}
+	return r.SendEnvelope(map[string]any{
+		"requests": response,
+	})
+}
+
+// ApproveImpersonationRequest gives consent to an impersonation request
+func (a *App) ApproveImpersonationRequest(r *fastglue.Request) error {
+	return a.respondImpersonationRequest(r, true)
+}
+
+// DenyImpersonationRequest refuses an impersonation request
+func (a *App) DenyImpersonationRequest(r *fastglue.Request) error {
+	return a.respondImpersonationRequest(r, false)
+}
+
+func (a *App) respondImpersonationRequest(r *fastglue.Request, approve bool) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	sessionID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request ID", nil, "")
+	}
+
+	var session models.ImpersonationSession
+	if err := a.DB.Preload("Impersonator").
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		First(&session).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Impersonation request not found", nil, "")
+	}
+	if session.Status != models.ImpersonationStatusPending || time.Since(session.CreatedAt) > impersonationConsentTTL {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Request is no longer pending", nil, "")
+	}
+
+	status, action := models.ImpersonationStatusDenied, models.AuditActionImpersonationDenied
+	if approve {
+		status, action = models.ImpersonationStatusApproved, models.AuditActionImpersonationApproved
+	}
+	now := time.Now()
+	if err := a.DB.Model(&session).Updates(map[string]interface{}{
+		"status":       status,
+		"responded_at": now,
+	}).Error; err != nil {
+		a.Log.Error("Failed to update impersonation request", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update impersonation request", nil, "")
+	}
+	session.Status = status
+	session.RespondedAt = &now
+
+	a.auditImpersonation(&session, action, r, "")
+	if a.WSHub != nil && session.Impersonator != nil {
+		a.WSHub.BroadcastToUser(session.Impersonator.OrganizationID, session.ImpersonatorID, websocket.WSMessage{
+			Type:    websocket.TypeImpersonationResponse,
+			Payload: a.impersonationToResponse(&session),
+		})
+	}
+
+	return r.SendEnvelope(a.impersonationToResponse(&session))
+}
+
+// CheckImpersonation reports whether an impersonation token's session is still active,
+// recording the request in the audit log with both identities
+func (a *App) CheckImpersonation(imp middleware.Impersonation) bool {
+	var session models.ImpersonationSession
+	if err := a.DB.Where("id = ? AND impersonator_id = ? AND user_id = ?", imp.SessionID, imp.ImpersonatorID, imp.UserID).
+		First(&session).Error; err != nil {
+		return false
+	}
+	if session.Status != models.ImpersonationStatusActive || session.ExpiresAt == nil || time.Now().After(*session.ExpiresAt) {
+		return false
+	}
+
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: session.OrganizationID,
+		UserID:         &session.UserID,
+		ImpersonatorID: &session.ImpersonatorID,
+		Action:         models.AuditActionImpersonatedRequest,
+		IPAddress:      imp.IP,
+		Path:           imp.Path,
+		Details:        imp.Method + " " + imp.Path,
+	})
+	return true
+}
+
+// isImpersonating reports whether a request was made with an impersonation token
+func isImpersonating(r *fastglue.Request) bool {
+	_, ok := r.RequestCtx.UserValue(middleware.ContextKeyImpersonation).(uuid.UUID)
+	return ok
+}
+
+// currentImpersonation returns the session a request was made with, or nil
+func (a *App) currentImpersonation(r *fastglue.Request) *ImpersonationResponse {
+	sessionID, ok := r.RequestCtx.UserValue(middleware.ContextKeyImpersonation).(uuid.UUID)
+	if !ok {
+		return nil
+	}
+	var session models.ImpersonationSession
+	if err := a.DB.Preload("Impersonator").Where("id = ?", sessionID).First(&session).Error; err != nil {
+		return nil
+	}
+	resp := a.impersonationToResponse(&session)
+	return &resp
+}
+
+// auditImpersonation records a change to an impersonation session
+func (a *App) auditImpersonation(session *models.ImpersonationSession, action models.AuditAction, r *fastglue.Request, details string) {
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: session.OrganizationID,
+		UserID:         &session.UserID,
+		ImpersonatorID: &session.ImpersonatorID,
+		Action:         action,
+		IPAddress:      middleware.ClientIP(r),
+		Path:           string(r.RequestCtx.Path()),
+		Details:        details,
+	})
+}
+
+// impersonationConsentRequired reports whether the organization's users must approve
+// being impersonated
+func (a *App) impersonationConsentRequired(orgID uuid.UUID) bool {
+	var org models.Organization
+	if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return true // Fail closed
+	}
+	v, _ := org.Settings[impersonationConsentSettingKey].(bool)
+	return v
+}
+
+func (a *App) impersonationToResponse(s *models.ImpersonationSession) ImpersonationResponse {
+	resp := ImpersonationResponse{
+		ID:             s.ID,
+		OrganizationID: s.OrganizationID,
+		UserID:         s.UserID,
+		ImpersonatorID: s.ImpersonatorID,
+		Reason:         s.Reason,
+		Status:         s.Status,
+		RespondedAt:    s.RespondedAt,
+		StartedAt:      s.StartedAt,
+		ExpiresAt:      s.ExpiresAt,
+		EndedAt:        s.EndedAt,
+		CreatedAt:      s.CreatedAt,
+	}
+	if s.User != nil {
+		resp.UserName = s.User.FullName
+		resp.UserEmail = s.User.Email
+	}
+	if s.Impersonator != nil {
+		resp.ImpersonatorName = s.Impersonator.FullName
+		resp.ImpersonatorEmail = s.Impersonator.Email
+	}
+	return resp
+}
@@ -0,0 +1,198 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func createImpersonation(t *testing.T, app *handlers.App, admin *models.User, userID uuid.UUID) *fastglue.Request {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, map[string]any{"user_id": userID, "reason": "Ticket #42: inbox not loading"})
+	setAuthContext(req, admin.OrganizationID, admin.ID)
+	require.NoError(t, app.CreateImpersonation(req))
+	return req
+}
+
+// impersonatedRequest returns a request authenticated with an impersonation token
+func impersonatedRequest(t *testing.T, token string) *fastglue.Request {
+	t.Helper()
+
+	claims := &middleware.JWTClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecret), nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, claims.ImpersonatorID)
+	require.NotNil(t, claims.ImpersonationID)
+	assert.False(t, claims.IsSuperAdmin)
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, claims.OrganizationID, claims.UserID)
+	req.RequestCtx.SetUserValue(middleware.ContextKeyImpersonatorID, *claims.ImpersonatorID)
+	req.RequestCtx.SetUserValue(middleware.ContextKeyImpersonation, *claims.ImpersonationID)
+	return req
+}
+
+func TestApp_Impersonation(t *testing.T) {
+	app := testApp(t)
+	adminOrg := createTestOrganization(t, app)
+	org := createTestOrganization(t, app)
+	admin := createSuperAdmin(t, app, adminOrg.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("impersonated"), "password", nil, true)
+
+	// Only super admins can impersonate
+	req := createImpersonation(t, app, user, admin.ID)
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	req = createImpersonation(t, app, admin, user.ID)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(req.RequestCtx.Response.Body()))
+	var started struct {
+		Data handlers.ImpersonationTokenResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &started)
+	assert.Equal(t, models.ImpersonationStatusActive, started.Data.Session.Status)
+	require.NotEmpty(t, started.Data.AccessToken)
+
+	impersonated := impersonatedRequest(t, started.Data.AccessToken)
+	imp := middleware.Impersonation{
+		SessionID:      started.Data.Session.ID,
+		ImpersonatorID: admin.ID,
+		UserID:         user.ID,
+		Method:         "GET",
+		Path:           "/api/contacts",
+	}
+	assert.True(t, app.CheckImpersonation(imp))
+
+	// Requests are audited with both identities
+	var logged models.AuditLog
+	require.NoError(t, app.DB.Where("organization_id = ? AND action = ?", org.ID, models.AuditActionImpersonatedRequest).First(&logged).Error)
+	assert.Equal(t, user.ID, *logged.UserID)
+	assert.Equal(t, admin.ID, *logged.ImpersonatorID)
+	assert.Equal(t, "GET /api/contacts", logged.Details)
+
+	// /api/me tells the frontend to show the banner
+	require.NoError(t, app.GetCurrentUser(impersonated))
+	var me struct {
+		Data handlers.UserResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, impersonated, &me)
+	require.NotNil(t, me.Data.Impersonation)
+	assert.Equal(t, admin.ID, me.Data.Impersonation.ImpersonatorID)
+	assert.Equal(t, admin.Email, me.Data.Impersonation.ImpersonatorEmail)
+
+	// The token can't be refreshed into a regular session
+	refresh := testutil.NewJSONRequest(t, map[string]any{"refresh_token": started.Data.AccessToken})
+	require.NoError(t, app.RefreshToken(refresh))
+	assert.Equal(t, fasthttp.StatusUnauthorized, testutil.GetResponseStatusCode(refresh))
+
+	end := impersonatedRequest(t, started.Data.AccessToken)
+	require.NoError(t, app.EndImpersonation(end))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(end))
+	assert.False(t, app.CheckImpersonation(imp))
+}
+
+func TestApp_Impersonation_RequiresConsent(t *testing.T) {
+	app := testApp(t)
+	adminOrg := createTestOrganization(t, app)
+	org := createTestOrganization(t, app)
+	require.NoError(t, app.DB.Model(org).Update("settings", models.JSONB{"require_impersonation_consent": true}).Error)
+	admin := createSuperAdmin(t, app, adminOrg.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("consent"), "password", nil, true)
+
+	req := createImpersonation(t, app, admin, user.ID)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var pending struct {
+		Data handlers.ImpersonationResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &pending)
+	assert.Equal(t, models.ImpersonationStatusPending, pending.Data.Status)
+	assert.NotContains(t, string(req.RequestCtx.Response.Body()), "access_token")
+
+	start := func() *fastglue.Request {
+		req := testutil.NewJSONRequest(t, nil)
+		setAuthContext(req, admin.OrganizationID, admin.ID)
+		testutil.SetPathParam(req, "id", pending.Data.ID.String())
+		require.NoError(t, app.StartImpersonation(req))
+		return req
+	}
+	assert.Equal(t, fasthttp.StatusConflict, testutil.GetResponseStatusCode(start()))
+
+	// The user sees the request and approves it
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.ListImpersonationRequests(req))
+	var requests struct {
+		Data struct {
+			Requests []handlers.ImpersonationResponse `json:"requests"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &requests)
+	require.Len(t, requests.Data.Requests, 1)
+	assert.Equal(t, admin.Email, requests.Data.Requests[0].ImpersonatorEmail)
+
+	req = testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", pending.Data.ID.String())
+	require.NoError(t, app.ApproveImpersonationRequest(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	req = start()
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Contains(t, string(req.RequestCtx.Response.Body()), "access_token")
+
+	// A session only starts once
+	assert.Equal(t, fasthttp.StatusConflict, testutil.GetResponseStatusCode(start()))
+
+	var actions []models.AuditAction
+	app.DB.Model(&models.AuditLog{}).Where("organization_id = ?", org.ID).Order("created_at").Pluck("action", &actions)
+	assert.Equal(t, []models.AuditAction{
+		models.AuditActionImpersonationRequested,
+		models.AuditActionImpersonationApproved,
+		models.AuditActionImpersonationStarted,
+	}, actions)
+}
+
+func TestApp_Impersonation_CannotChangeRolesOrPasswords(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	adminRole := createTransferAdminRole(t, app.DB, org.ID)
+	agentRole := createTransferAgentRole(t, app.DB, org.ID)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("impersonated-admin"), "password", &adminRole.ID, true)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("impersonation-target"), "password", &agentRole.ID, true)
+
+	updateUser := func(body map[string]any, impersonating bool) int {
+		req := testutil.NewJSONRequest(t, body)
+		setAuthContext(req, org.ID, admin.ID)
+		if impersonating {
+			req.RequestCtx.SetUserValue(middleware.ContextKeyImpersonatorID, uuid.New())
+			req.RequestCtx.SetUserValue(middleware.ContextKeyImpersonation, uuid.New())
+		}
+		testutil.SetPathParam(req, "id", agent.ID.String())
+		require.NoError(t, app.UpdateUser(req))
+		return testutil.GetResponseStatusCode(req)
+	}
+
+	// An impersonated admin can't hand out a role or set a password
+	assert.Equal(t, fasthttp.StatusForbidden, updateUser(map[string]any{"role_id": adminRole.ID}, true))
+	assert.Equal(t, fasthttp.StatusForbidden, updateUser(map[string]any{"password": "new-password"}, true))
+	var reloaded models.User
+	require.NoError(t, app.DB.First(&reloaded, "id = ?", agent.ID).Error)
+	assert.Equal(t, agentRole.ID, *reloaded.RoleID)
+
+	// Other edits, and resending the current role, still work
+	assert.Equal(t, fasthttp.StatusOK, updateUser(map[string]any{"full_name": "Renamed Agent", "role_id": agentRole.ID}, true))
+
+	// The same admin can change the role when not impersonated
+	assert.Equal(t, fasthttp.StatusOK, updateUser(map[string]any{"role_id": adminRole.ID}, false))
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/ipaccess"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// AuditLogResponse represents an audit log entry
+type AuditLogResponse struct {
+	ID               uuid.UUID          `json:"id"`
+	UserID           *uuid.UUID         `json:"user_id,omitempty"`
+	UserName         string             `json:"user_name,omitempty"`
+	ImpersonatorID   *uuid.UUID         `json:"impersonator_id,omitempty"`
+	ImpersonatorName string             `json:"impersonator_name,omitempty"`
+	APIKeyID         *uuid.UUID         `json:"api_key_id,omitempty"`
+	Action           models.AuditAction `json:"action"`
+	IPAddress        string             `json:"ip_address"`
+	Path             string             `json:"path"`
+	Details          string             `json:"details"`
+	CreatedAt        time.Time          `json:"created_at"`
+}
+
+// CheckIPAccess enforces the organization's IP allowlist for an authenticated request.
+// API keys are checked against the API key ranges; users who can manage organization
+// settings are checked against the admin ranges. Blocked attempts are audited.
+func (a *App) CheckIPAccess(access middleware.IPAccess) bool {
+	policy := a.getOrgIPAccessPolicy(access.OrganizationID)
+	if policy.IsEmpty() {
+		return true
+	}
+
+	if access.APIKeyID != nil {
+		if policy.AllowsAPIKey(access.IP) {
+			return true
+		}
+		a.recordAuditLog(models.AuditLog{
+			OrganizationID: access.OrganizationID,
+			UserID:         &access.UserID,
+			APIKeyID:       access.APIKeyID,
+			Action:         models.AuditActionAPIKeyIPBlocked,
+			IPAddress:      access.IP,
+			Path:           access.Path,
+			Details:        "API key used from an IP outside the allowlist",
+		})
+		return false
+	}
+
+	if len(policy.AdminCIDRs) == 0 || policy.AllowsAdmin(access.IP) || !a.isOrgAdmin(access.UserID, access.OrganizationID) {
+		return true
+	}
+	a.recordAuditLog(models.AuditLog{
+		OrganizationID: access.OrganizationID,
+		UserID:         &access.UserID,
+		Action:         models.AuditActionAdminIPBlocked,
+		IPAddress:      access.IP,
+		Path:           access.Path,
+		Details:        "Admin session used from an IP outside the allowlist",
+	})
+	return false
+}
+
+// checkAdminLoginIP reports whether user may log in from ip, auditing blocked attempts
+func (a *App) checkAdminLoginIP(user *models.User, ip string) bool {
+	policy := a.getOrgIPAccessPolicy(user.OrganizationID)
+	if len(policy.AdminCIDRs) == 0 || policy.AllowsAdmin(ip) || !a.isOrgAdmin(user.ID, user.OrganizationID) {
+		return true
+	}
+	a.recordAuditLog(models.AuditLog{
+		OrganizationID: user.OrganizationID,
+		UserID:         &user.ID,
+		Action:         models.AuditActionAdminLoginBlocked,
+		IPAddress:      ip,
+		Path:           "/api/auth/login",
+		Details:        "Admin login from an IP outside the allowlist",
+	})
+	return false
+}
+
+// isOrgAdmin reports whether the user can manage organization settings, which is
+// what the admin IP allowlist applies to
+func (a *App) isOrgAdmin(userID, orgID uuid.UUID) bool {
+	return a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite)
+}
+
+// recordAuditLog logs and stores a blocked access attempt
+func (a *App) recordAuditLog(entry models.AuditLog) {
+	a.Log.Warn("Access blocked", "action", entry.Action, "organization_id", entry.OrganizationID, "ip", entry.IPAddress, "path", entry.Path)
+	a.saveAuditLog(entry)
+}
+
+// saveAuditLog stores an audit entry. Failures are logged but never block the caller.
+func (a *App) saveAuditLog(entry models.AuditLog) {
+	if err := a.DB.Create(&entry).Error; err != nil {
+		a.Log.Error("Failed to record audit log", "error", err, "action", entry.Action)
+	}
+}
+
+// ListAuditLogs returns the organization's audit log, most recent first
+func (a *App) ListAuditLogs(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	page, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if action := string(r.RequestCtx.QueryArgs().Peek("action")); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if impersonatorID, err := uuid.Parse(string(r.RequestCtx.QueryArgs().Peek("impersonator_id"))); err == nil {
+		query = query.Where("impersonator_id = ?", impersonatorID)
+	}
+
+	var total int64
+	query.Model(&models.AuditLog{}).Count(&total)
+
+	var logs []models.AuditLog
+	if err := query.Preload("User").Preload("Impersonator").Order("created_at DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&logs).Error; err != nil {
+		a.Log.Error("Failed to list audit logs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list audit logs", nil, "")
+	}
+
+	response := make([]AuditLogResponse, len(logs))
+	for i, l := range logs {
+		response[i] = AuditLogResponse{
+			ID:             l.ID,
+			UserID:         l.UserID,
+			ImpersonatorID: l.ImpersonatorID,
+			APIKeyID:       l.APIKeyID,
+			Action:         l.Action,
+			IPAddress:      l.IPAddress,
+			Path:           l.Path,
+			Details:        l.Details,
+			CreatedAt:      l.CreatedAt,
+		}
+		if l.User != nil {
+			response[i].UserName = l.User.FullName
+		}
+		if l.Impersonator != nil {
+			response[i].ImpersonatorName = l.Impersonator.FullName
+		}
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"logs":  response,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// getOrgIPAccessPolicy returns the organization's IP allowlist
+func (a *App) getOrgIPAccessPolicy(orgID uuid.UUID) ipaccess.Policy {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgIPAccessCachePrefix, orgID.String())
+
+	var policy ipaccess.Policy
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			if err := json.Unmarshal([]byte(cached), &policy); err == nil {
+				return policy
+			}
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err == nil && org.Settings != nil {
+		policy = ipaccess.FromSettings(org.Settings)
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(policy); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgIPAccessCacheTTL)
+		}
+	}
+	return policy
+}
+
+// InvalidateOrgIPAccessCache invalidates the cached IP allowlist for an organization
+func (a *App) InvalidateOrgIPAccessCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgIPAccessCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
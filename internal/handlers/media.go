@@ -2,18 +2,36 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/byok"
+	"github.com/shridarpatil/whatomate/internal/mediapolicy"
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 )
 
+const (
+	// mediaDownloadAttempts is how many times a media download is tried before giving up
+	mediaDownloadAttempts = 3
+	// mediaDownloadBackoff is the delay before the first retry, doubled on each attempt
+	mediaDownloadBackoff = time.Second
+	// mediaRetentionWindow is how long Meta keeps incoming media available for download
+	mediaRetentionWindow = 30 * 24 * time.Hour
+	// mediaRepairBatchSize caps how many messages a single repair run inspects
+	mediaRepairBatchSize = 500
+)
+
+// mediaMessageTypes are the message types that carry a downloadable media file
+var mediaMessageTypes = []string{"image", "video", "audio", "document", "sticker"}
+
 // getMediaStoragePath returns the base path for media storage
 func (a *App) getMediaStoragePath() string {
 	basePath := a.Config.Storage.LocalPath
@@ -23,12 +41,6 @@ func (a *App) getMediaStoragePath() string {
 	return basePath
 }
 
-// ensureMediaDir ensures the media directory exists
-func (a *App) ensureMediaDir(subdir string) error {
-	path := filepath.Join(a.getMediaStoragePath(), subdir)
-	return os.MkdirAll(path, 0755)
-}
-
 // getExtensionFromMimeType returns file extension based on mime type
 func getExtensionFromMimeType(mimeType string) string {
 	switch {
@@ -76,19 +88,31 @@ func getExtensionFromMimeType(mimeType string) string {
 }
 
 // DownloadAndSaveMedia downloads media from Meta and saves it locally
-// Returns the local file path (relative to media storage) or error
-func (a *App) DownloadAndSaveMedia(ctx context.Context, mediaID string, mimeType string, account *whatsapp.Account) (string, error) {
-	// Get the media URL from Meta
-	mediaURL, err := a.WhatsApp.GetMediaURL(ctx, mediaID, account)
+// Returns the local file path (relative to media storage) or error. Media the policy
+// doesn't accept is not saved and the error wraps mediapolicy.ErrRejected.
+func (a *App) DownloadAndSaveMedia(ctx context.Context, orgID uuid.UUID, mediaID string, mimeType string, account *whatsapp.Account, policy mediapolicy.Policy) (string, error) {
+	if err := policy.CheckType(mimeType); err != nil {
+		return "", err
+	}
+
+	// Get the media URL and size from Meta
+	info, err := a.WhatsApp.GetMediaInfo(ctx, mediaID, account)
 	if err != nil {
 		return "", fmt.Errorf("failed to get media URL: %w", err)
 	}
+	if err := policy.CheckSize(info.FileSize); err != nil {
+		return "", err
+	}
 
 	// Download the media content
-	data, err := a.WhatsApp.DownloadMedia(ctx, mediaURL, account.AccessToken)
+	data, err := a.WhatsApp.DownloadMedia(ctx, info.URL, account.AccessToken)
 	if err != nil {
 		return "", fmt.Errorf("failed to download media: %w", err)
 	}
+	// Meta doesn't always report the size, so check what was actually downloaded too
+	if err := policy.CheckSize(int64(len(data))); err != nil {
+		return "", err
+	}
 
 	// Determine file extension
 	ext := getExtensionFromMimeType(mimeType)
@@ -112,24 +136,154 @@ func (a *App) DownloadAndSaveMedia(ctx context.Context, mediaID string, mimeType
 		subdir = "documents"
 	}
 
-	// Ensure directory exists
-	if err := a.ensureMediaDir(subdir); err != nil {
+	// Ensure directory exists in the organization's storage region
+	dir, err := a.ensureMediaDir(orgID, subdir)
+	if err != nil {
 		return "", fmt.Errorf("failed to create media directory: %w", err)
 	}
 
+	// Return relative path for storage in database
+	relativePath := filepath.Join(dir, filename)
+
 	// Save file
-	filePath := filepath.Join(a.getMediaStoragePath(), subdir, filename)
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := a.writeMediaFile(orgID, relativePath, data); err != nil {
 		return "", fmt.Errorf("failed to save media file: %w", err)
 	}
-
-	// Return relative path for storage in database
-	relativePath := filepath.Join(subdir, filename)
 	a.Log.Info("Media saved", "path", relativePath, "size", len(data))
 
 	return relativePath, nil
 }
 
+// downloadMediaWithRetry downloads and saves media, retrying transient failures with exponential backoff.
+// Policy rejections are returned straight away since retrying won't change them.
+func (a *App) downloadMediaWithRetry(ctx context.Context, orgID uuid.UUID, mediaID string, mimeType string, account *whatsapp.Account, policy mediapolicy.Policy) (string, error) {
+	var lastErr error
+	backoff := mediaDownloadBackoff
+	for attempt := 1; attempt <= mediaDownloadAttempts; attempt++ {
+		localPath, err := a.DownloadAndSaveMedia(ctx, orgID, mediaID, mimeType, account, policy)
+		if err == nil {
+			return localPath, nil
+		}
+		if errors.Is(err, mediapolicy.ErrRejected) {
+			return "", err
+		}
+		lastErr = err
+
+		if attempt == mediaDownloadAttempts {
+			break
+		}
+		a.Log.Warn("Media download failed, retrying", "media_id", mediaID, "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return "", fmt.Errorf("media download failed after %d attempts: %w", mediaDownloadAttempts, lastErr)
+}
+
+// refetchMessageMedia re-downloads an incoming message's media from Meta and stores the new local path.
+// Meta keeps media for 30 days, after which it can no longer be recovered.
+func (a *App) refetchMessageMedia(ctx context.Context, message *models.Message) (string, error) {
+	if reason, ok := message.Metadata[mediaRejectedMetadataKey].(string); ok {
+		return "", fmt.Errorf("media was rejected by the inbound media policy: %s", reason)
+	}
+	if message.MediaID == "" {
+		return "", fmt.Errorf("message has no media ID")
+	}
+	if time.Since(message.CreatedAt) > mediaRetentionWindow {
+		return "", fmt.Errorf("media has expired on Meta")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", message.WhatsAppAccount, message.OrganizationID).First(&account).Error; err != nil {
+		return "", fmt.Errorf("account not found: %w", err)
+	}
+
+	policy := a.getOrgMediaPolicy(message.OrganizationID)
+	localPath, err := a.downloadMediaWithRetry(ctx, message.OrganizationID, message.MediaID, message.MediaMimeType, a.toWhatsAppAccount(&account), policy)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.DB.Model(message).Update("media_url", localPath).Error; err != nil {
+		return "", fmt.Errorf("failed to update message media: %w", err)
+	}
+	message.MediaURL = localPath
+
+	a.Log.Info("Re-downloaded message media", "message_id", message.ID, "path", localPath)
+	return localPath, nil
+}
+
+// mediaFileExists reports whether a media file is present in local storage
+func mediaFileExists(fullPath string) bool {
+	_, err := os.Stat(fullPath)
+	return err == nil
+}
+
+// UnrecoverableMedia describes a media message whose file is missing and can't be re-downloaded
+type UnrecoverableMedia struct {
+	MessageID   uuid.UUID `json:"message_id"`
+	ContactID   uuid.UUID `json:"contact_id"`
+	MessageType string    `json:"message_type"`
+	CreatedAt   string    `json:"created_at"`
+	Reason      string    `json:"reason"`
+}
+
+// RepairMedia re-downloads missing media for recent incoming messages and lists what can't be recovered
+func (a *App) RepairMedia(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var messages []models.Message
+	if err := a.DB.Where("organization_id = ? AND direction = ? AND message_type IN ?",
+		orgID, models.DirectionIncoming, mediaMessageTypes).
+		Order("created_at DESC").
+		Limit(mediaRepairBatchSize).
+		Find(&messages).Error; err != nil {
+		a.Log.Error("Failed to list media messages", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list media messages", nil, "")
+	}
+
+	ctx := context.Background()
+	repaired := 0
+	unrecoverable := []UnrecoverableMedia{}
+	for i := range messages {
+		msg := &messages[i]
+		if msg.MediaURL != "" && !strings.Contains(msg.MediaURL, "..") &&
+			mediaFileExists(a.mediaFullPath(msg.MediaURL)) {
+			continue
+		}
+
+		if _, err := a.refetchMessageMedia(ctx, msg); err != nil {
+			unrecoverable = append(unrecoverable, UnrecoverableMedia{
+				MessageID:   msg.ID,
+				ContactID:   msg.ContactID,
+				MessageType: string(msg.MessageType),
+				CreatedAt:   msg.CreatedAt.Format("2006-01-02T15:04:05Z"),
+				Reason:      err.Error(),
+			})
+			continue
+		}
+		repaired++
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"checked":       len(messages),
+		"repaired":      repaired,
+		"unrecoverable": unrecoverable,
+	})
+}
+
 // ServeMedia serves media files from local storage
 // Only authorized users who have access to the message can view the media
 func (a *App) ServeMedia(r *fastglue.Request) error {
@@ -154,7 +308,7 @@ func (a *App) ServeMedia(r *fastglue.Request) error {
 	}
 
 	// Users without contacts:read permission can only access media from their assigned contacts
-	if !a.HasPermission(userID, models.ResourceContacts, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
 		var contact models.Contact
 		if err := a.DB.Where("id = ? AND assigned_user_id = ?", message.ContactID, userID).First(&contact).Error; err != nil {
 			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access denied", nil, "")
@@ -162,7 +316,7 @@ func (a *App) ServeMedia(r *fastglue.Request) error {
 	}
 
 	// Check if message has media
-	if message.MediaURL == "" {
+	if message.MediaURL == "" && message.MediaID == "" {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No media found", nil, "")
 	}
 
@@ -173,16 +327,25 @@ func (a *App) ServeMedia(r *fastglue.Request) error {
 	}
 
 	// Build full path
-	fullPath := filepath.Join(a.getMediaStoragePath(), filePath)
+	fullPath := a.mediaFullPath(filePath)
 
-	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "File not found", nil, "")
+	// Lazily re-download from Meta if the original download failed or the file was lost
+	if filePath == "" || !mediaFileExists(fullPath) {
+		refetched, err := a.refetchMessageMedia(context.Background(), &message)
+		if err != nil {
+			a.Log.Warn("Media not available locally and re-download failed", "message_id", message.ID, "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "File not found", nil, "")
+		}
+		filePath = refetched
+		fullPath = a.mediaFullPath(filePath)
 	}
 
 	// Read file
-	data, err := os.ReadFile(fullPath)
+	data, err := a.readMediaFile(orgID, filePath)
 	if err != nil {
+		if errors.Is(err, byok.ErrKeyUnavailable) {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization encryption key is unavailable", nil, "")
+		}
 		a.Log.Error("Failed to read media file", "path", fullPath, "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read file", nil, "")
 	}
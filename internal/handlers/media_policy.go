@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/mediapolicy"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// mediaRejectedMetadataKey is the message metadata key holding why its media was rejected
+const mediaRejectedMetadataKey = "media_rejected"
+
+// getOrgMediaPolicy returns the organization's inbound media policy
+func (a *App) getOrgMediaPolicy(orgID uuid.UUID) mediapolicy.Policy {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgMediaPolicyCachePrefix, orgID.String())
+
+	var policy mediapolicy.Policy
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			if err := json.Unmarshal([]byte(cached), &policy); err == nil {
+				return policy
+			}
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err == nil && org.Settings != nil {
+		policy = mediapolicy.FromSettings(org.Settings)
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(policy); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgMediaPolicyCacheTTL)
+		}
+	}
+	return policy
+}
+
+// InvalidateOrgMediaPolicyCache invalidates the cached inbound media policy for an organization
+func (a *App) InvalidateOrgMediaPolicyCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgMediaPolicyCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
+
+// fetchIncomingMedia downloads an incoming message's media unless the organization's
+// media policy rejects it. Rejected media is not stored: its media ID is dropped so it
+// can't be re-downloaded later, and RejectedReason says why.
+func (a *App) fetchIncomingMedia(account *models.WhatsAppAccount, mediaInfo *MediaInfo) {
+	policy := a.getOrgMediaPolicy(account.OrganizationID)
+	localPath, err := a.downloadMediaWithRetry(context.Background(), account.OrganizationID, mediaInfo.MediaID, mediaInfo.MediaMimeType, a.toWhatsAppAccount(account), policy)
+	if err == nil {
+		mediaInfo.MediaURL = localPath
+		return
+	}
+	if errors.Is(err, mediapolicy.ErrRejected) {
+		a.Log.Info("Incoming media rejected by policy", "media_id", mediaInfo.MediaID, "reason", err)
+		mediaInfo.RejectedReason = strings.TrimPrefix(err.Error(), mediapolicy.ErrRejected.Error()+": ")
+		mediaInfo.MediaID = ""
+		return
+	}
+	a.Log.Error("Failed to download media", "error", err, "media_id", mediaInfo.MediaID)
+}
+
+// handleRejectedMedia tells the contact their media wasn't accepted and records the
+// rejection in the audit log
+func (a *App) handleRejectedMedia(account *models.WhatsAppAccount, contact *models.Contact, mediaInfo *MediaInfo) {
+	policy := a.getOrgMediaPolicy(account.OrganizationID)
+	if err := a.sendAndSaveTextMessage(account, contact, policy.NoticeText()); err != nil {
+		a.Log.Error("Failed to send media rejection notice", "error", err, "contact", contact.PhoneNumber)
+	}
+
+	a.saveAuditLog(models.AuditLog{
+		OrganizationID: account.OrganizationID,
+		Action:         models.AuditActionMediaRejected,
+		Details: fmt.Sprintf("Rejected %s from %s on %s: %s",
+			mediaInfo.MediaMimeType, contact.PhoneNumber, account.Name, mediaInfo.RejectedReason),
+	})
+}
@@ -0,0 +1,61 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_InboundMediaPolicySettings(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("media-policy"), "password", &role.ID, true)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"inbound_media_policy": map[string]interface{}{
+			"max_size_mb":   10,
+			"allowed_types": []string{"Image/*", "application/pdf", "image/*"},
+			"notice":        "Please send images or PDFs up to 10 MB.",
+		},
+	})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.UpdateOrganizationSettings(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.GetOrganizationSettings(req))
+	var resp struct {
+		Data struct {
+			Settings handlers.OrganizationSettings `json:"settings"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	policy := resp.Data.Settings.InboundMediaPolicy
+	assert.Equal(t, 10, policy.MaxSizeMB)
+	assert.Equal(t, []string{"image/*", "application/pdf"}, policy.AllowedTypes)
+	assert.Equal(t, "Please send images or PDFs up to 10 MB.", policy.Notice)
+}
+
+func TestApp_InboundMediaPolicySettings_Invalid(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("media-policy-invalid"), "password", &role.ID, true)
+
+	for _, policy := range []map[string]interface{}{
+		{"max_size_mb": -1},
+		{"max_size_mb": 500},
+		{"allowed_types": []string{"pdf"}},
+	} {
+		req := testutil.NewJSONRequest(t, map[string]interface{}{"inbound_media_policy": policy})
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.UpdateOrganizationSettings(req))
+		assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req), policy)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// mockMediaServer serves Meta's media lookup and download endpoints for the files it holds
+type mockMediaServer struct {
+	server    *httptest.Server
+	files     map[string][]byte
+	downloads int
+}
+
+func newMockMediaServer() *mockMediaServer {
+	m := &mockMediaServer{files: map[string][]byte{}}
+	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if id, ok := strings.CutPrefix(r.URL.Path, "/cdn/"); ok {
+			if data, ok := m.files[id]; ok {
+				m.downloads++
+				_, _ = w.Write(data)
+				return
+			}
+		}
+		if id, ok := strings.CutPrefix(r.URL.Path, "/v18.0/"); ok {
+			if data, ok := m.files[id]; ok {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"url":       "https://lookaside.example.com/cdn/" + id,
+					"mime_type": "image/jpeg",
+					"file_size": len(data),
+				})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	return m
+}
+
+// mediaTestApp creates an App that downloads media from the mock server and stores it in a
+// temporary directory
+func mediaTestApp(t *testing.T, media *mockMediaServer) *handlers.App {
+	t.Helper()
+
+	app := messageTestApp(t, &mockWhatsAppServer{server: media.server})
+	app.Config.Storage.LocalPath = t.TempDir()
+	return app
+}
+
+// createMediaMessage creates an incoming image message
+func createMediaMessage(t *testing.T, app *handlers.App, account *models.WhatsAppAccount, contact *models.Contact, mediaID, mediaURL string, createdAt time.Time) *models.Message {
+	t.Helper()
+
+	msg := &models.Message{
+		BaseModel:       models.BaseModel{CreatedAt: createdAt},
+		OrganizationID:  account.OrganizationID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		Direction:       models.DirectionIncoming,
+		MessageType:     models.MessageTypeImage,
+		MediaID:         mediaID,
+		MediaURL:        mediaURL,
+		MediaMimeType:   "image/jpeg",
+		Status:          models.MessageStatusReceived,
+	}
+	require.NoError(t, app.DB.Create(msg).Error)
+	return msg
+}
+
+func serveMedia(t *testing.T, app *handlers.App, orgID, userID, messageID uuid.UUID) *fastglue.Request {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "message_id", messageID.String())
+	require.NoError(t, app.ServeMedia(req))
+	return req
+}
+
+func TestApp_ServeMedia_RefetchesMissingFile(t *testing.T) {
+	media := newMockMediaServer()
+	defer media.server.Close()
+	media.files["media-photo"] = []byte("jpeg bytes")
+
+	app := mediaTestApp(t, media)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	user := createTestUser(t, app, org.ID, uniqueEmail("media-refetch"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	// The first download failed, so the message has a media ID but no file
+	msg := createMediaMessage(t, app, account, contact, "media-photo", "", time.Now().Add(-time.Hour))
+
+	req := serveMedia(t, app, org.ID, user.ID, msg.ID)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, "jpeg bytes", string(req.RequestCtx.Response.Body()))
+	assert.Equal(t, "image/jpeg", string(req.RequestCtx.Response.Header.ContentType()))
+
+	var saved models.Message
+	require.NoError(t, app.DB.First(&saved, msg.ID).Error)
+	require.NotEmpty(t, saved.MediaURL)
+	assert.FileExists(t, filepath.Join(app.Config.Storage.LocalPath, saved.MediaURL))
+
+	// The saved file is served from then on
+	req = serveMedia(t, app, org.ID, user.ID, msg.ID)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, 1, media.downloads)
+}
+
+func TestApp_ServeMedia_ExpiredOrUnknownMedia(t *testing.T) {
+	media := newMockMediaServer()
+	defer media.server.Close()
+	media.files["media-old"] = []byte("old bytes")
+
+	app := mediaTestApp(t, media)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	user := createTestUser(t, app, org.ID, uniqueEmail("media-expired"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	// Meta no longer has media older than 30 days, so it isn't requested
+	expired := createMediaMessage(t, app, account, contact, "media-old", "images/lost.jpg", time.Now().Add(-31*24*time.Hour))
+	req := serveMedia(t, app, org.ID, user.ID, expired.ID)
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+	assert.Zero(t, media.downloads)
+
+	// Messages without media, or from another organization, aren't found
+	text := &models.Message{OrganizationID: org.ID, WhatsAppAccount: account.Name, ContactID: contact.ID,
+		Direction: models.DirectionIncoming, MessageType: models.MessageTypeText, Content: "hi"}
+	require.NoError(t, app.DB.Create(text).Error)
+	req = serveMedia(t, app, org.ID, user.ID, text.ID)
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+
+	other := createTestOrg(t, app)
+	req = serveMedia(t, app, other.ID, user.ID, expired.ID)
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "message_id", "not-a-uuid")
+	require.NoError(t, app.ServeMedia(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+}
+
+func TestApp_ServeMedia_AssignedContactsOnly(t *testing.T) {
+	media := newMockMediaServer()
+	defer media.server.Close()
+
+	app := mediaTestApp(t, media)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	role := createTransferTestRole(t, app.DB, org.ID, "media-viewer", []string{"chat:read"})
+	user := createTestUser(t, app, org.ID, uniqueEmail("media-viewer"), "password", &role.ID, true)
+
+	path := filepath.Join("images", uuid.NewString()+".jpg")
+	require.NoError(t, os.MkdirAll(filepath.Join(app.Config.Storage.LocalPath, "images"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(app.Config.Storage.LocalPath, path), []byte("jpeg bytes"), 0644))
+	msg := createMediaMessage(t, app, account, contact, "", path, time.Now())
+
+	// Without contacts:read only media of assigned contacts can be viewed
+	req := serveMedia(t, app, org.ID, user.ID, msg.ID)
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	require.NoError(t, app.DB.Model(contact).Update("assigned_user_id", user.ID).Error)
+	req = serveMedia(t, app, org.ID, user.ID, msg.ID)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, "jpeg bytes", string(req.RequestCtx.Response.Body()))
+}
+
+func TestApp_RepairMedia(t *testing.T) {
+	media := newMockMediaServer()
+	defer media.server.Close()
+	media.files["media-recoverable"] = []byte("jpeg bytes")
+	media.files["media-expired"] = []byte("old bytes")
+
+	app := mediaTestApp(t, media)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("media-repair"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	path := filepath.Join("images", uuid.NewString()+".jpg")
+	require.NoError(t, os.MkdirAll(filepath.Join(app.Config.Storage.LocalPath, "images"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(app.Config.Storage.LocalPath, path), []byte("jpeg bytes"), 0644))
+
+	createMediaMessage(t, app, account, contact, "media-present", path, time.Now())
+	recoverable := createMediaMessage(t, app, account, contact, "media-recoverable", "images/lost.jpg", time.Now().Add(-time.Hour))
+	expired := createMediaMessage(t, app, account, contact, "media-expired", "", time.Now().Add(-40*24*time.Hour))
+	noID := createMediaMessage(t, app, account, contact, "", "", time.Now().Add(-2*time.Hour))
+
+	req := testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.RepairMedia(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Checked       int                           `json:"checked"`
+		Repaired      int                           `json:"repaired"`
+		Unrecoverable []handlers.UnrecoverableMedia `json:"unrecoverable"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	assert.Equal(t, 4, resp.Checked)
+	assert.Equal(t, 1, resp.Repaired)
+	require.Len(t, resp.Unrecoverable, 2)
+	reasons := map[uuid.UUID]string{}
+	for _, u := range resp.Unrecoverable {
+		reasons[u.MessageID] = u.Reason
+	}
+	assert.Contains(t, reasons[expired.ID], "expired")
+	assert.Contains(t, reasons[noID.ID], "no media ID")
+
+	var saved models.Message
+	require.NoError(t, app.DB.First(&saved, recoverable.ID).Error)
+	assert.NotEqual(t, "images/lost.jpg", saved.MediaURL)
+	assert.FileExists(t, filepath.Join(app.Config.Storage.LocalPath, saved.MediaURL))
+	assert.Equal(t, 1, media.downloads)
+}
+
+func TestApp_RepairMedia_RequiresPermission(t *testing.T) {
+	media := newMockMediaServer()
+	defer media.server.Close()
+
+	app := mediaTestApp(t, media)
+	org := createTestOrg(t, app)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("media-repair-agent"), "password", &createTransferAgentRole(t, app.DB, org.ID).ID, true)
+
+	req := testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.RepairMedia(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewJSONRequest(t, nil)
+	require.NoError(t, app.RepairMedia(req))
+	assert.Equal(t, fasthttp.StatusUnauthorized, testutil.GetResponseStatusCode(req))
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// maxUploadFieldSize caps non-file form fields such as the caption
+	maxUploadFieldSize = 64 * 1024
+	// uploadFormOverhead allows for multipart boundaries and fields on top of the file itself
+	uploadFormOverhead = 1 << 20
+)
+
+var (
+	// errMediaTooLarge is returned when an upload exceeds the limit for its media type
+	errMediaTooLarge = errors.New("media file is too large")
+	// errInvalidUpload is returned for malformed multipart uploads
+	errInvalidUpload = errors.New("invalid multipart form")
+)
+
+// mediaUpload is a multipart media upload whose file has been streamed to storage
+type mediaUpload struct {
+	Fields    map[string]string
+	LocalPath string // Path relative to the media storage root
+	MimeType  string
+	Filename  string
+	Size      int64
+}
+
+// mediaSizeLimit returns the maximum upload size in bytes for a media type
+func (a *App) mediaSizeLimit(mediaType string) int64 {
+	mb := a.Config.Storage.MaxDocumentSize
+	switch mediaType {
+	case "image":
+		mb = a.Config.Storage.MaxImageSize
+	case "video":
+		mb = a.Config.Storage.MaxVideoSize
+	case "audio":
+		mb = a.Config.Storage.MaxAudioSize
+	}
+	return int64(mb) << 20
+}
+
+// maxMediaSize returns the largest upload size allowed for any media type
+func (a *App) maxMediaSize() int64 {
+	limit := a.mediaSizeLimit("document")
+	for _, mediaType := range []string{"image", "video", "audio"} {
+		limit = max(limit, a.mediaSizeLimit(mediaType))
+	}
+	return limit
+}
+
+// readMediaUpload streams a multipart media upload to storage without buffering the file
+// in memory. The file is checked against the limit for the "type" field, which may appear
+// before or after the file. Returns errMediaTooLarge or errInvalidUpload for bad requests.
+func (a *App) readMediaUpload(r *fastglue.Request) (*mediaUpload, error) {
+	maxSize := a.maxMediaSize()
+	if r.RequestCtx.Request.Header.ContentLength() > int(maxSize+uploadFormOverhead) {
+		return nil, errMediaTooLarge
+	}
+
+	boundary := string(r.RequestCtx.Request.Header.MultipartFormBoundary())
+	if boundary == "" {
+		return nil, errInvalidUpload
+	}
+
+	// The body is only streamed when the server has StreamRequestBody enabled
+	body := r.RequestCtx.RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(r.RequestCtx.PostBody())
+	}
+
+	// Uploads without an organization in context go to the default storage
+	orgID, _ := a.getOrgIDFromContext(r)
+
+	upload := &mediaUpload{Fields: make(map[string]string)}
+	reader := multipart.NewReader(io.LimitReader(body, maxSize+uploadFormOverhead), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			a.removeUpload(upload)
+			return nil, errInvalidUpload
+		}
+
+		if part.FormName() != "file" || part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxUploadFieldSize))
+			if err != nil {
+				a.removeUpload(upload)
+				return nil, errInvalidUpload
+			}
+			upload.Fields[part.FormName()] = string(value)
+			continue
+		}
+		if upload.LocalPath != "" {
+			a.removeUpload(upload)
+			return nil, errInvalidUpload
+		}
+
+		upload.Filename = filepath.Base(part.FileName())
+		upload.MimeType = part.Header.Get("Content-Type")
+		if upload.MimeType == "" {
+			upload.MimeType = "application/octet-stream"
+		}
+		limit := maxSize
+		if mediaType, ok := upload.Fields["type"]; ok {
+			limit = a.mediaSizeLimit(mediaType)
+		}
+		upload.LocalPath, upload.Size, err = a.saveMediaStream(orgID, part, upload.MimeType, upload.Filename, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if upload.LocalPath == "" {
+		return upload, nil
+	}
+	// The type field may have arrived after the file
+	if upload.Size > a.mediaSizeLimit(upload.Fields["type"]) {
+		a.removeUpload(upload)
+		return nil, errMediaTooLarge
+	}
+	return upload, nil
+}
+
+// removeUpload deletes a partially processed upload from storage
+func (a *App) removeUpload(upload *mediaUpload) {
+	if upload.LocalPath == "" {
+		return
+	}
+	if err := os.Remove(a.mediaFullPath(upload.LocalPath)); err != nil {
+		a.Log.Warn("Failed to remove rejected upload", "error", err, "path", upload.LocalPath)
+	}
+	upload.LocalPath = ""
+}
+
+// saveMediaStream copies up to limit bytes from r into local storage and returns the
+// relative path and size. Files over the limit are removed and errMediaTooLarge returned.
+func (a *App) saveMediaStream(orgID uuid.UUID, r io.Reader, mimeType, filename string, limit int64) (string, int64, error) {
+	relPath, err := a.newMediaFilePath(orgID, mimeType, filename)
+	if err != nil {
+		return "", 0, err
+	}
+	size, err := a.saveStream(orgID, r, relPath, limit)
+	if err != nil {
+		return "", 0, err
+	}
+
+	a.Log.Info("Media saved locally", "path", relPath, "size", size)
+	return relPath, size, nil
+}
+
+// saveStream copies up to limit bytes from r to relPath under the media storage root.
+// Files over the limit are removed and errMediaTooLarge returned.
+func (a *App) saveStream(orgID uuid.UUID, r io.Reader, relPath string, limit int64) (int64, error) {
+	fullPath := a.mediaFullPath(relPath)
+
+	file, err := a.createMediaFile(orgID, relPath, os.O_EXCL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create media file: %w", err)
+	}
+	// Read one byte past the limit to tell an exact fit from an oversized file
+	size, copyErr := io.Copy(file, io.LimitReader(r, limit+1))
+	closeErr := file.Close()
+
+	switch {
+	case copyErr != nil:
+		_ = os.Remove(fullPath)
+		return 0, errInvalidUpload
+	case size > limit:
+		_ = os.Remove(fullPath)
+		return 0, errMediaTooLarge
+	case closeErr != nil:
+		_ = os.Remove(fullPath)
+		return 0, fmt.Errorf("failed to save media file: %w", closeErr)
+	}
+	return size, nil
+}
+
+// uploadStoredMedia streams a file from local storage to WhatsApp and returns the media ID
+func (a *App) uploadStoredMedia(ctx context.Context, orgID uuid.UUID, account *whatsapp.Account, localPath, mimeType, filename string) (string, error) {
+	file, err := a.openMediaFile(orgID, localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	return a.WhatsApp.UploadMediaStream(ctx, account, file, mimeType, filename)
+}
+
+// newMediaFilePath picks the storage subdirectory and a unique name for an outgoing
+// media file in the organization's storage region, creating the directory if needed.
+// The returned path is relative.
+func (a *App) newMediaFilePath(orgID uuid.UUID, mimeType, filename string) (string, error) {
+	// Determine subdirectory based on MIME type
+	var subdir string
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		subdir = "images"
+	case strings.HasPrefix(mimeType, "video/"):
+		subdir = "videos"
+	case strings.HasPrefix(mimeType, "audio/"):
+		subdir = "audio"
+	default:
+		subdir = "documents"
+	}
+
+	// Ensure directory exists
+	dir, err := a.ensureMediaDir(orgID, subdir)
+	if err != nil {
+		return "", fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	// Get extension from MIME type or filename
+	ext := getExtensionFromMimeType(mimeType)
+	if ext == "" {
+		// Try to get from filename
+		if dotIdx := strings.LastIndex(filename, "."); dotIdx >= 0 {
+			ext = filename[dotIdx:]
+		} else {
+			ext = ".bin"
+		}
+	}
+
+	return filepath.Join(dir, uuid.New().String()+ext), nil
+}
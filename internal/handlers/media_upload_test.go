@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/fastglue"
+)
+
+func newUploadTestApp(t *testing.T) *App {
+	return &App{
+		Config: &config.Config{Storage: config.StorageConfig{
+			LocalPath:       t.TempDir(),
+			MaxImageSize:    1,
+			MaxVideoSize:    2,
+			MaxAudioSize:    2,
+			MaxDocumentSize: 3,
+		}},
+		Log: testutil.NopLogger(),
+	}
+}
+
+// newUploadRequest builds a multipart request; fields are written in order, with the
+// file placed at fileIndex
+func newUploadRequest(t *testing.T, fields [][2]string, fileIndex int, file []byte) *fastglue.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for i := 0; i <= len(fields); i++ {
+		if i == fileIndex {
+			part, err := w.CreateFormFile("file", "report.pdf")
+			require.NoError(t, err)
+			_, err = part.Write(file)
+			require.NoError(t, err)
+		}
+		if i < len(fields) {
+			require.NoError(t, w.WriteField(fields[i][0], fields[i][1]))
+		}
+	}
+	require.NoError(t, w.Close())
+
+	req := testutil.NewRequest(t)
+	req.RequestCtx.Request.Header.SetMethod("POST")
+	req.RequestCtx.Request.Header.SetContentType(w.FormDataContentType())
+	req.RequestCtx.Request.SetBody(body.Bytes())
+	return req
+}
+
+func TestReadMediaUpload(t *testing.T) {
+	app := newUploadTestApp(t)
+	content := []byte(strings.Repeat("a", 1500*1024))
+
+	req := newUploadRequest(t, [][2]string{{"contact_id", "c1"}, {"type", "document"}, {"caption", "Q3"}}, 3, content)
+	upload, err := app.readMediaUpload(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "c1", upload.Fields["contact_id"])
+	assert.Equal(t, "Q3", upload.Fields["caption"])
+	assert.Equal(t, "report.pdf", upload.Filename)
+	assert.Equal(t, int64(len(content)), upload.Size)
+
+	stored, err := os.ReadFile(filepath.Join(app.getMediaStoragePath(), upload.LocalPath))
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+}
+
+func TestReadMediaUpload_TooLarge(t *testing.T) {
+	content := []byte(strings.Repeat("a", 1500*1024))
+
+	tests := []struct {
+		name      string
+		fileIndex int
+	}{
+		{"type before file", 1},
+		{"type after file", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newUploadTestApp(t)
+			req := newUploadRequest(t, [][2]string{{"type", "image"}}, tt.fileIndex, content)
+
+			_, err := app.readMediaUpload(req)
+			assert.ErrorIs(t, err, errMediaTooLarge)
+
+			// Rejected files must not be left behind
+			entries, _ := os.ReadDir(filepath.Join(app.getMediaStoragePath(), "documents"))
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestReadMediaUpload_InvalidForm(t *testing.T) {
+	app := newUploadTestApp(t)
+
+	req := testutil.NewJSONRequest(t, map[string]string{"contact_id": "c1"})
+	_, err := app.readMediaUpload(req)
+	assert.ErrorIs(t, err, errInvalidUpload)
+}
@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// approvalReasonAgent is recorded when a message is held because of the agent or team setting
+const approvalReasonAgent = "Sender requires approval"
+
+// MessageApprovalResponse represents an outbound message awaiting or after review
+type MessageApprovalResponse struct {
+	ID              uuid.UUID             `json:"id"`
+	ContactID       uuid.UUID             `json:"contact_id"`
+	ContactName     string                `json:"contact_name"`
+	ContactPhone    string                `json:"contact_phone"`
+	RequestedByID   uuid.UUID             `json:"requested_by_id"`
+	RequestedByName string                `json:"requested_by_name,omitempty"`
+	MessageType     models.MessageType    `json:"message_type"`
+	Content         string                `json:"content"`
+	OriginalContent string                `json:"original_content,omitempty"`
+	MediaFilename   string                `json:"media_filename,omitempty"`
+	Reason          string                `json:"reason"`
+	Status          models.ApprovalStatus `json:"status"`
+	ReviewedByID    *uuid.UUID            `json:"reviewed_by_id,omitempty"`
+	ReviewedByName  string                `json:"reviewed_by_name,omitempty"`
+	ReviewedAt      *time.Time            `json:"reviewed_at,omitempty"`
+	ReviewNote      string                `json:"review_note,omitempty"`
+	MessageID       *uuid.UUID            `json:"message_id,omitempty"`
+	CreatedAt       time.Time             `json:"created_at"`
+}
+
+// ReviewMessageApprovalRequest represents a supervisor's decision on a pending message.
+// Content, when set on approval, replaces the message text before it is sent.
+type ReviewMessageApprovalRequest struct {
+	Note    string  `json:"note"`
+	Content *string `json:"content"`
+}
+
+// MessageApprovalStats represents approval volume and review latency
+type MessageApprovalStats struct {
+	Pending           int64                  `json:"pending"`
+	OldestPendingSecs float64                `json:"oldest_pending_secs"`
+	Approved          int64                  `json:"approved"`
+	Rejected          int64                  `json:"rejected"`
+	Edited            int64                  `json:"edited"`
+	AvgReviewSecs     float64                `json:"avg_review_secs"`
+	MaxReviewSecs     float64                `json:"max_review_secs"`
+	ReviewerLatencies []ReviewerLatencyStats `json:"reviewers"`
+}
+
+// ReviewerLatencyStats represents how quickly one supervisor reviews messages
+type ReviewerLatencyStats struct {
+	ReviewerID    uuid.UUID `json:"reviewer_id"`
+	ReviewerName  string    `json:"reviewer_name"`
+	Reviewed      int64     `json:"reviewed"`
+	AvgReviewSecs float64   `json:"avg_review_secs"`
+}
+
+// ListMessageApprovals lists outbound messages held for approval. Supervisors see the whole
+// organization, team managers see their team members' messages and agents see their own.
+func (a *App) ListMessageApprovals(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	status := string(r.RequestCtx.QueryArgs().Peek("status"))
+	if status == "" {
+		status = string(models.ApprovalStatusPending)
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID).
+		Preload("Contact").Preload("RequestedBy").Preload("ReviewedBy")
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if !a.canReviewMessages(userID, orgID) {
+		senderIDs := append(a.managedTeamMemberIDs(userID), userID)
+		query = query.Where("requested_by_id IN ?", senderIDs)
+	}
+
+	var approvals []models.MessageApproval
+	if err := query.Order("created_at DESC").Limit(100).Find(&approvals).Error; err != nil {
+		a.Log.Error("Failed to list message approvals", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list message approvals", nil, "")
+	}
+
+	result := make([]MessageApprovalResponse, len(approvals))
+	for i := range approvals {
+		result[i] = buildMessageApprovalResponse(&approvals[i])
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"approvals": result,
+	})
+}
+
+// GetMessageApprovalStats returns approval counts and review latency for a date range
+func (a *App) GetMessageApprovalStats(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.canReviewMessages(userID, orgID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	// Default to the last 30 days
+	loc := a.getOrgLocation(orgID)
+	periodEnd := time.Now().In(loc)
+	periodStart := periodEnd.AddDate(0, 0, -30)
+	if fromStr := string(r.RequestCtx.QueryArgs().Peek("from")); fromStr != "" {
+		if periodStart, err = time.ParseInLocation("2006-01-02", fromStr, loc); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
+		}
+	}
+	if toStr := string(r.RequestCtx.QueryArgs().Peek("to")); toStr != "" {
+		if periodEnd, err = time.ParseInLocation("2006-01-02", toStr, loc); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
+		}
+		periodEnd = periodEnd.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	stats := MessageApprovalStats{ReviewerLatencies: []ReviewerLatencyStats{}}
+
+	a.DB.Model(&models.MessageApproval{}).
+		Where("organization_id = ? AND status = ?", orgID, models.ApprovalStatusPending).
+		Count(&stats.Pending)
+
+	var oldest struct{ Secs float64 }
+	a.DB.Model(&models.MessageApproval{}).
+		Select("COALESCE(MAX(EXTRACT(EPOCH FROM (NOW() - created_at))), 0) as secs").
+		Where("organization_id = ? AND status = ?", orgID, models.ApprovalStatusPending).
+		Scan(&oldest)
+	stats.OldestPendingSecs = oldest.Secs
+
+	reviewed := func() *gorm.DB {
+		return a.DB.Model(&models.MessageApproval{}).
+			Where("organization_id = ? AND reviewed_at IS NOT NULL AND created_at >= ? AND created_at <= ?", orgID, periodStart, periodEnd)
+	}
+
+	type StatusCount struct {
+		Status models.ApprovalStatus
+		Count  int64
+	}
+	var counts []StatusCount
+	reviewed().Select("status, COUNT(*) as count").Group("status").Scan(&counts)
+	for _, c := range counts {
+		switch c.Status {
+		case models.ApprovalStatusApproved:
+			stats.Approved = c.Count
+		case models.ApprovalStatusRejected:
+			stats.Rejected = c.Count
+		}
+	}
+	reviewed().Where("original_content != ''").Count(&stats.Edited)
+
+	var latency struct {
+		Avg float64
+		Max float64
+	}
+	reviewed().
+		Select("COALESCE(AVG(EXTRACT(EPOCH FROM (reviewed_at - created_at))), 0) as avg, COALESCE(MAX(EXTRACT(EPOCH FROM (reviewed_at - created_at))), 0) as max").
+		Scan(&latency)
+	stats.AvgReviewSecs = latency.Avg
+	stats.MaxReviewSecs = latency.Max
+
+	type ReviewerRow struct {
+		ReviewedByID uuid.UUID
+		Reviewed     int64
+		Avg          float64
+	}
+	var rows []ReviewerRow
+	reviewed().
+		Select("reviewed_by_id, COUNT(*) as reviewed, AVG(EXTRACT(EPOCH FROM (reviewed_at - created_at))) as avg").
+		Group("reviewed_by_id").Order("reviewed DESC").
+		Scan(&rows)
+
+	if len(rows) > 0 {
+		reviewerIDs := make([]uuid.UUID, len(rows))
+		for i, row := range rows {
+			reviewerIDs[i] = row.ReviewedByID
+		}
+		var users []models.User
+		a.DB.Select("id, full_name").Where("id IN ?", reviewerIDs).Find(&users)
+		names := make(map[uuid.UUID]string, len(users))
+		for _, u := range users {
+			names[u.ID] = u.FullName
+		}
+		for _, row := range rows {
+			stats.ReviewerLatencies = append(stats.ReviewerLatencies, ReviewerLatencyStats{
+				ReviewerID:    row.ReviewedByID,
+				ReviewerName:  names[row.ReviewedByID],
+				Reviewed:      row.Reviewed,
+				AvgReviewSecs: row.Avg,
+			})
+		}
+	}
+
+	return r.SendEnvelope(stats)
+}
+
+// ApproveMessage sends a pending message on behalf of the agent who wrote it,
+// optionally with text edited by the reviewer
+func (a *App) ApproveMessage(r *fastglue.Request) error {
+	approval, err := a.claimMessageApproval(r, models.ApprovalStatusApproved)
+	if err != nil || approval == nil {
+		return err
+	}
+
+	message, err := a.sendApprovedMessage(approval)
+	if err != nil {
+		a.Log.Error("Failed to send approved message", "error", err, "approval_id", approval.ID)
+		// Put it back in the queue so it can be retried or rejected
+		a.DB.Model(approval).Updates(map[string]interface{}{
+			"status":         models.ApprovalStatusPending,
+			"reviewed_by_id": nil,
+			"reviewed_at":    nil,
+			"review_note":    "",
+		})
+		if isSendBlocked(err) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to send message", nil, "")
+	}
+
+	a.DB.Model(approval).Update("message_id", message.ID)
+	approval.MessageID = &message.ID
+
+	a.Log.Info("Outbound message approved", "approval_id", approval.ID, "reviewed_by", approval.ReviewedByID,
+		"edited", approval.OriginalContent != "", "latency_secs", approval.ReviewedAt.Sub(approval.CreatedAt).Seconds())
+	a.broadcastMessageApproval(approval, websocket.TypeMessageApprovalReviewed)
+
+	return r.SendEnvelope(buildMessageApprovalResponse(approval))
+}
+
+// RejectMessage discards a pending message without sending it
+func (a *App) RejectMessage(r *fastglue.Request) error {
+	approval, err := a.claimMessageApproval(r, models.ApprovalStatusRejected)
+	if err != nil || approval == nil {
+		return err
+	}
+
+	a.Log.Info("Outbound message rejected", "approval_id", approval.ID, "reviewed_by", approval.ReviewedByID,
+		"latency_secs", approval.ReviewedAt.Sub(approval.CreatedAt).Seconds())
+	a.broadcastMessageApproval(approval, websocket.TypeMessageApprovalReviewed)
+
+	return r.SendEnvelope(buildMessageApprovalResponse(approval))
+}
+
+// claimMessageApproval moves a pending approval to status for the reviewing supervisor.
+// On failure it sends the error response and returns a nil approval.
+func (a *App) claimMessageApproval(r *fastglue.Request, status models.ApprovalStatus) (*models.MessageApproval, error) {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	approvalID, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid approval ID", nil, "")
+	}
+
+	var req ReviewMessageApprovalRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+
+	var approval models.MessageApproval
+	if err := a.DB.Where("id = ? AND organization_id = ?", approvalID, orgID).
+		Preload("Contact").Preload("RequestedBy").First(&approval).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Approval not found", nil, "")
+	}
+
+	if !a.canReviewApproval(userID, &approval) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":         status,
+		"reviewed_by_id": userID,
+		"reviewed_at":    now,
+		"review_note":    req.Note,
+	}
+
+	// Reviewers may rewrite the text before approving; the edit is checked against the policy too
+	if status == models.ApprovalStatusApproved && req.Content != nil && *req.Content != approval.Content {
+		if approval.MessageType != models.MessageTypeText && approval.MessageType != models.MessageTypeInteractive {
+			return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Only text messages can be edited", nil, "")
+		}
+		result, err := a.getOrgContentPolicy(orgID).Apply(*req.Content)
+		if err != nil {
+			return nil, r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, err.Error(), nil, "")
+		}
+		if approval.OriginalContent == "" {
+			approval.OriginalContent = approval.Content
+		}
+		approval.Content = result.Text
+		updates["content"] = approval.Content
+		updates["original_content"] = approval.OriginalContent
+		if approval.MessageType == models.MessageTypeInteractive && approval.InteractiveData != nil {
+			approval.InteractiveData["body"] = approval.Content
+			updates["interactive_data"] = approval.InteractiveData
+		}
+	}
+
+	// Claim atomically so two supervisors can't both act on the same message
+	result := a.DB.Model(&models.MessageApproval{}).
+		Where("id = ? AND status = ?", approval.ID, models.ApprovalStatusPending).
+		Updates(updates)
+	if result.Error != nil {
+		a.Log.Error("Failed to update message approval", "error", result.Error)
+		return nil, r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update approval", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusConflict, "Message has already been reviewed", nil, "")
+	}
+
+	approval.Status = status
+	approval.ReviewedByID = &userID
+	approval.ReviewedAt = &now
+	approval.ReviewNote = req.Note
+	return &approval, nil
+}
+
+// createMessageApproval holds an outbound message for review instead of sending it
+// and notifies supervisors
+func (a *App) createMessageApproval(approval *models.MessageApproval, contact *models.Contact) error {
+	approval.ID = uuid.New()
+	approval.Status = models.ApprovalStatusPending
+	if err := a.DB.Create(approval).Error; err != nil {
+		a.Log.Error("Failed to create message approval", "error", err)
+		return err
+	}
+	approval.Contact = contact
+
+	a.Log.Info("Outbound message held for approval", "approval_id", approval.ID, "contact_id", contact.ID,
+		"user_id", approval.RequestedByID, "reason", approval.Reason)
+	a.broadcastMessageApproval(approval, websocket.TypeMessageApproval)
+	return nil
+}
+
+// sendApprovedMessage sends an approved message as the agent who requested it
+func (a *App) sendApprovedMessage(approval *models.MessageApproval) (*models.Message, error) {
+	account, err := a.resolveWhatsAppAccount(approval.OrganizationID, approval.WhatsAppAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	contact := approval.Contact
+	if contact == nil {
+		contact = &models.Contact{}
+		if err := a.DB.Where("id = ?", approval.ContactID).First(contact).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	msgReq := OutgoingMessageRequest{
+		Account: account,
+		Contact: contact,
+		Type:    approval.MessageType,
+		Content: approval.Content,
+	}
+	if approval.ReplyToMessageID != nil {
+		var replyTo models.Message
+		if err := a.DB.Where("id = ? AND contact_id = ?", *approval.ReplyToMessageID, contact.ID).First(&replyTo).Error; err == nil {
+			msgReq.ReplyToMessage = &replyTo
+		}
+	}
+
+	switch {
+	case approval.MessageType == models.MessageTypeInteractive && len(approval.InteractiveData) > 0:
+		var interactive InteractiveContent
+		data, err := json.Marshal(approval.InteractiveData)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &interactive); err != nil {
+			return nil, err
+		}
+		applyInteractiveContent(&msgReq, &interactive)
+
+	case approval.MessageType == models.MessageTypeTemplate && approval.TemplateID != nil:
+		// Send the template as it is now, so an edit or archive since the request is respected
+		var template models.Template
+		if err := a.DB.Where("id = ? AND organization_id = ?", *approval.TemplateID, approval.OrganizationID).First(&template).Error; err != nil {
+			return nil, err
+		}
+		if template.ArchivedAt != nil || template.Status != "APPROVED" {
+			return nil, fmt.Errorf("template %s can no longer be sent", template.Name)
+		}
+		var send SendTemplateMessageRequest
+		data, err := json.Marshal(approval.TemplateData)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &send); err != nil {
+			return nil, err
+		}
+		msgReq.Content = ""
+		msgReq.Template = &template
+		msgReq.BodyParams = send.TemplateParams
+		msgReq.FlowToken = send.FlowToken
+		msgReq.FlowActionData = send.FlowActionData
+
+	case approval.MediaURL != "":
+		// The stored file is streamed to WhatsApp when the message is sent
+		if _, err := os.Stat(a.mediaFullPath(approval.MediaURL)); err != nil {
+			return nil, err
+		}
+		msgReq.Content = ""
+		msgReq.Caption = approval.Content
+		msgReq.MediaURL = approval.MediaURL
+		msgReq.MediaMimeType = approval.MediaMimeType
+		msgReq.MediaFilename = approval.MediaFilename
+	}
+
+	opts := DefaultSendOptions()
+	opts.SentByUserID = &approval.RequestedByID
+
+	return a.SendOutgoingMessage(context.Background(), msgReq, opts)
+}
+
+// userRequiresApproval reports whether a user's outbound messages must be reviewed, either
+// because of their own setting or an approval-mode team they are an agent in
+func (a *App) userRequiresApproval(userID uuid.UUID) bool {
+	var user models.User
+	if err := a.DB.Select("id, requires_approval").Where("id = ?", userID).First(&user).Error; err != nil {
+		return false
+	}
+	if user.RequiresApproval {
+		return true
+	}
+
+	var count int64
+	a.DB.Model(&models.TeamMember{}).
+		Joins("JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+		Where("team_members.user_id = ? AND team_members.role = ? AND teams.requires_approval = ? AND teams.is_active = ?",
+			userID, models.TeamRoleAgent, true, true).
+		Count(&count)
+	return count > 0
+}
+
+// canReviewMessages reports whether the user may review any held message in the organization
+func (a *App) canReviewMessages(userID, orgID uuid.UUID) bool {
+	return a.HasPermission(userID, orgID, models.ResourceChatAssign, models.ActionWrite)
+}
+
+// canReviewApproval reports whether the user may review this message: supervisors can review
+// all of them and team managers can review messages from their own team members
+func (a *App) canReviewApproval(userID uuid.UUID, approval *models.MessageApproval) bool {
+	if approval.RequestedByID == userID {
+		return false
+	}
+	if a.canReviewMessages(userID, approval.OrganizationID) {
+		return true
+	}
+	for _, memberID := range a.managedTeamMemberIDs(userID) {
+		if memberID == approval.RequestedByID {
+			return true
+		}
+	}
+	return false
+}
+
+// managedTeamMemberIDs returns the users in teams the given user manages
+func (a *App) managedTeamMemberIDs(userID uuid.UUID) []uuid.UUID {
+	var memberIDs []uuid.UUID
+	a.DB.Model(&models.TeamMember{}).
+		Where("team_id IN (?) AND user_id != ?",
+			a.DB.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ? AND role = ?", userID, models.TeamRoleManager),
+			userID).
+		Distinct().Pluck("user_id", &memberIDs)
+	return memberIDs
+}
+
+// broadcastMessageApproval notifies the organization that a message was held or reviewed
+func (a *App) broadcastMessageApproval(approval *models.MessageApproval, msgType string) {
+	if a.WSHub == nil {
+		return
+	}
+
+	a.WSHub.BroadcastToOrg(approval.OrganizationID, websocket.WSMessage{
+		Type:    msgType,
+		Payload: buildMessageApprovalResponse(approval),
+	})
+}
+
+// buildMessageApprovalResponse converts an approval to its API response
+func buildMessageApprovalResponse(approval *models.MessageApproval) MessageApprovalResponse {
+	resp := MessageApprovalResponse{
+		ID:              approval.ID,
+		ContactID:       approval.ContactID,
+		RequestedByID:   approval.RequestedByID,
+		MessageType:     approval.MessageType,
+		Content:         approval.Content,
+		OriginalContent: approval.OriginalContent,
+		MediaFilename:   approval.MediaFilename,
+		Reason:          approval.Reason,
+		Status:          approval.Status,
+		ReviewedByID:    approval.ReviewedByID,
+		ReviewedAt:      approval.ReviewedAt,
+		ReviewNote:      approval.ReviewNote,
+		MessageID:       approval.MessageID,
+		CreatedAt:       approval.CreatedAt,
+	}
+	if approval.Contact != nil {
+		resp.ContactName = approval.Contact.ProfileName
+		resp.ContactPhone = approval.Contact.PhoneNumber
+	}
+	if approval.RequestedBy != nil {
+		resp.RequestedByName = approval.RequestedBy.FullName
+	}
+	if approval.ReviewedBy != nil {
+		resp.ReviewedByName = approval.ReviewedBy.FullName
+	}
+	return resp
+}
+
+// interactiveToJSONB converts interactive content to the JSONB stored on an approval
+func interactiveToJSONB(interactive *InteractiveContent) (models.JSONB, error) {
+	data, err := json.Marshal(interactive)
+	if err != nil {
+		return nil, err
+	}
+	var result models.JSONB
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// templateSendToJSONB converts the params and flow data of a template send to the JSONB
+// stored on an approval
+func templateSendToJSONB(req *SendTemplateMessageRequest) (models.JSONB, error) {
+	data, err := json.Marshal(SendTemplateMessageRequest{
+		TemplateParams: req.TemplateParams,
+		FlowToken:      req.FlowToken,
+		FlowActionData: req.FlowActionData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result models.JSONB
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
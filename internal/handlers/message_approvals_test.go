@@ -0,0 +1,340 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// sendApprovalTestMessage sends a text message to a contact as userID
+func sendApprovalTestMessage(t *testing.T, app *handlers.App, orgID, userID, contactID uuid.UUID, body string) *fastglue.Request {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"type":    "text",
+		"content": map[string]string{"body": body},
+	})
+	setAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "id", contactID.String())
+	require.NoError(t, app.SendMessage(req))
+	return req
+}
+
+// heldApproval sends a message that must be held and returns its approval
+func heldApproval(t *testing.T, app *handlers.App, orgID, userID, contactID uuid.UUID, body string) handlers.MessageApprovalResponse {
+	t.Helper()
+
+	req := sendApprovalTestMessage(t, app, orgID, userID, contactID, body)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		PendingApproval bool                             `json:"pending_approval"`
+		Approval        handlers.MessageApprovalResponse `json:"approval"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	require.True(t, resp.PendingApproval, "message was sent without approval")
+	return resp.Approval
+}
+
+// reviewApproval calls ApproveMessage or RejectMessage as userID
+func reviewApproval(t *testing.T, handler func(*fastglue.Request) error, orgID, userID uuid.UUID, approvalID string, body any) *fastglue.Request {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, body)
+	setAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "id", approvalID)
+	require.NoError(t, handler(req))
+	return req
+}
+
+func listApprovals(t *testing.T, app *handlers.App, orgID, userID uuid.UUID, status string) []handlers.MessageApprovalResponse {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, orgID, userID)
+	if status != "" {
+		testutil.SetQueryParam(req, "status", status)
+	}
+	require.NoError(t, app.ListMessageApprovals(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		Approvals []handlers.MessageApprovalResponse `json:"approvals"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	return resp.Approvals
+}
+
+func approvalIDs(approvals []handlers.MessageApprovalResponse) []uuid.UUID {
+	ids := make([]uuid.UUID, len(approvals))
+	for i, a := range approvals {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+func TestApp_ApprovalMode_AgentMessages(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	supervisor := createTestUser(t, app, org.ID, uniqueEmail("approval-supervisor"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	agent := createTestAgent(t, app, org.ID)
+	require.NoError(t, app.DB.Model(agent).Update("requires_approval", true).Error)
+
+	// The agent's message is held instead of sent
+	approval := heldApproval(t, app, org.ID, agent.ID, contact.ID, "Your refund is approved")
+	assert.Equal(t, models.ApprovalStatusPending, approval.Status)
+	assert.Equal(t, "Sender requires approval", approval.Reason)
+	assert.Equal(t, agent.ID, approval.RequestedByID)
+	assert.Empty(t, mockServer.sentMessages)
+	var count int64
+	app.DB.Model(&models.Message{}).Where("contact_id = ? AND direction = ?", contact.ID, models.DirectionOutgoing).Count(&count)
+	assert.Zero(t, count)
+
+	assert.Contains(t, approvalIDs(listApprovals(t, app, org.ID, supervisor.ID, "")), approval.ID)
+
+	// The supervisor edits the text and approves; it goes out as the agent
+	req := reviewApproval(t, app.ApproveMessage, org.ID, supervisor.ID, approval.ID.String(),
+		handlers.ReviewMessageApprovalRequest{Note: "Softened wording", Content: testutil.StringPtr("Your refund has been approved")})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var approved handlers.MessageApprovalResponse
+	testutil.ParseEnvelopeResponse(t, req, &approved)
+	assert.Equal(t, models.ApprovalStatusApproved, approved.Status)
+	assert.Equal(t, "Your refund has been approved", approved.Content)
+	assert.Equal(t, "Your refund is approved", approved.OriginalContent)
+	require.NotNil(t, approved.ReviewedByID)
+	assert.Equal(t, supervisor.ID, *approved.ReviewedByID)
+	require.NotNil(t, approved.MessageID)
+
+	require.Len(t, mockServer.sentMessages, 1)
+	assert.Equal(t, map[string]interface{}{"body": "Your refund has been approved"}, mockServer.sentMessages[0]["text"])
+	var message models.Message
+	require.NoError(t, app.DB.Where("id = ?", *approved.MessageID).First(&message).Error)
+	require.NotNil(t, message.SentByUserID)
+	assert.Equal(t, agent.ID, *message.SentByUserID)
+
+	// A message is reviewed once
+	req = reviewApproval(t, app.RejectMessage, org.ID, supervisor.ID, approval.ID.String(), nil)
+	assert.Equal(t, fasthttp.StatusConflict, testutil.GetResponseStatusCode(req))
+	assert.NotContains(t, approvalIDs(listApprovals(t, app, org.ID, supervisor.ID, "")), approval.ID)
+	assert.Contains(t, approvalIDs(listApprovals(t, app, org.ID, supervisor.ID, "approved")), approval.ID)
+
+	// Rejected messages are never sent
+	rejected := heldApproval(t, app, org.ID, agent.ID, contact.ID, "Call me on my personal number")
+	req = reviewApproval(t, app.RejectMessage, org.ID, supervisor.ID, rejected.ID.String(), handlers.ReviewMessageApprovalRequest{Note: "Against policy"})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var saved models.MessageApproval
+	require.NoError(t, app.DB.Where("id = ?", rejected.ID).First(&saved).Error)
+	assert.Equal(t, models.ApprovalStatusRejected, saved.Status)
+	assert.Equal(t, "Against policy", saved.ReviewNote)
+	assert.Nil(t, saved.MessageID)
+	assert.Len(t, mockServer.sentMessages, 1)
+}
+
+func TestApp_ApprovalMode_TemplateMessages(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	supervisor := createTestUser(t, app, org.ID, uniqueEmail("approval-supervisor"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	agent := createTestAgent(t, app, org.ID)
+	require.NoError(t, app.DB.Model(agent).Update("requires_approval", true).Error)
+
+	// Template sends are held like any other message from the agent
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"contact_id":      contact.ID.String(),
+		"template_name":   template.Name,
+		"template_params": map[string]string{"1": "Asha"},
+	})
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.SendTemplateMessage(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		PendingApproval bool                             `json:"pending_approval"`
+		Approval        handlers.MessageApprovalResponse `json:"approval"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	require.True(t, resp.PendingApproval, "template was sent without approval")
+	assert.Equal(t, models.MessageTypeTemplate, resp.Approval.MessageType)
+	assert.Equal(t, "Hello Asha", resp.Approval.Content)
+	assert.Empty(t, mockServer.sentMessages)
+
+	// Template text can't be edited, but approving sends the template with its params
+	req = reviewApproval(t, app.ApproveMessage, org.ID, supervisor.ID, resp.Approval.ID.String(),
+		handlers.ReviewMessageApprovalRequest{Content: testutil.StringPtr("Hi Asha")})
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	req = reviewApproval(t, app.ApproveMessage, org.ID, supervisor.ID, resp.Approval.ID.String(), nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var approved handlers.MessageApprovalResponse
+	testutil.ParseEnvelopeResponse(t, req, &approved)
+	require.NotNil(t, approved.MessageID)
+	require.Len(t, mockServer.sentMessages, 1)
+	assert.Equal(t, "template", mockServer.sentMessages[0]["type"])
+
+	var message models.Message
+	require.NoError(t, app.DB.Where("id = ?", *approved.MessageID).First(&message).Error)
+	assert.Equal(t, models.MessageTypeTemplate, message.MessageType)
+	require.NotNil(t, message.SentByUserID)
+	assert.Equal(t, agent.ID, *message.SentByUserID)
+}
+
+func TestApp_ApprovalMode_Teams(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	agent := createTestAgent(t, app, org.ID)
+	manager := createTestAgent(t, app, org.ID)
+	outsider := createTestAgent(t, app, org.ID)
+
+	team := createTestTeam(t, app, org.ID, agent.ID)
+	require.NoError(t, app.DB.Create(&models.TeamMember{TeamID: team.ID, UserID: manager.ID, Role: models.TeamRoleManager}).Error)
+
+	// Teams without approval mode send directly
+	req := sendApprovalTestMessage(t, app, org.ID, agent.ID, contact.ID, "Hello")
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	require.Len(t, mockServer.sentMessages, 1)
+
+	// Agents in an approval-mode team are held; the team's managers aren't
+	require.NoError(t, app.DB.Model(team).Update("requires_approval", true).Error)
+	approval := heldApproval(t, app, org.ID, agent.ID, contact.ID, "We can offer 50% off")
+	req = sendApprovalTestMessage(t, app, org.ID, manager.ID, contact.ID, "Manager here")
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	require.Len(t, mockServer.sentMessages, 2)
+
+	// Managers review their team's messages; other agents can't see or review them
+	assert.Contains(t, approvalIDs(listApprovals(t, app, org.ID, manager.ID, "")), approval.ID)
+	assert.NotContains(t, approvalIDs(listApprovals(t, app, org.ID, outsider.ID, "")), approval.ID)
+	req = reviewApproval(t, app.ApproveMessage, org.ID, outsider.ID, approval.ID.String(), nil)
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	req = reviewApproval(t, app.RejectMessage, org.ID, manager.ID, approval.ID.String(), nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Len(t, mockServer.sentMessages, 2)
+
+	// Inactive teams don't hold messages
+	require.NoError(t, app.DB.Model(team).Update("is_active", false).Error)
+	req = sendApprovalTestMessage(t, app, org.ID, agent.ID, contact.ID, "Back to normal")
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Len(t, mockServer.sentMessages, 3)
+}
+
+func TestApp_ApprovalMode_AccessChecks(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	supervisor := createTestUser(t, app, org.ID, uniqueEmail("approval-checks"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	agent := createTestAgent(t, app, org.ID)
+	require.NoError(t, app.DB.Model(agent).Update("requires_approval", true).Error)
+
+	// Supervisors' own messages are never held, even with the setting on
+	require.NoError(t, app.DB.Model(supervisor).Update("requires_approval", true).Error)
+	req := sendApprovalTestMessage(t, app, org.ID, supervisor.ID, contact.ID, "Hello from the supervisor")
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Len(t, mockServer.sentMessages, 1)
+
+	approval := heldApproval(t, app, org.ID, agent.ID, contact.ID, "Refund sent")
+
+	// Agents see their own held messages but can't approve them
+	assert.Equal(t, []uuid.UUID{approval.ID}, approvalIDs(listApprovals(t, app, org.ID, agent.ID, "")))
+	req = reviewApproval(t, app.ApproveMessage, org.ID, agent.ID, approval.ID.String(), nil)
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	statsReq := testutil.NewGETRequest(t)
+	setAuthContext(statsReq, org.ID, agent.ID)
+	require.NoError(t, app.GetMessageApprovalStats(statsReq))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(statsReq))
+
+	// Agents can't switch approval mode off for themselves
+	userReq := testutil.NewJSONRequest(t, map[string]interface{}{"requires_approval": false})
+	setAuthContext(userReq, org.ID, agent.ID)
+	testutil.SetPathParam(userReq, "id", agent.ID.String())
+	require.NoError(t, app.UpdateUser(userReq))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(userReq))
+
+	// Supervisors of other organizations can't see or review the message
+	other := createTestOrg(t, app)
+	otherSupervisor := createTestUser(t, app, other.ID, uniqueEmail("approval-other"), "password", &createTransferAdminRole(t, app.DB, other.ID).ID, true)
+	assert.NotContains(t, approvalIDs(listApprovals(t, app, other.ID, otherSupervisor.ID, "all")), approval.ID)
+	req = reviewApproval(t, app.ApproveMessage, other.ID, otherSupervisor.ID, approval.ID.String(), nil)
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusNotFound, "Approval not found")
+
+	req = reviewApproval(t, app.ApproveMessage, org.ID, supervisor.ID, "not-a-uuid", nil)
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Invalid approval ID")
+
+	// Only the text of a message can be edited
+	media := &models.MessageApproval{OrganizationID: org.ID, ContactID: contact.ID, WhatsAppAccount: account.Name, RequestedByID: agent.ID,
+		MessageType: models.MessageTypeImage, Content: "Invoice", MediaURL: "images/invoice.jpg", Status: models.ApprovalStatusPending}
+	require.NoError(t, app.DB.Create(media).Error)
+	req = reviewApproval(t, app.ApproveMessage, org.ID, supervisor.ID, media.ID.String(), handlers.ReviewMessageApprovalRequest{Content: testutil.StringPtr("Your invoice")})
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "Only text messages can be edited")
+
+	// Nothing was sent or reviewed along the way
+	assert.Len(t, mockServer.sentMessages, 1)
+	var pending int64
+	app.DB.Model(&models.MessageApproval{}).Where("organization_id = ? AND status = ?", org.ID, models.ApprovalStatusPending).Count(&pending)
+	assert.Equal(t, int64(2), pending)
+}
+
+func TestApp_ApprovalMode_Stats(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	supervisor := createTestUser(t, app, org.ID, uniqueEmail("approval-stats"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	agent := createTestAgent(t, app, org.ID)
+	require.NoError(t, app.DB.Model(agent).Update("requires_approval", true).Error)
+
+	edited := heldApproval(t, app, org.ID, agent.ID, contact.ID, "First")
+	rejected := heldApproval(t, app, org.ID, agent.ID, contact.ID, "Second")
+	heldApproval(t, app, org.ID, agent.ID, contact.ID, "Third")
+
+	req := reviewApproval(t, app.ApproveMessage, org.ID, supervisor.ID, edited.ID.String(), handlers.ReviewMessageApprovalRequest{Content: testutil.StringPtr("First, edited")})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	req = reviewApproval(t, app.RejectMessage, org.ID, supervisor.ID, rejected.ID.String(), nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, supervisor.ID)
+	require.NoError(t, app.GetMessageApprovalStats(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var stats handlers.MessageApprovalStats
+	testutil.ParseEnvelopeResponse(t, req, &stats)
+	assert.Equal(t, int64(1), stats.Pending)
+	assert.Equal(t, int64(1), stats.Approved)
+	assert.Equal(t, int64(1), stats.Rejected)
+	assert.Equal(t, int64(1), stats.Edited)
+	require.Len(t, stats.ReviewerLatencies, 1)
+	assert.Equal(t, supervisor.ID, stats.ReviewerLatencies[0].ReviewerID)
+	assert.Equal(t, int64(2), stats.ReviewerLatencies[0].Reviewed)
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, supervisor.ID)
+	testutil.SetQueryParam(req, "from", "yesterday")
+	require.NoError(t, app.GetMessageApprovalStats(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// Bounds of the archive_after_days organization setting; 0 disables archiving
+	minArchiveAfterDays = 30
+	maxArchiveAfterDays = 3650
+
+	// messageArchiveBatchSize messages are moved per transaction, and at most
+	// messageArchiveMaxBatches batches per organization per run so a large backlog is
+	// worked off over several runs instead of holding the processor
+	messageArchiveBatchSize  = 1000
+	messageArchiveMaxBatches = 50
+)
+
+// archiveAfterDaysFromSettings returns the organization's archive_after_days setting
+func archiveAfterDaysFromSettings(settings models.JSONB) int {
+	if v, ok := settings["archive_after_days"].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// validateArchiveAfterDays checks an archive_after_days setting
+func validateArchiveAfterDays(days int) error {
+	if days != 0 && (days < minArchiveAfterDays || days > maxArchiveAfterDays) {
+		return fmt.Errorf("must be 0 or between %d and %d days", minArchiveAfterDays, maxArchiveAfterDays)
+	}
+	return nil
+}
+
+// archiveMessages moves up to limit messages of an organization created before cutoff
+// from messages to archived_messages, returning how many were moved
+func (a *App) archiveMessages(orgID uuid.UUID, cutoff time.Time, limit int) (int64, error) {
+	// Columns are listed explicitly since their order in the two tables can differ
+	stmt := &gorm.Statement{DB: a.DB}
+	if err := stmt.Parse(&models.Message{}); err != nil {
+		return 0, err
+	}
+	columns := strings.Join(stmt.Schema.DBNames, ", ")
+
+	result := a.DB.Exec(`WITH moved AS (
+			DELETE FROM messages WHERE id IN (
+				SELECT id FROM messages WHERE organization_id = ? AND created_at < ? ORDER BY created_at LIMIT ?
+			) RETURNING `+columns+`
+		)
+		INSERT INTO archived_messages (`+columns+`, archived_at) SELECT `+columns+`, ? FROM moved`,
+		orgID, cutoff, limit, time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// MessageArchiveProcessor periodically moves messages older than each organization's
+// archive_after_days setting out of the messages table
+type MessageArchiveProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewMessageArchiveProcessor creates a new message archive processor
+func NewMessageArchiveProcessor(app *App, interval time.Duration) *MessageArchiveProcessor {
+	return &MessageArchiveProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the message archive loop
+func (p *MessageArchiveProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Message archive processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Message archive processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Message archive processor stopped")
+			return
+		case <-ticker.C:
+			p.archiveOldMessages()
+		}
+	}
+}
+
+// Stop stops the message archive processor
+func (p *MessageArchiveProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// archiveOldMessages archives old messages of every organization with archiving enabled
+func (p *MessageArchiveProcessor) archiveOldMessages() {
+	var orgs []models.Organization
+	if err := p.app.DB.Select("id", "settings").Find(&orgs).Error; err != nil {
+		p.app.Log.Error("Failed to load organizations for message archiving", "error", err)
+		return
+	}
+
+	for _, org := range orgs {
+		days := archiveAfterDaysFromSettings(org.Settings)
+		if days <= 0 {
+			continue
+		}
+		cutoff := time.Now().AddDate(0, 0, -days)
+
+		var total int64
+		for i := 0; i < messageArchiveMaxBatches; i++ {
+			moved, err := p.app.archiveMessages(org.ID, cutoff, messageArchiveBatchSize)
+			if err != nil {
+				p.app.Log.Error("Failed to archive messages", "error", err, "organization_id", org.ID)
+				break
+			}
+			total += moved
+			if moved < messageArchiveBatchSize {
+				break
+			}
+		}
+		if total > 0 {
+			p.app.Log.Info("Archived messages", "organization_id", org.ID, "count", total)
+		}
+	}
+}
+
+// GetArchivedMessages returns archived messages of a contact, newest first in pages of
+// limit, in chronological order like GetMessages. before_id is the oldest message already
+// loaded, which can be a regular or an archived message.
+func (a *App) GetArchivedMessages(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+	if a.HasRestrictedDataAccess(userID, orgID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, restrictedDataAccessMessage, nil, "")
+	}
+
+	hasContactsReadPermission := a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead)
+
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	if !hasContactsReadPermission {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	// Agents limited to the current conversation never see archived history
+	if !hasContactsReadPermission {
+		if settings, err := a.getChatbotSettingsCached(orgID, ""); err == nil && settings.AgentAssignment.CurrentConversationOnly {
+			return r.SendEnvelope(map[string]any{
+				"messages": []MessageResponse{},
+				"has_more": false,
+			})
+		}
+	}
+
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	msgQuery := a.archivedMessagesQuery(r, orgID, &contact)
+	if beforeID, err := uuid.Parse(string(r.RequestCtx.QueryArgs().Peek("before_id"))); err == nil {
+		var before models.Message
+		if err := a.DB.Select("created_at").Where("id = ? AND organization_id = ?", beforeID, orgID).First(&before).Error; err != nil {
+			var archived models.ArchivedMessage
+			if err := a.DB.Select("created_at").Where("id = ? AND organization_id = ?", beforeID, orgID).First(&archived).Error; err == nil {
+				before = archived.Message
+			}
+		}
+		if !before.CreatedAt.IsZero() {
+			msgQuery = msgQuery.Where("created_at < ?", before.CreatedAt)
+		}
+	}
+
+	var archived []models.ArchivedMessage
+	if err := msgQuery.Order("created_at DESC").Limit(limit).Find(&archived).Error; err != nil {
+		a.Log.Error("Failed to list archived messages", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list archived messages", nil, "")
+	}
+
+	// Reverse to chronological order, attaching the replied-to messages, which are
+	// usually archived as well
+	messages := make([]models.Message, len(archived))
+	var replyIDs []uuid.UUID
+	for i := range archived {
+		messages[len(archived)-1-i] = archived[i].Message
+		if archived[i].ReplyToMessageID != nil {
+			replyIDs = append(replyIDs, *archived[i].ReplyToMessageID)
+		}
+	}
+	if len(replyIDs) > 0 {
+		replies := map[uuid.UUID]*models.Message{}
+		var archivedReplies []models.ArchivedMessage
+		a.DB.Where("id IN ?", replyIDs).Find(&archivedReplies)
+		for i := range archivedReplies {
+			replies[archivedReplies[i].ID] = &archivedReplies[i].Message
+		}
+		var liveReplies []models.Message
+		a.DB.Where("id IN ?", replyIDs).Find(&liveReplies)
+		for i := range liveReplies {
+			replies[liveReplies[i].ID] = &liveReplies[i]
+		}
+		for i := range messages {
+			if messages[i].ReplyToMessageID != nil {
+				messages[i].ReplyToMessage = replies[*messages[i].ReplyToMessageID]
+			}
+		}
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"messages": a.buildMessagesResponse(messages),
+		"has_more": len(archived) == limit,
+	})
+}
+
+// archivedMessagesQuery scopes archived_messages to a contact's history, honouring the
+// same whatsapp_account and all_accounts query parameters as GetMessages
+func (a *App) archivedMessagesQuery(r *fastglue.Request, orgID uuid.UUID, contact *models.Contact) *gorm.DB {
+	query := a.DB.Model(&models.ArchivedMessage{}).Where("contact_id = ?", contact.ID)
+	if string(r.RequestCtx.QueryArgs().Peek("all_accounts")) == "true" {
+		query = a.DB.Model(&models.ArchivedMessage{}).Where("contact_id IN ?", a.linkedContactIDs(orgID, contact))
+	}
+	if account := string(r.RequestCtx.QueryArgs().Peek("whatsapp_account")); account != "" {
+		query = query.Where("whats_app_account = ?", account)
+	}
+	return query
+}
+
+// hasArchivedMessages reports whether a contact's history continues in archived_messages
+func (a *App) hasArchivedMessages(r *fastglue.Request, orgID uuid.UUID, contact *models.Contact) bool {
+	var ids []uuid.UUID
+	a.archivedMessagesQuery(r, orgID, contact).Limit(1).Pluck("id", &ids)
+	return len(ids) > 0
+}
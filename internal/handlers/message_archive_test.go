@@ -0,0 +1,139 @@
+package handlers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_MessageArchive(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("archive"), "password", &role.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "archive-account")
+	contact := createTestContact(t, app, org.ID)
+
+	now := time.Now()
+	createdAt := []time.Time{now.AddDate(0, 0, -200), now.AddDate(0, 0, -100), now.AddDate(0, 0, -1)}
+	ids := make([]uuid.UUID, len(createdAt))
+	for i, at := range createdAt {
+		ids[i] = uuid.New()
+		require.NoError(t, app.DB.Create(&models.Message{
+			BaseModel:       models.BaseModel{ID: ids[i], CreatedAt: at},
+			OrganizationID:  org.ID,
+			WhatsAppAccount: account.Name,
+			ContactID:       contact.ID,
+			Direction:       models.DirectionIncoming,
+			MessageType:     models.MessageTypeText,
+			Content:         "message",
+		}).Error)
+	}
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{"archive_after_days": 30})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.UpdateOrganizationSettings(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	processor := handlers.NewMessageArchiveProcessor(app, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	go processor.Start(ctx)
+	require.Eventually(t, func() bool {
+		var count int64
+		app.DB.Model(&models.ArchivedMessage{}).Where("contact_id = ?", contact.ID).Count(&count)
+		return count == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	cancel()
+
+	var live []models.Message
+	require.NoError(t, app.DB.Where("contact_id = ?", contact.ID).Find(&live).Error)
+	require.Len(t, live, 1)
+	assert.Equal(t, ids[2], live[0].ID)
+
+	// The regular history points at the archive once it is exhausted
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.GetMessages(req))
+	var page struct {
+		Data struct {
+			Messages    []handlers.MessageResponse `json:"messages"`
+			HasArchived bool                       `json:"has_archived"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &page)
+	require.Len(t, page.Data.Messages, 1)
+	assert.True(t, page.Data.HasArchived)
+
+	// Older history loads one page at a time in chronological order
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	testutil.SetQueryParam(req, "before_id", ids[2].String())
+	testutil.SetQueryParam(req, "limit", "1")
+	require.NoError(t, app.GetArchivedMessages(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var archived struct {
+		Data struct {
+			Messages []handlers.MessageResponse `json:"messages"`
+			HasMore  bool                       `json:"has_more"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &archived)
+	require.Len(t, archived.Data.Messages, 1)
+	assert.Equal(t, ids[1], archived.Data.Messages[0].ID)
+	assert.True(t, archived.Data.HasMore)
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	testutil.SetQueryParam(req, "before_id", ids[1].String())
+	require.NoError(t, app.GetArchivedMessages(req))
+	testutil.ParseJSONResponse(t, req, &archived)
+	require.Len(t, archived.Data.Messages, 1)
+	assert.Equal(t, ids[0], archived.Data.Messages[0].ID)
+	assert.False(t, archived.Data.HasMore)
+
+	// A cursor from another organization is ignored rather than used to probe its timestamps
+	otherOrg := createTestOrganization(t, app)
+	otherContact := createTestContact(t, app, otherOrg.ID)
+	foreign := models.Message{
+		BaseModel:       models.BaseModel{ID: uuid.New(), CreatedAt: now.AddDate(-1, 0, 0)},
+		OrganizationID:  otherOrg.ID,
+		WhatsAppAccount: "other-account",
+		ContactID:       otherContact.ID,
+		Direction:       models.DirectionIncoming,
+		MessageType:     models.MessageTypeText,
+		Content:         "message",
+	}
+	require.NoError(t, app.DB.Create(&foreign).Error)
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	testutil.SetQueryParam(req, "before_id", foreign.ID.String())
+	require.NoError(t, app.GetArchivedMessages(req))
+	testutil.ParseJSONResponse(t, req, &archived)
+	assert.Len(t, archived.Data.Messages, 2)
+}
+
+func TestApp_MessageArchive_InvalidPeriod(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("archive-invalid"), "password", &role.ID, true)
+
+	for _, days := range []int{-1, 7, 5000} {
+		req := testutil.NewJSONRequest(t, map[string]interface{}{"archive_after_days": days})
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.UpdateOrganizationSettings(req))
+		assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req), days)
+	}
+}
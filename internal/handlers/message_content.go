@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// SharedLocation is a location pin a contact sent. Location messages store it as JSON
+// in their content.
+type SharedLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+}
+
+// Coordinates formats the location as "latitude,longitude"
+func (l SharedLocation) Coordinates() string {
+	return formatCoordinate(l.Latitude) + "," + formatCoordinate(l.Longitude)
+}
+
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// SharedContact is a contact card a contact sent. Contacts messages store a list of
+// them as JSON in their content.
+type SharedContact struct {
+	Name   string   `json:"name"`
+	Phones []string `json:"phones,omitempty"`
+}
+
+// parseSharedLocation reads the location stored in a location message's content
+func parseSharedLocation(content string) *SharedLocation {
+	var loc SharedLocation
+	if err := json.Unmarshal([]byte(content), &loc); err != nil {
+		return nil
+	}
+	return &loc
+}
+
+// parseSharedContacts reads the contact cards stored in a contacts message's content
+func parseSharedContacts(content string) []SharedContact {
+	var contacts []SharedContact
+	if err := json.Unmarshal([]byte(content), &contacts); err != nil {
+		return nil
+	}
+	return contacts
+}
+
+// setSharedContent fills the structured location or contact cards of a message response
+func (m *MessageResponse) setSharedContent(msg models.Message) {
+	switch msg.MessageType {
+	case models.MessageTypeLocation:
+		m.Location = parseSharedLocation(msg.Content)
+	case models.MessageTypeContact:
+		m.Contacts = parseSharedContacts(msg.Content)
+	}
+}
+
+// storeLocation saves a shared location in a flow session's data: storeAs holds the
+// coordinates and storeAs_latitude, _longitude, _name and _address the details. They
+// are stored as strings so messages can use them as {{variables}}.
+func storeLocation(sessionData models.JSONB, storeAs string, loc *SharedLocation) {
+	sessionData[storeAs] = loc.Coordinates()
+	sessionData[storeAs+"_latitude"] = formatCoordinate(loc.Latitude)
+	sessionData[storeAs+"_longitude"] = formatCoordinate(loc.Longitude)
+	if loc.Name != "" {
+		sessionData[storeAs+"_name"] = loc.Name
+	}
+	if loc.Address != "" {
+		sessionData[storeAs+"_address"] = loc.Address
+	}
+}
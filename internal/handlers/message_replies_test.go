@@ -0,0 +1,72 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_SendMessage_Reply(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("reply-admin"), "password123", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	incoming := models.Message{
+		BaseModel:         models.BaseModel{ID: uuid.New()},
+		OrganizationID:    org.ID,
+		WhatsAppAccount:   account.Name,
+		ContactID:         contact.ID,
+		WhatsAppMessageID: "wamid.incoming-" + uuid.New().String()[:8],
+		Direction:         models.DirectionIncoming,
+		MessageType:       models.MessageTypeText,
+		Content:           "Where is my order?",
+		Status:            models.MessageStatusReceived,
+	}
+	require.NoError(t, app.DB.Create(&incoming).Error)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"type":                "text",
+		"content":             map[string]string{"body": "It ships today"},
+		"reply_to_message_id": incoming.ID.String(),
+	})
+	setAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.SendMessage(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data handlers.MessageResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.True(t, resp.Data.IsReply)
+	require.NotNil(t, resp.Data.ReplyToMessageID)
+	assert.Equal(t, incoming.ID.String(), *resp.Data.ReplyToMessageID)
+
+	// WhatsApp is told which message is quoted
+	require.Len(t, mockServer.sentMessages, 1)
+	assert.Equal(t, map[string]interface{}{"message_id": incoming.WhatsAppMessageID}, mockServer.sentMessages[0]["context"])
+
+	// A message of another contact can't be replied to
+	other := createTestContact(t, app, org.ID)
+	req = testutil.NewJSONRequest(t, map[string]interface{}{
+		"type":                "text",
+		"content":             map[string]string{"body": "Hi"},
+		"reply_to_message_id": incoming.ID.String(),
+	})
+	setAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", other.ID.String())
+	require.NoError(t, app.SendMessage(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+	assert.Len(t, mockServer.sentMessages, 1)
+}
@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_SendMessage_AgentSignature(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Updates(map[string]interface{}{
+		"sender_name": "Acme",
+		"signature":   "– {name}, {team}",
+	}).Error)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("signature-agent"), "password123", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	require.NoError(t, app.DB.Model(agent).Update("full_name", "Priya Shah").Error)
+
+	team := &models.Team{OrganizationID: org.ID, Name: "Support", IsActive: true}
+	require.NoError(t, app.DB.Create(team).Error)
+	require.NoError(t, app.DB.Create(&models.TeamMember{TeamID: team.ID, UserID: agent.ID, Role: models.TeamRoleAgent}).Error)
+
+	send := func(body string) string {
+		req := testutil.NewJSONRequest(t, map[string]interface{}{
+			"type":    "text",
+			"content": map[string]string{"body": body},
+		})
+		setAuthContext(req, org.ID, agent.ID)
+		testutil.SetPathParam(req, "id", contact.ID.String())
+		require.NoError(t, app.SendMessage(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		var resp struct {
+			Data handlers.MessageResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		var stored models.Message
+		require.NoError(t, app.DB.Where("id = ?", resp.Data.ID).First(&stored).Error)
+		return stored.Content
+	}
+	preview := func(signature *string) map[string]interface{} {
+		body := map[string]interface{}{"whatsapp_account": account.Name, "content": "Hi"}
+		if signature != nil {
+			body["signature"] = *signature
+		}
+		req := testutil.NewJSONRequest(t, body)
+		setAuthContext(req, org.ID, agent.ID)
+		require.NoError(t, app.PreviewSignature(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp map[string]interface{}
+		testutil.ParseEnvelopeResponse(t, req, &resp)
+		return resp
+	}
+
+	// The account signature, filled in with the agent and their team
+	assert.Equal(t, "It ships today\n– Priya Shah, Support", send("It ships today"))
+
+	// A team signature replaces the account's
+	require.NoError(t, app.DB.Model(team).Update("signature", "{first_name} at {business}").Error)
+	resp := preview(nil)
+	assert.Equal(t, "Priya at Acme", resp["signature"])
+	assert.Equal(t, "team", resp["source"])
+	assert.Equal(t, "Hi\nPriya at Acme", resp["message"])
+
+	// The user's own signature replaces both, and the account decides where it goes
+	require.NoError(t, app.DB.Model(account).Update("signature_position", models.SignaturePositionPrepend).Error)
+	req := testutil.NewJSONRequest(t, map[string]interface{}{"signature": "*{first_name}:*"})
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.UpdateMySignature(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, "*Priya:*\nSee you soon", send("See you soon"))
+
+	// An unsaved signature can be previewed
+	draft := "Cheers, {name}"
+	resp = preview(&draft)
+	assert.Equal(t, "Cheers, Priya Shah\nHi", resp["message"])
+
+	// "-" turns signatures off for the user
+	req = testutil.NewJSONRequest(t, map[string]interface{}{"signature": "-"})
+	setAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.UpdateMySignature(req))
+	assert.Equal(t, "Plain", send("Plain"))
+	assert.Equal(t, "", preview(nil)["source"])
+}
+
+func TestApp_UpdateMySignature_TooLong(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("signature-long"), "password", nil, true)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{"signature": strings.Repeat("a", 201)})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.UpdateMySignature(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	var stored models.User
+	require.NoError(t, app.DB.Where("id = ?", user.ID).First(&stored).Error)
+	assert.Empty(t, stored.Signature)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+// MessageStatusEntry is one step of a message's delivery timeline
+type MessageStatusEntry struct {
+	Status    models.MessageStatus `json:"status"`
+	Timestamp time.Time            `json:"timestamp"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// messageStatusRank orders delivery statuses so that late or out-of-order webhooks
+// never move a message's current status backwards
+var messageStatusRank = map[models.MessageStatus]int{
+	models.MessageStatusPending:   0,
+	models.MessageStatusSent:      1,
+	models.MessageStatusDelivered: 2,
+	models.MessageStatusRead:      3,
+}
+
+// recordMessageStatus adds a transition to a message's status history. Returns false if
+// the transition was already recorded, e.g. because Meta redelivered the webhook.
+func (a *App) recordMessageStatus(msg *models.Message, status models.MessageStatus, occurredAt time.Time, errMsg string) bool {
+	event := models.MessageStatusEvent{
+		OrganizationID: msg.OrganizationID,
+		MessageID:      msg.ID,
+		Status:         status,
+		ErrorMessage:   errMsg,
+		OccurredAt:     occurredAt,
+	}
+	result := a.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if result.Error != nil {
+		// History is best effort; never drop the status update itself
+		a.Log.Error("Failed to record message status", "error", result.Error, "message_id", msg.ID, "status", status)
+		return true
+	}
+	return result.RowsAffected > 0
+}
+
+// loadStatusHistory returns the status timelines of the given messages, oldest first
+func (a *App) loadStatusHistory(messageIDs []uuid.UUID) map[uuid.UUID][]MessageStatusEntry {
+	history := make(map[uuid.UUID][]MessageStatusEntry)
+	if len(messageIDs) == 0 {
+		return history
+	}
+
+	var events []models.MessageStatusEvent
+	if err := a.DB.Where("message_id IN ?", messageIDs).Order("occurred_at ASC, created_at ASC").Find(&events).Error; err != nil {
+		a.Log.Error("Failed to load message status history", "error", err)
+		return history
+	}
+	for _, e := range events {
+		history[e.MessageID] = append(history[e.MessageID], MessageStatusEntry{
+			Status:    e.Status,
+			Timestamp: e.OccurredAt,
+			Error:     e.ErrorMessage,
+		})
+	}
+	return history
+}
+
+// parseWebhookTimestamp converts a Meta webhook timestamp (Unix seconds) to a time,
+// falling back to now when it is missing or malformed
+func parseWebhookTimestamp(ts string) time.Time {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Now()
+	}
+	return time.Unix(secs, 0)
+}
+
+// LatencyStats summarizes the time between two delivery statuses, in seconds
+type LatencyStats struct {
+	From  models.MessageStatus `json:"from"`
+	To    models.MessageStatus `json:"to"`
+	Count int                  `json:"count"`
+	Avg   float64              `json:"avg"`
+	P50   float64              `json:"p50"`
+	P90   float64              `json:"p90"`
+	P95   float64              `json:"p95"`
+	P99   float64              `json:"p99"`
+}
+
+// deliveryLatencySteps are the status pairs reported by GetDeliveryLatency
+var deliveryLatencySteps = [][2]models.MessageStatus{
+	{models.MessageStatusAccepted, models.MessageStatusSent},
+	{models.MessageStatusAccepted, models.MessageStatusDelivered},
+	{models.MessageStatusDelivered, models.MessageStatusRead},
+}
+
+// GetDeliveryLatency returns latency percentiles between delivery statuses for outgoing
+// messages accepted in the period
+func (a *App) GetDeliveryLatency(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	periodStart, periodEnd, err := parseAnalyticsPeriod(r, a.getOrgLocation(orgID))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	account := string(r.RequestCtx.QueryArgs().Peek("whatsapp_account"))
+
+	stats := make([]LatencyStats, 0, len(deliveryLatencySteps))
+	for _, step := range deliveryLatencySteps {
+		query := a.DB.Table("message_status_events AS f").
+			Select("EXTRACT(EPOCH FROM (t.occurred_at - f.occurred_at)) AS seconds").
+			Joins("JOIN message_status_events AS t ON t.message_id = f.message_id AND t.status = ?", step[1]).
+			Where("f.organization_id = ? AND f.status = ? AND f.occurred_at BETWEEN ? AND ?", orgID, step[0], periodStart, periodEnd).
+			Where("t.occurred_at >= f.occurred_at")
+		if account != "" {
+			query = query.Joins("JOIN messages AS m ON m.id = f.message_id").Where("m.whats_app_account = ?", account)
+		}
+
+		var seconds []float64
+		if err := query.Pluck("seconds", &seconds).Error; err != nil {
+			a.Log.Error("Failed to load delivery latency", "error", err, "from", step[0], "to", step[1])
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load delivery latency", nil, "")
+		}
+		stats = append(stats, summarizeLatency(step[0], step[1], seconds))
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"period_start": periodStart,
+		"period_end":   periodEnd,
+		"latency":      stats,
+	})
+}
+
+// summarizeLatency computes the average and percentiles of latencies in seconds
+func summarizeLatency(from, to models.MessageStatus, seconds []float64) LatencyStats {
+	stats := LatencyStats{From: from, To: to, Count: len(seconds)}
+	if len(seconds) == 0 {
+		return stats
+	}
+
+	sorted := append([]float64(nil), seconds...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, s := range sorted {
+		sum += s
+	}
+	stats.Avg = sum / float64(len(sorted))
+	stats.P50 = percentile(sorted, 50)
+	stats.P90 = percentile(sorted, 90)
+	stats.P95 = percentile(sorted, 95)
+	stats.P99 = percentile(sorted, 99)
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating between
+// the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lower)
+	return sorted[lower] + frac*(sorted[lower+1]-sorted[lower])
+}
@@ -0,0 +1,196 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// postStatusWebhook delivers a Meta status webhook for a message and waits until its
+// status history has the expected number of entries
+func postStatusWebhook(t *testing.T, app *handlers.App, msg *models.Message, status string, at time.Time, wantEvents int64) {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+		"messaging_product":"whatsapp","metadata":{"phone_number_id":"phone-123"},
+		"statuses":[{"id":%q,"status":%q,"timestamp":%q,"recipient_id":"15551234567"}]}}]}]}`,
+		msg.WhatsAppMessageID, status, strconv.FormatInt(at.Unix(), 10))
+
+	req := testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.Request.SetBody([]byte(body))
+	require.NoError(t, app.WebhookHandler(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	require.Eventually(t, func() bool {
+		var count int64
+		app.DB.Model(&models.MessageStatusEvent{}).Where("message_id = ?", msg.ID).Count(&count)
+		return count == wantEvents
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestApp_MessageStatusHistory(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+
+	sent, err := app.SendOutgoingMessage(testutil.TestContext(t), handlers.OutgoingMessageRequest{
+		Account: account,
+		Contact: contact,
+		Type:    models.MessageTypeText,
+		Content: "Hello",
+	}, handlers.ChatbotSendOptions())
+	require.NoError(t, err)
+
+	var msg models.Message
+	require.NoError(t, app.DB.First(&msg, sent.ID).Error)
+	require.NotEmpty(t, msg.WhatsAppMessageID)
+
+	base := time.Now().Add(time.Minute).Truncate(time.Second)
+	postStatusWebhook(t, app, &msg, "sent", base, 2)
+	// Read arrives before delivered; the current status must not move backwards
+	postStatusWebhook(t, app, &msg, "read", base.Add(30*time.Second), 3)
+	postStatusWebhook(t, app, &msg, "delivered", base.Add(2*time.Second), 4)
+	// A redelivered webhook is ignored
+	postStatusWebhook(t, app, &msg, "delivered", base.Add(2*time.Second), 4)
+
+	require.NoError(t, app.DB.First(&msg, msg.ID).Error)
+	assert.Equal(t, models.MessageStatusRead, msg.Status)
+
+	// The timeline is exposed on the message list, oldest first
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("status-admin"), "password123", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.GetMessages(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data struct {
+			Messages []handlers.MessageResponse `json:"messages"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	require.Len(t, resp.Data.Messages, 1)
+
+	var statuses []models.MessageStatus
+	for _, e := range resp.Data.Messages[0].StatusHistory {
+		statuses = append(statuses, e.Status)
+	}
+	assert.Equal(t, []models.MessageStatus{
+		models.MessageStatusAccepted, models.MessageStatusSent, models.MessageStatusDelivered, models.MessageStatusRead,
+	}, statuses)
+
+	// Delivery latency percentiles
+	req = testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	req.RequestCtx.QueryArgs().Set("from", time.Now().AddDate(0, 0, -1).Format("2006-01-02"))
+	req.RequestCtx.QueryArgs().Set("to", time.Now().AddDate(0, 0, 1).Format("2006-01-02"))
+	require.NoError(t, app.GetDeliveryLatency(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var latency struct {
+		Data struct {
+			Latency []handlers.LatencyStats `json:"latency"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &latency)
+	require.Len(t, latency.Data.Latency, 3)
+
+	readStats := latency.Data.Latency[2]
+	assert.Equal(t, models.MessageStatusDelivered, readStats.From)
+	assert.Equal(t, models.MessageStatusRead, readStats.To)
+	assert.Equal(t, 1, readStats.Count)
+	assert.InDelta(t, 28, readStats.P50, 0.001)
+	assert.InDelta(t, 28, readStats.P99, 0.001)
+}
+
+func TestApp_MessageStatusWebhooks(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+
+	var mu sync.Mutex
+	var received []handlers.OutboundWebhookPayload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload handlers.OutboundWebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		received = append(received, payload)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	require.NoError(t, app.DB.Create(&models.Webhook{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: org.ID,
+		Name:           "crm",
+		URL:            receiver.URL,
+		Events:         models.StringArray{"message.delivered", "message.read"},
+		IsActive:       true,
+	}).Error)
+	app.InvalidateWebhooksCache(org.ID)
+
+	sent, err := app.SendOutgoingMessage(testutil.TestContext(t), handlers.OutgoingMessageRequest{
+		Account: account,
+		Contact: contact,
+		Type:    models.MessageTypeText,
+		Content: "Your order has shipped",
+	}, handlers.ChatbotSendOptions())
+	require.NoError(t, err)
+
+	var msg models.Message
+	require.NoError(t, app.DB.First(&msg, sent.ID).Error)
+
+	base := time.Now().Add(time.Minute).Truncate(time.Second)
+	postStatusWebhook(t, app, &msg, "sent", base, 2)
+	postStatusWebhook(t, app, &msg, "delivered", base.Add(time.Second), 3)
+	postStatusWebhook(t, app, &msg, "read", base.Add(5*time.Second), 4)
+	// A redelivered webhook isn't forwarded again
+	postStatusWebhook(t, app, &msg, "read", base.Add(5*time.Second), 4)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) >= 2
+	}, 2*time.Second, 20*time.Millisecond)
+	app.WaitForBackgroundTasks()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 2)
+	events := map[string]map[string]any{}
+	for _, p := range received {
+		events[p.Event] = p.Data.(map[string]any)
+	}
+	require.Contains(t, events, "message.delivered")
+	require.Contains(t, events, "message.read")
+	assert.Equal(t, msg.ID.String(), events["message.read"]["message_id"])
+	assert.Equal(t, contact.PhoneNumber, events["message.read"]["contact_phone"])
+	assert.Equal(t, "read", events["message.read"]["status"])
+	statusAt, err := time.Parse(time.RFC3339, events["message.read"]["status_at"].(string))
+	require.NoError(t, err)
+	assert.True(t, statusAt.Equal(base.Add(5*time.Second)))
+}
@@ -2,17 +2,22 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/consent"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/shridarpatil/whatomate/internal/plugins"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
 )
 
 // ============================================================================
@@ -34,7 +39,7 @@ type OutgoingMessageRequest struct {
 	// Media messages (image, video, audio, document)
 	MediaID       string // WhatsApp media ID (if already uploaded)
 	MediaData     []byte // Raw media data (if upload needed)
-	MediaURL      string // Local media URL (for storage)
+	MediaURL      string // Local media URL (for storage); streamed for upload when MediaData and MediaID are empty
 	MediaMimeType string
 	MediaFilename string
 	Caption       string
@@ -47,8 +52,9 @@ type OutgoingMessageRequest struct {
 	URL             string            // For CTA URL button
 
 	// Template messages
-	Template   *models.Template
-	BodyParams map[string]string // Parameter name -> value (supports both named and positional)
+	Template       *models.Template
+	BodyParams     map[string]string      // Parameter name -> value (supports both named and positional)
+	FlowActionData map[string]interface{} // Initial data for a template FLOW button (token goes in FlowToken)
 
 	// WhatsApp Flow messages
 	FlowID          string // Meta Flow ID
@@ -78,6 +84,9 @@ type MessageSendOptions struct {
 	// Async if true, sends in background goroutine and returns immediately
 	// Message is persisted before send, status updated after
 	Async bool
+
+	// SkipSignature sends the text without the agent signature of SentByUserID
+	SkipSignature bool
 }
 
 // DefaultSendOptions returns options suitable for agent UI sends
@@ -120,90 +129,86 @@ func SLASendOptions() MessageSendOptions {
 	}
 }
 
+// isSendBlocked reports whether a send failed because a country rule, consent requirement,
+// plugin or script refused it, rather than because of an internal error
+func isSendBlocked(err error) bool {
+	return errors.Is(err, phone.ErrCountryRestricted) || errors.Is(err, consent.ErrMissing) ||
+		errors.Is(err, plugins.ErrRejected) || errors.Is(err, ErrScriptRejected)
+}
+
 // SendOutgoingMessage is the unified method for sending all types of WhatsApp messages.
 // It handles: text, media (image/video/audio/document), interactive (buttons/list/cta_url), and template messages.
 func (a *App) SendOutgoingMessage(ctx context.Context, req OutgoingMessageRequest, opts MessageSendOptions) (*models.Message, error) {
-	// 1. Create message record
-	msg := a.createOutgoingMessage(req, opts)
+	// Enforce the organization's destination country rules before anything is recorded
+	if err := a.getOrgCountryRestrictions(req.Account.OrganizationID).Check(req.Contact.PhoneNumber); err != nil {
+		a.Log.Warn("Outgoing message blocked", "contact_id", req.Contact.ID, "error", err)
+		return nil, err
+	}
 
-	// Save to database
-	if err := a.DB.Create(msg).Error; err != nil {
-		a.Log.Error("Failed to create message", "error", err)
-		return nil, fmt.Errorf("failed to create message: %w", err)
+	// Marketing templates need the contact's opt-in when the organization requires it
+	if req.Type == models.MessageTypeTemplate {
+		if err := a.checkMarketingConsent(req.Account.OrganizationID, req.Contact.PhoneNumber, req.Template); err != nil {
+			a.Log.Warn("Outgoing message blocked", "contact_id", req.Contact.ID, "error", err)
+			return nil, err
+		}
 	}
 
-	// 2. Define the send function based on message type
-	sendFn := func(sendCtx context.Context) (string, error) {
-		waAccount := a.toWhatsAppAccount(req.Account)
+	// Sign the agent's text first, so plugins and scripts see the message as it is sent
+	a.applyAgentSignature(&req, opts)
 
-		switch req.Type {
-		case models.MessageTypeText:
-			return a.WhatsApp.SendTextMessage(sendCtx, waAccount, req.Contact.PhoneNumber, req.Content)
-
-		case models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeDocument:
-			// Upload media if MediaData is provided and MediaID is not set
-			mediaID := req.MediaID
-			if mediaID == "" && len(req.MediaData) > 0 {
-				var err error
-				mediaID, err = a.WhatsApp.UploadMedia(sendCtx, waAccount, req.MediaData, req.MediaMimeType, req.MediaFilename)
-				if err != nil {
-					return "", fmt.Errorf("failed to upload media: %w", err)
-				}
-			}
-			// Send the appropriate media type
-			switch req.Type {
-			case models.MessageTypeImage:
-				return a.WhatsApp.SendImageMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID, req.Caption)
-			case models.MessageTypeVideo:
-				return a.WhatsApp.SendVideoMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID, req.Caption)
-			case models.MessageTypeAudio:
-				return a.WhatsApp.SendAudioMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID)
-			default: // document
-				return a.WhatsApp.SendDocumentMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID, req.MediaFilename, req.Caption)
-			}
+	// Let the organization's plugins rewrite or reject the message
+	if err := a.runPreSendPlugins(ctx, &req, opts); err != nil {
+		a.Log.Warn("Outgoing message rejected by plugin", "contact_id", req.Contact.ID, "error", err)
+		return nil, err
+	}
 
-		case models.MessageTypeInteractive:
-			switch req.InteractiveType {
-			case "cta_url":
-				return a.WhatsApp.SendCTAURLButton(sendCtx, waAccount, req.Contact.PhoneNumber, req.BodyText, req.ButtonText, req.URL)
-			default: // "button" or "list"
-				return a.WhatsApp.SendInteractiveButtons(sendCtx, waAccount, req.Contact.PhoneNumber, req.BodyText, req.Buttons)
-			}
+	// Then the organization's pre-send scripts
+	if err := a.runPreSendScripts(&req, opts); err != nil {
+		a.Log.Warn("Outgoing message rejected by script", "contact_id", req.Contact.ID, "error", err)
+		return nil, err
+	}
 
-		case models.MessageTypeTemplate:
-			if req.Template == nil {
-				return "", fmt.Errorf("template is required for template messages")
-			}
-			return a.WhatsApp.SendTemplateMessage(sendCtx, waAccount, req.Contact.PhoneNumber, req.Template.Name, req.Template.Language, req.BodyParams)
+	// Templates with a FLOW button need a token so the flow response can be matched back
+	if req.Type == models.MessageTypeTemplate && req.Template != nil && req.FlowToken == "" &&
+		whatsapp.FlowButtonIndex(req.Template.Buttons) >= 0 {
+		req.FlowToken = uuid.New().String()
+	}
 
-		case models.MessageTypeFlow:
-			if req.FlowID == "" {
-				return "", fmt.Errorf("flow ID is required for flow messages")
-			}
-			return a.WhatsApp.SendFlowMessage(sendCtx, waAccount, req.Contact.PhoneNumber, req.FlowID, req.FlowHeader, req.BodyText, req.FlowCTA, req.FlowToken, req.FlowFirstScreen)
+	// 1. Create the message record along with its outbox entry, so a send that fails
+	// or is cut short is retried
+	msg := a.createOutgoingMessage(req, opts)
+	a.trackCTAURLButton(&req, msg)
+	entry, err := newOutboxEntry(msg, req, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create outbox entry: %w", err)
+	}
 
-		default:
-			return "", fmt.Errorf("unsupported message type: %s", req.Type)
+	if err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(msg).Error; err != nil {
+			return err
 		}
+		return tx.Create(entry).Error
+	}); err != nil {
+		a.Log.Error("Failed to create message", "error", err)
+		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
 
-	// 3. Execute send (async or sync)
+	// 2. Make the first attempt right away (async or sync); the outbox processor takes
+	// over the retries
 	if opts.Async {
 		a.wg.Add(1)
 		go func() {
 			defer a.wg.Done()
-			asyncCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			asyncCtx, cancel := context.WithTimeout(context.Background(), outboxSendTimeout)
 			defer cancel()
 
-			wamid, sendErr := sendFn(asyncCtx)
-			a.finalizeMessageSend(msg, req, opts, wamid, sendErr)
+			a.deliverOutboxMessage(asyncCtx, entry, msg, req, opts)
 		}()
 	} else {
-		wamid, err := sendFn(ctx)
-		a.finalizeMessageSend(msg, req, opts, wamid, err)
+		a.deliverOutboxMessage(ctx, entry, msg, req, opts)
 	}
 
-	// 4. Immediate actions (before send completes for async)
+	// 3. Immediate actions (before send completes for async)
 	if opts.BroadcastWebSocket {
 		a.broadcastNewMessage(req.Account.OrganizationID, msg, req.Contact)
 	}
@@ -223,14 +228,101 @@ func (a *App) SendOutgoingMessage(ctx context.Context, req OutgoingMessageReques
 // Internal Helpers
 // ============================================================================
 
+// sendOutgoingRequest makes the WhatsApp API call for a message and returns its
+// WhatsApp message ID
+func (a *App) sendOutgoingRequest(sendCtx context.Context, req OutgoingMessageRequest) (string, error) {
+	waAccount := a.toWhatsAppAccount(req.Account)
+
+	// Quote the replied-to message so the contact sees the reply threaded under it
+	var opts []whatsapp.SendOption
+	if req.ReplyToMessage != nil {
+		opts = append(opts, whatsapp.ReplyTo(req.ReplyToMessage.WhatsAppMessageID))
+	}
+
+	switch req.Type {
+	case models.MessageTypeText:
+		return a.WhatsApp.SendTextMessage(sendCtx, waAccount, req.Contact.PhoneNumber, req.Content, opts...)
+
+	case models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeDocument:
+		// Upload media if MediaData is provided and MediaID is not set
+		mediaID := req.MediaID
+		if mediaID == "" && len(req.MediaData) > 0 {
+			var err error
+			mediaID, err = a.WhatsApp.UploadMedia(sendCtx, waAccount, req.MediaData, req.MediaMimeType, req.MediaFilename)
+			if err != nil {
+				return "", fmt.Errorf("failed to upload media: %w", err)
+			}
+		} else if mediaID == "" && req.MediaURL != "" {
+			var err error
+			mediaID, err = a.uploadStoredMedia(sendCtx, req.Account.OrganizationID, waAccount, req.MediaURL, req.MediaMimeType, req.MediaFilename)
+			if err != nil {
+				return "", fmt.Errorf("failed to upload media: %w", err)
+			}
+		}
+		// Send the appropriate media type
+		switch req.Type {
+		case models.MessageTypeImage:
+			return a.WhatsApp.SendImageMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID, req.Caption, opts...)
+		case models.MessageTypeVideo:
+			return a.WhatsApp.SendVideoMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID, req.Caption, opts...)
+		case models.MessageTypeAudio:
+			return a.WhatsApp.SendAudioMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID, opts...)
+		default: // document
+			return a.WhatsApp.SendDocumentMessage(sendCtx, waAccount, req.Contact.PhoneNumber, mediaID, req.MediaFilename, req.Caption, opts...)
+		}
+
+	case models.MessageTypeInteractive:
+		switch req.InteractiveType {
+		case "cta_url":
+			return a.WhatsApp.SendCTAURLButton(sendCtx, waAccount, req.Contact.PhoneNumber, req.BodyText, req.ButtonText, req.URL, opts...)
+		default: // "button" or "list"
+			return a.WhatsApp.SendInteractiveButtons(sendCtx, waAccount, req.Contact.PhoneNumber, req.BodyText, req.Buttons, opts...)
+		}
+
+	case models.MessageTypeTemplate:
+		if req.Template == nil {
+			return "", fmt.Errorf("template is required for template messages")
+		}
+		if idx := whatsapp.FlowButtonIndex(req.Template.Buttons); idx >= 0 {
+			var components []map[string]interface{}
+			if len(req.BodyParams) > 0 {
+				components = append(components, map[string]interface{}{
+					"type":       "body",
+					"parameters": whatsapp.BodyParameters(req.BodyParams),
+				})
+			}
+			components = append(components, whatsapp.FlowButtonComponent(idx, req.FlowToken, req.FlowActionData))
+			return a.WhatsApp.SendTemplateMessageWithComponents(sendCtx, waAccount, req.Contact.PhoneNumber, req.Template.Name, req.Template.Language, components, opts...)
+		}
+		return a.WhatsApp.SendTemplateMessage(sendCtx, waAccount, req.Contact.PhoneNumber, req.Template.Name, req.Template.Language, req.BodyParams, opts...)
+
+	case models.MessageTypeFlow:
+		if req.FlowID == "" {
+			return "", fmt.Errorf("flow ID is required for flow messages")
+		}
+		return a.WhatsApp.SendFlowMessage(sendCtx, waAccount, req.Contact.PhoneNumber, req.FlowID, req.FlowHeader, req.BodyText, req.FlowCTA, req.FlowToken, req.FlowFirstScreen, opts...)
+
+	default:
+		return "", fmt.Errorf("unsupported message type: %s", req.Type)
+	}
+}
+
 // toWhatsAppAccount converts models.WhatsAppAccount to whatsapp.Account
+// If the organization's encryption key is unavailable the token is left empty, so calls
+// with the account fail instead of sending the encrypted token.
 func (a *App) toWhatsAppAccount(account *models.WhatsAppAccount) *whatsapp.Account {
+	accessToken, err := a.accountAccessToken(account)
+	if err != nil {
+		a.Log.Error("Failed to decrypt access token", "error", err, "account", account.Name)
+	}
 	return &whatsapp.Account{
 		PhoneID:     account.PhoneID,
 		BusinessID:  account.BusinessID,
 		AppID:       account.AppID,
 		APIVersion:  account.APIVersion,
-		AccessToken: account.AccessToken,
+		AccessToken: accessToken,
+
+		MessagesPerSecond: account.MessagesPerSecond,
 	}
 }
 
@@ -275,6 +367,9 @@ func (a *App) createOutgoingMessage(req OutgoingMessageRequest, opts MessageSend
 				"template_name": req.Template.Name,
 				"template_id":   req.Template.ID.String(),
 			}
+			if req.FlowToken != "" {
+				msg.Metadata["flow_token"] = req.FlowToken
+			}
 		}
 	}
 
@@ -324,10 +419,16 @@ func (a *App) buildInteractiveData(req OutgoingMessageRequest) models.JSONB {
 // finalizeMessageSend updates message status and triggers post-send actions
 func (a *App) finalizeMessageSend(msg *models.Message, req OutgoingMessageRequest, opts MessageSendOptions, wamid string, err error) {
 	if err != nil {
+		errorCode := whatsapp.ErrorCodeOf(err)
 		a.DB.Model(msg).Updates(map[string]any{
 			"status":        models.MessageStatusFailed,
 			"error_message": err.Error(),
+			"error_code":    errorCode,
 		})
+		a.recordMessageStatus(msg, models.MessageStatusFailed, time.Now(), err.Error())
+		if opts.DispatchWebhook {
+			a.dispatchMessageFailedWebhook(msg, errorCode, err.Error())
+		}
 		a.Log.Error("Failed to send message", "error", err, "message_id", msg.ID, "type", msg.MessageType)
 		return
 	}
@@ -336,8 +437,13 @@ func (a *App) finalizeMessageSend(msg *models.Message, req OutgoingMessageReques
 		"status":               models.MessageStatusSent,
 		"whats_app_message_id": wamid,
 	})
+	a.recordMessageStatus(msg, models.MessageStatusAccepted, time.Now(), "")
 	a.Log.Info("Message sent", "message_id", msg.ID, "wa_message_id", wamid, "type", msg.MessageType)
 
+	if req.Type == models.MessageTypeTemplate && req.Template != nil {
+		a.recordTemplateUsage(req.Template.ID)
+	}
+
 	// Dispatch webhook for successful send
 	if opts.DispatchWebhook {
 		a.dispatchMessageSentWebhook(req.Account, req.Contact, msg)
@@ -424,6 +530,58 @@ func (a *App) dispatchMessageSentWebhook(account *models.WhatsAppAccount, contac
 	})
 }
 
+// dispatchMessageFailedWebhook dispatches the message.failed webhook with the Meta error
+// code and its catalog category
+func (a *App) dispatchMessageFailedWebhook(msg *models.Message, errorCode int, errorMessage string) {
+	var contact models.Contact
+	a.DB.Where("id = ?", msg.ContactID).First(&contact)
+
+	a.DispatchWebhook(msg.OrganizationID, models.WebhookEventMessageFailed, MessageEventData{
+		MessageID:       msg.ID.String(),
+		ContactID:       msg.ContactID.String(),
+		ContactPhone:    contact.PhoneNumber,
+		ContactName:     contact.ProfileName,
+		MessageType:     msg.MessageType,
+		Content:         msg.Content,
+		WhatsAppAccount: msg.WhatsAppAccount,
+		Direction:       models.DirectionOutgoing,
+		Status:          models.MessageStatusFailed,
+		ErrorCode:       errorCode,
+		ErrorCategory:   errorCategory(errorCode),
+		ErrorMessage:    errorMessage,
+	})
+}
+
+// dispatchMessageStatusWebhook dispatches message.delivered or message.read when Meta
+// reports the status for an outgoing message
+func (a *App) dispatchMessageStatusWebhook(msg *models.Message, status models.MessageStatus, occurredAt time.Time) {
+	var event models.WebhookEvent
+	switch status {
+	case models.MessageStatusDelivered:
+		event = models.WebhookEventMessageDelivered
+	case models.MessageStatusRead:
+		event = models.WebhookEventMessageRead
+	default:
+		return
+	}
+
+	var contact models.Contact
+	a.DB.Where("id = ?", msg.ContactID).First(&contact)
+
+	a.DispatchWebhook(msg.OrganizationID, event, MessageEventData{
+		MessageID:       msg.ID.String(),
+		ContactID:       msg.ContactID.String(),
+		ContactPhone:    contact.PhoneNumber,
+		ContactName:     contact.ProfileName,
+		MessageType:     msg.MessageType,
+		Content:         msg.Content,
+		WhatsAppAccount: msg.WhatsAppAccount,
+		Direction:       models.DirectionOutgoing,
+		Status:          status,
+		StatusAt:        &occurredAt,
+	})
+}
+
 // updateContactLastMessage updates contact's last_message_at and preview
 func (a *App) updateContactLastMessage(contact *models.Contact, preview string) {
 	a.DB.Model(contact).Updates(map[string]any{
@@ -472,12 +630,14 @@ func (a *App) getMessagePreview(req OutgoingMessageRequest) string {
 
 // SendTemplateMessageRequest represents the request to send a template message
 type SendTemplateMessageRequest struct {
-	ContactID      string            `json:"contact_id"`
-	PhoneNumber    string            `json:"phone_number"`    // Alternative to contact_id - send to phone directly
-	TemplateName   string            `json:"template_name"`   // Template name
-	TemplateID     string            `json:"template_id"`     // Alternative: template UUID
-	TemplateParams map[string]string `json:"template_params"` // Named or positional params
-	AccountName    string            `json:"account_name"`    // Optional: specific WhatsApp account
+	ContactID      string                 `json:"contact_id"`
+	PhoneNumber    string                 `json:"phone_number"`     // Alternative to contact_id - send to phone directly
+	TemplateName   string                 `json:"template_name"`    // Template name
+	TemplateID     string                 `json:"template_id"`      // Alternative: template UUID
+	TemplateParams map[string]string      `json:"template_params"`  // Named or positional params
+	AccountName    string                 `json:"account_name"`     // Optional: specific WhatsApp account
+	FlowToken      string                 `json:"flow_token"`       // Optional: token for a FLOW button, generated if empty
+	FlowActionData map[string]interface{} `json:"flow_action_data"` // Optional: initial data for a FLOW button
 }
 
 // SendTemplateMessage sends a template message to a contact or phone number
@@ -519,6 +679,10 @@ func (a *App) SendTemplateMessage(r *fastglue.Request) error {
 		}
 	}
 
+	if template.ArchivedAt != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template is archived", nil, "")
+	}
+
 	// Check template is approved
 	if template.Status != "APPROVED" {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Template is not approved (status: %s)", template.Status), nil, "")
@@ -541,9 +705,16 @@ func (a *App) SendTemplateMessage(r *fastglue.Request) error {
 		phoneNumber = c.PhoneNumber
 	} else {
 		// Find or create contact from phone number
-		phoneNumber = req.PhoneNumber
+		normalized, err := phone.Normalize(req.PhoneNumber)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		if err := a.getOrgCountryRestrictions(orgID).Check(normalized); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+		}
+		phoneNumber = normalized
 		var c models.Contact
-		err := a.DB.Where("phone_number = ? AND organization_id = ?", phoneNumber, orgID).First(&c).Error
+		err = a.DB.Where("phone_number = ? AND organization_id = ?", phoneNumber, orgID).First(&c).Error
 		if err != nil {
 			// Contact not found, create new one
 			c = models.Contact{
@@ -556,6 +727,13 @@ func (a *App) SendTemplateMessage(r *fastglue.Request) error {
 				return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create contact", nil, "")
 			}
 			a.Log.Info("Contact created from API", "contact_id", c.ID, "phone", phoneNumber)
+			a.enrichContactWithScripts(&c)
+			a.enrichContactWithProviders(&c)
+			a.notifyPluginsContactCreated(plugins.ContactEvent{
+				OrganizationID: orgID,
+				ContactID:      c.ID,
+				PhoneNumber:    c.PhoneNumber,
+			})
 		}
 		contact = &c
 	}
@@ -602,13 +780,39 @@ func (a *App) SendTemplateMessage(r *fastglue.Request) error {
 		}
 	}
 
+	// Agents in approval mode wait for a supervisor; the template is sent as requested on approval
+	if a.userRequiresApproval(userID) && !a.canReviewMessages(userID, orgID) {
+		approval := models.MessageApproval{
+			OrganizationID:  orgID,
+			ContactID:       contact.ID,
+			WhatsAppAccount: account.Name,
+			RequestedByID:   userID,
+			MessageType:     models.MessageTypeTemplate,
+			Content:         replaceTemplateParams(template.BodyContent, req.TemplateParams),
+			TemplateID:      &template.ID,
+			Reason:          approvalReasonAgent,
+		}
+		if approval.TemplateData, err = templateSendToJSONB(&req); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template parameters", nil, "")
+		}
+		if err := a.createMessageApproval(&approval, contact); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to submit message for approval", nil, "")
+		}
+		return r.SendEnvelope(map[string]interface{}{
+			"pending_approval": true,
+			"approval":         buildMessageApprovalResponse(&approval),
+		})
+	}
+
 	// Send using unified message sender
 	msgReq := OutgoingMessageRequest{
-		Account:    &account,
-		Contact:    contact,
-		Type:       models.MessageTypeTemplate,
-		Template:   &template,
-		BodyParams: req.TemplateParams,
+		Account:        &account,
+		Contact:        contact,
+		Type:           models.MessageTypeTemplate,
+		Template:       &template,
+		BodyParams:     req.TemplateParams,
+		FlowToken:      req.FlowToken,
+		FlowActionData: req.FlowActionData,
 	}
 
 	opts := DefaultSendOptions()
@@ -617,6 +821,9 @@ func (a *App) SendTemplateMessage(r *fastglue.Request) error {
 	ctx := context.Background()
 	message, err := a.SendOutgoingMessage(ctx, msgReq, opts)
 	if err != nil {
+		if isSendBlocked(err) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+		}
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to send template message", nil, "")
 	}
 
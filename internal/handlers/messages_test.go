@@ -1079,3 +1079,44 @@ func TestResolveParams_WrongParamNames(t *testing.T) {
 	assert.Equal(t, "", result[0])
 	assert.Equal(t, "", result[1])
 }
+
+func TestApp_SendOutgoingMessage_TemplateFlowButton(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	template.Category = "UTILITY"
+	template.Buttons = models.JSONBArray{
+		map[string]interface{}{"type": "QUICK_REPLY", "text": "Not now"},
+		map[string]interface{}{"type": "FLOW", "text": "Book", "flow_id": "flow-1"},
+	}
+	require.NoError(t, app.DB.Save(template).Error)
+
+	msg, err := app.SendOutgoingMessage(testutil.TestContext(t), handlers.OutgoingMessageRequest{
+		Account:        account,
+		Contact:        contact,
+		Type:           models.MessageTypeTemplate,
+		Template:       template,
+		BodyParams:     map[string]string{"1": "Ann"},
+		FlowActionData: map[string]interface{}{"name": "Ann"},
+	}, handlers.ChatbotSendOptions())
+	require.NoError(t, err)
+
+	// A token is generated and kept on the message
+	token, _ := msg.Metadata["flow_token"].(string)
+	require.NotEmpty(t, token)
+
+	require.Len(t, mockServer.sentMessages, 1)
+	components := mockServer.sentMessages[0]["template"].(map[string]interface{})["components"].([]interface{})
+	require.Len(t, components, 2)
+	button := components[1].(map[string]interface{})
+	assert.Equal(t, "flow", button["sub_type"])
+	assert.Equal(t, "1", button["index"])
+	action := button["parameters"].([]interface{})[0].(map[string]interface{})["action"].(map[string]interface{})
+	assert.Equal(t, token, action["flow_token"])
+	assert.Equal(t, map[string]interface{}{"name": "Ann"}, action["flow_action_data"])
+}
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/chatnotify"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// notificationDedupTTL bounds how long a once-only notification is remembered across instances
+const notificationDedupTTL = 24 * time.Hour
+
+// NotificationChannelRequest represents the request body for creating/updating a channel
+type NotificationChannelRequest struct {
+	Name       string   `json:"name"`
+	Provider   string   `json:"provider"`
+	WebhookURL string   `json:"webhook_url"`
+	Events     []string `json:"events"`
+	IsActive   bool     `json:"is_active"`
+}
+
+// NotificationChannelResponse represents the API response for a channel. The webhook
+// URL is a credential, so only a masked hint is returned.
+type NotificationChannelResponse struct {
+	ID             uuid.UUID `json:"id"`
+	Name           string    `json:"name"`
+	Provider       string    `json:"provider"`
+	WebhookURLHint string    `json:"webhook_url_hint"`
+	Events         []string  `json:"events"`
+	IsActive       bool      `json:"is_active"`
+	CreatedAt      string    `json:"created_at"`
+	UpdatedAt      string    `json:"updated_at"`
+}
+
+// AvailableNotificationEvents returns the list of events that can be routed to channels
+var AvailableNotificationEvents = []map[string]string{
+	{"value": string(models.NotificationEventTransferQueued), "label": "Transfer Queued", "description": "When a conversation is waiting in the agent queue"},
+	{"value": string(models.NotificationEventSLABreached), "label": "SLA Breached", "description": "When a queued transfer misses its response deadline"},
+	{"value": string(models.NotificationEventCampaignCompleted), "label": "Campaign Finished", "description": "When a campaign has processed all recipients"},
+	{"value": string(models.NotificationEventTemplateRejected), "label": "Template Rejected", "description": "When Meta rejects a message template"},
+	{"value": string(models.NotificationEventUnansweredDigest), "label": "Unanswered Questions Digest", "description": "Every Monday, the questions the chatbot couldn't answer last week"},
+	{"value": string(models.NotificationEventAccountUnhealthy), "label": "Account Unhealthy", "description": "When too many webhook events or chatbot replies fail for a WhatsApp account"},
+}
+
+// ListNotificationChannels returns all Slack/Teams channels for the organization
+func (a *App) ListNotificationChannels(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var channels []models.NotificationChannel
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&channels).Error; err != nil {
+		a.Log.Error("Failed to list notification channels", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list notification channels", nil, "")
+	}
+
+	result := make([]NotificationChannelResponse, len(channels))
+	for i, ch := range channels {
+		result[i] = notificationChannelToResponse(ch)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"channels":         result,
+		"available_events": AvailableNotificationEvents,
+	})
+}
+
+// CreateNotificationChannel adds a Slack/Teams channel
+func (a *App) CreateNotificationChannel(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req NotificationChannelRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Name == "" || req.WebhookURL == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name and webhook_url are required", nil, "")
+	}
+	if msg := validateNotificationChannel(req.Provider, req.WebhookURL, req.Events); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	channel := models.NotificationChannel{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Provider:       req.Provider,
+		WebhookURL:     req.WebhookURL,
+		Events:         req.Events,
+		IsActive:       true,
+	}
+	if err := a.DB.Create(&channel).Error; err != nil {
+		a.Log.Error("Failed to create notification channel", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create notification channel", nil, "")
+	}
+
+	return r.SendEnvelope(notificationChannelToResponse(channel))
+}
+
+// UpdateNotificationChannel updates a channel; an empty webhook_url keeps the stored one
+func (a *App) UpdateNotificationChannel(r *fastglue.Request) error {
+	channel, err := a.loadNotificationChannel(r)
+	if err != nil || channel == nil {
+		return err
+	}
+
+	var req NotificationChannelRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.Name != "" {
+		channel.Name = req.Name
+	}
+	if req.Provider != "" {
+		channel.Provider = req.Provider
+	}
+	if req.WebhookURL != "" {
+		channel.WebhookURL = req.WebhookURL
+	}
+	if req.Events != nil {
+		channel.Events = req.Events
+	}
+	channel.IsActive = req.IsActive
+
+	if msg := validateNotificationChannel(channel.Provider, channel.WebhookURL, channel.Events); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Save(channel).Error; err != nil {
+		a.Log.Error("Failed to update notification channel", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update notification channel", nil, "")
+	}
+
+	return r.SendEnvelope(notificationChannelToResponse(*channel))
+}
+
+// DeleteNotificationChannel removes a channel
+func (a *App) DeleteNotificationChannel(r *fastglue.Request) error {
+	channel, err := a.loadNotificationChannel(r)
+	if err != nil || channel == nil {
+		return err
+	}
+
+	if err := a.DB.Delete(channel).Error; err != nil {
+		a.Log.Error("Failed to delete notification channel", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete notification channel", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Notification channel deleted successfully"})
+}
+
+// TestNotificationChannel posts a test message to a channel
+func (a *App) TestNotificationChannel(r *fastglue.Request) error {
+	channel, err := a.loadNotificationChannel(r)
+	if err != nil || channel == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	msg := chatnotify.Message{
+		Title: "Whatomate test notification",
+		Text:  fmt.Sprintf("Notifications for %q are set up correctly.", channel.Name),
+		URL:   a.appLink("/settings?tab=notifications"),
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if err := chatnotify.Send(ctx, client, channel.Provider, channel.WebhookURL, msg); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Test notification failed: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Test notification sent successfully"})
+}
+
+// loadNotificationChannel checks write permission and loads the channel from the path.
+// On failure it sends the error response and returns a nil channel.
+func (a *App) loadNotificationChannel(r *fastglue.Request) (*models.NotificationChannel, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	channelID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid channel ID", nil, "")
+	}
+
+	var channel models.NotificationChannel
+	if err := a.DB.Where("id = ? AND organization_id = ?", channelID, orgID).First(&channel).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Notification channel not found", nil, "")
+	}
+	return &channel, nil
+}
+
+// validateNotificationChannel returns a user-facing error message, or "" if valid
+func validateNotificationChannel(provider, webhookURL string, events []string) string {
+	if !chatnotify.IsSupported(provider) {
+		return "provider must be slack or teams"
+	}
+	if err := chatnotify.ValidateWebhookURL(webhookURL); err != nil {
+		return err.Error()
+	}
+	if len(events) == 0 {
+		return "at least one event must be selected"
+	}
+	for _, e := range events {
+		if !isNotificationEvent(e) {
+			return "unknown event: " + e
+		}
+	}
+	return ""
+}
+
+func isNotificationEvent(event string) bool {
+	for _, e := range AvailableNotificationEvents {
+		if e["value"] == event {
+			return true
+		}
+	}
+	return false
+}
+
+func notificationChannelToResponse(ch models.NotificationChannel) NotificationChannelResponse {
+	events := make([]string, len(ch.Events))
+	copy(events, ch.Events)
+
+	return NotificationChannelResponse{
+		ID:             ch.ID,
+		Name:           ch.Name,
+		Provider:       ch.Provider,
+		WebhookURLHint: maskWebhookURL(ch.WebhookURL),
+		Events:         events,
+		IsActive:       ch.IsActive,
+		CreatedAt:      ch.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      ch.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// maskWebhookURL keeps the host and the last characters of a webhook URL so admins can
+// tell channels apart without exposing the secret path
+func maskWebhookURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "****"
+	}
+	suffix := ""
+	if len(raw) > 4 {
+		suffix = raw[len(raw)-4:]
+	}
+	return u.Scheme + "://" + u.Host + "/****" + suffix
+}
+
+// NotifyChannels posts an event to every active channel of the organization that
+// subscribes to it. Delivery happens in the background, and build is only called when
+// at least one channel subscribes, so lookups for the message stay off the hot path.
+func (a *App) NotifyChannels(orgID uuid.UUID, event models.NotificationEvent, build func() (chatnotify.Message, bool)) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		var channels []models.NotificationChannel
+		if err := a.DB.Where("organization_id = ? AND is_active = ?", orgID, true).Find(&channels).Error; err != nil {
+			a.Log.Error("Failed to load notification channels", "error", err, "org_id", orgID)
+			return
+		}
+
+		var subscribed []models.NotificationChannel
+		for _, ch := range channels {
+			if containsEvent(ch.Events, string(event)) {
+				subscribed = append(subscribed, ch)
+			}
+		}
+		if len(subscribed) == 0 {
+			return
+		}
+
+		msg, ok := build()
+		if !ok {
+			return
+		}
+		client := &http.Client{Timeout: 10 * time.Second}
+		for _, ch := range subscribed {
+			a.sendChannelNotification(ctx, client, ch, event, msg)
+		}
+	}()
+}
+
+// sendChannelNotification delivers a notification with the same retry policy as webhooks
+func (a *App) sendChannelNotification(ctx context.Context, client *http.Client, ch models.NotificationChannel, event models.NotificationEvent, msg chatnotify.Message) {
+	const maxRetries = 3
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(1<<attempt) * time.Second):
+			}
+		}
+		err := chatnotify.Send(ctx, client, ch.Provider, ch.WebhookURL, msg)
+		if err == nil {
+			a.Log.Debug("Channel notification delivered", "channel_id", ch.ID, "event", event)
+			return
+		}
+		a.Log.Warn("Channel notification failed", "error", err, "channel_id", ch.ID, "event", event, "attempt", attempt+1)
+	}
+	a.Log.Error("Channel notification failed after all retries", "channel_id", ch.ID, "event", event)
+}
+
+// notifyOnce reports whether this instance should send a notification identified by key,
+// so events observed by every app instance are only posted once
+func (a *App) notifyOnce(key string) bool {
+	ok, err := a.Redis.SetNX(context.Background(), "notify:once:"+key, 1, notificationDedupTTL).Result()
+	if err != nil {
+		a.Log.Warn("Failed to claim notification", "error", err, "key", key)
+		return true
+	}
+	return ok
+}
+
+// appLink builds an absolute link into the frontend, or "" when no public URL is configured
+func (a *App) appLink(path string) string {
+	if a.Config == nil || a.Config.Server.PublicURL == "" {
+		return ""
+	}
+	return strings.TrimRight(a.Config.Server.PublicURL, "/") + path
+}
+
+// notifyTransferQueued posts a new unassigned transfer to subscribed channels
+func (a *App) notifyTransferQueued(transfer *models.AgentTransfer, contact *models.Contact) {
+	if transfer.AgentID != nil {
+		return
+	}
+	t, c := *transfer, *contact
+	a.NotifyChannels(t.OrganizationID, models.NotificationEventTransferQueued, func() (chatnotify.Message, bool) {
+		fields := a.transferNotificationFields(&t, &c)
+		fields = append(fields, chatnotify.Field{Name: "Source", Value: string(t.Source)})
+		if t.Notes != "" {
+			fields = append(fields, chatnotify.Field{Name: "Notes", Value: t.Notes})
+		}
+		return chatnotify.Message{
+			Title:    "New conversation waiting in queue",
+			Fields:   fields,
+			URL:      a.appLink("/chatbot/transfers"),
+			Severity: chatnotify.SeverityInfo,
+		}, true
+	})
+}
+
+// notifySLABreached posts a transfer that missed its response deadline
+func (a *App) notifySLABreached(transfer *models.AgentTransfer) {
+	t := *transfer
+	a.NotifyChannels(t.OrganizationID, models.NotificationEventSLABreached, func() (chatnotify.Message, bool) {
+		var contact models.Contact
+		if err := a.DB.Where("id = ?", t.ContactID).First(&contact).Error; err != nil {
+			a.Log.Error("Failed to load contact for SLA notification", "error", err, "transfer_id", t.ID)
+			return chatnotify.Message{}, false
+		}
+		fields := a.transferNotificationFields(&t, &contact)
+		fields = append(fields, chatnotify.Field{
+			Name:  "Waiting for",
+			Value: time.Since(t.TransferredAt).Round(time.Minute).String(),
+		})
+		return chatnotify.Message{
+			Title:    "SLA breached",
+			Text:     "A queued conversation missed its response deadline.",
+			Fields:   fields,
+			URL:      a.appLink("/chatbot/transfers"),
+			Severity: chatnotify.SeverityDanger,
+		}, true
+	})
+}
+
+// notifyCampaignCompleted posts campaign results. Every instance receives the completion
+// update, so only the first one to claim it sends the notification.
+func (a *App) notifyCampaignCompleted(update *queue.CampaignStatsUpdate) {
+	u := *update
+	a.NotifyChannels(u.OrganizationID, models.NotificationEventCampaignCompleted, func() (chatnotify.Message, bool) {
+		if !a.notifyOnce("campaign_completed:" + u.CampaignID) {
+			return chatnotify.Message{}, false
+		}
+
+		var campaign models.BulkMessageCampaign
+		if err := a.DB.Where("id = ?", u.CampaignID).First(&campaign).Error; err != nil {
+			a.Log.Error("Failed to load campaign for notification", "error", err, "campaign_id", u.CampaignID)
+			return chatnotify.Message{}, false
+		}
+
+		severity := chatnotify.SeverityInfo
+		if u.FailedCount > 0 {
+			severity = chatnotify.SeverityWarning
+		}
+		return chatnotify.Message{
+			Title: fmt.Sprintf("Campaign %q finished", campaign.Name),
+			Fields: []chatnotify.Field{
+				{Name: "Recipients", Value: fmt.Sprint(campaign.TotalRecipients)},
+				{Name: "Sent", Value: fmt.Sprint(u.SentCount)},
+				{Name: "Delivered", Value: fmt.Sprint(u.DeliveredCount)},
+				{Name: "Failed", Value: fmt.Sprint(u.FailedCount)},
+			},
+			URL:      a.appLink("/campaigns"),
+			Severity: severity,
+		}, true
+	})
+}
+
+// notifyTemplateRejected posts a template rejected by Meta
+func (a *App) notifyTemplateRejected(orgID uuid.UUID, account, name, language, reason string) {
+	a.NotifyChannels(orgID, models.NotificationEventTemplateRejected, func() (chatnotify.Message, bool) {
+		fields := []chatnotify.Field{
+			{Name: "Template", Value: name},
+			{Name: "Language", Value: language},
+			{Name: "Account", Value: account},
+		}
+		if reason != "" && reason != "NONE" {
+			fields = append(fields, chatnotify.Field{Name: "Reason", Value: reason})
+		}
+		return chatnotify.Message{
+			Title:    "Template rejected",
+			Fields:   fields,
+			URL:      a.appLink("/templates"),
+			Severity: chatnotify.SeverityWarning,
+		}, true
+	})
+}
+
+// transferNotificationFields describes a transfer's contact, honouring phone masking
+func (a *App) transferNotificationFields(transfer *models.AgentTransfer, contact *models.Contact) []chatnotify.Field {
+	name := contact.ProfileName
+	phone := transfer.PhoneNumber
+	if a.ShouldMaskPhoneNumbers(transfer.OrganizationID) {
+		name = MaskIfPhoneNumber(name)
+		phone = MaskPhoneNumber(phone)
+	}
+
+	fields := []chatnotify.Field{
+		{Name: "Contact", Value: name},
+		{Name: "Phone", Value: phone},
+		{Name: "Account", Value: transfer.WhatsAppAccount},
+	}
+	if transfer.TeamID != nil {
+		var team models.Team
+		if err := a.DB.Select("name").Where("id = ?", transfer.TeamID).First(&team).Error; err == nil {
+			fields = append(fields, chatnotify.Field{Name: "Team", Value: team.Name})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/optout"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// OptOutRequest changes a contact's opt-out state by hand
+type OptOutRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ContactOptOutResponse is an entry in the opt-out history
+type ContactOptOutResponse struct {
+	ID          uuid.UUID           `json:"id"`
+	ContactID   uuid.UUID           `json:"contact_id"`
+	PhoneNumber string              `json:"phone_number"`
+	Action      models.OptOutAction `json:"action"`
+	Source      models.OptOutSource `json:"source"`
+	Keyword     string              `json:"keyword,omitempty"`
+	Reason      string              `json:"reason,omitempty"`
+	ChangedBy   *uuid.UUID          `json:"changed_by,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// OptOutContact stops campaigns from messaging a contact
+func (a *App) OptOutContact(r *fastglue.Request) error {
+	return a.changeContactOptOut(r, models.OptOutActionOptOut)
+}
+
+// OptInContact lets campaigns message a contact who opted out again
+func (a *App) OptInContact(r *fastglue.Request) error {
+	return a.changeContactOptOut(r, models.OptOutActionOptIn)
+}
+
+// changeContactOptOut applies a manual opt-out or opt-in to the contact in the path
+func (a *App) changeContactOptOut(r *fastglue.Request, action models.OptOutAction) error {
+	contact, err := a.contactFromPath(r, models.ActionWrite)
+	if err != nil || contact == nil {
+		return err
+	}
+
+	var req OptOutRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > 255 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Reason cannot exceed 255 characters", nil, "")
+	}
+
+	optedOut := action == models.OptOutActionOptOut
+	if contact.OptedOut == optedOut {
+		if optedOut {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "Contact has already opted out", nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Contact has not opted out", nil, "")
+	}
+
+	record := models.ContactOptOut{
+		Action: action,
+		Source: models.OptOutSourceManual,
+		Reason: req.Reason,
+	}
+	if userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID); ok && userID != uuid.Nil {
+		record.ChangedBy = &userID
+	}
+	if err := a.setContactOptOut(contact, &record); err != nil {
+		a.Log.Error("Failed to update contact opt-out", "error", err, "contact_id", contact.ID, "action", action)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update opt-out", nil, "")
+	}
+
+	a.Log.Info("Contact opt-out changed", "contact_id", contact.ID, "action", action)
+	return r.SendEnvelope(map[string]interface{}{
+		"opted_out":    contact.OptedOut,
+		"opted_out_at": contact.OptedOutAt,
+		"record":       record,
+	})
+}
+
+// ListOptOuts returns the organization's opt-out history, newest first. It can be
+// filtered by contact_id, action, source and a from/to date range (YYYY-MM-DD, in the
+// organization's timezone).
+func (a *App) ListOptOuts(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	args := r.RequestCtx.QueryArgs()
+	page, _ := strconv.Atoi(string(args.Peek("page")))
+	limit, _ := strconv.Atoi(string(args.Peek("limit")))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	query := a.DB.Model(&models.ContactOptOut{}).Where("organization_id = ?", orgID)
+	if contactID := string(args.Peek("contact_id")); contactID != "" {
+		id, err := uuid.Parse(contactID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact_id", nil, "")
+		}
+		query = query.Where("contact_id = ?", id)
+	}
+	if action := models.OptOutAction(args.Peek("action")); action != "" {
+		if action != models.OptOutActionOptOut && action != models.OptOutActionOptIn {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "action must be opt_out or opt_in", nil, "")
+		}
+		query = query.Where("action = ?", action)
+	}
+	if source := models.OptOutSource(args.Peek("source")); source != "" {
+		if source != models.OptOutSourceKeyword && source != models.OptOutSourceManual {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "source must be keyword or manual", nil, "")
+		}
+		query = query.Where("source = ?", source)
+	}
+	loc := a.getOrgLocation(orgID)
+	if from := string(args.Peek("from")); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := string(args.Peek("to")); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
+		}
+		query = query.Where("created_at < ?", t.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		a.Log.Error("Failed to count opt-outs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list opt-outs", nil, "")
+	}
+	var records []models.ContactOptOut
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&records).Error; err != nil {
+		a.Log.Error("Failed to list opt-outs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list opt-outs", nil, "")
+	}
+
+	mask := a.dataMaskFor(orgID, userID)
+	result := make([]ContactOptOutResponse, len(records))
+	for i, rec := range records {
+		result[i] = ContactOptOutResponse{
+			ID:          rec.ID,
+			ContactID:   rec.ContactID,
+			PhoneNumber: mask.Phone(rec.PhoneNumber),
+			Action:      rec.Action,
+			Source:      rec.Source,
+			Keyword:     rec.Keyword,
+			Reason:      rec.Reason,
+			ChangedBy:   rec.ChangedBy,
+			CreatedAt:   rec.CreatedAt,
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"opt_outs": result,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// handleOptOutKeyword opts the contact out when the message is one of the organization's
+// opt-out keywords. Reports whether it matched.
+func (a *App) handleOptOutKeyword(contact *models.Contact, messageText string) bool {
+	if contact == nil || contact.OptedOut || messageText == "" {
+		return false
+	}
+	keyword, ok := optout.Match(messageText, a.orgOptOutKeywords(contact.OrganizationID))
+	if !ok {
+		return false
+	}
+
+	record := models.ContactOptOut{
+		Action:  models.OptOutActionOptOut,
+		Source:  models.OptOutSourceKeyword,
+		Keyword: keyword,
+	}
+	if err := a.setContactOptOut(contact, &record); err != nil {
+		a.Log.Error("Failed to opt out contact", "error", err, "contact_id", contact.ID, "keyword", keyword)
+		return false
+	}
+	a.Log.Info("Contact opted out by keyword", "contact_id", contact.ID, "keyword", keyword)
+	return true
+}
+
+// orgOptOutKeywords returns the keywords that opt a contact out of the organization's campaigns
+func (a *App) orgOptOutKeywords(orgID uuid.UUID) []string {
+	var org models.Organization
+	if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return optout.DefaultKeywords
+	}
+	return optout.KeywordsFromSettings(org.Settings)
+}
+
+// setContactOptOut updates the contact's opt-out state and records the change in the
+// history. record needs its Action, Source and optional details set.
+func (a *App) setContactOptOut(contact *models.Contact, record *models.ContactOptOut) error {
+	optedOut := record.Action == models.OptOutActionOptOut
+	var optedOutAt *time.Time
+	if optedOut {
+		now := time.Now()
+		optedOutAt = &now
+	}
+
+	record.OrganizationID = contact.OrganizationID
+	record.ContactID = contact.ID
+	record.PhoneNumber = contact.PhoneNumber
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Contact{}).Where("id = ?", contact.ID).
+			Updates(map[string]interface{}{"opted_out": optedOut, "opted_out_at": optedOutAt}).Error; err != nil {
+			return err
+		}
+		if optedOut {
+			if err := tx.Model(&models.SequenceEnrollment{}).
+				Where("contact_id = ? AND status = ?", contact.ID, models.SequenceEnrollmentActive).
+				Updates(exitEnrollmentUpdates(models.SequenceExitOptedOut)).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	contact.OptedOut = optedOut
+	contact.OptedOutAt = optedOutAt
+	return nil
+}
+
+// optedOutNumbers returns which of the numbers belong to contacts who opted out,
+// looking them up in batches
+func (a *App) optedOutNumbers(orgID uuid.UUID, numbers []string) (map[string]bool, error) {
+	optedOut := make(map[string]bool)
+	for start := 0; start < len(numbers); start += recipientInsertBatchSize {
+		end := min(start+recipientInsertBatchSize, len(numbers))
+		var found []string
+		if err := a.DB.Model(&models.Contact{}).
+			Where("organization_id = ? AND opted_out = ? AND phone_number IN ?", orgID, true, numbers[start:end]).
+			Pluck("phone_number", &found).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range found {
+			optedOut[p] = true
+		}
+	}
+	return optedOut, nil
+}
@@ -0,0 +1,160 @@
+package handlers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/optout"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// listOptOuts calls ListOptOuts with the query parameters and returns the records
+func listOptOuts(t *testing.T, app *handlers.App, orgID, userID uuid.UUID, query map[string]string) []handlers.ContactOptOutResponse {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, orgID, userID)
+	for k, v := range query {
+		testutil.SetQueryParam(req, k, v)
+	}
+	require.NoError(t, app.ListOptOuts(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		OptOuts []handlers.ContactOptOutResponse `json:"opt_outs"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	return resp.OptOuts
+}
+
+func TestApp_ContactOptOut(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("opt-out"), "password", &role.ID, true)
+	contact := createTestContact(t, app, org.ID)
+
+	change := func(optIn bool, reason string) int {
+		req := testutil.NewJSONRequest(t, map[string]string{"reason": reason})
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", contact.ID.String())
+		if optIn {
+			require.NoError(t, app.OptInContact(req))
+		} else {
+			require.NoError(t, app.OptOutContact(req))
+		}
+		return testutil.GetResponseStatusCode(req)
+	}
+
+	require.Equal(t, fasthttp.StatusOK, change(false, "Asked on a call"))
+	assert.ErrorIs(t, optout.Check(app.DB, org.ID, contact.PhoneNumber), optout.ErrOptedOut)
+	assert.Equal(t, fasthttp.StatusConflict, change(false, ""))
+
+	require.Equal(t, fasthttp.StatusOK, change(true, "Signed up again"))
+	require.NoError(t, optout.Check(app.DB, org.ID, contact.PhoneNumber))
+	assert.Equal(t, fasthttp.StatusConflict, change(true, ""))
+
+	records := listOptOuts(t, app, org.ID, user.ID, map[string]string{"contact_id": contact.ID.String()})
+	require.Len(t, records, 2)
+	assert.Equal(t, models.OptOutActionOptIn, records[0].Action)
+	assert.Equal(t, models.OptOutActionOptOut, records[1].Action)
+	assert.Equal(t, models.OptOutSourceManual, records[1].Source)
+	assert.Equal(t, "Asked on a call", records[1].Reason)
+	require.NotNil(t, records[1].ChangedBy)
+	assert.Equal(t, user.ID, *records[1].ChangedBy)
+
+	records = listOptOuts(t, app, org.ID, user.ID, map[string]string{"action": "opt_in"})
+	assert.Len(t, records, 1)
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetQueryParam(req, "source", "carrier_pigeon")
+	require.NoError(t, app.ListOptOuts(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+}
+
+func TestApp_OptOutKeyword(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Update("phone_id", "phone-"+uuid.NewString()[:8]).Error)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("opt-out-keyword"), "password", &role.ID, true)
+
+	receive := func(from, text string) {
+		body := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+			"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+			"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+			"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"text","text":{"body":%q}}]}}]}]}`,
+			account.PhoneID, from, from, "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()), text)
+		req := testutil.NewJSONRequest(t, nil)
+		req.RequestCtx.Request.SetBody([]byte(body))
+		require.NoError(t, app.WebhookHandler(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	}
+
+	// A message that only mentions the keyword doesn't opt out
+	receive("14155550170", "Please don't stop sending offers")
+	receive("14155550171", "Stop!")
+
+	require.Eventually(t, func() bool {
+		return optout.Check(app.DB, org.ID, "14155550171") != nil
+	}, 2*time.Second, 20*time.Millisecond)
+	require.Eventually(t, func() bool {
+		var count int64
+		app.DB.Model(&models.Contact{}).Where("organization_id = ? AND phone_number = ?", org.ID, "14155550170").Count(&count)
+		return count == 1
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.NoError(t, optout.Check(app.DB, org.ID, "14155550170"))
+
+	records := listOptOuts(t, app, org.ID, user.ID, map[string]string{"source": "keyword"})
+	require.Len(t, records, 1)
+	assert.Equal(t, "STOP", records[0].Keyword)
+	assert.Nil(t, records[0].ChangedBy)
+}
+
+func TestApp_ImportRecipients_SkipsOptedOut(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("import-opted-out"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "opt-out-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	campaign := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusDraft)
+
+	require.NoError(t, app.DB.Create(&models.Contact{
+		OrganizationID: org.ID,
+		PhoneNumber:    "14155550180",
+		OptedOut:       true,
+	}).Error)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"recipients": []map[string]interface{}{
+			{"phone_number": "+14155550180"},
+			{"phone_number": "+14155550181"},
+		},
+	})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", campaign.ID.String())
+	require.NoError(t, app.ImportRecipients(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		AddedCount int                          `json:"added_count"`
+		Rejected   []handlers.RejectedRecipient `json:"rejected"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	assert.Equal(t, 1, resp.AddedCount)
+	require.Len(t, resp.Rejected, 1)
+	assert.Equal(t, "14155550180", resp.Rejected[0].PhoneNumber)
+	assert.Equal(t, optout.ErrOptedOut.Error(), resp.Rejected[0].Reason)
+}
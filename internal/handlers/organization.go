@@ -1,19 +1,48 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/consent"
+	"github.com/shridarpatil/whatomate/internal/contentpolicy"
+	"github.com/shridarpatil/whatomate/internal/ipaccess"
+	"github.com/shridarpatil/whatomate/internal/mediapolicy"
+	"github.com/shridarpatil/whatomate/internal/middleware"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/optout"
+	"github.com/shridarpatil/whatomate/internal/phone"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 )
 
+const defaultOrgTimezone = "UTC"
+
 // OrganizationSettings represents the settings structure
 type OrganizationSettings struct {
 	MaskPhoneNumbers bool   `json:"mask_phone_numbers"`
 	Timezone         string `json:"timezone"`
 	DateFormat       string `json:"date_format"`
+	// Destination country calling codes; see phone.Restrictions
+	AllowedCountries []string `json:"allowed_countries"`
+	BlockedCountries []string `json:"blocked_countries"`
+	// Outbound agent message rules; see contentpolicy.Policy
+	ContentPolicy contentpolicy.Policy `json:"content_policy"`
+	// IP allowlist for API keys and admins; see ipaccess.Policy
+	IPAccess ipaccess.Policy `json:"ip_access"`
+	// Size and type limits for media sent by contacts; see mediapolicy.Policy
+	InboundMediaPolicy mediapolicy.Policy `json:"inbound_media_policy"`
+	// Messages older than this many days are moved to the archive; 0 disables archiving
+	ArchiveAfterDays int `json:"archive_after_days"`
+	// Marketing templates only go to contacts with an active opt-in; see consent.Check
+	RequireMarketingConsent bool `json:"require_marketing_consent"`
+	// Replies that opt a contact out of campaigns; see optout.Match
+	OptOutKeywords []string `json:"opt_out_keywords"`
+	// Users must approve before a super admin can impersonate them
+	RequireImpersonationConsent bool `json:"require_impersonation_consent"`
 }
 
 // GetOrganizationSettings returns the organization settings
@@ -31,8 +60,9 @@ func (a *App) GetOrganizationSettings(r *fastglue.Request) error {
 	// Parse settings from JSONB
 	settings := OrganizationSettings{
 		MaskPhoneNumbers: false,
-		Timezone:         "UTC",
+		Timezone:         defaultOrgTimezone,
 		DateFormat:       "YYYY-MM-DD",
+		OptOutKeywords:   optout.DefaultKeywords,
 	}
 
 	if org.Settings != nil {
@@ -45,11 +75,22 @@ func (a *App) GetOrganizationSettings(r *fastglue.Request) error {
 		if v, ok := org.Settings["date_format"].(string); ok && v != "" {
 			settings.DateFormat = v
 		}
+		restrictions := phone.RestrictionsFromSettings(org.Settings)
+		settings.AllowedCountries = restrictions.Allowed
+		settings.BlockedCountries = restrictions.Blocked
+		settings.ContentPolicy = contentpolicy.FromSettings(org.Settings)
+		settings.IPAccess = ipaccess.FromSettings(org.Settings)
+		settings.InboundMediaPolicy = mediapolicy.FromSettings(org.Settings)
+		settings.ArchiveAfterDays = archiveAfterDaysFromSettings(org.Settings)
+		settings.RequireMarketingConsent = consent.RequiredFromSettings(org.Settings)
+		settings.OptOutKeywords = optout.KeywordsFromSettings(org.Settings)
+		settings.RequireImpersonationConsent, _ = org.Settings[impersonationConsentSettingKey].(bool)
 	}
 
 	return r.SendEnvelope(map[string]interface{}{
-		"settings": settings,
-		"name":     org.Name,
+		"settings":          settings,
+		"name":              org.Name,
+		"data_region_label": a.dataRegionLabel(org.DataRegion),
 	})
 }
 
@@ -61,16 +102,96 @@ func (a *App) UpdateOrganizationSettings(r *fastglue.Request) error {
 	}
 
 	var req struct {
-		MaskPhoneNumbers *bool   `json:"mask_phone_numbers"`
-		Timezone         *string `json:"timezone"`
-		DateFormat       *string `json:"date_format"`
-		Name             *string `json:"name"`
+		MaskPhoneNumbers            *bool                 `json:"mask_phone_numbers"`
+		Timezone                    *string               `json:"timezone"`
+		DateFormat                  *string               `json:"date_format"`
+		AllowedCountries            *[]string             `json:"allowed_countries"`
+		BlockedCountries            *[]string             `json:"blocked_countries"`
+		ContentPolicy               *contentpolicy.Policy `json:"content_policy"`
+		IPAccess                    *ipaccess.Policy      `json:"ip_access"`
+		InboundMediaPolicy          *mediapolicy.Policy   `json:"inbound_media_policy"`
+		ArchiveAfterDays            *int                  `json:"archive_after_days"`
+		RequireConsent              *bool                 `json:"require_marketing_consent"`
+		OptOutKeywords              *[]string             `json:"opt_out_keywords"`
+		RequireImpersonationConsent *bool                 `json:"require_impersonation_consent"`
+		Name                        *string               `json:"name"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
+	if req.Timezone != nil && *req.Timezone != "" {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid timezone. Use an IANA name such as 'Asia/Kolkata'", nil, "")
+		}
+	}
+
+	var allowedCountries, blockedCountries []string
+	if req.AllowedCountries != nil {
+		if allowedCountries, err = phone.NormalizeCallingCodes(*req.AllowedCountries); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid allowed countries: "+err.Error(), nil, "")
+		}
+	}
+	if req.BlockedCountries != nil {
+		if blockedCountries, err = phone.NormalizeCallingCodes(*req.BlockedCountries); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid blocked countries: "+err.Error(), nil, "")
+		}
+	}
+
+	if req.ArchiveAfterDays != nil {
+		if err := validateArchiveAfterDays(*req.ArchiveAfterDays); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid archive period: "+err.Error(), nil, "")
+		}
+	}
+
+	var optOutKeywords []string
+	if req.OptOutKeywords != nil {
+		if optOutKeywords, err = optout.NormalizeKeywords(*req.OptOutKeywords); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid opt-out keywords: "+err.Error(), nil, "")
+		}
+	}
+
+	var contentPolicy contentpolicy.Policy
+	if req.ContentPolicy != nil {
+		if contentPolicy, err = req.ContentPolicy.Normalize(); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid content policy: "+err.Error(), nil, "")
+		}
+	}
+
+	var mediaPolicy mediapolicy.Policy
+	if req.InboundMediaPolicy != nil {
+		if mediaPolicy, err = req.InboundMediaPolicy.Normalize(); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid inbound media policy: "+err.Error(), nil, "")
+		}
+	}
+
+	var ipAccess ipaccess.Policy
+	if req.IPAccess != nil {
+		userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+		if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		}
+		if ipAccess, err = req.IPAccess.Normalize(); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid IP allowlist: "+err.Error(), nil, "")
+		}
+		// Don't let an admin lock themselves out
+		if !ipAccess.AllowsAdmin(middleware.ClientIP(r)) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Your current IP address must be included in the admin allowlist", nil, "")
+		}
+	}
+
+	if req.RequireImpersonationConsent != nil {
+		userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+		if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		}
+		// Only the organization's own admins decide, not super admins switched into it
+		if memberOrgID, _ := r.RequestCtx.UserValue("organization_id").(uuid.UUID); memberOrgID != orgID {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only members of the organization can change impersonation consent", nil, "")
+		}
+	}
+
 	var org models.Organization
 	if err := a.DB.Where("id = ?", orgID).First(&org).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
@@ -90,6 +211,36 @@ func (a *App) UpdateOrganizationSettings(r *fastglue.Request) error {
 	if req.DateFormat != nil {
 		org.Settings["date_format"] = *req.DateFormat
 	}
+	if req.AllowedCountries != nil {
+		org.Settings["allowed_countries"] = allowedCountries
+	}
+	if req.BlockedCountries != nil {
+		org.Settings["blocked_countries"] = blockedCountries
+	}
+	if req.ContentPolicy != nil {
+		org.Settings["content_policy"] = contentPolicy
+	}
+	if req.IPAccess != nil {
+		org.Settings["ip_access"] = ipAccess
+	}
+	if req.InboundMediaPolicy != nil {
+		org.Settings[mediapolicy.SettingKey] = mediaPolicy
+	}
+	if req.ArchiveAfterDays != nil {
+		org.Settings["archive_after_days"] = *req.ArchiveAfterDays
+	}
+	if req.RequireConsent != nil {
+		org.Settings[consent.SettingKey] = *req.RequireConsent
+	}
+	if req.OptOutKeywords != nil {
+		org.Settings[optout.SettingKey] = optOutKeywords
+	}
+	consentChanged := false
+	if req.RequireImpersonationConsent != nil {
+		current, _ := org.Settings[impersonationConsentSettingKey].(bool)
+		consentChanged = current != *req.RequireImpersonationConsent
+		org.Settings[impersonationConsentSettingKey] = *req.RequireImpersonationConsent
+	}
 	if req.Name != nil && *req.Name != "" {
 		org.Name = *req.Name
 	}
@@ -98,11 +249,78 @@ func (a *App) UpdateOrganizationSettings(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update settings", nil, "")
 	}
 
+	if req.Timezone != nil {
+		a.InvalidateOrgTimezoneCache(orgID)
+	}
+	if req.AllowedCountries != nil || req.BlockedCountries != nil {
+		a.InvalidateOrgCountriesCache(orgID)
+	}
+	if req.ContentPolicy != nil {
+		a.InvalidateOrgContentPolicyCache(orgID)
+	}
+	if req.IPAccess != nil {
+		a.InvalidateOrgIPAccessCache(orgID)
+	}
+	if req.InboundMediaPolicy != nil {
+		a.InvalidateOrgMediaPolicyCache(orgID)
+	}
+	if req.RequireConsent != nil {
+		a.InvalidateOrgConsentCache(orgID)
+	}
+	if consentChanged {
+		userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+		details := "Impersonation no longer requires consent"
+		if *req.RequireImpersonationConsent {
+			details = "Impersonation requires consent"
+		}
+		a.saveAuditLog(models.AuditLog{
+			OrganizationID: orgID,
+			UserID:         &userID,
+			Action:         models.AuditActionImpersonationConsent,
+			IPAddress:      middleware.ClientIP(r),
+			Path:           string(r.RequestCtx.Path()),
+			Details:        details,
+		})
+	}
+
 	return r.SendEnvelope(map[string]interface{}{
 		"message": "Settings updated successfully",
 	})
 }
 
+// getOrgLocation returns the organization's configured timezone, falling back to UTC.
+// Business hours, analytics date ranges and date bucketing are evaluated in this location.
+func (a *App) getOrgLocation(orgID uuid.UUID) *time.Location {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgTimezoneCachePrefix, orgID.String())
+
+	tz, err := a.Redis.Get(ctx, cacheKey).Result()
+	if err != nil || tz == "" {
+		tz = defaultOrgTimezone
+		var org models.Organization
+		if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err == nil && org.Settings != nil {
+			if v, ok := org.Settings["timezone"].(string); ok && v != "" {
+				tz = v
+			}
+		}
+		a.Redis.Set(ctx, cacheKey, tz, orgTimezoneCacheTTL)
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		a.Log.Warn("Invalid organization timezone, using UTC", "timezone", tz, "org_id", orgID)
+		return time.UTC
+	}
+	return loc
+}
+
+// InvalidateOrgTimezoneCache invalidates the cached timezone for an organization
+func (a *App) InvalidateOrgTimezoneCache(orgID uuid.UUID) {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgTimezoneCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
+
 // MaskPhoneNumber masks a phone number showing only last 4 digits
 func MaskPhoneNumber(phone string) string {
 	if len(phone) <= 4 {
@@ -156,10 +374,12 @@ func (a *App) ShouldMaskPhoneNumbers(orgID interface{}) bool {
 
 // OrganizationResponse represents an organization in API responses
 type OrganizationResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Slug      string    `json:"slug,omitempty"`
-	CreatedAt string    `json:"created_at"`
+	ID              uuid.UUID `json:"id"`
+	Name            string    `json:"name"`
+	Slug            string    `json:"slug,omitempty"`
+	DataRegion      string    `json:"data_region"`
+	DataRegionLabel string    `json:"data_region_label"`
+	CreatedAt       string    `json:"created_at"`
 }
 
 // ListOrganizations returns all organizations (super admin only)
@@ -183,10 +403,12 @@ func (a *App) ListOrganizations(r *fastglue.Request) error {
 	response := make([]OrganizationResponse, len(orgs))
 	for i, org := range orgs {
 		response[i] = OrganizationResponse{
-			ID:        org.ID,
-			Name:      org.Name,
-			Slug:      org.Slug,
-			CreatedAt: org.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			ID:              org.ID,
+			Name:            org.Name,
+			Slug:            org.Slug,
+			DataRegion:      org.DataRegion,
+			DataRegionLabel: a.dataRegionLabel(org.DataRegion),
+			CreatedAt:       org.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		}
 	}
 
@@ -208,9 +430,85 @@ func (a *App) GetCurrentOrganization(r *fastglue.Request) error {
 	}
 
 	return r.SendEnvelope(OrganizationResponse{
-		ID:        org.ID,
-		Name:      org.Name,
-		Slug:      org.Slug,
-		CreatedAt: org.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:              org.ID,
+		Name:            org.Name,
+		Slug:            org.Slug,
+		DataRegion:      org.DataRegion,
+		DataRegionLabel: a.dataRegionLabel(org.DataRegion),
+		CreatedAt:       org.CreatedAt.Format("2006-01-02T15:04:05Z"),
 	})
 }
+
+// getOrgCountryRestrictions returns the organization's destination country rules
+func (a *App) getOrgCountryRestrictions(orgID uuid.UUID) phone.Restrictions {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgCountriesCachePrefix, orgID.String())
+
+	var restrictions phone.Restrictions
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			if err := json.Unmarshal([]byte(cached), &restrictions); err == nil {
+				return restrictions
+			}
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err == nil && org.Settings != nil {
+		restrictions = phone.RestrictionsFromSettings(org.Settings)
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(restrictions); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgCountriesCacheTTL)
+		}
+	}
+	return restrictions
+}
+
+// InvalidateOrgCountriesCache invalidates the cached destination country rules for an organization
+func (a *App) InvalidateOrgCountriesCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgCountriesCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
+
+// getOrgContentPolicy returns the organization's outbound content policy
+func (a *App) getOrgContentPolicy(orgID uuid.UUID) contentpolicy.Policy {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgPolicyCachePrefix, orgID.String())
+
+	var policy contentpolicy.Policy
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			if err := json.Unmarshal([]byte(cached), &policy); err == nil {
+				return policy
+			}
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err == nil && org.Settings != nil {
+		policy = contentpolicy.FromSettings(org.Settings)
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(policy); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgPolicyCacheTTL)
+		}
+	}
+	return policy
+}
+
+// InvalidateOrgContentPolicyCache invalidates the cached content policy for an organization
+func (a *App) InvalidateOrgContentPolicyCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgPolicyCachePrefix, orgID.String())
+	a.Redis.Del(ctx, cacheKey)
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/mailer"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// organizationInviteTTL is how long an invitee has to accept
+const organizationInviteTTL = 7 * 24 * time.Hour
+
+// OrganizationInviteResponse is an invitation to join an organization
+type OrganizationInviteResponse struct {
+	ID               uuid.UUID  `json:"id"`
+	Email            string     `json:"email"`
+	OrganizationID   uuid.UUID  `json:"organization_id"`
+	OrganizationName string     `json:"organization_name,omitempty"`
+	RoleID           *uuid.UUID `json:"role_id,omitempty"`
+	RoleName         string     `json:"role_name,omitempty"`
+	InvitedBy        string     `json:"invited_by,omitempty"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+}
+
+// InviteOrganizationMember invites a user from another organization to this one, with a
+// role here. They become a member once they accept. The response is the same whether or
+// not the email has an account, so it can't be used to find out who has one.
+func (a *App) InviteOrganizationMember(r *fastglue.Request) error {
+	orgID, err := a.organizationMembersAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req OrganizationMemberRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Email is required", nil, "")
+	}
+	roleID, err := a.organizationMemberRole(orgID, req.RoleID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid role", nil, "")
+	}
+
+	// Users and members of this organization are already listed to the caller
+	var count int64
+	a.DB.Model(&models.User{}).Where("organization_id = ? AND email = ?", orgID, email).Count(&count)
+	if count == 0 {
+		a.DB.Model(&models.UserOrganization{}).
+			Joins("JOIN users ON users.id = user_organizations.user_id").
+			Where("user_organizations.organization_id = ? AND users.email = ?", orgID, email).
+			Count(&count)
+	}
+	if count > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "User is already a member of this organization", nil, "")
+	}
+
+	// Inviting the same email again renews the invitation
+	var invite models.OrganizationInvite
+	err = a.DB.Where("organization_id = ? AND email = ?", orgID, email).First(&invite).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		a.Log.Error("Failed to load organization invite", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to invite member", nil, "")
+	}
+	invite.OrganizationID = orgID
+	invite.Email = email
+	invite.RoleID = roleID
+	invite.InvitedByID = &userID
+	invite.ExpiresAt = time.Now().Add(organizationInviteTTL)
+	if err := a.DB.Save(&invite).Error; err != nil {
+		a.Log.Error("Failed to save organization invite", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to invite member", nil, "")
+	}
+
+	a.DB.Preload("Role").Preload("InvitedBy").First(&invite, invite.ID)
+	a.sendOrganizationInviteEmail(r, invite)
+
+	return r.SendEnvelope(organizationInviteToResponse(invite))
+}
+
+// sendOrganizationInviteEmail tells the invitee about the invitation, if the email has an
+// account to accept it with
+func (a *App) sendOrganizationInviteEmail(r *fastglue.Request, invite models.OrganizationInvite) {
+	var invitee models.User
+	if err := a.DB.Select("full_name, email").Where("email = ?", invite.Email).First(&invitee).Error; err != nil {
+		return
+	}
+
+	resp := organizationInviteToResponse(invite)
+	a.sendOrgEmailAsync(invite.OrganizationID, []string{invitee.Email}, mailer.TemplateOrgInvite, map[string]interface{}{
+		"Name":       invitee.FullName,
+		"InvitedBy":  resp.InvitedBy,
+		"Role":       resp.RoleName,
+		"InvitesURL": a.frontendURL(r, "/profile"),
+		"ExpiresIn":  "7 days",
+	})
+}
+
+// ListOrganizationInvites returns the invitations from this organization that haven't been
+// accepted yet
+func (a *App) ListOrganizationInvites(r *fastglue.Request) error {
+	orgID, err := a.organizationMembersAccess(r, models.ActionRead)
+	if err != nil {
+		return nil
+	}
+
+	var invites []models.OrganizationInvite
+	if err := a.DB.Preload("Role").Preload("InvitedBy").
+		Where("organization_id = ? AND expires_at > ?", orgID, time.Now()).
+		Order("created_at ASC").
+		Find(&invites).Error; err != nil {
+		a.Log.Error("Failed to list organization invites", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list invitations", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"invites": organizationInvitesToResponse(invites),
+	})
+}
+
+// RevokeOrganizationInvite withdraws an invitation before it is accepted
+func (a *App) RevokeOrganizationInvite(r *fastglue.Request) error {
+	orgID, err := a.organizationMembersAccess(r, models.ActionDelete)
+	if err != nil {
+		return nil
+	}
+	inviteID, err := uuid.Parse(fmt.Sprint(r.RequestCtx.UserValue("id")))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid invitation ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND organization_id = ?", inviteID, orgID).Delete(&models.OrganizationInvite{})
+	if result.Error != nil {
+		a.Log.Error("Failed to revoke organization invite", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to revoke invitation", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Invitation not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Invitation revoked"})
+}
+
+// ListMyOrganizationInvites returns the invitations the current user can accept
+func (a *App) ListMyOrganizationInvites(r *fastglue.Request) error {
+	user, err := a.currentInvitee(r)
+	if err != nil {
+		return nil
+	}
+
+	var invites []models.OrganizationInvite
+	if err := a.DB.Preload("Organization").Preload("Role").Preload("InvitedBy").
+		Where("email = ? AND expires_at > ?", user.Email, time.Now()).
+		Order("created_at ASC").
+		Find(&invites).Error; err != nil {
+		a.Log.Error("Failed to list organization invites", "error", err, "user_id", user.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list invitations", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"invites": organizationInvitesToResponse(invites),
+	})
+}
+
+// AcceptOrganizationInvite makes the current user a member of the inviting organization,
+// with the role from the invitation
+func (a *App) AcceptOrganizationInvite(r *fastglue.Request) error {
+	user, err := a.currentInvitee(r)
+	if err != nil {
+		return nil
+	}
+	invite, err := a.findMyOrganizationInvite(r, user)
+	if err != nil {
+		return nil
+	}
+
+	// The role may have been deleted since the invitation was sent
+	roleID, err := a.organizationMemberRole(invite.OrganizationID, invite.RoleID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "The role in this invitation no longer exists. Ask for a new invitation.", nil, "")
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if invite.OrganizationID != user.OrganizationID {
+			var count int64
+			tx.Model(&models.UserOrganization{}).Where("user_id = ? AND organization_id = ?", user.ID, invite.OrganizationID).Count(&count)
+			if count == 0 {
+				if err := tx.Create(&models.UserOrganization{
+					BaseModel:      models.BaseModel{ID: uuid.New()},
+					UserID:         user.ID,
+					OrganizationID: invite.OrganizationID,
+					RoleID:         roleID,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Delete(invite).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to accept organization invite", "error", err, "user_id", user.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to accept invitation", nil, "")
+	}
+	a.InvalidateUserPermissionsCache(user.ID)
+
+	a.Log.Info("User joined organization", "user_id", user.ID, "organization_id", invite.OrganizationID)
+	return r.SendEnvelope(map[string]string{"message": "Invitation accepted"})
+}
+
+// DeclineOrganizationInvite turns down an invitation
+func (a *App) DeclineOrganizationInvite(r *fastglue.Request) error {
+	user, err := a.currentInvitee(r)
+	if err != nil {
+		return nil
+	}
+	invite, err := a.findMyOrganizationInvite(r, user)
+	if err != nil {
+		return nil
+	}
+
+	if err := a.DB.Delete(invite).Error; err != nil {
+		a.Log.Error("Failed to decline organization invite", "error", err, "user_id", user.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to decline invitation", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Invitation declined"})
+}
+
+// currentInvitee loads the current user, sending a 401 if missing
+func (a *App) currentInvitee(r *fastglue.Request) (*models.User, error) {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+		return nil, errors.New("unauthorized")
+	}
+	var user models.User
+	if err := a.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "User not found", nil, "")
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findMyOrganizationInvite loads the invitation named by the id path parameter if it was
+// sent to the user's email and hasn't expired, sending a 4xx otherwise
+func (a *App) findMyOrganizationInvite(r *fastglue.Request, user *models.User) (*models.OrganizationInvite, error) {
+	inviteID, err := uuid.Parse(fmt.Sprint(r.RequestCtx.UserValue("id")))
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid invitation ID", nil, "")
+		return nil, err
+	}
+	var invite models.OrganizationInvite
+	if err := a.DB.Where("id = ? AND email = ? AND expires_at > ?", inviteID, user.Email, time.Now()).First(&invite).Error; err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusNotFound, "Invitation not found", nil, "")
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func organizationInvitesToResponse(invites []models.OrganizationInvite) []OrganizationInviteResponse {
+	resp := make([]OrganizationInviteResponse, len(invites))
+	for i, invite := range invites {
+		resp[i] = organizationInviteToResponse(invite)
+	}
+	return resp
+}
+
+func organizationInviteToResponse(invite models.OrganizationInvite) OrganizationInviteResponse {
+	resp := OrganizationInviteResponse{
+		ID:             invite.ID,
+		Email:          invite.Email,
+		OrganizationID: invite.OrganizationID,
+		RoleID:         invite.RoleID,
+		ExpiresAt:      invite.ExpiresAt,
+	}
+	if invite.Organization != nil {
+		resp.OrganizationName = invite.Organization.Name
+	}
+	if invite.Role != nil {
+		resp.RoleName = invite.Role.Name
+	}
+	if invite.InvitedBy != nil {
+		resp.InvitedBy = invite.InvitedBy.FullName
+	}
+	return resp
+}
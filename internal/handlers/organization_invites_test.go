@@ -0,0 +1,163 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// inviteTestMember invites email to orgID as adminID and returns the invitation
+func inviteTestMember(t *testing.T, app *handlers.App, orgID, adminID uuid.UUID, email string, roleID *uuid.UUID) handlers.OrganizationInviteResponse {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, map[string]any{"email": email, "role_id": roleID})
+	setTransferAuthContext(req, orgID, adminID)
+	require.NoError(t, app.InviteOrganizationMember(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var invite handlers.OrganizationInviteResponse
+	testutil.ParseEnvelopeResponse(t, req, &invite)
+	return invite
+}
+
+// respondToTestInvite accepts or declines an invitation as the user and returns the status
+func respondToTestInvite(t *testing.T, handler func(*fastglue.Request) error, orgID, userID, inviteID uuid.UUID) int {
+	t.Helper()
+
+	req := testutil.NewRequest(t)
+	setTransferAuthContext(req, orgID, userID)
+	testutil.SetPathParam(req, "id", inviteID.String())
+	require.NoError(t, handler(req))
+	return testutil.GetResponseStatusCode(req)
+}
+
+// listMyTestInvites returns the invitations the user can accept
+func listMyTestInvites(t *testing.T, app *handlers.App, orgID, userID uuid.UUID) []handlers.OrganizationInviteResponse {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	setTransferAuthContext(req, orgID, userID)
+	require.NoError(t, app.ListMyOrganizationInvites(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var resp struct {
+		Invites []handlers.OrganizationInviteResponse `json:"invites"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &resp)
+	return resp.Invites
+}
+
+func TestApp_OrganizationInvites(t *testing.T) {
+	app := testApp(t)
+	orgA := createTestOrganization(t, app)
+	orgB := createTestOrganization(t, app)
+	adminRoleA := createTransferAdminRole(t, app.DB, orgA.ID)
+	adminRoleB := createTransferAdminRole(t, app.DB, orgB.ID)
+	agentRoleB := createTransferTestRole(t, app.DB, orgB.ID, "agent", []string{"contacts:read"})
+
+	user := createTestUser(t, app, orgA.ID, uniqueEmail("agency"), "password123", &adminRoleA.ID, true)
+	colleague := createTestUser(t, app, orgA.ID, uniqueEmail("colleague"), "password123", &adminRoleA.ID, true)
+	adminB := createTestUser(t, app, orgB.ID, uniqueEmail("client-admin"), "password123", &adminRoleB.ID, true)
+
+	// Inviting looks the same whether or not the email has an account
+	known := inviteTestMember(t, app, orgB.ID, adminB.ID, user.Email, &agentRoleB.ID)
+	unknown := inviteTestMember(t, app, orgB.ID, adminB.ID, uniqueEmail("nobody"), &agentRoleB.ID)
+	assert.Equal(t, known.RoleName, unknown.RoleName)
+	assert.Equal(t, known.InvitedBy, unknown.InvitedBy)
+	assert.Equal(t, known.OrganizationID, unknown.OrganizationID)
+
+	// Nothing changes until the invitee accepts
+	assert.False(t, app.IsOrganizationMember(user.ID, orgB.ID))
+
+	req := testutil.NewGETRequest(t)
+	setTransferAuthContext(req, orgB.ID, adminB.ID)
+	require.NoError(t, app.ListOrganizationInvites(req))
+	var pending struct {
+		Invites []handlers.OrganizationInviteResponse `json:"invites"`
+	}
+	testutil.ParseEnvelopeResponse(t, req, &pending)
+	assert.Len(t, pending.Invites, 2)
+
+	// Only the invitee sees and can accept the invitation
+	invites := listMyTestInvites(t, app, orgA.ID, user.ID)
+	require.Len(t, invites, 1)
+	assert.Equal(t, known.ID, invites[0].ID)
+	assert.Equal(t, orgB.Name, invites[0].OrganizationName)
+	assert.Empty(t, listMyTestInvites(t, app, orgA.ID, colleague.ID))
+	assert.Equal(t, fasthttp.StatusNotFound, respondToTestInvite(t, app.AcceptOrganizationInvite, orgA.ID, colleague.ID, known.ID))
+	assert.False(t, app.IsOrganizationMember(colleague.ID, orgB.ID))
+
+	require.Equal(t, fasthttp.StatusOK, respondToTestInvite(t, app.AcceptOrganizationInvite, orgA.ID, user.ID, known.ID))
+	assert.True(t, app.IsOrganizationMember(user.ID, orgB.ID))
+	assert.True(t, app.HasPermission(user.ID, orgB.ID, models.ResourceContacts, models.ActionRead))
+	assert.False(t, app.HasPermission(user.ID, orgB.ID, models.ResourceUsers, models.ActionWrite))
+	assert.Empty(t, listMyTestInvites(t, app, orgA.ID, user.ID))
+	assert.Equal(t, fasthttp.StatusNotFound, respondToTestInvite(t, app.AcceptOrganizationInvite, orgA.ID, user.ID, known.ID))
+
+	// Members can't be invited again
+	req = testutil.NewJSONRequest(t, map[string]any{"email": user.Email, "role_id": agentRoleB.ID})
+	setTransferAuthContext(req, orgB.ID, adminB.ID)
+	require.NoError(t, app.InviteOrganizationMember(req))
+	assert.Equal(t, fasthttp.StatusConflict, testutil.GetResponseStatusCode(req))
+
+	// Declined, revoked and expired invitations can't be accepted
+	declined := inviteTestMember(t, app, orgB.ID, adminB.ID, colleague.Email, &agentRoleB.ID)
+	require.Equal(t, fasthttp.StatusOK, respondToTestInvite(t, app.DeclineOrganizationInvite, orgA.ID, colleague.ID, declined.ID))
+	assert.Equal(t, fasthttp.StatusNotFound, respondToTestInvite(t, app.AcceptOrganizationInvite, orgA.ID, colleague.ID, declined.ID))
+
+	revoked := inviteTestMember(t, app, orgB.ID, adminB.ID, colleague.Email, &agentRoleB.ID)
+	req = testutil.NewRequest(t)
+	setTransferAuthContext(req, orgB.ID, adminB.ID)
+	testutil.SetPathParam(req, "id", revoked.ID.String())
+	require.NoError(t, app.RevokeOrganizationInvite(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, fasthttp.StatusNotFound, respondToTestInvite(t, app.AcceptOrganizationInvite, orgA.ID, colleague.ID, revoked.ID))
+
+	expired := inviteTestMember(t, app, orgB.ID, adminB.ID, colleague.Email, &agentRoleB.ID)
+	require.NoError(t, app.DB.Model(&models.OrganizationInvite{}).Where("id = ?", expired.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	assert.Empty(t, listMyTestInvites(t, app, orgA.ID, colleague.ID))
+	assert.Equal(t, fasthttp.StatusNotFound, respondToTestInvite(t, app.AcceptOrganizationInvite, orgA.ID, colleague.ID, expired.ID))
+	assert.False(t, app.IsOrganizationMember(colleague.ID, orgB.ID))
+}
+
+func TestApp_OrganizationInvites_Validation(t *testing.T) {
+	app := testApp(t)
+	orgA := createTestOrganization(t, app)
+	orgB := createTestOrganization(t, app)
+	adminRoleA := createTransferAdminRole(t, app.DB, orgA.ID)
+	adminRoleB := createTransferAdminRole(t, app.DB, orgB.ID)
+	adminB := createTestUser(t, app, orgB.ID, uniqueEmail("client-admin"), "password123", &adminRoleB.ID, true)
+	user := createTestUser(t, app, orgA.ID, uniqueEmail("agency"), "password123", &adminRoleA.ID, true)
+
+	invite := func(email string, roleID *uuid.UUID) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, map[string]any{"email": email, "role_id": roleID})
+		setTransferAuthContext(req, orgB.ID, adminB.ID)
+		require.NoError(t, app.InviteOrganizationMember(req))
+		return req
+	}
+
+	testutil.AssertErrorResponse(t, invite("  ", &adminRoleB.ID), fasthttp.StatusBadRequest, "Email is required")
+	testutil.AssertErrorResponse(t, invite(user.Email, &adminRoleA.ID), fasthttp.StatusBadRequest, "Invalid role")
+	testutil.AssertErrorResponse(t, invite(adminB.Email, &adminRoleB.ID), fasthttp.StatusConflict, "User is already a member of this organization")
+
+	// Inviting again renews the pending invitation instead of adding another
+	first := inviteTestMember(t, app, orgB.ID, adminB.ID, user.Email, &adminRoleB.ID)
+	second := inviteTestMember(t, app, orgB.ID, adminB.ID, user.Email, &adminRoleB.ID)
+	assert.Equal(t, first.ID, second.ID)
+	assert.False(t, second.ExpiresAt.Before(first.ExpiresAt))
+
+	// Inviting needs users:write
+	agentRole := createTransferTestRole(t, app.DB, orgB.ID, "agent", []string{"users:read"})
+	agent := createTestUser(t, app, orgB.ID, uniqueEmail("agent"), "password123", &agentRole.ID, true)
+	req := testutil.NewJSONRequest(t, map[string]any{"email": uniqueEmail("someone")})
+	setTransferAuthContext(req, orgB.ID, agent.ID)
+	require.NoError(t, app.InviteOrganizationMember(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
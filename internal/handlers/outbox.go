@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// outboxMaxAttempts is how many times a message is sent before it is dead-lettered
+	outboxMaxAttempts = 5
+	// outboxRetryBase is the wait before the first retry; it doubles after each attempt
+	outboxRetryBase = 30 * time.Second
+	// outboxRetryMax caps the wait between retries
+	outboxRetryMax = 30 * time.Minute
+	// outboxLockTimeout is how long a sender holds an entry. Entries of a sender that
+	// crashed mid-send are picked up again once it passes.
+	outboxLockTimeout = 2 * time.Minute
+	// outboxSendTimeout bounds a single background send attempt
+	outboxSendTimeout = 30 * time.Second
+	// outboxBatchSize is how many due entries the processor claims per run
+	outboxBatchSize = 50
+)
+
+// outboxPayload is what an outbox entry keeps of an OutgoingMessageRequest to send it
+// again. The account, contact and template are loaded fresh on each attempt.
+type outboxPayload struct {
+	Type models.MessageType `json:"type"`
+
+	Content       string `json:"content,omitempty"`
+	MediaID       string `json:"media_id,omitempty"`
+	MediaData     []byte `json:"media_data,omitempty"`
+	MediaURL      string `json:"media_url,omitempty"`
+	MediaMimeType string `json:"media_mime_type,omitempty"`
+	MediaFilename string `json:"media_filename,omitempty"`
+	Caption       string `json:"caption,omitempty"`
+
+	InteractiveType string            `json:"interactive_type,omitempty"`
+	BodyText        string            `json:"body_text,omitempty"`
+	Buttons         []whatsapp.Button `json:"buttons,omitempty"`
+	ButtonText      string            `json:"button_text,omitempty"`
+	URL             string            `json:"url,omitempty"`
+
+	TemplateID     *uuid.UUID             `json:"template_id,omitempty"`
+	BodyParams     map[string]string      `json:"body_params,omitempty"`
+	FlowActionData map[string]interface{} `json:"flow_action_data,omitempty"`
+
+	FlowID          string `json:"flow_id,omitempty"`
+	FlowHeader      string `json:"flow_header,omitempty"`
+	FlowCTA         string `json:"flow_cta,omitempty"`
+	FlowToken       string `json:"flow_token,omitempty"`
+	FlowFirstScreen string `json:"flow_first_screen,omitempty"`
+
+	BroadcastWebSocket bool `json:"broadcast_websocket,omitempty"`
+	DispatchWebhook    bool `json:"dispatch_webhook,omitempty"`
+}
+
+// newOutboxEntry builds the outbox entry for a message about to be sent. It starts out
+// claimed by the caller, who makes the first attempt right away.
+func newOutboxEntry(msg *models.Message, req OutgoingMessageRequest, opts MessageSendOptions) (*models.OutboxMessage, error) {
+	payload := outboxPayload{
+		Type:               req.Type,
+		Content:            req.Content,
+		MediaID:            req.MediaID,
+		MediaData:          req.MediaData,
+		MediaURL:           req.MediaURL,
+		MediaMimeType:      req.MediaMimeType,
+		MediaFilename:      req.MediaFilename,
+		Caption:            req.Caption,
+		InteractiveType:    req.InteractiveType,
+		BodyText:           req.BodyText,
+		Buttons:            req.Buttons,
+		ButtonText:         req.ButtonText,
+		URL:                req.URL,
+		BodyParams:         req.BodyParams,
+		FlowActionData:     req.FlowActionData,
+		FlowID:             req.FlowID,
+		FlowHeader:         req.FlowHeader,
+		FlowCTA:            req.FlowCTA,
+		FlowToken:          req.FlowToken,
+		FlowFirstScreen:    req.FlowFirstScreen,
+		BroadcastWebSocket: opts.BroadcastWebSocket,
+		DispatchWebhook:    opts.DispatchWebhook,
+	}
+	if req.Template != nil {
+		payload.TemplateID = &req.Template.ID
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var jsonb models.JSONB
+	if err := json.Unmarshal(data, &jsonb); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lockedUntil := now.Add(outboxLockTimeout)
+	return &models.OutboxMessage{
+		OrganizationID: msg.OrganizationID,
+		MessageID:      msg.ID,
+		Payload:        jsonb,
+		Status:         models.OutboxStatusProcessing,
+		NextAttemptAt:  now,
+		LockedUntil:    &lockedUntil,
+	}, nil
+}
+
+// deliverOutboxMessage makes one send attempt for a claimed outbox entry and records
+// the outcome. A retryable failure puts the entry back with a backoff; any other
+// failure, or running out of attempts, dead-letters it and marks the message failed.
+func (a *App) deliverOutboxMessage(ctx context.Context, entry *models.OutboxMessage, msg *models.Message, req OutgoingMessageRequest, opts MessageSendOptions) {
+	// Mark the entry as sending so it can no longer be cancelled. If it's gone by then
+	// an agent cancelled the message.
+	result := a.DB.Model(&models.OutboxMessage{}).
+		Where("id = ? AND status = ?", entry.ID, models.OutboxStatusProcessing).
+		Update("status", models.OutboxStatusSending)
+	if result.Error == nil && result.RowsAffected == 0 {
+		a.Log.Info("Outgoing message cancelled before sending", "message_id", msg.ID)
+		return
+	}
+
+	wamid, err := a.sendOutgoingRequest(ctx, req)
+	if err == nil {
+		if err := a.DB.Unscoped().Delete(entry).Error; err != nil {
+			a.Log.Error("Failed to remove outbox entry", "error", err, "message_id", msg.ID)
+		}
+		a.finalizeMessageSend(msg, req, opts, wamid, nil)
+		return
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	entry.LockedUntil = nil
+	if whatsapp.IsRetryable(err) && entry.Attempts < outboxMaxAttempts {
+		entry.Status = models.OutboxStatusPending
+		entry.NextAttemptAt = time.Now().Add(outboxBackoff(entry.Attempts))
+		if saved, saveErr := a.saveOutboxAttempt(entry); saveErr != nil {
+			a.Log.Error("Failed to schedule message retry", "error", saveErr, "message_id", msg.ID)
+		} else if !saved {
+			return
+		}
+		a.Log.Warn("Message send failed, will retry", "error", err, "message_id", msg.ID,
+			"attempt", entry.Attempts, "next_attempt_at", entry.NextAttemptAt)
+		return
+	}
+
+	entry.Status = models.OutboxStatusDead
+	if saved, saveErr := a.saveOutboxAttempt(entry); saveErr != nil {
+		a.Log.Error("Failed to dead-letter outbox entry", "error", saveErr, "message_id", msg.ID)
+	} else if !saved {
+		return
+	}
+	a.finalizeMessageSend(msg, req, opts, "", err)
+}
+
+// saveOutboxAttempt stores the outcome of a failed attempt. It reports false when the
+// entry no longer exists because the message was cancelled meanwhile, so it isn't
+// brought back.
+func (a *App) saveOutboxAttempt(entry *models.OutboxMessage) (bool, error) {
+	result := a.DB.Model(entry).
+		Select("status", "attempts", "last_error", "locked_until", "next_attempt_at").
+		Updates(entry)
+	return result.RowsAffected > 0, result.Error
+}
+
+// outboxBackoff returns the wait before the next attempt after the given number of
+// failed attempts
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxRetryBase
+	for i := 1; i < attempts && backoff < outboxRetryMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxRetryMax)
+}
+
+// processOutbox claims the outbox entries that are due, along with entries whose sender
+// stopped before finishing, and sends them. Claiming them with a single UPDATE means
+// only one sender picks up each entry.
+func (a *App) processOutbox(ctx context.Context) {
+	now := time.Now()
+	due := a.DB.Model(&models.OutboxMessage{}).
+		Select("id").
+		Where("(status = ? AND next_attempt_at <= ?) OR (status IN ? AND locked_until < ?)",
+			models.OutboxStatusPending, now, []models.OutboxStatus{models.OutboxStatusProcessing, models.OutboxStatusSending}, now).
+		Order("next_attempt_at ASC").
+		Limit(outboxBatchSize).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var entries []models.OutboxMessage
+	if err := a.DB.Model(&entries).Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Updates(map[string]any{
+			"status":       models.OutboxStatusProcessing,
+			"locked_until": now.Add(outboxLockTimeout),
+		}).Error; err != nil {
+		a.Log.Error("Failed to claim outbox entries", "error", err)
+		return
+	}
+
+	for i := range entries {
+		if ctx.Err() != nil {
+			// Entries left unsent are picked up again once their lock expires
+			return
+		}
+		entry := &entries[i]
+		msg, req, opts, err := a.loadOutboxRequest(entry)
+		if err != nil {
+			a.Log.Warn("Outbox entry can't be sent", "error", err, "message_id", entry.MessageID)
+			a.deadLetterOutboxEntry(entry, msg, err)
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, outboxSendTimeout)
+		a.deliverOutboxMessage(sendCtx, entry, msg, req, opts)
+		cancel()
+	}
+}
+
+// loadOutboxRequest rebuilds the send request of an outbox entry from its payload and
+// the current message, contact, account and template
+func (a *App) loadOutboxRequest(entry *models.OutboxMessage) (*models.Message, OutgoingMessageRequest, MessageSendOptions, error) {
+	var req OutgoingMessageRequest
+	var opts MessageSendOptions
+
+	var msg models.Message
+	if err := a.DB.Where("id = ?", entry.MessageID).First(&msg).Error; err != nil {
+		return nil, req, opts, fmt.Errorf("message not found: %w", err)
+	}
+
+	var payload outboxPayload
+	data, err := json.Marshal(entry.Payload)
+	if err == nil {
+		err = json.Unmarshal(data, &payload)
+	}
+	if err != nil {
+		return &msg, req, opts, fmt.Errorf("invalid outbox payload: %w", err)
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", msg.WhatsAppAccount, msg.OrganizationID).First(&account).Error; err != nil {
+		return &msg, req, opts, fmt.Errorf("WhatsApp account not found")
+	}
+	var contact models.Contact
+	if err := a.DB.Where("id = ?", msg.ContactID).First(&contact).Error; err != nil {
+		return &msg, req, opts, fmt.Errorf("contact not found")
+	}
+
+	req = OutgoingMessageRequest{
+		Account:         &account,
+		Contact:         &contact,
+		Type:            payload.Type,
+		Content:         payload.Content,
+		MediaID:         payload.MediaID,
+		MediaData:       payload.MediaData,
+		MediaURL:        payload.MediaURL,
+		MediaMimeType:   payload.MediaMimeType,
+		MediaFilename:   payload.MediaFilename,
+		Caption:         payload.Caption,
+		InteractiveType: payload.InteractiveType,
+		BodyText:        payload.BodyText,
+		Buttons:         payload.Buttons,
+		ButtonText:      payload.ButtonText,
+		URL:             payload.URL,
+		BodyParams:      payload.BodyParams,
+		FlowActionData:  payload.FlowActionData,
+		FlowID:          payload.FlowID,
+		FlowHeader:      payload.FlowHeader,
+		FlowCTA:         payload.FlowCTA,
+		FlowToken:       payload.FlowToken,
+		FlowFirstScreen: payload.FlowFirstScreen,
+	}
+	if msg.ReplyToMessageID != nil {
+		var replyTo models.Message
+		if err := a.DB.Where("id = ?", *msg.ReplyToMessageID).First(&replyTo).Error; err == nil {
+			req.ReplyToMessage = &replyTo
+		}
+	}
+	if payload.TemplateID != nil {
+		var template models.Template
+		if err := a.DB.Where("id = ?", *payload.TemplateID).First(&template).Error; err != nil {
+			return &msg, req, opts, fmt.Errorf("template not found")
+		}
+		req.Template = &template
+	}
+
+	opts = MessageSendOptions{
+		BroadcastWebSocket: payload.BroadcastWebSocket,
+		DispatchWebhook:    payload.DispatchWebhook,
+		SentByUserID:       msg.SentByUserID,
+	}
+	return &msg, req, opts, nil
+}
+
+// deadLetterOutboxEntry gives up on an entry that can't be sent at all, failing its
+// message if it still exists
+func (a *App) deadLetterOutboxEntry(entry *models.OutboxMessage, msg *models.Message, err error) {
+	if msg == nil {
+		// The message was deleted or archived; there is nothing left to send
+		a.DB.Unscoped().Delete(entry)
+		return
+	}
+	entry.Status = models.OutboxStatusDead
+	entry.LastError = err.Error()
+	entry.LockedUntil = nil
+	a.DB.Save(entry)
+	a.DB.Model(msg).Updates(map[string]any{
+		"status":        models.MessageStatusFailed,
+		"error_message": err.Error(),
+	})
+	a.recordMessageStatus(msg, models.MessageStatusFailed, time.Now(), err.Error())
+}
+
+// RetryMessage sends a failed outgoing message again. The message keeps its ID and gets
+// a fresh set of attempts.
+func (a *App) RetryMessage(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	messageID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid message ID", nil, "")
+	}
+
+	// Users without full contact access can only retry messages to their assigned contacts
+	msg, err := a.findUserOutgoingMessage(orgID, userID, messageID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Message not found", nil, "")
+	}
+
+	if msg.Direction != models.DirectionOutgoing || msg.Status != models.MessageStatusFailed {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Only failed outgoing messages can be retried", nil, "")
+	}
+
+	// Claim the dead entry so a double click can't send the message twice
+	lockedUntil := time.Now().Add(outboxLockTimeout)
+	var entries []models.OutboxMessage
+	result := a.DB.Model(&entries).Clauses(clause.Returning{}).
+		Where("message_id = ? AND status = ?", msg.ID, models.OutboxStatusDead).
+		Updates(map[string]any{
+			"status":       models.OutboxStatusProcessing,
+			"attempts":     0,
+			"locked_until": lockedUntil,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to claim outbox entry", "error", result.Error, "message_id", msg.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to retry message", nil, "")
+	}
+	if len(entries) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "This message can't be retried, send it again instead", nil, "")
+	}
+	entry := &entries[0]
+
+	_, req, opts, err := a.loadOutboxRequest(entry)
+	if err != nil {
+		a.deadLetterOutboxEntry(entry, msg, err)
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Model(msg).Updates(map[string]any{
+		"status":        models.MessageStatusPending,
+		"error_message": "",
+		"error_code":    0,
+	}).Error; err != nil {
+		a.Log.Error("Failed to reset message status", "error", err, "message_id", msg.ID)
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), outboxSendTimeout)
+		defer cancel()
+		a.deliverOutboxMessage(ctx, entry, msg, req, opts)
+	}()
+
+	return r.SendEnvelope(map[string]any{
+		"message_id": msg.ID,
+		"status":     models.MessageStatusPending,
+	})
+}
+
+// OutboxProcessor periodically sends outbox entries that are due for a retry
+type OutboxProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewOutboxProcessor creates a new outbox processor
+func NewOutboxProcessor(app *App, interval time.Duration) *OutboxProcessor {
+	return &OutboxProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the outbox processing loop
+func (p *OutboxProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Outbox processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Outbox processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Outbox processor stopped")
+			return
+		case <-ticker.C:
+			p.app.processOutbox(ctx)
+		}
+	}
+}
+
+// Stop stops the outbox processor
+func (p *OutboxProcessor) Stop() {
+	close(p.stopCh)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+// Queue states of an outgoing message that WhatsApp hasn't accepted yet
+const (
+	queueStateQueued   = "queued"   // Waiting for a send slot
+	queueStateRetrying = "retrying" // A send failed; waiting for the next attempt
+	queueStateSending  = "sending"  // On its way to WhatsApp
+)
+
+// queuedOutboxStatuses are the outbox entries still waiting to be accepted by WhatsApp
+var queuedOutboxStatuses = []models.OutboxStatus{
+	models.OutboxStatusPending,
+	models.OutboxStatusProcessing,
+	models.OutboxStatusSending,
+}
+
+// MessageQueueState describes where an outgoing message stands in the send queue of its
+// WhatsApp account
+type MessageQueueState struct {
+	State string `json:"state"`
+	// Position is 1 for the next message the account sends; 0 while sending
+	Position      int        `json:"position,omitempty"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	// Cancellable is true until the message is on its way to WhatsApp
+	Cancellable bool `json:"cancellable"`
+}
+
+// loadQueueStates returns the queue state of each of the given messages still in the
+// outbox. Positions count the queued messages of the same WhatsApp account that are due
+// first.
+func (a *App) loadQueueStates(orgID uuid.UUID, messageIDs []uuid.UUID) map[uuid.UUID]*MessageQueueState {
+	states := make(map[uuid.UUID]*MessageQueueState)
+	if len(messageIDs) == 0 {
+		return states
+	}
+
+	var rows []struct {
+		MessageID     uuid.UUID
+		Status        models.OutboxStatus
+		Attempts      int
+		NextAttemptAt time.Time
+		LastError     string
+		Position      int
+	}
+	if err := a.DB.Raw(`
+		SELECT message_id, status, attempts, next_attempt_at, last_error, position FROM (
+			SELECT o.message_id, o.status, o.attempts, o.next_attempt_at, o.last_error,
+				ROW_NUMBER() OVER (
+					PARTITION BY m.whats_app_account, o.status = @sending
+					ORDER BY o.next_attempt_at, o.created_at
+				) AS position
+			FROM outbox_messages o
+			JOIN messages m ON m.id = o.message_id
+			WHERE o.organization_id = @org AND o.status IN @statuses AND o.deleted_at IS NULL
+		) q
+		WHERE message_id IN @ids`,
+		map[string]any{
+			"org":      orgID,
+			"sending":  models.OutboxStatusSending,
+			"statuses": queuedOutboxStatuses,
+			"ids":      messageIDs,
+		}).Scan(&rows).Error; err != nil {
+		a.Log.Error("Failed to load message queue states", "error", err)
+		return states
+	}
+
+	for _, row := range rows {
+		state := &MessageQueueState{
+			State:       queueStateQueued,
+			Position:    row.Position,
+			Attempts:    row.Attempts,
+			LastError:   row.LastError,
+			Cancellable: true,
+		}
+		switch {
+		case row.Status == models.OutboxStatusSending:
+			state.State = queueStateSending
+			state.Position = 0
+			state.Cancellable = false
+		case row.Status == models.OutboxStatusPending && row.Attempts > 0:
+			state.State = queueStateRetrying
+			nextAttemptAt := row.NextAttemptAt
+			state.NextAttemptAt = &nextAttemptAt
+		}
+		states[row.MessageID] = state
+	}
+	return states
+}
+
+// GetConversationQueue lists a contact's outgoing messages that WhatsApp hasn't accepted
+// yet, oldest first, with their place in the send queue
+func (a *App) GetConversationQueue(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	// Users without full contact access only see their assigned contacts
+	query := a.DB.Model(&models.Contact{}).Where("id = ? AND organization_id = ?", contactID, orgID)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	var count int64
+	if query.Count(&count); count == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var messages []models.Message
+	if err := a.DB.
+		Where("organization_id = ? AND contact_id = ? AND direction = ? AND status = ?",
+			orgID, contactID, models.DirectionOutgoing, models.MessageStatusPending).
+		Where("id IN (?)", a.DB.Model(&models.OutboxMessage{}).Select("message_id").Where("status IN ?", queuedOutboxStatuses)).
+		Order("created_at ASC").
+		Find(&messages).Error; err != nil {
+		a.Log.Error("Failed to list queued messages", "error", err, "contact_id", contactID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list queued messages", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"messages": a.buildMessagesResponse(messages),
+		"total":    len(messages),
+	})
+}
+
+// CancelMessage retracts an outgoing message that is still waiting in the send queue.
+// Messages already on their way to WhatsApp can't be cancelled.
+func (a *App) CancelMessage(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	messageID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid message ID", nil, "")
+	}
+
+	msg, err := a.findUserOutgoingMessage(orgID, userID, messageID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Message not found", nil, "")
+	}
+	if msg.Direction != models.DirectionOutgoing || msg.Status != models.MessageStatusPending {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Only queued outgoing messages can be cancelled", nil, "")
+	}
+
+	// Removing the entry is what stops the send; a sender that already claimed it finds
+	// it gone once it gets a send slot
+	var removed []models.OutboxMessage
+	result := a.DB.Unscoped().Clauses(clause.Returning{}).
+		Where("message_id = ? AND status IN ?", msg.ID,
+			[]models.OutboxStatus{models.OutboxStatusPending, models.OutboxStatusProcessing}).
+		Delete(&removed)
+	if result.Error != nil {
+		a.Log.Error("Failed to remove outbox entry", "error", result.Error, "message_id", msg.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to cancel message", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Message is already being sent and can't be cancelled", nil, "")
+	}
+
+	now := time.Now()
+	if err := a.DB.Model(msg).Update("status", models.MessageStatusCancelled).Error; err != nil {
+		a.Log.Error("Failed to mark message cancelled", "error", err, "message_id", msg.ID)
+	}
+	a.recordMessageStatus(msg, models.MessageStatusCancelled, now, "")
+	a.Log.Info("Outgoing message cancelled", "message_id", msg.ID, "user_id", userID)
+
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
+			Type: websocket.TypeStatusUpdate,
+			Payload: map[string]any{
+				"message_id": msg.ID.String(),
+				"status":     models.MessageStatusCancelled,
+				"timestamp":  now,
+			},
+		})
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"message_id": msg.ID,
+		"status":     models.MessageStatusCancelled,
+	})
+}
+
+// findUserOutgoingMessage loads a message of the organization that the user may act on:
+// users without full contact access only reach messages of their assigned contacts
+func (a *App) findUserOutgoingMessage(orgID, userID, messageID uuid.UUID) (*models.Message, error) {
+	var msg models.Message
+	if err := a.DB.Where("id = ? AND organization_id = ?", messageID, orgID).First(&msg).Error; err != nil {
+		return nil, err
+	}
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		var contact models.Contact
+		if err := a.DB.Select("id").Where("id = ? AND assigned_user_id = ?", msg.ContactID, userID).First(&contact).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &msg, nil
+}
@@ -0,0 +1,109 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_ConversationQueue(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("queue-admin"), "password123", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+
+	// Queue messages as a send backlog leaves them: waiting in the outbox, oldest first
+	queue := func(content string, status models.OutboxStatus, dueIn time.Duration) models.Message {
+		msg := models.Message{
+			BaseModel:       models.BaseModel{ID: uuid.New()},
+			OrganizationID:  org.ID,
+			WhatsAppAccount: account.Name,
+			ContactID:       contact.ID,
+			Direction:       models.DirectionOutgoing,
+			MessageType:     models.MessageTypeText,
+			Content:         content,
+			Status:          models.MessageStatusPending,
+		}
+		require.NoError(t, app.DB.Create(&msg).Error)
+		require.NoError(t, app.DB.Create(&models.OutboxMessage{
+			OrganizationID: org.ID,
+			MessageID:      msg.ID,
+			Payload:        models.JSONB{"type": "text", "content": content},
+			Status:         status,
+			NextAttemptAt:  time.Now().Add(dueIn),
+		}).Error)
+		return msg
+	}
+	sending := queue("Sending now", models.OutboxStatusSending, -time.Minute)
+	first := queue("First", models.OutboxStatusProcessing, 0)
+	second := queue("Second", models.OutboxStatusPending, time.Minute)
+
+	listQueue := func() []handlers.MessageResponse {
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", contact.ID.String())
+		require.NoError(t, app.GetConversationQueue(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp struct {
+			Data struct {
+				Messages []handlers.MessageResponse `json:"messages"`
+			} `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data.Messages
+	}
+
+	messages := listQueue()
+	require.Len(t, messages, 3)
+	queueOf := make(map[uuid.UUID]*handlers.MessageQueueState)
+	for _, m := range messages {
+		require.NotNil(t, m.Queue, m.ID)
+		queueOf[m.ID] = m.Queue
+	}
+	assert.Equal(t, "sending", queueOf[sending.ID].State)
+	assert.False(t, queueOf[sending.ID].Cancellable)
+	assert.Equal(t, 1, queueOf[first.ID].Position)
+	assert.Equal(t, 2, queueOf[second.ID].Position)
+	assert.True(t, queueOf[first.ID].Cancellable)
+
+	cancel := func(id uuid.UUID) int {
+		req := testutil.NewJSONRequest(t, nil)
+		setAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", id.String())
+		require.NoError(t, app.CancelMessage(req))
+		return testutil.GetResponseStatusCode(req)
+	}
+
+	// A queued message is retracted and the rest of the queue moves up
+	require.Equal(t, fasthttp.StatusOK, cancel(first.ID))
+	var cancelled models.Message
+	require.NoError(t, app.DB.First(&cancelled, first.ID).Error)
+	assert.Equal(t, models.MessageStatusCancelled, cancelled.Status)
+	var count int64
+	app.DB.Unscoped().Model(&models.OutboxMessage{}).Where("message_id = ?", first.ID).Count(&count)
+	assert.Zero(t, count)
+
+	messages = listQueue()
+	require.Len(t, messages, 2)
+	for _, m := range messages {
+		if m.ID == second.ID {
+			assert.Equal(t, 1, m.Queue.Position)
+		}
+	}
+
+	// Messages on their way to WhatsApp, or already cancelled, can't be cancelled
+	assert.Equal(t, fasthttp.StatusConflict, cancel(sending.ID))
+	assert.Equal(t, fasthttp.StatusBadRequest, cancel(first.ID))
+	assert.Empty(t, mockServer.sentMessages)
+}
@@ -0,0 +1,143 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// newScriptedWhatsAppServer answers message sends with the Meta error code returned by
+// errorCode, or accepts them when it returns 0
+func newScriptedWhatsAppServer(errorCode func() int) *mockWhatsAppServer {
+	return &mockWhatsAppServer{
+		server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if code := errorCode(); code != 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"error": map[string]any{"message": "Send failed", "code": code},
+				})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"messages": []map[string]string{{"id": "wamid.outbox"}},
+			})
+		})),
+	}
+}
+
+func TestApp_Outbox_RetriesTemporaryFailures(t *testing.T) {
+	var calls atomic.Int32
+	mockServer := newScriptedWhatsAppServer(func() int {
+		if calls.Add(1) == 1 {
+			return 131000 // Something went wrong, retryable
+		}
+		return 0
+	})
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+
+	msg, err := app.SendOutgoingMessage(testutil.TestContext(t), handlers.OutgoingMessageRequest{
+		Account: account,
+		Contact: contact,
+		Type:    models.MessageTypeText,
+		Content: "Hello!",
+	}, handlers.ChatbotSendOptions())
+	require.NoError(t, err)
+
+	// The failed attempt leaves the message pending with a retry scheduled
+	var dbMsg models.Message
+	require.NoError(t, app.DB.First(&dbMsg, msg.ID).Error)
+	assert.Equal(t, models.MessageStatusPending, dbMsg.Status)
+
+	var entry models.OutboxMessage
+	require.NoError(t, app.DB.Where("message_id = ?", msg.ID).First(&entry).Error)
+	assert.Equal(t, models.OutboxStatusPending, entry.Status)
+	assert.Equal(t, 1, entry.Attempts)
+	assert.True(t, entry.NextAttemptAt.After(time.Now()))
+
+	// Once due, the processor sends it and clears the entry
+	require.NoError(t, app.DB.Model(&entry).Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handlers.NewOutboxProcessor(app, 10*time.Millisecond).Start(ctx)
+
+	require.Eventually(t, func() bool {
+		var current models.Message
+		app.DB.First(&current, msg.ID)
+		return current.Status == models.MessageStatusSent
+	}, 5*time.Second, 20*time.Millisecond)
+
+	var remaining int64
+	app.DB.Unscoped().Model(&models.OutboxMessage{}).Where("message_id = ?", msg.ID).Count(&remaining)
+	assert.Zero(t, remaining)
+}
+
+func TestApp_Outbox_DeadLetterAndManualRetry(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	mockServer := newScriptedWhatsAppServer(func() int {
+		if failing.Load() {
+			return 131026 // Undeliverable, not worth retrying on its own
+		}
+		return 0
+	})
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("outbox-retry"), "password", &role.ID, true)
+
+	msg, err := app.SendOutgoingMessage(testutil.TestContext(t), handlers.OutgoingMessageRequest{
+		Account: account,
+		Contact: contact,
+		Type:    models.MessageTypeText,
+		Content: "Hello!",
+	}, handlers.ChatbotSendOptions())
+	require.NoError(t, err)
+
+	var dbMsg models.Message
+	require.NoError(t, app.DB.First(&dbMsg, msg.ID).Error)
+	assert.Equal(t, models.MessageStatusFailed, dbMsg.Status)
+
+	var entry models.OutboxMessage
+	require.NoError(t, app.DB.Where("message_id = ?", msg.ID).First(&entry).Error)
+	assert.Equal(t, models.OutboxStatusDead, entry.Status)
+
+	retry := func() *fasthttp.RequestCtx {
+		req := testutil.NewJSONRequest(t, nil)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", msg.ID.String())
+		require.NoError(t, app.RetryMessage(req))
+		return req.RequestCtx
+	}
+
+	failing.Store(false)
+	assert.Equal(t, fasthttp.StatusOK, retry().Response.StatusCode())
+	app.WaitForBackgroundTasks()
+
+	require.NoError(t, app.DB.First(&dbMsg, msg.ID).Error)
+	assert.Equal(t, models.MessageStatusSent, dbMsg.Status)
+	assert.Empty(t, dbMsg.ErrorMessage)
+
+	// Only failed messages can be retried
+	assert.Equal(t, fasthttp.StatusBadRequest, retry().Response.StatusCode())
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/mailer"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// passwordResetTTL is how long a password reset link stays valid
+	passwordResetTTL = time.Hour
+	// passwordResetCooldown limits how often reset emails are sent to one user
+	passwordResetCooldown = 2 * time.Minute
+	// invitationPasswordTTL is how long the set-password link in an invitation stays valid
+	invitationPasswordTTL = 7 * 24 * time.Hour
+)
+
+// ForgotPasswordRequest requests a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password using a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// forgotPasswordMessage is returned whether or not the account exists, so the endpoint
+// can't be used to discover registered emails
+const forgotPasswordMessage = "If an account exists for this email, a reset link has been sent"
+
+// ForgotPassword emails a password reset link through the user's organization mail server
+func (a *App) ForgotPassword(r *fastglue.Request) error {
+	var req ForgotPasswordRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Email is required", nil, "")
+	}
+
+	var user models.User
+	if err := a.DB.Where("email = ? AND is_active = ?", email, true).First(&user).Error; err != nil {
+		return r.SendEnvelope(map[string]string{"message": forgotPasswordMessage})
+	}
+
+	// Throttle repeated requests for the same account
+	ok, err := a.Redis.SetNX(r.RequestCtx, "password_reset:cooldown:"+user.ID.String(), 1, passwordResetCooldown).Result()
+	if err != nil {
+		a.Log.Error("Failed to store password reset cooldown", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start password reset", nil, "")
+	}
+	if !ok {
+		return r.SendEnvelope(map[string]string{"message": forgotPasswordMessage})
+	}
+
+	tokenBytes := make([]byte, 32)
+	_, _ = rand.Read(tokenBytes)
+	token := hex.EncodeToString(tokenBytes)
+
+	if err := a.Redis.Set(r.RequestCtx, passwordResetKey(token), user.ID.String(), passwordResetTTL).Err(); err != nil {
+		a.Log.Error("Failed to store password reset token", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start password reset", nil, "")
+	}
+
+	a.sendOrgEmailAsync(user.OrganizationID, []string{user.Email}, mailer.TemplatePasswordReset, map[string]interface{}{
+		"Name":      user.FullName,
+		"Email":     user.Email,
+		"ResetURL":  a.frontendURL(r, "/reset-password?token="+url.QueryEscape(token)),
+		"ExpiresIn": "1 hour",
+	})
+
+	return r.SendEnvelope(map[string]string{"message": forgotPasswordMessage})
+}
+
+// ResetPassword sets a new password using a token from a reset email. Tokens are single use.
+func (a *App) ResetPassword(r *fastglue.Request) error {
+	var req ResetPasswordRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Token == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Token is required", nil, "")
+	}
+	if len(req.Password) < 8 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Password must be at least 8 characters", nil, "")
+	}
+
+	userIDStr, err := a.Redis.GetDel(r.RequestCtx, passwordResetKey(req.Token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Reset link is invalid or has expired", nil, "")
+	}
+	if err != nil {
+		a.Log.Error("Failed to read password reset token", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reset password", nil, "")
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Reset link is invalid or has expired", nil, "")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		a.Log.Error("Failed to hash password", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reset password", nil, "")
+	}
+
+	result := a.DB.Model(&models.User{}).Where("id = ? AND is_active = ?", userID, true).
+		Update("password_hash", string(hashedPassword))
+	if result.Error != nil {
+		a.Log.Error("Failed to reset password", "error", result.Error, "user_id", userID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reset password", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Reset link is invalid or has expired", nil, "")
+	}
+
+	a.Log.Info("Password reset", "user_id", userID)
+	return r.SendEnvelope(map[string]string{"message": "Password has been reset. You can now sign in."})
+}
+
+// passwordSetupURL stores a single use token that lets an invited user choose their
+// password through ResetPassword. It returns "" when the token can't be stored.
+func (a *App) passwordSetupURL(r *fastglue.Request, userID uuid.UUID) string {
+	if a.Redis == nil {
+		return ""
+	}
+	tokenBytes := make([]byte, 32)
+	_, _ = rand.Read(tokenBytes)
+	token := hex.EncodeToString(tokenBytes)
+
+	if err := a.Redis.Set(r.RequestCtx, passwordResetKey(token), userID.String(), invitationPasswordTTL).Err(); err != nil {
+		a.Log.Error("Failed to store invitation password token", "error", err, "user_id", userID)
+		return ""
+	}
+	return a.frontendURL(r, "/reset-password?token="+url.QueryEscape(token))
+}
+
+// passwordResetKey stores tokens hashed so a Redis dump doesn't leak usable links
+func passwordResetKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "password_reset:" + hex.EncodeToString(sum[:])
+}
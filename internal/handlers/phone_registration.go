@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// pinPattern matches a six digit two-step verification PIN
+var pinPattern = regexp.MustCompile(`^\d{6}$`)
+
+// PhoneNumberPINRequest represents a request carrying a two-step verification PIN
+type PhoneNumberPINRequest struct {
+	PIN string `json:"pin"`
+}
+
+// GetPhoneNumberRegistration returns the registration and verification status of an account's phone number
+func (a *App) GetPhoneNumberRegistration(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	status, err := a.WhatsApp.GetPhoneNumberStatus(context.Background(), a.toWhatsAppAccount(account))
+	if err != nil {
+		a.Log.Error("Failed to fetch phone number status", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch phone number status: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"registration": status,
+		"registered":   status.PlatformType == "CLOUD_API",
+	})
+}
+
+// RegisterPhoneNumber registers an account's phone number with the Cloud API
+func (a *App) RegisterPhoneNumber(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var req PhoneNumberPINRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if !pinPattern.MatchString(req.PIN) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "PIN must be exactly 6 digits", nil, "")
+	}
+
+	if err := a.WhatsApp.RegisterPhoneNumber(context.Background(), a.toWhatsAppAccount(account), req.PIN); err != nil {
+		a.Log.Error("Failed to register phone number", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to register phone number: "+err.Error(), nil, "")
+	}
+
+	a.setAccountStatus(account, "active")
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Phone number registered successfully",
+	})
+}
+
+// DeregisterPhoneNumber deregisters an account's phone number from the Cloud API
+func (a *App) DeregisterPhoneNumber(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	if err := a.WhatsApp.DeregisterPhoneNumber(context.Background(), a.toWhatsAppAccount(account)); err != nil {
+		a.Log.Error("Failed to deregister phone number", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to deregister phone number: "+err.Error(), nil, "")
+	}
+
+	a.setAccountStatus(account, "inactive")
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Phone number deregistered successfully",
+	})
+}
+
+// SetTwoStepPIN sets or changes the two-step verification PIN of an account's phone number.
+// The PIN is sent to Meta only and never stored.
+func (a *App) SetTwoStepPIN(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var req PhoneNumberPINRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if !pinPattern.MatchString(req.PIN) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "PIN must be exactly 6 digits", nil, "")
+	}
+
+	if err := a.WhatsApp.SetTwoStepPIN(context.Background(), a.toWhatsAppAccount(account), req.PIN); err != nil {
+		a.Log.Error("Failed to set two-step verification PIN", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to set two-step verification PIN: "+err.Error(), nil, "")
+	}
+
+	a.Log.Info("Two-step verification PIN changed", "account", account.Name, "user_id", userID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Two-step verification PIN updated successfully",
+	})
+}
+
+// setAccountStatus updates the local account status and drops the cached copy
+func (a *App) setAccountStatus(account *models.WhatsAppAccount, status string) {
+	if err := a.DB.Model(account).Update("status", status).Error; err != nil {
+		a.Log.Error("Failed to update account status", "error", err, "account", account.Name)
+		return
+	}
+	a.InvalidateWhatsAppAccountCache(account.PhoneID)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/plugins"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+// pluginHookTimeout bounds a whole round of asynchronous hooks
+const pluginHookTimeout = 30 * time.Second
+
+// PluginResponse represents an installed plugin and the organization's settings for it
+type PluginResponse struct {
+	Name        string       `json:"name"`
+	Version     string       `json:"version"`
+	Description string       `json:"description"`
+	Hooks       []string     `json:"hooks"`
+	Enabled     bool         `json:"enabled"`
+	Config      models.JSONB `json:"config"`
+	UpdatedAt   *time.Time   `json:"updated_at,omitempty"`
+}
+
+// PluginConfigRequest represents the request body for configuring a plugin
+type PluginConfigRequest struct {
+	Enabled bool         `json:"enabled"`
+	Config  models.JSONB `json:"config"`
+}
+
+// ListPlugins returns the installed plugins with the organization's settings
+func (a *App) ListPlugins(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var settings []models.OrganizationPlugin
+	if err := a.DB.Where("organization_id = ?", orgID).Find(&settings).Error; err != nil {
+		a.Log.Error("Failed to list plugin settings", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list plugins", nil, "")
+	}
+	byName := make(map[string]models.OrganizationPlugin, len(settings))
+	for _, s := range settings {
+		byName[s.PluginName] = s
+	}
+
+	result := []PluginResponse{}
+	if a.Plugins != nil {
+		for _, p := range a.Plugins.List() {
+			info := p.Info()
+			resp := PluginResponse{
+				Name:        info.Name,
+				Version:     info.Version,
+				Description: info.Description,
+				Hooks:       plugins.Hooks(p),
+				Config:      models.JSONB{},
+			}
+			if s, ok := byName[info.Name]; ok {
+				resp.Enabled = s.Enabled
+				if s.Config != nil {
+					resp.Config = s.Config
+				}
+				updatedAt := s.UpdatedAt
+				resp.UpdatedAt = &updatedAt
+			}
+			result = append(result, resp)
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"plugins": result,
+	})
+}
+
+// UpdatePluginConfig enables or disables a plugin for the organization and saves its settings
+func (a *App) UpdatePluginConfig(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	name, _ := r.RequestCtx.UserValue("name").(string)
+	if a.Plugins == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Plugin not found", nil, "")
+	}
+	if _, ok := a.Plugins.Get(name); !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Plugin not found", nil, "")
+	}
+
+	var req PluginConfigRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Config == nil {
+		req.Config = models.JSONB{}
+	}
+
+	setting := models.OrganizationPlugin{
+		OrganizationID: orgID,
+		PluginName:     name,
+		Enabled:        req.Enabled,
+		Config:         req.Config,
+	}
+	if err := a.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}, {Name: "plugin_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "config", "updated_at"}),
+	}).Create(&setting).Error; err != nil {
+		a.Log.Error("Failed to save plugin settings", "error", err, "plugin", name)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save plugin settings", nil, "")
+	}
+
+	a.InvalidateOrgPluginsCache(orgID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Plugin settings saved",
+		"name":    name,
+		"enabled": req.Enabled,
+	})
+}
+
+// runPreSendPlugins lets enabled plugins rewrite or reject an outgoing message
+func (a *App) runPreSendPlugins(ctx context.Context, req *OutgoingMessageRequest, opts MessageSendOptions) error {
+	enabled := a.getOrgPlugins(req.Account.OrganizationID)
+	if len(enabled) == 0 {
+		return nil
+	}
+
+	msg := plugins.OutgoingMessage{
+		OrganizationID:  req.Account.OrganizationID,
+		ContactID:       req.Contact.ID,
+		ContactPhone:    req.Contact.PhoneNumber,
+		WhatsAppAccount: req.Account.Name,
+		Type:            string(req.Type),
+		SentByUserID:    opts.SentByUserID,
+	}
+	content := outgoingPluginContent(req)
+	if content != nil {
+		msg.Content = *content
+	}
+
+	if err := a.Plugins.BeforeSend(ctx, enabled, &msg); err != nil {
+		return err
+	}
+	if content != nil {
+		*content = msg.Content
+	}
+	return nil
+}
+
+// outgoingPluginContent points at the editable text of an outgoing message, or nil for
+// message types without free text (templates, flows)
+func outgoingPluginContent(req *OutgoingMessageRequest) *string {
+	switch req.Type {
+	case models.MessageTypeText:
+		return &req.Content
+	case models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeDocument:
+		return &req.Caption
+	case models.MessageTypeInteractive:
+		return &req.BodyText
+	}
+	return nil
+}
+
+// notifyPluginsMessageReceived runs the message-received hooks in the background
+func (a *App) notifyPluginsMessageReceived(ev plugins.MessageEvent) {
+	a.runPluginHooksAsync(ev.OrganizationID, func(ctx context.Context, enabled map[string]plugins.Config) {
+		a.Plugins.MessageReceived(ctx, enabled, ev)
+	})
+}
+
+// notifyPluginsFlowStep runs the flow-step hooks in the background
+func (a *App) notifyPluginsFlowStep(ev plugins.FlowStepEvent) {
+	if a.simulation(ev.SessionID) != nil {
+		return
+	}
+	a.runPluginHooksAsync(ev.OrganizationID, func(ctx context.Context, enabled map[string]plugins.Config) {
+		a.Plugins.FlowStepExecuted(ctx, enabled, ev)
+	})
+}
+
+// notifyPluginsContactCreated runs the contact-created hooks in the background
+func (a *App) notifyPluginsContactCreated(ev plugins.ContactEvent) {
+	a.runPluginHooksAsync(ev.OrganizationID, func(ctx context.Context, enabled map[string]plugins.Config) {
+		a.Plugins.ContactCreated(ctx, enabled, ev)
+	})
+}
+
+func (a *App) runPluginHooksAsync(orgID uuid.UUID, run func(ctx context.Context, enabled map[string]plugins.Config)) {
+	enabled := a.getOrgPlugins(orgID)
+	if len(enabled) == 0 {
+		return
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), pluginHookTimeout)
+		defer cancel()
+		run(ctx, enabled)
+	}()
+}
+
+// getOrgPlugins returns the organization's enabled plugins with their settings
+func (a *App) getOrgPlugins(orgID uuid.UUID) map[string]plugins.Config {
+	if a.Plugins == nil || a.Plugins.Len() == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s", orgPluginsCachePrefix, orgID.String())
+
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			var enabled map[string]plugins.Config
+			if err := json.Unmarshal([]byte(cached), &enabled); err == nil {
+				return enabled
+			}
+		}
+	}
+
+	var settings []models.OrganizationPlugin
+	if err := a.DB.Where("organization_id = ? AND enabled = ?", orgID, true).Find(&settings).Error; err != nil {
+		a.Log.Error("Failed to load plugin settings", "error", err, "organization_id", orgID)
+		return nil
+	}
+
+	enabled := make(map[string]plugins.Config, len(settings))
+	for _, s := range settings {
+		cfg := plugins.Config{}
+		for k, v := range s.Config {
+			cfg[k] = v
+		}
+		enabled[s.PluginName] = cfg
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(enabled); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgPluginsCacheTTL)
+		}
+	}
+	return enabled
+}
+
+// InvalidateOrgPluginsCache clears the cached plugin settings for an organization
+func (a *App) InvalidateOrgPluginsCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	a.Redis.Del(context.Background(), orgPluginsCachePrefix+orgID.String())
+}
@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/plugins"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// signaturePlugin appends a configured signature and rejects messages containing "forbidden"
+type signaturePlugin struct{}
+
+func (signaturePlugin) Info() plugins.Info {
+	return plugins.Info{Name: "signature", Version: "1.0.0", Description: "Appends a signature"}
+}
+
+func (signaturePlugin) BeforeSend(_ context.Context, cfg plugins.Config, msg *plugins.OutgoingMessage) error {
+	if strings.Contains(msg.Content, "forbidden") {
+		return plugins.Reject("forbidden word")
+	}
+	if sig, ok := cfg["signature"].(string); ok {
+		msg.Content += "\n" + sig
+	}
+	return nil
+}
+
+func enablePlugin(t *testing.T, app *handlers.App, user *models.User, name string, enabled bool, config map[string]any) int {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, map[string]any{"enabled": enabled, "config": config})
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	req.RequestCtx.SetUserValue("organization_id", user.OrganizationID)
+	testutil.SetPathParam(req, "name", name)
+	require.NoError(t, app.UpdatePluginConfig(req))
+	return testutil.GetResponseStatusCode(req)
+}
+
+func TestApp_Plugins_PreSendHook(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	app.Plugins = plugins.NewManager(0, nil)
+	require.NoError(t, app.Plugins.Register(signaturePlugin{}))
+
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+	ctx := testutil.TestContext(t)
+
+	send := func(content string) (*models.Message, error) {
+		return app.SendOutgoingMessage(ctx, handlers.OutgoingMessageRequest{
+			Account: account,
+			Contact: contact,
+			Type:    models.MessageTypeText,
+			Content: content,
+		}, handlers.ChatbotSendOptions())
+	}
+
+	// Not enabled for the organization yet
+	msg, err := send("Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", msg.Content)
+
+	require.Equal(t, fasthttp.StatusOK, enablePlugin(t, app, admin, "signature", true, map[string]any{"signature": "- Acme"}))
+
+	msg, err = send("Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello\n- Acme", msg.Content)
+
+	_, err = send("a forbidden word")
+	assert.True(t, errors.Is(err, plugins.ErrRejected))
+
+	// Disabling stops the hook
+	require.Equal(t, fasthttp.StatusOK, enablePlugin(t, app, admin, "signature", false, nil))
+	msg, err = send("Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", msg.Content)
+}
+
+func TestApp_Plugins_ListAndUnknown(t *testing.T) {
+	app := testApp(t)
+	app.Plugins = plugins.NewManager(0, nil)
+	require.NoError(t, app.Plugins.Register(signaturePlugin{}))
+
+	org := createTestOrganization(t, app)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+
+	assert.Equal(t, fasthttp.StatusNotFound, enablePlugin(t, app, admin, "missing", true, nil))
+	require.Equal(t, fasthttp.StatusOK, enablePlugin(t, app, admin, "signature", true, map[string]any{"signature": "Thanks"}))
+
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.ListPlugins(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data struct {
+			Plugins []handlers.PluginResponse `json:"plugins"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	require.Len(t, resp.Data.Plugins, 1)
+	assert.Equal(t, "signature", resp.Data.Plugins[0].Name)
+	assert.True(t, resp.Data.Plugins[0].Enabled)
+	assert.Equal(t, []string{plugins.HookPreSend}, resp.Data.Plugins[0].Hooks)
+	assert.Equal(t, "Thanks", resp.Data.Plugins[0].Config["signature"])
+}
+
+func TestApp_Plugins_RequiresPermission(t *testing.T) {
+	app := testApp(t)
+	app.Plugins = plugins.NewManager(0, nil)
+	require.NoError(t, app.Plugins.Register(signaturePlugin{}))
+
+	org := createTestOrganization(t, app)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("plugin-agent"), "password123", nil, true)
+
+	assert.Equal(t, fasthttp.StatusForbidden, enablePlugin(t, app, agent, "signature", true, nil))
+}
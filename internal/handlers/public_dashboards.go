@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// publicDashboardViewPath is the frontend page that displays a published dashboard
+	publicDashboardViewPath = "/public/dashboards/"
+	// Bounds of a dashboard's auto-refresh interval, in seconds
+	publicDashboardDefaultRefresh = 60
+	publicDashboardMinRefresh     = 15
+	publicDashboardMaxRefresh     = 3600
+	// publicDashboardCampaignLimit caps how many campaigns the campaigns widget shows
+	publicDashboardCampaignLimit = 10
+	// publicDashboardRecentCampaigns is how long a finished campaign stays on the campaigns widget
+	publicDashboardRecentCampaigns = 24 * time.Hour
+	// publicDashboardViewInterval throttles last_viewed_at writes from auto-refreshing screens
+	publicDashboardViewInterval = time.Minute
+)
+
+// Public dashboard widgets
+const (
+	PublicWidgetOverview      = "overview"
+	PublicWidgetCampaigns     = "campaigns"
+	PublicWidgetTransferQueue = "transfer_queue"
+)
+
+var publicDashboardWidgets = []string{PublicWidgetOverview, PublicWidgetCampaigns, PublicWidgetTransferQueue}
+
+// PublicDashboardRequest creates or updates a public dashboard
+type PublicDashboardRequest struct {
+	Name           string   `json:"name"`
+	Widgets        []string `json:"widgets"`
+	RefreshSeconds int      `json:"refresh_seconds"`
+	IsActive       *bool    `json:"is_active"` // Updates only; new dashboards are active
+}
+
+// PublicDashboardResponse describes a public dashboard to the organization's managers
+type PublicDashboardResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Name           string     `json:"name"`
+	Widgets        []string   `json:"widgets"`
+	RefreshSeconds int        `json:"refresh_seconds"`
+	IsActive       bool       `json:"is_active"`
+	URL            string     `json:"url"`
+	LastViewedAt   *time.Time `json:"last_viewed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PublicDashboardView is the data shown on a published dashboard. It only holds
+// aggregates and campaign names, never contact details or message content.
+type PublicDashboardView struct {
+	Name           string                     `json:"name"`
+	Organization   string                     `json:"organization"`
+	RefreshSeconds int                        `json:"refresh_seconds"`
+	GeneratedAt    time.Time                  `json:"generated_at"`
+	Widgets        []string                   `json:"widgets"`
+	Overview       *PublicOverviewWidget      `json:"overview,omitempty"`
+	Campaigns      []PublicCampaignWidget     `json:"campaigns,omitempty"`
+	TransferQueue  *PublicTransferQueueWidget `json:"transfer_queue,omitempty"`
+}
+
+// PublicOverviewWidget is the month-to-date dashboard summary
+type PublicOverviewWidget struct {
+	PeriodStart time.Time `json:"period_start"`
+	DashboardStats
+}
+
+// PublicCampaignWidget is the progress of a running or recently finished campaign
+type PublicCampaignWidget struct {
+	Name            string                `json:"name"`
+	Status          models.CampaignStatus `json:"status"`
+	TotalRecipients int                   `json:"total_recipients"`
+	SentCount       int                   `json:"sent_count"`
+	DeliveredCount  int                   `json:"delivered_count"`
+	ReadCount       int                   `json:"read_count"`
+	FailedCount     int                   `json:"failed_count"`
+	StartedAt       *time.Time            `json:"started_at,omitempty"`
+	CompletedAt     *time.Time            `json:"completed_at,omitempty"`
+}
+
+// PublicTransferQueueWidget is the live state of the agent transfer queue
+type PublicTransferQueueWidget struct {
+	Waiting            int64 `json:"waiting"`              // Transfers no agent has picked up
+	WaitingUrgent      int64 `json:"waiting_urgent"`       // Waiting transfers marked urgent
+	WaitingHigh        int64 `json:"waiting_high"`         // Waiting transfers marked high priority
+	LongestWaitSeconds int64 `json:"longest_wait_seconds"` // Age of the oldest waiting transfer
+	InProgress         int64 `json:"in_progress"`          // Active transfers assigned to an agent
+	SLABreached        int64 `json:"sla_breached"`         // Active transfers past their SLA
+	AgentsAvailable    int64 `json:"agents_available"`
+}
+
+// ListPublicDashboards returns the organization's public dashboards
+func (a *App) ListPublicDashboards(r *fastglue.Request) error {
+	orgID, ok := a.requirePublicDashboardAccess(r)
+	if !ok {
+		return nil
+	}
+
+	var dashboards []models.PublicDashboard
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&dashboards).Error; err != nil {
+		a.Log.Error("Failed to list public dashboards", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list public dashboards", nil, "")
+	}
+
+	result := make([]PublicDashboardResponse, len(dashboards))
+	for i := range dashboards {
+		result[i] = a.publicDashboardToResponse(r, &dashboards[i])
+	}
+	return r.SendEnvelope(map[string]interface{}{"dashboards": result})
+}
+
+// CreatePublicDashboard publishes a set of analytics widgets at a new token URL
+func (a *App) CreatePublicDashboard(r *fastglue.Request) error {
+	orgID, ok := a.requirePublicDashboardAccess(r)
+	if !ok {
+		return nil
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req PublicDashboardRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.RefreshSeconds == 0 {
+		req.RefreshSeconds = publicDashboardDefaultRefresh
+	}
+	if msg := validatePublicDashboard(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	dashboard := models.PublicDashboard{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Widgets:        widgetsToJSONB(req.Widgets),
+		RefreshSeconds: req.RefreshSeconds,
+		IsActive:       true,
+		Token:          generateVerifyToken(),
+		CreatedByID:    userID,
+	}
+	if err := a.DB.Create(&dashboard).Error; err != nil {
+		a.Log.Error("Failed to create public dashboard", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create public dashboard", nil, "")
+	}
+
+	return r.SendEnvelope(a.publicDashboardToResponse(r, &dashboard))
+}
+
+// UpdatePublicDashboard changes a public dashboard's widgets or refresh interval, or
+// turns its link off and on. The link itself stays the same.
+func (a *App) UpdatePublicDashboard(r *fastglue.Request) error {
+	dashboard, ok := a.loadPublicDashboard(r)
+	if !ok {
+		return nil
+	}
+
+	var req PublicDashboardRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.RefreshSeconds == 0 {
+		req.RefreshSeconds = dashboard.RefreshSeconds
+	}
+	if msg := validatePublicDashboard(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	dashboard.Name = req.Name
+	dashboard.Widgets = widgetsToJSONB(req.Widgets)
+	dashboard.RefreshSeconds = req.RefreshSeconds
+	if req.IsActive != nil {
+		dashboard.IsActive = *req.IsActive
+	}
+	if err := a.DB.Save(dashboard).Error; err != nil {
+		a.Log.Error("Failed to update public dashboard", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update public dashboard", nil, "")
+	}
+
+	return r.SendEnvelope(a.publicDashboardToResponse(r, dashboard))
+}
+
+// RotatePublicDashboardToken replaces a dashboard's link, so screens using the old
+// link stop receiving data
+func (a *App) RotatePublicDashboardToken(r *fastglue.Request) error {
+	dashboard, ok := a.loadPublicDashboard(r)
+	if !ok {
+		return nil
+	}
+
+	dashboard.Token = generateVerifyToken()
+	if err := a.DB.Save(dashboard).Error; err != nil {
+		a.Log.Error("Failed to rotate public dashboard token", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to rotate link", nil, "")
+	}
+
+	return r.SendEnvelope(a.publicDashboardToResponse(r, dashboard))
+}
+
+// DeletePublicDashboard removes a public dashboard and revokes its link
+func (a *App) DeletePublicDashboard(r *fastglue.Request) error {
+	dashboard, ok := a.loadPublicDashboard(r)
+	if !ok {
+		return nil
+	}
+
+	if err := a.DB.Delete(dashboard).Error; err != nil {
+		a.Log.Error("Failed to delete public dashboard", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete public dashboard", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Public dashboard deleted successfully"})
+}
+
+// GetPublicDashboardView returns a published dashboard's data. The route is public: the
+// token in the path is the only credential.
+func (a *App) GetPublicDashboardView(r *fastglue.Request) error {
+	token, _ := r.RequestCtx.UserValue("token").(string)
+	if token == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Dashboard not found", nil, "")
+	}
+
+	var dashboard models.PublicDashboard
+	if err := a.DB.Where("token = ? AND is_active = ?", token, true).First(&dashboard).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Dashboard not found", nil, "")
+	}
+	if a.IsOrganizationSuspended(dashboard.OrganizationID) {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Dashboard not found", nil, "")
+	}
+
+	var org models.Organization
+	a.DB.Select("id, name").Where("id = ?", dashboard.OrganizationID).First(&org)
+
+	now := time.Now()
+	view := PublicDashboardView{
+		Name:           dashboard.Name,
+		Organization:   org.Name,
+		RefreshSeconds: dashboard.RefreshSeconds,
+		GeneratedAt:    now,
+		Widgets:        widgetsFromJSONB(dashboard.Widgets),
+	}
+	for _, widget := range view.Widgets {
+		switch widget {
+		case PublicWidgetOverview:
+			loc := a.getOrgLocation(dashboard.OrganizationID)
+			local := now.In(loc)
+			start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+			view.Overview = &PublicOverviewWidget{
+				PeriodStart:    start,
+				DashboardStats: a.computeDashboardStats(dashboard.OrganizationID, start, local),
+			}
+		case PublicWidgetCampaigns:
+			view.Campaigns = a.publicCampaignWidget(dashboard.OrganizationID, now)
+		case PublicWidgetTransferQueue:
+			view.TransferQueue = a.publicTransferQueueWidget(dashboard.OrganizationID, now)
+		}
+	}
+
+	if dashboard.LastViewedAt == nil || now.Sub(*dashboard.LastViewedAt) >= publicDashboardViewInterval {
+		a.DB.Model(&dashboard).UpdateColumn("last_viewed_at", now)
+	}
+
+	return r.SendEnvelope(view)
+}
+
+// publicCampaignWidget lists campaigns that are running, waiting to run, or finished
+// within publicDashboardRecentCampaigns, most recently started first
+func (a *App) publicCampaignWidget(orgID uuid.UUID, now time.Time) []PublicCampaignWidget {
+	var campaigns []models.BulkMessageCampaign
+	a.DB.Where("organization_id = ?", orgID).
+		Where("status IN ? OR (status IN ? AND completed_at >= ?)",
+			[]models.CampaignStatus{models.CampaignStatusScheduled, models.CampaignStatusQueued, models.CampaignStatusProcessing, models.CampaignStatusPaused},
+			[]models.CampaignStatus{models.CampaignStatusCompleted, models.CampaignStatusFailed, models.CampaignStatusCancelled},
+			now.Add(-publicDashboardRecentCampaigns)).
+		Order("COALESCE(started_at, scheduled_at, created_at) DESC").
+		Limit(publicDashboardCampaignLimit).
+		Find(&campaigns)
+
+	result := make([]PublicCampaignWidget, len(campaigns))
+	for i, c := range campaigns {
+		result[i] = PublicCampaignWidget{
+			Name:            c.Name,
+			Status:          c.Status,
+			TotalRecipients: c.TotalRecipients,
+			SentCount:       c.SentCount,
+			DeliveredCount:  c.DeliveredCount,
+			ReadCount:       c.ReadCount,
+			FailedCount:     c.FailedCount,
+			StartedAt:       c.StartedAt,
+			CompletedAt:     c.CompletedAt,
+		}
+	}
+	return result
+}
+
+// publicTransferQueueWidget summarizes the organization's active agent transfers
+func (a *App) publicTransferQueueWidget(orgID uuid.UUID, now time.Time) *PublicTransferQueueWidget {
+	widget := &PublicTransferQueueWidget{}
+	active := func() *gorm.DB {
+		return a.DB.Model(&models.AgentTransfer{}).
+			Where("organization_id = ? AND status = ?", orgID, models.TransferStatusActive)
+	}
+
+	active().Where("agent_id IS NULL").Count(&widget.Waiting)
+	active().Where("agent_id IS NULL AND priority = ?", models.ConversationPriorityUrgent).Count(&widget.WaitingUrgent)
+	active().Where("agent_id IS NULL AND priority = ?", models.ConversationPriorityHigh).Count(&widget.WaitingHigh)
+	active().Where("agent_id IS NOT NULL").Count(&widget.InProgress)
+	active().Where("sla_breached = ?", true).Count(&widget.SLABreached)
+
+	var oldest models.AgentTransfer
+	if widget.Waiting > 0 && active().Where("agent_id IS NULL").Order("transferred_at ASC").
+		Select("transferred_at").First(&oldest).Error == nil {
+		widget.LongestWaitSeconds = int64(now.Sub(oldest.TransferredAt).Seconds())
+	}
+
+	a.DB.Model(&models.User{}).
+		Where("organization_id = ? AND is_active = ? AND is_available = ?", orgID, true, true).
+		Count(&widget.AgentsAvailable)
+	return widget
+}
+
+// requirePublicDashboardAccess checks that the user can both view analytics and change
+// organization settings, since publishing exposes analytics without a login. On failure
+// it sends the error response.
+func (a *App) requirePublicDashboardAccess(r *fastglue.Request) (uuid.UUID, bool) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+		return uuid.Nil, false
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) ||
+		!a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
+
+// loadPublicDashboard checks access and loads the organization's dashboard from the path.
+// On failure it sends the error response.
+func (a *App) loadPublicDashboard(r *fastglue.Request) (*models.PublicDashboard, bool) {
+	orgID, ok := a.requirePublicDashboardAccess(r)
+	if !ok {
+		return nil, false
+	}
+
+	dashboardID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid dashboard ID", nil, "")
+		return nil, false
+	}
+
+	var dashboard models.PublicDashboard
+	if err := a.DB.Where("id = ? AND organization_id = ?", dashboardID, orgID).First(&dashboard).Error; err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusNotFound, "Public dashboard not found", nil, "")
+		return nil, false
+	}
+	return &dashboard, true
+}
+
+// validatePublicDashboard normalizes the request in place and returns a message for the
+// first invalid field
+func validatePublicDashboard(req *PublicDashboardRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if len(req.Name) > 255 {
+		return "Name must be at most 255 characters"
+	}
+	if len(req.Widgets) == 0 {
+		return "Select at least one widget"
+	}
+	var widgets []string
+	for _, w := range req.Widgets {
+		if !slices.Contains(publicDashboardWidgets, w) {
+			return "Unknown widget: " + w
+		}
+		if !slices.Contains(widgets, w) {
+			widgets = append(widgets, w)
+		}
+	}
+	req.Widgets = widgets
+	if req.RefreshSeconds < publicDashboardMinRefresh || req.RefreshSeconds > publicDashboardMaxRefresh {
+		return "Refresh interval must be between 15 and 3600 seconds"
+	}
+	return ""
+}
+
+func (a *App) publicDashboardToResponse(r *fastglue.Request, d *models.PublicDashboard) PublicDashboardResponse {
+	return PublicDashboardResponse{
+		ID:             d.ID,
+		Name:           d.Name,
+		Widgets:        widgetsFromJSONB(d.Widgets),
+		RefreshSeconds: d.RefreshSeconds,
+		IsActive:       d.IsActive,
+		URL:            a.frontendURL(r, publicDashboardViewPath+d.Token),
+		LastViewedAt:   d.LastViewedAt,
+		CreatedAt:      d.CreatedAt,
+	}
+}
+
+func widgetsToJSONB(widgets []string) models.JSONBArray {
+	result := make(models.JSONBArray, len(widgets))
+	for i, w := range widgets {
+		result[i] = w
+	}
+	return result
+}
+
+func widgetsFromJSONB(widgets models.JSONBArray) []string {
+	result := make([]string, 0, len(widgets))
+	for _, w := range widgets {
+		if s, ok := w.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
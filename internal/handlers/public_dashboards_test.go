@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestApp_PublicDashboard(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("public-dashboard"), "password", &role.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "public-dashboard-account")
+	contact := createTestContact(t, app, org.ID)
+	createTestTransfer(t, app, org.ID, contact.ID, account.Name, models.TransferStatusActive, nil)
+
+	type dashboardResp struct {
+		Data handlers.PublicDashboardResponse `json:"data"`
+	}
+
+	// Unknown widgets are rejected
+	req := testutil.NewJSONRequest(t, map[string]any{"name": "Lobby", "widgets": []string{"messages"}})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreatePublicDashboard(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewJSONRequest(t, map[string]any{
+		"name":    "Lobby",
+		"widgets": []string{handlers.PublicWidgetOverview, handlers.PublicWidgetTransferQueue},
+	})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreatePublicDashboard(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(testutil.GetResponseBody(req)))
+
+	var created dashboardResp
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, 60, created.Data.RefreshSeconds)
+	assert.True(t, created.Data.IsActive)
+	token := created.Data.URL[strings.LastIndex(created.Data.URL, "/")+1:]
+	require.NotEmpty(t, token)
+
+	view := func(token string) *fastglue.Request {
+		req := testutil.NewGETRequest(t)
+		testutil.SetPathParam(req, "token", token)
+		require.NoError(t, app.GetPublicDashboardView(req))
+		return req
+	}
+
+	req = view(token)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(testutil.GetResponseBody(req)))
+	var viewResp struct {
+		Data handlers.PublicDashboardView `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &viewResp)
+	assert.Equal(t, "Lobby", viewResp.Data.Name)
+	require.NotNil(t, viewResp.Data.Overview)
+	require.NotNil(t, viewResp.Data.TransferQueue)
+	assert.Equal(t, int64(1), viewResp.Data.TransferQueue.Waiting)
+	assert.Nil(t, viewResp.Data.Campaigns)
+
+	// Rotating the token revokes the old link
+	req = testutil.NewJSONRequest(t, nil)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", created.Data.ID.String())
+	require.NoError(t, app.RotatePublicDashboardToken(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var rotated dashboardResp
+	testutil.ParseJSONResponse(t, req, &rotated)
+	newToken := rotated.Data.URL[strings.LastIndex(rotated.Data.URL, "/")+1:]
+	assert.NotEqual(t, token, newToken)
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(view(token)))
+	assert.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(view(newToken)))
+
+	// Disabled dashboards stop serving data
+	req = testutil.NewJSONRequest(t, map[string]any{
+		"name":      "Lobby",
+		"widgets":   []string{handlers.PublicWidgetOverview},
+		"is_active": false,
+	})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", created.Data.ID.String())
+	require.NoError(t, app.UpdatePublicDashboard(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, fasthttp.StatusNotFound, testutil.GetResponseStatusCode(view(newToken)))
+}
+
+func TestApp_PublicDashboard_RequiresSettingsWrite(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferTestRole(t, app.DB, org.ID, "analyst", []string{"analytics:read"})
+	user := createTestUser(t, app, org.ID, uniqueEmail("public-dashboard-analyst"), "password", &role.ID, true)
+
+	req := testutil.NewJSONRequest(t, map[string]any{"name": "Lobby", "widgets": []string{handlers.PublicWidgetOverview}})
+	setAuthContext(req, org.ID, user.ID)
+	require.NoError(t, app.CreatePublicDashboard(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
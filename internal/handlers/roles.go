@@ -9,23 +9,25 @@ import (
 
 // RoleRequest represents the request body for creating/updating a role
 type RoleRequest struct {
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	IsDefault   bool     `json:"is_default"`
-	Permissions []string `json:"permissions"` // Format: ["resource:action", ...]
+	Name               string   `json:"name"`
+	Description        string   `json:"description"`
+	IsDefault          bool     `json:"is_default"`
+	RestrictDataAccess bool     `json:"restrict_data_access"` // Mask contact details and hide message content
+	Permissions        []string `json:"permissions"`          // Format: ["resource:action", ...]
 }
 
 // RoleResponse represents the response for a role
 type RoleResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	IsSystem    bool      `json:"is_system"`
-	IsDefault   bool      `json:"is_default"`
-	Permissions []string  `json:"permissions"`
-	UserCount   int64     `json:"user_count"`
-	CreatedAt   string    `json:"created_at"`
-	UpdatedAt   string    `json:"updated_at"`
+	ID                 uuid.UUID `json:"id"`
+	Name               string    `json:"name"`
+	Description        string    `json:"description"`
+	IsSystem           bool      `json:"is_system"`
+	IsDefault          bool      `json:"is_default"`
+	RestrictDataAccess bool      `json:"restrict_data_access"`
+	Permissions        []string  `json:"permissions"`
+	UserCount          int64     `json:"user_count"`
+	CreatedAt          string    `json:"created_at"`
+	UpdatedAt          string    `json:"updated_at"`
 }
 
 // PermissionResponse represents a permission in the API
@@ -124,13 +126,14 @@ func (a *App) CreateRole(r *fastglue.Request) error {
 	}
 
 	role := models.CustomRole{
-		BaseModel:      models.BaseModel{ID: uuid.New()},
-		OrganizationID: orgID,
-		Name:           req.Name,
-		Description:    req.Description,
-		IsSystem:       false,
-		IsDefault:      req.IsDefault,
-		Permissions:    permissions,
+		BaseModel:          models.BaseModel{ID: uuid.New()},
+		OrganizationID:     orgID,
+		Name:               req.Name,
+		Description:        req.Description,
+		IsSystem:           false,
+		IsDefault:          req.IsDefault,
+		RestrictDataAccess: req.RestrictDataAccess,
+		Permissions:        permissions,
 	}
 
 	// If setting as default, unset other defaults
@@ -227,6 +230,7 @@ func (a *App) UpdateRole(r *fastglue.Request) error {
 	} else if !req.IsDefault && role.IsDefault {
 		role.IsDefault = false
 	}
+	role.RestrictDataAccess = req.RestrictDataAccess
 
 	if err := a.DB.Save(&role).Error; err != nil {
 		a.Log.Error("Failed to update role", "error", err)
@@ -312,15 +316,16 @@ func roleToResponse(role models.CustomRole, userCount int64) RoleResponse {
 	}
 
 	return RoleResponse{
-		ID:          role.ID,
-		Name:        role.Name,
-		Description: role.Description,
-		IsSystem:    role.IsSystem,
-		IsDefault:   role.IsDefault,
-		Permissions: permissions,
-		UserCount:   userCount,
-		CreatedAt:   role.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		UpdatedAt:   role.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:                 role.ID,
+		Name:               role.Name,
+		Description:        role.Description,
+		IsSystem:           role.IsSystem,
+		IsDefault:          role.IsDefault,
+		RestrictDataAccess: role.RestrictDataAccess,
+		Permissions:        permissions,
+		UserCount:          userCount,
+		CreatedAt:          role.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:          role.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
 }
 
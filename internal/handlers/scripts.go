@@ -0,0 +1,612 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/scripting"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// ErrScriptRejected is returned when a pre-send script rejects an outgoing message
+var ErrScriptRejected = errors.New("message rejected by script")
+
+// maxScriptExecutionsKept is how many execution logs are kept per script
+const maxScriptExecutionsKept = 200
+
+// maxScriptSourceLen bounds the size of a script
+const maxScriptSourceLen = 32 * 1024
+
+// ScriptRequest represents the request body for creating/updating a pipeline script
+type ScriptRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Hook        models.ScriptHook `json:"hook"`
+	Source      string            `json:"source"`
+	IsActive    *bool             `json:"is_active"`
+	Priority    int               `json:"priority"`
+	TimeoutMs   int               `json:"timeout_ms"`
+	MaxSteps    int               `json:"max_steps"`
+}
+
+// ScriptTestRequest runs a script against sample input without saving or logging it
+type ScriptTestRequest struct {
+	Hook      models.ScriptHook      `json:"hook"`
+	Source    string                 `json:"source"`
+	Input     map[string]interface{} `json:"input"` // Overrides the hook's sample globals
+	TimeoutMs int                    `json:"timeout_ms"`
+	MaxSteps  int                    `json:"max_steps"`
+}
+
+// ScriptTestResponse is the outcome of a test run
+type ScriptTestResponse struct {
+	Status     models.ScriptExecutionStatus `json:"status"`
+	Error      string                       `json:"error,omitempty"`
+	Reason     string                       `json:"reason,omitempty"`
+	Return     interface{}                  `json:"return"`
+	Output     map[string]interface{}       `json:"output"` // Globals after the run
+	Logs       []string                     `json:"logs"`
+	Steps      int                          `json:"steps"`
+	DurationUs int64                        `json:"duration_us"`
+}
+
+// ListScripts returns the organization's pipeline scripts
+func (a *App) ListScripts(r *fastglue.Request) error {
+	orgID, err := a.scriptsAccess(r, models.ActionRead)
+	if err != nil {
+		return nil
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if hook := string(r.RequestCtx.QueryArgs().Peek("hook")); hook != "" {
+		query = query.Where("hook = ?", hook)
+	}
+
+	var scripts []models.PipelineScript
+	if err := query.Order("hook ASC, priority ASC, created_at ASC").Find(&scripts).Error; err != nil {
+		a.Log.Error("Failed to list scripts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list scripts", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"scripts": scripts,
+	})
+}
+
+// GetScript returns a single pipeline script
+func (a *App) GetScript(r *fastglue.Request) error {
+	orgID, err := a.scriptsAccess(r, models.ActionRead)
+	if err != nil {
+		return nil
+	}
+	script, err := a.findScript(r, orgID)
+	if err != nil {
+		return nil
+	}
+	return r.SendEnvelope(script)
+}
+
+// CreateScript creates a pipeline script
+func (a *App) CreateScript(r *fastglue.Request) error {
+	orgID, err := a.scriptsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req ScriptRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := validateScriptRequest(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	script := models.PipelineScript{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Hook:           req.Hook,
+		Source:         req.Source,
+		IsActive:       req.IsActive == nil || *req.IsActive,
+		Priority:       req.Priority,
+		TimeoutMs:      req.TimeoutMs,
+		MaxSteps:       req.MaxSteps,
+		CreatedByID:    &userID,
+	}
+	if err := a.DB.Create(&script).Error; err != nil {
+		a.Log.Error("Failed to create script", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create script", nil, "")
+	}
+	// is_active defaults to true in the schema, so a false value needs an explicit update
+	if !script.IsActive {
+		a.DB.Model(&script).Update("is_active", false)
+	}
+
+	a.InvalidateScriptsCache(orgID)
+	return r.SendEnvelope(script)
+}
+
+// UpdateScript updates a pipeline script
+func (a *App) UpdateScript(r *fastglue.Request) error {
+	orgID, err := a.scriptsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	script, err := a.findScript(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	var req ScriptRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := validateScriptRequest(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	updates := map[string]interface{}{
+		"name":        req.Name,
+		"description": req.Description,
+		"hook":        req.Hook,
+		"source":      req.Source,
+		"priority":    req.Priority,
+		"timeout_ms":  req.TimeoutMs,
+		"max_steps":   req.MaxSteps,
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if err := a.DB.Model(script).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to update script", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update script", nil, "")
+	}
+	a.DB.First(script, script.ID)
+
+	a.InvalidateScriptsCache(orgID)
+	return r.SendEnvelope(script)
+}
+
+// DeleteScript deletes a pipeline script and its execution logs
+func (a *App) DeleteScript(r *fastglue.Request) error {
+	orgID, err := a.scriptsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	script, err := a.findScript(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	if err := a.DB.Delete(script).Error; err != nil {
+		a.Log.Error("Failed to delete script", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete script", nil, "")
+	}
+	a.DB.Where("script_id = ?", script.ID).Delete(&models.ScriptExecution{})
+
+	a.InvalidateScriptsCache(orgID)
+	return r.SendEnvelope(map[string]string{"message": "Script deleted"})
+}
+
+// ListScriptExecutions returns a script's most recent execution logs
+func (a *App) ListScriptExecutions(r *fastglue.Request) error {
+	orgID, err := a.scriptsAccess(r, models.ActionRead)
+	if err != nil {
+		return nil
+	}
+	script, err := a.findScript(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if limit <= 0 || limit > maxScriptExecutionsKept {
+		limit = 50
+	}
+	query := a.DB.Where("script_id = ?", script.ID)
+	if status := string(r.RequestCtx.QueryArgs().Peek("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var executions []models.ScriptExecution
+	if err := query.Order("created_at DESC").Limit(limit).Find(&executions).Error; err != nil {
+		a.Log.Error("Failed to list script executions", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list executions", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"executions": executions,
+	})
+}
+
+// TestScript runs a script against sample input for the hook without saving or logging it
+func (a *App) TestScript(r *fastglue.Request) error {
+	if _, err := a.scriptsAccess(r, models.ActionWrite); err != nil {
+		return nil
+	}
+
+	var req ScriptTestRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if !isValidScriptHook(req.Hook) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid hook", nil, "")
+	}
+	if len(req.Source) > maxScriptSourceLen {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Script is too long", nil, "")
+	}
+	prog, err := scripting.Compile(req.Source)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Syntax error: "+err.Error(), nil, "")
+	}
+
+	globals := sampleScriptGlobals(req.Hook)
+	for k, v := range req.Input {
+		globals[k] = v
+	}
+
+	result, runErr := prog.Run(globals, scriptLimits(req.TimeoutMs, req.MaxSteps))
+	status, errMsg := scriptStatus(result, runErr)
+
+	return r.SendEnvelope(ScriptTestResponse{
+		Status:     status,
+		Error:      errMsg,
+		Reason:     result.Reason,
+		Return:     result.Return,
+		Output:     globals,
+		Logs:       nonNilStrings(result.Logs),
+		Steps:      result.Steps,
+		DurationUs: result.Duration.Microseconds(),
+	})
+}
+
+// scriptsAccess checks the caller may manage scripts and returns their organization.
+// On failure the error response has already been sent.
+func (a *App) scriptsAccess(r *fastglue.Request, action string) (uuid.UUID, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+		return uuid.Nil, err
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, action) {
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		return uuid.Nil, errors.New("forbidden")
+	}
+	return orgID, nil
+}
+
+// findScript loads the script named by the id path parameter, sending a 4xx if missing
+func (a *App) findScript(r *fastglue.Request, orgID uuid.UUID) (*models.PipelineScript, error) {
+	id, err := uuid.Parse(fmt.Sprint(r.RequestCtx.UserValue("id")))
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid script ID", nil, "")
+		return nil, err
+	}
+	var script models.PipelineScript
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&script).Error; err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusNotFound, "Script not found", nil, "")
+		return nil, err
+	}
+	return &script, nil
+}
+
+func isValidScriptHook(hook models.ScriptHook) bool {
+	switch hook {
+	case models.ScriptHookContactCreated, models.ScriptHookPreSend, models.ScriptHookRouting:
+		return true
+	}
+	return false
+}
+
+// validateScriptRequest checks a script request and fills in default limits.
+// Returns an error message, or "" when valid.
+func validateScriptRequest(req *ScriptRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if !isValidScriptHook(req.Hook) {
+		return "Invalid hook (use contact_created, pre_send or routing)"
+	}
+	if strings.TrimSpace(req.Source) == "" {
+		return "Source is required"
+	}
+	if len(req.Source) > maxScriptSourceLen {
+		return "Script is too long"
+	}
+	if _, err := scripting.Compile(req.Source); err != nil {
+		return "Syntax error: " + err.Error()
+	}
+	if req.TimeoutMs == 0 {
+		req.TimeoutMs = int(scripting.DefaultTimeout / time.Millisecond)
+	}
+	if req.TimeoutMs < 1 || req.TimeoutMs > int(scripting.MaxTimeout/time.Millisecond) {
+		return fmt.Sprintf("timeout_ms must be between 1 and %d", scripting.MaxTimeout/time.Millisecond)
+	}
+	if req.MaxSteps == 0 {
+		req.MaxSteps = scripting.DefaultMaxSteps
+	}
+	if req.MaxSteps < 1 || req.MaxSteps > scripting.MaxSteps {
+		return fmt.Sprintf("max_steps must be between 1 and %d", scripting.MaxSteps)
+	}
+	return ""
+}
+
+func scriptLimits(timeoutMs, maxSteps int) scripting.Limits {
+	return scripting.Limits{
+		MaxSteps: maxSteps,
+		Timeout:  time.Duration(timeoutMs) * time.Millisecond,
+	}
+}
+
+// scriptStatus classifies a run
+func scriptStatus(result *scripting.Result, err error) (models.ScriptExecutionStatus, string) {
+	switch {
+	case errors.Is(err, scripting.ErrStepLimit) || errors.Is(err, scripting.ErrTimeout):
+		return models.ScriptExecutionTimeout, err.Error()
+	case err != nil:
+		return models.ScriptExecutionError, err.Error()
+	case result.Rejected:
+		return models.ScriptExecutionRejected, ""
+	}
+	return models.ScriptExecutionSuccess, ""
+}
+
+// sampleScriptGlobals returns example globals for a hook, used by test runs
+func sampleScriptGlobals(hook models.ScriptHook) map[string]interface{} {
+	contact := map[string]interface{}{
+		"id":               uuid.Nil.String(),
+		"phone_number":     "15551234567",
+		"profile_name":     "Sample Contact",
+		"whatsapp_account": "main",
+		"tags":             []interface{}{},
+		"metadata":         map[string]interface{}{},
+	}
+	globals := map[string]interface{}{"contact": contact}
+	switch hook {
+	case models.ScriptHookPreSend:
+		globals["message"] = map[string]interface{}{
+			"type":             "text",
+			"content":          "Hello!",
+			"whatsapp_account": "main",
+			"sent_by_user_id":  nil,
+		}
+	case models.ScriptHookRouting:
+		globals["transfer"] = map[string]interface{}{"source": string(models.TransferSourceChatbotDisabled), "whatsapp_account": "main"}
+		globals["route"] = map[string]interface{}{"team": nil, "agent": nil}
+	}
+	return globals
+}
+
+// scriptContact exposes a contact to scripts
+func scriptContact(c *models.Contact) map[string]interface{} {
+	tags := []interface{}{}
+	for _, t := range c.Tags {
+		tags = append(tags, t)
+	}
+	metadata := map[string]interface{}{}
+	for k, v := range c.Metadata {
+		metadata[k] = v
+	}
+	return map[string]interface{}{
+		"id":               c.ID.String(),
+		"phone_number":     c.PhoneNumber,
+		"profile_name":     c.ProfileName,
+		"whatsapp_account": c.WhatsAppAccount,
+		"tags":             tags,
+		"metadata":         metadata,
+	}
+}
+
+// runPipelineScripts runs the organization's active scripts for a hook in priority order,
+// stopping at the first rejection. Scripts that fail are logged and skipped. Returns the
+// rejection reason when a script rejected.
+func (a *App) runPipelineScripts(orgID uuid.UUID, hook models.ScriptHook, globals map[string]interface{}) (bool, string) {
+	scripts := a.getActiveScripts(orgID, hook)
+	for _, s := range scripts {
+		prog, err := scripting.Compile(s.Source)
+		var result *scripting.Result
+		if err == nil {
+			result, err = prog.Run(globals, scriptLimits(s.TimeoutMs, s.MaxSteps))
+		} else {
+			result = &scripting.Result{}
+		}
+		status, errMsg := scriptStatus(result, err)
+		if err != nil {
+			a.Log.Warn("Pipeline script failed", "script_id", s.ID, "hook", hook, "error", err)
+		}
+		a.logScriptExecution(models.ScriptExecution{
+			OrganizationID: orgID,
+			ScriptID:       s.ID,
+			Hook:           hook,
+			Status:         status,
+			DurationUs:     result.Duration.Microseconds(),
+			Steps:          result.Steps,
+			Logs:           nonNilStrings(result.Logs),
+			Error:          errMsg,
+		})
+		if status == models.ScriptExecutionRejected {
+			return true, result.Reason
+		}
+	}
+	return false, ""
+}
+
+// logScriptExecution records a run in the background and trims old logs for the script
+func (a *App) logScriptExecution(exec models.ScriptExecution) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if err := a.DB.Create(&exec).Error; err != nil {
+			a.Log.Error("Failed to log script execution", "error", err, "script_id", exec.ScriptID)
+			return
+		}
+		a.DB.Where("script_id = ? AND id NOT IN (?)", exec.ScriptID,
+			a.DB.Model(&models.ScriptExecution{}).Select("id").Where("script_id = ?", exec.ScriptID).
+				Order("created_at DESC").Limit(maxScriptExecutionsKept),
+		).Delete(&models.ScriptExecution{})
+	}()
+}
+
+// getActiveScripts returns the organization's active scripts for a hook in run order
+func (a *App) getActiveScripts(orgID uuid.UUID, hook models.ScriptHook) []models.PipelineScript {
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s:%s", orgScriptsCachePrefix, orgID.String(), hook)
+
+	if a.Redis != nil {
+		if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil {
+			var scripts []models.PipelineScript
+			if err := json.Unmarshal([]byte(cached), &scripts); err == nil {
+				return scripts
+			}
+		}
+	}
+
+	var scripts []models.PipelineScript
+	if err := a.DB.Where("organization_id = ? AND hook = ? AND is_active = ?", orgID, hook, true).
+		Order("priority ASC, created_at ASC").Find(&scripts).Error; err != nil {
+		a.Log.Error("Failed to load pipeline scripts", "error", err, "organization_id", orgID)
+		return nil
+	}
+
+	if a.Redis != nil {
+		if data, err := json.Marshal(scripts); err == nil {
+			a.Redis.Set(ctx, cacheKey, data, orgScriptsCacheTTL)
+		}
+	}
+	return scripts
+}
+
+// InvalidateScriptsCache clears the organization's cached scripts for every hook
+func (a *App) InvalidateScriptsCache(orgID uuid.UUID) {
+	if a.Redis == nil {
+		return
+	}
+	a.deleteKeysByPattern(context.Background(), fmt.Sprintf("%s%s:*", orgScriptsCachePrefix, orgID.String()))
+}
+
+// runPreSendScripts lets pre_send scripts rewrite or reject an outgoing message
+func (a *App) runPreSendScripts(req *OutgoingMessageRequest, opts MessageSendOptions) error {
+	content := outgoingPluginContent(req)
+	message := map[string]interface{}{
+		"type":             string(req.Type),
+		"whatsapp_account": req.Account.Name,
+		"content":          nil,
+		"sent_by_user_id":  nil,
+	}
+	if content != nil {
+		message["content"] = *content
+	}
+	if opts.SentByUserID != nil {
+		message["sent_by_user_id"] = opts.SentByUserID.String()
+	}
+
+	rejected, reason := a.runPipelineScripts(req.Account.OrganizationID, models.ScriptHookPreSend, map[string]interface{}{
+		"message": message,
+		"contact": scriptContact(req.Contact),
+	})
+	if rejected {
+		return fmt.Errorf("%w: %s", ErrScriptRejected, reason)
+	}
+	if s, ok := message["content"].(string); ok && content != nil {
+		*content = s
+	}
+	return nil
+}
+
+// enrichContactWithScripts runs contact_created scripts and saves the profile name, tags
+// and metadata they set
+func (a *App) enrichContactWithScripts(contact *models.Contact) {
+	globals := scriptContact(contact)
+	a.runPipelineScripts(contact.OrganizationID, models.ScriptHookContactCreated, map[string]interface{}{
+		"contact": globals,
+	})
+
+	updates := map[string]interface{}{}
+	if name, ok := globals["profile_name"].(string); ok && name != contact.ProfileName {
+		contact.ProfileName = name
+		updates["profile_name"] = name
+	}
+	if tags, ok := globals["tags"].([]interface{}); ok {
+		if !jsonEqual(tags, []interface{}(contact.Tags)) {
+			contact.Tags = models.JSONBArray(tags)
+			updates["tags"] = contact.Tags
+		}
+	}
+	if metadata, ok := globals["metadata"].(map[string]interface{}); ok {
+		if !jsonEqual(metadata, map[string]interface{}(contact.Metadata)) {
+			contact.Metadata = models.JSONB(metadata)
+			updates["metadata"] = contact.Metadata
+		}
+	}
+	if len(updates) == 0 {
+		return
+	}
+	if err := a.DB.Model(contact).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to save contact enrichment", "error", err, "contact_id", contact.ID)
+	}
+}
+
+// routeTransferWithScripts lets routing scripts pick a team or an agent for a queued
+// transfer. route.team takes a team name or ID; route.agent an agent email or ID.
+func (a *App) routeTransferWithScripts(account *models.WhatsAppAccount, contact *models.Contact, source models.TransferSource) (teamID, agentID *uuid.UUID) {
+	route := map[string]interface{}{"team": nil, "agent": nil}
+	a.runPipelineScripts(account.OrganizationID, models.ScriptHookRouting, map[string]interface{}{
+		"contact":  scriptContact(contact),
+		"transfer": map[string]interface{}{"source": string(source), "whatsapp_account": account.Name},
+		"route":    route,
+	})
+
+	if agent, ok := route["agent"].(string); ok && agent != "" {
+		var user models.User
+		query := a.DB.Where("organization_id = ? AND is_active = ?", account.OrganizationID, true)
+		if id, err := uuid.Parse(agent); err == nil {
+			query = query.Where("id = ?", id)
+		} else {
+			query = query.Where("LOWER(email) = ?", strings.ToLower(agent))
+		}
+		if err := query.First(&user).Error; err == nil {
+			return nil, &user.ID
+		}
+		a.Log.Warn("Routing script chose an unknown agent", "agent", agent, "organization_id", account.OrganizationID)
+	}
+
+	if team, ok := route["team"].(string); ok && team != "" {
+		var t models.Team
+		query := a.DB.Where("organization_id = ? AND is_active = ?", account.OrganizationID, true)
+		if id, err := uuid.Parse(team); err == nil {
+			query = query.Where("id = ?", id)
+		} else {
+			query = query.Where("LOWER(name) = ?", strings.ToLower(team))
+		}
+		if err := query.First(&t).Error; err == nil {
+			return &t.ID, nil
+		}
+		a.Log.Warn("Routing script chose an unknown team", "team", team, "organization_id", account.OrganizationID)
+	}
+	return nil, nil
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func createScript(t *testing.T, app *handlers.App, user *models.User, body map[string]any) int {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, body)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	req.RequestCtx.SetUserValue("organization_id", user.OrganizationID)
+	require.NoError(t, app.CreateScript(req))
+	return testutil.GetResponseStatusCode(req)
+}
+
+func TestApp_Scripts_PreSend(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+	ctx := testutil.TestContext(t)
+
+	status := createScript(t, app, admin, map[string]any{
+		"name": "Signature",
+		"hook": "pre_send",
+		"source": `
+			if contains(message.content, "password") then reject("no passwords") end
+			message.content = message.content .. "\n- Acme"
+			log("signed", message.content)
+		`,
+	})
+	require.Equal(t, fasthttp.StatusOK, status)
+
+	send := func(content string) (*models.Message, error) {
+		return app.SendOutgoingMessage(ctx, handlers.OutgoingMessageRequest{
+			Account: account,
+			Contact: contact,
+			Type:    models.MessageTypeText,
+			Content: content,
+		}, handlers.ChatbotSendOptions())
+	}
+
+	msg, err := send("Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello\n- Acme", msg.Content)
+
+	_, err = send("my password is 123")
+	assert.True(t, errors.Is(err, handlers.ErrScriptRejected))
+
+	// Executions are logged in the background
+	var script models.PipelineScript
+	require.NoError(t, app.DB.Where("organization_id = ?", org.ID).First(&script).Error)
+	require.Eventually(t, func() bool {
+		var count int64
+		app.DB.Model(&models.ScriptExecution{}).Where("script_id = ?", script.ID).Count(&count)
+		return count == 2
+	}, 2*time.Second, 20*time.Millisecond)
+
+	var rejected models.ScriptExecution
+	require.NoError(t, app.DB.Where("script_id = ? AND status = ?", script.ID, models.ScriptExecutionRejected).First(&rejected).Error)
+	assert.Empty(t, rejected.Logs)
+}
+
+func TestApp_Scripts_Validation(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+
+	cases := []map[string]any{
+		{"name": "", "hook": "pre_send", "source": "log(1)"},
+		{"name": "Bad hook", "hook": "on_delete", "source": "log(1)"},
+		{"name": "Syntax", "hook": "pre_send", "source": "if x then"},
+		{"name": "Timeout", "hook": "pre_send", "source": "log(1)", "timeout_ms": 5000},
+		{"name": "Steps", "hook": "pre_send", "source": "log(1)", "max_steps": -1},
+	}
+	for _, body := range cases {
+		status := createScript(t, app, admin, body)
+		assert.Equal(t, fasthttp.StatusBadRequest, status, body["name"])
+	}
+}
+
+func TestApp_Scripts_TestRun(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	admin := createAnnouncementAdmin(t, app, org.ID)
+
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"hook":   "routing",
+		"source": `if contains(contact.tags, "vip") then route.team = "VIP" end`,
+		"input": map[string]any{
+			"contact": map[string]any{"tags": []any{"vip"}},
+		},
+	})
+	req.RequestCtx.SetUserValue("user_id", admin.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.TestScript(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data handlers.ScriptTestResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.Equal(t, models.ScriptExecutionSuccess, resp.Data.Status)
+	assert.Equal(t, "VIP", resp.Data.Output["route"].(map[string]any)["team"])
+
+	// Test runs are not logged
+	var count int64
+	app.DB.Model(&models.ScriptExecution{}).Where("organization_id = ?", org.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestApp_Scripts_RequiresPermission(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	agent := createTestUser(t, app, org.ID, uniqueEmail("script-agent"), "password123", nil, true)
+
+	status := createScript(t, app, agent, map[string]any{"name": "x", "hook": "pre_send", "source": "log(1)"})
+	assert.Equal(t, fasthttp.StatusForbidden, status)
+
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", agent.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	testutil.SetPathParam(req, "id", uuid.New().String())
+	require.NoError(t, app.DeleteScript(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+}
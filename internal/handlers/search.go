@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// Search limits
+const (
+	minSearchQueryLength   = 2
+	defaultSearchTypeLimit = 5
+	maxSearchTypeLimit     = 20
+	// searchPreviewLength caps message and canned response snippets
+	searchPreviewLength = 120
+)
+
+// Search result types
+const (
+	SearchTypeContacts        = "contacts"
+	SearchTypeMessages        = "messages"
+	SearchTypeTemplates       = "templates"
+	SearchTypeCampaigns       = "campaigns"
+	SearchTypeCannedResponses = "canned_responses"
+	SearchTypeFlows           = "flows"
+)
+
+var searchTypes = []string{
+	SearchTypeContacts, SearchTypeMessages, SearchTypeTemplates,
+	SearchTypeCampaigns, SearchTypeCannedResponses, SearchTypeFlows,
+}
+
+// SearchResult is one match of a global search
+type SearchResult struct {
+	ID       uuid.UUID `json:"id"`
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Subtitle string    `json:"subtitle,omitempty"`
+	// ParentID links a match to the record it opens, e.g. a message's contact
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	Kind      string     `json:"kind,omitempty"` // Flows: chatbot or whatsapp
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Search finds contacts, messages, templates, campaigns, canned responses and flows
+// matching q, grouped by type. Types the user can't read are left out, as are contacts
+// and messages for roles with restricted data access.
+func (a *App) Search(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	q := strings.TrimSpace(string(r.RequestCtx.QueryArgs().Peek("q")))
+	if len([]rune(q)) < minSearchQueryLength {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "q must be at least 2 characters", nil, "")
+	}
+
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if limit < 1 {
+		limit = defaultSearchTypeLimit
+	}
+	if limit > maxSearchTypeLimit {
+		limit = maxSearchTypeLimit
+	}
+
+	types := searchTypes
+	if raw := string(r.RequestCtx.QueryArgs().Peek("types")); raw != "" {
+		types = nil
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(searchTypes, t) {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unknown search type: "+t, nil, "")
+			}
+			types = append(types, t)
+		}
+	}
+
+	pattern := likePattern(q)
+	restricted := a.HasRestrictedDataAccess(userID, orgID)
+	results := map[string][]SearchResult{}
+	for _, t := range types {
+		var (
+			matches []SearchResult
+			err     error
+			allowed = true
+		)
+		switch t {
+		case SearchTypeContacts:
+			if allowed = !restricted; allowed {
+				matches, err = a.searchContacts(orgID, userID, pattern, limit)
+			}
+		case SearchTypeMessages:
+			if allowed = !restricted && a.HasPermission(userID, orgID, models.ResourceChat, models.ActionRead); allowed {
+				matches, err = a.searchMessages(orgID, userID, pattern, limit)
+			}
+		case SearchTypeTemplates:
+			if allowed = a.HasPermission(userID, orgID, models.ResourceTemplates, models.ActionRead); allowed {
+				matches, err = a.searchTemplates(orgID, pattern, limit)
+			}
+		case SearchTypeCampaigns:
+			if allowed = a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionRead); allowed {
+				matches, err = a.searchCampaigns(orgID, pattern, limit)
+			}
+		case SearchTypeCannedResponses:
+			if allowed = a.HasPermission(userID, orgID, models.ResourceCannedResponses, models.ActionRead); allowed {
+				matches, err = a.searchCannedResponses(orgID, pattern, limit)
+			}
+		case SearchTypeFlows:
+			allowed = a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, models.ActionRead) ||
+				a.HasPermission(userID, orgID, models.ResourceFlowsWhatsApp, models.ActionRead)
+			if allowed {
+				matches, err = a.searchFlows(orgID, userID, pattern, limit)
+			}
+		}
+		if err != nil {
+			a.Log.Error("Search failed", "error", err, "type", t)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Search failed", nil, "")
+		}
+		if allowed {
+			results[t] = nonNilResults(matches)
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"query":   q,
+		"results": results,
+	})
+}
+
+// searchContacts matches names and phone numbers. Users without contacts:read only
+// find contacts assigned to them, as in the contact list.
+func (a *App) searchContacts(orgID, userID uuid.UUID, pattern string, limit int) ([]SearchResult, error) {
+	query := a.DB.Where("organization_id = ?", orgID).
+		Where("profile_name ILIKE ? OR phone_number LIKE ?", pattern, pattern)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+
+	var contacts []models.Contact
+	if err := query.Order("last_message_at DESC NULLS LAST").Limit(limit).Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+
+	shouldMask := a.ShouldMaskPhoneNumbers(orgID)
+	results := make([]SearchResult, len(contacts))
+	for i, c := range contacts {
+		name, phone := c.ProfileName, c.PhoneNumber
+		if shouldMask {
+			name, phone = MaskIfPhoneNumber(name), MaskPhoneNumber(phone)
+		}
+		if name == "" {
+			name = phone
+		}
+		results[i] = SearchResult{
+			ID:        c.ID,
+			Type:      SearchTypeContacts,
+			Title:     name,
+			Subtitle:  phone,
+			UpdatedAt: c.UpdatedAt,
+		}
+	}
+	return results, nil
+}
+
+// searchMessages matches message text, newest first, within the contacts the user can see
+func (a *App) searchMessages(orgID, userID uuid.UUID, pattern string, limit int) ([]SearchResult, error) {
+	query := a.DB.Table("messages").
+		Select("messages.id, messages.contact_id, messages.content, messages.created_at, contacts.profile_name, contacts.phone_number").
+		Joins("JOIN contacts ON contacts.id = messages.contact_id AND contacts.deleted_at IS NULL").
+		Where("messages.organization_id = ? AND messages.deleted_at IS NULL", orgID).
+		Where("messages.content ILIKE ?", pattern)
+	if !a.HasPermission(userID, orgID, models.ResourceContacts, models.ActionRead) {
+		query = query.Where("contacts.assigned_user_id = ?", userID)
+	}
+
+	var rows []struct {
+		ID          uuid.UUID
+		ContactID   uuid.UUID
+		Content     string
+		CreatedAt   time.Time
+		ProfileName string
+		PhoneNumber string
+	}
+	if err := query.Order("messages.created_at DESC").Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	shouldMask := a.ShouldMaskPhoneNumbers(orgID)
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		contactName := row.ProfileName
+		if contactName == "" {
+			contactName = row.PhoneNumber
+		}
+		if shouldMask {
+			contactName = MaskIfPhoneNumber(contactName)
+		}
+		contactID := row.ContactID
+		results[i] = SearchResult{
+			ID:        row.ID,
+			Type:      SearchTypeMessages,
+			Title:     truncateString(row.Content, searchPreviewLength),
+			Subtitle:  contactName,
+			ParentID:  &contactID,
+			UpdatedAt: row.CreatedAt,
+		}
+	}
+	return results, nil
+}
+
+func (a *App) searchTemplates(orgID uuid.UUID, pattern string, limit int) ([]SearchResult, error) {
+	var templates []models.Template
+	if err := a.DB.Where("organization_id = ? AND archived_at IS NULL", orgID).
+		Where("name ILIKE ? OR display_name ILIKE ? OR body_content ILIKE ?", pattern, pattern, pattern).
+		Order("usage_count DESC, name").Limit(limit).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(templates))
+	for i, t := range templates {
+		title := t.DisplayName
+		if title == "" {
+			title = t.Name
+		}
+		results[i] = SearchResult{
+			ID:        t.ID,
+			Type:      SearchTypeTemplates,
+			Title:     title,
+			Subtitle:  t.Language + " · " + t.Status,
+			UpdatedAt: t.UpdatedAt,
+		}
+	}
+	return results, nil
+}
+
+func (a *App) searchCampaigns(orgID uuid.UUID, pattern string, limit int) ([]SearchResult, error) {
+	var campaigns []models.BulkMessageCampaign
+	if err := a.DB.Where("organization_id = ? AND name ILIKE ?", orgID, pattern).
+		Order("created_at DESC").Limit(limit).Find(&campaigns).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(campaigns))
+	for i, c := range campaigns {
+		results[i] = SearchResult{
+			ID:        c.ID,
+			Type:      SearchTypeCampaigns,
+			Title:     c.Name,
+			Subtitle:  string(c.Status),
+			UpdatedAt: c.UpdatedAt,
+		}
+	}
+	return results, nil
+}
+
+func (a *App) searchCannedResponses(orgID uuid.UUID, pattern string, limit int) ([]SearchResult, error) {
+	var responses []models.CannedResponse
+	if err := a.DB.Where("organization_id = ?", orgID).
+		Where("name ILIKE ? OR content ILIKE ? OR shortcut ILIKE ?", pattern, pattern, pattern).
+		Order("usage_count DESC, name").Limit(limit).Find(&responses).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(responses))
+	for i, cr := range responses {
+		results[i] = SearchResult{
+			ID:        cr.ID,
+			Type:      SearchTypeCannedResponses,
+			Title:     cr.Name,
+			Subtitle:  truncateString(cr.Content, searchPreviewLength),
+			UpdatedAt: cr.UpdatedAt,
+		}
+	}
+	return results, nil
+}
+
+// searchFlows matches chatbot flows and WhatsApp flows by name, each only when the
+// user can read that kind of flow
+func (a *App) searchFlows(orgID, userID uuid.UUID, pattern string, limit int) ([]SearchResult, error) {
+	var results []SearchResult
+
+	if a.HasPermission(userID, orgID, models.ResourceFlowsChatbot, models.ActionRead) {
+		var flows []models.ChatbotFlow
+		if err := a.DB.Where("organization_id = ?", orgID).
+			Where("name ILIKE ? OR description ILIKE ?", pattern, pattern).
+			Order("name").Limit(limit).Find(&flows).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range flows {
+			results = append(results, SearchResult{
+				ID:        f.ID,
+				Type:      SearchTypeFlows,
+				Kind:      "chatbot",
+				Title:     f.Name,
+				Subtitle:  f.WhatsAppAccount,
+				UpdatedAt: f.UpdatedAt,
+			})
+		}
+	}
+
+	if a.HasPermission(userID, orgID, models.ResourceFlowsWhatsApp, models.ActionRead) {
+		var flows []models.WhatsAppFlow
+		if err := a.DB.Where("organization_id = ? AND name ILIKE ?", orgID, pattern).
+			Order("name").Limit(limit).Find(&flows).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range flows {
+			results = append(results, SearchResult{
+				ID:        f.ID,
+				Type:      SearchTypeFlows,
+				Kind:      "whatsapp",
+				Title:     f.Name,
+				Subtitle:  f.Status,
+				UpdatedAt: f.UpdatedAt,
+			})
+		}
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// likePattern builds a substring pattern for LIKE/ILIKE, escaping the wildcards in s
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+func nonNilResults(r []SearchResult) []SearchResult {
+	if r == nil {
+		return []SearchResult{}
+	}
+	return r
+}
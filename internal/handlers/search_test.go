@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func searchRequest(t *testing.T, app *handlers.App, orgID, userID uuid.UUID, q string) map[string][]handlers.SearchResult {
+	t.Helper()
+
+	req := testutil.NewGETRequest(t)
+	setAuthContext(req, orgID, userID)
+	testutil.SetQueryParam(req, "q", q)
+	require.NoError(t, app.Search(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data struct {
+			Results map[string][]handlers.SearchResult `json:"results"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	return resp.Data.Results
+}
+
+func TestApp_Search(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	adminRole := createTransferAdminRole(t, app.DB, org.ID)
+	admin := createTestUser(t, app, org.ID, uniqueEmail("search-admin"), "password", &adminRole.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "search-account")
+
+	contact := &models.Contact{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		PhoneNumber:     "15550001111",
+		ProfileName:     "Zebra Holdings",
+	}
+	require.NoError(t, app.DB.Create(contact).Error)
+	message := &models.Message{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		Direction:       models.DirectionIncoming,
+		MessageType:     models.MessageTypeText,
+		Content:         "Is the zebra print dress back in stock?",
+	}
+	require.NoError(t, app.DB.Create(message).Error)
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(template).Update("display_name", "Zebra sale").Error)
+	require.NoError(t, app.DB.Create(&models.CannedResponse{
+		OrganizationID: org.ID,
+		Name:           "Out of stock",
+		Content:        "Sorry, that item is sold out.",
+		IsActive:       true,
+	}).Error)
+
+	results := searchRequest(t, app, org.ID, admin.ID, "zebra")
+	require.Len(t, results[handlers.SearchTypeContacts], 1)
+	assert.Equal(t, "Zebra Holdings", results[handlers.SearchTypeContacts][0].Title)
+	require.Len(t, results[handlers.SearchTypeMessages], 1)
+	assert.Equal(t, contact.ID, *results[handlers.SearchTypeMessages][0].ParentID)
+	require.Len(t, results[handlers.SearchTypeTemplates], 1)
+	assert.Equal(t, "Zebra sale", results[handlers.SearchTypeTemplates][0].Title)
+	assert.Empty(t, results[handlers.SearchTypeCannedResponses])
+	assert.Contains(t, results, handlers.SearchTypeCampaigns)
+
+	// Wildcards in the query match literally
+	results = searchRequest(t, app, org.ID, admin.ID, "%%")
+	assert.Empty(t, results[handlers.SearchTypeContacts])
+
+	// Without read permissions, types are left out and contacts are limited to assigned ones
+	chatRole := createTransferTestRole(t, app.DB, org.ID, "search-chat", []string{"chat:read"})
+	agent := createTestUser(t, app, org.ID, uniqueEmail("search-agent"), "password", &chatRole.ID, true)
+
+	results = searchRequest(t, app, org.ID, agent.ID, "zebra")
+	assert.NotContains(t, results, handlers.SearchTypeTemplates)
+	assert.NotContains(t, results, handlers.SearchTypeCampaigns)
+	assert.Empty(t, results[handlers.SearchTypeContacts])
+	assert.Empty(t, results[handlers.SearchTypeMessages])
+
+	require.NoError(t, app.DB.Model(contact).Update("assigned_user_id", agent.ID).Error)
+	results = searchRequest(t, app, org.ID, agent.ID, "zebra")
+	assert.Len(t, results[handlers.SearchTypeContacts], 1)
+	assert.Len(t, results[handlers.SearchTypeMessages], 1)
+
+	// Roles with restricted data access don't search contacts or messages at all
+	analystRole := createTransferTestRole(t, app.DB, org.ID, "search-analyst", []string{"chat:read", "contacts:read", "templates:read"})
+	require.NoError(t, app.DB.Model(analystRole).Update("restrict_data_access", true).Error)
+	analyst := createTestUser(t, app, org.ID, uniqueEmail("search-analyst"), "password", &analystRole.ID, true)
+
+	results = searchRequest(t, app, org.ID, analyst.ID, "zebra")
+	assert.NotContains(t, results, handlers.SearchTypeContacts)
+	assert.NotContains(t, results, handlers.SearchTypeMessages)
+	assert.Len(t, results[handlers.SearchTypeTemplates], 1)
+}
+
+func TestApp_Search_Validation(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("search-invalid"), "password", nil, true)
+
+	for name, params := range map[string]map[string]string{
+		"short query":  {"q": "z"},
+		"unknown type": {"q": "zebra", "types": "contacts,invoices"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := testutil.NewGETRequest(t)
+			setAuthContext(req, org.ID, user.ID)
+			for k, v := range params {
+				testutil.SetQueryParam(req, k, v)
+			}
+			require.NoError(t, app.Search(req))
+			assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// createSegmentTestContacts creates a VIP contact in Pune who opted in and messaged
+// recently, a VIP contact in Mumbai who never messaged, and a churned VIP contact
+func createSegmentTestContacts(t *testing.T, app *handlers.App, orgID uuid.UUID) (pune, mumbai, churned *models.Contact) {
+	t.Helper()
+
+	recently := time.Now().Add(-48 * time.Hour)
+	create := func(phoneNumber string, tags models.JSONBArray, metadata models.JSONB, lastMessageAt *time.Time) *models.Contact {
+		contact := &models.Contact{
+			OrganizationID: orgID,
+			PhoneNumber:    phoneNumber,
+			ProfileName:    "Contact " + phoneNumber,
+			Tags:           tags,
+			Metadata:       metadata,
+			LastMessageAt:  lastMessageAt,
+		}
+		require.NoError(t, app.DB.Create(contact).Error)
+		return contact
+	}
+	pune = create("14155550101", models.JSONBArray{"vip", "billing"}, models.JSONB{"city": "Pune"}, &recently)
+	mumbai = create("14155550102", models.JSONBArray{"vip"}, models.JSONB{"city": "Mumbai"}, nil)
+	churned = create("14155550103", models.JSONBArray{"vip", "churned"}, models.JSONB{}, &recently)
+
+	require.NoError(t, app.DB.Create(&models.ContactConsent{
+		OrganizationID: orgID,
+		ContactID:      pune.ID,
+		PhoneNumber:    pune.PhoneNumber,
+		Source:         models.ConsentSourceWebForm,
+		Method:         models.ConsentMethodCheckbox,
+		ConsentedAt:    time.Now(),
+	}).Error)
+	return pune, mumbai, churned
+}
+
+func TestApp_CreateSegment(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("segments"), "password", &role.ID, true)
+	pune, mumbai, _ := createSegmentTestContacts(t, app, org.ID)
+
+	tests := []struct {
+		name    string
+		filters models.SegmentFilters
+		want    []uuid.UUID
+	}{
+		{"tags and excluded tags", models.SegmentFilters{Tags: []string{"vip"}, ExcludeTags: []string{"churned"}}, []uuid.UUID{pune.ID, mumbai.ID}},
+		{"custom field", models.SegmentFilters{CustomFields: []models.SegmentFieldFilter{{Field: "city", Operator: models.SegmentFieldEquals, Value: "Mumbai"}}}, []uuid.UUID{mumbai.ID}},
+		{"recent message", models.SegmentFilters{Tags: []string{"billing"}, LastMessageWithinDays: 7}, []uuid.UUID{pune.ID}},
+		{"quiet contacts", models.SegmentFilters{NoMessageForDays: 7}, []uuid.UUID{mumbai.ID}},
+		{"opted in", models.SegmentFilters{OptIn: models.SegmentOptInOptedIn}, []uuid.UUID{pune.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testutil.NewJSONRequest(t, map[string]any{"name": "Segment " + uuid.NewString()[:8], "filters": tt.filters})
+			setAuthContext(req, org.ID, user.ID)
+			require.NoError(t, app.CreateSegment(req))
+			require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+			var segment handlers.SegmentResponse
+			testutil.ParseEnvelopeResponse(t, req, &segment)
+			assert.EqualValues(t, len(tt.want), segment.ContactCount)
+
+			req = testutil.NewGETRequest(t)
+			setAuthContext(req, org.ID, user.ID)
+			testutil.SetPathParam(req, "id", segment.ID.String())
+			require.NoError(t, app.GetSegmentContacts(req))
+			require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+			var resp struct {
+				Contacts []handlers.SegmentContactResponse `json:"contacts"`
+			}
+			testutil.ParseEnvelopeResponse(t, req, &resp)
+			var got []uuid.UUID
+			for _, c := range resp.Contacts {
+				got = append(got, c.ID)
+			}
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+
+	t.Run("invalid operator", func(t *testing.T) {
+		req := testutil.NewJSONRequest(t, map[string]any{
+			"name":    "Bad segment",
+			"filters": map[string]any{"custom_fields": []map[string]string{{"field": "city", "operator": "starts_with"}}},
+		})
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.CreateSegment(req))
+		assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+	})
+}
+
+func TestApp_ImportSegmentRecipients(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("segment-recipients"), "password", &role.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "segment-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	campaign := createTestCampaign(t, app, org.ID, template.ID, user.ID, account.Name, models.CampaignStatusDraft)
+	pune, mumbai, _ := createSegmentTestContacts(t, app, org.ID)
+
+	segment := &models.Segment{
+		OrganizationID: org.ID,
+		Name:           "VIPs",
+		Filters:        models.SegmentFilters{Tags: []string{"vip"}, ExcludeTags: []string{"churned"}},
+	}
+	require.NoError(t, app.DB.Create(segment).Error)
+
+	importSegment := func() handlers.SegmentImportResult {
+		req := testutil.NewJSONRequest(t, map[string]any{
+			"segment_id":      segment.ID.String(),
+			"template_params": map[string]string{"1": "name", "city": "city"},
+		})
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", campaign.ID.String())
+		require.NoError(t, app.ImportSegmentRecipients(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		var result handlers.SegmentImportResult
+		testutil.ParseEnvelopeResponse(t, req, &result)
+		return result
+	}
+
+	result := importSegment()
+	assert.Equal(t, 2, result.MatchedCount)
+	assert.Equal(t, 2, result.AddedCount)
+	assert.Equal(t, 2, result.TotalRecipients)
+
+	var recipients []models.BulkMessageRecipient
+	require.NoError(t, app.DB.Where("campaign_id = ?", campaign.ID).Order("phone_number").Find(&recipients).Error)
+	require.Len(t, recipients, 2)
+	assert.Equal(t, pune.ProfileName, recipients[0].RecipientName)
+	assert.Equal(t, "Pune", recipients[0].TemplateParams["city"])
+	assert.Equal(t, mumbai.ProfileName, recipients[1].TemplateParams["1"])
+
+	// Adding the segment again only picks up contacts that weren't added yet
+	result = importSegment()
+	assert.Equal(t, 2, result.MatchedCount)
+	assert.Zero(t, result.AddedCount)
+	assert.Equal(t, 2, result.TotalRecipients)
+}
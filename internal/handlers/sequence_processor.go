@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/templatevars"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// sequenceStepBatchSize is how many due steps one run sends
+	sequenceStepBatchSize = 200
+	// sequenceStepLease is how long a claimed step is held before another run may retry it
+	sequenceStepLease = 10 * time.Minute
+)
+
+// processDueSequenceSteps sends the steps that have come due in active sequences.
+// Claiming pushes next_step_at out by a lease, so only one instance sends each step and
+// a step interrupted by a restart is picked up again later.
+func (a *App) processDueSequenceSteps(ctx context.Context) {
+	now := time.Now()
+	active := a.DB.Model(&models.Sequence{}).Select("id").Where("status = ?", models.SequenceStatusActive)
+	suspended := a.DB.Model(&models.Organization{}).Select("id").Where("suspended_at IS NOT NULL")
+	due := a.DB.Model(&models.SequenceEnrollment{}).Select("id").
+		Where("status = ? AND next_step_at <= ?", models.SequenceEnrollmentActive, now).
+		Where("sequence_id IN (?)", active).
+		Where("organization_id NOT IN (?)", suspended).
+		Order("next_step_at ASC").
+		Limit(sequenceStepBatchSize).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var enrollments []models.SequenceEnrollment
+	if err := a.DB.Model(&enrollments).Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Update("next_step_at", now.Add(sequenceStepLease)).Error; err != nil {
+		a.Log.Error("Failed to claim due sequence steps", "error", err)
+		return
+	}
+
+	sequences := make(map[uuid.UUID]*models.Sequence)
+	for i := range enrollments {
+		enrollment := &enrollments[i]
+		sequence, ok := sequences[enrollment.SequenceID]
+		if !ok {
+			sequence = &models.Sequence{}
+			if err := a.DB.Where("id = ?", enrollment.SequenceID).
+				Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+				Preload("Steps.Template").
+				First(sequence).Error; err != nil {
+				a.Log.Error("Failed to load sequence", "error", err, "sequence_id", enrollment.SequenceID)
+				sequence = nil
+			}
+			sequences[enrollment.SequenceID] = sequence
+		}
+		if sequence == nil {
+			continue
+		}
+		a.sendSequenceStep(ctx, sequence, enrollment)
+	}
+}
+
+// sendSequenceStep sends an enrollment's next step and schedules the one after it
+func (a *App) sendSequenceStep(ctx context.Context, sequence *models.Sequence, enrollment *models.SequenceEnrollment) {
+	if enrollment.NextStep >= len(sequence.Steps) {
+		a.finishEnrollment(enrollment, models.SequenceEnrollmentCompleted, "", "")
+		return
+	}
+	step := &sequence.Steps[enrollment.NextStep]
+
+	var contact models.Contact
+	if err := a.DB.Where("id = ?", enrollment.ContactID).First(&contact).Error; err != nil {
+		a.finishEnrollment(enrollment, models.SequenceEnrollmentFailed, "", "Contact not found")
+		return
+	}
+	if contact.OptedOut {
+		a.finishEnrollment(enrollment, models.SequenceEnrollmentExited, models.SequenceExitOptedOut, "")
+		return
+	}
+	if step.Template == nil {
+		a.finishEnrollment(enrollment, models.SequenceEnrollmentFailed, "", fmt.Sprintf("Template of step %d not found", step.Position+1))
+		return
+	}
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", sequence.WhatsAppAccount, sequence.OrganizationID).
+		First(&account).Error; err != nil {
+		a.finishEnrollment(enrollment, models.SequenceEnrollmentFailed, "", "WhatsApp account not found")
+		return
+	}
+
+	names := ExtractParamNamesFromContent(step.Template.BodyContent)
+	params, missing := templatevars.Apply(names, nil, templatevars.FromJSONB(step.TemplateParamMapping), &contact)
+	if len(missing) > 0 {
+		a.finishEnrollment(enrollment, models.SequenceEnrollmentFailed, "",
+			fmt.Sprintf("No value for template placeholders in step %d: %s", step.Position+1, strings.Join(missing, ", ")))
+		return
+	}
+	bodyParams := make(map[string]string, len(params))
+	for k, v := range params {
+		bodyParams[k] = fmt.Sprintf("%v", v)
+	}
+
+	if _, err := a.SendOutgoingMessage(ctx, OutgoingMessageRequest{
+		Account:    &account,
+		Contact:    &contact,
+		Type:       models.MessageTypeTemplate,
+		Template:   step.Template,
+		BodyParams: bodyParams,
+	}, DefaultSendOptions()); err != nil {
+		a.Log.Warn("Sequence step not sent", "error", err, "sequence_id", sequence.ID, "contact_id", contact.ID)
+		a.finishEnrollment(enrollment, models.SequenceEnrollmentFailed, "", err.Error())
+		return
+	}
+
+	now := time.Now()
+	next := enrollment.NextStep + 1
+	updates := map[string]interface{}{
+		"next_step":    next,
+		"last_sent_at": now,
+	}
+	if next >= len(sequence.Steps) {
+		updates["status"] = models.SequenceEnrollmentCompleted
+		updates["next_step_at"] = nil
+		updates["finished_at"] = now
+	} else {
+		updates["next_step_at"] = now.Add(time.Duration(sequence.Steps[next].DelayMinutes) * time.Minute)
+	}
+	// The contact may have replied or opted out while the step was being sent
+	if err := a.DB.Model(&models.SequenceEnrollment{}).
+		Where("id = ? AND status = ?", enrollment.ID, models.SequenceEnrollmentActive).
+		Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to advance sequence enrollment", "error", err, "enrollment_id", enrollment.ID)
+	}
+}
+
+// finishEnrollment ends an enrollment that is still active
+func (a *App) finishEnrollment(enrollment *models.SequenceEnrollment, status models.SequenceEnrollmentStatus, reason models.SequenceExitReason, errMsg string) {
+	if err := a.DB.Model(&models.SequenceEnrollment{}).
+		Where("id = ? AND status = ?", enrollment.ID, models.SequenceEnrollmentActive).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"exit_reason":  reason,
+			"error":        errMsg,
+			"next_step_at": nil,
+			"finished_at":  time.Now(),
+		}).Error; err != nil {
+		a.Log.Error("Failed to finish sequence enrollment", "error", err, "enrollment_id", enrollment.ID)
+	}
+}
+
+// SequenceProcessor sends the steps of drip sequences as they come due
+type SequenceProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewSequenceProcessor creates a new sequence processor
+func NewSequenceProcessor(app *App, interval time.Duration) *SequenceProcessor {
+	return &SequenceProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the sending loop
+func (p *SequenceProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Sequence processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Sequence processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Sequence processor stopped")
+			return
+		case <-ticker.C:
+			p.app.processDueSequenceSteps(ctx)
+		}
+	}
+}
+
+// Stop stops the sequence processor
+func (p *SequenceProcessor) Stop() {
+	close(p.stopCh)
+}
@@ -0,0 +1,706 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSequenceSteps caps the templates of a sequence
+	maxSequenceSteps = 20
+	// maxSequenceStepDelay is the longest wait between two steps
+	maxSequenceStepDelay = 90 * 24 * 60
+)
+
+// SequenceStepRequest is one step of a sequence create/update request
+type SequenceStepRequest struct {
+	TemplateID           string            `json:"template_id"`
+	DelayMinutes         int               `json:"delay_minutes"`
+	TemplateParamMapping map[string]string `json:"template_param_mapping"`
+}
+
+// SequenceRequest represents the request body for creating/updating a sequence
+type SequenceRequest struct {
+	Name              string                `json:"name" validate:"required"`
+	WhatsAppAccount   string                `json:"whatsapp_account" validate:"required"`
+	ExitOnReply       *bool                 `json:"exit_on_reply"` // Defaults to true
+	ExitOnButtonClick bool                  `json:"exit_on_button_click"`
+	Steps             []SequenceStepRequest `json:"steps"`
+}
+
+// SequenceStepResponse represents a sequence step in API responses
+type SequenceStepResponse struct {
+	ID                   uuid.UUID    `json:"id"`
+	Position             int          `json:"position"`
+	TemplateID           uuid.UUID    `json:"template_id"`
+	TemplateName         string       `json:"template_name"`
+	DelayMinutes         int          `json:"delay_minutes"`
+	TemplateParamMapping models.JSONB `json:"template_param_mapping"`
+}
+
+// SequenceResponse represents a sequence in API responses
+type SequenceResponse struct {
+	ID                uuid.UUID              `json:"id"`
+	Name              string                 `json:"name"`
+	WhatsAppAccount   string                 `json:"whatsapp_account"`
+	Status            models.SequenceStatus  `json:"status"`
+	ExitOnReply       bool                   `json:"exit_on_reply"`
+	ExitOnButtonClick bool                   `json:"exit_on_button_click"`
+	Steps             []SequenceStepResponse `json:"steps"`
+	Enrollments       map[string]int64       `json:"enrollments"` // Enrollment status -> count
+	CreatedAt         time.Time              `json:"created_at"`
+	UpdatedAt         time.Time              `json:"updated_at"`
+}
+
+// SequenceEnrollRequest enrolls contacts, picked directly or through a segment
+type SequenceEnrollRequest struct {
+	ContactIDs []string `json:"contact_ids"`
+	SegmentID  string   `json:"segment_id"`
+}
+
+// SequenceEnrollmentResponse represents a contact's enrollment in API responses
+type SequenceEnrollmentResponse struct {
+	ID          uuid.UUID                       `json:"id"`
+	ContactID   uuid.UUID                       `json:"contact_id"`
+	PhoneNumber string                          `json:"phone_number"`
+	ContactName string                          `json:"contact_name"`
+	Status      models.SequenceEnrollmentStatus `json:"status"`
+	NextStep    int                             `json:"next_step"`
+	NextStepAt  *time.Time                      `json:"next_step_at,omitempty"`
+	LastSentAt  *time.Time                      `json:"last_sent_at,omitempty"`
+	ExitReason  models.SequenceExitReason       `json:"exit_reason,omitempty"`
+	Error       string                          `json:"error,omitempty"`
+	EnrolledAt  time.Time                       `json:"enrolled_at"`
+	FinishedAt  *time.Time                      `json:"finished_at,omitempty"`
+}
+
+// ListSequences returns the organization's sequences with their enrollment counts
+func (a *App) ListSequences(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var sequences []models.Sequence
+	if err := a.DB.Where("organization_id = ?", orgID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Steps.Template").
+		Order("created_at DESC").Find(&sequences).Error; err != nil {
+		a.Log.Error("Failed to list sequences", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list sequences", nil, "")
+	}
+
+	ids := make([]uuid.UUID, len(sequences))
+	for i, s := range sequences {
+		ids[i] = s.ID
+	}
+	counts := a.sequenceEnrollmentCounts(ids)
+
+	result := make([]SequenceResponse, len(sequences))
+	for i := range sequences {
+		result[i] = sequenceToResponse(&sequences[i], counts[sequences[i].ID])
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"sequences": result,
+	})
+}
+
+// CreateSequence saves a new sequence as a draft
+func (a *App) CreateSequence(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req SequenceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	steps, err := a.validateSequenceRequest(orgID, &req)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	exitOnReply := req.ExitOnReply == nil || *req.ExitOnReply
+	sequence := models.Sequence{
+		OrganizationID:    orgID,
+		WhatsAppAccount:   req.WhatsAppAccount,
+		Name:              req.Name,
+		Status:            models.SequenceStatusDraft,
+		ExitOnReply:       exitOnReply,
+		ExitOnButtonClick: req.ExitOnButtonClick,
+		CreatedBy:         userID,
+	}
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Steps").Create(&sequence).Error; err != nil {
+			return err
+		}
+		// A false value is left to the column default on insert
+		if !exitOnReply {
+			if err := tx.Model(&sequence).Update("exit_on_reply", false).Error; err != nil {
+				return err
+			}
+		}
+		return createSequenceSteps(tx, sequence.ID, steps)
+	})
+	if err != nil {
+		a.Log.Error("Failed to create sequence", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create sequence", nil, "")
+	}
+
+	sequence.Steps = steps
+	return r.SendEnvelope(sequenceToResponse(&sequence, nil))
+}
+
+// GetSequence returns a single sequence
+func (a *App) GetSequence(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionRead)
+	if err != nil || sequence == nil {
+		return err
+	}
+	counts := a.sequenceEnrollmentCounts([]uuid.UUID{sequence.ID})
+	return r.SendEnvelope(sequenceToResponse(sequence, counts[sequence.ID]))
+}
+
+// UpdateSequence changes a sequence's settings and replaces its steps. Enrolled contacts
+// continue from the same position in the new steps.
+func (a *App) UpdateSequence(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionWrite)
+	if err != nil || sequence == nil {
+		return err
+	}
+
+	var req SequenceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	steps, err := a.validateSequenceRequest(sequence.OrganizationID, &req)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	if sequence.Status == models.SequenceStatusActive && len(steps) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "An active sequence needs at least one step", nil, "")
+	}
+
+	sequence.Name = req.Name
+	sequence.WhatsAppAccount = req.WhatsAppAccount
+	sequence.ExitOnReply = req.ExitOnReply == nil || *req.ExitOnReply
+	sequence.ExitOnButtonClick = req.ExitOnButtonClick
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(sequence).Updates(map[string]interface{}{
+			"name":                 sequence.Name,
+			"whats_app_account":    sequence.WhatsAppAccount,
+			"exit_on_reply":        sequence.ExitOnReply,
+			"exit_on_button_click": sequence.ExitOnButtonClick,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("sequence_id = ?", sequence.ID).Delete(&models.SequenceStep{}).Error; err != nil {
+			return err
+		}
+		if err := createSequenceSteps(tx, sequence.ID, steps); err != nil {
+			return err
+		}
+		// Contacts past the new last step have nothing left to receive
+		now := time.Now()
+		return tx.Model(&models.SequenceEnrollment{}).
+			Where("sequence_id = ? AND status = ? AND next_step >= ?", sequence.ID, models.SequenceEnrollmentActive, len(steps)).
+			Updates(map[string]interface{}{
+				"status":       models.SequenceEnrollmentCompleted,
+				"next_step_at": nil,
+				"finished_at":  now,
+			}).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to update sequence", "error", err, "sequence_id", sequence.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update sequence", nil, "")
+	}
+
+	sequence.Steps = steps
+	counts := a.sequenceEnrollmentCounts([]uuid.UUID{sequence.ID})
+	return r.SendEnvelope(sequenceToResponse(sequence, counts[sequence.ID]))
+}
+
+// DeleteSequence deletes a sequence with its steps and enrollments
+func (a *App) DeleteSequence(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionDelete)
+	if err != nil || sequence == nil {
+		return err
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("sequence_id = ?", sequence.ID).Delete(&models.SequenceEnrollment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("sequence_id = ?", sequence.ID).Delete(&models.SequenceStep{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(sequence).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to delete sequence", "error", err, "sequence_id", sequence.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete sequence", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Sequence deleted"})
+}
+
+// ActivateSequence starts sending the steps of enrolled contacts. Steps that came due
+// while the sequence was paused are sent on the next run.
+func (a *App) ActivateSequence(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionWrite)
+	if err != nil || sequence == nil {
+		return err
+	}
+	if len(sequence.Steps) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Add at least one step before activating the sequence", nil, "")
+	}
+	return a.setSequenceStatus(r, sequence, models.SequenceStatusActive)
+}
+
+// PauseSequence stops sending steps; enrolled contacts keep their place
+func (a *App) PauseSequence(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionWrite)
+	if err != nil || sequence == nil {
+		return err
+	}
+	if sequence.Status != models.SequenceStatusActive {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Only active sequences can be paused", nil, "")
+	}
+	return a.setSequenceStatus(r, sequence, models.SequenceStatusPaused)
+}
+
+// EnrollSequenceContacts enrolls contacts in a sequence. Contacts already going through
+// it are left alone, those who finished or left start over, and opted-out contacts are
+// skipped.
+func (a *App) EnrollSequenceContacts(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionWrite)
+	if err != nil || sequence == nil {
+		return err
+	}
+	if len(sequence.Steps) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Add at least one step before enrolling contacts", nil, "")
+	}
+
+	var req SequenceEnrollRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	var query *gorm.DB
+	switch {
+	case req.SegmentID != "":
+		segmentID, err := uuid.Parse(req.SegmentID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid segment ID", nil, "")
+		}
+		var segment models.Segment
+		if err := a.DB.Where("id = ? AND organization_id = ?", segmentID, sequence.OrganizationID).First(&segment).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Segment not found", nil, "")
+		}
+		query = a.segmentContacts(sequence.OrganizationID, segment.Filters)
+	case len(req.ContactIDs) > 0:
+		ids := make([]uuid.UUID, 0, len(req.ContactIDs))
+		for _, s := range req.ContactIDs {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID: "+s, nil, "")
+			}
+			ids = append(ids, id)
+		}
+		query = a.DB.Model(&models.Contact{}).Where("contacts.organization_id = ? AND contacts.id IN ?", sequence.OrganizationID, ids)
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_ids or segment_id is required", nil, "")
+	}
+
+	var enrolled, skipped int
+	var contacts []models.Contact
+	err = query.Select("contacts.id, contacts.opted_out").
+		FindInBatches(&contacts, recipientInsertBatchSize, func(tx *gorm.DB, _ int) error {
+			n, err := a.enrollContacts(sequence, contacts)
+			enrolled += n
+			skipped += len(contacts) - n
+			return err
+		}).Error
+	if err != nil {
+		a.Log.Error("Failed to enroll sequence contacts", "error", err, "sequence_id", sequence.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to enroll contacts", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"enrolled": enrolled,
+		"skipped":  skipped,
+	})
+}
+
+// ListSequenceEnrollments lists a sequence's enrollments, most recent first
+func (a *App) ListSequenceEnrollments(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionRead)
+	if err != nil || sequence == nil {
+		return err
+	}
+
+	page, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	query := a.DB.Model(&models.SequenceEnrollment{}).Where("sequence_id = ?", sequence.ID)
+	if status := string(r.RequestCtx.QueryArgs().Peek("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		a.Log.Error("Failed to count sequence enrollments", "error", err, "sequence_id", sequence.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list enrollments", nil, "")
+	}
+
+	var enrollments []models.SequenceEnrollment
+	if err := query.Preload("Contact").Order("enrolled_at DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&enrollments).Error; err != nil {
+		a.Log.Error("Failed to list sequence enrollments", "error", err, "sequence_id", sequence.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list enrollments", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	mask := a.dataMaskFor(sequence.OrganizationID, userID)
+	result := make([]SequenceEnrollmentResponse, len(enrollments))
+	for i, e := range enrollments {
+		result[i] = SequenceEnrollmentResponse{
+			ID:         e.ID,
+			ContactID:  e.ContactID,
+			Status:     e.Status,
+			NextStep:   e.NextStep,
+			NextStepAt: e.NextStepAt,
+			LastSentAt: e.LastSentAt,
+			ExitReason: e.ExitReason,
+			Error:      e.Error,
+			EnrolledAt: e.EnrolledAt,
+			FinishedAt: e.FinishedAt,
+		}
+		if e.Contact != nil {
+			result[i].PhoneNumber = mask.Phone(e.Contact.PhoneNumber)
+			result[i].ContactName = mask.Name(e.Contact.ProfileName)
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"enrollments": result,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+// UnenrollSequenceContact takes a contact out of a sequence before it finishes
+func (a *App) UnenrollSequenceContact(r *fastglue.Request) error {
+	sequence, err := a.loadSequence(r, models.ActionWrite)
+	if err != nil || sequence == nil {
+		return err
+	}
+	enrollmentID, err := uuid.Parse(r.RequestCtx.UserValue("enrollment_id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid enrollment ID", nil, "")
+	}
+
+	result := a.DB.Model(&models.SequenceEnrollment{}).
+		Where("id = ? AND sequence_id = ? AND status = ?", enrollmentID, sequence.ID, models.SequenceEnrollmentActive).
+		Updates(exitEnrollmentUpdates(models.SequenceExitUnenrolled))
+	if result.Error != nil {
+		a.Log.Error("Failed to unenroll contact", "error", result.Error, "enrollment_id", enrollmentID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to unenroll contact", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Active enrollment not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Contact unenrolled"})
+}
+
+// exitSequencesOnInbound ends the contact's enrollments in sequences that stop when the
+// contact writes back or, for button taps, when a button is clicked
+func (a *App) exitSequencesOnInbound(contact *models.Contact, buttonClick bool) {
+	if buttonClick {
+		clickExits := a.DB.Model(&models.Sequence{}).Select("id").Where("exit_on_button_click = ?", true)
+		if err := a.DB.Model(&models.SequenceEnrollment{}).
+			Where("contact_id = ? AND status = ? AND sequence_id IN (?)", contact.ID, models.SequenceEnrollmentActive, clickExits).
+			Updates(exitEnrollmentUpdates(models.SequenceExitButtonClicked)).Error; err != nil {
+			a.Log.Error("Failed to exit sequences on button click", "error", err, "contact_id", contact.ID)
+		}
+	}
+
+	replyExits := a.DB.Model(&models.Sequence{}).Select("id").Where("exit_on_reply = ?", true)
+	if err := a.DB.Model(&models.SequenceEnrollment{}).
+		Where("contact_id = ? AND status = ? AND sequence_id IN (?)", contact.ID, models.SequenceEnrollmentActive, replyExits).
+		Updates(exitEnrollmentUpdates(models.SequenceExitReplied)).Error; err != nil {
+		a.Log.Error("Failed to exit sequences on reply", "error", err, "contact_id", contact.ID)
+	}
+}
+
+// exitEnrollmentUpdates returns the column changes that take an enrollment out of its sequence
+func exitEnrollmentUpdates(reason models.SequenceExitReason) map[string]interface{} {
+	return map[string]interface{}{
+		"status":       models.SequenceEnrollmentExited,
+		"exit_reason":  reason,
+		"next_step_at": nil,
+		"finished_at":  time.Now(),
+	}
+}
+
+// enrollContacts enrolls the contacts who aren't opted out or already going through the
+// sequence and returns how many were enrolled. The sequence's Steps must be loaded.
+func (a *App) enrollContacts(sequence *models.Sequence, contacts []models.Contact) (int, error) {
+	ids := make([]uuid.UUID, 0, len(contacts))
+	for _, c := range contacts {
+		if !c.OptedOut {
+			ids = append(ids, c.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var existing []models.SequenceEnrollment
+	if err := a.DB.Select("id, contact_id, status").
+		Where("sequence_id = ? AND contact_id IN ?", sequence.ID, ids).
+		Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	previous := make(map[uuid.UUID]*models.SequenceEnrollment, len(existing))
+	for i := range existing {
+		previous[existing[i].ContactID] = &existing[i]
+	}
+
+	now := time.Now()
+	firstStepAt := now.Add(time.Duration(sequence.Steps[0].DelayMinutes) * time.Minute)
+	var restart []uuid.UUID
+	var fresh []models.SequenceEnrollment
+	for _, id := range ids {
+		e, ok := previous[id]
+		switch {
+		case !ok:
+			fresh = append(fresh, models.SequenceEnrollment{
+				OrganizationID: sequence.OrganizationID,
+				SequenceID:     sequence.ID,
+				ContactID:      id,
+				Status:         models.SequenceEnrollmentActive,
+				NextStepAt:     &firstStepAt,
+				EnrolledAt:     now,
+			})
+		case e.Status != models.SequenceEnrollmentActive:
+			restart = append(restart, e.ID)
+		}
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if len(fresh) > 0 {
+			if err := tx.Create(&fresh).Error; err != nil {
+				return err
+			}
+		}
+		if len(restart) == 0 {
+			return nil
+		}
+		return tx.Model(&models.SequenceEnrollment{}).Where("id IN ?", restart).Updates(map[string]interface{}{
+			"status":       models.SequenceEnrollmentActive,
+			"next_step":    0,
+			"next_step_at": firstStepAt,
+			"last_sent_at": nil,
+			"exit_reason":  "",
+			"error":        "",
+			"enrolled_at":  now,
+			"finished_at":  nil,
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(fresh) + len(restart), nil
+}
+
+// validateSequenceRequest trims the request, checks the account and builds the steps
+func (a *App) validateSequenceRequest(orgID uuid.UUID, req *SequenceRequest) ([]models.SequenceStep, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.WhatsAppAccount == "" {
+		return nil, fmt.Errorf("whatsapp_account is required")
+	}
+	var count int64
+	a.DB.Model(&models.WhatsAppAccount{}).Where("name = ? AND organization_id = ?", req.WhatsAppAccount, orgID).Count(&count)
+	if count == 0 {
+		return nil, fmt.Errorf("WhatsApp account not found")
+	}
+	if len(req.Steps) > maxSequenceSteps {
+		return nil, fmt.Errorf("a sequence can have at most %d steps", maxSequenceSteps)
+	}
+
+	steps := make([]models.SequenceStep, len(req.Steps))
+	for i, s := range req.Steps {
+		templateID, err := uuid.Parse(s.TemplateID)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: invalid template ID", i+1)
+		}
+		var template models.Template
+		if err := a.DB.Where("id = ? AND organization_id = ?", templateID, orgID).First(&template).Error; err != nil {
+			return nil, fmt.Errorf("step %d: template not found", i+1)
+		}
+		if template.ArchivedAt != nil {
+			return nil, fmt.Errorf("step %d: template %s is archived", i+1, template.Name)
+		}
+		if s.DelayMinutes < 0 || s.DelayMinutes > maxSequenceStepDelay {
+			return nil, fmt.Errorf("step %d: delay must be between 0 and %d minutes", i+1, maxSequenceStepDelay)
+		}
+		mapping, err := campaignParamMapping(&template, s.TemplateParamMapping)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		// Contacts carry no values of their own, so every placeholder needs a mapping
+		for _, name := range ExtractParamNamesFromContent(template.BodyContent) {
+			if _, ok := mapping[name]; !ok {
+				return nil, fmt.Errorf("step %d: map the {{%s}} placeholder to a contact field", i+1, name)
+			}
+		}
+		steps[i] = models.SequenceStep{
+			Position:             i,
+			TemplateID:           templateID,
+			DelayMinutes:         s.DelayMinutes,
+			TemplateParamMapping: mapping.JSONB(),
+			Template:             &template,
+		}
+	}
+	return steps, nil
+}
+
+// createSequenceSteps saves the steps of a sequence, setting their IDs
+func createSequenceSteps(tx *gorm.DB, sequenceID uuid.UUID, steps []models.SequenceStep) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	for i := range steps {
+		steps[i].SequenceID = sequenceID
+	}
+	return tx.Omit("Template").Create(&steps).Error
+}
+
+// setSequenceStatus saves a sequence's new status and sends it back
+func (a *App) setSequenceStatus(r *fastglue.Request, sequence *models.Sequence, status models.SequenceStatus) error {
+	if err := a.DB.Model(sequence).Update("status", status).Error; err != nil {
+		a.Log.Error("Failed to update sequence status", "error", err, "sequence_id", sequence.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update sequence", nil, "")
+	}
+	sequence.Status = status
+	counts := a.sequenceEnrollmentCounts([]uuid.UUID{sequence.ID})
+	return r.SendEnvelope(sequenceToResponse(sequence, counts[sequence.ID]))
+}
+
+// loadSequence checks the campaigns permission for action and loads the sequence from
+// the path with its steps. On failure it sends the error response and returns a nil
+// sequence.
+func (a *App) loadSequence(r *fastglue.Request, action string) (*models.Sequence, error) {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, action) {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid sequence ID", nil, "")
+	}
+
+	var sequence models.Sequence
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Steps.Template").
+		First(&sequence).Error; err != nil {
+		return nil, r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sequence not found", nil, "")
+	}
+	return &sequence, nil
+}
+
+// sequenceEnrollmentCounts counts the enrollments of each sequence by status
+func (a *App) sequenceEnrollmentCounts(sequenceIDs []uuid.UUID) map[uuid.UUID]map[string]int64 {
+	counts := make(map[uuid.UUID]map[string]int64, len(sequenceIDs))
+	if len(sequenceIDs) == 0 {
+		return counts
+	}
+
+	var rows []struct {
+		SequenceID uuid.UUID
+		Status     string
+		Count      int64
+	}
+	if err := a.DB.Model(&models.SequenceEnrollment{}).
+		Select("sequence_id, status, COUNT(*) AS count").
+		Where("sequence_id IN ?", sequenceIDs).
+		Group("sequence_id, status").
+		Scan(&rows).Error; err != nil {
+		a.Log.Error("Failed to count sequence enrollments", "error", err)
+		return counts
+	}
+	for _, row := range rows {
+		if counts[row.SequenceID] == nil {
+			counts[row.SequenceID] = make(map[string]int64)
+		}
+		counts[row.SequenceID][row.Status] = row.Count
+	}
+	return counts
+}
+
+// sequenceToResponse converts a sequence with its loaded steps
+func sequenceToResponse(sequence *models.Sequence, counts map[string]int64) SequenceResponse {
+	if counts == nil {
+		counts = map[string]int64{}
+	}
+	steps := make([]SequenceStepResponse, len(sequence.Steps))
+	for i, s := range sequence.Steps {
+		steps[i] = SequenceStepResponse{
+			ID:                   s.ID,
+			Position:             s.Position,
+			TemplateID:           s.TemplateID,
+			DelayMinutes:         s.DelayMinutes,
+			TemplateParamMapping: s.TemplateParamMapping,
+		}
+		if s.Template != nil {
+			steps[i].TemplateName = s.Template.Name
+		}
+	}
+	return SequenceResponse{
+		ID:                sequence.ID,
+		Name:              sequence.Name,
+		WhatsAppAccount:   sequence.WhatsAppAccount,
+		Status:            sequence.Status,
+		ExitOnReply:       sequence.ExitOnReply,
+		ExitOnButtonClick: sequence.ExitOnButtonClick,
+		Steps:             steps,
+		Enrollments:       counts,
+		CreatedAt:         sequence.CreatedAt,
+		UpdatedAt:         sequence.UpdatedAt,
+	}
+}
@@ -0,0 +1,217 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// runSequenceProcessor runs the sequence processor until cond holds
+func runSequenceProcessor(t *testing.T, app *handlers.App, cond func() bool) {
+	t.Helper()
+
+	processor := handlers.NewSequenceProcessor(app, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		processor.Start(ctx)
+		close(done)
+	}()
+	require.Eventually(t, cond, 5*time.Second, 20*time.Millisecond)
+	cancel()
+	<-done
+}
+
+// sequenceEnrollment loads a contact's enrollment in a sequence
+func sequenceEnrollment(t *testing.T, app *handlers.App, sequenceID, contactID uuid.UUID) models.SequenceEnrollment {
+	t.Helper()
+
+	var enrollment models.SequenceEnrollment
+	require.NoError(t, app.DB.Where("sequence_id = ? AND contact_id = ?", sequenceID, contactID).First(&enrollment).Error)
+	return enrollment
+}
+
+func TestApp_Sequence(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	require.NoError(t, app.DB.Model(account).Update("phone_id", "phone-"+uuid.NewString()[:8]).Error)
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("sequence"), "password", &role.ID, true)
+
+	call := func(handler func(*fastglue.Request) error, body interface{}, params map[string]string) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, body)
+		setAuthContext(req, org.ID, user.ID)
+		for k, v := range params {
+			testutil.SetPathParam(req, k, v)
+		}
+		require.NoError(t, handler(req))
+		return req
+	}
+	step := func(mapping map[string]string, delay int) map[string]interface{} {
+		return map[string]interface{}{
+			"template_id":            template.ID.String(),
+			"delay_minutes":          delay,
+			"template_param_mapping": mapping,
+		}
+	}
+	body := map[string]interface{}{
+		"name":             "Onboarding",
+		"whatsapp_account": account.Name,
+		"steps":            []interface{}{step(map[string]string{"1": "{{contact.email}}"}, 0)},
+	}
+
+	// Placeholders must map to known contact fields
+	req := call(app.CreateSequence, body, nil)
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	body["steps"] = []interface{}{
+		step(map[string]string{"1": "{{contact.name}}"}, 0),
+		step(map[string]string{"1": "{{contact.name}}"}, 24*60),
+	}
+	req = call(app.CreateSequence, body, nil)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var created handlers.SequenceResponse
+	testutil.ParseEnvelopeResponse(t, req, &created)
+	assert.Equal(t, models.SequenceStatusDraft, created.Status)
+	assert.True(t, created.ExitOnReply)
+	require.Len(t, created.Steps, 2)
+	assert.Equal(t, template.Name, created.Steps[1].TemplateName)
+	id := map[string]string{"id": created.ID.String()}
+
+	optedOut := createTestContact(t, app, org.ID)
+	require.NoError(t, app.DB.Model(optedOut).Update("opted_out", true).Error)
+	replier := createTestContact(t, app, org.ID)
+	require.NoError(t, app.DB.Model(replier).Update("phone_number", "14155550190").Error)
+	leaver := createTestContact(t, app, org.ID)
+
+	// Opted-out contacts aren't enrolled, and enrolling twice changes nothing
+	enroll := func(contacts ...*models.Contact) (enrolled, skipped int) {
+		ids := make([]string, len(contacts))
+		for i, c := range contacts {
+			ids[i] = c.ID.String()
+		}
+		req := call(app.EnrollSequenceContacts, map[string]interface{}{"contact_ids": ids}, id)
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp struct {
+			Enrolled int `json:"enrolled"`
+			Skipped  int `json:"skipped"`
+		}
+		testutil.ParseEnvelopeResponse(t, req, &resp)
+		return resp.Enrolled, resp.Skipped
+	}
+	enrolled, skipped := enroll(optedOut, replier, leaver)
+	assert.Equal(t, 2, enrolled)
+	assert.Equal(t, 1, skipped)
+	enrolled, _ = enroll(replier)
+	assert.Zero(t, enrolled)
+
+	// Once the sequence is active, due steps are sent and the next one scheduled
+	req = call(app.ActivateSequence, nil, id)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	runSequenceProcessor(t, app, func() bool {
+		return sequenceEnrollment(t, app, created.ID, replier.ID).NextStep == 1 &&
+			sequenceEnrollment(t, app, created.ID, leaver.ID).NextStep == 1
+	})
+	require.Eventually(t, func() bool {
+		var count int64
+		app.DB.Model(&models.Message{}).
+			Where("contact_id IN ? AND message_type = ?", []uuid.UUID{replier.ID, leaver.ID}, models.MessageTypeTemplate).
+			Count(&count)
+		return count == 2
+	}, 2*time.Second, 20*time.Millisecond)
+	enrollment := sequenceEnrollment(t, app, created.ID, replier.ID)
+	assert.Equal(t, models.SequenceEnrollmentActive, enrollment.Status)
+	require.NotNil(t, enrollment.NextStepAt)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *enrollment.NextStepAt, time.Minute)
+
+	// A reply ends the sequence for that contact
+	webhook := fmt.Sprintf(`{"object":"whatsapp_business_account","entry":[{"id":"waba","changes":[{"field":"messages","value":{
+		"messaging_product":"whatsapp","metadata":{"phone_number_id":%q},
+		"contacts":[{"profile":{"name":"Sam"},"wa_id":%q}],
+		"messages":[{"from":%q,"id":%q,"timestamp":%q,"type":"text","text":{"body":"Thanks!"}}]}}]}]}`,
+		account.PhoneID, "14155550190", "14155550190", "wamid."+uuid.NewString(), fmt.Sprint(time.Now().Unix()))
+	req = testutil.NewJSONRequest(t, nil)
+	req.RequestCtx.Request.SetBody([]byte(webhook))
+	require.NoError(t, app.WebhookHandler(req))
+	require.Eventually(t, func() bool {
+		return sequenceEnrollment(t, app, created.ID, replier.ID).Status == models.SequenceEnrollmentExited
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, models.SequenceExitReplied, sequenceEnrollment(t, app, created.ID, replier.ID).ExitReason)
+
+	// Unenrolling takes the other contact out
+	leaverEnrollment := sequenceEnrollment(t, app, created.ID, leaver.ID)
+	req = call(app.UnenrollSequenceContact, nil, map[string]string{"id": created.ID.String(), "enrollment_id": leaverEnrollment.ID.String()})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Equal(t, models.SequenceExitUnenrolled, sequenceEnrollment(t, app, created.ID, leaver.ID).ExitReason)
+
+	// Contacts who left can be enrolled again from the start
+	enrolled, _ = enroll(leaver)
+	assert.Equal(t, 1, enrolled)
+	leaverEnrollment = sequenceEnrollment(t, app, created.ID, leaver.ID)
+	assert.Equal(t, models.SequenceEnrollmentActive, leaverEnrollment.Status)
+	assert.Zero(t, leaverEnrollment.NextStep)
+	assert.Empty(t, leaverEnrollment.ExitReason)
+
+	req = call(app.GetSequence, nil, id)
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var got handlers.SequenceResponse
+	testutil.ParseEnvelopeResponse(t, req, &got)
+	assert.Equal(t, int64(1), got.Enrollments[string(models.SequenceEnrollmentActive)])
+	assert.Equal(t, int64(1), got.Enrollments[string(models.SequenceEnrollmentExited)])
+}
+
+func TestApp_Sequence_OptOutExits(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrganization(t, app)
+	role := createTransferAdminRole(t, app.DB, org.ID)
+	user := createTestUser(t, app, org.ID, uniqueEmail("sequence-opt-out"), "password", &role.ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "sequence-opt-out-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+	contact := createTestContact(t, app, org.ID)
+
+	sequence := &models.Sequence{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		Name:            "Win-back",
+		Status:          models.SequenceStatusActive,
+		CreatedBy:       user.ID,
+	}
+	require.NoError(t, app.DB.Create(sequence).Error)
+	require.NoError(t, app.DB.Create(&models.SequenceStep{SequenceID: sequence.ID, TemplateID: template.ID}).Error)
+	nextStepAt := time.Now().Add(time.Hour)
+	require.NoError(t, app.DB.Create(&models.SequenceEnrollment{
+		OrganizationID: org.ID,
+		SequenceID:     sequence.ID,
+		ContactID:      contact.ID,
+		Status:         models.SequenceEnrollmentActive,
+		NextStepAt:     &nextStepAt,
+		EnrolledAt:     time.Now(),
+	}).Error)
+
+	req := testutil.NewJSONRequest(t, map[string]string{"reason": "Not interested"})
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", contact.ID.String())
+	require.NoError(t, app.OptOutContact(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	enrollment := sequenceEnrollment(t, app, sequence.ID, contact.ID)
+	assert.Equal(t, models.SequenceEnrollmentExited, enrollment.Status)
+	assert.Equal(t, models.SequenceExitOptedOut, enrollment.ExitReason)
+	assert.Nil(t, enrollment.NextStepAt)
+}
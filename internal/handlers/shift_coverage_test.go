@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnShiftAt(t *testing.T) {
+	// Monday 09:00-17:00 and a Friday night shift running into Saturday
+	shifts := []models.AgentShift{
+		{DayOfWeek: 1, StartTime: "09:00", EndTime: "17:00"},
+		{DayOfWeek: 5, StartTime: "22:00", EndTime: "06:00"},
+	}
+	at := func(day, hour, minute int) time.Time {
+		// 2024-01-07 is a Sunday
+		return time.Date(2024, time.January, 7+day, hour, minute, 0, 0, time.UTC)
+	}
+
+	assert.True(t, onShiftAt(shifts, at(1, 9, 0)))
+	assert.True(t, onShiftAt(shifts, at(1, 16, 59)))
+	assert.False(t, onShiftAt(shifts, at(1, 17, 0)), "the end time is exclusive")
+	assert.False(t, onShiftAt(shifts, at(2, 10, 0)))
+	assert.True(t, onShiftAt(shifts, at(5, 23, 0)))
+	assert.True(t, onShiftAt(shifts, at(6, 5, 59)), "overnight shifts run into the next day")
+	assert.False(t, onShiftAt(shifts, at(6, 6, 0)))
+	assert.False(t, onShiftAt(shifts, at(5, 5, 0)), "overnight shifts don't cover the morning of their own day")
+}
+
+func TestValidateShift(t *testing.T) {
+	assert.NoError(t, validateShift(ShiftRequest{DayOfWeek: 6, StartTime: "22:00", EndTime: "06:00"}))
+	assert.Error(t, validateShift(ShiftRequest{DayOfWeek: 7, StartTime: "09:00", EndTime: "17:00"}))
+	assert.Error(t, validateShift(ShiftRequest{DayOfWeek: 1, StartTime: "9am", EndTime: "17:00"}))
+	assert.Error(t, validateShift(ShiftRequest{DayOfWeek: 1, StartTime: "09:00", EndTime: "09:00"}))
+}
+
+func TestShiftCoverage(t *testing.T) {
+	ada, bob := uuid.New(), uuid.New()
+	shifts := map[uuid.UUID][]models.AgentShift{
+		ada: {{DayOfWeek: 1, StartTime: "09:00", EndTime: "17:00"}},
+		bob: {{DayOfWeek: 1, StartTime: "12:00", EndTime: "20:00"}},
+	}
+	// Monday business hours only
+	businessHours := models.JSONBArray{
+		map[string]interface{}{"day": float64(1), "enabled": true, "start_time": "08:00", "end_time": "18:00"},
+	}
+
+	slots, gaps := shiftCoverage([]uuid.UUID{ada, bob}, shifts, businessHours, 2)
+	require.Len(t, slots, 7*48)
+
+	monday := func(clock string) ShiftCoverageSlot {
+		for _, s := range slots {
+			if s.DayOfWeek == 1 && s.StartTime == clock {
+				return s
+			}
+		}
+		t.Fatalf("no slot at %s", clock)
+		return ShiftCoverageSlot{}
+	}
+	assert.Equal(t, []uuid.UUID{ada, bob}, monday("12:00").AgentIDs)
+	assert.Equal(t, 1, monday("17:30").Agents)
+	assert.False(t, monday("18:00").Required)
+	assert.True(t, monday("17:30").Required)
+
+	assert.Equal(t, []ShiftCoverageGap{
+		{DayOfWeek: 1, StartTime: "08:00", EndTime: "12:00", Agents: 0},
+		{DayOfWeek: 1, StartTime: "17:00", EndTime: "18:00", Agents: 1},
+	}, gaps)
+
+	// Without business hours every understaffed slot counts, one gap per day at most
+	_, gaps = shiftCoverage([]uuid.UUID{ada}, shifts, nil, 1)
+	require.Len(t, gaps, 8)
+	assert.Equal(t, ShiftCoverageGap{DayOfWeek: 0, StartTime: "00:00", EndTime: "00:00", Agents: 0}, gaps[0])
+	assert.Equal(t, ShiftCoverageGap{DayOfWeek: 1, StartTime: "00:00", EndTime: "09:00", Agents: 0}, gaps[1])
+	assert.Equal(t, ShiftCoverageGap{DayOfWeek: 1, StartTime: "17:00", EndTime: "00:00", Agents: 0}, gaps[2])
+}
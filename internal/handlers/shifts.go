@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// shiftSlotMinutes is the resolution of the coverage view
+	shiftSlotMinutes = 30
+	// maxShiftsPerSchedule bounds a weekly schedule; several windows a day is plenty
+	maxShiftsPerSchedule = 50
+)
+
+// ShiftRequest is one weekly window in a schedule update
+type ShiftRequest struct {
+	DayOfWeek int    `json:"day_of_week"` // 0 = Sunday
+	StartTime string `json:"start_time"`  // HH:MM
+	EndTime   string `json:"end_time"`    // HH:MM, before start_time for overnight shifts
+}
+
+// ShiftScheduleRequest replaces the weekly schedule of an agent or team
+type ShiftScheduleRequest struct {
+	Shifts []ShiftRequest `json:"shifts"`
+}
+
+// ShiftAgent identifies an agent in the coverage view
+type ShiftAgent struct {
+	ID       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+// ShiftCoverageSlot is the number of agents on shift during one slot of the week
+type ShiftCoverageSlot struct {
+	DayOfWeek int         `json:"day_of_week"`
+	StartTime string      `json:"start_time"`
+	Agents    int         `json:"agents"`
+	AgentIDs  []uuid.UUID `json:"agent_ids,omitempty"`
+	Required  bool        `json:"required"` // Within business hours
+}
+
+// ShiftCoverageGap is a stretch of required time with fewer agents than wanted
+type ShiftCoverageGap struct {
+	DayOfWeek int    `json:"day_of_week"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Agents    int    `json:"agents"` // Fewest agents on shift during the gap
+}
+
+// ShiftCoverageResponse is the weekly coverage of an organization or team
+type ShiftCoverageResponse struct {
+	MinAgents     int                 `json:"min_agents"`
+	BusinessHours bool                `json:"business_hours"` // Gaps are limited to business hours
+	Timezone      string              `json:"timezone"`
+	Agents        []ShiftAgent        `json:"agents"`
+	Slots         []ShiftCoverageSlot `json:"slots"`
+	Gaps          []ShiftCoverageGap  `json:"gaps"`
+}
+
+// ListShifts returns the shifts of an agent or team, or every shift in the organization
+func (a *App) ListShifts(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	ownShifts := false
+	if v := string(r.RequestCtx.QueryArgs().Peek("user_id")); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid user ID", nil, "")
+		}
+		query = query.Where("user_id = ?", id)
+		ownShifts = id == userID
+	}
+	if v := string(r.RequestCtx.QueryArgs().Peek("team_id")); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid team ID", nil, "")
+		}
+		query = query.Where("team_id = ?", id)
+	}
+
+	if !ownShifts && !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var shifts []models.AgentShift
+	if err := query.Order("day_of_week ASC, start_time ASC").Find(&shifts).Error; err != nil {
+		a.Log.Error("Failed to list shifts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list shifts", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{"shifts": shifts})
+}
+
+// GetMyShifts returns the shifts that apply to the current user, their own and their teams'
+func (a *App) GetMyShifts(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	byUser, err := a.loadUserShifts(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load shifts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load shifts", nil, "")
+	}
+	shifts := byUser[userID]
+	if shifts == nil {
+		shifts = []models.AgentShift{}
+	}
+
+	loc := a.getOrgLocation(orgID)
+	return r.SendEnvelope(map[string]interface{}{
+		"shifts":   shifts,
+		"on_shift": len(shifts) > 0 && onShiftAt(shifts, time.Now().In(loc)),
+		"timezone": loc.String(),
+	})
+}
+
+// UpdateUserShifts replaces an agent's weekly schedule
+func (a *App) UpdateUserShifts(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	targetID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid user ID", nil, "")
+	}
+	var count int64
+	a.DB.Model(&models.User{}).Where("id = ? AND organization_id = ?", targetID, orgID).Count(&count)
+	if count == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "User not found", nil, "")
+	}
+
+	return a.replaceShifts(r, models.AgentShift{OrganizationID: orgID, UserID: &targetID})
+}
+
+// UpdateTeamShifts replaces the weekly schedule shared by a team's members. Team managers
+// may edit their own team's schedule.
+func (a *App) UpdateTeamShifts(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	teamID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid team ID", nil, "")
+	}
+	var team models.Team
+	if err := a.DB.Where("id = ? AND organization_id = ?", teamID, orgID).First(&team).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Team not found", nil, "")
+	}
+
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite) {
+		var managers int64
+		a.DB.Model(&models.TeamMember{}).
+			Where("team_id = ? AND user_id = ? AND role = ?", teamID, userID, models.TeamRoleManager).
+			Count(&managers)
+		if managers == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		}
+	}
+
+	return a.replaceShifts(r, models.AgentShift{OrganizationID: orgID, TeamID: &teamID})
+}
+
+// replaceShifts swaps the schedule of owner's user or team for the request's shifts and
+// applies the change to availability right away
+func (a *App) replaceShifts(r *fastglue.Request, owner models.AgentShift) error {
+	var req ShiftScheduleRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.Shifts) > maxShiftsPerSchedule {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("A schedule can have at most %d shifts", maxShiftsPerSchedule), nil, "")
+	}
+
+	shifts := make([]models.AgentShift, 0, len(req.Shifts))
+	for _, s := range req.Shifts {
+		if err := validateShift(s); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		shift := owner
+		shift.DayOfWeek = s.DayOfWeek
+		shift.StartTime = s.StartTime
+		shift.EndTime = s.EndTime
+		shifts = append(shifts, shift)
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		existing := tx.Where("organization_id = ?", owner.OrganizationID)
+		if owner.UserID != nil {
+			existing = existing.Where("user_id = ?", *owner.UserID)
+		} else {
+			existing = existing.Where("team_id = ?", *owner.TeamID)
+		}
+		if err := existing.Delete(&models.AgentShift{}).Error; err != nil {
+			return err
+		}
+		if len(shifts) == 0 {
+			return nil
+		}
+		return tx.Create(&shifts).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to save shifts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save shifts", nil, "")
+	}
+
+	a.applyShifts(owner.OrganizationID, time.Now())
+
+	return r.SendEnvelope(map[string]interface{}{"shifts": shifts})
+}
+
+// validateShift checks a shift's day and HH:MM times
+func validateShift(s ShiftRequest) error {
+	if s.DayOfWeek < 0 || s.DayOfWeek > 6 {
+		return fmt.Errorf("day_of_week must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	start, err := parseShiftClock(s.StartTime)
+	if err != nil {
+		return err
+	}
+	end, err := parseShiftClock(s.EndTime)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("start_time and end_time must differ")
+	}
+	return nil
+}
+
+// parseShiftClock returns the minutes past midnight of an HH:MM time
+func parseShiftClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// shiftCovers reports whether the shift covers the given minute of the given weekday
+func shiftCovers(s models.AgentShift, day, minute int) bool {
+	start, _ := parseShiftClock(s.StartTime)
+	end, _ := parseShiftClock(s.EndTime)
+	if start < end {
+		return s.DayOfWeek == day && minute >= start && minute < end
+	}
+	// Overnight: the evening of its day and the early hours of the next
+	return (s.DayOfWeek == day && minute >= start) || ((s.DayOfWeek+1)%7 == day && minute < end)
+}
+
+// onShiftAt reports whether the wall-clock time falls within any of the shifts
+func onShiftAt(shifts []models.AgentShift, now time.Time) bool {
+	day, minute := int(now.Weekday()), now.Hour()*60+now.Minute()
+	for _, s := range shifts {
+		if shiftCovers(s, day, minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadUserShifts returns the shifts that apply to each user of the organization: their
+// own plus those of every team they belong to. Users without shifts are absent.
+func (a *App) loadUserShifts(orgID uuid.UUID) (map[uuid.UUID][]models.AgentShift, error) {
+	var shifts []models.AgentShift
+	if err := a.DB.Where("organization_id = ?", orgID).Find(&shifts).Error; err != nil {
+		return nil, err
+	}
+
+	byTeam := make(map[uuid.UUID][]models.AgentShift)
+	byUser := make(map[uuid.UUID][]models.AgentShift)
+	for _, s := range shifts {
+		switch {
+		case s.UserID != nil:
+			byUser[*s.UserID] = append(byUser[*s.UserID], s)
+		case s.TeamID != nil:
+			byTeam[*s.TeamID] = append(byTeam[*s.TeamID], s)
+		}
+	}
+	if len(byTeam) == 0 {
+		return byUser, nil
+	}
+
+	teamIDs := make([]uuid.UUID, 0, len(byTeam))
+	for id := range byTeam {
+		teamIDs = append(teamIDs, id)
+	}
+	var members []models.TeamMember
+	if err := a.DB.Where("team_id IN ?", teamIDs).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		byUser[m.UserID] = append(byUser[m.UserID], byTeam[m.TeamID]...)
+	}
+	return byUser, nil
+}
+
+// applyShifts brings the organization's users in line with their shifts. Availability
+// only changes when a user crosses a shift boundary, so a manual toggle holds until the
+// next one; users whose schedule was removed are released from shift routing.
+func (a *App) applyShifts(orgID uuid.UUID, now time.Time) {
+	byUser, err := a.loadUserShifts(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load shifts", "error", err, "organization_id", orgID)
+		return
+	}
+
+	scheduled := make([]uuid.UUID, 0, len(byUser))
+	for id := range byUser {
+		scheduled = append(scheduled, id)
+	}
+	query := a.DB.Where("organization_id = ? AND is_active = ?", orgID, true)
+	if len(scheduled) > 0 {
+		query = query.Where("on_shift IS NOT NULL OR id IN ?", scheduled)
+	} else {
+		query = query.Where("on_shift IS NOT NULL")
+	}
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		a.Log.Error("Failed to load users for shifts", "error", err, "organization_id", orgID)
+		return
+	}
+
+	local := now.In(a.getOrgLocation(orgID))
+	for i := range users {
+		user := &users[i]
+		shifts, ok := byUser[user.ID]
+		if !ok {
+			if err := a.DB.Model(user).Update("on_shift", nil).Error; err != nil {
+				a.Log.Error("Failed to clear shift state", "error", err, "user_id", user.ID)
+			}
+			continue
+		}
+
+		on := onShiftAt(shifts, local)
+		if user.OnShift != nil && *user.OnShift == on {
+			continue
+		}
+		a.crossShiftBoundary(user, orgID, on)
+	}
+}
+
+// crossShiftBoundary records a user's new shift state. Ending a shift marks the user away,
+// returning their chats to the queue; starting one marks them available unless their
+// calendar has them in a meeting or they just got a schedule mid-shift.
+func (a *App) crossShiftBoundary(user *models.User, orgID uuid.UUID, on bool) {
+	wasScheduled := user.OnShift != nil
+	if err := a.DB.Model(user).Update("on_shift", on).Error; err != nil {
+		a.Log.Error("Failed to save shift state", "error", err, "user_id", user.ID)
+		return
+	}
+
+	switch {
+	case !on && user.IsAvailable:
+		returned, err := a.setUserAvailability(user, orgID, false)
+		if err != nil {
+			a.Log.Error("Failed to mark user away at shift end", "error", err, "user_id", user.ID)
+			return
+		}
+		a.Log.Info("Shift ended, user marked away", "user_id", user.ID, "transfers_to_queue", returned)
+	case on && wasScheduled && !user.IsAvailable:
+		var inMeeting int64
+		a.DB.Model(&models.CalendarConnection{}).Where("user_id = ? AND marked_away = ?", user.ID, true).Count(&inMeeting)
+		if inMeeting > 0 {
+			return
+		}
+		if _, err := a.setUserAvailability(user, orgID, true); err != nil {
+			a.Log.Error("Failed to mark user available at shift start", "error", err, "user_id", user.ID)
+			return
+		}
+		a.Log.Info("Shift started, user marked available", "user_id", user.ID)
+	}
+}
+
+// GetShiftCoverage returns how many agents are on shift in each half hour of the week
+// and where coverage falls below min_agents during business hours
+func (a *App) GetShiftCoverage(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	minAgents := 1
+	if v := string(r.RequestCtx.QueryArgs().Peek("min_agents")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "min_agents must be a positive number", nil, "")
+		}
+		minAgents = n
+	}
+
+	byUser, err := a.loadUserShifts(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load shifts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load coverage", nil, "")
+	}
+
+	// Coverage counts the agents who take chats: active users, limited to a team's agents
+	query := a.DB.Model(&models.User{}).Select("users.id, users.full_name").
+		Where("users.organization_id = ? AND users.is_active = ?", orgID, true)
+	if v := string(r.RequestCtx.QueryArgs().Peek("team_id")); v != "" {
+		teamID, err := uuid.Parse(v)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid team ID", nil, "")
+		}
+		query = query.Joins("JOIN team_members ON team_members.user_id = users.id").
+			Where("team_members.team_id = ? AND team_members.role = ?", teamID, models.TeamRoleAgent)
+	}
+	var agents []ShiftAgent
+	if err := query.Order("users.full_name ASC").Scan(&agents).Error; err != nil {
+		a.Log.Error("Failed to load agents for coverage", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load coverage", nil, "")
+	}
+	scheduled := agents[:0]
+	agentShifts := make(map[uuid.UUID][]models.AgentShift)
+	for _, ag := range agents {
+		if shifts, ok := byUser[ag.ID]; ok {
+			scheduled = append(scheduled, ag)
+			agentShifts[ag.ID] = shifts
+		}
+	}
+
+	// Org-wide business hours bound the gaps; without them the whole week must be covered
+	var businessHours models.JSONBArray
+	var settings models.ChatbotSettings
+	if err := a.DB.Where("organization_id = ? AND whats_app_account = ''", orgID).First(&settings).Error; err == nil &&
+		settings.BusinessHours.Enabled {
+		businessHours = settings.BusinessHours.Hours
+	}
+
+	order := make([]uuid.UUID, len(scheduled))
+	for i, ag := range scheduled {
+		order[i] = ag.ID
+	}
+	slots, gaps := shiftCoverage(order, agentShifts, businessHours, minAgents)
+
+	return r.SendEnvelope(ShiftCoverageResponse{
+		MinAgents:     minAgents,
+		BusinessHours: businessHours != nil,
+		Timezone:      a.getOrgLocation(orgID).String(),
+		Agents:        scheduled,
+		Slots:         slots,
+		Gaps:          gaps,
+	})
+}
+
+// shiftCoverage counts the agents on shift in each slot of the week, in agent order, and
+// merges consecutive understaffed required slots into gaps. A nil businessHours makes
+// every slot required.
+func shiftCoverage(agents []uuid.UUID, shifts map[uuid.UUID][]models.AgentShift, businessHours models.JSONBArray, minAgents int) ([]ShiftCoverageSlot, []ShiftCoverageGap) {
+	// Business hours are checked on a reference week starting on a Sunday, at the middle
+	// of each slot so their inclusive end time doesn't claim the following slot
+	week := time.Date(2024, time.January, 7, 0, 0, 0, 0, time.UTC)
+
+	slotsPerDay := 24 * 60 / shiftSlotMinutes
+	slots := make([]ShiftCoverageSlot, 0, 7*slotsPerDay)
+	var gaps []ShiftCoverageGap
+	var open *ShiftCoverageGap
+	for day := 0; day < 7; day++ {
+		for i := 0; i < slotsPerDay; i++ {
+			minute := i * shiftSlotMinutes
+			slot := ShiftCoverageSlot{DayOfWeek: day, StartTime: formatShiftClock(minute), Required: true}
+			if businessHours != nil {
+				mid := week.AddDate(0, 0, day).Add(time.Duration(minute+shiftSlotMinutes/2) * time.Minute)
+				slot.Required = isWithinBusinessHoursAt(businessHours, mid)
+			}
+			for _, id := range agents {
+				for _, s := range shifts[id] {
+					if shiftCovers(s, day, minute) {
+						slot.AgentIDs = append(slot.AgentIDs, id)
+						break
+					}
+				}
+			}
+			slot.Agents = len(slot.AgentIDs)
+			slots = append(slots, slot)
+
+			if !slot.Required || slot.Agents >= minAgents {
+				open = nil
+				continue
+			}
+			end := formatShiftClock((minute + shiftSlotMinutes) % (24 * 60))
+			if open != nil {
+				open.EndTime = end
+				open.Agents = min(open.Agents, slot.Agents)
+				continue
+			}
+			gaps = append(gaps, ShiftCoverageGap{DayOfWeek: day, StartTime: slot.StartTime, EndTime: end, Agents: slot.Agents})
+			open = &gaps[len(gaps)-1]
+		}
+		// Gaps are reported per day
+		open = nil
+	}
+	if gaps == nil {
+		gaps = []ShiftCoverageGap{}
+	}
+	return slots, gaps
+}
+
+// formatShiftClock formats minutes past midnight as HH:MM
+func formatShiftClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// ShiftAvailabilityProcessor toggles agent availability at shift boundaries
+type ShiftAvailabilityProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewShiftAvailabilityProcessor creates a new shift availability processor
+func NewShiftAvailabilityProcessor(app *App, interval time.Duration) *ShiftAvailabilityProcessor {
+	return &ShiftAvailabilityProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the shift loop
+func (p *ShiftAvailabilityProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Shift availability processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Shift availability processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Shift availability processor stopped")
+			return
+		case <-ticker.C:
+			p.applyAll(ctx)
+		}
+	}
+}
+
+// Stop stops the shift availability processor
+func (p *ShiftAvailabilityProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// applyAll applies shifts in every organization that has shifts or users still on a
+// shift schedule
+func (p *ShiftAvailabilityProcessor) applyAll(ctx context.Context) {
+	var orgIDs, tracked []uuid.UUID
+	if err := p.app.DB.Model(&models.AgentShift{}).Distinct().Pluck("organization_id", &orgIDs).Error; err != nil {
+		p.app.Log.Error("Failed to load organizations with shifts", "error", err)
+		return
+	}
+	if err := p.app.DB.Model(&models.User{}).Where("on_shift IS NOT NULL").Distinct().
+		Pluck("organization_id", &tracked).Error; err != nil {
+		p.app.Log.Error("Failed to load organizations with shift state", "error", err)
+		return
+	}
+	orgIDs = append(orgIDs, tracked...)
+	sort.Slice(orgIDs, func(i, j int) bool { return orgIDs[i].String() < orgIDs[j].String() })
+
+	now := time.Now()
+	for i, orgID := range orgIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if i > 0 && orgIDs[i-1] == orgID {
+			continue
+		}
+		p.app.applyShifts(orgID, now)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_ShiftsToggleAvailability(t *testing.T) {
+	app := testApp(t)
+	if app.Redis == nil {
+		t.Skip("shift times are read in the organization timezone, which needs Redis")
+	}
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	agent := createTransferTestUser(t, app, org.ID, &createTransferTestRole(t, app.DB, org.ID, "agent", nil).ID)
+	team := createTestTeam(t, app, org.ID, agent.ID)
+
+	// Organizations default to UTC
+	today := int(time.Now().UTC().Weekday())
+	later := (today + 3) % 7
+
+	schedule := func(userID uuid.UUID, path string, id uuid.UUID, shifts ...map[string]any) int {
+		req := testutil.NewJSONRequest(t, map[string]any{"shifts": shifts})
+		setTransferAuthContext(req, org.ID, userID)
+		testutil.SetPathParam(req, "id", id.String())
+		if path == "team" {
+			require.NoError(t, app.UpdateTeamShifts(req))
+		} else {
+			require.NoError(t, app.UpdateUserShifts(req))
+		}
+		return testutil.GetResponseStatusCode(req)
+	}
+	reload := func() models.User {
+		var u models.User
+		require.NoError(t, app.DB.First(&u, agent.ID).Error)
+		return u
+	}
+	shift := func(day int, start, end string) map[string]any {
+		return map[string]any{"day_of_week": day, "start_time": start, "end_time": end}
+	}
+
+	assert.Equal(t, fasthttp.StatusForbidden, schedule(agent.ID, "team", team.ID, shift(later, "09:00", "17:00")))
+	assert.Equal(t, fasthttp.StatusBadRequest, schedule(admin.ID, "team", team.ID, shift(later, "09:00", "09:00")))
+
+	// A team shift later in the week puts the agent off shift now
+	require.Equal(t, fasthttp.StatusOK, schedule(admin.ID, "team", team.ID, shift(later, "09:00", "17:00")))
+	u := reload()
+	require.NotNil(t, u.OnShift)
+	assert.False(t, *u.OnShift)
+	assert.False(t, u.IsAvailable)
+
+	// An own shift covering all of today starts it
+	require.Equal(t, fasthttp.StatusOK, schedule(admin.ID, "user", agent.ID,
+		shift(today, "00:00", "23:59"), shift(today, "23:59", "00:00")))
+	u = reload()
+	assert.True(t, *u.OnShift)
+	assert.True(t, u.IsAvailable)
+
+	// Coverage shows the agent today and a gap tomorrow
+	req := testutil.NewGETRequest(t)
+	setTransferAuthContext(req, org.ID, admin.ID)
+	testutil.SetQueryParam(req, "team_id", team.ID.String())
+	require.NoError(t, app.GetShiftCoverage(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var coverage struct {
+		Data handlers.ShiftCoverageResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &coverage)
+	require.Len(t, coverage.Data.Agents, 1)
+	for _, slot := range coverage.Data.Slots {
+		if slot.DayOfWeek == today {
+			assert.Equal(t, 1, slot.Agents, slot.StartTime)
+		}
+	}
+	assert.Contains(t, coverage.Data.Gaps, handlers.ShiftCoverageGap{DayOfWeek: (today + 1) % 7, StartTime: "00:00", EndTime: "00:00"})
+
+	// Removing the own shift ends it, and removing the team's releases the agent
+	require.Equal(t, fasthttp.StatusOK, schedule(admin.ID, "user", agent.ID))
+	u = reload()
+	assert.False(t, *u.OnShift)
+	assert.False(t, u.IsAvailable)
+
+	require.Equal(t, fasthttp.StatusOK, schedule(admin.ID, "team", team.ID))
+	assert.Nil(t, reload().OnShift)
+}
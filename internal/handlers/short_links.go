@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/shortlink"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// ShortLinkRequest represents a request to shorten a URL
+type ShortLinkRequest struct {
+	URL        string `json:"url"`
+	CampaignID string `json:"campaign_id"`
+}
+
+// ShortLinkResponse represents a short link in API responses
+type ShortLinkResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	Code          string     `json:"code"`
+	ShortURL      string     `json:"short_url"`
+	TargetURL     string     `json:"target_url"`
+	CampaignID    *uuid.UUID `json:"campaign_id,omitempty"`
+	RecipientID   *uuid.UUID `json:"recipient_id,omitempty"`
+	ContactID     *uuid.UUID `json:"contact_id,omitempty"`
+	ClickCount    int        `json:"click_count"`
+	LastClickedAt *string    `json:"last_clicked_at,omitempty"`
+	CreatedAt     string     `json:"created_at"`
+}
+
+// LinkTargetStats represents click stats for one destination URL in a campaign
+type LinkTargetStats struct {
+	TargetURL    string  `json:"target_url"`
+	Links        int64   `json:"links"`
+	ClickedLinks int64   `json:"clicked_links"`
+	TotalClicks  int64   `json:"total_clicks"`
+	CTR          float64 `json:"ctr"`
+}
+
+// RecipientLinkStats represents link clicks for a single campaign recipient
+type RecipientLinkStats struct {
+	RecipientID    uuid.UUID `json:"recipient_id"`
+	PhoneNumber    string    `json:"phone_number"`
+	RecipientName  string    `json:"recipient_name"`
+	Clicks         int64     `json:"clicks"`
+	FirstClickedAt string    `json:"first_clicked_at"`
+	LastClickedAt  string    `json:"last_clicked_at"`
+}
+
+// ShortLinkRedirect records a click and redirects to the link's target URL (public)
+func (a *App) ShortLinkRedirect(r *fastglue.Request) error {
+	code, _ := r.RequestCtx.UserValue("code").(string)
+
+	var link models.ShortLink
+	if err := a.DB.Where("code = ?", code).First(&link).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Link not found", nil, "")
+	}
+
+	now := time.Now()
+	click := models.LinkClick{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		ShortLinkID:    link.ID,
+		OrganizationID: link.OrganizationID,
+		CampaignID:     link.CampaignID,
+		RecipientID:    link.RecipientID,
+		UserAgent:      string(r.RequestCtx.UserAgent()),
+		IPAddress:      middleware.ClientIP(r),
+		ClickedAt:      now,
+	}
+	if err := a.DB.Create(&click).Error; err != nil {
+		a.Log.Error("Failed to record link click", "error", err, "code", code)
+	}
+
+	a.DB.Model(&link).Updates(map[string]interface{}{
+		"click_count":     gorm.Expr("click_count + 1"),
+		"last_clicked_at": now,
+	})
+
+	if link.MessageID != nil {
+		a.recordURLButtonClick(&link, now)
+	}
+
+	r.RequestCtx.Redirect(link.TargetURL, fasthttp.StatusFound)
+	return nil
+}
+
+// CreateShortLink shortens a URL for use in templates or messages
+func (a *App) CreateShortLink(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req ShortLinkRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "A valid http(s) URL is required", nil, "")
+	}
+
+	owner := shortlink.Owner{OrganizationID: orgID, CreatedBy: &userID}
+	if req.CampaignID != "" {
+		campaignID, err := uuid.Parse(req.CampaignID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+		}
+		var count int64
+		a.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND organization_id = ?", campaignID, orgID).Count(&count)
+		if count == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+		}
+		owner.CampaignID = &campaignID
+	}
+
+	link, err := shortlink.Create(a.DB, owner, req.URL)
+	if err != nil {
+		a.Log.Error("Failed to create short link", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create short link", nil, "")
+	}
+
+	return r.SendEnvelope(shortLinkToResponse(*link, a.publicBaseURL(r)))
+}
+
+// ListShortLinks returns short links for the organization, optionally filtered by campaign
+func (a *App) ListShortLinks(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Limit(500)
+	if campaignID := string(r.RequestCtx.QueryArgs().Peek("campaign_id")); campaignID != "" {
+		query = query.Where("campaign_id = ?", campaignID)
+	}
+
+	var links []models.ShortLink
+	if err := query.Find(&links).Error; err != nil {
+		a.Log.Error("Failed to list short links", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list short links", nil, "")
+	}
+
+	baseURL := a.publicBaseURL(r)
+	response := make([]ShortLinkResponse, len(links))
+	for i, l := range links {
+		response[i] = shortLinkToResponse(l, baseURL)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"links": response,
+		"total": len(response),
+	})
+}
+
+// GetCampaignLinkStats returns click-through rates for a campaign's short links
+func (a *App) GetCampaignLinkStats(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceCampaigns, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	campaignID, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaignID, orgID).First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+
+	// Per destination URL
+	var targets []LinkTargetStats
+	a.DB.Model(&models.ShortLink{}).
+		Select("target_url, COUNT(*) as links, COUNT(*) FILTER (WHERE click_count > 0) as clicked_links, COALESCE(SUM(click_count), 0) as total_clicks").
+		Where("campaign_id = ? AND organization_id = ?", campaignID, orgID).
+		Group("target_url").
+		Order("total_clicks DESC").
+		Scan(&targets)
+	for i := range targets {
+		targets[i].CTR = calculateRate(targets[i].ClickedLinks, targets[i].Links)
+	}
+
+	// Per recipient
+	type recipientClicks struct {
+		RecipientID    uuid.UUID
+		Clicks         int64
+		FirstClickedAt time.Time
+		LastClickedAt  time.Time
+	}
+	var clicks []recipientClicks
+	a.DB.Model(&models.LinkClick{}).
+		Select("recipient_id, COUNT(*) as clicks, MIN(clicked_at) as first_clicked_at, MAX(clicked_at) as last_clicked_at").
+		Where("campaign_id = ? AND organization_id = ? AND recipient_id IS NOT NULL", campaignID, orgID).
+		Group("recipient_id").
+		Order("clicks DESC").
+		Scan(&clicks)
+
+	recipientIDs := make([]uuid.UUID, len(clicks))
+	for i, c := range clicks {
+		recipientIDs[i] = c.RecipientID
+	}
+	recipientsByID := make(map[uuid.UUID]models.BulkMessageRecipient, len(clicks))
+	if len(recipientIDs) > 0 {
+		var recipients []models.BulkMessageRecipient
+		a.DB.Where("id IN ?", recipientIDs).Find(&recipients)
+		for _, rec := range recipients {
+			recipientsByID[rec.ID] = rec
+		}
+	}
+
+	var totalClicks int64
+	recipientStats := make([]RecipientLinkStats, len(clicks))
+	for i, c := range clicks {
+		rec := recipientsByID[c.RecipientID]
+		recipientStats[i] = RecipientLinkStats{
+			RecipientID:    c.RecipientID,
+			PhoneNumber:    rec.PhoneNumber,
+			RecipientName:  rec.RecipientName,
+			Clicks:         c.Clicks,
+			FirstClickedAt: c.FirstClickedAt.Format("2006-01-02T15:04:05Z"),
+			LastClickedAt:  c.LastClickedAt.Format("2006-01-02T15:04:05Z"),
+		}
+		totalClicks += c.Clicks
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"campaign_id":        campaign.ID,
+		"recipients_sent":    campaign.SentCount,
+		"recipients_clicked": len(clicks),
+		"total_clicks":       totalClicks,
+		"ctr":                calculateRate(int64(len(clicks)), int64(campaign.SentCount)),
+		"targets":            targets,
+		"recipients":         recipientStats,
+	})
+}
+
+// publicBaseURL returns the configured public URL, falling back to the request's host
+func (a *App) publicBaseURL(r *fastglue.Request) string {
+	if a.Config != nil && a.Config.Server.PublicURL != "" {
+		return a.Config.Server.PublicURL
+	}
+	return string(r.RequestCtx.URI().Scheme()) + "://" + string(r.RequestCtx.Host())
+}
+
+func shortLinkToResponse(l models.ShortLink, baseURL string) ShortLinkResponse {
+	resp := ShortLinkResponse{
+		ID:          l.ID,
+		Code:        l.Code,
+		ShortURL:    shortlink.URL(baseURL, l.Code),
+		TargetURL:   l.TargetURL,
+		CampaignID:  l.CampaignID,
+		RecipientID: l.RecipientID,
+		ContactID:   l.ContactID,
+		ClickCount:  l.ClickCount,
+		CreatedAt:   l.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if l.LastClickedAt != nil {
+		lastClicked := l.LastClickedAt.Format("2006-01-02T15:04:05Z")
+		resp.LastClickedAt = &lastClicked
+	}
+	return resp
+}
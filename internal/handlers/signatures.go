@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// maxSignatureLength caps signature templates on accounts, teams and users
+	maxSignatureLength = 200
+	// maxSenderNameLength caps an account's sender name
+	maxSenderNameLength = 100
+	// signatureOff is a user signature that turns signatures off for the user
+	signatureOff = "-"
+
+	signatureLengthError = "Signature must be 200 characters or fewer"
+)
+
+// Where a message's signature came from
+const (
+	signatureSourceUser    = "user"
+	signatureSourceTeam    = "team"
+	signatureSourceAccount = "account"
+)
+
+// agentSignature is the signature that applies to a user's messages from an account
+type agentSignature struct {
+	Template string
+	Position models.SignaturePosition
+	Source   string // user, team or account; empty when no signature applies
+	vars     signatureVars
+}
+
+// signatureVars are the values substituted into a signature template
+type signatureVars struct {
+	Name     string // {name}: the agent's full name
+	Team     string // {team}: the agent's team
+	Business string // {business}: the account's sender name
+}
+
+// Text renders the signature, or returns "" when no signature applies
+func (s *agentSignature) Text() string {
+	if s == nil || s.Template == "" {
+		return ""
+	}
+	return renderSignature(s.Template, s.vars)
+}
+
+// replace fills in the {name}, {first_name}, {team} and {business} placeholders
+func (v signatureVars) replace(template string) string {
+	firstName, _, _ := strings.Cut(v.Name, " ")
+	return strings.NewReplacer(
+		"{name}", v.Name,
+		"{first_name}", firstName,
+		"{team}", v.Team,
+		"{business}", v.Business,
+	).Replace(template)
+}
+
+// renderSignature fills in a signature template. Separators left dangling by an empty
+// placeholder, as in "– Priya, " for a user without a team, are trimmed.
+func renderSignature(template string, vars signatureVars) string {
+	return strings.TrimRight(strings.TrimSpace(vars.replace(template)), " ,|-–—")
+}
+
+// signText adds a rendered signature to message text on its own line
+func signText(text, signature string, position models.SignaturePosition) string {
+	if signature == "" || text == "" {
+		return text
+	}
+	if position == models.SignaturePositionPrepend {
+		return signature + "\n" + text
+	}
+	return text + "\n" + signature
+}
+
+// resolveAgentSignature works out the signature for a user's messages from an account: the
+// user's own signature, else the first of their active teams (by name) that has one, else
+// the account's. A user signature of "-" turns signatures off for the user.
+func (a *App) resolveAgentSignature(account *models.WhatsAppAccount, userID uuid.UUID) *agentSignature {
+	sig := &agentSignature{
+		Position: account.SignaturePosition,
+		vars:     signatureVars{Business: account.SenderName},
+	}
+	if sig.Position == "" {
+		sig.Position = models.SignaturePositionAppend
+	}
+
+	var user models.User
+	if err := a.DB.Select("id, full_name, signature").Where("id = ?", userID).First(&user).Error; err != nil {
+		return sig
+	}
+	sig.vars.Name = user.FullName
+	if sig.vars.Name == "" {
+		sig.vars.Name = account.SenderName
+	}
+
+	var teams []models.Team
+	a.DB.Select("teams.name, teams.signature").
+		Joins("JOIN team_members ON team_members.team_id = teams.id AND team_members.deleted_at IS NULL").
+		Where("team_members.user_id = ? AND teams.organization_id = ? AND teams.is_active = ?", userID, account.OrganizationID, true).
+		Order("teams.name ASC").
+		Find(&teams)
+	for _, team := range teams {
+		if sig.vars.Team == "" {
+			sig.vars.Team = team.Name
+		}
+		if team.Signature != "" {
+			sig.vars.Team = team.Name
+			sig.Template, sig.Source = team.Signature, signatureSourceTeam
+			break
+		}
+	}
+
+	switch {
+	case user.Signature == signatureOff:
+		sig.Template, sig.Source = "", ""
+	case user.Signature != "":
+		sig.Template, sig.Source = user.Signature, signatureSourceUser
+	case sig.Template == "" && account.Signature != "":
+		sig.Template, sig.Source = account.Signature, signatureSourceAccount
+	}
+	return sig
+}
+
+// applyAgentSignature signs the text of a message an agent sends. Templates, flows and
+// audio carry no free text and are sent as they are.
+func (a *App) applyAgentSignature(req *OutgoingMessageRequest, opts MessageSendOptions) {
+	if opts.SentByUserID == nil || opts.SkipSignature {
+		return
+	}
+	content := outgoingPluginContent(req)
+	if content == nil || *content == "" {
+		return
+	}
+	sig := a.resolveAgentSignature(req.Account, *opts.SentByUserID)
+	*content = signText(*content, sig.Text(), sig.Position)
+}
+
+// validateSignature checks a signature template's length
+func validateSignature(signature string) string {
+	if utf8.RuneCountInString(signature) > maxSignatureLength {
+		return signatureLengthError
+	}
+	return ""
+}
+
+// MySignatureRequest sets the current user's own signature
+type MySignatureRequest struct {
+	Signature string `json:"signature"` // Empty uses the team or account signature; "-" turns signatures off
+}
+
+// UpdateMySignature sets the signature added to the current user's outbound messages
+func (a *App) UpdateMySignature(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req MySignatureRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Signature = strings.TrimSpace(req.Signature)
+	if msg := validateSignature(req.Signature); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if err := a.DB.Model(&models.User{}).Where("id = ?", userID).Update("signature", req.Signature).Error; err != nil {
+		a.Log.Error("Failed to update signature", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update signature", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":   "Signature updated successfully",
+		"signature": req.Signature,
+	})
+}
+
+// SignaturePreviewRequest asks how the current user's messages from an account are signed
+type SignaturePreviewRequest struct {
+	WhatsAppAccount string  `json:"whatsapp_account"`
+	Content         string  `json:"content"`   // Sample message text
+	Signature       *string `json:"signature"` // Preview this template instead of the saved one
+}
+
+// PreviewSignature shows the signature the current user's messages from an account get,
+// optionally with an unsaved signature template
+func (a *App) PreviewSignature(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req SignaturePreviewRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if req.WhatsAppAccount != "" {
+		query = query.Where("name = ?", req.WhatsAppAccount)
+	} else {
+		query = query.Order("is_default_outgoing DESC, created_at ASC")
+	}
+	var account models.WhatsAppAccount
+	if err := query.First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "WhatsApp account not found", nil, "")
+	}
+
+	sig := a.resolveAgentSignature(&account, userID)
+	if req.Signature != nil {
+		if msg := validateSignature(*req.Signature); msg != "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+		}
+		sig.Template, sig.Source = strings.TrimSpace(*req.Signature), signatureSourceUser
+		if sig.Template == signatureOff {
+			sig.Template, sig.Source = "", ""
+		}
+	}
+	if req.Content == "" {
+		req.Content = "Hi! Your order has shipped."
+	}
+
+	text := sig.Text()
+	return r.SendEnvelope(map[string]interface{}{
+		"whatsapp_account": account.Name,
+		"signature":        text,
+		"position":         sig.Position,
+		"source":           sig.Source,
+		"message":          signText(req.Content, text, sig.Position),
+	})
+}
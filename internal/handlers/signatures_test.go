@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderSignature(t *testing.T) {
+	vars := signatureVars{Name: "Priya Shah", Team: "Support", Business: "Acme"}
+
+	tests := []struct {
+		template string
+		vars     signatureVars
+		want     string
+	}{
+		{"– {name}, {team}", vars, "– Priya Shah, Support"},
+		{"{first_name} from {business}", vars, "Priya from Acme"},
+		{"– {name}, {team}", signatureVars{Name: "Priya"}, "– Priya"},
+		{"*{first_name}:*", vars, "*Priya:*"},
+		{"Thanks!", vars, "Thanks!"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, renderSignature(tt.template, tt.vars), tt.template)
+	}
+}
+
+func TestSignText(t *testing.T) {
+	assert.Equal(t, "Hi\n– Priya", signText("Hi", "– Priya", models.SignaturePositionAppend))
+	assert.Equal(t, "*Priya:*\nHi", signText("Hi", "*Priya:*", models.SignaturePositionPrepend))
+	assert.Equal(t, "Hi", signText("Hi", "", models.SignaturePositionAppend))
+	assert.Equal(t, "", signText("", "– Priya", models.SignaturePositionAppend))
+}
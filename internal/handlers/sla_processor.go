@@ -5,8 +5,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/chatnotify"
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/mailer"
+	"gorm.io/gorm/clause"
 )
 
 // SLAProcessor handles periodic SLA checks and escalations
@@ -55,6 +58,9 @@ func (p *SLAProcessor) Stop() {
 func (p *SLAProcessor) processStaleTransfers() {
 	now := time.Now()
 
+	// Flow inactivity nudges and abandonment don't depend on SLA settings
+	p.processFlowInactivity(now)
+
 	// Get all organizations with SLA enabled (use cache)
 	settings, err := p.app.getSLAEnabledSettingsCached()
 	if err != nil {
@@ -118,6 +124,7 @@ func (p *SLAProcessor) autoCloseExpiredTransfers(orgID uuid.UUID, settings model
 			p.app.Log.Error("Failed to expire transfer", "error", err, "transfer_id", transfer.ID)
 			continue
 		}
+		p.app.resetConversationPriority(transfer.ContactID)
 
 		p.app.Log.Info("Transfer auto-closed due to expiry",
 			"transfer_id", transfer.ID,
@@ -164,6 +171,9 @@ func (p *SLAProcessor) escalateTransfers(orgID uuid.UUID, settings models.Chatbo
 			p.app.Log.Error("Failed to escalate transfer", "error", err, "transfer_id", transfer.ID)
 			continue
 		}
+		if _, breached := updates["sla_breached"]; breached {
+			p.app.notifySLABreached(&transfer)
+		}
 
 		p.app.Log.Warn("Transfer escalated",
 			"transfer_id", transfer.ID,
@@ -191,7 +201,9 @@ func (p *SLAProcessor) escalateTransfers(orgID uuid.UUID, settings models.Chatbo
 
 // markSLABreached marks transfers as SLA breached when past response deadline
 func (p *SLAProcessor) markSLABreached(orgID uuid.UUID, settings models.ChatbotSettings, now time.Time) {
-	result := p.app.DB.Model(&models.AgentTransfer{}).Where(
+	// RETURNING gives us exactly the transfers this update marked, for notifications
+	var transfers []models.AgentTransfer
+	result := p.app.DB.Model(&transfers).Clauses(clause.Returning{}).Where(
 		"organization_id = ? AND status = ? AND sla_breached = ? AND sla_response_deadline IS NOT NULL AND sla_response_deadline < ? AND agent_id IS NULL",
 		orgID, models.TransferStatusActive, false, now,
 	).Updates(map[string]interface{}{
@@ -207,6 +219,10 @@ func (p *SLAProcessor) markSLABreached(orgID uuid.UUID, settings models.ChatbotS
 	if result.RowsAffected > 0 {
 		p.app.Log.Warn("Marked transfers as SLA breached", "count", result.RowsAffected, "org_id", orgID)
 	}
+
+	for i := range transfers {
+		p.app.notifySLABreached(&transfers[i])
+	}
 }
 
 // notifyEscalation sends notifications to escalation contacts via WebSocket broadcast
@@ -245,6 +261,8 @@ func (p *SLAProcessor) notifyEscalation(transfer models.AgentTransfer, settings
 		},
 	})
 
+	p.emailEscalation(transfer, contact, settings.SLA.EscalationNotifyIDs, levelName)
+
 	p.app.Log.Info("Escalation notification sent",
 		"transfer_id", transfer.ID,
 		"level", level,
@@ -252,6 +270,37 @@ func (p *SLAProcessor) notifyEscalation(transfer models.AgentTransfer, settings
 	)
 }
 
+// emailEscalation emails escalation contacts when the organization has a mail server
+func (p *SLAProcessor) emailEscalation(transfer models.AgentTransfer, contact models.Contact, userIDs []string, levelName string) {
+	var emails []string
+	if err := p.app.DB.Model(&models.User{}).
+		Where("id IN ? AND organization_id = ? AND is_active = ?", userIDs, transfer.OrganizationID, true).
+		Pluck("email", &emails).Error; err != nil {
+		p.app.Log.Error("Failed to load escalation contacts", "error", err)
+		return
+	}
+	if len(emails) == 0 {
+		return
+	}
+
+	fields := p.app.transferNotificationFields(&transfer, &contact)
+	fields = append(fields, chatnotify.Field{
+		Name:  "Waiting for",
+		Value: time.Since(transfer.TransferredAt).Round(time.Minute).String(),
+	})
+	mailFields := make([]mailer.Field, len(fields))
+	for i, f := range fields {
+		mailFields[i] = mailer.Field{Name: f.Name, Value: f.Value}
+	}
+
+	p.app.sendOrgEmailAsync(transfer.OrganizationID, emails, mailer.TemplateAlert, map[string]interface{}{
+		"Title":  "Transfer escalated (" + levelName + ")",
+		"Text":   "A queued conversation has been waiting longer than its SLA allows.",
+		"Fields": mailFields,
+		"URL":    p.app.appLink("/chatbot/transfers"),
+	})
+}
+
 // sendSLAWarningToCustomer sends a warning message to the customer
 func (p *SLAProcessor) sendSLAWarningToCustomer(transfer models.AgentTransfer, message string) {
 	// Get WhatsApp account
@@ -542,6 +591,9 @@ func (a *App) UpdateContactChatbotMessage(contactID uuid.UUID) {
 
 // ClearContactChatbotTracking clears chatbot tracking when client replies or is transferred
 func (a *App) ClearContactChatbotTracking(contactID uuid.UUID) {
+	if a.simulation(contactID) != nil {
+		return
+	}
 	a.DB.Model(&models.Contact{}).
 		Where("id = ?", contactID).
 		Updates(map[string]interface{}{
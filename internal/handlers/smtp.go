@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/mailer"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// errSMTPNotConfigured is returned when the organization has no enabled mail server
+var errSMTPNotConfigured = errors.New("email is not configured for this organization")
+
+// SMTPSettingsRequest sets the organization's mail server. An empty password keeps the stored one.
+type SMTPSettingsRequest struct {
+	Host               string `json:"host" validate:"required"`
+	Port               int    `json:"port" validate:"required"`
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	FromEmail          string `json:"from_email" validate:"required,email"`
+	FromName           string `json:"from_name"`
+	TLSMode            string `json:"tls_mode"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	IsEnabled          bool   `json:"is_enabled"`
+}
+
+// SMTPSettingsResponse describes the mail server (password masked)
+type SMTPSettingsResponse struct {
+	Configured         bool   `json:"configured"`
+	Host               string `json:"host"`
+	Port               int    `json:"port"`
+	Username           string `json:"username"`
+	HasPassword        bool   `json:"has_password"`
+	FromEmail          string `json:"from_email"`
+	FromName           string `json:"from_name"`
+	TLSMode            string `json:"tls_mode"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	IsEnabled          bool   `json:"is_enabled"`
+}
+
+// TestSMTPRequest optionally overrides the test recipient
+type TestSMTPRequest struct {
+	To string `json:"to"`
+}
+
+// GetSMTPSettings returns the organization's mail server settings
+func (a *App) GetSMTPSettings(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var settings models.SMTPSettings
+	if err := a.DB.Where("organization_id = ?", orgID).First(&settings).Error; err != nil {
+		return r.SendEnvelope(SMTPSettingsResponse{Port: 587, TLSMode: string(mailer.TLSStartTLS)})
+	}
+
+	return r.SendEnvelope(smtpSettingsToResponse(settings))
+}
+
+// UpdateSMTPSettings saves the organization's mail server settings
+func (a *App) UpdateSMTPSettings(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req SMTPSettingsRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	var settings models.SMTPSettings
+	if err := a.DB.Where("organization_id = ?", orgID).First(&settings).Error; err != nil {
+		settings = models.SMTPSettings{OrganizationID: orgID}
+	}
+
+	// A stored password belongs to the stored account
+	if req.Username != settings.Username {
+		settings.Password = ""
+	}
+	settings.Host = req.Host
+	settings.Port = req.Port
+	settings.Username = req.Username
+	if req.Password != "" {
+		settings.Password = req.Password
+	}
+	settings.FromEmail = req.FromEmail
+	settings.FromName = req.FromName
+	settings.TLSMode = req.TLSMode
+	if settings.TLSMode == "" {
+		settings.TLSMode = string(mailer.TLSStartTLS)
+	}
+	settings.InsecureSkipVerify = req.InsecureSkipVerify
+	settings.IsEnabled = req.IsEnabled
+
+	if _, err := mailer.New(smtpMailerConfig(settings)); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Save(&settings).Error; err != nil {
+		a.Log.Error("Failed to save SMTP settings", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save SMTP settings", nil, "")
+	}
+
+	return r.SendEnvelope(smtpSettingsToResponse(settings))
+}
+
+// TestSMTPSettings sends a test email using the saved settings
+func (a *App) TestSMTPSettings(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsGeneral, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req TestSMTPRequest
+	_ = r.Decode(&req, "json")
+	if req.To == "" {
+		var user models.User
+		if err := a.DB.Select("email").Where("id = ?", userID).First(&user).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Recipient is required", nil, "")
+		}
+		req.To = user.Email
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailer.DefaultTimeout)
+	defer cancel()
+	if err := a.SendOrgEmail(ctx, orgID, []string{req.To}, mailer.TemplateTest, nil); err != nil {
+		if errors.Is(err, errSMTPNotConfigured) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Test email failed: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Test email sent to " + req.To})
+}
+
+// SendOrgEmail renders a built-in template and sends it through the organization's mail
+// server. OrgName is added to data when missing.
+func (a *App) SendOrgEmail(ctx context.Context, orgID uuid.UUID, to []string, template string, data map[string]interface{}) error {
+	var settings models.SMTPSettings
+	if err := a.DB.Where("organization_id = ? AND is_enabled = ?", orgID, true).First(&settings).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errSMTPNotConfigured
+		}
+		return err
+	}
+	m, err := mailer.New(smtpMailerConfig(settings))
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if _, ok := data["OrgName"]; !ok {
+		var org models.Organization
+		if err := a.DB.Select("name").Where("id = ?", orgID).First(&org).Error; err == nil {
+			data["OrgName"] = org.Name
+		} else {
+			data["OrgName"] = ""
+		}
+	}
+
+	msg, err := mailer.Render(template, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return m.Send(ctx, msg)
+}
+
+// sendOrgEmailAsync sends an email in the background. Organizations without a mail
+// server are skipped silently.
+func (a *App) sendOrgEmailAsync(orgID uuid.UUID, to []string, template string, data map[string]interface{}) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := a.SendOrgEmail(ctx, orgID, to, template, data)
+		switch {
+		case errors.Is(err, errSMTPNotConfigured):
+			a.Log.Debug("Skipping email, SMTP not configured", "org_id", orgID, "template", template)
+		case err != nil:
+			a.Log.Error("Failed to send email", "error", err, "org_id", orgID, "template", template)
+		}
+	}()
+}
+
+// frontendURL builds an absolute link into the frontend, falling back to the request host
+// when no public URL is configured
+func (a *App) frontendURL(r *fastglue.Request, path string) string {
+	if link := a.appLink(path); link != "" {
+		return link
+	}
+	return a.oauthCallbackURL(r, path)
+}
+
+func smtpMailerConfig(s models.SMTPSettings) mailer.Config {
+	return mailer.Config{
+		Host:               s.Host,
+		Port:               s.Port,
+		Username:           s.Username,
+		Password:           s.Password,
+		FromEmail:          s.FromEmail,
+		FromName:           s.FromName,
+		TLS:                mailer.TLSMode(s.TLSMode),
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+}
+
+func smtpSettingsToResponse(s models.SMTPSettings) SMTPSettingsResponse {
+	return SMTPSettingsResponse{
+		Configured:         true,
+		Host:               s.Host,
+		Port:               s.Port,
+		Username:           s.Username,
+		HasPassword:        s.Password != "",
+		FromEmail:          s.FromEmail,
+		FromName:           s.FromName,
+		TLSMode:            s.TLSMode,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+		IsEnabled:          s.IsEnabled,
+	}
+}
@@ -327,6 +327,11 @@ func (a *App) CallbackSSO(r *fastglue.Request) error {
 		}
 	}
 
+	if a.isSuspendedUser(&user) {
+		a.redirectWithError(r, "Your organization has been suspended")
+		return nil
+	}
+
 	// Generate JWT tokens
 	accessToken, err := a.generateAccessToken(&user)
 	if err != nil {
@@ -510,7 +515,17 @@ func (a *App) buildOAuthConfig(provider string, ssoConfig *models.SSOProvider, r
 		scopes = providerCfg.Scopes
 	}
 
-	// Build callback URL from request
+	return &oauth2.Config{
+		ClientID:     ssoConfig.ClientID,
+		ClientSecret: ssoConfig.ClientSecret,
+		Endpoint:     endpoint,
+		Scopes:       scopes,
+		RedirectURL:  a.oauthCallbackURL(r, fmt.Sprintf("/api/auth/sso/%s/callback", provider)),
+	}
+}
+
+// oauthCallbackURL builds an absolute OAuth redirect URL for path from the request
+func (a *App) oauthCallbackURL(r *fastglue.Request, path string) string {
 	scheme := "https"
 	if !r.RequestCtx.IsTLS() && a.Config.App.Environment == "development" {
 		scheme = "http"
@@ -520,15 +535,7 @@ func (a *App) buildOAuthConfig(provider string, ssoConfig *models.SSOProvider, r
 	if basePath != "" && basePath[0] != '/' {
 		basePath = "/" + basePath
 	}
-	callbackURL := fmt.Sprintf("%s://%s%s/api/auth/sso/%s/callback", scheme, host, basePath, provider)
-
-	return &oauth2.Config{
-		ClientID:     ssoConfig.ClientID,
-		ClientSecret: ssoConfig.ClientSecret,
-		Endpoint:     endpoint,
-		Scopes:       scopes,
-		RedirectURL:  callbackURL,
-	}
+	return fmt.Sprintf("%s://%s%s%s", scheme, host, basePath, path)
 }
 
 // UserInfo represents normalized user info from OAuth providers
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +16,9 @@ type TeamRequest struct {
 	Description        string                   `json:"description"`
 	AssignmentStrategy models.AssignmentStrategy `json:"assignment_strategy"` // round_robin, load_balanced, manual
 	IsActive           bool                     `json:"is_active"`
+	RequiresApproval   bool                     `json:"requires_approval"` // Hold agents' outbound messages for review
+	Signature          string                   `json:"signature"`         // Replaces the account signature for the team's agents
+	AwayMessage        string                   `json:"away_message"`      // Sent for the team's agents while they are away
 }
 
 // TeamMemberRequest represents add member request
@@ -30,6 +34,9 @@ type TeamResponse struct {
 	Description        string                    `json:"description"`
 	AssignmentStrategy models.AssignmentStrategy `json:"assignment_strategy"`
 	IsActive           bool                      `json:"is_active"`
+	RequiresApproval   bool                      `json:"requires_approval"`
+	Signature          string                    `json:"signature"`
+	AwayMessage        string                    `json:"away_message"`
 	MemberCount        int                       `json:"member_count"`
 	Members            []TeamMemberResponse      `json:"members,omitempty"`
 	CreatedAt          time.Time                 `json:"created_at"`
@@ -55,7 +62,7 @@ func (a *App) ListTeams(r *fastglue.Request) error {
 	var teams []models.Team
 
 	// Users with teams:read permission can see all teams, others see only their teams
-	if a.HasPermission(userID, models.ResourceTeams, models.ActionRead) {
+	if a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionRead) {
 		if err := a.ScopeToOrg(a.DB, userID, orgID).
 			Preload("Members").Preload("Members.User").
 			Order("name ASC").Find(&teams).Error; err != nil {
@@ -99,7 +106,7 @@ func (a *App) GetTeam(r *fastglue.Request) error {
 	}
 
 	// Check access: users with teams:read permission can see all teams, otherwise must be a member
-	if !a.HasPermission(userID, models.ResourceTeams, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionRead) {
 		hasAccess := false
 		for _, m := range team.Members {
 			if m.UserID == userID {
@@ -120,7 +127,7 @@ func (a *App) CreateTeam(r *fastglue.Request) error {
 	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
 	userID := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 
-	if !a.HasPermission(userID, models.ResourceTeams, models.ActionWrite) {
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -132,6 +139,14 @@ func (a *App) CreateTeam(r *fastglue.Request) error {
 	if req.Name == "" {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Team name is required", nil, "")
 	}
+	req.Signature = strings.TrimSpace(req.Signature)
+	if msg := validateSignature(req.Signature); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	req.AwayMessage = strings.TrimSpace(req.AwayMessage)
+	if msg := validateAwayMessage(req.AwayMessage); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
 
 	// Validate assignment strategy
 	strategy := req.AssignmentStrategy
@@ -148,6 +163,9 @@ func (a *App) CreateTeam(r *fastglue.Request) error {
 		Description:        req.Description,
 		AssignmentStrategy: strategy,
 		IsActive:           true,
+		RequiresApproval:   req.RequiresApproval,
+		Signature:          req.Signature,
+		AwayMessage:        req.AwayMessage,
 	}
 
 	if err := a.DB.Create(&team).Error; err != nil {
@@ -176,7 +194,7 @@ func (a *App) UpdateTeam(r *fastglue.Request) error {
 	}
 
 	// Check access: users with teams:write permission OR team managers can update
-	if !a.HasPermission(userID, models.ResourceTeams, models.ActionWrite) {
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite) {
 		isManager := false
 		for _, m := range team.Members {
 			if m.UserID == userID && m.Role == models.TeamRoleManager {
@@ -194,12 +212,24 @@ func (a *App) UpdateTeam(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
+	req.Signature = strings.TrimSpace(req.Signature)
+	if msg := validateSignature(req.Signature); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	req.AwayMessage = strings.TrimSpace(req.AwayMessage)
+	if msg := validateAwayMessage(req.AwayMessage); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
 	// Update fields
 	if req.Name != "" {
 		team.Name = req.Name
 	}
 	team.Description = req.Description
 	team.IsActive = req.IsActive
+	team.RequiresApproval = req.RequiresApproval
+	team.Signature = req.Signature
+	team.AwayMessage = req.AwayMessage
 
 	if req.AssignmentStrategy != "" {
 		if req.AssignmentStrategy != models.AssignmentStrategyRoundRobin && req.AssignmentStrategy != models.AssignmentStrategyLoadBalanced && req.AssignmentStrategy != models.AssignmentStrategyManual {
@@ -222,7 +252,7 @@ func (a *App) DeleteTeam(r *fastglue.Request) error {
 	userID := r.RequestCtx.UserValue("user_id").(uuid.UUID)
 	teamIDStr := r.RequestCtx.UserValue("id").(string)
 
-	if !a.HasPermission(userID, models.ResourceTeams, models.ActionDelete) {
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionDelete) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -247,6 +277,7 @@ func (a *App) DeleteTeam(r *fastglue.Request) error {
 	if result.RowsAffected == 0 {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Team not found", nil, "")
 	}
+	a.DB.Where("team_id = ?", teamID).Delete(&models.AgentShift{})
 
 	return r.SendEnvelope(map[string]string{"message": "Team deleted"})
 }
@@ -271,7 +302,7 @@ func (a *App) ListTeamMembers(r *fastglue.Request) error {
 	}
 
 	// Check access: users with teams:read permission can see all, otherwise must be a member
-	if !a.HasPermission(userID, models.ResourceTeams, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionRead) {
 		hasAccess := false
 		for _, m := range team.Members {
 			if m.UserID == userID {
@@ -318,7 +349,7 @@ func (a *App) AddTeamMember(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Team not found", nil, "")
 	}
 
-	hasWritePermission := a.HasPermission(userID, models.ResourceTeams, models.ActionWrite)
+	hasWritePermission := a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite)
 
 	// Check access: users with teams:write permission OR team managers can add members
 	if !hasWritePermission {
@@ -415,7 +446,7 @@ func (a *App) RemoveTeamMember(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Team not found", nil, "")
 	}
 
-	hasWritePermission := a.HasPermission(userID, models.ResourceTeams, models.ActionWrite)
+	hasWritePermission := a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite)
 
 	// Check access: users with teams:write permission OR team managers can remove members
 	if !hasWritePermission {
@@ -459,6 +490,9 @@ func buildTeamResponse(team *models.Team, includeMembers bool) TeamResponse {
 		Description:        team.Description,
 		AssignmentStrategy: team.AssignmentStrategy,
 		IsActive:           team.IsActive,
+		RequiresApproval:   team.RequiresApproval,
+		Signature:          team.Signature,
+		AwayMessage:        team.AwayMessage,
 		MemberCount:        len(team.Members),
 		CreatedAt:          team.CreatedAt,
 		UpdatedAt:          team.UpdatedAt,
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// defaultUnusedTemplateDays is how long a template can go unsent before it's suggested for cleanup
+const defaultUnusedTemplateDays = 90
+
+// Cleanup suggestion reasons
+const (
+	TemplateCleanupRejected  = "rejected"
+	TemplateCleanupNeverUsed = "never_used"
+	TemplateCleanupStale     = "stale"
+)
+
+// TemplateCleanupSuggestion is a template that is a candidate for archiving
+type TemplateCleanupSuggestion struct {
+	TemplateResponse
+	Reason string `json:"reason"`
+}
+
+// ArchiveTemplatesRequest represents the request body for bulk archiving templates
+type ArchiveTemplatesRequest struct {
+	TemplateIDs []string `json:"template_ids"`
+}
+
+// recordTemplateUsage bumps the template's usage counter and last-used time
+func (a *App) recordTemplateUsage(templateID uuid.UUID) {
+	if err := a.DB.Model(&models.Template{}).
+		Where("id = ?", templateID).
+		Updates(map[string]interface{}{
+			"usage_count":  gorm.Expr("usage_count + 1"),
+			"last_used_at": time.Now(),
+		}).Error; err != nil {
+		a.Log.Error("Failed to record template usage", "error", err, "template_id", templateID)
+	}
+}
+
+// GetTemplateCleanupSuggestions returns rejected templates and templates that haven't
+// been sent within the unused window (?days=, default 90)
+func (a *App) GetTemplateCleanupSuggestions(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	days := defaultUnusedTemplateDays
+	if v := string(r.RequestCtx.QueryArgs().Peek("days")); v != "" {
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "days must be a positive number", nil, "")
+		}
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	var templates []models.Template
+	if err := a.DB.Where("organization_id = ? AND archived_at IS NULL", orgID).
+		Where("status = ? OR (last_used_at IS NULL AND created_at < ?) OR last_used_at < ?", models.TemplateStatusRejected, cutoff, cutoff).
+		Order("last_used_at ASC NULLS FIRST, created_at ASC").
+		Find(&templates).Error; err != nil {
+		a.Log.Error("Failed to list cleanup suggestions", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list cleanup suggestions", nil, "")
+	}
+
+	// Templates still attached to a campaign that hasn't finished must stay usable
+	var inUse []uuid.UUID
+	a.DB.Model(&models.BulkMessageCampaign{}).
+		Where("organization_id = ? AND status IN ?", orgID, []models.CampaignStatus{
+			models.CampaignStatusDraft, models.CampaignStatusScheduled,
+			models.CampaignStatusQueued, models.CampaignStatusProcessing, models.CampaignStatusPaused,
+		}).
+		Distinct().Pluck("template_id", &inUse)
+	active := make(map[uuid.UUID]bool, len(inUse))
+	for _, id := range inUse {
+		active[id] = true
+	}
+
+	suggestions := make([]TemplateCleanupSuggestion, 0, len(templates))
+	for _, t := range templates {
+		if active[t.ID] {
+			continue
+		}
+		reason := TemplateCleanupStale
+		switch {
+		case t.Status == string(models.TemplateStatusRejected):
+			reason = TemplateCleanupRejected
+		case t.LastUsedAt == nil:
+			reason = TemplateCleanupNeverUsed
+		}
+		suggestions = append(suggestions, TemplateCleanupSuggestion{
+			TemplateResponse: templateToResponse(t),
+			Reason:           reason,
+		})
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"suggestions": suggestions,
+		"days":        days,
+	})
+}
+
+// ArchiveTemplates archives templates in bulk. Archived templates are hidden from the
+// library and can't be used for new sends or campaigns; they stay on Meta.
+func (a *App) ArchiveTemplates(r *fastglue.Request) error {
+	return a.setTemplatesArchived(r, true)
+}
+
+// UnarchiveTemplates restores archived templates to the library
+func (a *App) UnarchiveTemplates(r *fastglue.Request) error {
+	return a.setTemplatesArchived(r, false)
+}
+
+func (a *App) setTemplatesArchived(r *fastglue.Request, archive bool) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req ArchiveTemplatesRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.TemplateIDs) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "template_ids is required", nil, "")
+	}
+
+	ids := make([]uuid.UUID, len(req.TemplateIDs))
+	for i, s := range req.TemplateIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template ID: "+s, nil, "")
+		}
+		ids[i] = id
+	}
+
+	query := a.DB.Model(&models.Template{}).Where("organization_id = ? AND id IN ?", orgID, ids)
+	var result *gorm.DB
+	if archive {
+		result = query.Where("archived_at IS NULL").Update("archived_at", time.Now())
+	} else {
+		result = query.Where("archived_at IS NOT NULL").Update("archived_at", nil)
+	}
+	if result.Error != nil {
+		a.Log.Error("Failed to update templates", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update templates", nil, "")
+	}
+
+	message := "Templates archived"
+	if !archive {
+		message = "Templates restored"
+	}
+	return r.SendEnvelope(map[string]interface{}{
+		"message": message,
+		"count":   result.RowsAffected,
+	})
+}
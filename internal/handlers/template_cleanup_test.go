@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_GetTemplateCleanupSuggestions(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("tpl-cleanup"), "password123", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "acct-"+uuid.New().String()[:8])
+
+	old := time.Now().AddDate(0, 0, -120)
+	recent := time.Now().AddDate(0, 0, -5)
+
+	neverUsed := createTestTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(neverUsed).UpdateColumn("created_at", old).Error)
+
+	stale := createTestTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(stale).Updates(map[string]any{"usage_count": 10, "last_used_at": old}).Error)
+
+	rejected := createTestTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(rejected).Update("status", models.TemplateStatusRejected).Error)
+
+	active := createTestTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(active).Updates(map[string]any{"usage_count": 3, "last_used_at": recent}).Error)
+
+	// Unused, but still attached to a draft campaign
+	inCampaign := createTestTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(inCampaign).UpdateColumn("created_at", old).Error)
+	createTestCampaign(t, app, org.ID, inCampaign.ID, user.ID, account.Name, models.CampaignStatusDraft)
+
+	req := testutil.NewGETRequest(t)
+	req.RequestCtx.SetUserValue("user_id", user.ID)
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.GetTemplateCleanupSuggestions(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data struct {
+			Suggestions []handlers.TemplateCleanupSuggestion `json:"suggestions"`
+			Days        int                                  `json:"days"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+	assert.Equal(t, 90, resp.Data.Days)
+
+	reasons := map[uuid.UUID]string{}
+	for _, s := range resp.Data.Suggestions {
+		reasons[s.ID] = s.Reason
+	}
+	assert.Equal(t, map[uuid.UUID]string{
+		neverUsed.ID: handlers.TemplateCleanupNeverUsed,
+		stale.ID:     handlers.TemplateCleanupStale,
+		rejected.ID:  handlers.TemplateCleanupRejected,
+	}, reasons)
+}
+
+func TestApp_ArchiveTemplates(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	account := createTestWhatsAppAccount(t, app, org.ID, "acct-"+uuid.New().String()[:8])
+	archived := createTestTemplate(t, app, org.ID, account.Name)
+	kept := createTestTemplate(t, app, org.ID, account.Name)
+
+	req := testutil.NewJSONRequest(t, map[string]any{"template_ids": []string{archived.ID.String()}})
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.ArchiveTemplates(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	listTemplates := func(archivedOnly bool) []handlers.TemplateResponse {
+		req := testutil.NewGETRequest(t)
+		req.RequestCtx.SetUserValue("organization_id", org.ID)
+		if archivedOnly {
+			testutil.SetQueryParam(req, "archived", "true")
+		}
+		require.NoError(t, app.ListTemplates(req))
+
+		var resp struct {
+			Data struct {
+				Templates []handlers.TemplateResponse `json:"templates"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(testutil.GetResponseBody(req), &resp))
+		return resp.Data.Templates
+	}
+
+	library := listTemplates(false)
+	require.Len(t, library, 1)
+	assert.Equal(t, kept.ID, library[0].ID)
+
+	archivedList := listTemplates(true)
+	require.Len(t, archivedList, 1)
+	assert.Equal(t, archived.ID, archivedList[0].ID)
+	assert.NotNil(t, archivedList[0].ArchivedAt)
+
+	// Restoring brings it back into the library
+	req = testutil.NewJSONRequest(t, map[string]any{"template_ids": []string{archived.ID.String()}})
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.UnarchiveTemplates(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Len(t, listTemplates(false), 2)
+}
+
+func TestApp_ArchiveTemplates_InvalidID(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+
+	req := testutil.NewJSONRequest(t, map[string]any{"template_ids": []string{"not-a-uuid"}})
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.ArchiveTemplates(req))
+	assertErrorResponse(t, req, fasthttp.StatusBadRequest, "Invalid template ID")
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// maxTemplateSendRecipients caps the recipients of a single bulk template send request
+const maxTemplateSendRecipients = 1000
+
+// TemplateSendRecipient is one recipient of a bulk template send
+type TemplateSendRecipient struct {
+	PhoneNumber    string            `json:"phone_number"`
+	TemplateParams map[string]string `json:"template_params"` // Named or positional params
+	Reference      string            `json:"reference"`       // Optional caller ID, returned in the batch status
+}
+
+// SendBulkTemplateRequest represents the request to send a template to many recipients
+type SendBulkTemplateRequest struct {
+	TemplateName string                  `json:"template_name"`
+	TemplateID   string                  `json:"template_id"`  // Alternative: template UUID
+	AccountName  string                  `json:"account_name"` // Optional: specific WhatsApp account
+	Recipients   []TemplateSendRecipient `json:"recipients"`
+}
+
+// TemplateSendBatchResponse is the status of a bulk template send batch
+type TemplateSendBatchResponse struct {
+	models.TemplateSendBatch
+	PendingCount int                       `json:"pending_count"`
+	Items        []models.TemplateSendItem `json:"items"`
+}
+
+// SendBulkTemplate queues a template message for each recipient, each with its own
+// parameters, and returns a batch ID to poll for the outcome
+func (a *App) SendBulkTemplate(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req SendBulkTemplateRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.TemplateName == "" && req.TemplateID == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Either template_name or template_id is required", nil, "")
+	}
+	if len(req.Recipients) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "At least one recipient is required", nil, "")
+	}
+	if len(req.Recipients) > maxTemplateSendRecipients {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+			fmt.Sprintf("Too many recipients: at most %d per request", maxTemplateSendRecipients), nil, "")
+	}
+
+	var template models.Template
+	if req.TemplateID != "" {
+		templateID, err := uuid.Parse(req.TemplateID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template_id", nil, "")
+		}
+		if err := a.DB.Where("id = ? AND organization_id = ?", templateID, orgID).First(&template).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
+		}
+	} else {
+		if err := a.DB.Where("name = ? AND organization_id = ?", req.TemplateName, orgID).First(&template).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
+		}
+	}
+	if template.ArchivedAt != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template is archived", nil, "")
+	}
+	if template.Status != "APPROVED" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Template is not approved (status: %s)", template.Status), nil, "")
+	}
+
+	accountName := req.AccountName
+	if accountName == "" {
+		accountName = template.WhatsAppAccount
+	}
+	var account models.WhatsAppAccount
+	if accountName != "" {
+		if err := a.DB.Where("name = ? AND organization_id = ?", accountName, orgID).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+		}
+	} else if err := a.DB.Where("organization_id = ? AND is_default_outgoing = ?", orgID, true).First(&account).Error; err != nil {
+		if err := a.DB.Where("organization_id = ?", orgID).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No WhatsApp account configured", nil, "")
+		}
+	}
+
+	// Validate every recipient up front so a bad row rejects the whole request
+	// instead of leaving a partially queued batch
+	paramNames := ExtractParamNamesFromContent(template.BodyContent)
+	items := make([]models.TemplateSendItem, len(req.Recipients))
+	for i, rcpt := range req.Recipients {
+		normalized, err := phone.Normalize(rcpt.PhoneNumber)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("recipients[%d]: %s", i, err.Error()), nil, "")
+		}
+
+		bodyParams := ResolveParams(paramNames, rcpt.TemplateParams)
+		var missingParams []string
+		for j, name := range paramNames {
+			if j >= len(bodyParams) || bodyParams[j] == "" {
+				missingParams = append(missingParams, name)
+			}
+		}
+		if len(missingParams) > 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+				fmt.Sprintf("recipients[%d]: missing template parameters: %s", i, strings.Join(missingParams, ", ")), nil, "")
+		}
+
+		params := make(models.JSONB, len(rcpt.TemplateParams))
+		for k, v := range rcpt.TemplateParams {
+			params[k] = v
+		}
+		items[i] = models.TemplateSendItem{
+			BaseModel:      models.BaseModel{ID: uuid.New()},
+			OrganizationID: orgID,
+			PhoneNumber:    normalized,
+			TemplateParams: params,
+			Reference:      rcpt.Reference,
+			Status:         models.MessageStatusPending,
+		}
+	}
+
+	batch := models.TemplateSendBatch{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  orgID,
+		WhatsAppAccount: account.Name,
+		TemplateID:      template.ID,
+		Status:          models.TemplateSendBatchStatusProcessing,
+		TotalCount:      len(items),
+	}
+	if userID != uuid.Nil {
+		batch.CreatedBy = &userID
+	}
+	for i := range items {
+		items[i].BatchID = batch.ID
+	}
+
+	tx := a.DB.Begin()
+	if err := tx.Create(&batch).Error; err != nil {
+		tx.Rollback()
+		a.Log.Error("Failed to create template send batch", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create batch", nil, "")
+	}
+	if err := tx.CreateInBatches(&items, 500).Error; err != nil {
+		tx.Rollback()
+		a.Log.Error("Failed to create template send items", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create batch", nil, "")
+	}
+	if err := tx.Commit().Error; err != nil {
+		a.Log.Error("Failed to commit template send batch", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create batch", nil, "")
+	}
+
+	jobs := make([]*queue.TemplateSendJob, len(items))
+	for i, item := range items {
+		jobs[i] = &queue.TemplateSendJob{
+			BatchID:        batch.ID,
+			ItemID:         item.ID,
+			OrganizationID: orgID,
+		}
+	}
+	if err := a.Queue.EnqueueTemplateSends(r.RequestCtx, jobs); err != nil {
+		a.Log.Error("Failed to enqueue template sends", "error", err, "batch_id", batch.ID)
+		a.DB.Where("batch_id = ?", batch.ID).Delete(&models.TemplateSendItem{})
+		a.DB.Delete(&batch)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue messages", nil, "")
+	}
+
+	a.Log.Info("Template send batch queued", "batch_id", batch.ID, "template", template.Name, "count", len(items))
+
+	r.RequestCtx.SetStatusCode(fasthttp.StatusAccepted)
+	return r.SendEnvelope(map[string]interface{}{
+		"batch_id":    batch.ID,
+		"status":      batch.Status,
+		"total_count": batch.TotalCount,
+	})
+}
+
+// GetBulkTemplateBatch returns the progress of a bulk template send batch and the
+// delivery status of each of its messages
+func (a *App) GetBulkTemplateBatch(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	batchID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid batch ID", nil, "")
+	}
+
+	var batch models.TemplateSendBatch
+	if err := a.DB.Where("id = ? AND organization_id = ?", batchID, orgID).First(&batch).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Batch not found", nil, "")
+	}
+
+	var items []models.TemplateSendItem
+	if err := a.DB.Where("batch_id = ?", batch.ID).Order("created_at ASC, id ASC").Find(&items).Error; err != nil {
+		a.Log.Error("Failed to load template send items", "error", err, "batch_id", batch.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load batch", nil, "")
+	}
+
+	// Items only record whether the send succeeded; delivery and read receipts
+	// arrive on the message itself
+	var messageIDs []uuid.UUID
+	for _, item := range items {
+		if item.MessageID != nil {
+			messageIDs = append(messageIDs, *item.MessageID)
+		}
+	}
+	if len(messageIDs) > 0 {
+		var messages []models.Message
+		a.DB.Select("id, status, error_message, error_code").Where("id IN ?", messageIDs).Find(&messages)
+		byID := make(map[uuid.UUID]models.Message, len(messages))
+		for _, m := range messages {
+			byID[m.ID] = m
+		}
+		for i := range items {
+			if items[i].MessageID == nil {
+				continue
+			}
+			if m, ok := byID[*items[i].MessageID]; ok {
+				items[i].Status = m.Status
+				if m.Status == models.MessageStatusFailed {
+					items[i].ErrorMessage = m.ErrorMessage
+					items[i].ErrorCode = m.ErrorCode
+				}
+			}
+		}
+	}
+
+	pending := batch.TotalCount - batch.SentCount - batch.FailedCount
+	if pending < 0 {
+		pending = 0
+	}
+	return r.SendEnvelope(TemplateSendBatchResponse{
+		TemplateSendBatch: batch,
+		PendingCount:      pending,
+		Items:             items,
+	})
+}
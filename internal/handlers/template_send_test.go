@@ -0,0 +1,166 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_SendBulkTemplate(t *testing.T) {
+	app, mockQueue := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("bulk-template"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "bulk-template-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"template_name": template.Name,
+		"recipients": []map[string]interface{}{
+			{"phone_number": "+1 555 000 0001", "template_params": map[string]string{"1": "Alice"}, "reference": "order-1"},
+			{"phone_number": "15550000002", "template_params": map[string]string{"1": "Bob"}, "reference": "order-2"},
+		},
+	})
+	setAuthContext(req, org.ID, user.ID)
+
+	require.NoError(t, app.SendBulkTemplate(req))
+	require.Equal(t, fasthttp.StatusAccepted, testutil.GetResponseStatusCode(req))
+
+	var resp struct {
+		Data struct {
+			BatchID    string `json:"batch_id"`
+			TotalCount int    `json:"total_count"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &resp)
+	assert.Equal(t, 2, resp.Data.TotalCount)
+	require.Len(t, mockQueue.TemplateSendJobs, 2)
+	assert.Equal(t, resp.Data.BatchID, mockQueue.TemplateSendJobs[0].BatchID.String())
+
+	// Simulate the worker sending the first message and Meta delivering it
+	var items []models.TemplateSendItem
+	require.NoError(t, app.DB.Where("batch_id = ?", resp.Data.BatchID).Order("reference").Find(&items).Error)
+	require.Len(t, items, 2)
+	assert.Equal(t, "15550000001", items[0].PhoneNumber)
+	assert.Equal(t, "Alice", items[0].TemplateParams["1"])
+
+	contact := createMsgTestContact(t, app, org.ID, account.Name)
+	msg := models.Message{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		Direction:       models.DirectionOutgoing,
+		MessageType:     models.MessageTypeTemplate,
+		Status:          models.MessageStatusDelivered,
+	}
+	require.NoError(t, app.DB.Create(&msg).Error)
+	require.NoError(t, app.DB.Model(&items[0]).Updates(map[string]interface{}{"status": models.MessageStatusSent, "message_id": msg.ID}).Error)
+	require.NoError(t, app.DB.Model(&models.TemplateSendBatch{}).Where("id = ?", resp.Data.BatchID).Update("sent_count", 1).Error)
+
+	req = testutil.NewGETRequest(t)
+	setAuthContext(req, org.ID, user.ID)
+	testutil.SetPathParam(req, "id", resp.Data.BatchID)
+	require.NoError(t, app.GetBulkTemplateBatch(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var status struct {
+		Data handlers.TemplateSendBatchResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &status)
+	assert.Equal(t, models.TemplateSendBatchStatusProcessing, status.Data.Status)
+	assert.Equal(t, 1, status.Data.SentCount)
+	assert.Equal(t, 1, status.Data.PendingCount)
+	require.Len(t, status.Data.Items, 2)
+
+	byRef := map[string]models.TemplateSendItem{}
+	for _, item := range status.Data.Items {
+		byRef[item.Reference] = item
+	}
+	assert.Equal(t, models.MessageStatusDelivered, byRef["order-1"].Status)
+	assert.Equal(t, models.MessageStatusPending, byRef["order-2"].Status)
+}
+
+func TestApp_SendBulkTemplate_Validation(t *testing.T) {
+	app, mockQueue := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("bulk-template-invalid"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "bulk-template-invalid-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+
+	tests := []struct {
+		name       string
+		recipients []map[string]interface{}
+	}{
+		{"no recipients", nil},
+		{"invalid phone", []map[string]interface{}{
+			{"phone_number": "not-a-number", "template_params": map[string]string{"1": "Alice"}},
+		}},
+		{"missing params", []map[string]interface{}{
+			{"phone_number": "15550000001", "template_params": map[string]string{"1": "Alice"}},
+			{"phone_number": "15550000002"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testutil.NewJSONRequest(t, map[string]interface{}{
+				"template_id": template.ID.String(),
+				"recipients":  tt.recipients,
+			})
+			setAuthContext(req, org.ID, user.ID)
+
+			require.NoError(t, app.SendBulkTemplate(req))
+			assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+		})
+	}
+
+	assert.Empty(t, mockQueue.TemplateSendJobs)
+	var count int64
+	app.DB.Model(&models.TemplateSendBatch{}).Where("organization_id = ?", org.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestApp_PreviewTemplate(t *testing.T) {
+	app, _ := campaignTestApp(t)
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("preview-template"), "password", nil, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "preview-template-account")
+	template := createTestTemplate(t, app, org.ID, account.Name)
+
+	preview := func(query map[string]string) (int, whatsapp.TemplatePreview) {
+		req := testutil.NewGETRequest(t)
+		setAuthContext(req, org.ID, user.ID)
+		testutil.SetPathParam(req, "id", template.ID.String())
+		for k, v := range query {
+			testutil.SetQueryParam(req, k, v)
+		}
+		require.NoError(t, app.PreviewTemplate(req))
+
+		var resp whatsapp.TemplatePreview
+		if testutil.GetResponseStatusCode(req) == fasthttp.StatusOK {
+			testutil.ParseEnvelopeResponse(t, req, &resp)
+		}
+		return testutil.GetResponseStatusCode(req), resp
+	}
+
+	// Without a sample the placeholder is left in and the template can't be submitted
+	status, resp := preview(nil)
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, "Hello {{1}}", resp.Body)
+	assert.Equal(t, []string{"1"}, resp.Placeholders["body"])
+	assert.False(t, resp.Valid)
+	assert.Nil(t, resp.Payload)
+
+	status, resp = preview(map[string]string{"body.1": "Ana"})
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, "Hello Ana", resp.Body)
+	assert.True(t, resp.Valid)
+	assert.Equal(t, template.Name, resp.Payload["name"])
+
+	status, _ = preview(map[string]string{"button.3": "abc"})
+	assert.Equal(t, fasthttp.StatusBadRequest, status)
+}
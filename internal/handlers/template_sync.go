@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+)
+
+const (
+	// templateSyncTimeout bounds one account's template sync
+	templateSyncTimeout = 2 * time.Minute
+	// templateSyncOverlap is how far before the last sync an incremental sync starts
+	// looking, so changes made while it ran aren't missed
+	templateSyncOverlap = time.Minute
+	// templateSyncMaxUsage is the share of Meta's rate limit, in percent, at which a sync
+	// stops between pages and continues later
+	templateSyncMaxUsage = 80
+	// templateSyncUsagePause is how long a sync that stopped at templateSyncMaxUsage waits
+	templateSyncUsagePause = 15 * time.Minute
+	// templateSyncErrorBackoff is how long scheduled syncs wait after a failed sync
+	templateSyncErrorBackoff = 15 * time.Minute
+	// templateSyncBatchSize caps the accounts synced per scheduler run
+	templateSyncBatchSize = 20
+	// maxTemplateSyncInterval caps the minutes between scheduled syncs (one week)
+	maxTemplateSyncInterval = 7 * 24 * 60
+
+	templateSyncLockPrefix    = "template_sync:lock:"
+	templateSyncIntervalError = "template_sync_interval must be between 0 and 10080 minutes"
+)
+
+// errTemplateSyncRunning is returned when the account's templates are already syncing
+var errTemplateSyncRunning = errors.New("a template sync is already running for this account")
+
+// TemplateSyncResult is the outcome of syncing an account's templates
+type TemplateSyncResult struct {
+	Synced   int        `json:"count"`              // Templates saved by this run
+	Full     bool       `json:"full"`               // Every template was listed, not only changed ones
+	Complete bool       `json:"complete"`           // False when the sync stopped early and continues later
+	RetryAt  *time.Time `json:"retry_at,omitempty"` // When an incomplete sync continues
+}
+
+// syncAccountTemplates saves the account's templates from Meta. Unless full is set only
+// templates changed since the last completed sync are fetched. A sync that hits Meta's
+// rate limit, or gets close to it, saves its paging cursor and is continued by the
+// scheduler once the limit has reset.
+func (a *App) syncAccountTemplates(ctx context.Context, account *models.WhatsAppAccount, full bool) (*TemplateSyncResult, error) {
+	if a.Redis != nil {
+		key := templateSyncLockPrefix + account.ID.String()
+		ok, err := a.Redis.SetNX(ctx, key, 1, templateSyncTimeout).Result()
+		if err == nil && !ok {
+			return nil, errTemplateSyncRunning
+		}
+		if err == nil {
+			defer a.Redis.Del(context.Background(), key)
+		}
+	}
+
+	startedAt := time.Now()
+	opts := whatsapp.TemplateListOptions{}
+	// Continue an interrupted sync, unless a full sync was asked for and that one wasn't
+	if account.TemplateSyncCursor != "" && (!full || account.TemplateSyncFull) {
+		opts.After = account.TemplateSyncCursor
+		full = account.TemplateSyncFull
+		if account.TemplateSyncStartedAt != nil {
+			startedAt = *account.TemplateSyncStartedAt
+		}
+	}
+	if !full && account.TemplatesSyncedAt != nil {
+		opts.UpdatedSince = account.TemplatesSyncedAt.Add(-templateSyncOverlap)
+	}
+
+	result := &TemplateSyncResult{Full: full}
+	waAccount := a.toWhatsAppAccount(account)
+	for {
+		page, err := a.WhatsApp.FetchTemplatePage(ctx, waAccount, opts)
+		if err != nil {
+			var rateErr *whatsapp.RateLimitError
+			if errors.As(err, &rateErr) {
+				a.Log.Warn("Template sync hit Meta's rate limit", "account", account.Name, "retry_after", rateErr.RetryAfter)
+				result.RetryAt = a.pauseTemplateSync(account, opts.After, startedAt, full, rateErr.RetryAfter, "")
+				return result, nil
+			}
+			a.pauseTemplateSync(account, opts.After, startedAt, full, templateSyncErrorBackoff, err.Error())
+			return result, err
+		}
+
+		for _, metaTemplate := range page.Templates {
+			if err := a.saveMetaTemplate(account, metaTemplate); err != nil {
+				a.pauseTemplateSync(account, opts.After, startedAt, full, templateSyncErrorBackoff, "Failed to save templates")
+				return result, err
+			}
+			result.Synced++
+		}
+
+		if page.After == "" {
+			break
+		}
+		opts.After = page.After
+		if page.Usage >= templateSyncMaxUsage {
+			a.Log.Info("Pausing template sync near Meta's rate limit", "account", account.Name, "usage", page.Usage)
+			result.RetryAt = a.pauseTemplateSync(account, opts.After, startedAt, full, templateSyncUsagePause, "")
+			return result, nil
+		}
+	}
+
+	account.TemplatesSyncedAt = &startedAt
+	account.TemplateSyncError = ""
+	account.TemplateSyncRetryAt = nil
+	account.TemplateSyncCursor = ""
+	account.TemplateSyncStartedAt = nil
+	account.TemplateSyncFull = false
+	if err := a.DB.Model(account).Updates(map[string]interface{}{
+		"templates_synced_at":      startedAt,
+		"template_sync_error":      "",
+		"template_sync_retry_at":   nil,
+		"template_sync_cursor":     "",
+		"template_sync_started_at": nil,
+		"template_sync_full":       false,
+	}).Error; err != nil {
+		a.Log.Error("Failed to save template sync status", "error", err, "account", account.Name)
+	}
+	result.Complete = true
+	return result, nil
+}
+
+// pauseTemplateSync saves where an unfinished sync stopped, so the scheduler continues
+// it after wait, and returns when that is. syncErr is empty when nothing went wrong.
+func (a *App) pauseTemplateSync(account *models.WhatsAppAccount, cursor string, startedAt time.Time, full bool, wait time.Duration, syncErr string) *time.Time {
+	retryAt := time.Now().Add(wait)
+	account.TemplateSyncError = syncErr
+	account.TemplateSyncRetryAt = &retryAt
+	account.TemplateSyncCursor = cursor
+	account.TemplateSyncStartedAt = &startedAt
+	account.TemplateSyncFull = full
+	if err := a.DB.Model(account).Updates(map[string]interface{}{
+		"template_sync_error":      syncErr,
+		"template_sync_retry_at":   retryAt,
+		"template_sync_cursor":     cursor,
+		"template_sync_started_at": startedAt,
+		"template_sync_full":       full,
+	}).Error; err != nil {
+		a.Log.Error("Failed to save template sync status", "error", err, "account", account.Name)
+	}
+	return &retryAt
+}
+
+// saveMetaTemplate creates or updates a template from Meta, restoring it if it was
+// deleted locally
+func (a *App) saveMetaTemplate(account *models.WhatsAppAccount, metaTemplate whatsapp.MetaTemplate) error {
+	template := models.Template{
+		OrganizationID:  account.OrganizationID,
+		WhatsAppAccount: account.Name,
+		MetaTemplateID:  metaTemplate.ID,
+		Name:            metaTemplate.Name,
+		DisplayName:     metaTemplate.Name,
+		Language:        metaTemplate.Language,
+		Category:        metaTemplate.Category,
+		Status:          metaTemplate.Status,
+	}
+
+	for _, comp := range metaTemplate.Components {
+		switch comp.Type {
+		case "HEADER":
+			template.HeaderType = comp.Format
+			if comp.Text != "" {
+				template.HeaderContent = comp.Text
+			}
+		case "BODY":
+			template.BodyContent = comp.Text
+		case "FOOTER":
+			template.FooterContent = comp.Text
+		case "BUTTONS":
+			buttons := make([]interface{}, len(comp.Buttons))
+			for i, btn := range comp.Buttons {
+				buttons[i] = btn
+			}
+			template.Buttons = convertToJSONBArray(buttons)
+		}
+	}
+
+	// Include soft-deleted templates to restore them
+	existing := models.Template{}
+	if err := a.DB.Unscoped().Where("organization_id = ? AND whats_app_account = ? AND name = ? AND language = ?",
+		account.OrganizationID, account.Name, template.Name, template.Language).First(&existing).Error; err == nil {
+		template.ID = existing.ID
+		return a.DB.Unscoped().Model(&template).Updates(map[string]interface{}{
+			"meta_template_id": template.MetaTemplateID,
+			"display_name":     template.DisplayName,
+			"category":         template.Category,
+			"status":           template.Status,
+			"header_type":      template.HeaderType,
+			"header_content":   template.HeaderContent,
+			"body_content":     template.BodyContent,
+			"footer_content":   template.FooterContent,
+			"buttons":          template.Buttons,
+			"deleted_at":       nil, // Restore soft-deleted template
+		}).Error
+	}
+	return a.DB.Create(&template).Error
+}
+
+// syncDueTemplates runs the scheduled template syncs that are due, and continues syncs
+// that stopped early
+func (a *App) syncDueTemplates(ctx context.Context) {
+	now := time.Now()
+	var accounts []models.WhatsAppAccount
+	if err := a.DB.Where("status = ?", "active").
+		Where("template_sync_retry_at IS NULL OR template_sync_retry_at <= ?", now).
+		Where("template_sync_cursor <> '' OR (template_sync_interval > 0 AND (templates_synced_at IS NULL OR templates_synced_at <= ?::timestamptz - template_sync_interval * INTERVAL '1 minute'))", now).
+		Order("templates_synced_at ASC NULLS FIRST").
+		Limit(templateSyncBatchSize).
+		Find(&accounts).Error; err != nil {
+		a.Log.Error("Failed to list accounts due for template sync", "error", err)
+		return
+	}
+
+	for i := range accounts {
+		if ctx.Err() != nil {
+			return
+		}
+		syncCtx, cancel := context.WithTimeout(ctx, templateSyncTimeout)
+		result, err := a.syncAccountTemplates(syncCtx, &accounts[i], false)
+		cancel()
+		switch {
+		case errors.Is(err, errTemplateSyncRunning):
+		case err != nil:
+			a.Log.Error("Scheduled template sync failed", "error", err, "account", accounts[i].Name)
+		default:
+			a.Log.Debug("Scheduled template sync done", "account", accounts[i].Name, "synced", result.Synced, "complete", result.Complete)
+		}
+	}
+}
+
+// TemplateSyncProcessor periodically syncs the templates of each account from Meta
+type TemplateSyncProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewTemplateSyncProcessor creates a new template sync processor
+func NewTemplateSyncProcessor(app *App, interval time.Duration) *TemplateSyncProcessor {
+	return &TemplateSyncProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the sync loop
+func (p *TemplateSyncProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Template sync processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Template sync processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Template sync processor stopped")
+			return
+		case <-ticker.C:
+			p.app.syncDueTemplates(ctx)
+		}
+	}
+}
+
+// Stop stops the template sync processor
+func (p *TemplateSyncProcessor) Stop() {
+	close(p.stopCh)
+}
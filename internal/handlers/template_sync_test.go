@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// fakeTemplateGraph serves two pages of templates. While rateLimited is set, requests
+// for the second page are refused with Meta's rate limit error.
+type fakeTemplateGraph struct {
+	mu          sync.Mutex
+	requests    []map[string]string
+	rateLimited bool
+}
+
+func (g *fakeTemplateGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	query := map[string]string{"after": r.URL.Query().Get("after"), "since": r.URL.Query().Get("since")}
+	g.requests = append(g.requests, query)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch query["after"] {
+	case "":
+		_, _ = w.Write([]byte(`{"data":[{"id":"t1","name":"welcome","language":"en","category":"MARKETING","status":"APPROVED","components":[{"type":"BODY","text":"Hi"}]}],"paging":{"cursors":{"after":"page2"},"next":"next"}}`))
+	case "page2":
+		if g.rateLimited {
+			w.Header().Set("X-Business-Use-Case-Usage", `{"waba":[{"call_count":100,"estimated_time_to_regain_access":30}]}`)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"Too many calls","code":80008}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"t2","name":"receipt","language":"en","category":"UTILITY","status":"APPROVED","components":[{"type":"BODY","text":"Paid"}]}],"paging":{"cursors":{"after":"end"}}}`))
+	}
+}
+
+func (g *fakeTemplateGraph) lastRequest() map[string]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.requests[len(g.requests)-1]
+}
+
+func TestApp_SyncTemplates_Incremental(t *testing.T) {
+	app := testApp(t)
+	graph := &fakeTemplateGraph{rateLimited: true}
+	server := httptest.NewServer(graph)
+	t.Cleanup(server.Close)
+	app.WhatsApp = whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+
+	org := createTestOrganization(t, app)
+	user := createTestUser(t, app, org.ID, uniqueEmail("template-sync"), "password", &createTransferAdminRole(t, app.DB, org.ID).ID, true)
+	account := createTestWhatsAppAccount(t, app, org.ID, "sync-account")
+
+	runSync := func(body map[string]any) map[string]any {
+		req := testutil.NewJSONRequest(t, body)
+		setAuthContext(req, org.ID, user.ID)
+		require.NoError(t, app.SyncTemplates(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var resp map[string]any
+		testutil.ParseEnvelopeResponse(t, req, &resp)
+		return resp
+	}
+	loadAccount := func() models.WhatsAppAccount {
+		var stored models.WhatsAppAccount
+		require.NoError(t, app.DB.Where("id = ?", account.ID).First(&stored).Error)
+		return stored
+	}
+
+	// The rate limit stops the first sync after one page and saves where it stopped
+	resp := runSync(map[string]any{"whatsapp_account": account.Name})
+	assert.Equal(t, float64(1), resp["count"])
+	assert.Equal(t, false, resp["complete"])
+	assert.NotNil(t, resp["retry_at"])
+
+	stored := loadAccount()
+	assert.Equal(t, "page2", stored.TemplateSyncCursor)
+	assert.Nil(t, stored.TemplatesSyncedAt)
+	require.NotNil(t, stored.TemplateSyncRetryAt)
+
+	// The accounts API shows the sync as unfinished
+	list := testutil.NewGETRequest(t)
+	setAuthContext(list, org.ID, user.ID)
+	require.NoError(t, app.ListAccounts(list))
+	var accounts struct {
+		Accounts []handlers.AccountResponse `json:"accounts"`
+	}
+	testutil.ParseEnvelopeResponse(t, list, &accounts)
+	require.Len(t, accounts.Accounts, 1)
+	assert.True(t, accounts.Accounts[0].TemplateSync.InProgress)
+	assert.Equal(t, 60, accounts.Accounts[0].TemplateSync.Interval)
+	assert.NotNil(t, accounts.Accounts[0].TemplateSync.RetryAt)
+
+	// The next sync continues from the saved cursor
+	graph.mu.Lock()
+	graph.rateLimited = false
+	graph.mu.Unlock()
+	resp = runSync(map[string]any{"whatsapp_account": account.Name})
+	assert.Equal(t, float64(1), resp["count"])
+	assert.Equal(t, true, resp["complete"])
+	assert.Equal(t, "page2", graph.lastRequest()["after"])
+
+	stored = loadAccount()
+	assert.Empty(t, stored.TemplateSyncCursor)
+	require.NotNil(t, stored.TemplatesSyncedAt)
+	assert.Nil(t, stored.TemplateSyncRetryAt)
+
+	var count int64
+	app.DB.Model(&models.Template{}).Where("organization_id = ? AND whats_app_account = ?", org.ID, account.Name).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	// Later syncs only ask for templates changed since the last one
+	runSync(map[string]any{"whatsapp_account": account.Name})
+	assert.NotEmpty(t, graph.lastRequest()["since"])
+
+	// A full sync lists everything again
+	resp = runSync(map[string]any{"whatsapp_account": account.Name, "full": true})
+	assert.Equal(t, true, resp["full"])
+	graph.mu.Lock()
+	first := graph.requests[len(graph.requests)-2]
+	graph.mu.Unlock()
+	assert.Empty(t, first["since"])
+	assert.Empty(t, first["after"])
+}
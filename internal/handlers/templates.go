@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
@@ -47,6 +50,9 @@ type TemplateResponse struct {
 	FooterContent   string        `json:"footer_content"`
 	Buttons         []interface{} `json:"buttons"`
 	SampleValues    []interface{} `json:"sample_values"`
+	UsageCount      int64         `json:"usage_count"`
+	LastUsedAt      *time.Time    `json:"last_used_at,omitempty"`
+	ArchivedAt      *time.Time    `json:"archived_at,omitempty"`
 	CreatedAt       string        `json:"created_at"`
 	UpdatedAt       string        `json:"updated_at"`
 }
@@ -62,9 +68,17 @@ func (a *App) ListTemplates(r *fastglue.Request) error {
 	accountName := string(r.RequestCtx.QueryArgs().Peek("account")) // Filter by account name
 	status := string(r.RequestCtx.QueryArgs().Peek("status"))
 	category := string(r.RequestCtx.QueryArgs().Peek("category"))
+	archived := string(r.RequestCtx.QueryArgs().Peek("archived")) == "true"
 
 	query := a.DB.Where("organization_id = ?", orgID)
 
+	// Archived templates are hidden unless explicitly requested
+	if archived {
+		query = query.Where("archived_at IS NOT NULL")
+	} else {
+		query = query.Where("archived_at IS NULL")
+	}
+
 	if accountName != "" {
 		query = query.Where("whats_app_account = ?", accountName)
 	}
@@ -144,6 +158,10 @@ func (a *App) CreateTemplate(r *fastglue.Request) error {
 		SampleValues:    convertToJSONBArray(req.SampleValues),
 	}
 
+	if invalid, err := sendTemplateValidationErrors(r, &template); invalid {
+		return err
+	}
+
 	if err := a.DB.Create(&template).Error; err != nil {
 		a.Log.Error("Failed to create template", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create template", nil, "")
@@ -176,6 +194,72 @@ func (a *App) GetTemplate(r *fastglue.Request) error {
 	return r.SendEnvelope(templateToResponse(template))
 }
 
+// PreviewTemplate renders a template with sample values and returns the payload that
+// would be submitted to Meta. Query parameters header.<name> and body.<name> set the
+// value of a placeholder, and button.<index> the example of a URL or copy code button;
+// anything not given falls back to the template's saved samples.
+func (a *App) PreviewTemplate(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	idStr, ok := r.RequestCtx.UserValue("id").(string)
+	if !ok || idStr == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Missing template ID", nil, "")
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template ID", nil, "")
+	}
+
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&template).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
+	}
+
+	submission := templateSubmission(&template)
+	submission.Buttons = make([]interface{}, len(template.Buttons))
+	for i, b := range template.Buttons {
+		if btn, ok := b.(map[string]interface{}); ok {
+			b = copyMap(btn)
+		}
+		submission.Buttons[i] = b
+	}
+
+	values := map[string]map[string]string{}
+	var badKey string
+	r.RequestCtx.QueryArgs().VisitAll(func(k, v []byte) {
+		component, name, found := strings.Cut(string(k), ".")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return
+		}
+		switch component {
+		case "header", "body":
+			if values[component] == nil {
+				values[component] = map[string]string{}
+			}
+			values[component][name] = string(v)
+		case "button":
+			idx, err := strconv.Atoi(name)
+			if err != nil || idx < 0 || idx >= len(submission.Buttons) {
+				badKey = string(k)
+				return
+			}
+			if btn, ok := submission.Buttons[idx].(map[string]interface{}); ok {
+				btn["example"] = string(v)
+			}
+		}
+	})
+	if badKey != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("No button at %s", badKey), nil, "")
+	}
+	submission.SampleValues = whatsapp.MergeSampleValues(submission.SampleValues, values)
+
+	return r.SendEnvelope(whatsapp.PreviewTemplate(submission))
+}
+
 // UpdateTemplate updates a message template
 func (a *App) UpdateTemplate(r *fastglue.Request) error {
 	orgID, err := getOrganizationID(r)
@@ -232,6 +316,10 @@ func (a *App) UpdateTemplate(r *fastglue.Request) error {
 		template.SampleValues = convertToJSONBArray(req.SampleValues)
 	}
 
+	if invalid, err := sendTemplateValidationErrors(r, &template); invalid {
+		return err
+	}
+
 	if err := a.DB.Save(&template).Error; err != nil {
 		a.Log.Error("Failed to update template", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update template", nil, "")
@@ -345,7 +433,13 @@ func (a *App) SubmitTemplate(r *fastglue.Request) error {
 func (a *App) submitTemplateToMeta(account *models.WhatsAppAccount, template *models.Template) (string, error) {
 	waAccount := a.toWhatsAppAccount(account)
 
-	submission := &whatsapp.TemplateSubmission{
+	ctx := context.Background()
+	return a.WhatsApp.SubmitTemplate(ctx, waAccount, templateSubmission(template))
+}
+
+// templateSubmission converts a template to the form submitted to Meta
+func templateSubmission(template *models.Template) *whatsapp.TemplateSubmission {
+	return &whatsapp.TemplateSubmission{
 		Name:          template.Name,
 		Language:      template.Language,
 		Category:      template.Category,
@@ -356,12 +450,23 @@ func (a *App) submitTemplateToMeta(account *models.WhatsAppAccount, template *mo
 		Buttons:       template.Buttons,
 		SampleValues:  template.SampleValues,
 	}
+}
 
-	ctx := context.Background()
-	return a.WhatsApp.SubmitTemplate(ctx, waAccount, submission)
+// sendTemplateValidationErrors responds with the template's field errors if Meta would
+// reject it, and reports whether it did
+func sendTemplateValidationErrors(r *fastglue.Request, template *models.Template) (bool, error) {
+	errs := whatsapp.ValidateTemplate(templateSubmission(template))
+	if len(errs) == 0 {
+		return false, nil
+	}
+	return true, r.SendErrorEnvelope(fasthttp.StatusBadRequest, errs[0].Message, map[string]interface{}{
+		"errors": errs,
+	}, "")
 }
 
-// SyncTemplates syncs templates from Meta API
+// SyncTemplates syncs templates from Meta API. Only templates changed since the last
+// sync are fetched unless full is set. If Meta's rate limit stops the sync early, the
+// rest is synced in the background once the limit resets.
 func (a *App) SyncTemplates(r *fastglue.Request) error {
 	orgID, err := getOrganizationID(r)
 	if err != nil {
@@ -370,13 +475,16 @@ func (a *App) SyncTemplates(r *fastglue.Request) error {
 
 	// Get account name from query or body
 	accountName := string(r.RequestCtx.QueryArgs().Peek("account"))
+	full := string(r.RequestCtx.QueryArgs().Peek("full")) == "true"
+	var body struct {
+		WhatsAppAccount string `json:"whatsapp_account"`
+		Full            bool   `json:"full"`
+	}
+	_ = r.Decode(&body, "json")
 	if accountName == "" {
-		var body struct {
-			WhatsAppAccount string `json:"whatsapp_account"`
-		}
-		_ = r.Decode(&body, "json")
 		accountName = body.WhatsAppAccount
 	}
+	full = full || body.Full
 
 	if accountName == "" {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "whatsapp_account is required", nil, "")
@@ -387,87 +495,30 @@ func (a *App) SyncTemplates(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "WhatsApp account not found", nil, "")
 	}
 
-	// Fetch templates from Meta API
-	templates, err := a.fetchTemplatesFromMeta(&account)
+	ctx, cancel := context.WithTimeout(context.Background(), templateSyncTimeout)
+	defer cancel()
+	result, err := a.syncAccountTemplates(ctx, &account, full)
+	if errors.Is(err, errTemplateSyncRunning) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Templates are already syncing for this account", nil, "")
+	}
 	if err != nil {
-		a.Log.Error("Failed to fetch templates from Meta", "error", err)
+		a.Log.Error("Failed to sync templates from Meta", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch templates from Meta: "+err.Error(), nil, "")
 	}
 
-	// Sync to database
-	synced := 0
-	for _, metaTemplate := range templates {
-		template := models.Template{
-			OrganizationID:  orgID,
-			WhatsAppAccount: account.Name,
-			MetaTemplateID:  metaTemplate.ID,
-			Name:            metaTemplate.Name,
-			DisplayName:     metaTemplate.Name,
-			Language:        metaTemplate.Language,
-			Category:        metaTemplate.Category,
-			Status:          metaTemplate.Status,
-		}
-
-		// Parse components
-		for _, comp := range metaTemplate.Components {
-			switch comp.Type {
-			case "HEADER":
-				template.HeaderType = comp.Format
-				if comp.Text != "" {
-					template.HeaderContent = comp.Text
-				}
-			case "BODY":
-				template.BodyContent = comp.Text
-			case "FOOTER":
-				template.FooterContent = comp.Text
-			case "BUTTONS":
-				// Convert []TemplateButton to []interface{}
-				buttons := make([]interface{}, len(comp.Buttons))
-				for i, btn := range comp.Buttons {
-					buttons[i] = btn
-				}
-				template.Buttons = convertToJSONBArray(buttons)
-			}
-		}
-
-		// Upsert (including soft-deleted templates to restore them)
-		existing := models.Template{}
-		if err := a.DB.Unscoped().Where("organization_id = ? AND whats_app_account = ? AND name = ? AND language = ?",
-			orgID, account.Name, template.Name, template.Language).First(&existing).Error; err == nil {
-			// Update existing and restore if soft-deleted (explicitly set deleted_at to NULL)
-			template.ID = existing.ID
-			a.DB.Unscoped().Model(&template).Updates(map[string]interface{}{
-				"meta_template_id": template.MetaTemplateID,
-				"display_name":     template.DisplayName,
-				"category":         template.Category,
-				"status":           template.Status,
-				"header_type":      template.HeaderType,
-				"header_content":   template.HeaderContent,
-				"body_content":     template.BodyContent,
-				"footer_content":   template.FooterContent,
-				"buttons":          template.Buttons,
-				"deleted_at":       nil, // Restore soft-deleted template
-			})
-		} else {
-			// Create new
-			a.DB.Create(&template)
-		}
-		synced++
+	message := fmt.Sprintf("Synced %d templates", result.Synced)
+	if !result.Complete {
+		message = fmt.Sprintf("Synced %d templates so far; the rest sync automatically once Meta's rate limit resets", result.Synced)
 	}
-
 	return r.SendEnvelope(map[string]interface{}{
-		"message": fmt.Sprintf("Synced %d templates", synced),
-		"count":   synced,
+		"message":  message,
+		"count":    result.Synced,
+		"full":     result.Full,
+		"complete": result.Complete,
+		"retry_at": result.RetryAt,
 	})
 }
 
-func (a *App) fetchTemplatesFromMeta(account *models.WhatsAppAccount) ([]whatsapp.MetaTemplate, error) {
-	waAccount := a.toWhatsAppAccount(account)
-
-	ctx := context.Background()
-	return a.WhatsApp.FetchTemplates(ctx, waAccount)
-}
-
 func (a *App) deleteTemplateFromMeta(account *models.WhatsAppAccount, templateName string) {
 	waAccount := a.toWhatsAppAccount(account)
 
@@ -495,6 +546,9 @@ func templateToResponse(t models.Template) TemplateResponse {
 		FooterContent:   t.FooterContent,
 		Buttons:         convertFromJSONBArray(t.Buttons),
 		SampleValues:    convertFromJSONBArray(t.SampleValues),
+		UsageCount:      t.UsageCount,
+		LastUsedAt:      t.LastUsedAt,
+		ArchivedAt:      t.ArchivedAt,
 		CreatedAt:       t.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:       t.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
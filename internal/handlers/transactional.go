@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/shridarpatil/whatomate/internal/plugins"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+// maxIdempotencyKeyLen is the longest idempotency key accepted by the transactional API
+const maxIdempotencyKeyLen = 255
+
+// TransactionalSendRequest is the body of POST /api/v1/send
+type TransactionalSendRequest struct {
+	To             string            `json:"to"`   // Recipient phone number in international format
+	Type           string            `json:"type"` // "template" or "text"
+	TemplateName   string            `json:"template_name"`
+	TemplateID     string            `json:"template_id"`
+	TemplateParams map[string]string `json:"template_params"`
+	Text           string            `json:"text"` // Only delivered inside the 24-hour customer service window
+	AccountName    string            `json:"account_name"`
+	IdempotencyKey string            `json:"idempotency_key"` // Also accepted as the Idempotency-Key header
+	CallbackURL    string            `json:"callback_url"`    // Receives this message's sent, delivered, read and failed events
+	CallbackSecret string            `json:"callback_secret"` // Signs callbacks with X-Webhook-Signature
+	FlowToken      string            `json:"flow_token"`      // Token for a template FLOW button, generated if empty
+	FlowActionData map[string]any    `json:"flow_action_data"`
+}
+
+// TransactionalSendResponse is the result of a transactional send
+type TransactionalSendResponse struct {
+	MessageID         uuid.UUID            `json:"message_id"`
+	Status            models.MessageStatus `json:"status"`
+	WhatsAppMessageID string               `json:"whatsapp_message_id,omitempty"`
+	IdempotencyKey    string               `json:"idempotency_key,omitempty"`
+	ErrorCode         int                  `json:"error_code,omitempty"`
+	ErrorCategory     string               `json:"error_category,omitempty"`
+	ErrorMessage      string               `json:"error_message,omitempty"`
+}
+
+// TransactionalCallbackData is the data of a delivery callback for a transactional message
+type TransactionalCallbackData struct {
+	MessageID         string               `json:"message_id"`
+	WhatsAppMessageID string               `json:"whatsapp_message_id,omitempty"`
+	IdempotencyKey    string               `json:"idempotency_key,omitempty"`
+	Status            models.MessageStatus `json:"status"`
+	OccurredAt        time.Time            `json:"occurred_at"`
+	ErrorCode         int                  `json:"error_code,omitempty"`
+	ErrorCategory     string               `json:"error_category,omitempty"`
+	ErrorMessage      string               `json:"error_message,omitempty"`
+}
+
+// TransactionalSend sends a single template or session message for an external system.
+// Retrying with the same idempotency key returns the original message instead of sending again.
+func (a *App) TransactionalSend(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req TransactionalSendRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if key := strings.TrimSpace(string(r.RequestCtx.Request.Header.Peek("Idempotency-Key"))); key != "" {
+		req.IdempotencyKey = key
+	}
+	if msg := validateTransactionalSend(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	if req.IdempotencyKey != "" {
+		var existing models.TransactionalSend
+		if err := a.DB.Where("organization_id = ? AND idempotency_key = ?", orgID, req.IdempotencyKey).First(&existing).Error; err == nil {
+			return a.replayTransactionalSend(r, &existing)
+		}
+	}
+
+	to, err := phone.Normalize(req.To)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var template *models.Template
+	if req.Type == string(models.MessageTypeTemplate) {
+		var t models.Template
+		query := a.DB.Where("organization_id = ?", orgID)
+		if req.TemplateID != "" {
+			templateID, err := uuid.Parse(req.TemplateID)
+			if err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template_id", nil, "")
+			}
+			query = query.Where("id = ?", templateID)
+		} else {
+			query = query.Where("name = ?", req.TemplateName)
+		}
+		if err := query.First(&t).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
+		}
+		if t.ArchivedAt != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template is archived", nil, "")
+		}
+		if t.Status != "APPROVED" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Template is not approved (status: %s)", t.Status), nil, "")
+		}
+
+		paramNames := ExtractParamNamesFromContent(t.BodyContent)
+		bodyParams := ResolveParams(paramNames, req.TemplateParams)
+		var missingParams []string
+		for i, name := range paramNames {
+			if i >= len(bodyParams) || bodyParams[i] == "" {
+				missingParams = append(missingParams, name)
+			}
+		}
+		if len(missingParams) > 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+				fmt.Sprintf("Missing template parameters: %s", strings.Join(missingParams, ", ")), nil, "")
+		}
+		template = &t
+	}
+
+	accountName := req.AccountName
+	if accountName == "" && template != nil {
+		accountName = template.WhatsAppAccount
+	}
+	var account models.WhatsAppAccount
+	if accountName != "" {
+		if err := a.DB.Where("name = ? AND organization_id = ?", accountName, orgID).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+		}
+	} else if err := a.DB.Where("organization_id = ? AND is_default_outgoing = ?", orgID, true).First(&account).Error; err != nil {
+		if err := a.DB.Where("organization_id = ?", orgID).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No WhatsApp account configured", nil, "")
+		}
+	}
+
+	// Reserve the idempotency key before sending so that concurrent retries can't both send
+	send := models.TransactionalSend{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		CallbackURL:    req.CallbackURL,
+		CallbackSecret: req.CallbackSecret,
+	}
+	if req.IdempotencyKey != "" {
+		send.IdempotencyKey = &req.IdempotencyKey
+	}
+	if apiKeyID, ok := r.RequestCtx.UserValue("api_key_id").(uuid.UUID); ok {
+		send.APIKeyID = &apiKeyID
+	}
+	result := a.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&send)
+	if result.Error != nil {
+		a.Log.Error("Failed to create transactional send", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to send message", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		// A concurrent request claimed the key first
+		var existing models.TransactionalSend
+		if err := a.DB.Where("organization_id = ? AND idempotency_key = ?", orgID, req.IdempotencyKey).First(&existing).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "A request with this idempotency key is still being processed", nil, "")
+		}
+		return a.replayTransactionalSend(r, &existing)
+	}
+
+	contact, isNew := a.getOrCreateContact(orgID, to, "")
+	if isNew {
+		a.enrichContactWithScripts(contact)
+		a.enrichContactWithProviders(contact)
+		a.notifyPluginsContactCreated(plugins.ContactEvent{
+			OrganizationID: orgID,
+			ContactID:      contact.ID,
+			PhoneNumber:    contact.PhoneNumber,
+		})
+	}
+
+	msgReq := OutgoingMessageRequest{
+		Account: &account,
+		Contact: contact,
+	}
+	if template != nil {
+		msgReq.Type = models.MessageTypeTemplate
+		msgReq.Template = template
+		msgReq.BodyParams = req.TemplateParams
+		msgReq.FlowToken = req.FlowToken
+		msgReq.FlowActionData = req.FlowActionData
+	} else {
+		msgReq.Type = models.MessageTypeText
+		msgReq.Content = req.Text
+	}
+
+	// Send synchronously so the caller learns right away whether WhatsApp accepted the message
+	opts := APISendOptions()
+	opts.Async = false
+	opts.SkipSignature = true // Notifications, not agent replies
+	if userID != uuid.Nil {
+		opts.SentByUserID = &userID
+	}
+
+	message, err := a.SendOutgoingMessage(context.Background(), msgReq, opts)
+	if err != nil {
+		// Nothing was sent, so release the idempotency key for a corrected retry
+		a.DB.Unscoped().Delete(&send)
+		if isSendBlocked(err) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+		}
+		a.Log.Error("Failed to send transactional message", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to send message", nil, "")
+	}
+
+	send.MessageID = &message.ID
+	if err := a.DB.Model(&send).Update("message_id", message.ID).Error; err != nil {
+		a.Log.Error("Failed to link transactional send", "error", err, "message_id", message.ID)
+	}
+
+	// Reload for the status written when the send finished
+	if err := a.DB.First(message, message.ID).Error; err != nil {
+		a.Log.Error("Failed to reload message", "error", err, "message_id", message.ID)
+	}
+	if message.Status == models.MessageStatusFailed {
+		a.sendTransactionalCallback(&send, message, models.MessageStatusFailed, time.Now(), message.ErrorCode, message.ErrorMessage)
+	}
+
+	return r.SendEnvelope(transactionalSendResponse(&send, message))
+}
+
+// validateTransactionalSend checks a transactional send request and returns an error
+// message, or "" if it is valid
+func validateTransactionalSend(req *TransactionalSendRequest) string {
+	if req.To == "" {
+		return "to is required"
+	}
+	switch req.Type {
+	case string(models.MessageTypeTemplate):
+		if req.TemplateName == "" && req.TemplateID == "" {
+			return "Either template_name or template_id is required"
+		}
+	case string(models.MessageTypeText):
+		if strings.TrimSpace(req.Text) == "" {
+			return "text is required"
+		}
+	default:
+		return "type must be template or text"
+	}
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLen {
+		return fmt.Sprintf("idempotency_key must be at most %d characters", maxIdempotencyKeyLen)
+	}
+	if req.CallbackURL != "" {
+		u, err := url.Parse(req.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "callback_url must be an absolute http or https URL"
+		}
+	}
+	return ""
+}
+
+// replayTransactionalSend answers a retried request with the message sent for its
+// idempotency key
+func (a *App) replayTransactionalSend(r *fastglue.Request, send *models.TransactionalSend) error {
+	if send.MessageID == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A request with this idempotency key is still being processed", nil, "")
+	}
+
+	var message models.Message
+	if err := a.DB.Where("id = ?", *send.MessageID).First(&message).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Message not found", nil, "")
+	}
+
+	r.RequestCtx.Response.Header.Set("Idempotent-Replayed", "true")
+	return r.SendEnvelope(transactionalSendResponse(send, &message))
+}
+
+func transactionalSendResponse(send *models.TransactionalSend, message *models.Message) TransactionalSendResponse {
+	resp := TransactionalSendResponse{
+		MessageID:         message.ID,
+		Status:            message.Status,
+		WhatsAppMessageID: message.WhatsAppMessageID,
+	}
+	if send.IdempotencyKey != nil {
+		resp.IdempotencyKey = *send.IdempotencyKey
+	}
+	if message.Status == models.MessageStatusFailed {
+		resp.ErrorCode = message.ErrorCode
+		resp.ErrorCategory = errorCategory(message.ErrorCode)
+		resp.ErrorMessage = message.ErrorMessage
+	}
+	return resp
+}
+
+// dispatchTransactionalCallback posts a status change to the callback URL given when
+// the message was sent through the transactional API, if any
+func (a *App) dispatchTransactionalCallback(message *models.Message, status models.MessageStatus, occurredAt time.Time, errorCode int, errorMessage string) {
+	var send models.TransactionalSend
+	if err := a.DB.Where("message_id = ? AND callback_url <> ''", message.ID).First(&send).Error; err != nil {
+		return
+	}
+	a.sendTransactionalCallback(&send, message, status, occurredAt, errorCode, errorMessage)
+}
+
+// sendTransactionalCallback delivers a callback in the background with the same retries
+// and signing as outbound webhooks
+func (a *App) sendTransactionalCallback(send *models.TransactionalSend, message *models.Message, status models.MessageStatus, occurredAt time.Time, errorCode int, errorMessage string) {
+	if send.CallbackURL == "" {
+		return
+	}
+
+	data := TransactionalCallbackData{
+		MessageID:         message.ID.String(),
+		WhatsAppMessageID: message.WhatsAppMessageID,
+		Status:            status,
+		OccurredAt:        occurredAt.UTC(),
+	}
+	if send.IdempotencyKey != nil {
+		data.IdempotencyKey = *send.IdempotencyKey
+	}
+	if status == models.MessageStatusFailed {
+		data.ErrorCode = errorCode
+		data.ErrorCategory = errorCategory(errorCode)
+		data.ErrorMessage = errorMessage
+	}
+
+	callback := models.Webhook{
+		BaseModel: models.BaseModel{ID: send.ID},
+		URL:       send.CallbackURL,
+		Secret:    send.CallbackSecret,
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		a.sendWebhook(ctx, callback, "message."+string(status), data)
+	}()
+}
@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_TransactionalSend(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	callbacks := make(chan handlers.OutboundWebhookPayload, 4)
+	signatures := make(chan string, 4)
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload handlers.OutboundWebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		signatures <- r.Header.Get("X-Webhook-Signature")
+		callbacks <- payload
+	}))
+	defer callbackServer.Close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	createTestAccount(t, app, org.ID)
+
+	send := func() (handlers.TransactionalSendResponse, *fasthttp.RequestCtx) {
+		req := testutil.NewJSONRequest(t, map[string]interface{}{
+			"to":              "+1 555 123 4567",
+			"type":            "text",
+			"text":            "Your order has shipped",
+			"callback_url":    callbackServer.URL,
+			"callback_secret": "s3cret",
+		})
+		req.RequestCtx.Request.Header.Set("Idempotency-Key", "order-42-shipped")
+		req.RequestCtx.SetUserValue("organization_id", org.ID)
+		require.NoError(t, app.TransactionalSend(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		var resp struct {
+			Data handlers.TransactionalSendResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data, req.RequestCtx
+	}
+
+	first, _ := send()
+	assert.Equal(t, models.MessageStatusSent, first.Status)
+	assert.Equal(t, "order-42-shipped", first.IdempotencyKey)
+	assert.NotEmpty(t, first.WhatsAppMessageID)
+
+	// A retry with the same key returns the same message without sending again
+	second, ctx := send()
+	assert.Equal(t, first.MessageID, second.MessageID)
+	assert.Equal(t, "true", string(ctx.Response.Header.Peek("Idempotent-Replayed")))
+	assert.Len(t, mockServer.sentMessages, 1)
+
+	// Delivery receipts for the message are posted to its callback URL
+	var msg models.Message
+	require.NoError(t, app.DB.First(&msg, first.MessageID).Error)
+	postStatusWebhook(t, app, &msg, "delivered", time.Now(), 2)
+
+	select {
+	case payload := <-callbacks:
+		assert.Equal(t, "message.delivered", payload.Event)
+		data := payload.Data.(map[string]interface{})
+		assert.Equal(t, first.MessageID.String(), data["message_id"])
+		assert.Equal(t, "order-42-shipped", data["idempotency_key"])
+		assert.Contains(t, <-signatures, "sha256=")
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+}
+
+func TestApp_TransactionalSend_Validation(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	createTestAccount(t, app, org.ID)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+	}{
+		{"missing recipient", map[string]interface{}{"type": "text", "text": "Hi"}},
+		{"unknown type", map[string]interface{}{"to": "15551234567", "type": "image"}},
+		{"missing template", map[string]interface{}{"to": "15551234567", "type": "template"}},
+		{"invalid callback", map[string]interface{}{"to": "15551234567", "type": "text", "text": "Hi", "callback_url": "ftp://example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testutil.NewJSONRequest(t, tt.body)
+			req.RequestCtx.SetUserValue("organization_id", org.ID)
+			require.NoError(t, app.TransactionalSend(req))
+			assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+		})
+	}
+	assert.Empty(t, mockServer.sentMessages)
+}
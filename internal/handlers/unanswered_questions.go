@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/chatnotify"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	unansweredDefaultDays  = 7
+	unansweredMaxDays      = 90
+	unansweredDefaultLimit = 50
+	unansweredDigestItems  = 5
+)
+
+// UnansweredQuestionGroup is one distinct question in the unanswered questions report
+type UnansweredQuestionGroup struct {
+	WhatsAppAccount string    `json:"whatsapp_account"`
+	Normalized      string    `json:"normalized"`
+	Question        string    `json:"question"` // Most recent wording
+	Count           int64     `json:"count"`
+	Contacts        int64     `json:"contacts"`
+	AIFailures      int64     `json:"ai_failures"`
+	LastAskedAt     time.Time `json:"last_asked_at"`
+}
+
+// ResolveUnansweredRequest turns an unanswered question into a keyword rule or AI
+// context, or dismisses it
+type ResolveUnansweredRequest struct {
+	WhatsAppAccount string                      `json:"whatsapp_account"`
+	Normalized      string                      `json:"normalized"`
+	Action          models.UnansweredResolution `json:"action"`
+	Name            string                      `json:"name"`
+	Keywords        []string                    `json:"keywords"`
+	Response        string                      `json:"response"` // Keyword rule reply
+	Content         string                      `json:"content"`  // AI context content
+}
+
+// GetUnansweredQuestions returns the questions the chatbot couldn't answer in the last
+// ?days= (default 7), most frequent first. Resolved questions are left out.
+func (a *App) GetUnansweredQuestions(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceSettingsChatbot, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	days := unansweredDefaultDays
+	if v := string(r.RequestCtx.QueryArgs().Peek("days")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > unansweredMaxDays {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", unansweredMaxDays), nil, "")
+		}
+		days = n
+	}
+	account := string(r.RequestCtx.QueryArgs().Peek("whatsapp_account"))
+
+	since := time.Now().AddDate(0, 0, -days)
+	groups, err := a.unansweredQuestionGroups(orgID, account, since, unansweredDefaultLimit)
+	if err != nil {
+		a.Log.Error("Failed to load unanswered questions", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load unanswered questions", nil, "")
+	}
+	total, contacts := a.unansweredTotals(orgID, account, since)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"questions":      groups,
+		"total_messages": total,
+		"total_contacts": contacts,
+		"days":           days,
+		"since":          since.Format(time.RFC3339),
+	})
+}
+
+// ResolveUnansweredQuestion creates a keyword rule or AI context from a question, or
+// dismisses it, and marks every open occurrence of it resolved
+func (a *App) ResolveUnansweredQuestion(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req ResolveUnansweredRequest
+	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Normalized == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "normalized is required", nil, "")
+	}
+
+	var sample models.UnansweredQuestion
+	if err := a.DB.Where("organization_id = ? AND whats_app_account = ? AND normalized = ? AND resolved_at IS NULL",
+		orgID, req.WhatsAppAccount, req.Normalized).
+		Order("created_at DESC").First(&sample).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Unanswered question not found", nil, "")
+	}
+
+	keywords := make([]string, 0, len(req.Keywords))
+	for _, k := range req.Keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			keywords = append(keywords, k)
+		}
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = truncateRunes(sample.Question, 100)
+	}
+
+	var createdID string
+	switch req.Action {
+	case models.UnansweredResolvedKeywordRule:
+		if !a.HasPermission(userID, orgID, models.ResourceChatbotKeywords, models.ActionWrite) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		}
+		if strings.TrimSpace(req.Response) == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "response is required", nil, "")
+		}
+		if len(keywords) == 0 {
+			keywords = []string{req.Normalized}
+		}
+		rule := models.KeywordRule{
+			BaseModel:       models.BaseModel{ID: uuid.New()},
+			OrganizationID:  orgID,
+			WhatsAppAccount: req.WhatsAppAccount,
+			Name:            name,
+			Keywords:        keywords,
+			MatchType:       models.MatchTypeContains,
+			ResponseType:    models.ResponseTypeText,
+			ResponseContent: models.JSONB{"body": req.Response},
+			Priority:        10,
+			IsEnabled:       true,
+		}
+		if err := a.DB.Create(&rule).Error; err != nil {
+			a.Log.Error("Failed to create keyword rule from unanswered question", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create keyword rule", nil, "")
+		}
+		a.InvalidateKeywordRulesCache(orgID)
+		createdID = rule.ID.String()
+
+	case models.UnansweredResolvedAIContext:
+		if !a.HasPermission(userID, orgID, models.ResourceChatbotAI, models.ActionWrite) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		}
+		if strings.TrimSpace(req.Content) == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "content is required", nil, "")
+		}
+		ctx := models.AIContext{
+			BaseModel:       models.BaseModel{ID: uuid.New()},
+			OrganizationID:  orgID,
+			WhatsAppAccount: req.WhatsAppAccount,
+			Name:            name,
+			ContextType:     models.ContextTypeStatic,
+			TriggerKeywords: keywords,
+			StaticContent:   req.Content,
+			Priority:        10,
+			IsEnabled:       true,
+		}
+		if err := a.DB.Create(&ctx).Error; err != nil {
+			a.Log.Error("Failed to create AI context from unanswered question", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create AI context", nil, "")
+		}
+		a.InvalidateAIContextsCache(orgID)
+		createdID = ctx.ID.String()
+
+	case models.UnansweredResolvedDismissed:
+		if !a.HasPermission(userID, orgID, models.ResourceSettingsChatbot, models.ActionWrite) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		}
+
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "action must be keyword_rule, ai_context or dismissed", nil, "")
+	}
+
+	result := a.DB.Model(&models.UnansweredQuestion{}).
+		Where("organization_id = ? AND whats_app_account = ? AND normalized = ? AND resolved_at IS NULL",
+			orgID, req.WhatsAppAccount, req.Normalized).
+		Updates(map[string]interface{}{
+			"resolution":  req.Action,
+			"resolved_at": time.Now(),
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to resolve unanswered question", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to resolve unanswered question", nil, "")
+	}
+
+	response := map[string]interface{}{
+		"message":  "Unanswered question resolved",
+		"resolved": result.RowsAffected,
+	}
+	if createdID != "" {
+		response["id"] = createdID
+	}
+	return r.SendEnvelope(response)
+}
+
+// recordUnansweredQuestion stores a message the chatbot had no answer for
+func (a *App) recordUnansweredQuestion(account *models.WhatsAppAccount, contact *models.Contact, session *models.ChatbotSession, messageText string, reason models.UnansweredReason) {
+	normalized := normalizeQuestion(messageText)
+	if normalized == "" {
+		return
+	}
+	q := models.UnansweredQuestion{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  account.OrganizationID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		SessionID:       session.ID,
+		Question:        messageText,
+		Normalized:      normalized,
+		Reason:          reason,
+	}
+	if err := a.DB.Create(&q).Error; err != nil {
+		a.Log.Error("Failed to record unanswered question", "error", err, "contact_id", contact.ID)
+	}
+}
+
+// unansweredQuestionGroups groups open unanswered questions asked since the given time
+func (a *App) unansweredQuestionGroups(orgID uuid.UUID, account string, since time.Time, limit int) ([]UnansweredQuestionGroup, error) {
+	query := a.DB.Model(&models.UnansweredQuestion{}).
+		Select(`whats_app_account, normalized,
+			(array_agg(question ORDER BY created_at DESC))[1] as question,
+			COUNT(*) as count,
+			COUNT(DISTINCT contact_id) as contacts,
+			COUNT(*) FILTER (WHERE reason = ?) as ai_failures,
+			MAX(created_at) as last_asked_at`, models.UnansweredReasonAIFailed).
+		Where("organization_id = ? AND resolved_at IS NULL AND created_at >= ?", orgID, since)
+	if account != "" {
+		query = query.Where("whats_app_account = ?", account)
+	}
+
+	groups := []UnansweredQuestionGroup{}
+	err := query.Group("whats_app_account, normalized").
+		Order("count DESC, last_asked_at DESC").
+		Limit(limit).
+		Scan(&groups).Error
+	return groups, err
+}
+
+// unansweredTotals counts open unanswered messages and the contacts who sent them
+func (a *App) unansweredTotals(orgID uuid.UUID, account string, since time.Time) (messages, contacts int64) {
+	var totals struct {
+		Messages int64
+		Contacts int64
+	}
+	query := a.DB.Model(&models.UnansweredQuestion{}).
+		Select("COUNT(*) as messages, COUNT(DISTINCT contact_id) as contacts").
+		Where("organization_id = ? AND resolved_at IS NULL AND created_at >= ?", orgID, since)
+	if account != "" {
+		query = query.Where("whats_app_account = ?", account)
+	}
+	query.Scan(&totals)
+	return totals.Messages, totals.Contacts
+}
+
+// normalizeQuestion lowercases a message, drops punctuation and collapses whitespace so
+// rewordings that differ only in case or punctuation are counted together
+func normalizeQuestion(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return truncateRunes(b.String(), 255)
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n]))
+}
+
+// sendUnansweredDigests posts last week's unanswered questions to subscribed channels.
+// Digests go out on Mondays (UTC); each organization gets one per week.
+func (a *App) sendUnansweredDigests(now time.Time) {
+	now = now.UTC()
+	if now.Weekday() != time.Monday {
+		return
+	}
+	since := now.AddDate(0, 0, -7)
+
+	var orgIDs []uuid.UUID
+	if err := a.DB.Model(&models.UnansweredQuestion{}).
+		Where("resolved_at IS NULL AND created_at >= ?", since).
+		Distinct().Pluck("organization_id", &orgIDs).Error; err != nil {
+		a.Log.Error("Failed to load organizations for unanswered digest", "error", err)
+		return
+	}
+
+	year, week := now.ISOWeek()
+	for _, orgID := range orgIDs {
+		orgID := orgID
+		a.NotifyChannels(orgID, models.NotificationEventUnansweredDigest, func() (chatnotify.Message, bool) {
+			if !a.notifyOnce(fmt.Sprintf("unanswered_digest:%s:%d-%d", orgID, year, week)) {
+				return chatnotify.Message{}, false
+			}
+			groups, err := a.unansweredQuestionGroups(orgID, "", since, unansweredDigestItems)
+			if err != nil || len(groups) == 0 {
+				return chatnotify.Message{}, false
+			}
+			messages, contacts := a.unansweredTotals(orgID, "", since)
+
+			fields := make([]chatnotify.Field, len(groups))
+			for i, g := range groups {
+				fields[i] = chatnotify.Field{
+					Name:  truncateRunes(g.Question, 80),
+					Value: fmt.Sprintf("%d times, %d contacts", g.Count, g.Contacts),
+				}
+			}
+			return chatnotify.Message{
+				Title:    "Unanswered chatbot questions this week",
+				Text:     fmt.Sprintf("The chatbot couldn't answer %d messages from %d contacts in the last 7 days. The most frequent:", messages, contacts),
+				Fields:   fields,
+				URL:      a.appLink("/chatbot/unanswered"),
+				Severity: chatnotify.SeverityInfo,
+			}, true
+		})
+	}
+}
+
+// UnansweredDigestProcessor posts the weekly unanswered questions digest
+type UnansweredDigestProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewUnansweredDigestProcessor creates a new unanswered digest processor
+func NewUnansweredDigestProcessor(app *App, interval time.Duration) *UnansweredDigestProcessor {
+	return &UnansweredDigestProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the digest loop
+func (p *UnansweredDigestProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Unanswered digest processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Unanswered digest processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Unanswered digest processor stopped")
+			return
+		case <-ticker.C:
+			p.app.sendUnansweredDigests(time.Now())
+		}
+	}
+}
+
+// Stop stops the unanswered digest processor
+func (p *UnansweredDigestProcessor) Stop() {
+	close(p.stopCh)
+}
@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestApp_UnansweredQuestions(t *testing.T) {
+	app := agentTransfersTestApp(t)
+	org := createTransferTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	account := createTransferTestAccount(t, app, org.ID)
+	first := createTestContact(t, app, org.ID)
+	second := createTestContact(t, app, org.ID)
+
+	record := func(contactID uuid.UUID, question, normalized string, reason models.UnansweredReason, age time.Duration) {
+		q := models.UnansweredQuestion{
+			BaseModel:       models.BaseModel{ID: uuid.New(), CreatedAt: time.Now().Add(-age)},
+			OrganizationID:  org.ID,
+			WhatsAppAccount: account.Name,
+			ContactID:       contactID,
+			Question:        question,
+			Normalized:      normalized,
+			Reason:          reason,
+		}
+		require.NoError(t, app.DB.Create(&q).Error)
+	}
+	record(first.ID, "Do you deliver on Sundays?", "do you deliver on sundays", models.UnansweredReasonNoMatch, time.Hour)
+	record(second.ID, "do you deliver on sundays", "do you deliver on sundays", models.UnansweredReasonAIFailed, 2*time.Hour)
+	record(first.ID, "Do you deliver on Sundays??", "do you deliver on sundays", models.UnansweredReasonNoMatch, 3*time.Hour)
+	record(first.ID, "Where is the store?", "where is the store", models.UnansweredReasonNoMatch, time.Hour)
+	record(first.ID, "Old question", "old question", models.UnansweredReasonNoMatch, 10*24*time.Hour)
+
+	type report struct {
+		Questions     []handlers.UnansweredQuestionGroup `json:"questions"`
+		TotalMessages int64                              `json:"total_messages"`
+		TotalContacts int64                              `json:"total_contacts"`
+	}
+	load := func() report {
+		req := testutil.NewGETRequest(t)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		require.NoError(t, app.GetUnansweredQuestions(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+		var result struct {
+			Data report `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &result)
+		return result.Data
+	}
+
+	data := load()
+	require.Len(t, data.Questions, 2)
+	top := data.Questions[0]
+	assert.Equal(t, "do you deliver on sundays", top.Normalized)
+	assert.Equal(t, "Do you deliver on Sundays?", top.Question)
+	assert.Equal(t, int64(3), top.Count)
+	assert.Equal(t, int64(2), top.Contacts)
+	assert.Equal(t, int64(1), top.AIFailures)
+	assert.Equal(t, int64(4), data.TotalMessages)
+	assert.Equal(t, int64(2), data.TotalContacts)
+
+	// A keyword rule needs a reply
+	req := testutil.NewJSONRequest(t, map[string]any{
+		"whatsapp_account": account.Name,
+		"normalized":       top.Normalized,
+		"action":           "keyword_rule",
+	})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.ResolveUnansweredQuestion(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewJSONRequest(t, map[string]any{
+		"whatsapp_account": account.Name,
+		"normalized":       top.Normalized,
+		"action":           "keyword_rule",
+		"keywords":         []string{"sunday"},
+		"response":         "We deliver every day, Sundays included.",
+	})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.ResolveUnansweredQuestion(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var rule models.KeywordRule
+	require.NoError(t, app.DB.Where("organization_id = ?", org.ID).First(&rule).Error)
+	assert.Equal(t, account.Name, rule.WhatsAppAccount)
+	assert.Equal(t, models.StringArray{"sunday"}, rule.Keywords)
+	assert.Equal(t, "We deliver every day, Sundays included.", rule.ResponseContent["body"])
+
+	var resolved int64
+	app.DB.Model(&models.UnansweredQuestion{}).
+		Where("normalized = ? AND resolution = ?", top.Normalized, models.UnansweredResolvedKeywordRule).
+		Count(&resolved)
+	assert.Equal(t, int64(3), resolved)
+
+	data = load()
+	require.Len(t, data.Questions, 1)
+	assert.Equal(t, "where is the store", data.Questions[0].Normalized)
+
+	// Dismissing leaves the report empty
+	req = testutil.NewJSONRequest(t, map[string]any{
+		"whatsapp_account": account.Name,
+		"normalized":       "where is the store",
+		"action":           "dismissed",
+	})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.ResolveUnansweredQuestion(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Empty(t, load().Questions)
+}
@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxUserImportSize caps the size of a user import CSV
+	maxUserImportSize = 1 << 20
+	// maxUserImportRows caps the users created by one import
+	maxUserImportRows = 500
+	// maxBulkTeamMembers caps the users and teams in one bulk team-membership change
+	maxBulkTeamMembers = 500
+)
+
+// User import columns. Headers are matched ignoring case; full_name is accepted for name.
+const (
+	UserImportColumnEmail    = "email"
+	UserImportColumnName     = "name"
+	UserImportColumnRole     = "role"      // Role name; the organization's default role if empty
+	UserImportColumnTeam     = "team"      // Team names, separated by ; or ,
+	UserImportColumnTeamRole = "team_role" // manager or agent (default)
+)
+
+// User import row statuses
+const (
+	UserImportStatusCreated  = "created"  // A new user, who is sent an invitation
+	UserImportStatusExisting = "existing" // Already in the organization; only teams are added
+)
+
+// ImportedUserRow is a user import row that was, or in a dry run would be, applied
+type ImportedUserRow struct {
+	Row      int        `json:"row"`
+	UserID   *uuid.UUID `json:"user_id,omitempty"` // Unset for new users in a dry run
+	Email    string     `json:"email"`
+	FullName string     `json:"full_name"`
+	Role     string     `json:"role,omitempty"`
+	Teams    []string   `json:"teams"`
+	Status   string     `json:"status"` // created, existing
+}
+
+// RejectedUserRow is a user import row that couldn't be applied
+type RejectedUserRow struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+// UserImportResponse is the result of a user import
+type UserImportResponse struct {
+	DryRun     bool              `json:"dry_run"`
+	Created    int               `json:"created"`
+	Existing   int               `json:"existing"`
+	TeamsAdded int               `json:"teams_added"` // New team memberships
+	Users      []ImportedUserRow `json:"users"`
+	Rejected   []RejectedUserRow `json:"rejected"`
+}
+
+// BulkTeamMembersRequest adds or removes several users to or from several teams
+type BulkTeamMembersRequest struct {
+	Action  string          `json:"action"` // add, remove
+	UserIDs []uuid.UUID     `json:"user_ids"`
+	TeamIDs []uuid.UUID     `json:"team_ids"`
+	Role    models.TeamRole `json:"role"` // For add: manager or agent (default)
+}
+
+// BulkTeamMembersResponse counts the memberships a bulk change touched
+type BulkTeamMembersResponse struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Skipped int `json:"skipped"` // Already a member when adding, or not one when removing
+}
+
+// userImportRow is a parsed user import row
+type userImportRow struct {
+	Row      int
+	Email    string
+	FullName string
+	RoleName string
+	Teams    []string
+	TeamRole models.TeamRole
+}
+
+// ImportUsers creates users from an uploaded CSV file with email, name, role and team
+// columns and adds them to teams. New users get an invitation email with a link to set
+// their password. Users already in the organization are only added to the listed teams,
+// so a file can be uploaded again after fixing rejected rows. Rows are checked before
+// anything is saved; a dry_run form field of true returns the result without saving.
+func (a *App) ImportUsers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceUsers, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	fileHeader, err := r.RequestCtx.FormFile("file")
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No file provided", nil, "")
+	}
+	if fileHeader.Size > maxUserImportSize {
+		return r.SendErrorEnvelope(fasthttp.StatusRequestEntityTooLarge, "CSV file is too large", nil, "")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to open uploaded file", nil, "")
+	}
+	defer func() { _ = file.Close() }()
+
+	rows, rejected, err := parseUserImport(file)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	// Team changes need the same permission as on the teams page
+	for _, row := range rows {
+		if len(row.Teams) > 0 && !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions to assign teams", nil, "")
+		}
+	}
+
+	resp := UserImportResponse{
+		DryRun:   string(r.RequestCtx.FormValue("dry_run")) == "true",
+		Users:    []ImportedUserRow{},
+		Rejected: rejected,
+	}
+	plan, err := a.planUserImport(orgID, rows, &resp)
+	if err != nil {
+		a.Log.Error("Failed to check user import", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to import users", nil, "")
+	}
+	if resp.DryRun {
+		return r.SendEnvelope(resp)
+	}
+
+	created, err := a.applyUserImport(orgID, plan, &resp)
+	if err != nil {
+		a.Log.Error("Failed to import users", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to import users", nil, "")
+	}
+
+	for _, user := range created {
+		a.sendInvitationEmail(r, user, userID, a.passwordSetupURL(r, user.ID))
+	}
+
+	a.Log.Info("Users imported", "organization_id", orgID, "created", resp.Created, "existing", resp.Existing, "rejected", len(resp.Rejected))
+	return r.SendEnvelope(resp)
+}
+
+// parseUserImport reads the CSV rows, rejecting rows with a missing or invalid email or
+// name. It fails when the header lacks a required column or the file is too long.
+func parseUserImport(file io.Reader) ([]userImportRow, []RejectedUserRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("Invalid CSV file")
+	}
+	columns := make(map[string]int, len(header))
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "full_name" {
+			h = UserImportColumnName
+		}
+		if _, ok := columns[h]; !ok {
+			columns[h] = i
+		}
+	}
+	for _, required := range []string{UserImportColumnEmail, UserImportColumnName} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV is missing the %s column", required)
+		}
+	}
+
+	rows := []userImportRow{}
+	rejected := []RejectedUserRow{}
+	seen := make(map[string]bool)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid CSV on line %d", line)
+		}
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := userImportRow{
+			Row:      line,
+			Email:    strings.ToLower(value(UserImportColumnEmail)),
+			FullName: value(UserImportColumnName),
+			RoleName: value(UserImportColumnRole),
+			TeamRole: models.TeamRole(strings.ToLower(value(UserImportColumnTeamRole))),
+		}
+		if row.Email == "" && row.FullName == "" {
+			continue // Blank line
+		}
+		for _, team := range strings.FieldsFunc(value(UserImportColumnTeam), func(c rune) bool { return c == ';' || c == ',' }) {
+			if team = strings.TrimSpace(team); team != "" {
+				row.Teams = append(row.Teams, team)
+			}
+		}
+		if row.TeamRole == "" {
+			row.TeamRole = models.TeamRoleAgent
+		}
+
+		reject := func(reason string) {
+			rejected = append(rejected, RejectedUserRow{Row: line, Email: row.Email, Reason: reason})
+		}
+		switch {
+		case row.Email == "":
+			reject("Email is required")
+		case !validImportEmail(row.Email):
+			reject("Invalid email")
+		case row.FullName == "":
+			reject("Name is required")
+		case seen[row.Email]:
+			reject("Email appears more than once in the file")
+		case row.TeamRole != models.TeamRoleAgent && row.TeamRole != models.TeamRoleManager:
+			reject("team_role must be manager or agent")
+		default:
+			seen[row.Email] = true
+			rows = append(rows, row)
+		}
+		if len(rows)+len(rejected) > maxUserImportRows {
+			return nil, nil, fmt.Errorf("At most %d users can be imported at once", maxUserImportRows)
+		}
+	}
+	if len(rows)+len(rejected) == 0 {
+		return nil, nil, errors.New("CSV has no users")
+	}
+	return rows, rejected, nil
+}
+
+// validImportEmail accepts a bare address such as ada@example.com
+func validImportEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email && strings.Contains(email[strings.LastIndex(email, "@"):], ".")
+}
+
+// userImportPlan is what an import will save
+type userImportPlan struct {
+	rows     []userImportRow
+	existing map[string]models.User           // By email
+	roles    map[string]*uuid.UUID            // Role ID by row email
+	teams    map[string][]models.Team         // Teams by row email
+	members  map[uuid.UUID]map[uuid.UUID]bool // Existing memberships by team, then user
+}
+
+// planUserImport resolves roles, teams and existing users for the parsed rows, moving
+// rows that can't be applied to resp.Rejected and listing the rest in resp.Users
+func (a *App) planUserImport(orgID uuid.UUID, rows []userImportRow, resp *UserImportResponse) (*userImportPlan, error) {
+	var roles []models.CustomRole
+	if err := a.DB.Where("organization_id = ?", orgID).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	rolesByName := make(map[string]models.CustomRole, len(roles))
+	var defaultRole *models.CustomRole
+	for i, role := range roles {
+		rolesByName[strings.ToLower(role.Name)] = role
+		if role.IsDefault {
+			defaultRole = &roles[i]
+		}
+	}
+
+	var teams []models.Team
+	if err := a.DB.Where("organization_id = ?", orgID).Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	teamsByName := make(map[string]models.Team, len(teams))
+	teamIDs := make([]uuid.UUID, len(teams))
+	for i, team := range teams {
+		teamsByName[strings.ToLower(team.Name)] = team
+		teamIDs[i] = team.ID
+	}
+
+	emails := make([]string, len(rows))
+	for i, row := range rows {
+		emails[i] = row.Email
+	}
+	var users []models.User
+	if err := a.DB.Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	plan := &userImportPlan{
+		existing: make(map[string]models.User, len(users)),
+		roles:    make(map[string]*uuid.UUID, len(rows)),
+		teams:    make(map[string][]models.Team, len(rows)),
+		members:  make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	for _, user := range users {
+		plan.existing[strings.ToLower(user.Email)] = user
+	}
+
+	var members []models.TeamMember
+	if len(teamIDs) > 0 {
+		if err := a.DB.Where("team_id IN ?", teamIDs).Find(&members).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range members {
+		if plan.members[m.TeamID] == nil {
+			plan.members[m.TeamID] = make(map[uuid.UUID]bool)
+		}
+		plan.members[m.TeamID][m.UserID] = true
+	}
+
+	for _, row := range rows {
+		reject := func(reason string) {
+			resp.Rejected = append(resp.Rejected, RejectedUserRow{Row: row.Row, Email: row.Email, Reason: reason})
+		}
+
+		user, exists := plan.existing[row.Email]
+		if exists && user.OrganizationID != orgID {
+			reject("Email belongs to a user in another organization; add them under organization members")
+			continue
+		}
+
+		imported := ImportedUserRow{Row: row.Row, Email: row.Email, FullName: row.FullName, Teams: []string{}}
+		if exists {
+			imported.UserID = &user.ID
+			imported.FullName = user.FullName
+			imported.Status = UserImportStatusExisting
+		} else {
+			imported.Status = UserImportStatusCreated
+			switch role, ok := rolesByName[strings.ToLower(row.RoleName)]; {
+			case row.RoleName == "" && defaultRole != nil:
+				plan.roles[row.Email] = &defaultRole.ID
+				imported.Role = defaultRole.Name
+			case row.RoleName == "":
+			case !ok:
+				reject(fmt.Sprintf("Unknown role %q", row.RoleName))
+				continue
+			default:
+				plan.roles[row.Email] = &role.ID
+				imported.Role = role.Name
+			}
+		}
+
+		var rowTeams []models.Team
+		unknown := ""
+		for _, name := range row.Teams {
+			team, ok := teamsByName[strings.ToLower(name)]
+			if !ok {
+				unknown = name
+				break
+			}
+			rowTeams = append(rowTeams, team)
+		}
+		if unknown != "" {
+			reject(fmt.Sprintf("Unknown team %q", unknown))
+			continue
+		}
+		for _, team := range rowTeams {
+			imported.Teams = append(imported.Teams, team.Name)
+			if !exists || !plan.members[team.ID][user.ID] {
+				resp.TeamsAdded++
+			}
+		}
+		plan.teams[row.Email] = rowTeams
+
+		if exists {
+			resp.Existing++
+		} else {
+			resp.Created++
+		}
+		plan.rows = append(plan.rows, row)
+		resp.Users = append(resp.Users, imported)
+	}
+	return plan, nil
+}
+
+// applyUserImport saves the planned users and team memberships in one transaction,
+// returning the users it created
+func (a *App) applyUserImport(orgID uuid.UUID, plan *userImportPlan, resp *UserImportResponse) ([]models.User, error) {
+	var created []models.User
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		for i, row := range plan.rows {
+			user, exists := plan.existing[row.Email]
+			if !exists {
+				passwordHash, err := unusablePasswordHash()
+				if err != nil {
+					return err
+				}
+				user = models.User{
+					OrganizationID: orgID,
+					Email:          row.Email,
+					PasswordHash:   passwordHash,
+					FullName:       row.FullName,
+					RoleID:         plan.roles[row.Email],
+					IsActive:       true,
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				created = append(created, user)
+				resp.Users[i].UserID = &user.ID
+			}
+
+			for _, team := range plan.teams[row.Email] {
+				if plan.members[team.ID][user.ID] {
+					continue
+				}
+				if err := tx.Create(&models.TeamMember{TeamID: team.ID, UserID: user.ID, Role: row.TeamRole}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// unusablePasswordHash hashes a random password nobody knows, so imported users sign in
+// only after setting their own
+func unusablePasswordHash() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// BulkUpdateTeamMembers adds several users to several teams, or removes them, in one
+// request
+func (a *App) BulkUpdateTeamMembers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceTeams, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	var req BulkTeamMembersRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Action != "add" && req.Action != "remove" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "action must be add or remove", nil, "")
+	}
+	if len(req.UserIDs) == 0 || len(req.TeamIDs) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "user_ids and team_ids are required", nil, "")
+	}
+	if len(req.UserIDs) > maxBulkTeamMembers || len(req.TeamIDs) > maxBulkTeamMembers {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("At most %d users and %d teams can be changed at once", maxBulkTeamMembers, maxBulkTeamMembers), nil, "")
+	}
+	if req.Role == "" {
+		req.Role = models.TeamRoleAgent
+	}
+	if req.Role != models.TeamRoleManager && req.Role != models.TeamRoleAgent {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid role. Must be 'manager' or 'agent'", nil, "")
+	}
+
+	userIDs, teamIDs := uniqueUUIDs(req.UserIDs), uniqueUUIDs(req.TeamIDs)
+	var count int64
+	a.DB.Model(&models.Team{}).Where("id IN ? AND organization_id = ?", teamIDs, orgID).Count(&count)
+	if int(count) != len(teamIDs) {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Team not found", nil, "")
+	}
+	a.DB.Model(&models.User{}).Where("id IN ? AND organization_id = ?", userIDs, orgID).Count(&count)
+	if int(count) != len(userIDs) {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "User not found", nil, "")
+	}
+
+	var resp BulkTeamMembersResponse
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if req.Action == "remove" {
+			result := tx.Where("team_id IN ? AND user_id IN ?", teamIDs, userIDs).Delete(&models.TeamMember{})
+			resp.Removed = int(result.RowsAffected)
+			resp.Skipped = len(teamIDs)*len(userIDs) - resp.Removed
+			return result.Error
+		}
+
+		var existing []models.TeamMember
+		if err := tx.Where("team_id IN ? AND user_id IN ?", teamIDs, userIDs).Find(&existing).Error; err != nil {
+			return err
+		}
+		isMember := make(map[[2]uuid.UUID]bool, len(existing))
+		for _, m := range existing {
+			isMember[[2]uuid.UUID{m.TeamID, m.UserID}] = true
+		}
+		var members []models.TeamMember
+		for _, teamID := range teamIDs {
+			for _, memberID := range userIDs {
+				if isMember[[2]uuid.UUID{teamID, memberID}] {
+					resp.Skipped++
+					continue
+				}
+				members = append(members, models.TeamMember{TeamID: teamID, UserID: memberID, Role: req.Role})
+			}
+		}
+		resp.Added = len(members)
+		if len(members) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&members, 500).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to update team members", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update team members", nil, "")
+	}
+
+	return r.SendEnvelope(resp)
+}
+
+// uniqueUUIDs drops repeated IDs, keeping the first occurrence
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
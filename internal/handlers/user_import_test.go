@@ -0,0 +1,186 @@
+package handlers_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// newUserImportRequest builds a multipart user import request
+func newUserImportRequest(t *testing.T, csv string, dryRun bool) *fastglue.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if dryRun {
+		require.NoError(t, w.WriteField("dry_run", "true"))
+	}
+	part, err := w.CreateFormFile("file", "users.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(csv))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req := testutil.NewRequest(t)
+	req.RequestCtx.Request.Header.SetMethod("POST")
+	req.RequestCtx.Request.Header.SetContentType(w.FormDataContentType())
+	req.RequestCtx.Request.SetBody(body.Bytes())
+	return req
+}
+
+func TestApp_ImportUsers(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	agentRole := createTransferTestRole(t, app.DB, org.ID, "Agent", nil)
+	support := createTestTeam(t, app, org.ID)
+	sales := createTestTeam(t, app, org.ID, admin.ID)
+	other := createTestUser(t, app, createTestOrg(t, app).ID, uniqueEmail("import-other"), "password", nil, true)
+
+	ada, bob := uniqueEmail("import-ada"), uniqueEmail("import-bob")
+	csv := fmt.Sprintf("\ufeffEmail,Full_Name,Role,Team,Team_Role\n"+
+		"%s,Ada,%s,%s;%s,\n"+
+		"%s,Bob,,%s,manager\n"+
+		"%s,Admin,,%s,\n"+
+		"%s,Other,,,\n"+
+		"not-an-email,Bad,,,\n"+
+		"%s,Carol,Unknown,,\n"+
+		"%s,Dan,,Nowhere,\n"+
+		"%s,Ada again,,,\n",
+		ada, strings.ToUpper(agentRole.Name), support.Name, sales.Name,
+		bob, support.Name,
+		admin.Email, sales.Name,
+		other.Email,
+		uniqueEmail("import-carol"),
+		uniqueEmail("import-dan"),
+		ada)
+
+	run := func(dryRun bool) handlers.UserImportResponse {
+		req := newUserImportRequest(t, csv, dryRun)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		require.NoError(t, app.ImportUsers(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req), string(testutil.GetResponseBody(req)))
+		var resp struct {
+			Data handlers.UserImportResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data
+	}
+
+	preview := run(true)
+	assert.True(t, preview.DryRun)
+	assert.Equal(t, 2, preview.Created)
+	assert.Equal(t, 1, preview.Existing)
+	assert.Equal(t, 3, preview.TeamsAdded, "admin is already in sales")
+	reasons := map[int]string{}
+	for _, rej := range preview.Rejected {
+		reasons[rej.Row] = rej.Reason
+	}
+	assert.Equal(t, map[int]string{
+		5: "Email belongs to a user in another organization; add them under organization members",
+		6: "Invalid email",
+		7: `Unknown role "Unknown"`,
+		8: `Unknown team "Nowhere"`,
+		9: "Email appears more than once in the file",
+	}, reasons)
+	var count int64
+	app.DB.Model(&models.User{}).Where("email = ?", ada).Count(&count)
+	assert.Zero(t, count, "a dry run saves nothing")
+
+	result := run(false)
+	assert.Equal(t, 2, result.Created)
+	require.Len(t, result.Users, 3)
+	assert.Equal(t, handlers.UserImportStatusCreated, result.Users[0].Status)
+	assert.Equal(t, agentRole.Name, result.Users[0].Role)
+	assert.NotNil(t, result.Users[0].UserID)
+	assert.Equal(t, handlers.UserImportStatusExisting, result.Users[2].Status)
+
+	var created models.User
+	require.NoError(t, app.DB.Where("email = ?", ada).First(&created).Error)
+	assert.Equal(t, org.ID, created.OrganizationID)
+	assert.NotEmpty(t, created.PasswordHash)
+
+	var members []models.TeamMember
+	require.NoError(t, app.DB.Where("team_id IN ?", []uuid.UUID{support.ID, sales.ID}).Find(&members).Error)
+	assert.Len(t, members, 4)
+	for _, m := range members {
+		if m.TeamID == support.ID && m.UserID != created.ID {
+			assert.Equal(t, models.TeamRoleManager, m.Role)
+		}
+	}
+
+	// Uploading the file again changes nothing
+	again := run(false)
+	assert.Equal(t, 0, again.Created)
+	assert.Equal(t, 3, again.Existing)
+	assert.Equal(t, 0, again.TeamsAdded)
+}
+
+func TestApp_ImportUsers_MissingColumn(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+
+	req := newUserImportRequest(t, "email,team\nada@example.com,Support\n", false)
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.ImportUsers(req))
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusBadRequest, "CSV is missing the name column")
+}
+
+func TestApp_BulkUpdateTeamMembers(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	agent := createTransferTestUser(t, app, org.ID, &createTransferTestRole(t, app.DB, org.ID, "agent", nil).ID)
+	first := createTestTeam(t, app, org.ID, agent.ID)
+	second := createTestTeam(t, app, org.ID)
+
+	update := func(userID uuid.UUID, body map[string]any) (int, handlers.BulkTeamMembersResponse) {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, userID)
+		require.NoError(t, app.BulkUpdateTeamMembers(req))
+		var resp struct {
+			Data handlers.BulkTeamMembersResponse `json:"data"`
+		}
+		if testutil.GetResponseStatusCode(req) == fasthttp.StatusOK {
+			testutil.ParseJSONResponse(t, req, &resp)
+		}
+		return testutil.GetResponseStatusCode(req), resp.Data
+	}
+	body := map[string]any{
+		"action":   "add",
+		"user_ids": []uuid.UUID{admin.ID, agent.ID},
+		"team_ids": []uuid.UUID{first.ID, second.ID},
+	}
+
+	status, _ := update(agent.ID, body)
+	assert.Equal(t, fasthttp.StatusForbidden, status)
+
+	status, resp := update(admin.ID, body)
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, 3, resp.Added)
+	assert.Equal(t, 1, resp.Skipped)
+
+	body["action"] = "remove"
+	body["team_ids"] = []uuid.UUID{second.ID}
+	status, resp = update(admin.ID, body)
+	require.Equal(t, fasthttp.StatusOK, status)
+	assert.Equal(t, 2, resp.Removed)
+
+	var count int64
+	app.DB.Model(&models.TeamMember{}).Where("team_id = ?", first.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	body["team_ids"] = []uuid.UUID{uuid.New()}
+	status, _ = update(admin.ID, body)
+	assert.Equal(t, fasthttp.StatusNotFound, status)
+}
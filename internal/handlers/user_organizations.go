@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// SwitchOrganizationRequest represents the request body for switching organizations
+type SwitchOrganizationRequest struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// OrganizationMemberRequest represents the request body for inviting a member who
+// belongs to several organizations, or changing their role
+type OrganizationMemberRequest struct {
+	Email  string     `json:"email"`
+	RoleID *uuid.UUID `json:"role_id"`
+}
+
+// UserOrganizationResponse is an organization the current user can switch to
+type UserOrganizationResponse struct {
+	OrganizationID   uuid.UUID  `json:"organization_id"`
+	OrganizationName string     `json:"organization_name"`
+	RoleID           *uuid.UUID `json:"role_id,omitempty"`
+	RoleName         string     `json:"role_name,omitempty"`
+	IsCurrent        bool       `json:"is_current"`
+}
+
+// OrganizationMemberResponse is a user with access to the organization
+type OrganizationMemberResponse struct {
+	UserID   uuid.UUID  `json:"user_id"`
+	Email    string     `json:"email"`
+	FullName string     `json:"full_name"`
+	RoleID   *uuid.UUID `json:"role_id,omitempty"`
+	RoleName string     `json:"role_name,omitempty"`
+}
+
+// ListMyOrganizations returns the organizations the current user can switch to
+func (a *App) ListMyOrganizations(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	currentOrgID, _ := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+
+	var user models.User
+	if err := a.DB.Preload("Organization").Preload("Role").Where("id = ?", userID).First(&user).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "User not found", nil, "")
+	}
+
+	var memberships []models.UserOrganization
+	if err := a.DB.Preload("Organization").Preload("Role").
+		Where("user_id = ? AND organization_id <> ?", userID, user.OrganizationID).
+		Order("created_at ASC").
+		Find(&memberships).Error; err != nil {
+		a.Log.Error("Failed to list user organizations", "error", err, "user_id", userID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list organizations", nil, "")
+	}
+
+	// The user's own organization comes first, then the ones they are a member of
+	own := UserOrganizationResponse{
+		OrganizationID: user.OrganizationID,
+		RoleID:         user.RoleID,
+		IsCurrent:      user.OrganizationID == currentOrgID,
+	}
+	if user.Organization != nil {
+		own.OrganizationName = user.Organization.Name
+	}
+	if user.Role != nil {
+		own.RoleName = user.Role.Name
+	}
+	organizations := []UserOrganizationResponse{own}
+	for _, m := range memberships {
+		if m.Organization == nil {
+			continue
+		}
+		org := UserOrganizationResponse{
+			OrganizationID:   m.OrganizationID,
+			OrganizationName: m.Organization.Name,
+			RoleID:           m.RoleID,
+			IsCurrent:        m.OrganizationID == currentOrgID,
+		}
+		if m.Role != nil {
+			org.RoleName = m.Role.Name
+		}
+		organizations = append(organizations, org)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"organizations": organizations,
+	})
+}
+
+// SwitchOrganization returns tokens scoped to another organization the current user is a
+// member of, carrying their role there. The user itself is unchanged, so sessions and API
+// keys for their other organizations keep working.
+func (a *App) SwitchOrganization(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req SwitchOrganizationRequest
+	if err := r.Decode(&req, "json"); err != nil || req.OrganizationID == uuid.Nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "organization_id is required", nil, "")
+	}
+
+	var user models.User
+	if err := a.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "User not found", nil, "")
+	}
+	if !user.IsActive {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Account is disabled", nil, "")
+	}
+
+	member, ok := a.userInOrganization(user, req.OrganizationID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "You are not a member of this organization", nil, "")
+	}
+	if a.isSuspendedUser(&member) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "This organization has been suspended", nil, "")
+	}
+	if !a.checkAdminLoginIP(&member, middleware.ClientIP(r)) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access from this IP address is not allowed", nil, "")
+	}
+
+	if member.RoleID != nil {
+		var role models.CustomRole
+		if err := a.DB.Where("id = ?", member.RoleID).First(&role).Error; err == nil {
+			member.Role = &role
+		}
+	}
+
+	accessToken, err := a.generateAccessToken(&member)
+	if err != nil {
+		a.Log.Error("Failed to generate access token", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to generate token", nil, "")
+	}
+	refreshToken, err := a.generateRefreshToken(&member)
+	if err != nil {
+		a.Log.Error("Failed to generate refresh token", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to generate token", nil, "")
+	}
+
+	a.Log.Info("User switched organization", "user_id", user.ID, "organization_id", member.OrganizationID)
+	return r.SendEnvelope(AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    a.Config.JWT.AccessExpiryMins * 60,
+		User:         member,
+	})
+}
+
+// userInOrganization returns user as they work in orgID, with their role there. ok is false
+// when orgID is neither the user's own organization nor one they are a member of.
+func (a *App) userInOrganization(user models.User, orgID uuid.UUID) (models.User, bool) {
+	if orgID == user.OrganizationID {
+		return user, true
+	}
+	var membership models.UserOrganization
+	if err := a.DB.Where("user_id = ? AND organization_id = ?", user.ID, orgID).First(&membership).Error; err != nil {
+		return user, false
+	}
+	user.OrganizationID = membership.OrganizationID
+	user.RoleID = membership.RoleID
+	user.Organization = nil
+	user.Role = nil
+	return user, true
+}
+
+// ListOrganizationMembers returns the users who can switch into the organization
+func (a *App) ListOrganizationMembers(r *fastglue.Request) error {
+	orgID, err := a.organizationMembersAccess(r, models.ActionRead)
+	if err != nil {
+		return nil
+	}
+
+	var memberships []models.UserOrganization
+	if err := a.DB.Preload("User").Preload("Role").
+		Where("organization_id = ?", orgID).
+		Order("created_at ASC").
+		Find(&memberships).Error; err != nil {
+		a.Log.Error("Failed to list organization members", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list members", nil, "")
+	}
+
+	members := make([]OrganizationMemberResponse, 0, len(memberships))
+	for _, m := range memberships {
+		if m.User == nil {
+			continue
+		}
+		members = append(members, organizationMemberToResponse(m))
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"members": members,
+	})
+}
+
+// UpdateOrganizationMember changes a member's role in this organization
+func (a *App) UpdateOrganizationMember(r *fastglue.Request) error {
+	orgID, err := a.organizationMembersAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	membership, err := a.findOrganizationMember(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	var req OrganizationMemberRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	roleID, err := a.organizationMemberRole(orgID, req.RoleID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid role", nil, "")
+	}
+
+	if err := a.DB.Model(membership).Update("role_id", roleID).Error; err != nil {
+		a.Log.Error("Failed to update organization member", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update member", nil, "")
+	}
+	a.InvalidateUserPermissionsCache(membership.UserID)
+
+	a.DB.Preload("User").Preload("Role").First(membership, membership.ID)
+	return r.SendEnvelope(organizationMemberToResponse(*membership))
+}
+
+// RemoveOrganizationMember takes away a member's access to this organization. Their
+// sessions and API keys for it stop working; their own organization is unaffected.
+func (a *App) RemoveOrganizationMember(r *fastglue.Request) error {
+	orgID, err := a.organizationMembersAccess(r, models.ActionDelete)
+	if err != nil {
+		return nil
+	}
+	membership, err := a.findOrganizationMember(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	currentUserID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if membership.UserID == currentUserID {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Cannot remove yourself", nil, "")
+	}
+
+	if err := a.DB.Delete(membership).Error; err != nil {
+		a.Log.Error("Failed to remove organization member", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to remove member", nil, "")
+	}
+	a.InvalidateUserPermissionsCache(membership.UserID)
+
+	return r.SendEnvelope(map[string]string{"message": "Member removed"})
+}
+
+// organizationMembersAccess resolves the organization and checks the users permission,
+// sending a 4xx on failure
+func (a *App) organizationMembersAccess(r *fastglue.Request, action string) (uuid.UUID, error) {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+		return uuid.Nil, err
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceUsers, action) {
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+		return uuid.Nil, errors.New("forbidden")
+	}
+	return orgID, nil
+}
+
+// findOrganizationMember loads the membership of the user named by the id path parameter,
+// sending a 4xx if missing
+func (a *App) findOrganizationMember(r *fastglue.Request, orgID uuid.UUID) (*models.UserOrganization, error) {
+	userID, err := uuid.Parse(fmt.Sprint(r.RequestCtx.UserValue("id")))
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid user ID", nil, "")
+		return nil, err
+	}
+	var membership models.UserOrganization
+	if err := a.DB.Preload("User").Where("user_id = ? AND organization_id = ?", userID, orgID).First(&membership).Error; err != nil || membership.User == nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusNotFound, "Member not found", nil, "")
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &membership, nil
+}
+
+// organizationMemberRole validates a role for the organization, falling back to its
+// default role when none is given
+func (a *App) organizationMemberRole(orgID uuid.UUID, roleID *uuid.UUID) (*uuid.UUID, error) {
+	var role models.CustomRole
+	if roleID != nil {
+		if err := a.DB.Where("id = ? AND organization_id = ?", roleID, orgID).First(&role).Error; err != nil {
+			return nil, err
+		}
+		return &role.ID, nil
+	}
+	if err := a.DB.Where("organization_id = ? AND is_default = ?", orgID, true).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role.ID, nil
+}
+
+func organizationMemberToResponse(m models.UserOrganization) OrganizationMemberResponse {
+	resp := OrganizationMemberResponse{
+		UserID: m.UserID,
+		RoleID: m.RoleID,
+	}
+	if m.User != nil {
+		resp.Email = m.User.Email
+		resp.FullName = m.User.FullName
+	}
+	if m.Role != nil {
+		resp.RoleName = m.Role.Name
+	}
+	return resp
+}
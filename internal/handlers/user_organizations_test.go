@@ -0,0 +1,188 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// switchTestOrganization switches the user from one organization to another and returns
+// the response status and, on success, the tokens
+func switchTestOrganization(t *testing.T, app *handlers.App, userID, from, to uuid.UUID) (int, handlers.AuthResponse) {
+	t.Helper()
+
+	req := testutil.NewJSONRequest(t, map[string]any{"organization_id": to})
+	setTransferAuthContext(req, from, userID)
+	require.NoError(t, app.SwitchOrganization(req))
+	var resp struct {
+		Data handlers.AuthResponse `json:"data"`
+	}
+	status := testutil.GetResponseStatusCode(req)
+	if status == fasthttp.StatusOK {
+		testutil.ParseJSONResponse(t, req, &resp)
+	}
+	return status, resp.Data
+}
+
+// parseTestClaims parses a token issued by the app
+func parseTestClaims(t *testing.T, token string) *middleware.JWTClaims {
+	t.Helper()
+
+	parsed, err := jwt.ParseWithClaims(token, &middleware.JWTClaims{}, func(token *jwt.Token) (any, error) {
+		return []byte(testJWTSecret), nil
+	})
+	require.NoError(t, err)
+	return parsed.Claims.(*middleware.JWTClaims)
+}
+
+func TestApp_SwitchOrganization(t *testing.T) {
+	app := testApp(t)
+	orgA := createTestOrganization(t, app)
+	orgB := createTestOrganization(t, app)
+	adminRoleA := createTransferAdminRole(t, app.DB, orgA.ID)
+	adminRoleB := createTransferAdminRole(t, app.DB, orgB.ID)
+	agentRoleB := createTransferTestRole(t, app.DB, orgB.ID, "agent", []string{"contacts:read"})
+
+	user := createTestUser(t, app, orgA.ID, uniqueEmail("agency"), "password123", &adminRoleA.ID, true)
+	adminB := createTestUser(t, app, orgB.ID, uniqueEmail("client-admin"), "password123", &adminRoleB.ID, true)
+
+	// Not a member of B yet
+	status, _ := switchTestOrganization(t, app, user.ID, orgA.ID, orgB.ID)
+	assert.Equal(t, fasthttp.StatusForbidden, status)
+
+	invite := inviteTestMember(t, app, orgB.ID, adminB.ID, user.Email, &agentRoleB.ID)
+	status, _ = switchTestOrganization(t, app, user.ID, orgA.ID, orgB.ID)
+	assert.Equal(t, fasthttp.StatusForbidden, status)
+	require.Equal(t, fasthttp.StatusOK, respondToTestInvite(t, app.AcceptOrganizationInvite, orgA.ID, user.ID, invite.ID))
+
+	req := testutil.NewGETRequest(t)
+	setTransferAuthContext(req, orgA.ID, user.ID)
+	require.NoError(t, app.ListMyOrganizations(req))
+	var list struct {
+		Data struct {
+			Organizations []handlers.UserOrganizationResponse `json:"organizations"`
+		} `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &list)
+	require.Len(t, list.Data.Organizations, 2)
+	assert.Equal(t, orgA.ID, list.Data.Organizations[0].OrganizationID)
+	assert.True(t, list.Data.Organizations[0].IsCurrent)
+	assert.Equal(t, orgB.ID, list.Data.Organizations[1].OrganizationID)
+	assert.False(t, list.Data.Organizations[1].IsCurrent)
+	assert.Equal(t, "agent", list.Data.Organizations[1].RoleName)
+
+	// Switching issues tokens for B with the role the user has there
+	status, auth := switchTestOrganization(t, app, user.ID, orgA.ID, orgB.ID)
+	require.Equal(t, fasthttp.StatusOK, status)
+	claims := parseTestClaims(t, auth.AccessToken)
+	assert.Equal(t, orgB.ID, claims.OrganizationID)
+	require.NotNil(t, claims.RoleID)
+	assert.Equal(t, agentRoleB.ID, *claims.RoleID)
+	assert.Equal(t, orgB.ID, auth.User.OrganizationID)
+
+	// Permissions follow the organization, and the user stays in A
+	assert.False(t, app.HasPermission(user.ID, orgB.ID, models.ResourceUsers, models.ActionWrite))
+	assert.True(t, app.HasPermission(user.ID, orgA.ID, models.ResourceUsers, models.ActionWrite))
+	var stored models.User
+	require.NoError(t, app.DB.First(&stored, user.ID).Error)
+	assert.Equal(t, orgA.ID, stored.OrganizationID)
+	assert.Equal(t, adminRoleA.ID, *stored.RoleID)
+
+	status, auth = switchTestOrganization(t, app, user.ID, orgB.ID, orgA.ID)
+	require.Equal(t, fasthttp.StatusOK, status)
+	claims = parseTestClaims(t, auth.AccessToken)
+	assert.Equal(t, orgA.ID, claims.OrganizationID)
+	assert.Equal(t, adminRoleA.ID, *claims.RoleID)
+
+	// Removing the member takes away B only
+	req = testutil.NewRequest(t)
+	setTransferAuthContext(req, orgB.ID, adminB.ID)
+	testutil.SetPathParam(req, "id", user.ID.String())
+	require.NoError(t, app.RemoveOrganizationMember(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	status, _ = switchTestOrganization(t, app, user.ID, orgA.ID, orgB.ID)
+	assert.Equal(t, fasthttp.StatusForbidden, status)
+	assert.False(t, app.HasPermission(user.ID, orgB.ID, models.ResourceContacts, models.ActionRead))
+	assert.True(t, app.HasPermission(user.ID, orgA.ID, models.ResourceUsers, models.ActionWrite))
+}
+
+func TestApp_SwitchOrganization_SessionsPerOrganization(t *testing.T) {
+	app := testApp(t)
+	orgA := createTestOrganization(t, app)
+	orgB := createTestOrganization(t, app)
+	adminRoleA := createTransferAdminRole(t, app.DB, orgA.ID)
+	agentRoleB := createTransferTestRole(t, app.DB, orgB.ID, "agent", []string{"contacts:read"})
+	user := createTestUser(t, app, orgA.ID, uniqueEmail("agency"), "password123", &adminRoleA.ID, true)
+	membership := models.UserOrganization{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		UserID:         user.ID,
+		OrganizationID: orgB.ID,
+		RoleID:         &agentRoleB.ID,
+	}
+	require.NoError(t, app.DB.Create(&membership).Error)
+
+	// authenticate runs a token or API key through the API's auth middleware
+	authenticate := func(header, value string) int {
+		req := testutil.NewGETRequest(t)
+		req.RequestCtx.Request.Header.Set(header, value)
+		if r := middleware.AuthWithDB(testJWTSecret, app.DB)(req); r == nil {
+			return testutil.GetResponseStatusCode(req)
+		}
+		if r := middleware.RequireOrganizationMember(app.IsOrganizationMember)(req); r == nil {
+			return testutil.GetResponseStatusCode(req)
+		}
+		return fasthttp.StatusOK
+	}
+
+	// An API key for A, the user's own organization
+	req := testutil.NewJSONRequest(t, map[string]any{"name": "Agency sync"})
+	setTransferAuthContext(req, orgA.ID, user.ID)
+	require.NoError(t, app.CreateAPIKey(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var key struct {
+		Data handlers.APIKeyCreateResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &key)
+
+	status, authA := switchTestOrganization(t, app, user.ID, orgA.ID, orgA.ID)
+	require.Equal(t, fasthttp.StatusOK, status)
+	status, authB := switchTestOrganization(t, app, user.ID, orgA.ID, orgB.ID)
+	require.Equal(t, fasthttp.StatusOK, status)
+
+	// Sessions for A and B and the key for A all work side by side
+	assert.Equal(t, fasthttp.StatusOK, authenticate("Authorization", "Bearer "+authA.AccessToken))
+	assert.Equal(t, fasthttp.StatusOK, authenticate("Authorization", "Bearer "+authB.AccessToken))
+	assert.Equal(t, fasthttp.StatusOK, authenticate("X-API-Key", key.Data.Key))
+
+	// Refreshing a session keeps it in its organization
+	req = testutil.NewJSONRequest(t, map[string]string{"refresh_token": authB.RefreshToken})
+	require.NoError(t, app.RefreshToken(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var refreshed struct {
+		Data handlers.AuthResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &refreshed)
+	claims := parseTestClaims(t, refreshed.Data.AccessToken)
+	assert.Equal(t, orgB.ID, claims.OrganizationID)
+	assert.Equal(t, agentRoleB.ID, *claims.RoleID)
+
+	// Once the membership is gone, only the sessions for B stop working
+	require.NoError(t, app.DB.Delete(&membership).Error)
+	app.InvalidateUserPermissionsCache(user.ID)
+	assert.Equal(t, fasthttp.StatusUnauthorized, authenticate("Authorization", "Bearer "+authB.AccessToken))
+	assert.Equal(t, fasthttp.StatusOK, authenticate("Authorization", "Bearer "+authA.AccessToken))
+	assert.Equal(t, fasthttp.StatusOK, authenticate("X-API-Key", key.Data.Key))
+
+	req = testutil.NewJSONRequest(t, map[string]string{"refresh_token": authB.RefreshToken})
+	require.NoError(t, app.RefreshToken(req))
+	assert.Equal(t, fasthttp.StatusUnauthorized, testutil.GetResponseStatusCode(req))
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/mailer"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 	"golang.org/x/crypto/bcrypt"
@@ -12,28 +14,36 @@ import (
 
 // UserRequest represents the request body for creating/updating a user
 type UserRequest struct {
-	Email        string     `json:"email"`
-	Password     string     `json:"password"`
-	FullName     string     `json:"full_name"`
-	RoleID       *uuid.UUID `json:"role_id"`
-	IsActive     *bool      `json:"is_active"`
-	IsSuperAdmin *bool      `json:"is_super_admin"`
+	Email            string     `json:"email"`
+	Password         string     `json:"password"`
+	FullName         string     `json:"full_name"`
+	RoleID           *uuid.UUID `json:"role_id"`
+	IsActive         *bool      `json:"is_active"`
+	IsSuperAdmin     *bool      `json:"is_super_admin"`
+	RequiresApproval *bool      `json:"requires_approval"` // Hold outbound messages for supervisor review
+	Skills           *[]string  `json:"skills"`            // Matched against contact tags by skill-based assignment
 }
 
 // UserResponse represents the response for a user (without sensitive data)
 type UserResponse struct {
-	ID             uuid.UUID    `json:"id"`
-	Email          string       `json:"email"`
-	FullName       string       `json:"full_name"`
-	RoleID         *uuid.UUID   `json:"role_id,omitempty"`
-	Role           *RoleInfo    `json:"role,omitempty"`
-	IsActive       bool         `json:"is_active"`
-	IsAvailable    bool         `json:"is_available"`
-	IsSuperAdmin   bool         `json:"is_super_admin"`
-	OrganizationID uuid.UUID    `json:"organization_id"`
-	Settings       models.JSONB `json:"settings,omitempty"`
-	CreatedAt      string       `json:"created_at"`
-	UpdatedAt      string       `json:"updated_at"`
+	ID               uuid.UUID    `json:"id"`
+	Email            string       `json:"email"`
+	FullName         string       `json:"full_name"`
+	RoleID           *uuid.UUID   `json:"role_id,omitempty"`
+	Role             *RoleInfo    `json:"role,omitempty"`
+	IsActive         bool         `json:"is_active"`
+	IsAvailable      bool         `json:"is_available"`
+	IsSuperAdmin     bool         `json:"is_super_admin"`
+	RequiresApproval bool         `json:"requires_approval"`
+	Skills           []string     `json:"skills"`
+	Signature        string       `json:"signature"`
+	AwayMessage      string       `json:"away_message"`
+	OrganizationID   uuid.UUID    `json:"organization_id"`
+	Settings         models.JSONB `json:"settings,omitempty"`
+	CreatedAt        string       `json:"created_at"`
+	UpdatedAt        string       `json:"updated_at"`
+	// Set on /api/me when a super admin is impersonating the user
+	Impersonation *ImpersonationResponse `json:"impersonation,omitempty"`
 }
 
 // PermissionInfo represents permission info in role response
@@ -74,7 +84,7 @@ func (a *App) ListUsers(r *fastglue.Request) error {
 	}
 
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceUsers, models.ActionRead) {
+	if !a.HasPermission(userID, orgID, models.ResourceUsers, models.ActionRead) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -129,7 +139,7 @@ func (a *App) CreateUser(r *fastglue.Request) error {
 	}
 
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(userID, models.ResourceUsers, models.ActionWrite) {
+	if !a.HasPermission(userID, orgID, models.ResourceUsers, models.ActionWrite) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -181,6 +191,12 @@ func (a *App) CreateUser(r *fastglue.Request) error {
 		RoleID:         roleID,
 		IsActive:       true,
 	}
+	if req.RequiresApproval != nil {
+		user.RequiresApproval = *req.RequiresApproval
+	}
+	if req.Skills != nil {
+		user.Skills = normalizeSkills(*req.Skills)
+	}
 
 	// Only superadmins can create other superadmins
 	if req.IsSuperAdmin != nil && *req.IsSuperAdmin {
@@ -198,9 +214,31 @@ func (a *App) CreateUser(r *fastglue.Request) error {
 	// Load role for response
 	a.DB.Preload("Role").First(&user, user.ID)
 
+	a.sendInvitationEmail(r, user, userID, "")
+
 	return r.SendEnvelope(userToResponse(user))
 }
 
+// sendInvitationEmail tells a newly created user about their account. The password is
+// never included; they get it from the admin or set their own via password reset, or
+// with setPasswordURL when given.
+func (a *App) sendInvitationEmail(r *fastglue.Request, user models.User, invitedByID uuid.UUID, setPasswordURL string) {
+	var inviter models.User
+	inviterName := ""
+	if err := a.DB.Select("full_name").Where("id = ?", invitedByID).First(&inviter).Error; err == nil {
+		inviterName = inviter.FullName
+	}
+
+	a.sendOrgEmailAsync(user.OrganizationID, []string{user.Email}, mailer.TemplateInvitation, map[string]interface{}{
+		"Name":           user.FullName,
+		"Email":          user.Email,
+		"InvitedBy":      inviterName,
+		"LoginURL":       a.frontendURL(r, "/login"),
+		"SetPasswordURL": setPasswordURL,
+		"ExpiresIn":      "7 days",
+	})
+}
+
 // UpdateUser updates a user
 func (a *App) UpdateUser(r *fastglue.Request) error {
 	orgID, err := getOrganizationID(r)
@@ -220,7 +258,7 @@ func (a *App) UpdateUser(r *fastglue.Request) error {
 	}
 
 	// Users can update themselves, others need users:write permission
-	if currentUserID != id && !a.HasPermission(currentUserID, models.ResourceUsers, models.ActionWrite) {
+	if currentUserID != id && !a.HasPermission(currentUserID, orgID, models.ResourceUsers, models.ActionWrite) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -236,10 +274,15 @@ func (a *App) UpdateUser(r *fastglue.Request) error {
 	}
 
 	// Only users with users:write permission can change roles
-	if req.RoleID != nil && !a.HasPermission(currentUserID, models.ResourceUsers, models.ActionWrite) {
+	if req.RoleID != nil && !a.HasPermission(currentUserID, orgID, models.ResourceUsers, models.ActionWrite) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions to change roles", nil, "")
 	}
 
+	// Agents can't take themselves out of approval mode
+	if req.RequiresApproval != nil && *req.RequiresApproval != user.RequiresApproval && !a.HasPermission(currentUserID, orgID, models.ResourceUsers, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions to change approval mode", nil, "")
+	}
+
 	// Update fields if provided
 	if req.Email != "" {
 		var existingUser models.User
@@ -252,6 +295,9 @@ func (a *App) UpdateUser(r *fastglue.Request) error {
 		user.FullName = req.FullName
 	}
 	if req.Password != "" {
+		if isImpersonating(r) {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Passwords can't be changed while impersonating", nil, "")
+		}
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			a.Log.Error("Failed to hash password", "error", err)
@@ -273,6 +319,9 @@ func (a *App) UpdateUser(r *fastglue.Request) error {
 			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Cannot demote yourself", nil, "")
 		}
 		if user.RoleID == nil || *user.RoleID != *req.RoleID {
+			if isImpersonating(r) {
+				return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Roles can't be changed while impersonating", nil, "")
+			}
 			roleChanged = true
 		}
 		user.RoleID = req.RoleID
@@ -287,6 +336,13 @@ func (a *App) UpdateUser(r *fastglue.Request) error {
 		user.IsActive = *req.IsActive
 	}
 
+	if req.RequiresApproval != nil {
+		user.RequiresApproval = *req.RequiresApproval
+	}
+	if req.Skills != nil {
+		user.Skills = normalizeSkills(*req.Skills)
+	}
+
 	// Handle super admin update - only superadmins can change this
 	if req.IsSuperAdmin != nil {
 		if !a.IsSuperAdmin(currentUserID) {
@@ -323,7 +379,7 @@ func (a *App) DeleteUser(r *fastglue.Request) error {
 	}
 
 	currentUserID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
-	if !a.HasPermission(currentUserID, models.ResourceUsers, models.ActionDelete) {
+	if !a.HasPermission(currentUserID, orgID, models.ResourceUsers, models.ActionDelete) {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
 	}
 
@@ -364,6 +420,9 @@ func (a *App) DeleteUser(r *fastglue.Request) error {
 	if result.RowsAffected == 0 {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "User not found", nil, "")
 	}
+	// The user no longer exists to switch into their other organizations
+	a.DB.Where("user_id = ?", id).Delete(&models.UserOrganization{})
+	a.DB.Where("user_id = ?", id).Delete(&models.AgentShift{})
 
 	return r.SendEnvelope(map[string]string{"message": "User deleted successfully"})
 }
@@ -376,12 +435,22 @@ func (a *App) GetCurrentUser(r *fastglue.Request) error {
 	}
 
 	var user models.User
-	if err := a.DB.Where("id = ?", userID).
-		Preload("Role").
-		First(&user).Error; err != nil {
+	if err := a.DB.Where("id = ?", userID).First(&user).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "User not found", nil, "")
 	}
 
+	// The session's organization and the user's role there
+	orgID, _ := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	if member, ok := a.userInOrganization(user, orgID); ok {
+		user = member
+	}
+	if user.RoleID != nil {
+		var role models.CustomRole
+		if err := a.DB.Where("id = ?", user.RoleID).First(&role).Error; err == nil {
+			user.Role = &role
+		}
+	}
+
 	// Load permissions from cache
 	if user.Role != nil && user.RoleID != nil {
 		cachedPerms, err := a.GetRolePermissionsCached(*user.RoleID)
@@ -401,7 +470,9 @@ func (a *App) GetCurrentUser(r *fastglue.Request) error {
 		}
 	}
 
-	return r.SendEnvelope(userToResponse(user))
+	resp := userToResponse(user)
+	resp.Impersonation = a.currentImpersonation(r)
+	return r.SendEnvelope(resp)
 }
 
 // splitPermission splits a "resource:action" string
@@ -500,20 +571,40 @@ func (a *App) ChangePassword(r *fastglue.Request) error {
 	return r.SendEnvelope(map[string]string{"message": "Password changed successfully"})
 }
 
+// normalizeSkills trims skills and drops empty and repeated ones, ignoring case
+func normalizeSkills(skills []string) models.StringArray {
+	normalized := models.StringArray{}
+	seen := make(map[string]bool)
+	for _, skill := range skills {
+		skill = strings.TrimSpace(skill)
+		key := strings.ToLower(skill)
+		if skill == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, skill)
+	}
+	return normalized
+}
+
 // Helper function to convert User to UserResponse
 func userToResponse(user models.User) UserResponse {
 	resp := UserResponse{
-		ID:             user.ID,
-		Email:          user.Email,
-		FullName:       user.FullName,
-		RoleID:         user.RoleID,
-		IsActive:       user.IsActive,
-		IsAvailable:    user.IsAvailable,
-		IsSuperAdmin:   user.IsSuperAdmin,
-		OrganizationID: user.OrganizationID,
-		Settings:       user.Settings,
-		CreatedAt:      user.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		UpdatedAt:      user.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:               user.ID,
+		Email:            user.Email,
+		FullName:         user.FullName,
+		RoleID:           user.RoleID,
+		IsActive:         user.IsActive,
+		IsAvailable:      user.IsAvailable,
+		IsSuperAdmin:     user.IsSuperAdmin,
+		RequiresApproval: user.RequiresApproval,
+		Skills:           user.Skills,
+		Signature:        user.Signature,
+		AwayMessage:      user.AwayMessage,
+		OrganizationID:   user.OrganizationID,
+		Settings:         user.Settings,
+		CreatedAt:        user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:        user.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
 
 	// Include role info if loaded
@@ -569,41 +660,18 @@ func (a *App) UpdateAvailability(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
-	// Only log if status is actually changing
-	if user.IsAvailable != req.IsAvailable {
-		now := time.Now()
-
-		// End the previous availability log (if exists)
-		a.DB.Model(&models.UserAvailabilityLog{}).
-			Where("user_id = ? AND ended_at IS NULL", userID).
-			Update("ended_at", now)
-
-		// Create new availability log
-		log := models.UserAvailabilityLog{
-			UserID:         userID,
-			OrganizationID: orgID,
-			IsAvailable:    req.IsAvailable,
-			StartedAt:      now,
-		}
-		if err := a.DB.Create(&log).Error; err != nil {
-			a.Log.Error("Failed to create availability log", "error", err)
-			// Continue anyway - logging failure shouldn't block availability update
-		}
-	}
-
-	user.IsAvailable = req.IsAvailable
-
-	if err := a.DB.Save(&user).Error; err != nil {
+	transfersReturned, err := a.setUserAvailability(&user, orgID, req.IsAvailable)
+	if err != nil {
 		a.Log.Error("Failed to update availability", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update availability", nil, "")
 	}
 
+	// A manual change overrides the calendar until the current busy event ends
+	a.overrideCalendarAvailability(userID)
+
 	status := "available"
-	transfersReturned := 0
 	if !req.IsAvailable {
 		status = "away"
-		// Return agent's active transfers to queue when going away
-		transfersReturned = a.ReturnAgentTransfersToQueue(userID, orgID)
 	}
 
 	// Get the current break start time if away
@@ -617,10 +685,48 @@ func (a *App) UpdateAvailability(r *fastglue.Request) error {
 	}
 
 	return r.SendEnvelope(map[string]interface{}{
-		"message":             "Availability updated successfully",
-		"is_available":        user.IsAvailable,
-		"status":              status,
-		"break_started_at":    breakStartedAt,
-		"transfers_to_queue":  transfersReturned,
+		"message":            "Availability updated successfully",
+		"is_available":       user.IsAvailable,
+		"status":             status,
+		"break_started_at":   breakStartedAt,
+		"transfers_to_queue": transfersReturned,
 	})
 }
+
+// setUserAvailability changes a user's away/available status, logging the change for
+// break time tracking. Going away returns the user's active transfers to the queue;
+// the number returned is reported.
+func (a *App) setUserAvailability(user *models.User, orgID uuid.UUID, available bool) (int, error) {
+	// Only log if status is actually changing
+	if user.IsAvailable != available {
+		now := time.Now()
+
+		// End the previous availability log (if exists)
+		a.DB.Model(&models.UserAvailabilityLog{}).
+			Where("user_id = ? AND ended_at IS NULL", user.ID).
+			Update("ended_at", now)
+
+		// Create new availability log
+		log := models.UserAvailabilityLog{
+			UserID:         user.ID,
+			OrganizationID: orgID,
+			IsAvailable:    available,
+			StartedAt:      now,
+		}
+		if err := a.DB.Create(&log).Error; err != nil {
+			a.Log.Error("Failed to create availability log", "error", err)
+			// Continue anyway - logging failure shouldn't block availability update
+		}
+	}
+
+	if err := a.DB.Model(user).Update("is_available", available).Error; err != nil {
+		return 0, err
+	}
+	user.IsAvailable = available
+
+	if available {
+		return 0, nil
+	}
+	// Return agent's active transfers to queue when going away
+	return a.ReturnAgentTransfersToQueue(user.ID, orgID), nil
+}
@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Verification defaults and limits
+const (
+	defaultVerificationCodeLength = 6
+	defaultVerificationTTL        = 10 * time.Minute
+	defaultVerificationAttempts   = 5
+
+	// verificationResendCooldown is the minimum time between codes sent to one number
+	verificationResendCooldown = 30 * time.Second
+	// maxVerificationsPerHour caps the codes sent to one number per hour
+	maxVerificationsPerHour = 5
+)
+
+// StartVerificationRequest is the body of POST /api/v1/verifications
+type StartVerificationRequest struct {
+	To           string `json:"to"`
+	TemplateName string `json:"template_name"` // Approved AUTHENTICATION template
+	TemplateID   string `json:"template_id"`   // Alternative: template UUID
+	AccountName  string `json:"account_name"`
+	CodeLength   int    `json:"code_length"` // 4-10 digits, default 6
+	TTLSeconds   int    `json:"ttl_seconds"` // 60-3600, default 600
+	MaxAttempts  int    `json:"max_attempts"`
+}
+
+// CheckVerificationRequest is the body of POST /api/v1/verifications/check
+type CheckVerificationRequest struct {
+	ID   string `json:"id"` // Verification ID; or the number's latest pending verification via to
+	To   string `json:"to"`
+	Code string `json:"code"`
+}
+
+// VerificationResponse describes a verification
+type VerificationResponse struct {
+	ID                uuid.UUID                 `json:"id"`
+	To                string                    `json:"to"`
+	Status            models.VerificationStatus `json:"status"`
+	Valid             bool                      `json:"valid"`
+	AttemptsRemaining int                       `json:"attempts_remaining"`
+	ExpiresAt         time.Time                 `json:"expires_at"`
+	VerifiedAt        *time.Time                `json:"verified_at,omitempty"`
+	MessageID         *uuid.UUID                `json:"message_id,omitempty"`
+}
+
+// StartVerification sends a one-time code to a phone number with an authentication template
+func (a *App) StartVerification(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req StartVerificationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := normalizeStartVerification(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	to, err := phone.Normalize(req.To)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	if err := a.getOrgCountryRestrictions(orgID).Check(to); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, err.Error(), nil, "")
+	}
+
+	var template models.Template
+	query := a.DB.Where("organization_id = ?", orgID)
+	if req.TemplateID != "" {
+		templateID, err := uuid.Parse(req.TemplateID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template_id", nil, "")
+		}
+		query = query.Where("id = ?", templateID)
+	} else {
+		query = query.Where("name = ?", req.TemplateName)
+	}
+	if err := query.First(&template).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
+	}
+	if template.Status != "APPROVED" || template.ArchivedAt != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template is not approved", nil, "")
+	}
+	if !strings.EqualFold(template.Category, "AUTHENTICATION") {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template must be in the AUTHENTICATION category", nil, "")
+	}
+
+	accountName := req.AccountName
+	if accountName == "" {
+		accountName = template.WhatsAppAccount
+	}
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", accountName, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+	}
+
+	// Rate limit per number so the API can't be used to flood someone with codes
+	var recent []models.Verification
+	a.DB.Select("created_at").
+		Where("organization_id = ? AND phone_number = ? AND created_at > ?", orgID, to, time.Now().Add(-time.Hour)).
+		Order("created_at DESC").Find(&recent)
+	if len(recent) > 0 {
+		if wait := verificationResendCooldown - time.Since(recent[0].CreatedAt); wait > 0 {
+			return sendVerificationRateLimited(r, wait, "Please wait before requesting another code")
+		}
+	}
+	if len(recent) >= maxVerificationsPerHour {
+		wait := time.Hour - time.Since(recent[len(recent)-1].CreatedAt)
+		return sendVerificationRateLimited(r, wait, "Too many codes requested for this number")
+	}
+
+	code, err := generateVerificationCode(req.CodeLength)
+	if err != nil {
+		a.Log.Error("Failed to generate verification code", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start verification", nil, "")
+	}
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		a.Log.Error("Failed to hash verification code", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start verification", nil, "")
+	}
+
+	// Only the newest code for a number is valid
+	a.DB.Model(&models.Verification{}).
+		Where("organization_id = ? AND phone_number = ? AND status = ?", orgID, to, models.VerificationStatusPending).
+		Update("status", models.VerificationStatusCanceled)
+
+	verification := models.Verification{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  orgID,
+		PhoneNumber:     to,
+		WhatsAppAccount: account.Name,
+		TemplateID:      template.ID,
+		CodeHash:        string(codeHash),
+		Status:          models.VerificationStatusPending,
+		MaxAttempts:     req.MaxAttempts,
+		ExpiresAt:       time.Now().Add(time.Duration(req.TTLSeconds) * time.Second),
+	}
+	if err := a.DB.Create(&verification).Error; err != nil {
+		a.Log.Error("Failed to create verification", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start verification", nil, "")
+	}
+
+	message, sendErr := a.sendVerificationCode(r.RequestCtx, &account, &template, to, code)
+	updates := map[string]interface{}{}
+	if message != nil {
+		verification.MessageID = &message.ID
+		updates["message_id"] = message.ID
+	}
+	if sendErr != nil {
+		verification.Status = models.VerificationStatusFailed
+		updates["status"] = models.VerificationStatusFailed
+	}
+	if len(updates) > 0 {
+		a.DB.Model(&verification).Updates(updates)
+	}
+	if sendErr != nil {
+		a.Log.Error("Failed to send verification code", "error", sendErr, "verification_id", verification.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to send verification code: "+sendErr.Error(), nil, "")
+	}
+
+	r.RequestCtx.SetStatusCode(fasthttp.StatusCreated)
+	return r.SendEnvelope(verificationResponse(&verification, false))
+}
+
+// CheckVerification checks a code against a pending verification
+func (a *App) CheckVerification(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req CheckVerificationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "code is required", nil, "")
+	}
+
+	var verification models.Verification
+	query := a.DB.Where("organization_id = ?", orgID)
+	switch {
+	case req.ID != "":
+		id, err := uuid.Parse(req.ID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid verification ID", nil, "")
+		}
+		query = query.Where("id = ?", id)
+	case req.To != "":
+		to, err := phone.Normalize(req.To)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		query = query.Where("phone_number = ? AND status = ?", to, models.VerificationStatusPending).Order("created_at DESC")
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Either id or to is required", nil, "")
+	}
+	if err := query.First(&verification).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Verification not found", nil, "")
+	}
+
+	if verification.Status == models.VerificationStatusPending && time.Now().After(verification.ExpiresAt) {
+		verification.Status = models.VerificationStatusExpired
+		a.DB.Model(&verification).Update("status", models.VerificationStatusExpired)
+	}
+	if verification.Status != models.VerificationStatusPending {
+		return r.SendEnvelope(verificationResponse(&verification, false))
+	}
+
+	// Count the attempt before comparing so concurrent guesses can't exceed the limit
+	result := a.DB.Model(&models.Verification{}).
+		Where("id = ? AND status = ? AND attempts < max_attempts", verification.ID, models.VerificationStatusPending).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		a.Log.Error("Failed to record verification attempt", "error", result.Error, "verification_id", verification.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to check verification", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		a.DB.First(&verification, verification.ID)
+		return r.SendEnvelope(verificationResponse(&verification, false))
+	}
+	verification.Attempts++
+
+	if bcrypt.CompareHashAndPassword([]byte(verification.CodeHash), []byte(req.Code)) == nil {
+		now := time.Now()
+		result := a.DB.Model(&models.Verification{}).
+			Where("id = ? AND status = ?", verification.ID, models.VerificationStatusPending).
+			Updates(map[string]interface{}{"status": models.VerificationStatusApproved, "verified_at": now})
+		if result.Error == nil && result.RowsAffected > 0 {
+			verification.Status = models.VerificationStatusApproved
+			verification.VerifiedAt = &now
+			return r.SendEnvelope(verificationResponse(&verification, true))
+		}
+		a.DB.First(&verification, verification.ID)
+		return r.SendEnvelope(verificationResponse(&verification, false))
+	}
+
+	if verification.Attempts >= verification.MaxAttempts {
+		verification.Status = models.VerificationStatusFailed
+		a.DB.Model(&models.Verification{}).
+			Where("id = ? AND status = ?", verification.ID, models.VerificationStatusPending).
+			Update("status", models.VerificationStatusFailed)
+	}
+	return r.SendEnvelope(verificationResponse(&verification, false))
+}
+
+// VerificationStats summarizes the verifications started in a period
+type VerificationStats struct {
+	Total              int64   `json:"total"`
+	Approved           int64   `json:"approved"`
+	Pending            int64   `json:"pending"`
+	Expired            int64   `json:"expired"`
+	Failed             int64   `json:"failed"`
+	Canceled           int64   `json:"canceled"`
+	SuccessRate        float64 `json:"success_rate"`  // Approved as a percentage of completed verifications
+	DeliveryRate       float64 `json:"delivery_rate"` // Codes delivered as a percentage of codes sent
+	AvgSecondsToVerify float64 `json:"avg_seconds_to_verify"`
+	AvgAttempts        float64 `json:"avg_attempts"` // Per approved verification
+}
+
+// GetVerificationStats returns success and delivery metrics for verifications
+func (a *App) GetVerificationStats(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	periodStart, periodEnd, err := parseAnalyticsPeriod(r, a.getOrgLocation(orgID))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	scope := func() *gorm.DB {
+		return a.DB.Model(&models.Verification{}).
+			Where("organization_id = ? AND created_at BETWEEN ? AND ?", orgID, periodStart, periodEnd)
+	}
+
+	// Codes that ran out without being checked are still pending in the table
+	var counts []struct {
+		Status models.VerificationStatus
+		Count  int64
+	}
+	if err := scope().
+		Select("CASE WHEN status = ? AND expires_at < ? THEN ? ELSE status END AS status, COUNT(*) AS count",
+			models.VerificationStatusPending, time.Now(), models.VerificationStatusExpired).
+		Group("1").Scan(&counts).Error; err != nil {
+		a.Log.Error("Failed to load verification stats", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load verification stats", nil, "")
+	}
+
+	var stats VerificationStats
+	for _, c := range counts {
+		stats.Total += c.Count
+		switch c.Status {
+		case models.VerificationStatusApproved:
+			stats.Approved = c.Count
+		case models.VerificationStatusPending:
+			stats.Pending = c.Count
+		case models.VerificationStatusExpired:
+			stats.Expired = c.Count
+		case models.VerificationStatusFailed:
+			stats.Failed = c.Count
+		case models.VerificationStatusCanceled:
+			stats.Canceled = c.Count
+		}
+	}
+	if completed := stats.Approved + stats.Expired + stats.Failed; completed > 0 {
+		stats.SuccessRate = float64(stats.Approved) / float64(completed) * 100
+	}
+
+	var timing struct {
+		AvgSeconds  float64
+		AvgAttempts float64
+	}
+	scope().Where("status = ?", models.VerificationStatusApproved).
+		Select("COALESCE(AVG(EXTRACT(EPOCH FROM (verified_at - created_at))), 0) AS avg_seconds, COALESCE(AVG(attempts), 0) AS avg_attempts").
+		Scan(&timing)
+	stats.AvgSecondsToVerify = timing.AvgSeconds
+	stats.AvgAttempts = timing.AvgAttempts
+
+	var delivery struct {
+		Sent      int64
+		Delivered int64
+	}
+	scope().Joins("JOIN messages ON messages.id = verifications.message_id").
+		Select("COUNT(*) AS sent, COUNT(*) FILTER (WHERE messages.status IN ?) AS delivered",
+			[]models.MessageStatus{models.MessageStatusDelivered, models.MessageStatusRead}).
+		Scan(&delivery)
+	if delivery.Sent > 0 {
+		stats.DeliveryRate = float64(delivery.Delivered) / float64(delivery.Sent) * 100
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"period_start": periodStart,
+		"period_end":   periodEnd,
+		"stats":        stats,
+	})
+}
+
+// normalizeStartVerification applies defaults to a start request and returns an error
+// message, or "" if it is valid
+func normalizeStartVerification(req *StartVerificationRequest) string {
+	if req.To == "" {
+		return "to is required"
+	}
+	if req.TemplateName == "" && req.TemplateID == "" {
+		return "Either template_name or template_id is required"
+	}
+	if req.CodeLength == 0 {
+		req.CodeLength = defaultVerificationCodeLength
+	}
+	if req.CodeLength < 4 || req.CodeLength > 10 {
+		return "code_length must be between 4 and 10"
+	}
+	if req.TTLSeconds == 0 {
+		req.TTLSeconds = int(defaultVerificationTTL.Seconds())
+	}
+	if req.TTLSeconds < 60 || req.TTLSeconds > 3600 {
+		return "ttl_seconds must be between 60 and 3600"
+	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = defaultVerificationAttempts
+	}
+	if req.MaxAttempts < 1 || req.MaxAttempts > 10 {
+		return "max_attempts must be between 1 and 10"
+	}
+	return ""
+}
+
+// generateVerificationCode returns a random numeric code of the given length
+func generateVerificationCode(length int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + n.Int64()))
+	}
+	return b.String(), nil
+}
+
+// sendVerificationCode sends the code with an authentication template and records the
+// message with the code masked. The message is returned even if sending failed.
+func (a *App) sendVerificationCode(ctx context.Context, account *models.WhatsAppAccount, template *models.Template, to, code string) (*models.Message, error) {
+	contact, _ := a.getOrCreateContact(account.OrganizationID, to, "")
+
+	masked := strings.Repeat("*", len(code))
+	msg := &models.Message{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  account.OrganizationID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contact.ID,
+		Direction:       models.DirectionOutgoing,
+		MessageType:     models.MessageTypeTemplate,
+		Content:         replaceTemplateParams(template.BodyContent, map[string]string{"1": masked}),
+		TemplateName:    template.Name,
+		Status:          models.MessageStatusPending,
+		Metadata: models.JSONB{
+			"template_name": template.Name,
+			"template_id":   template.ID.String(),
+			"verification":  true,
+		},
+	}
+	if err := a.DB.Create(msg).Error; err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+
+	components := []map[string]interface{}{
+		{
+			"type":       "body",
+			"parameters": []map[string]interface{}{{"type": "text", "text": code}},
+		},
+	}
+	// Copy code and one-tap buttons take the code as their parameter too
+	for i, b := range template.Buttons {
+		button, _ := b.(map[string]interface{})
+		if btnType, _ := button["type"].(string); btnType == "OTP" || btnType == "URL" {
+			components = append(components, map[string]interface{}{
+				"type":       "button",
+				"sub_type":   "url",
+				"index":      strconv.Itoa(i),
+				"parameters": []map[string]interface{}{{"type": "text", "text": code}},
+			})
+			break
+		}
+	}
+
+	wamid, err := a.WhatsApp.SendTemplateMessageWithComponents(ctx, a.toWhatsAppAccount(account), to, template.Name, template.Language, components)
+	if err != nil {
+		errorCode := whatsapp.ErrorCodeOf(err)
+		a.DB.Model(msg).Updates(map[string]interface{}{
+			"status":        models.MessageStatusFailed,
+			"error_message": err.Error(),
+			"error_code":    errorCode,
+		})
+		a.recordMessageStatus(msg, models.MessageStatusFailed, time.Now(), err.Error())
+		return msg, err
+	}
+
+	a.DB.Model(msg).Updates(map[string]interface{}{
+		"status":               models.MessageStatusSent,
+		"whats_app_message_id": wamid,
+	})
+	a.recordMessageStatus(msg, models.MessageStatusAccepted, time.Now(), "")
+	a.recordTemplateUsage(template.ID)
+	return msg, nil
+}
+
+func sendVerificationRateLimited(r *fastglue.Request, wait time.Duration, message string) error {
+	seconds := int(wait.Seconds()) + 1
+	r.RequestCtx.Response.Header.Set("Retry-After", strconv.Itoa(seconds))
+	return r.SendErrorEnvelope(fasthttp.StatusTooManyRequests, message, nil, "")
+}
+
+func verificationResponse(v *models.Verification, valid bool) VerificationResponse {
+	remaining := v.MaxAttempts - v.Attempts
+	if remaining < 0 || v.Status != models.VerificationStatusPending {
+		remaining = 0
+	}
+	return VerificationResponse{
+		ID:                v.ID,
+		To:                v.PhoneNumber,
+		Status:            v.Status,
+		Valid:             valid,
+		AttemptsRemaining: remaining,
+		ExpiresAt:         v.ExpiresAt,
+		VerifiedAt:        v.VerifiedAt,
+		MessageID:         v.MessageID,
+	}
+}
@@ -0,0 +1,160 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func createVerificationTemplate(t *testing.T, app *handlers.App, orgID uuid.UUID, accountName string) *models.Template {
+	t.Helper()
+
+	template := &models.Template{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  orgID,
+		WhatsAppAccount: accountName,
+		Name:            "login_code_" + uuid.New().String()[:8],
+		Language:        "en",
+		Category:        "AUTHENTICATION",
+		Status:          "APPROVED",
+		BodyContent:     "{{1}} is your verification code.",
+		Buttons:         models.JSONBArray{map[string]interface{}{"type": "OTP", "otp_type": "COPY_CODE", "text": "Copy code"}},
+	}
+	require.NoError(t, app.DB.Create(template).Error)
+	return template
+}
+
+func TestApp_Verification(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	template := createVerificationTemplate(t, app, org.ID, account.Name)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"to":            "+1 555 987 6543",
+		"template_name": template.Name,
+	})
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.StartVerification(req))
+	require.Equal(t, fasthttp.StatusCreated, testutil.GetResponseStatusCode(req))
+
+	var started struct {
+		Data handlers.VerificationResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &started)
+	assert.Equal(t, models.VerificationStatusPending, started.Data.Status)
+	assert.Equal(t, 5, started.Data.AttemptsRemaining)
+	require.NotNil(t, started.Data.MessageID)
+
+	// The code goes out in the body and copy-code button but is never stored
+	require.Len(t, mockServer.sentMessages, 1)
+	components := mockServer.sentMessages[0]["template"].(map[string]interface{})["components"].([]interface{})
+	require.Len(t, components, 2)
+	body := components[0].(map[string]interface{})
+	code := body["parameters"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	assert.Len(t, code, 6)
+
+	var msg models.Message
+	require.NoError(t, app.DB.First(&msg, *started.Data.MessageID).Error)
+	assert.Equal(t, "****** is your verification code.", msg.Content)
+
+	check := func(code string) handlers.VerificationResponse {
+		req := testutil.NewJSONRequest(t, map[string]interface{}{"id": started.Data.ID.String(), "code": code})
+		req.RequestCtx.SetUserValue("organization_id", org.ID)
+		require.NoError(t, app.CheckVerification(req))
+		require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+		var resp struct {
+			Data handlers.VerificationResponse `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data
+	}
+
+	wrong := "000000"
+	if wrong == code {
+		wrong = "111111"
+	}
+	result := check(wrong)
+	assert.False(t, result.Valid)
+	assert.Equal(t, models.VerificationStatusPending, result.Status)
+	assert.Equal(t, 4, result.AttemptsRemaining)
+
+	result = check(code)
+	assert.True(t, result.Valid)
+	assert.Equal(t, models.VerificationStatusApproved, result.Status)
+	assert.NotNil(t, result.VerifiedAt)
+
+	// An approved code can't be reused
+	result = check(code)
+	assert.False(t, result.Valid)
+	assert.Equal(t, models.VerificationStatusApproved, result.Status)
+}
+
+func TestApp_StartVerification_RateLimit(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	template := createVerificationTemplate(t, app, org.ID, account.Name)
+
+	start := func() *fasthttp.RequestCtx {
+		req := testutil.NewJSONRequest(t, map[string]interface{}{
+			"to":          "15559876543",
+			"template_id": template.ID.String(),
+		})
+		req.RequestCtx.SetUserValue("organization_id", org.ID)
+		require.NoError(t, app.StartVerification(req))
+		return req.RequestCtx
+	}
+
+	require.Equal(t, fasthttp.StatusCreated, start().Response.StatusCode())
+
+	// A second code within the cooldown is refused
+	ctx := start()
+	assert.Equal(t, fasthttp.StatusTooManyRequests, ctx.Response.StatusCode())
+	assert.NotEmpty(t, string(ctx.Response.Header.Peek("Retry-After")))
+	assert.Len(t, mockServer.sentMessages, 1)
+
+	// Once the cooldown passes a new code replaces the old one
+	require.NoError(t, app.DB.Model(&models.Verification{}).
+		Where("organization_id = ?", org.ID).
+		Update("created_at", time.Now().Add(-time.Minute)).Error)
+	require.Equal(t, fasthttp.StatusCreated, start().Response.StatusCode())
+
+	var statuses []models.VerificationStatus
+	app.DB.Model(&models.Verification{}).Where("organization_id = ?", org.ID).Order("created_at").Pluck("status", &statuses)
+	assert.Equal(t, []models.VerificationStatus{models.VerificationStatusCanceled, models.VerificationStatusPending}, statuses)
+}
+
+func TestApp_StartVerification_RequiresAuthenticationTemplate(t *testing.T) {
+	mockServer := newMockWhatsAppServer()
+	defer mockServer.close()
+
+	app := messageTestApp(t, mockServer)
+	org := createTestOrg(t, app)
+	account := createTestAccount(t, app, org.ID)
+	template := createVerificationTemplate(t, app, org.ID, account.Name)
+	require.NoError(t, app.DB.Model(template).Update("category", "MARKETING").Error)
+
+	req := testutil.NewJSONRequest(t, map[string]interface{}{
+		"to":            "15559876543",
+		"template_name": template.Name,
+	})
+	req.RequestCtx.SetUserValue("organization_id", org.ID)
+	require.NoError(t, app.StartVerification(req))
+	assert.Equal(t, fasthttp.StatusBadRequest, testutil.GetResponseStatusCode(req))
+	assert.Empty(t, mockServer.sentMessages)
+}
@@ -2,14 +2,22 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"strings"
+	"time"
 
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
 )
 
+// recentMessageWindow bounds the first lookup of a message by its WhatsApp ID. Status
+// updates, reactions and redeliveries almost always concern recent messages, and a
+// created_at bound lets PostgreSQL skip older partitions when messages is partitioned.
+const recentMessageWindow = 30 * 24 * time.Hour
+
 // WebhookVerify handles Meta's webhook verification challenge
 func (a *App) WebhookVerify(r *fastglue.Request) error {
 	mode := string(r.RequestCtx.QueryArgs().Peek("hub.mode"))
@@ -148,6 +156,10 @@ type WebhookPayload struct {
 							Name         string `json:"name"`
 						} `json:"nfm_reply,omitempty"`
 					} `json:"interactive,omitempty"`
+					Button *struct {
+						Payload string `json:"payload"`
+						Text    string `json:"text"`
+					} `json:"button,omitempty"`
 					Reaction *struct {
 						MessageID string `json:"message_id"`
 						Emoji     string `json:"emoji"`
@@ -264,7 +276,7 @@ func (a *App) processIncomingMessage(phoneNumberID string, msg interface{}, prof
 	// Check for duplicate message - Meta sometimes sends the same message multiple times
 	if textMsg.ID != "" {
 		var existingMsg models.Message
-		if err := a.DB.Where("whats_app_message_id = ?", textMsg.ID).First(&existingMsg).Error; err == nil {
+		if err := a.findMessageByWhatsAppID(textMsg.ID, &existingMsg); err == nil {
 			a.Log.Debug("Duplicate message detected, skipping", "message_id", textMsg.ID)
 			return
 		}
@@ -281,43 +293,81 @@ func (a *App) processStatusUpdate(phoneNumberID string, status WebhookStatus) {
 	a.Log.Info("Processing status update", "message_id", messageID, "status", statusValue, "phone_number_id", phoneNumberID)
 
 	// Update messages table - this also handles campaign stats via incrementCampaignStat
-	a.updateMessageStatus(messageID, statusValue, status.Errors)
+	a.updateMessageStatus(messageID, statusValue, parseWebhookTimestamp(status.Timestamp), status.Errors)
+
+	// A failed delivery usually means a bad token, an expired window or a rejected template
+	if account, err := a.getWhatsAppAccountCached(phoneNumberID); err == nil {
+		a.recordAccountMetric(account, accountMetricProcessed)
+		if models.MessageStatus(statusValue) == models.MessageStatusFailed {
+			a.recordAccountMetric(account, accountMetricFailed)
+		}
+	}
 }
 
-// updateMessageStatus updates the status of a regular message in the messages table
-func (a *App) updateMessageStatus(whatsappMsgID, statusValue string, errors []WebhookStatusError) {
+// findMessageByWhatsAppID loads the message with the given WhatsApp message ID, searching
+// recent messages before falling back to the whole table
+func (a *App) findMessageByWhatsAppID(whatsappMsgID string, message *models.Message) error {
+	err := a.DB.Where("whats_app_message_id = ? AND created_at > ?", whatsappMsgID, time.Now().Add(-recentMessageWindow)).
+		First(message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = a.DB.Where("whats_app_message_id = ?", whatsappMsgID).First(message).Error
+	}
+	return err
+}
+
+// updateMessageStatus records a status transition of a regular message and advances
+// its current status in the messages table
+func (a *App) updateMessageStatus(whatsappMsgID, statusValue string, occurredAt time.Time, errors []WebhookStatusError) {
 	// Find the message by WhatsApp message ID
 	var message models.Message
-	result := a.DB.Where("whats_app_message_id = ?", whatsappMsgID).First(&message)
-	if result.Error != nil {
+	if err := a.findMessageByWhatsAppID(whatsappMsgID, &message); err != nil {
 		a.Log.Debug("No message found for status update", "whats_app_message_id", whatsappMsgID)
 		return
 	}
 
-	updates := map[string]interface{}{}
-
-	switch models.MessageStatus(statusValue) {
-	case models.MessageStatusSent:
-		updates["status"] = models.MessageStatusSent
-	case models.MessageStatusDelivered:
-		updates["status"] = models.MessageStatusDelivered
-	case models.MessageStatusRead:
-		updates["status"] = models.MessageStatusRead
-	case models.MessageStatusFailed:
-		updates["status"] = models.MessageStatusFailed
-		if len(errors) > 0 {
-			updates["error_message"] = errors[0].Message
-		}
+	status := models.MessageStatus(statusValue)
+	switch status {
+	case models.MessageStatusSent, models.MessageStatusDelivered, models.MessageStatusRead, models.MessageStatusFailed:
 	default:
 		a.Log.Debug("Ignoring message status update", "status", statusValue)
 		return
 	}
 
-	if err := a.DB.Model(&message).Updates(updates).Error; err != nil {
-		a.Log.Error("Failed to update message status", "error", err, "message_id", message.ID)
+	var errorMessage string
+	var errorCode int
+	if status == models.MessageStatusFailed && len(errors) > 0 {
+		errorMessage = errors[0].Message
+		errorCode = errors[0].Code
+	}
+
+	// Keep every transition; a repeated webhook is ignored entirely so campaign stats
+	// aren't counted twice
+	if !a.recordMessageStatus(&message, status, occurredAt, errorMessage) {
+		a.Log.Debug("Duplicate message status update", "message_id", message.ID, "status", statusValue)
 		return
 	}
 
+	// Webhooks can arrive out of order, so only move the current status forward
+	updates := map[string]interface{}{}
+	if status == models.MessageStatusFailed {
+		updates["status"] = models.MessageStatusFailed
+		if errorMessage != "" {
+			updates["error_message"] = errorMessage
+		}
+		if errorCode != 0 {
+			updates["error_code"] = errorCode
+		}
+	} else if messageStatusRank[status] > messageStatusRank[message.Status] {
+		updates["status"] = status
+	}
+
+	if len(updates) > 0 {
+		if err := a.DB.Model(&message).Updates(updates).Error; err != nil {
+			a.Log.Error("Failed to update message status", "error", err, "message_id", message.ID)
+			return
+		}
+	}
+
 	a.Log.Info("Updated message status", "message_id", message.ID, "status", statusValue)
 
 	// Update campaign stats if this is a campaign message
@@ -327,13 +377,22 @@ func (a *App) updateMessageStatus(whatsappMsgID, statusValue string, errors []We
 		}
 	}
 
+	switch status {
+	case models.MessageStatusFailed:
+		a.dispatchMessageFailedWebhook(&message, errorCode, errorMessage)
+	case models.MessageStatusDelivered, models.MessageStatusRead:
+		a.dispatchMessageStatusWebhook(&message, status, occurredAt)
+	}
+	a.dispatchTransactionalCallback(&message, status, occurredAt, errorCode, errorMessage)
+
 	// Broadcast status update via WebSocket
-	if a.WSHub != nil {
+	if a.WSHub != nil && len(updates) > 0 {
 		a.WSHub.BroadcastToOrg(message.OrganizationID, websocket.WSMessage{
 			Type: websocket.TypeStatusUpdate,
 			Payload: map[string]any{
 				"message_id": message.ID.String(),
 				"status":     statusValue,
+				"timestamp":  occurredAt,
 			},
 		})
 	}
@@ -387,6 +446,10 @@ func (a *App) processTemplateStatusUpdate(wabaID, event, templateName, templateL
 				"status", status,
 				"reason", reason,
 			)
+
+			if status == string(models.TemplateStatusRejected) {
+				a.notifyTemplateRejected(account.OrganizationID, account.Name, templateName, templateLanguage, reason)
+			}
 		}
 	}
 }
@@ -34,6 +34,15 @@ type MessageEventData struct {
 	WhatsAppAccount string             `json:"whatsapp_account"`
 	Direction       models.Direction   `json:"direction,omitempty"`
 	SentByUserID    string             `json:"sent_by_user_id,omitempty"`
+
+	// Set for message.delivered, message.read and message.failed
+	Status   models.MessageStatus `json:"status,omitempty"`
+	StatusAt *time.Time           `json:"status_at,omitempty"` // When WhatsApp reported the status
+
+	// Set for message.failed; see GET /api/errors/catalog
+	ErrorCode     int    `json:"error_code,omitempty"`
+	ErrorCategory string `json:"error_category,omitempty"`
+	ErrorMessage  string `json:"error_message,omitempty"`
 }
 
 // ContactEventData represents data for contact events
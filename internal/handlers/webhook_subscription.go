@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// webhookCallbackPath is the path Meta delivers WhatsApp webhooks to
+const webhookCallbackPath = "/api/webhook"
+
+// WebhookSubscriptionRequest represents a request to subscribe a WABA to this app's webhooks
+type WebhookSubscriptionRequest struct {
+	// OverrideCallback points this WABA's webhooks at this server instead of the app's default callback URL
+	OverrideCallback bool `json:"override_callback"`
+}
+
+// WebhookSubscriptionResponse represents the webhook subscription status of a WABA
+type WebhookSubscriptionResponse struct {
+	Subscribed     bool                     `json:"subscribed"`
+	SubscribedApps []whatsapp.SubscribedApp `json:"subscribed_apps"`
+	CallbackURL    string                   `json:"callback_url,omitempty"`
+}
+
+// GetWebhookSubscription returns whether the account's WABA is subscribed to this app's webhooks
+func (a *App) GetWebhookSubscription(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	waAccount := a.toWhatsAppAccount(account)
+	apps, err := a.WhatsApp.ListSubscribedApps(context.Background(), waAccount)
+	if err != nil {
+		a.Log.Error("Failed to fetch webhook subscriptions", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch webhook subscriptions: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(WebhookSubscriptionResponse{
+		Subscribed:     whatsapp.IsAppSubscribed(waAccount, apps),
+		SubscribedApps: apps,
+	})
+}
+
+// SubscribeWebhooks subscribes the account's WABA to this app's webhooks and verifies the subscription
+func (a *App) SubscribeWebhooks(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var req WebhookSubscriptionRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+
+	var callbackURL string
+	if req.OverrideCallback {
+		callbackURL = strings.TrimRight(a.publicBaseURL(r), "/") + webhookCallbackPath
+	}
+
+	apps, subscribed, err := a.subscribeAccountWebhooks(account, callbackURL)
+	if err != nil {
+		a.Log.Error("Failed to subscribe to webhooks", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to subscribe to webhooks: "+err.Error(), nil, "")
+	}
+	if !subscribed {
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Subscription request succeeded but the app is not listed as subscribed", nil, "")
+	}
+
+	return r.SendEnvelope(WebhookSubscriptionResponse{
+		Subscribed:     subscribed,
+		SubscribedApps: apps,
+		CallbackURL:    callbackURL,
+	})
+}
+
+// UnsubscribeWebhooks removes this app's webhook subscription from the account's WABA
+func (a *App) UnsubscribeWebhooks(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAccounts, models.ActionWrite) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	account, err := a.getAccountByIDParam(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	if err := a.WhatsApp.UnsubscribeApp(context.Background(), a.toWhatsAppAccount(account)); err != nil {
+		a.Log.Error("Failed to unsubscribe from webhooks", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to unsubscribe from webhooks: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Webhooks unsubscribed successfully",
+	})
+}
+
+// subscribeAccountWebhooks subscribes the account's WABA and re-reads the subscription list to verify it
+func (a *App) subscribeAccountWebhooks(account *models.WhatsAppAccount, callbackURL string) ([]whatsapp.SubscribedApp, bool, error) {
+	ctx := context.Background()
+	waAccount := a.toWhatsAppAccount(account)
+
+	if err := a.WhatsApp.SubscribeApp(ctx, waAccount, callbackURL, account.WebhookVerifyToken); err != nil {
+		return nil, false, err
+	}
+
+	apps, err := a.WhatsApp.ListSubscribedApps(ctx, waAccount)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return apps, whatsapp.IsAppSubscribed(waAccount, apps), nil
+}
+
+// autoSubscribeAccountWebhooks subscribes a newly connected account in the background.
+// The callback is only overridden when a public URL is configured.
+func (a *App) autoSubscribeAccountWebhooks(account models.WhatsAppAccount) {
+	var callbackURL string
+	if a.Config != nil && a.Config.Server.PublicURL != "" {
+		callbackURL = strings.TrimRight(a.Config.Server.PublicURL, "/") + webhookCallbackPath
+	}
+
+	_, subscribed, err := a.subscribeAccountWebhooks(&account, callbackURL)
+	if err != nil {
+		a.Log.Warn("Automatic webhook subscription failed", "error", err, "account", account.Name)
+		return
+	}
+	if !subscribed {
+		a.Log.Warn("Automatic webhook subscription could not be verified", "account", account.Name)
+		return
+	}
+	a.Log.Info("Account subscribed to webhooks", "account", account.Name, "callback_url", callbackURL)
+}
@@ -38,6 +38,9 @@ type WebhookResponse struct {
 var AvailableWebhookEvents = []map[string]string{
 	{"value": string(models.WebhookEventMessageIncoming), "label": "Message Incoming", "description": "When a new message is received from a contact"},
 	{"value": string(models.WebhookEventMessageSent), "label": "Message Sent", "description": "When an agent sends a message"},
+	{"value": string(models.WebhookEventMessageDelivered), "label": "Message Delivered", "description": "When WhatsApp reports an outgoing message delivered to the contact"},
+	{"value": string(models.WebhookEventMessageRead), "label": "Message Read", "description": "When the contact reads an outgoing message"},
+	{"value": string(models.WebhookEventMessageFailed), "label": "Message Failed", "description": "When an outgoing message fails to send or deliver"},
 	{"value": string(models.WebhookEventContactCreated), "label": "Contact Created", "description": "When a new contact is created"},
 	{"value": string(models.WebhookEventTransferCreated), "label": "Transfer Created", "description": "When a transfer to human agent is requested"},
 	{"value": string(models.WebhookEventTransferAssigned), "label": "Transfer Assigned", "description": "When a transfer is assigned to an agent"},
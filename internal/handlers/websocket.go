@@ -32,6 +32,9 @@ func (a *App) WebSocketHandler(r *fastglue.Request) error {
 		a.Log.Error("WebSocket auth failed", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid token", nil, "")
 	}
+	if !a.IsSuperAdmin(userID) && a.IsOrganizationSuspended(orgID) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Your organization has been suspended", nil, "")
+	}
 
 	// Upgrade to WebSocket
 	err = upgrader.Upgrade(r.RequestCtx, func(conn *websocket.Conn) {
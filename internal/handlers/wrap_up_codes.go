@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// maxWrapUpCodeLen matches the size of the code column
+const maxWrapUpCodeLen = 50
+
+// WrapUpCodeRequest represents the request body for creating/updating a wrap-up code
+type WrapUpCodeRequest struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// WrapUpCodeStats is how many resolved conversations were wrapped up with a code
+type WrapUpCodeStats struct {
+	CodeID  uuid.UUID `json:"code_id"`
+	Code    string    `json:"code"`
+	Name    string    `json:"name"`
+	Count   int64     `json:"count"`
+	Percent float64   `json:"percent"`
+}
+
+// WrapUpAnalyticsResponse breaks resolved conversations down by wrap-up code
+type WrapUpAnalyticsResponse struct {
+	TotalResolved int64             `json:"total_resolved"`
+	Uncoded       int64             `json:"uncoded"` // Resolved without a code
+	Codes         []WrapUpCodeStats `json:"codes"`
+}
+
+// ListWrapUpCodes returns the organization's wrap-up codes. Any member can list them
+// since agents pick one when resolving. Given a whatsapp_account, it also returns that
+// account's wrap-up settings for the resolve dialog.
+func (a *App) ListWrapUpCodes(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if string(r.RequestCtx.QueryArgs().Peek("active_only")) == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var codes []models.WrapUpCode
+	if err := query.Order("name ASC").Find(&codes).Error; err != nil {
+		a.Log.Error("Failed to list wrap-up codes", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list wrap-up codes", nil, "")
+	}
+
+	response := map[string]interface{}{
+		"wrap_up_codes": codes,
+	}
+	if account := string(r.RequestCtx.QueryArgs().Peek("whatsapp_account")); account != "" {
+		var wrapUp models.WrapUpConfig
+		if settings, err := a.getChatbotSettingsCached(orgID, account); err == nil {
+			wrapUp = settings.WrapUp
+		}
+		response["require_code"] = wrapUp.RequireCode
+		response["closing_message"] = wrapUp.ClosingMessage
+	}
+	return r.SendEnvelope(response)
+}
+
+// CreateWrapUpCode creates a wrap-up code
+func (a *App) CreateWrapUpCode(r *fastglue.Request) error {
+	orgID, err := a.chatbotSettingsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+
+	var req WrapUpCodeRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := validateWrapUpCodeRequest(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if a.wrapUpCodeTaken(orgID, req.Code, uuid.Nil) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A wrap-up code with this code already exists", nil, "")
+	}
+
+	code := models.WrapUpCode{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: orgID,
+		Code:           req.Code,
+		Name:           req.Name,
+		Description:    req.Description,
+		IsActive:       req.IsActive == nil || *req.IsActive,
+	}
+	if err := a.DB.Create(&code).Error; err != nil {
+		a.Log.Error("Failed to create wrap-up code", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create wrap-up code", nil, "")
+	}
+
+	return r.SendEnvelope(code)
+}
+
+// UpdateWrapUpCode updates a wrap-up code
+func (a *App) UpdateWrapUpCode(r *fastglue.Request) error {
+	orgID, err := a.chatbotSettingsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	code, err := a.findWrapUpCode(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	var req WrapUpCodeRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := validateWrapUpCodeRequest(&req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if a.wrapUpCodeTaken(orgID, req.Code, code.ID) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A wrap-up code with this code already exists", nil, "")
+	}
+
+	updates := map[string]interface{}{
+		"code":        req.Code,
+		"name":        req.Name,
+		"description": req.Description,
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if err := a.DB.Model(code).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to update wrap-up code", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update wrap-up code", nil, "")
+	}
+	a.DB.First(code, code.ID)
+
+	return r.SendEnvelope(code)
+}
+
+// DeleteWrapUpCode deletes a wrap-up code. Conversations resolved with it keep the
+// code for reporting.
+func (a *App) DeleteWrapUpCode(r *fastglue.Request) error {
+	orgID, err := a.chatbotSettingsAccess(r, models.ActionWrite)
+	if err != nil {
+		return nil
+	}
+	code, err := a.findWrapUpCode(r, orgID)
+	if err != nil {
+		return nil
+	}
+
+	if err := a.DB.Delete(code).Error; err != nil {
+		a.Log.Error("Failed to delete wrap-up code", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete wrap-up code", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Wrap-up code deleted"})
+}
+
+// GetWrapUpAnalytics counts the conversations resolved in the period by wrap-up code,
+// most used first
+func (a *App) GetWrapUpAnalytics(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !a.HasPermission(userID, orgID, models.ResourceAnalytics, models.ActionRead) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Insufficient permissions", nil, "")
+	}
+
+	periodStart, periodEnd, err := parseAnalyticsPeriod(r, a.getOrgLocation(orgID))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	response, err := a.calculateWrapUpStats(orgID, periodStart, periodEnd)
+	if err != nil {
+		a.Log.Error("Failed to calculate wrap-up analytics", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load wrap-up analytics", nil, "")
+	}
+	return r.SendEnvelope(response)
+}
+
+// calculateWrapUpStats counts the transfers resolved in the range by wrap-up code.
+// Deleted codes still count under their name.
+func (a *App) calculateWrapUpStats(orgID uuid.UUID, start, end time.Time) (WrapUpAnalyticsResponse, error) {
+	response := WrapUpAnalyticsResponse{Codes: []WrapUpCodeStats{}}
+
+	resolved := a.DB.Model(&models.AgentTransfer{}).
+		Where("organization_id = ? AND status = ? AND resumed_at >= ? AND resumed_at <= ?",
+			orgID, models.TransferStatusResumed, start, end)
+	if err := resolved.Count(&response.TotalResolved).Error; err != nil {
+		return response, err
+	}
+
+	if err := a.DB.Table("agent_transfers").
+		Select("wrap_up_codes.id AS code_id, wrap_up_codes.code, wrap_up_codes.name, COUNT(*) AS count").
+		Joins("JOIN wrap_up_codes ON wrap_up_codes.id = agent_transfers.wrap_up_code_id").
+		Where("agent_transfers.organization_id = ? AND agent_transfers.status = ? AND agent_transfers.deleted_at IS NULL",
+			orgID, models.TransferStatusResumed).
+		Where("agent_transfers.resumed_at >= ? AND agent_transfers.resumed_at <= ?", start, end).
+		Group("wrap_up_codes.id, wrap_up_codes.code, wrap_up_codes.name").
+		Order("count DESC, wrap_up_codes.name").
+		Scan(&response.Codes).Error; err != nil {
+		return response, err
+	}
+
+	coded := int64(0)
+	for i := range response.Codes {
+		coded += response.Codes[i].Count
+		if response.TotalResolved > 0 {
+			response.Codes[i].Percent = float64(response.Codes[i].Count) * 100 / float64(response.TotalResolved)
+		}
+	}
+	response.Uncoded = response.TotalResolved - coded
+	return response, nil
+}
+
+// findWrapUpCode loads the wrap-up code named by the id path parameter, sending a 4xx if missing
+func (a *App) findWrapUpCode(r *fastglue.Request, orgID uuid.UUID) (*models.WrapUpCode, error) {
+	id, err := uuid.Parse(fmt.Sprint(r.RequestCtx.UserValue("id")))
+	if err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid wrap-up code ID", nil, "")
+		return nil, err
+	}
+	var code models.WrapUpCode
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&code).Error; err != nil {
+		_ = r.SendErrorEnvelope(fasthttp.StatusNotFound, "Wrap-up code not found", nil, "")
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (a *App) wrapUpCodeTaken(orgID uuid.UUID, code string, exceptID uuid.UUID) bool {
+	var count int64
+	a.DB.Model(&models.WrapUpCode{}).
+		Where("organization_id = ? AND code = ? AND id <> ?", orgID, code, exceptID).
+		Count(&count)
+	return count > 0
+}
+
+// validateWrapUpCodeRequest checks a wrap-up code request.
+// Returns an error message, or "" when valid.
+func validateWrapUpCodeRequest(req *WrapUpCodeRequest) string {
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		return "Code and name are required"
+	}
+	if len(req.Code) > maxWrapUpCodeLen {
+		return fmt.Sprintf("Code must be at most %d characters", maxWrapUpCodeLen)
+	}
+	return ""
+}
+
+// activeWrapUpCode returns the organization's wrap-up code if it can still be picked
+func (a *App) activeWrapUpCode(orgID, id uuid.UUID) (*models.WrapUpCode, error) {
+	var code models.WrapUpCode
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&code).Error; err != nil {
+		return nil, err
+	}
+	if !code.IsActive {
+		return nil, errors.New("wrap-up code is inactive")
+	}
+	return &code, nil
+}
+
+// sendClosingMessage sends the closing message to the contact of a resolved transfer
+func (a *App) sendClosingMessage(transfer models.AgentTransfer, message string) {
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", transfer.WhatsAppAccount, transfer.OrganizationID).First(&account).Error; err != nil {
+		a.Log.Error("Failed to load account for closing message", "error", err, "account", transfer.WhatsAppAccount)
+		return
+	}
+
+	var contact models.Contact
+	if err := a.DB.Where("id = ?", transfer.ContactID).First(&contact).Error; err != nil {
+		a.Log.Error("Failed to load contact for closing message", "error", err, "contact_id", transfer.ContactID)
+		return
+	}
+
+	if _, err := a.SendOutgoingMessage(context.Background(), OutgoingMessageRequest{
+		Account: &account,
+		Contact: &contact,
+		Type:    models.MessageTypeText,
+		Content: a.resolveOrgVariables(transfer.OrganizationID, message),
+	}, SLASendOptions()); err != nil {
+		a.Log.Error("Failed to send closing message", "error", err, "contact_id", contact.ID)
+	}
+}
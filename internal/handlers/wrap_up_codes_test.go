@@ -0,0 +1,138 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestApp_WrapUpCodes_CRUD(t *testing.T) {
+	app := testApp(t)
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	agent := createTestAgent(t, app, org.ID)
+
+	req := testutil.NewJSONRequest(t, map[string]any{"code": " billing ", "name": "Billing question"})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.CreateWrapUpCode(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var created struct {
+		Data models.WrapUpCode `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &created)
+	assert.Equal(t, "billing", created.Data.Code)
+	assert.True(t, created.Data.IsActive)
+
+	req = testutil.NewJSONRequest(t, map[string]any{"code": "billing", "name": "Duplicate"})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.CreateWrapUpCode(req))
+	testutil.AssertErrorResponse(t, req, fasthttp.StatusConflict, "A wrap-up code with this code already exists")
+
+	// Agents can list the codes but not manage them
+	req = testutil.NewJSONRequest(t, map[string]any{"code": "refund", "name": "Refund"})
+	setTransferAuthContext(req, org.ID, agent.ID)
+	require.NoError(t, app.CreateWrapUpCode(req))
+	assert.Equal(t, fasthttp.StatusForbidden, testutil.GetResponseStatusCode(req))
+
+	req = testutil.NewJSONRequest(t, map[string]any{"code": "billing", "name": "Billing", "is_active": false})
+	setTransferAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", created.Data.ID.String())
+	require.NoError(t, app.UpdateWrapUpCode(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	list := func(activeOnly bool) []models.WrapUpCode {
+		req := testutil.NewGETRequest(t)
+		if activeOnly {
+			testutil.SetQueryParam(req, "active_only", "true")
+		}
+		setTransferAuthContext(req, org.ID, agent.ID)
+		require.NoError(t, app.ListWrapUpCodes(req))
+		var resp struct {
+			Data struct {
+				WrapUpCodes []models.WrapUpCode `json:"wrap_up_codes"`
+			} `json:"data"`
+		}
+		testutil.ParseJSONResponse(t, req, &resp)
+		return resp.Data.WrapUpCodes
+	}
+	require.Len(t, list(false), 1)
+	assert.Equal(t, "Billing", list(false)[0].Name)
+	assert.Empty(t, list(true))
+
+	req = testutil.NewJSONRequest(t, nil)
+	setTransferAuthContext(req, org.ID, admin.ID)
+	testutil.SetPathParam(req, "id", created.Data.ID.String())
+	require.NoError(t, app.DeleteWrapUpCode(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	assert.Empty(t, list(false))
+}
+
+func TestApp_ResumeFromTransfer_WrapUp(t *testing.T) {
+	app := testApp(t)
+	if app.Redis == nil {
+		t.Skip("resuming a transfer reads the cached chatbot settings, which needs Redis")
+	}
+	org := createTestOrg(t, app)
+	admin := createTransferTestUser(t, app, org.ID, &createTransferAdminRole(t, app.DB, org.ID).ID)
+	account := createTransferTestAccount(t, app, org.ID)
+
+	require.NoError(t, app.DB.Create(&models.ChatbotSettings{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		OrganizationID: org.ID,
+		WrapUp:         models.WrapUpConfig{RequireCode: true},
+	}).Error)
+
+	billing := models.WrapUpCode{BaseModel: models.BaseModel{ID: uuid.New()}, OrganizationID: org.ID, Code: "billing", Name: "Billing", IsActive: true}
+	retired := models.WrapUpCode{BaseModel: models.BaseModel{ID: uuid.New()}, OrganizationID: org.ID, Code: "old", Name: "Old", IsActive: true}
+	require.NoError(t, app.DB.Create(&billing).Error)
+	require.NoError(t, app.DB.Create(&retired).Error)
+	require.NoError(t, app.DB.Model(&retired).Update("is_active", false).Error)
+
+	resume := func(transfer *models.AgentTransfer, body map[string]any) *fastglue.Request {
+		req := testutil.NewJSONRequest(t, body)
+		setTransferAuthContext(req, org.ID, admin.ID)
+		testutil.SetPathParam(req, "id", transfer.ID.String())
+		require.NoError(t, app.ResumeFromTransfer(req))
+		return req
+	}
+
+	transfer := createTestTransfer(t, app, org.ID, createTestContact(t, app, org.ID).ID, account.Name, models.TransferStatusActive, nil)
+	testutil.AssertErrorResponse(t, resume(transfer, nil), fasthttp.StatusBadRequest, "A wrap-up code is required to resolve this conversation")
+	testutil.AssertErrorResponse(t, resume(transfer, map[string]any{"wrap_up_code_id": retired.ID}), fasthttp.StatusBadRequest, "Wrap-up code not found or inactive")
+
+	req := resume(transfer, map[string]any{"wrap_up_code_id": billing.ID, "wrap_up_notes": " Asked about an invoice ", "send_closing_message": false})
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+
+	var resolved models.AgentTransfer
+	require.NoError(t, app.DB.First(&resolved, transfer.ID).Error)
+	assert.Equal(t, models.TransferStatusResumed, resolved.Status)
+	require.NotNil(t, resolved.WrapUpCodeID)
+	assert.Equal(t, billing.ID, *resolved.WrapUpCodeID)
+	assert.Equal(t, "Asked about an invoice", resolved.WrapUpNotes)
+
+	// Resolved by the system without a code
+	uncoded := createTestTransfer(t, app, org.ID, createTestContact(t, app, org.ID).ID, account.Name, models.TransferStatusResumed, nil)
+	require.NoError(t, app.DB.Model(uncoded).Update("resumed_at", resolved.ResumedAt).Error)
+
+	req = testutil.NewGETRequest(t)
+	setTransferAuthContext(req, org.ID, admin.ID)
+	require.NoError(t, app.GetWrapUpAnalytics(req))
+	require.Equal(t, fasthttp.StatusOK, testutil.GetResponseStatusCode(req))
+	var analytics struct {
+		Data handlers.WrapUpAnalyticsResponse `json:"data"`
+	}
+	testutil.ParseJSONResponse(t, req, &analytics)
+	assert.Equal(t, int64(2), analytics.Data.TotalResolved)
+	assert.Equal(t, int64(1), analytics.Data.Uncoded)
+	require.Len(t, analytics.Data.Codes, 1)
+	assert.Equal(t, "billing", analytics.Data.Codes[0].Code)
+	assert.Equal(t, int64(1), analytics.Data.Codes[0].Count)
+	assert.InDelta(t, 50.0, analytics.Data.Codes[0].Percent, 0.01)
+}
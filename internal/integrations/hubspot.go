@@ -0,0 +1,118 @@
+package integrations
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hubSpotNoteToContact is HubSpot's association type for a note on a contact
+const hubSpotNoteToContact = 202
+
+// hubSpot talks to the HubSpot CRM v3 API
+type hubSpot struct {
+	client  *http.Client
+	baseURL string
+}
+
+// hubSpotObject is a CRM object as HubSpot returns it
+type hubSpotObject struct {
+	ID         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+func (h *hubSpot) UpsertContact(ctx context.Context, contact Contact) (string, error) {
+	id, err := h.findContact(ctx, contact.Phone)
+	if err != nil {
+		return "", err
+	}
+
+	properties := map[string]string{"phone": contact.Phone}
+	if contact.FirstName != "" {
+		properties["firstname"] = contact.FirstName
+	}
+	if contact.LastName != "" {
+		properties["lastname"] = contact.LastName
+	}
+
+	method, endpoint := http.MethodPost, h.baseURL+"/crm/v3/objects/contacts"
+	if id != "" {
+		method, endpoint = http.MethodPatch, endpoint+"/"+url.PathEscape(id)
+	}
+	var result hubSpotObject
+	if err := h.do(ctx, method, endpoint, map[string]interface{}{"properties": properties}, &result); err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// findContact returns the ID of the contact with phone, or "" if there is none
+func (h *hubSpot) findContact(ctx context.Context, phone string) (string, error) {
+	search := map[string]interface{}{
+		"filterGroups": []map[string]interface{}{{
+			"filters": []map[string]string{{"propertyName": "phone", "operator": "EQ", "value": phone}},
+		}},
+		"properties": []string{"phone"},
+		"limit":      1,
+	}
+	var result struct {
+		Results []hubSpotObject `json:"results"`
+	}
+	if err := h.do(ctx, http.MethodPost, h.baseURL+"/crm/v3/objects/contacts/search", search, &result); err != nil {
+		return "", err
+	}
+	if len(result.Results) == 0 {
+		return "", nil
+	}
+	return result.Results[0].ID, nil
+}
+
+func (h *hubSpot) AddNote(ctx context.Context, recordID string, note Note) error {
+	body := htmlEscape(note.Body)
+	if note.Title != "" {
+		body = "<strong>" + htmlEscape(note.Title) + "</strong><br>" + body
+	}
+	payload := map[string]interface{}{
+		"properties": map[string]string{
+			"hs_note_body": body,
+			"hs_timestamp": note.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		},
+		"associations": []map[string]interface{}{{
+			"to": map[string]string{"id": recordID},
+			"types": []map[string]interface{}{{
+				"associationCategory": "HUBSPOT_DEFINED",
+				"associationTypeId":   hubSpotNoteToContact,
+			}},
+		}},
+	}
+	return h.do(ctx, http.MethodPost, h.baseURL+"/crm/v3/objects/notes", payload, nil)
+}
+
+func (h *hubSpot) FetchProperties(ctx context.Context, recordID string, names []string) (map[string]string, error) {
+	endpoint := h.baseURL + "/crm/v3/objects/contacts/" + url.PathEscape(recordID) +
+		"?properties=" + url.QueryEscape(strings.Join(names, ","))
+	var result hubSpotObject
+	if err := h.do(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		if v := propertyString(result.Properties[name]); v != "" {
+			values[name] = v
+		}
+	}
+	return values, nil
+}
+
+func (h *hubSpot) do(ctx context.Context, method, endpoint string, payload, out interface{}) error {
+	return doRequest(ctx, h.client, method, endpoint, "hubspot", payload, out)
+}
+
+// htmlEscape escapes text for HubSpot's HTML note body, keeping line breaks
+func htmlEscape(text string) string {
+	return strings.ReplaceAll(htmlReplacer.Replace(text), "\n", "<br>")
+}
+
+var htmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
@@ -0,0 +1,217 @@
+// Package integrations syncs contacts and conversation summaries with HubSpot and
+// Salesforce, and reads CRM properties back for contact custom fields.
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Supported CRM providers
+const (
+	ProviderHubSpot    = "hubspot"
+	ProviderSalesforce = "salesforce"
+)
+
+// Scopes are the OAuth scopes needed per provider to manage contacts and log notes
+var Scopes = map[string][]string{
+	ProviderHubSpot:    {"oauth", "crm.objects.contacts.read", "crm.objects.contacts.write"},
+	ProviderSalesforce: {"api", "refresh_token"},
+}
+
+// Endpoints (overridable in tests). Salesforce API calls go to the instance URL returned
+// with the token rather than a fixed host.
+var (
+	HubSpotBaseURL     = "https://api.hubapi.com"
+	HubSpotAppURL      = "https://app.hubspot.com"
+	SalesforceLoginURL = "https://login.salesforce.com"
+)
+
+// ErrRecordNotFound is returned when a linked CRM record no longer exists
+var ErrRecordNotFound = errors.New("crm record not found")
+
+// propertyNamePattern matches HubSpot property and Salesforce field API names
+var propertyNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,99}$`)
+
+// Contact is a WhatsApp contact as written to a CRM
+type Contact struct {
+	Phone     string // E.164, with a leading +
+	FirstName string
+	LastName  string
+}
+
+// Note is a conversation summary logged against a CRM contact
+type Note struct {
+	Title string
+	Body  string
+	Time  time.Time
+}
+
+// Connector reads and writes contacts in a CRM. The HTTP client it was created with
+// must attach OAuth credentials.
+type Connector interface {
+	// UpsertContact finds the contact by phone number, creating it if needed, and
+	// returns the CRM record ID
+	UpsertContact(ctx context.Context, contact Contact) (string, error)
+	// AddNote logs a note on a contact record
+	AddNote(ctx context.Context, recordID string, note Note) error
+	// FetchProperties reads properties of a contact record. Empty values are omitted.
+	FetchProperties(ctx context.Context, recordID string, names []string) (map[string]string, error)
+}
+
+// IsSupported reports whether provider has a CRM connector
+func IsSupported(provider string) bool {
+	_, ok := Scopes[provider]
+	return ok
+}
+
+// ValidPropertyName reports whether name can be a CRM property or field name
+func ValidPropertyName(name string) bool {
+	return propertyNamePattern.MatchString(name)
+}
+
+// Endpoint returns the OAuth endpoint for provider. Both providers expect the client
+// credentials in the token request body.
+func Endpoint(provider string) oauth2.Endpoint {
+	switch provider {
+	case ProviderHubSpot:
+		return oauth2.Endpoint{
+			AuthURL:   HubSpotAppURL + "/oauth/authorize",
+			TokenURL:  HubSpotBaseURL + "/oauth/v1/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		}
+	case ProviderSalesforce:
+		return oauth2.Endpoint{
+			AuthURL:   SalesforceLoginURL + "/services/oauth2/authorize",
+			TokenURL:  SalesforceLoginURL + "/services/oauth2/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		}
+	default:
+		return oauth2.Endpoint{}
+	}
+}
+
+// NewConnector returns the connector for provider. instanceURL is the Salesforce API
+// host and is ignored for HubSpot.
+func NewConnector(provider string, client *http.Client, instanceURL string) (Connector, error) {
+	switch provider {
+	case ProviderHubSpot:
+		return &hubSpot{client: client, baseURL: HubSpotBaseURL}, nil
+	case ProviderSalesforce:
+		if instanceURL == "" {
+			return nil, errors.New("salesforce instance URL is missing, reconnect Salesforce")
+		}
+		return &salesforce{client: client, baseURL: strings.TrimRight(instanceURL, "/") + "/services/data/" + SalesforceAPIVersion}, nil
+	default:
+		return nil, fmt.Errorf("unsupported CRM provider: %s", provider)
+	}
+}
+
+// SplitName splits a display name into first and last names
+func SplitName(name string) (string, string) {
+	fields := strings.Fields(name)
+	switch len(fields) {
+	case 0:
+		return "", ""
+	case 1:
+		return fields[0], ""
+	default:
+		return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+	}
+}
+
+// propertyString formats a CRM property value; nulls become ""
+func propertyString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// doRequest sends a request with an optional JSON payload and decodes the response
+func doRequest(ctx context.Context, client *http.Client, method, endpoint, provider string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	return doJSON(client, req, provider, out)
+}
+
+// doJSON sends req and decodes a successful JSON response into out (when non-nil).
+// provider names the CRM in errors.
+func doJSON(client *http.Client, req *http.Request, provider string, out interface{}) error {
+	if req.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", provider, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", provider, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrRecordNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if msg := apiErrorMessage(body); msg != "" {
+			return fmt.Errorf("%s: %s", provider, msg)
+		}
+		return fmt.Errorf("%s request failed with status %d", provider, resp.StatusCode)
+	}
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", provider, err)
+	}
+	return nil
+}
+
+// apiErrorMessage extracts the message from a HubSpot error object or a Salesforce
+// error list
+func apiErrorMessage(body []byte) string {
+	var single struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &single) == nil && single.Message != "" {
+		return single.Message
+	}
+	var list []struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &list) == nil && len(list) > 0 {
+		return list[0].Message
+	}
+	return ""
+}
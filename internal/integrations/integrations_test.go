@@ -0,0 +1,130 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitName(t *testing.T) {
+	first, last := SplitName("  Ada  King Lovelace ")
+	assert.Equal(t, "Ada King", first)
+	assert.Equal(t, "Lovelace", last)
+
+	first, last = SplitName("Ada")
+	assert.Equal(t, "Ada", first)
+	assert.Empty(t, last)
+}
+
+func TestValidPropertyName(t *testing.T) {
+	assert.True(t, ValidPropertyName("lifecyclestage"))
+	assert.True(t, ValidPropertyName("Account_Tier__c"))
+	assert.False(t, ValidPropertyName("Name, Email"))
+	assert.False(t, ValidPropertyName("1st"))
+	assert.False(t, ValidPropertyName(""))
+}
+
+func TestHubSpot(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /crm/v3/objects/contacts/search":
+			_, _ = w.Write([]byte(`{"total":0,"results":[]}`))
+		case "POST /crm/v3/objects/contacts":
+			_, _ = w.Write([]byte(`{"id":"501","properties":{}}`))
+		case "POST /crm/v3/objects/notes":
+			_, _ = w.Write([]byte(`{"id":"9"}`))
+		case "GET /crm/v3/objects/contacts/501":
+			assert.Equal(t, "lifecyclestage,hs_lead_status", r.URL.Query().Get("properties"))
+			_, _ = w.Write([]byte(`{"id":"501","properties":{"lifecyclestage":"customer","hs_lead_status":null}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	orig := HubSpotBaseURL
+	HubSpotBaseURL = server.URL
+	defer func() { HubSpotBaseURL = orig }()
+
+	conn, err := NewConnector(ProviderHubSpot, server.Client(), "")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	id, err := conn.UpsertContact(ctx, Contact{Phone: "+14155550100", FirstName: "Ada"})
+	require.NoError(t, err)
+	assert.Equal(t, "501", id)
+	assert.Equal(t, map[string]interface{}{"phone": "+14155550100", "firstname": "Ada"}, bodies[1]["properties"])
+
+	require.NoError(t, conn.AddNote(ctx, id, Note{Title: "WhatsApp", Body: "Asked about <refunds>", Time: time.Now()}))
+	note := bodies[2]["properties"].(map[string]interface{})
+	assert.Equal(t, "<strong>WhatsApp</strong><br>Asked about &lt;refunds&gt;", note["hs_note_body"])
+
+	props, err := conn.FetchProperties(ctx, id, []string{"lifecyclestage", "hs_lead_status"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"lifecyclestage": "customer"}, props)
+
+	_, err = conn.FetchProperties(ctx, "404", []string{"lifecyclestage"})
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestSalesforce(t *testing.T) {
+	var created map[string]interface{}
+	var task map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := "/services/data/" + SalesforceAPIVersion
+		switch r.Method + " " + r.URL.Path {
+		case "GET " + base + "/query":
+			assert.Equal(t, `SELECT Id FROM Contact WHERE Phone = '+1415\'5550100' LIMIT 1`, r.URL.Query().Get("q"))
+			_, _ = w.Write([]byte(`{"totalSize":0,"records":[]}`))
+		case "POST " + base + "/sobjects/Contact":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"003XX","success":true,"errors":[]}`))
+		case "POST " + base + "/sobjects/Task":
+			_ = json.NewDecoder(r.Body).Decode(&task)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"00TXX","success":true}`))
+		case "GET " + base + "/sobjects/Contact/003XX":
+			_, _ = w.Write([]byte(`{"Id":"003XX","Account_Tier__c":"Gold","NumberOfEmployees":250,"Title":null}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`[{"message":"No such column","errorCode":"INVALID_FIELD"}]`))
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewConnector(ProviderSalesforce, server.Client(), "")
+	assert.Error(t, err)
+
+	conn, err := NewConnector(ProviderSalesforce, server.Client(), server.URL+"/")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// LastName is required, so a single name moves there
+	id, err := conn.UpsertContact(ctx, Contact{Phone: "+1415'5550100", FirstName: "Ada"})
+	require.NoError(t, err)
+	assert.Equal(t, "003XX", id)
+	assert.Equal(t, map[string]interface{}{"Phone": "+1415'5550100", "LastName": "Ada"}, created)
+
+	require.NoError(t, conn.AddNote(ctx, id, Note{Title: "WhatsApp", Body: "Summary", Time: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)}))
+	assert.Equal(t, "003XX", task["WhoId"])
+	assert.Equal(t, "2026-03-02", task["ActivityDate"])
+
+	props, err := conn.FetchProperties(ctx, id, []string{"Account_Tier__c", "NumberOfEmployees", "Title"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Account_Tier__c": "Gold", "NumberOfEmployees": "250"}, props)
+
+	_, err = conn.FetchProperties(ctx, "other", []string{"Bogus"})
+	assert.EqualError(t, err, "salesforce: No such column")
+}
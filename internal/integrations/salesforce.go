@@ -0,0 +1,106 @@
+package integrations
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SalesforceAPIVersion is the Salesforce REST API version the connector uses
+const SalesforceAPIVersion = "v59.0"
+
+// salesforce talks to the Salesforce REST API of one org
+type salesforce struct {
+	client  *http.Client
+	baseURL string // Instance URL plus the versioned data path
+}
+
+// soqlEscaper escapes a value for a single-quoted SOQL string literal
+var soqlEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func (s *salesforce) UpsertContact(ctx context.Context, contact Contact) (string, error) {
+	id, err := s.findContact(ctx, contact.Phone)
+	if err != nil {
+		return "", err
+	}
+
+	fields := map[string]string{"Phone": contact.Phone}
+	if contact.FirstName != "" {
+		fields["FirstName"] = contact.FirstName
+	}
+	if contact.LastName != "" {
+		fields["LastName"] = contact.LastName
+	}
+
+	if id != "" {
+		// Updates answer 204 No Content
+		return id, s.do(ctx, http.MethodPatch, s.baseURL+"/sobjects/Contact/"+url.PathEscape(id), fields, nil)
+	}
+
+	// LastName is required on Salesforce contacts
+	if fields["LastName"] == "" {
+		fields["LastName"] = contact.FirstName
+		delete(fields, "FirstName")
+	}
+	if fields["LastName"] == "" {
+		fields["LastName"] = contact.Phone
+	}
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := s.do(ctx, http.MethodPost, s.baseURL+"/sobjects/Contact", fields, &result); err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// findContact returns the ID of the contact with phone, or "" if there is none
+func (s *salesforce) findContact(ctx context.Context, phone string) (string, error) {
+	query := "SELECT Id FROM Contact WHERE Phone = '" + soqlEscaper.Replace(phone) + "' LIMIT 1"
+	var result struct {
+		Records []struct {
+			ID string `json:"Id"`
+		} `json:"records"`
+	}
+	if err := s.do(ctx, http.MethodGet, s.baseURL+"/query?q="+url.QueryEscape(query), nil, &result); err != nil {
+		return "", err
+	}
+	if len(result.Records) == 0 {
+		return "", nil
+	}
+	return result.Records[0].ID, nil
+}
+
+// AddNote logs the note as a completed task, which shows in the contact's activity history
+func (s *salesforce) AddNote(ctx context.Context, recordID string, note Note) error {
+	task := map[string]string{
+		"WhoId":        recordID,
+		"Subject":      note.Title,
+		"Description":  note.Body,
+		"Status":       "Completed",
+		"ActivityDate": note.Time.UTC().Format("2006-01-02"),
+	}
+	return s.do(ctx, http.MethodPost, s.baseURL+"/sobjects/Task", task, nil)
+}
+
+func (s *salesforce) FetchProperties(ctx context.Context, recordID string, names []string) (map[string]string, error) {
+	endpoint := s.baseURL + "/sobjects/Contact/" + url.PathEscape(recordID) +
+		"?fields=" + url.QueryEscape(strings.Join(names, ","))
+	var result map[string]interface{}
+	if err := s.do(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		if v := propertyString(result[name]); v != "" {
+			values[name] = v
+		}
+	}
+	return values, nil
+}
+
+func (s *salesforce) do(ctx context.Context, method, endpoint string, payload, out interface{}) error {
+	return doRequest(ctx, s.client, method, endpoint, "salesforce", payload, out)
+}
@@ -0,0 +1,110 @@
+// Package ipaccess restricts where an organization's API keys and admins can be used from.
+package ipaccess
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Policy is an organization's IP allowlist. An empty list places no restriction.
+type Policy struct {
+	// APIKeyCIDRs limits requests authenticated with an API key
+	APIKeyCIDRs []string `json:"api_key_cidrs"`
+	// AdminCIDRs limits logins and sessions of users who can manage organization settings
+	AdminCIDRs []string `json:"admin_cidrs"`
+}
+
+// FromSettings reads the IP allowlist from organization settings
+func FromSettings(settings map[string]interface{}) Policy {
+	var policy Policy
+	raw, ok := settings["ip_access"]
+	if !ok || raw == nil {
+		return policy
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return policy
+	}
+	_ = json.Unmarshal(data, &policy)
+	return policy
+}
+
+// IsEmpty reports whether no ranges are configured
+func (p Policy) IsEmpty() bool {
+	return len(p.APIKeyCIDRs) == 0 && len(p.AdminCIDRs) == 0
+}
+
+// Normalize validates every range, turning bare addresses into single-host CIDRs
+func (p Policy) Normalize() (Policy, error) {
+	apiKeyCIDRs, err := normalizeCIDRs(p.APIKeyCIDRs)
+	if err != nil {
+		return Policy{}, err
+	}
+	adminCIDRs, err := normalizeCIDRs(p.AdminCIDRs)
+	if err != nil {
+		return Policy{}, err
+	}
+	return Policy{APIKeyCIDRs: apiKeyCIDRs, AdminCIDRs: adminCIDRs}, nil
+}
+
+// AllowsAPIKey reports whether an API key may be used from ip
+func (p Policy) AllowsAPIKey(ip string) bool {
+	return allowed(p.APIKeyCIDRs, ip)
+}
+
+// AllowsAdmin reports whether an admin may log in or use a session from ip
+func (p Policy) AllowsAdmin(ip string) bool {
+	return allowed(p.AdminCIDRs, ip)
+}
+
+// allowed reports whether ip falls in any of cidrs. An empty list allows everything;
+// an unparseable ip is never allowed by a non-empty list.
+func allowed(cidrs []string, ip string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCIDRs trims entries, drops empty ones and checks that each is a CIDR or address
+func normalizeCIDRs(list []string) ([]string, error) {
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", item)
+		}
+		result = append(result, network.String())
+	}
+	return result, nil
+}
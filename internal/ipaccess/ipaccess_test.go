@@ -0,0 +1,50 @@
+package ipaccess
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Normalize(t *testing.T) {
+	policy, err := Policy{
+		APIKeyCIDRs: []string{" 10.0.0.0/8 ", "", "203.0.113.7"},
+		AdminCIDRs:  []string{"2001:db8::1", "192.168.1.17/24"},
+	}.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "203.0.113.7/32"}, policy.APIKeyCIDRs)
+	assert.Equal(t, []string{"2001:db8::1/128", "192.168.1.0/24"}, policy.AdminCIDRs)
+
+	_, err = Policy{APIKeyCIDRs: []string{"10.0.0.0/33"}}.Normalize()
+	assert.Error(t, err)
+
+	_, err = Policy{AdminCIDRs: []string{"office"}}.Normalize()
+	assert.Error(t, err)
+}
+
+func TestPolicy_Allows(t *testing.T) {
+	policy := Policy{
+		APIKeyCIDRs: []string{"10.0.0.0/8", "2001:db8::/32"},
+	}
+
+	assert.True(t, policy.AllowsAPIKey("10.1.2.3"))
+	assert.True(t, policy.AllowsAPIKey("2001:db8::42"))
+	assert.False(t, policy.AllowsAPIKey("192.168.0.1"))
+	assert.False(t, policy.AllowsAPIKey("not-an-ip"))
+
+	// No admin ranges configured
+	assert.True(t, policy.AllowsAdmin("192.168.0.1"))
+}
+
+func TestFromSettings(t *testing.T) {
+	policy := FromSettings(map[string]interface{}{
+		"ip_access": map[string]interface{}{
+			"api_key_cidrs": []interface{}{"10.0.0.0/8"},
+		},
+	})
+	assert.Equal(t, []string{"10.0.0.0/8"}, policy.APIKeyCIDRs)
+	assert.Empty(t, policy.AdminCIDRs)
+
+	assert.True(t, FromSettings(nil).IsEmpty())
+}
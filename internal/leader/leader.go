@@ -0,0 +1,155 @@
+// Package leader elects one instance among several servers sharing a Redis to run
+// singleton background tasks. Leadership is a lease on a Redis key that the leader
+// keeps renewing; when it stops renewing, another instance takes over once the lease
+// expires.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/zerodha/logf"
+)
+
+// DefaultKey is the Redis key holding the lease of the server instances
+const DefaultKey = "whatomate:leader"
+
+// claimScript takes the lease when it is free and extends it when it is already ours
+var claimScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// releaseScript gives up the lease only if it is still ours
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Elector campaigns for a lease and runs tasks while holding it
+type Elector struct {
+	client  *redis.Client
+	key     string
+	id      string
+	ttl     time.Duration
+	log     logf.Logger
+	leading atomic.Bool
+}
+
+// New creates an elector for the lease at key. The lease expires ttl after the last
+// renewal, so ttl is also the longest time the tasks go unrun after a leader dies.
+func New(client *redis.Client, key string, ttl time.Duration, log logf.Logger) *Elector {
+	host, _ := os.Hostname()
+	return &Elector{
+		client: client,
+		key:    key,
+		id:     fmt.Sprintf("%s-%s", host, uuid.NewString()),
+		ttl:    ttl,
+		log:    log,
+	}
+}
+
+// ID returns the identity this instance holds the lease under
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Run campaigns for the lease until ctx is done. Each time the lease is won, tasks runs
+// with a context that is cancelled when the lease is lost; a new term doesn't start
+// before tasks has returned. On shutdown the lease is released so another instance
+// takes over right away.
+func (e *Elector) Run(ctx context.Context, tasks func(ctx context.Context)) {
+	// Renew well within the lease so a slow round trip doesn't cost the lease
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	var (
+		cancelTerm context.CancelFunc
+		term       sync.WaitGroup
+	)
+	stepDown := func() {
+		if cancelTerm == nil {
+			return
+		}
+		e.leading.Store(false)
+		cancelTerm()
+		term.Wait()
+		cancelTerm = nil
+	}
+
+	for {
+		ok, err := e.claim(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			// Without Redis the lease can't be confirmed, so stop rather than risk two leaders
+			if cancelTerm != nil {
+				e.log.Error("Failed to renew leadership, stepping down", "error", err)
+				stepDown()
+			} else {
+				e.log.Warn("Failed to claim leadership", "error", err)
+			}
+		case ok && cancelTerm == nil:
+			e.log.Info("Elected leader", "id", e.id)
+			var termCtx context.Context
+			termCtx, cancelTerm = context.WithCancel(ctx)
+			e.leading.Store(true)
+			term.Add(1)
+			go func() {
+				defer term.Done()
+				tasks(termCtx)
+			}()
+		case !ok && cancelTerm != nil:
+			e.log.Warn("Lost leadership", "id", e.id)
+			stepDown()
+		}
+
+		select {
+		case <-ctx.Done():
+			wasLeader := cancelTerm != nil
+			stepDown()
+			if wasLeader {
+				e.release()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim takes or renews the lease, reporting whether this instance holds it
+func (e *Elector) claim(ctx context.Context) (bool, error) {
+	n, err := claimScript.Run(ctx, e.client, []string{e.key}, e.id, e.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// release gives up the lease after the tasks have stopped
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := releaseScript.Run(ctx, e.client, []string{e.key}, e.id).Err(); err != nil {
+		e.log.Warn("Failed to release leadership", "error", err)
+		return
+	}
+	e.log.Info("Released leadership", "id", e.id)
+}
@@ -0,0 +1,94 @@
+package leader_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/leader"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runElector campaigns in the background and counts running terms
+func runElector(ctx context.Context, e *leader.Elector, running *atomic.Int32) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(ctx context.Context) {
+			running.Add(1)
+			<-ctx.Done()
+			running.Add(-1)
+		})
+	}()
+	return done
+}
+
+func TestElector_SingleLeaderWithFailover(t *testing.T) {
+	client := testutil.SetupTestRedis(t)
+	if client == nil {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+	key := "test:leader:" + uuid.NewString()
+	t.Cleanup(func() { client.Del(context.Background(), key) })
+
+	var running atomic.Int32
+	first := leader.New(client, key, 300*time.Millisecond, testutil.NopLogger())
+	second := leader.New(client, key, 300*time.Millisecond, testutil.NopLogger())
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := runElector(firstCtx, first, &running)
+	require.Eventually(t, first.IsLeader, 2*time.Second, 10*time.Millisecond)
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	secondDone := runElector(secondCtx, second, &running)
+
+	// Only one instance runs the tasks while both campaign
+	time.Sleep(500 * time.Millisecond)
+	assert.False(t, second.IsLeader())
+	assert.Equal(t, int32(1), running.Load())
+
+	// Shutting down the leader hands over to the other instance
+	stopFirst()
+	<-firstDone
+	assert.False(t, first.IsLeader())
+	require.Eventually(t, second.IsLeader, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), running.Load())
+
+	stopSecond()
+	<-secondDone
+	assert.Equal(t, int32(0), running.Load())
+	assert.Zero(t, client.Exists(context.Background(), key).Val())
+}
+
+func TestElector_StepsDownWhenLeaseIsLost(t *testing.T) {
+	client := testutil.SetupTestRedis(t)
+	if client == nil {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+	key := "test:leader:" + uuid.NewString()
+	t.Cleanup(func() { client.Del(context.Background(), key) })
+
+	var running atomic.Int32
+	e := leader.New(client, key, 300*time.Millisecond, testutil.NopLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runElector(ctx, e, &running)
+	defer func() {
+		cancel()
+		<-done
+	}()
+	require.Eventually(t, e.IsLeader, 2*time.Second, 10*time.Millisecond)
+
+	// Another instance took the lease, e.g. after this one stalled past its expiry
+	require.NoError(t, client.Set(context.Background(), key, "other-instance", time.Minute).Err())
+	require.Eventually(t, func() bool { return !e.IsLeader() && running.Load() == 0 }, 2*time.Second, 10*time.Millisecond)
+
+	// The lease is not released on shutdown since it belongs to the other instance
+	cancel()
+	<-done
+	assert.Equal(t, "other-instance", client.Get(context.Background(), key).Val())
+}
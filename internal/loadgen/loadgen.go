@@ -0,0 +1,427 @@
+// Package loadgen generates synthetic traffic against a running Whatomate server for
+// capacity planning. It posts inbound webhook messages and sends messages through the
+// API at fixed rates, while a mock Meta backend takes the server's outgoing calls.
+// Latencies are measured end to end: an inbound message counts as handled once it
+// reaches a WebSocket client, an API send once it reaches the mock Meta backend.
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/google/uuid"
+	"github.com/zerodha/logf"
+)
+
+// Config sets the target and the shape of the generated traffic
+type Config struct {
+	URL         string        // Base URL of the server under test
+	Email       string        // Login of the user sending messages and watching the WebSocket
+	Password    string        //
+	Account     string        // WhatsApp account to simulate; empty picks the first one
+	MockAddr    string        // Listen address of the mock Meta backend
+	MetaLatency time.Duration // Delay the mock Meta backend adds to each call
+	WebhookRate float64       // Inbound messages per second
+	SendRate    float64       // API sends per second
+	Contacts    int           // Number of distinct synthetic contacts
+	Duration    time.Duration // How long traffic is generated
+	Drain       time.Duration // How long to wait for outstanding messages afterwards
+}
+
+// Report holds the measured latencies of a run
+type Report struct {
+	Elapsed     time.Duration `json:"elapsed"`
+	WebhookAck  Summary       `json:"webhook_ack"`   // Webhook POST until the server acknowledged it
+	WebhookToWS Summary       `json:"webhook_to_ws"` // Webhook POST until the message reached the WebSocket
+	SendAPI     Summary       `json:"send_api"`      // Send API call until it returned
+	SendToMeta  Summary       `json:"send_to_meta"`  // Send API call until the message reached Meta
+}
+
+// Print writes the report as a table
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nLoad test finished in %s\n\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "%-26s %8s %8s %10s %10s %10s %10s\n", "", "count", "errors", "p50", "p95", "p99", "max")
+	row := func(name string, s Summary) {
+		fmt.Fprintf(w, "%-26s %8d %8d %10s %10s %10s %10s\n", name, s.Count, s.Errors,
+			s.P50.Round(time.Millisecond), s.P95.Round(time.Millisecond),
+			s.P99.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+	}
+	row("webhook ack", r.WebhookAck)
+	row("webhook -> websocket", r.WebhookToWS)
+	row("send api", r.SendAPI)
+	row("send api -> meta", r.SendToMeta)
+}
+
+// runner holds the state of one run
+type runner struct {
+	cfg    Config
+	log    logf.Logger
+	client *http.Client
+	runID  string
+
+	token   string
+	phoneID string
+
+	webhookAck  Latencies
+	webhookToWS Latencies
+	sendAPI     Latencies
+	sendToMeta  Latencies
+
+	mu           sync.Mutex
+	pendingIn    map[string]time.Time // Inbound message ID -> posted at
+	pendingOut   map[string]time.Time // Outbound text -> send started at
+	contactIDs   []string             // Contacts the server created for the synthetic numbers
+	knownContact map[string]bool
+}
+
+// Run generates traffic for cfg.Duration and reports the latencies
+func Run(ctx context.Context, cfg Config, log logf.Logger) (*Report, error) {
+	if cfg.Contacts < 1 {
+		cfg.Contacts = 1
+	}
+	r := &runner{
+		cfg:          cfg,
+		log:          log,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		runID:        uuid.NewString()[:8],
+		pendingIn:    make(map[string]time.Time),
+		pendingOut:   make(map[string]time.Time),
+		knownContact: make(map[string]bool),
+	}
+
+	// The server sends to the mock through its [whatsapp] base_url
+	ln, err := net.Listen("tcp", cfg.MockAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start mock Meta backend: %w", err)
+	}
+	mock := &http.Server{Handler: NewMockMeta(cfg.MetaLatency, r.sentToMeta)}
+	go func() { _ = mock.Serve(ln) }()
+	defer func() { _ = mock.Close() }()
+
+	if err := r.login(); err != nil {
+		return nil, err
+	}
+	if err := r.loadAccount(); err != nil {
+		return nil, err
+	}
+	ws, err := r.dialWebSocket()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = ws.Close() }()
+	go r.readWebSocket(ws)
+
+	log.Info("Generating traffic", "webhook_rate", cfg.WebhookRate, "send_rate", cfg.SendRate, "duration", cfg.Duration, "contacts", cfg.Contacts)
+	start := time.Now()
+	runCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	// Webhooks and sends share one sequence so every message text is unique
+	var wg sync.WaitGroup
+	var seq atomic.Int64
+	r.every(runCtx, &wg, cfg.WebhookRate, func() {
+		n := int(seq.Add(1))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.postWebhook(n)
+		}()
+	})
+	r.every(runCtx, &wg, cfg.SendRate, func() {
+		n := int(seq.Add(1))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.send(n)
+		}()
+	})
+	<-runCtx.Done()
+	wg.Wait()
+
+	r.drain(ctx)
+	return &Report{
+		Elapsed:     time.Since(start),
+		WebhookAck:  r.webhookAck.Summary(),
+		WebhookToWS: r.webhookToWS.Summary(),
+		SendAPI:     r.sendAPI.Summary(),
+		SendToMeta:  r.sendToMeta.Summary(),
+	}, nil
+}
+
+// every calls fn rate times per second until ctx is done. fn runs on the ticker's
+// goroutine, so it must not block.
+func (r *runner) every(ctx context.Context, wg *sync.WaitGroup, rate float64, fn func()) {
+	if rate <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
+
+// drain waits for outstanding messages, then counts the ones that never arrived as
+// failures
+func (r *runner) drain(ctx context.Context) {
+	deadline := time.Now().Add(r.cfg.Drain)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		r.mu.Lock()
+		outstanding := len(r.pendingIn) + len(r.pendingOut)
+		r.mu.Unlock()
+		if outstanding == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for range r.pendingIn {
+		r.webhookToWS.Fail()
+	}
+	for range r.pendingOut {
+		r.sendToMeta.Fail()
+	}
+	if n := len(r.pendingIn) + len(r.pendingOut); n > 0 {
+		r.log.Warn("Messages still outstanding after drain", "count", n)
+	}
+}
+
+// postWebhook simulates inbound message n from one of the synthetic contacts
+func (r *runner) postWebhook(n int) {
+	from := fmt.Sprintf("1555%07d", n%r.cfg.Contacts)
+	id := fmt.Sprintf("wamid.loadgen.%s.%d", r.runID, n)
+	now := time.Now()
+	payload := map[string]any{
+		"object": "whatsapp_business_account",
+		"entry": []map[string]any{{
+			"id": "loadgen",
+			"changes": []map[string]any{{
+				"field": "messages",
+				"value": map[string]any{
+					"messaging_product": "whatsapp",
+					"metadata":          map[string]string{"phone_number_id": r.phoneID},
+					"contacts": []map[string]any{{
+						"wa_id":   from,
+						"profile": map[string]string{"name": "Load " + from[len(from)-4:]},
+					}},
+					"messages": []map[string]any{{
+						"id":        id,
+						"from":      from,
+						"timestamp": fmt.Sprint(now.Unix()),
+						"type":      "text",
+						"text":      map[string]string{"body": fmt.Sprintf("loadgen inbound %d", n)},
+					}},
+				},
+			}},
+		}},
+	}
+
+	r.mu.Lock()
+	r.pendingIn[id] = now
+	r.mu.Unlock()
+
+	if err := r.call(http.MethodPost, "/api/webhook", "", payload, nil); err != nil {
+		r.log.Debug("Webhook failed", "error", err)
+		r.webhookAck.Fail()
+		r.mu.Lock()
+		delete(r.pendingIn, id)
+		r.mu.Unlock()
+		return
+	}
+	r.webhookAck.Add(time.Since(now))
+}
+
+// send sends message n through the API to a contact that has written in, so the
+// customer service window is open
+func (r *runner) send(n int) {
+	r.mu.Lock()
+	if len(r.contactIDs) == 0 {
+		r.mu.Unlock()
+		return
+	}
+	contactID := r.contactIDs[rand.Intn(len(r.contactIDs))]
+	text := fmt.Sprintf("loadgen %s outbound %d", r.runID, n)
+	now := time.Now()
+	r.pendingOut[text] = now
+	r.mu.Unlock()
+
+	body := map[string]any{"type": "text", "content": map[string]string{"body": text}}
+	if err := r.call(http.MethodPost, "/api/contacts/"+contactID+"/messages", r.token, body, nil); err != nil {
+		r.log.Debug("Send failed", "error", err)
+		r.sendAPI.Fail()
+		r.mu.Lock()
+		delete(r.pendingOut, text)
+		r.mu.Unlock()
+		return
+	}
+	r.sendAPI.Add(time.Since(now))
+}
+
+// sentToMeta is called by the mock Meta backend for every message the server sends
+func (r *runner) sentToMeta(_, text string) {
+	r.mu.Lock()
+	started, ok := r.pendingOut[text]
+	delete(r.pendingOut, text)
+	r.mu.Unlock()
+	if ok {
+		r.sendToMeta.Add(time.Since(started))
+	}
+}
+
+// readWebSocket records when inbound messages reach the WebSocket and learns the
+// contacts the server created for the synthetic numbers
+func (r *runner) readWebSocket(ws *websocket.Conn) {
+	for {
+		var msg struct {
+			Type    string `json:"type"`
+			Payload struct {
+				ContactID string `json:"contact_id"`
+				Direction string `json:"direction"`
+				WAMID     string `json:"wamid"`
+			} `json:"payload"`
+		}
+		if err := ws.ReadJSON(&msg); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				r.log.Debug("WebSocket closed", "error", err)
+			}
+			return
+		}
+		if msg.Type != "new_message" || msg.Payload.Direction != "incoming" {
+			continue
+		}
+
+		r.mu.Lock()
+		posted, ok := r.pendingIn[msg.Payload.WAMID]
+		delete(r.pendingIn, msg.Payload.WAMID)
+		if ok && msg.Payload.ContactID != "" && !r.knownContact[msg.Payload.ContactID] {
+			r.knownContact[msg.Payload.ContactID] = true
+			r.contactIDs = append(r.contactIDs, msg.Payload.ContactID)
+		}
+		r.mu.Unlock()
+		if ok {
+			r.webhookToWS.Add(time.Since(posted))
+		}
+	}
+}
+
+// login signs in and keeps the access token
+func (r *runner) login() error {
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := r.call(http.MethodPost, "/api/auth/login", "", map[string]string{
+		"email":    r.cfg.Email,
+		"password": r.cfg.Password,
+	}, &resp); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	r.token = resp.AccessToken
+	return nil
+}
+
+// loadAccount finds the phone number ID the inbound messages are addressed to
+func (r *runner) loadAccount() error {
+	var resp struct {
+		Accounts []struct {
+			Name    string `json:"name"`
+			PhoneID string `json:"phone_id"`
+		} `json:"accounts"`
+	}
+	if err := r.call(http.MethodGet, "/api/accounts", r.token, nil, &resp); err != nil {
+		return fmt.Errorf("failed to list WhatsApp accounts: %w", err)
+	}
+	for _, acc := range resp.Accounts {
+		if r.cfg.Account == "" || acc.Name == r.cfg.Account {
+			r.phoneID = acc.PhoneID
+			r.log.Info("Simulating WhatsApp account", "account", acc.Name, "phone_id", acc.PhoneID)
+			return nil
+		}
+	}
+	if r.cfg.Account == "" {
+		return errors.New("the organization has no WhatsApp account")
+	}
+	return fmt.Errorf("WhatsApp account %q not found", r.cfg.Account)
+}
+
+// dialWebSocket connects to the server's WebSocket as the logged in user
+func (r *runner) dialWebSocket() (*websocket.Conn, error) {
+	u, err := url.Parse(strings.TrimRight(r.cfg.URL, "/") + "/ws")
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.RawQuery = url.Values{"token": {r.token}}.Encode()
+
+	ws, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to WebSocket: %w", err)
+	}
+	return ws, nil
+}
+
+// call makes an API request and decodes the envelope's data into out when it isn't nil
+func (r *runner) call(method, path, token string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(r.cfg.URL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if out == nil {
+		return nil
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
@@ -0,0 +1,79 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencies_Summary(t *testing.T) {
+	var l Latencies
+	assert.Equal(t, Summary{}, l.Summary())
+
+	// 1ms..100ms in reverse order
+	for i := 100; i >= 1; i-- {
+		l.Add(time.Duration(i) * time.Millisecond)
+	}
+	l.Fail()
+	l.Fail()
+
+	s := l.Summary()
+	assert.Equal(t, 100, s.Count)
+	assert.Equal(t, 2, s.Errors)
+	assert.Equal(t, 50*time.Millisecond, s.P50)
+	assert.Equal(t, 95*time.Millisecond, s.P95)
+	assert.Equal(t, 99*time.Millisecond, s.P99)
+	assert.Equal(t, 100*time.Millisecond, s.Max)
+}
+
+func TestPercentile_FewSamples(t *testing.T) {
+	sorted := []time.Duration{10, 20, 30}
+	assert.Equal(t, time.Duration(10), percentile(sorted, 0))
+	assert.Equal(t, time.Duration(20), percentile(sorted, 50))
+	assert.Equal(t, time.Duration(30), percentile(sorted, 95))
+	assert.Equal(t, time.Duration(30), percentile(sorted, 100))
+}
+
+func TestMockMeta_Send(t *testing.T) {
+	var gotTo, gotText string
+	srv := httptest.NewServer(NewMockMeta(0, func(to, text string) {
+		gotTo, gotText = to, text
+	}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v21.0/12345/messages", "application/json",
+		strings.NewReader(`{"messaging_product":"whatsapp","to":"+15550001","type":"text","text":{"body":"hi"}}`))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Messages, 1)
+	assert.NotEmpty(t, body.Messages[0].ID)
+	assert.Equal(t, "+15550001", gotTo)
+	assert.Equal(t, "hi", gotText)
+}
+
+func TestMockMeta_ReadReceiptNotReported(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(NewMockMeta(0, func(_, _ string) { called = true }))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v21.0/12345/messages", "application/json",
+		strings.NewReader(`{"messaging_product":"whatsapp","status":"read","message_id":"wamid.1"}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.False(t, called)
+}
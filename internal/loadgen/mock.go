@@ -0,0 +1,69 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MockMeta stands in for Meta's Graph API. Message sends are accepted after an
+// optional delay that mimics Meta's response time, and reported to onSend; every other
+// call gets an empty success response.
+type MockMeta struct {
+	latency time.Duration
+	onSend  func(to, text string)
+	seq     atomic.Int64
+}
+
+// NewMockMeta creates a mock Graph API. onSend receives the recipient and text of each
+// message sent and may be nil.
+func NewMockMeta(latency time.Duration, onSend func(to, text string)) *MockMeta {
+	return &MockMeta{latency: latency, onSend: onSend}
+}
+
+// ServeHTTP handles a Graph API call
+func (m *MockMeta) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if m.latency > 0 {
+		time.Sleep(m.latency)
+	}
+
+	// Sends are POST /{version}/{phone-id}/messages
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodPost || len(parts) != 3 || parts[2] != "messages" {
+		_, _ = io.WriteString(w, `{"success":true}`)
+		return
+	}
+
+	var body struct {
+		To     string `json:"to"`
+		Status string `json:"status"`
+		Text   struct {
+			Body string `json:"body"`
+		} `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"message":"Invalid JSON","code":100}}`)
+		return
+	}
+
+	// Read receipts go to the same endpoint
+	if body.Status != "" {
+		_, _ = io.WriteString(w, `{"success":true}`)
+		return
+	}
+
+	if m.onSend != nil {
+		m.onSend(body.To, body.Text.Body)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"messaging_product": "whatsapp",
+		"contacts":          []map[string]string{{"input": body.To, "wa_id": strings.TrimPrefix(body.To, "+")}},
+		"messages":          []map[string]string{{"id": fmt.Sprintf("wamid.mock%d", m.seq.Add(1))}},
+	})
+}
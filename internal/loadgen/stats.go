@@ -0,0 +1,68 @@
+package loadgen
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latencies collects the latency samples and failures of one kind of operation
+type Latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	errors  int
+}
+
+// Add records a successful operation
+func (l *Latencies) Add(d time.Duration) {
+	l.mu.Lock()
+	l.samples = append(l.samples, d)
+	l.mu.Unlock()
+}
+
+// Fail records a failed operation
+func (l *Latencies) Fail() {
+	l.mu.Lock()
+	l.errors++
+	l.mu.Unlock()
+}
+
+// Summary is the latency distribution of an operation
+type Summary struct {
+	Count  int           `json:"count"`
+	Errors int           `json:"errors"`
+	P50    time.Duration `json:"p50"`
+	P95    time.Duration `json:"p95"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+// Summary returns the distribution of the samples recorded so far
+func (l *Latencies) Summary() Summary {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	s := Summary{Count: len(sorted), Errors: l.errors}
+	l.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return s
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	s.P50 = percentile(sorted, 50)
+	s.P95 = percentile(sorted, 95)
+	s.P99 = percentile(sorted, 99)
+	s.Max = sorted[len(sorted)-1]
+	return s
+}
+
+// percentile returns the nearest-rank percentile p of sorted samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted)) + 0.999999)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
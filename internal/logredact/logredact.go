@@ -0,0 +1,249 @@
+// Package logredact keeps message content and contact identifiers out of application
+// logs. It rewrites the logfmt lines written by the logger: values of content fields
+// are replaced and values of identifier fields such as phone numbers are masked.
+// Unredacted lines of severe entries can be kept in a separate audit log.
+package logredact
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/zerodha/logf"
+)
+
+// Redacted replaces the value of a content field
+const Redacted = "[REDACTED]"
+
+// DefaultContentFields are log fields that carry message bodies, user input or
+// collected chatbot data
+var DefaultContentFields = []string{
+	"text", "body", "content", "caption", "input", "userInput", "user_input",
+	"sessionData", "session_data", "payload", "reply", "response", "response_json",
+	"data", "value", "keyword", "default_response",
+}
+
+// DefaultIdentifierFields are log fields that carry phone numbers or emails
+var DefaultIdentifierFields = []string{
+	"phone", "phone_number", "from", "to", "recipient", "recipient_id", "contact",
+	"sender", "wa_id", "email",
+}
+
+// Options configures a Writer
+type Options struct {
+	ContentFields    []string   // Added to DefaultContentFields
+	IdentifierFields []string   // Added to DefaultIdentifierFields
+	Audit            io.Writer  // Receives unredacted lines at or above AuditLevel; nil disables
+	AuditLevel       logf.Level // Defaults to logf.ErrorLevel
+}
+
+// Writer redacts log lines before passing them on
+type Writer struct {
+	out         io.Writer
+	audit       io.Writer
+	auditLevel  logf.Level
+	content     map[string]bool
+	identifiers map[string]bool
+}
+
+// NewWriter returns a Writer that writes redacted lines to out
+func NewWriter(out io.Writer, opts Options) *Writer {
+	w := &Writer{
+		out:         out,
+		audit:       opts.Audit,
+		auditLevel:  opts.AuditLevel,
+		content:     map[string]bool{},
+		identifiers: map[string]bool{},
+	}
+	if w.auditLevel == 0 {
+		w.auditLevel = logf.ErrorLevel
+	}
+	for _, f := range append(DefaultContentFields, opts.ContentFields...) {
+		w.content[f] = true
+	}
+	for _, f := range append(DefaultIdentifierFields, opts.IdentifierFields...) {
+		w.identifiers[f] = true
+	}
+	return w
+}
+
+// Enabled reports whether logs are redacted for a mode of auto, on or off. auto redacts
+// in production only.
+func Enabled(mode, environment string) bool {
+	switch strings.ToLower(mode) {
+	case "on", "true":
+		return true
+	case "off", "false":
+		return false
+	default:
+		return environment == "production"
+	}
+}
+
+// Write redacts one log line. The logger writes each entry with a single call.
+func (w *Writer) Write(p []byte) (int, error) {
+	fields := splitFields(p)
+
+	if w.audit != nil {
+		if lvl, err := logf.LevelFromString(fieldValue(fields, "level")); err == nil && lvl >= w.auditLevel {
+			_, _ = w.audit.Write(p)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(p))
+	seenMessage := false
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		key := f.key()
+		// The first message field is the log message itself, not a value
+		if key == "message" && !seenMessage {
+			seenMessage = true
+			buf.WriteString(f.raw)
+			continue
+		}
+		switch {
+		case w.content[key] && f.hasValue():
+			buf.WriteString(f.rawKey)
+			buf.WriteByte('=')
+			buf.WriteString(Redacted)
+		case w.identifiers[key] && f.hasValue():
+			buf.WriteString(f.rawKey)
+			buf.WriteByte('=')
+			writeValue(&buf, Mask(unquote(f.rawValue)))
+		default:
+			buf.WriteString(f.raw)
+		}
+	}
+	if bytes.HasSuffix(p, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Mask hides an identifier, keeping the first letter and domain of an email or the last
+// four characters of anything else
+func Mask(value string) string {
+	if value == "" || value == "null" {
+		return value
+	}
+	if at := strings.LastIndex(value, "@"); at > 0 {
+		return value[:1] + "***" + value[at:]
+	}
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+}
+
+// field is one key=value pair of a logfmt line
+type field struct {
+	raw      string // The pair as written
+	rawKey   string // The key as written, with color codes if any
+	rawValue string // The value as written, quoted if it was
+}
+
+func (f field) key() string {
+	return stripColor(f.rawKey)
+}
+
+func (f field) hasValue() bool {
+	return f.rawValue != "" && f.rawValue != "null"
+}
+
+// splitFields splits a logfmt line into its pairs. Quoted values may contain spaces and
+// escaped quotes.
+func splitFields(p []byte) []field {
+	line := strings.TrimRight(string(p), "\n")
+	var fields []field
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		f := field{rawKey: line[start:i]}
+		if i < len(line) && line[i] == '=' {
+			i++
+			valueStart := i
+			if i < len(line) && line[i] == '"' {
+				i++
+				for i < len(line) && line[i] != '"' {
+					if line[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				if i < len(line) {
+					i++
+				}
+			} else {
+				for i < len(line) && line[i] != ' ' {
+					i++
+				}
+			}
+			if i > len(line) {
+				i = len(line)
+			}
+			f.rawValue = line[valueStart:i]
+		}
+		f.raw = line[start:i]
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// fieldValue returns the unquoted value of the first field with the key
+func fieldValue(fields []field, key string) string {
+	for _, f := range fields {
+		if f.key() == key {
+			return unquote(f.rawValue)
+		}
+	}
+	return ""
+}
+
+func unquote(v string) string {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return v
+	}
+	v = v[1 : len(v)-1]
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v)
+}
+
+func writeValue(buf *bytes.Buffer, v string) {
+	if strings.ContainsAny(v, ` ="`) {
+		buf.WriteByte('"')
+		buf.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v))
+		buf.WriteByte('"')
+		return
+	}
+	buf.WriteString(v)
+}
+
+// stripColor removes the ANSI color codes the logger puts around keys in development
+func stripColor(s string) string {
+	if !strings.Contains(s, "\x1b[") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package logredact
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zerodha/logf"
+)
+
+func newTestLogger(w *Writer, color bool) logf.Logger {
+	return logf.New(logf.Opts{
+		Writer:          w,
+		Level:           logf.DebugLevel,
+		EnableColor:     color,
+		TimestampFormat: "2006-01-02 15:04:05",
+		DefaultFields:   []any{"app", "whatomate"},
+	})
+}
+
+func TestWriter_RedactsContentAndMasksIdentifiers(t *testing.T) {
+	var out bytes.Buffer
+	lo := newTestLogger(NewWriter(&out, Options{}), false)
+
+	lo.Info("Processing message", "text", "my card is 4111 1111", "from", "919876543210", "contact_id", "c-1", "email", "jane@example.com")
+
+	line := out.String()
+	assert.Contains(t, line, `message="Processing message"`)
+	assert.Contains(t, line, "text="+Redacted)
+	assert.NotContains(t, line, "4111")
+	assert.Contains(t, line, "from=********3210")
+	assert.Contains(t, line, "email=j***@example.com")
+	assert.Contains(t, line, "contact_id=c-1")
+	assert.Contains(t, line, "app=whatomate")
+	assert.True(t, bytes.HasSuffix(out.Bytes(), []byte("\n")))
+}
+
+func TestWriter_ColoredKeysAndCustomFields(t *testing.T) {
+	var out bytes.Buffer
+	lo := newTestLogger(NewWriter(&out, Options{ContentFields: []string{"note"}, IdentifierFields: []string{"customer"}}), true)
+
+	lo.Debug("Step", "note", "private", "customer", "ACME-12345", "step", "ask_name")
+
+	line := out.String()
+	assert.NotContains(t, line, "private")
+	assert.Contains(t, line, "=******2345")
+	assert.Contains(t, line, "=ask_name")
+}
+
+func TestWriter_AuditKeepsSevereEntries(t *testing.T) {
+	var out, audit bytes.Buffer
+	lo := newTestLogger(NewWriter(&out, Options{Audit: &audit}), false)
+
+	lo.Info("Received", "text", "hello there")
+	lo.Error("Failed to send", "text", "hello there", "phone", "919876543210")
+
+	assert.NotContains(t, out.String(), "hello there")
+	assert.NotContains(t, audit.String(), "Received")
+	assert.Contains(t, audit.String(), `text="hello there"`)
+	assert.Contains(t, audit.String(), "phone=919876543210")
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "", Mask(""))
+	assert.Equal(t, "***", Mask("123"))
+	assert.Equal(t, "******7890", Mask("1234567890"))
+	assert.Equal(t, "a***@b.io", Mask("ann@b.io"))
+}
+
+func TestEnabled(t *testing.T) {
+	assert.True(t, Enabled("auto", "production"))
+	assert.False(t, Enabled("", "development"))
+	assert.True(t, Enabled("on", "development"))
+	assert.False(t, Enabled("off", "production"))
+}
@@ -0,0 +1,124 @@
+// Package mediapolicy decides which media files contacts may send an organization: a
+// size limit and the accepted file types. Media that breaks the policy is not stored and
+// the contact is told why.
+package mediapolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrRejected is returned for media the policy doesn't accept
+var ErrRejected = errors.New("media rejected by the inbound media policy")
+
+// SettingKey is the organization setting holding the policy
+const SettingKey = "inbound_media_policy"
+
+// MaxSizeLimitMB is the largest size limit that can be set, WhatsApp's own limit for documents
+const MaxSizeLimitMB = 100
+
+// DefaultNotice is sent to the contact when the policy has no notice of its own
+const DefaultNotice = "Sorry, we couldn't accept the file you sent. Please send a smaller file or a different file type."
+
+// Policy is an organization's inbound media policy. The zero value accepts everything.
+type Policy struct {
+	// MaxSizeMB rejects larger files; 0 means no limit
+	MaxSizeMB int `json:"max_size_mb"`
+	// AllowedTypes lists the accepted MIME types, such as "application/pdf", or whole
+	// families such as "image/*". Empty accepts every type.
+	AllowedTypes []string `json:"allowed_types"`
+	// Notice is sent to the contact when their media is rejected; DefaultNotice when empty
+	Notice string `json:"notice"`
+}
+
+// FromSettings reads the media policy from organization settings
+func FromSettings(settings map[string]interface{}) Policy {
+	var policy Policy
+	raw, ok := settings[SettingKey]
+	if !ok || raw == nil {
+		return policy
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return policy
+	}
+	_ = json.Unmarshal(data, &policy)
+	return policy
+}
+
+// IsEmpty reports whether the policy accepts all media
+func (p Policy) IsEmpty() bool {
+	return p.MaxSizeMB == 0 && len(p.AllowedTypes) == 0
+}
+
+// MaxBytes returns the size limit in bytes, 0 when there is none
+func (p Policy) MaxBytes() int64 {
+	return int64(p.MaxSizeMB) << 20
+}
+
+// NoticeText returns the notice to send the contact about rejected media
+func (p Policy) NoticeText() string {
+	if p.Notice != "" {
+		return p.Notice
+	}
+	return DefaultNotice
+}
+
+// Normalize lower-cases and de-duplicates the allowed types and checks the size limit
+// and that every type looks like "type/subtype" or "type/*"
+func (p Policy) Normalize() (Policy, error) {
+	if p.MaxSizeMB < 0 || p.MaxSizeMB > MaxSizeLimitMB {
+		return Policy{}, fmt.Errorf("max_size_mb must be between 0 and %d", MaxSizeLimitMB)
+	}
+
+	normalized := Policy{MaxSizeMB: p.MaxSizeMB, Notice: strings.TrimSpace(p.Notice)}
+	seen := make(map[string]bool, len(p.AllowedTypes))
+	for _, t := range p.AllowedTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		family, subtype, ok := strings.Cut(t, "/")
+		if !ok || family == "" || subtype == "" || family == "*" || strings.Contains(subtype, "/") {
+			return Policy{}, fmt.Errorf("invalid media type %q, use a MIME type such as image/png or image/*", t)
+		}
+		seen[t] = true
+		normalized.AllowedTypes = append(normalized.AllowedTypes, t)
+	}
+	return normalized, nil
+}
+
+// AllowsType reports whether media of the MIME type is accepted. Parameters such as
+// "; codecs=opus" are ignored.
+func (p Policy) AllowsType(mimeType string) bool {
+	if len(p.AllowedTypes) == 0 {
+		return true
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	family, _, _ := strings.Cut(mimeType, "/")
+	for _, t := range p.AllowedTypes {
+		if t == mimeType || t == family+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckType returns an error wrapping ErrRejected when the MIME type isn't accepted
+func (p Policy) CheckType(mimeType string) error {
+	if !p.AllowsType(mimeType) {
+		return fmt.Errorf("%w: type %s is not allowed", ErrRejected, mimeType)
+	}
+	return nil
+}
+
+// CheckSize returns an error wrapping ErrRejected when size bytes is over the limit
+func (p Policy) CheckSize(size int64) error {
+	if limit := p.MaxBytes(); limit > 0 && size > limit {
+		return fmt.Errorf("%w: %.1f MB is over the %d MB limit", ErrRejected, float64(size)/(1<<20), p.MaxSizeMB)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package mediapolicy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Normalize(t *testing.T) {
+	policy, err := Policy{
+		MaxSizeMB:    10,
+		AllowedTypes: []string{" Image/* ", "application/pdf", "image/*", ""},
+		Notice:       "  Files up to 10 MB only  ",
+	}.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"image/*", "application/pdf"}, policy.AllowedTypes)
+	assert.Equal(t, "Files up to 10 MB only", policy.Notice)
+
+	for _, bad := range []Policy{
+		{MaxSizeMB: -1},
+		{MaxSizeMB: MaxSizeLimitMB + 1},
+		{AllowedTypes: []string{"pdf"}},
+		{AllowedTypes: []string{"*/*"}},
+		{AllowedTypes: []string{"image/"}},
+	} {
+		_, err := bad.Normalize()
+		assert.Error(t, err, "%+v", bad)
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	policy := Policy{MaxSizeMB: 5, AllowedTypes: []string{"image/*", "application/pdf"}}
+
+	assert.NoError(t, policy.CheckType("image/jpeg"))
+	assert.NoError(t, policy.CheckType("Application/PDF"))
+	err := policy.CheckType("audio/ogg; codecs=opus")
+	assert.True(t, errors.Is(err, ErrRejected), "expected ErrRejected, got %v", err)
+
+	assert.NoError(t, policy.CheckSize(5<<20))
+	err = policy.CheckSize(5<<20 + 1)
+	assert.True(t, errors.Is(err, ErrRejected), "expected ErrRejected, got %v", err)
+
+	// The zero policy accepts everything
+	var open Policy
+	assert.True(t, open.IsEmpty())
+	assert.NoError(t, open.CheckType("video/mp4"))
+	assert.NoError(t, open.CheckSize(1<<40))
+	assert.Equal(t, DefaultNotice, open.NoticeText())
+}
+
+func TestFromSettings(t *testing.T) {
+	policy := FromSettings(map[string]interface{}{
+		SettingKey: map[string]interface{}{
+			"max_size_mb":   float64(16),
+			"allowed_types": []interface{}{"image/*"},
+			"notice":        "Images only please",
+		},
+	})
+	assert.Equal(t, 16, policy.MaxSizeMB)
+	assert.Equal(t, []string{"image/*"}, policy.AllowedTypes)
+	assert.Equal(t, "Images only please", policy.NoticeText())
+
+	assert.True(t, FromSettings(map[string]interface{}{}).IsEmpty())
+}
@@ -2,6 +2,10 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +28,12 @@ const (
 	ContextKeyIsSuperAdmin   = "is_super_admin"
 	ContextKeyUser           = "user"
 	ContextKeyOrganization   = "organization"
+	ContextKeyAPIKeyID       = "api_key_id"
+	ContextKeyAPIKeyScope    = "api_key_scope"
+	ContextKeyAPIKeyQuota    = "api_key_daily_quota"
+	ContextKeyImpersonatorID = "impersonator_id"
+	ContextKeyImpersonation  = "impersonation_id"
+	ContextKeyClientIP       = "client_ip"
 )
 
 // JWTClaims represents JWT claims
@@ -33,6 +43,9 @@ type JWTClaims struct {
 	Email          string     `json:"email"`
 	RoleID         *uuid.UUID `json:"role_id,omitempty"`
 	IsSuperAdmin   bool       `json:"is_super_admin"`
+	// Set on impersonation tokens: the super admin acting as UserID and their session
+	ImpersonatorID  *uuid.UUID `json:"impersonator_id,omitempty"`
+	ImpersonationID *uuid.UUID `json:"impersonation_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -66,6 +79,76 @@ func CORS() fastglue.FastMiddleware {
 	}
 }
 
+// apiContentSecurityPolicy locks down API responses, which never need to load anything
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeadersConfig controls the hardening headers set on every response
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy string // Policy for the frontend; API responses always use a locked-down policy
+	HSTSMaxAge            int    // Seconds; HSTS is only sent over HTTPS and is skipped when <= 0
+	FrameOptions          string // DENY or SAMEORIGIN
+}
+
+// SecurityHeaders sets CSP, HSTS, X-Frame-Options and related headers
+func SecurityHeaders(cfg SecurityHeadersConfig) fastglue.FastMiddleware {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(cfg.HSTSMaxAge) + "; includeSubDomains"
+	}
+
+	return func(r *fastglue.Request) *fastglue.Request {
+		h := &r.RequestCtx.Response.Header
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", cfg.FrameOptions)
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+
+		path := string(r.RequestCtx.Path())
+		if strings.HasPrefix(path, "/api") {
+			h.Set("Content-Security-Policy", apiContentSecurityPolicy)
+		} else if cfg.ContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+
+		if hsts != "" && isHTTPS(r) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+
+		return r
+	}
+}
+
+// DefaultContentSecurityPolicy returns the CSP for the embedded frontend. scriptHashes are
+// 'sha256-...' sources for the inline scripts in index.html.
+func DefaultContentSecurityPolicy(scriptHashes []string, frameOptions string) string {
+	frameAncestors := "'none'"
+	if strings.EqualFold(frameOptions, "SAMEORIGIN") {
+		frameAncestors = "'self'"
+	}
+
+	directives := []string{
+		"default-src 'self'",
+		strings.TrimSpace("script-src 'self' " + strings.Join(scriptHashes, " ")),
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com",
+		"font-src 'self' data: https://fonts.gstatic.com",
+		"img-src 'self' data: blob: https:",
+		"media-src 'self' data: blob:",
+		"connect-src 'self' ws: wss:",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors " + frameAncestors,
+	}
+	return strings.Join(directives, "; ")
+}
+
+// isHTTPS reports whether the request reached us, or the proxy in front of us, over TLS
+func isHTTPS(r *fastglue.Request) bool {
+	if r.RequestCtx.IsTLS() {
+		return true
+	}
+	return strings.EqualFold(string(r.RequestCtx.Request.Header.Peek("X-Forwarded-Proto")), "https")
+}
+
 // Recovery recovers from panics
 func Recovery(log logf.Logger) fastglue.FastMiddleware {
 	return func(r *fastglue.Request) *fastglue.Request {
@@ -140,6 +223,10 @@ func AuthWithDB(secret string, db *gorm.DB) fastglue.FastMiddleware {
 			r.RequestCtx.SetUserValue(ContextKeyRoleID, *claims.RoleID)
 		}
 		r.RequestCtx.SetUserValue(ContextKeyIsSuperAdmin, claims.IsSuperAdmin)
+		if claims.ImpersonatorID != nil && claims.ImpersonationID != nil {
+			r.RequestCtx.SetUserValue(ContextKeyImpersonatorID, *claims.ImpersonatorID)
+			r.RequestCtx.SetUserValue(ContextKeyImpersonation, *claims.ImpersonationID)
+		}
 
 		return r
 	}
@@ -179,11 +266,23 @@ func validateAPIKey(r *fastglue.Request, key string, db *gorm.DB) bool {
 
 			// Set context values from the user who created the key
 			if apiKey.User != nil {
+				r.RequestCtx.SetUserValue(ContextKeyAPIKeyID, apiKey.ID)
+				r.RequestCtx.SetUserValue(ContextKeyAPIKeyScope, apiKey.Scope)
+				r.RequestCtx.SetUserValue(ContextKeyAPIKeyQuota, apiKey.DailyQuota)
 				r.RequestCtx.SetUserValue(ContextKeyUserID, apiKey.UserID)
 				r.RequestCtx.SetUserValue(ContextKeyOrganizationID, apiKey.OrganizationID)
 				r.RequestCtx.SetUserValue(ContextKeyEmail, apiKey.User.Email)
-				if apiKey.User.RoleID != nil {
-					r.RequestCtx.SetUserValue(ContextKeyRoleID, *apiKey.User.RoleID)
+				// Keys for an organization the user is a member of carry their role there
+				roleID := apiKey.User.RoleID
+				if apiKey.OrganizationID != apiKey.User.OrganizationID {
+					var membership models.UserOrganization
+					roleID = nil
+					if err := db.Where("user_id = ? AND organization_id = ?", apiKey.UserID, apiKey.OrganizationID).First(&membership).Error; err == nil {
+						roleID = membership.RoleID
+					}
+				}
+				if roleID != nil {
+					r.RequestCtx.SetUserValue(ContextKeyRoleID, *roleID)
 				}
 				r.RequestCtx.SetUserValue(ContextKeyIsSuperAdmin, apiKey.User.IsSuperAdmin)
 				return true
@@ -194,6 +293,324 @@ func validateAPIKey(r *fastglue.Request, key string, db *gorm.DB) bool {
 	return false
 }
 
+// IPAccess describes an authenticated request for an IP allowlist check
+type IPAccess struct {
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+	APIKeyID       *uuid.UUID // Set when the request authenticated with an API key
+	IP             string
+	Path           string
+}
+
+// IPAccessChecker is a function that checks if an authenticated request may come from its IP
+type IPAccessChecker func(access IPAccess) bool
+
+// RequireAllowedIP rejects authenticated requests from IPs outside the organization's allowlist
+func RequireAllowedIP(checker IPAccessChecker) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		userID, ok := r.RequestCtx.UserValue(ContextKeyUserID).(uuid.UUID)
+		if !ok {
+			_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "User not authenticated", nil, "")
+			return nil
+		}
+		orgID, _ := r.RequestCtx.UserValue(ContextKeyOrganizationID).(uuid.UUID)
+
+		access := IPAccess{
+			UserID:         userID,
+			OrganizationID: orgID,
+			IP:             ClientIP(r),
+			Path:           string(r.RequestCtx.Path()),
+		}
+		if apiKeyID, ok := r.RequestCtx.UserValue(ContextKeyAPIKeyID).(uuid.UUID); ok {
+			access.APIKeyID = &apiKeyID
+		}
+
+		if !checker(access) {
+			_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access from this IP address is not allowed", nil, "")
+			return nil
+		}
+
+		return r
+	}
+}
+
+// OrganizationChecker is a function that checks if the user can work in an organization
+type OrganizationChecker func(userID, orgID uuid.UUID) bool
+
+// RequireOrganizationMember rejects tokens and API keys for an organization the user is
+// no longer a member of. Tokens carry the organization they were issued for, so a user
+// can hold sessions and keys for several organizations at once.
+func RequireOrganizationMember(checker OrganizationChecker) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		userID, ok := r.RequestCtx.UserValue(ContextKeyUserID).(uuid.UUID)
+		if !ok {
+			_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "User not authenticated", nil, "")
+			return nil
+		}
+		orgID, _ := r.RequestCtx.UserValue(ContextKeyOrganizationID).(uuid.UUID)
+		if !checker(userID, orgID) {
+			_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "You no longer have access to this organization. Sign in again.", nil, "")
+			return nil
+		}
+		return r
+	}
+}
+
+// RestrictAPIKeyScope rejects requests from limited-scope API keys to paths outside their
+// scope. scopePaths maps each limited scope to the path prefixes it may call; full-scope
+// keys and JWT sessions are not restricted.
+func RestrictAPIKeyScope(scopePaths map[models.APIKeyScope][]string) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		scope, ok := r.RequestCtx.UserValue(ContextKeyAPIKeyScope).(models.APIKeyScope)
+		if !ok || scope == "" || scope == models.APIKeyScopeFull {
+			return r
+		}
+
+		path := string(r.RequestCtx.Path())
+		for _, prefix := range scopePaths[scope] {
+			if strings.HasPrefix(path, prefix) {
+				return r
+			}
+		}
+
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "This API key is not allowed to access this endpoint", nil, "")
+		return nil
+	}
+}
+
+// APIUsage describes an authenticated API request for usage tracking
+type APIUsage struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	APIKeyID       *uuid.UUID // Set when the request authenticated with an API key
+	DailyQuota     int        // The API key's requests per day; 0 is unlimited
+	Method         string
+	Path           string
+}
+
+// APIUsageTracker is a function that counts an API request and reports whether it is
+// within the API key's daily quota
+type APIUsageTracker func(usage APIUsage) bool
+
+// TrackAPIUsage counts authenticated requests and rejects requests from API keys that
+// have used up their daily quota
+func TrackAPIUsage(tracker APIUsageTracker) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		userID, ok := r.RequestCtx.UserValue(ContextKeyUserID).(uuid.UUID)
+		if !ok {
+			return r
+		}
+		orgID, _ := r.RequestCtx.UserValue(ContextKeyOrganizationID).(uuid.UUID)
+
+		usage := APIUsage{
+			OrganizationID: orgID,
+			UserID:         userID,
+			Method:         string(r.RequestCtx.Method()),
+			Path:           string(r.RequestCtx.Path()),
+		}
+		if apiKeyID, ok := r.RequestCtx.UserValue(ContextKeyAPIKeyID).(uuid.UUID); ok {
+			usage.APIKeyID = &apiKeyID
+			usage.DailyQuota, _ = r.RequestCtx.UserValue(ContextKeyAPIKeyQuota).(int)
+		}
+
+		if !tracker(usage) {
+			r.RequestCtx.Response.Header.Set("Retry-After", strconv.Itoa(secondsUntilUTCMidnight(time.Now())))
+			_ = r.SendErrorEnvelope(fasthttp.StatusTooManyRequests, "Daily API quota exceeded for this API key", nil, "")
+			return nil
+		}
+
+		return r
+	}
+}
+
+// secondsUntilUTCMidnight is when daily quotas reset
+func secondsUntilUTCMidnight(now time.Time) int {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return int(midnight.Sub(now).Seconds()) + 1
+}
+
+// Impersonation describes a request made with an impersonation token
+type Impersonation struct {
+	SessionID      uuid.UUID
+	ImpersonatorID uuid.UUID
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+	Method         string
+	IP             string
+	Path           string
+}
+
+// ImpersonationChecker is a function that checks if an impersonation session is still
+// active, recording the request
+type ImpersonationChecker func(imp Impersonation) bool
+
+// RestrictImpersonation rejects impersonated requests from sessions that have ended and
+// requests to blocked path prefixes, such as ones that would create lasting access. A
+// blocked entry is either a path prefix or a method and prefix, e.g. "POST /api/users".
+func RestrictImpersonation(checker ImpersonationChecker, blockedPaths []string) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		sessionID, ok := r.RequestCtx.UserValue(ContextKeyImpersonation).(uuid.UUID)
+		if !ok {
+			return r
+		}
+
+		path := string(r.RequestCtx.Path())
+		method := string(r.RequestCtx.Method())
+		for _, entry := range blockedPaths {
+			prefix := entry
+			if m, p, found := strings.Cut(entry, " "); found {
+				if m != method {
+					continue
+				}
+				prefix = p
+			}
+			if strings.HasPrefix(path, prefix) {
+				_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "This endpoint is not available while impersonating", nil, "")
+				return nil
+			}
+		}
+
+		imp := Impersonation{
+			SessionID: sessionID,
+			Method:    method,
+			IP:        ClientIP(r),
+			Path:      path,
+		}
+		imp.ImpersonatorID, _ = r.RequestCtx.UserValue(ContextKeyImpersonatorID).(uuid.UUID)
+		imp.UserID, _ = r.RequestCtx.UserValue(ContextKeyUserID).(uuid.UUID)
+		imp.OrganizationID, _ = r.RequestCtx.UserValue(ContextKeyOrganizationID).(uuid.UUID)
+		if !checker(imp) {
+			_ = r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Impersonation session has ended", nil, "")
+			return nil
+		}
+		return r
+	}
+}
+
+// SuspensionChecker is a function that checks if an organization is suspended
+type SuspensionChecker func(orgID uuid.UUID) bool
+
+// RejectSuspendedOrganization rejects requests from users of a suspended organization.
+// Super admins are let through so they can still reactivate it.
+func RejectSuspendedOrganization(checker SuspensionChecker) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		if isSuperAdmin, _ := r.RequestCtx.UserValue(ContextKeyIsSuperAdmin).(bool); isSuperAdmin {
+			return r
+		}
+		orgID, ok := r.RequestCtx.UserValue(ContextKeyOrganizationID).(uuid.UUID)
+		if !ok || !checker(orgID) {
+			return r
+		}
+		_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "Your organization has been suspended", nil, "")
+		return nil
+	}
+}
+
+// FeatureChecker is a function that checks if a feature flag is enabled for an organization
+type FeatureChecker func(orgID uuid.UUID, feature string) bool
+
+// RequireFeature rejects requests to paths under a feature-gated prefix when the feature is
+// disabled for the caller's organization. features maps path prefixes to flag keys.
+func RequireFeature(checker FeatureChecker, features map[string]string) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		path := string(r.RequestCtx.Path())
+		for prefix, feature := range features {
+			if !strings.HasPrefix(path, prefix) {
+				continue
+			}
+			orgID, ok := r.RequestCtx.UserValue(ContextKeyOrganizationID).(uuid.UUID)
+			if !ok {
+				return r // Unauthenticated routes are left to their own checks
+			}
+			if !checker(orgID, feature) {
+				_ = r.SendErrorEnvelope(fasthttp.StatusForbidden, "This feature is not enabled for your organization", nil, "")
+				return nil
+			}
+		}
+		return r
+	}
+}
+
+// TrustedProxies are the reverse proxies whose X-Forwarded-For header is believed
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses proxy IP addresses and CIDR ranges
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// Contains reports whether ip belongs to a trusted proxy
+func (t TrustedProxies) Contains(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, prefix := range t {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPResolver works out the client IP of each request for ClientIP. X-Forwarded-For is
+// only believed when the connection comes from a trusted proxy, and then the client is the
+// right-most hop that isn't one of our proxies: entries left of it can be set by the client.
+func ClientIPResolver(trusted TrustedProxies) fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		r.RequestCtx.SetUserValue(ContextKeyClientIP, resolveClientIP(r.RequestCtx.RemoteIP(), string(r.RequestCtx.Request.Header.Peek("X-Forwarded-For")), trusted))
+		return r
+	}
+}
+
+// resolveClientIP picks the client IP from the connection address and X-Forwarded-For
+func resolveClientIP(remote net.IP, forwarded string, trusted TrustedProxies) string {
+	remoteAddr, ok := netip.AddrFromSlice(remote)
+	if !ok || forwarded == "" || !trusted.Contains(remoteAddr) {
+		return remote.String()
+	}
+
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed hop means the header can't be trusted past this point
+			return remote.String()
+		}
+		if !trusted.Contains(hop) || i == 0 {
+			return hop.Unmap().String()
+		}
+	}
+	return remote.String()
+}
+
+// ClientIP returns the client IP worked out by ClientIPResolver, or the connection's address
+func ClientIP(r *fastglue.Request) string {
+	if ip, ok := r.RequestCtx.UserValue(ContextKeyClientIP).(string); ok && ip != "" {
+		return ip
+	}
+	return r.RequestCtx.RemoteIP().String()
+}
+
 // OrganizationContext loads organization and user from database
 func OrganizationContext(db *gorm.DB) fastglue.FastMiddleware {
 	return func(r *fastglue.Request) *fastglue.Request {
@@ -1,12 +1,14 @@
 package middleware_test
 
 import (
+	"net"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/test/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -93,6 +95,48 @@ func TestCORS(t *testing.T) {
 	}
 }
 
+func TestSecurityHeaders(t *testing.T) {
+	t.Parallel()
+
+	mw := middleware.SecurityHeaders(middleware.SecurityHeadersConfig{
+		ContentSecurityPolicy: "default-src 'self'",
+		HSTSMaxAge:            3600,
+		FrameOptions:          "DENY",
+	})
+
+	page := newTestRequest()
+	page.RequestCtx.Request.SetRequestURI("/chat")
+	page.RequestCtx.Request.Header.Set("X-Forwarded-Proto", "https")
+	require.NotNil(t, mw(page))
+
+	h := &page.RequestCtx.Response.Header
+	assert.Equal(t, "default-src 'self'", string(h.Peek("Content-Security-Policy")))
+	assert.Equal(t, "DENY", string(h.Peek("X-Frame-Options")))
+	assert.Equal(t, "nosniff", string(h.Peek("X-Content-Type-Options")))
+	assert.Equal(t, "max-age=3600; includeSubDomains", string(h.Peek("Strict-Transport-Security")))
+
+	api := newTestRequest()
+	api.RequestCtx.Request.SetRequestURI("/api/contacts")
+	require.NotNil(t, mw(api))
+
+	h = &api.RequestCtx.Response.Header
+	assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", string(h.Peek("Content-Security-Policy")))
+	assert.Empty(t, string(h.Peek("Strict-Transport-Security")), "HSTS should only be sent over HTTPS")
+}
+
+func TestDefaultContentSecurityPolicy(t *testing.T) {
+	t.Parallel()
+
+	csp := middleware.DefaultContentSecurityPolicy([]string{"'sha256-abc'"}, "SAMEORIGIN")
+	assert.Contains(t, csp, "script-src 'self' 'sha256-abc'")
+	assert.Contains(t, csp, "frame-ancestors 'self'")
+	assert.NotContains(t, csp, "'unsafe-eval'")
+
+	csp = middleware.DefaultContentSecurityPolicy(nil, "DENY")
+	assert.Contains(t, csp, "script-src 'self';")
+	assert.Contains(t, csp, "frame-ancestors 'none'")
+}
+
 func TestRecovery(t *testing.T) {
 	t.Parallel()
 
@@ -330,6 +374,316 @@ func TestRequirePermission_NoUserInContext(t *testing.T) {
 	assert.Equal(t, fasthttp.StatusUnauthorized, req.RequestCtx.Response.StatusCode())
 }
 
+func TestRequireAllowedIP(t *testing.T) {
+	t.Parallel()
+
+	userID := uuid.New()
+	orgID := uuid.New()
+	apiKeyID := uuid.New()
+
+	req := newTestRequest()
+	req.RequestCtx.SetUserValue(middleware.ContextKeyUserID, userID)
+	req.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, orgID)
+	req.RequestCtx.SetUserValue(middleware.ContextKeyAPIKeyID, apiKeyID)
+	req.RequestCtx.Request.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	req.RequestCtx.SetRemoteAddr(&net.TCPAddr{IP: net.ParseIP("10.0.0.2")})
+	trusted, err := middleware.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	req = middleware.ClientIPResolver(trusted)(req)
+
+	var got middleware.IPAccess
+	checker := func(access middleware.IPAccess) bool {
+		got = access
+		return access.IP == "198.51.100.1"
+	}
+
+	result := middleware.RequireAllowedIP(checker)(req)
+
+	assert.Nil(t, result, "should deny access from an IP outside the allowlist")
+	assert.Equal(t, fasthttp.StatusForbidden, req.RequestCtx.Response.StatusCode())
+	assert.Equal(t, userID, got.UserID)
+	assert.Equal(t, orgID, got.OrganizationID)
+	assert.Equal(t, "203.0.113.7", got.IP)
+	require.NotNil(t, got.APIKeyID)
+	assert.Equal(t, apiKeyID, *got.APIKeyID)
+
+	allowed := newTestRequest()
+	allowed.RequestCtx.SetUserValue(middleware.ContextKeyUserID, userID)
+	allowed.RequestCtx.SetRemoteAddr(&net.TCPAddr{IP: net.ParseIP("198.51.100.1")})
+
+	assert.NotNil(t, middleware.RequireAllowedIP(checker)(allowed), "should allow access from an allowlisted IP")
+	assert.Nil(t, got.APIKeyID)
+}
+
+func TestRequireOrganizationMember(t *testing.T) {
+	t.Parallel()
+
+	userID, orgA, orgB := uuid.New(), uuid.New(), uuid.New()
+	checker := func(u, o uuid.UUID) bool { return u == userID && (o == orgA || o == orgB) }
+
+	// Sessions for each of the user's organizations work side by side
+	for _, orgID := range []uuid.UUID{orgA, orgB} {
+		req := newTestRequest()
+		req.RequestCtx.SetUserValue(middleware.ContextKeyUserID, userID)
+		req.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, orgID)
+		assert.NotNil(t, middleware.RequireOrganizationMember(checker)(req))
+	}
+
+	stranger := newTestRequest()
+	stranger.RequestCtx.SetUserValue(middleware.ContextKeyUserID, userID)
+	stranger.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, uuid.New())
+	assert.Nil(t, middleware.RequireOrganizationMember(checker)(stranger), "should reject a token for an organization the user isn't a member of")
+	assert.Equal(t, fasthttp.StatusUnauthorized, stranger.RequestCtx.Response.StatusCode())
+
+	anonymous := newTestRequest()
+	assert.Nil(t, middleware.RequireOrganizationMember(checker)(anonymous))
+}
+
+func TestClientIPResolver(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := middleware.ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10"})
+	require.NoError(t, err)
+
+	clientIP := func(remote, forwarded string) string {
+		req := newTestRequest()
+		req.RequestCtx.SetRemoteAddr(&net.TCPAddr{IP: net.ParseIP(remote)})
+		if forwarded != "" {
+			req.RequestCtx.Request.Header.Set("X-Forwarded-For", forwarded)
+		}
+		return middleware.ClientIP(middleware.ClientIPResolver(trusted)(req))
+	}
+
+	// Without a trusted proxy in front the header is ignored
+	assert.Equal(t, "198.51.100.9", clientIP("198.51.100.9", "203.0.113.7"))
+	assert.Equal(t, "198.51.100.9", clientIP("198.51.100.9", ""))
+
+	// Behind trusted proxies the right-most untrusted hop is the client
+	assert.Equal(t, "203.0.113.7", clientIP("10.0.0.2", "203.0.113.7"))
+	assert.Equal(t, "203.0.113.7", clientIP("192.0.2.10", "203.0.113.7, 10.1.2.3"))
+
+	// A spoofed entry prepended by the client doesn't get past the proxy's own entry
+	assert.Equal(t, "203.0.113.7", clientIP("10.0.0.2", "198.51.100.1, 203.0.113.7"))
+
+	// Garbage from the proxy falls back to the connection
+	assert.Equal(t, "10.0.0.2", clientIP("10.0.0.2", "not-an-ip"))
+
+	// A request that never went through the resolver uses the connection's address
+	req := newTestRequest()
+	req.RequestCtx.SetRemoteAddr(&net.TCPAddr{IP: net.ParseIP("10.0.0.2")})
+	req.RequestCtx.Request.Header.Set("X-Forwarded-For", "198.51.100.1")
+	assert.Equal(t, "10.0.0.2", middleware.ClientIP(req))
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	_, err := middleware.ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = middleware.ParseTrustedProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+
+	trusted, err := middleware.ParseTrustedProxies([]string{" 127.0.0.1 ", "", "fd00::/8"})
+	require.NoError(t, err)
+	assert.Len(t, trusted, 2)
+}
+
+func TestRestrictAPIKeyScope(t *testing.T) {
+	t.Parallel()
+
+	scopePaths := map[models.APIKeyScope][]string{
+		models.APIKeyScopeTransactional: {"/api/v1/"},
+	}
+
+	tests := []struct {
+		name        string
+		scope       *models.APIKeyScope
+		path        string
+		wantAllowed bool
+	}{
+		{"JWT session", nil, "/api/contacts", true},
+		{"full scope key", ptr(models.APIKeyScopeFull), "/api/contacts", true},
+		{"transactional key on send API", ptr(models.APIKeyScopeTransactional), "/api/v1/send", true},
+		{"transactional key elsewhere", ptr(models.APIKeyScopeTransactional), "/api/contacts", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := newTestRequest()
+			req.RequestCtx.Request.SetRequestURI(tt.path)
+			if tt.scope != nil {
+				req.RequestCtx.SetUserValue(middleware.ContextKeyAPIKeyScope, *tt.scope)
+			}
+
+			result := middleware.RestrictAPIKeyScope(scopePaths)(req)
+			if tt.wantAllowed {
+				assert.NotNil(t, result)
+			} else {
+				assert.Nil(t, result)
+				assert.Equal(t, fasthttp.StatusForbidden, req.RequestCtx.Response.StatusCode())
+			}
+		})
+	}
+}
+
+func TestTrackAPIUsage(t *testing.T) {
+	t.Parallel()
+
+	userID, orgID, apiKeyID := uuid.New(), uuid.New(), uuid.New()
+
+	var got middleware.APIUsage
+	tracker := func(usage middleware.APIUsage) bool {
+		got = usage
+		return usage.APIKeyID == nil
+	}
+
+	req := newTestRequest()
+	req.RequestCtx.Request.Header.SetMethod("POST")
+	req.RequestCtx.Request.SetRequestURI("/api/v1/send")
+	req.RequestCtx.SetUserValue(middleware.ContextKeyUserID, userID)
+	req.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, orgID)
+	req.RequestCtx.SetUserValue(middleware.ContextKeyAPIKeyID, apiKeyID)
+	req.RequestCtx.SetUserValue(middleware.ContextKeyAPIKeyQuota, 100)
+
+	result := middleware.TrackAPIUsage(tracker)(req)
+
+	assert.Nil(t, result, "should reject a key over its quota")
+	assert.Equal(t, fasthttp.StatusTooManyRequests, req.RequestCtx.Response.StatusCode())
+	assert.NotEmpty(t, req.RequestCtx.Response.Header.Peek("Retry-After"))
+	assert.Equal(t, orgID, got.OrganizationID)
+	assert.Equal(t, userID, got.UserID)
+	assert.Equal(t, 100, got.DailyQuota)
+	assert.Equal(t, "POST", got.Method)
+	assert.Equal(t, "/api/v1/send", got.Path)
+
+	session := newTestRequest()
+	session.RequestCtx.SetUserValue(middleware.ContextKeyUserID, userID)
+	assert.NotNil(t, middleware.TrackAPIUsage(tracker)(session), "should allow requests within quota")
+	assert.Nil(t, got.APIKeyID)
+	assert.Equal(t, 0, got.DailyQuota)
+}
+
+func TestAuth_ImpersonationToken(t *testing.T) {
+	t.Parallel()
+
+	userID, orgID, adminID, sessionID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	claims := middleware.JWTClaims{
+		UserID:          userID,
+		OrganizationID:  orgID,
+		Email:           "agent@example.com",
+		ImpersonatorID:  &adminID,
+		ImpersonationID: &sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	require.NoError(t, err)
+
+	req := newTestRequest()
+	req.RequestCtx.Request.Header.Set("Authorization", "Bearer "+token)
+	result := middleware.Auth(testJWTSecret)(req)
+	require.NotNil(t, result)
+
+	assert.Equal(t, userID, result.RequestCtx.UserValue(middleware.ContextKeyUserID))
+	assert.Equal(t, adminID, result.RequestCtx.UserValue(middleware.ContextKeyImpersonatorID))
+	assert.Equal(t, sessionID, result.RequestCtx.UserValue(middleware.ContextKeyImpersonation))
+}
+
+func TestRestrictImpersonation(t *testing.T) {
+	t.Parallel()
+
+	blocked := []string{"/api/impersonations", "/api/api-keys", "POST /api/users", "PUT /api/roles"}
+
+	tests := []struct {
+		name          string
+		impersonating bool
+		method        string
+		path          string
+		sessionActive bool
+		wantStatus    int
+	}{
+		{"regular session", false, "POST", "/api/api-keys", false, 0},
+		{"active session", true, "POST", "/api/contacts", true, 0},
+		{"ended session", true, "POST", "/api/contacts", false, fasthttp.StatusUnauthorized},
+		{"blocked path", true, "POST", "/api/api-keys", true, fasthttp.StatusForbidden},
+		{"blocked user creation", true, "POST", "/api/users", true, fasthttp.StatusForbidden},
+		{"blocked user import", true, "POST", "/api/users/import", true, fasthttp.StatusForbidden},
+		{"blocked role edit", true, "PUT", "/api/roles/" + uuid.NewString(), true, fasthttp.StatusForbidden},
+		{"user list allowed", true, "GET", "/api/users", true, 0},
+		{"role list allowed", true, "GET", "/api/roles", true, 0},
+		{"regular session creates users", false, "POST", "/api/users", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			userID, adminID, sessionID := uuid.New(), uuid.New(), uuid.New()
+			req := newTestRequest()
+			req.RequestCtx.Request.SetRequestURI(tt.path)
+			req.RequestCtx.Request.Header.SetMethod(tt.method)
+			req.RequestCtx.SetUserValue(middleware.ContextKeyUserID, userID)
+			if tt.impersonating {
+				req.RequestCtx.SetUserValue(middleware.ContextKeyImpersonatorID, adminID)
+				req.RequestCtx.SetUserValue(middleware.ContextKeyImpersonation, sessionID)
+			}
+
+			var checked *middleware.Impersonation
+			checker := func(imp middleware.Impersonation) bool {
+				checked = &imp
+				return tt.sessionActive
+			}
+
+			result := middleware.RestrictImpersonation(checker, blocked)(req)
+			if tt.wantStatus == 0 {
+				assert.NotNil(t, result)
+			} else {
+				assert.Nil(t, result)
+				assert.Equal(t, tt.wantStatus, req.RequestCtx.Response.StatusCode())
+			}
+
+			if tt.impersonating && tt.wantStatus != fasthttp.StatusForbidden {
+				require.NotNil(t, checked)
+				assert.Equal(t, sessionID, checked.SessionID)
+				assert.Equal(t, adminID, checked.ImpersonatorID)
+				assert.Equal(t, userID, checked.UserID)
+				assert.Equal(t, tt.method, checked.Method)
+				assert.Equal(t, tt.path, checked.Path)
+			} else {
+				assert.Nil(t, checked)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestRejectSuspendedOrganization(t *testing.T) {
+	t.Parallel()
+
+	suspendedOrg := uuid.New()
+	checker := func(orgID uuid.UUID) bool { return orgID == suspendedOrg }
+
+	req := newTestRequest()
+	req.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, suspendedOrg)
+	assert.Nil(t, middleware.RejectSuspendedOrganization(checker)(req), "should reject users of a suspended organization")
+	assert.Equal(t, fasthttp.StatusForbidden, req.RequestCtx.Response.StatusCode())
+
+	superAdmin := newTestRequest()
+	superAdmin.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, suspendedOrg)
+	superAdmin.RequestCtx.SetUserValue(middleware.ContextKeyIsSuperAdmin, true)
+	assert.NotNil(t, middleware.RejectSuspendedOrganization(checker)(superAdmin), "should let super admins through")
+
+	active := newTestRequest()
+	active.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, uuid.New())
+	assert.NotNil(t, middleware.RejectSuspendedOrganization(checker)(active), "should allow active organizations")
+}
+
 func TestRequireAnyPermission(t *testing.T) {
 	t.Parallel()
 
@@ -386,6 +740,49 @@ func TestRequireAnyPermission(t *testing.T) {
 	}
 }
 
+func TestRequireFeature(t *testing.T) {
+	t.Parallel()
+
+	features := map[string]string{"/api/catalogs": "commerce"}
+	orgID := uuid.New()
+
+	tests := []struct {
+		name        string
+		path        string
+		enabled     bool
+		wantAllowed bool
+	}{
+		{name: "gated path with feature enabled", path: "/api/catalogs/123", enabled: true, wantAllowed: true},
+		{name: "gated path with feature disabled", path: "/api/catalogs", enabled: false, wantAllowed: false},
+		{name: "ungated path", path: "/api/contacts", enabled: false, wantAllowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := newTestRequest()
+			req.RequestCtx.Request.SetRequestURI(tt.path)
+			req.RequestCtx.SetUserValue(middleware.ContextKeyOrganizationID, orgID)
+
+			checker := func(id uuid.UUID, feature string) bool {
+				assert.Equal(t, orgID, id)
+				assert.Equal(t, "commerce", feature)
+				return tt.enabled
+			}
+
+			result := middleware.RequireFeature(checker, features)(req)
+
+			if tt.wantAllowed {
+				assert.NotNil(t, result, "should allow access")
+			} else {
+				assert.Nil(t, result, "should deny access")
+				assert.Equal(t, fasthttp.StatusForbidden, req.RequestCtx.Response.StatusCode())
+			}
+		})
+	}
+}
+
 func TestGetUserID(t *testing.T) {
 	t.Parallel()
 
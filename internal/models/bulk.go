@@ -18,16 +18,37 @@ type BulkMessageCampaign struct {
 	HeaderMediaMimeType  string         `gorm:"type:text" json:"header_media_mime_type"`  // MIME type (image/jpeg, video/mp4, etc.)
 	HeaderMediaLocalPath string         `gorm:"type:text" json:"header_media_local_path"` // Local file path for preview
 	Status              CampaignStatus `gorm:"size:20;default:'draft'" json:"status"`   // draft, queued, processing, completed, failed
+	TrackLinks      bool       `gorm:"default:false" json:"track_links"` // Wrap URLs in template params with tracked short links
 	TotalRecipients int        `gorm:"default:0" json:"total_recipients"`
 	SentCount       int        `gorm:"default:0" json:"sent_count"`
 	DeliveredCount  int        `gorm:"default:0" json:"delivered_count"`
 	ReadCount       int        `gorm:"default:0" json:"read_count"`
 	FailedCount     int        `gorm:"default:0" json:"failed_count"`
-	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	MaxBudget       *float64   `gorm:"type:numeric(12,4)" json:"max_budget,omitempty"` // Spend cap in pricing.Currency; nil means unlimited
+	EstimatedCost   float64    `gorm:"type:numeric(12,4);default:0" json:"estimated_cost"`
+	ActualCost      float64    `gorm:"type:numeric(12,4);default:0" json:"actual_cost"`
+	ScheduledAt     *time.Time `gorm:"index" json:"scheduled_at,omitempty"` // Started automatically at this time once scheduled
 	StartedAt       *time.Time `json:"started_at,omitempty"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 	CreatedBy       uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
 
+	// Daily delivery window (HH:MM) in each recipient's local time; empty sends at any time
+	SendWindowStart string `gorm:"size:5" json:"send_window_start,omitempty"`
+	SendWindowEnd   string `gorm:"size:5" json:"send_window_end,omitempty"`
+
+	// Template placeholder -> text filled from the recipient's contact at send time, such
+	// as "{{contact.custom_fields.order_id}}"; used for placeholders recipients leave empty
+	TemplateParamMapping JSONB `gorm:"type:jsonb;default:'{}'" json:"template_param_mapping,omitempty"`
+
+	// Google Sheets source; rows are mapped to recipients with SheetColumnMapping
+	// (column header -> phone_number, recipient_name or a template parameter name)
+	SheetSpreadsheetID    string     `gorm:"size:100" json:"sheet_spreadsheet_id,omitempty"`
+	SheetRange            string     `gorm:"size:255" json:"sheet_range,omitempty"`
+	SheetColumnMapping    JSONB      `gorm:"type:jsonb;default:'{}'" json:"sheet_column_mapping,omitempty"`
+	SheetSyncIntervalMins int        `gorm:"default:0" json:"sheet_sync_interval_mins"` // Re-sync period while draft; 0 disables
+	SheetLastSyncedAt     *time.Time `json:"sheet_last_synced_at,omitempty"`
+	SheetSyncError        string     `gorm:"type:text" json:"sheet_sync_error,omitempty"`
+
 	// Relations
 	Organization *Organization          `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 	Template     *Template              `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
@@ -53,16 +74,83 @@ type BulkMessageRecipient struct {
 	SentAt             *time.Time `json:"sent_at,omitempty"`
 	DeliveredAt        *time.Time `json:"delivered_at,omitempty"`
 	ReadAt             *time.Time `json:"read_at,omitempty"`
+	DeferredUntil      *time.Time `gorm:"index" json:"deferred_until,omitempty"` // Outside the send window; re-queued at this time
 
 	// Relations
 	Campaign *BulkMessageCampaign `gorm:"foreignKey:CampaignID" json:"campaign,omitempty"`
-	Message  *Message             `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+	Message  *Message             `gorm:"foreignKey:MessageID;-:migration" json:"message,omitempty"` // No FK: messages may be partitioned
 }
 
 func (BulkMessageRecipient) TableName() string {
 	return "bulk_message_recipients"
 }
 
+// RecipientImport is a CSV file of campaign recipients imported in the background.
+// Progress is recorded after every batch of rows.
+type RecipientImport struct {
+	BaseModel
+	OrganizationID uuid.UUID             `gorm:"type:uuid;index;not null" json:"organization_id"`
+	CampaignID     uuid.UUID             `gorm:"type:uuid;index;not null" json:"campaign_id"`
+	RequestedByID  uuid.UUID             `gorm:"type:uuid;not null" json:"requested_by_id"`
+	Filename       string                `gorm:"size:255" json:"filename"`
+	FilePath       string                `gorm:"type:text" json:"-"` // Relative to the media storage root; removed once processed
+	ColumnMapping  JSONB                 `gorm:"type:jsonb;default:'{}'" json:"column_mapping"`
+	Status         RecipientImportStatus `gorm:"size:20;not null;default:'processing'" json:"status"`
+	Error          string                `gorm:"type:text" json:"error,omitempty"`
+	ProcessedRows  int                   `gorm:"default:0" json:"processed_rows"`
+	AddedCount     int                   `gorm:"default:0" json:"added_count"`
+	RejectedCount  int                   `gorm:"default:0" json:"rejected_count"`
+	Rejected       JSONBArray            `gorm:"type:jsonb;default:'[]'" json:"rejected"` // The first rejected rows, for review
+	CompletedAt    *time.Time            `json:"completed_at,omitempty"`
+}
+
+func (RecipientImport) TableName() string {
+	return "recipient_imports"
+}
+
+// TemplateSendBatch is a set of template messages sent through the bulk send API.
+// Unlike a campaign it has no lifecycle: recipients are queued as soon as it is created.
+type TemplateSendBatch struct {
+	BaseModel
+	OrganizationID  uuid.UUID               `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string                  `gorm:"size:100;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
+	TemplateID      uuid.UUID               `gorm:"type:uuid;not null" json:"template_id"`
+	Status          TemplateSendBatchStatus `gorm:"size:20;default:'processing'" json:"status"`
+	TotalCount      int                     `gorm:"default:0" json:"total_count"`
+	SentCount       int                     `gorm:"default:0" json:"sent_count"`
+	FailedCount     int                     `gorm:"default:0" json:"failed_count"`
+	CreatedBy       *uuid.UUID              `gorm:"type:uuid" json:"created_by,omitempty"`
+	CompletedAt     *time.Time              `json:"completed_at,omitempty"`
+
+	// Relations
+	Template *Template          `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
+	Items    []TemplateSendItem `gorm:"foreignKey:BatchID" json:"items,omitempty"`
+}
+
+func (TemplateSendBatch) TableName() string {
+	return "template_send_batches"
+}
+
+// TemplateSendItem is a single recipient of a TemplateSendBatch
+type TemplateSendItem struct {
+	BaseModel
+	BatchID           uuid.UUID     `gorm:"type:uuid;index;not null" json:"batch_id"`
+	OrganizationID    uuid.UUID     `gorm:"type:uuid;not null" json:"organization_id"`
+	PhoneNumber       string        `gorm:"size:20;not null" json:"phone_number"`
+	TemplateParams    JSONB         `gorm:"type:jsonb;default:'{}'" json:"template_params"`
+	Reference         string        `gorm:"size:255" json:"reference,omitempty"` // Caller's ID for the recipient, e.g. an order number
+	Status            MessageStatus `gorm:"size:20;default:'pending'" json:"status"`
+	MessageID         *uuid.UUID    `gorm:"type:uuid" json:"message_id,omitempty"`
+	WhatsAppMessageID string        `gorm:"column:whats_app_message_id;size:100" json:"whatsapp_message_id,omitempty"`
+	ErrorMessage      string        `gorm:"type:text" json:"error_message,omitempty"`
+	ErrorCode         int           `gorm:"default:0" json:"error_code,omitempty"`
+	SentAt            *time.Time    `json:"sent_at,omitempty"`
+}
+
+func (TemplateSendItem) TableName() string {
+	return "template_send_items"
+}
+
 // NotificationRule defines automated notification rules
 type NotificationRule struct {
 	BaseModel
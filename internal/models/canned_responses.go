@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -24,3 +26,18 @@ type CannedResponse struct {
 func (CannedResponse) TableName() string {
 	return "canned_responses"
 }
+
+// CannedResponseUsage counts how often an agent inserted a canned response, so their
+// searches rank the responses they use most first
+type CannedResponseUsage struct {
+	BaseModel
+	OrganizationID   uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	UserID           uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_canned_response_usage_user;not null" json:"user_id"`
+	CannedResponseID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_canned_response_usage_user;index;not null" json:"canned_response_id"`
+	UseCount         int       `gorm:"default:0" json:"use_count"`
+	LastUsedAt       time.Time `json:"last_used_at"`
+}
+
+func (CannedResponseUsage) TableName() string {
+	return "canned_response_usages"
+}
@@ -19,6 +19,12 @@ type AgentAssignmentConfig struct {
 	AllowQueuePickup        bool `gorm:"column:allow_agent_queue_pickup;default:true" json:"allow_agent_queue_pickup"`           // Allow agents to pick transfers from queue
 	AssignToSameAgent       bool `gorm:"column:assign_to_same_agent;default:true" json:"assign_to_same_agent"`                   // Auto-assign transfers to contact's existing agent
 	CurrentConversationOnly bool `gorm:"column:agent_current_conversation_only;default:false" json:"agent_current_conversation_only"` // Agents see only current session messages
+	AcceptTimeoutSecs       int  `gorm:"column:assignment_accept_timeout_secs;default:0" json:"assignment_accept_timeout_secs"`       // Time agents get to accept auto-assigned transfers (0 = no prompt)
+
+	// How transfers without a team are assigned when the contact has no agent to return to
+	Strategy       AssignmentStrategy `gorm:"column:assignment_strategy;size:30;default:'queue'" json:"assignment_strategy"`
+	TeamID         *uuid.UUID         `gorm:"column:assignment_team_id;type:uuid" json:"assignment_team_id,omitempty"` // Team used by the by_team strategy
+	MaxActiveChats int                `gorm:"column:assignment_max_active_chats;default:0" json:"assignment_max_active_chats"` // Agents at this many active transfers are skipped (0 = no limit)
 }
 
 // SLAConfig holds SLA tracking settings
@@ -42,6 +48,20 @@ type ClientInactivityConfig struct {
 	AutoCloseMessage string `gorm:"column:client_auto_close_message;type:text" json:"client_auto_close_message"`   // Message when closing due to client inactivity
 }
 
+// CSATConfig holds post-conversation rating survey settings
+type CSATConfig struct {
+	Enabled         bool   `gorm:"column:csat_enabled;default:false" json:"csat_enabled"`                 // Send a 1-5 rating survey when a transfer is closed
+	Question        string `gorm:"column:csat_question;type:text" json:"csat_question"`                   // Survey question shown with the rating buttons
+	CommentPrompt   string `gorm:"column:csat_comment_prompt;type:text" json:"csat_comment_prompt"`       // Optional follow-up asking for a comment (empty = skip)
+	ThankYouMessage string `gorm:"column:csat_thank_you_message;type:text" json:"csat_thank_you_message"` // Sent once the survey is complete
+}
+
+// WrapUpConfig holds what agents do when they resolve a conversation
+type WrapUpConfig struct {
+	RequireCode    bool   `gorm:"column:wrap_up_require_code;default:false" json:"wrap_up_require_code"` // Agents must pick a wrap-up code to resolve
+	ClosingMessage string `gorm:"column:wrap_up_closing_message;type:text" json:"wrap_up_closing_message"` // Sent to the contact on resolve (empty = none)
+}
+
 // AIConfig holds AI provider settings
 type AIConfig struct {
 	Enabled        bool    `gorm:"column:ai_enabled;default:false" json:"ai_enabled"`
@@ -53,6 +73,8 @@ type AIConfig struct {
 	SystemPrompt   string  `gorm:"column:ai_system_prompt;type:text" json:"ai_system_prompt"`
 	IncludeHistory bool    `gorm:"column:ai_include_history;default:true" json:"ai_include_history"`
 	HistoryLimit   int     `gorm:"column:ai_history_limit;default:4" json:"ai_history_limit"`
+	MemoryEnabled  bool    `gorm:"column:ai_memory_enabled;default:false" json:"ai_memory_enabled"` // Remember per-contact facts across sessions
+	SentimentPriority bool `gorm:"column:ai_sentiment_priority;default:false" json:"ai_sentiment_priority"` // Raise conversation priority for upset customers
 }
 
 // PanelFieldConfig defines a field to display in the contact info panel
@@ -87,6 +109,7 @@ type ChatbotSettings struct {
 	OrganizationID  uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
 	WhatsAppAccount string    `gorm:"size:100;index" json:"whatsapp_account"` // References WhatsAppAccount.Name (empty for org-level defaults)
 	IsEnabled       bool      `gorm:"default:false" json:"is_enabled"`
+	RolloutPercent  int       `gorm:"default:100" json:"rollout_percent"` // Share of contacts (0-100) the chatbot handles; the rest go to the agent queue
 
 	// Response settings
 	DefaultResponse string     `gorm:"type:text" json:"default_response"`
@@ -99,6 +122,8 @@ type ChatbotSettings struct {
 	AgentAssignment  AgentAssignmentConfig  `gorm:"embedded"`
 	SLA              SLAConfig              `gorm:"embedded"`
 	ClientInactivity ClientInactivityConfig `gorm:"embedded"`
+	CSAT             CSATConfig             `gorm:"embedded"`
+	WrapUp           WrapUpConfig           `gorm:"embedded"`
 	AI               AIConfig               `gorm:"embedded"`
 
 	// Session settings
@@ -127,6 +152,7 @@ type KeywordRule struct {
 	ResponseType    ResponseType `gorm:"size:20;not null" json:"response_type"` // text, template, media, flow, script
 	ResponseContent JSONB       `gorm:"type:jsonb;not null" json:"response_content"`
 	Conditions      string      `gorm:"type:text" json:"conditions"`
+	SetPriority     ConversationPriority `gorm:"size:10" json:"set_priority,omitempty"` // Raises the conversation's priority on a match
 	ActiveFrom      *time.Time  `json:"active_from,omitempty"`
 	ActiveUntil     *time.Time  `json:"active_until,omitempty"`
 
@@ -145,6 +171,7 @@ type ChatbotFlow struct {
 	WhatsAppAccount    string      `gorm:"size:100;index;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
 	Name               string      `gorm:"size:255;not null" json:"name"`
 	IsEnabled          bool        `gorm:"default:true" json:"is_enabled"`
+	RolloutPercent     int         `gorm:"default:100" json:"rollout_percent"` // Share of contacts (0-100) the flow triggers for
 	Description        string      `gorm:"type:text" json:"description"`
 	TriggerKeywords    StringArray `gorm:"type:jsonb" json:"trigger_keywords"`
 	TriggerButtonID    string      `gorm:"size:100" json:"trigger_button_id"`
@@ -158,6 +185,12 @@ type ChatbotFlow struct {
 	CancelKeywords     StringArray `gorm:"type:jsonb" json:"cancel_keywords"`
 	PanelConfig        JSONB       `gorm:"type:jsonb;default:'{}'" json:"panel_config"` // Contact info panel configuration
 
+	// Abandonment: runs when the user stops responding mid-flow for AbandonAfterMins
+	AbandonAfterMins int               `gorm:"default:0" json:"abandon_after_minutes"` // 0 = disabled
+	AbandonAction    FlowAbandonAction `gorm:"size:20" json:"abandon_action"`          // none, transfer, webhook
+	AbandonConfig    JSONB             `gorm:"type:jsonb" json:"abandon_config"`       // transfer: {team_id, notes}; webhook: {url, method, headers, body}
+	AbandonMessage   string            `gorm:"type:text" json:"abandon_message"`
+
 	// Relations
 	Organization    *Organization     `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 	InitialTemplate *Template         `gorm:"foreignKey:InitialTemplateID" json:"initial_template,omitempty"`
@@ -179,8 +212,8 @@ type ChatbotFlowStep struct {
 	TemplateID      *uuid.UUID `gorm:"type:uuid" json:"template_id,omitempty"`
 	ApiConfig       JSONB      `gorm:"type:jsonb" json:"api_config"`      // {url, method, headers, body, response_path, fallback_message}
 	Buttons         JSONBArray `gorm:"type:jsonb" json:"buttons"`         // [{id, title}] - max 10 options (3=buttons, 4-10=list)
-	TransferConfig  JSONB      `gorm:"type:jsonb" json:"transfer_config"` // {team_id: uuid, notes: string} - for transfer message type
-	InputType       InputType  `gorm:"size:20" json:"input_type"`         // none, text, number, email, phone, date, select, button, whatsapp_flow
+	TransferConfig  JSONB      `gorm:"type:jsonb" json:"transfer_config"` // {team_id: uuid, notes: string, skills: []string} - for transfer message type
+	InputType       InputType  `gorm:"size:20" json:"input_type"`         // none, text, number, email, phone, date, select, button, whatsapp_flow, location
 	InputConfig     JSONB      `gorm:"type:jsonb" json:"input_config"`
 	ValidationRegex string     `gorm:"size:255" json:"validation_regex"`
 	ValidationError string     `gorm:"type:text" json:"validation_error"`
@@ -191,6 +224,12 @@ type ChatbotFlowStep struct {
 	RetryOnInvalid  bool       `gorm:"default:true" json:"retry_on_invalid"`
 	MaxRetries      int        `gorm:"default:3" json:"max_retries"`
 
+	// Inactivity nudge: after InactivityTimeoutMins without a reply, branch to
+	// InactivityNextStep if set, otherwise send InactivityMessage once
+	InactivityTimeoutMins int    `gorm:"default:0" json:"inactivity_timeout_minutes"` // 0 = disabled
+	InactivityMessage     string `gorm:"type:text" json:"inactivity_message"`
+	InactivityNextStep    string `gorm:"size:100" json:"inactivity_next_step"`
+
 	// Relations
 	Flow     *ChatbotFlow `gorm:"foreignKey:FlowID" json:"flow,omitempty"`
 	Template *Template    `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
@@ -200,6 +239,28 @@ func (ChatbotFlowStep) TableName() string {
 	return "chatbot_flow_steps"
 }
 
+// ChatbotFlowVersion is a saved revision of a chatbot flow. Edits are saved to a draft
+// version; publishing a version copies it onto the live flow and its steps.
+type ChatbotFlowVersion struct {
+	BaseModel
+	OrganizationID uuid.UUID         `gorm:"type:uuid;index;not null" json:"organization_id"`
+	FlowID         uuid.UUID         `gorm:"type:uuid;index;not null" json:"flow_id"`
+	Version        int               `gorm:"not null" json:"version"`
+	Status         FlowVersionStatus `gorm:"size:20;not null" json:"status"` // draft, published, superseded
+	Definition     JSONB             `gorm:"type:jsonb" json:"definition"`   // Flow settings and steps as sent by the flow builder
+	CreatedByID    *uuid.UUID        `gorm:"type:uuid" json:"created_by_id,omitempty"`
+	PublishedByID  *uuid.UUID        `gorm:"type:uuid" json:"published_by_id,omitempty"`
+	PublishedAt    *time.Time        `json:"published_at,omitempty"`
+
+	// Relations
+	CreatedBy   *User `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	PublishedBy *User `gorm:"foreignKey:PublishedByID" json:"published_by,omitempty"`
+}
+
+func (ChatbotFlowVersion) TableName() string {
+	return "chatbot_flow_versions"
+}
+
 // ChatbotSession tracks active conversation sessions
 type ChatbotSession struct {
 	BaseModel
@@ -216,6 +277,11 @@ type ChatbotSession struct {
 	LastActivityAt  time.Time  `json:"last_activity_at"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 
+	// Flow inactivity scheduling (processed by the SLA processor)
+	StepNudgeAt *time.Time `gorm:"index" json:"step_nudge_at,omitempty"` // When the current step's inactivity nudge is due
+	StepNudged  bool       `gorm:"default:false" json:"step_nudged"`
+	AbandonAt   *time.Time `gorm:"index" json:"abandon_at,omitempty"` // When the flow's abandonment action is due
+
 	// Relations
 	Organization *Organization           `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 	Contact      *Contact                `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
@@ -243,6 +309,23 @@ func (ChatbotSessionMessage) TableName() string {
 	return "chatbot_session_messages"
 }
 
+// ChatbotSessionTrace records how the chatbot handled a session, for replaying why a
+// conversation took the path it did
+type ChatbotSessionTrace struct {
+	BaseModel
+	SessionID uuid.UUID        `gorm:"type:uuid;index;not null" json:"session_id"`
+	Kind      SessionTraceKind `gorm:"size:20;not null" json:"kind"`
+	StepName  string           `gorm:"size:100" json:"step_name"`
+	Detail    JSONB            `gorm:"type:jsonb;default:'{}'" json:"detail"` // Inputs and results; secrets are redacted
+
+	// Relations
+	Session *ChatbotSession `gorm:"foreignKey:SessionID" json:"session,omitempty"`
+}
+
+func (ChatbotSessionTrace) TableName() string {
+	return "chatbot_session_traces"
+}
+
 // AIContext provides context data for AI responses
 type AIContext struct {
 	BaseModel
@@ -264,6 +347,39 @@ func (AIContext) TableName() string {
 	return "ai_contexts"
 }
 
+// ChatbotVariable is an organization-wide constant (a store URL, support hours, a promo
+// code) that flow messages, keyword responses and AI prompts reference as {{org.name}}
+type ChatbotVariable struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string    `gorm:"size:100;not null" json:"name"`
+	Value          string    `gorm:"type:text" json:"value"`
+	Description    string    `gorm:"size:500" json:"description"`
+}
+
+func (ChatbotVariable) TableName() string {
+	return "chatbot_variables"
+}
+
+// ContactMemory holds AI-condensed long-term facts and preferences about a contact,
+// included in the AI system prompt for future sessions
+type ContactMemory struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID      uuid.UUID  `gorm:"type:uuid;uniqueIndex;not null" json:"contact_id"`
+	Facts          string     `gorm:"type:text" json:"facts"`
+	LastSessionID  *uuid.UUID `gorm:"type:uuid" json:"last_session_id,omitempty"` // Last session folded into the memory
+	SummarizedAt   *time.Time `json:"summarized_at,omitempty"`
+	EditedBy       *uuid.UUID `gorm:"type:uuid" json:"edited_by,omitempty"` // Set when an agent edits the memory by hand
+
+	// Relations
+	Contact *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+func (ContactMemory) TableName() string {
+	return "contact_memories"
+}
+
 // SLATracking holds SLA-related tracking fields for agent transfers
 type SLATracking struct {
 	ResponseDeadline   *time.Time `gorm:"column:sla_response_deadline;index" json:"sla_response_deadline,omitempty"`   // When pickup is due
@@ -291,9 +407,13 @@ type AgentTransfer struct {
 	TeamID              *uuid.UUID `gorm:"type:uuid;index" json:"team_id,omitempty"` // Team queue (null = general queue)
 	TransferredByUserID *uuid.UUID `gorm:"type:uuid" json:"transferred_by_user_id,omitempty"` // User who initiated the transfer (null for system)
 	Notes               string     `gorm:"type:text" json:"notes"`
+	HandoffContext      JSONB      `gorm:"type:jsonb" json:"handoff_context,omitempty"` // Variables a flow collected before handing off
+	Priority            ConversationPriority `gorm:"size:10;default:'normal'" json:"priority"` // Copied from the contact; orders the queue
 	TransferredAt       time.Time  `gorm:"autoCreateTime" json:"transferred_at"`
 	ResumedAt           *time.Time `json:"resumed_at,omitempty"`
 	ResumedBy           *uuid.UUID `gorm:"type:uuid" json:"resumed_by,omitempty"`
+	WrapUpCodeID        *uuid.UUID `gorm:"type:uuid;index" json:"wrap_up_code_id,omitempty"` // Disposition the agent resolved with
+	WrapUpNotes         string     `gorm:"type:text" json:"wrap_up_notes,omitempty"`
 
 	// SLA Tracking (embedded - all fields stored in same table)
 	SLA SLATracking `gorm:"embedded"`
@@ -305,8 +425,82 @@ type AgentTransfer struct {
 	Team              *Team         `gorm:"foreignKey:TeamID" json:"team,omitempty"`
 	TransferredByUser *User         `gorm:"foreignKey:TransferredByUserID" json:"transferred_by_user,omitempty"`
 	ResumedByUser     *User         `gorm:"foreignKey:ResumedBy" json:"resumed_by_user,omitempty"`
+	WrapUpCode        *WrapUpCode   `gorm:"foreignKey:WrapUpCodeID" json:"wrap_up_code,omitempty"`
 }
 
 func (AgentTransfer) TableName() string {
 	return "agent_transfers"
 }
+
+// TransferAssignmentOffer records an auto-assigned transfer waiting for the agent to
+// accept it. Declined and expired offers pass the transfer to the next agent or the queue.
+type TransferAssignmentOffer struct {
+	BaseModel
+	OrganizationID uuid.UUID             `gorm:"type:uuid;index;not null" json:"organization_id"`
+	TransferID     uuid.UUID             `gorm:"type:uuid;index;not null" json:"transfer_id"`
+	AgentID        uuid.UUID             `gorm:"type:uuid;index;not null" json:"agent_id"`
+	Status         AssignmentOfferStatus `gorm:"size:20;default:'pending'" json:"status"`
+	ExpiresAt      time.Time             `gorm:"not null" json:"expires_at"`
+	RespondedAt    *time.Time            `json:"responded_at,omitempty"`
+}
+
+func (TransferAssignmentOffer) TableName() string {
+	return "transfer_assignment_offers"
+}
+
+// WrapUpCode is a disposition agents pick when resolving a conversation, such as
+// "billing question" or "refund issued". Codes feed the contact reasons analytics.
+type WrapUpCode struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Code           string    `gorm:"size:50;not null" json:"code"`
+	Name           string    `gorm:"size:255;not null" json:"name"`
+	Description    string    `gorm:"type:text" json:"description"`
+	IsActive       bool      `gorm:"default:true" json:"is_active"`
+}
+
+func (WrapUpCode) TableName() string {
+	return "wrap_up_codes"
+}
+
+// UnansweredQuestion records a message the chatbot fell back on, so recurring questions
+// can be turned into keyword rules or AI context. Occurrences are grouped by Normalized.
+type UnansweredQuestion struct {
+	BaseModel
+	OrganizationID  uuid.UUID            `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string               `gorm:"size:100;index;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
+	ContactID       uuid.UUID            `gorm:"type:uuid;index;not null" json:"contact_id"`
+	SessionID       uuid.UUID            `gorm:"type:uuid" json:"session_id"`
+	Question        string               `gorm:"type:text;not null" json:"question"`
+	Normalized      string               `gorm:"size:255;index;not null" json:"normalized"`
+	Reason          UnansweredReason     `gorm:"size:20;not null" json:"reason"`
+	Resolution      UnansweredResolution `gorm:"size:20" json:"resolution,omitempty"`
+	ResolvedAt      *time.Time           `gorm:"index" json:"resolved_at,omitempty"`
+}
+
+func (UnansweredQuestion) TableName() string {
+	return "chatbot_unanswered_questions"
+}
+
+// ChatRating stores a contact's satisfaction rating for a closed conversation
+type ChatRating struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string     `gorm:"size:100;index;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
+	ContactID       uuid.UUID  `gorm:"type:uuid;index;not null" json:"contact_id"`
+	TransferID      *uuid.UUID `gorm:"type:uuid;index" json:"transfer_id,omitempty"`
+	AgentID         *uuid.UUID `gorm:"type:uuid;index" json:"agent_id,omitempty"`
+	Rating          int        `gorm:"default:0" json:"rating"` // 1-5, 0 until answered
+	Comment         string     `gorm:"type:text" json:"comment"`
+	Status          CSATStatus `gorm:"size:20;default:'pending'" json:"status"`
+	RatedAt         *time.Time `json:"rated_at,omitempty"`
+
+	// Relations
+	Contact  *Contact       `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+	Transfer *AgentTransfer `gorm:"foreignKey:TransferID" json:"transfer,omitempty"`
+	Agent    *User          `gorm:"foreignKey:AgentID" json:"agent,omitempty"`
+}
+
+func (ChatRating) TableName() string {
+	return "chat_ratings"
+}
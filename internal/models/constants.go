@@ -16,6 +16,15 @@ const (
 	DirectionOutgoing Direction = "outgoing"
 )
 
+// LifecycleStage represents where a contact is in the customer lifecycle
+type LifecycleStage string
+
+const (
+	LifecycleStageLead     LifecycleStage = "lead"
+	LifecycleStageCustomer LifecycleStage = "customer"
+	LifecycleStageDormant  LifecycleStage = "dormant"
+)
+
 // MessageType represents the type of WhatsApp message
 type MessageType string
 
@@ -30,7 +39,18 @@ const (
 	MessageTypeFlow        MessageType = "flow"
 	MessageTypeReaction    MessageType = "reaction"
 	MessageTypeLocation    MessageType = "location"
-	MessageTypeContact     MessageType = "contact"
+	MessageTypeContact     MessageType = "contacts" // Contact cards, named as in the webhook
+	MessageTypeSticker     MessageType = "sticker"
+)
+
+// ButtonType represents the kind of button a contact tapped
+type ButtonType string
+
+const (
+	ButtonTypeReply      ButtonType = "reply"       // Interactive reply button
+	ButtonTypeList       ButtonType = "list"        // Interactive list row
+	ButtonTypeQuickReply ButtonType = "quick_reply" // Template quick-reply button
+	ButtonTypeURL        ButtonType = "url"         // CTA URL button, recorded when its tracked link is opened
 )
 
 // MessageStatus represents the delivery status of a message
@@ -38,11 +58,69 @@ type MessageStatus string
 
 const (
 	MessageStatusPending   MessageStatus = "pending"
+	MessageStatusAccepted  MessageStatus = "accepted" // Accepted by the WhatsApp API; only recorded in status history
 	MessageStatusSent      MessageStatus = "sent"
 	MessageStatusDelivered MessageStatus = "delivered"
 	MessageStatusRead      MessageStatus = "read"
 	MessageStatusFailed    MessageStatus = "failed"
 	MessageStatusReceived  MessageStatus = "received"
+	MessageStatusCancelled MessageStatus = "cancelled" // Retracted by an agent before it was sent
+)
+
+// OutboxStatus represents the delivery state of an outgoing message in the outbox
+type OutboxStatus string
+
+const (
+	OutboxStatusPending    OutboxStatus = "pending"    // Waiting for its next attempt
+	OutboxStatusProcessing OutboxStatus = "processing" // Claimed by a sender, waiting for a send slot
+	OutboxStatusSending    OutboxStatus = "sending"    // On its way to WhatsApp; can no longer be cancelled
+	OutboxStatusDead       OutboxStatus = "dead"       // Gave up; only sent again when retried by hand
+)
+
+// ApprovalStatus represents the review state of an outbound message awaiting approval
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending  ApprovalStatus = "pending"
+	ApprovalStatusApproved ApprovalStatus = "approved"
+	ApprovalStatusRejected ApprovalStatus = "rejected"
+)
+
+// AuditAction represents the kind of event recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionAPIKeyIPBlocked   AuditAction = "api_key_ip_blocked"
+	AuditActionAdminIPBlocked    AuditAction = "admin_ip_blocked"
+	AuditActionAdminLoginBlocked AuditAction = "admin_login_blocked"
+
+	AuditActionImpersonationRequested AuditAction = "impersonation_requested"
+	AuditActionImpersonationApproved  AuditAction = "impersonation_approved"
+	AuditActionImpersonationDenied    AuditAction = "impersonation_denied"
+	AuditActionImpersonationStarted   AuditAction = "impersonation_started"
+	AuditActionImpersonationEnded     AuditAction = "impersonation_ended"
+	AuditActionImpersonatedRequest    AuditAction = "impersonated_request"
+	AuditActionImpersonationConsent   AuditAction = "impersonation_consent_changed"
+
+	AuditActionOrganizationSuspended   AuditAction = "organization_suspended"
+	AuditActionOrganizationReactivated AuditAction = "organization_reactivated"
+	AuditActionDataRegionChanged       AuditAction = "data_region_changed"
+
+	AuditActionEncryptionKeyConfigured AuditAction = "encryption_key_configured"
+	AuditActionEncryptionKeyRotated    AuditAction = "encryption_key_rotated"
+
+	AuditActionMediaRejected AuditAction = "inbound_media_rejected"
+)
+
+// ImpersonationStatus represents the state of an impersonation session
+type ImpersonationStatus string
+
+const (
+	ImpersonationStatusPending  ImpersonationStatus = "pending"  // Waiting for the user's consent
+	ImpersonationStatusApproved ImpersonationStatus = "approved" // Consent given, not started yet
+	ImpersonationStatusDenied   ImpersonationStatus = "denied"
+	ImpersonationStatusActive   ImpersonationStatus = "active"
+	ImpersonationStatusEnded    ImpersonationStatus = "ended"
 )
 
 // AIProvider represents supported AI providers
@@ -99,6 +177,35 @@ const (
 	SessionStatusTimeout   SessionStatus = "timeout"
 )
 
+// SessionTraceKind represents what a chatbot session trace entry records
+type SessionTraceKind string
+
+const (
+	SessionTraceKindStep      SessionTraceKind = "step"      // A flow step was sent
+	SessionTraceKindSkip      SessionTraceKind = "skip"      // A step's skip condition was evaluated
+	SessionTraceKindCondition SessionTraceKind = "condition" // Conditional routing picked the next step
+	SessionTraceKindAPIFetch  SessionTraceKind = "api_fetch" // An API fetch step called an external API
+	SessionTraceKindAIPrompt  SessionTraceKind = "ai_prompt" // A prompt was sent to the AI provider
+)
+
+// FlowAbandonAction represents what happens when a user abandons a chatbot flow
+type FlowAbandonAction string
+
+const (
+	FlowAbandonActionNone     FlowAbandonAction = "none"
+	FlowAbandonActionTransfer FlowAbandonAction = "transfer"
+	FlowAbandonActionWebhook  FlowAbandonAction = "webhook"
+)
+
+// FlowVersionStatus represents the state of a chatbot flow version
+type FlowVersionStatus string
+
+const (
+	FlowVersionStatusDraft      FlowVersionStatus = "draft"      // Being edited, not live
+	FlowVersionStatusPublished  FlowVersionStatus = "published"  // Live
+	FlowVersionStatusSuperseded FlowVersionStatus = "superseded" // Was live, replaced by a later publish or rollback
+)
+
 // TransferStatus represents agent transfer states
 type TransferStatus string
 
@@ -118,6 +225,61 @@ const (
 	TransferSourceChatbotDisabled TransferSource = "chatbot_disabled"
 )
 
+// ConversationPriority labels how urgently a conversation needs an agent. Waiting
+// transfers are picked in priority order, oldest first within a priority.
+type ConversationPriority string
+
+const (
+	ConversationPriorityUrgent ConversationPriority = "urgent"
+	ConversationPriorityHigh   ConversationPriority = "high"
+	ConversationPriorityNormal ConversationPriority = "normal"
+)
+
+// PrioritySource represents what set a conversation's priority
+type PrioritySource string
+
+const (
+	PrioritySourceManual    PrioritySource = "manual"
+	PrioritySourceKeyword   PrioritySource = "keyword"
+	PrioritySourceSentiment PrioritySource = "sentiment"
+)
+
+// AssignmentOfferStatus represents an agent's answer to an auto-assigned transfer
+type AssignmentOfferStatus string
+
+const (
+	AssignmentOfferPending  AssignmentOfferStatus = "pending"
+	AssignmentOfferAccepted AssignmentOfferStatus = "accepted"
+	AssignmentOfferDeclined AssignmentOfferStatus = "declined"
+	AssignmentOfferExpired  AssignmentOfferStatus = "expired"
+)
+
+// UnansweredReason represents why the chatbot couldn't answer a question
+type UnansweredReason string
+
+const (
+	UnansweredReasonNoMatch  UnansweredReason = "no_match"  // No keyword, flow or AI handled it
+	UnansweredReasonAIFailed UnansweredReason = "ai_failed" // The AI provider errored or returned nothing
+)
+
+// UnansweredResolution represents what was done about an unanswered question
+type UnansweredResolution string
+
+const (
+	UnansweredResolvedKeywordRule UnansweredResolution = "keyword_rule"
+	UnansweredResolvedAIContext   UnansweredResolution = "ai_context"
+	UnansweredResolvedDismissed   UnansweredResolution = "dismissed"
+)
+
+// CSATStatus represents the state of a conversation rating survey
+type CSATStatus string
+
+const (
+	CSATStatusPending         CSATStatus = "pending"
+	CSATStatusAwaitingComment CSATStatus = "awaiting_comment"
+	CSATStatusCompleted       CSATStatus = "completed"
+)
+
 // CampaignStatus represents bulk message campaign states
 type CampaignStatus string
 
@@ -132,6 +294,117 @@ const (
 	CampaignStatusFailed     CampaignStatus = "failed"
 )
 
+// EncryptionKeyProvider is the KMS an organization's own key lives in
+type EncryptionKeyProvider string
+
+const (
+	// EncryptionKeyVaultTransit is the Transit secrets engine of HashiCorp Vault or OpenBao
+	EncryptionKeyVaultTransit EncryptionKeyProvider = "vault_transit"
+)
+
+// EncryptionKeyStatus is whether an organization's own key could last be used
+type EncryptionKeyStatus string
+
+const (
+	EncryptionKeyActive      EncryptionKeyStatus = "active"
+	EncryptionKeyUnavailable EncryptionKeyStatus = "unavailable" // The KMS refused the key, e.g. it was revoked
+)
+
+// SequenceStatus represents drip sequence states
+type SequenceStatus string
+
+const (
+	SequenceStatusDraft  SequenceStatus = "draft"  // Being set up; enrolled contacts wait
+	SequenceStatusActive SequenceStatus = "active" // Sending steps as they come due
+	SequenceStatusPaused SequenceStatus = "paused" // Due steps wait until it is activated again
+)
+
+// SequenceEnrollmentStatus represents a contact's progress through a sequence
+type SequenceEnrollmentStatus string
+
+const (
+	SequenceEnrollmentActive    SequenceEnrollmentStatus = "active"
+	SequenceEnrollmentCompleted SequenceEnrollmentStatus = "completed" // Every step was sent
+	SequenceEnrollmentExited    SequenceEnrollmentStatus = "exited"    // Left early; see the exit reason
+	SequenceEnrollmentFailed    SequenceEnrollmentStatus = "failed"    // A step couldn't be sent
+)
+
+// SequenceExitReason says why a contact left a sequence early
+type SequenceExitReason string
+
+const (
+	SequenceExitReplied       SequenceExitReason = "replied"
+	SequenceExitButtonClicked SequenceExitReason = "button_clicked"
+	SequenceExitOptedOut      SequenceExitReason = "opted_out"
+	SequenceExitUnenrolled    SequenceExitReason = "unenrolled"
+)
+
+// APIKeyScope limits what an API key can be used for
+type APIKeyScope string
+
+const (
+	APIKeyScopeFull          APIKeyScope = "full"          // Everything the key's creator can do
+	APIKeyScopeTransactional APIKeyScope = "transactional" // Only the /api/v1 transactional messaging API
+)
+
+// VerificationStatus represents the state of a phone number verification
+type VerificationStatus string
+
+const (
+	VerificationStatusPending  VerificationStatus = "pending"
+	VerificationStatusApproved VerificationStatus = "approved"
+	VerificationStatusExpired  VerificationStatus = "expired"
+	VerificationStatusFailed   VerificationStatus = "failed"   // Too many wrong codes, or the code could not be sent
+	VerificationStatusCanceled VerificationStatus = "canceled" // Replaced by a newer verification for the number
+)
+
+// AnalyticsExportStatus represents the state of an analytics export job
+type AnalyticsExportStatus string
+
+const (
+	AnalyticsExportStatusPending    AnalyticsExportStatus = "pending"
+	AnalyticsExportStatusProcessing AnalyticsExportStatus = "processing"
+	AnalyticsExportStatusCompleted  AnalyticsExportStatus = "completed"
+	AnalyticsExportStatusFailed     AnalyticsExportStatus = "failed"
+	AnalyticsExportStatusExpired    AnalyticsExportStatus = "expired" // Download window passed and the file was removed
+)
+
+// RecipientImportStatus represents the state of a campaign recipient file import
+type RecipientImportStatus string
+
+const (
+	RecipientImportStatusProcessing RecipientImportStatus = "processing"
+	RecipientImportStatusCompleted  RecipientImportStatus = "completed"
+	RecipientImportStatusFailed     RecipientImportStatus = "failed"
+)
+
+// ContactImportStatus represents the state of a contact file import
+type ContactImportStatus string
+
+const (
+	ContactImportStatusProcessing ContactImportStatus = "processing"
+	ContactImportStatusCompleted  ContactImportStatus = "completed"
+	ContactImportStatusFailed     ContactImportStatus = "failed"
+)
+
+// ContactBulkUpdateStatus represents the state of a bulk contact update
+type ContactBulkUpdateStatus string
+
+const (
+	ContactBulkUpdateStatusQueued     ContactBulkUpdateStatus = "queued"
+	ContactBulkUpdateStatusProcessing ContactBulkUpdateStatus = "processing"
+	ContactBulkUpdateStatusCompleted  ContactBulkUpdateStatus = "completed"
+	ContactBulkUpdateStatusFailed     ContactBulkUpdateStatus = "failed"
+)
+
+// TemplateSendBatchStatus represents bulk template send batch states
+type TemplateSendBatchStatus string
+
+const (
+	TemplateSendBatchStatusProcessing TemplateSendBatchStatus = "processing"
+	TemplateSendBatchStatusCompleted  TemplateSendBatchStatus = "completed"
+)
+
 // TemplateStatus represents WhatsApp template approval states
 type TemplateStatus string
 
@@ -171,15 +444,23 @@ const (
 	InputTypeSelect       InputType = "select"
 	InputTypeButton       InputType = "button"
 	InputTypeWhatsAppFlow InputType = "whatsapp_flow"
+	InputTypeLocation     InputType = "location" // Contact shares a location pin
 )
 
-// AssignmentStrategy represents team assignment strategies
+// AssignmentStrategy represents how transfers are assigned to agents, for a team or
+// for the organization's transfers that have no team
 type AssignmentStrategy string
 
 const (
 	AssignmentStrategyRoundRobin   AssignmentStrategy = "round_robin"
 	AssignmentStrategyLoadBalanced AssignmentStrategy = "load_balanced"
 	AssignmentStrategyManual       AssignmentStrategy = "manual"
+
+	// Organization-wide strategies, set in chatbot settings
+	AssignmentStrategyQueue            AssignmentStrategy = "queue" // Leave transfers for agents to pick up
+	AssignmentStrategyLeastActiveChats AssignmentStrategy = "least_active_chats"
+	AssignmentStrategyByTeam           AssignmentStrategy = "by_team"  // Hand transfers to a default team and its strategy
+	AssignmentStrategyBySkill          AssignmentStrategy = "by_skill" // Match contact tags against agent skills
 )
 
 // SSOProviderType represents supported SSO providers
@@ -200,12 +481,103 @@ const (
 	WebhookEventMessageIncoming  WebhookEvent = "message.incoming"
 	WebhookEventMessageOutgoing  WebhookEvent = "message.outgoing"
 	WebhookEventMessageSent      WebhookEvent = "message.sent"
+	WebhookEventMessageDelivered WebhookEvent = "message.delivered"
+	WebhookEventMessageRead      WebhookEvent = "message.read"
+	WebhookEventMessageFailed    WebhookEvent = "message.failed"
 	WebhookEventContactCreated   WebhookEvent = "contact.created"
 	WebhookEventTransferCreated  WebhookEvent = "transfer.created"
 	WebhookEventTransferResumed  WebhookEvent = "transfer.resumed"
 	WebhookEventTransferAssigned WebhookEvent = "transfer.assigned"
 )
 
+// NotificationEvent represents events that can be posted to Slack or Teams channels
+type NotificationEvent string
+
+const (
+	NotificationEventTransferQueued    NotificationEvent = "transfer.queued"
+	NotificationEventSLABreached       NotificationEvent = "sla.breached"
+	NotificationEventCampaignCompleted NotificationEvent = "campaign.completed"
+	NotificationEventTemplateRejected  NotificationEvent = "template.rejected"
+	NotificationEventUnansweredDigest  NotificationEvent = "chatbot.unanswered_digest"
+	NotificationEventAccountUnhealthy  NotificationEvent = "account.unhealthy"
+)
+
+// AnnouncementType controls where an announcement is shown
+type AnnouncementType string
+
+const (
+	AnnouncementTypeBanner    AnnouncementType = "banner"    // Shown across the app until dismissed or it ends
+	AnnouncementTypeChangelog AnnouncementType = "changelog" // Listed in the "What's new" feed
+)
+
+// AnnouncementCategory describes what an announcement is about
+type AnnouncementCategory string
+
+const (
+	AnnouncementCategoryInfo        AnnouncementCategory = "info"
+	AnnouncementCategoryMaintenance AnnouncementCategory = "maintenance"
+	AnnouncementCategoryFeature     AnnouncementCategory = "feature"
+)
+
+// ConsentSource is where a contact's opt-in was collected
+type ConsentSource string
+
+const (
+	ConsentSourceWebForm  ConsentSource = "web_form" // Sign-up or checkout form
+	ConsentSourceWhatsApp ConsentSource = "whatsapp" // The contact opted in within a WhatsApp conversation
+	ConsentSourceCampaign ConsentSource = "campaign" // Reply to an opt-in request sent by a campaign
+	ConsentSourceImport   ConsentSource = "import"   // Imported from another system
+	ConsentSourceOffline  ConsentSource = "offline"  // Paper form, in store or over the phone
+	ConsentSourceAPI      ConsentSource = "api"      // Recorded by an integration through the API
+)
+
+// ConsentMethod is how a contact expressed their opt-in
+type ConsentMethod string
+
+const (
+	ConsentMethodCheckbox    ConsentMethod = "checkbox"      // Ticked an unchecked box
+	ConsentMethodDoubleOptIn ConsentMethod = "double_opt_in" // Confirmed a follow-up message
+	ConsentMethodKeyword     ConsentMethod = "keyword"       // Sent an opt-in keyword
+	ConsentMethodSignature   ConsentMethod = "signature"     // Signed a form
+	ConsentMethodVerbal      ConsentMethod = "verbal"        // Agreed in person or on a call
+)
+
+// OptOutAction is a change to whether a contact receives campaign messages
+type OptOutAction string
+
+const (
+	OptOutActionOptOut OptOutAction = "opt_out" // The contact stopped receiving campaigns
+	OptOutActionOptIn  OptOutAction = "opt_in"  // The contact receives campaigns again
+)
+
+// OptOutSource is what triggered an opt-out change
+type OptOutSource string
+
+const (
+	OptOutSourceKeyword OptOutSource = "keyword" // The contact replied with an opt-out keyword
+	OptOutSourceManual  OptOutSource = "manual"  // Changed by a user or through the API
+)
+
+// SegmentFieldOperator compares a contact custom field in a segment filter
+type SegmentFieldOperator string
+
+const (
+	SegmentFieldEquals    SegmentFieldOperator = "equals"
+	SegmentFieldNotEquals SegmentFieldOperator = "not_equals" // Also matches contacts without the field
+	SegmentFieldContains  SegmentFieldOperator = "contains"   // Case-insensitive substring
+	SegmentFieldExists    SegmentFieldOperator = "exists"
+	SegmentFieldNotExists SegmentFieldOperator = "not_exists"
+)
+
+// SegmentOptIn filters a segment on the contact's marketing opt-in
+type SegmentOptIn string
+
+const (
+	SegmentOptInAny        SegmentOptIn = ""
+	SegmentOptInOptedIn    SegmentOptIn = "opted_in"     // Has an active consent record
+	SegmentOptInNotOptedIn SegmentOptIn = "not_opted_in" // Never opted in, or revoked
+)
+
 // ActionType represents custom action types
 type ActionType string
 
@@ -214,3 +586,11 @@ const (
 	ActionTypeURL        ActionType = "url"
 	ActionTypeJavascript ActionType = "javascript"
 )
+
+// SignaturePosition is where an agent's signature goes in their outbound messages
+type SignaturePosition string
+
+const (
+	SignaturePositionAppend  SignaturePosition = "append"  // On its own line after the text
+	SignaturePositionPrepend SignaturePosition = "prepend" // On its own line before the text
+)
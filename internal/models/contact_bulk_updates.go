@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ContactBulkUpdate is a change applied to many contacts by a worker job. The contacts
+// are chosen when the update is requested, so contacts that start matching the filter
+// later aren't changed.
+type ContactBulkUpdate struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	RequestedByID  uuid.UUID `gorm:"type:uuid;not null" json:"requested_by_id"`
+
+	// The changes; unset ones are left alone
+	AddTags         JSONBArray `gorm:"type:jsonb;default:'[]'" json:"add_tags"`
+	RemoveTags      JSONBArray `gorm:"type:jsonb;default:'[]'" json:"remove_tags"`
+	CustomFields    JSONB      `gorm:"type:jsonb;default:'{}'" json:"custom_fields"` // Merged into the contact's fields
+	Assign          bool       `gorm:"default:false" json:"assign"`
+	AssignUserID    *uuid.UUID `gorm:"type:uuid" json:"assign_user_id,omitempty"` // Unassigns when Assign is set and this is nil
+	WhatsAppAccount string     `gorm:"size:100" json:"whatsapp_account,omitempty"`
+
+	ContactIDs     JSONBArray              `gorm:"type:jsonb;default:'[]'" json:"-"` // The contacts to change, in order
+	Status         ContactBulkUpdateStatus `gorm:"size:20;not null;default:'queued';index" json:"status"`
+	Error          string                  `gorm:"type:text" json:"error,omitempty"`
+	TotalCount     int                     `gorm:"default:0" json:"total_count"`
+	ProcessedCount int                     `gorm:"default:0" json:"processed_count"` // Contacts handled so far; the job resumes from here
+	UpdatedCount   int                     `gorm:"default:0" json:"updated_count"`   // Contacts that actually changed
+	CompletedAt    *time.Time              `json:"completed_at,omitempty"`
+}
+
+func (ContactBulkUpdate) TableName() string {
+	return "contact_bulk_updates"
+}
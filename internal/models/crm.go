@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CRMConnection holds an organization's OAuth app for a CRM (HubSpot or Salesforce), the
+// tokens of the account that connected it, and how contacts are synced
+type CRMConnection struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;uniqueIndex:idx_crm_connection_org_provider;not null" json:"organization_id"`
+	Provider       string     `gorm:"size:20;uniqueIndex:idx_crm_connection_org_provider;not null" json:"provider"` // hubspot, salesforce
+	ClientID       string     `gorm:"size:500;not null" json:"client_id"`
+	ClientSecret   string     `gorm:"size:500;not null" json:"-"` // Never exposed in JSON
+	AccessToken    string     `gorm:"type:text" json:"-"`
+	RefreshToken   string     `gorm:"type:text" json:"-"`
+	TokenExpiry    *time.Time `json:"token_expiry,omitempty"`
+	InstanceURL    string     `gorm:"size:500" json:"instance_url,omitempty"` // Salesforce API host, returned with the token
+	ConnectedByID  *uuid.UUID `gorm:"type:uuid" json:"connected_by_id,omitempty"`
+	ConnectedAt    *time.Time `json:"connected_at,omitempty"`
+
+	// What is synced
+	PushContacts     bool  `gorm:"default:true" json:"push_contacts"`             // Create and update CRM contacts
+	PushSummaries    bool  `gorm:"default:true" json:"push_summaries"`            // Log conversation summaries as notes
+	FieldMappings    JSONB `gorm:"type:jsonb;default:'{}'" json:"field_mappings"` // CRM property -> contact custom field, pulled on each sync
+	SyncIntervalMins int   `gorm:"default:60" json:"sync_interval_mins"`          // 0 syncs only on demand
+
+	// Sync state
+	ContactsSyncedThrough *time.Time `json:"contacts_synced_through,omitempty"` // updated_at of the last contact pushed
+	LastSyncedAt          *time.Time `json:"last_synced_at,omitempty"`
+	SyncError             string     `gorm:"type:text" json:"sync_error,omitempty"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (CRMConnection) TableName() string {
+	return "crm_connections"
+}
+
+// CRMContactLink records the CRM record a contact was synced to
+type CRMContactLink struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ConnectionID    uuid.UUID  `gorm:"type:uuid;uniqueIndex:idx_crm_contact_link;not null" json:"connection_id"`
+	ContactID       uuid.UUID  `gorm:"type:uuid;uniqueIndex:idx_crm_contact_link;not null" json:"contact_id"`
+	RecordID        string     `gorm:"size:100;not null" json:"record_id"`
+	SummaryPushedAt *time.Time `json:"summary_pushed_at,omitempty"` // summarized_at of the last summary logged
+	PulledAt        *time.Time `json:"pulled_at,omitempty"`         // When CRM properties were last read
+
+	// Relations
+	Contact *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+func (CRMContactLink) TableName() string {
+	return "crm_contact_links"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationEncryptionKey is an organization's own key in an external KMS. The
+// organization's secrets and media are encrypted with a data key that is stored here only
+// wrapped by that key, so revoking the key in the KMS makes the data unreadable.
+type OrganizationEncryptionKey struct {
+	BaseModel
+	OrganizationID uuid.UUID             `gorm:"type:uuid;uniqueIndex;not null" json:"organization_id"`
+	Provider       EncryptionKeyProvider `gorm:"size:30;not null" json:"provider"`
+	Address        string                `gorm:"size:500;not null" json:"address"`       // KMS base URL
+	Mount          string                `gorm:"size:100;not null" json:"mount"`         // Transit secrets engine path
+	KeyName        string                `gorm:"size:255;not null" json:"key_name"`      // Key in the KMS
+	Token          string                `gorm:"type:text;not null" json:"-"`            // Credential for the KMS
+	WrappedKey     string                `gorm:"type:text;not null" json:"-"`            // Data key, encrypted by the KMS key
+	Status         EncryptionKeyStatus   `gorm:"size:20;default:'active'" json:"status"` // Result of the last check
+	LastError      string                `gorm:"type:text" json:"last_error,omitempty"`
+	LastCheckedAt  *time.Time            `json:"last_checked_at,omitempty"`
+	RotatedAt      *time.Time            `json:"rotated_at,omitempty"` // Last time the data key was rewrapped
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (OrganizationEncryptionKey) TableName() string {
+	return "organization_encryption_keys"
+}
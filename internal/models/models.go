@@ -91,6 +91,13 @@ type Organization struct {
 	Slug     string `gorm:"size:100;uniqueIndex;not null" json:"slug"`
 	Settings JSONB  `gorm:"type:jsonb;default:'{}'" json:"settings"`
 
+	// A suspended organization's users can't sign in or call the API
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason string     `gorm:"size:500" json:"suspended_reason,omitempty"`
+
+	// Storage region the organization's files are written to; empty uses the default storage
+	DataRegion string `gorm:"size:50" json:"data_region,omitempty"`
+
 	// Relations
 	Users            []User            `gorm:"foreignKey:OrganizationID" json:"users,omitempty"`
 	WhatsAppAccounts []WhatsAppAccount `gorm:"foreignKey:OrganizationID" json:"whatsapp_accounts,omitempty"`
@@ -113,6 +120,25 @@ type User struct {
 	IsAvailable    bool       `gorm:"default:true" json:"is_available"` // Agent availability status (away/available)
 	IsSuperAdmin   bool       `gorm:"default:false" json:"is_super_admin"`  // Super admin can access all organizations
 
+	// Outbound messages are held for supervisor review
+	RequiresApproval bool `gorm:"default:false" json:"requires_approval"`
+
+	// Replaces the team and account signature in the user's outbound messages
+	Signature string `gorm:"size:200" json:"signature"`
+
+	// Sent to contacts assigned to the user who write while the user is away; replaces
+	// the team's away message
+	AwayMessage string `gorm:"type:text" json:"away_message"`
+
+	// Automatic assignment: skills are matched against contact tags by the by_skill
+	// strategy, and the last assignment orders organization-wide round-robin
+	Skills         StringArray `gorm:"type:jsonb;default:'[]'" json:"skills"`
+	LastAssignedAt *time.Time  `json:"-"`
+
+	// Set by the shift processor at shift boundaries; nil when no shift applies to the
+	// user. Routing skips users who are off shift.
+	OnShift *bool `json:"on_shift,omitempty"`
+
 	// SSO fields
 	SSOProvider   string `gorm:"size:50" json:"sso_provider,omitempty"`     // google, microsoft, github, facebook, custom
 	SSOProviderID string `gorm:"size:255" json:"sso_provider_id,omitempty"` // External user ID from provider
@@ -126,6 +152,46 @@ func (User) TableName() string {
 	return "users"
 }
 
+// UserOrganization gives a user access to another organization with a role there. The
+// user's own OrganizationID and RoleID stay those of their home organization; which
+// organization a session works in is carried by its token.
+type UserOrganization struct {
+	BaseModel
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	RoleID         *uuid.UUID `gorm:"type:uuid" json:"role_id,omitempty"`
+
+	// Relations
+	User         *User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Role         *CustomRole   `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+}
+
+func (UserOrganization) TableName() string {
+	return "user_organizations"
+}
+
+// OrganizationInvite offers the account with Email a role in an organization. It becomes a
+// UserOrganization once the invitee accepts it. Invites are stored whether or not the email
+// has an account, so the inviting organization can't tell.
+type OrganizationInvite struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Email          string     `gorm:"size:255;not null;index" json:"email"`
+	RoleID         *uuid.UUID `gorm:"type:uuid" json:"role_id,omitempty"`
+	InvitedByID    *uuid.UUID `gorm:"type:uuid" json:"invited_by_id,omitempty"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Role         *CustomRole   `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+	InvitedBy    *User         `gorm:"foreignKey:InvitedByID" json:"invited_by,omitempty"`
+}
+
+func (OrganizationInvite) TableName() string {
+	return "organization_invites"
+}
+
 // UserAvailabilityLog tracks user availability changes for break time calculation
 type UserAvailabilityLog struct {
 	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
@@ -143,6 +209,51 @@ func (UserAvailabilityLog) TableName() string {
 	return "user_availability_logs"
 }
 
+// AuditLog records a security-relevant event, such as an access attempt blocked by the IP allowlist
+type AuditLog struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	UserID         *uuid.UUID  `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	ImpersonatorID *uuid.UUID  `gorm:"type:uuid;index" json:"impersonator_id,omitempty"` // Super admin acting as UserID
+	APIKeyID       *uuid.UUID  `gorm:"type:uuid" json:"api_key_id,omitempty"`
+	Action         AuditAction `gorm:"size:50;index;not null" json:"action"`
+	IPAddress      string      `gorm:"size:45" json:"ip_address"`
+	Path           string      `gorm:"size:255" json:"path"`
+	Details        string      `gorm:"type:text" json:"details"`
+	CreatedAt      time.Time   `gorm:"autoCreateTime;index" json:"created_at"`
+
+	// Relations
+	User         *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Impersonator *User `gorm:"foreignKey:ImpersonatorID" json:"impersonator,omitempty"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// ImpersonationSession lets a super admin act as an organization user for troubleshooting.
+// When the organization requires consent, the user must approve it before it can start.
+type ImpersonationSession struct {
+	BaseModel
+	OrganizationID uuid.UUID           `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ImpersonatorID uuid.UUID           `gorm:"type:uuid;index;not null" json:"impersonator_id"`
+	UserID         uuid.UUID           `gorm:"type:uuid;index;not null" json:"user_id"`
+	Reason         string              `gorm:"type:text;not null" json:"reason"`
+	Status         ImpersonationStatus `gorm:"size:20;index;not null" json:"status"`
+	RespondedAt    *time.Time          `json:"responded_at,omitempty"`
+	StartedAt      *time.Time          `json:"started_at,omitempty"`
+	ExpiresAt      *time.Time          `json:"expires_at,omitempty"`
+	EndedAt        *time.Time          `json:"ended_at,omitempty"`
+
+	// Relations
+	Impersonator *User `gorm:"foreignKey:ImpersonatorID" json:"impersonator,omitempty"`
+	User         *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (ImpersonationSession) TableName() string {
+	return "impersonation_sessions"
+}
+
 // Team represents a group of agents handling specific types of chats
 type Team struct {
 	BaseModel
@@ -151,6 +262,9 @@ type Team struct {
 	Description        string    `gorm:"size:500" json:"description"`
 	AssignmentStrategy AssignmentStrategy `gorm:"size:50;default:'round_robin'" json:"assignment_strategy"` // round_robin, load_balanced, manual
 	IsActive           bool      `gorm:"default:true" json:"is_active"`
+	RequiresApproval   bool      `gorm:"default:false" json:"requires_approval"` // Agents' outbound messages are held for review
+	Signature          string    `gorm:"size:200" json:"signature"` // Replaces the account signature for the team's agents
+	AwayMessage        string    `gorm:"type:text" json:"away_message"` // Sent for the team's agents while they are away
 
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -178,6 +292,23 @@ func (TeamMember) TableName() string {
 	return "team_members"
 }
 
+// AgentShift is a weekly working window for one agent, or for every member of a team.
+// Times are wall-clock in the organization's timezone; a shift that ends before it
+// starts runs past midnight.
+type AgentShift struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	UserID         *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	TeamID         *uuid.UUID `gorm:"type:uuid;index" json:"team_id,omitempty"`
+	DayOfWeek      int        `gorm:"not null" json:"day_of_week"`       // 0 = Sunday
+	StartTime      string     `gorm:"size:5;not null" json:"start_time"` // HH:MM
+	EndTime        string     `gorm:"size:5;not null" json:"end_time"`   // HH:MM
+}
+
+func (AgentShift) TableName() string {
+	return "agent_shifts"
+}
+
 // APIKey represents an API key for programmatic access
 type APIKey struct {
 	BaseModel
@@ -189,6 +320,8 @@ type APIKey struct {
 	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
 	ExpiresAt      *time.Time `json:"expires_at,omitempty"` // null = never expires
 	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	Scope          APIKeyScope `gorm:"size:20;default:'full'" json:"scope"`
+	DailyQuota     int        `gorm:"default:0" json:"daily_quota"` // Requests per UTC day; 0 = unlimited
 
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -199,6 +332,23 @@ func (APIKey) TableName() string {
 	return "api_keys"
 }
 
+// APIUsageDaily is one day of API requests by a user or API key to an endpoint, rolled
+// up from the Redis counters
+type APIUsageDaily struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null" json:"organization_id"`
+	Day            time.Time  `gorm:"type:date;not null" json:"day"`
+	APIKeyID       *uuid.UUID `gorm:"type:uuid;index" json:"api_key_id,omitempty"` // null for requests made with a login session
+	UserID         uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Endpoint       string     `gorm:"size:255;not null" json:"endpoint"` // Method and path with IDs replaced, e.g. "GET /api/contacts/{id}"
+	Requests       int64      `gorm:"default:0" json:"requests"`
+	Rejected       int64      `gorm:"default:0" json:"rejected"` // Requests refused over the API key's daily quota
+}
+
+func (APIUsageDaily) TableName() string {
+	return "api_usage_daily"
+}
+
 // SSOProvider represents an SSO/OAuth provider configuration for an organization
 type SSOProvider struct {
 	BaseModel
@@ -224,6 +374,77 @@ func (SSOProvider) TableName() string {
 	return "sso_providers"
 }
 
+// GoogleSheetsConnection holds an organization's Google OAuth app and the tokens of the
+// account that connected it, used to import campaign recipients from spreadsheets
+type GoogleSheetsConnection struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;uniqueIndex;not null" json:"organization_id"`
+	ClientID       string     `gorm:"size:500;not null" json:"client_id"`
+	ClientSecret   string     `gorm:"size:500;not null" json:"-"` // Never exposed in JSON
+	AccessToken    string     `gorm:"type:text" json:"-"`
+	RefreshToken   string     `gorm:"type:text" json:"-"`
+	TokenExpiry    *time.Time `json:"token_expiry,omitempty"`
+	AccountEmail   string     `gorm:"size:255" json:"account_email"` // Google account that granted access
+	ConnectedByID  *uuid.UUID `gorm:"type:uuid" json:"connected_by_id,omitempty"`
+	ConnectedAt    *time.Time `json:"connected_at,omitempty"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (GoogleSheetsConnection) TableName() string {
+	return "google_sheets_connections"
+}
+
+// CalendarConnection links a user's work calendar so busy events mark them away
+type CalendarConnection struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;uniqueIndex;not null" json:"user_id"` // One calendar per user
+	Provider       string     `gorm:"size:50;not null" json:"provider"`              // google, microsoft
+	AccountEmail   string     `gorm:"size:255" json:"account_email"`
+	AccessToken    string     `gorm:"type:text" json:"-"`
+	RefreshToken   string     `gorm:"type:text" json:"-"`
+	TokenExpiry    *time.Time `json:"token_expiry,omitempty"`
+
+	// Sync state
+	MarkedAway    bool       `gorm:"default:false" json:"marked_away"` // The user is away because of a calendar event
+	BusyUntil     *time.Time `json:"busy_until,omitempty"`             // End of the current busy stretch
+	IgnoreUntil   *time.Time `json:"ignore_until,omitempty"`           // User overrode availability during this busy stretch
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	SyncError     string     `gorm:"type:text" json:"sync_error,omitempty"`
+
+	// Relations
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (CalendarConnection) TableName() string {
+	return "calendar_connections"
+}
+
+// SMTPSettings is an organization's outgoing mail server, used for invitations,
+// password resets and alerts
+type SMTPSettings struct {
+	BaseModel
+	OrganizationID     uuid.UUID `gorm:"type:uuid;uniqueIndex;not null" json:"organization_id"`
+	Host               string    `gorm:"size:255;not null" json:"host"`
+	Port               int       `gorm:"not null;default:587" json:"port"`
+	Username           string    `gorm:"size:255" json:"username"`
+	Password           string    `gorm:"size:500" json:"-"` // Never exposed in JSON
+	FromEmail          string    `gorm:"size:255;not null" json:"from_email"`
+	FromName           string    `gorm:"size:255" json:"from_name"`
+	TLSMode            string    `gorm:"size:20;default:'starttls'" json:"tls_mode"` // none, starttls, tls
+	InsecureSkipVerify bool      `gorm:"default:false" json:"insecure_skip_verify"`
+	IsEnabled          bool      `gorm:"default:true" json:"is_enabled"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (SMTPSettings) TableName() string {
+	return "smtp_settings"
+}
+
 // Webhook represents an outbound webhook configuration for integrations
 type Webhook struct {
 	BaseModel
@@ -243,6 +464,195 @@ func (Webhook) TableName() string {
 	return "webhooks"
 }
 
+// NotificationChannel posts selected events to a Slack or Microsoft Teams channel
+type NotificationChannel struct {
+	BaseModel
+	OrganizationID uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string      `gorm:"size:255;not null" json:"name"`
+	Provider       string      `gorm:"size:20;not null" json:"provider"` // slack, teams
+	WebhookURL     string      `gorm:"type:text;not null" json:"-"`      // Incoming webhook URL, treated as a secret
+	Events         StringArray `gorm:"type:jsonb;default:'[]'" json:"events"`
+	IsActive       bool        `gorm:"default:true" json:"is_active"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (NotificationChannel) TableName() string {
+	return "notification_channels"
+}
+
+// EmailGateway turns inbound emails from allowlisted senders into template sends, so
+// systems that can only email can still notify contacts on WhatsApp
+type EmailGateway struct {
+	BaseModel
+	OrganizationID   uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name             string      `gorm:"size:255;not null" json:"name"`
+	Token            string      `gorm:"size:64;uniqueIndex;not null" json:"-"`          // Secret part of the inbound URL
+	AllowedSenders   StringArray `gorm:"type:jsonb;default:'[]'" json:"allowed_senders"` // Addresses or @domain
+	SubjectPattern   string      `gorm:"type:text" json:"subject_pattern"`               // Regex; emails that don't match are ignored
+	TemplateID       uuid.UUID   `gorm:"type:uuid;not null" json:"template_id"`
+	WhatsAppAccount  string      `gorm:"size:100" json:"whatsapp_account"`           // Defaults to the template's account
+	ParamRules       JSONB       `gorm:"type:jsonb;default:'{}'" json:"param_rules"` // {param: {source, pattern}}
+	Recipients       StringArray `gorm:"type:jsonb;default:'[]'" json:"recipients"`  // Phone numbers notified for every email
+	RecipientPattern string      `gorm:"type:text" json:"recipient_pattern"`         // Regex finding more numbers in the body
+	IsActive         bool        `gorm:"default:true" json:"is_active"`
+	LastReceivedAt   *time.Time  `json:"last_received_at,omitempty"`
+	LastResult       string      `gorm:"type:text" json:"last_result"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Template     *Template     `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
+}
+
+func (EmailGateway) TableName() string {
+	return "email_gateways"
+}
+
+// EnrichmentProvider looks up details for new contacts from an external API
+type EnrichmentProvider struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string    `gorm:"size:255;not null" json:"name"`
+	Provider       string    `gorm:"size:20;not null" json:"provider"` // clearbit, custom
+	URL            string    `gorm:"type:text" json:"url"`             // Defaults to the provider's public API
+	APIKey         string    `gorm:"type:text" json:"-"`
+	Priority       int       `gorm:"default:0" json:"priority"` // Lower runs first; earlier results win
+	IsActive       bool      `gorm:"default:true" json:"is_active"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (EnrichmentProvider) TableName() string {
+	return "enrichment_providers"
+}
+
+// Announcement is a banner or changelog entry pushed to users. A nil OrganizationID
+// targets every organization and can only be managed by super admins.
+type Announcement struct {
+	BaseModel
+	OrganizationID *uuid.UUID           `gorm:"type:uuid;index" json:"organization_id,omitempty"`
+	Title          string               `gorm:"size:255;not null" json:"title"`
+	Body           string               `gorm:"type:text" json:"body"`
+	Type           AnnouncementType     `gorm:"size:20;not null;default:'changelog'" json:"type"`
+	Category       AnnouncementCategory `gorm:"size:20;not null;default:'info'" json:"category"`
+	StartsAt       *time.Time           `json:"starts_at,omitempty"`                 // Hidden until then; nil means immediately
+	EndsAt         *time.Time           `json:"ends_at,omitempty"`                   // Banners stop showing after this
+	PublishedAt    *time.Time           `gorm:"index" json:"published_at,omitempty"` // Set once pushed to connected users
+	CreatedByID    *uuid.UUID           `gorm:"type:uuid" json:"created_by_id,omitempty"`
+}
+
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// AnnouncementRead records that a user has read or dismissed an announcement
+type AnnouncementRead struct {
+	AnnouncementID uuid.UUID `gorm:"type:uuid;primaryKey" json:"announcement_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"user_id"`
+	ReadAt         time.Time `gorm:"not null" json:"read_at"`
+}
+
+func (AnnouncementRead) TableName() string {
+	return "announcement_reads"
+}
+
+// FeatureFlag is the deployment-wide configuration of a feature flag. Flags without a
+// row fall back to their built-in default.
+type FeatureFlag struct {
+	BaseModel
+	Key            string `gorm:"size:64;uniqueIndex;not null" json:"key"`
+	Description    string `gorm:"size:255" json:"description"`
+	Enabled        bool   `gorm:"default:false" json:"enabled"`
+	RolloutPercent int    `gorm:"default:100" json:"rollout_percent"` // Share of organizations (0-100) that get it while enabled
+}
+
+func (FeatureFlag) TableName() string {
+	return "feature_flags"
+}
+
+// FeatureFlagOverride forces a flag on or off for a single organization
+type FeatureFlagOverride struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_feature_flag_override;not null" json:"organization_id"`
+	FlagKey        string    `gorm:"size:64;uniqueIndex:idx_feature_flag_override;not null" json:"flag_key"`
+	Enabled        bool      `json:"enabled"`
+}
+
+func (FeatureFlagOverride) TableName() string {
+	return "feature_flag_overrides"
+}
+
+// OrganizationPlugin enables a server plugin for an organization, with its settings
+type OrganizationPlugin struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_org_plugin;not null" json:"organization_id"`
+	PluginName     string    `gorm:"size:100;uniqueIndex:idx_org_plugin;not null" json:"plugin_name"`
+	Enabled        bool      `gorm:"default:false" json:"enabled"`
+	Config         JSONB     `gorm:"type:jsonb;default:'{}'" json:"config"`
+}
+
+func (OrganizationPlugin) TableName() string {
+	return "organization_plugins"
+}
+
+// ScriptHook is a pipeline point a script can attach to
+type ScriptHook string
+
+const (
+	ScriptHookContactCreated ScriptHook = "contact_created" // Enrich a new contact
+	ScriptHookPreSend        ScriptHook = "pre_send"        // Rewrite or reject an outgoing message
+	ScriptHookRouting        ScriptHook = "routing"         // Pick a team or agent for a queued transfer
+)
+
+// PipelineScript is an admin-written script run at a pipeline point
+type PipelineScript struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string     `gorm:"size:100;not null" json:"name"`
+	Description    string     `gorm:"type:text" json:"description"`
+	Hook           ScriptHook `gorm:"size:30;index;not null" json:"hook"`
+	Source         string     `gorm:"type:text;not null" json:"source"`
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	Priority       int        `gorm:"default:0" json:"priority"` // Lower runs first
+	TimeoutMs      int        `gorm:"default:100" json:"timeout_ms"`
+	MaxSteps       int        `gorm:"default:10000" json:"max_steps"`
+	CreatedByID    *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
+}
+
+func (PipelineScript) TableName() string {
+	return "pipeline_scripts"
+}
+
+// ScriptExecutionStatus is the outcome of a script run
+type ScriptExecutionStatus string
+
+const (
+	ScriptExecutionSuccess  ScriptExecutionStatus = "success"
+	ScriptExecutionRejected ScriptExecutionStatus = "rejected"
+	ScriptExecutionError    ScriptExecutionStatus = "error"
+	ScriptExecutionTimeout  ScriptExecutionStatus = "timeout" // Step or time limit exceeded
+)
+
+// ScriptExecution logs a single pipeline script run
+type ScriptExecution struct {
+	ID             uuid.UUID             `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID             `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ScriptID       uuid.UUID             `gorm:"type:uuid;index:idx_script_executions_script;not null" json:"script_id"`
+	Hook           ScriptHook            `gorm:"size:30" json:"hook"`
+	Status         ScriptExecutionStatus `gorm:"size:20;index" json:"status"`
+	DurationUs     int64                 `json:"duration_us"`
+	Steps          int                   `json:"steps"`
+	Logs           StringArray           `gorm:"type:jsonb;default:'[]'" json:"logs"`
+	Error          string                `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt      time.Time             `gorm:"autoCreateTime;index:idx_script_executions_script" json:"created_at"`
+}
+
+func (ScriptExecution) TableName() string {
+	return "script_executions"
+}
+
 // CustomAction represents a custom action button for chat integrations
 type CustomAction struct {
 	BaseModel
@@ -276,8 +686,30 @@ type WhatsAppAccount struct {
 	IsDefaultIncoming  bool      `gorm:"default:false" json:"is_default_incoming"`
 	IsDefaultOutgoing  bool      `gorm:"default:false" json:"is_default_outgoing"`
 	AutoReadReceipt    bool      `gorm:"default:false" json:"auto_read_receipt"`
+	MessagesPerSecond  int       `gorm:"default:0" json:"messages_per_second"` // Send rate limit; 0 uses the server default
 	Status             string    `gorm:"size:20;default:'active'" json:"status"`
 
+	// Health alerts fire when, within an hour, at least HealthAlertMinEvents webhook
+	// events were processed and HealthAlertFailureRate percent of them failed
+	HealthAlertFailureRate int `gorm:"default:20" json:"health_alert_failure_rate"` // 0 turns alerts off
+	HealthAlertMinEvents   int `gorm:"default:20" json:"health_alert_min_events"`
+
+	// Template sync. Scheduled syncs only fetch templates changed since the last one, and
+	// an interrupted sync resumes from its saved paging cursor.
+	TemplateSyncInterval  int        `gorm:"default:60" json:"template_sync_interval"` // Minutes between scheduled syncs; 0 turns them off
+	TemplatesSyncedAt     *time.Time `json:"templates_synced_at,omitempty"`            // Start of the last completed sync
+	TemplateSyncError     string     `gorm:"type:text" json:"template_sync_error,omitempty"`
+	TemplateSyncRetryAt   *time.Time `json:"template_sync_retry_at,omitempty"` // No scheduled sync runs before this, e.g. while rate limited
+	TemplateSyncCursor    string     `gorm:"type:text" json:"-"`               // Next page of an interrupted sync
+	TemplateSyncStartedAt *time.Time `json:"-"`                                // Start of the interrupted sync
+	TemplateSyncFull      bool       `gorm:"default:false" json:"-"`           // The interrupted sync lists every template
+
+	// Agent signatures. Signature is a template such as "– {name}, {team}" added to the
+	// text of messages agents send from this account; teams and users can override it.
+	SenderName        string            `gorm:"size:100" json:"sender_name"` // Name the business signs as, e.g. "Acme Support"
+	Signature         string            `gorm:"size:200" json:"signature"`   // Empty turns signatures off for the account
+	SignaturePosition SignaturePosition `gorm:"size:10;default:'append'" json:"signature_position"`
+
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 }
@@ -304,6 +736,30 @@ type Contact struct {
 	ChatbotLastMessageAt *time.Time `json:"chatbot_last_message_at,omitempty"` // When chatbot last sent a message
 	ChatbotReminderSent  bool       `gorm:"default:false" json:"chatbot_reminder_sent"`
 
+	// Engagement tracking (recomputed periodically by ContactScoreProcessor)
+	EngagementScore int            `gorm:"default:0;index" json:"engagement_score"` // 0-100
+	LifecycleStage  LifecycleStage `gorm:"size:20;default:'lead';index" json:"lifecycle_stage"`
+	ScoreUpdatedAt  *time.Time     `json:"score_updated_at,omitempty"`
+
+	// The stage an active contact is at: customer once the contact has been made a customer.
+	// A dormant contact returns to it when they write again.
+	PeakLifecycleStage LifecycleStage `gorm:"size:20" json:"peak_lifecycle_stage,omitempty"`
+	// When a user last set the stage; the scoring job keeps that stage until the contact writes again
+	LifecycleStageSetAt *time.Time `json:"lifecycle_stage_set_at,omitempty"`
+
+	// Profile photo from an enrichment provider, cached in media storage
+	AvatarPath      string     `gorm:"type:text" json:"-"` // Relative to the media storage root
+	AvatarCheckedAt *time.Time `json:"avatar_checked_at,omitempty"`
+
+	// Opted-out contacts are skipped by campaigns; see ContactOptOut for the history
+	OptedOut   bool       `gorm:"default:false;index" json:"opted_out"`
+	OptedOutAt *time.Time `json:"opted_out_at,omitempty"`
+
+	// Priority of the current conversation; it goes back to normal when the agent transfer ends
+	Priority          ConversationPriority `gorm:"size:10;default:'normal'" json:"priority"`
+	PrioritySource    PrioritySource       `gorm:"size:20" json:"priority_source,omitempty"`
+	PriorityUpdatedAt *time.Time           `json:"priority_updated_at,omitempty"`
+
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 	AssignedUser *User         `gorm:"foreignKey:AssignedUserID" json:"assigned_user,omitempty"`
@@ -314,6 +770,77 @@ func (Contact) TableName() string {
 	return "contacts"
 }
 
+// ContactConsent is proof that a contact opted in to marketing messages. Records are
+// never deleted; a withdrawn opt-in is marked revoked so the history stays auditable.
+type ContactConsent struct {
+	BaseModel
+	OrganizationID uuid.UUID     `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID      uuid.UUID     `gorm:"type:uuid;index;not null" json:"contact_id"`
+	PhoneNumber    string        `gorm:"size:20;not null" json:"phone_number"` // Kept so the record outlives the contact
+	Source         ConsentSource `gorm:"size:20;not null" json:"source"`
+	Method         ConsentMethod `gorm:"size:20;not null" json:"method"`
+	CampaignID     *uuid.UUID    `gorm:"type:uuid;index" json:"campaign_id,omitempty"` // Campaign that collected the opt-in
+	ConsentedAt    time.Time     `gorm:"not null" json:"consented_at"`
+	Evidence       string        `gorm:"type:text" json:"evidence"` // Form URL, consent wording, IP address or similar proof
+	RecordedBy     *uuid.UUID    `gorm:"type:uuid" json:"recorded_by,omitempty"`
+	RevokedAt      *time.Time    `json:"revoked_at,omitempty"`
+	RevokedBy      *uuid.UUID    `gorm:"type:uuid" json:"revoked_by,omitempty"`
+	RevokeReason   string        `gorm:"size:255" json:"revoke_reason,omitempty"`
+}
+
+func (ContactConsent) TableName() string {
+	return "contact_consents"
+}
+
+// IsActive reports whether the opt-in still stands
+func (c *ContactConsent) IsActive() bool {
+	return c.RevokedAt == nil
+}
+
+// ContactOptOut records a contact opting out of or back in to campaign messages. The
+// contact's OptedOut flag holds the current state; these records are the audit trail.
+type ContactOptOut struct {
+	BaseModel
+	OrganizationID uuid.UUID    `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID      uuid.UUID    `gorm:"type:uuid;index;not null" json:"contact_id"`
+	PhoneNumber    string       `gorm:"size:20;not null" json:"phone_number"`
+	Action         OptOutAction `gorm:"size:20;not null" json:"action"`
+	Source         OptOutSource `gorm:"size:20;not null" json:"source"`
+	Keyword        string       `gorm:"size:50" json:"keyword,omitempty"` // Keyword the contact replied with
+	Reason         string       `gorm:"size:255" json:"reason,omitempty"`
+	ChangedBy      *uuid.UUID   `gorm:"type:uuid" json:"changed_by,omitempty"`
+}
+
+func (ContactOptOut) TableName() string {
+	return "contact_opt_outs"
+}
+
+// ContactImport is a CSV or XLSX file of contacts imported in the background. Rows are
+// matched to existing contacts by phone number; progress is recorded after every batch.
+type ContactImport struct {
+	BaseModel
+	OrganizationID uuid.UUID           `gorm:"type:uuid;index;not null" json:"organization_id"`
+	RequestedByID  uuid.UUID           `gorm:"type:uuid;not null" json:"requested_by_id"`
+	Filename       string              `gorm:"size:255" json:"filename"`
+	Format         string              `gorm:"size:10;not null" json:"format"` // csv or xlsx
+	FilePath       string              `gorm:"type:text" json:"-"`             // Relative to the media storage root; removed once processed
+	ColumnMapping  JSONB               `gorm:"type:jsonb;default:'{}'" json:"column_mapping"`
+	Tags           JSONBArray          `gorm:"type:jsonb;default:'[]'" json:"tags"` // Added to every imported contact
+	Status         ContactImportStatus `gorm:"size:20;not null;default:'processing'" json:"status"`
+	Error          string              `gorm:"type:text" json:"error,omitempty"`
+	ProcessedRows  int                 `gorm:"default:0" json:"processed_rows"`
+	CreatedCount   int                 `gorm:"default:0" json:"created_count"`
+	UpdatedCount   int                 `gorm:"default:0" json:"updated_count"`
+	DuplicateCount int                 `gorm:"default:0" json:"duplicate_count"` // Rows whose number came earlier in the file or was created meanwhile
+	RejectedCount  int                 `gorm:"default:0" json:"rejected_count"`
+	Rejected       JSONBArray          `gorm:"type:jsonb;default:'[]'" json:"rejected"` // The first rejected rows, for review
+	CompletedAt    *time.Time          `json:"completed_at,omitempty"`
+}
+
+func (ContactImport) TableName() string {
+	return "contact_imports"
+}
+
 // Message represents a WhatsApp message
 type Message struct {
 	BaseModel
@@ -328,12 +855,14 @@ type Message struct {
 	MediaURL          string     `gorm:"type:text" json:"media_url"`
 	MediaMimeType     string     `gorm:"size:100" json:"media_mime_type"`
 	MediaFilename     string     `gorm:"size:255" json:"media_filename"`
+	MediaID           string     `gorm:"size:100" json:"media_id,omitempty"` // Meta media ID, used to re-download incoming media
 	TemplateName      string     `gorm:"size:255" json:"template_name"`
 	TemplateParams    JSONB      `gorm:"type:jsonb" json:"template_params"`
 	InteractiveData   JSONB      `gorm:"type:jsonb" json:"interactive_data"`
 	FlowResponse      JSONB      `gorm:"type:jsonb" json:"flow_response"`
 	Status            MessageStatus `gorm:"size:20;default:'pending'" json:"status"`
 	ErrorMessage      string     `gorm:"type:text" json:"error_message"`
+	ErrorCode         int        `gorm:"default:0" json:"error_code,omitempty"` // Meta error code of a failed message
 	IsReply           bool       `gorm:"default:false" json:"is_reply"`
 	ReplyToMessageID  *uuid.UUID `gorm:"type:uuid" json:"reply_to_message_id,omitempty"`
 	SentByUserID      *uuid.UUID `gorm:"type:uuid;index" json:"sent_by_user_id,omitempty"` // User who sent outgoing message
@@ -342,7 +871,7 @@ type Message struct {
 	// Relations
 	Organization   *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 	Contact        *Contact      `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
-	ReplyToMessage *Message      `gorm:"foreignKey:ReplyToMessageID" json:"reply_to_message,omitempty"`
+	ReplyToMessage *Message      `gorm:"foreignKey:ReplyToMessageID;-:migration" json:"reply_to_message,omitempty"` // No FK: messages may be partitioned
 	SentByUser     *User         `gorm:"foreignKey:SentByUserID" json:"sent_by_user,omitempty"`
 }
 
@@ -350,6 +879,167 @@ func (Message) TableName() string {
 	return "messages"
 }
 
+// ArchivedMessage is a message moved out of the messages table by the archive job once
+// it is older than the organization's archive_after_days setting
+type ArchivedMessage struct {
+	Message
+	ArchivedAt time.Time `gorm:"not null" json:"archived_at"`
+}
+
+func (ArchivedMessage) TableName() string {
+	return "archived_messages"
+}
+
+// MessageStatusEvent records one delivery status transition of an outgoing message,
+// timestamped with the time WhatsApp reported it
+type MessageStatusEvent struct {
+	ID             uuid.UUID     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID     `gorm:"type:uuid;index;not null" json:"organization_id"`
+	MessageID      uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_message_status_event" json:"message_id"`
+	Status         MessageStatus `gorm:"size:20;not null;uniqueIndex:idx_message_status_event" json:"status"`
+	ErrorMessage   string        `gorm:"type:text" json:"error_message,omitempty"`
+	OccurredAt     time.Time     `gorm:"not null;index" json:"occurred_at"`
+	CreatedAt      time.Time     `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (MessageStatusEvent) TableName() string {
+	return "message_status_events"
+}
+
+// OutboxMessage holds what is needed to send an outgoing message until WhatsApp accepts
+// it. Failed sends are retried with backoff and the entry is removed once one succeeds.
+type OutboxMessage struct {
+	BaseModel
+	OrganizationID uuid.UUID    `gorm:"type:uuid;index;not null" json:"organization_id"`
+	MessageID      uuid.UUID    `gorm:"type:uuid;uniqueIndex;not null" json:"message_id"`
+	Payload        JSONB        `gorm:"type:jsonb;default:'{}'" json:"-"`
+	Status         OutboxStatus `gorm:"size:20;index;not null" json:"status"`
+	Attempts       int          `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time    `gorm:"index;not null" json:"next_attempt_at"`
+	LockedUntil    *time.Time   `json:"-"` // A crashed sender's claim expires here
+	LastError      string       `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
+
+// TransactionalSend is a message sent through the /api/v1/send transactional API. It
+// reserves the caller's idempotency key and holds where to post delivery callbacks.
+type TransactionalSend struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_transactional_send_key" json:"organization_id"`
+	IdempotencyKey *string    `gorm:"size:255;uniqueIndex:idx_transactional_send_key" json:"idempotency_key,omitempty"`
+	APIKeyID       *uuid.UUID `gorm:"type:uuid" json:"api_key_id,omitempty"`
+	MessageID      *uuid.UUID `gorm:"type:uuid;index" json:"message_id,omitempty"` // Set once the message is created
+	CallbackURL    string     `gorm:"type:text" json:"callback_url,omitempty"`
+	CallbackSecret string     `gorm:"size:255" json:"-"` // For HMAC signature of callbacks
+}
+
+func (TransactionalSend) TableName() string {
+	return "transactional_sends"
+}
+
+// Verification is a one-time code sent to a phone number to verify it, e.g. for login
+type Verification struct {
+	BaseModel
+	OrganizationID  uuid.UUID          `gorm:"type:uuid;not null;index:idx_verification_phone" json:"organization_id"`
+	PhoneNumber     string             `gorm:"size:20;not null;index:idx_verification_phone" json:"phone_number"`
+	WhatsAppAccount string             `gorm:"size:100;not null" json:"whatsapp_account"`
+	TemplateID      uuid.UUID          `gorm:"type:uuid;not null" json:"template_id"`
+	MessageID       *uuid.UUID         `gorm:"type:uuid" json:"message_id,omitempty"`
+	CodeHash        string             `gorm:"size:255;not null" json:"-"` // bcrypt hash of the code
+	Status          VerificationStatus `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	Attempts        int                `gorm:"default:0" json:"attempts"`
+	MaxAttempts     int                `gorm:"default:5" json:"max_attempts"`
+	ExpiresAt       time.Time          `gorm:"not null" json:"expires_at"`
+	VerifiedAt      *time.Time         `json:"verified_at,omitempty"`
+}
+
+func (Verification) TableName() string {
+	return "verifications"
+}
+
+// AnalyticsExport is a report file generated in the background for a date range and
+// downloaded through a time-limited link
+type AnalyticsExport struct {
+	BaseModel
+	OrganizationID uuid.UUID             `gorm:"type:uuid;index;not null" json:"organization_id"`
+	RequestedByID  uuid.UUID             `gorm:"type:uuid;index;not null" json:"requested_by_id"`
+	Report         string                `gorm:"size:50;not null" json:"report"` // messages, agents, campaigns
+	Format         string                `gorm:"size:10;not null" json:"format"` // csv, xlsx
+	FromDate       string                `gorm:"size:10;not null" json:"from"`   // YYYY-MM-DD in the organization's timezone
+	ToDate         string                `gorm:"size:10;not null" json:"to"`
+	NotifyEmail    bool                  `gorm:"default:false" json:"notify_email"`
+	Status         AnalyticsExportStatus `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	Error          string                `gorm:"type:text" json:"error,omitempty"`
+	FilePath       string                `gorm:"type:text" json:"-"` // Relative to the media storage root
+	FileSize       int64                 `gorm:"default:0" json:"file_size"`
+	RowCount       int                   `gorm:"default:0" json:"row_count"`
+	DownloadToken  string                `gorm:"size:64;uniqueIndex" json:"-"`
+	DownloadURL    string                `gorm:"type:text" json:"-"` // Absolute link sent in notifications
+	StartedAt      *time.Time            `json:"started_at,omitempty"`
+	CompletedAt    *time.Time            `json:"completed_at,omitempty"`
+	ExpiresAt      *time.Time            `json:"expires_at,omitempty"` // Set when the file is ready
+}
+
+func (AnalyticsExport) TableName() string {
+	return "analytics_exports"
+}
+
+// PublicDashboard is a read-only set of analytics widgets published at a token URL, for
+// wall screens that show live stats without signing in
+type PublicDashboard struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string     `gorm:"size:255;not null" json:"name"`
+	Widgets        JSONBArray `gorm:"type:jsonb;default:'[]'" json:"widgets"` // overview, campaigns, transfer_queue
+	RefreshSeconds int        `gorm:"default:60" json:"refresh_seconds"`
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	Token          string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	CreatedByID    uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
+	LastViewedAt   *time.Time `json:"last_viewed_at,omitempty"`
+}
+
+func (PublicDashboard) TableName() string {
+	return "public_dashboards"
+}
+
+// MessageApproval is an agent's outbound message held for review by a manager
+// before it is sent to the contact
+type MessageApproval struct {
+	BaseModel
+	OrganizationID   uuid.UUID      `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID        uuid.UUID      `gorm:"type:uuid;index;not null" json:"contact_id"`
+	WhatsAppAccount  string         `gorm:"size:100" json:"whatsapp_account"`
+	RequestedByID    uuid.UUID      `gorm:"type:uuid;index;not null" json:"requested_by_id"`
+	MessageType      MessageType    `gorm:"size:20;not null" json:"message_type"`
+	Content          string         `gorm:"type:text" json:"content"`
+	OriginalContent  string         `gorm:"type:text" json:"original_content,omitempty"` // Set when a reviewer edits the message
+	InteractiveData  JSONB          `gorm:"type:jsonb" json:"interactive_data,omitempty"`
+	MediaURL         string         `gorm:"type:text" json:"media_url,omitempty"` // Local media path for held media messages
+	MediaMimeType    string         `gorm:"size:100" json:"media_mime_type,omitempty"`
+	MediaFilename    string         `gorm:"size:255" json:"media_filename,omitempty"`
+	TemplateID       *uuid.UUID     `gorm:"type:uuid" json:"template_id,omitempty"`    // Template of held template messages
+	TemplateData     JSONB          `gorm:"type:jsonb" json:"template_data,omitempty"` // Params and flow data the template is sent with
+	ReplyToMessageID *uuid.UUID     `gorm:"type:uuid" json:"reply_to_message_id,omitempty"`
+	Reason           string         `gorm:"type:text" json:"reason"` // Why the message needs approval
+	Status           ApprovalStatus `gorm:"size:20;default:'pending';index" json:"status"`
+	ReviewedByID     *uuid.UUID     `gorm:"type:uuid" json:"reviewed_by_id,omitempty"`
+	ReviewedAt       *time.Time     `json:"reviewed_at,omitempty"`
+	ReviewNote       string         `gorm:"type:text" json:"review_note"`
+	MessageID        *uuid.UUID     `gorm:"type:uuid" json:"message_id,omitempty"` // Message sent once approved
+
+	// Relations
+	Contact     *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+	RequestedBy *User    `gorm:"foreignKey:RequestedByID" json:"requested_by,omitempty"`
+	ReviewedBy  *User    `gorm:"foreignKey:ReviewedByID" json:"reviewed_by,omitempty"`
+}
+
+func (MessageApproval) TableName() string {
+	return "message_approvals"
+}
+
 // Template represents a WhatsApp message template
 type Template struct {
 	BaseModel
@@ -367,6 +1057,9 @@ type Template struct {
 	FooterContent   string     `gorm:"type:text" json:"footer_content"`
 	Buttons         JSONBArray  `gorm:"type:jsonb;default:'[]'" json:"buttons"`
 	SampleValues    JSONBArray  `gorm:"type:jsonb;default:'[]'" json:"sample_values"`
+	UsageCount      int64       `gorm:"default:0" json:"usage_count"`          // Successful sends, campaigns and direct
+	LastUsedAt      *time.Time  `json:"last_used_at,omitempty"`
+	ArchivedAt      *time.Time  `gorm:"index" json:"archived_at,omitempty"` // Hidden from the library and can't be sent
 
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -19,12 +19,13 @@ func (Permission) TableName() string {
 // CustomRole represents a role with specific permissions
 type CustomRole struct {
 	BaseModel
-	OrganizationID uuid.UUID    `gorm:"type:uuid;index;not null" json:"organization_id"`
-	Name           string       `gorm:"size:100;not null" json:"name"`
-	Description    string       `gorm:"size:500" json:"description"`
-	IsSystem       bool         `gorm:"default:false" json:"is_system"` // true for default admin/manager/agent
-	IsDefault      bool         `gorm:"default:false" json:"is_default"` // default role for new users in org
-	Permissions    []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
+	OrganizationID     uuid.UUID    `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name               string       `gorm:"size:100;not null" json:"name"`
+	Description        string       `gorm:"size:500" json:"description"`
+	IsSystem           bool         `gorm:"default:false" json:"is_system"`            // true for default admin/manager/agent/analyst
+	IsDefault          bool         `gorm:"default:false" json:"is_default"`           // default role for new users in org
+	RestrictDataAccess bool         `gorm:"default:false" json:"restrict_data_access"` // Masks contact details and hides message content
+	Permissions        []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
 
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -243,9 +244,24 @@ func SystemRolePermissions() map[string][]string {
 		"canned_responses:read",
 	}
 
+	// Analysts only read. The role restricts data access, so contact details are masked
+	// and message content is hidden.
+	analystPermissions := []string{
+		// Teams (read only)
+		"teams:read",
+		// Templates and campaigns (read only)
+		"templates:read",
+		"campaigns:read",
+		// Contacts (masked)
+		"contacts:read", "contacts:export",
+		// Analytics
+		"analytics:read", "analytics.agents:read",
+	}
+
 	return map[string][]string{
 		"admin":   allPermissions,
 		"manager": managerPermissions,
 		"agent":   agentPermissions,
+		"analyst": analystPermissions,
 	}
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Segment is a saved set of contact filters. Its contacts are looked up whenever the
+// segment is used, so it picks up contacts that start matching after it was saved.
+type Segment struct {
+	BaseModel
+	OrganizationID uuid.UUID      `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string         `gorm:"size:100;not null" json:"name"`
+	Description    string         `gorm:"type:text" json:"description"`
+	Filters        SegmentFilters `gorm:"type:jsonb;default:'{}'" json:"filters"`
+	CreatedByID    *uuid.UUID     `gorm:"type:uuid" json:"created_by_id,omitempty"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (Segment) TableName() string {
+	return "segments"
+}
+
+// SegmentFilters selects the contacts of a segment. Contacts must match every filter
+// that is set; an empty filter set matches all contacts.
+type SegmentFilters struct {
+	Tags                  []string             `json:"tags,omitempty"`         // Contacts with all of these tags
+	ExcludeTags           []string             `json:"exclude_tags,omitempty"` // Contacts with none of these tags
+	CustomFields          []SegmentFieldFilter `json:"custom_fields,omitempty"`
+	LastMessageWithinDays int                  `json:"last_message_within_days,omitempty"` // Messaged in the last N days
+	NoMessageForDays      int                  `json:"no_message_for_days,omitempty"`      // Not messaged in the last N days, or never
+	WhatsAppAccount       string               `json:"whatsapp_account,omitempty"`
+	OptIn                 SegmentOptIn         `json:"opt_in,omitempty"`
+}
+
+// SegmentFieldFilter compares one contact custom field
+type SegmentFieldFilter struct {
+	Field    string               `json:"field"`
+	Operator SegmentFieldOperator `json:"operator"`
+	Value    string               `json:"value,omitempty"` // Unused by exists and not_exists
+}
+
+func (f SegmentFilters) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+func (f *SegmentFilters) Scan(value interface{}) error {
+	if value == nil {
+		*f = SegmentFilters{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, f)
+}
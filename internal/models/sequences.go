@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sequence is a drip campaign: templates sent to each enrolled contact one step at a
+// time, each a set delay after the previous one, until the contact has received them all
+// or meets one of the sequence's exit conditions. Contacts who opt out always leave.
+type Sequence struct {
+	BaseModel
+	OrganizationID    uuid.UUID      `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount   string         `gorm:"size:100;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
+	Name              string         `gorm:"size:255;not null" json:"name"`
+	Status            SequenceStatus `gorm:"size:20;default:'draft'" json:"status"`
+	ExitOnReply       bool           `gorm:"default:true" json:"exit_on_reply"`         // Any message from the contact ends it
+	ExitOnButtonClick bool           `gorm:"default:false" json:"exit_on_button_click"` // A button tap ends it
+	CreatedBy         uuid.UUID      `gorm:"type:uuid;not null" json:"created_by"`
+
+	// Relations
+	Organization *Organization  `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Steps        []SequenceStep `gorm:"foreignKey:SequenceID" json:"steps,omitempty"`
+}
+
+func (Sequence) TableName() string {
+	return "sequences"
+}
+
+// SequenceStep is one template of a sequence
+type SequenceStep struct {
+	BaseModel
+	SequenceID   uuid.UUID `gorm:"type:uuid;index;not null" json:"sequence_id"`
+	Position     int       `gorm:"not null" json:"position"` // Send order, from 0
+	TemplateID   uuid.UUID `gorm:"type:uuid;not null" json:"template_id"`
+	DelayMinutes int       `gorm:"default:0" json:"delay_minutes"` // Wait after the previous step, or after enrolling for the first
+
+	// Template placeholder -> text filled from the contact, such as "{{contact.name}}"
+	TemplateParamMapping JSONB `gorm:"type:jsonb;default:'{}'" json:"template_param_mapping"`
+
+	// Relations
+	Template *Template `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
+}
+
+func (SequenceStep) TableName() string {
+	return "sequence_steps"
+}
+
+// SequenceEnrollment tracks one contact's progress through a sequence
+type SequenceEnrollment struct {
+	BaseModel
+	OrganizationID uuid.UUID                `gorm:"type:uuid;index;not null" json:"organization_id"`
+	SequenceID     uuid.UUID                `gorm:"type:uuid;not null;uniqueIndex:idx_sequence_enrollments_contact" json:"sequence_id"`
+	ContactID      uuid.UUID                `gorm:"type:uuid;not null;uniqueIndex:idx_sequence_enrollments_contact" json:"contact_id"`
+	Status         SequenceEnrollmentStatus `gorm:"size:20;default:'active'" json:"status"`
+	NextStep       int                      `gorm:"default:0" json:"next_step"`          // Position of the next step to send
+	NextStepAt     *time.Time               `gorm:"index" json:"next_step_at,omitempty"` // When the next step is due
+	LastSentAt     *time.Time               `json:"last_sent_at,omitempty"`
+	ExitReason     SequenceExitReason       `gorm:"size:30" json:"exit_reason,omitempty"`
+	Error          string                   `gorm:"type:text" json:"error,omitempty"`
+	EnrolledAt     time.Time                `json:"enrolled_at"`
+	FinishedAt     *time.Time               `json:"finished_at,omitempty"` // Completed, exited or failed
+
+	// Relations
+	Contact  *Contact  `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+	Sequence *Sequence `gorm:"foreignKey:SequenceID" json:"sequence,omitempty"`
+}
+
+func (SequenceEnrollment) TableName() string {
+	return "sequence_enrollments"
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ButtonClick records a contact tapping a button on an interactive or template message
+type ButtonClick struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string     `gorm:"size:100;index;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
+	ContactID       uuid.UUID  `gorm:"type:uuid;index;not null" json:"contact_id"`
+	MessageID       *uuid.UUID `gorm:"type:uuid;index" json:"message_id,omitempty"` // Originating outgoing message
+	ReplyMessageID  *uuid.UUID `gorm:"type:uuid" json:"reply_message_id,omitempty"` // Incoming message carrying the click
+	CampaignID      *uuid.UUID `gorm:"type:uuid;index" json:"campaign_id,omitempty"`
+	TemplateName    string     `gorm:"size:255;index" json:"template_name"`
+	ButtonType      ButtonType `gorm:"size:20;not null" json:"button_type"`
+	ButtonID        string     `gorm:"size:255" json:"button_id"` // Button/list row ID or template button payload
+	ButtonText      string     `gorm:"size:255" json:"button_text"`
+	ClickedAt       time.Time  `gorm:"not null;index" json:"clicked_at"`
+
+	// Relations
+	Contact *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+	Message *Message `gorm:"foreignKey:MessageID;-:migration" json:"message,omitempty"` // No FK: messages may be partitioned
+}
+
+func (ButtonClick) TableName() string {
+	return "button_clicks"
+}
+
+// ShortLink is a tracked redirect generated for an outbound URL
+type ShortLink struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Code           string     `gorm:"size:20;uniqueIndex;not null" json:"code"`
+	TargetURL      string     `gorm:"type:text;not null" json:"target_url"`
+	CampaignID     *uuid.UUID `gorm:"type:uuid;index" json:"campaign_id,omitempty"`
+	RecipientID    *uuid.UUID `gorm:"type:uuid;index" json:"recipient_id,omitempty"`
+	ContactID      *uuid.UUID `gorm:"type:uuid;index" json:"contact_id,omitempty"`
+	MessageID      *uuid.UUID `gorm:"type:uuid;index" json:"message_id,omitempty"` // Message whose CTA URL button the link stands in for
+	ButtonText     string     `gorm:"size:255" json:"button_text,omitempty"`
+	ClickCount     int        `gorm:"default:0" json:"click_count"`
+	LastClickedAt  *time.Time `json:"last_clicked_at,omitempty"`
+	CreatedBy      *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+
+	// Relations
+	Campaign  *BulkMessageCampaign  `gorm:"foreignKey:CampaignID" json:"campaign,omitempty"`
+	Recipient *BulkMessageRecipient `gorm:"foreignKey:RecipientID" json:"recipient,omitempty"`
+	Contact   *Contact              `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+func (ShortLink) TableName() string {
+	return "short_links"
+}
+
+// LinkClick records a single visit to a short link
+type LinkClick struct {
+	BaseModel
+	ShortLinkID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"short_link_id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	CampaignID     *uuid.UUID `gorm:"type:uuid;index" json:"campaign_id,omitempty"`
+	RecipientID    *uuid.UUID `gorm:"type:uuid" json:"recipient_id,omitempty"`
+	UserAgent      string     `gorm:"type:text" json:"user_agent"`
+	IPAddress      string     `gorm:"size:45" json:"ip_address"`
+	ClickedAt      time.Time  `gorm:"not null;index" json:"clicked_at"`
+
+	// Relations
+	ShortLink *ShortLink `gorm:"foreignKey:ShortLinkID" json:"short_link,omitempty"`
+}
+
+func (LinkClick) TableName() string {
+	return "link_clicks"
+}
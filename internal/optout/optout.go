@@ -0,0 +1,105 @@
+// Package optout tracks contacts who asked to stop receiving campaign messages. A reply
+// made up of just an opt-out keyword such as STOP marks the contact opted out, and
+// campaigns skip opted-out numbers.
+package optout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrOptedOut is returned when a campaign message is sent to a number that opted out
+var ErrOptedOut = errors.New("contact has opted out")
+
+// SettingKey is the organization setting holding the opt-out keywords
+const SettingKey = "opt_out_keywords"
+
+const (
+	// MaxKeywords caps how many opt-out keywords an organization can configure
+	MaxKeywords = 20
+	// maxKeywordLength caps the length of a single keyword
+	maxKeywordLength = 30
+)
+
+// DefaultKeywords are used when the organization hasn't configured its own
+var DefaultKeywords = []string{"STOP", "STOP ALL", "STOP PROMOTIONS", "UNSUBSCRIBE", "OPT OUT", "OPTOUT", "CANCEL", "END", "QUIT"}
+
+// KeywordsFromSettings returns the organization's opt-out keywords, or DefaultKeywords when
+// none are configured. An empty list turns keyword opt-outs off.
+func KeywordsFromSettings(settings map[string]interface{}) []string {
+	raw, ok := settings[SettingKey].([]interface{})
+	if !ok {
+		return DefaultKeywords
+	}
+	keywords := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			keywords = append(keywords, s)
+		}
+	}
+	return keywords
+}
+
+// NormalizeKeywords upper-cases, trims and de-duplicates keywords, dropping empty ones
+func NormalizeKeywords(keywords []string) ([]string, error) {
+	seen := make(map[string]bool, len(keywords))
+	result := make([]string, 0, len(keywords))
+	for _, k := range keywords {
+		k = normalize(k)
+		if k == "" || seen[k] {
+			continue
+		}
+		if len(k) > maxKeywordLength {
+			return nil, fmt.Errorf("keyword %q is longer than %d characters", k, maxKeywordLength)
+		}
+		seen[k] = true
+		result = append(result, k)
+	}
+	if len(result) > MaxKeywords {
+		return nil, fmt.Errorf("at most %d keywords are allowed", MaxKeywords)
+	}
+	return result, nil
+}
+
+// Match returns the keyword the message consists of, ignoring case, extra spaces and
+// trailing punctuation. Messages that merely contain a keyword don't match.
+func Match(text string, keywords []string) (string, bool) {
+	text = normalize(strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	}))
+	if text == "" {
+		return "", false
+	}
+	for _, k := range keywords {
+		if normalize(k) == text {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// Check returns ErrOptedOut if a contact with the number has opted out of the organization's campaigns
+func Check(db *gorm.DB, orgID uuid.UUID, phoneNumber string) error {
+	var count int64
+	if err := db.Model(&models.Contact{}).
+		Where("organization_id = ? AND phone_number = ? AND opted_out = ?", orgID, phoneNumber, true).
+		Limit(1).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrOptedOut
+	}
+	return nil
+}
+
+// normalize upper-cases s and collapses runs of whitespace into single spaces
+func normalize(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,55 @@
+package optout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordsFromSettings(t *testing.T) {
+	assert.Equal(t, DefaultKeywords, KeywordsFromSettings(nil))
+	assert.Equal(t, DefaultKeywords, KeywordsFromSettings(map[string]interface{}{SettingKey: "STOP"}))
+	assert.Empty(t, KeywordsFromSettings(map[string]interface{}{SettingKey: []interface{}{}}))
+	assert.Equal(t, []string{"BASTA"}, KeywordsFromSettings(map[string]interface{}{SettingKey: []interface{}{"BASTA", 1}}))
+}
+
+func TestNormalizeKeywords(t *testing.T) {
+	keywords, err := NormalizeKeywords([]string{" stop ", "STOP", "", "opt   out"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"STOP", "OPT OUT"}, keywords)
+
+	_, err = NormalizeKeywords([]string{"please remove me from every single list"})
+	assert.Error(t, err)
+
+	tooMany := make([]string, MaxKeywords+1)
+	for i := range tooMany {
+		tooMany[i] = string(rune('A' + i))
+	}
+	_, err = NormalizeKeywords(tooMany)
+	assert.Error(t, err)
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		text    string
+		keyword string
+		ok      bool
+	}{
+		{"STOP", "STOP", true},
+		{"  stop!  ", "STOP", true},
+		{"Opt  out.", "OPT OUT", true},
+		{"Stop promotions", "STOP PROMOTIONS", true},
+		{"please stop", "", false},
+		{"stopping by later", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		keyword, ok := Match(tt.text, DefaultKeywords)
+		assert.Equal(t, tt.ok, ok, tt.text)
+		assert.Equal(t, tt.keyword, keyword, tt.text)
+	}
+
+	_, ok := Match("STOP", nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,202 @@
+// Package phone normalizes and validates international phone numbers and applies
+// per-organization destination country restrictions.
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// minDigits is the shortest international number in use (country code plus subscriber number)
+	minDigits = 7
+	// maxDigits is the E.164 maximum length
+	maxDigits = 15
+)
+
+var (
+	// ErrInvalidNumber is returned for numbers that can't be a valid international number
+	ErrInvalidNumber = errors.New("invalid phone number")
+	// ErrCountryRestricted is returned when a destination country is not allowed for the organization
+	ErrCountryRestricted = errors.New("destination country is not allowed")
+)
+
+// callingCodes is the set of country calling codes assigned by the ITU
+var callingCodes = map[string]bool{}
+
+// callingCodesByLength lists calling codes longest first for prefix matching
+var callingCodesByLength []string
+
+func init() {
+	codes := []string{
+		"1", "7",
+		"20", "27", "30", "31", "32", "33", "34", "36", "39",
+		"40", "41", "43", "44", "45", "46", "47", "48", "49",
+		"51", "52", "53", "54", "55", "56", "57", "58",
+		"60", "61", "62", "63", "64", "65", "66",
+		"81", "82", "84", "86", "90", "91", "92", "93", "94", "95", "98",
+		"211", "212", "213", "216", "218",
+		"220", "221", "222", "223", "224", "225", "226", "227", "228", "229",
+		"230", "231", "232", "233", "234", "235", "236", "237", "238", "239",
+		"240", "241", "242", "243", "244", "245", "246", "247", "248", "249",
+		"250", "251", "252", "253", "254", "255", "256", "257", "258",
+		"260", "261", "262", "263", "264", "265", "266", "267", "268", "269",
+		"290", "291", "297", "298", "299",
+		"350", "351", "352", "353", "354", "355", "356", "357", "358", "359",
+		"370", "371", "372", "373", "374", "375", "376", "377", "378", "380",
+		"381", "382", "383", "385", "386", "387", "389",
+		"420", "421", "423",
+		"500", "501", "502", "503", "504", "505", "506", "507", "508", "509",
+		"590", "591", "592", "593", "594", "595", "596", "597", "598", "599",
+		"670", "672", "673", "674", "675", "676", "677", "678", "679", "680",
+		"681", "682", "683", "685", "686", "687", "688", "689", "690", "691", "692",
+		"850", "852", "853", "855", "856", "880", "886",
+		"960", "961", "962", "963", "964", "965", "966", "967", "968",
+		"970", "971", "972", "973", "974", "975", "976", "977",
+		"992", "993", "994", "995", "996", "998",
+	}
+	for _, code := range codes {
+		callingCodes[code] = true
+	}
+	callingCodesByLength = codes
+	sort.SliceStable(callingCodesByLength, func(i, j int) bool {
+		return len(callingCodesByLength[i]) > len(callingCodesByLength[j])
+	})
+}
+
+// Normalize converts a phone number in international format to the digits-only form
+// stored on contacts (E.164 without the leading +). Spaces, dashes, dots, brackets and
+// a "(0)" trunk prefix are removed and a leading 00 international prefix is accepted.
+func Normalize(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	s = strings.ReplaceAll(s, "(0)", "")
+
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", fmt.Errorf("%w: unexpected character %q", ErrInvalidNumber, r)
+		}
+	}
+
+	digits := b.String()
+	if strings.HasPrefix(digits, "00") {
+		digits = digits[2:]
+	}
+	if digits == "" {
+		return "", fmt.Errorf("%w: number is empty", ErrInvalidNumber)
+	}
+	if digits[0] == '0' {
+		return "", fmt.Errorf("%w: number must start with a country code", ErrInvalidNumber)
+	}
+	if len(digits) < minDigits || len(digits) > maxDigits {
+		return "", fmt.Errorf("%w: must be between %d and %d digits", ErrInvalidNumber, minDigits, maxDigits)
+	}
+	if CallingCode(digits) == "" {
+		return "", fmt.Errorf("%w: unknown country code", ErrInvalidNumber)
+	}
+	return digits, nil
+}
+
+// CallingCode returns the country calling code of a normalized number, or "" if none matches
+func CallingCode(number string) string {
+	number = strings.TrimPrefix(number, "+")
+	for _, code := range callingCodesByLength {
+		if strings.HasPrefix(number, code) {
+			return code
+		}
+	}
+	return ""
+}
+
+// IsCallingCode reports whether code is an assigned country calling code
+func IsCallingCode(code string) bool {
+	return callingCodes[strings.TrimPrefix(code, "+")]
+}
+
+// Restrictions are an organization's destination country rules, as calling codes.
+// If Allowed is non-empty only those countries can be messaged; Blocked always wins.
+type Restrictions struct {
+	Allowed []string `json:"allowed_countries"`
+	Blocked []string `json:"blocked_countries"`
+}
+
+// RestrictionsFromSettings reads the destination country lists from organization settings
+func RestrictionsFromSettings(settings map[string]interface{}) Restrictions {
+	return Restrictions{
+		Allowed: stringList(settings["allowed_countries"]),
+		Blocked: stringList(settings["blocked_countries"]),
+	}
+}
+
+// IsEmpty reports whether no restrictions are configured
+func (r Restrictions) IsEmpty() bool {
+	return len(r.Allowed) == 0 && len(r.Blocked) == 0
+}
+
+// Check returns ErrCountryRestricted if the number's country may not be messaged
+func (r Restrictions) Check(number string) error {
+	if r.IsEmpty() {
+		return nil
+	}
+
+	code := CallingCode(number)
+	for _, blocked := range r.Blocked {
+		if code == blocked {
+			return fmt.Errorf("%w: +%s is blocked", ErrCountryRestricted, code)
+		}
+	}
+	if len(r.Allowed) == 0 {
+		return nil
+	}
+	for _, allowed := range r.Allowed {
+		if code == allowed {
+			return nil
+		}
+	}
+	if code == "" {
+		return fmt.Errorf("%w: unknown country code", ErrCountryRestricted)
+	}
+	return fmt.Errorf("%w: +%s is not in the allowed list", ErrCountryRestricted, code)
+}
+
+// NormalizeCallingCodes strips "+" from a list of calling codes, validates and de-duplicates them
+func NormalizeCallingCodes(codes []string) ([]string, error) {
+	seen := make(map[string]bool, len(codes))
+	result := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = strings.TrimPrefix(strings.TrimSpace(code), "+")
+		if !IsCallingCode(code) {
+			return nil, fmt.Errorf("unknown country calling code %q", code)
+		}
+		if !seen[code] {
+			seen[code] = true
+			result = append(result, code)
+		}
+	}
+	return result, nil
+}
+
+// stringList converts a JSON array value to a string slice
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"919876543210", "919876543210"},
+		{"+91 98765 43210", "919876543210"},
+		{"+1 (415) 555-0123", "14155550123"},
+		{"0044 20 7946 0958", "442079460958"},
+		{"+44 (0) 20 7946 0958", "442079460958"},
+		{"971.50.123.4567", "971501234567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNormalize_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"09876543210",      // national format without country code
+		"+91 98765x43210",  // stray letter
+		"12345",            // too short
+		"1234567890123456", // longer than E.164
+		"+28 123 456 789",  // unassigned country code
+		"91+9876543210",    // + only allowed at the start
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Normalize(input)
+			assert.True(t, errors.Is(err, ErrInvalidNumber), "expected ErrInvalidNumber, got %v", err)
+		})
+	}
+}
+
+func TestCallingCode(t *testing.T) {
+	assert.Equal(t, "1", CallingCode("14155550123"))
+	assert.Equal(t, "91", CallingCode("+919876543210"))
+	assert.Equal(t, "971", CallingCode("971501234567"))
+	assert.Equal(t, "", CallingCode("28123456789"))
+}
+
+func TestTimezone(t *testing.T) {
+	assert.Equal(t, "Asia/Kolkata", Timezone("919876543210"))
+	assert.Equal(t, "Asia/Dubai", Timezone("971501234567"))
+	assert.Equal(t, "", Timezone("14155550123"), "+1 spans several timezones")
+	assert.Equal(t, "", Timezone("28123456789"))
+
+	for code, name := range countryTimezones {
+		assert.True(t, IsCallingCode(code), "unknown calling code %s", code)
+		_, err := time.LoadLocation(name)
+		assert.NoError(t, err, "calling code %s", code)
+	}
+}
+
+func TestRestrictions_Check(t *testing.T) {
+	none := Restrictions{}
+	assert.NoError(t, none.Check("919876543210"))
+
+	allowOnlyIndia := Restrictions{Allowed: []string{"91"}}
+	assert.NoError(t, allowOnlyIndia.Check("919876543210"))
+	assert.ErrorIs(t, allowOnlyIndia.Check("14155550123"), ErrCountryRestricted)
+
+	blockUS := Restrictions{Blocked: []string{"1"}}
+	assert.ErrorIs(t, blockUS.Check("14155550123"), ErrCountryRestricted)
+	assert.NoError(t, blockUS.Check("919876543210"))
+
+	// Blocked wins over allowed
+	both := Restrictions{Allowed: []string{"91"}, Blocked: []string{"91"}}
+	assert.ErrorIs(t, both.Check("919876543210"), ErrCountryRestricted)
+}
+
+func TestRestrictionsFromSettings(t *testing.T) {
+	r := RestrictionsFromSettings(map[string]interface{}{
+		"allowed_countries": []interface{}{"91", "44"},
+		"blocked_countries": []interface{}{"1"},
+	})
+	assert.Equal(t, []string{"91", "44"}, r.Allowed)
+	assert.Equal(t, []string{"1"}, r.Blocked)
+
+	assert.True(t, RestrictionsFromSettings(nil).IsEmpty())
+}
+
+func TestNormalizeCallingCodes(t *testing.T) {
+	codes, err := NormalizeCallingCodes([]string{"+91", "44", "91"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"91", "44"}, codes)
+
+	_, err = NormalizeCallingCodes([]string{"28"})
+	assert.Error(t, err)
+}
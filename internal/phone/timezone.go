@@ -0,0 +1,79 @@
+package phone
+
+// countryTimezones maps calling codes of countries that use a single timezone to it.
+// Countries spanning several timezones (e.g. +1, +7, +52, +55, +61, +62) are left out
+// because the number alone doesn't say which one applies.
+var countryTimezones = map[string]string{
+	"20":  "Africa/Cairo",
+	"27":  "Africa/Johannesburg",
+	"30":  "Europe/Athens",
+	"31":  "Europe/Amsterdam",
+	"32":  "Europe/Brussels",
+	"33":  "Europe/Paris",
+	"34":  "Europe/Madrid",
+	"36":  "Europe/Budapest",
+	"39":  "Europe/Rome",
+	"40":  "Europe/Bucharest",
+	"41":  "Europe/Zurich",
+	"43":  "Europe/Vienna",
+	"44":  "Europe/London",
+	"45":  "Europe/Copenhagen",
+	"46":  "Europe/Stockholm",
+	"47":  "Europe/Oslo",
+	"48":  "Europe/Warsaw",
+	"49":  "Europe/Berlin",
+	"51":  "America/Lima",
+	"53":  "America/Havana",
+	"54":  "America/Argentina/Buenos_Aires",
+	"56":  "America/Santiago",
+	"57":  "America/Bogota",
+	"58":  "America/Caracas",
+	"60":  "Asia/Kuala_Lumpur",
+	"63":  "Asia/Manila",
+	"64":  "Pacific/Auckland",
+	"65":  "Asia/Singapore",
+	"66":  "Asia/Bangkok",
+	"81":  "Asia/Tokyo",
+	"82":  "Asia/Seoul",
+	"84":  "Asia/Ho_Chi_Minh",
+	"86":  "Asia/Shanghai",
+	"90":  "Europe/Istanbul",
+	"91":  "Asia/Kolkata",
+	"92":  "Asia/Karachi",
+	"93":  "Asia/Kabul",
+	"94":  "Asia/Colombo",
+	"95":  "Asia/Yangon",
+	"98":  "Asia/Tehran",
+	"212": "Africa/Casablanca",
+	"213": "Africa/Algiers",
+	"216": "Africa/Tunis",
+	"233": "Africa/Accra",
+	"234": "Africa/Lagos",
+	"254": "Africa/Nairobi",
+	"255": "Africa/Dar_es_Salaam",
+	"256": "Africa/Kampala",
+	"351": "Europe/Lisbon",
+	"353": "Europe/Dublin",
+	"358": "Europe/Helsinki",
+	"380": "Europe/Kiev",
+	"420": "Europe/Prague",
+	"852": "Asia/Hong_Kong",
+	"880": "Asia/Dhaka",
+	"886": "Asia/Taipei",
+	"961": "Asia/Beirut",
+	"962": "Asia/Amman",
+	"965": "Asia/Kuwait",
+	"966": "Asia/Riyadh",
+	"968": "Asia/Muscat",
+	"971": "Asia/Dubai",
+	"972": "Asia/Jerusalem",
+	"973": "Asia/Bahrain",
+	"974": "Asia/Qatar",
+	"977": "Asia/Kathmandu",
+}
+
+// Timezone returns the IANA timezone of a normalized number's country, or "" when the
+// country is unknown or spans several timezones
+func Timezone(number string) string {
+	return countryTimezones[CallingCode(number)]
+}
@@ -0,0 +1,74 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	goplugin "plugin"
+	"sort"
+)
+
+// LoadDir opens every .so file in dir and registers the plugin it exports. A plugin file
+// must be built with `go build -buildmode=plugin` against the same Go toolchain and module
+// versions as the server, and export either
+//
+//	func New() plugins.Plugin
+//
+// or a package-level variable named Plugin that implements plugins.Plugin.
+// A missing directory is not an error. Returns the names of the loaded plugins.
+func (m *Manager) LoadDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".so" {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	var loaded []string
+	for _, path := range files {
+		p, err := open(path)
+		if err != nil {
+			return loaded, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		if err := m.Register(p); err != nil {
+			return loaded, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		loaded = append(loaded, p.Info().Name)
+	}
+	return loaded, nil
+}
+
+// open loads a single plugin file
+func open(path string) (Plugin, error) {
+	so, err := goplugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if sym, err := so.Lookup("New"); err == nil {
+		newFn, ok := sym.(func() Plugin)
+		if !ok {
+			return nil, fmt.Errorf("New has type %T, want func() plugins.Plugin", sym)
+		}
+		return newFn(), nil
+	}
+
+	sym, err := so.Lookup("Plugin")
+	if err != nil {
+		return nil, fmt.Errorf("plugin exports neither New nor Plugin")
+	}
+	p, ok := sym.(Plugin)
+	if !ok {
+		return nil, fmt.Errorf("Plugin (%T) does not implement plugins.Plugin", sym)
+	}
+	return p, nil
+}
@@ -0,0 +1,183 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a single hook call
+const DefaultTimeout = 5 * time.Second
+
+// ErrorHandler receives hook failures that don't stop processing
+type ErrorHandler func(plugin, hook string, err error)
+
+// Manager holds the registered plugins and runs their hooks
+type Manager struct {
+	mu      sync.RWMutex
+	plugins []Plugin
+	byName  map[string]Plugin
+	timeout time.Duration
+	onError ErrorHandler
+}
+
+// NewManager creates an empty manager. A zero timeout uses DefaultTimeout.
+func NewManager(timeout time.Duration, onError ErrorHandler) *Manager {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if onError == nil {
+		onError = func(string, string, error) {}
+	}
+	return &Manager{
+		byName:  make(map[string]Plugin),
+		timeout: timeout,
+		onError: onError,
+	}
+}
+
+// Register adds a plugin. Names must be unique.
+func (m *Manager) Register(p Plugin) error {
+	name := p.Info().Name
+	if name == "" {
+		return errors.New("plugin name is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.byName[name]; exists {
+		return fmt.Errorf("plugin %q is already registered", name)
+	}
+	m.byName[name] = p
+	m.plugins = append(m.plugins, p)
+	return nil
+}
+
+// Get returns the plugin registered under name
+func (m *Manager) Get(name string) (Plugin, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.byName[name]
+	return p, ok
+}
+
+// List returns the registered plugins in registration order
+func (m *Manager) List() []Plugin {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Plugin(nil), m.plugins...)
+}
+
+// Len returns the number of registered plugins
+func (m *Manager) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.plugins)
+}
+
+// BeforeSend runs the pre-send hooks of the enabled plugins in registration order.
+// It returns an ErrRejected error if a plugin rejects the message; other failures
+// are reported to the error handler and the remaining plugins still run.
+func (m *Manager) BeforeSend(ctx context.Context, enabled map[string]Config, msg *OutgoingMessage) error {
+	for _, p := range m.enabled(enabled) {
+		hook, ok := p.(PreSendHook)
+		if !ok {
+			continue
+		}
+		name := p.Info().Name
+		// Work on a copy so a hook that times out can't change the message afterwards
+		draft := *msg
+		err := m.call(ctx, name, HookPreSend, func(ctx context.Context) error {
+			return hook.BeforeSend(ctx, enabled[name], &draft)
+		})
+		if errors.Is(err, ErrRejected) {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err == nil {
+			*msg = draft
+		}
+	}
+	return nil
+}
+
+// MessageReceived runs the message-received hooks of the enabled plugins
+func (m *Manager) MessageReceived(ctx context.Context, enabled map[string]Config, ev MessageEvent) {
+	for _, p := range m.enabled(enabled) {
+		if hook, ok := p.(MessageReceivedHook); ok {
+			name := p.Info().Name
+			_ = m.call(ctx, name, HookMessageReceived, func(ctx context.Context) error {
+				return hook.OnMessageReceived(ctx, enabled[name], ev)
+			})
+		}
+	}
+}
+
+// FlowStepExecuted runs the flow-step hooks of the enabled plugins
+func (m *Manager) FlowStepExecuted(ctx context.Context, enabled map[string]Config, ev FlowStepEvent) {
+	for _, p := range m.enabled(enabled) {
+		if hook, ok := p.(FlowStepHook); ok {
+			name := p.Info().Name
+			_ = m.call(ctx, name, HookFlowStepExecuted, func(ctx context.Context) error {
+				return hook.OnFlowStepExecuted(ctx, enabled[name], ev)
+			})
+		}
+	}
+}
+
+// ContactCreated runs the contact-created hooks of the enabled plugins
+func (m *Manager) ContactCreated(ctx context.Context, enabled map[string]Config, ev ContactEvent) {
+	for _, p := range m.enabled(enabled) {
+		if hook, ok := p.(ContactCreatedHook); ok {
+			name := p.Info().Name
+			_ = m.call(ctx, name, HookContactCreated, func(ctx context.Context) error {
+				return hook.OnContactCreated(ctx, enabled[name], ev)
+			})
+		}
+	}
+}
+
+// enabled returns the registered plugins that are switched on, in registration order
+func (m *Manager) enabled(enabled map[string]Config) []Plugin {
+	if len(enabled) == 0 {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []Plugin
+	for _, p := range m.plugins {
+		if _, ok := enabled[p.Info().Name]; ok {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// call runs one hook with a timeout, turning panics into errors so a faulty plugin
+// can't take down message processing. Errors other than rejections are reported.
+func (m *Manager) call(ctx context.Context, name, hook string, fn func(context.Context) error) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("panic: %v", rec)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", m.timeout)
+	}
+
+	if err != nil && !errors.Is(err, ErrRejected) {
+		m.onError(name, hook, err)
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+// Package plugins lets custom business logic hook into message and flow processing
+// without forking the handlers. Plugins are registered in-process or loaded from Go
+// plugin (.so) files, and each organization chooses which plugins run and with what config.
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Hook names
+const (
+	HookMessageReceived  = "message_received"
+	HookPreSend          = "pre_send"
+	HookFlowStepExecuted = "flow_step_executed"
+	HookContactCreated   = "contact_created"
+)
+
+// ErrRejected is returned when a pre-send hook rejects an outgoing message
+var ErrRejected = errors.New("message rejected by plugin")
+
+// Reject returns an error that stops an outgoing message from being sent. Any other
+// error returned from BeforeSend is logged and the message is sent anyway.
+func Reject(reason string) error {
+	return fmt.Errorf("%w: %s", ErrRejected, reason)
+}
+
+// Config is an organization's settings for a plugin
+type Config map[string]interface{}
+
+// Info describes a plugin
+type Info struct {
+	Name        string `json:"name"` // Unique, used as the key for per-organization config
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// Plugin is implemented by every plugin. A plugin subscribes to hooks by also
+// implementing any of MessageReceivedHook, PreSendHook, FlowStepHook and ContactCreatedHook.
+type Plugin interface {
+	Info() Info
+}
+
+// MessageReceivedHook is called after an incoming message has been saved
+type MessageReceivedHook interface {
+	OnMessageReceived(ctx context.Context, cfg Config, ev MessageEvent) error
+}
+
+// PreSendHook is called before an outgoing message is sent. It may rewrite
+// msg.Content or stop the send by returning Reject.
+type PreSendHook interface {
+	BeforeSend(ctx context.Context, cfg Config, msg *OutgoingMessage) error
+}
+
+// FlowStepHook is called after a chatbot flow step has processed the user's response
+type FlowStepHook interface {
+	OnFlowStepExecuted(ctx context.Context, cfg Config, ev FlowStepEvent) error
+}
+
+// ContactCreatedHook is called when a contact is created
+type ContactCreatedHook interface {
+	OnContactCreated(ctx context.Context, cfg Config, ev ContactEvent) error
+}
+
+// MessageEvent describes an incoming message
+type MessageEvent struct {
+	OrganizationID  uuid.UUID
+	MessageID       uuid.UUID
+	ContactID       uuid.UUID
+	ContactPhone    string
+	ContactName     string
+	WhatsAppAccount string
+	Type            string
+	Content         string
+}
+
+// OutgoingMessage is a message about to be sent
+type OutgoingMessage struct {
+	OrganizationID  uuid.UUID
+	ContactID       uuid.UUID
+	ContactPhone    string
+	WhatsAppAccount string
+	Type            string
+	// Content is the text, caption or interactive body; plugins may rewrite it
+	Content      string
+	SentByUserID *uuid.UUID
+}
+
+// FlowStepEvent describes a processed chatbot flow step
+type FlowStepEvent struct {
+	OrganizationID uuid.UUID
+	FlowID         uuid.UUID
+	FlowName       string
+	SessionID      uuid.UUID
+	ContactID      uuid.UUID
+	ContactPhone   string
+	StepName       string
+	Input          string
+	NextStep       string // Empty when the flow is completing
+	SessionData    map[string]interface{}
+}
+
+// ContactEvent describes a new contact
+type ContactEvent struct {
+	OrganizationID  uuid.UUID
+	ContactID       uuid.UUID
+	PhoneNumber     string
+	ProfileName     string
+	WhatsAppAccount string
+}
+
+// Hooks lists the hooks a plugin implements
+func Hooks(p Plugin) []string {
+	var hooks []string
+	if _, ok := p.(MessageReceivedHook); ok {
+		hooks = append(hooks, HookMessageReceived)
+	}
+	if _, ok := p.(PreSendHook); ok {
+		hooks = append(hooks, HookPreSend)
+	}
+	if _, ok := p.(FlowStepHook); ok {
+		hooks = append(hooks, HookFlowStepExecuted)
+	}
+	if _, ok := p.(ContactCreatedHook); ok {
+		hooks = append(hooks, HookContactCreated)
+	}
+	return hooks
+}
@@ -0,0 +1,151 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPlugin struct {
+	name     string
+	beforeFn func(cfg Config, msg *OutgoingMessage) error
+	mu       sync.Mutex
+	contacts []ContactEvent
+}
+
+func (p *testPlugin) Info() Info { return Info{Name: p.name, Version: "1.0.0"} }
+
+func (p *testPlugin) BeforeSend(_ context.Context, cfg Config, msg *OutgoingMessage) error {
+	if p.beforeFn == nil {
+		return nil
+	}
+	return p.beforeFn(cfg, msg)
+}
+
+func (p *testPlugin) OnContactCreated(_ context.Context, _ Config, ev ContactEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.contacts = append(p.contacts, ev)
+	return nil
+}
+
+type infoOnly struct{}
+
+func (infoOnly) Info() Info { return Info{Name: "info_only"} }
+
+func TestManager_Register(t *testing.T) {
+	m := NewManager(0, nil)
+
+	require.NoError(t, m.Register(&testPlugin{name: "signature"}))
+	assert.Error(t, m.Register(&testPlugin{name: "signature"}))
+	assert.Error(t, m.Register(&testPlugin{}))
+
+	p, ok := m.Get("signature")
+	require.True(t, ok)
+	assert.Equal(t, []string{HookPreSend, HookContactCreated}, Hooks(p))
+	assert.Empty(t, Hooks(infoOnly{}))
+	assert.Equal(t, 1, m.Len())
+}
+
+func TestManager_BeforeSend_RewritesInOrder(t *testing.T) {
+	m := NewManager(0, nil)
+	require.NoError(t, m.Register(&testPlugin{name: "upper", beforeFn: func(_ Config, msg *OutgoingMessage) error {
+		msg.Content = strings.ToUpper(msg.Content)
+		return nil
+	}}))
+	require.NoError(t, m.Register(&testPlugin{name: "signature", beforeFn: func(cfg Config, msg *OutgoingMessage) error {
+		msg.Content += "\n" + cfg["text"].(string)
+		return nil
+	}}))
+
+	msg := &OutgoingMessage{Content: "hello"}
+	err := m.BeforeSend(context.Background(), map[string]Config{
+		"upper":     {},
+		"signature": {"text": "- Acme"},
+	}, msg)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n- Acme", msg.Content)
+
+	// Only enabled plugins run
+	msg = &OutgoingMessage{Content: "hello"}
+	require.NoError(t, m.BeforeSend(context.Background(), map[string]Config{"signature": {"text": "x"}}, msg))
+	assert.Equal(t, "hello\nx", msg.Content)
+
+	msg = &OutgoingMessage{Content: "hello"}
+	require.NoError(t, m.BeforeSend(context.Background(), nil, msg))
+	assert.Equal(t, "hello", msg.Content)
+}
+
+func TestManager_BeforeSend_Reject(t *testing.T) {
+	m := NewManager(0, nil)
+	require.NoError(t, m.Register(&testPlugin{name: "guard", beforeFn: func(_ Config, msg *OutgoingMessage) error {
+		if strings.Contains(msg.Content, "secret") {
+			return Reject("contains a secret")
+		}
+		return nil
+	}}))
+
+	err := m.BeforeSend(context.Background(), map[string]Config{"guard": {}}, &OutgoingMessage{Content: "the secret"})
+	assert.True(t, errors.Is(err, ErrRejected))
+	assert.Contains(t, err.Error(), "contains a secret")
+}
+
+func TestManager_FailuresDoNotBlock(t *testing.T) {
+	var mu sync.Mutex
+	var failures []string
+	m := NewManager(50*time.Millisecond, func(plugin, hook string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, plugin+":"+hook)
+	})
+
+	require.NoError(t, m.Register(&testPlugin{name: "panics", beforeFn: func(Config, *OutgoingMessage) error {
+		panic("boom")
+	}}))
+	require.NoError(t, m.Register(&testPlugin{name: "slow", beforeFn: func(_ Config, msg *OutgoingMessage) error {
+		time.Sleep(200 * time.Millisecond)
+		msg.Content = "late"
+		return nil
+	}}))
+	require.NoError(t, m.Register(&testPlugin{name: "errors", beforeFn: func(_ Config, msg *OutgoingMessage) error {
+		msg.Content = "partial"
+		return errors.New("upstream unavailable")
+	}}))
+
+	msg := &OutgoingMessage{Content: "hello"}
+	err := m.BeforeSend(context.Background(), map[string]Config{"panics": {}, "slow": {}, "errors": {}}, msg)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", msg.Content)
+	assert.Equal(t, []string{"panics:pre_send", "slow:pre_send", "errors:pre_send"}, failures)
+}
+
+func TestManager_ContactCreated(t *testing.T) {
+	m := NewManager(0, nil)
+	p := &testPlugin{name: "crm"}
+	require.NoError(t, m.Register(p))
+
+	ev := ContactEvent{OrganizationID: uuid.New(), ContactID: uuid.New(), PhoneNumber: "15551234567"}
+	m.ContactCreated(context.Background(), map[string]Config{"crm": {}}, ev)
+	m.ContactCreated(context.Background(), map[string]Config{"other": {}}, ev)
+
+	assert.Equal(t, []ContactEvent{ev}, p.contacts)
+}
+
+func TestManager_LoadDir_Missing(t *testing.T) {
+	m := NewManager(0, nil)
+
+	loaded, err := m.LoadDir(t.TempDir() + "/missing")
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	loaded, err = m.LoadDir(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+}
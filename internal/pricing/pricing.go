@@ -0,0 +1,136 @@
+// Package pricing estimates the Meta charges for template messages sent to WhatsApp users.
+package pricing
+
+import (
+	"sort"
+	"strings"
+)
+
+// Currency is the currency all rates are expressed in
+const Currency = "USD"
+
+// Template categories as reported by Meta
+const (
+	CategoryMarketing      = "MARKETING"
+	CategoryUtility        = "UTILITY"
+	CategoryAuthentication = "AUTHENTICATION"
+)
+
+// otherCountries is the key of the fallback rate for calling codes without their own entry
+const otherCountries = "other"
+
+// Rate is the per-message price of each template category in a market
+type Rate struct {
+	Marketing      float64
+	Utility        float64
+	Authentication float64
+}
+
+// forCategory returns the price for a template category, treating unknown categories as marketing
+func (r Rate) forCategory(category string) float64 {
+	switch strings.ToUpper(category) {
+	case CategoryUtility:
+		return r.Utility
+	case CategoryAuthentication:
+		return r.Authentication
+	default:
+		return r.Marketing
+	}
+}
+
+// rates maps country calling codes to Meta's per-message list prices in USD.
+// These are approximations of the public rate card and must be kept in sync with it.
+var rates = map[string]Rate{
+	"1":            {Marketing: 0.0250, Utility: 0.0040, Authentication: 0.0135}, // US, Canada
+	"27":           {Marketing: 0.0379, Utility: 0.0076, Authentication: 0.0076}, // South Africa
+	"33":           {Marketing: 0.0859, Utility: 0.0300, Authentication: 0.0691}, // France
+	"34":           {Marketing: 0.0615, Utility: 0.0200, Authentication: 0.0342}, // Spain
+	"39":           {Marketing: 0.0691, Utility: 0.0300, Authentication: 0.0378}, // Italy
+	"44":           {Marketing: 0.0529, Utility: 0.0220, Authentication: 0.0358}, // United Kingdom
+	"49":           {Marketing: 0.1365, Utility: 0.0550, Authentication: 0.0768}, // Germany
+	"52":           {Marketing: 0.0305, Utility: 0.0085, Authentication: 0.0239}, // Mexico
+	"55":           {Marketing: 0.0625, Utility: 0.0068, Authentication: 0.0315}, // Brazil
+	"62":           {Marketing: 0.0411, Utility: 0.0250, Authentication: 0.0300}, // Indonesia
+	"91":           {Marketing: 0.0107, Utility: 0.0014, Authentication: 0.0014}, // India
+	"234":          {Marketing: 0.0516, Utility: 0.0067, Authentication: 0.0067}, // Nigeria
+	"966":          {Marketing: 0.0455, Utility: 0.0115, Authentication: 0.0107}, // Saudi Arabia
+	"971":          {Marketing: 0.0384, Utility: 0.0157, Authentication: 0.0178}, // United Arab Emirates
+	otherCountries: {Marketing: 0.0604, Utility: 0.0077, Authentication: 0.0224},
+}
+
+// callingCodes lists the known calling codes, longest first, for prefix matching
+var callingCodes = func() []string {
+	codes := make([]string, 0, len(rates))
+	for code := range rates {
+		if code != otherCountries {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return len(codes[i]) > len(codes[j]) })
+	return codes
+}()
+
+// CountryCode returns the calling code used to price a phone number, or "other" if it has no own rate
+func CountryCode(phoneNumber string) string {
+	digits := strings.TrimLeft(strings.TrimSpace(phoneNumber), "+")
+	for _, code := range callingCodes {
+		if strings.HasPrefix(digits, code) {
+			return code
+		}
+	}
+	return otherCountries
+}
+
+// MessageCost returns the price of sending one template message of the given category to a phone number
+func MessageCost(phoneNumber, category string) float64 {
+	return rates[CountryCode(phoneNumber)].forCategory(category)
+}
+
+// CountryEstimate is the cost of the recipients in one market
+type CountryEstimate struct {
+	CountryCode string  `json:"country_code"`
+	Recipients  int     `json:"recipients"`
+	Rate        float64 `json:"rate"`
+	Cost        float64 `json:"cost"`
+}
+
+// Estimate is the expected cost of sending a template to a set of recipients
+type Estimate struct {
+	Currency   string            `json:"currency"`
+	Category   string            `json:"category"`
+	Recipients int               `json:"recipients"`
+	Total      float64           `json:"total"`
+	Breakdown  []CountryEstimate `json:"breakdown"`
+}
+
+// EstimateCost prices a template send to each phone number, grouped by market
+func EstimateCost(phoneNumbers []string, category string) Estimate {
+	counts := make(map[string]int)
+	for _, phone := range phoneNumbers {
+		counts[CountryCode(phone)]++
+	}
+
+	estimate := Estimate{
+		Currency:   Currency,
+		Category:   strings.ToUpper(category),
+		Recipients: len(phoneNumbers),
+		Breakdown:  make([]CountryEstimate, 0, len(counts)),
+	}
+	for code, count := range counts {
+		rate := rates[code].forCategory(category)
+		cost := rate * float64(count)
+		estimate.Breakdown = append(estimate.Breakdown, CountryEstimate{
+			CountryCode: code,
+			Recipients:  count,
+			Rate:        rate,
+			Cost:        cost,
+		})
+		estimate.Total += cost
+	}
+
+	// Most expensive markets first
+	sort.Slice(estimate.Breakdown, func(i, j int) bool {
+		return estimate.Breakdown[i].Cost > estimate.Breakdown[j].Cost
+	})
+	return estimate
+}
@@ -0,0 +1,44 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountryCode(t *testing.T) {
+	assert.Equal(t, "91", CountryCode("919876543210"))
+	assert.Equal(t, "91", CountryCode("+919876543210"))
+	assert.Equal(t, "1", CountryCode("14155550123"))
+	// Longest prefix wins: 971 must not be priced as 97x/9x
+	assert.Equal(t, "971", CountryCode("971501234567"))
+	assert.Equal(t, "other", CountryCode("8613800138000"))
+}
+
+func TestMessageCost(t *testing.T) {
+	assert.Equal(t, rates["91"].Utility, MessageCost("919876543210", "utility"))
+	assert.Equal(t, rates["91"].Authentication, MessageCost("919876543210", CategoryAuthentication))
+	// Unknown categories are priced as marketing
+	assert.Equal(t, rates["44"].Marketing, MessageCost("447700900123", ""))
+}
+
+func TestEstimateCost(t *testing.T) {
+	estimate := EstimateCost([]string{"919876543210", "919876543211", "447700900123"}, CategoryMarketing)
+
+	assert.Equal(t, Currency, estimate.Currency)
+	assert.Equal(t, 3, estimate.Recipients)
+	require.Len(t, estimate.Breakdown, 2)
+	assert.InDelta(t, 2*rates["91"].Marketing+rates["44"].Marketing, estimate.Total, 1e-9)
+
+	// Sorted by cost, most expensive first
+	assert.GreaterOrEqual(t, estimate.Breakdown[0].Cost, estimate.Breakdown[1].Cost)
+}
+
+func TestEstimateCost_Empty(t *testing.T) {
+	estimate := EstimateCost(nil, CategoryUtility)
+
+	assert.Equal(t, 0, estimate.Recipients)
+	assert.Zero(t, estimate.Total)
+	assert.Empty(t, estimate.Breakdown)
+}
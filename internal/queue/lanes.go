@@ -0,0 +1,174 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Lane is a priority lane of the job queue. Each lane is its own Redis stream, so a
+// campaign burst queued on the low lane doesn't hold up jobs on the high lane.
+type Lane string
+
+const (
+	// LaneHigh carries latency-sensitive sends, such as API template sends used for
+	// OTPs and notifications
+	LaneHigh Lane = "high"
+
+	// LaneLow carries campaign traffic and other background jobs
+	LaneLow Lane = "low"
+)
+
+// Lanes lists the lanes in priority order
+var Lanes = []Lane{LaneHigh, LaneLow}
+
+const (
+	// HighPriorityStreamName is the Redis stream of the high lane
+	HighPriorityStreamName = "whatomate:priority"
+
+	// laneStatsPrefix prefixes the per-minute throughput counters of a lane
+	laneStatsPrefix = "whatomate:queue:stats:"
+
+	// laneStatsRetention is how long per-minute throughput counters are kept
+	laneStatsRetention = time.Hour
+)
+
+// Stream returns the Redis stream of the lane. The low lane keeps the original
+// campaign stream, so jobs queued before lanes existed are still consumed.
+func (l Lane) Stream() string {
+	if l == LaneHigh {
+		return HighPriorityStreamName
+	}
+	return StreamName
+}
+
+// LaneForJob returns the lane a job type is queued on
+func LaneForJob(jobType JobType) Lane {
+	if jobType == JobTypeTemplateSend {
+		return LaneHigh
+	}
+	return LaneLow
+}
+
+// laneForStream returns the lane consuming a Redis stream
+func laneForStream(stream string) Lane {
+	for _, lane := range Lanes {
+		if lane.Stream() == stream {
+			return lane
+		}
+	}
+	return LaneLow
+}
+
+// LaneWeights sets how many jobs each lane is given per dequeue cycle while several
+// lanes have work waiting. An idle lane's turns go to the others.
+type LaneWeights map[Lane]int
+
+// schedule expands the weights into the lane order of one dequeue cycle, e.g.
+// {high: 3, low: 1} gives high, high, high, low. Lanes without a positive weight get
+// one turn so that no lane starves.
+func (w LaneWeights) schedule() []Lane {
+	var order []Lane
+	for _, lane := range Lanes {
+		n := w[lane]
+		if n < 1 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			order = append(order, lane)
+		}
+	}
+	return order
+}
+
+// LaneStats is the backlog and recent throughput of a lane
+type LaneStats struct {
+	Lane          Lane    `json:"lane"`
+	Waiting       int64   `json:"waiting"`         // Jobs not yet picked up by a worker
+	Pending       int64   `json:"pending"`         // Jobs picked up but not yet acknowledged
+	Processed     int64   `json:"processed"`       // Jobs finished within the window
+	Failed        int64   `json:"failed"`          // Jobs that failed within the window and are left for retry
+	PerMinute     float64 `json:"per_minute"`      // Finished jobs per minute over the window
+	AvgDurationMs float64 `json:"avg_duration_ms"` // Average handling time of finished jobs
+}
+
+// laneStatsKey returns the throughput counters key of a lane for one minute
+func laneStatsKey(lane Lane, t time.Time) string {
+	return fmt.Sprintf("%s%s:%d", laneStatsPrefix, lane, t.Unix()/60)
+}
+
+// recordLaneJob adds a handled job to the lane's throughput counters
+func recordLaneJob(ctx context.Context, client *redis.Client, lane Lane, duration time.Duration, failed bool) error {
+	key := laneStatsKey(lane, time.Now())
+	pipe := client.Pipeline()
+	if failed {
+		pipe.HIncrBy(ctx, key, "failed", 1)
+	} else {
+		pipe.HIncrBy(ctx, key, "processed", 1)
+		pipe.HIncrBy(ctx, key, "duration_ms", duration.Milliseconds())
+	}
+	pipe.Expire(ctx, key, laneStatsRetention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetLaneStats returns the backlog of every lane and its throughput over the last
+// window, rounded up to whole minutes
+func GetLaneStats(ctx context.Context, client *redis.Client, window time.Duration) ([]LaneStats, error) {
+	minutes := int((window + time.Minute - 1) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	now := time.Now()
+
+	stats := make([]LaneStats, 0, len(Lanes))
+	for _, lane := range Lanes {
+		s := LaneStats{Lane: lane}
+
+		groups, err := client.XInfoGroups(ctx, lane.Stream()).Result()
+		if err != nil && !isNoSuchKey(err) {
+			return nil, fmt.Errorf("failed to read %s lane backlog: %w", lane, err)
+		}
+		for _, g := range groups {
+			if g.Name == ConsumerGroup {
+				s.Waiting = g.Lag
+				s.Pending = g.Pending
+			}
+		}
+
+		pipe := client.Pipeline()
+		cmds := make([]*redis.MapStringStringCmd, minutes)
+		for i := 0; i < minutes; i++ {
+			cmds[i] = pipe.HGetAll(ctx, laneStatsKey(lane, now.Add(-time.Duration(i)*time.Minute)))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to read %s lane throughput: %w", lane, err)
+		}
+
+		var durationMs int64
+		for _, cmd := range cmds {
+			values := cmd.Val()
+			processed, _ := strconv.ParseInt(values["processed"], 10, 64)
+			failed, _ := strconv.ParseInt(values["failed"], 10, 64)
+			ms, _ := strconv.ParseInt(values["duration_ms"], 10, 64)
+			s.Processed += processed
+			s.Failed += failed
+			durationMs += ms
+		}
+		s.PerMinute = float64(s.Processed) / float64(minutes)
+		if s.Processed > 0 {
+			s.AvgDurationMs = float64(durationMs) / float64(s.Processed)
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// isNoSuchKey reports whether err is Redis' error for a stream that doesn't exist yet
+func isNoSuchKey(err error) bool {
+	return strings.Contains(err.Error(), "no such key")
+}
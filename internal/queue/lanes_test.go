@@ -0,0 +1,24 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLaneWeights_Schedule(t *testing.T) {
+	assert.Equal(t, []Lane{LaneHigh, LaneHigh, LaneHigh, LaneLow}, LaneWeights{LaneHigh: 3, LaneLow: 1}.schedule())
+	// Lanes without a positive weight still get a turn
+	assert.Equal(t, []Lane{LaneHigh, LaneLow}, LaneWeights{}.schedule())
+	assert.Equal(t, []Lane{LaneHigh, LaneLow, LaneLow}, LaneWeights{LaneHigh: -1, LaneLow: 2}.schedule())
+}
+
+func TestLaneForJob(t *testing.T) {
+	assert.Equal(t, LaneHigh, LaneForJob(JobTypeTemplateSend))
+	assert.Equal(t, LaneLow, LaneForJob(JobTypeRecipient))
+
+	// Campaign jobs stay on the original stream
+	assert.Equal(t, StreamName, LaneLow.Stream())
+	assert.Equal(t, LaneHigh, laneForStream(LaneHigh.Stream()))
+	assert.Equal(t, LaneLow, laneForStream(StreamName))
+}
@@ -13,6 +13,9 @@ import (
 const (
 	// CampaignStatsChannel is the Redis pub/sub channel for campaign stats updates
 	CampaignStatsChannel = "whatomate:campaign_stats"
+
+	// ContactBulkUpdateChannel is the Redis pub/sub channel for bulk contact update progress
+	ContactBulkUpdateChannel = "whatomate:contact_bulk_update"
 )
 
 // CampaignStatsUpdate represents a campaign stats update message
@@ -26,6 +29,18 @@ type CampaignStatsUpdate struct {
 	FailedCount    int                  `json:"failed_count"`
 }
 
+// ContactBulkUpdateProgress reports the progress of a bulk contact update
+type ContactBulkUpdateProgress struct {
+	UpdateID       uuid.UUID                      `json:"id"`
+	OrganizationID uuid.UUID                      `json:"organization_id"`
+	RequestedByID  uuid.UUID                      `json:"requested_by_id"`
+	Status         models.ContactBulkUpdateStatus `json:"status"`
+	TotalCount     int                            `json:"total_count"`
+	ProcessedCount int                            `json:"processed_count"`
+	UpdatedCount   int                            `json:"updated_count"`
+	Error          string                         `json:"error,omitempty"`
+}
+
 // Publisher publishes messages to Redis pub/sub channels
 type Publisher struct {
 	client *redis.Client
@@ -56,6 +71,20 @@ func (p *Publisher) PublishCampaignStats(ctx context.Context, update *CampaignSt
 	return nil
 }
 
+// PublishContactBulkUpdate publishes the progress of a bulk contact update
+func (p *Publisher) PublishContactBulkUpdate(ctx context.Context, progress *ContactBulkUpdateProgress) error {
+	payload, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Publish(ctx, ContactBulkUpdateChannel, payload).Err(); err != nil {
+		p.log.Error("Failed to publish contact bulk update progress", "error", err, "update_id", progress.UpdateID)
+		return err
+	}
+	return nil
+}
+
 // Subscriber subscribes to Redis pub/sub channels
 type Subscriber struct {
 	client *redis.Client
@@ -112,6 +141,40 @@ func (s *Subscriber) SubscribeCampaignStats(ctx context.Context, handler func(up
 	return nil
 }
 
+// SubscribeContactBulkUpdates subscribes to bulk contact update progress. The handler is
+// called for each received update.
+func (s *Subscriber) SubscribeContactBulkUpdates(ctx context.Context, handler func(progress *ContactBulkUpdateProgress)) error {
+	s.pubsub = s.client.Subscribe(ctx, ContactBulkUpdateChannel)
+
+	if _, err := s.pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := s.pubsub.Channel()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var progress ContactBulkUpdateProgress
+				if err := json.Unmarshal([]byte(msg.Payload), &progress); err != nil {
+					s.log.Error("Failed to unmarshal contact bulk update progress", "error", err)
+					continue
+				}
+
+				handler(&progress)
+			}
+		}
+	}()
+
+	return nil
+}
+
 // Close closes the subscriber
 func (s *Subscriber) Close() error {
 	if s.pubsub != nil {
@@ -14,6 +14,12 @@ type JobType string
 const (
 	// JobTypeRecipient is for processing a single recipient message
 	JobTypeRecipient JobType = "recipient"
+
+	// JobTypeTemplateSend is for sending one message of a bulk template send batch
+	JobTypeTemplateSend JobType = "template_send"
+
+	// JobTypeContactBulkUpdate is for applying a bulk update to a set of contacts
+	JobTypeContactBulkUpdate JobType = "contact_bulk_update"
 )
 
 // RecipientJob represents a single recipient message job
@@ -27,6 +33,22 @@ type RecipientJob struct {
 	EnqueuedAt     time.Time     `json:"enqueued_at"`
 }
 
+// TemplateSendJob represents a single message of a bulk template send batch
+type TemplateSendJob struct {
+	BatchID        uuid.UUID `json:"batch_id"`
+	ItemID         uuid.UUID `json:"item_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	EnqueuedAt     time.Time `json:"enqueued_at"`
+}
+
+// ContactBulkUpdateJob applies a bulk contact update. The whole update is one job; the
+// worker records its progress so a redelivered job resumes where it stopped.
+type ContactBulkUpdateJob struct {
+	UpdateID       uuid.UUID `json:"update_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	EnqueuedAt     time.Time `json:"enqueued_at"`
+}
+
 // Queue defines the interface for job queue operations
 type Queue interface {
 	// EnqueueRecipient adds a single recipient job to the queue
@@ -35,6 +57,12 @@ type Queue interface {
 	// EnqueueRecipients adds multiple recipient jobs to the queue
 	EnqueueRecipients(ctx context.Context, jobs []*RecipientJob) error
 
+	// EnqueueTemplateSends adds bulk template send jobs to the queue
+	EnqueueTemplateSends(ctx context.Context, jobs []*TemplateSendJob) error
+
+	// EnqueueContactBulkUpdate adds a bulk contact update job to the queue
+	EnqueueContactBulkUpdate(ctx context.Context, job *ContactBulkUpdateJob) error
+
 	// Close closes the queue connection
 	Close() error
 }
@@ -42,6 +70,8 @@ type Queue interface {
 // JobHandler handles different job types
 type JobHandler interface {
 	HandleRecipientJob(ctx context.Context, job *RecipientJob) error
+	HandleTemplateSendJob(ctx context.Context, job *TemplateSendJob) error
+	HandleContactBulkUpdateJob(ctx context.Context, job *ContactBulkUpdateJob) error
 }
 
 // Consumer defines the interface for consuming jobs from the queue
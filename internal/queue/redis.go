@@ -12,7 +12,7 @@ import (
 )
 
 const (
-	// StreamName is the Redis stream for campaign jobs
+	// StreamName is the Redis stream for campaign jobs, the low priority lane
 	StreamName = "whatomate:campaigns"
 
 	// ConsumerGroup is the consumer group name for workers
@@ -51,7 +51,7 @@ func (q *RedisQueue) EnqueueRecipient(ctx context.Context, job *RecipientJob) er
 	}
 
 	_, err = q.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: StreamName,
+		Stream: LaneForJob(JobTypeRecipient).Stream(),
 		Values: map[string]interface{}{
 			"type":    string(JobTypeRecipient),
 			"payload": string(payload),
@@ -85,7 +85,7 @@ func (q *RedisQueue) EnqueueRecipients(ctx context.Context, jobs []*RecipientJob
 		}
 
 		pipe.XAdd(ctx, &redis.XAddArgs{
-			Stream: StreamName,
+			Stream: LaneForJob(JobTypeRecipient).Stream(),
 			Values: map[string]interface{}{
 				"type":    string(JobTypeRecipient),
 				"payload": string(payload),
@@ -102,20 +102,85 @@ func (q *RedisQueue) EnqueueRecipients(ctx context.Context, jobs []*RecipientJob
 	return nil
 }
 
+// EnqueueTemplateSends adds bulk template send jobs to the queue using pipeline
+func (q *RedisQueue) EnqueueTemplateSends(ctx context.Context, jobs []*TemplateSendJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	pipe := q.client.Pipeline()
+	now := time.Now()
+
+	for _, job := range jobs {
+		if job.EnqueuedAt.IsZero() {
+			job.EnqueuedAt = now
+		}
+
+		payload, err := json.Marshal(job)
+		if err != nil {
+			return fmt.Errorf("failed to marshal template send job: %w", err)
+		}
+
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: LaneForJob(JobTypeTemplateSend).Stream(),
+			Values: map[string]interface{}{
+				"type":    string(JobTypeTemplateSend),
+				"payload": string(payload),
+			},
+		})
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue template send jobs: %w", err)
+	}
+
+	q.log.Info("Template send jobs enqueued", "count", len(jobs), "batch_id", jobs[0].BatchID)
+	return nil
+}
+
+// EnqueueContactBulkUpdate adds a bulk contact update job to the queue
+func (q *RedisQueue) EnqueueContactBulkUpdate(ctx context.Context, job *ContactBulkUpdateJob) error {
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contact bulk update job: %w", err)
+	}
+
+	_, err = q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: LaneForJob(JobTypeContactBulkUpdate).Stream(),
+		Values: map[string]interface{}{
+			"type":    string(JobTypeContactBulkUpdate),
+			"payload": string(payload),
+		},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to enqueue contact bulk update job: %w", err)
+	}
+
+	q.log.Info("Contact bulk update job enqueued", "update_id", job.UpdateID)
+	return nil
+}
+
 // Close closes the queue connection
 func (q *RedisQueue) Close() error {
 	return nil // Redis client is managed externally
 }
 
-// RedisConsumer implements the Consumer interface using Redis Streams
+// RedisConsumer implements the Consumer interface using Redis Streams. Jobs are read
+// from the lane streams by weight, so high priority jobs keep flowing during campaigns.
 type RedisConsumer struct {
 	client     *redis.Client
 	log        logf.Logger
 	consumerID string
+	weights    LaneWeights
 }
 
-// NewRedisConsumer creates a new Redis consumer
-func NewRedisConsumer(client *redis.Client, log logf.Logger) (*RedisConsumer, error) {
+// NewRedisConsumer creates a new Redis consumer reading the lanes by weights
+func NewRedisConsumer(client *redis.Client, log logf.Logger, weights LaneWeights) (*RedisConsumer, error) {
 	// Generate unique consumer ID
 	hostname, _ := os.Hostname()
 	consumerID := fmt.Sprintf("worker-%s-%d", hostname, os.Getpid())
@@ -124,16 +189,19 @@ func NewRedisConsumer(client *redis.Client, log logf.Logger) (*RedisConsumer, er
 		client:     client,
 		log:        log,
 		consumerID: consumerID,
+		weights:    weights,
 	}
 
-	// Create consumer group if it doesn't exist
+	// Create the consumer group of every lane if it doesn't exist
 	ctx := context.Background()
-	err := client.XGroupCreateMkStream(ctx, StreamName, ConsumerGroup, "0").Err()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	for _, lane := range Lanes {
+		err := client.XGroupCreateMkStream(ctx, lane.Stream(), ConsumerGroup, "0").Err()
+		if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+			return nil, fmt.Errorf("failed to create consumer group: %w", err)
+		}
 	}
 
-	log.Info("Redis consumer initialized", "consumer_id", consumerID)
+	log.Info("Redis consumer initialized", "consumer_id", consumerID, "weights", weights)
 	return consumer, nil
 }
 
@@ -146,7 +214,8 @@ func (c *RedisConsumer) Consume(ctx context.Context, handler JobHandler) error {
 		c.log.Warn("Failed to claim pending messages", "error", err)
 	}
 
-	for {
+	order := c.weights.schedule()
+	for turn := 0; ; turn++ {
 		select {
 		case <-ctx.Done():
 			c.log.Info("Consumer shutting down")
@@ -154,14 +223,13 @@ func (c *RedisConsumer) Consume(ctx context.Context, handler JobHandler) error {
 		default:
 		}
 
-		// Read new messages from the stream
-		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-			Group:    ConsumerGroup,
-			Consumer: c.consumerID,
-			Streams:  []string{StreamName, ">"},
-			Count:    1,
-			Block:    BlockTimeout,
-		}).Result()
+		// Take the next message from the lane whose turn it is. When that lane is idle,
+		// wait on all lanes, highest priority first.
+		lane := order[turn%len(order)]
+		streams, err := c.read(ctx, []Lane{lane}, -1)
+		if err == redis.Nil {
+			streams, err = c.read(ctx, Lanes, BlockTimeout)
+		}
 
 		if err != nil {
 			if err == redis.Nil {
@@ -178,68 +246,95 @@ func (c *RedisConsumer) Consume(ctx context.Context, handler JobHandler) error {
 
 		for _, stream := range streams {
 			for _, msg := range stream.Messages {
-				if err := c.processMessage(ctx, msg, handler); err != nil {
-					c.log.Error("Failed to process message", "error", err, "message_id", msg.ID)
-					// Don't ACK failed messages - they'll be reclaimed later
-					continue
-				}
-
-				// Acknowledge the message
-				if err := c.client.XAck(ctx, StreamName, ConsumerGroup, msg.ID).Err(); err != nil {
-					c.log.Error("Failed to ACK message", "error", err, "message_id", msg.ID)
-				}
+				c.handleMessage(ctx, stream.Stream, msg, handler)
 			}
 		}
 	}
 }
 
-// claimPendingMessages claims stale pending messages from crashed workers
-func (c *RedisConsumer) claimPendingMessages(ctx context.Context, handler JobHandler) error {
-	// Get pending messages that have been idle for too long
-	pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
-		Stream: StreamName,
-		Group:  ConsumerGroup,
-		Start:  "-",
-		End:    "+",
-		Count:  100,
-		Idle:   ClaimMinIdleTime,
+// read reads at most one new message from each lane's stream. A negative block
+// returns redis.Nil right away when there are none.
+func (c *RedisConsumer) read(ctx context.Context, lanes []Lane, block time.Duration) ([]redis.XStream, error) {
+	streams := make([]string, 0, 2*len(lanes))
+	for _, lane := range lanes {
+		streams = append(streams, lane.Stream())
+	}
+	for range lanes {
+		streams = append(streams, ">")
+	}
+
+	return c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    ConsumerGroup,
+		Consumer: c.consumerID,
+		Streams:  streams,
+		Count:    1,
+		Block:    block,
 	}).Result()
+}
 
+// handleMessage processes a message, records it in the lane's throughput and
+// acknowledges it on success
+func (c *RedisConsumer) handleMessage(ctx context.Context, stream string, msg redis.XMessage, handler JobHandler) {
+	start := time.Now()
+	err := c.processMessage(ctx, msg, handler)
+	if statErr := recordLaneJob(ctx, c.client, laneForStream(stream), time.Since(start), err != nil); statErr != nil {
+		c.log.Warn("Failed to record queue lane stats", "error", statErr)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get pending messages: %w", err)
+		c.log.Error("Failed to process message", "error", err, "message_id", msg.ID, "stream", stream)
+		// Don't ACK failed messages - they'll be reclaimed later
+		return
 	}
 
-	if len(pending) == 0 {
-		return nil
+	// Acknowledge the message
+	if err := c.client.XAck(ctx, stream, ConsumerGroup, msg.ID).Err(); err != nil {
+		c.log.Error("Failed to ACK message", "error", err, "message_id", msg.ID)
 	}
+}
 
-	c.log.Info("Found stale pending messages to claim", "count", len(pending))
-
-	// Claim and process each pending message
-	for _, p := range pending {
-		// Claim the message
-		messages, err := c.client.XClaim(ctx, &redis.XClaimArgs{
-			Stream:   StreamName,
-			Group:    ConsumerGroup,
-			Consumer: c.consumerID,
-			MinIdle:  ClaimMinIdleTime,
-			Messages: []string{p.ID},
+// claimPendingMessages claims stale pending messages from crashed workers
+func (c *RedisConsumer) claimPendingMessages(ctx context.Context, handler JobHandler) error {
+	for _, lane := range Lanes {
+		stream := lane.Stream()
+
+		// Get pending messages that have been idle for too long
+		pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  ConsumerGroup,
+			Start:  "-",
+			End:    "+",
+			Count:  100,
+			Idle:   ClaimMinIdleTime,
 		}).Result()
 
 		if err != nil {
-			c.log.Error("Failed to claim message", "error", err, "message_id", p.ID)
+			return fmt.Errorf("failed to get pending messages: %w", err)
+		}
+
+		if len(pending) == 0 {
 			continue
 		}
 
-		for _, msg := range messages {
-			if err := c.processMessage(ctx, msg, handler); err != nil {
-				c.log.Error("Failed to process claimed message", "error", err, "message_id", msg.ID)
+		c.log.Info("Found stale pending messages to claim", "count", len(pending), "lane", lane)
+
+		// Claim and process each pending message
+		for _, p := range pending {
+			// Claim the message
+			messages, err := c.client.XClaim(ctx, &redis.XClaimArgs{
+				Stream:   stream,
+				Group:    ConsumerGroup,
+				Consumer: c.consumerID,
+				MinIdle:  ClaimMinIdleTime,
+				Messages: []string{p.ID},
+			}).Result()
+
+			if err != nil {
+				c.log.Error("Failed to claim message", "error", err, "message_id", p.ID)
 				continue
 			}
 
-			// Acknowledge the message
-			if err := c.client.XAck(ctx, StreamName, ConsumerGroup, msg.ID).Err(); err != nil {
-				c.log.Error("Failed to ACK claimed message", "error", err, "message_id", msg.ID)
+			for _, msg := range messages {
+				c.handleMessage(ctx, stream, msg, handler)
 			}
 		}
 	}
@@ -268,6 +363,22 @@ func (c *RedisConsumer) processMessage(ctx context.Context, msg redis.XMessage,
 		c.log.Debug("Processing recipient job", "campaign_id", job.CampaignID, "recipient_id", job.RecipientID, "message_id", msg.ID)
 		return handler.HandleRecipientJob(ctx, &job)
 
+	case JobTypeTemplateSend:
+		var job TemplateSendJob
+		if err := json.Unmarshal([]byte(payload), &job); err != nil {
+			return fmt.Errorf("failed to unmarshal template send job: %w", err)
+		}
+		c.log.Debug("Processing template send job", "batch_id", job.BatchID, "item_id", job.ItemID, "message_id", msg.ID)
+		return handler.HandleTemplateSendJob(ctx, &job)
+
+	case JobTypeContactBulkUpdate:
+		var job ContactBulkUpdateJob
+		if err := json.Unmarshal([]byte(payload), &job); err != nil {
+			return fmt.Errorf("failed to unmarshal contact bulk update job: %w", err)
+		}
+		c.log.Debug("Processing contact bulk update job", "update_id", job.UpdateID, "message_id", msg.ID)
+		return handler.HandleContactBulkUpdateJob(ctx, &job)
+
 	default:
 		return fmt.Errorf("unknown job type: %s", jobType)
 	}
@@ -0,0 +1,104 @@
+package queue_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler records the order jobs are handled in
+type recordingHandler struct {
+	mu    sync.Mutex
+	lanes []queue.Lane
+}
+
+func (h *recordingHandler) HandleRecipientJob(ctx context.Context, job *queue.RecipientJob) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lanes = append(h.lanes, queue.LaneLow)
+	return nil
+}
+
+func (h *recordingHandler) HandleTemplateSendJob(ctx context.Context, job *queue.TemplateSendJob) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lanes = append(h.lanes, queue.LaneHigh)
+	return nil
+}
+
+func (h *recordingHandler) HandleContactBulkUpdateJob(ctx context.Context, job *queue.ContactBulkUpdateJob) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lanes = append(h.lanes, queue.LaneLow)
+	return nil
+}
+
+func (h *recordingHandler) handled() []queue.Lane {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]queue.Lane(nil), h.lanes...)
+}
+
+func TestRedisConsumer_WeightedLanes(t *testing.T) {
+	client := testutil.SetupTestRedis(t)
+	if client == nil {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+	ctx := context.Background()
+	for _, lane := range queue.Lanes {
+		require.NoError(t, client.Del(ctx, lane.Stream()).Err())
+	}
+
+	consumer, err := queue.NewRedisConsumer(client, testutil.NopLogger(), queue.LaneWeights{queue.LaneHigh: 2, queue.LaneLow: 1})
+	require.NoError(t, err)
+
+	// A campaign burst queued ahead of a few template sends
+	q := queue.NewRedisQueue(client, testutil.NopLogger())
+	campaignID := uuid.New()
+	recipients := make([]*queue.RecipientJob, 6)
+	for i := range recipients {
+		recipients[i] = &queue.RecipientJob{CampaignID: campaignID, RecipientID: uuid.New()}
+	}
+	require.NoError(t, q.EnqueueRecipients(ctx, recipients))
+	batchID := uuid.New()
+	sends := make([]*queue.TemplateSendJob, 4)
+	for i := range sends {
+		sends[i] = &queue.TemplateSendJob{BatchID: batchID, ItemID: uuid.New()}
+	}
+	require.NoError(t, q.EnqueueTemplateSends(ctx, sends))
+
+	handler := &recordingHandler{}
+	consumeCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = consumer.Consume(consumeCtx, handler)
+	}()
+	require.Eventually(t, func() bool { return len(handler.handled()) == 10 }, 10*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []queue.Lane{queue.LaneHigh, queue.LaneHigh, queue.LaneLow, queue.LaneHigh, queue.LaneHigh, queue.LaneLow, queue.LaneLow, queue.LaneLow, queue.LaneLow, queue.LaneLow}, handler.handled())
+
+	stats, err := queue.GetLaneStats(ctx, client, 5*time.Minute)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, queue.LaneHigh, stats[0].Lane)
+	assert.GreaterOrEqual(t, stats[0].Processed, int64(4))
+	assert.GreaterOrEqual(t, stats[1].Processed, int64(6))
+	assert.Zero(t, stats[0].Waiting)
+	assert.Zero(t, stats[1].Pending)
+
+	workers, err := queue.GetWorkerStats(ctx, client)
+	require.NoError(t, err)
+	require.NotEmpty(t, workers)
+	assert.True(t, workers[0].Alive)
+	assert.Contains(t, workers[0].Name, "worker-")
+}
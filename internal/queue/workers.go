@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// WorkerStaleAfter is how long a worker can go without reading the queue before it
+	// is reported as down. Idle workers read every BlockTimeout.
+	WorkerStaleAfter = 2 * time.Minute
+
+	// workerForgetAfter drops consumers of long-gone processes from the worker list
+	// once they hold no jobs
+	workerForgetAfter = 24 * time.Hour
+)
+
+// WorkerStats is a worker's activity on the job queue, as seen by the consumer group
+type WorkerStats struct {
+	Name    string `json:"name"`    // Consumer name, worker-<hostname>-<pid>
+	Pending int64  `json:"pending"` // Jobs picked up but not yet acknowledged, across lanes
+	IdleMs  int64  `json:"idle_ms"` // Time since the worker last read from the queue
+	Alive   bool   `json:"alive"`   // Read from the queue within WorkerStaleAfter
+}
+
+// GetWorkerStats returns the workers that consumed the queue recently or still hold
+// jobs, most recently active first
+func GetWorkerStats(ctx context.Context, client *redis.Client) ([]WorkerStats, error) {
+	byName := make(map[string]*WorkerStats)
+	for _, lane := range Lanes {
+		consumers, err := client.XInfoConsumers(ctx, lane.Stream(), ConsumerGroup).Result()
+		if err != nil {
+			if isNoSuchKey(err) || isNoGroup(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s lane workers: %w", lane, err)
+		}
+		for _, c := range consumers {
+			w, ok := byName[c.Name]
+			if !ok {
+				w = &WorkerStats{Name: c.Name, IdleMs: c.Idle.Milliseconds()}
+				byName[c.Name] = w
+			}
+			// A worker reads every lane, so its latest read on any of them counts
+			w.IdleMs = min(w.IdleMs, c.Idle.Milliseconds())
+			w.Pending += c.Pending
+		}
+	}
+
+	workers := make([]WorkerStats, 0, len(byName))
+	for _, w := range byName {
+		idle := time.Duration(w.IdleMs) * time.Millisecond
+		if idle > workerForgetAfter && w.Pending == 0 {
+			continue
+		}
+		w.Alive = idle <= WorkerStaleAfter
+		workers = append(workers, *w)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		if workers[i].IdleMs != workers[j].IdleMs {
+			return workers[i].IdleMs < workers[j].IdleMs
+		}
+		return workers[i].Name < workers[j].Name
+	})
+	return workers, nil
+}
+
+// isNoGroup reports whether err is Redis' error for a stream without the consumer group
+func isNoGroup(err error) bool {
+	return strings.Contains(err.Error(), "NOGROUP")
+}
@@ -0,0 +1,281 @@
+package scripting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// unsafeGlobals are base library functions removed from the sandbox: they load code,
+// reach the host or change other functions' environments
+var unsafeGlobals = []string{
+	"collectgarbage", "dofile", "getfenv", "load", "loadfile", "loadstring", "module",
+	"newproxy", "print", "require", "setfenv", "_printregs", "_GOPHER_LUA_VERSION",
+}
+
+// newSandbox returns a Lua state with the safe standard libraries and the host functions
+// that write to result
+func newSandbox(result *Result) *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range unsafeGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+	if str, ok := L.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		str.RawSetString("dump", lua.LNil)
+		str.RawSetString("rep", L.NewFunction(strRep))
+	}
+
+	regexps := map[string]*regexp.Regexp{}
+	host := map[string]lua.LGFunction{
+		// log writes its arguments, separated by spaces, to the execution log
+		"log": func(L *lua.LState) int {
+			if len(result.Logs) >= maxLogLines {
+				return 0
+			}
+			parts := make([]string, L.GetTop())
+			for i := range parts {
+				parts[i] = display(L.Get(i + 1))
+			}
+			line := strings.Join(parts, " ")
+			if len(line) > 1000 {
+				line = line[:1000] + "…"
+			}
+			result.Logs = append(result.Logs, line)
+			return 0
+		},
+		// reject stops the script and the pipeline
+		"reject": func(L *lua.LState) int {
+			result.Rejected = true
+			result.Reason = L.OptString(1, "rejected by script")
+			L.RaiseError("rejected: %s", result.Reason)
+			return 0
+		},
+		// matches tests a string against a Go regular expression, which unlike Lua
+		// patterns supports alternation and runs in linear time
+		"matches": func(L *lua.LState) int {
+			s, pattern := L.CheckString(1), L.CheckString(2)
+			re, ok := regexps[pattern]
+			if !ok {
+				var err error
+				if re, err = regexp.Compile(pattern); err != nil {
+					L.RaiseError("matches: %v", err)
+					return 0
+				}
+				regexps[pattern] = re
+			}
+			L.Push(lua.LBool(re.MatchString(s)))
+			return 1
+		},
+		// contains reports whether a string contains a substring or a list contains a value
+		"contains": func(L *lua.LState) int {
+			switch h := L.Get(1).(type) {
+			case lua.LString:
+				L.Push(lua.LBool(strings.Contains(string(h), L.CheckString(2))))
+			case *lua.LTable:
+				found := false
+				needle := L.Get(2)
+				h.ForEach(func(_, v lua.LValue) {
+					found = found || L.Equal(v, needle)
+				})
+				L.Push(lua.LBool(found))
+			case *lua.LNilType:
+				L.Push(lua.LFalse)
+			default:
+				L.ArgError(1, "string or table expected")
+			}
+			return 1
+		},
+		// split splits a string on a plain separator
+		"split": func(L *lua.LState) int {
+			parts := strings.Split(L.CheckString(1), L.CheckString(2))
+			if len(parts) > maxListLen {
+				parts = parts[:maxListLen]
+			}
+			list := L.CreateTable(len(parts), 0)
+			for i, p := range parts {
+				list.RawSetInt(i+1, lua.LString(p))
+			}
+			L.Push(list)
+			return 1
+		},
+		"trim": func(L *lua.LState) int {
+			L.Push(lua.LString(strings.TrimSpace(L.CheckString(1))))
+			return 1
+		},
+		// now returns the current Unix time in seconds
+		"now": func(L *lua.LState) int {
+			L.Push(lua.LNumber(time.Now().Unix()))
+			return 1
+		},
+	}
+	for name, fn := range host {
+		L.SetGlobal(name, L.NewFunction(fn))
+	}
+	return L
+}
+
+// strRep replaces string.rep so scripts can't build huge strings in one call
+func strRep(L *lua.LState) int {
+	s, n := L.CheckString(1), L.CheckInt(2)
+	if n <= 0 {
+		L.Push(lua.LString(""))
+		return 1
+	}
+	if len(s) > 0 && n > maxStringLen/len(s) {
+		L.RaiseError("string too long")
+		return 0
+	}
+	L.Push(lua.LString(strings.Repeat(s, n)))
+	return 1
+}
+
+// toLua converts a host value to a Lua value
+func toLua(L *lua.LState, v interface{}, depth int) (lua.LValue, error) {
+	if depth > maxDepth {
+		return lua.LNil, errors.New("value nested too deeply")
+	}
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil, nil
+	case bool:
+		return lua.LBool(v), nil
+	case string:
+		return lua.LString(v), nil
+	case float64:
+		return lua.LNumber(v), nil
+	case float32:
+		return lua.LNumber(v), nil
+	case int:
+		return lua.LNumber(v), nil
+	case int32:
+		return lua.LNumber(v), nil
+	case int64:
+		return lua.LNumber(v), nil
+	case []string:
+		list := L.CreateTable(len(v), 0)
+		for i, s := range v {
+			list.RawSetInt(i+1, lua.LString(s))
+		}
+		return list, nil
+	case []interface{}:
+		list := L.CreateTable(len(v), 0)
+		for i, item := range v {
+			lv, err := toLua(L, item, depth+1)
+			if err != nil {
+				return lua.LNil, err
+			}
+			list.RawSetInt(i+1, lv)
+		}
+		return list, nil
+	case map[string]interface{}:
+		obj := L.CreateTable(0, len(v))
+		for k, item := range v {
+			lv, err := toLua(L, item, depth+1)
+			if err != nil {
+				return lua.LNil, err
+			}
+			obj.RawSetString(k, lv)
+		}
+		return obj, nil
+	}
+	return lua.LNil, fmt.Errorf("unsupported value type %T", v)
+}
+
+// fromLua converts a Lua value to a host value. Tables with keys 1..n become slices and
+// other tables maps. Lua can't tell an empty list from an empty object, so an empty table
+// takes the shape of hint, the value it replaces.
+func fromLua(lv lua.LValue, hint interface{}, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("value nested too deeply")
+	}
+	switch v := lv.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		return tableFromLua(v, hint, depth)
+	}
+	return nil, fmt.Errorf("unsupported Lua value %s", lv.Type())
+}
+
+func tableFromLua(t *lua.LTable, hint interface{}, depth int) (interface{}, error) {
+	count := 0
+	t.ForEach(func(_, _ lua.LValue) { count++ })
+
+	hintList, isList := hint.([]interface{})
+	if _, ok := hint.([]string); ok {
+		isList = true
+	}
+	if count == 0 && isList {
+		return []interface{}{}, nil
+	}
+
+	if count > 0 && count == t.MaxN() {
+		list := make([]interface{}, count)
+		for i := range list {
+			var itemHint interface{}
+			if i < len(hintList) {
+				itemHint = hintList[i]
+			}
+			item, err := fromLua(t.RawGetInt(i+1), itemHint, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = item
+		}
+		return list, nil
+	}
+
+	hintMap, _ := hint.(map[string]interface{})
+	obj := make(map[string]interface{}, count)
+	var err error
+	t.ForEach(func(k, v lua.LValue) {
+		if err != nil {
+			return
+		}
+		key := k.String()
+		var item interface{}
+		if item, err = fromLua(v, hintMap[key], depth+1); err == nil {
+			obj[key] = item
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// display renders a value for logs, using JSON for tables
+func display(lv lua.LValue) string {
+	if t, ok := lv.(*lua.LTable); ok {
+		if v, err := fromLua(t, nil, 0); err == nil {
+			if data, err := json.Marshal(v); err == nil {
+				return string(data)
+			}
+		}
+	}
+	return lv.String()
+}
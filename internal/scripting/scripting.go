@@ -0,0 +1,202 @@
+// Package scripting runs small sandboxed Lua scripts that admins attach to message pipeline
+// points. Scripts run on gopher-lua with only the base, string, table and math libraries
+// and a few host functions, so they have no I/O; they read and modify the values they are
+// given, write to an execution log, and are stopped when they exceed their instruction or
+// time budget.
+package scripting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+var (
+	// ErrStepLimit is returned when a script executes too many instructions
+	ErrStepLimit = errors.New("script exceeded its step limit")
+	// ErrTimeout is returned when a script runs longer than its time limit
+	ErrTimeout = errors.New("script exceeded its time limit")
+)
+
+// Default and maximum limits
+const (
+	DefaultMaxSteps = 10000
+	MaxSteps        = 1000000
+	DefaultTimeout  = 100 * time.Millisecond
+	MaxTimeout      = 2 * time.Second
+	maxLogLines     = 100
+	maxStringLen    = 64 * 1024
+	maxListLen      = 10000
+	maxDepth        = 32
+
+	chunkName = "script"
+)
+
+// Limits bounds a script run. Zero values use the defaults.
+type Limits struct {
+	MaxSteps int // Lua VM instructions
+	Timeout  time.Duration
+}
+
+func (l Limits) normalized() Limits {
+	if l.MaxSteps <= 0 {
+		l.MaxSteps = DefaultMaxSteps
+	}
+	if l.MaxSteps > MaxSteps {
+		l.MaxSteps = MaxSteps
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	if l.Timeout > MaxTimeout {
+		l.Timeout = MaxTimeout
+	}
+	return l
+}
+
+// Program is a compiled script, safe to run concurrently
+type Program struct {
+	proto *lua.FunctionProto
+}
+
+// Compile parses a script
+func Compile(src string) (*Program, error) {
+	chunk, err := parse.Parse(strings.NewReader(src), chunkName)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := lua.Compile(chunk, chunkName)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{proto: proto}, nil
+}
+
+// Result is the outcome of a script run
+type Result struct {
+	Return   interface{}   // Value passed to return, if any
+	Rejected bool          // reject() was called
+	Reason   string        // Reason given to reject()
+	Logs     []string      // Lines written with log()
+	Steps    int           // Instructions executed
+	Duration time.Duration // Wall time
+}
+
+// Run executes the program in a fresh Lua state. Globals are exposed to the script by
+// name and read back when it finishes, even when it fails, so callers see the changes in
+// the values they passed: maps are updated in place, keeping fields the script set to nil,
+// and other values are replaced in globals. Values must be nil, bool, numbers, strings,
+// slices of strings or interface{} values, or map[string]interface{}. The returned Result
+// is never nil.
+func (p *Program) Run(globals map[string]interface{}, limits Limits) (*Result, error) {
+	limits = limits.normalized()
+	result := &Result{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), limits.Timeout)
+	defer cancel()
+	budget := &stepBudget{Context: ctx, max: limits.MaxSteps}
+
+	L := newSandbox(result)
+	defer L.Close()
+
+	for name, v := range globals {
+		lv, err := toLua(L, v, 0)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", name, err)
+		}
+		L.SetGlobal(name, lv)
+	}
+
+	L.SetContext(budget)
+	start := time.Now()
+	L.Push(L.NewFunctionFromProto(p.proto))
+	err := L.PCall(0, 1, nil)
+	result.Duration = time.Since(start)
+	result.Steps = min(budget.steps, limits.MaxSteps)
+	L.RemoveContext()
+
+	if writeErr := readGlobals(L, globals); writeErr != nil && err == nil {
+		err = writeErr
+	}
+
+	switch {
+	case budget.Err() != nil:
+		return result, budget.Err()
+	case result.Rejected:
+		return result, nil
+	case err != nil:
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			return result, errors.New(apiErr.Object.String())
+		}
+		return result, err
+	}
+
+	ret, err := fromLua(L.Get(-1), nil, 0)
+	if err != nil {
+		return result, fmt.Errorf("return value: %w", err)
+	}
+	result.Return = ret
+	return result, nil
+}
+
+// readGlobals copies the script's globals back into the caller's values
+func readGlobals(L *lua.LState, globals map[string]interface{}) error {
+	for name, orig := range globals {
+		v, err := fromLua(L.GetGlobal(name), orig, 0)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		origMap, isMap := orig.(map[string]interface{})
+		newMap, ok := v.(map[string]interface{})
+		if !isMap || !ok {
+			globals[name] = v
+			continue
+		}
+		// Lua drops fields set to nil; keep them so the caller's keys stay
+		for k := range origMap {
+			origMap[k] = nil
+		}
+		for k, item := range newMap {
+			origMap[k] = item
+		}
+	}
+	return nil
+}
+
+// stepBudget stops a script after max instructions or when its deadline passes. The Lua
+// VM checks Done before every instruction, which is what the count is based on.
+type stepBudget struct {
+	context.Context
+	steps int
+	max   int
+}
+
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func (b *stepBudget) Done() <-chan struct{} {
+	b.steps++
+	if b.steps > b.max {
+		return closedChan
+	}
+	return b.Context.Done()
+}
+
+func (b *stepBudget) Err() error {
+	if b.steps > b.max {
+		return ErrStepLimit
+	}
+	if b.Context.Err() != nil {
+		return ErrTimeout
+	}
+	return nil
+}
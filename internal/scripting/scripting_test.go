@@ -0,0 +1,183 @@
+package scripting
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func run(t *testing.T, src string, globals map[string]interface{}) *Result {
+	t.Helper()
+
+	prog, err := Compile(src)
+	require.NoError(t, err)
+	result, err := prog.Run(globals, Limits{})
+	require.NoError(t, err)
+	return result
+}
+
+func TestRun_MutatesGlobals(t *testing.T) {
+	message := map[string]interface{}{"content": "hello", "type": "text"}
+	contact := map[string]interface{}{"tags": []interface{}{"vip"}, "name": "Ana", "metadata": map[string]interface{}{}}
+
+	run(t, `
+		-- Sign messages for VIP contacts
+		if message.type == "text" and contains(contact.tags, "vip") then
+			message.content = message.content .. "\n- " .. string.upper(contact.name)
+		end
+		contact.metadata = { greeted = true, score = 2 * 21 }
+		table.insert(contact.tags, "signed")
+	`, map[string]interface{}{"message": message, "contact": contact})
+
+	assert.Equal(t, "hello\n- ANA", message["content"])
+	assert.Equal(t, map[string]interface{}{"greeted": true, "score": float64(42)}, contact["metadata"])
+	assert.Equal(t, []interface{}{"vip", "signed"}, contact["tags"])
+}
+
+func TestRun_EmptyTablesKeepTheirShape(t *testing.T) {
+	contact := map[string]interface{}{"tags": []interface{}{"a"}, "metadata": map[string]interface{}{"k": "v"}, "name": "Ana"}
+
+	run(t, `
+		contact.tags = {}
+		contact.metadata = {}
+		contact.name = nil
+	`, map[string]interface{}{"contact": contact})
+
+	assert.Equal(t, []interface{}{}, contact["tags"])
+	assert.Equal(t, map[string]interface{}{}, contact["metadata"])
+	assert.Contains(t, contact, "name")
+	assert.Nil(t, contact["name"])
+}
+
+func TestRun_ControlFlowAndReturn(t *testing.T) {
+	result := run(t, `
+		local total = 0
+		local words = {}
+		for _, w in ipairs(split("a bb ccc stop dddd", " ")) do
+			if w == "stop" then break end
+			if #w ~= 2 then
+				total = total + #w
+				words[#words + 1] = w
+			end
+		end
+		log("words", words, total)
+		if total > 3 then return "big" end
+		return "small"
+	`, nil)
+
+	assert.Equal(t, "big", result.Return)
+	assert.Equal(t, []string{`words ["a","ccc"] 4`}, result.Logs)
+	assert.Positive(t, result.Steps)
+}
+
+func TestRun_Routing(t *testing.T) {
+	src := `
+		if matches(text, "(?i)refund|chargeback") then route.team = "Billing"
+		elseif string.find(string.lower(text), "bug", 1, true) then route.team = "Support"
+		else route.team = nil end
+	`
+	for text, team := range map[string]interface{}{"I want a REFUND": "Billing", "Found a bug": "Support", "hi": nil} {
+		route := map[string]interface{}{"team": nil}
+		run(t, src, map[string]interface{}{"text": text, "route": route})
+		assert.Equal(t, team, route["team"], text)
+	}
+}
+
+func TestRun_Reject(t *testing.T) {
+	message := map[string]interface{}{"content": "my password is"}
+	result := run(t, `
+		message.content = "checked"
+		if contains(message.content .. "password", "password") then reject("no passwords") end
+		log("not reached")
+	`, map[string]interface{}{"message": message})
+
+	assert.True(t, result.Rejected)
+	assert.Equal(t, "no passwords", result.Reason)
+	assert.Empty(t, result.Logs)
+	// Changes made before the rejection are kept
+	assert.Equal(t, "checked", message["content"])
+}
+
+func TestRun_HostNumbersAndStrings(t *testing.T) {
+	result := run(t, `return count + 1 + #tags`, map[string]interface{}{
+		"count": int64(2),
+		"tags":  []string{"a", "b"},
+	})
+	assert.Equal(t, float64(5), result.Return)
+}
+
+func TestRun_Limits(t *testing.T) {
+	prog, err := Compile(`local n = 0 while true do n = n + 1 end`)
+	require.NoError(t, err)
+
+	result, err := prog.Run(nil, Limits{MaxSteps: 1000})
+	assert.True(t, errors.Is(err, ErrStepLimit))
+	assert.Equal(t, 1000, result.Steps)
+
+	_, err = prog.Run(nil, Limits{MaxSteps: MaxSteps, Timeout: time.Millisecond})
+	assert.True(t, errors.Is(err, ErrTimeout))
+
+	// Catching the error doesn't let a script keep running
+	prog, err = Compile(`while true do pcall(function() while true do end end) end`)
+	require.NoError(t, err)
+	_, err = prog.Run(nil, Limits{MaxSteps: 1000})
+	assert.True(t, errors.Is(err, ErrStepLimit))
+}
+
+func TestRun_Sandbox(t *testing.T) {
+	for _, src := range []string{
+		`os.exit(1)`,
+		`io.open("/etc/passwd")`,
+		`require("os")`,
+		`load("return 1")()`,
+		`loadstring("return 1")()`,
+		`dofile("/etc/passwd")`,
+		`print("hi")`,
+		`string.dump(function() end)`,
+		`setfenv(1, {})`,
+		`coroutine.create(function() end)`,
+		`string.rep("x", 1e9)`,
+	} {
+		prog, err := Compile(src)
+		require.NoError(t, err, src)
+		_, err = prog.Run(nil, Limits{})
+		assert.Error(t, err, src)
+	}
+}
+
+func TestRun_RuntimeErrors(t *testing.T) {
+	cases := map[string]string{
+		`missing.x = 1`:                 "attempt to index",
+		`local a = {} - 1`:              "cannot perform sub operation",
+		`unknownFn()`:                   "attempt to call a non-function object",
+		`matches("a", "(")`:             "matches",
+		`error("custom failure")`:       "custom failure",
+		`local t = {} t.self = t x = t`: "",
+	}
+	for src, want := range cases {
+		prog, err := Compile(src)
+		require.NoError(t, err, src)
+		_, err = prog.Run(map[string]interface{}{"x": nil}, Limits{})
+		require.Error(t, err, src)
+		assert.Contains(t, err.Error(), want, src)
+	}
+}
+
+func TestCompile_SyntaxErrors(t *testing.T) {
+	for _, src := range []string{
+		`local = 1`,
+		`if x then`,
+		`"unterminated`,
+		`local a = {1, 2`,
+		`1 + = 2`,
+		`f(x) = 1`,
+		`--[[ open`,
+		`local a = #`,
+	} {
+		_, err := Compile(src)
+		assert.Error(t, err, src)
+	}
+}
@@ -0,0 +1,106 @@
+// Package sendwindow limits campaign sends to a daily time window in each recipient's
+// local time, so broadcast messages don't arrive in the middle of the night.
+package sendwindow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/phone"
+)
+
+// ErrInvalidWindow is returned for windows that can't be parsed
+var ErrInvalidWindow = errors.New("invalid send window")
+
+// ContactTimezoneKey is the contact metadata key holding the contact's IANA timezone
+const ContactTimezoneKey = "timezone"
+
+// Window is a daily time range, in minutes since midnight. End may be before Start
+// for windows that span midnight (e.g. 22:00-02:00). The zero Window has no limits.
+type Window struct {
+	Start int
+	End   int
+	set   bool
+}
+
+// Parse parses a window from "HH:MM" start and end times. Both empty means no window.
+func Parse(start, end string) (Window, error) {
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if start == "" && end == "" {
+		return Window{}, nil
+	}
+	if start == "" || end == "" {
+		return Window{}, fmt.Errorf("%w: both start and end are required", ErrInvalidWindow)
+	}
+	s, err := parseClock(start)
+	if err != nil {
+		return Window{}, err
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return Window{}, err
+	}
+	if s == e {
+		return Window{}, fmt.Errorf("%w: start and end must differ", ErrInvalidWindow)
+	}
+	return Window{Start: s, End: e, set: true}, nil
+}
+
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a HH:MM time", ErrInvalidWindow, v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsZero reports whether the window places no limits on sending
+func (w Window) IsZero() bool {
+	return !w.set
+}
+
+// Contains reports whether t, in loc, falls inside the window
+func (w Window) Contains(t time.Time, loc *time.Location) bool {
+	if w.IsZero() {
+		return true
+	}
+	lt := t.In(loc)
+	m := lt.Hour()*60 + lt.Minute()
+	if w.Start < w.End {
+		return m >= w.Start && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// Next returns t if it falls inside the window, otherwise the time the window next opens in loc
+func (w Window) Next(t time.Time, loc *time.Location) time.Time {
+	if w.Contains(t, loc) {
+		return t
+	}
+	lt := t.In(loc)
+	open := time.Date(lt.Year(), lt.Month(), lt.Day(), w.Start/60, w.Start%60, 0, 0, loc)
+	if !open.After(lt) {
+		open = time.Date(lt.Year(), lt.Month(), lt.Day()+1, w.Start/60, w.Start%60, 0, 0, loc)
+	}
+	return open
+}
+
+// RecipientLocation returns the timezone to use for a recipient: the contact's own
+// timezone if known, else the timezone of the number's country when it has only one,
+// else fallback.
+func RecipientLocation(phoneNumber, contactTimezone string, fallback *time.Location) *time.Location {
+	for _, name := range []string{contactTimezone, phone.Timezone(phoneNumber)} {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	if fallback == nil {
+		return time.UTC
+	}
+	return fallback
+}
@@ -0,0 +1,66 @@
+package sendwindow
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	w, err := Parse("", "")
+	require.NoError(t, err)
+	assert.True(t, w.IsZero())
+
+	w, err = Parse("09:00", "18:30")
+	require.NoError(t, err)
+	assert.Equal(t, Window{Start: 9 * 60, End: 18*60 + 30, set: true}, w)
+
+	for _, tc := range [][2]string{{"09:00", ""}, {"9am", "18:00"}, {"25:00", "18:00"}, {"10:00", "10:00"}} {
+		_, err := Parse(tc[0], tc[1])
+		assert.True(t, errors.Is(err, ErrInvalidWindow), "%v: got %v", tc, err)
+	}
+}
+
+func TestWindow_Next(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	w, err := Parse("09:00", "18:00")
+	require.NoError(t, err)
+
+	// 05:00 UTC is 10:30 in Kolkata, inside the window
+	inside := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+	assert.True(t, w.Contains(inside, kolkata))
+	assert.Equal(t, inside, w.Next(inside, kolkata))
+
+	// 01:00 UTC is 06:30 in Kolkata: wait until 09:00 the same day
+	early := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	assert.True(t, w.Next(early, kolkata).Equal(time.Date(2024, 1, 1, 9, 0, 0, 0, kolkata)))
+
+	// 14:00 UTC is 19:30 in Kolkata: wait until 09:00 the next day
+	late := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	assert.True(t, w.Next(late, kolkata).Equal(time.Date(2024, 1, 2, 9, 0, 0, 0, kolkata)))
+}
+
+func TestWindow_SpansMidnight(t *testing.T) {
+	w, err := Parse("22:00", "02:00")
+	require.NoError(t, err)
+
+	assert.True(t, w.Contains(time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC), time.UTC))
+	assert.True(t, w.Contains(time.Date(2024, 1, 2, 1, 59, 0, 0, time.UTC), time.UTC))
+	assert.False(t, w.Contains(time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC), time.UTC))
+	assert.Equal(t, time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC), w.Next(time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC), time.UTC))
+}
+
+func TestRecipientLocation(t *testing.T) {
+	fallback, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	assert.Equal(t, "America/Chicago", RecipientLocation("14155550123", "America/Chicago", fallback).String())
+	assert.Equal(t, "Asia/Kolkata", RecipientLocation("919876543210", "", fallback).String())
+	assert.Equal(t, "Asia/Kolkata", RecipientLocation("919876543210", "Not/AZone", fallback).String())
+	assert.Equal(t, "Europe/Berlin", RecipientLocation("14155550123", "", fallback).String())
+	assert.Equal(t, "UTC", RecipientLocation("14155550123", "", nil).String())
+}
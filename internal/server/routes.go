@@ -0,0 +1,638 @@
+package server
+
+import (
+	"github.com/shridarpatil/whatomate/internal/featureflags"
+	"github.com/shridarpatil/whatomate/internal/frontend"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/zerodha/fastglue"
+	"github.com/zerodha/logf"
+)
+
+// apiKeyScopePaths lists the path prefixes each limited API key scope may call
+var apiKeyScopePaths = map[models.APIKeyScope][]string{
+	models.APIKeyScopeTransactional: {"/api/v1/"},
+}
+
+// impersonationBlockedPaths can't be called with an impersonation token, so a session
+// can't grant itself consent, start another session or create lasting access such as new
+// users or wider roles. Role changes through PUT /api/users/{id} are refused by UpdateUser.
+var impersonationBlockedPaths = []string{
+	"/api/impersonations",
+	"/api/me/impersonation-requests",
+	"/api/me/password",
+	"/api/api-keys",
+	"/api/auth/switch-org",
+	"POST /api/users",
+	"POST /api/roles",
+	"PUT /api/roles",
+	"DELETE /api/roles",
+	"PUT /api/organization-members",
+	"POST /api/organization-invites",
+	"POST /api/me/organization-invites",
+}
+
+func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePath string) {
+	// Health check
+	g.GET("/health", app.HealthCheck)
+	g.GET("/ready", app.ReadyCheck)
+	g.GET("/.well-known/security.txt", app.SecurityTxt)
+
+	// Auth routes (public)
+	g.POST("/api/auth/login", app.Login)
+	g.POST("/api/auth/register", app.Register)
+	g.POST("/api/auth/refresh", app.RefreshToken)
+	g.POST("/api/auth/forgot-password", app.ForgotPassword)
+	g.POST("/api/auth/reset-password", app.ResetPassword)
+
+	// SSO routes (public)
+	g.GET("/api/auth/sso/providers", app.GetPublicSSOProviders)
+	g.GET("/api/auth/sso/{provider}/init", app.InitSSO)
+	g.GET("/api/auth/sso/{provider}/callback", app.CallbackSSO)
+	g.GET("/api/integrations/google-sheets/callback", app.GoogleSheetsCallback)
+	g.GET("/api/integrations/crm/callback", app.CRMCallback)
+	g.GET("/api/me/integrations/calendar/callback", app.CalendarCallback)
+
+	// Webhook routes (public - for Meta)
+	g.GET("/api/webhook", app.WebhookVerify)
+	g.POST("/api/webhook", app.WebhookHandler)
+
+	// WebSocket route (auth handled in handler via query param)
+	g.GET("/ws", app.WebSocketHandler)
+
+	// Short link redirects (public - tracked clicks)
+	g.GET("/l/{code}", app.ShortLinkRedirect)
+
+	// For protected routes, we'll use a path-based middleware approach
+	// Apply auth middleware globally but check path in the middleware
+	g.Before(func(r *fastglue.Request) *fastglue.Request {
+		// Skip auth for OPTIONS preflight requests (handled by CORS middleware)
+		if string(r.RequestCtx.Method()) == "OPTIONS" {
+			return r
+		}
+		path := string(r.RequestCtx.Path())
+		// Skip auth for public routes
+		if path == "/health" || path == "/ready" ||
+			path == "/api/auth/login" || path == "/api/auth/register" || path == "/api/auth/refresh" ||
+			path == "/api/auth/forgot-password" || path == "/api/auth/reset-password" ||
+			path == "/api/webhook" || path == "/ws" {
+			return r
+		}
+		// Skip auth for the Google Sheets OAuth callback (validated via state token)
+		if path == "/api/integrations/google-sheets/callback" {
+			return r
+		}
+		// Skip auth for the CRM OAuth callback (validated via state token)
+		if path == "/api/integrations/crm/callback" {
+			return r
+		}
+		// Skip auth for the calendar OAuth callback (validated via state token)
+		if path == "/api/me/integrations/calendar/callback" {
+			return r
+		}
+		// Skip auth for SSO routes (they handle their own auth via state tokens)
+		if len(path) >= 13 && path[:13] == "/api/auth/sso" {
+			return r
+		}
+		// Skip auth for custom action redirects (uses one-time token)
+		if len(path) >= 28 && path[:28] == "/api/custom-actions/redirect" {
+			return r
+		}
+		// Skip auth for analytics export downloads (uses a time-limited token)
+		if len(path) >= 32 && path[:32] == "/api/analytics/exports/download/" {
+			return r
+		}
+		// Skip auth for published dashboards (the path token identifies the dashboard)
+		if len(path) >= 23 && path[:23] == "/api/public/dashboards/" {
+			return r
+		}
+		// Skip auth for inbound gateway emails (the path token identifies the gateway)
+		if len(path) >= 28 && path[:28] == "/api/email-gateways/inbound/" {
+			return r
+		}
+		// Apply auth for all other /api routes (supports both JWT and API key),
+		// then the user's membership of the organization, organization suspension, the organization's IP allowlist, impersonation
+		// limits, the API key's scope and its daily quota
+		if len(path) > 4 && path[:4] == "/api" {
+			if r = middleware.AuthWithDB(app.Config.JWT.Secret, app.DB)(r); r == nil {
+				return nil
+			}
+			if r = middleware.RequireOrganizationMember(app.IsOrganizationMember)(r); r == nil {
+				return nil
+			}
+			if r = middleware.RejectSuspendedOrganization(app.IsOrganizationSuspended)(r); r == nil {
+				return nil
+			}
+			if r = middleware.RequireAllowedIP(app.CheckIPAccess)(r); r == nil {
+				return nil
+			}
+			if r = middleware.RestrictImpersonation(app.CheckImpersonation, impersonationBlockedPaths)(r); r == nil {
+				return nil
+			}
+			if r = middleware.RestrictAPIKeyScope(apiKeyScopePaths)(r); r == nil {
+				return nil
+			}
+			return middleware.TrackAPIUsage(app.TrackAPIUsage)(r)
+		}
+		return r
+	})
+
+	// Role-based access control middleware
+	g.Before(func(r *fastglue.Request) *fastglue.Request {
+		method := string(r.RequestCtx.Method())
+
+		// Skip OPTIONS preflight requests
+		if method == "OPTIONS" {
+			return r
+		}
+
+		// Route-level permission checks are now handled at the handler level
+		// using the granular permission system (HasPermission checks)
+		return r
+	})
+
+	// Feature-gated modules
+	g.Before(middleware.RequireFeature(app.IsFeatureEnabled, map[string]string{
+		"/api/catalogs": featureflags.Commerce,
+		"/api/products": featureflags.Commerce,
+	}))
+
+	// Current User (all authenticated users)
+	g.GET("/api/me", app.GetCurrentUser)
+	g.GET("/api/me/organizations", app.ListMyOrganizations)
+	g.POST("/api/auth/switch-org", app.SwitchOrganization)
+	g.GET("/api/me/organization-invites", app.ListMyOrganizationInvites)
+	g.POST("/api/me/organization-invites/{id}/accept", app.AcceptOrganizationInvite)
+	g.DELETE("/api/me/organization-invites/{id}", app.DeclineOrganizationInvite)
+	g.PUT("/api/me/settings", app.UpdateCurrentUserSettings)
+	g.PUT("/api/me/password", app.ChangePassword)
+	g.PUT("/api/me/availability", app.UpdateAvailability)
+	g.PUT("/api/me/signature", app.UpdateMySignature)
+	g.POST("/api/me/signature/preview", app.PreviewSignature)
+	g.PUT("/api/me/away-message", app.UpdateMyAwayMessage)
+	g.GET("/api/me/shifts", app.GetMyShifts)
+	g.GET("/api/me/integrations", app.GetMyIntegrations)
+	g.POST("/api/me/integrations/calendar/{provider}/connect", app.ConnectCalendar)
+	g.DELETE("/api/me/integrations/calendar", app.DisconnectCalendar)
+	g.DELETE("/api/me/impersonation", app.EndImpersonation)
+	g.GET("/api/me/impersonation-requests", app.ListImpersonationRequests)
+	g.POST("/api/me/impersonation-requests/{id}/approve", app.ApproveImpersonationRequest)
+	g.POST("/api/me/impersonation-requests/{id}/deny", app.DenyImpersonationRequest)
+
+	// Impersonation (super admin only)
+	g.GET("/api/impersonations", app.ListImpersonations)
+	g.POST("/api/impersonations", app.CreateImpersonation)
+	g.POST("/api/impersonations/{id}/start", app.StartImpersonation)
+
+	// User Management (admin only - enforced by middleware)
+	g.GET("/api/users", app.ListUsers)
+	g.POST("/api/users", app.CreateUser)
+	g.POST("/api/users/import", app.ImportUsers)
+	g.GET("/api/users/{id}", app.GetUser)
+	g.PUT("/api/users/{id}", app.UpdateUser)
+	g.DELETE("/api/users/{id}", app.DeleteUser)
+	g.PUT("/api/users/{id}/shifts", app.UpdateUserShifts)
+
+	// Users from other organizations (admin only - enforced by middleware)
+	g.GET("/api/organization-members", app.ListOrganizationMembers)
+	g.PUT("/api/organization-members/{id}", app.UpdateOrganizationMember)
+	g.DELETE("/api/organization-members/{id}", app.RemoveOrganizationMember)
+	g.GET("/api/organization-invites", app.ListOrganizationInvites)
+	g.POST("/api/organization-invites", app.InviteOrganizationMember)
+	g.DELETE("/api/organization-invites/{id}", app.RevokeOrganizationInvite)
+
+	// Roles & Permissions (admin only - enforced by middleware)
+	g.GET("/api/roles", app.ListRoles)
+	g.POST("/api/roles", app.CreateRole)
+	g.GET("/api/roles/{id}", app.GetRole)
+	g.PUT("/api/roles/{id}", app.UpdateRole)
+	g.DELETE("/api/roles/{id}", app.DeleteRole)
+	g.GET("/api/permissions", app.ListPermissions)
+
+	// API Keys (admin only - enforced by middleware)
+	g.GET("/api/api-keys", app.ListAPIKeys)
+	g.POST("/api/api-keys", app.CreateAPIKey)
+	g.PUT("/api/api-keys/{id}", app.UpdateAPIKey)
+	g.DELETE("/api/api-keys/{id}", app.DeleteAPIKey)
+
+	// Accounts
+	g.GET("/api/accounts", app.ListAccounts)
+	g.POST("/api/accounts", app.CreateAccount)
+	g.GET("/api/accounts/{id}", app.GetAccount)
+	g.PUT("/api/accounts/{id}", app.UpdateAccount)
+	g.DELETE("/api/accounts/{id}", app.DeleteAccount)
+	g.POST("/api/accounts/{id}/test", app.TestAccountConnection)
+	g.GET("/api/accounts/{id}/health", app.GetAccountHealth)
+	g.GET("/api/accounts/{id}/profile", app.GetBusinessProfile)
+	g.PUT("/api/accounts/{id}/profile", app.UpdateBusinessProfile)
+	g.POST("/api/accounts/{id}/profile/photo", app.UploadBusinessProfilePhoto)
+	g.GET("/api/accounts/{id}/commerce-settings", app.GetCommerceSettings)
+	g.PUT("/api/accounts/{id}/commerce-settings", app.UpdateCommerceSettings)
+	g.GET("/api/accounts/{id}/registration", app.GetPhoneNumberRegistration)
+	g.POST("/api/accounts/{id}/register", app.RegisterPhoneNumber)
+	g.POST("/api/accounts/{id}/deregister", app.DeregisterPhoneNumber)
+	g.PUT("/api/accounts/{id}/two-step-pin", app.SetTwoStepPIN)
+	g.GET("/api/accounts/{id}/subscription", app.GetWebhookSubscription)
+	g.POST("/api/accounts/{id}/subscription", app.SubscribeWebhooks)
+	g.DELETE("/api/accounts/{id}/subscription", app.UnsubscribeWebhooks)
+
+	// Search
+	g.GET("/api/search", app.Search)
+
+	// Contacts
+	g.GET("/api/contacts", app.ListContacts)
+	g.POST("/api/contacts", app.CreateContact)
+	g.POST("/api/contacts/import", app.ImportContacts)
+	g.GET("/api/contacts/imports/{id}", app.GetContactImport)
+	g.POST("/api/contacts/bulk", app.BulkUpdateContacts)
+	g.GET("/api/contacts/bulk/{id}", app.GetContactBulkUpdate)
+	g.GET("/api/contacts/export", app.ExportContacts)
+	g.GET("/api/contacts/{id}", app.GetContact)
+	g.PUT("/api/contacts/{id}", app.UpdateContact)
+	g.DELETE("/api/contacts/{id}", app.DeleteContact)
+	g.GET("/api/contacts/{id}/avatar", app.GetContactAvatar)
+	g.PUT("/api/contacts/{id}/assign", app.AssignContact)
+	g.PUT("/api/contacts/{id}/priority", app.SetConversationPriority)
+	g.GET("/api/contacts/{id}/session-data", app.GetContactSessionData)
+	g.GET("/api/contacts/{id}/memory", app.GetContactMemory)
+	g.PUT("/api/contacts/{id}/memory", app.UpdateContactMemory)
+	g.DELETE("/api/contacts/{id}/memory", app.DeleteContactMemory)
+	g.PUT("/api/contacts/{id}/lifecycle", app.UpdateContactLifecycle)
+	g.POST("/api/contacts/{id}/enrich", app.EnrichContact)
+	g.GET("/api/contacts/{id}/consents", app.ListContactConsents)
+	g.POST("/api/contacts/{id}/consents", app.CreateContactConsent)
+	g.POST("/api/contacts/{id}/consents/{consent_id}/revoke", app.RevokeContactConsent)
+	g.GET("/api/consents/export", app.ExportConsents)
+	g.POST("/api/contacts/{id}/opt-out", app.OptOutContact)
+	g.DELETE("/api/contacts/{id}/opt-out", app.OptInContact)
+	g.GET("/api/opt-outs", app.ListOptOuts)
+
+	// Segments
+	g.GET("/api/segments", app.ListSegments)
+	g.POST("/api/segments", app.CreateSegment)
+	g.POST("/api/segments/preview", app.PreviewSegment)
+	g.GET("/api/segments/{id}", app.GetSegment)
+	g.PUT("/api/segments/{id}", app.UpdateSegment)
+	g.DELETE("/api/segments/{id}", app.DeleteSegment)
+	g.GET("/api/segments/{id}/contacts", app.GetSegmentContacts)
+
+	// Messages
+	g.GET("/api/contacts/{id}/messages", app.GetMessages)
+	g.GET("/api/contacts/{id}/messages/archived", app.GetArchivedMessages)
+	g.GET("/api/contacts/{id}/outbox", app.GetConversationQueue)
+	g.POST("/api/contacts/{id}/messages", app.SendMessage)
+	g.POST("/api/contacts/{id}/messages/{message_id}/reaction", app.SendReaction)
+	g.POST("/api/messages", app.SendMessage) // Legacy route
+	g.POST("/api/messages/template", app.SendTemplateMessage)
+	g.POST("/api/messages/template/bulk", app.SendBulkTemplate)
+	g.GET("/api/messages/template/bulk/{id}", app.GetBulkTemplateBatch)
+	g.POST("/api/messages/media", app.SendMediaMessage)
+	g.PUT("/api/messages/{id}/read", app.MarkMessageRead)
+	g.POST("/api/messages/{id}/retry", app.RetryMessage)
+	g.POST("/api/messages/{id}/cancel", app.CancelMessage)
+	g.GET("/api/messages/{id}/clicks", app.GetMessageButtonClicks)
+
+	// Transactional messaging API (also the only routes transactional-scope API keys may call)
+	g.POST("/api/v1/send", app.TransactionalSend)
+	g.POST("/api/v1/verifications", app.StartVerification)
+	g.POST("/api/v1/verifications/check", app.CheckVerification)
+
+	// Message Approvals (outbound messages held for supervisor review)
+	g.GET("/api/message-approvals", app.ListMessageApprovals)
+	g.GET("/api/message-approvals/stats", app.GetMessageApprovalStats)
+	g.POST("/api/message-approvals/{id}/approve", app.ApproveMessage)
+	g.POST("/api/message-approvals/{id}/reject", app.RejectMessage)
+
+	// Media (serves media files for messages, auth-protected)
+	g.GET("/api/media/{message_id}", app.ServeMedia)
+	g.POST("/api/media/repair", app.RepairMedia)
+
+	// Templates
+	g.GET("/api/templates", app.ListTemplates)
+	g.POST("/api/templates", app.CreateTemplate)
+	g.GET("/api/templates/{id}", app.GetTemplate)
+	g.GET("/api/templates/{id}/preview", app.PreviewTemplate)
+	g.PUT("/api/templates/{id}", app.UpdateTemplate)
+	g.DELETE("/api/templates/{id}", app.DeleteTemplate)
+	g.POST("/api/templates/sync", app.SyncTemplates)
+	g.GET("/api/templates/cleanup-suggestions", app.GetTemplateCleanupSuggestions)
+	g.POST("/api/templates/archive", app.ArchiveTemplates)
+	g.POST("/api/templates/unarchive", app.UnarchiveTemplates)
+	g.POST("/api/templates/{id}/publish", app.SubmitTemplate)
+	g.POST("/api/templates/upload-media", app.UploadTemplateMedia)
+
+	// WhatsApp Flows
+	g.GET("/api/flows", app.ListFlows)
+	g.POST("/api/flows", app.CreateFlow)
+	g.GET("/api/flows/{id}", app.GetFlow)
+	g.PUT("/api/flows/{id}", app.UpdateFlow)
+	g.DELETE("/api/flows/{id}", app.DeleteFlow)
+	g.POST("/api/flows/{id}/save-to-meta", app.SaveFlowToMeta)
+	g.POST("/api/flows/{id}/publish", app.PublishFlow)
+	g.POST("/api/flows/{id}/deprecate", app.DeprecateFlow)
+	g.POST("/api/flows/{id}/duplicate", app.DuplicateFlow)
+	g.POST("/api/flows/sync", app.SyncFlows)
+
+	// Bulk Campaigns
+	g.GET("/api/campaigns", app.ListCampaigns)
+	g.POST("/api/campaigns", app.CreateCampaign)
+	g.GET("/api/campaigns/{id}", app.GetCampaign)
+	g.PUT("/api/campaigns/{id}", app.UpdateCampaign)
+	g.DELETE("/api/campaigns/{id}", app.DeleteCampaign)
+	g.POST("/api/campaigns/{id}/start", app.StartCampaign)
+	g.POST("/api/campaigns/{id}/pause", app.PauseCampaign)
+	g.POST("/api/campaigns/{id}/cancel", app.CancelCampaign)
+	g.POST("/api/campaigns/{id}/retry-failed", app.RetryFailed)
+	g.GET("/api/campaigns/{id}/progress", app.GetCampaign)
+	g.POST("/api/campaigns/{id}/recipients/import", app.ImportRecipients)
+	g.POST("/api/campaigns/{id}/recipients/import/csv", app.ImportRecipientsCSV)
+	g.GET("/api/campaigns/{id}/recipients/imports/{importId}", app.GetRecipientImport)
+	g.POST("/api/campaigns/{id}/recipients/segment", app.ImportSegmentRecipients)
+	g.PUT("/api/campaigns/{id}/sheet", app.SetCampaignSheet)
+	g.POST("/api/campaigns/{id}/sheet/sync", app.SyncCampaignSheet)
+	g.DELETE("/api/campaigns/{id}/sheet", app.UnlinkCampaignSheet)
+	g.GET("/api/campaigns/{id}/recipients", app.GetCampaignRecipients)
+	g.GET("/api/campaigns/{id}/recipients/{recipientId}", app.GetCampaignRecipientTimeline)
+	g.DELETE("/api/campaigns/{id}/recipients/{recipientId}", app.DeleteCampaignRecipient)
+	g.POST("/api/campaigns/{id}/media", app.UploadCampaignMedia)
+	g.GET("/api/campaigns/{id}/media", app.ServeCampaignMedia)
+	g.GET("/api/campaigns/{id}/links", app.GetCampaignLinkStats)
+	g.GET("/api/campaigns/{id}/estimate", app.GetCampaignEstimate)
+	g.PUT("/api/campaigns/{id}/budget", app.UpdateCampaignBudget)
+
+	// Drip sequences
+	g.GET("/api/sequences", app.ListSequences)
+	g.POST("/api/sequences", app.CreateSequence)
+	g.GET("/api/sequences/{id}", app.GetSequence)
+	g.PUT("/api/sequences/{id}", app.UpdateSequence)
+	g.DELETE("/api/sequences/{id}", app.DeleteSequence)
+	g.POST("/api/sequences/{id}/activate", app.ActivateSequence)
+	g.POST("/api/sequences/{id}/pause", app.PauseSequence)
+	g.GET("/api/sequences/{id}/enrollments", app.ListSequenceEnrollments)
+	g.POST("/api/sequences/{id}/enrollments", app.EnrollSequenceContacts)
+	g.DELETE("/api/sequences/{id}/enrollments/{enrollment_id}", app.UnenrollSequenceContact)
+
+	// Short Links
+	g.GET("/api/short-links", app.ListShortLinks)
+	g.POST("/api/short-links", app.CreateShortLink)
+
+	// Chatbot Settings
+	g.GET("/api/chatbot/settings", app.GetChatbotSettings)
+	g.PUT("/api/chatbot/settings", app.UpdateChatbotSettings)
+
+	// Keyword Rules
+	g.GET("/api/chatbot/keywords", app.ListKeywordRules)
+	g.POST("/api/chatbot/keywords", app.CreateKeywordRule)
+	g.GET("/api/chatbot/keywords/{id}", app.GetKeywordRule)
+	g.PUT("/api/chatbot/keywords/{id}", app.UpdateKeywordRule)
+	g.DELETE("/api/chatbot/keywords/{id}", app.DeleteKeywordRule)
+
+	// Chatbot Flows
+	g.GET("/api/chatbot/flows", app.ListChatbotFlows)
+	g.POST("/api/chatbot/flows", app.CreateChatbotFlow)
+	g.GET("/api/chatbot/flows/{id}", app.GetChatbotFlow)
+	g.PUT("/api/chatbot/flows/{id}", app.UpdateChatbotFlow)
+	g.DELETE("/api/chatbot/flows/{id}", app.DeleteChatbotFlow)
+	g.GET("/api/chatbot/flows/{id}/versions", app.ListChatbotFlowVersions)
+	g.GET("/api/chatbot/flows/{id}/versions/{version}", app.GetChatbotFlowVersion)
+	g.POST("/api/chatbot/flows/{id}/versions/{version}/rollback", app.RollbackChatbotFlow)
+	g.POST("/api/chatbot/flows/{id}/publish", app.PublishChatbotFlow)
+	g.DELETE("/api/chatbot/flows/{id}/draft", app.DiscardChatbotFlowDraft)
+	g.POST("/api/chatbot/flows/{id}/simulate", app.SimulateChatbotFlow)
+
+	// AI Contexts
+	g.GET("/api/chatbot/ai-contexts", app.ListAIContexts)
+	g.POST("/api/chatbot/ai-contexts", app.CreateAIContext)
+	g.GET("/api/chatbot/ai-contexts/{id}", app.GetAIContext)
+	g.PUT("/api/chatbot/ai-contexts/{id}", app.UpdateAIContext)
+	g.DELETE("/api/chatbot/ai-contexts/{id}", app.DeleteAIContext)
+
+	// Chatbot Variables
+	g.GET("/api/chatbot/variables", app.ListChatbotVariables)
+	g.POST("/api/chatbot/variables", app.CreateChatbotVariable)
+	g.PUT("/api/chatbot/variables/{id}", app.UpdateChatbotVariable)
+	g.DELETE("/api/chatbot/variables/{id}", app.DeleteChatbotVariable)
+
+	// Unanswered Questions
+	g.GET("/api/chatbot/unanswered-questions", app.GetUnansweredQuestions)
+	g.POST("/api/chatbot/unanswered-questions/resolve", app.ResolveUnansweredQuestion)
+
+	// Agent Transfers
+	g.GET("/api/chatbot/transfers", app.ListAgentTransfers)
+	g.POST("/api/chatbot/transfers", app.CreateAgentTransfer)
+	g.POST("/api/chatbot/transfers/pick", app.PickNextTransfer)
+	g.PUT("/api/chatbot/transfers/{id}/resume", app.ResumeFromTransfer)
+	g.PUT("/api/chatbot/transfers/{id}/assign", app.AssignAgentTransfer)
+	g.POST("/api/chatbot/transfers/{id}/accept", app.AcceptTransferAssignment)
+	g.POST("/api/chatbot/transfers/{id}/decline", app.DeclineTransferAssignment)
+
+	// Wrap-up codes (managed with chatbot settings - access control in handler)
+	g.GET("/api/wrap-up-codes", app.ListWrapUpCodes)
+	g.POST("/api/wrap-up-codes", app.CreateWrapUpCode)
+	g.PUT("/api/wrap-up-codes/{id}", app.UpdateWrapUpCode)
+	g.DELETE("/api/wrap-up-codes/{id}", app.DeleteWrapUpCode)
+
+	// Teams (admin/manager - access control in handler)
+	g.GET("/api/teams", app.ListTeams)
+	g.POST("/api/teams", app.CreateTeam)
+	g.POST("/api/teams/members/bulk", app.BulkUpdateTeamMembers)
+	g.GET("/api/teams/{id}", app.GetTeam)
+	g.PUT("/api/teams/{id}", app.UpdateTeam)
+	g.DELETE("/api/teams/{id}", app.DeleteTeam)
+	g.GET("/api/teams/{id}/members", app.ListTeamMembers)
+	g.POST("/api/teams/{id}/members", app.AddTeamMember)
+	g.DELETE("/api/teams/{id}/members/{user_id}", app.RemoveTeamMember)
+	g.PUT("/api/teams/{id}/shifts", app.UpdateTeamShifts)
+
+	// Agent shifts
+	g.GET("/api/shifts", app.ListShifts)
+	g.GET("/api/shifts/coverage", app.GetShiftCoverage)
+
+	// Canned Responses
+	g.GET("/api/canned-responses", app.ListCannedResponses)
+	g.POST("/api/canned-responses", app.CreateCannedResponse)
+	g.GET("/api/canned-responses/search", app.SearchCannedResponses)
+	g.GET("/api/canned-responses/{id}", app.GetCannedResponse)
+	g.PUT("/api/canned-responses/{id}", app.UpdateCannedResponse)
+	g.DELETE("/api/canned-responses/{id}", app.DeleteCannedResponse)
+	g.POST("/api/canned-responses/{id}/use", app.IncrementCannedResponseUsage)
+
+	// Sessions (admin/debug)
+	g.GET("/api/chatbot/sessions", app.ListChatbotSessions)
+	g.GET("/api/chatbot/sessions/{id}", app.GetChatbotSession)
+
+	// Analytics
+	g.GET("/api/analytics/dashboard", app.GetDashboardStats)
+	g.GET("/api/analytics/messages", app.GetMessageAnalytics)
+	g.GET("/api/analytics/chatbot", app.GetChatbotAnalytics)
+	g.GET("/api/analytics/agents", app.GetAgentAnalytics)
+	g.GET("/api/analytics/agents/{id}", app.GetAgentDetails)
+	g.GET("/api/analytics/agents/comparison", app.GetAgentComparison)
+	g.GET("/api/analytics/campaigns/compare", app.GetCampaignComparison)
+	g.GET("/api/analytics/buttons", app.GetButtonAnalytics)
+	g.GET("/api/analytics/engagement", app.GetEngagementAnalytics)
+	g.GET("/api/analytics/delivery-latency", app.GetDeliveryLatency)
+	g.GET("/api/analytics/verifications", app.GetVerificationStats)
+	g.GET("/api/analytics/wrap-up-codes", app.GetWrapUpAnalytics)
+	g.POST("/api/analytics/export", app.CreateAnalyticsExport)
+	g.GET("/api/analytics/exports", app.ListAnalyticsExports)
+	g.GET("/api/analytics/exports/{id}", app.GetAnalyticsExport)
+	g.GET("/api/analytics/exports/download/{token}", app.DownloadAnalyticsExport)
+	g.GET("/api/analytics/public-dashboards", app.ListPublicDashboards)
+	g.POST("/api/analytics/public-dashboards", app.CreatePublicDashboard)
+	g.PUT("/api/analytics/public-dashboards/{id}", app.UpdatePublicDashboard)
+	g.DELETE("/api/analytics/public-dashboards/{id}", app.DeletePublicDashboard)
+	g.POST("/api/analytics/public-dashboards/{id}/rotate-token", app.RotatePublicDashboardToken)
+	g.GET("/api/public/dashboards/{token}", app.GetPublicDashboardView)
+
+	// Meta error code catalog
+	g.GET("/api/errors/catalog", app.GetErrorCatalog)
+
+	// Organization Settings
+	g.GET("/api/org/settings", app.GetOrganizationSettings)
+	g.PUT("/api/org/settings", app.UpdateOrganizationSettings)
+	g.GET("/api/org/audit-logs", app.ListAuditLogs)
+	g.GET("/api/org/api-usage", app.GetAPIUsage)
+	g.GET("/api/org/encryption-key", app.GetEncryptionKey)
+	g.PUT("/api/org/encryption-key", app.UpdateEncryptionKey)
+	g.POST("/api/org/encryption-key/rotate", app.RotateEncryptionKey)
+
+	// Organizations (super admin only)
+	g.GET("/api/organizations", app.ListOrganizations)
+	g.GET("/api/organizations/current", app.GetCurrentOrganization)
+
+	// Plugins
+	g.GET("/api/plugins", app.ListPlugins)
+	g.PUT("/api/plugins/{name}", app.UpdatePluginConfig)
+
+	// Pipeline scripts
+	g.GET("/api/scripts", app.ListScripts)
+	g.POST("/api/scripts", app.CreateScript)
+	g.POST("/api/scripts/test", app.TestScript)
+	g.GET("/api/scripts/{id}", app.GetScript)
+	g.PUT("/api/scripts/{id}", app.UpdateScript)
+	g.DELETE("/api/scripts/{id}", app.DeleteScript)
+	g.GET("/api/scripts/{id}/executions", app.ListScriptExecutions)
+
+	// Feature Flags
+	g.GET("/api/feature-flags", app.GetFeatureFlags)
+	g.GET("/api/admin/feature-flags", app.ListAdminFeatureFlags)
+	g.PUT("/api/admin/feature-flags/{key}", app.UpdateFeatureFlag)
+	g.DELETE("/api/admin/feature-flags/{key}", app.DeleteFeatureFlag)
+	g.PUT("/api/admin/feature-flags/{key}/organizations/{org_id}", app.SetFeatureFlagOverride)
+
+	// Database pool stats (super admin only - enforced in handler)
+	g.GET("/api/admin/database/pool", app.GetDatabasePoolStats)
+	g.GET("/api/admin/queue", app.GetQueueStats)
+
+	// Instance console (super admin only - enforced in handler)
+	g.GET("/api/admin/organizations", app.ListAdminOrganizations)
+	g.POST("/api/admin/organizations/{id}/suspend", app.SuspendOrganization)
+	g.POST("/api/admin/organizations/{id}/reactivate", app.ReactivateOrganization)
+	g.PUT("/api/admin/organizations/{id}/data-region", app.SetOrganizationDataRegion)
+	g.GET("/api/admin/data-regions", app.ListDataRegions)
+	g.GET("/api/admin/health", app.GetInstanceHealth)
+	g.POST("/api/admin/maintenance-notices", app.CreateMaintenanceNotice)
+
+	// SSO Settings (admin only - enforced by middleware)
+	g.GET("/api/settings/sso", app.GetSSOSettings)
+	g.PUT("/api/settings/sso/{provider}", app.UpdateSSOProvider)
+	g.DELETE("/api/settings/sso/{provider}", app.DeleteSSOProvider)
+
+	// Email (SMTP) Settings
+	g.GET("/api/settings/smtp", app.GetSMTPSettings)
+	g.PUT("/api/settings/smtp", app.UpdateSMTPSettings)
+	g.POST("/api/settings/smtp/test", app.TestSMTPSettings)
+
+	// Google Sheets integration
+	g.GET("/api/integrations/google-sheets", app.GetGoogleSheetsSettings)
+	g.PUT("/api/integrations/google-sheets", app.UpdateGoogleSheetsSettings)
+	g.POST("/api/integrations/google-sheets/connect", app.ConnectGoogleSheets)
+	g.DELETE("/api/integrations/google-sheets", app.DisconnectGoogleSheets)
+	g.POST("/api/integrations/google-sheets/preview", app.PreviewSheet)
+
+	// CRM integrations (HubSpot, Salesforce)
+	g.GET("/api/integrations/crm", app.ListCRMConnections)
+	g.PUT("/api/integrations/crm/{provider}", app.UpdateCRMConnection)
+	g.POST("/api/integrations/crm/{provider}/connect", app.ConnectCRM)
+	g.POST("/api/integrations/crm/{provider}/sync", app.SyncCRMConnection)
+	g.DELETE("/api/integrations/crm/{provider}", app.DisconnectCRM)
+
+	// Webhooks
+	g.GET("/api/webhooks", app.ListWebhooks)
+	g.POST("/api/webhooks", app.CreateWebhook)
+	g.GET("/api/webhooks/{id}", app.GetWebhook)
+	g.PUT("/api/webhooks/{id}", app.UpdateWebhook)
+	g.DELETE("/api/webhooks/{id}", app.DeleteWebhook)
+	g.POST("/api/webhooks/{id}/test", app.TestWebhook)
+
+	// Notification Channels (Slack / Teams)
+	g.GET("/api/notification-channels", app.ListNotificationChannels)
+	g.POST("/api/notification-channels", app.CreateNotificationChannel)
+	g.PUT("/api/notification-channels/{id}", app.UpdateNotificationChannel)
+	g.DELETE("/api/notification-channels/{id}", app.DeleteNotificationChannel)
+	g.POST("/api/notification-channels/{id}/test", app.TestNotificationChannel)
+
+	// Email gateways (inbound emails to template sends)
+	g.GET("/api/email-gateways", app.ListEmailGateways)
+	g.POST("/api/email-gateways", app.CreateEmailGateway)
+	g.PUT("/api/email-gateways/{id}", app.UpdateEmailGateway)
+	g.DELETE("/api/email-gateways/{id}", app.DeleteEmailGateway)
+	g.POST("/api/email-gateways/inbound/{token}", app.ReceiveGatewayEmail)
+
+	// Contact Enrichment Providers
+	g.GET("/api/enrichment-providers", app.ListEnrichmentProviders)
+	g.POST("/api/enrichment-providers", app.CreateEnrichmentProvider)
+	g.PUT("/api/enrichment-providers/{id}", app.UpdateEnrichmentProvider)
+	g.DELETE("/api/enrichment-providers/{id}", app.DeleteEnrichmentProvider)
+
+	// Announcements
+	g.GET("/api/announcements", app.ListAnnouncements)
+	g.POST("/api/announcements/read-all", app.MarkAllAnnouncementsRead)
+	g.POST("/api/announcements/{id}/read", app.MarkAnnouncementRead)
+	g.GET("/api/announcements/manage", app.ListManagedAnnouncements)
+	g.POST("/api/announcements", app.CreateAnnouncement)
+	g.PUT("/api/announcements/{id}", app.UpdateAnnouncement)
+	g.DELETE("/api/announcements/{id}", app.DeleteAnnouncement)
+
+	// Custom Actions
+	g.GET("/api/custom-actions", app.ListCustomActions)
+	g.POST("/api/custom-actions", app.CreateCustomAction)
+	g.GET("/api/custom-actions/{id}", app.GetCustomAction)
+	g.PUT("/api/custom-actions/{id}", app.UpdateCustomAction)
+	g.DELETE("/api/custom-actions/{id}", app.DeleteCustomAction)
+	g.POST("/api/custom-actions/{id}/execute", app.ExecuteCustomAction)
+	g.GET("/api/custom-actions/redirect/{token}", app.CustomActionRedirect)
+
+	// Catalogs
+	g.GET("/api/catalogs", app.ListCatalogs)
+	g.POST("/api/catalogs", app.CreateCatalog)
+	g.GET("/api/catalogs/{id}", app.GetCatalog)
+	g.DELETE("/api/catalogs/{id}", app.DeleteCatalog)
+	g.POST("/api/catalogs/sync", app.SyncCatalogs)
+
+	// Catalog Products
+	g.GET("/api/catalogs/{id}/products", app.ListCatalogProducts)
+	g.POST("/api/catalogs/{id}/products", app.CreateCatalogProduct)
+	g.GET("/api/products/{id}", app.GetCatalogProduct)
+	g.PUT("/api/products/{id}", app.UpdateCatalogProduct)
+	g.DELETE("/api/products/{id}", app.DeleteCatalogProduct)
+
+	// Serve embedded frontend (SPA)
+	if frontend.IsEmbedded() {
+		lo.Info("Serving embedded frontend", "base_path", basePath)
+		frontendHandler := frontend.Handler(basePath)
+		// Catch-all for frontend routes
+		g.GET("/{path:*}", func(r *fastglue.Request) error {
+			frontendHandler(r.RequestCtx)
+			return nil
+		})
+		g.GET("/", func(r *fastglue.Request) error {
+			frontendHandler(r.RequestCtx)
+			return nil
+		})
+	} else {
+		lo.Info("Frontend not embedded, API-only mode")
+	}
+}
@@ -0,0 +1,130 @@
+// Package server assembles the HTTP server: middleware, routes and the fasthttp
+// wrappers for CORS and request body limits.
+package server
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/frontend"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"github.com/zerodha/logf"
+)
+
+// New creates the HTTP server for app. The caller starts it with ListenAndServe or Serve.
+func New(app *handlers.App, lo logf.Logger) *fasthttp.Server {
+	cfg := app.Config
+	g := fastglue.NewGlue()
+
+	// Setup middleware (CORS is handled by corsWrapper at fasthttp level)
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		lo.Fatal("Invalid server.trusted_proxies", "error", err)
+	}
+
+	g.Before(middleware.RequestLogger(lo))
+	g.Before(middleware.Recovery(lo))
+	g.Before(middleware.ClientIPResolver(trustedProxies))
+	if !cfg.Security.DisableHeaders {
+		csp := cfg.Security.ContentSecurityPolicy
+		if csp == "" {
+			csp = middleware.DefaultContentSecurityPolicy(frontend.InlineScriptHashes(cfg.Server.BasePath), cfg.Security.FrameOptions)
+		}
+		g.Before(middleware.SecurityHeaders(middleware.SecurityHeadersConfig{
+			ContentSecurityPolicy: csp,
+			HSTSMaxAge:            cfg.Security.HSTSMaxAge,
+			FrameOptions:          cfg.Security.FrameOptions,
+		}))
+	}
+
+	// Setup routes
+	setupRoutes(g, app, lo, cfg.Server.BasePath)
+
+	// Create server with CORS wrapper
+	// Bodies are streamed so media uploads never sit in memory; everything else is
+	// held to max_body_size by bodyLimitWrapper
+	maxBodySize := cfg.Server.MaxBodySize << 20
+	return &fasthttp.Server{
+		Handler:            corsWrapper(bodyLimitWrapper(g.Handler(), maxBodySize)),
+		ReadTimeout:        time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout:       time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		Name:               "Whatomate",
+		MaxRequestBodySize: maxBodySize,
+		StreamRequestBody:  true,
+	}
+}
+
+// streamingUploadPaths are routes that read the request body as a stream and
+// enforce their own size limits
+var streamingUploadPaths = map[string]bool{
+	"/api/messages/media":  true,
+	"/api/contacts/import": true,
+}
+
+// isStreamingUpload reports whether path is a streaming upload route, including the
+// campaign recipient CSV import whose path holds the campaign ID
+func isStreamingUpload(path string) bool {
+	if streamingUploadPaths[path] {
+		return true
+	}
+	return strings.HasPrefix(path, "/api/campaigns/") && strings.HasSuffix(path, "/recipients/import/csv")
+}
+
+// bodyLimitWrapper rejects request bodies over maxSize, except on streaming upload
+// routes. With StreamRequestBody enabled fasthttp no longer enforces the limit itself.
+func bodyLimitWrapper(next fasthttp.RequestHandler, maxSize int) fasthttp.RequestHandler {
+	tooLarge := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusRequestEntityTooLarge)
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"status":"error","message":"Request body too large"}`)
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		if isStreamingUpload(string(ctx.Path())) {
+			next(ctx)
+			return
+		}
+		if ctx.Request.Header.ContentLength() > maxSize {
+			tooLarge(ctx)
+			return
+		}
+		// Chunked bodies have no length up front, so read them with a cap
+		if stream := ctx.RequestBodyStream(); stream != nil && ctx.Request.Header.ContentLength() < 0 {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(maxSize)+1))
+			if err != nil || len(body) > maxSize {
+				tooLarge(ctx)
+				return
+			}
+			ctx.Request.SetBody(body)
+		}
+		next(ctx)
+	}
+}
+
+// corsWrapper wraps a handler with CORS support at the fasthttp level
+// This ensures CORS headers are set even for auto-handled OPTIONS requests
+func corsWrapper(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		origin := string(ctx.Request.Header.Peek("Origin"))
+		if origin == "" {
+			origin = "*"
+		}
+
+		ctx.Response.Header.Set("Access-Control-Allow-Origin", origin)
+		ctx.Response.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
+		ctx.Response.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Requested-With, X-Organization-ID")
+		ctx.Response.Header.Set("Access-Control-Allow-Credentials", "true")
+		ctx.Response.Header.Set("Access-Control-Max-Age", "86400")
+
+		// Handle preflight OPTIONS requests
+		if string(ctx.Method()) == "OPTIONS" {
+			ctx.SetStatusCode(fasthttp.StatusNoContent)
+			return
+		}
+
+		next(ctx)
+	}
+}
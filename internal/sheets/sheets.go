@@ -0,0 +1,195 @@
+// Package sheets reads campaign recipients from Google Sheets.
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Scopes are the OAuth scopes needed to read a user's spreadsheets
+var Scopes = []string{
+	"https://www.googleapis.com/auth/spreadsheets.readonly",
+	"https://www.googleapis.com/auth/userinfo.email",
+}
+
+// BaseURL is the Google Sheets API endpoint (overridable in tests)
+var BaseURL = "https://sheets.googleapis.com/v4/spreadsheets"
+
+const (
+	// DefaultRange reads the first sheet when no range is given
+	DefaultRange = "A:Z"
+
+	// TargetPhoneNumber and TargetRecipientName are the mapping targets for the
+	// recipient fields; any other target is a template parameter name
+	TargetPhoneNumber   = "phone_number"
+	TargetRecipientName = "recipient_name"
+)
+
+// spreadsheetURLPattern extracts the ID from a docs.google.com spreadsheet URL
+var spreadsheetURLPattern = regexp.MustCompile(`/spreadsheets/d/([a-zA-Z0-9_-]+)`)
+
+// spreadsheetIDPattern matches a bare spreadsheet ID
+var spreadsheetIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{20,}$`)
+
+// ErrInvalidSpreadsheet is returned when a spreadsheet URL or ID can't be parsed
+var ErrInvalidSpreadsheet = errors.New("invalid spreadsheet URL or ID")
+
+// Mapping maps a sheet column header to a recipient field or template parameter
+type Mapping map[string]string
+
+// Recipient is a mapped sheet row
+type Recipient struct {
+	Row            int // 1-based sheet row, for error reporting
+	PhoneNumber    string
+	RecipientName  string
+	TemplateParams map[string]interface{}
+}
+
+// ParseSpreadsheetID accepts a spreadsheet URL or bare ID and returns the ID
+func ParseSpreadsheetID(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if m := spreadsheetURLPattern.FindStringSubmatch(input); m != nil {
+		return m[1], nil
+	}
+	if spreadsheetIDPattern.MatchString(input) {
+		return input, nil
+	}
+	return "", ErrInvalidSpreadsheet
+}
+
+// FetchValues reads a range of cells as strings. client must attach OAuth credentials.
+func FetchValues(ctx context.Context, client *http.Client, spreadsheetID, cellRange string) ([][]string, error) {
+	if cellRange == "" {
+		cellRange = DefaultRange
+	}
+	endpoint := fmt.Sprintf("%s/%s/values/%s?majorDimension=ROWS",
+		BaseURL, url.PathEscape(spreadsheetID), url.PathEscape(cellRange))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("google sheets: %s", apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("google sheets request failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Values [][]interface{} `json:"values"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse sheet values: %w", err)
+	}
+
+	rows := make([][]string, len(result.Values))
+	for i, row := range result.Values {
+		rows[i] = make([]string, len(row))
+		for j, cell := range row {
+			rows[i][j] = strings.TrimSpace(fmt.Sprint(cell))
+		}
+	}
+	return rows, nil
+}
+
+// Validate checks that the mapping targets a phone number column present in headers
+func (m Mapping) Validate(headers []string) error {
+	present := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		present[h] = true
+	}
+
+	hasPhone := false
+	for column, target := range m {
+		if !present[column] {
+			return fmt.Errorf("column %q not found in sheet", column)
+		}
+		if target == TargetPhoneNumber {
+			hasPhone = true
+		}
+	}
+	if !hasPhone {
+		return errors.New("a column must be mapped to phone_number")
+	}
+	return nil
+}
+
+// MapRows turns sheet rows into recipients using the first row as headers. Rows
+// without a phone number are skipped.
+func MapRows(rows [][]string, mapping Mapping) ([]Recipient, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	headers := rows[0]
+	if err := mapping.Validate(headers); err != nil {
+		return nil, err
+	}
+
+	recipients := make([]Recipient, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		rec := mapping.MapRow(headers, row, i+2)
+		if rec.PhoneNumber == "" {
+			continue
+		}
+		recipients = append(recipients, rec)
+	}
+	return recipients, nil
+}
+
+// MapRow turns one data row into a recipient. row is the 1-based row number.
+func (m Mapping) MapRow(headers, values []string, row int) Recipient {
+	rec := Recipient{Row: row, TemplateParams: make(map[string]interface{})}
+	for col, header := range headers {
+		target, ok := m[header]
+		if !ok || target == "" {
+			continue
+		}
+		value := ""
+		if col < len(values) {
+			value = values[col]
+		}
+		switch target {
+		case TargetPhoneNumber:
+			rec.PhoneNumber = value
+		case TargetRecipientName:
+			rec.RecipientName = value
+		default:
+			rec.TemplateParams[target] = value
+		}
+	}
+	return rec
+}
+
+// MappingFromJSON converts a stored JSONB mapping into a Mapping
+func MappingFromJSON(raw map[string]interface{}) Mapping {
+	mapping := make(Mapping, len(raw))
+	for column, target := range raw {
+		if s, ok := target.(string); ok && s != "" {
+			mapping[column] = s
+		}
+	}
+	return mapping
+}
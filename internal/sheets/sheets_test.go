@@ -0,0 +1,71 @@
+package sheets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpreadsheetID(t *testing.T) {
+	id, err := ParseSpreadsheetID("https://docs.google.com/spreadsheets/d/1AbC_dEf-GhIjKlMnOpQrStUvWxYz/edit#gid=0")
+	require.NoError(t, err)
+	assert.Equal(t, "1AbC_dEf-GhIjKlMnOpQrStUvWxYz", id)
+
+	id, err = ParseSpreadsheetID(" 1AbC_dEf-GhIjKlMnOpQrStUvWxYz ")
+	require.NoError(t, err)
+	assert.Equal(t, "1AbC_dEf-GhIjKlMnOpQrStUvWxYz", id)
+
+	_, err = ParseSpreadsheetID("not a sheet")
+	assert.ErrorIs(t, err, ErrInvalidSpreadsheet)
+}
+
+func TestMapRows(t *testing.T) {
+	rows := [][]string{
+		{"Phone", "Name", "Order", "Notes"},
+		{"+14155550100", "Ada", "ORD-1", "vip"},
+		{"", "Missing phone", "ORD-2"},
+		{"+14155550101", "Grace"},
+	}
+	mapping := Mapping{"Phone": TargetPhoneNumber, "Name": TargetRecipientName, "Order": "order_id"}
+
+	recipients, err := MapRows(rows, mapping)
+	require.NoError(t, err)
+	require.Len(t, recipients, 2)
+
+	assert.Equal(t, 2, recipients[0].Row)
+	assert.Equal(t, "+14155550100", recipients[0].PhoneNumber)
+	assert.Equal(t, "Ada", recipients[0].RecipientName)
+	assert.Equal(t, map[string]interface{}{"order_id": "ORD-1"}, recipients[0].TemplateParams)
+
+	// Short rows leave unmapped trailing columns empty
+	assert.Equal(t, 4, recipients[1].Row)
+	assert.Equal(t, map[string]interface{}{"order_id": ""}, recipients[1].TemplateParams)
+}
+
+func TestMapping_Validate(t *testing.T) {
+	headers := []string{"Phone", "Name"}
+
+	assert.NoError(t, Mapping{"Phone": TargetPhoneNumber}.Validate(headers))
+	assert.Error(t, Mapping{"Name": TargetRecipientName}.Validate(headers))
+	assert.Error(t, Mapping{"Mobile": TargetPhoneNumber}.Validate(headers))
+}
+
+func TestFetchValues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/sheet-id/values/Sheet1!A:C", r.URL.Path)
+		_, _ = w.Write([]byte(`{"range":"Sheet1!A1:C2","values":[["Phone","Amount"],["+14155550100",42]]}`))
+	}))
+	defer server.Close()
+
+	orig := BaseURL
+	BaseURL = server.URL
+	defer func() { BaseURL = orig }()
+
+	rows, err := FetchValues(context.Background(), server.Client(), "sheet-id", "Sheet1!A:C")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"Phone", "Amount"}, {"+14155550100", "42"}}, rows)
+}
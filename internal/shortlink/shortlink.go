@@ -0,0 +1,140 @@
+// Package shortlink wraps outbound URLs in tracked short links served by Whatomate.
+package shortlink
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	codeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	codeLength   = 8
+
+	// PathPrefix is the public route prefix short links are served under
+	PathPrefix = "/l/"
+)
+
+// urlPattern matches http(s) URLs embedded in free text
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// Owner identifies who a short link is generated for
+type Owner struct {
+	OrganizationID uuid.UUID
+	CampaignID     *uuid.UUID
+	RecipientID    *uuid.UUID
+	ContactID      *uuid.UUID
+	CreatedBy      *uuid.UUID
+
+	// Set for the link behind a message's CTA URL button, so opening it counts as a tap
+	MessageID  *uuid.UUID
+	ButtonText string
+}
+
+// GenerateCode returns a random base62 short link code
+func GenerateCode() (string, error) {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	code := make([]byte, codeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// URL builds the public URL for a short link code
+func URL(baseURL, code string) string {
+	return strings.TrimRight(baseURL, "/") + PathPrefix + code
+}
+
+// Create stores a new short link pointing at targetURL
+func Create(db *gorm.DB, owner Owner, targetURL string) (*models.ShortLink, error) {
+	var lastErr error
+	// Retry a few times in the unlikely event of a code collision
+	for attempt := 0; attempt < 3; attempt++ {
+		code, err := GenerateCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate code: %w", err)
+		}
+
+		link := &models.ShortLink{
+			BaseModel:      models.BaseModel{ID: uuid.New()},
+			OrganizationID: owner.OrganizationID,
+			Code:           code,
+			TargetURL:      targetURL,
+			CampaignID:     owner.CampaignID,
+			RecipientID:    owner.RecipientID,
+			ContactID:      owner.ContactID,
+			CreatedBy:      owner.CreatedBy,
+			MessageID:      owner.MessageID,
+			ButtonText:     owner.ButtonText,
+		}
+		if lastErr = db.Create(link).Error; lastErr == nil {
+			return link, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to create short link: %w", lastErr)
+}
+
+// WrapText replaces every URL in text with a tracked short link
+func WrapText(db *gorm.DB, baseURL string, owner Owner, text string) (string, error) {
+	if baseURL == "" || !urlPattern.MatchString(text) {
+		return text, nil
+	}
+
+	ownPrefix := URL(baseURL, "")
+	var wrapErr error
+	wrapped := urlPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if wrapErr != nil || strings.HasPrefix(match, ownPrefix) {
+			return match
+		}
+		target, trailing := splitTrailingPunctuation(match)
+		link, err := Create(db, owner, target)
+		if err != nil {
+			wrapErr = err
+			return match
+		}
+		return URL(baseURL, link.Code) + trailing
+	})
+	if wrapErr != nil {
+		return text, wrapErr
+	}
+	return wrapped, nil
+}
+
+// WrapParams returns a copy of template params with URLs in string values wrapped
+func WrapParams(db *gorm.DB, baseURL string, owner Owner, params models.JSONB) (models.JSONB, error) {
+	if params == nil {
+		return nil, nil
+	}
+
+	wrapped := make(models.JSONB, len(params))
+	for key, value := range params {
+		str, ok := value.(string)
+		if !ok {
+			wrapped[key] = value
+			continue
+		}
+		replaced, err := WrapText(db, baseURL, owner, str)
+		if err != nil {
+			return params, err
+		}
+		wrapped[key] = replaced
+	}
+	return wrapped, nil
+}
+
+// splitTrailingPunctuation separates sentence punctuation that the URL pattern swallowed
+func splitTrailingPunctuation(rawURL string) (string, string) {
+	trimmed := strings.TrimRight(rawURL, ".,;:!?)]'")
+	return trimmed, rawURL[len(trimmed):]
+}
@@ -0,0 +1,40 @@
+package shortlink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCode(t *testing.T) {
+	code, err := GenerateCode()
+	require.NoError(t, err)
+	assert.Len(t, code, codeLength)
+
+	other, err := GenerateCode()
+	require.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
+
+func TestURL(t *testing.T) {
+	assert.Equal(t, "https://wa.example.com/l/abc123", URL("https://wa.example.com/", "abc123"))
+	assert.Equal(t, "https://wa.example.com/l/abc123", URL("https://wa.example.com", "abc123"))
+}
+
+func TestSplitTrailingPunctuation(t *testing.T) {
+	target, trailing := splitTrailingPunctuation("https://example.com/offer.")
+	assert.Equal(t, "https://example.com/offer", target)
+	assert.Equal(t, ".", trailing)
+
+	target, trailing = splitTrailingPunctuation("https://example.com/offer?id=1")
+	assert.Equal(t, "https://example.com/offer?id=1", target)
+	assert.Empty(t, trailing)
+}
+
+func TestWrapText_NoURLs(t *testing.T) {
+	// No database access is needed when there is nothing to wrap
+	text, err := WrapText(nil, "https://wa.example.com", Owner{}, "Hello there")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there", text)
+}
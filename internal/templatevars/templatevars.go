@@ -0,0 +1,173 @@
+// Package templatevars binds template placeholders to contact fields, so campaign
+// recipients don't have to carry every parameter value themselves. A mapping value is
+// text that may reference the contact, such as "{{contact.name}}" or
+// "Order {{contact.custom_fields.order_id}}", and is resolved when the message is sent.
+package templatevars
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// ErrInvalidMapping is returned for mappings that reference unknown fields
+var ErrInvalidMapping = errors.New("invalid template variable mapping")
+
+// Contact fields a mapping can reference
+const (
+	FieldName         = "contact.name"
+	FieldPhoneNumber  = "contact.phone_number"
+	CustomFieldPrefix = "contact.custom_fields."
+)
+
+// referencePattern matches references like {{contact.name}} in a mapping value
+var referencePattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// Mapping maps template placeholder names to the text they are filled with
+type Mapping map[string]string
+
+// FromJSONB reads a mapping stored as JSONB, skipping values that aren't text
+func FromJSONB(data models.JSONB) Mapping {
+	mapping := make(Mapping, len(data))
+	for param, v := range data {
+		if s, ok := v.(string); ok {
+			mapping[param] = s
+		}
+	}
+	return mapping
+}
+
+// JSONB returns the mapping for storage
+func (m Mapping) JSONB() models.JSONB {
+	data := make(models.JSONB, len(m))
+	for param, value := range m {
+		data[param] = value
+	}
+	return data
+}
+
+// Normalize trims placeholder names and values, drops empty entries and checks that
+// every reference is a known contact field
+func (m Mapping) Normalize() (Mapping, error) {
+	normalized := make(Mapping, len(m))
+	for param, value := range m {
+		param, value = strings.TrimSpace(param), strings.TrimSpace(value)
+		if param == "" || value == "" {
+			continue
+		}
+		for _, match := range referencePattern.FindAllStringSubmatch(value, -1) {
+			if !validField(match[1]) {
+				return nil, fmt.Errorf("%w: unknown field %q for {{%s}}, use %s, %s or %s<name>",
+					ErrInvalidMapping, match[1], param, FieldName, FieldPhoneNumber, CustomFieldPrefix)
+			}
+		}
+		normalized[param] = value
+	}
+	return normalized, nil
+}
+
+// CheckParams returns an error when the mapping binds a placeholder the template doesn't have
+func (m Mapping) CheckParams(names []string) error {
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+	for param := range m {
+		if !known[param] {
+			return fmt.Errorf("%w: the template has no {{%s}} placeholder", ErrInvalidMapping, param)
+		}
+	}
+	return nil
+}
+
+// Resolve fills in the mapping of a placeholder for the contact. It reports false when
+// the placeholder isn't mapped or a referenced field has no value.
+func (m Mapping) Resolve(param string, contact *models.Contact) (string, bool) {
+	value, ok := m[param]
+	if !ok {
+		return "", false
+	}
+	resolved := true
+	value = referencePattern.ReplaceAllStringFunc(value, func(ref string) string {
+		text := fieldValue(referencePattern.FindStringSubmatch(ref)[1], contact)
+		if text == "" {
+			resolved = false
+		}
+		return text
+	})
+	return value, resolved
+}
+
+// Apply fills the template's placeholders from the mapping for those the recipient's own
+// parameters leave empty. Placeholders are looked up by name, then by position, the way
+// they are sent. It returns the merged parameters and the placeholders left without a
+// value.
+func Apply(names []string, params models.JSONB, m Mapping, contact *models.Contact) (models.JSONB, []string) {
+	merged := make(models.JSONB, len(params)+len(m))
+	for k, v := range params {
+		merged[k] = v
+	}
+
+	var missing []string
+	for i, name := range names {
+		if hasValue(params, name) || hasValue(params, strconv.Itoa(i+1)) {
+			continue
+		}
+		if value, ok := m.Resolve(name, contact); ok {
+			merged[name] = value
+			continue
+		}
+		missing = append(missing, name)
+	}
+	return merged, missing
+}
+
+// validField reports whether a reference names a contact field
+func validField(field string) bool {
+	if field == FieldName || field == FieldPhoneNumber {
+		return true
+	}
+	key, ok := strings.CutPrefix(field, CustomFieldPrefix)
+	return ok && strings.TrimSpace(key) != ""
+}
+
+// fieldValue returns the text of a contact field, empty without a contact
+func fieldValue(field string, contact *models.Contact) string {
+	if contact == nil {
+		return ""
+	}
+	switch field {
+	case FieldName:
+		return contact.ProfileName
+	case FieldPhoneNumber:
+		return contact.PhoneNumber
+	}
+	key, _ := strings.CutPrefix(field, CustomFieldPrefix)
+	return valueText(contact.Metadata[key])
+}
+
+// valueText returns a custom field value as text
+func valueText(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// hasValue reports whether params holds a non-empty value for key
+func hasValue(params models.JSONB, key string) bool {
+	v, ok := params[key]
+	return ok && v != nil && fmt.Sprintf("%v", v) != ""
+}
@@ -0,0 +1,71 @@
+package templatevars
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapping_Normalize(t *testing.T) {
+	mapping, err := Mapping{
+		" name ": " {{ contact.name }} ",
+		"order":  "Order {{contact.custom_fields.order_id}}",
+		"empty":  "  ",
+	}.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, Mapping{
+		"name":  "{{ contact.name }}",
+		"order": "Order {{contact.custom_fields.order_id}}",
+	}, mapping)
+
+	for _, bad := range []Mapping{
+		{"name": "{{contact.email}}"},
+		{"name": "{{contact.custom_fields.}}"},
+		{"name": "{{name}}"},
+	} {
+		_, err := bad.Normalize()
+		assert.True(t, errors.Is(err, ErrInvalidMapping), "expected ErrInvalidMapping for %v, got %v", bad, err)
+	}
+}
+
+func TestMapping_CheckParams(t *testing.T) {
+	mapping := Mapping{"name": "{{contact.name}}"}
+	assert.NoError(t, mapping.CheckParams([]string{"name", "order"}))
+	assert.True(t, errors.Is(mapping.CheckParams([]string{"1"}), ErrInvalidMapping))
+}
+
+func TestApply(t *testing.T) {
+	contact := &models.Contact{
+		PhoneNumber: "15551234567",
+		ProfileName: "Ada",
+		Metadata:    models.JSONB{"order_id": "A-42", "points": float64(120)},
+	}
+	mapping := Mapping{
+		"name":   "{{contact.name}}",
+		"order":  "#{{contact.custom_fields.order_id}}",
+		"points": "{{contact.custom_fields.points}}",
+		"code":   "{{contact.custom_fields.coupon}}",
+	}
+	names := []string{"name", "order", "points", "code", "date"}
+
+	// The recipient's own values win; mapped fields fill the rest
+	params, missing := Apply(names, models.JSONB{"name": "Ms Lovelace", "5": "Friday"}, mapping, contact)
+	assert.Equal(t, "Ms Lovelace", params["name"])
+	assert.Equal(t, "#A-42", params["order"])
+	assert.Equal(t, "120", params["points"])
+	assert.Equal(t, "Friday", params["5"])
+	assert.Equal(t, []string{"code"}, missing)
+
+	// Without a contact, contact fields can't be resolved
+	_, missing = Apply(names, nil, mapping, nil)
+	assert.Equal(t, names, missing)
+}
+
+func TestFromJSONB(t *testing.T) {
+	mapping := Mapping{"1": "{{contact.name}}"}
+	assert.Equal(t, mapping, FromJSONB(mapping.JSONB()))
+	assert.Empty(t, FromJSONB(models.JSONB{"1": 5}))
+}
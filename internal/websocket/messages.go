@@ -17,16 +17,48 @@ const (
 	TypePing          = "ping"
 	TypePong          = "pong"
 
+	// Reaction types
+	TypeReactionUpdate = "reaction_update"
+
 	// Agent transfer types
 	TypeAgentTransfer       = "agent_transfer"
 	TypeAgentTransferResume = "agent_transfer_resume"
 	TypeAgentTransferAssign = "agent_transfer_assign"
+	TypeAssignmentOffer     = "assignment_offer"
+	TypeAssignmentOfferDone = "assignment_offer_done"
+
+	// Conversation priority types
+	TypeConversationPriority = "conversation_priority"
 
 	// Campaign types
 	TypeCampaignStatsUpdate = "campaign_stats_update"
 
 	// Permission types
 	TypePermissionsUpdated = "permissions_updated"
+
+	// Message approval types
+	TypeMessageApproval         = "message_approval"
+	TypeMessageApprovalReviewed = "message_approval_reviewed"
+
+	// Announcement types
+	TypeAnnouncement        = "announcement"
+	TypeAnnouncementDeleted = "announcement_deleted"
+
+	// Analytics export types
+	TypeAnalyticsExport = "analytics_export"
+
+	// Campaign recipient import types
+	TypeRecipientImport = "recipient_import"
+
+	// Contact import types
+	TypeContactImport = "contact_import"
+
+	// Contact bulk update types
+	TypeContactBulkUpdate = "contact_bulk_update"
+
+	// Impersonation consent types
+	TypeImpersonationRequest  = "impersonation_request"
+	TypeImpersonationResponse = "impersonation_response"
 )
 
 // BroadcastMessage represents a message to be broadcast to clients
@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"gorm.io/gorm"
+)
+
+// contactBulkUpdateChunk is how many contacts are changed per transaction
+const contactBulkUpdateChunk = 500
+
+// errBulkUpdateTakenOver is returned when another worker recorded progress on the same
+// update first, e.g. after a slow job was redelivered
+var errBulkUpdateTakenOver = errors.New("bulk update is being applied by another worker")
+
+// HandleContactBulkUpdateJob applies a bulk contact update chunk by chunk. Progress is
+// recorded with each chunk, so a redelivered job resumes where the last one stopped.
+func (w *Worker) HandleContactBulkUpdateJob(ctx context.Context, job *queue.ContactBulkUpdateJob) error {
+	var update models.ContactBulkUpdate
+	if err := w.DB.Where("id = ? AND organization_id = ?", job.UpdateID, job.OrganizationID).First(&update).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			w.Log.Warn("Contact bulk update not found", "update_id", job.UpdateID)
+			return nil
+		}
+		return fmt.Errorf("failed to load contact bulk update: %w", err)
+	}
+
+	switch update.Status {
+	case models.ContactBulkUpdateStatusCompleted, models.ContactBulkUpdateStatusFailed:
+		return nil
+	case models.ContactBulkUpdateStatusQueued:
+		update.Status = models.ContactBulkUpdateStatusProcessing
+		w.DB.Model(&update).Update("status", update.Status)
+		w.publishContactBulkUpdate(ctx, &update)
+	}
+
+	ids := make([]uuid.UUID, 0, len(update.ContactIDs))
+	for _, v := range update.ContactIDs {
+		if s, ok := v.(string); ok {
+			if id, err := uuid.Parse(s); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	for update.ProcessedCount < len(ids) {
+		// Stop without acknowledging the job, so it's picked up again after a restart
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(update.ProcessedCount+contactBulkUpdateChunk, len(ids))
+		changed, err := w.applyContactBulkUpdate(&update, ids[update.ProcessedCount:end], end)
+		if errors.Is(err, errBulkUpdateTakenOver) {
+			return nil
+		}
+		if err != nil {
+			w.Log.Error("Contact bulk update failed", "error", err, "update_id", update.ID)
+			update.Status = models.ContactBulkUpdateStatusFailed
+			update.Error = "Failed to update contacts"
+			w.finishContactBulkUpdate(ctx, &update)
+			return nil
+		}
+		update.ProcessedCount = end
+		update.UpdatedCount += changed
+		w.publishContactBulkUpdate(ctx, &update)
+	}
+
+	update.Status = models.ContactBulkUpdateStatusCompleted
+	w.finishContactBulkUpdate(ctx, &update)
+	w.Log.Info("Contact bulk update completed", "update_id", update.ID, "contacts", update.TotalCount, "updated", update.UpdatedCount)
+	return nil
+}
+
+// applyContactBulkUpdate changes one chunk of contacts and records the progress in the
+// same transaction, returning how many contacts changed. Contacts deleted since the
+// update was requested are skipped.
+func (w *Worker) applyContactBulkUpdate(update *models.ContactBulkUpdate, ids []uuid.UUID, processed int) (int, error) {
+	changed := 0
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		var contacts []models.Contact
+		if err := tx.Select("id", "tags", "metadata", "assigned_user_id", "whats_app_account").
+			Where("organization_id = ? AND id IN ?", update.OrganizationID, ids).
+			Find(&contacts).Error; err != nil {
+			return err
+		}
+
+		for i := range contacts {
+			updates := contactBulkChanges(update, &contacts[i])
+			if len(updates) == 0 {
+				continue
+			}
+			if err := tx.Model(&models.Contact{}).Where("id = ?", contacts[i].ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+
+		result := tx.Model(&models.ContactBulkUpdate{}).
+			Where("id = ? AND processed_count = ?", update.ID, update.ProcessedCount).
+			Updates(map[string]interface{}{
+				"processed_count": processed,
+				"updated_count":   gorm.Expr("updated_count + ?", changed),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errBulkUpdateTakenOver
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// contactBulkChanges returns the column updates a bulk update makes to a contact, or
+// nothing if the contact already matches
+func contactBulkChanges(update *models.ContactBulkUpdate, contact *models.Contact) map[string]interface{} {
+	updates := map[string]interface{}{}
+
+	if len(update.AddTags) > 0 || len(update.RemoveTags) > 0 {
+		tags := make(models.JSONBArray, 0, len(contact.Tags)+len(update.AddTags))
+		for _, tag := range contact.Tags {
+			if !slices.Contains(update.RemoveTags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		for _, tag := range update.AddTags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		if !slices.Equal(tags, contact.Tags) {
+			updates["tags"] = tags
+		}
+	}
+
+	if len(update.CustomFields) > 0 {
+		metadata := maps.Clone(contact.Metadata)
+		if metadata == nil {
+			metadata = models.JSONB{}
+		}
+		changed := false
+		for k, v := range update.CustomFields {
+			if current, ok := metadata[k]; !ok || current != v {
+				metadata[k] = v
+				changed = true
+			}
+		}
+		if changed {
+			updates["metadata"] = metadata
+		}
+	}
+
+	if update.Assign {
+		current, target := contact.AssignedUserID, update.AssignUserID
+		if (current == nil) != (target == nil) || (current != nil && *current != *target) {
+			updates["assigned_user_id"] = target
+		}
+	}
+
+	if update.WhatsAppAccount != "" && update.WhatsAppAccount != contact.WhatsAppAccount {
+		updates["whats_app_account"] = update.WhatsAppAccount
+	}
+	return updates
+}
+
+// finishContactBulkUpdate records the final state of a bulk update and reports it
+func (w *Worker) finishContactBulkUpdate(ctx context.Context, update *models.ContactBulkUpdate) {
+	now := time.Now()
+	update.CompletedAt = &now
+	if err := w.DB.Model(update).Updates(map[string]interface{}{
+		"status":       update.Status,
+		"error":        update.Error,
+		"completed_at": now,
+	}).Error; err != nil {
+		w.Log.Error("Failed to update contact bulk update", "error", err, "update_id", update.ID)
+	}
+	w.publishContactBulkUpdate(ctx, update)
+}
+
+// publishContactBulkUpdate publishes a bulk update's progress for real-time updates
+func (w *Worker) publishContactBulkUpdate(ctx context.Context, update *models.ContactBulkUpdate) {
+	if w.Publisher == nil {
+		return
+	}
+	_ = w.Publisher.PublishContactBulkUpdate(ctx, &queue.ContactBulkUpdateProgress{
+		UpdateID:       update.ID,
+		OrganizationID: update.OrganizationID,
+		RequestedByID:  update.RequestedByID,
+		Status:         update.Status,
+		TotalCount:     update.TotalCount,
+		ProcessedCount: update.ProcessedCount,
+		UpdatedCount:   update.UpdatedCount,
+		Error:          update.Error,
+	})
+}
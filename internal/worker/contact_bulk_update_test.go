@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_HandleContactBulkUpdateJob(t *testing.T) {
+	w := testWorker(t)
+	org, account, _, campaign, _ := createTestCampaignData(t, w)
+	agentID := campaign.CreatedBy
+
+	gold := &models.Contact{
+		OrganizationID:  org.ID,
+		PhoneNumber:     "1415555" + uuid.New().String()[:4],
+		Tags:            models.JSONBArray{"vip"},
+		Metadata:        models.JSONB{"city": "Pune"},
+		WhatsAppAccount: account.Name,
+		AssignedUserID:  &agentID,
+	}
+	plain := &models.Contact{
+		OrganizationID: org.ID,
+		PhoneNumber:    "1415556" + uuid.New().String()[:4],
+		Tags:           models.JSONBArray{"trial"},
+		Metadata:       models.JSONB{},
+	}
+	require.NoError(t, w.DB.Create(gold).Error)
+	require.NoError(t, w.DB.Create(plain).Error)
+
+	update := &models.ContactBulkUpdate{
+		OrganizationID:  org.ID,
+		RequestedByID:   agentID,
+		AddTags:         models.JSONBArray{"gold"},
+		RemoveTags:      models.JSONBArray{"trial"},
+		CustomFields:    models.JSONB{"tier": "gold"},
+		Assign:          true,
+		AssignUserID:    &agentID,
+		WhatsAppAccount: account.Name,
+		ContactIDs:      models.JSONBArray{gold.ID.String(), plain.ID.String(), uuid.New().String()},
+		Status:          models.ContactBulkUpdateStatusQueued,
+		TotalCount:      3,
+	}
+	require.NoError(t, w.DB.Create(update).Error)
+
+	job := &queue.ContactBulkUpdateJob{UpdateID: update.ID, OrganizationID: org.ID}
+	require.NoError(t, w.HandleContactBulkUpdateJob(context.Background(), job))
+
+	var stored models.ContactBulkUpdate
+	require.NoError(t, w.DB.Where("id = ?", update.ID).First(&stored).Error)
+	assert.Equal(t, models.ContactBulkUpdateStatusCompleted, stored.Status)
+	assert.Equal(t, 3, stored.ProcessedCount)
+	assert.Equal(t, 2, stored.UpdatedCount)
+	assert.NotNil(t, stored.CompletedAt)
+
+	for _, id := range []uuid.UUID{gold.ID, plain.ID} {
+		var contact models.Contact
+		require.NoError(t, w.DB.Where("id = ?", id).First(&contact).Error)
+		assert.Contains(t, contact.Tags, "gold")
+		assert.NotContains(t, contact.Tags, "trial")
+		assert.Equal(t, "gold", contact.Metadata["tier"])
+		assert.Equal(t, &agentID, contact.AssignedUserID)
+		assert.Equal(t, account.Name, contact.WhatsAppAccount)
+	}
+	var kept models.Contact
+	require.NoError(t, w.DB.Where("id = ?", gold.ID).First(&kept).Error)
+	assert.Equal(t, "Pune", kept.Metadata["city"])
+
+	// A redelivered job leaves a finished update alone
+	require.NoError(t, w.DB.Model(&models.Contact{}).Where("id = ?", plain.ID).Update("tags", models.JSONBArray{"trial"}).Error)
+	require.NoError(t, w.HandleContactBulkUpdateJob(context.Background(), job))
+	var untouched models.Contact
+	require.NoError(t, w.DB.Where("id = ?", plain.ID).First(&untouched).Error)
+	assert.Equal(t, models.JSONBArray{"trial"}, untouched.Tags)
+}
+
+func TestContactBulkChanges_NoChange(t *testing.T) {
+	userID := uuid.New()
+	contact := &models.Contact{
+		Tags:            models.JSONBArray{"vip"},
+		Metadata:        models.JSONB{"tier": "gold"},
+		AssignedUserID:  &userID,
+		WhatsAppAccount: "main",
+	}
+	update := &models.ContactBulkUpdate{
+		AddTags:         models.JSONBArray{"vip"},
+		RemoveTags:      models.JSONBArray{"trial"},
+		CustomFields:    models.JSONB{"tier": "gold"},
+		Assign:          true,
+		AssignUserID:    &userID,
+		WhatsAppAccount: "main",
+	}
+	assert.Empty(t, contactBulkChanges(update, contact))
+
+	update.Assign, update.AssignUserID = true, nil
+	assert.Equal(t, map[string]interface{}{"assigned_user_id": (*uuid.UUID)(nil)}, contactBulkChanges(update, contact))
+}
@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"gorm.io/gorm"
+)
+
+// HandleTemplateSendJob sends one message of a bulk template send batch
+func (w *Worker) HandleTemplateSendJob(ctx context.Context, job *queue.TemplateSendJob) error {
+	var item models.TemplateSendItem
+	if err := w.DB.Where("id = ? AND batch_id = ?", job.ItemID, job.BatchID).First(&item).Error; err != nil {
+		w.Log.Error("Failed to load template send item", "error", err, "item_id", job.ItemID)
+		return fmt.Errorf("failed to load template send item: %w", err)
+	}
+
+	// Already handled, e.g. the job was redelivered after a worker restart
+	if item.Status != models.MessageStatusPending {
+		return nil
+	}
+
+	var batch models.TemplateSendBatch
+	if err := w.DB.Where("id = ?", job.BatchID).Preload("Template").First(&batch).Error; err != nil {
+		w.Log.Error("Failed to load template send batch", "error", err, "batch_id", job.BatchID)
+		return fmt.Errorf("failed to load template send batch: %w", err)
+	}
+
+	defer w.checkTemplateSendBatchCompletion(job.BatchID)
+
+	if batch.Template == nil {
+		w.failTemplateSendItem(&item, "Template not found", 0)
+		return nil
+	}
+
+	// Enforce the organization's destination country rules
+	if err := w.checkDestination(job.OrganizationID, item.PhoneNumber); err != nil {
+		w.Log.Warn("Template send blocked by country restrictions", "recipient", item.PhoneNumber, "error", err)
+		w.failTemplateSendItem(&item, err.Error(), 0)
+		return nil // Don't retry
+	}
+	if err := w.checkMarketingConsent(job.OrganizationID, item.PhoneNumber, batch.Template); err != nil {
+		w.Log.Warn("Template send blocked without marketing consent", "recipient", item.PhoneNumber, "batch_id", job.BatchID)
+		w.failTemplateSendItem(&item, err.Error(), 0)
+		return nil // Don't retry
+	}
+
+	var account models.WhatsAppAccount
+	if err := w.DB.Where("name = ? AND organization_id = ?", batch.WhatsAppAccount, job.OrganizationID).First(&account).Error; err != nil {
+		w.Log.Error("Failed to load WhatsApp account", "error", err, "account_name", batch.WhatsAppAccount)
+		w.failTemplateSendItem(&item, "WhatsApp account not found", 0)
+		return nil
+	}
+
+	contact, err := w.getOrCreateContact(job.OrganizationID, item.PhoneNumber, "")
+	if err != nil || contact == nil {
+		w.Log.Error("Failed to get or create contact", "error", err, "phone", item.PhoneNumber)
+		w.failTemplateSendItem(&item, "Failed to create contact", 0)
+		return nil
+	}
+
+	recipient := &models.BulkMessageRecipient{
+		PhoneNumber:    item.PhoneNumber,
+		TemplateParams: item.TemplateParams,
+	}
+	flowToken := templateFlowToken(batch.Template, "batch", batch.ID, item.ID)
+	waMessageID, sendErr := w.sendTemplateMessage(ctx, &account, batch.Template, recipient, "", flowToken)
+
+	message := models.Message{
+		OrganizationID:    job.OrganizationID,
+		WhatsAppAccount:   batch.WhatsAppAccount,
+		ContactID:         contact.ID,
+		WhatsAppMessageID: waMessageID,
+		Direction:         models.DirectionOutgoing,
+		MessageType:       models.MessageTypeTemplate,
+		TemplateName:      batch.Template.Name,
+		TemplateParams:    item.TemplateParams,
+		Content:           replaceTemplateContent(batch.Template, batch.Template.BodyContent, item.TemplateParams),
+		SentByUserID:      batch.CreatedBy,
+		Status:            models.MessageStatusSent,
+		Metadata: models.JSONB{
+			"template_send_batch_id": batch.ID.String(),
+			"reference":              item.Reference,
+		},
+	}
+	if flowToken != "" {
+		message.Metadata["flow_token"] = flowToken
+	}
+	if sendErr != nil {
+		w.Log.Error("Failed to send template message", "error", sendErr, "recipient", item.PhoneNumber, "batch_id", batch.ID)
+		message.Status = models.MessageStatusFailed
+		message.ErrorMessage = sendErr.Error()
+		message.ErrorCode = whatsapp.ErrorCodeOf(sendErr)
+	}
+
+	if err := w.DB.Create(&message).Error; err != nil {
+		w.Log.Error("Failed to save message", "error", err, "recipient", item.PhoneNumber)
+	} else {
+		item.MessageID = &message.ID
+		event := models.MessageStatusEvent{
+			OrganizationID: message.OrganizationID,
+			MessageID:      message.ID,
+			Status:         models.MessageStatusAccepted,
+			OccurredAt:     time.Now(),
+		}
+		if sendErr != nil {
+			event.Status = models.MessageStatusFailed
+			event.ErrorMessage = message.ErrorMessage
+		}
+		if err := w.DB.Create(&event).Error; err != nil {
+			w.Log.Error("Failed to record message status", "error", err, "message_id", message.ID)
+		}
+	}
+
+	if sendErr != nil {
+		w.failTemplateSendItem(&item, message.ErrorMessage, message.ErrorCode)
+		return nil
+	}
+
+	now := time.Now()
+	w.DB.Model(&item).Updates(map[string]interface{}{
+		"status":               models.MessageStatusSent,
+		"message_id":           item.MessageID,
+		"whats_app_message_id": waMessageID,
+		"sent_at":              now,
+	})
+	w.DB.Model(&models.TemplateSendBatch{}).Where("id = ?", batch.ID).
+		Update("sent_count", gorm.Expr("sent_count + 1"))
+	w.recordTemplateUsage(batch.TemplateID)
+	return nil
+}
+
+// failTemplateSendItem marks a bulk template send item as failed and counts it on its batch
+func (w *Worker) failTemplateSendItem(item *models.TemplateSendItem, errMsg string, errCode int) {
+	w.DB.Model(item).Updates(map[string]interface{}{
+		"status":        models.MessageStatusFailed,
+		"message_id":    item.MessageID,
+		"error_message": errMsg,
+		"error_code":    errCode,
+	})
+	w.DB.Model(&models.TemplateSendBatch{}).Where("id = ?", item.BatchID).
+		Update("failed_count", gorm.Expr("failed_count + 1"))
+}
+
+// checkTemplateSendBatchCompletion marks a batch completed once none of its items are pending
+func (w *Worker) checkTemplateSendBatchCompletion(batchID uuid.UUID) {
+	var pendingCount int64
+	w.DB.Model(&models.TemplateSendItem{}).
+		Where("batch_id = ? AND status = ?", batchID, models.MessageStatusPending).
+		Count(&pendingCount)
+	if pendingCount > 0 {
+		return
+	}
+
+	result := w.DB.Model(&models.TemplateSendBatch{}).
+		Where("id = ? AND status = ?", batchID, models.TemplateSendBatchStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":       models.TemplateSendBatchStatusCompleted,
+			"completed_at": time.Now(),
+		})
+	if result.Error == nil && result.RowsAffected > 0 {
+		w.Log.Info("Template send batch completed", "batch_id", batchID)
+	}
+}
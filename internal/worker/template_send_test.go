@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_HandleTemplateSendJob(t *testing.T) {
+	w := testWorker(t)
+	org, account, template, _, _ := createTestCampaignData(t, w)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.Header().Set("Content-Type", "application/json")
+		if calls == 2 {
+			rw.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"code": 131026, "message": "Message undeliverable"},
+			})
+			return
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"messages": []map[string]interface{}{{"id": "wamid.bulk1"}},
+		})
+	}))
+	defer server.Close()
+	require.NoError(t, w.DB.Model(account).Update("api_version", "v21.0").Error)
+	w.WhatsApp = whatsapp.NewWithBaseURL(w.Log, server.URL)
+
+	batch := &models.TemplateSendBatch{
+		OrganizationID:  org.ID,
+		WhatsAppAccount: account.Name,
+		TemplateID:      template.ID,
+		Status:          models.TemplateSendBatchStatusProcessing,
+		TotalCount:      2,
+	}
+	require.NoError(t, w.DB.Create(batch).Error)
+	items := []models.TemplateSendItem{
+		{BatchID: batch.ID, OrganizationID: org.ID, PhoneNumber: "15550000001", Reference: "order-1", Status: models.MessageStatusPending,
+			TemplateParams: models.JSONB{"1": "Alice", "2": "ORD-1"}},
+		{BatchID: batch.ID, OrganizationID: org.ID, PhoneNumber: "15550000002", Reference: "order-2", Status: models.MessageStatusPending,
+			TemplateParams: models.JSONB{"1": "Bob", "2": "ORD-2"}},
+	}
+	require.NoError(t, w.DB.Create(&items).Error)
+
+	for _, item := range items {
+		job := &queue.TemplateSendJob{BatchID: batch.ID, ItemID: item.ID, OrganizationID: org.ID}
+		require.NoError(t, w.HandleTemplateSendJob(context.Background(), job))
+	}
+	// A redelivered job is not sent twice
+	require.NoError(t, w.HandleTemplateSendJob(context.Background(), &queue.TemplateSendJob{BatchID: batch.ID, ItemID: items[0].ID, OrganizationID: org.ID}))
+	assert.Equal(t, 2, calls)
+
+	var sent models.TemplateSendItem
+	require.NoError(t, w.DB.First(&sent, items[0].ID).Error)
+	assert.Equal(t, models.MessageStatusSent, sent.Status)
+	assert.Equal(t, "wamid.bulk1", sent.WhatsAppMessageID)
+	require.NotNil(t, sent.MessageID)
+
+	var message models.Message
+	require.NoError(t, w.DB.First(&message, *sent.MessageID).Error)
+	assert.Equal(t, "Hello Alice, your order ORD-1 is ready!", message.Content)
+	assert.Equal(t, "order-1", message.Metadata["reference"])
+
+	var failed models.TemplateSendItem
+	require.NoError(t, w.DB.First(&failed, items[1].ID).Error)
+	assert.Equal(t, models.MessageStatusFailed, failed.Status)
+	assert.Equal(t, 131026, failed.ErrorCode)
+
+	var updated models.TemplateSendBatch
+	require.NoError(t, w.DB.First(&updated, batch.ID).Error)
+	assert.Equal(t, models.TemplateSendBatchStatusCompleted, updated.Status)
+	assert.Equal(t, 1, updated.SentCount)
+	assert.Equal(t, 1, updated.FailedCount)
+	assert.NotNil(t, updated.CompletedAt)
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -9,9 +10,17 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/byok"
 	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/shridarpatil/whatomate/internal/consent"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/optout"
+	"github.com/shridarpatil/whatomate/internal/phone"
+	"github.com/shridarpatil/whatomate/internal/pricing"
 	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/internal/sendwindow"
+	"github.com/shridarpatil/whatomate/internal/shortlink"
+	"github.com/shridarpatil/whatomate/internal/templatevars"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/zerodha/logf"
 	"gorm.io/gorm"
@@ -52,6 +61,9 @@ type Worker struct {
 	WhatsApp  *whatsapp.Client
 	Consumer  *queue.RedisConsumer
 	Publisher *queue.Publisher
+
+	// keys caches the data keys of organizations that bring their own encryption key
+	keys byok.Keyring
 }
 
 // Ensure Worker implements JobHandler interface
@@ -59,19 +71,25 @@ var _ queue.JobHandler = (*Worker)(nil)
 
 // New creates a new Worker instance
 func New(cfg *config.Config, db *gorm.DB, rdb *redis.Client, log logf.Logger) (*Worker, error) {
-	consumer, err := queue.NewRedisConsumer(rdb, log)
+	consumer, err := queue.NewRedisConsumer(rdb, log, queue.LaneWeights{
+		queue.LaneHigh: cfg.Queue.HighPriorityWeight,
+		queue.LaneLow:  cfg.Queue.LowPriorityWeight,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create consumer: %w", err)
 	}
 
 	publisher := queue.NewPublisher(rdb, log)
 
+	waClient := whatsapp.NewWithBaseURL(log, cfg.WhatsApp.BaseURL)
+	waClient.SetRateLimit(whatsapp.NewRedisRateLimiter(rdb), cfg.WhatsApp.MessagesPerSecond)
+
 	return &Worker{
 		Config:    cfg,
 		DB:        db,
 		Redis:     rdb,
 		Log:       log,
-		WhatsApp:  whatsapp.New(log),
+		WhatsApp:  waClient,
 		Consumer:  consumer,
 		Publisher: publisher,
 	}, nil
@@ -167,12 +185,60 @@ func (w *Worker) HandleRecipientJob(ctx context.Context, job *queue.RecipientJob
 		return nil // Not an error, just skip
 	}
 
+	// Enforce the organization's destination country rules
+	if err := w.checkDestination(job.OrganizationID, job.PhoneNumber); err != nil {
+		w.Log.Warn("Recipient blocked by country restrictions", "recipient", job.PhoneNumber, "error", err)
+		w.updateRecipientStatus(job.RecipientID, models.MessageStatusFailed, "", err.Error())
+		w.incrementCampaignCount(job.CampaignID, "failed_count")
+		w.checkCampaignCompletion(ctx, job.CampaignID, job.OrganizationID)
+		return nil // Don't retry
+	}
+
+	// Contacts may opt out after they were added to the campaign
+	if err := w.checkOptOut(job.OrganizationID, job.PhoneNumber); err != nil {
+		w.Log.Info("Recipient skipped, contact opted out", "recipient", job.PhoneNumber, "campaign_id", job.CampaignID)
+		w.updateRecipientStatus(job.RecipientID, models.MessageStatusFailed, "", err.Error())
+		w.incrementCampaignCount(job.CampaignID, "failed_count")
+		w.checkCampaignCompletion(ctx, job.CampaignID, job.OrganizationID)
+		return nil // Don't retry
+	}
+
+	// Marketing campaigns only reach contacts who opted in, when the organization requires it
+	if err := w.checkMarketingConsent(job.OrganizationID, job.PhoneNumber, campaign.Template); err != nil {
+		w.Log.Warn("Recipient blocked without marketing consent", "recipient", job.PhoneNumber, "campaign_id", job.CampaignID)
+		w.updateRecipientStatus(job.RecipientID, models.MessageStatusFailed, "", err.Error())
+		w.incrementCampaignCount(job.CampaignID, "failed_count")
+		w.checkCampaignCompletion(ctx, job.CampaignID, job.OrganizationID)
+		return nil // Don't retry
+	}
+
+	// Hold the message until the recipient's send window opens; the campaign scheduler
+	// re-queues it then
+	if opensAt := w.sendWindowOpensAt(&campaign, job.OrganizationID, job.PhoneNumber); !opensAt.IsZero() {
+		w.DB.Model(&models.BulkMessageRecipient{}).Where("id = ?", job.RecipientID).Update("deferred_until", opensAt)
+		w.Log.Debug("Recipient outside send window, deferred", "recipient_id", job.RecipientID, "until", opensAt)
+		return nil
+	}
+
+	// Reserve this message's cost against the campaign budget; once it runs out the campaign is
+	// paused and the recipient stays pending so it is sent when the campaign is resumed
+	var category string
+	if campaign.Template != nil {
+		category = campaign.Template.Category
+	}
+	cost := pricing.MessageCost(job.PhoneNumber, category)
+	if !w.reserveCampaignCost(job.CampaignID, cost) {
+		w.pauseCampaignOverBudget(ctx, job.CampaignID, job.OrganizationID)
+		return nil
+	}
+
 	// Get WhatsApp account
 	var account models.WhatsAppAccount
 	if err := w.DB.Where("name = ? AND organization_id = ?", campaign.WhatsAppAccount, job.OrganizationID).First(&account).Error; err != nil {
 		w.Log.Error("Failed to load WhatsApp account", "error", err, "account_name", campaign.WhatsAppAccount)
 		w.updateRecipientStatus(job.RecipientID, models.MessageStatusFailed, "", "WhatsApp account not found")
 		w.incrementCampaignCount(job.CampaignID, "failed_count")
+		w.releaseCampaignCost(job.CampaignID, cost)
 		return nil // Don't retry, mark as failed
 	}
 
@@ -182,18 +248,53 @@ func (w *Worker) HandleRecipientJob(ctx context.Context, job *queue.RecipientJob
 		w.Log.Error("Failed to get or create contact", "error", err, "phone", job.PhoneNumber)
 		w.updateRecipientStatus(job.RecipientID, models.MessageStatusFailed, "", "Failed to create contact")
 		w.incrementCampaignCount(job.CampaignID, "failed_count")
+		w.releaseCampaignCost(job.CampaignID, cost)
 		return nil // Don't retry
 	}
 
+	// Fill the placeholders the recipient left empty from the contact's fields. Contacts
+	// can change after the campaign was checked, so a placeholder may be left without a
+	// value; don't send the message with it blank.
+	templateParams := job.TemplateParams
+	if mapping := templatevars.FromJSONB(campaign.TemplateParamMapping); len(mapping) > 0 && campaign.Template != nil {
+		var missing []string
+		templateParams, missing = templatevars.Apply(extractParameterNames(campaign.Template.BodyContent), job.TemplateParams, mapping, contact)
+		if len(missing) > 0 {
+			errorMsg := "No value for template placeholders: " + strings.Join(missing, ", ")
+			w.Log.Warn("Recipient skipped, template placeholders unresolved", "recipient", job.PhoneNumber, "campaign_id", job.CampaignID, "params", missing)
+			w.updateRecipientStatus(job.RecipientID, models.MessageStatusFailed, "", errorMsg)
+			w.incrementCampaignCount(job.CampaignID, "failed_count")
+			w.releaseCampaignCost(job.CampaignID, cost)
+			w.checkCampaignCompletion(ctx, job.CampaignID, job.OrganizationID)
+			return nil // Don't retry
+		}
+	}
+
+	// Wrap URLs in per-recipient tracked short links if enabled for this campaign
+	if campaign.TrackLinks && w.Config != nil && w.Config.Server.PublicURL != "" {
+		owner := shortlink.Owner{
+			OrganizationID: job.OrganizationID,
+			CampaignID:     &job.CampaignID,
+			RecipientID:    &job.RecipientID,
+			ContactID:      &contact.ID,
+		}
+		if wrapped, err := shortlink.WrapParams(w.DB, w.Config.Server.PublicURL, owner, templateParams); err != nil {
+			w.Log.Error("Failed to wrap links, sending original URLs", "error", err, "recipient_id", job.RecipientID)
+		} else {
+			templateParams = wrapped
+		}
+	}
+
 	// Build recipient for sending
 	recipient := &models.BulkMessageRecipient{
 		PhoneNumber:    job.PhoneNumber,
 		RecipientName:  job.RecipientName,
-		TemplateParams: job.TemplateParams,
+		TemplateParams: templateParams,
 	}
 
 	// Send template message
-	waMessageID, err := w.sendTemplateMessage(ctx, &account, campaign.Template, recipient, campaign.HeaderMediaID)
+	flowToken := templateFlowToken(campaign.Template, "campaign", job.CampaignID, job.RecipientID)
+	waMessageID, err := w.sendTemplateMessage(ctx, &account, campaign.Template, recipient, campaign.HeaderMediaID, flowToken)
 
 	// Create Message record
 	message := models.Message{
@@ -203,15 +304,19 @@ func (w *Worker) HandleRecipientJob(ctx context.Context, job *queue.RecipientJob
 		WhatsAppMessageID: waMessageID,
 		Direction:         models.DirectionOutgoing,
 		MessageType:       models.MessageTypeTemplate,
-		TemplateParams:    job.TemplateParams,
+		TemplateParams:    templateParams,
 		Metadata: models.JSONB{
 			"campaign_id":    job.CampaignID.String(),
+			"recipient_id":   job.RecipientID.String(),
 			"recipient_name": job.RecipientName,
 		},
 	}
+	if flowToken != "" {
+		message.Metadata["flow_token"] = flowToken
+	}
 	if campaign.Template != nil {
 		message.TemplateName = campaign.Template.Name
-		content := replaceTemplateContent(campaign.Template, campaign.Template.BodyContent, job.TemplateParams)
+		content := replaceTemplateContent(campaign.Template, campaign.Template.BodyContent, templateParams)
 		message.Content = content
 	}
 
@@ -219,18 +324,38 @@ func (w *Worker) HandleRecipientJob(ctx context.Context, job *queue.RecipientJob
 		w.Log.Error("Failed to send message", "error", err, "recipient", job.PhoneNumber)
 		message.Status = models.MessageStatusFailed
 		message.ErrorMessage = err.Error()
+		message.ErrorCode = whatsapp.ErrorCodeOf(err)
 		w.updateRecipientStatus(job.RecipientID, models.MessageStatusFailed, "", err.Error())
 		w.incrementCampaignCount(job.CampaignID, "failed_count")
+		w.releaseCampaignCost(job.CampaignID, cost)
 	} else {
 		w.Log.Info("Message sent", "recipient", job.PhoneNumber, "message_id", waMessageID)
 		message.Status = models.MessageStatusSent
 		w.updateRecipientStatus(job.RecipientID, models.MessageStatusSent, waMessageID, "")
 		w.incrementCampaignCount(job.CampaignID, "sent_count")
+		w.recordTemplateUsage(campaign.TemplateID)
 	}
 
 	// Save message record
 	if err := w.DB.Create(&message).Error; err != nil {
 		w.Log.Error("Failed to save message", "error", err, "recipient", job.PhoneNumber)
+	} else {
+		w.DB.Model(&models.BulkMessageRecipient{}).Where("id = ?", job.RecipientID).Update("message_id", message.ID)
+
+		// Start the message's delivery timeline; webhooks add the later steps
+		event := models.MessageStatusEvent{
+			OrganizationID: message.OrganizationID,
+			MessageID:      message.ID,
+			Status:         models.MessageStatusAccepted,
+			OccurredAt:     time.Now(),
+		}
+		if message.Status == models.MessageStatusFailed {
+			event.Status = models.MessageStatusFailed
+			event.ErrorMessage = message.ErrorMessage
+		}
+		if err := w.DB.Create(&event).Error; err != nil {
+			w.Log.Error("Failed to record message status", "error", err, "message_id", message.ID)
+		}
 	}
 
 	// Check if campaign is complete (all recipients processed)
@@ -261,6 +386,129 @@ func (w *Worker) incrementCampaignCount(campaignID uuid.UUID, column string) {
 		Update(column, gorm.Expr(column+" + 1"))
 }
 
+// recordTemplateUsage bumps the template's usage counter and last-used time
+func (w *Worker) recordTemplateUsage(templateID uuid.UUID) {
+	w.DB.Model(&models.Template{}).
+		Where("id = ?", templateID).
+		Updates(map[string]interface{}{
+			"usage_count":  gorm.Expr("usage_count + 1"),
+			"last_used_at": time.Now(),
+		})
+}
+
+// checkDestination returns an error if the organization does not allow messaging the number's country
+func (w *Worker) checkDestination(orgID uuid.UUID, phoneNumber string) error {
+	var org models.Organization
+	if err := w.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil // Restrictions can't be loaded; don't block on a lookup failure
+	}
+	return phone.RestrictionsFromSettings(org.Settings).Check(phoneNumber)
+}
+
+// checkMarketingConsent returns consent.ErrMissing if the template is a marketing template, the
+// organization requires consent for those and the number has no active opt-in
+func (w *Worker) checkMarketingConsent(orgID uuid.UUID, phoneNumber string, template *models.Template) error {
+	if template == nil || !consent.AppliesTo(template.Category) {
+		return nil
+	}
+	var org models.Organization
+	if err := w.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil // Settings can't be loaded; don't block on a lookup failure
+	}
+	if !consent.RequiredFromSettings(org.Settings) {
+		return nil
+	}
+	if err := consent.Check(w.DB, orgID, phoneNumber); err != nil {
+		if errors.Is(err, consent.ErrMissing) {
+			return err
+		}
+		w.Log.Error("Failed to check marketing consent", "error", err, "org_id", orgID)
+	}
+	return nil
+}
+
+// checkOptOut returns optout.ErrOptedOut if the number belongs to a contact who opted out
+func (w *Worker) checkOptOut(orgID uuid.UUID, phoneNumber string) error {
+	err := optout.Check(w.DB, orgID, phoneNumber)
+	if err != nil && !errors.Is(err, optout.ErrOptedOut) {
+		// Don't block sends on a lookup failure
+		w.Log.Error("Failed to check opt-out", "error", err, "org_id", orgID)
+		return nil
+	}
+	return err
+}
+
+// sendWindowOpensAt returns when the campaign's send window next opens in the recipient's
+// timezone, or the zero time if the message can be sent now
+func (w *Worker) sendWindowOpensAt(campaign *models.BulkMessageCampaign, orgID uuid.UUID, phoneNumber string) time.Time {
+	window, err := sendwindow.Parse(campaign.SendWindowStart, campaign.SendWindowEnd)
+	if err != nil || window.IsZero() {
+		return time.Time{}
+	}
+
+	var contactTimezone string
+	var contacts []models.Contact
+	w.DB.Select("id, metadata").Where("organization_id = ? AND phone_number = ?", orgID, phoneNumber).Limit(1).Find(&contacts)
+	if len(contacts) > 0 {
+		contactTimezone, _ = contacts[0].Metadata[sendwindow.ContactTimezoneKey].(string)
+	}
+
+	now := time.Now()
+	loc := sendwindow.RecipientLocation(phoneNumber, contactTimezone, w.orgLocation(orgID))
+	if opensAt := window.Next(now, loc); opensAt.After(now) {
+		return opensAt
+	}
+	return time.Time{}
+}
+
+// orgLocation returns the organization's configured timezone, falling back to UTC
+func (w *Worker) orgLocation(orgID uuid.UUID) *time.Location {
+	var org models.Organization
+	if err := w.DB.Select("id, settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return time.UTC
+	}
+	if tz, ok := org.Settings["timezone"].(string); ok && tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// reserveCampaignCost atomically adds a message's cost to the campaign's actual cost.
+// Returns false if that would exceed the campaign's budget.
+func (w *Worker) reserveCampaignCost(campaignID uuid.UUID, cost float64) bool {
+	result := w.DB.Model(&models.BulkMessageCampaign{}).
+		Where("id = ? AND (max_budget IS NULL OR actual_cost + ? <= max_budget)", campaignID, cost).
+		Update("actual_cost", gorm.Expr("actual_cost + ?", cost))
+	if result.Error != nil {
+		// Don't block sending on a cost accounting failure
+		w.Log.Error("Failed to record campaign cost", "error", result.Error, "campaign_id", campaignID)
+		return true
+	}
+	return result.RowsAffected > 0
+}
+
+// releaseCampaignCost gives back a reserved cost for a message that was not sent
+func (w *Worker) releaseCampaignCost(campaignID uuid.UUID, cost float64) {
+	w.DB.Model(&models.BulkMessageCampaign{}).
+		Where("id = ?", campaignID).
+		Update("actual_cost", gorm.Expr("GREATEST(actual_cost - ?, 0)", cost))
+}
+
+// pauseCampaignOverBudget pauses a running campaign whose budget has been used up
+func (w *Worker) pauseCampaignOverBudget(ctx context.Context, campaignID, organizationID uuid.UUID) {
+	result := w.DB.Model(&models.BulkMessageCampaign{}).
+		Where("id = ? AND status = ?", campaignID, models.CampaignStatusProcessing).
+		Update("status", models.CampaignStatusPaused)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	w.Log.Warn("Campaign paused, budget exhausted", "campaign_id", campaignID)
+	w.publishCampaignStats(ctx, campaignID, organizationID)
+}
+
 // publishCampaignStats publishes campaign stats for real-time updates
 func (w *Worker) publishCampaignStats(ctx context.Context, campaignID, organizationID uuid.UUID) {
 	var campaign models.BulkMessageCampaign
@@ -323,13 +571,49 @@ func (w *Worker) checkCampaignCompletion(ctx context.Context, campaignID, organi
 	}
 }
 
-// sendTemplateMessage sends a template message via WhatsApp Cloud API
-func (w *Worker) sendTemplateMessage(ctx context.Context, account *models.WhatsAppAccount, template *models.Template, recipient *models.BulkMessageRecipient, campaignHeaderMediaID string) (string, error) {
+// templateFlowToken returns the token for a template's FLOW button, or "" if it has none.
+// The token comes back in the flow response so it can be traced to the send.
+func templateFlowToken(template *models.Template, prefix string, ids ...uuid.UUID) string {
+	if template == nil || whatsapp.FlowButtonIndex(template.Buttons) < 0 {
+		return ""
+	}
+	token := prefix
+	for _, id := range ids {
+		token += "_" + id.String()
+	}
+	return token
+}
+
+// accessToken returns an account's access token, decrypting it if its organization brings
+// its own encryption key
+func (w *Worker) accessToken(ctx context.Context, account *models.WhatsAppAccount) (string, error) {
+	if !byok.IsEncrypted(account.AccessToken) {
+		return account.AccessToken, nil
+	}
+	key, err := w.keys.DataKey(ctx, w.DB, account.OrganizationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	if key == nil {
+		return "", byok.ErrKeyUnavailable
+	}
+	return byok.DecryptString(key, account.AccessToken)
+}
+
+// sendTemplateMessage sends a template message via WhatsApp Cloud API.
+// flowToken is passed to the template's FLOW button, if it has one.
+func (w *Worker) sendTemplateMessage(ctx context.Context, account *models.WhatsAppAccount, template *models.Template, recipient *models.BulkMessageRecipient, campaignHeaderMediaID, flowToken string) (string, error) {
+	accessToken, err := w.accessToken(ctx, account)
+	if err != nil {
+		return "", err
+	}
 	waAccount := &whatsapp.Account{
 		PhoneID:     account.PhoneID,
 		BusinessID:  account.BusinessID,
 		APIVersion:  account.APIVersion,
-		AccessToken: account.AccessToken,
+		AccessToken: accessToken,
+
+		MessagesPerSecond: account.MessagesPerSecond,
 	}
 
 	// Build template components with parameters
@@ -374,6 +658,10 @@ func (w *Worker) sendTemplateMessage(ctx context.Context, account *models.WhatsA
 		})
 	}
 
+	if idx := whatsapp.FlowButtonIndex(template.Buttons); idx >= 0 {
+		components = append(components, whatsapp.FlowButtonComponent(idx, flowToken, nil))
+	}
+
 	return w.WhatsApp.SendTemplateMessageWithComponents(ctx, waAccount, recipient.PhoneNumber, template.Name, template.Language, components)
 }
 
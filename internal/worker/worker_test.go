@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
@@ -178,6 +179,71 @@ func TestWorker_HandleRecipientJob_CampaignPaused(t *testing.T) {
 	assert.Equal(t, models.MessageStatusPending, updatedRecipient.Status)
 }
 
+func TestWorker_HandleRecipientJob_BudgetExhausted(t *testing.T) {
+	w := testWorker(t)
+	org, _, _, campaign, recipient := createTestCampaignData(t, w)
+
+	// A zero budget can't cover any message
+	require.NoError(t, w.DB.Model(campaign).Update("max_budget", 0).Error)
+
+	job := &queue.RecipientJob{
+		CampaignID:     campaign.ID,
+		RecipientID:    recipient.ID,
+		OrganizationID: org.ID,
+		PhoneNumber:    recipient.PhoneNumber,
+		RecipientName:  recipient.RecipientName,
+	}
+
+	err := w.HandleRecipientJob(context.Background(), job)
+	require.NoError(t, err)
+
+	// Campaign should be paused without spending anything
+	var updatedCampaign models.BulkMessageCampaign
+	require.NoError(t, w.DB.First(&updatedCampaign, campaign.ID).Error)
+	assert.Equal(t, models.CampaignStatusPaused, updatedCampaign.Status)
+	assert.Zero(t, updatedCampaign.ActualCost)
+
+	// Recipient stays pending so it is sent when the campaign resumes
+	var updatedRecipient models.BulkMessageRecipient
+	require.NoError(t, w.DB.First(&updatedRecipient, recipient.ID).Error)
+	assert.Equal(t, models.MessageStatusPending, updatedRecipient.Status)
+}
+
+func TestWorker_HandleRecipientJob_OutsideSendWindow(t *testing.T) {
+	w := testWorker(t)
+	org, _, _, campaign, recipient := createTestCampaignData(t, w)
+
+	// A window opening two hours from now; the +1 recipient falls back to the org's UTC
+	opens := time.Now().UTC().Add(2 * time.Hour).Truncate(time.Minute)
+	require.NoError(t, w.DB.Model(campaign).Updates(map[string]interface{}{
+		"send_window_start": opens.Format("15:04"),
+		"send_window_end":   opens.Add(time.Hour).Format("15:04"),
+	}).Error)
+
+	job := &queue.RecipientJob{
+		CampaignID:     campaign.ID,
+		RecipientID:    recipient.ID,
+		OrganizationID: org.ID,
+		PhoneNumber:    recipient.PhoneNumber,
+		RecipientName:  recipient.RecipientName,
+	}
+
+	err := w.HandleRecipientJob(context.Background(), job)
+	require.NoError(t, err)
+
+	// Recipient stays pending until the window opens, without spending anything
+	var updatedRecipient models.BulkMessageRecipient
+	require.NoError(t, w.DB.First(&updatedRecipient, recipient.ID).Error)
+	assert.Equal(t, models.MessageStatusPending, updatedRecipient.Status)
+	require.NotNil(t, updatedRecipient.DeferredUntil)
+	assert.True(t, opens.Equal(*updatedRecipient.DeferredUntil), "deferred until %v, want %v", updatedRecipient.DeferredUntil, opens)
+
+	var updatedCampaign models.BulkMessageCampaign
+	require.NoError(t, w.DB.First(&updatedCampaign, campaign.ID).Error)
+	assert.Zero(t, updatedCampaign.ActualCost)
+	assert.Zero(t, updatedCampaign.SentCount)
+}
+
 func TestWorker_HandleRecipientJob_CampaignCancelled(t *testing.T) {
 	w := testWorker(t)
 	org, _, _, campaign, recipient := createTestCampaignData(t, w)
@@ -577,7 +643,7 @@ func TestWorker_sendTemplateMessage_BuildsComponents(t *testing.T) {
 		},
 	}
 
-	msgID, err := w.sendTemplateMessage(context.Background(), account, template, recipient, "")
+	msgID, err := w.sendTemplateMessage(context.Background(), account, template, recipient, "", "")
 	require.NoError(t, err)
 	assert.Equal(t, "wamid.test123", msgID)
 
@@ -633,7 +699,7 @@ func TestWorker_sendTemplateMessage_NoParams(t *testing.T) {
 		TemplateParams: nil, // No params
 	}
 
-	msgID, err := w.sendTemplateMessage(context.Background(), account, template, recipient, "")
+	msgID, err := w.sendTemplateMessage(context.Background(), account, template, recipient, "", "")
 	require.NoError(t, err)
 	assert.Equal(t, "wamid.test456", msgID)
 
@@ -696,6 +762,7 @@ func TestWorker_HandleRecipientJob_Success(t *testing.T) {
 	var updatedCampaign models.BulkMessageCampaign
 	require.NoError(t, w.DB.First(&updatedCampaign, campaign.ID).Error)
 	assert.Equal(t, 1, updatedCampaign.SentCount)
+	assert.Positive(t, updatedCampaign.ActualCost)
 
 	// Verify message record created
 	var message models.Message
@@ -866,6 +933,61 @@ func TestWorker_HandleRecipientJob_TemplateParamSubstitution(t *testing.T) {
 	assert.NotContains(t, message.Content, "{{2}}")
 }
 
+func TestWorker_HandleRecipientJob_ParamMapping(t *testing.T) {
+	w := testWorker(t)
+	org, account, template, campaign, recipient := createTestCampaignData(t, w)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"messages": []map[string]interface{}{
+				{"id": "wamid.mapped123"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	require.NoError(t, w.DB.Model(account).Update("api_version", "v21.0").Error)
+	w.WhatsApp = whatsapp.NewWithBaseURL(w.Log, server.URL)
+
+	require.NoError(t, w.DB.Create(&models.Contact{
+		OrganizationID: org.ID,
+		PhoneNumber:    recipient.PhoneNumber,
+		ProfileName:    "Grace",
+		Metadata:       models.JSONB{"order_id": "ORD-789"},
+	}).Error)
+	require.NoError(t, w.DB.Model(campaign).Update("template_param_mapping", models.JSONB{
+		"1": "{{contact.name}}",
+		"2": "{{contact.custom_fields.order_id}}",
+	}).Error)
+
+	// The recipient carries no parameters; both come from the contact
+	job := &queue.RecipientJob{
+		CampaignID:     campaign.ID,
+		RecipientID:    recipient.ID,
+		OrganizationID: org.ID,
+		PhoneNumber:    recipient.PhoneNumber,
+		RecipientName:  recipient.RecipientName,
+	}
+	require.NoError(t, w.HandleRecipientJob(context.Background(), job))
+
+	var message models.Message
+	require.NoError(t, w.DB.Where("template_name = ?", template.Name).Order("created_at desc").First(&message).Error)
+	assert.Equal(t, "Hello Grace, your order ORD-789 is ready!", message.Content)
+
+	// A placeholder the contact has no value for fails the recipient instead of sending it blank
+	require.NoError(t, w.DB.Model(campaign).Update("template_param_mapping", models.JSONB{
+		"1": "{{contact.name}}",
+		"2": "{{contact.custom_fields.coupon}}",
+	}).Error)
+	require.NoError(t, w.HandleRecipientJob(context.Background(), job))
+
+	var updated models.BulkMessageRecipient
+	require.NoError(t, w.DB.First(&updated, recipient.ID).Error)
+	assert.Equal(t, models.MessageStatusFailed, updated.Status)
+	assert.Contains(t, updated.ErrorMessage, "2")
+}
+
 // Unit tests for parameter resolution functions (no database required)
 
 func TestResolveTemplateParams_NamedParams(t *testing.T) {
@@ -0,0 +1,264 @@
+// Package mailer sends templated email through an SMTP server.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLSMode selects how the SMTP connection is secured
+type TLSMode string
+
+const (
+	// TLSNone sends over plain text (only sensible for local relays)
+	TLSNone TLSMode = "none"
+	// TLSStartTLS upgrades a plain connection with STARTTLS, usually on port 587
+	TLSStartTLS TLSMode = "starttls"
+	// TLSImplicit connects over TLS from the start, usually on port 465
+	TLSImplicit TLSMode = "tls"
+)
+
+// DefaultTimeout bounds connecting to and talking with the SMTP server
+const DefaultTimeout = 30 * time.Second
+
+// Config describes an SMTP server and sender identity
+type Config struct {
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	FromEmail string
+	FromName  string
+	TLS       TLSMode
+	// InsecureSkipVerify disables certificate verification (self-signed relays)
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+// Message is an email to send. HTML and Text are sent as alternatives; either may be empty.
+type Message struct {
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+	ReplyTo string
+}
+
+// Mailer sends email using a fixed SMTP configuration
+type Mailer struct {
+	cfg Config
+}
+
+// IsValidTLSMode reports whether mode is a known TLS mode
+func IsValidTLSMode(mode TLSMode) bool {
+	return mode == TLSNone || mode == TLSStartTLS || mode == TLSImplicit
+}
+
+// New validates cfg and creates a Mailer
+func New(cfg Config) (*Mailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return nil, errors.New("invalid smtp port")
+	}
+	if _, err := mail.ParseAddress(cfg.FromEmail); err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+	if !IsValidTLSMode(cfg.TLS) {
+		return nil, fmt.Errorf("invalid tls mode: %s", cfg.TLS)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &Mailer{cfg: cfg}, nil
+}
+
+// Send delivers msg to all of its recipients
+func (m *Mailer) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("no recipients")
+	}
+	recipients := make([]string, 0, len(msg.To))
+	for _, to := range msg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		recipients = append(recipients, addr.Address)
+	}
+
+	body, err := m.build(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if m.cfg.Username != "" {
+		auth := smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+	if err := client.Mail(m.cfg.FromEmail); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s failed: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected message: %w", err)
+	}
+	return client.Quit()
+}
+
+func (m *Mailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{
+		ServerName:         m.cfg.Host,
+		InsecureSkipVerify: m.cfg.InsecureSkipVerify, //nolint:gosec // Opt-in per organization
+	}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if m.cfg.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	// net/smtp has no context support, so the deadline bounds the whole exchange
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("smtp handshake failed: %w", err)
+	}
+	if m.cfg.TLS == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			_ = client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("smtp STARTTLS failed: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// build renders msg as an RFC 5322 message
+func (m *Mailer) build(msg Message, now time.Time) ([]byte, error) {
+	from := (&mail.Address{Name: m.cfg.FromName, Address: m.cfg.FromEmail}).String()
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", from)
+	writeHeader(&buf, "To", strings.Join(msg.To, ", "))
+	if msg.ReplyTo != "" {
+		writeHeader(&buf, "Reply-To", msg.ReplyTo)
+	}
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader(&buf, "Date", now.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", fmt.Sprintf("<%s@%s>", randomID(), m.messageIDDomain()))
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	switch {
+	case msg.HTML != "" && msg.Text != "":
+		boundary := "whatomate-" + randomID()
+		writeHeader(&buf, "Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+		buf.WriteString("\r\n")
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain", msg.Text},
+			{"text/html", msg.HTML},
+		} {
+			buf.WriteString("--" + boundary + "\r\n")
+			if err := writeBody(&buf, part.contentType, part.body); err != nil {
+				return nil, err
+			}
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString("--" + boundary + "--\r\n")
+	case msg.HTML != "":
+		if err := writeBody(&buf, "text/html", msg.HTML); err != nil {
+			return nil, err
+		}
+	default:
+		if err := writeBody(&buf, "text/plain", msg.Text); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *Mailer) messageIDDomain() string {
+	if at := strings.LastIndex(m.cfg.FromEmail, "@"); at >= 0 {
+		return m.cfg.FromEmail[at+1:]
+	}
+	return m.cfg.Host
+}
+
+// writeHeader writes a header line, dropping line breaks that could inject headers
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	buf.WriteString(key + ": " + value + "\r\n")
+}
+
+func writeBody(buf *bytes.Buffer, contentType, body string) error {
+	writeHeader(buf, "Content-Type", contentType+"; charset=utf-8")
+	writeHeader(buf, "Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(buf)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	if err := qp.Close(); err != nil {
+		return err
+	}
+	buf.WriteString("\r\n")
+	return nil
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,285 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer accepts one plain-text session and records the envelope and data
+type fakeSMTPServer struct {
+	addr string
+	from string
+	rcpt []string
+	data chan string
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	s := &fakeSMTPServer{addr: ln.Addr().String(), data: make(chan string, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				// Drop ESMTP parameters such as BODY=8BITMIME
+				s.from = strings.Trim(strings.Fields(strings.TrimPrefix(cmd, "MAIL FROM:"))[0], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				s.rcpt = append(s.rcpt, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<> "))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				s.data <- body.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+	return s
+}
+
+func newTestMailer(t *testing.T, addr string) *Mailer {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	port, _ := strconv.Atoi(portStr)
+
+	m, err := New(Config{
+		Host:      host,
+		Port:      port,
+		FromEmail: "alerts@example.com",
+		FromName:  "Acme Support",
+		TLS:       TLSNone,
+		Timeout:   5 * time.Second,
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestNew_Validation(t *testing.T) {
+	_, err := New(Config{Port: 587, FromEmail: "a@example.com"})
+	assert.EqualError(t, err, "smtp host is required")
+
+	_, err = New(Config{Host: "smtp.example.com", FromEmail: "a@example.com"})
+	assert.EqualError(t, err, "invalid smtp port")
+
+	_, err = New(Config{Host: "smtp.example.com", Port: 587, FromEmail: "nope"})
+	assert.Error(t, err)
+
+	_, err = New(Config{Host: "smtp.example.com", Port: 587, FromEmail: "a@example.com", TLS: "ssl"})
+	assert.EqualError(t, err, "invalid tls mode: ssl")
+
+	m, err := New(Config{Host: "smtp.example.com", Port: 587, FromEmail: "a@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, TLSStartTLS, m.cfg.TLS)
+	assert.Equal(t, DefaultTimeout, m.cfg.Timeout)
+}
+
+func TestSend(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	m := newTestMailer(t, server.addr)
+
+	err := m.Send(context.Background(), Message{
+		To:      []string{"Ada <ada@example.com>", "bob@example.com"},
+		Subject: "Héllo",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	})
+	require.NoError(t, err)
+
+	var raw string
+	select {
+	case raw = <-server.data:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	assert.Equal(t, "alerts@example.com", server.from)
+	assert.Equal(t, []string{"ada@example.com", "bob@example.com"}, server.rcpt)
+
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Héllo", subject)
+	assert.Equal(t, `"Acme Support" <alerts@example.com>`, msg.Header.Get("From"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		types = append(types, strings.Split(part.Header.Get("Content-Type"), ";")[0])
+	}
+	assert.Equal(t, []string{"text/plain", "text/html"}, types)
+}
+
+func TestSend_InvalidRecipient(t *testing.T) {
+	m, err := New(Config{Host: "127.0.0.1", Port: 25, FromEmail: "a@example.com", TLS: TLSNone})
+	require.NoError(t, err)
+
+	assert.Error(t, m.Send(context.Background(), Message{To: []string{"not an address"}, Text: "x"}))
+	assert.EqualError(t, m.Send(context.Background(), Message{Text: "x"}), "no recipients")
+}
+
+func TestBuild_StripsHeaderInjection(t *testing.T) {
+	m, err := New(Config{Host: "smtp.example.com", Port: 587, FromEmail: "a@example.com"})
+	require.NoError(t, err)
+
+	raw, err := m.build(Message{
+		To:      []string{"b@example.com"},
+		ReplyTo: "c@example.com\r\nBcc: evil@example.com",
+		Subject: "hi",
+		Text:    "body",
+	}, time.Now())
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	require.NoError(t, err)
+	assert.Empty(t, msg.Header.Get("Bcc"))
+	assert.Equal(t, "text/plain; charset=utf-8", msg.Header.Get("Content-Type"))
+}
+
+func TestRender(t *testing.T) {
+	msg, err := Render(TemplatePasswordReset, map[string]interface{}{
+		"OrgName":   "Acme",
+		"Name":      "Ada <script>",
+		"Email":     "ada@example.com",
+		"ResetURL":  "https://app.example.com/reset-password?token=abc",
+		"ExpiresIn": "1 hour",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Reset your Whatomate password", msg.Subject)
+	assert.Contains(t, msg.Text, "Hi Ada <script>,")
+	assert.Contains(t, msg.Text, "https://app.example.com/reset-password?token=abc")
+	assert.Contains(t, msg.HTML, "Ada &lt;script&gt;")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/reset-password?token=abc"`)
+	assert.Contains(t, msg.HTML, "Acme &middot; Sent by Whatomate")
+}
+
+func TestRender_Alert(t *testing.T) {
+	msg, err := Render(TemplateAlert, map[string]interface{}{
+		"OrgName": "Acme",
+		"Title":   "SLA breached",
+		"Fields":  []Field{{Name: "Contact", Value: "Ada"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "[Acme] SLA breached", msg.Subject)
+	assert.Contains(t, msg.Text, "Contact: Ada")
+	assert.NotContains(t, msg.HTML, "Open in Whatomate")
+}
+
+func TestRender_ExportReady(t *testing.T) {
+	msg, err := Render(TemplateExportReady, map[string]interface{}{
+		"OrgName":     "Acme",
+		"Name":        "Ada",
+		"Report":      "messages",
+		"Range":       "2024-01-01 to 2024-01-31",
+		"Rows":        1200,
+		"DownloadURL": "https://app.example.com/api/analytics/exports/download/abc",
+		"ExpiresIn":   "24 hours",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "[Acme] Your messages export is ready", msg.Subject)
+	assert.Contains(t, msg.Text, "(1200 rows)")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/api/analytics/exports/download/abc"`)
+}
+
+func TestRender_Invitation(t *testing.T) {
+	data := map[string]interface{}{
+		"OrgName":  "Acme",
+		"Name":     "Ada",
+		"Email":    "ada@example.com",
+		"LoginURL": "https://app.example.com/login",
+	}
+	msg, err := Render(TemplateInvitation, data)
+	require.NoError(t, err)
+	assert.Contains(t, msg.Text, "Ask your administrator")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/login"`)
+
+	data["SetPasswordURL"] = "https://app.example.com/reset-password?token=abc"
+	data["ExpiresIn"] = "7 days"
+	msg, err = Render(TemplateInvitation, data)
+	require.NoError(t, err)
+	assert.Contains(t, msg.Text, "Set your password: https://app.example.com/reset-password?token=abc")
+	assert.Contains(t, msg.Text, "expires in 7 days")
+	assert.NotContains(t, msg.Text, "Ask your administrator")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/reset-password?token=abc"`)
+}
+
+func TestRender_OrganizationInvite(t *testing.T) {
+	msg, err := Render(TemplateOrgInvite, map[string]interface{}{
+		"OrgName":    "Client Co",
+		"Name":       "Ada",
+		"InvitedBy":  "Grace",
+		"Role":       "manager",
+		"InvitesURL": "https://app.example.com/invitations",
+		"ExpiresIn":  "7 days",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "You've been invited to join Client Co", msg.Subject)
+	assert.Contains(t, msg.Text, "Grace has invited you to join Client Co on Whatomate as manager.")
+	assert.Contains(t, msg.Text, "Review the invitation: https://app.example.com/invitations")
+	assert.Contains(t, msg.HTML, `href="https://app.example.com/invitations"`)
+}
+
+func TestRender_UnknownTemplate(t *testing.T) {
+	_, err := Render("missing", nil)
+	assert.EqualError(t, err, "unknown email template: missing")
+}
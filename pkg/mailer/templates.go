@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Built-in email templates. Each template has a <name>.txt file defining "subject" and
+// "text", and a <name>.html file defining "content", which is wrapped in the shared layout.
+const (
+	TemplateInvitation    = "invitation"
+	TemplatePasswordReset = "password_reset"
+	TemplateAlert         = "alert"
+	TemplateTest          = "test"
+	TemplateExportReady   = "export_ready"
+	TemplateOrgInvite     = "organization_invite"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Field is a labelled value listed in alert emails
+type Field struct {
+	Name  string
+	Value string
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = mustLoadTemplates()
+
+var htmlFuncs = htmltemplate.FuncMap{
+	"button": func(url, label string) map[string]string {
+		return map[string]string{"URL": url, "Label": label}
+	},
+}
+
+func mustLoadTemplates() map[string]emailTemplate {
+	names, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	out := make(map[string]emailTemplate, len(names))
+	for _, path := range names {
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "templates/"), ".txt")
+		text := texttemplate.Must(texttemplate.New(name).ParseFS(templateFS, path))
+		html := htmltemplate.Must(htmltemplate.New(name).Funcs(htmlFuncs).ParseFS(templateFS,
+			"templates/layout.html", "templates/button.html", "templates/"+name+".html"))
+		out[name] = emailTemplate{text: text, html: html}
+	}
+	return out
+}
+
+// Render builds the subject and bodies of a built-in template. Values referenced outside
+// of {{if}} blocks must be present in data.
+func Render(name string, data map[string]interface{}) (Message, error) {
+	tpl, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template: %s", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := tpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+	if err := tpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s html: %w", name, err)
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -163,3 +163,55 @@ func (c *Client) DeleteProduct(ctx context.Context, account *Account, productID
 	_, err := c.doRequest(ctx, http.MethodDelete, apiURL, nil, account.AccessToken)
 	return err
 }
+
+// buildCommerceSettingsURL builds the commerce settings endpoint URL for a phone number
+func (c *Client) buildCommerceSettingsURL(account *Account) string {
+	return fmt.Sprintf("%s/%s/%s/whatsapp_commerce_settings", c.getBaseURL(), account.APIVersion, account.PhoneID)
+}
+
+// GetCommerceSettings fetches the cart and catalog visibility settings of a phone number
+func (c *Client) GetCommerceSettings(ctx context.Context, account *Account) (*CommerceSettings, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, c.buildCommerceSettingsURL(account), nil, account.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp CommerceSettingsResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(resp.Data) == 0 {
+		return &CommerceSettings{}, nil
+	}
+	return &resp.Data[0], nil
+}
+
+// UpdateCommerceSettings enables or disables the cart and catalog visibility for a phone number
+func (c *Client) UpdateCommerceSettings(ctx context.Context, account *Account, settings *CommerceSettings) error {
+	// The commerce settings endpoint takes its values as query parameters
+	params := url.Values{}
+	params.Add("is_cart_enabled", strconv.FormatBool(settings.IsCartEnabled))
+	params.Add("is_catalog_visible", strconv.FormatBool(settings.IsCatalogVisible))
+	apiURL := c.buildCommerceSettingsURL(account) + "?" + params.Encode()
+
+	_, err := c.doRequest(ctx, http.MethodPost, apiURL, nil, account.AccessToken)
+	return err
+}
+
+// ListLinkedCatalogs lists the catalogs connected to the WhatsApp Business Account
+func (c *Client) ListLinkedCatalogs(ctx context.Context, account *Account) ([]CatalogInfo, error) {
+	apiURL := fmt.Sprintf("%s/%s/%s/product_catalogs", c.getBaseURL(), account.APIVersion, account.BusinessID)
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, apiURL, nil, account.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp CatalogListResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return resp.Data, nil
+}
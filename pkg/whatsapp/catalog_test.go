@@ -0,0 +1,72 @@
+package whatsapp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetCommerceSettings(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Contains(t, r.URL.Path, "/123456789/whatsapp_commerce_settings")
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []map[string]interface{}{
+				{"id": "cs1", "is_cart_enabled": true, "is_catalog_visible": false},
+			},
+		})
+	})
+
+	settings, err := client.GetCommerceSettings(testutil.TestContext(t), account)
+
+	require.NoError(t, err)
+	assert.True(t, settings.IsCartEnabled)
+	assert.False(t, settings.IsCatalogVisible)
+}
+
+func TestClient_UpdateCommerceSettings(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Contains(t, r.URL.Path, "/whatsapp_commerce_settings")
+		assert.Equal(t, "true", r.URL.Query().Get("is_cart_enabled"))
+		assert.Equal(t, "false", r.URL.Query().Get("is_catalog_visible"))
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	})
+
+	err := client.UpdateCommerceSettings(testutil.TestContext(t), account, &whatsapp.CommerceSettings{IsCartEnabled: true})
+
+	require.NoError(t, err)
+}
+
+func TestClient_ListLinkedCatalogs(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Contains(t, r.URL.Path, "/987654321/product_catalogs")
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []map[string]string{{"id": "cat1", "name": "Main catalog"}},
+		})
+	})
+
+	catalogs, err := client.ListLinkedCatalogs(testutil.TestContext(t), account)
+
+	require.NoError(t, err)
+	require.Len(t, catalogs, 1)
+	assert.Equal(t, "cat1", catalogs[0].ID)
+}
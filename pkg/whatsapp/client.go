@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strings"
 	"time"
 
 	"github.com/zerodha/logf"
@@ -24,6 +27,9 @@ type Client struct {
 	HTTPClient *http.Client
 	Log        logf.Logger
 	baseURL    string // For testing with mock servers
+
+	limiter           RateLimiter // Paces message sends, see SetRateLimit
+	messagesPerSecond int
 }
 
 // New creates a new WhatsApp client
@@ -48,7 +54,8 @@ func NewWithTimeout(log logf.Logger, timeout time.Duration) *Client {
 	}
 }
 
-// NewWithBaseURL creates a new WhatsApp client with a custom base URL (for testing)
+// NewWithBaseURL creates a new WhatsApp client with a custom base URL, such as a mock
+// Graph API for tests and load generation
 func NewWithBaseURL(log logf.Logger, baseURL string) *Client {
 	return &Client{
 		HTTPClient: &http.Client{
@@ -69,18 +76,25 @@ func (c *Client) getBaseURL() string {
 
 // doRequest performs an HTTP request to the Meta API
 func (c *Client) doRequest(ctx context.Context, method, url string, body interface{}, accessToken string) ([]byte, error) {
+	respBody, _, err := c.doRequestWithHeader(ctx, method, url, body, accessToken)
+	return respBody, err
+}
+
+// doRequestWithHeader is doRequest that also returns the response headers, including
+// for error responses, e.g. to read Meta's rate limit usage
+func (c *Client) doRequestWithHeader(ctx context.Context, method, url string, body interface{}, accessToken string) ([]byte, http.Header, error) {
 	var reqBody io.Reader
 	if body != nil {
 		jsonBody, err := json.Marshal(body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+			return nil, nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
 		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+accessToken)
@@ -88,31 +102,30 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body interfa
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		var apiErr MetaAPIError
 		if err := json.Unmarshal(respBody, &apiErr); err == nil && apiErr.Error.Message != "" {
-			errMsg := fmt.Sprintf("API error %d: %s", apiErr.Error.Code, apiErr.Error.Message)
-			if apiErr.Error.ErrorData.Details != "" {
-				errMsg += " - Details: " + apiErr.Error.ErrorData.Details
+			return nil, resp.Header, &APIError{
+				Code:        apiErr.Error.Code,
+				Subcode:     apiErr.Error.ErrorSubcode,
+				Message:     apiErr.Error.Message,
+				Details:     apiErr.Error.ErrorData.Details,
+				UserMessage: apiErr.Error.ErrorUserMsg,
 			}
-			if apiErr.Error.ErrorUserMsg != "" {
-				errMsg += " - " + apiErr.Error.ErrorUserMsg
-			}
-			return nil, fmt.Errorf("%s", errMsg)
 		}
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, resp.Header, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
-	return respBody, nil
+	return respBody, resp.Header, nil
 }
 
 // buildMessagesURL builds the messages endpoint URL
@@ -136,23 +149,32 @@ type MediaURLResponse struct {
 
 // GetMediaURL retrieves the download URL for a media file from Meta's API
 func (c *Client) GetMediaURL(ctx context.Context, mediaID string, account *Account) (string, error) {
+	info, err := c.GetMediaInfo(ctx, mediaID, account)
+	if err != nil {
+		return "", err
+	}
+	return info.URL, nil
+}
+
+// GetMediaInfo retrieves the download URL, MIME type and size of a media file from Meta's API
+func (c *Client) GetMediaInfo(ctx context.Context, mediaID string, account *Account) (*MediaURLResponse, error) {
 	url := fmt.Sprintf("%s/%s/%s", c.getBaseURL(), account.APIVersion, mediaID)
 
 	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account.AccessToken)
 	if err != nil {
-		return "", fmt.Errorf("failed to get media URL: %w", err)
+		return nil, fmt.Errorf("failed to get media URL: %w", err)
 	}
 
 	var mediaResp MediaURLResponse
 	if err := json.Unmarshal(respBody, &mediaResp); err != nil {
-		return "", fmt.Errorf("failed to parse media response: %w", err)
+		return nil, fmt.Errorf("failed to parse media response: %w", err)
 	}
 
 	if mediaResp.URL == "" {
-		return "", fmt.Errorf("no URL in media response")
+		return nil, fmt.Errorf("no URL in media response")
 	}
 
-	return mediaResp.URL, nil
+	return &mediaResp, nil
 }
 
 // DownloadMedia downloads media content from Meta's CDN URL
@@ -190,32 +212,29 @@ type UploadMediaResponse struct {
 
 // UploadMedia uploads media to WhatsApp's servers and returns the media ID
 func (c *Client) UploadMedia(ctx context.Context, account *Account, data []byte, mimeType, filename string) (string, error) {
-	url := fmt.Sprintf("%s/%s/%s/media", c.getBaseURL(), account.APIVersion, account.PhoneID)
-
-	// Create multipart form body
-	body := &bytes.Buffer{}
-	boundary := "----WebKitFormBoundary7MA4YWxkTrZu0gW"
-
-	// Build multipart body manually
-	fmt.Fprintf(body, "--%s\r\n", boundary)
-	body.WriteString("Content-Disposition: form-data; name=\"messaging_product\"\r\n\r\n")
-	body.WriteString("whatsapp\r\n")
+	return c.UploadMediaStream(ctx, account, bytes.NewReader(data), mimeType, filename)
+}
 
-	fmt.Fprintf(body, "--%s\r\n", boundary)
-	fmt.Fprintf(body, "Content-Disposition: form-data; name=\"file\"; filename=\"%s\"\r\n", filename)
-	fmt.Fprintf(body, "Content-Type: %s\r\n\r\n", mimeType)
-	body.Write(data)
-	body.WriteString("\r\n")
+// UploadMediaStream uploads media read from r without buffering the whole file,
+// so large documents can be sent from disk
+func (c *Client) UploadMediaStream(ctx context.Context, account *Account, r io.Reader, mimeType, filename string) (string, error) {
+	url := fmt.Sprintf("%s/%s/%s/media", c.getBaseURL(), account.APIVersion, account.PhoneID)
 
-	fmt.Fprintf(body, "--%s--\r\n", boundary)
+	// Write the multipart body into a pipe as the request consumes it
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMediaForm(mw, r, mimeType, filename))
+	}()
+	defer func() { _ = pr.Close() }()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, pr)
 	if err != nil {
 		return "", fmt.Errorf("failed to create upload request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
-	req.Header.Set("Content-Type", fmt.Sprintf("multipart/form-data; boundary=%s", boundary))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -245,8 +264,30 @@ func (c *Client) UploadMedia(ctx context.Context, account *Account, data []byte,
 	return uploadResp.ID, nil
 }
 
+// quoteEscaper escapes a multipart filename the way mime/multipart does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// writeMediaForm writes the media upload form fields followed by the file contents
+func writeMediaForm(mw *multipart.Writer, r io.Reader, mimeType, filename string) error {
+	if err := mw.WriteField("messaging_product", "whatsapp"); err != nil {
+		return err
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quoteEscaper.Replace(filename)))
+	header.Set("Content-Type", mimeType)
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
 // SendImageMessage sends an image message using a media ID
-func (c *Client) SendImageMessage(ctx context.Context, account *Account, phoneNumber, mediaID, caption string) (string, error) {
+func (c *Client) SendImageMessage(ctx context.Context, account *Account, phoneNumber, mediaID, caption string, opts ...SendOption) (string, error) {
 	payload := map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"recipient_type":    "individual",
@@ -261,7 +302,7 @@ func (c *Client) SendImageMessage(ctx context.Context, account *Account, phoneNu
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending image message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to send image message: %w", err)
 	}
@@ -281,7 +322,7 @@ func (c *Client) SendImageMessage(ctx context.Context, account *Account, phoneNu
 }
 
 // SendDocumentMessage sends a document message using a media ID
-func (c *Client) SendDocumentMessage(ctx context.Context, account *Account, phoneNumber, mediaID, filename, caption string) (string, error) {
+func (c *Client) SendDocumentMessage(ctx context.Context, account *Account, phoneNumber, mediaID, filename, caption string, opts ...SendOption) (string, error) {
 	payload := map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"recipient_type":    "individual",
@@ -297,7 +338,7 @@ func (c *Client) SendDocumentMessage(ctx context.Context, account *Account, phon
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending document message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to send document message: %w", err)
 	}
@@ -317,7 +358,7 @@ func (c *Client) SendDocumentMessage(ctx context.Context, account *Account, phon
 }
 
 // SendVideoMessage sends a video message using a media ID
-func (c *Client) SendVideoMessage(ctx context.Context, account *Account, phoneNumber, mediaID, caption string) (string, error) {
+func (c *Client) SendVideoMessage(ctx context.Context, account *Account, phoneNumber, mediaID, caption string, opts ...SendOption) (string, error) {
 	payload := map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"recipient_type":    "individual",
@@ -332,7 +373,7 @@ func (c *Client) SendVideoMessage(ctx context.Context, account *Account, phoneNu
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending video message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to send video message: %w", err)
 	}
@@ -352,7 +393,7 @@ func (c *Client) SendVideoMessage(ctx context.Context, account *Account, phoneNu
 }
 
 // SendAudioMessage sends an audio message using a media ID
-func (c *Client) SendAudioMessage(ctx context.Context, account *Account, phoneNumber, mediaID string) (string, error) {
+func (c *Client) SendAudioMessage(ctx context.Context, account *Account, phoneNumber, mediaID string, opts ...SendOption) (string, error) {
 	payload := map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"recipient_type":    "individual",
@@ -366,7 +407,7 @@ func (c *Client) SendAudioMessage(ctx context.Context, account *Account, phoneNu
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending audio message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to send audio message: %w", err)
 	}
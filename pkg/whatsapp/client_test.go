@@ -2,8 +2,10 @@ package whatsapp_test
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -395,6 +397,46 @@ func TestClient_MarkMessageRead(t *testing.T) {
 	}
 }
 
+func TestClient_UploadMediaStream(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Repeat("%PDF-1.4 large document ", 4096)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.URL.Path, "/media")
+		assert.Equal(t, "Bearer test-access-token", r.Header.Get("Authorization"))
+
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whatsapp", r.FormValue("messaging_product"))
+
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer func() { _ = file.Close() }()
+		assert.Equal(t, "report \"final\".pdf", header.Filename)
+		assert.Equal(t, "application/pdf", header.Header.Get("Content-Type"))
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "media-doc-1"})
+	}))
+	defer server.Close()
+
+	log := testutil.NopLogger()
+	client := whatsapp.NewWithTimeout(log, 5*time.Second)
+	client.HTTPClient = &http.Client{
+		Transport: &testServerTransport{serverURL: server.URL},
+	}
+
+	account := testAccount(server.URL)
+	ctx := testutil.TestContext(t)
+
+	mediaID, err := client.UploadMediaStream(ctx, account, strings.NewReader(content), "application/pdf", `report "final".pdf`)
+
+	require.NoError(t, err)
+	assert.Equal(t, "media-doc-1", mediaID)
+}
+
 func TestClient_SendImageMessage(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,176 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// APIError is an error response from the Meta Graph API
+type APIError struct {
+	Code        int
+	Subcode     int
+	Message     string
+	Details     string
+	UserMessage string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API error %d: %s", e.Code, e.Message)
+	if e.Details != "" {
+		msg += " - Details: " + e.Details
+	}
+	if e.UserMessage != "" {
+		msg += " - " + e.UserMessage
+	}
+	return msg
+}
+
+// StatusError is a non-200 response from the Graph API that carries no Meta error,
+// such as a gateway error in front of the API
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrorCodeOf returns the Meta error code carried by err, or 0 if it has none
+func ErrorCodeOf(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
+
+// IsRetryable reports whether a failed request can succeed if sent again unchanged:
+// network failures, server-side errors and the Meta errors the catalog marks retryable
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return LookupError(apiErr.Code).Retryable
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == 429
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// ErrorCategory groups Meta error codes by what the sender has to do about them
+type ErrorCategory string
+
+const (
+	ErrorCategoryReengagementRequired ErrorCategory = "reengagement_required"
+	ErrorCategoryOptInRequired        ErrorCategory = "opt_in_required"
+	ErrorCategoryTemplatePaused       ErrorCategory = "template_paused"
+	ErrorCategoryTemplateInvalid      ErrorCategory = "template_invalid"
+	ErrorCategoryRateLimited          ErrorCategory = "rate_limited"
+	ErrorCategoryInvalidNumber        ErrorCategory = "invalid_number"
+	ErrorCategoryUndeliverable        ErrorCategory = "undeliverable"
+	ErrorCategoryMedia                ErrorCategory = "media"
+	ErrorCategoryInvalidRequest       ErrorCategory = "invalid_request"
+	ErrorCategoryAuthentication       ErrorCategory = "authentication"
+	ErrorCategoryAccountRestricted    ErrorCategory = "account_restricted"
+	ErrorCategoryPayment              ErrorCategory = "payment"
+	ErrorCategoryTemporary            ErrorCategory = "temporary"
+	ErrorCategoryUnknown              ErrorCategory = "unknown"
+)
+
+// ErrorInfo describes a Meta error code and how to resolve it
+type ErrorInfo struct {
+	Code        int           `json:"code"`
+	Title       string        `json:"title"`
+	Category    ErrorCategory `json:"category"`
+	Description string        `json:"description"`
+	Remediation string        `json:"remediation"`
+	Retryable   bool          `json:"retryable"` // Sending again later can succeed without changes
+}
+
+// errorCatalog lists the Cloud API error codes seen when sending messages.
+// See https://developers.facebook.com/docs/whatsapp/cloud-api/support/error-codes
+var errorCatalog = map[int]ErrorInfo{
+	// Authorization and account
+	0:      {Title: "Authentication exception", Category: ErrorCategoryAuthentication, Description: "The access token could not be validated.", Remediation: "Generate a new access token and update the WhatsApp account settings."},
+	3:      {Title: "API method not permitted", Category: ErrorCategoryAuthentication, Description: "The app lacks the capability or permission for this call.", Remediation: "Check the app's permissions in Meta Business Manager."},
+	10:     {Title: "Permission denied", Category: ErrorCategoryAuthentication, Description: "The access token lacks the whatsapp_business_messaging permission, or it was removed.", Remediation: "Grant the required permissions to the system user and regenerate the token."},
+	190:    {Title: "Access token expired", Category: ErrorCategoryAuthentication, Description: "The access token has expired or was revoked.", Remediation: "Generate a new access token, preferably a permanent system user token."},
+	200:    {Title: "Permission denied", Category: ErrorCategoryAuthentication, Description: "The app does not have permission to access this resource.", Remediation: "Check that the phone number belongs to the WhatsApp Business Account the token can access."},
+	368:    {Title: "Temporarily blocked for policy violations", Category: ErrorCategoryAccountRestricted, Description: "The account is restricted for violating WhatsApp platform policies.", Remediation: "Review the account's policy violations in WhatsApp Manager and appeal if appropriate."},
+	131031: {Title: "Account locked", Category: ErrorCategoryAccountRestricted, Description: "The business account is restricted or disabled for a policy violation, or the two-step verification PIN could not be verified.", Remediation: "Check the account status in WhatsApp Manager and resolve any violations."},
+	130497: {Title: "Business account restricted from messaging users in this country", Category: ErrorCategoryAccountRestricted, Description: "The account may not message users in the recipient's country.", Remediation: "Remove recipients in restricted countries or contact Meta support."},
+	131042: {Title: "Business eligibility payment issue", Category: ErrorCategoryPayment, Description: "There is a problem with the account's payment method.", Remediation: "Add or fix the payment method in WhatsApp Manager, then retry."},
+	133010: {Title: "Phone number not registered", Category: ErrorCategoryAuthentication, Description: "The business phone number is not registered on WhatsApp.", Remediation: "Register the phone number with the Cloud API before sending."},
+
+	// Throttling
+	4:      {Title: "Too many API calls", Category: ErrorCategoryRateLimited, Description: "The app reached its API call rate limit.", Remediation: "Slow down and retry later.", Retryable: true},
+	80007:  {Title: "Rate limit issues", Category: ErrorCategoryRateLimited, Description: "The WhatsApp Business Account reached its rate limit.", Remediation: "Slow down and retry later.", Retryable: true},
+	130429: {Title: "Rate limit hit", Category: ErrorCategoryRateLimited, Description: "The phone number reached the Cloud API throughput limit.", Remediation: "Lower the sending rate and retry later.", Retryable: true},
+	131048: {Title: "Spam rate limit hit", Category: ErrorCategoryRateLimited, Description: "Sending is limited because too many previous messages were blocked or flagged as spam.", Remediation: "Improve message quality and only message users who opted in; check the quality rating in WhatsApp Manager.", Retryable: true},
+	131056: {Title: "Pair rate limit hit", Category: ErrorCategoryRateLimited, Description: "Too many messages were sent to this recipient in a short time.", Remediation: "Wait before messaging this recipient again.", Retryable: true},
+
+	// Recipient
+	131026: {Title: "Message undeliverable", Category: ErrorCategoryInvalidNumber, Description: "The recipient's number is not a WhatsApp number, they have not accepted the latest terms, or their app is too old.", Remediation: "Confirm the number is on WhatsApp and ask the contact to update the app."},
+	131021: {Title: "Recipient cannot be sender", Category: ErrorCategoryInvalidNumber, Description: "The message was sent to the business phone number itself.", Remediation: "Send to a different number."},
+	131030: {Title: "Recipient not in allowed list", Category: ErrorCategoryInvalidNumber, Description: "Test numbers can only message numbers on their allowed list.", Remediation: "Add the recipient to the allowed list or use a production number."},
+	131047: {Title: "Re-engagement message", Category: ErrorCategoryReengagementRequired, Description: "More than 24 hours have passed since the contact last replied, so only templates can be sent.", Remediation: "Send an approved template message to reopen the conversation."},
+	470:    {Title: "Re-engagement message", Category: ErrorCategoryReengagementRequired, Description: "More than 24 hours have passed since the contact last replied, so only templates can be sent.", Remediation: "Send an approved template message to reopen the conversation."},
+	131050: {Title: "User stopped marketing messages", Category: ErrorCategoryOptInRequired, Description: "The contact opted out of marketing messages from this business.", Remediation: "Stop sending marketing templates until the contact opts in again."},
+	131049: {Title: "Not delivered to maintain ecosystem health", Category: ErrorCategoryUndeliverable, Description: "Meta chose not to deliver this marketing message to keep user engagement healthy.", Remediation: "Avoid resending right away; retry later with a more relevant message.", Retryable: true},
+
+	// Templates
+	132000: {Title: "Template parameter count mismatch", Category: ErrorCategoryTemplateInvalid, Description: "The number of parameters does not match the template.", Remediation: "Send exactly the variables the template expects."},
+	132001: {Title: "Template does not exist", Category: ErrorCategoryTemplateInvalid, Description: "The template name or language does not exist or is not approved.", Remediation: "Sync templates and check the name, language and approval status."},
+	132005: {Title: "Template hydrated text too long", Category: ErrorCategoryTemplateInvalid, Description: "The text with parameters filled in exceeds the length limit.", Remediation: "Shorten the parameter values."},
+	132007: {Title: "Template format character policy violated", Category: ErrorCategoryTemplateInvalid, Description: "Parameter values break formatting rules, e.g. contain newlines or too many spaces.", Remediation: "Remove newlines, tabs and repeated spaces from parameter values."},
+	132012: {Title: "Template parameter format mismatch", Category: ErrorCategoryTemplateInvalid, Description: "A parameter does not match the format the template expects.", Remediation: "Check parameter types, e.g. media headers need a media parameter."},
+	132015: {Title: "Template is paused", Category: ErrorCategoryTemplatePaused, Description: "The template was paused because of low quality ratings.", Remediation: "Edit the template to improve quality or use another template."},
+	132016: {Title: "Template is disabled", Category: ErrorCategoryTemplatePaused, Description: "The template was disabled after repeated pauses for low quality.", Remediation: "Create a new template with different content."},
+	132068: {Title: "Flow is blocked", Category: ErrorCategoryTemplateInvalid, Description: "The WhatsApp Flow in the message is blocked.", Remediation: "Fix the Flow in WhatsApp Manager."},
+	132069: {Title: "Flow is throttled", Category: ErrorCategoryRateLimited, Description: "The WhatsApp Flow in the message is throttled.", Remediation: "Retry later or fix the Flow's health issues.", Retryable: true},
+
+	// Request and media
+	100:    {Title: "Invalid parameter", Category: ErrorCategoryInvalidRequest, Description: "The request contains an invalid or misspelled parameter.", Remediation: "Check the message payload."},
+	131008: {Title: "Required parameter is missing", Category: ErrorCategoryInvalidRequest, Description: "The request is missing a required parameter.", Remediation: "Check the message payload."},
+	131009: {Title: "Parameter value is not valid", Category: ErrorCategoryInvalidRequest, Description: "A parameter value is invalid, such as a malformed phone number.", Remediation: "Check the phone number format and parameter values."},
+	131051: {Title: "Unsupported message type", Category: ErrorCategoryInvalidRequest, Description: "The message type is not supported.", Remediation: "Use a supported message type."},
+	131052: {Title: "Media download error", Category: ErrorCategoryMedia, Description: "The media sent by the user could not be downloaded.", Remediation: "Ask the contact to send the media again."},
+	131053: {Title: "Media upload error", Category: ErrorCategoryMedia, Description: "The media could not be uploaded, usually because of an unsupported type or size.", Remediation: "Check the media's file type and size limits."},
+
+	// Transient
+	1:      {Title: "API unknown", Category: ErrorCategoryTemporary, Description: "The request failed for an unknown reason, possibly a temporary outage.", Remediation: "Retry later; check the WhatsApp Business API status page if it persists.", Retryable: true},
+	2:      {Title: "API service", Category: ErrorCategoryTemporary, Description: "Temporary downtime or overload at Meta.", Remediation: "Retry later.", Retryable: true},
+	131000: {Title: "Something went wrong", Category: ErrorCategoryTemporary, Description: "The message failed for an unknown reason.", Remediation: "Retry; contact Meta support if it persists.", Retryable: true},
+	131016: {Title: "Service unavailable", Category: ErrorCategoryTemporary, Description: "A Meta service is temporarily unavailable.", Remediation: "Retry later.", Retryable: true},
+}
+
+// LookupError returns the catalog entry for a Meta error code. Unknown codes return an
+// entry in the unknown category.
+func LookupError(code int) ErrorInfo {
+	if info, ok := errorCatalog[code]; ok {
+		info.Code = code
+		return info
+	}
+	return ErrorInfo{
+		Code:        code,
+		Title:       "Unknown error",
+		Category:    ErrorCategoryUnknown,
+		Description: "This error code is not in the catalog.",
+		Remediation: "Check the error message and Meta's error code reference.",
+	}
+}
+
+// ErrorCatalog returns every known error code, sorted by code
+func ErrorCatalog() []ErrorInfo {
+	catalog := make([]ErrorInfo, 0, len(errorCatalog))
+	for code := range errorCatalog {
+		catalog = append(catalog, LookupError(code))
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Code < catalog[j].Code })
+	return catalog
+}
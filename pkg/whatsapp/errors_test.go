@@ -0,0 +1,88 @@
+package whatsapp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_APIErrorCarriesCode(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"Re-engagement message","code":131047,"error_data":{"details":"More than 24 hours have passed"}}}`))
+	}))
+	defer server.Close()
+
+	client := whatsapp.NewWithTimeout(testutil.NopLogger(), 5*time.Second)
+	client.HTTPClient = &http.Client{Transport: &testServerTransport{serverURL: server.URL}}
+
+	_, err := client.SendTextMessage(testutil.TestContext(t), testAccount(server.URL), "1234567890", "Hello")
+	require.Error(t, err)
+	assert.Equal(t, "failed to send text message: API error 131047: Re-engagement message - Details: More than 24 hours have passed", err.Error())
+	assert.Equal(t, 131047, whatsapp.ErrorCodeOf(err))
+	assert.Equal(t, 0, whatsapp.ErrorCodeOf(fmt.Errorf("network down")))
+}
+
+func TestLookupError(t *testing.T) {
+	t.Parallel()
+
+	cases := map[int]whatsapp.ErrorCategory{
+		131047: whatsapp.ErrorCategoryReengagementRequired,
+		131050: whatsapp.ErrorCategoryOptInRequired,
+		132015: whatsapp.ErrorCategoryTemplatePaused,
+		130429: whatsapp.ErrorCategoryRateLimited,
+		131026: whatsapp.ErrorCategoryInvalidNumber,
+	}
+	for code, category := range cases {
+		info := whatsapp.LookupError(code)
+		assert.Equal(t, code, info.Code)
+		assert.Equal(t, category, info.Category, code)
+		assert.NotEmpty(t, info.Remediation, code)
+	}
+
+	assert.True(t, whatsapp.LookupError(130429).Retryable)
+	assert.False(t, whatsapp.LookupError(131026).Retryable)
+
+	unknown := whatsapp.LookupError(999999)
+	assert.Equal(t, 999999, unknown.Code)
+	assert.Equal(t, whatsapp.ErrorCategoryUnknown, unknown.Category)
+}
+
+func TestErrorCatalog(t *testing.T) {
+	t.Parallel()
+
+	catalog := whatsapp.ErrorCatalog()
+	require.NotEmpty(t, catalog)
+	assert.True(t, sort.SliceIsSorted(catalog, func(i, j int) bool { return catalog[i].Code < catalog[j].Code }))
+	for _, info := range catalog {
+		assert.NotEmpty(t, info.Title, info.Code)
+		assert.NotEmpty(t, info.Description, info.Code)
+		assert.NotEqual(t, whatsapp.ErrorCategoryUnknown, info.Category, info.Code)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, whatsapp.IsRetryable(fmt.Errorf("failed to send text message: %w", &whatsapp.APIError{Code: 130429})))
+	assert.False(t, whatsapp.IsRetryable(&whatsapp.APIError{Code: 131026}))
+	assert.True(t, whatsapp.IsRetryable(&whatsapp.StatusError{StatusCode: http.StatusBadGateway}))
+	assert.False(t, whatsapp.IsRetryable(&whatsapp.StatusError{StatusCode: http.StatusNotFound}))
+	assert.False(t, whatsapp.IsRetryable(fmt.Errorf("template is required for template messages")))
+
+	// Connection failures come back as *url.Error
+	client := whatsapp.NewWithBaseURL(testutil.NopLogger(), "http://127.0.0.1:1")
+	_, err := client.SendTextMessage(testutil.TestContext(t), testAccount(""), "1234567890", "Hello")
+	require.Error(t, err)
+	assert.True(t, whatsapp.IsRetryable(err))
+}
@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // SendTextMessage sends a text message to a phone number
-func (c *Client) SendTextMessage(ctx context.Context, account *Account, phoneNumber, text string) (string, error) {
+func (c *Client) SendTextMessage(ctx context.Context, account *Account, phoneNumber, text string, opts ...SendOption) (string, error) {
 	payload := map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"recipient_type":    "individual",
@@ -25,7 +26,7 @@ func (c *Client) SendTextMessage(ctx context.Context, account *Account, phoneNum
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending text message", "phone", phoneNumber, "url", url)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		c.Log.Error("Failed to send text message", "error", err, "phone", phoneNumber)
 		return "", fmt.Errorf("failed to send text message: %w", err)
@@ -47,7 +48,7 @@ func (c *Client) SendTextMessage(ctx context.Context, account *Account, phoneNum
 
 // SendInteractiveButtons sends an interactive message with buttons or list
 // If buttons <= 3, sends as buttons; if 4-10, sends as list
-func (c *Client) SendInteractiveButtons(ctx context.Context, account *Account, phoneNumber, bodyText string, buttons []Button) (string, error) {
+func (c *Client) SendInteractiveButtons(ctx context.Context, account *Account, phoneNumber, bodyText string, buttons []Button, opts ...SendOption) (string, error) {
 	if len(buttons) == 0 {
 		return "", fmt.Errorf("at least one button is required")
 	}
@@ -125,7 +126,7 @@ func (c *Client) SendInteractiveButtons(ctx context.Context, account *Account, p
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending interactive message", "phone", phoneNumber, "button_count", len(buttons))
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		c.Log.Error("Failed to send interactive message", "error", err, "phone", phoneNumber)
 		return "", fmt.Errorf("failed to send interactive message: %w", err)
@@ -147,7 +148,7 @@ func (c *Client) SendInteractiveButtons(ctx context.Context, account *Account, p
 
 // SendCTAURLButton sends an interactive message with a CTA URL button
 // This opens a URL when clicked instead of sending a reply
-func (c *Client) SendCTAURLButton(ctx context.Context, account *Account, phoneNumber, bodyText, buttonText, url string) (string, error) {
+func (c *Client) SendCTAURLButton(ctx context.Context, account *Account, phoneNumber, bodyText, buttonText, url string, opts ...SendOption) (string, error) {
 	if buttonText == "" || url == "" {
 		return "", fmt.Errorf("button text and URL are required")
 	}
@@ -182,7 +183,7 @@ func (c *Client) SendCTAURLButton(ctx context.Context, account *Account, phoneNu
 	apiURL := c.buildMessagesURL(account)
 	c.Log.Debug("Sending CTA URL button message", "phone", phoneNumber, "url", url)
 
-	respBody, err := c.doRequest(ctx, "POST", apiURL, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, apiURL, payload, opts...)
 	if err != nil {
 		c.Log.Error("Failed to send CTA URL button message", "error", err, "phone", phoneNumber)
 		return "", fmt.Errorf("failed to send CTA URL button message: %w", err)
@@ -219,7 +220,7 @@ type TemplateParam struct {
 }
 
 // SendTemplateMessage sends a template message
-func (c *Client) SendTemplateMessage(ctx context.Context, account *Account, phoneNumber, templateName, languageCode string, bodyParams map[string]string) (string, error) {
+func (c *Client) SendTemplateMessage(ctx context.Context, account *Account, phoneNumber, templateName, languageCode string, bodyParams map[string]string, opts ...SendOption) (string, error) {
 	template := map[string]interface{}{
 		"name": templateName,
 		"language": map[string]interface{}{
@@ -229,38 +230,10 @@ func (c *Client) SendTemplateMessage(ctx context.Context, account *Account, phon
 
 	// Add body parameters if provided
 	if len(bodyParams) > 0 {
-		// Check if using named parameters (non-numeric keys like "name", "order_id")
-		isNamedParams := false
-		for key := range bodyParams {
-			if _, err := strconv.Atoi(key); err != nil {
-				isNamedParams = true
-				break
-			}
-		}
-
-		// Get sorted keys for deterministic ordering
-		keys := make([]string, 0, len(bodyParams))
-		for k := range bodyParams {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-
-		params := make([]map[string]interface{}, 0, len(bodyParams))
-		for _, key := range keys {
-			param := map[string]interface{}{
-				"type": "text",
-				"text": bodyParams[key],
-			}
-			// For named parameters, include the parameter_name field
-			if isNamedParams {
-				param["parameter_name"] = key
-			}
-			params = append(params, param)
-		}
 		template["components"] = []map[string]interface{}{
 			{
 				"type":       "body",
-				"parameters": params,
+				"parameters": BodyParameters(bodyParams),
 			},
 		}
 	}
@@ -275,7 +248,7 @@ func (c *Client) SendTemplateMessage(ctx context.Context, account *Account, phon
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending template message", "phone", phoneNumber, "template", templateName)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		c.Log.Error("Failed to send template message", "error", err, "phone", phoneNumber, "template", templateName)
 		return "", fmt.Errorf("failed to send template message: %w", err)
@@ -299,7 +272,7 @@ func (c *Client) SendTemplateMessage(ctx context.Context, account *Account, phon
 // flowID is the Meta Flow ID, headerText is optional header, bodyText is the message body,
 // ctaText is the button text, flowToken is a unique token for tracking the flow response,
 // and firstScreen is the name of the first screen to navigate to
-func (c *Client) SendFlowMessage(ctx context.Context, account *Account, phoneNumber, flowID, headerText, bodyText, ctaText, flowToken, firstScreen string) (string, error) {
+func (c *Client) SendFlowMessage(ctx context.Context, account *Account, phoneNumber, flowID, headerText, bodyText, ctaText, flowToken, firstScreen string, opts ...SendOption) (string, error) {
 	if flowID == "" {
 		return "", fmt.Errorf("flow ID is required")
 	}
@@ -360,7 +333,7 @@ func (c *Client) SendFlowMessage(ctx context.Context, account *Account, phoneNum
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending flow message", "phone", phoneNumber, "flow_id", flowID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		c.Log.Error("Failed to send flow message", "error", err, "phone", phoneNumber, "flow_id", flowID)
 		return "", fmt.Errorf("failed to send flow message: %w", err)
@@ -380,8 +353,81 @@ func (c *Client) SendFlowMessage(ctx context.Context, account *Account, phoneNum
 	return messageID, nil
 }
 
+// BodyParameters converts template body params into Meta's parameter list.
+// Keys are sorted for deterministic ordering; non-numeric keys are sent as named parameters.
+func BodyParameters(bodyParams map[string]string) []map[string]interface{} {
+	// Check if using named parameters (non-numeric keys like "name", "order_id")
+	isNamedParams := false
+	for key := range bodyParams {
+		if _, err := strconv.Atoi(key); err != nil {
+			isNamedParams = true
+			break
+		}
+	}
+
+	keys := make([]string, 0, len(bodyParams))
+	for k := range bodyParams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make([]map[string]interface{}, 0, len(bodyParams))
+	for _, key := range keys {
+		param := map[string]interface{}{
+			"type": "text",
+			"text": bodyParams[key],
+		}
+		if isNamedParams {
+			param["parameter_name"] = key
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// FlowButtonIndex returns the position of the FLOW button among a template's
+// buttons, or -1 if the template has none. Buttons without text are skipped
+// the same way SubmitTemplate skips them, so the index matches Meta's.
+func FlowButtonIndex(buttons []interface{}) int {
+	index := 0
+	for _, btn := range buttons {
+		btnMap, ok := btn.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if text, _ := btnMap["text"].(string); text == "" {
+			continue
+		}
+		if t, _ := btnMap["type"].(string); strings.EqualFold(t, "FLOW") {
+			return index
+		}
+		index++
+	}
+	return -1
+}
+
+// FlowButtonComponent builds the send-time component for a template FLOW button.
+// The flow token is echoed back in the flow's nfm_reply so the response can be
+// matched to this send; actionData pre-fills the first screen.
+func FlowButtonComponent(index int, flowToken string, actionData map[string]interface{}) map[string]interface{} {
+	action := map[string]interface{}{
+		"flow_token": flowToken,
+	}
+	if len(actionData) > 0 {
+		action["flow_action_data"] = actionData
+	}
+	return map[string]interface{}{
+		"type":     "button",
+		"sub_type": "flow",
+		"index":    strconv.Itoa(index),
+		"parameters": []map[string]interface{}{
+			{"type": "action", "action": action},
+		},
+	}
+}
+
 // SendTemplateMessageWithComponents sends a template message with full component control
-func (c *Client) SendTemplateMessageWithComponents(ctx context.Context, account *Account, phoneNumber, templateName, languageCode string, components []map[string]interface{}) (string, error) {
+func (c *Client) SendTemplateMessageWithComponents(ctx context.Context, account *Account, phoneNumber, templateName, languageCode string, components []map[string]interface{}, opts ...SendOption) (string, error) {
 	template := map[string]interface{}{
 		"name": templateName,
 		"language": map[string]interface{}{
@@ -403,7 +449,7 @@ func (c *Client) SendTemplateMessageWithComponents(ctx context.Context, account
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending template message with components", "phone", phoneNumber, "template", templateName)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.sendMessage(ctx, account, url, payload, opts...)
 	if err != nil {
 		c.Log.Error("Failed to send template message", "error", err, "phone", phoneNumber, "template", templateName)
 		return "", fmt.Errorf("failed to send template message: %w", err)
@@ -461,3 +461,48 @@ func TestClient_SendTemplateMessageWithComponents(t *testing.T) {
 	assert.Len(t, sentComponents, 2)
 }
 
+
+func TestFlowButtonIndex(t *testing.T) {
+	t.Parallel()
+
+	buttons := []interface{}{
+		map[string]interface{}{"type": "QUICK_REPLY", "text": "Stop"},
+		map[string]interface{}{"type": "URL", "text": ""},
+		map[string]interface{}{"type": "flow", "text": "Book now", "flow_id": "123"},
+	}
+	// The empty button is skipped at submission, so the flow button is second
+	assert.Equal(t, 1, whatsapp.FlowButtonIndex(buttons))
+	assert.Equal(t, -1, whatsapp.FlowButtonIndex(buttons[:2]))
+	assert.Equal(t, -1, whatsapp.FlowButtonIndex(nil))
+}
+
+func TestFlowButtonComponent(t *testing.T) {
+	t.Parallel()
+
+	component := whatsapp.FlowButtonComponent(2, "tok-1", map[string]interface{}{"name": "Ann"})
+	assert.Equal(t, "button", component["type"])
+	assert.Equal(t, "flow", component["sub_type"])
+	assert.Equal(t, "2", component["index"])
+
+	params := component["parameters"].([]map[string]interface{})
+	require.Len(t, params, 1)
+	action := params[0]["action"].(map[string]interface{})
+	assert.Equal(t, "tok-1", action["flow_token"])
+	assert.Equal(t, map[string]interface{}{"name": "Ann"}, action["flow_action_data"])
+
+	action = whatsapp.FlowButtonComponent(0, "tok-2", nil)["parameters"].([]map[string]interface{})[0]["action"].(map[string]interface{})
+	assert.NotContains(t, action, "flow_action_data")
+}
+
+func TestBodyParameters(t *testing.T) {
+	t.Parallel()
+
+	positional := whatsapp.BodyParameters(map[string]string{"2": "b", "1": "a"})
+	require.Len(t, positional, 2)
+	assert.Equal(t, "a", positional[0]["text"])
+	assert.NotContains(t, positional[0], "parameter_name")
+
+	named := whatsapp.BodyParameters(map[string]string{"name": "Ann"})
+	require.Len(t, named, 1)
+	assert.Equal(t, "name", named[0]["parameter_name"])
+}
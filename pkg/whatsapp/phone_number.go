@@ -0,0 +1,75 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const phoneNumberStatusFields = "display_phone_number,verified_name,status,code_verification_status,name_status,platform_type,quality_rating,messaging_limit_tier"
+
+// buildPhoneNumberURL builds the URL for the account's phone number node
+func (c *Client) buildPhoneNumberURL(account *Account) string {
+	return fmt.Sprintf("%s/%s/%s", c.getBaseURL(), account.APIVersion, account.PhoneID)
+}
+
+// GetPhoneNumberStatus fetches the registration and verification status of the account's phone number
+func (c *Client) GetPhoneNumberStatus(ctx context.Context, account *Account) (*PhoneNumberStatus, error) {
+	apiURL := c.buildPhoneNumberURL(account) + "?fields=" + phoneNumberStatusFields
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, apiURL, nil, account.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var status PhoneNumberStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &status, nil
+}
+
+// SetTwoStepPIN sets or changes the two-step verification PIN of the account's phone number
+func (c *Client) SetTwoStepPIN(ctx context.Context, account *Account, pin string) error {
+	body := map[string]string{
+		"pin": pin,
+	}
+
+	_, err := c.doRequest(ctx, http.MethodPost, c.buildPhoneNumberURL(account), body, account.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	c.Log.Info("Two-step verification PIN updated", "phone_id", account.PhoneID)
+	return nil
+}
+
+// RegisterPhoneNumber registers the account's phone number for Cloud API use.
+// The pin becomes the two-step verification PIN if none is set, otherwise it must match it.
+func (c *Client) RegisterPhoneNumber(ctx context.Context, account *Account, pin string) error {
+	body := map[string]string{
+		"messaging_product": "whatsapp",
+		"pin":               pin,
+	}
+
+	_, err := c.doRequest(ctx, http.MethodPost, c.buildPhoneNumberURL(account)+"/register", body, account.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	c.Log.Info("Phone number registered", "phone_id", account.PhoneID)
+	return nil
+}
+
+// DeregisterPhoneNumber deregisters the account's phone number from the Cloud API
+func (c *Client) DeregisterPhoneNumber(ctx context.Context, account *Account) error {
+	_, err := c.doRequest(ctx, http.MethodPost, c.buildPhoneNumberURL(account)+"/deregister", nil, account.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	c.Log.Info("Phone number deregistered", "phone_id", account.PhoneID)
+	return nil
+}
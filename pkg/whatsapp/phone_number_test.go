@@ -0,0 +1,94 @@
+package whatsapp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetPhoneNumberStatus(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Contains(t, r.URL.Path, "/123456789")
+		assert.Contains(t, r.URL.Query().Get("fields"), "platform_type")
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"id":                       "123456789",
+			"status":                   "CONNECTED",
+			"code_verification_status": "VERIFIED",
+			"platform_type":            "CLOUD_API",
+		})
+	})
+
+	status, err := client.GetPhoneNumberStatus(testutil.TestContext(t), account)
+
+	require.NoError(t, err)
+	assert.Equal(t, "CONNECTED", status.Status)
+	assert.Equal(t, "VERIFIED", status.CodeVerificationStatus)
+	assert.Equal(t, "CLOUD_API", status.PlatformType)
+}
+
+func TestClient_RegisterPhoneNumber(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Contains(t, r.URL.Path, "/123456789/register")
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "whatsapp", body["messaging_product"])
+		assert.Equal(t, "123456", body["pin"])
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	})
+
+	err := client.RegisterPhoneNumber(testutil.TestContext(t), account, "123456")
+
+	require.NoError(t, err)
+}
+
+func TestClient_SetTwoStepPIN(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Contains(t, r.URL.Path, "/123456789")
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "654321", body["pin"])
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	})
+
+	err := client.SetTwoStepPIN(testutil.TestContext(t), account, "654321")
+
+	require.NoError(t, err)
+}
+
+func TestClient_DeregisterPhoneNumber_APIError(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.URL.Path, "/123456789/deregister")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": 133010, "message": "Phone number not registered"},
+		})
+	})
+
+	err := client.DeregisterPhoneNumber(testutil.TestContext(t), account)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not registered")
+}
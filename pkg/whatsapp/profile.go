@@ -0,0 +1,63 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const businessProfileFields = "about,address,description,email,profile_picture_url,websites,vertical"
+
+// BusinessVerticals lists the industry values accepted for a business profile
+var BusinessVerticals = []string{
+	"UNDEFINED", "OTHER", "AUTO", "BEAUTY", "APPAREL", "EDU", "ENTERTAIN", "EVENT_PLAN",
+	"FINANCE", "GROCERY", "GOVT", "HOTEL", "HEALTH", "NONPROFIT", "PROF_SERVICES",
+	"RETAIL", "TRAVEL", "RESTAURANT", "NOT_A_BIZ",
+}
+
+// buildBusinessProfileURL builds the business profile endpoint URL for a phone number
+func (c *Client) buildBusinessProfileURL(account *Account) string {
+	return fmt.Sprintf("%s/%s/%s/whatsapp_business_profile", c.getBaseURL(), account.APIVersion, account.PhoneID)
+}
+
+// GetBusinessProfile fetches the business profile of the account's phone number
+func (c *Client) GetBusinessProfile(ctx context.Context, account *Account) (*BusinessProfile, error) {
+	apiURL := c.buildBusinessProfileURL(account) + "?fields=" + businessProfileFields
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, apiURL, nil, account.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp BusinessProfileResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(resp.Data) == 0 {
+		return &BusinessProfile{}, nil
+	}
+	return &resp.Data[0], nil
+}
+
+// UpdateBusinessProfile updates the business profile of the account's phone number
+func (c *Client) UpdateBusinessProfile(ctx context.Context, account *Account, update *BusinessProfileUpdate) error {
+	apiURL := c.buildBusinessProfileURL(account)
+
+	payload := struct {
+		MessagingProduct string `json:"messaging_product"`
+		*BusinessProfileUpdate
+	}{
+		MessagingProduct:      "whatsapp",
+		BusinessProfileUpdate: update,
+	}
+
+	_, err := c.doRequest(ctx, http.MethodPost, apiURL, payload, account.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	c.Log.Info("Business profile updated", "phone_id", account.PhoneID)
+	return nil
+}
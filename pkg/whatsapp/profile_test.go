@@ -0,0 +1,99 @@
+package whatsapp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*whatsapp.Client, *whatsapp.Account) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := whatsapp.NewWithTimeout(testutil.NopLogger(), 5*time.Second)
+	client.HTTPClient = &http.Client{
+		Transport: &testServerTransport{serverURL: server.URL},
+	}
+	return client, testAccount(server.URL)
+}
+
+func TestClient_GetBusinessProfile(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Contains(t, r.URL.Path, "/123456789/whatsapp_business_profile")
+		assert.Contains(t, r.URL.Query().Get("fields"), "websites")
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []map[string]interface{}{
+				{
+					"about":    "Open 9-5",
+					"email":    "hello@example.com",
+					"websites": []string{"https://example.com"},
+					"vertical": "RETAIL",
+				},
+			},
+		})
+	})
+
+	profile, err := client.GetBusinessProfile(testutil.TestContext(t), account)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Open 9-5", profile.About)
+	assert.Equal(t, "hello@example.com", profile.Email)
+	assert.Equal(t, []string{"https://example.com"}, profile.Websites)
+	assert.Equal(t, "RETAIL", profile.Vertical)
+}
+
+func TestClient_UpdateBusinessProfile(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Contains(t, r.URL.Path, "/whatsapp_business_profile")
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "whatsapp", body["messaging_product"])
+		assert.Equal(t, "New about", body["about"])
+		// Unset fields must not be sent, otherwise they would be cleared
+		assert.NotContains(t, body, "email")
+		assert.NotContains(t, body, "profile_picture_handle")
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	})
+
+	about := "New about"
+	err := client.UpdateBusinessProfile(testutil.TestContext(t), account, &whatsapp.BusinessProfileUpdate{About: &about})
+
+	require.NoError(t, err)
+}
+
+func TestClient_UpdateBusinessProfile_APIError(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": 100, "message": "Invalid parameter"},
+		})
+	})
+
+	vertical := "RETAIL"
+	err := client.UpdateBusinessProfile(testutil.TestContext(t), account, &whatsapp.BusinessProfileUpdate{Vertical: &vertical})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid parameter")
+}
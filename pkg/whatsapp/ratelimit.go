@@ -0,0 +1,230 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// rateLimitKeyPrefix prefixes the token bucket key of each phone number
+	rateLimitKeyPrefix = "whatsapp:ratelimit:"
+	// sendRetries is how many times a send rejected by Meta's throughput limit is retried
+	sendRetries = 3
+	// sendRetryBackoff is the wait before the first retry; it doubles after each attempt
+	sendRetryBackoff = time.Second
+)
+
+// rateLimitErrorCodes are Meta's errors for an app, user or business account that made
+// too many API calls; they clear once the usage window moves on
+var rateLimitErrorCodes = []int{4, 17, 613, 80007, 80008}
+
+// defaultRateLimitWait is how long to back off when Meta doesn't say when access returns
+const defaultRateLimitWait = 5 * time.Minute
+
+// throughputErrorCodes are Meta's rate limit errors that clear once the number sends
+// slower. Per-recipient and spam limits aren't retried.
+var throughputErrorCodes = []int{4, 80007, 130429}
+
+// RateLimiter paces outbound messages so a phone number stays within its throughput
+type RateLimiter interface {
+	// Wait blocks until the phone number may send another message at rate messages
+	// per second, or the context is done
+	Wait(ctx context.Context, phoneID string, rate int) error
+}
+
+// reserveScript takes a token from the phone number's bucket and returns how many
+// milliseconds the caller must wait before using it. The bucket holds a second's worth
+// of tokens and may go negative, so concurrent senders queue up in order instead of
+// racing for the next free token. Redis' clock is used so every host agrees on time.
+var reserveScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = rate
+	ts = now
+end
+tokens = math.min(rate, tokens + (now - ts) * rate / 1000) - 1
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+local wait = 0
+if tokens < 0 then
+	wait = math.ceil(-tokens * 1000 / rate)
+end
+redis.call('PEXPIRE', KEYS[1], wait + 2000)
+return wait
+`)
+
+// RedisRateLimiter is a token bucket per phone number kept in Redis, so the server and
+// every worker sending for a number share one limit. Messages over the rate wait for
+// their turn instead of failing.
+type RedisRateLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimiter creates a rate limiter backed by Redis
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client}
+}
+
+// Wait reserves the phone number's next send slot and sleeps until it is due
+func (l *RedisRateLimiter) Wait(ctx context.Context, phoneID string, rate int) error {
+	if rate <= 0 {
+		return nil
+	}
+	waitMs, err := reserveScript.Run(ctx, l.client, []string{rateLimitKeyPrefix + phoneID}, rate).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to reserve send slot: %w", err)
+	}
+	if waitMs <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(waitMs) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SetRateLimit paces message sends through limiter at messagesPerSecond per phone
+// number. Accounts with their own MessagesPerSecond use that instead; a rate of zero
+// or less sends without limiting.
+func (c *Client) SetRateLimit(limiter RateLimiter, messagesPerSecond int) {
+	c.limiter = limiter
+	c.messagesPerSecond = messagesPerSecond
+}
+
+// sendMessage posts a message payload once the phone number's rate limit allows it.
+// If the limiter itself fails the message is sent anyway, since Meta enforces the hard
+// limit on its side; sends Meta rejects for throughput are retried with backoff.
+func (c *Client) sendMessage(ctx context.Context, account *Account, url string, payload map[string]interface{}, opts ...SendOption) ([]byte, error) {
+	for _, opt := range opts {
+		opt(payload)
+	}
+	backoff := sendRetryBackoff
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForSendSlot(ctx, account); err != nil {
+			return nil, err
+		}
+		respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+		if err == nil || attempt == sendRetries || !slices.Contains(throughputErrorCodes, ErrorCodeOf(err)) {
+			return respBody, err
+		}
+
+		c.Log.Warn("Send hit Meta's throughput limit, retrying", "phone_id", account.PhoneID, "attempt", attempt+1, "backoff", backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// waitForSendSlot waits for the phone number's rate limit, using the account's own rate
+// when it has one
+func (c *Client) waitForSendSlot(ctx context.Context, account *Account) error {
+	if c.limiter == nil {
+		return nil
+	}
+	rate := c.messagesPerSecond
+	if account.MessagesPerSecond > 0 {
+		rate = account.MessagesPerSecond
+	}
+	if err := c.limiter.Wait(ctx, account.PhoneID, rate); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("waiting for send rate limit: %w", ctx.Err())
+		}
+		c.Log.Warn("Send rate limiter unavailable, sending without it", "error", err, "phone_id", account.PhoneID)
+	}
+	return nil
+}
+
+// RateLimitError is returned when Meta refuses a request because the app or business
+// account reached its rate limit
+type RateLimitError struct {
+	RetryAfter time.Duration // How long to wait before calling again
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by Meta, retry in %s: %v", e.RetryAfter, e.Err)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// rateLimited reports whether err is Meta refusing a request for its rate limit
+func rateLimited(err error) bool {
+	if slices.Contains(rateLimitErrorCodes, ErrorCodeOf(err)) {
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests
+}
+
+// useCaseUsage is one entry of Meta's X-Business-Use-Case-Usage header. The counts are
+// percentages of the limit.
+type useCaseUsage struct {
+	CallCount                   int `json:"call_count"`
+	TotalCPUTime                int `json:"total_cputime"`
+	TotalTime                   int `json:"total_time"`
+	EstimatedTimeToRegainAccess int `json:"estimated_time_to_regain_access"` // Minutes
+}
+
+// businessUseCaseUsage parses the X-Business-Use-Case-Usage header, keyed by business
+// object ID
+func businessUseCaseUsage(header http.Header) []useCaseUsage {
+	var byObject map[string][]useCaseUsage
+	if err := json.Unmarshal([]byte(header.Get("X-Business-Use-Case-Usage")), &byObject); err != nil {
+		return nil
+	}
+	var usage []useCaseUsage
+	for _, entries := range byObject {
+		usage = append(usage, entries...)
+	}
+	return usage
+}
+
+// usagePercent returns the highest share of a rate limit used so far, from Meta's
+// business use case and app usage headers
+func usagePercent(header http.Header) int {
+	highest := 0
+	for _, u := range businessUseCaseUsage(header) {
+		highest = max(highest, u.CallCount, u.TotalCPUTime, u.TotalTime)
+	}
+	var app useCaseUsage
+	if err := json.Unmarshal([]byte(header.Get("X-App-Usage")), &app); err == nil {
+		highest = max(highest, app.CallCount, app.TotalCPUTime, app.TotalTime)
+	}
+	return highest
+}
+
+// regainAccessAfter returns how long Meta's usage header says to wait before calling
+// again, or defaultRateLimitWait when it doesn't say
+func regainAccessAfter(header http.Header) time.Duration {
+	minutes := 0
+	for _, u := range businessUseCaseUsage(header) {
+		minutes = max(minutes, u.EstimatedTimeToRegainAccess)
+	}
+	if minutes <= 0 {
+		return defaultRateLimitWait
+	}
+	return time.Duration(minutes) * time.Minute
+}
@@ -0,0 +1,110 @@
+package whatsapp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLimiter records the rates it was asked to wait for
+type recordingLimiter struct {
+	mu    sync.Mutex
+	rates []int
+}
+
+func (l *recordingLimiter) Wait(ctx context.Context, phoneID string, rate int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rates = append(l.rates, rate)
+	return nil
+}
+
+func TestClient_SendRateLimit(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"messages":[{"id":"wamid.1"}]}`))
+	}))
+	defer server.Close()
+
+	limiter := &recordingLimiter{}
+	client := whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+	client.SetRateLimit(limiter, 80)
+
+	account := testAccount(server.URL)
+	_, err := client.SendTextMessage(context.Background(), account, "1234567890", "Hello")
+	require.NoError(t, err)
+
+	account.MessagesPerSecond = 20
+	_, err = client.SendTextMessage(context.Background(), account, "1234567890", "Hello")
+	require.NoError(t, err)
+
+	// Read receipts don't count towards the messaging rate
+	require.NoError(t, client.MarkMessageRead(context.Background(), account, "wamid.1"))
+
+	assert.Equal(t, []int{80, 20}, limiter.rates)
+}
+
+func TestClient_SendRetriesThroughputErrors(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"Rate limit hit","code":130429}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"messages":[{"id":"wamid.2"}]}`))
+	}))
+	defer server.Close()
+
+	client := whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+	messageID, err := client.SendTextMessage(context.Background(), testAccount(server.URL), "1234567890", "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "wamid.2", messageID)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRedisRateLimiter_Wait(t *testing.T) {
+	rdb := testutil.SetupTestRedis(t)
+	if rdb == nil {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+
+	limiter := whatsapp.NewRedisRateLimiter(rdb)
+	phoneID := "ratelimit-" + uuid.NewString()
+	ctx := context.Background()
+
+	// The bucket starts with a second's worth of sends; the rest are paced at the rate
+	start := time.Now()
+	for range 15 {
+		require.NoError(t, limiter.Wait(ctx, phoneID, 10))
+	}
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 400*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second)
+
+	// A cancelled wait gives up instead of sending
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for range 20 {
+		if err := limiter.Wait(cancelled, phoneID, 10); err != nil {
+			assert.ErrorIs(t, err, context.Canceled)
+			return
+		}
+	}
+	t.Fatal("expected a cancelled wait to fail")
+}
@@ -0,0 +1,14 @@
+package whatsapp
+
+// SendOption adjusts the payload of a message send
+type SendOption func(payload map[string]interface{})
+
+// ReplyTo makes a message quote the message with the given WhatsApp message ID, so the
+// recipient sees it as a reply to that message. An empty ID sends a regular message.
+func ReplyTo(wamid string) SendOption {
+	return func(payload map[string]interface{}) {
+		if wamid != "" {
+			payload["context"] = map[string]interface{}{"message_id": wamid}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package whatsapp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SendMessage_ReplyTo(t *testing.T) {
+	t.Parallel()
+
+	const to = "1234567890"
+	sends := []struct {
+		name string
+		send func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error)
+	}{
+		{"text", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendTextMessage(ctx, account, to, "Thanks!", opts...)
+		}},
+		{"image", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendImageMessage(ctx, account, to, "media-1", "Receipt", opts...)
+		}},
+		{"document", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendDocumentMessage(ctx, account, to, "media-1", "invoice.pdf", "Invoice", opts...)
+		}},
+		{"video", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendVideoMessage(ctx, account, to, "media-1", "", opts...)
+		}},
+		{"audio", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendAudioMessage(ctx, account, to, "media-1", opts...)
+		}},
+		{"buttons", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendInteractiveButtons(ctx, account, to, "Pick one", []whatsapp.Button{{ID: "yes", Title: "Yes"}}, opts...)
+		}},
+		{"cta url", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendCTAURLButton(ctx, account, to, "Track your order", "Track", "https://example.com/orders/1", opts...)
+		}},
+		{"template", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendTemplateMessage(ctx, account, to, "order_update", "en", map[string]string{"1": "42"}, opts...)
+		}},
+		{"template with components", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendTemplateMessageWithComponents(ctx, account, to, "order_update", "en", nil, opts...)
+		}},
+		{"flow", func(ctx context.Context, c *whatsapp.Client, account *whatsapp.Account, opts ...whatsapp.SendOption) (string, error) {
+			return c.SendFlowMessage(ctx, account, to, "flow-1", "Book", "Book a visit", "Start", "token", "WELCOME", opts...)
+		}},
+	}
+
+	for _, tt := range sends {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var bodies []map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				bodies = append(bodies, body)
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"messages": []map[string]string{{"id": "wamid.test"}},
+				})
+			}))
+			defer server.Close()
+
+			client := whatsapp.NewWithTimeout(testutil.NopLogger(), 5*time.Second)
+			client.HTTPClient = &http.Client{
+				Transport: &testServerTransport{serverURL: server.URL},
+			}
+			account := &whatsapp.Account{
+				PhoneID:     "123456789",
+				BusinessID:  "987654321",
+				APIVersion:  "v21.0",
+				AccessToken: "test-token",
+			}
+			ctx := testutil.TestContext(t)
+
+			_, err := tt.send(ctx, client, account, whatsapp.ReplyTo("wamid.original"))
+			require.NoError(t, err)
+			_, err = tt.send(ctx, client, account)
+			require.NoError(t, err)
+			_, err = tt.send(ctx, client, account, whatsapp.ReplyTo(""))
+			require.NoError(t, err)
+
+			require.Len(t, bodies, 3)
+			assert.Equal(t, map[string]interface{}{"message_id": "wamid.original"}, bodies[0]["context"])
+			assert.NotContains(t, bodies[1], "context")
+			assert.NotContains(t, bodies[2], "context")
+		})
+	}
+}
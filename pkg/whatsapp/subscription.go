@@ -0,0 +1,74 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// buildSubscribedAppsURL builds the subscribed_apps endpoint URL for the WhatsApp Business Account
+func (c *Client) buildSubscribedAppsURL(account *Account) string {
+	return fmt.Sprintf("%s/%s/%s/subscribed_apps", c.getBaseURL(), account.APIVersion, account.BusinessID)
+}
+
+// SubscribeApp subscribes the app owning the access token to the WABA's webhooks.
+// If callbackURL is set, webhooks for this WABA are sent there instead of the app's default callback.
+func (c *Client) SubscribeApp(ctx context.Context, account *Account, callbackURL, verifyToken string) error {
+	var body interface{}
+	if callbackURL != "" {
+		body = map[string]string{
+			"override_callback_uri": callbackURL,
+			"verify_token":          verifyToken,
+		}
+	}
+
+	_, err := c.doRequest(ctx, http.MethodPost, c.buildSubscribedAppsURL(account), body, account.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	c.Log.Info("Subscribed app to WABA webhooks", "business_id", account.BusinessID)
+	return nil
+}
+
+// UnsubscribeApp removes the app's webhook subscription from the WABA
+func (c *Client) UnsubscribeApp(ctx context.Context, account *Account) error {
+	_, err := c.doRequest(ctx, http.MethodDelete, c.buildSubscribedAppsURL(account), nil, account.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	c.Log.Info("Unsubscribed app from WABA webhooks", "business_id", account.BusinessID)
+	return nil
+}
+
+// ListSubscribedApps lists the apps subscribed to the WABA's webhooks
+func (c *Client) ListSubscribedApps(ctx context.Context, account *Account) ([]SubscribedApp, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, c.buildSubscribedAppsURL(account), nil, account.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp SubscribedAppsResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	apps := make([]SubscribedApp, 0, len(resp.Data))
+	for _, d := range resp.Data {
+		apps = append(apps, d.WhatsAppBusinessAPIData)
+	}
+	return apps, nil
+}
+
+// IsAppSubscribed reports whether the account's app is subscribed to the WABA's webhooks.
+// When the account has no app ID, any subscribed app counts.
+func IsAppSubscribed(account *Account, apps []SubscribedApp) bool {
+	for _, app := range apps {
+		if account.AppID == "" || app.ID == account.AppID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package whatsapp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SubscribeApp(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Contains(t, r.URL.Path, "/987654321/subscribed_apps")
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "https://example.com/api/webhook", body["override_callback_uri"])
+		assert.Equal(t, "verify-me", body["verify_token"])
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	})
+
+	err := client.SubscribeApp(testutil.TestContext(t), account, "https://example.com/api/webhook", "verify-me")
+
+	require.NoError(t, err)
+}
+
+func TestClient_ListSubscribedApps(t *testing.T) {
+	t.Parallel()
+
+	client, account := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Contains(t, r.URL.Path, "/987654321/subscribed_apps")
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"data":[{"whatsapp_business_api_data":{"id":"111","name":"Whatomate","link":"https://example.com"}}]}`))
+	})
+
+	apps, err := client.ListSubscribedApps(testutil.TestContext(t), account)
+
+	require.NoError(t, err)
+	require.Len(t, apps, 1)
+	assert.Equal(t, "111", apps[0].ID)
+	assert.Equal(t, "Whatomate", apps[0].Name)
+}
+
+func TestIsAppSubscribed(t *testing.T) {
+	t.Parallel()
+
+	apps := []whatsapp.SubscribedApp{{ID: "111"}, {ID: "222"}}
+
+	assert.True(t, whatsapp.IsAppSubscribed(&whatsapp.Account{AppID: "222"}, apps))
+	assert.False(t, whatsapp.IsAppSubscribed(&whatsapp.Account{AppID: "333"}, apps))
+	assert.True(t, whatsapp.IsAppSubscribed(&whatsapp.Account{}, apps))
+	assert.False(t, whatsapp.IsAppSubscribed(&whatsapp.Account{}, nil))
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // TemplateSubmission represents a template to be submitted to Meta
@@ -26,6 +29,32 @@ type TemplateSubmission struct {
 func (c *Client) SubmitTemplate(ctx context.Context, account *Account, template *TemplateSubmission) (string, error) {
 	url := c.buildTemplatesURL(account)
 
+	payload, err := BuildTemplatePayload(template)
+	if err != nil {
+		return "", err
+	}
+
+	// Log payload for debugging
+	payloadJSON, _ := json.MarshalIndent(payload, "", "  ")
+	c.Log.Info("Submitting template to Meta", "url", url, "name", template.Name, "payload", string(payloadJSON))
+
+	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account.AccessToken)
+	if err != nil {
+		c.Log.Error("Failed to submit template", "error", err, "name", template.Name)
+		return "", err
+	}
+
+	var result TemplateResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	c.Log.Info("Template submitted", "template_id", result.ID, "name", template.Name)
+	return result.ID, nil
+}
+
+// BuildTemplatePayload builds the request body SubmitTemplate sends to Meta for the template
+func BuildTemplatePayload(template *TemplateSubmission) (map[string]interface{}, error) {
 	// Build components array
 	components := []map[string]interface{}{}
 
@@ -91,7 +120,7 @@ func (c *Client) SubmitTemplate(ctx context.Context, account *Account, template
 			} else {
 				varCount := strings.Count(template.BodyContent, "{{")
 				if varCount > 0 {
-					return "", fmt.Errorf("sample values are required for template variables. Found %d variable(s) in body but no sample values provided", varCount)
+					return nil, fmt.Errorf("sample values are required for template variables. Found %d variable(s) in body but no sample values provided", varCount)
 				}
 			}
 		} else {
@@ -103,7 +132,7 @@ func (c *Client) SubmitTemplate(ctx context.Context, account *Account, template
 			} else {
 				varCount := strings.Count(template.BodyContent, "{{")
 				if varCount > 0 {
-					return "", fmt.Errorf("sample values are required for template variables. Found %d variable(s) in body but no sample values provided", varCount)
+					return nil, fmt.Errorf("sample values are required for template variables. Found %d variable(s) in body but no sample values provided", varCount)
 				}
 			}
 		}
@@ -164,6 +193,20 @@ func (c *Client) SubmitTemplate(ctx context.Context, account *Account, template
 					if example, ok := btnMap["example"].(string); ok && example != "" {
 						button["example"] = example
 					}
+				case "FLOW":
+					flowID, _ := btnMap["flow_id"].(string)
+					if flowID == "" {
+						continue
+					}
+					button["type"] = "FLOW"
+					button["text"] = btnText
+					button["flow_id"] = flowID
+					if screen, ok := btnMap["navigate_screen"].(string); ok && screen != "" {
+						button["navigate_screen"] = screen
+					}
+					if action, ok := btnMap["flow_action"].(string); ok && action != "" {
+						button["flow_action"] = action
+					}
 				default:
 					button["type"] = "QUICK_REPLY"
 					button["text"] = btnText
@@ -195,31 +238,62 @@ func (c *Client) SubmitTemplate(ctx context.Context, account *Account, template
 		payload["parameter_format"] = "NAMED"
 	}
 
-	// Log payload for debugging
-	payloadJSON, _ := json.MarshalIndent(payload, "", "  ")
-	c.Log.Info("Submitting template to Meta", "url", url, "name", template.Name, "payload", string(payloadJSON))
+	return payload, nil
+}
 
-	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account.AccessToken)
-	if err != nil {
-		c.Log.Error("Failed to submit template", "error", err, "name", template.Name)
-		return "", err
-	}
+// templatePageSize is how many templates are requested per page
+const templatePageSize = 100
 
-	var result TemplateResponse
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
+// TemplateListOptions narrows a template listing
+type TemplateListOptions struct {
+	After        string    // Paging cursor to continue from; empty starts at the first page
+	UpdatedSince time.Time // Only templates changed after this time; zero lists all of them
+}
 
-	c.Log.Info("Template submitted", "template_id", result.ID, "name", template.Name)
-	return result.ID, nil
+// TemplatePage is one page of an account's templates
+type TemplatePage struct {
+	Templates []MetaTemplate
+	After     string // Cursor of the next page; empty on the last page
+	Usage     int    // Percentage of Meta's rate limit used so far, from its usage headers
 }
 
-// FetchTemplates fetches all templates from Meta's API
+// FetchTemplates fetches all templates from Meta's API, following the paging cursors
 func (c *Client) FetchTemplates(ctx context.Context, account *Account) ([]MetaTemplate, error) {
-	url := fmt.Sprintf("%s?limit=100", c.buildTemplatesURL(account))
+	var templates []MetaTemplate
+	opts := TemplateListOptions{}
+	for {
+		page, err := c.FetchTemplatePage(ctx, account, opts)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, page.Templates...)
+		if page.After == "" {
+			break
+		}
+		opts.After = page.After
+	}
+
+	c.Log.Info("Fetched templates from Meta", "count", len(templates))
+	return templates, nil
+}
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account.AccessToken)
+// FetchTemplatePage fetches one page of an account's templates. A request Meta refuses
+// for its rate limit returns a *RateLimitError saying how long to wait.
+func (c *Client) FetchTemplatePage(ctx context.Context, account *Account, opts TemplateListOptions) (*TemplatePage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(templatePageSize))
+	if opts.After != "" {
+		query.Set("after", opts.After)
+	}
+	if !opts.UpdatedSince.IsZero() {
+		query.Set("since", strconv.FormatInt(opts.UpdatedSince.Unix(), 10))
+	}
+
+	respBody, header, err := c.doRequestWithHeader(ctx, http.MethodGet, c.buildTemplatesURL(account)+"?"+query.Encode(), nil, account.AccessToken)
 	if err != nil {
+		if rateLimited(err) {
+			return nil, &RateLimitError{RetryAfter: regainAccessAfter(header), Err: err}
+		}
 		c.Log.Error("Failed to fetch templates", "error", err)
 		return nil, err
 	}
@@ -228,9 +302,14 @@ func (c *Client) FetchTemplates(ctx context.Context, account *Account) ([]MetaTe
 	if err := json.Unmarshal(respBody, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
-
-	c.Log.Info("Fetched templates from Meta", "count", len(result.Data))
-	return result.Data, nil
+	page := &TemplatePage{
+		Templates: result.Data,
+		Usage:     usagePercent(header),
+	}
+	if result.Paging.Next != "" {
+		page.After = result.Paging.Cursors.After
+	}
+	return page, nil
 }
 
 // DeleteTemplate deletes a template from Meta's API
@@ -0,0 +1,151 @@
+package whatsapp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TemplatePreview is a template rendered with its sample values, together with the
+// payload SubmitTemplate would send to Meta for it
+type TemplatePreview struct {
+	HeaderType   string                  `json:"header_type,omitempty"`
+	Header       string                  `json:"header,omitempty"`
+	Body         string                  `json:"body"`
+	Footer       string                  `json:"footer,omitempty"`
+	Buttons      []TemplatePreviewButton `json:"buttons,omitempty"`
+	Placeholders map[string][]string     `json:"placeholders"`
+	Payload      map[string]interface{}  `json:"payload,omitempty"`
+	Valid        bool                    `json:"valid"`
+	Errors       []TemplateFieldError    `json:"errors,omitempty"`
+}
+
+// TemplatePreviewButton is a button as the recipient sees it
+type TemplatePreviewButton struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	URL         string `json:"url,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	Code        string `json:"code,omitempty"`
+	FlowID      string `json:"flow_id,omitempty"`
+}
+
+// PreviewTemplate renders the template's header, body and buttons with its sample
+// values. Placeholders without a sample are left as they are. The payload is only
+// included when the template could be submitted as it is.
+func PreviewTemplate(t *TemplateSubmission) *TemplatePreview {
+	preview := &TemplatePreview{
+		HeaderType:   t.HeaderType,
+		Footer:       t.FooterContent,
+		Placeholders: make(map[string][]string),
+	}
+
+	bodyParams, _ := templatePlaceholders(t.BodyContent)
+	preview.Placeholders["body"] = bodyParams
+	preview.Body = renderPlaceholders(t.BodyContent, templateSamples(t.SampleValues, "body"))
+
+	switch t.HeaderType {
+	case "", "NONE":
+	case "TEXT":
+		headerParams, _ := templatePlaceholders(t.HeaderContent)
+		preview.Placeholders["header"] = headerParams
+		preview.Header = renderPlaceholders(t.HeaderContent, templateSamples(t.SampleValues, "header"))
+	default:
+		// Media headers hold the uploaded media handle
+		preview.Header = t.HeaderContent
+	}
+
+	for _, b := range t.Buttons {
+		btn, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		btnType, _ := btn["type"].(string)
+		text, _ := btn["text"].(string)
+		example, _ := btn["example"].(string)
+		button := TemplatePreviewButton{Type: strings.ToUpper(btnType), Text: text}
+		switch button.Type {
+		case "URL":
+			rawURL, _ := btn["url"].(string)
+			if example != "" {
+				rawURL = placeholderPattern.ReplaceAllString(rawURL, example)
+			}
+			button.URL = rawURL
+		case "PHONE_NUMBER":
+			button.PhoneNumber, _ = btn["phone_number"].(string)
+		case "COPY_CODE":
+			button.Code = example
+		case "FLOW":
+			button.FlowID, _ = btn["flow_id"].(string)
+		}
+		preview.Buttons = append(preview.Buttons, button)
+	}
+
+	preview.Errors = ValidateTemplate(t)
+	preview.Valid = len(preview.Errors) == 0
+	if preview.Valid {
+		preview.Payload, _ = BuildTemplatePayload(t)
+	}
+	return preview
+}
+
+// MergeSampleValues returns the sample values with values added or replaced, keyed by
+// component ("header" or "body") and then placeholder name. Header and body samples are
+// rewritten in the shapes BuildTemplatePayload reads; other entries are kept as they are.
+func MergeSampleValues(sampleValues []interface{}, values map[string]map[string]string) []interface{} {
+	if len(values) == 0 {
+		return sampleValues
+	}
+
+	merged := []interface{}{}
+	for _, sv := range sampleValues {
+		if svMap, ok := sv.(map[string]interface{}); ok {
+			comp, _ := svMap["component"].(string)
+			if comp != "" && comp != "header" && comp != "body" {
+				merged = append(merged, sv)
+			}
+		}
+	}
+
+	for _, component := range []string{"header", "body"} {
+		samples := templateSamples(sampleValues, component)
+		for name, value := range values[component] {
+			samples[name] = value
+		}
+
+		names := make([]string, 0, len(samples))
+		for name := range samples {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			a, errA := strconv.Atoi(names[i])
+			b, errB := strconv.Atoi(names[j])
+			if errA == nil && errB == nil {
+				return a < b
+			}
+			return names[i] < names[j]
+		})
+
+		for _, name := range names {
+			entry := map[string]interface{}{"component": component, "value": samples[name]}
+			if idx, err := strconv.Atoi(name); err == nil {
+				entry["index"] = float64(idx)
+			} else {
+				entry["param_name"] = name
+			}
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
+
+// renderPlaceholders replaces each placeholder in text with its sample value
+func renderPlaceholders(text string, samples map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		name := strings.TrimSpace(m[2 : len(m)-2])
+		if value, ok := samples[name]; ok && value != "" {
+			return value
+		}
+		return m
+	})
+}
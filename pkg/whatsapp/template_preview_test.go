@@ -0,0 +1,76 @@
+package whatsapp_test
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewTemplate(t *testing.T) {
+	t.Parallel()
+
+	template := &whatsapp.TemplateSubmission{
+		Name:          "order_shipped",
+		Language:      "en",
+		Category:      "UTILITY",
+		HeaderType:    "TEXT",
+		HeaderContent: "Order {{1}}",
+		BodyContent:   "Hi {{1}}, order {{2}} has shipped",
+		FooterContent: "Reply STOP to opt out",
+		Buttons: []interface{}{
+			map[string]interface{}{"type": "url", "text": "Track", "url": "https://example.com/track/{{1}}", "example": "1234"},
+			map[string]interface{}{"type": "COPY_CODE", "text": "Copy code", "example": "SAVE10"},
+		},
+		SampleValues: []interface{}{
+			map[string]interface{}{"component": "header", "index": float64(1), "value": "#1234"},
+			map[string]interface{}{"component": "body", "index": float64(1), "value": "John"},
+		},
+	}
+
+	preview := whatsapp.PreviewTemplate(template)
+	assert.Equal(t, "Order #1234", preview.Header)
+	assert.Equal(t, "Hi John, order {{2}} has shipped", preview.Body)
+	assert.Equal(t, "Reply STOP to opt out", preview.Footer)
+	assert.Equal(t, []string{"1"}, preview.Placeholders["header"])
+	assert.Equal(t, []string{"1", "2"}, preview.Placeholders["body"])
+	require.Len(t, preview.Buttons, 2)
+	assert.Equal(t, "https://example.com/track/1234", preview.Buttons[0].URL)
+	assert.Equal(t, "SAVE10", preview.Buttons[1].Code)
+	assert.False(t, preview.Valid)
+	assert.Nil(t, preview.Payload)
+	require.NotEmpty(t, preview.Errors)
+	assert.Equal(t, "sample_values", preview.Errors[0].Field)
+
+	template.SampleValues = whatsapp.MergeSampleValues(template.SampleValues, map[string]map[string]string{
+		"body": {"1": "Ana", "2": "#5678"},
+	})
+	preview = whatsapp.PreviewTemplate(template)
+	assert.Equal(t, "Hi Ana, order #5678 has shipped", preview.Body)
+	assert.Equal(t, "Order #1234", preview.Header)
+	assert.True(t, preview.Valid, preview.Errors)
+
+	payload, err := whatsapp.BuildTemplatePayload(template)
+	require.NoError(t, err)
+	assert.Equal(t, payload, preview.Payload)
+
+	components := preview.Payload["components"].([]map[string]interface{})
+	require.Len(t, components, 4)
+	assert.Equal(t, map[string]interface{}{"body_text": [][]string{{"Ana", "#5678"}}}, components[1]["example"])
+	assert.Equal(t, map[string]interface{}{"header_text": []string{"#1234"}}, components[0]["example"])
+}
+
+func TestMergeSampleValues_Named(t *testing.T) {
+	t.Parallel()
+
+	merged := whatsapp.MergeSampleValues([]interface{}{
+		map[string]interface{}{"component": "body", "param_name": "name", "value": "John"},
+		map[string]interface{}{"component": "body", "param_name": "order", "value": "#1"},
+	}, map[string]map[string]string{"body": {"name": "Ana"}})
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"component": "body", "param_name": "name", "value": "Ana"},
+		map[string]interface{}{"component": "body", "param_name": "order", "value": "#1"},
+	}, merged)
+}
@@ -0,0 +1,129 @@
+package whatsapp_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_FetchTemplates_FollowsCursors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v21.0/987654321/message_templates", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("after") {
+		case "":
+			_, _ = w.Write([]byte(`{"data":[{"id":"1","name":"welcome"}],"paging":{"cursors":{"before":"a","after":"page2"},"next":"https://graph.facebook.com/next"}}`))
+		case "page2":
+			_, _ = w.Write([]byte(`{"data":[{"id":"2","name":"receipt"}],"paging":{"cursors":{"before":"page2","after":"end"}}}`))
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("after"))
+		}
+	}))
+	defer server.Close()
+
+	client := whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+	templates, err := client.FetchTemplates(context.Background(), testAccount(server.URL))
+	require.NoError(t, err)
+	require.Len(t, templates, 2)
+	assert.Equal(t, "welcome", templates[0].Name)
+	assert.Equal(t, "receipt", templates[1].Name)
+}
+
+func TestClient_FetchTemplatePage(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100", r.URL.Query().Get("limit"))
+		assert.Equal(t, "cursor", r.URL.Query().Get("after"))
+		assert.Equal(t, "1709287200", r.URL.Query().Get("since"))
+		w.Header().Set("X-Business-Use-Case-Usage", `{"987654321":[{"type":"whatsapp_business_management","call_count":42,"total_cputime":91,"total_time":12,"estimated_time_to_regain_access":0}]}`)
+		w.Header().Set("X-App-Usage", `{"call_count":7,"total_cputime":1,"total_time":3}`)
+		_, _ = w.Write([]byte(`{"data":[{"id":"1","name":"welcome"}],"paging":{"cursors":{"after":"end"}}}`))
+	}))
+	defer server.Close()
+
+	client := whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+	page, err := client.FetchTemplatePage(context.Background(), testAccount(server.URL), whatsapp.TemplateListOptions{
+		After:        "cursor",
+		UpdatedSince: since,
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Templates, 1)
+	assert.Empty(t, page.After, "the last page has no next cursor")
+	assert.Equal(t, 91, page.Usage)
+}
+
+func TestClient_FetchTemplatePage_RateLimited(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		usage     string
+		status    int
+		body      string
+		wantRetry time.Duration
+	}{
+		{
+			name:      "business use case limit",
+			usage:     `{"987654321":[{"type":"whatsapp_business_management","call_count":100,"estimated_time_to_regain_access":12}]}`,
+			status:    http.StatusBadRequest,
+			body:      `{"error":{"message":"Too many calls","code":80008}}`,
+			wantRetry: 12 * time.Minute,
+		},
+		{
+			name:      "no estimate",
+			status:    http.StatusTooManyRequests,
+			body:      `slow down`,
+			wantRetry: 5 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.usage != "" {
+					w.Header().Set("X-Business-Use-Case-Usage", tt.usage)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+			_, err := client.FetchTemplatePage(context.Background(), testAccount(server.URL), whatsapp.TemplateListOptions{})
+			var rateErr *whatsapp.RateLimitError
+			require.True(t, errors.As(err, &rateErr), "got %v", err)
+			assert.Equal(t, tt.wantRetry, rateErr.RetryAfter)
+		})
+	}
+}
+
+func TestClient_FetchTemplatePage_OtherErrors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"message":"Invalid OAuth access token","code":190}}`))
+	}))
+	defer server.Close()
+
+	client := whatsapp.NewWithBaseURL(testutil.NopLogger(), server.URL)
+	_, err := client.FetchTemplatePage(context.Background(), testAccount(server.URL), whatsapp.TemplateListOptions{})
+	require.Error(t, err)
+	var rateErr *whatsapp.RateLimitError
+	assert.False(t, errors.As(err, &rateErr))
+	assert.Equal(t, 190, whatsapp.ErrorCodeOf(err))
+}
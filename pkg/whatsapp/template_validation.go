@@ -0,0 +1,287 @@
+package whatsapp
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Meta's limits for template components
+const (
+	maxTemplateHeaderLength   = 60
+	maxTemplateBodyLength     = 1024
+	maxTemplateFooterLength   = 60
+	maxTemplateButtons        = 10
+	maxTemplateURLButtons     = 2
+	maxTemplatePhoneButtons   = 1
+	maxTemplateButtonText     = 25
+	maxTemplatePhoneLength    = 20
+	maxTemplateCopyCodeLength = 15
+)
+
+var (
+	// placeholderPattern matches {{1}} and {{name}} placeholders
+	placeholderPattern = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+	// namedPlaceholderPattern is what Meta accepts as a named parameter
+	namedPlaceholderPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	// templatePhonePattern is a phone number with an optional leading +
+	templatePhonePattern = regexp.MustCompile(`^\+?[0-9]{6,19}$`)
+)
+
+// TemplateFieldError is a problem with one field of a template, named as in the
+// template API request, such as "body_content" or "buttons[1].url"
+type TemplateFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidateTemplate checks a template against the rules Meta applies on submission, so
+// mistakes surface while editing instead of as a rejection. It returns nil when the
+// template is valid.
+func ValidateTemplate(t *TemplateSubmission) []TemplateFieldError {
+	var errs []TemplateFieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, TemplateFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Body placeholders must all be positional or all named, and positional ones
+	// numbered 1, 2, 3... without gaps
+	bodyParams, problems := templatePlaceholders(t.BodyContent)
+	for _, p := range problems {
+		add("body_content", "%s", p)
+	}
+	if len(t.BodyContent) > maxTemplateBodyLength {
+		add("body_content", "Body must be at most %d characters", maxTemplateBodyLength)
+	}
+
+	var headerParams []string
+	if t.HeaderType == "TEXT" {
+		var headerProblems []string
+		headerParams, headerProblems = templatePlaceholders(t.HeaderContent)
+		for _, p := range headerProblems {
+			add("header_content", "%s", p)
+		}
+		if len(t.HeaderContent) > maxTemplateHeaderLength {
+			add("header_content", "Header must be at most %d characters", maxTemplateHeaderLength)
+		}
+		if len(headerParams) > 1 {
+			add("header_content", "Header can have at most one placeholder")
+		}
+	}
+
+	if len(t.FooterContent) > maxTemplateFooterLength {
+		add("footer_content", "Footer must be at most %d characters", maxTemplateFooterLength)
+	}
+	if strings.Contains(t.FooterContent, "{{") {
+		add("footer_content", "Footer can't have placeholders")
+	}
+
+	// Every placeholder needs a sample value, and every sample a placeholder
+	for _, component := range []struct {
+		name   string
+		params []string
+	}{{"header", headerParams}, {"body", bodyParams}} {
+		samples := templateSamples(t.SampleValues, component.name)
+		for _, param := range component.params {
+			if samples[param] == "" {
+				add("sample_values", "Missing sample value for {{%s}} in the %s", param, component.name)
+			}
+		}
+		for param := range samples {
+			if !slices.Contains(component.params, param) {
+				add("sample_values", "Sample value for {{%s}} has no matching placeholder in the %s", param, component.name)
+			}
+		}
+	}
+
+	errs = append(errs, validateTemplateButtons(t.Buttons)...)
+	return errs
+}
+
+// templatePlaceholders returns the distinct placeholder names in text in order of first
+// use, along with any problems with how they are written
+func templatePlaceholders(text string) ([]string, []string) {
+	matches := placeholderPattern.FindAllStringSubmatch(text, -1)
+
+	var problems []string
+	if strings.Count(text, "{{") != len(matches) || strings.Count(text, "}}") != len(matches) {
+		problems = append(problems, "Placeholders must be written as {{1}} or {{name}}")
+	}
+
+	var names []string
+	positional, named := 0, 0
+	highest := 0
+	for _, m := range matches {
+		name := strings.TrimSpace(m[1])
+		if slices.Contains(names, name) {
+			continue
+		}
+		names = append(names, name)
+		if n, err := strconv.Atoi(name); err == nil {
+			positional++
+			highest = max(highest, n)
+			continue
+		}
+		named++
+		if !namedPlaceholderPattern.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("Placeholder {{%s}} must use lowercase letters, numbers and underscores, starting with a letter", name))
+		}
+	}
+
+	if positional > 0 && named > 0 {
+		problems = append(problems, "Placeholders must be all numbered or all named, not a mix")
+	} else if positional > 0 && highest != positional {
+		problems = append(problems, fmt.Sprintf("Numbered placeholders must run from {{1}} to {{%d}} without gaps", positional))
+	}
+	return names, problems
+}
+
+// templateSamples returns the sample values for a component keyed by placeholder name.
+// It accepts the same sample_values shapes SubmitTemplate does: objects with a
+// param_name or index, legacy objects with a values array, and bare strings for the body.
+func templateSamples(sampleValues []interface{}, component string) map[string]string {
+	samples := make(map[string]string)
+	position := 0
+	for _, sv := range sampleValues {
+		switch v := sv.(type) {
+		case string:
+			if component == "body" {
+				position++
+				samples[strconv.Itoa(position)] = v
+			}
+		case map[string]interface{}:
+			comp, _ := v["component"].(string)
+			if comp != component && (comp != "" || component != "body") {
+				continue
+			}
+			if values, ok := v["values"].([]interface{}); ok {
+				for i, value := range values {
+					str, _ := value.(string)
+					samples[strconv.Itoa(i+1)] = str
+				}
+				continue
+			}
+			value, _ := v["value"].(string)
+			if name, _ := v["param_name"].(string); name != "" {
+				samples[name] = value
+			} else if idx, ok := v["index"].(float64); ok {
+				samples[strconv.Itoa(int(idx))] = value
+			} else if idx, ok := v["index"].(int); ok {
+				samples[strconv.Itoa(idx)] = value
+			}
+		}
+	}
+	return samples
+}
+
+// validateTemplateButtons checks button counts, texts and per-type fields
+func validateTemplateButtons(buttons []interface{}) []TemplateFieldError {
+	var errs []TemplateFieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, TemplateFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(buttons) > maxTemplateButtons {
+		add("buttons", "A template can have at most %d buttons", maxTemplateButtons)
+	}
+
+	counts := make(map[string]int)
+	quickReplyGroups := 0
+	lastWasQuickReply := false
+	for i, b := range buttons {
+		field := fmt.Sprintf("buttons[%d]", i)
+		btn, ok := b.(map[string]interface{})
+		if !ok {
+			add(field, "Button must be an object")
+			continue
+		}
+		btnType, _ := btn["type"].(string)
+		btnType = strings.ToUpper(btnType)
+		counts[btnType]++
+
+		text, _ := btn["text"].(string)
+		if strings.TrimSpace(text) == "" {
+			add(field+".text", "Button text is required")
+		} else if len([]rune(text)) > maxTemplateButtonText {
+			add(field+".text", "Button text must be at most %d characters", maxTemplateButtonText)
+		}
+
+		isQuickReply := btnType == "QUICK_REPLY"
+		if isQuickReply && !lastWasQuickReply {
+			quickReplyGroups++
+		}
+		lastWasQuickReply = isQuickReply
+
+		switch btnType {
+		case "QUICK_REPLY":
+		case "URL":
+			rawURL, _ := btn["url"].(string)
+			errs = append(errs, validateTemplateButtonURL(field, rawURL, btn)...)
+		case "PHONE_NUMBER":
+			phone, _ := btn["phone_number"].(string)
+			if !templatePhonePattern.MatchString(phone) || len(phone) > maxTemplatePhoneLength {
+				add(field+".phone_number", "Phone number must be digits with an optional leading +, in international format")
+			}
+		case "COPY_CODE":
+			example, _ := btn["example"].(string)
+			if example == "" {
+				add(field+".example", "Copy code buttons need an example code")
+			} else if len(example) > maxTemplateCopyCodeLength {
+				add(field+".example", "Example code must be at most %d characters", maxTemplateCopyCodeLength)
+			}
+		case "FLOW":
+			if flowID, _ := btn["flow_id"].(string); flowID == "" {
+				add(field+".flow_id", "Flow buttons need a flow")
+			}
+		default:
+			add(field+".type", "Unknown button type %q", btnType)
+		}
+	}
+
+	if counts["URL"] > maxTemplateURLButtons {
+		add("buttons", "A template can have at most %d URL buttons", maxTemplateURLButtons)
+	}
+	if counts["PHONE_NUMBER"] > maxTemplatePhoneButtons {
+		add("buttons", "A template can have at most %d phone number button", maxTemplatePhoneButtons)
+	}
+	if counts["COPY_CODE"] > 1 {
+		add("buttons", "A template can have at most 1 copy code button")
+	}
+	if counts["FLOW"] > 1 {
+		add("buttons", "A template can have at most 1 flow button")
+	}
+	if quickReplyGroups > 1 {
+		add("buttons", "Quick reply buttons must be next to each other")
+	}
+	return errs
+}
+
+// validateTemplateButtonURL checks a URL button's address. Meta allows one placeholder,
+// at the end of the URL, and needs an example value for it.
+func validateTemplateButtonURL(field, rawURL string, btn map[string]interface{}) []TemplateFieldError {
+	var errs []TemplateFieldError
+	params, _ := templatePlaceholders(rawURL)
+
+	base := placeholderPattern.ReplaceAllString(rawURL, "")
+	u, err := url.Parse(base)
+	if rawURL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, TemplateFieldError{Field: field + ".url", Message: "URL must be a full http or https address"})
+		return errs
+	}
+
+	switch {
+	case len(params) > 1:
+		errs = append(errs, TemplateFieldError{Field: field + ".url", Message: "URL can have at most one placeholder"})
+	case len(params) == 1:
+		if !strings.HasSuffix(rawURL, "}}") {
+			errs = append(errs, TemplateFieldError{Field: field + ".url", Message: "The URL placeholder must be at the end"})
+		}
+		if example, _ := btn["example"].(string); example == "" {
+			errs = append(errs, TemplateFieldError{Field: field + ".example", Message: "URLs with a placeholder need an example value"})
+		}
+	}
+	return errs
+}
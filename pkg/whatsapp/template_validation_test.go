@@ -0,0 +1,146 @@
+package whatsapp_test
+
+import (
+	"testing"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	t.Parallel()
+
+	sample := func(component, name, value string) map[string]interface{} {
+		return map[string]interface{}{"component": component, "param_name": name, "value": value}
+	}
+	button := func(fields ...string) map[string]interface{} {
+		b := map[string]interface{}{}
+		for i := 0; i < len(fields); i += 2 {
+			b[fields[i]] = fields[i+1]
+		}
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		template whatsapp.TemplateSubmission
+		fields   []string
+	}{
+		{
+			name: "valid positional template",
+			template: whatsapp.TemplateSubmission{
+				HeaderType:    "TEXT",
+				HeaderContent: "Order {{1}}",
+				BodyContent:   "Hi {{1}}, your order {{2}} has shipped",
+				SampleValues: []interface{}{
+					sample("header", "1", "#1234"),
+					sample("body", "1", "John"),
+					sample("body", "2", "#1234"),
+				},
+				Buttons: []interface{}{
+					button("type", "QUICK_REPLY", "text", "Thanks"),
+					button("type", "QUICK_REPLY", "text", "Stop"),
+					button("type", "URL", "text", "Track", "url", "https://example.com/track/{{1}}", "example", "1234"),
+				},
+			},
+		},
+		{
+			name: "valid named template",
+			template: whatsapp.TemplateSubmission{
+				BodyContent:  "Hi {{name}}",
+				SampleValues: []interface{}{sample("body", "name", "John")},
+			},
+		},
+		{
+			name: "legacy values array",
+			template: whatsapp.TemplateSubmission{
+				BodyContent: "Hi {{1}} and {{2}}",
+				SampleValues: []interface{}{
+					map[string]interface{}{"component": "body", "values": []interface{}{"John", "Jane"}},
+				},
+			},
+		},
+		{
+			name: "placeholders with a gap",
+			template: whatsapp.TemplateSubmission{
+				BodyContent:  "Hi {{1}}, see {{3}}",
+				SampleValues: []interface{}{sample("body", "1", "John"), sample("body", "3", "you")},
+			},
+			fields: []string{"body_content"},
+		},
+		{
+			name: "mixed placeholders",
+			template: whatsapp.TemplateSubmission{
+				BodyContent:  "Hi {{1}}, see {{name}}",
+				SampleValues: []interface{}{sample("body", "1", "John"), sample("body", "name", "you")},
+			},
+			fields: []string{"body_content"},
+		},
+		{
+			name: "missing and extra samples",
+			template: whatsapp.TemplateSubmission{
+				BodyContent:  "Hi {{1}}",
+				SampleValues: []interface{}{sample("body", "2", "John")},
+			},
+			fields: []string{"sample_values", "sample_values"},
+		},
+		{
+			name: "unclosed placeholder",
+			template: whatsapp.TemplateSubmission{
+				BodyContent: "Hi {{1}",
+			},
+			fields: []string{"body_content"},
+		},
+		{
+			name: "footer placeholder",
+			template: whatsapp.TemplateSubmission{
+				BodyContent:   "Hi",
+				FooterContent: "Bye {{1}}",
+			},
+			fields: []string{"footer_content"},
+		},
+		{
+			name: "button problems",
+			template: whatsapp.TemplateSubmission{
+				BodyContent: "Hi",
+				Buttons: []interface{}{
+					button("type", "QUICK_REPLY", "text", "This reply text is far too long for Meta"),
+					button("type", "URL", "text", "Visit", "url", "example.com"),
+					button("type", "QUICK_REPLY", "text", "Stop"),
+					button("type", "URL", "text", "Track", "url", "https://example.com/{{1}}/status"),
+				},
+			},
+			fields: []string{
+				"buttons[0].text",
+				"buttons[1].url",
+				"buttons[3].url",
+				"buttons[3].example",
+				"buttons",
+			},
+		},
+		{
+			name: "too many phone buttons",
+			template: whatsapp.TemplateSubmission{
+				BodyContent: "Hi",
+				Buttons: []interface{}{
+					button("type", "PHONE_NUMBER", "text", "Call", "phone_number", "+1234567890"),
+					button("type", "PHONE_NUMBER", "text", "Call us", "phone_number", "call me"),
+				},
+			},
+			fields: []string{"buttons[1].phone_number", "buttons"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fields []string
+			for _, err := range whatsapp.ValidateTemplate(&tt.template) {
+				assert.NotEmpty(t, err.Message)
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
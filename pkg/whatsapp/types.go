@@ -9,6 +9,8 @@ type Account struct {
 	AppID       string
 	APIVersion  string
 	AccessToken string
+
+	MessagesPerSecond int // Send rate limit for this number; 0 uses the client's default
 }
 
 // Button represents an interactive button
@@ -82,7 +84,14 @@ type TemplateExample struct {
 
 // TemplateListResponse represents response from fetching templates
 type TemplateListResponse struct {
-	Data []MetaTemplate `json:"data"`
+	Data   []MetaTemplate `json:"data"`
+	Paging struct {
+		Cursors struct {
+			Before string `json:"before"`
+			After  string `json:"after"`
+		} `json:"cursors"`
+		Next string `json:"next,omitempty"` // Absent on the last page
+	} `json:"paging"`
 }
 
 // WebhookPayload represents the incoming webhook from Meta
@@ -244,6 +253,18 @@ type CatalogListResponse struct {
 	Data []CatalogInfo `json:"data"`
 }
 
+// CommerceSettings represents the commerce settings of a phone number
+type CommerceSettings struct {
+	ID               string `json:"id,omitempty"`
+	IsCartEnabled    bool   `json:"is_cart_enabled"`
+	IsCatalogVisible bool   `json:"is_catalog_visible"`
+}
+
+// CommerceSettingsResponse represents response from fetching commerce settings
+type CommerceSettingsResponse struct {
+	Data []CommerceSettings `json:"data"`
+}
+
 // ProductInput represents input for creating/updating a product
 type ProductInput struct {
 	Name        string `json:"name"`
@@ -276,3 +297,58 @@ type ProductListResponse struct {
 type ProductCreateResponse struct {
 	ID string `json:"id"`
 }
+
+// BusinessProfile represents a phone number's WhatsApp business profile
+type BusinessProfile struct {
+	About             string   `json:"about"`
+	Address           string   `json:"address"`
+	Description       string   `json:"description"`
+	Email             string   `json:"email"`
+	ProfilePictureURL string   `json:"profile_picture_url"`
+	Websites          []string `json:"websites"`
+	Vertical          string   `json:"vertical"`
+}
+
+// BusinessProfileResponse represents response from fetching a business profile
+type BusinessProfileResponse struct {
+	Data []BusinessProfile `json:"data"`
+}
+
+// BusinessProfileUpdate represents the fields to change on a business profile.
+// Nil fields are left unchanged.
+type BusinessProfileUpdate struct {
+	About                *string  `json:"about,omitempty"`
+	Address              *string  `json:"address,omitempty"`
+	Description          *string  `json:"description,omitempty"`
+	Email                *string  `json:"email,omitempty"`
+	Websites             []string `json:"websites,omitempty"`
+	Vertical             *string  `json:"vertical,omitempty"`
+	ProfilePictureHandle string   `json:"profile_picture_handle,omitempty"` // From ResumableUpload
+}
+
+// PhoneNumberStatus represents the registration and verification status of a phone number
+type PhoneNumberStatus struct {
+	ID                     string `json:"id"`
+	DisplayPhoneNumber     string `json:"display_phone_number"`
+	VerifiedName           string `json:"verified_name"`
+	Status                 string `json:"status"`                   // e.g. CONNECTED, PENDING, DISCONNECTED
+	CodeVerificationStatus string `json:"code_verification_status"` // e.g. VERIFIED, NOT_VERIFIED, EXPIRED
+	NameStatus             string `json:"name_status"`
+	PlatformType           string `json:"platform_type"` // CLOUD_API when registered, NOT_APPLICABLE otherwise
+	QualityRating          string `json:"quality_rating"`
+	MessagingLimitTier     string `json:"messaging_limit_tier"`
+}
+
+// SubscribedApp represents an app subscribed to a WABA's webhooks
+type SubscribedApp struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Link string `json:"link"`
+}
+
+// SubscribedAppsResponse represents response from listing a WABA's subscribed apps
+type SubscribedAppsResponse struct {
+	Data []struct {
+		WhatsAppBusinessAPIData SubscribedApp `json:"whatsapp_business_api_data"`
+	} `json:"data"`
+}
@@ -0,0 +1,245 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxSharedStringsSize caps the uncompressed shared strings part, which is held in memory
+const maxSharedStringsSize = 256 << 20
+
+// ErrInvalidWorkbook is returned when a file isn't a readable workbook
+var ErrInvalidWorkbook = errors.New("xlsx: invalid workbook")
+
+// Reader reads the rows of a workbook's first sheet as text, one row at a time
+type Reader struct {
+	sheet   io.ReadCloser
+	dec     *xml.Decoder
+	strings []string
+}
+
+// sheetRow and sheetCell are the parts of a worksheet row the reader uses
+type sheetRow struct {
+	Cells []sheetCell `xml:"c"`
+}
+
+type sheetCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline richText `xml:"is"`
+}
+
+// richText is a shared or inline string, either plain or made of formatted runs
+type richText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+// NewReader opens the workbook in r, which is size bytes long. Close must be called
+// when done.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrInvalidWorkbook
+	}
+
+	shared, err := readSharedStrings(zr)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := zr.Open(firstSheetPath(zr))
+	if err != nil {
+		return nil, ErrInvalidWorkbook
+	}
+	return &Reader{sheet: sheet, dec: xml.NewDecoder(sheet), strings: shared}, nil
+}
+
+// Read returns the next non-empty row. Missing cells are returned as "", numbers as
+// written in the file and booleans as TRUE or FALSE. Dates are Excel serial numbers,
+// as the sheet stores them. It returns io.EOF after the last row.
+func (r *Reader) Read() ([]string, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, ErrInvalidWorkbook
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		var row sheetRow
+		if err := r.dec.DecodeElement(&row, &start); err != nil {
+			return nil, ErrInvalidWorkbook
+		}
+		values := r.rowValues(row)
+		if len(values) > 0 {
+			return values, nil
+		}
+	}
+}
+
+// Close closes the sheet. It does not close the underlying reader.
+func (r *Reader) Close() error {
+	return r.sheet.Close()
+}
+
+// rowValues places a row's cells by column, trimming trailing empty cells
+func (r *Reader) rowValues(row sheetRow) []string {
+	var values []string
+	for _, c := range row.Cells {
+		col := len(values)
+		if c.Ref != "" {
+			if i, ok := columnIndex(c.Ref); ok {
+				col = i
+			}
+		}
+		value := r.cellValue(c)
+		if value == "" {
+			continue
+		}
+		for len(values) <= col {
+			values = append(values, "")
+		}
+		values[col] = value
+	}
+	return values
+}
+
+func (r *Reader) cellValue(c sheetCell) string {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(c.Value))
+		if err != nil || i < 0 || i >= len(r.strings) {
+			return ""
+		}
+		return r.strings[i]
+	case "inlineStr":
+		return c.Inline.String()
+	case "b":
+		if strings.TrimSpace(c.Value) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "e":
+		return ""
+	case "n", "":
+		// Long numbers such as phone numbers may be stored in exponent form
+		if strings.ContainsAny(c.Value, "eE") {
+			if f, err := strconv.ParseFloat(c.Value, 64); err == nil {
+				return strconv.FormatFloat(f, 'f', -1, 64)
+			}
+		}
+	}
+	return c.Value
+}
+
+// readSharedStrings loads the workbook's shared string table, if it has one
+func readSharedStrings(zr *zip.Reader) ([]string, error) {
+	f, err := zr.Open("xl/sharedStrings.xml")
+	if err != nil {
+		return nil, nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var shared []string
+	dec := xml.NewDecoder(io.LimitReader(f, maxSharedStringsSize))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return shared, nil
+		}
+		if err != nil {
+			return nil, ErrInvalidWorkbook
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "si" {
+			continue
+		}
+		var text richText
+		if err := dec.DecodeElement(&text, &start); err != nil {
+			return nil, ErrInvalidWorkbook
+		}
+		shared = append(shared, text.String())
+	}
+}
+
+// firstSheetPath finds the part holding the workbook's first sheet, falling back to
+// the conventional name
+func firstSheetPath(zr *zip.Reader) string {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if decodePart(zr, "xl/workbook.xml", &workbook) != nil || len(workbook.Sheets) == 0 ||
+		decodePart(zr, "xl/_rels/workbook.xml.rels", &rels) != nil {
+		return fallback
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+	return fallback
+}
+
+func decodePart(zr *zip.Reader, name string, v interface{}) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return xml.NewDecoder(f).Decode(v)
+}
+
+// columnIndex converts a cell reference such as "AB12" to its zero-based column
+func columnIndex(ref string) (int, bool) {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, false
+	}
+	return col - 1, true
+}
@@ -0,0 +1,145 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readPart(t *testing.T, data []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	f, err := zr.Open(name)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	body, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Messages")
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]interface{}{"Date", "Contact", "Count"}))
+	require.NoError(t, w.Write([]interface{}{
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"Fish & <Chips>",
+		int64(42),
+	}))
+	require.NoError(t, w.Close())
+	assert.Error(t, w.Write([]interface{}{"late"}))
+
+	for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		readPart(t, buf.Bytes(), part)
+	}
+	assert.Contains(t, readPart(t, buf.Bytes(), "xl/workbook.xml"), `name="Messages"`)
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	require.NoError(t, xml.Unmarshal([]byte(sheet), new(interface{})))
+	assert.Contains(t, sheet, `<c r="A2" t="inlineStr"><is><t xml:space="preserve">2024-01-02 03:04:05</t></is></c>`)
+	assert.Contains(t, sheet, `Fish &amp; &lt;Chips&gt;`)
+	assert.Contains(t, sheet, `<c r="C2"><v>42</v></c>`)
+}
+
+func TestWriter_DropsInvalidCharacters(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Bad:Name?")
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]interface{}{"a\x00b\x1fc"}))
+	require.NoError(t, w.Close())
+
+	assert.Contains(t, readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml"), ">abc<")
+	assert.Contains(t, readPart(t, buf.Bytes(), "xl/workbook.xml"), `name="BadName"`)
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		assert.Equal(t, want, columnName(i))
+	}
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "", Format(nil))
+	assert.Equal(t, "", Format((*time.Time)(nil)))
+	assert.Equal(t, "Yes", Format(true))
+	assert.Equal(t, "12.5", Format(12.5))
+	assert.Equal(t, "7", Format(7))
+}
+
+func TestReader_ReadsWrittenWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Contacts")
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]interface{}{"Phone", "Name", "Score"}))
+	require.NoError(t, w.Write([]interface{}{"+14155550123", "Fish & <Chips>", 42}))
+	require.NoError(t, w.Write([]interface{}{"", "", ""}))
+	require.NoError(t, w.Write([]interface{}{"+14155550124", "", 7}))
+	require.NoError(t, w.Close())
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+	assert.Equal(t, [][]string{
+		{"Phone", "Name", "Score"},
+		{"+14155550123", "Fish & <Chips>", "42"},
+		{"+14155550124", "", "7"},
+	}, rows)
+}
+
+func TestReader_SharedStrings(t *testing.T) {
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Data" sheetId="1" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/data.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>Phone</t></si><si><r><t>Ada </t></r><r><t>Lovelace</t></r></si></sst>`,
+		"xl/worksheets/data.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="b"><v>1</v></c></row>` +
+			`<row r="2"><c r="A2"><v>4.4207946096E11</v></c><c r="B2" t="s"><v>1</v></c><c r="C2" t="e"><v>#N/A</v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range parts {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = io.WriteString(f, body)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	row, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Phone", "", "TRUE"}, row)
+	row, err = r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"442079460960", "Ada Lovelace"}, row)
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_InvalidWorkbook(t *testing.T) {
+	data := []byte("phone,name\n")
+	_, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, ErrInvalidWorkbook)
+}
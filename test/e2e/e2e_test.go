@@ -0,0 +1,77 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatbot_KeywordReply(t *testing.T) {
+	e := Start(t)
+
+	e.API(t, http.MethodPut, "/api/chatbot/settings", map[string]any{"enabled": true}, nil)
+	e.API(t, http.MethodPost, "/api/chatbot/keywords", map[string]any{
+		"keywords":         []string{"pricing"},
+		"response_content": map[string]any{"body": "Plans start at $10 a month"},
+		"enabled":          true,
+	}, nil)
+
+	e.ReceiveText(t, "14155550100", "Jane", "What is your pricing?")
+
+	require.Eventually(t, func() bool { return len(e.Graph.Sent()) == 1 }, 10*time.Second, 50*time.Millisecond)
+	reply := e.Graph.Sent()[0]
+	assert.Equal(t, "text", reply.Type)
+	assert.Equal(t, "Plans start at $10 a month", reply.Text())
+
+	// Both sides of the conversation are stored
+	require.Eventually(t, func() bool {
+		var count int64
+		e.DB.Model(&models.Message{}).Where("organization_id = ?", e.Org.ID).Count(&count)
+		return count == 2
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestCampaign_SendAndDeliver(t *testing.T) {
+	e := Start(t)
+	template := e.CreateTemplate(t, "order_shipped", "Hello {{1}}, your order has shipped.")
+
+	var campaign handlers.CampaignResponse
+	e.API(t, http.MethodPost, "/api/campaigns", map[string]any{
+		"name":             "Shipping notice",
+		"whatsapp_account": e.Account.Name,
+		"template_id":      template.ID,
+	}, &campaign)
+
+	recipients := []map[string]any{
+		{"phone_number": "+14155550101", "template_params": map[string]any{"1": "Ann"}},
+		{"phone_number": "+14155550102", "template_params": map[string]any{"1": "Ben"}},
+		{"phone_number": "+14155550103", "template_params": map[string]any{"1": "Cat"}},
+	}
+	e.API(t, http.MethodPost, "/api/campaigns/"+campaign.ID.String()+"/recipients/import", map[string]any{"recipients": recipients}, nil)
+	e.API(t, http.MethodPost, "/api/campaigns/"+campaign.ID.String()+"/start", nil, nil)
+
+	// The worker sends every recipient the template through the Graph API
+	require.Eventually(t, func() bool { return len(e.Graph.Sent()) == len(recipients) }, 30*time.Second, 100*time.Millisecond)
+	for _, msg := range e.Graph.Sent() {
+		assert.Equal(t, "template", msg.Type)
+		assert.Equal(t, template.Name, msg.TemplateName())
+	}
+
+	// Delivery receipts from Meta flow back into the campaign's stats
+	for _, msg := range e.Graph.Sent() {
+		e.DeliverStatus(t, msg, "delivered")
+	}
+	require.Eventually(t, func() bool {
+		e.API(t, http.MethodGet, "/api/campaigns/"+campaign.ID.String(), nil, &campaign)
+		return campaign.Status == models.CampaignStatusCompleted && campaign.DeliveredCount == len(recipients)
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Equal(t, len(recipients), campaign.SentCount)
+	assert.Zero(t, campaign.FailedCount)
+}
@@ -0,0 +1,123 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// SentMessage is a message the server or a worker sent through the Graph API
+type SentMessage struct {
+	ID      string         // WhatsApp message ID handed back to the sender
+	PhoneID string         // Phone number ID of the sending account
+	To      string         // Recipient phone number
+	Type    string         // text, template, interactive, ...
+	Body    map[string]any // Full request body
+}
+
+// Text returns the body of a text message
+func (m SentMessage) Text() string {
+	text, _ := m.Body["text"].(map[string]any)
+	body, _ := text["body"].(string)
+	return body
+}
+
+// TemplateName returns the template name of a template message
+func (m SentMessage) TemplateName() string {
+	template, _ := m.Body["template"].(map[string]any)
+	name, _ := template["name"].(string)
+	return name
+}
+
+// GraphAPI is a fake Meta Graph API. It accepts every message send, hands out message
+// IDs and records what was sent; other calls get an empty success response.
+type GraphAPI struct {
+	server *httptest.Server
+
+	mu   sync.Mutex
+	sent []SentMessage
+	seq  int
+}
+
+// newGraphAPI starts the fake Graph API
+func newGraphAPI() *GraphAPI {
+	g := &GraphAPI{}
+	g.server = httptest.NewServer(http.HandlerFunc(g.handle))
+	return g
+}
+
+// URL returns the base URL to point WhatsApp clients at
+func (g *GraphAPI) URL() string {
+	return g.server.URL
+}
+
+// Close stops the fake Graph API
+func (g *GraphAPI) Close() {
+	g.server.Close()
+}
+
+// Sent returns the messages sent so far, optionally only those to one phone number
+func (g *GraphAPI) Sent(to ...string) []SentMessage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var out []SentMessage
+	for _, m := range g.sent {
+		if len(to) == 0 || m.To == to[0] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Reset forgets the messages sent so far
+func (g *GraphAPI) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sent = nil
+}
+
+func (g *GraphAPI) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Sends are POST /{version}/{phone-id}/messages
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodPost || len(parts) != 3 || parts[2] != "messages" {
+		_, _ = io.WriteString(w, `{"success":true}`)
+		return
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"message":"Invalid JSON","code":100}}`)
+		return
+	}
+
+	// Read receipts and typing indicators go to the same endpoint
+	if _, ok := body["status"]; ok {
+		_, _ = io.WriteString(w, `{"success":true}`)
+		return
+	}
+
+	to, _ := body["to"].(string)
+	msgType, _ := body["type"].(string)
+
+	g.mu.Lock()
+	g.seq++
+	id := fmt.Sprintf("wamid.e2e%06d", g.seq)
+	g.sent = append(g.sent, SentMessage{ID: id, PhoneID: parts[1], To: to, Type: msgType, Body: body})
+	g.mu.Unlock()
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"messaging_product": "whatsapp",
+		"contacts":          []map[string]string{{"input": to, "wa_id": strings.TrimPrefix(to, "+")}},
+		"messages":          []map[string]string{{"id": id}},
+	})
+}
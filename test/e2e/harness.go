@@ -0,0 +1,276 @@
+//go:build e2e
+
+// Package e2e boots a complete Whatomate stack for end-to-end tests: PostgreSQL and
+// Redis in throwaway containers, the API server and a worker in-process, and a fake
+// Meta Graph API that records what gets sent to WhatsApp. It needs Docker and is built
+// with the e2e tag:
+//
+//	go test -tags e2e -v ./test/e2e/...
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/shridarpatil/whatomate/internal/database"
+	"github.com/shridarpatil/whatomate/internal/handlers"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/internal/server"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/internal/worker"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	fixtures "github.com/shridarpatil/whatomate/test/fixtures/models"
+	"github.com/shridarpatil/whatomate/test/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/gorm"
+)
+
+const (
+	// AdminEmail and AdminPassword are the credentials of the admin created by migrations
+	AdminEmail    = "admin@admin.com"
+	AdminPassword = "admin"
+
+	postgresImage = "postgres:16-alpine"
+	redisImage    = "redis:7-alpine"
+)
+
+// Env is a running stack. Everything it starts is torn down when the test ends.
+type Env struct {
+	URL   string // Base URL of the API server
+	App   *handlers.App
+	DB    *gorm.DB
+	Redis *redis.Client
+	Graph *GraphAPI
+
+	Org     models.Organization    // Organization of the default admin
+	Admin   models.User            // Default admin, a super admin
+	Account models.WhatsAppAccount // WhatsApp account sending through Graph
+	Token   string                 // Access token of Admin
+}
+
+// Start boots a fresh stack for the test, skipping it when Docker is unavailable
+func Start(t *testing.T) *Env {
+	t.Helper()
+	skipWithoutDocker(t)
+
+	ctx := context.Background()
+	lo := testutil.NopLogger()
+
+	pgHost, pgPort := startContainer(t, testcontainers.ContainerRequest{
+		Image:        postgresImage,
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_USER":     "whatomate",
+			"POSTGRES_PASSWORD": "whatomate",
+			"POSTGRES_DB":       "whatomate",
+		},
+		// The server restarts once after the init scripts ran
+		WaitingFor: wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(2 * time.Minute),
+	}, "5432/tcp")
+	redisHost, redisPort := startContainer(t, testcontainers.ContainerRequest{
+		Image:        redisImage,
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForLog("Ready to accept connections").WithStartupTimeout(2 * time.Minute),
+	}, "6379/tcp")
+
+	graph := newGraphAPI()
+	t.Cleanup(graph.Close)
+
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	cfg.App.Environment = "test"
+	cfg.Database.Host = pgHost
+	cfg.Database.Port = pgPort
+	cfg.Database.User = "whatomate"
+	cfg.Database.Password = "whatomate"
+	cfg.Database.Name = "whatomate"
+	cfg.Redis.Host = redisHost
+	cfg.Redis.Port = redisPort
+	cfg.JWT.Secret = "e2e-secret"
+	cfg.WhatsApp.BaseURL = graph.URL()
+	cfg.Storage.LocalPath = t.TempDir()
+
+	db, err := database.NewPostgres(&cfg.Database, false)
+	require.NoError(t, err)
+	require.NoError(t, database.RunMigrationWithProgress(db))
+
+	rdb, err := database.NewRedis(&cfg.Redis)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	wsHub := websocket.NewHub(lo)
+	go wsHub.Run()
+
+	app := &handlers.App{
+		Config:   cfg,
+		DB:       db,
+		Redis:    rdb,
+		Log:      lo,
+		WhatsApp: whatsapp.NewWithBaseURL(lo, graph.URL()),
+		WSHub:    wsHub,
+		Queue:    queue.NewRedisQueue(rdb, lo),
+	}
+	require.NoError(t, app.StartCampaignStatsSubscriber())
+	require.NoError(t, app.StartContactBulkUpdateSubscriber())
+	t.Cleanup(func() {
+		app.StopCampaignStatsSubscriber()
+		app.StopContactBulkUpdateSubscriber()
+		app.WaitForBackgroundTasks()
+	})
+
+	// Server
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := server.New(app, lo)
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	// Worker
+	w, err := worker.New(cfg, db, rdb, lo)
+	require.NoError(t, err)
+	w.WhatsApp = whatsapp.NewWithBaseURL(lo, graph.URL())
+	workerCtx, stopWorker := context.WithCancel(ctx)
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		_ = w.Run(workerCtx)
+	}()
+	t.Cleanup(func() {
+		stopWorker()
+		<-workerDone
+		_ = w.Close()
+	})
+
+	e := &Env{
+		URL:   "http://" + ln.Addr().String(),
+		App:   app,
+		DB:    db,
+		Redis: rdb,
+		Graph: graph,
+	}
+	e.seed(t)
+	return e
+}
+
+// skipWithoutDocker skips the test when no Docker daemon is reachable. testcontainers
+// panics instead of skipping when it can't find a Docker host at all.
+func skipWithoutDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("Docker not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+// startContainer starts a container for the test and returns the host and port its
+// port is published on
+func startContainer(t *testing.T, req testcontainers.ContainerRequest, port nat.Port) (string, int) {
+	t.Helper()
+	ctx := context.Background()
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err, "failed to start %s", req.Image)
+	t.Cleanup(func() { _ = c.Terminate(context.Background()) })
+
+	host, err := c.Host(ctx)
+	require.NoError(t, err)
+	mapped, err := c.MappedPort(ctx, port)
+	require.NoError(t, err)
+	return host, mapped.Int()
+}
+
+// seed loads the default admin and gives its organization a WhatsApp account
+func (e *Env) seed(t *testing.T) {
+	t.Helper()
+
+	require.NoError(t, e.DB.Where("email = ?", AdminEmail).First(&e.Admin).Error)
+	require.NoError(t, e.DB.First(&e.Org, "id = ?", e.Admin.OrganizationID).Error)
+
+	e.Account = fixtures.NewWhatsAppAccount(e.Org.ID).WithName("e2e").Build()
+	require.NoError(t, e.DB.Create(&e.Account).Error)
+
+	var login struct {
+		AccessToken string `json:"access_token"`
+	}
+	e.call(t, "", http.MethodPost, "/api/auth/login", map[string]string{
+		"email":    AdminEmail,
+		"password": AdminPassword,
+	}, &login)
+	require.NotEmpty(t, login.AccessToken)
+	e.Token = login.AccessToken
+}
+
+// CreateTemplate adds an approved template with the given body to the account
+func (e *Env) CreateTemplate(t *testing.T, name, body string) models.Template {
+	t.Helper()
+	template := fixtures.NewTemplate(e.Org.ID, e.Account.Name).WithName(name).WithBody(body).Build()
+	require.NoError(t, e.DB.Create(&template).Error)
+	return template
+}
+
+// Do sends an API request authenticated with token, or unauthenticated when token is
+// empty, and returns the status code and raw response body
+func (e *Env) Do(t *testing.T, method, path, token string, body any) (int, []byte) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, e.URL+path, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+// API calls the API as the admin, requires a 200 response and decodes the envelope's
+// data into out when it isn't nil
+func (e *Env) API(t *testing.T, method, path string, body, out any) {
+	t.Helper()
+	e.call(t, e.Token, method, path, body, out)
+}
+
+func (e *Env) call(t *testing.T, token, method, path string, body, out any) {
+	t.Helper()
+
+	status, data := e.Do(t, method, path, token, body)
+	require.Equal(t, http.StatusOK, status, "%s %s: %s", method, path, data)
+	if out == nil {
+		return
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &envelope))
+	require.NoError(t, json.Unmarshal(envelope.Data, out), "%s %s", method, path)
+}
@@ -0,0 +1,96 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// incomingSeq numbers the IDs of simulated incoming messages
+var incomingSeq atomic.Int64
+
+// PostWebhook delivers a "messages" change for the account to the webhook endpoint,
+// the way Meta does. value holds the change's contacts, messages or statuses; the
+// account metadata is filled in.
+func (e *Env) PostWebhook(t *testing.T, value map[string]any) {
+	t.Helper()
+
+	value["messaging_product"] = "whatsapp"
+	value["metadata"] = map[string]string{
+		"display_phone_number": "15550000000",
+		"phone_number_id":      e.Account.PhoneID,
+	}
+	payload := map[string]any{
+		"object": "whatsapp_business_account",
+		"entry": []map[string]any{{
+			"id": e.Account.BusinessID,
+			"changes": []map[string]any{{
+				"field": "messages",
+				"value": value,
+			}},
+		}},
+	}
+
+	status, body := e.Do(t, http.MethodPost, "/api/webhook", "", payload)
+	require.Equal(t, http.StatusOK, status, string(body))
+}
+
+// ReceiveText simulates a text message from a contact and returns its WhatsApp ID.
+// The server handles it asynchronously, so wait for its effects.
+func (e *Env) ReceiveText(t *testing.T, from, profileName, text string) string {
+	t.Helper()
+	return e.receive(t, from, profileName, map[string]any{
+		"type": "text",
+		"text": map[string]string{"body": text},
+	})
+}
+
+// ReceiveButtonReply simulates a contact tapping a reply button
+func (e *Env) ReceiveButtonReply(t *testing.T, from, profileName, buttonID, title string) string {
+	t.Helper()
+	return e.receive(t, from, profileName, map[string]any{
+		"type": "interactive",
+		"interactive": map[string]any{
+			"type":         "button_reply",
+			"button_reply": map[string]string{"id": buttonID, "title": title},
+		},
+	})
+}
+
+// DeliverStatus simulates a status update (sent, delivered, read or failed) for a
+// message sent through the fake Graph API
+func (e *Env) DeliverStatus(t *testing.T, msg SentMessage, status string) {
+	t.Helper()
+	e.PostWebhook(t, map[string]any{
+		"statuses": []map[string]any{{
+			"id":           msg.ID,
+			"status":       status,
+			"timestamp":    strconv.FormatInt(time.Now().Unix(), 10),
+			"recipient_id": msg.To,
+		}},
+	})
+}
+
+func (e *Env) receive(t *testing.T, from, profileName string, msg map[string]any) string {
+	t.Helper()
+
+	id := fmt.Sprintf("wamid.in%06d", incomingSeq.Add(1))
+	msg["id"] = id
+	msg["from"] = from
+	msg["timestamp"] = strconv.FormatInt(time.Now().Unix(), 10)
+	e.PostWebhook(t, map[string]any{
+		"contacts": []map[string]any{{
+			"wa_id":   from,
+			"profile": map[string]string{"name": profileName},
+		}},
+		"messages": []map[string]any{msg},
+	})
+	return id
+}
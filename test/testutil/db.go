@@ -78,37 +78,86 @@ func SetupTestDBWithCleanup(t *testing.T, cleanup bool) *gorm.DB {
 
 // runMigrations runs all model migrations.
 func runMigrations(db *gorm.DB) error {
+	// Canned response search uses trigram similarity
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return err
+	}
 	return db.AutoMigrate(
 		// Core models
 		&models.Organization{},
 		&models.Permission{},
 		&models.CustomRole{},
 		&models.User{},
+		&models.UserOrganization{},
+		&models.OrganizationInvite{},
 		&models.Team{},
 		&models.TeamMember{},
+		&models.AgentShift{},
 		&models.APIKey{},
+		&models.APIUsageDaily{},
 		&models.SSOProvider{},
+		&models.GoogleSheetsConnection{},
+		&models.CalendarConnection{},
+		&models.SMTPSettings{},
 		&models.Webhook{},
+		&models.NotificationChannel{},
+		&models.EnrichmentProvider{},
+		&models.Announcement{},
+		&models.AnnouncementRead{},
+		&models.FeatureFlag{},
+		&models.FeatureFlagOverride{},
+		&models.OrganizationPlugin{},
+		&models.OrganizationEncryptionKey{},
+		&models.PipelineScript{},
+		&models.ScriptExecution{},
 		&models.CustomAction{},
 		&models.UserAvailabilityLog{},
+		&models.AuditLog{},
+		&models.ImpersonationSession{},
+		&models.CannedResponse{},
+		&models.CannedResponseUsage{},
 		// WhatsApp models
 		&models.WhatsAppAccount{},
 		&models.Contact{},
 		&models.Message{},
+		&models.ArchivedMessage{},
+		&models.MessageStatusEvent{},
+		&models.OutboxMessage{},
+		&models.TransactionalSend{},
+		&models.Verification{},
+		&models.MessageApproval{},
+		&models.AnalyticsExport{},
+		&models.PublicDashboard{},
 		&models.Template{},
 		&models.WhatsAppFlow{},
+		&models.EmailGateway{},
 		// Chatbot models
 		&models.ChatbotSettings{},
 		&models.KeywordRule{},
 		&models.ChatbotFlow{},
 		&models.ChatbotFlowStep{},
+		&models.ChatbotFlowVersion{},
 		&models.ChatbotSession{},
 		&models.ChatbotSessionMessage{},
+		&models.ChatbotSessionTrace{},
 		&models.AIContext{},
+		&models.ContactMemory{},
+		&models.ChatbotVariable{},
+		&models.ContactConsent{},
+		&models.ContactOptOut{},
+		&models.ContactImport{},
+		&models.ContactBulkUpdate{},
+		&models.Segment{},
 		&models.AgentTransfer{},
+		&models.TransferAssignmentOffer{},
+		&models.WrapUpCode{},
+		&models.UnansweredQuestion{},
 		// Bulk message models
 		&models.BulkMessageCampaign{},
 		&models.BulkMessageRecipient{},
+		&models.RecipientImport{},
+		&models.TemplateSendBatch{},
+		&models.TemplateSendItem{},
 		&models.NotificationRule{},
 	)
 }
@@ -119,20 +168,44 @@ func cleanupTables(db *gorm.DB) {
 	tables := []string{
 		// Bulk message tables
 		"bulk_message_recipients",
+		"recipient_imports",
+		"template_send_items",
+		"template_send_batches",
 		"bulk_message_campaigns",
 		"notification_rules",
 		// Chatbot tables
+		"chatbot_session_traces",
 		"chatbot_session_messages",
 		"chatbot_sessions",
 		"chatbot_flow_steps",
+		"chatbot_flow_versions",
 		"chatbot_flows",
 		"keyword_rules",
 		"chatbot_settings",
 		"ai_contexts",
+		"contact_memories",
+		"chatbot_variables",
+		"contact_consents",
+		"contact_opt_outs",
+		"contact_imports",
+		"contact_bulk_updates",
+		"segments",
+		"transfer_assignment_offers",
+		"chatbot_unanswered_questions",
 		"agent_transfers",
+		"wrap_up_codes",
 		// WhatsApp tables
+		"message_approvals",
+		"analytics_exports",
+		"public_dashboards",
+		"outbox_messages",
+		"message_status_events",
+		"transactional_sends",
+		"verifications",
 		"messages",
+		"archived_messages",
 		"contacts",
+		"email_gateways",
 		"templates",
 		"whatsapp_flows",
 		"whatsapp_accounts",
@@ -142,12 +215,33 @@ func cleanupTables(db *gorm.DB) {
 		"permissions",
 		// Core tables
 		"team_members",
+		"agent_shifts",
 		"teams",
+		"api_usage_daily",
 		"api_keys",
 		"sso_providers",
+		"google_sheets_connections",
+		"calendar_connections",
+		"smtp_settings",
 		"webhooks",
+		"notification_channels",
+		"enrichment_providers",
+		"announcement_reads",
+		"announcements",
+		"script_executions",
+		"pipeline_scripts",
+		"organization_plugins",
+		"organization_encryption_keys",
+		"feature_flag_overrides",
+		"feature_flags",
 		"custom_actions",
+		"canned_response_usages",
+		"canned_responses",
 		"user_availability_logs",
+		"audit_logs",
+		"impersonation_sessions",
+		"user_organizations",
+		"organization_invites",
 		"users",
 		"organizations",
 	}
@@ -161,18 +255,42 @@ func cleanupTables(db *gorm.DB) {
 func TruncateTables(db *gorm.DB) {
 	tables := []string{
 		"bulk_message_recipients",
+		"recipient_imports",
+		"template_send_items",
+		"template_send_batches",
 		"bulk_message_campaigns",
 		"notification_rules",
+		"chatbot_session_traces",
 		"chatbot_session_messages",
 		"chatbot_sessions",
 		"chatbot_flow_steps",
+		"chatbot_flow_versions",
 		"chatbot_flows",
 		"keyword_rules",
 		"chatbot_settings",
 		"ai_contexts",
+		"contact_memories",
+		"chatbot_variables",
+		"contact_consents",
+		"contact_opt_outs",
+		"contact_imports",
+		"contact_bulk_updates",
+		"segments",
+		"transfer_assignment_offers",
+		"chatbot_unanswered_questions",
 		"agent_transfers",
+		"wrap_up_codes",
+		"message_approvals",
+		"analytics_exports",
+		"public_dashboards",
+		"outbox_messages",
+		"message_status_events",
+		"transactional_sends",
+		"verifications",
 		"messages",
+		"archived_messages",
 		"contacts",
+		"email_gateways",
 		"templates",
 		"whatsapp_flows",
 		"whatsapp_accounts",
@@ -180,12 +298,33 @@ func TruncateTables(db *gorm.DB) {
 		"custom_roles",
 		"permissions",
 		"team_members",
+		"agent_shifts",
 		"teams",
+		"api_usage_daily",
 		"api_keys",
 		"sso_providers",
+		"google_sheets_connections",
+		"calendar_connections",
+		"smtp_settings",
 		"webhooks",
+		"notification_channels",
+		"enrichment_providers",
+		"announcement_reads",
+		"announcements",
+		"script_executions",
+		"pipeline_scripts",
+		"organization_plugins",
+		"organization_encryption_keys",
+		"feature_flag_overrides",
+		"feature_flags",
 		"custom_actions",
+		"canned_response_usages",
+		"canned_responses",
 		"user_availability_logs",
+		"audit_logs",
+		"impersonation_sessions",
+		"user_organizations",
+		"organization_invites",
 		"users",
 		"organizations",
 	}
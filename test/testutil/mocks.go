@@ -286,6 +286,9 @@ type MockQueue struct {
 	mu   sync.Mutex
 	Jobs []*queue.RecipientJob
 
+	TemplateSendJobs      []*queue.TemplateSendJob
+	ContactBulkUpdateJobs []*queue.ContactBulkUpdateJob
+
 	// Configurable behavior
 	EnqueueFunc  func(ctx context.Context, job *queue.RecipientJob) error
 	EnqueuesFunc func(ctx context.Context, jobs []*queue.RecipientJob) error
@@ -335,6 +338,32 @@ func (m *MockQueue) EnqueueRecipients(ctx context.Context, jobs []*queue.Recipie
 	return nil
 }
 
+// EnqueueTemplateSends mocks enqueueing bulk template send jobs.
+func (m *MockQueue) EnqueueTemplateSends(ctx context.Context, jobs []*queue.TemplateSendJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+
+	m.TemplateSendJobs = append(m.TemplateSendJobs, jobs...)
+	return nil
+}
+
+// EnqueueContactBulkUpdate mocks enqueueing a bulk contact update job.
+func (m *MockQueue) EnqueueContactBulkUpdate(ctx context.Context, job *queue.ContactBulkUpdateJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Error != nil {
+		return m.Error
+	}
+
+	m.ContactBulkUpdateJobs = append(m.ContactBulkUpdateJobs, job)
+	return nil
+}
+
 // Close is a no-op for the mock.
 func (m *MockQueue) Close() error {
 	return nil